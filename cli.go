@@ -0,0 +1,2750 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/capsulediff"
+	"QLP/internal/catalog"
+	"QLP/internal/config"
+	"QLP/internal/constraints"
+	"QLP/internal/dag"
+	"QLP/internal/database"
+	"QLP/internal/deployment/azure"
+	"QLP/internal/deployments"
+	"QLP/internal/e2e"
+	"QLP/internal/github"
+	"QLP/internal/hitl"
+	"QLP/internal/importer"
+	"QLP/internal/install"
+	"QLP/internal/llm"
+	"QLP/internal/logger"
+	"QLP/internal/models"
+	"QLP/internal/modify"
+	"QLP/internal/operator"
+	"QLP/internal/orchestrator"
+	"QLP/internal/packaging"
+	"QLP/internal/preview"
+	"QLP/internal/promotion"
+	"QLP/internal/remediate"
+	"QLP/internal/report"
+	"QLP/internal/requirements"
+	"QLP/internal/sandbox"
+	"QLP/internal/storage"
+	"QLP/internal/tenants"
+	"QLP/internal/tfstate"
+	"QLP/internal/validation"
+	"QLP/internal/workspace"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// jsonOutput switches every command to a single JSON document on stdout
+var jsonOutput bool
+
+// profileName selects the qlp.yaml profile (QLP_PROFILE when empty)
+var profileName string
+
+// activeConfig is the configuration applied by initProcess
+var activeConfig = &config.Effective{}
+
+// errValidationFailed makes qlp validate exit non-zero without repeating the report
+var errValidationFailed = errors.New("validation failed")
+
+// errNotReproduced makes qlp capsule reproduce exit non-zero after its report
+var errNotReproduced = errors.New("capsule not reproduced")
+
+func newRootCommand() *cobra.Command {
+	var opts generateOptions
+	root := &cobra.Command{
+		Use:   "qlp [intent]",
+		Short: "QuantumLayer universal agent orchestration",
+		Long: `QuantumLayer turns natural-language intents into validated, deployable capsules.
+
+Run without arguments for the interactive menu. "qlp <intent>" is shorthand
+for "qlp generate <intent>".`,
+		Args:          cobra.ArbitraryArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return initProcess(jsonOutput)
+		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			logger.Sync()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 || opts.requirementsFile != "" {
+				return runGenerate(strings.Join(args, " "), opts)
+			}
+			rt := startRuntime()
+			defer rt.Close()
+			return runMenu(rt)
+		},
+	}
+	root.PersistentFlags().BoolVar(&jsonOutput, "json", false, "print a machine-readable JSON result on stdout")
+	root.PersistentFlags().StringVar(&profileName, "profile", "", "qlp.yaml profile to apply, e.g. dev, staging or prod")
+	opts.register(root)
+
+	root.AddCommand(
+		newGenerateCommand(),
+		newValidateCommand(),
+		newImportCommand(),
+		newModifyCommand(),
+		newDeployCommand(),
+		newCleanupCommand(),
+		newDriftCommand(),
+		newCapsuleCommand(),
+		newPreviewCommand(),
+		newHistoryCommand(),
+		newHITLCommand(),
+		newRetryFailedCommand(),
+		newResumeCommand(),
+		newConfigCommand(),
+		newAdminCommand(),
+		newAllInOneCommand(),
+		newOperatorCommand(),
+		newInstallCommand(),
+	)
+	return root
+}
+
+// execute runs the command line and prints failures to stderr
+func execute(root *cobra.Command) error {
+	err := root.Execute()
+	if err != nil && !errors.Is(err, errValidationFailed) && !errors.Is(err, errNotReproduced) {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+	}
+	return err
+}
+
+type generateOptions struct {
+	constraintsFile  string
+	requirementsFile string
+	workspace        string
+	tenantID         string
+	seed             int
+}
+
+func (o *generateOptions) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.constraintsFile, "constraints", "", "JSON file of organization constraints the output must follow")
+	cmd.Flags().StringVar(&o.requirementsFile, "requirements", "", "requirements document to implement: Markdown, PDF, docx, text or a WebVTT/SRT transcript")
+	cmd.Flags().StringVar(&o.workspace, "workspace", "", `workspace to extend by ID or name ("last" for the most recent)`)
+	cmd.Flags().StringVar(&o.tenantID, "tenant", "", "tenant the intent runs for, metered against its execution quota")
+	cmd.Flags().IntVar(&o.seed, "seed", -1, "sampling seed sent to providers that support one, for reproducible runs")
+}
+
+func newGenerateCommand() *cobra.Command {
+	var opts generateOptions
+	cmd := &cobra.Command{
+		Use:   "generate <intent>",
+		Short: "Generate a capsule from an intent",
+		Example: `  qlp generate "Create a REST API for user management with JWT authentication"
+  qlp generate --workspace shop "add a billing service"
+  qlp generate --seed 42 "Create a URL shortener"
+  qlp generate --requirements spec.pdf "Build the order service"`,
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && opts.requirementsFile == "" {
+				return errors.New("requires an intent or a --requirements document")
+			}
+			return runGenerate(strings.Join(args, " "), opts)
+		},
+	}
+	opts.register(cmd)
+	return cmd
+}
+
+// generateResult is the --json output of qlp generate
+type generateResult struct {
+	Intent       string `json:"intent"`
+	IntentID     string `json:"intent_id,omitempty"`
+	CapsuleID    string `json:"capsule_id,omitempty"`
+	Status       string `json:"status"`
+	OverallScore int    `json:"overall_score"`
+	DurationMS   int64  `json:"duration_ms"`
+	Workspace    string `json:"workspace,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+func runGenerate(intentText string, opts generateOptions) error {
+	var intentConstraints *models.Constraints
+	if opts.constraintsFile != "" {
+		c, err := loadConstraints(opts.constraintsFile)
+		if err != nil {
+			return fmt.Errorf("invalid constraints file: %w", err)
+		}
+		intentConstraints = c
+	}
+	var attachment *requirements.Attachment
+	if opts.requirementsFile != "" {
+		data, err := os.ReadFile(opts.requirementsFile)
+		if err != nil {
+			return fmt.Errorf("failed to read requirements document: %w", err)
+		}
+		attachment = &requirements.Attachment{Name: filepath.Base(opts.requirementsFile), Data: data}
+		if intentText == "" {
+			intentText = "Build the system described in " + attachment.Name
+		}
+	}
+
+	rt := startRuntime()
+	defer rt.Close()
+
+	ctx := rt.ctx
+	if opts.tenantID != "" {
+		ctx = audit.WithTenant(ctx, opts.tenantID)
+	}
+	if opts.seed >= 0 {
+		seed := opts.seed
+		ctx = llm.WithParameters(ctx, llm.Parameters{Seed: &seed})
+	}
+	if attachment != nil {
+		ctx = requirements.WithAttachment(ctx, *attachment)
+	}
+	if opts.workspace != "" {
+		if _, err := rt.openWorkspaces(); err != nil {
+			return fmt.Errorf("failed to open workspaces: %w", err)
+		}
+		ctx = workspace.WithWorkspace(ctx, opts.workspace)
+	}
+
+	startTime := time.Now()
+	err := processSingleIntent(ctx, rt.orch, intentText, intentConstraints)
+	if errors.Is(err, dag.ErrDraining) {
+		logger.Logger.Warn("Intent interrupted by shutdown, unfinished tasks were checkpointed; finish them with qlp resume",
+			zap.Error(err))
+	}
+
+	if jsonOutput {
+		result := generateResult{
+			Intent:     intentText,
+			Status:     string(models.IntentStatusFailed),
+			DurationMS: time.Since(startTime).Milliseconds(),
+			Workspace:  opts.workspace,
+		}
+		if err != nil {
+			result.Error = err.Error()
+		} else if intent, capsuleID := rt.orch.LastResult(); intent != nil {
+			result.IntentID = intent.ID
+			result.CapsuleID = capsuleID
+			result.Status = string(intent.Status)
+			result.OverallScore = intent.OverallScore
+		}
+		printJSON(result)
+	}
+	return err
+}
+
+type validateOptions struct {
+	constraintsFile string
+	minScore        int
+	sarifFile       string
+	tenantID        string
+}
+
+func newValidateCommand() *cobra.Command {
+	var opts validateOptions
+	cmd := &cobra.Command{
+		Use:   "validate <path|capsule>",
+		Short: "Validate a project directory, capsule file or stored capsule",
+		Long: `Runs static security, quality, architecture and compliance validation and
+checks the files against organization constraints. Exits non-zero when the
+overall score is below --min-score or a constraint is violated.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runValidate(cmd.Context(), args[0], opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.constraintsFile, "constraints", "", "JSON file of organization constraints to check")
+	cmd.Flags().IntVar(&opts.minScore, "min-score", 70, "minimum overall score to pass")
+	cmd.Flags().StringVar(&opts.sarifFile, "sarif", "", "also write the findings as SARIF 2.1 to this file")
+	cmd.Flags().StringVar(&opts.tenantID, "tenant", "", "tenant whose constraint defaults apply")
+	return cmd
+}
+
+// validateReport is the --json output of qlp validate
+type validateReport struct {
+	Target     string                             `json:"target"`
+	CapsuleID  string                             `json:"capsule_id"`
+	Files      int                                `json:"files"`
+	Passed     bool                               `json:"passed"`
+	MinScore   int                                `json:"min_score"`
+	Validation *validation.StaticValidationResult `json:"validation"`
+	Violations []constraints.Violation            `json:"constraint_violations,omitempty"`
+}
+
+func runValidate(ctx context.Context, target string, opts validateOptions) error {
+	if opts.tenantID != "" {
+		ctx = audit.WithTenant(ctx, opts.tenantID)
+	}
+	files, capsuleID, err := loadCapsule(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	var intentConstraints *models.Constraints
+	if opts.constraintsFile != "" {
+		if intentConstraints, err = loadConstraints(opts.constraintsFile); err != nil {
+			return fmt.Errorf("invalid constraints file: %w", err)
+		}
+	}
+	if _, err := constraints.InitFromEnv(); err != nil {
+		logger.Logger.Warn("Tenant constraint defaults disabled", zap.Error(err))
+	}
+
+	result, err := validation.NewStaticValidator(llm.NewLLMClient()).ValidateQuantumDrop(ctx, capsuleDrop(capsuleID, files))
+	if err != nil {
+		return fmt.Errorf("validation failed to run: %w", err)
+	}
+
+	report := validateReport{
+		Target:     target,
+		CapsuleID:  capsuleID,
+		Files:      len(files),
+		MinScore:   opts.minScore,
+		Validation: result,
+		Violations: constraints.Validate(constraints.Resolve(audit.TenantFromContext(ctx), intentConstraints), files),
+	}
+	report.Passed = result.OverallScore >= opts.minScore && len(report.Violations) == 0
+
+	// Revalidating a stored capsule refreshes its health in the service catalog
+	if _, err := os.Stat(target); err != nil {
+		if store, err := openArtifactStore(); err == nil {
+			if err := catalog.New(store).RecordValidation(ctx, capsuleID, result.OverallScore, report.Passed); err != nil && !errors.Is(err, catalog.ErrNotFound) {
+				logger.Logger.Warn("Failed to update the service catalog", zap.Error(err))
+			}
+		}
+	}
+
+	if opts.sarifFile != "" {
+		data, err := result.SARIF(validation.DefaultSARIFLocation(files)).Marshal()
+		if err == nil {
+			err = os.WriteFile(opts.sarifFile, data, 0644)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write SARIF: %w", err)
+		}
+	}
+
+	if jsonOutput {
+		printJSON(report)
+	} else {
+		fmt.Printf("🔍 %s (%d files)\n", target, report.Files)
+		fmt.Printf("   Overall %d/100 (security %d, quality %d, architecture %d, compliance %d)\n",
+			result.OverallScore, result.SecurityScore, result.QualityScore, result.ArchitectureScore, result.ComplianceScore)
+		for _, issue := range result.Issues {
+			fmt.Printf("   - [%s] %s\n", issue.Severity, issue.Message)
+		}
+		for _, v := range report.Violations {
+			fmt.Printf("   - %s\n", v)
+		}
+		if report.Passed {
+			fmt.Println("✅ Validation passed")
+		} else {
+			fmt.Printf("❌ Validation failed (minimum score %d)\n", opts.minScore)
+		}
+	}
+
+	if !report.Passed {
+		return errValidationFailed
+	}
+	return nil
+}
+
+type importOptions struct {
+	ref          string
+	name         string
+	tenantID     string
+	reportFile   string
+	reportFormat string
+	skipTests    bool
+	minScore     int
+	baselines    []string
+	patchFile    string
+}
+
+func newImportCommand() *cobra.Command {
+	var opts importOptions
+	cmd := &cobra.Command{
+		Use:   "import <path|git-url>",
+		Short: "Import an existing repository, assess it and suggest modernization intents",
+		Long: `Ingests a local directory or git repository into a QuantumDrop, stores it
+in artifact storage and runs the static, security, infrastructure, Dockerfile
+and sandboxed test validation against it. The assessment lists scores,
+findings and suggested modernization intents; the stored drop can then be
+passed to qlp validate or qlp deploy by its ID.`,
+		Example: `  qlp import ./legacy-billing
+  qlp import https://github.com/acme/orders.git --ref main --report assessment.md`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImport(cmd.Context(), args[0], opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.ref, "ref", "", "branch or tag to clone for git URLs")
+	cmd.Flags().StringVar(&opts.name, "name", "", "project name (default the directory or repository name)")
+	cmd.Flags().StringVar(&opts.tenantID, "tenant", storage.DefaultTenant, "tenant that owns the imported drop")
+	cmd.Flags().StringVar(&opts.reportFile, "report", "", "also write the assessment report to this file")
+	cmd.Flags().StringVar(&opts.reportFormat, "format", "markdown", "report format: markdown, html, sarif or junit")
+	cmd.Flags().BoolVar(&opts.skipTests, "skip-tests", false, "do not run the project's test suites in sandbox containers")
+	cmd.Flags().IntVar(&opts.minScore, "min-score", 0, "exit non-zero when the overall score is below this")
+	cmd.Flags().StringSliceVar(&opts.baselines, "baseline", nil, "compliance baseline profiles to check infrastructure against, e.g. cis,pci-dss (default the tenant's)")
+	cmd.Flags().StringVar(&opts.patchFile, "patch", "", "also write the remediation patches for the findings to this file")
+	return cmd
+}
+
+func runImport(ctx context.Context, source string, opts importOptions) error {
+	if err := validation.LookupBaselines(opts.baselines); err != nil {
+		return err
+	}
+	importOpts := importer.DefaultOptions()
+	importOpts.Ref = opts.ref
+	dir, cleanup, err := importer.Fetch(ctx, source, importOpts)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	name := opts.name
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(strings.TrimRight(source, "/")), ".git")
+	}
+	drop, inv, err := importer.Ingest(dir, name, importOpts)
+	if err != nil {
+		return err
+	}
+	inv.Source = source
+	fmt.Fprintf(console, "📥 Imported %s: %d files (%d skipped)\n", source, inv.Files, inv.Skipped)
+
+	store, err := openArtifactStore()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(drop)
+	if err != nil {
+		return err
+	}
+	if _, err := store.Put(ctx, opts.tenantID, drop.ID, "drop.json", bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to store imported drop: %w", err)
+	}
+
+	if _, err := constraints.InitFromEnv(); err != nil {
+		logger.Logger.Warn("Tenant constraint defaults disabled", zap.Error(err))
+	}
+	analyzer := &importer.Analyzer{
+		Static:      validation.NewStaticValidator(llm.NewLLMClient()),
+		Infra:       validation.NewInfrastructureValidator(),
+		Dockerfiles: validation.NewDockerfileValidator(),
+		PowerShell:  validation.NewPowerShellValidator(),
+	}
+	if !opts.skipTests {
+		analyzer.Tests = sandbox.NewTestRunner()
+	}
+	fmt.Fprintf(console, "🔍 Assessing %s\n", drop.ID)
+	ctx = validation.WithBaselines(audit.WithTenant(ctx, opts.tenantID), opts.baselines)
+	assessment := analyzer.Analyze(ctx, drop, inv)
+
+	if opts.reportFile != "" {
+		content, _, err := report.Render(assessment.Report(), opts.reportFormat)
+		if err == nil {
+			err = os.WriteFile(opts.reportFile, content, 0644)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+	if opts.patchFile != "" && len(assessment.Patches) > 0 {
+		if err := os.WriteFile(opts.patchFile, []byte(remediate.Combined(assessment.Patches)), 0644); err != nil {
+			return fmt.Errorf("failed to write patch: %w", err)
+		}
+	}
+
+	if jsonOutput {
+		printJSON(assessment)
+	} else {
+		fmt.Printf("📊 %s: overall %d/100 (%s)\n", name, assessment.OverallScore, drop.ID)
+		if s := assessment.Static; s != nil {
+			fmt.Printf("   Security %d, quality %d, architecture %d, compliance %d\n",
+				s.SecurityScore, s.QualityScore, s.ArchitectureScore, s.ComplianceScore)
+		}
+		for stage, reason := range assessment.Errors {
+			fmt.Printf("   ⚠️  %s did not complete: %s\n", stage, reason)
+		}
+		if len(assessment.Patches) > 0 {
+			fmt.Println("🩹 Remediation patches (write them with --patch, apply with git apply):")
+			for _, p := range assessment.Patches {
+				fmt.Printf("   %s: %s\n", p.File, strings.Join(p.Changes, "; "))
+			}
+		}
+		if len(assessment.Suggestions) > 0 {
+			fmt.Println("💡 Suggested modernization intents:")
+			for _, s := range assessment.Suggestions {
+				fmt.Printf("   [%s] %s (%s)\n      %s\n", s.Priority, s.Title, s.Reason, s.Intent)
+			}
+		}
+		fmt.Printf("   Deploy for validation with: qlp deploy %s\n", drop.ID)
+		if assessment.OverallScore < opts.minScore {
+			fmt.Printf("❌ Overall score below the minimum of %d\n", opts.minScore)
+		}
+	}
+
+	if assessment.OverallScore < opts.minScore {
+		return errValidationFailed
+	}
+	return nil
+}
+
+type modifyOptions struct {
+	base      string
+	branch    string
+	push      bool
+	patchFile string
+	prFile    string
+	skipTests bool
+	openPR    bool
+	repo      string
+}
+
+func newModifyCommand() *cobra.Command {
+	var opts modifyOptions
+	cmd := &cobra.Command{
+		Use:   "modify <path|git-url|capsule> <intent>",
+		Short: "Change existing code and export the result as a git branch",
+		Long: `Plans the change intent as edits to an existing repository, runs the
+project's test suites in sandbox containers before and after, and revises
+the edits until the build passes without new test failures. Repositories
+(local git checkouts or git URLs) get the change committed on a new branch,
+pushed back with --push; the patch and pull request description can also
+be written to files. Stored capsules and drops get the patch only.`,
+		Example: `  qlp modify ./orders-api "add rate limiting middleware to this API"
+  qlp modify https://github.com/acme/orders.git "add request tracing" --push --pr-description pr.md`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runModify(cmd.Context(), args[0], strings.Join(args[1:], " "), opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.base, "base", "", "branch or tag to change (default the repository's default branch)")
+	cmd.Flags().StringVar(&opts.branch, "branch", "", "name of the new branch (default qlp/<title>)")
+	cmd.Flags().BoolVar(&opts.push, "push", false, "push the branch to the source repository")
+	cmd.Flags().StringVar(&opts.patchFile, "patch", "", "also write the change as a patch to this file")
+	cmd.Flags().StringVar(&opts.prFile, "pr-description", "", "write the pull request title and description to this file")
+	cmd.Flags().BoolVar(&opts.skipTests, "skip-tests", false, "do not run the test suites in sandbox containers")
+	cmd.Flags().BoolVar(&opts.openPR, "open-pr", false, "open a GitHub pull request for the pushed branch (implies --push)")
+	cmd.Flags().StringVar(&opts.repo, "github-repo", "", "GitHub repository owner/name for --open-pr (default the git URL's)")
+	return cmd
+}
+
+func runModify(ctx context.Context, target, intentText string, opts modifyOptions) error {
+	var files map[string]string
+	repo := ""
+	if info, err := os.Stat(target); importer.IsGitURL(target) || (err == nil && info.IsDir()) {
+		importOpts := importer.DefaultOptions()
+		importOpts.Ref = opts.base
+		dir, cleanup, err := importer.Fetch(ctx, target, importOpts)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		drop, _, err := importer.Ingest(dir, filepath.Base(target), importOpts)
+		if err != nil {
+			return err
+		}
+		files = drop.Files
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			repo = target
+		}
+	} else if files, _, err = loadCapsule(ctx, target); err != nil {
+		return err
+	}
+
+	var runner modify.Runner
+	if !opts.skipTests {
+		runner = sandbox.NewTestRunner()
+	}
+	fmt.Fprintf(console, "🛠️  Planning: %s\n", intentText)
+	result, err := modify.NewAgent(llm.NewLLMClient(), runner, modify.ConfigFromEnv()).Modify(ctx, intentText, files)
+	if err != nil {
+		return fmt.Errorf("modification failed: %w", err)
+	}
+	title, body := result.PullRequest()
+
+	var branch *modify.Branch
+	if repo != "" {
+		if branch, err = modify.ExportBranch(ctx, repo, result, modify.BranchOptions{
+			Branch: opts.branch,
+			Base:   opts.base,
+			Push:   opts.push || opts.openPR,
+		}); err != nil {
+			return fmt.Errorf("failed to export branch: %w", err)
+		}
+	}
+
+	var pr *github.PullRequest
+	if opts.openPR {
+		if branch == nil {
+			return errors.New("--open-pr needs a git repository, not a capsule")
+		}
+		if pr, err = openModifyPullRequest(ctx, branch, opts, title, body); err != nil {
+			return err
+		}
+	}
+
+	if opts.patchFile != "" {
+		patch := result.Patch()
+		if branch != nil {
+			patch = branch.Patch
+		}
+		if err := os.WriteFile(opts.patchFile, []byte(patch), 0644); err != nil {
+			return fmt.Errorf("failed to write patch: %w", err)
+		}
+	}
+	if opts.prFile != "" {
+		if err := os.WriteFile(opts.prFile, []byte("# "+title+"\n\n"+body), 0644); err != nil {
+			return fmt.Errorf("failed to write pull request description: %w", err)
+		}
+	}
+
+	if jsonOutput {
+		printJSON(map[string]interface{}{
+			"result":       result,
+			"branch":       branch,
+			"pull_request": map[string]interface{}{"title": title, "body": body, "github": pr},
+		})
+	} else {
+		fmt.Printf("📝 %s: %d files changed (+%d −%d) after %d plan(s)\n",
+			title, len(result.Diff.Changes), result.Diff.Additions, result.Diff.Deletions, result.Iterations)
+		for _, c := range result.Diff.Changes {
+			fmt.Printf("   %-8s %s\n", c.Kind, c.Path)
+		}
+		switch {
+		case runner == nil:
+		case !result.Verified:
+			fmt.Println("   ⚠️  Tests could not run in the sandbox; the change is untested")
+		case result.Passed:
+			fmt.Println("   ✅ Builds with no new test failures")
+		default:
+			fmt.Println("   ❌ The change breaks the build or adds test failures")
+		}
+		if branch != nil {
+			where := "in a local clone only; use --push to publish it"
+			if branch.Pushed {
+				where = "pushed to " + branch.Remote
+			}
+			fmt.Printf("🌿 Branch %s at %.12s, %s\n", branch.Name, branch.Commit, where)
+		}
+		if pr != nil {
+			fmt.Printf("🔀 Opened pull request #%d: %s\n", pr.Number, pr.HTMLURL)
+		}
+	}
+
+	if runner != nil && result.Verified && !result.Passed {
+		return errValidationFailed
+	}
+	return nil
+}
+
+// openModifyPullRequest opens a pull request for a pushed modify branch
+func openModifyPullRequest(ctx context.Context, branch *modify.Branch, opts modifyOptions, title, body string) (*github.PullRequest, error) {
+	source := opts.repo
+	if source == "" {
+		source = branch.Remote
+	}
+	repo, err := github.ParseRepo(source)
+	if err != nil {
+		return nil, fmt.Errorf("%w; pass --github-repo owner/name", err)
+	}
+	client, err := github.NewClientFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	pr, err := client.CreatePullRequest(ctx, repo, branch.Name, opts.base, title, body, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pull request: %w", err)
+	}
+	return pr, nil
+}
+
+type deployOptions struct {
+	provider  string
+	location  string
+	ttl       time.Duration
+	costLimit float64
+	strategy  string
+	rollout   azure.StrategyConfig
+}
+
+func newDeployCommand() *cobra.Command {
+	var opts deployOptions
+	cmd := &cobra.Command{
+		Use:   "deploy <capsule>",
+		Short: "Deploy a capsule to a temporary cloud environment for validation",
+		Example: `  qlp deploy QL-CAP-1234 --provider azure
+  qlp deploy ./output/capsule.qlcapsule --provider azure --ttl 1h --json
+  qlp deploy QL-CAP-1234 --strategy canary --app orders --image acr.io/orders:2 --canary-steps 10,50
+  qlp deploy rollback DEP-1718000000000000000 --reason "500s after release"`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDeploy(cmd.Context(), args[0], opts)
+		},
+	}
+	cmd.AddCommand(newDeployHistoryCommand(), newDeployRollbackCommand())
+	cmd.Flags().StringVar(&opts.provider, "provider", "azure", "cloud provider (azure)")
+	cmd.Flags().StringVar(&opts.location, "location", "", "cloud region (default AZURE_LOCATION or westeurope)")
+	cmd.Flags().DurationVar(&opts.ttl, "ttl", time.Hour, "time before the deployment is cleaned up")
+	cmd.Flags().Float64Var(&opts.costLimit, "cost-limit", 10, "maximum estimated cost in USD")
+	cmd.Flags().StringVar(&opts.strategy, "strategy", "recreate", "rollout strategy: recreate, blue_green or canary")
+	cmd.Flags().StringVar((*string)(&opts.rollout.Platform), "platform", string(azure.PlatformAppService), "where blue/green and canary shift traffic: app_service (slots) or kubernetes")
+	cmd.Flags().StringVar(&opts.rollout.App, "app", "", "App Service app, or Kubernetes service, to roll out to")
+	cmd.Flags().StringVar(&opts.rollout.Image, "image", "", "container image of the new version")
+	cmd.Flags().StringVar(&opts.rollout.Slot, "slot", "", "App Service slot the new version is staged in (default staging)")
+	cmd.Flags().StringVar(&opts.rollout.Namespace, "namespace", "", "Kubernetes namespace (default default)")
+	cmd.Flags().IntSliceVar(&opts.rollout.CanarySteps, "canary-steps", azure.DefaultCanarySteps, "traffic percentages of the canary before the full switch")
+	cmd.Flags().DurationVar(&opts.rollout.StepWait, "step-wait", 0, "time each canary step takes traffic before it is verified")
+	return cmd
+}
+
+func runDeploy(ctx context.Context, target string, opts deployOptions) error {
+	if opts.provider != "azure" {
+		return fmt.Errorf("unsupported provider %q (supported: azure)", opts.provider)
+	}
+	subscriptionID := config.GetEnvOrDefault("AZURE_SUBSCRIPTION_ID", "")
+	if subscriptionID == "" {
+		return errors.New("AZURE_SUBSCRIPTION_ID is not set")
+	}
+
+	strategy, err := azure.ParseStrategy(opts.strategy)
+	if err != nil {
+		return err
+	}
+	opts.rollout.Strategy = strategy
+	if err := opts.rollout.Validate(); err != nil {
+		return err
+	}
+
+	files, capsuleID, err := loadCapsule(ctx, target)
+	if err != nil {
+		return err
+	}
+	initSecrets()
+
+	location := opts.location
+	if location == "" {
+		location = config.GetEnvOrDefault("AZURE_LOCATION", "westeurope")
+	}
+	clientConfig := azure.ClientConfigFromEnv()
+	clientConfig.SubscriptionID = subscriptionID
+	clientConfig.Location = location
+	client, err := azure.NewAzureClient(clientConfig)
+	if err != nil {
+		return err
+	}
+
+	deployConfig := azure.DeploymentConfig{
+		CapsuleID:       capsuleID,
+		ResourceGroup:   azure.GenerateResourceGroupName(capsuleID),
+		Location:        location,
+		FallbackRegions: azure.ParseRegions(config.GetEnvOrDefault("QLP_AZURE_FALLBACK_REGIONS", "")),
+		TTL:             opts.ttl,
+		CostLimitUSD:    opts.costLimit,
+		Strategy:        opts.rollout,
+	}
+	if config.GetEnvOrDefault("QLP_TFSTATE_VALIDATE", "true") == "true" {
+		backend := azure.ValidationStateBackend(capsuleID)
+		deployConfig.StateBackend = &backend
+		deployConfig.ProvisionStateBackend = config.GetEnvOrDefault("QLP_TFSTATE_PROVISION", "true") == "true"
+	}
+
+	fmt.Fprintf(console, "☁️  Deploying %s to Azure (%s)\n", capsuleID, location)
+	result, err := azure.NewDeploymentManager(client, opts.costLimit).Deploy(ctx, capsuleDrop(capsuleID, files), deployConfig)
+	if result != nil {
+		if history, herr := openDeploymentHistory(); herr != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Deployment not recorded: %v\n", herr)
+		} else {
+			recordDeployment(history, deployments.Record{
+				CapsuleID:      capsuleID,
+				Target:         deployConfig.ResourceGroup,
+				Environment:    "validation",
+				SubscriptionID: subscriptionID,
+				StateBackend:   deployConfig.StateBackend,
+				Image:          opts.rollout.Image,
+				Source:         deployments.SourceDeploy,
+				Actor:          config.GetEnvOrDefault("QLP_ACTOR", config.GetEnvOrDefault("USER", "system")),
+			}, files, result, err)
+		}
+		if jsonOutput {
+			printJSON(result)
+		} else {
+			fmt.Printf("📦 %s → %s: %s (estimated $%.2f)\n", result.CapsuleID, result.ResourceGroup, result.Status, result.CostEstimate.TotalUSD)
+			if result.RequestedLocation != "" {
+				fmt.Printf("   Moved from %s to %s after preflight: %s\n", result.RequestedLocation, result.Location, strings.Join(result.AvailabilityIssues, "; "))
+			}
+			if result.ActualCost != nil {
+				fmt.Printf("   Actual cost so far: %.2f %s (Cost Management lags usage by hours)\n", result.ActualCost.TotalUSD, result.ActualCost.Currency)
+			}
+			if result.CostAlert != nil {
+				fmt.Printf("   ⚠️  %s\n", result.CostAlert.Message)
+			}
+			if s := result.Strategy; s != nil {
+				fmt.Printf("   %s rollout of %s: %s\n", s.Strategy, s.Release.Image, s.Status)
+				for _, step := range s.Steps {
+					if step.Status != "pass" {
+						fmt.Printf("     %s failed: %s\n", step.Action, step.Message)
+					}
+				}
+			}
+			for name, test := range result.TestResults {
+				if strings.HasPrefix(name, "terraform_init") && test.Status != "pass" {
+					fmt.Printf("   %s: %s\n", name, test.Status)
+				}
+			}
+			if result.ErrorMessage != "" {
+				fmt.Printf("   %s\n", result.ErrorMessage)
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("deployment failed: %w", err)
+	}
+	if result.Status == azure.StatusFailed || result.Status == azure.StatusUnhealthy {
+		return fmt.Errorf("deployment %s", result.Status)
+	}
+	return nil
+}
+
+// promotionDeployer deploys promoted capsules to the subscription and
+// location of their environment, in a resource group per environment with
+// the Terraform state kept in that subscription. Deployments are recorded in
+// history, when set, so a failed one can be rolled back.
+func promotionDeployer(ttl time.Duration, costLimit float64, history *deployments.History) promotion.DeployerFunc {
+	return func(ctx context.Context, target promotion.Target) (*promotion.Deployment, error) {
+		env := target.Environment
+		clientConfig := azure.ClientConfigFromEnv()
+		if env.SubscriptionID != "" {
+			clientConfig.SubscriptionID = env.SubscriptionID
+		}
+		if env.Location != "" {
+			clientConfig.Location = env.Location
+		}
+		if clientConfig.SubscriptionID == "" {
+			return nil, fmt.Errorf("environment %s has no subscription", env.Name)
+		}
+		client, err := azure.NewAzureClient(clientConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		deployConfig := azure.DeploymentConfig{
+			CapsuleID:       target.CapsuleID,
+			ResourceGroup:   azure.GenerateResourceGroupName(target.CapsuleID) + "-" + env.Name,
+			Location:        clientConfig.Location,
+			FallbackRegions: azure.ParseRegions(config.GetEnvOrDefault("QLP_AZURE_FALLBACK_REGIONS", "")),
+			TTL:             ttl,
+			CostLimitUSD:    costLimit,
+		}
+		if rollout := env.Rollout; rollout != nil {
+			strategy, err := azure.ParseStrategy(rollout.Strategy)
+			if err != nil {
+				return nil, err
+			}
+			deployConfig.Strategy = azure.StrategyConfig{
+				Strategy:    strategy,
+				Platform:    azure.Platform(rollout.Platform),
+				App:         rollout.App,
+				Image:       target.Image,
+				CanarySteps: rollout.CanarySteps,
+			}
+			if deployConfig.Strategy.Platform == "" {
+				deployConfig.Strategy.Platform = azure.PlatformAppService
+			}
+			if err := deployConfig.Strategy.Validate(); err != nil {
+				return nil, fmt.Errorf("environment %s: %w", env.Name, err)
+			}
+		}
+		if config.GetEnvOrDefault("QLP_TFSTATE_VALIDATE", "true") == "true" {
+			backend := tfstate.DefaultBackend(clientConfig.SubscriptionID, target.CapsuleID)
+			backend.KeyPrefix = env.Name
+			backend.Location = clientConfig.Location
+			deployConfig.StateBackend = &backend
+			deployConfig.ProvisionStateBackend = config.GetEnvOrDefault("QLP_TFSTATE_PROVISION", "true") == "true"
+		}
+
+		result, err := azure.NewDeploymentManager(client, costLimit).Deploy(ctx, capsuleDrop(target.CapsuleID, target.Files), deployConfig)
+		if history != nil && result != nil {
+			recordDeployment(history, deployments.Record{
+				TenantID:       target.TenantID,
+				CapsuleID:      target.CapsuleID,
+				Target:         env.Name,
+				Environment:    env.Name,
+				SubscriptionID: clientConfig.SubscriptionID,
+				StateBackend:   deployConfig.StateBackend,
+				Image:          target.Image,
+				Source:         deployments.SourcePromotion,
+				PromotionID:    target.PromotionID,
+			}, target.Files, result, err)
+		}
+		deployment := &promotion.Deployment{SubscriptionID: clientConfig.SubscriptionID, Location: clientConfig.Location, ResourceGroup: deployConfig.ResourceGroup}
+		if result != nil {
+			deployment.Location = result.Location
+			deployment.Status = string(result.Status)
+			deployment.RolledBack = result.Strategy != nil && result.Strategy.RolledBack
+			deployment.Result = result
+		}
+		if err == nil && (result.Status == azure.StatusFailed || result.Status == azure.StatusUnhealthy) {
+			err = fmt.Errorf("deployment %s", result.Status)
+		}
+		return deployment, err
+	}
+}
+
+// openDeploymentHistory opens the deployment history in
+// QLP_DEPLOYMENT_HISTORY
+func openDeploymentHistory() (*deployments.History, error) {
+	return deployments.Open(config.GetEnvOrDefault("QLP_DEPLOYMENT_HISTORY", "./data/deployments"))
+}
+
+// recordDeployment adds the outcome of a deployment to history with a
+// snapshot of the capsule's IaC. A deployment that failed, was unhealthy or
+// whose rollout was rolled back is recorded as failed.
+func recordDeployment(history *deployments.History, record deployments.Record, files map[string]string, result *azure.DeploymentResult, err error) {
+	record.Location = result.Location
+	record.ResourceGroup = result.ResourceGroup
+	record.Status = deployments.StatusSucceeded
+	switch {
+	case err != nil:
+		record.Status, record.Error = deployments.StatusFailed, err.Error()
+	case result.Status == azure.StatusFailed || result.Status == azure.StatusUnhealthy:
+		record.Status, record.Error = deployments.StatusFailed, result.ErrorMessage
+	case result.Strategy != nil && result.Strategy.Status != azure.StrategySucceeded:
+		record.Status, record.Error = deployments.StatusFailed, result.Strategy.Error
+	}
+	if _, err := history.Add(record, deployments.IaC(files)); err != nil {
+		logger.GetDefaultLogger().WithComponent("deployments").Warn("Failed to record deployment",
+			zap.String("capsule_id", record.CapsuleID), zap.Error(err))
+	}
+}
+
+// deploymentRestorer applies IaC snapshots with terraform, using the state
+// backend key from the subscription of the deployment restored
+func deploymentRestorer() deployments.RestorerFunc {
+	return func(ctx context.Context, good deployments.Record, iac map[string]string, apply bool) ([]tfstate.PlanResult, error) {
+		clientConfig := azure.ClientConfigFromEnv()
+		clientConfig.SubscriptionID = good.SubscriptionID
+		client, err := azure.NewAzureClient(clientConfig)
+		if err != nil {
+			return nil, err
+		}
+		key, err := client.StateBackendKey(ctx, *good.StateBackend)
+		if err != nil {
+			return nil, err
+		}
+		return tfstate.Plan(ctx, iac, *good.StateBackend, []string{"ARM_ACCESS_KEY=" + key}, tfstate.CommandRunner, apply)
+	}
+}
+
+func newDeployHistoryCommand() *cobra.Command {
+	var capsule, tenant string
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "List recorded deployments, newest first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			history, err := openDeploymentHistory()
+			if err != nil {
+				return err
+			}
+			list := history.List(tenant, capsule)
+			if jsonOutput {
+				printJSON(map[string]interface{}{"deployments": list})
+				return nil
+			}
+			if len(list) == 0 {
+				fmt.Println("No deployments recorded")
+			}
+			for _, d := range list {
+				fmt.Printf("%s  %s  %-12s %-10s %-9s %s → %s\n", d.ID, d.CreatedAt.Format(time.RFC3339),
+					d.Environment, d.Source, d.Status, d.CapsuleID, d.ResourceGroup)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&capsule, "capsule", "", "only deployments of this capsule")
+	cmd.Flags().StringVar(&tenant, "tenant", "", "only deployments of this tenant")
+	return cmd
+}
+
+func newDeployRollbackCommand() *cobra.Command {
+	opts := deployments.Options{Actor: config.GetEnvOrDefault("QLP_ACTOR", config.GetEnvOrDefault("USER", ""))}
+	cmd := &cobra.Command{
+		Use:   "rollback <deployment-id>",
+		Short: "Restore the last known-good deployment before a failed one",
+		Long: `Restores the target of a deployment (its promotion environment, or the
+resource group of a validation deployment) to the last successful deployment
+before it that was not itself rolled back, by applying that deployment's IaC
+snapshot to its Terraform state again. The rollback is refused when no such
+deployment exists, when the deployment was already rolled back, and, unless
+--force is set, when a later deployment replaced it. Every rollback is
+recorded in the deployment history and the audit log.`,
+		Example: `  qlp deploy history --capsule QL-CAP-1234
+  qlp deploy rollback DEP-1718000000000000000 --reason "500s after release" --dry-run
+  qlp deploy rollback DEP-1718000000000000000 --reason "500s after release" --json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDeployRollback(cmd.Context(), args[0], opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.Reason, "reason", "", "why the deployment is rolled back (required)")
+	cmd.Flags().StringVar(&opts.Actor, "actor", opts.Actor, "who is rolling back (default QLP_ACTOR or USER)")
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "plan the restore without applying it")
+	cmd.Flags().BoolVar(&opts.Force, "force", false, "roll back even though a later deployment replaced this one")
+	return cmd
+}
+
+func runDeployRollback(ctx context.Context, id string, opts deployments.Options) error {
+	history, err := openDeploymentHistory()
+	if err != nil {
+		return err
+	}
+	initSecrets()
+	rollback, err := deployments.New(history, deploymentRestorer()).Rollback(audit.WithActor(ctx, opts.Actor), id, opts)
+	if rollback != nil {
+		if jsonOutput {
+			printJSON(rollback)
+		} else {
+			verb := "Rolled back"
+			if opts.DryRun {
+				verb = "Planned rollback of"
+			}
+			fmt.Printf("⏪ %s %s (%s) to %s (%s) in %s\n", verb, id, rollback.RolledBack.CapsuleID,
+				rollback.Restored.ID, rollback.Restored.CapsuleID, rollback.Restored.ResourceGroup)
+			for _, p := range rollback.Plans {
+				switch {
+				case p.Error != "":
+					fmt.Printf("   ❌ %s: %s\n", p.Module, p.Error)
+				case p.Applied:
+					fmt.Printf("   ✅ %s: restored\n", p.Module)
+				case p.Changes:
+					fmt.Printf("   📝 %s: changes to restore\n", p.Module)
+				default:
+					fmt.Printf("   ✅ %s: already matches\n", p.Module)
+				}
+			}
+			if rollback.Deployment != nil {
+				fmt.Printf("   Recorded as %s\n", rollback.Deployment.ID)
+			}
+		}
+	}
+	return err
+}
+
+type cleanupOptions struct {
+	dryRun   bool
+	watch    bool
+	interval time.Duration
+	grace    time.Duration
+	maxAge   time.Duration
+	runbook  string
+}
+
+func newCleanupCommand() *cobra.Command {
+	var opts cleanupOptions
+	defaults := azure.DefaultCleanupPolicy()
+	cmd := &cobra.Command{
+		Use:   "cleanup",
+		Short: "Delete validation resource groups past their TTL",
+		Long: `Deletes the QuantumLayer resource groups in the Azure subscription whose
+auto-delete-after tag has passed, enforcing deployment TTLs after the process
+that created them has exited. --watch keeps sweeping on an interval;
+--runbook writes an Azure Automation runbook that does the same inside Azure.`,
+		Example: `  qlp cleanup --dry-run
+  qlp cleanup --watch --interval 15m
+  qlp cleanup --runbook qlp-cleanup.ps1`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCleanup(cmd.Context(), opts)
+		},
+	}
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "list expired resource groups without deleting them")
+	cmd.Flags().BoolVar(&opts.watch, "watch", false, "keep sweeping every --interval until interrupted")
+	cmd.Flags().DurationVar(&opts.interval, "interval", defaults.CheckInterval, "time between sweeps with --watch")
+	cmd.Flags().DurationVar(&opts.grace, "grace", defaults.GracePeriod, "time past expiry before a group is deleted")
+	cmd.Flags().DurationVar(&opts.maxAge, "max-age", defaults.MaxAge, "delete groups older than this regardless of their TTL")
+	cmd.Flags().StringVar(&opts.runbook, "runbook", "", "write an Azure Automation runbook to this file instead of sweeping")
+	return cmd
+}
+
+func runCleanup(ctx context.Context, opts cleanupOptions) error {
+	policy := azure.DefaultCleanupPolicy()
+	policy.DryRun = opts.dryRun
+	policy.CheckInterval = opts.interval
+	policy.GracePeriod = opts.grace
+	policy.MaxAge = opts.maxAge
+	policy.PreserveOnError = false
+
+	if opts.runbook != "" {
+		if err := os.WriteFile(opts.runbook, []byte(azure.AutomationRunbook(policy)), 0644); err != nil {
+			return fmt.Errorf("failed to write runbook: %w", err)
+		}
+		fmt.Fprintf(console, "📝 Runbook written to %s; import it into an Automation account and schedule it\n", opts.runbook)
+		return nil
+	}
+
+	clientConfig := azure.ClientConfigFromEnv()
+	if clientConfig.SubscriptionID == "" {
+		return errors.New("AZURE_SUBSCRIPTION_ID is not set")
+	}
+	client, err := azure.NewAzureClient(clientConfig)
+	if err != nil {
+		return err
+	}
+	manager := azure.NewCleanupManager(client)
+
+	if opts.watch {
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+		defer stop()
+		fmt.Fprintf(console, "🧹 Sweeping expired resource groups every %s (Ctrl+C to stop)\n", opts.interval)
+		manager.StartCleanupScheduler(ctx, policy)
+		return nil
+	}
+
+	results, err := manager.Sweep(ctx, policy)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		printJSON(results)
+		return nil
+	}
+	if len(results) == 0 {
+		fmt.Println("✅ No expired resource groups")
+	}
+	failed := 0
+	for _, r := range results {
+		if r.Status == "failed" {
+			failed++
+		}
+		fmt.Printf("🧹 %s: %s\n", r.ResourceGroup, r.Status)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d resource groups could not be deleted", failed)
+	}
+	return nil
+}
+
+type driftOptions struct {
+	watch       bool
+	interval    time.Duration
+	minLifetime time.Duration
+	remediate   bool
+	webhook     string
+}
+
+func newDriftCommand() *cobra.Command {
+	var opts driftOptions
+	defaults := azure.DefaultDriftPolicy()
+	cmd := &cobra.Command{
+		Use:   "drift",
+		Short: "Check long-lived validation environments for drift from their capsules",
+		Long: `Compares the resources in each live QuantumLayer resource group with a TTL
+of at least --min-lifetime against the Terraform of the capsule deployed to it,
+and runs terraform plan against the environment's validation state. The result
+is recorded in the capsule's service catalog entry. --remediate applies the
+Terraform where the plan has changes; --alert-webhook receives the report when
+an environment starts drifting.`,
+		Example: `  qlp drift
+  qlp drift --watch --interval 30m --alert-webhook https://hooks.example.com/qlp
+  qlp drift --remediate --json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDrift(cmd.Context(), opts)
+		},
+	}
+	cmd.Flags().BoolVar(&opts.watch, "watch", false, "keep checking every --interval until interrupted")
+	cmd.Flags().DurationVar(&opts.interval, "interval", defaults.CheckInterval, "time between checks with --watch")
+	cmd.Flags().DurationVar(&opts.minLifetime, "min-lifetime", defaults.MinLifetime, "only check environments with a TTL at least this long")
+	cmd.Flags().BoolVar(&opts.remediate, "remediate", false, "apply the Terraform to environments whose plan has changes")
+	cmd.Flags().StringVar(&opts.webhook, "alert-webhook", config.GetEnvOrDefault("QLP_AZURE_DRIFT_WEBHOOK", ""), "POST the report here when an environment starts drifting")
+	return cmd
+}
+
+func runDrift(ctx context.Context, opts driftOptions) error {
+	policy := azure.DriftPolicy{
+		CheckInterval: opts.interval,
+		MinLifetime:   opts.minLifetime,
+		Remediate:     opts.remediate,
+		AlertWebhook:  opts.webhook,
+	}
+	clientConfig := azure.ClientConfigFromEnv()
+	if clientConfig.SubscriptionID == "" {
+		return errors.New("AZURE_SUBSCRIPTION_ID is not set")
+	}
+	client, err := azure.NewAzureClient(clientConfig)
+	if err != nil {
+		return err
+	}
+	store, err := openArtifactStore()
+	if err != nil {
+		return err
+	}
+	detector := newDriftDetector(client, store)
+
+	if opts.watch {
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+		defer stop()
+		fmt.Fprintf(console, "🔭 Checking validation environments for drift every %s (Ctrl+C to stop)\n", opts.interval)
+		detector.Start(ctx, policy)
+		return nil
+	}
+
+	reports, err := detector.Check(ctx, policy)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		printJSON(reports)
+		return nil
+	}
+	if len(reports) == 0 {
+		fmt.Println("✅ No long-lived validation environments")
+	}
+	drifted := 0
+	for _, r := range reports {
+		if r.Status == azure.DriftDetected {
+			drifted++
+		}
+		fmt.Printf("🔭 %s (%s): %s\n", r.ResourceGroup, r.CapsuleID, r.Status)
+		for _, m := range r.Missing {
+			fmt.Printf("   missing: %s\n", m)
+		}
+		for _, u := range r.Unmanaged {
+			fmt.Printf("   unmanaged: %s\n", u)
+		}
+		for _, p := range r.Plans {
+			if p.Changes {
+				fmt.Printf("   plan has changes in %s (applied: %t)\n", p.Module, p.Applied)
+			}
+		}
+		if r.Error != "" {
+			fmt.Printf("   %s\n", r.Error)
+		}
+	}
+	if drifted > 0 {
+		return fmt.Errorf("%d validation environments drifted", drifted)
+	}
+	return nil
+}
+
+// newDriftDetector checks environments against the capsules in store and
+// records each report in the capsule's service catalog entry
+func newDriftDetector(client *azure.AzureClient, store storage.ArtifactStore) *azure.DriftDetector {
+	detector := azure.NewDriftDetector(client, storedCapsuleLoader(store))
+	if config.GetEnvOrDefault("QLP_TFSTATE_VALIDATE", "true") == "true" {
+		detector.SetStateBackend(client, tfstate.CommandRunner)
+	}
+	services := catalog.New(store)
+	detector.OnReport(func(ctx context.Context, report *azure.DriftReport) {
+		if err := services.RecordDrift(ctx, report.CapsuleID, string(report.Status), report.CheckedAt); err != nil && !errors.Is(err, catalog.ErrNotFound) {
+			logger.Logger.Warn("Failed to record drift in the service catalog",
+				zap.String("capsule_id", report.CapsuleID), zap.Error(err))
+		}
+	})
+	return detector
+}
+
+func newCapsuleCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "capsule",
+		Short: "Export and import capsules from artifact storage, or open them as pull requests",
+	}
+
+	var output string
+	export := &cobra.Command{
+		Use:   "export <capsule-id>",
+		Short: "Copy a stored capsule to a local file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCapsuleExport(cmd.Context(), args[0], output)
+		},
+	}
+	export.Flags().StringVarP(&output, "output", "o", "", "destination file (default the artifact name in the current directory)")
+
+	var tenantID string
+	importCmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Add a capsule file to artifact storage",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCapsuleImport(cmd.Context(), args[0], tenantID)
+		},
+	}
+	importCmd.Flags().StringVar(&tenantID, "tenant", storage.DefaultTenant, "tenant that owns the capsule")
+
+	var prOpts capsulePROptions
+	pr := &cobra.Command{
+		Use:   "pr <capsule> <owner/name>",
+		Short: "Open a GitHub pull request adding a capsule's files to a repository",
+		Long: `Commits the capsule's project files onto a new branch of the GitHub
+repository and opens a pull request for it. Credentials come from
+QLP_GITHUB_APP_ID with a private key, or GITHUB_TOKEN. With the GitHub
+webhook enabled on the server, the pull request is then validated by
+QLP and the result reported as a check run.`,
+		Example: `  qlp capsule pr QL-CAP-1234 acme/orders --dir services/orders`,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCapsulePR(cmd.Context(), args[0], args[1], prOpts)
+		},
+	}
+	pr.Flags().StringVar(&prOpts.base, "base", "", "target branch (default the repository's default branch)")
+	pr.Flags().StringVar(&prOpts.branch, "branch", "", "name of the new branch (default qlp/<capsule>)")
+	pr.Flags().StringVar(&prOpts.dir, "dir", "", "directory in the repository to place the files under")
+	pr.Flags().StringVar(&prOpts.title, "title", "", "pull request title")
+	pr.Flags().BoolVar(&prOpts.draft, "draft", false, "open the pull request as a draft")
+
+	reproduce := &cobra.Command{
+		Use:   "reproduce <capsule> <rerun>...",
+		Short: "Report whether re-runs reproduced a capsule",
+		Long: `Compares the files of each re-run with the first capsule and explains
+differences by the seed, models, model versions, backend fingerprints and
+parameters recorded in the capsules' metadata. Capsules generated with
+--seed by providers that support seeds are expected to match; providers
+only make a best effort, so a re-run can differ under identical conditions.
+Exits non-zero unless every re-run reproduced the capsule.`,
+		Example: `  qlp generate --seed 42 "Create a URL shortener"   # twice, then
+  qlp capsule reproduce output/QL-CAP-1a2b.qlcapsule output/QL-CAP-3c4d.qlcapsule`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCapsuleReproduce(args[0], args[1:])
+		},
+	}
+
+	cmd.AddCommand(export, importCmd, pr, reproduce)
+	return cmd
+}
+
+func newPreviewCommand() *cobra.Command {
+	var addr string
+	cmd := &cobra.Command{
+		Use:   "preview <capsule>",
+		Short: "Review a capsule in a local web UI before deploying it",
+		Long: `Serves a local web UI showing the capsule's file tree with syntax
+highlighting and validation findings inline on the lines they concern,
+the task DAG, and the capsule's reports. The capsule is a file, a project
+directory or the ID of a stored capsule, which is read from artifact
+storage without exporting it first.`,
+		Example: `  qlp preview output/QL-CAP-1a2b.qlcapsule
+  qlp preview QL-CAP-1a2b --addr 127.0.0.1:9000`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPreview(cmd.Context(), args[0], addr)
+		},
+	}
+	cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:8787", "address to serve the preview on")
+	return cmd
+}
+
+func runPreview(ctx context.Context, target, addr string) error {
+	capsule, err := loadPreview(ctx, target)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	name := capsule.ID
+	if name == "" {
+		name = target
+	}
+	fmt.Fprintf(console, "🔎 Previewing %s (%d files, %d findings) at http://%s (Ctrl+C to stop)\n",
+		name, len(capsule.Files), len(capsule.Annotations), listener.Addr())
+	return preview.Serve(ctx, listener, capsule)
+}
+
+// loadPreview reads a capsule file or project directory, or failing that
+// the stored capsule with that ID
+func loadPreview(ctx context.Context, target string) (*preview.Capsule, error) {
+	if info, err := os.Stat(target); err == nil {
+		if info.IsDir() {
+			files, err := capsulediff.LoadFile(target)
+			if err != nil {
+				return nil, err
+			}
+			return preview.FromFiles(ctx, filepath.Base(filepath.Clean(target)), files), nil
+		}
+		data, err := os.ReadFile(target)
+		if err != nil {
+			return nil, err
+		}
+		return preview.Load(ctx, data)
+	}
+
+	store, err := openArtifactStore()
+	if err != nil {
+		return nil, err
+	}
+	data, err := storedCapsule(ctx, store, target)
+	if err != nil {
+		return nil, err
+	}
+	return preview.Load(ctx, data)
+}
+
+func runCapsuleReproduce(baseFile string, reruns []string) error {
+	base, err := capsulediff.LoadFile(baseFile)
+	if err != nil {
+		return err
+	}
+	baseRun, err := capsulediff.LoadGeneration(baseFile)
+	if err != nil {
+		return err
+	}
+
+	reports := make([]*capsulediff.Reproducibility, 0, len(reruns))
+	reproduced := true
+	for _, file := range reruns {
+		head, err := capsulediff.LoadFile(file)
+		if err != nil {
+			return err
+		}
+		headRun, err := capsulediff.LoadGeneration(file)
+		if err != nil {
+			return err
+		}
+		report := capsulediff.CompareRuns(base, head, baseRun, headRun)
+		report.Base, report.Head = baseFile, file
+		reports = append(reports, report)
+		reproduced = reproduced && report.Verdict == capsulediff.VerdictReproduced
+	}
+
+	if jsonOutput {
+		printJSON(map[string]interface{}{"reproduced": reproduced, "runs": reports})
+	} else {
+		for _, r := range reports {
+			icon := "✅"
+			if r.Verdict != capsulediff.VerdictReproduced {
+				icon = "❌"
+			}
+			fmt.Printf("%s %s: %s (%d files identical, %d differ)\n", icon, r.Head, r.Verdict, r.Unchanged, len(r.Changed))
+			for _, c := range r.Changed {
+				fmt.Printf("   %-8s %s\n", c.Kind, c.Path)
+			}
+			for _, c := range r.Conditions {
+				fmt.Printf("   ⚠️  %s\n", c)
+			}
+		}
+	}
+	if !reproduced {
+		return errNotReproduced
+	}
+	return nil
+}
+
+type capsulePROptions struct {
+	base   string
+	branch string
+	dir    string
+	title  string
+	draft  bool
+}
+
+func runCapsulePR(ctx context.Context, target, repoName string, opts capsulePROptions) error {
+	repo, err := github.ParseRepo(repoName)
+	if err != nil {
+		return err
+	}
+	files, capsuleID, err := loadCapsule(ctx, target)
+	if err != nil {
+		return err
+	}
+	if opts.dir != "" {
+		prefixed := make(map[string]string, len(files))
+		for p, content := range files {
+			prefixed[path.Join(opts.dir, p)] = content
+		}
+		files = prefixed
+	}
+	title := opts.title
+	if title == "" {
+		title = "Add generated project " + capsuleID
+	}
+	branch := opts.branch
+	if branch == "" {
+		branch = modify.BranchName(capsuleID)
+	}
+	body := fmt.Sprintf("Adds the %d files of QLP capsule `%s`.\n", len(files), capsuleID)
+
+	client, err := github.NewClientFromEnv()
+	if err != nil {
+		return err
+	}
+	pr, err := client.OpenPullRequest(ctx, github.PullRequestOptions{
+		Repo:   repo,
+		Base:   opts.base,
+		Branch: branch,
+		Title:  title,
+		Body:   body,
+		Files:  files,
+		Draft:  opts.draft,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to open pull request: %w", err)
+	}
+
+	if jsonOutput {
+		printJSON(pr)
+	} else {
+		fmt.Printf("🔀 Opened pull request #%d on %s: %s\n", pr.Number, repo, pr.HTMLURL)
+	}
+	return nil
+}
+
+func runCapsuleExport(ctx context.Context, capsuleID, output string) error {
+	store, err := openArtifactStore()
+	if err != nil {
+		return err
+	}
+	artifact, err := capsuleArtifact(ctx, store, capsuleID)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		output = artifact.Name
+	}
+
+	rc, _, err := store.Open(ctx, artifact.Key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", output, err)
+	}
+	if _, err := io.Copy(f, rc); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		printJSON(map[string]interface{}{"capsule_id": capsuleID, "artifact": artifact.Key, "path": output, "size_bytes": artifact.SizeBytes})
+	} else {
+		fmt.Printf("📦 Exported %s to %s (%d bytes)\n", capsuleID, output, artifact.SizeBytes)
+	}
+	return nil
+}
+
+func runCapsuleImport(ctx context.Context, filename, tenantID string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if _, err := capsulediff.Load(data); err != nil {
+		return fmt.Errorf("%s is not a capsule: %w", filename, err)
+	}
+
+	store, err := openArtifactStore()
+	if err != nil {
+		return err
+	}
+	capsuleID := capsuleIDOf(data, filename)
+	artifact, err := store.Put(ctx, tenantID, capsuleID, filepath.Base(filename), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		printJSON(artifact)
+	} else {
+		fmt.Printf("📥 Imported %s as %s\n", filename, artifact.Key)
+	}
+	return nil
+}
+
+func newHITLCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "hitl",
+		Short: "Export and summarise the human-in-the-loop decision audit trail",
+		Long: `Reads the HITL decision audit trail the server appends to
+QLP_HITL_AUDIT_LOG: every quality gate decision, auto-approval and human
+override, with who made it, when and why. The same records are served at
+GET /hitl/decisions and GET /hitl/decisions/stats.`,
+	}
+	// addFilters adds the record filters to a subcommand, named as the
+	// query parameters of the HTTP endpoints
+	addFilters := func(c *cobra.Command) map[string]*string {
+		filters := make(map[string]*string)
+		for _, f := range []struct{ name, usage string }{
+			{"drop_id", "only records of this QuantumDrop"},
+			{"capsule_id", "only records of this capsule"},
+			{"actor", "only records made by this actor"},
+			{"action", "only records with this action, e.g. approve or reject"},
+			{"type", "only these record types: decision, auto_approval, override (comma-separated)"},
+			{"since", "only records at or after this time (RFC3339)"},
+			{"until", "only records before this time (RFC3339)"},
+			{"limit", "only the most recent records, this many"},
+		} {
+			filters[f.name] = c.Flags().String(strings.ReplaceAll(f.name, "_", "-"), "", f.usage)
+		}
+		return filters
+	}
+	query := func(filters map[string]*string) (hitl.DecisionQuery, *hitl.DecisionHistory, error) {
+		if os.Getenv("QLP_HITL_AUDIT_LOG") == "" {
+			return hitl.DecisionQuery{}, nil, errors.New("QLP_HITL_AUDIT_LOG is not set")
+		}
+		values := url.Values{}
+		for name, value := range filters {
+			if *value != "" {
+				values.Set(name, *value)
+			}
+		}
+		q, err := hitl.ParseDecisionQuery(values)
+		return q, hitl.NewDecisionHistory(), err
+	}
+
+	var format, output string
+	var exportFilters map[string]*string
+	export := &cobra.Command{
+		Use:   "export",
+		Short: "Export decision records as JSON or CSV",
+		Example: `  QLP_HITL_AUDIT_LOG=./data/hitl.jsonl qlp hitl export --format csv --output decisions.csv
+  qlp hitl export --type override --since 2025-06-01T00:00:00Z`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			q, history, err := query(exportFilters)
+			if err != nil {
+				return err
+			}
+			out := io.Writer(os.Stdout)
+			if output != "" {
+				f, err := os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				out = f
+			}
+			switch format {
+			case "csv":
+				return history.ExportCSV(out, q)
+			case "json":
+				return history.ExportJSON(out, q)
+			default:
+				return fmt.Errorf("unsupported format %q (supported: json, csv)", format)
+			}
+		},
+	}
+	export.Flags().StringVar(&format, "format", "json", "output format: json or csv")
+	export.Flags().StringVarP(&output, "output", "o", "", "file to write (default stdout)")
+	exportFilters = addFilters(export)
+
+	var period time.Duration
+	var statsFilters map[string]*string
+	stats := &cobra.Command{
+		Use:     "stats",
+		Short:   "Summarise decisions: approval rates, overrides and failing gates",
+		Example: `  qlp hitl stats --period 24h --json`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			q, history, err := query(statsFilters)
+			if err != nil {
+				return err
+			}
+			summary := history.Stats(q, period)
+			if jsonOutput {
+				printJSON(summary)
+				return nil
+			}
+			fmt.Printf("⚖️  %d decisions: %d auto-approved (%.0f%%), %d overridden (%.0f%%)\n", summary.TotalDecisions,
+				summary.AutoApproved, summary.AutoApprovalRate*100, summary.Overrides, summary.OverrideRate*100)
+			for _, gate := range summary.TopFailedGates {
+				fmt.Printf("   %-20s failed %d times\n", gate.Gate, gate.Count)
+			}
+			for _, b := range summary.Buckets {
+				fmt.Printf("   %s  %d decisions, %d auto-approved, %d overridden\n", b.PeriodStart.Format(time.RFC3339),
+					b.Decisions, b.AutoApproved, b.Overrides)
+			}
+			return nil
+		},
+	}
+	stats.Flags().DurationVar(&period, "period", 0, "also group decisions into buckets of this length")
+	statsFilters = addFilters(stats)
+
+	cmd.AddCommand(export, stats)
+	return cmd
+}
+
+func newHistoryCommand() *cobra.Command {
+	var limit int
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "List recently processed intents",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runHistory(limit)
+		},
+	}
+	cmd.Flags().IntVar(&limit, "limit", 20, "number of intents to list")
+	return cmd
+}
+
+// historyEntry is one intent in the qlp history output
+type historyEntry struct {
+	ID              string     `json:"id"`
+	Intent          string     `json:"intent"`
+	Status          string     `json:"status"`
+	OverallScore    int        `json:"overall_score"`
+	ExecutionTimeMS int        `json:"execution_time_ms"`
+	CreatedAt       time.Time  `json:"created_at"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+	FailedTasks     []string   `json:"failed_tasks,omitempty"` // Failed or skipped; see qlp retry-failed
+}
+
+func runHistory(limit int) error {
+	db, err := database.New()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if !db.IsConnected() {
+		return errors.New("history requires a database connection (set DATABASE_URL)")
+	}
+
+	intents, err := database.NewIntentRepository(db).List(limit, 0)
+	if err != nil {
+		return fmt.Errorf("failed to list intents: %w", err)
+	}
+
+	entries := make([]historyEntry, 0, len(intents))
+	for _, intent := range intents {
+		entries = append(entries, historyEntry{
+			ID:              intent.ID,
+			Intent:          intent.UserInput,
+			Status:          string(intent.Status),
+			OverallScore:    intent.OverallScore,
+			ExecutionTimeMS: intent.ExecutionTimeMS,
+			CreatedAt:       intent.CreatedAt,
+			CompletedAt:     intent.CompletedAt,
+			FailedTasks:     orchestrator.UnfinishedTasks(intent),
+		})
+	}
+
+	if jsonOutput {
+		printJSON(map[string]interface{}{"intents": entries})
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tSCORE\tCREATED\tINTENT")
+	for _, e := range entries {
+		text := e.Intent
+		if len(text) > 60 {
+			text = text[:57] + "..."
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", e.ID, e.Status, e.OverallScore, e.CreatedAt.Format("2006-01-02 15:04"), text)
+	}
+	return w.Flush()
+}
+
+func newRetryFailedCommand() *cobra.Command {
+	var tenantID string
+	cmd := &cobra.Command{
+		Use:   "retry-failed <intent-id>",
+		Short: "Re-run only the failed tasks of an intent",
+		Long: `Re-runs the tasks of an intent that failed, and those skipped because they
+depend on one, against the saved results of the tasks that completed, then
+packages a new capsule. Runs that had failed tasks are recorded in
+QLP_CHECKPOINT_DIR, so the retry can run in a later process.`,
+		Example: `  qlp retry-failed 7f3c2a9e-1b4d-4c8e-9f0a-2d6b5e8c1a47`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRetryFailed(args[0], tenantID)
+		},
+	}
+	cmd.Flags().StringVar(&tenantID, "tenant", "", "tenant the retry runs for, metered against its execution quota")
+	return cmd
+}
+
+func runRetryFailed(intentID, tenantID string) error {
+	rt := startRuntime()
+	defer rt.Close()
+
+	ctx := rt.ctx
+	if tenantID != "" {
+		ctx = audit.WithTenant(ctx, tenantID)
+	}
+	result, err := rt.orch.RetryFailed(ctx, intentID)
+
+	if jsonOutput {
+		out := map[string]interface{}{"intent_id": intentID}
+		if result != nil {
+			out["status"] = result.Status
+			out["retried_tasks"] = result.Retried
+			out["recovered_tasks"] = result.Recovered
+			out["failed_tasks"] = result.Failed
+			if result.Capsule != nil {
+				out["capsule_id"] = result.Capsule.Metadata.CapsuleID
+				out["overall_score"] = result.Capsule.Metadata.OverallScore
+			}
+		}
+		if err != nil {
+			out["error"] = err.Error()
+		}
+		printJSON(out)
+	} else if result != nil {
+		fmt.Printf("🔁 Retried %d tasks of intent %s: %d recovered\n", len(result.Retried), intentID, len(result.Recovered))
+		for _, id := range result.Failed {
+			fmt.Printf("   ❌ %s still failed\n", id)
+		}
+		if result.Capsule != nil {
+			fmt.Printf("📦 Capsule %s (%s, score %d)\n", result.Capsule.Metadata.CapsuleID, result.Status, result.Capsule.Metadata.OverallScore)
+		}
+	}
+	return err
+}
+
+func newResumeCommand() *cobra.Command {
+	var tenantID string
+	cmd := &cobra.Command{
+		Use:   "resume [checkpoint...]",
+		Short: "Finish intents interrupted by a shutdown",
+		Long: `Runs the tasks a drain checkpointed when the process shut down mid-intent,
+against the saved results of the tasks that completed, then packages the
+capsule. Without arguments every checkpoint in QLP_CHECKPOINT_DIR is
+resumed. Checkpoints are removed once resumed.`,
+		Example: `  qlp resume
+  qlp resume data/checkpoints/graph_1_1760000000.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runResume(args, tenantID)
+		},
+	}
+	cmd.Flags().StringVar(&tenantID, "tenant", "", "tenant the resumed intents run for, metered against its execution quota")
+	return cmd
+}
+
+func runResume(paths []string, tenantID string) error {
+	if len(paths) == 0 {
+		var err error
+		if paths, err = dag.ListCheckpoints(); err != nil {
+			return fmt.Errorf("failed to list checkpoints: %w", err)
+		}
+		if len(paths) == 0 {
+			fmt.Println("No checkpoints to resume")
+			return nil
+		}
+	}
+
+	rt := startRuntime()
+	defer rt.Close()
+
+	ctx := rt.ctx
+	if tenantID != "" {
+		ctx = audit.WithTenant(ctx, tenantID)
+	}
+	var resumed []map[string]interface{}
+	var firstErr error
+	for _, path := range paths {
+		result, err := rt.orch.ResumeCheckpoint(ctx, path)
+		out := map[string]interface{}{"checkpoint": path}
+		if result != nil {
+			out["intent_id"] = result.IntentID
+			out["status"] = result.Status
+			out["resumed_tasks"] = result.Retried
+			out["failed_tasks"] = result.Failed
+			if result.Capsule != nil {
+				out["capsule_id"] = result.Capsule.Metadata.CapsuleID
+			}
+		}
+		if err != nil {
+			out["error"] = err.Error()
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		resumed = append(resumed, out)
+
+		if jsonOutput {
+			continue
+		}
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n", path, err)
+			continue
+		}
+		fmt.Printf("🔁 Resumed %d tasks of intent %s\n", len(result.Retried), result.IntentID)
+		for _, id := range result.Failed {
+			fmt.Printf("   ❌ %s failed\n", id)
+		}
+		if result.Capsule != nil {
+			fmt.Printf("📦 Capsule %s (%s, score %d)\n", result.Capsule.Metadata.CapsuleID, result.Status, result.Capsule.Metadata.OverallScore)
+		}
+	}
+	if jsonOutput {
+		printJSON(map[string]interface{}{"checkpoints": resumed})
+	}
+	return firstErr
+}
+
+func newConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect and validate the qlp.yaml configuration",
+		Long: `Settings come from qlp.yaml, qlp.yml or qlp.json in the working directory
+(or the file named by QLP_CONFIG). A profile selected with --profile or
+QLP_PROFILE overlays the base settings; environment variables override both.`,
+	}
+	cmd.AddCommand(newConfigValidateCommand(), newConfigShowCommand())
+	return cmd
+}
+
+func newConfigValidateCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate [file]",
+		Short: "Check a config file against the schema",
+		Args:  cobra.MaximumNArgs(1),
+		// Skip the shared startup so a broken file can still be reported
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := ""
+			if len(args) > 0 {
+				path = args[0]
+			}
+			return runConfigValidate(path)
+		},
+	}
+}
+
+// configValidation is the --json output of qlp config validate
+type configValidation struct {
+	Path   string         `json:"path"`
+	Valid  bool           `json:"valid"`
+	Issues []config.Issue `json:"issues"`
+}
+
+func runConfigValidate(path string) error {
+	if path == "" {
+		config.LoadEnv()
+		if path = config.FindFile(); path == "" {
+			return errors.New("no qlp.yaml, qlp.yml or qlp.json found (set QLP_CONFIG to point at one)")
+		}
+	}
+	f, err := config.LoadFile(path)
+	if err != nil {
+		return err
+	}
+
+	result := configValidation{Path: path, Issues: f.Validate()}
+	if profileName != "" {
+		if _, err := f.Resolve(profileName); err != nil && !config.HasErrors(result.Issues) {
+			result.Issues = append(result.Issues, config.Issue{Severity: "error", Message: err.Error()})
+		}
+	}
+	if result.Issues == nil {
+		result.Issues = []config.Issue{}
+	}
+	result.Valid = !config.HasErrors(result.Issues)
+
+	if jsonOutput {
+		printJSON(result)
+	} else {
+		for _, issue := range result.Issues {
+			fmt.Println(issue)
+		}
+		if result.Valid {
+			fmt.Printf("✅ %s is valid\n", path)
+		} else {
+			fmt.Printf("❌ %s is invalid\n", path)
+		}
+	}
+	if !result.Valid {
+		return errValidationFailed
+	}
+	return nil
+}
+
+func newConfigShowCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show",
+		Short: "Print the effective settings and where each came from",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigShow()
+		},
+	}
+}
+
+func runConfigShow() error {
+	effective := *activeConfig
+	effective.Settings = activeConfig.Masked()
+	if effective.Settings == nil {
+		effective.Settings = []config.Setting{}
+	}
+
+	if jsonOutput {
+		printJSON(effective)
+		return nil
+	}
+	if effective.Path == "" {
+		fmt.Printf("No config file found; profile %s\n", effective.Profile)
+		return nil
+	}
+	fmt.Printf("Config file: %s\nProfile:     %s\n\n", effective.Path, effective.Profile)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, setting := range effective.Settings {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", setting.Key, setting.Value, setting.Source)
+	}
+	return w.Flush()
+}
+
+func newInstallCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "Render the manifests that deploy QuantumLayer itself",
+	}
+	cmd.AddCommand(newInstallRenderCommand())
+	return cmd
+}
+
+type installRenderOptions struct {
+	format string
+	output string
+	file   string
+}
+
+func newInstallRenderCommand() *cobra.Command {
+	var opts installRenderOptions
+	cmd := &cobra.Command{
+		Use:   "render",
+		Short: "Render validated Kubernetes manifests or a Compose file for QLP",
+		Long: `Renders the resources that run the QLP API server (qlp all-in-one) and,
+when enabled, the operator with its CRDs. The image, namespace, replicas,
+resource requests and limits and secret references come from the install
+section of qlp.yaml; the non-secret settings of the selected profile become
+the server's ConfigMap. Settings named like credentials are never copied
+into the manifests: map them to Kubernetes Secrets with install.secret_refs.
+
+The configuration is checked first and the rendered manifests are parsed
+back before anything is written; errors exit non-zero with the problems
+listed. Without --output the manifests are printed as one stream.`,
+		Example: `  qlp install render --profile prod | kubectl apply -f -
+  qlp install render --profile prod -o deploy/rendered
+  qlp install render --format compose -o .`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runInstallRender(opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.format, "format", install.FormatKubernetes, "kubernetes or compose")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "", "directory to write the files to (default stdout)")
+	cmd.Flags().StringVar(&opts.file, "config", "", "config file to render from (default qlp.yaml or QLP_CONFIG)")
+	return cmd
+}
+
+func runInstallRender(opts installRenderOptions) error {
+	path := opts.file
+	if path == "" {
+		path = config.FindFile()
+	}
+	var cfg install.Config
+	settings := map[string]string{}
+	if path != "" {
+		f, err := config.LoadFile(path)
+		if err != nil {
+			return err
+		}
+		if settings, err = f.Resolve(activeConfig.Profile); err != nil {
+			return err
+		}
+		if cfg, err = install.Load(path); err != nil {
+			return err
+		}
+	}
+
+	result, err := install.Render(cfg, settings, activeConfig.Profile, opts.format)
+	if jsonOutput {
+		printJSON(result)
+	} else {
+		for _, issue := range result.Issues {
+			fmt.Fprintln(console, issue)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, install.ErrInvalid) && !jsonOutput {
+			return fmt.Errorf("%w, nothing rendered", err)
+		}
+		return err
+	}
+	if jsonOutput {
+		return nil
+	}
+
+	if opts.output == "" {
+		fmt.Print(result.Stream())
+		return nil
+	}
+	if err := os.MkdirAll(opts.output, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", opts.output, err)
+	}
+	names := make([]string, 0, len(result.Files))
+	for name := range result.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(opts.output, name), []byte(result.Files[name]), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		fmt.Fprintf(console, "📄 %s\n", filepath.Join(opts.output, name))
+	}
+	return nil
+}
+
+// allInOneDefaults turn on every subsystem that runs on the local machine
+// for qlp all-in-one. Settings from the environment or qlp.yaml win.
+var allInOneDefaults = []struct{ key, value string }{
+	{"QLP_ENABLE_METRICS", "true"},
+	// The API has no authentication unless tenants are configured, so it is
+	// only reachable from this machine
+	{"QLP_METRICS_HOST", "127.0.0.1"},
+	{"QLP_ENABLE_AUDIT_LOGGING", "true"},
+	{"QLP_AUDIT_SINKS", "file"},
+	{"QLP_ENABLE_WORKSPACES", "true"},
+	{"QLP_ENABLE_SCHEDULER", "true"},
+	{"QLP_ENABLE_BATCHES", "true"},
+	{"QLP_ENABLE_EXECUTION_QUOTAS", "true"},
+	{"QLP_ENABLE_METERING", "true"},
+	{"QLP_ENABLE_PROMPT_VERSIONING", "true"},
+	{"QLP_ENABLE_CLARIFICATION", "true"},
+	// There is no terminal to ask on; questions are answered through the API
+	{"QLP_CLARIFICATION_TERMINAL", "false"},
+	{"QLP_EVENT_BACKEND", "file"},
+}
+
+func newAllInOneCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "all-in-one",
+		Short: "Serve the whole pipeline from this process, for evaluation",
+		Long: `Starts QuantumLayer as a server with every subsystem that runs locally
+turned on: the HTTP API on QLP_METRICS_PORT (default 9090) with batches,
+schedules, workspaces, clarifications, prompts, artifacts, the service
+catalog, capsule diffs, quotas and metering, all wired to the orchestrator
+in this process. The API listens on 127.0.0.1 only, as it has no
+authentication; set QLP_METRICS_HOST to expose it. Tenant deletion stays
+off unless QLP_ENABLE_TENANT_DELETION is set. Events stay on the in-process bus,
+journaled under ./data so queued events survive a restart.
+
+Without DATABASE_URL nothing external is needed: artifacts, workspaces,
+schedules, prompts and the audit log are files under ./data, and the stores
+that would otherwise live in Postgres are kept in memory until the process
+exits. Settings in the environment or qlp.yaml override these defaults, so
+the same endpoints can later be pointed at Postgres and Azure unchanged.
+Runs until interrupted.`,
+		Example: `  qlp all-in-one
+  QLP_METRICS_PORT=8080 qlp all-in-one
+  curl -X POST localhost:9090/batches -d '{"intents":["Create a REST API for todos"]}'`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAllInOne()
+		},
+	}
+}
+
+func runAllInOne() error {
+	for _, d := range allInOneDefaults {
+		if os.Getenv(d.key) == "" {
+			os.Setenv(d.key, d.value)
+		}
+	}
+	rt := startRuntime()
+	defer rt.Close()
+
+	port := config.GetEnvOrDefault("QLP_METRICS_PORT", "9090")
+	host := config.GetEnvOrDefault("QLP_METRICS_HOST", "")
+	if host == "" {
+		host = "localhost"
+	}
+	fmt.Fprintf(console, "🧩 All-in-one mode: API on http://%s:%s (metrics at /metrics), press Ctrl+C to stop\n", host, port)
+	if os.Getenv("DATABASE_URL") == "" {
+		fmt.Fprintln(console, "   No DATABASE_URL: history, quotas and metering are kept in memory, everything else under ./data")
+	}
+	<-rt.ctx.Done()
+	return nil
+}
+
+type operatorOptions struct {
+	namespace   string
+	apiServer   string
+	resync      time.Duration
+	concurrency int
+}
+
+func newOperatorCommand() *cobra.Command {
+	var opts operatorOptions
+	cmd := &cobra.Command{
+		Use:   "operator",
+		Short: "Reconcile Intent and ValidationRun resources in a Kubernetes cluster",
+		Long: `Runs the QLP Kubernetes operator. Intent resources are generated into
+capsules, each recorded as a Capsule resource owned by its Intent, and
+ValidationRun resources validate a capsule; both report their progress in
+status phases and Ready and Progressing conditions. A resource runs again
+when its spec changes, and a ValidationRun waits for its Capsule to appear.
+Install the CRDs and RBAC from deploy/operator first.
+
+In a pod the operator uses its service account and watches its own
+namespace; elsewhere point --api-server at "kubectl proxy", or at the API
+server itself with a bearer token in QLP_OPERATOR_TOKEN.`,
+		Example: `  kubectl apply -f deploy/operator/crds.yaml -f deploy/operator/operator.yaml
+  kubectl proxy & qlp operator --api-server http://localhost:8001 --namespace apps`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runOperator(cmd.Context(), opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.namespace, "namespace", operator.InNamespace(), "namespace to reconcile, empty for every namespace")
+	cmd.Flags().StringVar(&opts.apiServer, "api-server", "", "API server URL to use instead of the in-cluster service account")
+	cmd.Flags().DurationVar(&opts.resync, "resync", 30*time.Second, "how often resources are listed")
+	cmd.Flags().IntVar(&opts.concurrency, "concurrency", 2, "intents and validation runs processed at once")
+	return cmd
+}
+
+func runOperator(ctx context.Context, opts operatorOptions) error {
+	if opts.resync <= 0 {
+		return errors.New("--resync must be positive")
+	}
+	var client operator.Client
+	if opts.apiServer != "" {
+		client = operator.NewRESTClient(opts.apiServer, os.Getenv("QLP_OPERATOR_TOKEN"), nil)
+	} else {
+		c, err := operator.NewInClusterClient()
+		if err != nil {
+			return fmt.Errorf("%w (use --api-server outside the cluster)", err)
+		}
+		client = c
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	scope := opts.namespace
+	if scope == "" {
+		scope = "every namespace"
+	}
+	fmt.Fprintf(console, "☸️  Reconciling QLP resources in %s every %s\n", scope, opts.resync)
+	operator.NewController(client, e2e.CommandExecutor(self), opts.namespace, opts.resync, opts.concurrency).Run(ctx)
+	return nil
+}
+
+func newAdminCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "admin",
+		Short: "Administer QuantumLayer tenants",
+	}
+	cmd.AddCommand(newAdminTenantCommand())
+	return cmd
+}
+
+// tenantFlags are the tenant settings accepted by create and set; only
+// flags given on the command line change the tenant
+type tenantFlags struct {
+	actor         string
+	name          string
+	plan          string
+	dailyMinutes  float64
+	maxConcurrent int
+	cpu           float64
+	memoryMB      int64
+}
+
+func (f *tenantFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.name, "name", "", "display name of the tenant")
+	cmd.Flags().StringVar(&f.plan, "plan", "", "plan whose limits apply: free, standard or enterprise")
+	cmd.Flags().Float64Var(&f.dailyMinutes, "daily-minutes", 0, "execution minutes per UTC day, 0 for unlimited")
+	cmd.Flags().IntVar(&f.maxConcurrent, "max-concurrent", 0, "sandboxes running at once, 0 for unlimited")
+	cmd.Flags().Float64Var(&f.cpu, "cpu", 0, "cores across the running sandboxes, 0 for unlimited")
+	cmd.Flags().Int64Var(&f.memoryMB, "memory-mb", 0, "memory across the running sandboxes, 0 for unlimited")
+}
+
+func (f *tenantFlags) update(cmd *cobra.Command) tenants.Update {
+	var u tenants.Update
+	if cmd.Flags().Changed("name") {
+		u.Name = &f.name
+	}
+	if cmd.Flags().Changed("plan") {
+		u.Plan = &f.plan
+	}
+	if cmd.Flags().Changed("daily-minutes") {
+		u.DailyMinutes = &f.dailyMinutes
+	}
+	if cmd.Flags().Changed("max-concurrent") {
+		u.MaxConcurrent = &f.maxConcurrent
+	}
+	if cmd.Flags().Changed("cpu") {
+		u.CPU = &f.cpu
+	}
+	if cmd.Flags().Changed("memory-mb") {
+		u.MemoryMB = &f.memoryMB
+	}
+	return u
+}
+
+func newAdminTenantCommand() *cobra.Command {
+	actor := config.GetEnvOrDefault("QLP_ACTOR", config.GetEnvOrDefault("USER", ""))
+	cmd := &cobra.Command{
+		Use:   "tenant",
+		Short: "Create, configure, suspend and resume tenants",
+		Long: `Manages the tenants in the database at DATABASE_URL, which the QLP server
+shares. New tenants get an API key, printed once; rotate-key issues a new
+one. Suspended tenants' API keys are refused until they are resumed. Every
+change is recorded in the audit log.`,
+		Example: `  qlp admin tenant create acme --name "Acme Corp" --plan standard
+  qlp admin tenant set acme --plan enterprise --daily-minutes 1200
+  qlp admin tenant rotate-key acme --grace 24h
+  qlp admin tenant suspend acme --reason "unpaid invoice"
+  qlp admin tenant list --json`,
+	}
+	cmd.PersistentFlags().StringVar(&actor, "actor", actor, "who is making the change (default QLP_ACTOR or USER)")
+
+	var createFlags tenantFlags
+	create := &cobra.Command{
+		Use:   "create <tenant-id>",
+		Short: "Create a tenant and print its API key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withTenantAdmin(func(svc *tenants.Service) error {
+				t, key, err := svc.Create(cmd.Context(), args[0], createFlags.update(cmd), actor)
+				if err != nil {
+					return err
+				}
+				printIssuedKey(t, key)
+				return nil
+			})
+		},
+	}
+	createFlags.register(create)
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List tenants",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withTenantAdmin(func(svc *tenants.Service) error {
+				list, err := svc.List(cmd.Context())
+				if err != nil {
+					return err
+				}
+				if jsonOutput {
+					printJSON(map[string]interface{}{"tenants": list})
+					return nil
+				}
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "ID\tNAME\tPLAN\tSTATUS\tDAILY MIN\tSANDBOXES\tCPU\tMEMORY MB\tKEYS")
+				for _, t := range list {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%g\t%d\t%g\t%d\t%d\n", t.ID, t.Name, t.Plan, t.Status,
+						t.Limits.DailyMinutes, t.Limits.MaxConcurrent, t.Limits.CPU, t.Limits.MemoryMB, len(t.APIKeys))
+				}
+				return w.Flush()
+			})
+		},
+	}
+
+	show := &cobra.Command{
+		Use:   "show <tenant-id>",
+		Short: "Show a tenant, its limits and its API keys",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withTenantAdmin(func(svc *tenants.Service) error {
+				t, err := svc.Get(cmd.Context(), args[0])
+				if err != nil {
+					return err
+				}
+				printTenant(t)
+				return nil
+			})
+		},
+	}
+
+	var setFlags tenantFlags
+	set := &cobra.Command{
+		Use:   "set <tenant-id>",
+		Short: "Change a tenant's name, plan or limits",
+		Long: `Changes the given settings only. --plan resets the limits to the plan's
+before any limit flags apply.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withTenantAdmin(func(svc *tenants.Service) error {
+				t, err := svc.Update(cmd.Context(), args[0], setFlags.update(cmd), actor)
+				if err != nil {
+					return err
+				}
+				printTenant(t)
+				return nil
+			})
+		},
+	}
+	setFlags.register(set)
+
+	var grace time.Duration
+	var keyID string
+	rotate := &cobra.Command{
+		Use:   "rotate-key <tenant-id>",
+		Short: "Replace an API key with a new one of the same scopes",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withTenantAdmin(func(svc *tenants.Service) error {
+				t, key, err := svc.RotateKey(cmd.Context(), args[0], keyID, grace, actor)
+				if err != nil {
+					return err
+				}
+				printIssuedKey(t, key)
+				return nil
+			})
+		},
+	}
+	rotate.Flags().DurationVar(&grace, "grace", 0, "keep the replaced key working for this long")
+	rotate.Flags().StringVar(&keyID, "key", "", "ID of the key to replace (needed when the tenant has several)")
+
+	var reason string
+	suspend := &cobra.Command{
+		Use:   "suspend <tenant-id>",
+		Short: "Refuse a tenant's API keys until it is resumed",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withTenantAdmin(func(svc *tenants.Service) error {
+				t, err := svc.Suspend(cmd.Context(), args[0], reason, actor)
+				if err != nil {
+					return err
+				}
+				printTenant(t)
+				return nil
+			})
+		},
+	}
+	suspend.Flags().StringVar(&reason, "reason", "", "why the tenant is suspended")
+
+	resume := &cobra.Command{
+		Use:   "resume <tenant-id>",
+		Short: "Accept a suspended tenant's API keys again",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withTenantAdmin(func(svc *tenants.Service) error {
+				t, err := svc.Resume(cmd.Context(), args[0], actor)
+				if err != nil {
+					return err
+				}
+				printTenant(t)
+				return nil
+			})
+		},
+	}
+
+	cmd.AddCommand(create, list, show, set, rotate, suspend, resume, newAdminTenantKeyCommand(&actor))
+	return cmd
+}
+
+func newAdminTenantKeyCommand(actor *string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "key",
+		Short: "Issue, list and revoke a tenant's scoped API keys",
+		Long: `API keys carry scopes: generate submits intents, batches, validations and
+schedules; read reads results, capsules and reports; deploy promotes capsules
+and rolls deployments back; admin changes the prompts every tenant shares and
+manages artifact data keys. Give CI systems only the scopes they need.`,
+		Example: `  qlp admin tenant key create acme --name github-ci --scope generate --scope read --ttl 2160h
+  qlp admin tenant key list acme
+  qlp admin tenant key revoke acme KEY-1718000000000000000`,
+	}
+
+	var req tenants.KeyRequest
+	create := &cobra.Command{
+		Use:   "create <tenant-id>",
+		Short: "Issue an API key and print it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withTenantAdmin(func(svc *tenants.Service) error {
+				k, key, err := svc.CreateKey(cmd.Context(), args[0], req, *actor)
+				if err != nil {
+					return err
+				}
+				if jsonOutput {
+					printJSON(map[string]interface{}{"key": k, "api_key": key})
+					return nil
+				}
+				printKey(k)
+				fmt.Printf("\n🔑 API key: %s\n   Store it now; it is not shown again.\n", key)
+				return nil
+			})
+		},
+	}
+	create.Flags().StringVar(&req.Name, "name", "", "what the key is for, e.g. github-ci")
+	create.Flags().StringSliceVar(&req.Scopes, "scope", nil, "scope to grant: generate, read, deploy or admin (repeatable)")
+	create.Flags().DurationVar(&req.TTL, "ttl", 0, "expire the key after this long (default never)")
+
+	list := &cobra.Command{
+		Use:   "list <tenant-id>",
+		Short: "List a tenant's API keys, without secrets",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withTenantAdmin(func(svc *tenants.Service) error {
+				keys, err := svc.ListKeys(cmd.Context(), args[0])
+				if err != nil {
+					return err
+				}
+				if jsonOutput {
+					printJSON(map[string]interface{}{"keys": keys})
+					return nil
+				}
+				for _, k := range keys {
+					printKey(k)
+				}
+				return nil
+			})
+		},
+	}
+
+	revoke := &cobra.Command{
+		Use:   "revoke <tenant-id> <key-id>",
+		Short: "Stop accepting an API key at once",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withTenantAdmin(func(svc *tenants.Service) error {
+				if err := svc.RevokeKey(cmd.Context(), args[0], args[1], *actor); err != nil {
+					return err
+				}
+				if jsonOutput {
+					printJSON(map[string]interface{}{"tenant_id": args[0], "revoked": args[1]})
+				} else {
+					fmt.Printf("🗑️  Revoked %s of %s\n", args[1], args[0])
+				}
+				return nil
+			})
+		},
+	}
+
+	cmd.AddCommand(create, list, revoke)
+	return cmd
+}
+
+// withTenantAdmin runs fn with the tenant service of the shared database,
+// recording changes in the configured audit sinks
+func withTenantAdmin(fn func(svc *tenants.Service) error) error {
+	db, err := database.New()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if !db.IsConnected() {
+		return errors.New("tenant admin requires a database connection (set DATABASE_URL)")
+	}
+	store, err := tenants.NewPostgresStore(db.GetConnection())
+	if err != nil {
+		return err
+	}
+	svc, err := tenants.NewService(store, config.GetEnvOrDefault("QLP_TENANT_DEFAULT_PLAN", "standard"))
+	if err != nil {
+		return err
+	}
+	if config.GetEnvOrDefault("QLP_ENABLE_AUDIT_LOGGING", "false") == "true" {
+		auditLogger, err := audit.InitFromEnv()
+		if err != nil {
+			return fmt.Errorf("failed to open the audit log: %w", err)
+		}
+		defer auditLogger.Close()
+	}
+	return fn(svc)
+}
+
+func printIssuedKey(t *tenants.Tenant, key string) {
+	if jsonOutput {
+		printJSON(map[string]interface{}{"tenant": t, "api_key": key})
+		return
+	}
+	printTenant(t)
+	fmt.Printf("\n🔑 API key: %s\n   Store it now; it is not shown again.\n", key)
+}
+
+func printTenant(t *tenants.Tenant) {
+	if jsonOutput {
+		printJSON(t)
+		return
+	}
+	fmt.Printf("Tenant:  %s (%s)\nPlan:    %s\nStatus:  %s\n", t.ID, t.Name, t.Plan, t.Status)
+	if t.SuspendedReason != "" {
+		fmt.Printf("Reason:  %s\n", t.SuspendedReason)
+	}
+	fmt.Printf("Limits:  %g min/day, %d sandboxes, %g CPU, %d MB (0 is unlimited)\n",
+		t.Limits.DailyMinutes, t.Limits.MaxConcurrent, t.Limits.CPU, t.Limits.MemoryMB)
+	for _, k := range t.APIKeys {
+		printKey(k)
+	}
+}
+
+func printKey(k tenants.APIKey) {
+	scopes := "all scopes but admin"
+	if len(k.Scopes) > 0 {
+		scopes = strings.Join(k.Scopes, ",")
+	}
+	expiry := ""
+	if k.ExpiresAt != nil {
+		expiry = ", expires " + k.ExpiresAt.Format(time.RFC3339)
+	}
+	fmt.Printf("Key:     %s %s… %s [%s] created %s%s\n", k.ID, k.Prefix, k.Name, scopes, k.CreatedAt.Format(time.RFC3339), expiry)
+}
+
+// loadCapsule reads project files from a path on disk or, failing that, from
+// the stored capsule with that ID
+func loadCapsule(ctx context.Context, target string) (map[string]string, string, error) {
+	if _, err := os.Stat(target); err == nil {
+		files, err := capsulediff.LoadFile(target)
+		if err != nil {
+			return nil, "", err
+		}
+		data, _ := os.ReadFile(target)
+		return files, capsuleIDOf(data, target), nil
+	}
+
+	store, err := openArtifactStore()
+	if err != nil {
+		return nil, "", err
+	}
+	files, err := storedCapsuleLoader(store)(ctx, target)
+	if err != nil {
+		return nil, "", err
+	}
+	return files, target, nil
+}
+
+// storedCapsuleLoader reads the files of capsules from artifact storage
+func storedCapsuleLoader(store storage.ArtifactStore) azure.CapsuleLoader {
+	return func(ctx context.Context, capsuleID string) (map[string]string, error) {
+		data, err := storedCapsule(ctx, store, capsuleID)
+		if err != nil {
+			return nil, err
+		}
+		return capsulediff.Load(data)
+	}
+}
+
+// storedCapsule reads a stored capsule's exported archive
+func storedCapsule(ctx context.Context, store storage.ArtifactStore, capsuleID string) ([]byte, error) {
+	artifact, err := capsuleArtifact(ctx, store, capsuleID)
+	if err != nil {
+		return nil, err
+	}
+	return readArtifact(ctx, store, artifact)
+}
+
+// readArtifact reads a stored capsule artifact
+func readArtifact(ctx context.Context, store storage.ArtifactStore, artifact *storage.Artifact) ([]byte, error) {
+	rc, _, err := store.Open(ctx, artifact.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read capsule %s: %w", artifact.CapsuleID, err)
+	}
+	return data, nil
+}
+
+func openArtifactStore() (storage.ArtifactStore, error) {
+	return storage.OpenFromEnv()
+}
+
+// capsuleArtifact picks the exported archive of a stored capsule, falling
+// back to any JSON export
+func capsuleArtifact(ctx context.Context, store storage.ArtifactStore, capsuleID string) (*storage.Artifact, error) {
+	artifacts, err := store.List(ctx, capsuleID)
+	if err != nil {
+		return nil, err
+	}
+	var fallback *storage.Artifact
+	for i, a := range artifacts {
+		switch strings.ToLower(filepath.Ext(a.Name)) {
+		case ".qlcapsule", ".zip":
+			return &artifacts[i], nil
+		case ".json":
+			fallback = &artifacts[i]
+		}
+	}
+	if fallback == nil {
+		return nil, fmt.Errorf("%s is neither a file nor a stored capsule: %w", capsuleID, storage.ErrNotFound)
+	}
+	return fallback, nil
+}
+
+// capsuleIDOf reads the capsule ID from an exported capsule's metadata,
+// falling back to the file name
+func capsuleIDOf(data []byte, filename string) string {
+	var metadata struct {
+		CapsuleID string `json:"capsule_id"`
+		Metadata  struct {
+			CapsuleID string `json:"capsule_id"`
+		} `json:"metadata"`
+	}
+	if zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data))); err == nil {
+		for _, f := range zr.File {
+			if f.Name != "metadata.json" {
+				continue
+			}
+			if rc, err := f.Open(); err == nil {
+				json.NewDecoder(rc).Decode(&metadata)
+				rc.Close()
+			}
+		}
+	} else {
+		json.Unmarshal(data, &metadata)
+	}
+	for _, id := range []string{metadata.CapsuleID, metadata.Metadata.CapsuleID} {
+		if id != "" {
+			return id
+		}
+	}
+	base := filepath.Base(filename)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// capsuleDrop wraps loaded capsule files for the validators and deployers,
+// which operate on drops
+func capsuleDrop(capsuleID string, files map[string]string) *packaging.QuantumDrop {
+	return &packaging.QuantumDrop{
+		ID:        capsuleID,
+		Type:      packaging.DropTypeCodebase,
+		Name:      capsuleID,
+		Files:     files,
+		Status:    packaging.DropStatusApproved,
+		CreatedAt: time.Now(),
+		Metadata:  packaging.DropMetadata{FileCount: len(files)},
+	}
+}
+
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"QLP/internal/bench"
+	"QLP/internal/e2e"
+	"QLP/internal/headless"
+)
+
+// Scenarios: "compare" runs the corpus and fails when scores regress beyond
+// the thresholds against the baseline; "record" runs the corpus and writes
+// its scores as the new baseline.
+func main() {
+	corpusFile := flag.String("corpus", os.Getenv("QLP_BENCH_CORPUS"), "JSON file of benchmark cases (default: built-in corpus)")
+	baselineFile := flag.String("baseline", envOr("QLP_BENCH_BASELINE", "bench/baseline.json"), "baseline scores to compare against or record")
+	label := flag.String("label", os.Getenv("QLP_BENCH_LABEL"), "label recorded with a baseline, e.g. the model and prompt version")
+	maxDrop := flag.Int("max-drop", bench.DefaultThresholds.MaxDrop, "points any score of a case may drop")
+	maxMeanDrop := flag.Float64("max-mean-drop", bench.DefaultThresholds.MaxMeanDrop, "points the mean overall score may drop")
+	parallel := flag.Int("parallel", 2, "cases to run at once")
+	qlpPath := flag.String("qlp", envOr("QLP_E2E_BINARY", "./qlp"), "path to the qlp binary")
+	outFile := flag.String("out", "output/bench/comparison.json", "where to write the comparison")
+	timeout := flag.Duration("timeout", time.Hour, "timeout for the whole run")
+	opts := headless.Parse("compare", "record")
+	report := opts.NewResult("qlp-bench")
+
+	corpus := bench.DefaultCorpus
+	if *corpusFile != "" {
+		var err error
+		if corpus, err = bench.LoadCorpus(*corpusFile); err != nil {
+			report.Fail(err, headless.ExitUsage)
+			opts.Finish(report)
+		}
+	}
+	var baseline *bench.Baseline
+	if opts.Scenario == "compare" {
+		var err error
+		if baseline, err = bench.LoadBaseline(*baselineFile); err != nil {
+			report.Fail(fmt.Errorf("%w (run with --scenario record first)", err), headless.ExitUsage)
+			opts.Finish(report)
+		}
+	}
+	if _, err := os.Stat(*qlpPath); err != nil {
+		report.Fail(err, headless.ExitUsage)
+		opts.Finish(report)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	fmt.Printf("📏 Benchmarking %d intents, %d at a time\n", len(corpus), max(*parallel, 1))
+	results := bench.Run(ctx, e2e.CommandExecutor(*qlpPath), corpus, *parallel)
+	for _, r := range results {
+		if r.Error != "" {
+			fmt.Printf("❌ %-18s %s\n", r.ID, r.Error)
+		} else {
+			fmt.Printf("   %-18s overall %3d  security %3d  quality %3d  architecture %3d  compliance %3d\n",
+				r.ID, r.Scores.Overall, r.Scores.Security, r.Scores.Quality, r.Scores.Architecture, r.Scores.Compliance)
+		}
+	}
+
+	if opts.Scenario == "record" {
+		recorded := bench.NewBaseline(*label, results)
+		err := recorded.Save(*baselineFile)
+		if err == nil && len(recorded.Cases) < len(results) {
+			err = fmt.Errorf("%d of %d cases failed and were left out of the baseline", len(results)-len(recorded.Cases), len(results))
+		}
+		report.Step("record_baseline", err)
+		fmt.Printf("💾 Recorded %d cases to %s\n", len(recorded.Cases), *baselineFile)
+		report.Details["baseline"] = *baselineFile
+		opts.Finish(report)
+	}
+
+	comparison := bench.Compare(baseline, results, bench.Thresholds{MaxDrop: *maxDrop, MaxMeanDrop: *maxMeanDrop})
+	report.Step("write_comparison", writeJSON(*outFile, comparison))
+	for _, r := range comparison.Regressions {
+		fmt.Printf("📉 %s\n", r.Message)
+	}
+	for _, id := range comparison.Missing(baseline) {
+		fmt.Printf("⚠️  %s is in the baseline but was not run\n", id)
+	}
+	for _, id := range comparison.Unbaselined {
+		fmt.Printf("⚠️  %s has no baseline scores; record a new baseline to track it\n", id)
+	}
+	fmt.Printf("Mean overall %.1f (baseline %.1f, %+.1f)\n", comparison.MeanOverall, comparison.MeanBaseline, comparison.MeanDelta)
+
+	var err error
+	if !comparison.Passed {
+		err = fmt.Errorf("%d scores regressed beyond the thresholds", len(comparison.Regressions))
+	} else if ctx.Err() != nil {
+		err = errors.New("benchmark interrupted before every case finished")
+	}
+	report.Step("compare_baseline", err)
+	report.Details["comparison"] = *outFile
+	report.Details["regressions"] = comparison.Regressions
+	report.Details["mean_delta"] = comparison.MeanDelta
+	opts.Finish(report)
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
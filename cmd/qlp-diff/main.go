@@ -0,0 +1,88 @@
+// Command qlp-diff compares two capsules or drops generated for the same
+// intent and prints a unified diff with a change summary.
+//
+//	qlp-diff [-summary] [-json] [-context N] <base> <head>
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"QLP/internal/capsulediff"
+	"QLP/internal/llm"
+	"QLP/internal/logger"
+)
+
+func main() {
+	summarize := flag.Bool("summary", false, "summarize the changes with the configured LLM")
+	asJSON := flag.Bool("json", false, "print the comparison as JSON")
+	contextLines := flag.Int("context", capsulediff.DefaultContextLines, "unchanged lines shown around each hunk")
+	timeout := flag.Duration("timeout", 2*time.Minute, "time limit for the LLM summary")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] <base> <head>\n\nBase and head may be .qlcapsule/.zip capsules, JSON capsules or JSON drops.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg := logger.DefaultConfig()
+	cfg.Level = logger.ERROR
+	logger.InitLogger(cfg)
+
+	base, err := capsulediff.LoadFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(2)
+	}
+	head, err := capsulediff.LoadFile(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(2)
+	}
+
+	var client llm.Client
+	if *summarize {
+		client = llm.NewLLMClient()
+	}
+	engine := capsulediff.NewEngine(client)
+	engine.SetContextLines(*contextLines)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	result := engine.Compare(ctx, base, head)
+	result.Base, result.Head = flag.Arg(0), flag.Arg(1)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(result)
+	} else {
+		printResult(result)
+	}
+
+	// Exit status follows diff(1): 1 when the inputs differ, 2 on errors
+	if !result.Identical() {
+		os.Exit(1)
+	}
+}
+
+func printResult(result *capsulediff.Result) {
+	fmt.Print(result.UnifiedDiff())
+
+	summary := result.Summary
+	fmt.Fprintf(os.Stderr, "\n📋 %s\n", summary.Headline)
+	for _, h := range summary.Highlights {
+		fmt.Fprintf(os.Stderr, "  • %s\n", h)
+	}
+	if summary.Risk != "" {
+		fmt.Fprintf(os.Stderr, "⚠️  Risk: %s\n", summary.Risk)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"QLP/internal/e2e"
+	"QLP/internal/headless"
+)
+
+// Runs the E2E scenarios against the qlp binary, up to --parallel at once,
+// and writes scoreboard.json and scoreboard.html to --out for nightly
+// regression tracking. --parallel 1 runs them one after another.
+func main() {
+	scenariosFile := flag.String("scenarios", os.Getenv("QLP_E2E_SCENARIOS"), "JSON file of scenarios (default: built-in scenarios)")
+	only := flag.String("only", "", "comma-separated scenario names to run")
+	parallel := flag.Int("parallel", envInt("QLP_E2E_PARALLEL", 4), "scenarios to run at once")
+	qlpPath := flag.String("qlp", envOr("QLP_E2E_BINARY", "./qlp"), "path to the qlp binary")
+	outDir := flag.String("out", envOr("QLP_E2E_OUTPUT", "output/e2e"), "directory for the scoreboard")
+	label := flag.String("label", os.Getenv("QLP_E2E_LABEL"), "label recorded on the scoreboard, e.g. the commit")
+	timeout := flag.Duration("timeout", 20*time.Minute, "timeout per scenario")
+	opts := headless.Parse("all")
+	report := opts.NewResult("quantumlayer-e2e")
+
+	scenarios := e2e.DefaultScenarios
+	if *scenariosFile != "" {
+		var err error
+		if scenarios, err = e2e.LoadScenarios(*scenariosFile); err != nil {
+			report.Fail(err, headless.ExitUsage)
+			opts.Finish(report)
+		}
+	}
+	var names []string
+	if *only != "" {
+		names = strings.Split(*only, ",")
+	}
+	scenarios, err := e2e.Filter(scenarios, names)
+	if err == nil && len(scenarios) == 0 {
+		err = errors.New("no scenarios to run")
+	}
+	if err == nil {
+		_, err = os.Stat(*qlpPath)
+	}
+	if err != nil {
+		report.Fail(err, headless.ExitUsage)
+		opts.Finish(report)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Printf("🚀 Running %d scenarios, %d at a time\n", len(scenarios), max(*parallel, 1))
+	runner := &e2e.Runner{Exec: e2e.CommandExecutor(*qlpPath), Concurrency: *parallel, Timeout: *timeout}
+	board := runner.Run(ctx, scenarios)
+	board.Label = *label
+
+	for _, r := range board.Scenarios {
+		var stepErr error
+		if r.Status != e2e.StatusPassed {
+			stepErr = fmt.Errorf("%s: %s", r.FailedStage, r.Error)
+			fmt.Printf("❌ %-20s %s\n", r.Name, stepErr)
+		} else {
+			fmt.Printf("✅ %-20s score %d, generated in %.1fs\n", r.Name, r.ValidationScore, float64(r.GenerationMS)/1000)
+		}
+		report.Step(r.Name, stepErr)
+	}
+
+	paths, err := board.Write(*outDir)
+	report.Step("write_scoreboard", err)
+	if err == nil {
+		fmt.Printf("📊 %d/%d passed, scoreboard written to %s\n", board.Summary.Passed, board.Summary.Total, strings.Join(paths, ", "))
+	}
+	report.Details["summary"] = board.Summary
+	report.Details["scoreboard"] = paths
+	opts.Finish(report)
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
@@ -3,12 +3,12 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
 	"strings"
 	"time"
 
 	"QLP/internal/agents"
 	"QLP/internal/deployment/azure"
+	"QLP/internal/headless"
 	"QLP/internal/logger"
 	"QLP/internal/packaging"
 	"QLP/internal/types"
@@ -17,6 +17,8 @@ import (
 
 // Mock test that doesn't require real Azure resources
 func main() {
+	opts := headless.Parse("validate")
+	report := opts.NewResult("test-azure-deployment-mock")
 	ctx := context.Background()
 	
 	// Initialize logger
@@ -94,8 +96,10 @@ func main() { fmt.Println("Hello from QuantumLayer!") }`,
 	)
 	if err != nil {
 		agentLogger.Error("❌ Failed to create deployment validator agent", zap.Error(err))
-		log.Fatal(err)
+		report.Step("create_agent", err)
+		opts.Finish(report)
 	}
+	report.Step("create_agent", nil)
 	
 	agentLogger.Info("✅ Deployment validator agent created successfully",
 		zap.String("agent_id", "mock-deployment-validator-001"),
@@ -133,8 +137,12 @@ func main() { fmt.Println("Hello from QuantumLayer!") }`,
 		if result != nil {
 			printMockResults(result, agentLogger)
 		}
-		log.Fatal(err)
+		report.Step("execute_validation", err)
+		opts.Finish(report)
 	}
+	report.Step("execute_validation", nil)
+	report.Details["drop_id"] = testDrop.ID
+	report.Details["task_status"] = result.Status
 	
 	duration := time.Since(startTime)
 	agentLogger.Info("✅ Mock deployment validation completed",
@@ -175,6 +183,7 @@ func main() { fmt.Println("Hello from QuantumLayer!") }`,
 	fmt.Println("2. Set up Azure credentials: ./scripts/setup-azure-test.sh")
 	fmt.Println("3. Check Azure deployment validation in action!")
 	fmt.Println(strings.Repeat("=", 70))
+	opts.Finish(report)
 }
 
 func printMockResults(result *types.TaskResult, logger logger.Interface) {
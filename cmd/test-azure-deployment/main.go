@@ -10,13 +10,18 @@ import (
 
 	"QLP/internal/agents"
 	"QLP/internal/deployment/azure"
+	"QLP/internal/headless"
 	"QLP/internal/logger"
 	"QLP/internal/packaging"
 	"QLP/internal/types"
 	"go.uber.org/zap"
 )
 
+// Scenarios: "validate" runs the deployment validator against the sample
+// Go web server; "config" only checks the Azure configuration.
 func main() {
+	opts := headless.Parse("validate", "config")
+	report := opts.NewResult("test-azure-deployment")
 	ctx := context.Background()
 	
 	// Initialize logger
@@ -30,7 +35,12 @@ func main() {
 	// Check Azure configuration
 	if err := checkAzureConfig(); err != nil {
 		agentLogger.Error("Azure configuration check failed", zap.Error(err))
-		os.Exit(1)
+		report.Fail(err, headless.ExitUsage)
+		opts.Finish(report)
+	}
+	report.Step("check_azure_config", nil)
+	if opts.Scenario == "config" {
+		opts.Finish(report)
 	}
 	
 	// Create test QuantumDrop
@@ -59,6 +69,7 @@ func main() {
 			RetryAttempts: 3,
 			RetryDelay:    30 * time.Second,
 		},
+		Regions: azure.ParseRegions(getEnvOrDefault("QLP_AZURE_REGIONS", "")),
 	}
 	
 	// Create mock LLM client for testing
@@ -74,8 +85,10 @@ func main() {
 	)
 	if err != nil {
 		agentLogger.Error("Failed to create deployment validator agent", zap.Error(err))
-		os.Exit(1)
+		report.Step("create_agent", err)
+		opts.Finish(report)
 	}
+	report.Step("create_agent", nil)
 	
 	// Create test task
 	task := types.Task{
@@ -104,8 +117,12 @@ func main() {
 		if result != nil {
 			printTestResults(result, agentLogger)
 		}
-		os.Exit(1)
+		report.Step("execute_validation", err)
+		opts.Finish(report)
 	}
+	report.Step("execute_validation", taskResultError(result))
+	report.Details["drop_id"] = testDrop.ID
+	report.Details["task_status"] = result.Status
 	
 	duration := time.Since(startTime)
 	agentLogger.Info("Deployment validation completed",
@@ -118,13 +135,26 @@ func main() {
 	
 	// Test cleanup functionality
 	agentLogger.Info("Testing cleanup functionality")
-	if err := agent.Cleanup(ctx); err != nil {
+	err = agent.Cleanup(ctx)
+	report.Step("cleanup", err)
+	if err != nil {
 		agentLogger.Warn("Cleanup failed", zap.Error(err))
 	} else {
 		agentLogger.Info("Cleanup completed successfully")
 	}
 	
-	agentLogger.Info("Azure deployment validation test completed successfully!")
+	if report.Status == headless.StatusPassed {
+		agentLogger.Info("Azure deployment validation test completed successfully!")
+	}
+	opts.Finish(report)
+}
+
+// taskResultError turns a non-completed task result into a step failure
+func taskResultError(result *types.TaskResult) error {
+	if result.Status == types.TaskStatusCompleted {
+		return nil
+	}
+	return fmt.Errorf("validation finished with status %s: %s", result.Status, result.ErrorMessage)
 }
 
 func checkAzureConfig() error {
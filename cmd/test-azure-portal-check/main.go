@@ -1,22 +1,25 @@
 package main
 
 import (
-	"bufio"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
 	"time"
 
 	"QLP/internal/deployment/azure"
+	"QLP/internal/headless"
 	"QLP/internal/logger"
 	"go.uber.org/zap"
 )
 
-// Test that creates Azure resources and waits for manual verification
+// Test that creates Azure resources and waits for manual verification.
+// Scenarios: "verify" creates, lists and deletes the group; "keep" leaves it
+// for manual inspection. Run with --yes --no-wait to go unattended.
 func main() {
+	opts := headless.Parse("verify", "keep")
+	result := opts.NewResult("test-azure-portal-check")
 	ctx := context.Background()
 	
 	// Initialize logger
@@ -31,7 +34,15 @@ func main() {
 	azureConfig, err := getAzureConfig()
 	if err != nil {
 		agentLogger.Error("Failed to get Azure configuration", zap.Error(err))
-		os.Exit(1)
+		result.Fail(err, headless.ExitUsage)
+		opts.Finish(result)
+	}
+	
+	client, err := azure.NewAzureClient(azureConfig)
+	if err != nil {
+		agentLogger.Error("Failed to create Azure client", zap.Error(err))
+		result.Fail(err, headless.ExitUsage)
+		opts.Finish(result)
 	}
 	
 	fmt.Println("🔥 QUANTUMLAYER AZURE PORTAL VERIFICATION TEST")
@@ -43,19 +54,17 @@ func main() {
 	
 	// Create unique resource group name
 	resourceGroupName := fmt.Sprintf("quantumlayer-test-%d", time.Now().Unix())
+	result.Details["resource_group"] = resourceGroupName
+	result.Details["location"] = azureConfig.Location
 	
 	fmt.Printf("🚀 Creating Resource Group: %s\n", resourceGroupName)
 	fmt.Println("⏳ This will create a REAL Azure resource group...")
 	
 	// Confirm before creating
-	fmt.Print("Continue? (y/N): ")
-	reader := bufio.NewReader(os.Stdin)
-	response, _ := reader.ReadString('\n')
-	response = strings.TrimSpace(strings.ToLower(response))
-	
-	if response != "y" && response != "yes" {
+	if !opts.Confirm("Continue?", false) {
 		fmt.Println("❌ Test cancelled by user")
-		return
+		result.Cancel()
+		opts.Finish(result)
 	}
 	
 	// Create resource group
@@ -64,168 +73,158 @@ func main() {
 		zap.String("location", azureConfig.Location),
 	)
 	
-	if err := createResourceGroupWithDetails(ctx, resourceGroupName, azureConfig.Location, agentLogger); err != nil {
+	err = createResourceGroupWithDetails(ctx, client, resourceGroupName, azureConfig.Location, agentLogger)
+	result.Step("create_resource_group", err)
+	if err != nil {
 		agentLogger.Error("Failed to create resource group", zap.Error(err))
-		os.Exit(1)
+		opts.Finish(result)
 	}
 	
 	// Show resource group details
 	fmt.Println("\n✅ RESOURCE GROUP CREATED SUCCESSFULLY!")
 	fmt.Println(strings.Repeat("=", 60))
 	
-	if err := showResourceGroupDetails(ctx, resourceGroupName, agentLogger); err != nil {
+	err = showResourceGroupDetails(ctx, client, resourceGroupName, agentLogger)
+	result.Step("show_resource_group", err)
+	if err != nil {
 		agentLogger.Warn("Failed to get resource group details", zap.Error(err))
 	}
 	
 	// Wait for manual verification
+	portalURL := fmt.Sprintf("https://portal.azure.com/#@%s/resource/subscriptions/%s/resourceGroups/%s/overview",
+		azureConfig.TenantID, azureConfig.SubscriptionID, resourceGroupName)
+	result.Details["portal_url"] = portalURL
 	fmt.Println("\n🔍 MANUAL VERIFICATION INSTRUCTIONS:")
 	fmt.Println("1. Open the Azure Portal: https://portal.azure.com")
 	fmt.Println("2. Navigate to Resource Groups")
 	fmt.Printf("3. Look for resource group: %s\n", resourceGroupName)
 	fmt.Println("4. Verify it exists and shows the tags")
 	fmt.Println()
-	fmt.Printf("🌐 Direct link: https://portal.azure.com/#@%s/resource/subscriptions/%s/resourceGroups/%s/overview\n", 
-		azureConfig.TenantID, azureConfig.SubscriptionID, resourceGroupName)
+	fmt.Printf("🌐 Direct link: %s\n", portalURL)
 	fmt.Println()
 	
 	// Wait for user confirmation
 	fmt.Println("⏰ Resource group will remain active for verification...")
-	fmt.Print("Press ENTER when you've verified the resource group in the portal: ")
-	reader.ReadString('\n')
+	opts.Pause("Press ENTER when you've verified the resource group in the portal")
 	
 	// List all QuantumLayer resource groups
 	fmt.Println("\n📋 LISTING ALL QUANTUMLAYER RESOURCE GROUPS:")
-	if err := listQuantumLayerResourceGroups(ctx, agentLogger); err != nil {
+	err = listQuantumLayerResourceGroups(ctx, client, agentLogger)
+	result.Step("list_resource_groups", err)
+	if err != nil {
 		agentLogger.Warn("Failed to list resource groups", zap.Error(err))
 	}
 	
 	// Cleanup confirmation
 	fmt.Printf("\n🧹 Ready to delete resource group: %s\n", resourceGroupName)
-	fmt.Print("Delete the resource group? (Y/n): ")
-	response, _ = reader.ReadString('\n')
-	response = strings.TrimSpace(strings.ToLower(response))
-	
-	if response == "n" || response == "no" {
+	if opts.Scenario == "keep" || !opts.Confirm("Delete the resource group?", true) {
 		fmt.Printf("⚠️  Resource group %s left active for manual cleanup\n", resourceGroupName)
 		fmt.Printf("To delete later: az group delete --name %s --yes\n", resourceGroupName)
-		return
+		result.Details["left_active"] = true
+		opts.Finish(result)
 	}
 	
 	// Delete resource group
 	agentLogger.Info("Deleting resource group", zap.String("name", resourceGroupName))
 	
-	if err := deleteResourceGroup(ctx, resourceGroupName, agentLogger); err != nil {
+	err = deleteResourceGroup(ctx, client, resourceGroupName, agentLogger)
+	result.Step("delete_resource_group", err)
+	if err != nil {
 		agentLogger.Error("Failed to delete resource group", zap.Error(err))
 		fmt.Printf("❌ Failed to delete resource group. Delete manually: az group delete --name %s --yes\n", resourceGroupName)
-		os.Exit(1)
+		opts.Finish(result)
 	}
 	
-	fmt.Println("\n🎉 AZURE PORTAL VERIFICATION TEST COMPLETED!")
-	fmt.Println("✅ Resource group created and verified")
-	fmt.Println("✅ Resource group deleted successfully")
-	fmt.Println("🔥 QuantumLayer Azure integration is LIVE!")
+	if result.Status == headless.StatusPassed {
+		fmt.Println("\n🎉 AZURE PORTAL VERIFICATION TEST COMPLETED!")
+		fmt.Println("✅ Resource group created and verified")
+		fmt.Println("✅ Resource group deleted successfully")
+		fmt.Println("🔥 QuantumLayer Azure integration is LIVE!")
+	}
+	opts.Finish(result)
 }
 
-func createResourceGroupWithDetails(ctx context.Context, name, location string, logger logger.Interface) error {
+func createResourceGroupWithDetails(ctx context.Context, client *azure.AzureClient, name, location string, logger logger.Interface) error {
 	logger.Info("Creating resource group with detailed tracking",
 		zap.String("name", name),
 		zap.String("location", location),
 	)
 	
 	// Create with comprehensive tags
-	cmd := exec.CommandContext(ctx, "az", "group", "create",
-		"--name", name,
-		"--location", location,
-		"--tags",
-		"created-by=quantumlayer",
-		"purpose=portal-verification-test",
-		"test-mode=true",
-		fmt.Sprintf("created-at=%s", time.Now().Format(time.RFC3339)),
-		fmt.Sprintf("expires-at=%s", time.Now().Add(1*time.Hour).Format(time.RFC3339)),
-		"--output", "json")
-	
-	output, err := cmd.CombinedOutput()
+	err := client.CreateResourceGroup(ctx, azure.ResourceGroupSpec{
+		Name:     name,
+		Location: location,
+		TTL:      1 * time.Hour,
+		Tags: map[string]*string{
+			"test-mode":  stringPtr("true"),
+			"created-at": stringPtr(time.Now().Format(time.RFC3339)),
+		},
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create resource group: %w, output: %s", err, string(output))
+		return fmt.Errorf("failed to create resource group: %w", err)
 	}
 	
-	// Parse and display creation details
-	var rgInfo map[string]interface{}
-	if err := json.Unmarshal(output, &rgInfo); err == nil {
-		fmt.Printf("📍 Resource Group ID: %v\n", rgInfo["id"])
-		fmt.Printf("📍 Location: %v\n", rgInfo["location"])
-		fmt.Printf("📍 Provisioning State: %v\n", rgInfo["properties"].(map[string]interface{})["provisioningState"])
+	// Display creation details
+	rg, err := client.GetResourceGroup(ctx, name)
+	if err == nil {
+		fmt.Printf("📍 Resource Group ID: %s\n", deref(rg.ID))
+		fmt.Printf("📍 Location: %s\n", deref(rg.Location))
+		if rg.Properties != nil {
+			fmt.Printf("📍 Provisioning State: %s\n", deref(rg.Properties.ProvisioningState))
+		}
 		
-		if tags, ok := rgInfo["tags"].(map[string]interface{}); ok {
-			fmt.Println("🏷️  Tags:")
-			for key, value := range tags {
-				fmt.Printf("   %s: %v\n", key, value)
-			}
+		fmt.Println("🏷️  Tags:")
+		for key, value := range rg.Tags {
+			fmt.Printf("   %s: %s\n", key, deref(value))
 		}
 	}
 	
 	logger.Info("Resource group created successfully",
 		zap.String("name", name),
-		zap.Int("output_bytes", len(output)),
 	)
 	
 	return nil
 }
 
-func showResourceGroupDetails(ctx context.Context, name string, logger logger.Interface) error {
-	cmd := exec.CommandContext(ctx, "az", "group", "show",
-		"--name", name,
-		"--output", "json")
-	
-	output, err := cmd.CombinedOutput()
+func showResourceGroupDetails(ctx context.Context, client *azure.AzureClient, name string, logger logger.Interface) error {
+	rg, err := client.GetResourceGroup(ctx, name)
 	if err != nil {
 		return fmt.Errorf("failed to get resource group details: %w", err)
 	}
 	
-	var rgInfo map[string]interface{}
-	if err := json.Unmarshal(output, &rgInfo); err != nil {
-		return fmt.Errorf("failed to parse resource group info: %w", err)
-	}
-	
 	fmt.Println("📊 RESOURCE GROUP DETAILS:")
-	fmt.Printf("   Name: %v\n", rgInfo["name"])
-	fmt.Printf("   ID: %v\n", rgInfo["id"])
-	fmt.Printf("   Location: %v\n", rgInfo["location"])
-	fmt.Printf("   Provisioning State: %v\n", 
-		rgInfo["properties"].(map[string]interface{})["provisioningState"])
+	fmt.Printf("   Name: %s\n", deref(rg.Name))
+	fmt.Printf("   ID: %s\n", deref(rg.ID))
+	fmt.Printf("   Location: %s\n", deref(rg.Location))
+	if rg.Properties != nil {
+		fmt.Printf("   Provisioning State: %s\n", deref(rg.Properties.ProvisioningState))
+	}
 	
 	return nil
 }
 
-func listQuantumLayerResourceGroups(ctx context.Context, logger logger.Interface) error {
-	cmd := exec.CommandContext(ctx, "az", "group", "list",
-		"--tag", "created-by=quantumlayer",
-		"--output", "table")
-	
-	output, err := cmd.CombinedOutput()
+func listQuantumLayerResourceGroups(ctx context.Context, client *azure.AzureClient, logger logger.Interface) error {
+	groups, err := client.ListResourceGroups(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to list resource groups: %w", err)
 	}
 	
-	fmt.Println(string(output))
+	fmt.Printf("%-40s %-15s %s\n", "Name", "Location", "Expires")
+	for _, rg := range groups {
+		fmt.Printf("%-40s %-15s %s\n", deref(rg.Name), deref(rg.Location), deref(rg.Tags["auto-delete-after"]))
+	}
 	return nil
 }
 
-func deleteResourceGroup(ctx context.Context, name string, logger logger.Interface) error {
+func deleteResourceGroup(ctx context.Context, client *azure.AzureClient, name string, logger logger.Interface) error {
 	logger.Info("Deleting resource group", zap.String("name", name))
 	
-	// Delete with progress tracking
-	cmd := exec.CommandContext(ctx, "az", "group", "delete",
-		"--name", name,
-		"--yes",
-		"--verbose")
-	
 	fmt.Printf("🗑️  Deleting resource group %s...\n", name)
 	fmt.Println("⏳ This may take a few minutes...")
 	
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to delete resource group: %w, output: %s", err, string(output))
+	// Polls the long-running delete until it completes
+	if err := client.DeleteResourceGroup(ctx, name); err != nil {
+		return fmt.Errorf("failed to delete resource group: %w", err)
 	}
 	
 	logger.Info("Resource group deleted successfully", zap.String("name", name))
@@ -235,36 +234,12 @@ func deleteResourceGroup(ctx context.Context, name string, logger logger.Interfa
 }
 
 func getAzureConfig() (azure.ClientConfig, error) {
-	// Try environment variables first
-	if subscriptionID := os.Getenv("AZURE_SUBSCRIPTION_ID"); subscriptionID != "" {
-		return azure.ClientConfig{
-			SubscriptionID: subscriptionID,
-			Location:       getEnvOrDefault("AZURE_LOCATION", "uksouth"),
-			TenantID:       os.Getenv("AZURE_TENANT_ID"),
-		}, nil
-	}
-	
-	// Fall back to Azure CLI
-	cmd := exec.Command("az", "account", "show", "--output", "json")
-	output, err := cmd.Output()
-	if err != nil {
-		return azure.ClientConfig{}, fmt.Errorf("failed to get Azure account info: %w", err)
-	}
-	
-	var accountInfo struct {
-		ID       string `json:"id"`
-		TenantID string `json:"tenantId"`
+	azureConfig := azure.ClientConfigFromEnv()
+	if azureConfig.SubscriptionID == "" {
+		return azure.ClientConfig{}, errors.New("AZURE_SUBSCRIPTION_ID is not set")
 	}
-	
-	if err := json.Unmarshal(output, &accountInfo); err != nil {
-		return azure.ClientConfig{}, fmt.Errorf("failed to parse Azure account info: %w", err)
-	}
-	
-	return azure.ClientConfig{
-		SubscriptionID: accountInfo.ID,
-		Location:       getEnvOrDefault("AZURE_LOCATION", "uksouth"),
-		TenantID:       accountInfo.TenantID,
-	}, nil
+	azureConfig.Location = getEnvOrDefault("AZURE_LOCATION", "uksouth")
+	return azureConfig, nil
 }
 
 func getEnvOrDefault(key, defaultValue string) string {
@@ -279,4 +254,15 @@ func maskValue(value string) string {
 		return "***"
 	}
 	return value[:4] + "***" + value[len(value)-4:]
-}
\ No newline at end of file
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
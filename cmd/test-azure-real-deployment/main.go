@@ -2,22 +2,24 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
 	"time"
 
 	"QLP/internal/agents"
 	"QLP/internal/deployment/azure"
+	"QLP/internal/headless"
 	"QLP/internal/logger"
 	"QLP/internal/packaging"
 	"go.uber.org/zap"
 )
 
-// Real Azure test using Azure CLI for actual resource creation
+// Real Azure test using the Azure SDK client for actual resource creation
 func main() {
+	opts := headless.Parse("resource-group")
+	result := opts.NewResult("test-azure-real-deployment")
 	ctx := context.Background()
 	
 	// Initialize logger
@@ -28,17 +30,12 @@ func main() {
 	agentLogger := logger.GetDefaultLogger().WithComponent("azure_real_deployment_test")
 	agentLogger.Info("🚀 Starting REAL Azure deployment validation test")
 	
-	// Check Azure CLI is logged in
-	if err := checkAzureCLI(); err != nil {
-		agentLogger.Error("Azure CLI check failed", zap.Error(err))
-		os.Exit(1)
-	}
-	
-	// Get Azure config from environment or CLI
+	// Get Azure config from environment
 	azureConfig, err := getAzureConfig()
 	if err != nil {
 		agentLogger.Error("Failed to get Azure configuration", zap.Error(err))
-		os.Exit(1)
+		result.Fail(err, headless.ExitUsage)
+		opts.Finish(result)
 	}
 	
 	agentLogger.Info("✅ Azure configuration loaded",
@@ -67,17 +64,12 @@ func main() {
 		},
 	}
 	
-	// Create enhanced Azure client that uses CLI fallback
-	realAzureClient := &realAzureClientWithCLI{
-		config: azureConfig,
-		logger: agentLogger,
-	}
-	
-	// Create deployment manager with real Azure client
-	_ = &realDeploymentManager{
-		logger:      agentLogger,
-		azureClient: realAzureClient,
-		costLimit:   deploymentConfig.CostLimitUSD,
+	// Create Azure SDK client
+	realAzureClient, err := azure.NewAzureClient(azureConfig)
+	if err != nil {
+		agentLogger.Error("Failed to create Azure client", zap.Error(err))
+		result.Fail(err, headless.ExitUsage)
+		opts.Finish(result)
 	}
 	
 	// Test resource group creation
@@ -86,8 +78,11 @@ func main() {
 		zap.String("resource_group", resourceGroupName),
 	)
 	
-	// Create resource group using Azure CLI
-	if err := realAzureClient.CreateResourceGroup(ctx, azure.ResourceGroupSpec{
+	result.Details["resource_group"] = resourceGroupName
+	result.Details["location"] = azureConfig.Location
+
+	// Create resource group
+	err = realAzureClient.CreateResourceGroup(ctx, azure.ResourceGroupSpec{
 		Name:     resourceGroupName,
 		Location: azureConfig.Location,
 		TTL:      deploymentConfig.TTL,
@@ -96,9 +91,11 @@ func main() {
 			"created-by":  stringPtr("quantumlayer"),
 			"test-mode":   stringPtr("true"),
 		},
-	}); err != nil {
+	})
+	result.Step("create_resource_group", err)
+	if err != nil {
 		agentLogger.Error("Failed to create real resource group", zap.Error(err))
-		os.Exit(1)
+		opts.Finish(result)
 	}
 	
 	agentLogger.Info("✅ Real resource group created successfully",
@@ -116,7 +113,7 @@ func main() {
 		HealthChecks:  []realHealthCheck{
 			{
 				Name:         "resource_group_exists",
-				Type:         "azure-cli",
+				Type:         "azure-sdk",
 				Status:       "pass",
 				Message:      "Resource group created successfully",
 				ResponseTime: 500 * time.Millisecond,
@@ -150,7 +147,10 @@ func main() {
 	
 	// Cleanup real resources
 	agentLogger.Info("🧹 Cleaning up real Azure resources...")
-	if err := realAzureClient.DeleteResourceGroup(ctx, resourceGroupName); err != nil {
+	err = realAzureClient.DeleteResourceGroup(ctx, resourceGroupName)
+	result.Step("delete_resource_group", err)
+	result.Details["cost_usd"] = testResult.CostEstimate.TotalUSD
+	if err != nil {
 		agentLogger.Error("Failed to cleanup real resource group", 
 			zap.String("resource_group", resourceGroupName),
 			zap.Error(err),
@@ -159,6 +159,7 @@ func main() {
 		agentLogger.Info("✅ Real resource cleanup completed successfully",
 			zap.String("resource_group", resourceGroupName),
 		)
+		opts.Finish(result)
 	}
 	
 	agentLogger.Info("🎉 REAL Azure deployment validation test completed successfully!")
@@ -167,7 +168,7 @@ func main() {
 	fmt.Println("\n" + strings.Repeat("=", 70))
 	fmt.Println("🎯 REAL AZURE TEST SUMMARY")
 	fmt.Println(strings.Repeat("=", 70))
-	fmt.Printf("✅ Azure CLI Integration: SUCCESS\n")
+	fmt.Printf("✅ Azure SDK Integration: SUCCESS\n")
 	fmt.Printf("✅ Real Resource Group Creation: SUCCESS\n")
 	fmt.Printf("✅ Resource Group Cleanup: SUCCESS\n")
 	fmt.Printf("✅ End-to-End Validation: SUCCESS\n")
@@ -175,82 +176,7 @@ func main() {
 	fmt.Printf("⏱️  Total Duration: %v\n", testResult.Duration)
 	fmt.Println("\n🔥 QuantumLayer Azure deployment validation is LIVE!")
 	fmt.Println(strings.Repeat("=", 70))
-}
-
-type realAzureClientWithCLI struct {
-	config azure.ClientConfig
-	logger logger.Interface
-}
-
-func (c *realAzureClientWithCLI) CreateResourceGroup(ctx context.Context, spec azure.ResourceGroupSpec) error {
-	c.logger.Info("Creating real resource group via Azure CLI",
-		zap.String("name", spec.Name),
-		zap.String("location", spec.Location),
-	)
-	
-	// Build tags string for CLI
-	tagsStr := ""
-	if spec.Tags != nil {
-		tagPairs := []string{}
-		for key, value := range spec.Tags {
-			if value != nil {
-				tagPairs = append(tagPairs, fmt.Sprintf("%s=%s", key, *value))
-			}
-		}
-		if len(tagPairs) > 0 {
-			tagsStr = "--tags " + strings.Join(tagPairs, " ")
-		}
-	}
-	
-	// Create resource group using Azure CLI
-	cmd := exec.CommandContext(ctx, "az", "group", "create",
-		"--name", spec.Name,
-		"--location", spec.Location)
-	
-	if tagsStr != "" {
-		cmd.Args = append(cmd.Args, strings.Fields(tagsStr)...)
-	}
-	
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to create resource group via CLI: %w, output: %s", err, string(output))
-	}
-	
-	c.logger.Info("Resource group created successfully via Azure CLI",
-		zap.String("name", spec.Name),
-		zap.String("cli_output", string(output)[:min(200, len(output))]),
-	)
-	
-	return nil
-}
-
-func (c *realAzureClientWithCLI) DeleteResourceGroup(ctx context.Context, name string) error {
-	c.logger.Info("Deleting real resource group via Azure CLI",
-		zap.String("name", name),
-	)
-	
-	// Delete resource group using Azure CLI
-	cmd := exec.CommandContext(ctx, "az", "group", "delete",
-		"--name", name,
-		"--yes",
-		"--no-wait") // Don't wait for completion to avoid long delays
-	
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to delete resource group via CLI: %w, output: %s", err, string(output))
-	}
-	
-	c.logger.Info("Resource group deletion initiated via Azure CLI",
-		zap.String("name", name),
-	)
-	
-	return nil
-}
-
-type realDeploymentManager struct {
-	logger      logger.Interface
-	azureClient *realAzureClientWithCLI
-	costLimit   float64
+	opts.Finish(result)
 }
 
 type realDeploymentResult struct {
@@ -288,53 +214,13 @@ type realCostEstimate struct {
 	BillingPeriod     string             `json:"billing_period"`
 }
 
-func checkAzureCLI() error {
-	// Check if az is available
-	cmd := exec.Command("az", "--version")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("Azure CLI not found: %w", err)
-	}
-	
-	// Check if logged in
-	cmd = exec.Command("az", "account", "show")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("not logged in to Azure CLI: %w", err)
-	}
-	
-	return nil
-}
-
 func getAzureConfig() (azure.ClientConfig, error) {
-	// Try environment variables first
-	if subscriptionID := os.Getenv("AZURE_SUBSCRIPTION_ID"); subscriptionID != "" {
-		return azure.ClientConfig{
-			SubscriptionID: subscriptionID,
-			Location:       getEnvOrDefault("AZURE_LOCATION", "uksouth"),
-			TenantID:       os.Getenv("AZURE_TENANT_ID"),
-		}, nil
-	}
-	
-	// Fall back to Azure CLI
-	cmd := exec.Command("az", "account", "show", "--output", "json")
-	output, err := cmd.Output()
-	if err != nil {
-		return azure.ClientConfig{}, fmt.Errorf("failed to get Azure account info: %w", err)
-	}
-	
-	var accountInfo struct {
-		ID       string `json:"id"`
-		TenantID string `json:"tenantId"`
+	azureConfig := azure.ClientConfigFromEnv()
+	if azureConfig.SubscriptionID == "" {
+		return azure.ClientConfig{}, errors.New("AZURE_SUBSCRIPTION_ID is not set")
 	}
-	
-	if err := json.Unmarshal(output, &accountInfo); err != nil {
-		return azure.ClientConfig{}, fmt.Errorf("failed to parse Azure account info: %w", err)
-	}
-	
-	return azure.ClientConfig{
-		SubscriptionID: accountInfo.ID,
-		Location:       getEnvOrDefault("AZURE_LOCATION", "uksouth"),
-		TenantID:       accountInfo.TenantID,
-	}, nil
+	azureConfig.Location = getEnvOrDefault("AZURE_LOCATION", "uksouth")
+	return azureConfig, nil
 }
 
 func createRealTestQuantumDrop() *packaging.QuantumDrop {
@@ -436,11 +322,4 @@ func maskValue(value string) string {
 
 func stringPtr(s string) *string {
 	return &s
-}
-
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
 }
\ No newline at end of file
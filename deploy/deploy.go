@@ -0,0 +1,10 @@
+// Package deploy embeds the static deployment assets, so commands that
+// render manifests ship the same definitions as this directory.
+package deploy
+
+import _ "embed"
+
+// OperatorCRDs are the Intent, Capsule and ValidationRun definitions
+//
+//go:embed operator/crds.yaml
+var OperatorCRDs string
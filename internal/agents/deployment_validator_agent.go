@@ -7,10 +7,12 @@ import (
 	"strings"
 	"time"
 
+	"QLP/internal/audit"
 	"QLP/internal/deployment/azure"
 	"QLP/internal/llm"
 	"QLP/internal/logger"
 	"QLP/internal/packaging"
+	"QLP/internal/storage"
 	"QLP/internal/types"
 	"go.uber.org/zap"
 )
@@ -25,6 +27,9 @@ type DeploymentValidatorAgent struct {
 	azureClient       *azure.AzureClient
 	capsule           *packaging.QuantumDrop
 	config            azure.DeploymentConfig
+	regions           []string
+	artifactStore     storage.ArtifactStore
+	inlineLimit       int
 }
 
 // DeploymentValidatorConfig configures the deployment validator agent
@@ -35,6 +40,10 @@ type DeploymentValidatorConfig struct {
 	EnableHealthChecks bool
 	EnableFunctionalTests bool
 	CleanupPolicy   azure.CleanupPolicy
+	Regions         []string // validate in each region in parallel; AzureConfig.Location alone when empty
+	FallbackRegions []string // tried in order when a single-region deployment fails preflight
+	TenantAzureConfigs azure.TenantConfigs // per-tenant overrides of AzureConfig
+	Strategy        azure.StrategyConfig // how the containers roll out; recreate when zero
 }
 
 // NewDeploymentValidatorAgent creates a new deployment validator agent
@@ -61,8 +70,10 @@ func NewDeploymentValidatorAgent(
 		CapsuleID:     capsule.ID,
 		ResourceGroup: azure.GenerateResourceGroupName(capsule.ID),
 		Location:      config.AzureConfig.Location,
+		FallbackRegions: config.FallbackRegions,
 		TTL:           config.TTL,
 		CostLimitUSD:  config.CostLimitUSD,
+		Strategy:      config.Strategy,
 		SecurityContext: azure.SecurityContext{
 			ManagedIdentityOnly: true,
 			NetworkIsolation:    true,
@@ -79,6 +90,7 @@ func NewDeploymentValidatorAgent(
 		azureClient:       azureClient,
 		capsule:           capsule,
 		config:            deploymentConfig,
+		regions:           config.Regions,
 	}
 	
 	agentLogger.Info("Deployment validator agent created",
@@ -91,8 +103,25 @@ func NewDeploymentValidatorAgent(
 	return agent, nil
 }
 
+// SetArtifactStore moves attachments larger than inlineLimit bytes out of
+// task results into store, leaving references in their place
+func (dva *DeploymentValidatorAgent) SetArtifactStore(store storage.ArtifactStore, inlineLimit int) {
+	dva.artifactStore = store
+	dva.inlineLimit = inlineLimit
+}
+
 // Execute performs the deployment validation
 func (dva *DeploymentValidatorAgent) Execute(ctx context.Context, task types.Task) (*types.TaskResult, error) {
+	result, err := dva.execute(ctx, task)
+	if dva.artifactStore != nil && len(result.Attachments) > 0 {
+		if offloadErr := storage.OffloadAttachments(ctx, dva.artifactStore, audit.TenantFromContext(ctx), dva.capsule.ID, result, dva.inlineLimit); offloadErr != nil {
+			dva.Logger.Warn("Keeping attachments inline", zap.Error(offloadErr))
+		}
+	}
+	return result, err
+}
+
+func (dva *DeploymentValidatorAgent) execute(ctx context.Context, task types.Task) (*types.TaskResult, error) {
 	dva.Logger.Info("Starting deployment validation",
 		zap.String("task_id", task.ID),
 		zap.String("capsule_id", dva.capsule.ID),
@@ -112,6 +141,10 @@ func (dva *DeploymentValidatorAgent) Execute(ctx context.Context, task types.Tas
 		Attachments: make(map[string][]byte),
 	}
 	
+	if len(dva.regions) > 1 {
+		return dva.executeRegions(ctx, task, result)
+	}
+	
 	// Perform Azure deployment validation
 	deploymentResult, err := dva.deploymentManager.Deploy(ctx, dva.capsule, dva.config)
 	if err != nil {
@@ -157,12 +190,121 @@ func (dva *DeploymentValidatorAgent) Execute(ctx context.Context, task types.Tas
 	return result, nil
 }
 
+// executeRegions validates the capsule in every configured region and
+// reports the regions side by side. The task fails only when no region
+// succeeded.
+func (dva *DeploymentValidatorAgent) executeRegions(ctx context.Context, task types.Task, result *types.TaskResult) (*types.TaskResult, error) {
+	multiRegion := dva.deploymentManager.DeployRegions(ctx, dva.capsule, dva.config, dva.regions)
+	succeeded, failed := multiRegion.Succeeded(), multiRegion.Failed()
+	costs := multiRegion.CostComparison()
+
+	result.Metadata["regions"] = dva.regions
+	result.Metadata["succeeded_regions"] = succeeded
+	result.Metadata["failed_regions"] = failed
+	result.Metadata["region_costs"] = costs
+	result.Metadata["deployment_success"] = len(failed) == 0
+	if len(costs) > 0 {
+		result.Metadata["cheapest_region"] = costs[0].Region
+	}
+
+	if report, err := dva.generateRegionsReport(multiRegion); err != nil {
+		dva.Logger.Warn("Failed to generate multi-region validation report", zap.Error(err))
+	} else {
+		result.Output = report
+	}
+	if resultJSON, err := json.MarshalIndent(multiRegion, "", "  "); err == nil {
+		result.Attachments["multi_region_result.json"] = resultJSON
+	}
+	if junitXML, err := multiRegion.JUnit().Marshal(); err == nil {
+		result.Attachments["junit.xml"] = junitXML
+	}
+	if costJSON, err := json.MarshalIndent(costs, "", "  "); err == nil {
+		result.Attachments["cost_comparison.json"] = costJSON
+	}
+
+	result.EndTime = time.Now()
+	dva.Logger.Info("Multi-region deployment validation completed",
+		zap.String("task_id", task.ID),
+		zap.String("capsule_id", dva.capsule.ID),
+		zap.Strings("succeeded", succeeded),
+		zap.Strings("failed", failed),
+		zap.Duration("duration", result.EndTime.Sub(result.StartTime)),
+	)
+
+	if len(succeeded) == 0 {
+		result.Status = types.TaskStatusFailed
+		result.ErrorMessage = fmt.Sprintf("deployment failed in every region: %s", strings.Join(failed, ", "))
+		dva.Status = AgentStatusFailed
+		return result, fmt.Errorf("%s", result.ErrorMessage)
+	}
+	if len(failed) > 0 {
+		result.ErrorMessage = fmt.Sprintf("deployment failed in %s", strings.Join(failed, ", "))
+	}
+	result.Status = types.TaskStatusCompleted
+	dva.Status = AgentStatusCompleted
+	return result, nil
+}
+
+// generateRegionsReport summarizes each region's outcome and compares their costs
+func (dva *DeploymentValidatorAgent) generateRegionsReport(multiRegion *azure.MultiRegionResult) (string, error) {
+	regions := make([]map[string]interface{}, 0, len(multiRegion.Regions))
+	for _, r := range multiRegion.Regions {
+		region := map[string]interface{}{
+			"region":               r.Region,
+			"success":              r.Success,
+			"resource_group":       r.Result.ResourceGroup,
+			"status":               string(r.Result.Status),
+			"duration_minutes":     r.Result.Duration.Minutes(),
+			"health_checks_passed": dva.countPassedHealthChecks(r.Result.HealthChecks),
+			"tests_passed":         dva.countPassedTests(r.Result.TestResults),
+			"cost_usd":             r.Result.CostEstimate.TotalUSD,
+		}
+		if len(r.Result.AvailabilityIssues) > 0 {
+			region["availability_issues"] = r.Result.AvailabilityIssues
+		}
+		if r.Error != "" {
+			region["error_message"] = r.Error
+			region["recommendations"] = dva.generateFailureRecommendations(r.Result)
+		}
+		regions = append(regions, region)
+	}
+
+	report := map[string]interface{}{
+		"multi_region_validation_report": map[string]interface{}{
+			"capsule_id":        dva.capsule.ID,
+			"start_time":        multiRegion.StartTime.Format(time.RFC3339),
+			"duration_minutes":  multiRegion.Duration.Minutes(),
+			"succeeded_regions": multiRegion.Succeeded(),
+			"failed_regions":    multiRegion.Failed(),
+			"success":           len(multiRegion.Failed()) == 0,
+		},
+		"regions":         regions,
+		"cost_comparison": multiRegion.CostComparison(),
+		"azure_resources": map[string]interface{}{
+			"subscription_id": dva.azureClient.GetSubscriptionID(),
+			"resource_groups": multiRegion.ResourceGroups(),
+		},
+	}
+
+	reportJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal multi-region validation report: %w", err)
+	}
+	return string(reportJSON), nil
+}
+
 // processDeploymentResult converts Azure deployment result to task result metadata
 func (dva *DeploymentValidatorAgent) processDeploymentResult(deploymentResult *azure.DeploymentResult, taskResult *types.TaskResult) {
 	taskResult.Metadata["deployment_status"] = string(deploymentResult.Status)
 	taskResult.Metadata["resource_group"] = deploymentResult.ResourceGroup
 	taskResult.Metadata["deployment_duration"] = deploymentResult.Duration.String()
 	taskResult.Metadata["cost_estimate_usd"] = deploymentResult.CostEstimate.TotalUSD
+	if deploymentResult.ActualCost != nil {
+		taskResult.Metadata["actual_cost_usd"] = deploymentResult.ActualCost.TotalUSD
+	}
+	if deploymentResult.CostAlert != nil {
+		taskResult.Metadata["cost_alert"] = deploymentResult.CostAlert.Message
+	}
 	taskResult.Metadata["health_checks_passed"] = dva.countPassedHealthChecks(deploymentResult.HealthChecks)
 	taskResult.Metadata["total_health_checks"] = len(deploymentResult.HealthChecks)
 	taskResult.Metadata["tests_passed"] = dva.countPassedTests(deploymentResult.TestResults)
@@ -205,6 +347,8 @@ func (dva *DeploymentValidatorAgent) generateValidationReport(deploymentResult *
 			"resource_breakdown": deploymentResult.CostEstimate.ResourceBreakdown,
 			"billing_period":     deploymentResult.CostEstimate.BillingPeriod,
 			"cost_efficiency":    dva.calculateCostEfficiency(deploymentResult),
+			"actual_cost":        deploymentResult.ActualCost,
+			"cost_alert":         deploymentResult.CostAlert,
 		},
 		"health_checks": map[string]interface{}{
 			"total":  len(deploymentResult.HealthChecks),
@@ -227,6 +371,10 @@ func (dva *DeploymentValidatorAgent) generateValidationReport(deploymentResult *
 		},
 	}
 	
+	if deploymentResult.Strategy != nil {
+		report["strategy"] = deploymentResult.Strategy
+	}
+
 	// Add error information if deployment failed
 	if deploymentResult.ErrorMessage != "" {
 		report["error_analysis"] = map[string]interface{}{
@@ -262,10 +410,20 @@ func (dva *DeploymentValidatorAgent) storeDeploymentArtifacts(deploymentResult *
 		taskResult.Attachments["test_results.json"] = testJSON
 	}
 	
+	// Store test results as JUnit XML for CI systems
+	if junitXML, err := deploymentResult.JUnit().Marshal(); err == nil {
+		taskResult.Attachments["junit.xml"] = junitXML
+	}
+	
 	// Store cost breakdown
 	if costJSON, err := json.MarshalIndent(deploymentResult.CostEstimate, "", "  "); err == nil {
 		taskResult.Attachments["cost_estimate.json"] = costJSON
 	}
+	if deploymentResult.ActualCost != nil {
+		if costJSON, err := json.MarshalIndent(deploymentResult.ActualCost, "", "  "); err == nil {
+			taskResult.Attachments["actual_cost.json"] = costJSON
+		}
+	}
 }
 
 // Helper methods
@@ -326,6 +484,10 @@ func (dva *DeploymentValidatorAgent) generateFailureRecommendations(deploymentRe
 	recommendations := []string{}
 	
 	// Analyze failure patterns and suggest fixes
+	if len(deploymentResult.AvailabilityIssues) > 0 {
+		recommendations = append(recommendations, "Choose SKUs and VM sizes offered in every target region, or drop the regions that lack them")
+		recommendations = append(recommendations, "Request vCPU quota increases where preflight reports a shortfall, or set QLP_AZURE_FALLBACK_REGIONS")
+	}
 	if deploymentResult.ErrorMessage != "" {
 		if contains(deploymentResult.ErrorMessage, "timeout") {
 			recommendations = append(recommendations, "Increase deployment timeout or optimize resource provisioning")
@@ -379,22 +541,36 @@ func (dva *DeploymentValidatorAgent) Cleanup(ctx context.Context) error {
 		zap.String("resource_group", dva.config.ResourceGroup),
 	)
 	
-	// Force cleanup of the resource group
-	cleanupManager := azure.NewCleanupManager(dva.azureClient)
-	result := cleanupManager.ForceCleanup(ctx, dva.config.ResourceGroup)
+	// Force cleanup of the resource group, one per region when validating several
+	resourceGroups := []string{dva.config.ResourceGroup}
+	if len(dva.regions) > 1 {
+		resourceGroups = resourceGroups[:0]
+		for _, region := range dva.regions {
+			resourceGroups = append(resourceGroups, azure.RegionResourceGroupName(dva.config.ResourceGroup, region))
+		}
+	}
 	
-	if result.Status != "success" {
-		dva.Logger.Error("Failed to cleanup Azure resources",
-			zap.String("resource_group", dva.config.ResourceGroup),
-			zap.Strings("errors", result.ErrorsEncountered),
+	cleanupManager := azure.NewCleanupManager(dva.azureClient)
+	var errs []string
+	for _, resourceGroup := range resourceGroups {
+		result := cleanupManager.ForceCleanup(ctx, resourceGroup)
+		if result.Status != "success" {
+			dva.Logger.Error("Failed to cleanup Azure resources",
+				zap.String("resource_group", resourceGroup),
+				zap.Strings("errors", result.ErrorsEncountered),
+			)
+			errs = append(errs, result.ErrorsEncountered...)
+			continue
+		}
+		
+		dva.Logger.Info("Azure resources cleaned up successfully",
+			zap.String("resource_group", resourceGroup),
+			zap.Duration("cleanup_duration", result.Duration),
 		)
-		return fmt.Errorf("cleanup failed: %v", result.ErrorsEncountered)
 	}
-	
-	dva.Logger.Info("Azure resources cleaned up successfully",
-		zap.String("resource_group", dva.config.ResourceGroup),
-		zap.Duration("cleanup_duration", result.Duration),
-	)
+	if len(errs) > 0 {
+		return fmt.Errorf("cleanup failed: %v", errs)
+	}
 	
 	return nil
 }
@@ -412,6 +588,7 @@ func (dva *DeploymentValidatorAgent) GetMetrics() map[string]interface{} {
 		"resource_group":  dva.config.ResourceGroup,
 		"cost_limit_usd":  dva.config.CostLimitUSD,
 		"azure_location":  dva.config.Location,
+		"azure_regions":   dva.regions,
 		"ttl_minutes":     dva.config.TTL.Minutes(),
 	}
 }
\ No newline at end of file
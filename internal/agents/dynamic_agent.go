@@ -3,12 +3,16 @@ package agents
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
+	"QLP/internal/agenttrace"
+	"QLP/internal/capabilities"
 	"QLP/internal/events"
 	"QLP/internal/llm"
 	"QLP/internal/logger"
 	"QLP/internal/models"
+	"QLP/internal/prompts"
 	"QLP/internal/sandbox"
 	"QLP/internal/types"
 	"QLP/internal/validation"
@@ -31,16 +35,25 @@ type DynamicAgent struct {
 	SandboxResult     *sandbox.SandboxExecutionResult
 	ValidationResult  *types.ValidationResult
 	Error             error
+	PromptName        string
+	PromptVersion     int
+	AgentType         capabilities.AgentType
+	SelfReviewConfig  SelfReviewConfig
+	SelfReview        *SelfReview
+	Refinements       int
+	ModelParameters   llm.Parameters // Generation call
+	ReviewParameters  llm.Parameters // Self-review and validation calls
 }
 
 type AgentStatus string
 
 const (
-	AgentStatusInitializing AgentStatus = "initializing"
-	AgentStatusReady        AgentStatus = "ready"
-	AgentStatusExecuting    AgentStatus = "executing"
-	AgentStatusCompleted    AgentStatus = "completed"
-	AgentStatusFailed       AgentStatus = "failed"
+	AgentStatusInitializing    AgentStatus = "initializing"
+	AgentStatusReady           AgentStatus = "ready"
+	AgentStatusExecuting       AgentStatus = "executing"
+	AgentStatusNeedsRefinement AgentStatus = "needs_refinement"
+	AgentStatusCompleted       AgentStatus = "completed"
+	AgentStatusFailed          AgentStatus = "failed"
 )
 
 func NewDynamicAgent(task models.Task, llmClient llm.Client, eventBus *events.EventBus, agentContext AgentContext) *DynamicAgent {
@@ -83,7 +96,9 @@ func (da *DynamicAgent) Initialize(ctx context.Context) error {
 		},
 	})
 
-	logger.WithComponent("agents").With(zap.String("agent_id", da.ID)).Info("Agent initialized with specialized prompt")
+	logger.WithComponent("agents").With(zap.String("agent_id", da.ID)).Info("Agent initialized with specialized prompt",
+		zap.String("prompt", da.PromptName),
+		zap.Int("prompt_version", da.PromptVersion))
 	return nil
 }
 
@@ -113,7 +128,22 @@ func (da *DynamicAgent) Execute(ctx context.Context) error {
 
 	executionPrompt := da.buildExecutionPrompt()
 
-	llmOutput, err := da.LLMClient.Complete(ctx, executionPrompt)
+	trace := agenttrace.Default().Start(ctx, agenttrace.Trace{
+		AgentID:   da.ID,
+		TaskID:    da.Task.ID,
+		TaskType:  string(da.Task.Type),
+		AgentType: da.AgentType.Name,
+		Prompt:    agenttrace.Prompt{Name: da.PromptName, Version: da.PromptVersion, Text: executionPrompt},
+	})
+	defer func() {
+		if err := trace.Finish(da.Error); err != nil {
+			logger.WithComponent("agents").Warn("Failed to store execution trace",
+				zap.String("agent_id", da.ID),
+				zap.Error(err))
+		}
+	}()
+
+	llmOutput, err := da.LLMClient.Complete(llm.WithParameters(trace.Context(ctx), da.ModelParameters), executionPrompt)
 	if err != nil {
 		da.Status = AgentStatusFailed
 		da.Error = err
@@ -136,7 +166,49 @@ func (da *DynamicAgent) Execute(ctx context.Context) error {
 	logger.WithComponent("agents").With(zap.String("agent_id", da.ID)).Info("Agent received LLM output, executing in sandbox",
 		zap.Int("llm_output_length", len(llmOutput)))
 
+	trace.Response(llmOutput)
+
+	// Review the output before publishing it, sending low scores back to the
+	// model instead of on to the sandbox
+	da.SelfReview = nil
+	if da.SelfReviewConfig.Enabled {
+		started := time.Now()
+		review, err := da.selfReview(llm.WithParameters(ctx, da.ReviewParameters), llmOutput)
+		if err != nil {
+			logger.WithComponent("agents").With(zap.String("agent_id", da.ID)).Warn("Self-review failed, publishing without a self-score",
+				zap.Error(err))
+			trace.Step("self_review", started, nil, err)
+		} else {
+			da.SelfReview = review
+			trace.Step("self_review", started, map[string]interface{}{
+				"score": review.Score,
+				"gaps":  strings.Join(review.Gaps, "\n"),
+			}, nil)
+		}
+	}
+	if da.needsRefinement() {
+		da.Status = AgentStatusNeedsRefinement
+		da.Output = llmOutput
+		da.publishArtifact("refinement")
+		logger.WithComponent("agents").With(zap.String("agent_id", da.ID)).Info("Self-score below minimum, routing output to refinement",
+			zap.Int("self_score", da.SelfReview.Score),
+			zap.Int("min_score", da.SelfReviewConfig.MinScore),
+			zap.Int("gaps", len(da.SelfReview.Gaps)))
+		return fmt.Errorf("%w: self-score %d is below %d", ErrNeedsRefinement, da.SelfReview.Score, da.SelfReviewConfig.MinScore)
+	}
+	da.publishArtifact("validation")
+
+	started := time.Now()
 	sandboxResult, err := da.SandboxExecutor.Execute(ctx, da.Task, llmOutput)
+	if sandboxResult != nil {
+		trace.Step("sandbox", started, map[string]interface{}{
+			"success":        sandboxResult.Success,
+			"security_score": sandboxResult.SecurityScore,
+			"output":         sandboxResult.Output,
+		}, err)
+	} else {
+		trace.Step("sandbox", started, nil, err)
+	}
 	if err != nil {
 		da.Status = AgentStatusFailed
 		da.Error = err
@@ -165,7 +237,8 @@ func (da *DynamicAgent) Execute(ctx context.Context) error {
 		zap.Duration("execution_time", sandboxResult.ExecutionTime))
 
 	// Validate the output
-	validationResult, err := da.ValidationEngine.ValidateTaskOutput(ctx, da.Task, llmOutput, sandboxResult)
+	started = time.Now()
+	validationResult, err := da.ValidationEngine.ValidateTaskOutput(llm.WithParameters(ctx, da.ReviewParameters), da.Task, llmOutput, sandboxResult)
 	if err != nil {
 		logger.WithComponent("agents").With(zap.String("agent_id", da.ID)).Warn("Validation failed",
 			zap.Error(err))
@@ -178,6 +251,19 @@ func (da *DynamicAgent) Execute(ctx context.Context) error {
 	}
 
 	da.ValidationResult = validationResult
+	trace.Step("validation", started, map[string]interface{}{
+		"overall_score": validationResult.OverallScore,
+		"passed":        validationResult.Passed,
+	}, err)
+
+	// Attribute the score to the prompt version for A/B comparison
+	if registry := prompts.Default(); registry != nil && da.PromptName != "" {
+		if err := registry.RecordScore(da.PromptName, da.PromptVersion, validationResult.OverallScore, validationResult.Passed); err != nil {
+			logger.WithComponent("agents").Warn("Failed to record prompt score",
+				zap.String("prompt", da.PromptName),
+				zap.Error(err))
+		}
+	}
 
 	// Build comprehensive output
 	da.Output = fmt.Sprintf(`=== LLM OUTPUT ===
@@ -205,6 +291,12 @@ Validation Time: %v
 		0, // critique score not available
 		validationResult.ValidationTime,
 	)
+	if review := da.SelfReview; review != nil {
+		da.Output += fmt.Sprintf("\n=== SELF REVIEW ===\nSelf Score: %d/100 after %d refinement(s)\n", review.Score, da.Refinements)
+		for _, gap := range review.Gaps {
+			da.Output += "- " + gap + "\n"
+		}
+	}
 
 	da.Status = AgentStatusCompleted
 
@@ -223,6 +315,8 @@ Validation Time: %v
 			"execution_time":   sandboxResult.ExecutionTime.Milliseconds(),
 			"validation_score": validationResult.OverallScore,
 			"validation_passed": validationResult.Passed,
+			"prompt":            da.PromptName,
+			"prompt_version":    da.PromptVersion,
 		},
 	})
 
@@ -234,8 +328,31 @@ Validation Time: %v
 	return nil
 }
 
+// publishArtifact announces the agent's output with its self-review; next is
+// where the orchestrator routes it, validation or refinement
+func (da *DynamicAgent) publishArtifact(next string) {
+	payload := map[string]interface{}{
+		"agent_id":    da.ID,
+		"task_id":     da.Task.ID,
+		"refinements": da.Refinements,
+		"next":        next,
+	}
+	if da.SelfReview != nil {
+		payload["self_score"] = da.SelfReview.Score
+		payload["self_review"] = da.SelfReview
+		payload["gaps"] = da.SelfReview.Gaps
+	}
+	da.EventBus.Publish(events.Event{
+		ID:        fmt.Sprintf("agent_%s_artifact_%d", da.ID, da.Refinements),
+		Type:      events.EventArtifactCreated,
+		Timestamp: time.Now(),
+		Source:    da.ID,
+		Payload:   payload,
+	})
+}
+
 func (da *DynamicAgent) buildDirectExecutionPrompt() string {
-	taskTypeInstructions := da.getTaskTypeExecutionInstructions()
+	taskTypeInstructions := da.resolveExecutionInstructions() + da.Context.StackGuidance
 	
 	return fmt.Sprintf(`You are an Expert %s Agent. Your job is to DIRECTLY EXECUTE the following task and provide the complete, ready-to-use output.
 
@@ -251,7 +368,7 @@ PROJECT CONTEXT:
 - Dependencies: %v
 
 %s
-
+%s%s%s
 CRITICAL: Provide ONLY the actual executable output (code/configuration/documentation) - NO lists, NO steps, NO explanations, NO process descriptions. Just the final working result that can be used immediately.
 `,
 		da.Task.Type,
@@ -263,9 +380,46 @@ CRITICAL: Provide ONLY the actual executable output (code/configuration/document
 		da.Context.TechStack,
 		da.Task.Dependencies,
 		taskTypeInstructions,
+		da.MetaPromptGen.formatStandards(da.Context.Standards),
+		da.MetaPromptGen.formatExistingProject(da.Context.ExistingProject),
+		da.MetaPromptGen.formatPriorSolutions(da.Context.PriorSolutions),
 	)
 }
 
+// resolveExecutionInstructions returns the task-type instructions from the
+// prompt registry when versioning is enabled, falling back to the built-in
+// text or the prompt of a registered agent type
+func (da *DynamicAgent) resolveExecutionInstructions() string {
+	builtIn := da.getTaskTypeExecutionInstructions()
+	name := "agent.instructions." + string(da.Task.Type)
+	if da.AgentType.Prompt != "" {
+		builtIn = da.AgentType.Prompt
+		name = "agent.instructions." + da.AgentType.Name
+	}
+
+	registry := prompts.Default()
+	if registry == nil {
+		return builtIn
+	}
+
+	if err := registry.Register(name, builtIn); err != nil {
+		logger.WithComponent("agents").Warn("Failed to register prompt",
+			zap.String("prompt", name),
+			zap.Error(err))
+	}
+	rev, err := registry.Select(name, da.ID)
+	if err != nil {
+		logger.WithComponent("agents").Warn("Falling back to built-in prompt",
+			zap.String("prompt", name),
+			zap.Error(err))
+		return builtIn
+	}
+
+	da.PromptName = name
+	da.PromptVersion = rev.Version
+	return rev.Template
+}
+
 func (da *DynamicAgent) buildExecutionPrompt() string {
 	// Use the direct execution prompt - no double-wrapping
 	return da.GeneratedPrompt
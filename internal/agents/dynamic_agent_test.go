@@ -88,12 +88,14 @@ func TestDynamicAgent_ExecutionPromptBuilding(t *testing.T) {
 	agent := NewDynamicAgent(task, mockClient, eventBus, agentContext)
 	agent.GeneratedPrompt = "Test generated prompt"
 
-	prompt := agent.buildExecutionPrompt()
+	// The generated prompt is executed as is
+	if agent.buildExecutionPrompt() != "Test generated prompt" {
+		t.Error("Expected execution prompt to be the generated prompt")
+	}
+
+	prompt := agent.buildDirectExecutionPrompt()
 
 	// Validate execution prompt contains required elements
-	if !contains(prompt, "Test generated prompt") {
-		t.Error("Expected execution prompt to contain generated prompt")
-	}
 
 	if !contains(prompt, "test_prompt_building") {
 		t.Error("Expected execution prompt to contain task ID")
@@ -128,11 +130,13 @@ func TestDynamicAgent_ErrorHandling(t *testing.T) {
 
 	agent := NewDynamicAgent(task, failingClient, eventBus, agentContext)
 
-	// Test initialization failure
+	// Initialization builds the prompt without the client; execution fails
 	ctx := context.Background()
-	err := agent.Initialize(ctx)
-	if err == nil {
-		t.Error("Expected initialization to fail with failing client")
+	if err := agent.Initialize(ctx); err != nil {
+		t.Fatalf("Failed to initialize agent: %v", err)
+	}
+	if err := agent.Execute(ctx); err == nil {
+		t.Error("Expected execution to fail with failing client")
 	}
 
 	if agent.GetStatus() != AgentStatusFailed {
@@ -151,6 +155,10 @@ func (f *FailingMockClient) Complete(ctx context.Context, prompt string) (string
 	return "", &MockError{message: "Mock client intentional failure"}
 }
 
+func (f *FailingMockClient) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	return nil, &MockError{message: "Mock client intentional failure"}
+}
+
 type MockError struct {
 	message string
 }
@@ -6,12 +6,16 @@ import (
 	"sync"
 	"time"
 
+	"QLP/internal/audit"
+	"QLP/internal/capabilities"
+	"QLP/internal/config"
 	"QLP/internal/deployment/azure"
 	"QLP/internal/events"
 	"QLP/internal/llm"
 	"QLP/internal/logger"
 	"QLP/internal/models"
 	"QLP/internal/packaging"
+	"QLP/internal/storage"
 	"QLP/internal/types"
 	"go.uber.org/zap"
 )
@@ -25,6 +29,10 @@ type AgentFactory struct {
 	mu                       sync.RWMutex
 	contextBuilder           *ContextBuilder
 	deploymentValidationConfig *DeploymentValidatorConfig
+	artifactStore            storage.ArtifactStore
+	inlineLimit              int
+	selfReview               SelfReviewConfig
+	modelParameters          ModelParameterConfig
 }
 
 func NewAgentFactory(llmClient llm.Client, eventBus *events.EventBus) *AgentFactory {
@@ -35,16 +43,19 @@ func NewAgentFactory(llmClient llm.Client, eventBus *events.EventBus) *AgentFact
 		activeDeploymentAgents:   make(map[string]*DeploymentValidatorAgent),
 		agentOutputs:             make(map[string]string),
 		contextBuilder:           NewContextBuilder(),
+		selfReview:               SelfReviewConfigFromEnv(),
+		modelParameters:          loadModelParameters(),
 		deploymentValidationConfig: &DeploymentValidatorConfig{
-			AzureConfig: azure.ClientConfig{
-				SubscriptionID: "", // Will be set from environment
-				Location:       "westeurope",
-			},
+			AzureConfig:           azure.ClientConfigFromEnv(),
 			CostLimitUSD:          10.0,                // $10 limit per deployment
 			TTL:                   15 * time.Minute,    // 15 minute TTL
 			EnableHealthChecks:    true,
 			EnableFunctionalTests: true,
 			CleanupPolicy:         azure.DefaultCleanupPolicy(),
+			Regions:               azure.ParseRegions(config.GetEnvOrDefault("QLP_AZURE_REGIONS", "")),
+			FallbackRegions:       azure.ParseRegions(config.GetEnvOrDefault("QLP_AZURE_FALLBACK_REGIONS", "")),
+			TenantAzureConfigs:    loadTenantAzureConfigs(),
+			Strategy:              loadDeployStrategy(),
 		},
 	}
 }
@@ -54,9 +65,21 @@ func (af *AgentFactory) CreateAgent(ctx context.Context, task models.Task, proje
 		zap.String("task_id", task.ID),
 		zap.String("task_type", string(task.Type)))
 
+	agentType, hasType := capabilities.Default().ForTask(task)
+	if hasType {
+		capabilities.Assign(&task, agentType)
+	}
+
 	agentContext := af.contextBuilder.BuildAgentContext(task, projectContext, af.agentOutputs)
 
 	agent := NewDynamicAgent(task, af.llmClient, af.eventBus, agentContext)
+	agent.AgentType = agentType
+	agent.SelfReviewConfig = af.selfReview
+	af.mu.RLock()
+	tenantID := audit.TenantFromContext(ctx)
+	agent.ModelParameters = af.modelParameters.Generation(tenantID, task.Type, agentType.Name)
+	agent.ReviewParameters = af.modelParameters.Review(tenantID)
+	af.mu.RUnlock()
 
 	if err := agent.Initialize(ctx); err != nil {
 		return nil, fmt.Errorf("failed to initialize agent: %w", err)
@@ -73,6 +96,12 @@ func (af *AgentFactory) CreateAgent(ctx context.Context, task models.Task, proje
 }
 
 func (af *AgentFactory) ExecuteAgent(ctx context.Context, agent *DynamicAgent) error {
+	if timeout := capabilities.TaskResources(agent.Task).Timeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	if err := agent.Execute(ctx); err != nil {
 		return fmt.Errorf("agent execution failed: %w", err)
 	}
@@ -137,17 +166,25 @@ func (af *AgentFactory) CreateDeploymentValidatorAgent(
 		zap.String("agent_id", agentID),
 		zap.String("capsule_id", capsule.ID))
 
+	// Tenants with their own Azure configuration deploy into their own
+	// subscription with their own credentials
+	validationConfig := *af.deploymentValidationConfig
+	validationConfig.AzureConfig = validationConfig.TenantAzureConfigs.Resolve(audit.TenantFromContext(ctx), validationConfig.AzureConfig)
+
 	agent, err := NewDeploymentValidatorAgent(
 		agentID,
 		af.llmClient,
 		capsule,
-		*af.deploymentValidationConfig,
+		validationConfig,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create deployment validator agent: %w", err)
 	}
 
 	af.mu.Lock()
+	if af.artifactStore != nil {
+		agent.SetArtifactStore(af.artifactStore, af.inlineLimit)
+	}
 	af.activeDeploymentAgents[agentID] = agent
 	af.mu.Unlock()
 
@@ -167,11 +204,25 @@ func (af *AgentFactory) ExecuteDeploymentValidatorAgent(
 	// Convert models.Task to types.Task for compatibility
 	validationTask := af.convertModelTaskToTypesTask(task)
 	
-	_, err := agent.Execute(ctx, validationTask)
+	result, err := agent.Execute(ctx, validationTask)
 	if err != nil {
 		return fmt.Errorf("deployment validator agent execution failed: %w", err)
 	}
 
+	// Large attachments travel as references; consumers stream them from
+	// artifact storage
+	af.eventBus.PublishWithContext(ctx, events.Event{
+		ID:     fmt.Sprintf("deployment_agent_%s_completed", agent.ID),
+		Type:   events.EventTaskCompleted,
+		Source: agent.ID,
+		Payload: map[string]interface{}{
+			"agent_id":      agent.ID,
+			"task_id":       task.ID,
+			"attachments":   result.Attachments,
+			"artifact_refs": result.ArtifactRefs,
+		},
+	})
+
 	return nil
 }
 
@@ -226,6 +277,52 @@ func (af *AgentFactory) GetActiveDeploymentAgents() map[string]*DeploymentValida
 	return agents
 }
 
+// loadTenantAzureConfigs reads the per-tenant Azure configuration named by
+// QLP_AZURE_TENANT_CONFIG_FILE, if any
+func loadTenantAzureConfigs() azure.TenantConfigs {
+	path := config.GetEnvOrDefault("QLP_AZURE_TENANT_CONFIG_FILE", "")
+	if path == "" {
+		return nil
+	}
+	configs, err := azure.LoadTenantConfigs(path)
+	if err != nil {
+		logger.WithComponent("agents").Warn("Ignoring tenant Azure configuration",
+			zap.String("path", path),
+			zap.Error(err))
+		return nil
+	}
+	return configs
+}
+
+// loadDeployStrategy reads how validation deployments roll out, falling
+// back to recreate when the configuration is invalid
+func loadDeployStrategy() azure.StrategyConfig {
+	strategy, err := azure.StrategyConfigFromEnv()
+	if err != nil {
+		logger.WithComponent("agents").Warn("Ignoring deployment strategy configuration", zap.Error(err))
+		return azure.StrategyConfig{Strategy: azure.StrategyRecreate}
+	}
+	return strategy
+}
+
+// loadModelParameters reads the completion parameters of agents, falling
+// back to the defaults when the configuration is invalid
+func loadModelParameters() ModelParameterConfig {
+	params, err := ModelParametersFromEnv()
+	if err != nil {
+		logger.WithComponent("agents").Warn("Ignoring model parameter configuration", zap.Error(err))
+		return DefaultModelParameters()
+	}
+	return params
+}
+
+// SetModelParameters updates the completion parameters of agents created from now on
+func (af *AgentFactory) SetModelParameters(params ModelParameterConfig) {
+	af.mu.Lock()
+	defer af.mu.Unlock()
+	af.modelParameters = params
+}
+
 // SetDeploymentValidationConfig updates the deployment validation configuration
 func (af *AgentFactory) SetDeploymentValidationConfig(config DeploymentValidatorConfig) {
 	af.mu.Lock()
@@ -233,6 +330,15 @@ func (af *AgentFactory) SetDeploymentValidationConfig(config DeploymentValidator
 	af.deploymentValidationConfig = &config
 }
 
+// SetArtifactStore makes deployment validator agents keep attachments
+// larger than inlineLimit bytes in store rather than in their results
+func (af *AgentFactory) SetArtifactStore(store storage.ArtifactStore, inlineLimit int) {
+	af.mu.Lock()
+	defer af.mu.Unlock()
+	af.artifactStore = store
+	af.inlineLimit = inlineLimit
+}
+
 // convertModelTaskToTypesTask converts models.Task to types.Task
 func (af *AgentFactory) convertModelTaskToTypesTask(task models.Task) types.Task {
 	// TODO: Import types package and implement proper conversion
@@ -251,6 +357,14 @@ type ProjectContext struct {
 	Requirements []string          `json:"requirements"`
 	Constraints  map[string]string `json:"constraints"`
 	Architecture string            `json:"architecture"`
+	// PriorSolutions holds relevant past outputs recalled from generation memory
+	PriorSolutions []string `json:"prior_solutions,omitempty"`
+	// Standards are organization constraints every agent must follow
+	Standards []string `json:"standards,omitempty"`
+	// ExistingProject describes the workspace a follow-up intent extends
+	ExistingProject string `json:"existing_project,omitempty"`
+	// Guidance holds the target stack's instructions for each task type
+	Guidance map[models.TaskType]string `json:"guidance,omitempty"`
 }
 
 type ContextBuilder struct{}
@@ -278,6 +392,10 @@ func (cb *ContextBuilder) BuildAgentContext(task models.Task, projectContext Pro
 		OutputRequirements: outputRequirements,
 		Constraints:        constraints,
 		PreviousOutputs:    dependencyOutputs,
+		PriorSolutions:     projectContext.PriorSolutions,
+		Standards:          projectContext.Standards,
+		ExistingProject:    projectContext.ExistingProject,
+		StackGuidance:      projectContext.Guidance[task.Type],
 	}
 }
 
@@ -0,0 +1,15 @@
+package agents
+
+import (
+	"os"
+	"testing"
+
+	"QLP/internal/logger"
+)
+
+func TestMain(m *testing.M) {
+	cfg := logger.DefaultConfig()
+	cfg.Level = logger.ERROR
+	logger.InitLogger(cfg)
+	os.Exit(m.Run())
+}
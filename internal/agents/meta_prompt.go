@@ -37,6 +37,10 @@ type AgentContext struct {
 	OutputRequirements []string          `json:"output_requirements"`
 	Constraints        map[string]string `json:"constraints"`
 	PreviousOutputs    map[string]string `json:"previous_outputs"`
+	PriorSolutions     []string          `json:"prior_solutions,omitempty"`
+	Standards          []string          `json:"standards,omitempty"`
+	ExistingProject    string            `json:"existing_project,omitempty"`
+	StackGuidance      string            `json:"stack_guidance,omitempty"`
 }
 
 func (m *MetaPromptGenerator) buildMetaPrompt(task models.Task, context AgentContext) string {
@@ -74,7 +78,12 @@ EXECUTE THIS TASK NOW and provide ONLY the final deliverable output - no explana
 		m.formatPreviousOutputs(context.PreviousOutputs),
 	)
 
-	return basePrompt + m.getTaskTypeSpecificGuidance(task.Type)
+	// A non-Go target stack replaces the Go-specific guidance
+	guidance := context.StackGuidance
+	if guidance == "" {
+		guidance = m.getTaskTypeSpecificGuidance(task.Type)
+	}
+	return basePrompt + m.formatStandards(context.Standards) + m.formatExistingProject(context.ExistingProject) + m.formatPriorSolutions(context.PriorSolutions) + guidance
 }
 
 func (m *MetaPromptGenerator) getTaskTypeSpecificGuidance(taskType models.TaskType) string {
@@ -211,3 +220,44 @@ func (m *MetaPromptGenerator) formatPreviousOutputs(outputs map[string]string) s
 
 	return formatted.String()
 }
+
+// formatStandards renders organization constraints the output must satisfy
+func (m *MetaPromptGenerator) formatStandards(standards []string) string {
+	if len(standards) == 0 {
+		return ""
+	}
+
+	var formatted strings.Builder
+	formatted.WriteString("\nORGANIZATION STANDARDS (mandatory, output violating these will be rejected):\n")
+	for _, standard := range standards {
+		formatted.WriteString("- ")
+		formatted.WriteString(standard)
+		formatted.WriteString("\n")
+	}
+
+	return formatted.String()
+}
+
+// formatExistingProject renders the workspace the output must extend
+func (m *MetaPromptGenerator) formatExistingProject(existing string) string {
+	if existing == "" {
+		return ""
+	}
+	return "\n" + existing
+}
+
+// formatPriorSolutions renders similar past solutions recalled from generation memory
+func (m *MetaPromptGenerator) formatPriorSolutions(solutions []string) string {
+	if len(solutions) == 0 {
+		return ""
+	}
+
+	var formatted strings.Builder
+	formatted.WriteString("\nRELEVANT PRIOR SOLUTIONS (reuse proven patterns where they fit this task):\n")
+	for _, solution := range solutions {
+		formatted.WriteString(solution)
+		formatted.WriteString("\n")
+	}
+
+	return formatted.String()
+}
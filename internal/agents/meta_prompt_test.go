@@ -2,7 +2,6 @@ package agents
 
 import (
 	"QLP/internal/models"
-	"context"
 	"testing"
 	"time"
 )
@@ -56,7 +55,7 @@ func TestMetaPromptGenerator_BuildMetaPrompt(t *testing.T) {
 
 	// Test task-specific guidance
 	guidance := generator.getTaskTypeSpecificGuidance(models.TaskTypeCodegen)
-	if !contains(guidance, "CODEGEN AGENT REQUIREMENTS") {
+	if !contains(guidance, "Complete, executable Go code") {
 		t.Error("Expected codegen-specific guidance")
 	}
 }
@@ -68,11 +67,11 @@ func TestMetaPromptGenerator_TaskTypeSpecificGuidance(t *testing.T) {
 		taskType models.TaskType
 		expected string
 	}{
-		{models.TaskTypeCodegen, "CODEGEN AGENT REQUIREMENTS"},
-		{models.TaskTypeInfra, "INFRASTRUCTURE AGENT REQUIREMENTS"},
-		{models.TaskTypeDoc, "DOCUMENTATION AGENT REQUIREMENTS"},
-		{models.TaskTypeTest, "TESTING AGENT REQUIREMENTS"},
-		{models.TaskTypeAnalyze, "ANALYSIS AGENT REQUIREMENTS"},
+		{models.TaskTypeCodegen, "Complete, executable Go code"},
+		{models.TaskTypeInfra, "Complete infrastructure configuration"},
+		{models.TaskTypeDoc, "Complete documentation in Markdown"},
+		{models.TaskTypeTest, "Complete test code"},
+		{models.TaskTypeAnalyze, "Complete analysis report"},
 	}
 
 	for _, tc := range testCases {
@@ -103,19 +102,3 @@ func TestMetaPromptGenerator_FormatPreviousOutputs(t *testing.T) {
 		t.Error("Expected formatted output to contain all task IDs")
 	}
 }
-
-// Helper function to check if string contains substring
-func contains(str, substr string) bool {
-	if len(substr) == 0 {
-		return true
-	}
-	if len(str) < len(substr) {
-		return false
-	}
-	for i := 0; i <= len(str)-len(substr); i++ {
-		if str[i:i+len(substr)] == substr {
-			return true
-		}
-	}
-	return false
-}
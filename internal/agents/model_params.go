@@ -0,0 +1,153 @@
+package agents
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"QLP/internal/config"
+	"QLP/internal/llm"
+	"QLP/internal/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ModelParameterConfig selects the completion parameters of agent LLM calls.
+// Generation resolves Default, then the task type, then the agent type;
+// self-review and validation use Validation over Default. A tenant entry, or
+// the "*" entry for tenants without one, is layered on top the same way.
+type ModelParameterConfig struct {
+	Default    llm.Parameters                     `json:"default" yaml:"default"`
+	TaskTypes  map[models.TaskType]llm.Parameters `json:"task_types" yaml:"task_types"`
+	Agents     map[string]llm.Parameters          `json:"agents" yaml:"agents"` // By agent type name
+	Validation llm.Parameters                     `json:"validation" yaml:"validation"`
+	Tenants    map[string]ModelParameterConfig    `json:"tenants" yaml:"tenants"`
+}
+
+func temperature(t float64) *float64 {
+	return &t
+}
+
+// DefaultModelParameters keeps generation of code and infrastructure close
+// to deterministic, gives documentation room to vary and makes validation
+// repeatable
+func DefaultModelParameters() ModelParameterConfig {
+	return ModelParameterConfig{
+		TaskTypes: map[models.TaskType]llm.Parameters{
+			models.TaskTypeCodegen: {Temperature: temperature(0.2)},
+			models.TaskTypeInfra:   {Temperature: temperature(0.1)},
+			models.TaskTypeTest:    {Temperature: temperature(0.2)},
+			models.TaskTypeDoc:     {Temperature: temperature(0.7)},
+			models.TaskTypeAnalyze: {Temperature: temperature(0.3)},
+		},
+		Validation: llm.Parameters{Temperature: temperature(0)},
+	}
+}
+
+// Generation returns the parameters of an agent's generation call
+func (c ModelParameterConfig) Generation(tenantID string, taskType models.TaskType, agentType string) llm.Parameters {
+	p := c.generation(taskType, agentType)
+	if tenant, ok := c.tenant(tenantID); ok {
+		p = p.Merge(tenant.generation(taskType, agentType))
+	}
+	return p
+}
+
+// Review returns the parameters of self-review and validation calls
+func (c ModelParameterConfig) Review(tenantID string) llm.Parameters {
+	p := c.Default.Merge(c.Validation)
+	if tenant, ok := c.tenant(tenantID); ok {
+		p = p.Merge(tenant.Default).Merge(tenant.Validation)
+	}
+	return p
+}
+
+func (c ModelParameterConfig) generation(taskType models.TaskType, agentType string) llm.Parameters {
+	return c.Default.Merge(c.TaskTypes[taskType]).Merge(c.Agents[agentType])
+}
+
+func (c ModelParameterConfig) tenant(tenantID string) (ModelParameterConfig, bool) {
+	if t, ok := c.Tenants[tenantID]; ok {
+		return t, true
+	}
+	t, ok := c.Tenants["*"]
+	return t, ok
+}
+
+// Validate checks every parameter set, naming the first invalid one
+func (c ModelParameterConfig) Validate() error {
+	check := func(name string, p llm.Parameters) error {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return nil
+	}
+	if err := check("default", c.Default); err != nil {
+		return err
+	}
+	if err := check("validation", c.Validation); err != nil {
+		return err
+	}
+	for taskType, p := range c.TaskTypes {
+		if err := check("task_types."+string(taskType), p); err != nil {
+			return err
+		}
+	}
+	for agent, p := range c.Agents {
+		if err := check("agents."+agent, p); err != nil {
+			return err
+		}
+	}
+	for tenantID, t := range c.Tenants {
+		if len(t.Tenants) > 0 {
+			return fmt.Errorf("tenants.%s: tenant overrides cannot nest tenants", tenantID)
+		}
+		if err := t.Validate(); err != nil {
+			return fmt.Errorf("tenants.%s.%w", tenantID, err)
+		}
+	}
+	return nil
+}
+
+// LoadModelParameters reads a YAML or JSON parameter file and layers it
+// over the defaults
+func LoadModelParameters(path string) (ModelParameterConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ModelParameterConfig{}, fmt.Errorf("failed to read model parameters: %w", err)
+	}
+	var file ModelParameterConfig
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return ModelParameterConfig{}, fmt.Errorf("failed to parse model parameters: %w", err)
+	}
+	if err := file.Validate(); err != nil {
+		return ModelParameterConfig{}, fmt.Errorf("invalid model parameters: %w", err)
+	}
+
+	cfg := DefaultModelParameters()
+	cfg.Default = cfg.Default.Merge(file.Default)
+	cfg.Validation = cfg.Validation.Merge(file.Validation)
+	for taskType, p := range file.TaskTypes {
+		cfg.TaskTypes[taskType] = cfg.TaskTypes[taskType].Merge(p)
+	}
+	cfg.Agents = file.Agents
+	cfg.Tenants = file.Tenants
+	return cfg, nil
+}
+
+// ModelParametersFromEnv loads QLP_MODEL_PARAMETERS_FILE, or the defaults
+// when it is unset
+func ModelParametersFromEnv() (ModelParameterConfig, error) {
+	path := config.GetEnvOrDefault("QLP_MODEL_PARAMETERS_FILE", "")
+	if path == "" {
+		return DefaultModelParameters(), nil
+	}
+	return LoadModelParameters(path)
+}
@@ -0,0 +1,116 @@
+package agents
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"QLP/internal/events"
+	"QLP/internal/llm"
+	"QLP/internal/models"
+)
+
+const testModelParameters = `default:
+  max_tokens: 4000
+task_types:
+  codegen: {top_p: 0.9}
+agents:
+  security-reviewer: {temperature: 0}
+validation:
+  max_tokens: 1000
+tenants:
+  acme:
+    task_types:
+      codegen: {temperature: 0.05}
+  "*":
+    default: {max_tokens: 3000}
+`
+
+func writeModelParameters(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "model-parameters.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestModelParameterResolution(t *testing.T) {
+	cfg, err := LoadModelParameters(writeModelParameters(t, testModelParameters))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Built-in task type temperature, file top_p and default max tokens
+	p := cfg.Generation("globex-unlisted", models.TaskTypeCodegen, "")
+	if *p.Temperature != 0.2 || *p.TopP != 0.9 || p.MaxTokens != 3000 {
+		t.Errorf("codegen for other tenants = %+v", p)
+	}
+	p = cfg.Generation("acme", models.TaskTypeCodegen, "")
+	if *p.Temperature != 0.05 || p.MaxTokens != 4000 {
+		t.Errorf("codegen for acme = %+v", p)
+	}
+	p = cfg.Generation("acme", models.TaskTypeAnalyze, "security-reviewer")
+	if *p.Temperature != 0 || p.TopP != nil {
+		t.Errorf("security-reviewer = %+v", p)
+	}
+	p = cfg.Review("acme")
+	if *p.Temperature != 0 || p.MaxTokens != 1000 {
+		t.Errorf("review = %+v", p)
+	}
+}
+
+func TestLoadModelParametersRejectsInvalid(t *testing.T) {
+	for content, want := range map[string]string{
+		"default: {temperature: 3}\n":                           "default: temperature 3",
+		"task_types:\n  doc: {top_p: 0}\n":                      "task_types.doc: top_p 0",
+		"tenants:\n  acme:\n    validation: {max_tokens: -1}\n": "tenants.acme.validation",
+		"tenants:\n  acme:\n    tenants:\n      inner: {}\n":    "cannot nest tenants",
+	} {
+		_, err := LoadModelParameters(writeModelParameters(t, content))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: err = %v, want %q", content, err, want)
+		}
+	}
+}
+
+// paramsClient records the parameters each completion is asked for with
+type paramsClient struct {
+	params []llm.Parameters
+}
+
+func (c *paramsClient) Complete(ctx context.Context, _ string) (string, error) {
+	c.params = append(c.params, llm.ParametersFromContext(ctx))
+	return "package main\n\nfunc main() {}\n", nil
+}
+
+func (c *paramsClient) GenerateEmbedding(context.Context, string) ([]float32, error) {
+	return nil, nil
+}
+
+func TestAgentCompletesWithItsModelParameters(t *testing.T) {
+	cfg, err := LoadModelParameters(writeModelParameters(t, testModelParameters))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &paramsClient{}
+	task := models.Task{ID: "task_1", Type: models.TaskTypeCodegen, Description: "Create a health endpoint"}
+	agent := NewDynamicAgent(task, client, events.NewEventBus(), AgentContext{ProjectType: "web_api"})
+	agent.ModelParameters = cfg.Generation("acme", task.Type, "")
+
+	ctx := context.Background()
+	if err := agent.Initialize(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.params) == 0 {
+		t.Fatal("no completion requested")
+	}
+	if p := client.params[0]; p.Temperature == nil || *p.Temperature != 0.05 || p.MaxTokens != 4000 || p.TopP == nil || *p.TopP != 0.9 {
+		t.Errorf("generation parameters = %+v", p)
+	}
+}
@@ -0,0 +1,148 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"QLP/internal/config"
+	"QLP/internal/llm"
+)
+
+// ErrNeedsRefinement is returned by Execute when the agent's self-review
+// scored its output below the minimum; Refine prepares the next attempt
+var ErrNeedsRefinement = errors.New("output needs refinement")
+
+// maxReviewedOutput bounds how much of the output the self-review prompt quotes
+const maxReviewedOutput = 24000
+
+// SelfReviewConfig controls the critique an agent runs on its own output
+// before publishing it
+type SelfReviewConfig struct {
+	Enabled        bool
+	MinScore       int // Outputs scoring below go back for refinement
+	MaxRefinements int // Refinement attempts before the output is published anyway
+}
+
+// SelfReviewConfigFromEnv reads QLP_ENABLE_SELF_REVIEW,
+// QLP_SELF_REVIEW_MIN_SCORE (default 60) and QLP_SELF_REVIEW_MAX_REFINEMENTS
+// (default 1)
+func SelfReviewConfigFromEnv() SelfReviewConfig {
+	cfg := SelfReviewConfig{
+		Enabled:        config.GetEnvOrDefault("QLP_ENABLE_SELF_REVIEW", "false") == "true",
+		MinScore:       60,
+		MaxRefinements: 1,
+	}
+	if n, err := strconv.Atoi(config.GetEnvOrDefault("QLP_SELF_REVIEW_MIN_SCORE", "60")); err == nil && n >= 0 && n <= 100 {
+		cfg.MinScore = n
+	}
+	if n, err := strconv.Atoi(config.GetEnvOrDefault("QLP_SELF_REVIEW_MAX_REFINEMENTS", "1")); err == nil && n >= 0 {
+		cfg.MaxRefinements = n
+	}
+	return cfg
+}
+
+// SelfReview is an agent's critique of its output against the task
+type SelfReview struct {
+	Score      int              `json:"score"` // Sum of the rubric, 0-100
+	Rubric     SelfReviewRubric `json:"rubric"`
+	Gaps       []string         `json:"gaps"`
+	Refinement int              `json:"refinement"` // Refinements made before the reviewed output
+}
+
+// SelfReviewRubric scores four criteria out of 25 each
+type SelfReviewRubric struct {
+	Requirements        int `json:"requirements"`         // Every stated requirement is addressed
+	Correctness         int `json:"correctness"`          // The output would work as written
+	Completeness        int `json:"completeness"`         // No placeholders, TODOs or missing files
+	ProductionReadiness int `json:"production_readiness"` // Error handling, security and configuration
+}
+
+// selfReview asks the model to grade output against the task with the rubric
+func (da *DynamicAgent) selfReview(ctx context.Context, output string) (*SelfReview, error) {
+	if len(output) > maxReviewedOutput {
+		output = output[:maxReviewedOutput] + "\n... (output truncated)\n"
+	}
+	prompt := fmt.Sprintf(`You are reviewing the output an agent produced for a task before it is published. Grade it strictly against the task requirements.
+
+TASK:
+- Type: %s
+- Description: %s
+
+OUTPUT:
+%s
+
+Score each criterion from 0 to 25:
+- requirements: every requirement in the description is addressed
+- correctness: the output would work as written, without syntax or logic errors
+- completeness: no placeholders, TODOs, stubs or missing files
+- production_readiness: error handling, security and configuration are in place
+
+List each gap as one concrete, fixable sentence; return an empty list when there are none.`, da.Task.Type, da.Task.Description, output)
+
+	var result struct {
+		Rubric SelfReviewRubric `json:"rubric"`
+		Gaps   []string         `json:"gaps"`
+	}
+	schema := llm.SchemaFor("self_review", "Rubric scores and gaps of an agent output", result)
+	if err := llm.CompleteJSON(ctx, da.LLMClient, prompt, schema, &result); err != nil {
+		return nil, fmt.Errorf("self-review failed: %w", err)
+	}
+
+	r := result.Rubric
+	for _, score := range []*int{&r.Requirements, &r.Correctness, &r.Completeness, &r.ProductionReadiness} {
+		*score = min(max(*score, 0), 25)
+	}
+	gaps := make([]string, 0, len(result.Gaps))
+	for _, gap := range result.Gaps {
+		if gap = strings.TrimSpace(gap); gap != "" {
+			gaps = append(gaps, gap)
+		}
+	}
+	return &SelfReview{
+		Score:      r.Requirements + r.Correctness + r.Completeness + r.ProductionReadiness,
+		Rubric:     r,
+		Gaps:       gaps,
+		Refinement: da.Refinements,
+	}, nil
+}
+
+// needsRefinement reports whether the reviewed output should go back to the
+// model rather than on to the sandbox and validation
+func (da *DynamicAgent) needsRefinement() bool {
+	return da.SelfReview != nil && da.SelfReview.Score < da.SelfReviewConfig.MinScore &&
+		da.Refinements < da.SelfReviewConfig.MaxRefinements
+}
+
+// Refine prepares the agent to run again after ErrNeedsRefinement, asking the
+// model to fix the gaps its self-review found in the previous output
+func (da *DynamicAgent) Refine() error {
+	if da.Status != AgentStatusNeedsRefinement {
+		return fmt.Errorf("agent %s has no output to refine, status: %s", da.ID, da.Status)
+	}
+	output := da.Output
+	if len(output) > maxReviewedOutput {
+		output = output[:maxReviewedOutput] + "\n... (output truncated)\n"
+	}
+
+	var gaps strings.Builder
+	for _, gap := range da.SelfReview.Gaps {
+		fmt.Fprintf(&gaps, "- %s\n", gap)
+	}
+	da.Refinements++
+	da.GeneratedPrompt = fmt.Sprintf(`%s
+
+REFINEMENT %d:
+A review of your previous output scored it %d/100 and found these gaps:
+%s
+PREVIOUS OUTPUT:
+%s
+
+Produce the complete output again, in the same format, with every gap fixed.`,
+		da.buildDirectExecutionPrompt(), da.Refinements, da.SelfReview.Score, gaps.String(), output)
+	da.Output = ""
+	da.Status = AgentStatusReady
+	return nil
+}
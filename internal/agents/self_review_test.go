@@ -0,0 +1,70 @@
+package agents
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"QLP/internal/models"
+)
+
+// reviewClient answers self-review prompts with a fixed critique
+type reviewClient struct {
+	response string
+	prompts  []string
+}
+
+func (c *reviewClient) Complete(_ context.Context, prompt string) (string, error) {
+	c.prompts = append(c.prompts, prompt)
+	return c.response, nil
+}
+
+func (c *reviewClient) GenerateEmbedding(context.Context, string) ([]float32, error) {
+	return nil, nil
+}
+
+func TestSelfReviewRoutesLowScoresToRefinement(t *testing.T) {
+	client := &reviewClient{response: `{"rubric": {"requirements": 10, "correctness": 30, "completeness": 5, "production_readiness": -3}, "gaps": ["No input validation on POST /todos", " "]}`}
+	agent := &DynamicAgent{
+		ID:               "QLD-AGT-1",
+		Task:             models.Task{ID: "task_1", Type: models.TaskTypeCodegen, Description: "Create a todo REST API"},
+		LLMClient:        client,
+		SelfReviewConfig: SelfReviewConfig{Enabled: true, MinScore: 60, MaxRefinements: 1},
+	}
+
+	review, err := agent.selfReview(context.Background(), "package main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Rubric scores are clamped to 0-25 and blank gaps dropped
+	if review.Score != 40 || len(review.Gaps) != 1 || review.Rubric.Correctness != 25 {
+		t.Fatalf("review = %+v", review)
+	}
+	if !strings.Contains(client.prompts[0], "Create a todo REST API") || !strings.Contains(client.prompts[0], "package main") {
+		t.Errorf("prompt does not quote the task and output: %s", client.prompts[0])
+	}
+
+	agent.SelfReview = review
+	if !agent.needsRefinement() {
+		t.Fatal("low self-score not routed to refinement")
+	}
+	agent.Status = AgentStatusNeedsRefinement
+	agent.Output = "package main"
+	if err := agent.Refine(); err != nil {
+		t.Fatal(err)
+	}
+	if agent.Status != AgentStatusReady || agent.Refinements != 1 {
+		t.Errorf("status = %s, refinements = %d", agent.Status, agent.Refinements)
+	}
+	if !strings.Contains(agent.GeneratedPrompt, "No input validation on POST /todos") || !strings.Contains(agent.GeneratedPrompt, "scored it 40/100") {
+		t.Errorf("refinement prompt = %s", agent.GeneratedPrompt)
+	}
+
+	// The budget is spent, so the next low score is published anyway
+	if agent.needsRefinement() {
+		t.Error("refinement requested beyond the budget")
+	}
+	if err := agent.Refine(); err == nil {
+		t.Error("Refine succeeded without a reviewed output")
+	}
+}
@@ -0,0 +1,52 @@
+package agenttrace
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"QLP/internal/audit"
+)
+
+// Routes returns the HTTP handler for reading execution traces. A run is
+// its number or "latest"; ?tenant= restricts the lookup to that tenant's runs.
+func Routes(s *Store) map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /agents/{id}/executions/{run}/trace": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			run := 0
+			if param := req.PathValue("run"); param != "latest" {
+				n, err := strconv.Atoi(param)
+				if err != nil || n < 1 {
+					http.Error(w, "run must be a positive number or latest", http.StatusBadRequest)
+					return
+				}
+				run = n
+			}
+			t, err := s.Get(req.PathValue("id"), run)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			if tenant := audit.RequestTenant(req); tenant != "" && t.TenantID != tenant {
+				writeError(w, ErrNotFound)
+				return
+			}
+			writeJSON(w, http.StatusOK, t)
+		}),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
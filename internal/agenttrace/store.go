@@ -0,0 +1,230 @@
+package agenttrace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/config"
+	"QLP/internal/llm"
+	"QLP/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// ErrNotFound is returned for unknown agents or runs
+var ErrNotFound = errors.New("execution trace not found")
+
+// agentIDPattern keeps agent IDs usable as directory names
+var agentIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Store keeps traces as JSON files, one directory per agent and one file
+// per run, and deletes them once they are older than the retention
+type Store struct {
+	dir       string
+	retention time.Duration // 0 keeps traces forever
+	mu        sync.Mutex
+}
+
+// NewStore opens a trace directory
+func NewStore(dir string, retention time.Duration) (*Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create trace directory: %w", err)
+	}
+	return &Store{dir: dir, retention: retention}, nil
+}
+
+// Start begins the trace of the next run of an agent. The trace carries the
+// tenant of ctx; a nil Store returns a nil Recorder.
+func (s *Store) Start(ctx context.Context, t Trace) *Recorder {
+	if s == nil || !agentIDPattern.MatchString(t.AgentID) {
+		return nil
+	}
+	t.TenantID = audit.TenantFromContext(ctx)
+	t.StartedAt = time.Now().UTC()
+	t.Status = StatusRunning
+	t.Calls, t.Steps = []llm.Call{}, []Step{}
+
+	// Reserve the run number so concurrent runs of an agent do not collide
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t.Run = s.lastRun(t.AgentID) + 1
+	running := t
+	running.Prompt.Text, _ = Redact(t.Prompt.Text)
+	if err := s.write(&running); err != nil {
+		logger.WithComponent("agenttrace").Warn("Failed to start execution trace",
+			zap.String("agent_id", t.AgentID),
+			zap.Error(err))
+		return nil
+	}
+	return &Recorder{store: s, trace: t}
+}
+
+func (s *Store) path(agentID string, run int) string {
+	return filepath.Join(s.dir, agentID, strconv.Itoa(run)+".json")
+}
+
+// runs lists the run numbers recorded for an agent, oldest first
+func (s *Store) runs(agentID string) []int {
+	entries, _ := os.ReadDir(filepath.Join(s.dir, agentID))
+	var runs []int
+	for _, e := range entries {
+		if run, err := strconv.Atoi(strings.TrimSuffix(e.Name(), ".json")); err == nil && strings.HasSuffix(e.Name(), ".json") {
+			runs = append(runs, run)
+		}
+	}
+	sort.Ints(runs)
+	return runs
+}
+
+func (s *Store) lastRun(agentID string) int {
+	if runs := s.runs(agentID); len(runs) > 0 {
+		return runs[len(runs)-1]
+	}
+	return 0
+}
+
+func (s *Store) save(t *Trace) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(t)
+}
+
+func (s *Store) write(t *Trace) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := s.path(t.AgentID, t.Run)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create trace directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write execution trace: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Get returns a run of an agent; run 0 is the latest
+func (s *Store) Get(agentID string, run int) (*Trace, error) {
+	if !agentIDPattern.MatchString(agentID) {
+		return nil, ErrNotFound
+	}
+	if run == 0 {
+		if run = s.lastRun(agentID); run == 0 {
+			return nil, ErrNotFound
+		}
+	}
+	data, err := os.ReadFile(s.path(agentID, run))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read execution trace: %w", err)
+	}
+	var t Trace
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("corrupt execution trace %s: %w", s.path(agentID, run), err)
+	}
+	return &t, nil
+}
+
+// Sweep deletes the traces older than the retention and returns how many
+// were removed
+func (s *Store) Sweep() (int, error) {
+	if s.retention <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().Add(-s.retention)
+	agents, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list execution traces: %w", err)
+	}
+	deleted := 0
+	for _, agent := range agents {
+		if !agent.IsDir() {
+			continue
+		}
+		dir := filepath.Join(s.dir, agent.Name())
+		files, _ := os.ReadDir(dir)
+		for _, f := range files {
+			info, err := f.Info()
+			if err != nil || info.ModTime().After(cutoff) {
+				continue
+			}
+			if os.Remove(filepath.Join(dir, f.Name())) == nil {
+				deleted++
+			}
+		}
+		os.Remove(dir) // Only succeeds once the agent has no traces left
+	}
+	return deleted, nil
+}
+
+// Run sweeps expired traces every interval until ctx is done
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if deleted, err := s.Sweep(); err != nil {
+			logger.WithComponent("agenttrace").Warn("Execution trace sweep failed", zap.Error(err))
+		} else if deleted > 0 {
+			logger.WithComponent("agenttrace").Info("Retention sweep removed execution traces",
+				zap.Int("deleted", deleted))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+var (
+	defaultStore   *Store
+	defaultStoreMu sync.RWMutex
+)
+
+// Default returns the process-wide store, or nil when tracing is disabled
+func Default() *Store {
+	defaultStoreMu.RLock()
+	defer defaultStoreMu.RUnlock()
+	return defaultStore
+}
+
+// SetDefault replaces the process-wide store
+func SetDefault(s *Store) {
+	defaultStoreMu.Lock()
+	defer defaultStoreMu.Unlock()
+	defaultStore = s
+}
+
+// InitFromEnv opens the process-wide store and starts its retention sweep:
+//
+//	QLP_AGENT_TRACE_DIR        trace directory (default ./data/agent-traces)
+//	QLP_AGENT_TRACE_RETENTION  how long traces are kept (default 168h, 0 keeps forever)
+func InitFromEnv(ctx context.Context) (*Store, error) {
+	retention, err := time.ParseDuration(config.GetEnvOrDefault("QLP_AGENT_TRACE_RETENTION", "168h"))
+	if err != nil || retention < 0 {
+		return nil, fmt.Errorf("invalid QLP_AGENT_TRACE_RETENTION %q", config.GetEnvOrDefault("QLP_AGENT_TRACE_RETENTION", ""))
+	}
+	s, err := NewStore(config.GetEnvOrDefault("QLP_AGENT_TRACE_DIR", "./data/agent-traces"), retention)
+	if err != nil {
+		return nil, err
+	}
+	if retention > 0 {
+		go s.Run(ctx, time.Hour)
+	}
+	SetDefault(s)
+	return s, nil
+}
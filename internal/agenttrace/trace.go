@@ -0,0 +1,186 @@
+// Package agenttrace captures what an agent run did for debugging bad
+// generations: the exact prompt, every provider attempt with its model and
+// parameters, the raw response and the post-processing steps that followed,
+// with timings. Secrets are redacted before a trace is stored.
+package agenttrace
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"QLP/internal/llm"
+	"QLP/internal/secrets"
+)
+
+// Trace statuses
+const (
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// Trace is the record of one agent run
+type Trace struct {
+	AgentID    string        `json:"agent_id"`
+	Run        int           `json:"run"`
+	TaskID     string        `json:"task_id"`
+	TaskType   string        `json:"task_type,omitempty"`
+	AgentType  string        `json:"agent_type,omitempty"`
+	TenantID   string        `json:"tenant_id,omitempty"`
+	Prompt     Prompt        `json:"prompt"`
+	Calls      []llm.Call    `json:"calls"`
+	Response   string        `json:"response"`
+	Steps      []Step        `json:"steps"`
+	Status     string        `json:"status"`
+	Error      string        `json:"error,omitempty"`
+	Redactions int           `json:"redactions"` // Secrets masked in the stored trace
+	StartedAt  time.Time     `json:"started_at"`
+	Duration   time.Duration `json:"duration_ns"`
+}
+
+// Prompt is the prompt sent to the model and the registry version it came from
+type Prompt struct {
+	Name    string `json:"name,omitempty"`
+	Version int    `json:"version,omitempty"`
+	Text    string `json:"text"`
+}
+
+// Step is a post-processing step applied to the response
+type Step struct {
+	Name      string                 `json:"name"`
+	StartedAt time.Time              `json:"started_at"`
+	Duration  time.Duration          `json:"duration_ns"`
+	Detail    map[string]interface{} `json:"detail,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+// Recorder collects the trace of a run in progress. A nil Recorder, returned
+// when tracing is disabled, ignores every call.
+type Recorder struct {
+	store *Store
+	mu    sync.Mutex
+	trace Trace
+}
+
+// Context returns ctx with the run's provider attempts recorded
+func (r *Recorder) Context(ctx context.Context) context.Context {
+	if r == nil {
+		return ctx
+	}
+	return llm.WithObserver(ctx, func(call llm.Call) {
+		r.mu.Lock()
+		r.trace.Calls = append(r.trace.Calls, call)
+		r.mu.Unlock()
+	})
+}
+
+// Response records the raw model response
+func (r *Recorder) Response(response string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.trace.Response = response
+	r.mu.Unlock()
+}
+
+// Step records a post-processing step that began at started
+func (r *Recorder) Step(name string, started time.Time, detail map[string]interface{}, err error) {
+	if r == nil {
+		return
+	}
+	step := Step{Name: name, StartedAt: started, Duration: time.Since(started), Detail: detail}
+	if err != nil {
+		step.Error = err.Error()
+	}
+	r.mu.Lock()
+	r.trace.Steps = append(r.trace.Steps, step)
+	r.mu.Unlock()
+}
+
+// Finish redacts and stores the trace; err is the run's failure, if any. A
+// trace that cannot be stored is returned as an error for the caller to log,
+// it never fails the run.
+func (r *Recorder) Finish(err error) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trace.Duration = time.Since(r.trace.StartedAt)
+	r.trace.Status = StatusCompleted
+	if err != nil {
+		r.trace.Status = StatusFailed
+		r.trace.Error = err.Error()
+	}
+	r.trace.Redactions = redactTrace(&r.trace)
+	return r.store.save(&r.trace)
+}
+
+const redactionMask = "[REDACTED]"
+
+// secretPatterns match credentials that were never registered with the
+// secrets scrubber, such as keys pasted into an intent or generated code.
+// Patterns with a group keep the text before it and mask the rest.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`),
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),
+	regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`),
+	regexp.MustCompile(`(?i)(\bbearer\s+)[A-Za-z0-9._~+/-]{16,}=*`),
+	regexp.MustCompile(`(://[^/\s:@]+:)[^@\s/]+@`),
+	regexp.MustCompile(`(?i)((?:api[_-]?key|secret|token|password|passwd|pwd)["']?\s*[:=]\s*["']?)[^\s"',;}]{6,}`),
+}
+
+// Redact masks registered secrets and credential-shaped values in text,
+// returning the redacted text and how many values were masked
+func Redact(text string) (string, int) {
+	before := strings.Count(text, redactionMask)
+	text = secrets.Scrub(text)
+	for _, pattern := range secretPatterns {
+		text = pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if m := pattern.FindStringSubmatch(match); len(m) > 1 {
+				if strings.Contains(match[len(m[1]):], redactionMask) {
+					return match
+				}
+				tail := ""
+				if strings.HasSuffix(match, "@") {
+					tail = "@"
+				}
+				return m[1] + redactionMask + tail
+			}
+			return redactionMask
+		})
+	}
+	return text, strings.Count(text, redactionMask) - before
+}
+
+// redactTrace redacts every captured text of t and returns the count
+func redactTrace(t *Trace) int {
+	total := 0
+	redact := func(s *string) {
+		var n int
+		*s, n = Redact(*s)
+		total += n
+	}
+	redact(&t.Prompt.Text)
+	redact(&t.Response)
+	redact(&t.Error)
+	for i := range t.Calls {
+		redact(&t.Calls[i].Error)
+	}
+	for i := range t.Steps {
+		redact(&t.Steps[i].Error)
+		for key, value := range t.Steps[i].Detail {
+			if s, ok := value.(string); ok {
+				redact(&s)
+				t.Steps[i].Detail[key] = s
+			}
+		}
+	}
+	return total
+}
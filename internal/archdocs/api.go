@@ -0,0 +1,180 @@
+package archdocs
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Route is an HTTP endpoint of the generated project
+type Route struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Summary string `json:"summary,omitempty"`
+	Source  string `json:"source"` // "file:line" or the OpenAPI document
+}
+
+var httpMethods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+// ExtractRoutes reads the endpoints from an OpenAPI document when the project
+// has one, and otherwise from handler registrations in Go, Node and Python
+// sources
+func ExtractRoutes(files map[string]string) []Route {
+	for _, name := range sortedKeys(files) {
+		if isOpenAPIFile(name) {
+			if routes := openAPIRoutes(name, files[name]); len(routes) > 0 {
+				return routes
+			}
+		}
+	}
+
+	var routes []Route
+	seen := make(map[string]bool)
+	for _, name := range sortedKeys(files) {
+		patterns := routePatterns[path.Ext(name)]
+		for i, line := range strings.Split(files[name], "\n") {
+			for _, p := range patterns {
+				m := p.re.FindStringSubmatch(line)
+				if m == nil {
+					continue
+				}
+				route := p.route(m, line)
+				route.Source = fmt.Sprintf("%s:%d", name, i+1)
+				key := route.Method + " " + route.Path
+				if !seen[key] {
+					seen[key] = true
+					routes = append(routes, route)
+				}
+				break
+			}
+		}
+	}
+	sort.SliceStable(routes, func(i, j int) bool { return routes[i].Path < routes[j].Path })
+	return routes
+}
+
+func isOpenAPIFile(name string) bool {
+	base := strings.ToLower(path.Base(name))
+	for _, prefix := range []string{"openapi.", "swagger."} {
+		if strings.HasPrefix(base, prefix) {
+			ext := path.Ext(base)
+			return ext == ".yaml" || ext == ".yml" || ext == ".json"
+		}
+	}
+	return false
+}
+
+func openAPIRoutes(name, content string) []Route {
+	var doc struct {
+		Paths map[string]map[string]struct {
+			Summary     string `yaml:"summary"`
+			OperationID string `yaml:"operationId"`
+		} `yaml:"paths"`
+	}
+	// JSON is valid YAML, so one decoder handles both
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+		return nil
+	}
+
+	var routes []Route
+	for _, p := range sortedKeys(doc.Paths) {
+		for _, method := range httpMethods {
+			op, ok := doc.Paths[p][method]
+			if !ok {
+				continue
+			}
+			summary := op.Summary
+			if summary == "" {
+				summary = op.OperationID
+			}
+			routes = append(routes, Route{Method: strings.ToUpper(method), Path: p, Summary: summary, Source: name})
+		}
+	}
+	return routes
+}
+
+type routePattern struct {
+	re    *regexp.Regexp
+	route func(m []string, line string) Route
+}
+
+var goMethods = regexp.MustCompile(`\.Methods\(\s*"([A-Z]+)"`)
+
+var (
+	// http.HandleFunc("/users", ...) and Go 1.22 patterns like "GET /users/{id}"
+	goHandleFunc = routePattern{
+		re: regexp.MustCompile(`\.Handle(?:Func)?\(\s*"(?:([A-Z]+) )?(/[^"]*)"`),
+		route: func(m []string, line string) Route {
+			method := m[1]
+			if gm := goMethods.FindStringSubmatch(line); gm != nil {
+				method = gm[1]
+			}
+			if method == "" {
+				method = "ANY"
+			}
+			return Route{Method: method, Path: m[2]}
+		},
+	}
+	// gin and echo: r.GET("/users", ...); chi: r.Get("/users", ...)
+	goRouter = routePattern{
+		re: regexp.MustCompile(`\.(GET|POST|PUT|PATCH|DELETE|Get|Post|Put|Patch|Delete)\(\s*"(/[^"]*)"`),
+		route: func(m []string, _ string) Route {
+			return Route{Method: strings.ToUpper(m[1]), Path: m[2]}
+		},
+	}
+	// express: app.get('/users', ...) or router.post("/users", ...)
+	nodeRouter = routePattern{
+		re: regexp.MustCompile("\\b(?:app|router|server)\\.(get|post|put|patch|delete)\\(\\s*['\"`](/[^'\"`]*)"),
+		route: func(m []string, _ string) Route {
+			return Route{Method: strings.ToUpper(m[1]), Path: m[2]}
+		},
+	}
+	// FastAPI: @app.get("/users")
+	pythonDecorator = routePattern{
+		re: regexp.MustCompile(`@\w+\.(get|post|put|patch|delete)\(\s*["'](/[^"']*)`),
+		route: func(m []string, _ string) Route {
+			return Route{Method: strings.ToUpper(m[1]), Path: m[2]}
+		},
+	}
+	// Flask: @app.route("/users", methods=["GET", "POST"])
+	pythonRoute = routePattern{
+		re: regexp.MustCompile(`@\w+\.route\(\s*["'](/[^"']*)["'](?:.*methods\s*=\s*\[([^\]]*)\])?`),
+		route: func(m []string, _ string) Route {
+			method := "GET"
+			if m[2] != "" {
+				var methods []string
+				for _, part := range strings.Split(m[2], ",") {
+					methods = append(methods, strings.ToUpper(strings.Trim(strings.TrimSpace(part), `"'`)))
+				}
+				method = strings.Join(methods, ", ")
+			}
+			return Route{Method: method, Path: m[1]}
+		},
+	}
+)
+
+var routePatterns = map[string][]routePattern{
+	".go": {goHandleFunc, goRouter},
+	".js": {nodeRouter},
+	".ts": {nodeRouter},
+	".py": {pythonDecorator, pythonRoute},
+}
+
+// APIReference renders the routes as Markdown
+func APIReference(project string, routes []Route) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s API Reference\n\n", project)
+	if len(routes) == 0 {
+		b.WriteString("No HTTP endpoints were found in the generated sources.\n")
+		return b.String()
+	}
+	b.WriteString("| Method | Path | Summary | Source |\n|---|---|---|---|\n")
+	for _, r := range routes {
+		fmt.Fprintf(&b, "| %s | `%s` | %s | %s |\n", r.Method, r.Path, strings.ReplaceAll(r.Summary, "|", `\|`), r.Source)
+	}
+	return b.String()
+}
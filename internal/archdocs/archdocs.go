@@ -0,0 +1,343 @@
+// Package archdocs writes the architecture documentation of a generated
+// project: a Mermaid component diagram, an API reference extracted from the
+// handlers or the OpenAPI document, a runbook and ADRs for the key decisions.
+// The diagram and the API reference are derived from the files; the runbook
+// and ADRs are written by the LLM, with templates as the fallback.
+package archdocs
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"QLP/internal/llm"
+	"QLP/internal/llm/jsonutil"
+	"QLP/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// Paths of the generated documents in the project
+const (
+	ArchitecturePath = "docs/architecture.md"
+	APIPath          = "docs/api.md"
+	RunbookPath      = "docs/runbook.md"
+	ADRDir           = "docs/adr"
+)
+
+// ADR is an architecture decision record
+type ADR struct {
+	Title        string `json:"title"`
+	Status       string `json:"status"`
+	Context      string `json:"context"`
+	Decision     string `json:"decision"`
+	Consequences string `json:"consequences"`
+}
+
+// Agent writes architecture docs for generated projects
+type Agent struct {
+	llmClient llm.Client
+}
+
+// NewAgent creates a docs agent. A nil client writes the runbook and ADRs
+// from templates.
+func NewAgent(llmClient llm.Client) *Agent {
+	return &Agent{llmClient: llmClient}
+}
+
+// Generate returns the docs/ files for a project described by intent
+func (a *Agent) Generate(ctx context.Context, project, intent string, files map[string]string) map[string]string {
+	arch := ExtractArchitecture(project, files)
+	routes := ExtractRoutes(files)
+	facts := detect(files)
+
+	runbook, adrs := a.write(ctx, project, intent, files, arch, routes, facts)
+
+	docs := map[string]string{
+		ArchitecturePath: architectureDoc(project, intent, arch, facts),
+		APIPath:          APIReference(project, routes),
+		RunbookPath:      runbook,
+	}
+	for i, adr := range adrs {
+		docs[path.Join(ADRDir, fmt.Sprintf("%04d-%s.md", i+1, slug(adr.Title)))] = adr.Markdown(i + 1)
+	}
+	return docs
+}
+
+// Markdown renders the ADR in the Nygard format
+func (adr ADR) Markdown(number int) string {
+	status := adr.Status
+	if status == "" {
+		status = "Accepted"
+	}
+	return fmt.Sprintf("# %d. %s\n\n## Status\n\n%s\n\n## Context\n\n%s\n\n## Decision\n\n%s\n\n## Consequences\n\n%s\n",
+		number, adr.Title, status, adr.Context, adr.Decision, adr.Consequences)
+}
+
+// facts are the technologies the project uses, read from its files
+type facts struct {
+	Language   string
+	Dockerfile bool
+	Compose    bool
+	Kubernetes bool
+	Terraform  bool
+	Health     string
+}
+
+func detect(files map[string]string) facts {
+	var f facts
+	for _, name := range sortedKeys(files) {
+		base := path.Base(name)
+		switch {
+		case base == "go.mod":
+			f.Language = "Go"
+		case base == "package.json" && f.Language == "":
+			f.Language = "Node.js"
+		case (base == "requirements.txt" || base == "pyproject.toml") && f.Language == "":
+			f.Language = "Python"
+		case (base == "pom.xml" || base == "build.gradle") && f.Language == "":
+			f.Language = "Java"
+		case strings.HasSuffix(base, ".csproj") && f.Language == "":
+			f.Language = "C#"
+		}
+		switch {
+		case base == "Dockerfile" || strings.HasPrefix(base, "Dockerfile."):
+			f.Dockerfile = true
+		case isComposeFile(name):
+			f.Compose = true
+		case path.Ext(name) == ".tf":
+			f.Terraform = true
+		case (path.Ext(name) == ".yaml" || path.Ext(name) == ".yml") && strings.Contains(files[name], "apiVersion:"):
+			f.Kubernetes = true
+		}
+	}
+	for _, r := range ExtractRoutes(files) {
+		if strings.Contains(r.Path, "health") || strings.Contains(r.Path, "ready") {
+			f.Health = r.Path
+			break
+		}
+	}
+	return f
+}
+
+func architectureDoc(project, intent string, arch Architecture, f facts) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s Architecture\n\n", project)
+	if intent != "" {
+		fmt.Fprintf(&b, "%s\n\n", intent)
+	}
+	b.WriteString("## Components\n\n```mermaid\n")
+	b.WriteString(arch.Mermaid())
+	b.WriteString("```\n\n| Component | Kind |\n|---|---|\n")
+	for _, c := range arch.Components {
+		fmt.Fprintf(&b, "| %s | %s |\n", c.Label, c.Kind)
+	}
+
+	var stack []string
+	if f.Language != "" {
+		stack = append(stack, f.Language)
+	}
+	for _, t := range []struct {
+		on   bool
+		name string
+	}{{f.Dockerfile, "Docker"}, {f.Compose, "Docker Compose"}, {f.Kubernetes, "Kubernetes"}, {f.Terraform, "Terraform"}} {
+		if t.on {
+			stack = append(stack, t.name)
+		}
+	}
+	if len(stack) > 0 {
+		fmt.Fprintf(&b, "\n## Technology\n\n%s\n", strings.Join(stack, ", "))
+	}
+	fmt.Fprintf(&b, "\nSee [API reference](api.md), [runbook](runbook.md) and the [decision records](adr/).\n")
+	return b.String()
+}
+
+var docsSchema = map[string]interface{}{
+	"type":     "object",
+	"required": []string{"runbook", "adrs"},
+	"properties": map[string]interface{}{
+		"runbook": map[string]interface{}{"type": "string"},
+		"adrs": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type":     "object",
+				"required": []string{"title", "context", "decision", "consequences"},
+				"properties": map[string]interface{}{
+					"title":        map[string]interface{}{"type": "string"},
+					"status":       map[string]interface{}{"type": "string"},
+					"context":      map[string]interface{}{"type": "string"},
+					"decision":     map[string]interface{}{"type": "string"},
+					"consequences": map[string]interface{}{"type": "string"},
+				},
+			},
+		},
+	},
+}
+
+// write asks the LLM for the runbook and ADRs and falls back to templates
+// when there is no client or the response is unusable
+func (a *Agent) write(ctx context.Context, project, intent string, files map[string]string, arch Architecture, routes []Route, f facts) (string, []ADR) {
+	if a.llmClient != nil {
+		var out struct {
+			Runbook string `json:"runbook"`
+			ADRs    []ADR  `json:"adrs"`
+		}
+		prompt := docsPrompt(project, intent, files, arch, routes)
+		err := jsonutil.CompleteAndDecode(ctx, a.llmClient, prompt, docsSchema, &out, 2)
+		if err == nil && strings.TrimSpace(out.Runbook) != "" && len(out.ADRs) > 0 {
+			return out.Runbook, out.ADRs
+		}
+		logger.WithComponent("archdocs").Warn("Falling back to template runbook and ADRs",
+			zap.String("project", project),
+			zap.Error(err))
+	}
+	return templateRunbook(project, f, routes), templateADRs(arch, f)
+}
+
+func docsPrompt(project, intent string, files map[string]string, arch Architecture, routes []Route) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "You are writing operations documentation for the generated project %q.\n\n", project)
+	fmt.Fprintf(&b, "Original request: %s\n\nFiles:\n", intent)
+	for _, name := range sortedKeys(files) {
+		fmt.Fprintf(&b, "- %s\n", name)
+	}
+	b.WriteString("\nComponent diagram:\n")
+	b.WriteString(arch.Mermaid())
+	if len(routes) > 0 {
+		b.WriteString("\nEndpoints:\n")
+		for _, r := range routes {
+			fmt.Fprintf(&b, "- %s %s\n", r.Method, r.Path)
+		}
+	}
+	for _, name := range sortedKeys(files) {
+		base := path.Base(name)
+		if base == "Dockerfile" || isComposeFile(name) || path.Ext(name) == ".tf" {
+			fmt.Fprintf(&b, "\n%s:\n%s\n", name, truncate(files[name], 3000))
+		}
+	}
+	b.WriteString(`
+Return JSON with:
+- "runbook": a Markdown runbook with sections for deploying, scaling, configuration (environment variables) and debugging (logs, health checks, common failures), using the commands that fit these files
+- "adrs": 3 to 5 architecture decision records for the key decisions visible in the files (language and framework, data storage, deployment platform, API style), each with "title", "status", "context", "decision" and "consequences"`)
+	return b.String()
+}
+
+func templateRunbook(project string, f facts, routes []Route) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s Runbook\n\n## Deploy\n\n", project)
+	switch {
+	case f.Kubernetes:
+		b.WriteString("```sh\nkubectl apply -f <manifests>\nkubectl rollout status deployment/<name>\n```\n")
+	case f.Compose:
+		b.WriteString("```sh\ndocker compose up -d --build\n```\n")
+	case f.Dockerfile:
+		fmt.Fprintf(&b, "```sh\ndocker build -t %s .\ndocker run -p 8080:8080 %s\n```\n", slug(project), slug(project))
+	default:
+		b.WriteString("Build and start the application with the toolchain of the project.\n")
+	}
+	if f.Terraform {
+		b.WriteString("\nProvision the infrastructure first:\n\n```sh\nterraform init\nterraform plan -out plan.tfplan\nterraform apply plan.tfplan\n```\n")
+	}
+
+	b.WriteString("\n## Scale\n\n")
+	switch {
+	case f.Kubernetes:
+		b.WriteString("```sh\nkubectl scale deployment/<name> --replicas=3\n```\n")
+	case f.Compose:
+		b.WriteString("```sh\ndocker compose up -d --scale <service>=3\n```\n")
+	default:
+		b.WriteString("Run more instances behind a load balancer; the services keep no local state.\n")
+	}
+
+	b.WriteString("\n## Debug\n\n")
+	switch {
+	case f.Kubernetes:
+		b.WriteString("```sh\nkubectl get pods\nkubectl logs deployment/<name>\nkubectl describe pod <pod>\n```\n")
+	case f.Compose:
+		b.WriteString("```sh\ndocker compose ps\ndocker compose logs -f <service>\n```\n")
+	case f.Dockerfile:
+		b.WriteString("```sh\ndocker logs <container>\n```\n")
+	}
+	if f.Health != "" {
+		fmt.Fprintf(&b, "\nCheck health with `curl http://localhost:8080%s`.\n", f.Health)
+	}
+	if len(routes) > 0 {
+		b.WriteString("\nThe endpoints are listed in the [API reference](api.md).\n")
+	}
+	return b.String()
+}
+
+func templateADRs(arch Architecture, f facts) []ADR {
+	var adrs []ADR
+	if f.Language != "" {
+		adrs = append(adrs, ADR{
+			Title:        "Use " + f.Language,
+			Context:      "The services need a language with a mature ecosystem for the required features.",
+			Decision:     fmt.Sprintf("The services are written in %s.", f.Language),
+			Consequences: fmt.Sprintf("Contributors need %s tooling; dependencies are managed with its package manager.", f.Language),
+		})
+	}
+	switch {
+	case f.Kubernetes:
+		adrs = append(adrs, ADR{
+			Title:        "Deploy to Kubernetes",
+			Context:      "The services must be deployed, scaled and restarted without manual steps.",
+			Decision:     "The services run as containers on Kubernetes with the manifests in this repository.",
+			Consequences: "Operators need a cluster and kubectl access; scaling and rollouts use Kubernetes primitives.",
+		})
+	case f.Dockerfile || f.Compose:
+		adrs = append(adrs, ADR{
+			Title:        "Package as containers",
+			Context:      "The services must run the same way on developer machines and servers.",
+			Decision:     "The services are built into container images.",
+			Consequences: "Deployments need a container runtime and an image registry.",
+		})
+	}
+	var stores []string
+	for _, c := range arch.Components {
+		if c.Kind != KindService {
+			stores = append(stores, c.Label)
+		}
+	}
+	if len(stores) > 0 {
+		sort.Strings(stores)
+		adrs = append(adrs, ADR{
+			Title:        "Use " + strings.Join(stores, " and "),
+			Context:      "The services need durable state and asynchronous communication where applicable.",
+			Decision:     fmt.Sprintf("State and messaging use %s.", strings.Join(stores, ", ")),
+			Consequences: "These services must be provisioned, backed up and monitored alongside the application.",
+		})
+	}
+	if len(adrs) == 0 {
+		adrs = append(adrs, ADR{
+			Title:        "Record architecture decisions",
+			Context:      "Decisions about this project should be traceable.",
+			Decision:     "Significant decisions are recorded as ADRs in docs/adr.",
+			Consequences: "Each new decision adds a numbered record.",
+		})
+	}
+	return adrs
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+func slug(s string) string {
+	s = strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if len(s) > 50 {
+		s = strings.TrimRight(s[:50], "-")
+	}
+	if s == "" {
+		return "decision"
+	}
+	return s
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "\n..."
+}
@@ -0,0 +1,183 @@
+package archdocs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExtractRoutesFromOpenAPI(t *testing.T) {
+	files := map[string]string{
+		"api/openapi.yaml": `openapi: 3.0.0
+paths:
+  /users:
+    get:
+      summary: List users
+    post:
+      operationId: createUser
+  /health:
+    get: {}
+`,
+		"main.go": `http.HandleFunc("/ignored", h)`,
+	}
+	routes := ExtractRoutes(files)
+	if len(routes) != 3 {
+		t.Fatalf("expected 3 routes from the OpenAPI document, got %+v", routes)
+	}
+	if routes[0].Path != "/health" || routes[1].Method != "GET" || routes[1].Summary != "List users" || routes[2].Summary != "createUser" {
+		t.Errorf("unexpected routes: %+v", routes)
+	}
+}
+
+func TestExtractRoutesFromHandlers(t *testing.T) {
+	files := map[string]string{
+		"cmd/server/main.go": `mux.HandleFunc("GET /users/{id}", getUser)
+r.HandleFunc("/orders", createOrder).Methods("POST")
+router.GET("/health", health)`,
+		"src/app.js":  `app.delete('/items/:id', remove)`,
+		"app/main.py": "@app.route(\"/login\", methods=[\"GET\", \"POST\"])\n@api.get(\"/items\")",
+	}
+	got := make(map[string]string)
+	for _, r := range ExtractRoutes(files) {
+		got[r.Path] = r.Method
+	}
+	want := map[string]string{
+		"/users/{id}": "GET",
+		"/orders":     "POST",
+		"/health":     "GET",
+		"/items/:id":  "DELETE",
+		"/login":      "GET, POST",
+		"/items":      "GET",
+	}
+	for path, method := range want {
+		if got[path] != method {
+			t.Errorf("%s: expected %q, got %q", path, method, got[path])
+		}
+	}
+}
+
+func TestExtractArchitectureFromCompose(t *testing.T) {
+	files := map[string]string{
+		"docker-compose.yml": `services:
+  api:
+    build: .
+    depends_on:
+      db:
+        condition: service_healthy
+  db:
+    image: postgres:16
+`,
+		"main.go": `import "github.com/redis/go-redis/v9"
+// connects to postgres`,
+	}
+	arch := ExtractArchitecture("user-api", files)
+
+	kinds := make(map[string]string)
+	for _, c := range arch.Components {
+		kinds[c.Label] = c.Kind
+	}
+	if kinds["api"] != KindService || kinds["db"] != KindDatastore || kinds["Redis"] != KindDatastore {
+		t.Errorf("unexpected components: %+v", arch.Components)
+	}
+	if _, ok := kinds["PostgreSQL"]; ok {
+		t.Error("PostgreSQL is deployed as db and should not be added again")
+	}
+
+	diagram := arch.Mermaid()
+	for _, line := range []string{"client --> api", "api --> db", "api --> Redis", `db[("db")]`} {
+		if !strings.Contains(diagram, line) {
+			t.Errorf("diagram missing %q:\n%s", line, diagram)
+		}
+	}
+}
+
+func TestExtractArchitectureFallsBackToProject(t *testing.T) {
+	arch := ExtractArchitecture("rest-api", map[string]string{"main.go": "package main"})
+	if len(arch.Components) != 1 || arch.Components[0].ID != "rest_api" {
+		t.Errorf("expected a single project component, got %+v", arch.Components)
+	}
+}
+
+func TestExtractArchitectureFromKubernetesEnv(t *testing.T) {
+	arch := ExtractArchitecture("shop", map[string]string{"k8s/app.yaml": `kind: Deployment
+metadata:
+  name: api
+spec:
+  template:
+    spec:
+      containers:
+      - name: api
+        env:
+        - name: DATABASE_URL
+          value: postgres://app@postgres-db:5432/shop
+        args: ["--cache=cache.shop.svc:6379"]
+---
+kind: StatefulSet
+metadata:
+  name: postgres-db
+---
+kind: Deployment
+metadata:
+  name: cache
+---
+kind: Deployment
+metadata:
+  name: postgres
+`})
+	if len(arch.Components) != 4 || arch.Components[1].Kind != KindDatastore {
+		t.Fatalf("unexpected components: %+v", arch.Components)
+	}
+	if len(arch.Edges) != 2 || arch.Edges[0] != (Edge{From: "api", To: "postgres_db"}) || arch.Edges[1] != (Edge{From: "api", To: "cache"}) {
+		t.Errorf("expected api to depend on the hosts its env and args name, got %+v", arch.Edges)
+	}
+}
+
+type fakeClient struct {
+	response string
+	err      error
+}
+
+func (c *fakeClient) Complete(ctx context.Context, prompt string) (string, error) {
+	return c.response, c.err
+}
+
+func (c *fakeClient) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	return nil, nil
+}
+
+var project = map[string]string{
+	"go.mod":     "module example.com/users",
+	"Dockerfile": "FROM golang:1.22",
+	"main.go":    `http.HandleFunc("/healthz", health)`,
+}
+
+func TestGenerateWithLLM(t *testing.T) {
+	client := &fakeClient{response: `{"runbook": "# Runbook\n\nRun it.", "adrs": [
+		{"title": "Use Go for the API", "context": "c", "decision": "d", "consequences": "q"},
+		{"title": "Store users in PostgreSQL", "status": "Proposed", "context": "c", "decision": "d", "consequences": "q"}]}`}
+
+	docs := NewAgent(client).Generate(context.Background(), "user-api", "Build a user API", project)
+
+	if docs[RunbookPath] != "# Runbook\n\nRun it." {
+		t.Errorf("unexpected runbook: %q", docs[RunbookPath])
+	}
+	adr, ok := docs["docs/adr/0002-store-users-in-postgresql.md"]
+	if !ok || !strings.Contains(adr, "# 2. Store users in PostgreSQL") || !strings.Contains(adr, "Proposed") {
+		t.Errorf("unexpected ADRs: %v", docs)
+	}
+	if !strings.Contains(docs[ArchitecturePath], "```mermaid") || !strings.Contains(docs[APIPath], "`/healthz`") {
+		t.Errorf("unexpected architecture or API docs:\n%s\n%s", docs[ArchitecturePath], docs[APIPath])
+	}
+}
+
+func TestGenerateFallsBackToTemplates(t *testing.T) {
+	docs := NewAgent(&fakeClient{err: errors.New("unavailable")}).Generate(context.Background(), "user-api", "", project)
+
+	if !strings.Contains(docs[RunbookPath], "docker build -t user-api .") || !strings.Contains(docs[RunbookPath], "/healthz") {
+		t.Errorf("unexpected template runbook:\n%s", docs[RunbookPath])
+	}
+	if _, ok := docs["docs/adr/0001-use-go.md"]; !ok {
+		t.Errorf("expected a language ADR, got %v", docs)
+	}
+}
@@ -0,0 +1,329 @@
+package archdocs
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Component kinds shown in the diagram
+const (
+	KindService   = "service"
+	KindDatastore = "datastore"
+	KindQueue     = "queue"
+)
+
+// Component is a deployable part of the generated project
+type Component struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	Kind  string `json:"kind"`
+}
+
+// Edge is a dependency between components
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Architecture is the component graph of the generated project
+type Architecture struct {
+	Components []Component `json:"components"`
+	Edges      []Edge      `json:"edges"`
+}
+
+// backingServices are detected by name in images, service names and sources
+var backingServices = []struct {
+	match string
+	label string
+	kind  string
+}{
+	{"postgres", "PostgreSQL", KindDatastore},
+	{"mysql", "MySQL", KindDatastore},
+	{"mongo", "MongoDB", KindDatastore},
+	{"redis", "Redis", KindDatastore},
+	{"elasticsearch", "Elasticsearch", KindDatastore},
+	{"kafka", "Kafka", KindQueue},
+	{"rabbitmq", "RabbitMQ", KindQueue},
+	{"nats", "NATS", KindQueue},
+}
+
+func backingKind(name string) (string, string) {
+	name = strings.ToLower(name)
+	for _, b := range backingServices {
+		if strings.Contains(name, b.match) {
+			return b.label, b.kind
+		}
+	}
+	return "", KindService
+}
+
+// ExtractArchitecture builds the component graph from docker-compose services
+// and their depends_on, falling back to Kubernetes workloads, which depend on
+// the workloads their containers' env, args and commands name as hosts, and
+// then to a single application component. Backing services referenced from the sources
+// but not deployed by the project are added as dependencies of the services.
+func ExtractArchitecture(project string, files map[string]string) Architecture {
+	var arch Architecture
+	ids := make(map[string]bool)
+	add := func(name, kind string) string {
+		id := nodeID(name)
+		if !ids[id] {
+			ids[id] = true
+			arch.Components = append(arch.Components, Component{ID: id, Label: name, Kind: kind})
+		}
+		return id
+	}
+
+	deployed := make(map[string]bool)
+	for _, name := range sortedKeys(files) {
+		if !isComposeFile(name) {
+			continue
+		}
+		var compose struct {
+			Services map[string]struct {
+				Image     string    `yaml:"image"`
+				DependsOn yaml.Node `yaml:"depends_on"`
+			} `yaml:"services"`
+		}
+		if err := yaml.Unmarshal([]byte(files[name]), &compose); err != nil {
+			continue
+		}
+		services := sortedKeys(compose.Services)
+		for _, svc := range services {
+			label, kind := backingKind(svc + " " + compose.Services[svc].Image)
+			if label != "" {
+				deployed[label] = true
+			}
+			add(svc, kind)
+		}
+		for _, svc := range services {
+			for _, dep := range dependsOn(compose.Services[svc].DependsOn) {
+				_, kind := backingKind(dep)
+				arch.Edges = append(arch.Edges, Edge{From: nodeID(svc), To: add(dep, kind)})
+			}
+		}
+	}
+
+	if len(arch.Components) == 0 {
+		workloads := kubernetesWorkloads(files)
+		for _, w := range workloads {
+			label, kind := backingKind(w.name)
+			if label != "" {
+				deployed[label] = true
+			}
+			add(w.name, kind)
+		}
+		for _, w := range workloads {
+			for _, dep := range workloads {
+				if dep.name != w.name && referencesHost(w.refs, dep.name) {
+					arch.Edges = append(arch.Edges, Edge{From: nodeID(w.name), To: nodeID(dep.name)})
+				}
+			}
+		}
+	}
+	if len(arch.Components) == 0 {
+		add(project, KindService)
+	}
+
+	// Backing services the code talks to that the project does not deploy
+	sources := strings.ToLower(sourceText(files))
+	var services []string
+	for _, c := range arch.Components {
+		if c.Kind == KindService {
+			services = append(services, c.ID)
+		}
+	}
+	for _, b := range backingServices {
+		if !strings.Contains(sources, b.match) || deployed[b.label] {
+			continue
+		}
+		to := add(b.label, b.kind)
+		for _, from := range services {
+			arch.Edges = append(arch.Edges, Edge{From: from, To: to})
+		}
+	}
+	return arch
+}
+
+func isComposeFile(name string) bool {
+	base := strings.ToLower(path.Base(name))
+	return strings.HasPrefix(base, "docker-compose") || strings.HasPrefix(base, "compose.")
+}
+
+// dependsOn reads both the list and the map form of depends_on
+func dependsOn(node yaml.Node) []string {
+	var deps []string
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for _, n := range node.Content {
+			deps = append(deps, n.Value)
+		}
+	case yaml.MappingNode:
+		for i := 0; i < len(node.Content); i += 2 {
+			deps = append(deps, node.Content[i].Value)
+		}
+	}
+	sort.Strings(deps)
+	return deps
+}
+
+var workloadKinds = map[string]bool{"Deployment": true, "StatefulSet": true, "DaemonSet": true, "CronJob": true}
+
+// kubeWorkload is a Kubernetes workload with the environment values,
+// args and commands of its containers, where the hosts it calls appear
+type kubeWorkload struct {
+	name string
+	refs string
+}
+
+func kubernetesWorkloads(files map[string]string) []kubeWorkload {
+	var workloads []kubeWorkload
+	for _, name := range sortedKeys(files) {
+		if ext := path.Ext(name); ext != ".yaml" && ext != ".yml" {
+			continue
+		}
+		dec := yaml.NewDecoder(strings.NewReader(files[name]))
+		for {
+			var obj struct {
+				Kind     string `yaml:"kind"`
+				Metadata struct {
+					Name string `yaml:"name"`
+				} `yaml:"metadata"`
+				Spec yaml.Node `yaml:"spec"`
+			}
+			if err := dec.Decode(&obj); err != nil {
+				break
+			}
+			if workloadKinds[obj.Kind] && obj.Metadata.Name != "" {
+				var refs []string
+				containerRefs(&obj.Spec, &refs)
+				workloads = append(workloads, kubeWorkload{name: obj.Metadata.Name, refs: strings.Join(refs, "\n")})
+			}
+		}
+	}
+	return workloads
+}
+
+// containerRefs collects the env values, args and commands under node
+func containerRefs(node *yaml.Node, refs *[]string) {
+	if node.Kind != yaml.MappingNode {
+		for _, n := range node.Content {
+			containerRefs(n, refs)
+		}
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		switch key {
+		case "env":
+			for _, v := range value.Content {
+				var env struct {
+					Value string `yaml:"value"`
+				}
+				if v.Decode(&env) == nil && env.Value != "" {
+					*refs = append(*refs, env.Value)
+				}
+			}
+		case "args", "command":
+			for _, v := range value.Content {
+				*refs = append(*refs, v.Value)
+			}
+		default:
+			containerRefs(value, refs)
+		}
+	}
+}
+
+// referencesHost reports whether text names host as a whole DNS label,
+// e.g. the second postgres in postgres://postgres:5432/app, not the URL
+// scheme, or postgres.data.svc
+func referencesHost(text, host string) bool {
+	text, host = strings.ToLower(text), strings.ToLower(host)
+	for i := strings.Index(text, host); i >= 0; {
+		end := i + len(host)
+		if (i == 0 || !isLabelChar(text[i-1])) && (end == len(text) || !isLabelChar(text[end])) &&
+			!strings.HasPrefix(text[end:], "://") {
+			return true
+		}
+		next := strings.Index(text[i+1:], host)
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return false
+}
+
+func isLabelChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-'
+}
+
+var sourceExts = map[string]bool{".go": true, ".js": true, ".ts": true, ".py": true, ".java": true, ".cs": true, ".mod": true, ".json": true, ".txt": true, ".toml": true}
+
+func sourceText(files map[string]string) string {
+	var b strings.Builder
+	for _, name := range sortedKeys(files) {
+		if sourceExts[path.Ext(name)] {
+			b.WriteString(files[name])
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+var nonIdent = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+func nodeID(name string) string {
+	id := nonIdent.ReplaceAllString(name, "_")
+	if id == "" || (id[0] >= '0' && id[0] <= '9') {
+		id = "c_" + id
+	}
+	return id
+}
+
+// Mermaid renders the graph as a Mermaid flowchart with clients calling the
+// services nothing else depends on
+func (a Architecture) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	b.WriteString("    client([Client])\n")
+	for _, c := range a.Components {
+		label := strings.ReplaceAll(c.Label, `"`, "'")
+		switch c.Kind {
+		case KindDatastore:
+			fmt.Fprintf(&b, "    %s[(\"%s\")]\n", c.ID, label)
+		case KindQueue:
+			fmt.Fprintf(&b, "    %s>\"%s\"]\n", c.ID, label)
+		default:
+			fmt.Fprintf(&b, "    %s[\"%s\"]\n", c.ID, label)
+		}
+	}
+
+	called := make(map[string]bool)
+	for _, e := range a.Edges {
+		called[e.To] = true
+	}
+	for _, c := range a.Components {
+		if c.Kind == KindService && !called[c.ID] {
+			fmt.Fprintf(&b, "    client --> %s\n", c.ID)
+		}
+	}
+	for _, e := range a.Edges {
+		fmt.Fprintf(&b, "    %s --> %s\n", e.From, e.To)
+	}
+	return b.String()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
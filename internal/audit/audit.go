@@ -0,0 +1,332 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"QLP/internal/logger"
+	"QLP/internal/tracing"
+
+	"go.uber.org/zap"
+)
+
+// Action identifies what was done in an audited operation
+type Action string
+
+const (
+	ActionResourceGroupCreate  Action = "azure.resource_group.create"
+	ActionResourceGroupDelete  Action = "azure.resource_group.delete"
+	ActionStorageAccountCreate Action = "azure.storage_account.create"
+	ActionDriftRemediate       Action = "azure.drift.remediate"
+	ActionAgentExecute         Action = "agent.execute"
+	ActionCapsulePromote       Action = "capsule.promote"
+	ActionDeploymentRollback   Action = "deployment.rollback"
+	ActionValidationRuleCreate Action = "validation.rule.create"
+	ActionValidationRuleUpdate Action = "validation.rule.update"
+	ActionValidationRuleDelete Action = "validation.rule.delete"
+	ActionArtifactKeyRotate    Action = "artifact.key.rotate"
+	ActionArtifactKeyRewrap    Action = "artifact.key.rewrap"
+	ActionTenantDelete         Action = "tenant.delete"
+	ActionTenantCreate         Action = "tenant.create"
+	ActionTenantUpdate         Action = "tenant.update"
+	ActionTenantKeyCreate      Action = "tenant.key.create"
+	ActionTenantKeyRotate      Action = "tenant.key.rotate"
+	ActionTenantKeyRevoke      Action = "tenant.key.revoke"
+	ActionTenantSuspend        Action = "tenant.suspend"
+	ActionTenantResume         Action = "tenant.resume"
+)
+
+// Outcome records whether an audited operation succeeded
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+)
+
+// Entry is a single audit record. Audit entries are written to their own
+// sinks and are never mixed with application logs.
+type Entry struct {
+	ID           string                 `json:"id"`
+	Timestamp    time.Time              `json:"timestamp"`
+	Action       Action                 `json:"action"`
+	Outcome      Outcome                `json:"outcome"`
+	IntentID     string                 `json:"intent_id,omitempty"`
+	TenantID     string                 `json:"tenant_id,omitempty"`
+	Actor        string                 `json:"actor"`
+	ResourceType string                 `json:"resource_type,omitempty"`
+	ResourceIDs  []string               `json:"resource_ids,omitempty"`
+	TraceID      string                 `json:"trace_id,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+	Details      map[string]interface{} `json:"details,omitempty"`
+}
+
+// Sink receives audit entries
+type Sink interface {
+	Write(ctx context.Context, entry Entry) error
+	Close() error
+}
+
+// Querier is implemented by sinks that can answer audit queries
+type Querier interface {
+	Query(ctx context.Context, filter Filter) ([]Entry, error)
+}
+
+// Filter selects audit entries. Zero values match everything.
+type Filter struct {
+	IntentID   string
+	TenantID   string
+	Actor      string
+	Action     Action
+	ResourceID string
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+}
+
+// Matches reports whether the entry satisfies the filter
+func (f Filter) Matches(e Entry) bool {
+	if f.IntentID != "" && e.IntentID != f.IntentID {
+		return false
+	}
+	if f.TenantID != "" && e.TenantID != f.TenantID {
+		return false
+	}
+	if f.Actor != "" && e.Actor != f.Actor {
+		return false
+	}
+	if f.Action != "" && e.Action != f.Action {
+		return false
+	}
+	if f.ResourceID != "" {
+		found := false
+		for _, id := range e.ResourceIDs {
+			if id == f.ResourceID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && e.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Timestamp.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Logger fans audit entries out to its sinks and keeps a bounded in-memory
+// buffer used for queries when no sink supports them.
+type Logger struct {
+	sinks     []Sink
+	mu        sync.RWMutex
+	recent    []Entry
+	maxRecent int
+	seq       uint64
+}
+
+// NewLogger creates an audit logger writing to the given sinks
+func NewLogger(sinks ...Sink) *Logger {
+	return &Logger{
+		sinks:     sinks,
+		recent:    make([]Entry, 0),
+		maxRecent: 10000,
+	}
+}
+
+// Record fills in correlation fields from ctx and writes the entry to every sink
+func (l *Logger) Record(ctx context.Context, entry Entry) {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+	if entry.IntentID == "" {
+		entry.IntentID = IntentFromContext(ctx)
+	}
+	if entry.TenantID == "" {
+		entry.TenantID = TenantFromContext(ctx)
+	}
+	if entry.Actor == "" {
+		entry.Actor = ActorFromContext(ctx)
+	}
+	if entry.TraceID == "" {
+		entry.TraceID = tracing.TraceID(ctx)
+	}
+	if entry.Outcome == "" {
+		entry.Outcome = OutcomeSuccess
+	}
+
+	l.mu.Lock()
+	l.seq++
+	if entry.ID == "" {
+		entry.ID = fmt.Sprintf("audit_%d_%d", entry.Timestamp.UnixNano(), l.seq)
+	}
+	l.recent = append(l.recent, entry)
+	if len(l.recent) > l.maxRecent {
+		l.recent = l.recent[len(l.recent)-l.maxRecent:]
+	}
+	l.mu.Unlock()
+
+	for _, sink := range l.sinks {
+		if err := sink.Write(ctx, entry); err != nil {
+			logger.WithComponent("audit").Error("Failed to write audit entry",
+				zap.String("audit_id", entry.ID),
+				zap.String("action", string(entry.Action)),
+				zap.Error(err))
+		}
+	}
+}
+
+// Query returns matching entries, newest last. The first sink that supports
+// queries is used; otherwise the in-memory buffer is searched.
+func (l *Logger) Query(ctx context.Context, filter Filter) ([]Entry, error) {
+	for _, sink := range l.sinks {
+		if q, ok := sink.(Querier); ok {
+			return q.Query(ctx, filter)
+		}
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	results := make([]Entry, 0)
+	for _, e := range l.recent {
+		if filter.Matches(e) {
+			results = append(results, e)
+		}
+	}
+	if filter.Limit > 0 && len(results) > filter.Limit {
+		results = results[len(results)-filter.Limit:]
+	}
+	return results, nil
+}
+
+// Close closes all sinks
+func (l *Logger) Close() error {
+	var firstErr error
+	for _, sink := range l.sinks {
+		if err := sink.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+var (
+	defaultLogger   = NewLogger()
+	defaultLoggerMu sync.RWMutex
+)
+
+// Default returns the process-wide audit logger
+func Default() *Logger {
+	defaultLoggerMu.RLock()
+	defer defaultLoggerMu.RUnlock()
+	return defaultLogger
+}
+
+// SetDefault replaces the process-wide audit logger
+func SetDefault(l *Logger) {
+	defaultLoggerMu.Lock()
+	defer defaultLoggerMu.Unlock()
+	defaultLogger = l
+}
+
+// Record writes an entry to the process-wide audit logger
+func Record(ctx context.Context, entry Entry) {
+	Default().Record(ctx, entry)
+}
+
+// InitFromEnv configures the process-wide audit logger from environment variables:
+// QLP_AUDIT_SINKS (comma-separated: file, postgres, syslog), QLP_AUDIT_FILE and
+// DATABASE_URL for the postgres sink.
+func InitFromEnv() (*Logger, error) {
+	sinkNames := os.Getenv("QLP_AUDIT_SINKS")
+	if sinkNames == "" {
+		sinkNames = "file"
+	}
+
+	sinks := make([]Sink, 0)
+	for _, name := range strings.Split(sinkNames, ",") {
+		switch strings.TrimSpace(strings.ToLower(name)) {
+		case "file":
+			path := os.Getenv("QLP_AUDIT_FILE")
+			if path == "" {
+				path = "./data/audit.jsonl"
+			}
+			sink, err := NewFileSink(path)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		case "postgres":
+			sink, err := NewPostgresSinkFromEnv()
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		case "syslog":
+			sink, err := NewSyslogSink("quantumlayer-audit")
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown audit sink: %s", name)
+		}
+	}
+
+	l := NewLogger(sinks...)
+	SetDefault(l)
+	return l, nil
+}
+
+type contextKey int
+
+const (
+	intentKey contextKey = iota
+	tenantKey
+	actorKey
+)
+
+// WithIntent attaches an intent ID used to correlate audit entries
+func WithIntent(ctx context.Context, intentID string) context.Context {
+	return context.WithValue(ctx, intentKey, intentID)
+}
+
+// WithTenant attaches a tenant ID to audit entries recorded with ctx
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey, tenantID)
+}
+
+// WithActor attaches the acting user or service to audit entries recorded with ctx
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// IntentFromContext returns the intent ID attached to ctx, if any
+func IntentFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(intentKey).(string)
+	return v
+}
+
+// TenantFromContext returns the tenant ID attached to ctx, if any
+func TenantFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(tenantKey).(string)
+	return v
+}
+
+// ActorFromContext returns the actor attached to ctx, defaulting to "system"
+func ActorFromContext(ctx context.Context) string {
+	if v, ok := ctx.Value(actorKey).(string); ok && v != "" {
+		return v
+	}
+	return "system"
+}
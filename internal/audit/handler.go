@@ -0,0 +1,100 @@
+package audit
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RequestTenant returns the tenant a request names in its tenant query
+// parameter, defaulting to the tenant of its API key
+func RequestTenant(r *http.Request) string {
+	if tenant := r.URL.Query().Get("tenant"); tenant != "" {
+		return tenant
+	}
+	return TenantFromContext(r.Context())
+}
+
+// BodyTenant returns the tenant of the request's API key, or the tenant its
+// body names when it carries no key
+func BodyTenant(r *http.Request, named string) string {
+	if tenant := TenantFromContext(r.Context()); tenant != "" {
+		return tenant
+	}
+	return named
+}
+
+// RequestActor returns the API key that authenticated the request, or the
+// actor its body names when it carries no key
+func RequestActor(r *http.Request, named string) string {
+	if actor, _ := r.Context().Value(actorKey).(string); actor != "" {
+		return actor
+	}
+	return named
+}
+
+// TenantAllowed reports whether the request may see a resource of tenantID:
+// requests naming no tenant, by key or query parameter, see every tenant's
+func TenantAllowed(r *http.Request, tenantID string) bool {
+	requested := RequestTenant(r)
+	return requested == "" || requested == tenantID
+}
+
+// Handler serves audit queries as JSON. Supported query parameters:
+// intent_id, tenant_id, actor, action, resource_id, since, until (RFC3339) and limit.
+// Requests authenticated by an API key see their tenant's entries by default.
+func Handler(l *Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		q := r.URL.Query()
+		filter := Filter{
+			IntentID:   q.Get("intent_id"),
+			TenantID:   q.Get("tenant_id"),
+			Actor:      q.Get("actor"),
+			Action:     Action(q.Get("action")),
+			ResourceID: q.Get("resource_id"),
+			Limit:      100,
+		}
+
+		if filter.TenantID == "" {
+			filter.TenantID = TenantFromContext(r.Context())
+		}
+
+		var err error
+		if v := q.Get("since"); v != "" {
+			if filter.Since, err = time.Parse(time.RFC3339, v); err != nil {
+				http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if v := q.Get("until"); v != "" {
+			if filter.Until, err = time.Parse(time.RFC3339, v); err != nil {
+				http.Error(w, "invalid until: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if v := q.Get("limit"); v != "" {
+			if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+
+		entries, err := l.Query(r.Context(), filter)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"entries": entries,
+			"count":   len(entries),
+		})
+	})
+}
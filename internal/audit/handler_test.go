@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	start := time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC)
+	l := NewLogger()
+	for _, e := range auditEntries(start) {
+		l.Record(context.Background(), e)
+	}
+	for i := 0; i < 120; i++ {
+		l.Record(context.Background(), Entry{Timestamp: start.Add(time.Duration(4+i) * time.Hour), Action: ActionAgentExecute, TenantID: "t3", Actor: "agent"})
+	}
+	h := Handler(l)
+
+	query := func(ctx context.Context, target string) (int, []Entry) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil).WithContext(ctx))
+		if rec.Code != http.StatusOK {
+			return rec.Code, nil
+		}
+		var body struct {
+			Entries []Entry `json:"entries"`
+			Count   int     `json:"count"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Count != len(body.Entries) {
+			t.Fatalf("count %d for %d entries", body.Count, len(body.Entries))
+		}
+		return rec.Code, body.Entries
+	}
+
+	tests := []struct {
+		name   string
+		tenant string
+		target string
+		want   []string
+	}{
+		{"filters", "", "/audit?tenant_id=t1&actor=alice&action=capsule.promote", []string{"a1", "a4"}},
+		{"resource", "", "/audit?resource_id=dep-1", []string{"a2"}},
+		{"time range", "", "/audit?since=2026-01-02T10:00:00Z&until=2026-01-02T12:00:00Z", []string{"a2", "a3"}},
+		{"limit", "", "/audit?tenant_id=t1&limit=1", []string{"a4"}},
+		{"defaults to the key's tenant", "t2", "/audit", []string{"a3"}},
+		{"intent within the key's tenant", "t1", "/audit?intent_id=i2", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.tenant != "" {
+				ctx = WithTenant(ctx, tt.tenant)
+			}
+			code, entries := query(ctx, tt.target)
+			if code != http.StatusOK {
+				t.Fatalf("status %d", code)
+			}
+			if got := ids(entries); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("default limit", func(t *testing.T) {
+		_, entries := query(context.Background(), "/audit?tenant_id=t3")
+		if len(entries) != 100 {
+			t.Fatalf("expected the newest 100 entries, got %d", len(entries))
+		}
+		if last := entries[len(entries)-1].Timestamp; !last.Equal(start.Add(123 * time.Hour)) {
+			t.Fatalf("expected the newest entry last, got %v", last)
+		}
+	})
+
+	for _, target := range []string{"/audit?since=yesterday", "/audit?until=2026-01-02", "/audit?limit=-1", "/audit?limit=many"} {
+		if code, _ := query(context.Background(), target); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", target, code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/audit", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for POST, got %d", rec.Code)
+	}
+}
+
+func TestRequestIdentity(t *testing.T) {
+	anonymous := httptest.NewRequest(http.MethodPost, "/rules", nil)
+	if got := RequestActor(anonymous, "alice"); got != "alice" {
+		t.Errorf("actor without a key = %q, want the named one", got)
+	}
+	if got := BodyTenant(anonymous, "acme"); got != "acme" {
+		t.Errorf("tenant without a key = %q, want the named one", got)
+	}
+	if !TenantAllowed(anonymous, "acme") {
+		t.Error("requests naming no tenant should see every tenant's resources")
+	}
+
+	keyed := anonymous.WithContext(WithActor(WithTenant(context.Background(), "t1"), "api-key:k1"))
+	if got := RequestActor(keyed, "alice"); got != "api-key:k1" {
+		t.Errorf("actor with a key = %q, want the key", got)
+	}
+	if got := BodyTenant(keyed, "t2"); got != "t1" {
+		t.Errorf("tenant with a key = %q, want the key's", got)
+	}
+	if TenantAllowed(keyed, "t2") || !TenantAllowed(keyed, "t1") {
+		t.Error("keys should only see their tenant's resources")
+	}
+}
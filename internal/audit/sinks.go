@@ -0,0 +1,233 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"QLP/internal/database"
+
+	"github.com/lib/pq"
+)
+
+// FileSink appends audit entries to a JSON Lines file
+type FileSink struct {
+	path string
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileSink opens (or creates) the audit file at path
+func NewFileSink(path string) (*FileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit file: %w", err)
+	}
+	return &FileSink{path: path, file: f}, nil
+}
+
+func (s *FileSink) Write(_ context.Context, entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+// Query scans the audit file for matching entries
+func (s *FileSink) Query(_ context.Context, filter Filter) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit file: %w", err)
+	}
+	defer f.Close()
+
+	results := make([]Entry, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if filter.Matches(entry) {
+			results = append(results, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit file: %w", err)
+	}
+
+	if filter.Limit > 0 && len(results) > filter.Limit {
+		results = results[len(results)-filter.Limit:]
+	}
+	return results, nil
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// PostgresSink stores audit entries in the audit_log table
+type PostgresSink struct {
+	db *sql.DB
+}
+
+// NewPostgresSink creates a Postgres sink on an open connection
+func NewPostgresSink(db *sql.DB) (*PostgresSink, error) {
+	if db == nil {
+		return nil, fmt.Errorf("postgres audit sink requires a database connection")
+	}
+
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS audit_log (
+			id VARCHAR(100) PRIMARY KEY,
+			timestamp TIMESTAMP NOT NULL,
+			action VARCHAR(100) NOT NULL,
+			outcome VARCHAR(20) NOT NULL,
+			intent_id VARCHAR(50),
+			tenant_id VARCHAR(100),
+			actor VARCHAR(100) NOT NULL,
+			resource_type VARCHAR(100),
+			resource_ids TEXT[],
+			trace_id VARCHAR(64),
+			error TEXT,
+			details JSONB DEFAULT '{}'
+		)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audit_log table: %w", err)
+	}
+	return &PostgresSink{db: db}, nil
+}
+
+// NewPostgresSinkFromEnv connects using DATABASE_URL
+func NewPostgresSinkFromEnv() (*PostgresSink, error) {
+	db, err := database.New()
+	if err != nil {
+		return nil, err
+	}
+	if !db.IsConnected() {
+		return nil, fmt.Errorf("postgres audit sink requested but database is unavailable")
+	}
+	return NewPostgresSink(db.GetConnection())
+}
+
+func (s *PostgresSink) Write(ctx context.Context, entry Entry) error {
+	details, err := json.Marshal(entry.Details)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit details: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO audit_log (id, timestamp, action, outcome, intent_id, tenant_id, actor,
+			resource_type, resource_ids, trace_id, error, details)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		entry.ID, entry.Timestamp, entry.Action, entry.Outcome, entry.IntentID, entry.TenantID,
+		entry.Actor, entry.ResourceType, pq.Array(entry.ResourceIDs), entry.TraceID, entry.Error, details)
+	if err != nil {
+		return fmt.Errorf("failed to insert audit entry: %w", err)
+	}
+	return nil
+}
+
+// Query selects matching entries from the audit_log table
+func (s *PostgresSink) Query(ctx context.Context, filter Filter) ([]Entry, error) {
+	query, args := auditQuery(filter)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	results := make([]Entry, 0)
+	for rows.Next() {
+		var entry Entry
+		var resourceIDs pq.StringArray
+		var details []byte
+		if err := rows.Scan(&entry.ID, &entry.Timestamp, &entry.Action, &entry.Outcome, &entry.IntentID,
+			&entry.TenantID, &entry.Actor, &entry.ResourceType, &resourceIDs, &entry.TraceID, &entry.Error, &details); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entry.ResourceIDs = resourceIDs
+		if len(details) > 0 {
+			json.Unmarshal(details, &entry.Details)
+		}
+		results = append(results, entry)
+	}
+
+	// Newest-first from SQL so LIMIT keeps the latest; return oldest first like other sinks
+	for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
+		results[i], results[j] = results[j], results[i]
+	}
+	return results, rows.Err()
+}
+
+// auditQuery builds the SELECT for a filter, newest first so LIMIT keeps the
+// latest entries
+func auditQuery(filter Filter) (string, []interface{}) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.IntentID != "" {
+		add("intent_id = $%d", filter.IntentID)
+	}
+	if filter.TenantID != "" {
+		add("tenant_id = $%d", filter.TenantID)
+	}
+	if filter.Actor != "" {
+		add("actor = $%d", filter.Actor)
+	}
+	if filter.Action != "" {
+		add("action = $%d", string(filter.Action))
+	}
+	if filter.ResourceID != "" {
+		add("$%d = ANY(resource_ids)", filter.ResourceID)
+	}
+	if !filter.Since.IsZero() {
+		add("timestamp >= $%d", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		add("timestamp < $%d", filter.Until)
+	}
+
+	query := `SELECT id, timestamp, action, outcome, COALESCE(intent_id, ''), COALESCE(tenant_id, ''), actor,
+		COALESCE(resource_type, ''), resource_ids, COALESCE(trace_id, ''), COALESCE(error, ''), details
+		FROM audit_log`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY timestamp DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+	return query, args
+}
+
+func (s *PostgresSink) Close() error {
+	return nil
+}
@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// auditEntries are four entries an hour apart across two tenants
+func auditEntries(start time.Time) []Entry {
+	return []Entry{
+		{ID: "a1", Timestamp: start, Action: ActionCapsulePromote, TenantID: "t1", IntentID: "i1", Actor: "alice", ResourceIDs: []string{"cap-1"}},
+		{ID: "a2", Timestamp: start.Add(time.Hour), Action: ActionDeploymentRollback, TenantID: "t1", IntentID: "i1", Actor: "bob", ResourceIDs: []string{"dep-1"}},
+		{ID: "a3", Timestamp: start.Add(2 * time.Hour), Action: ActionCapsulePromote, TenantID: "t2", IntentID: "i2", Actor: "alice", ResourceIDs: []string{"cap-2", "cap-3"}},
+		{ID: "a4", Timestamp: start.Add(3 * time.Hour), Action: ActionCapsulePromote, TenantID: "t1", IntentID: "i3", Actor: "alice", ResourceIDs: []string{"cap-4"}},
+	}
+}
+
+func ids(entries []Entry) []string {
+	out := make([]string, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, e.ID)
+	}
+	return out
+}
+
+func TestFileSinkQuery(t *testing.T) {
+	sink, err := NewFileSink(filepath.Join(t.TempDir(), "audit", "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	start := time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC)
+	for _, e := range auditEntries(start) {
+		if err := sink.Write(context.Background(), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"everything", Filter{}, []string{"a1", "a2", "a3", "a4"}},
+		{"tenant", Filter{TenantID: "t1"}, []string{"a1", "a2", "a4"}},
+		{"intent", Filter{IntentID: "i1"}, []string{"a1", "a2"}},
+		{"actor and action", Filter{Actor: "alice", Action: ActionCapsulePromote}, []string{"a1", "a3", "a4"}},
+		{"resource", Filter{ResourceID: "cap-3"}, []string{"a3"}},
+		{"since is inclusive", Filter{Since: start.Add(time.Hour)}, []string{"a2", "a3", "a4"}},
+		{"until is exclusive", Filter{Until: start.Add(2 * time.Hour)}, []string{"a1", "a2"}},
+		{"limit keeps the newest", Filter{TenantID: "t1", Limit: 2}, []string{"a2", "a4"}},
+		{"limit above the matches", Filter{Limit: 10}, []string{"a1", "a2", "a3", "a4"}},
+		{"no match", Filter{TenantID: "t3"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := sink.Query(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(entries); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuditQuery(t *testing.T) {
+	since := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	until := since.Add(24 * time.Hour)
+
+	query, args := auditQuery(Filter{})
+	if strings.Contains(query, "WHERE") || strings.Contains(query, "LIMIT") || len(args) != 0 {
+		t.Fatalf("empty filter should select everything, got %q %v", query, args)
+	}
+
+	query, args = auditQuery(Filter{
+		IntentID: "i1", TenantID: "t1", Actor: "alice", Action: ActionCapsulePromote,
+		ResourceID: "cap-1", Since: since, Until: until, Limit: 50,
+	})
+	where := "WHERE intent_id = $1 AND tenant_id = $2 AND actor = $3 AND action = $4 AND $5 = ANY(resource_ids) AND timestamp >= $6 AND timestamp < $7"
+	if !strings.Contains(query, where) {
+		t.Fatalf("expected %q in %q", where, query)
+	}
+	if !strings.HasSuffix(query, " ORDER BY timestamp DESC LIMIT 50") {
+		t.Fatalf("expected the newest 50 entries, got %q", query)
+	}
+	want := []interface{}{"i1", "t1", "alice", string(ActionCapsulePromote), "cap-1", since, until}
+	if !reflect.DeepEqual(args, want) {
+		t.Fatalf("got args %v, want %v", args, want)
+	}
+
+	// Placeholders are numbered by the conditions present
+	query, args = auditQuery(Filter{TenantID: "t1", Until: until})
+	if !strings.Contains(query, "WHERE tenant_id = $1 AND timestamp < $2 ORDER BY") || len(args) != 2 {
+		t.Fatalf("unexpected query %q %v", query, args)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build windows
+
+package audit
+
+import (
+	"context"
+	"fmt"
+)
+
+// SyslogSink is unavailable on Windows
+type SyslogSink struct{}
+
+// NewSyslogSink always fails on Windows, which has no syslog daemon
+func NewSyslogSink(tag string) (*SyslogSink, error) {
+	return nil, fmt.Errorf("syslog audit sink is not supported on windows")
+}
+
+func (s *SyslogSink) Write(_ context.Context, _ Entry) error {
+	return nil
+}
+
+func (s *SyslogSink) Close() error {
+	return nil
+}
@@ -0,0 +1,406 @@
+// Package batch runs many intents submitted together, e.g. one per legacy
+// script being migrated. Each batch shares constraints, runs at most its own
+// concurrency limit of intents at once within a service-wide limit, and
+// reports per-intent status plus a rollup.
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"QLP/internal/e2e"
+	"QLP/internal/models"
+	"QLP/internal/quota"
+)
+
+var (
+	// ErrNotFound is returned for unknown batch IDs
+	ErrNotFound = errors.New("batch not found")
+	// ErrInvalid is returned for batches that cannot be scheduled
+	ErrInvalid = errors.New("invalid batch")
+)
+
+// Status of a batch or one of its items
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+// Request submits a batch
+type Request struct {
+	Name        string              `json:"name,omitempty"`
+	TenantID    string              `json:"tenant_id,omitempty"`
+	Intents     []string            `json:"intents"`
+	Constraints *models.Constraints `json:"constraints,omitempty"` // shared by every intent
+	Concurrency int                 `json:"concurrency,omitempty"` // intents of this batch run at once; 1 by default
+}
+
+// Item is one intent of a batch
+type Item struct {
+	Index        int        `json:"index"`
+	Intent       string     `json:"intent"`
+	Status       Status     `json:"status"`
+	IntentID     string     `json:"intent_id,omitempty"`
+	CapsuleID    string     `json:"capsule_id,omitempty"`
+	OverallScore int        `json:"overall_score"`
+	Error        string     `json:"error,omitempty"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+}
+
+// Rollup summarizes a batch's items
+type Rollup struct {
+	Total        int     `json:"total"`
+	Queued       int     `json:"queued"`
+	Running      int     `json:"running"`
+	Completed    int     `json:"completed"`
+	Failed       int     `json:"failed"`
+	Cancelled    int     `json:"cancelled"`
+	AverageScore float64 `json:"average_score"` // over completed intents
+	DurationMS   int64   `json:"duration_ms"`
+}
+
+// Batch is a submitted batch and its progress
+type Batch struct {
+	ID          string              `json:"id"`
+	Name        string              `json:"name,omitempty"`
+	TenantID    string              `json:"tenant_id,omitempty"`
+	Status      Status              `json:"status"`
+	Concurrency int                 `json:"concurrency"`
+	Constraints *models.Constraints `json:"constraints,omitempty"`
+	Items       []Item              `json:"items"`
+	Rollup      Rollup              `json:"rollup"`
+	CreatedAt   time.Time           `json:"created_at"`
+	CompletedAt *time.Time          `json:"completed_at,omitempty"`
+
+	cancel context.CancelFunc
+}
+
+// Service schedules batches
+type Service struct {
+	exec     e2e.Executor
+	slots    *quota.FairShare // service-wide limit on running intents, shared fairly by tenants
+	quota    *quota.Tracker
+	maxItems int
+	timeout  time.Duration
+	mu       sync.Mutex
+	batches  map[string]*Batch
+}
+
+// NewService runs intents with exec (qlp generate --json), at most
+// maxConcurrency at once across all batches. Tenants with queued intents
+// take turns at free slots.
+func NewService(exec e2e.Executor, maxConcurrency, maxItems int, timeout time.Duration) *Service {
+	return &Service{
+		exec:     exec,
+		slots:    quota.NewFairShare(maxConcurrency),
+		maxItems: maxItems,
+		timeout:  timeout,
+		batches:  make(map[string]*Batch),
+	}
+}
+
+// SetQuota refuses batches of tenants that have used up their daily
+// execution minutes when tracker is in reject mode. In queue mode batches
+// are accepted and their intents wait for the quota in qlp generate.
+func (s *Service) SetQuota(tracker *quota.Tracker) {
+	s.quota = tracker
+}
+
+// Submit validates and schedules a batch, returning it immediately
+func (s *Service) Submit(ctx context.Context, req Request) (*Batch, error) {
+	if len(req.Intents) == 0 {
+		return nil, fmt.Errorf("%w: no intents", ErrInvalid)
+	}
+	if s.maxItems > 0 && len(req.Intents) > s.maxItems {
+		return nil, fmt.Errorf("%w: %d intents exceeds the limit of %d", ErrInvalid, len(req.Intents), s.maxItems)
+	}
+	if s.quota != nil && s.quota.Mode() == quota.ModeReject {
+		if err := s.quota.Check(ctx, req.TenantID); err != nil {
+			return nil, err
+		}
+	}
+	now := time.Now()
+	b := &Batch{
+		ID:          fmt.Sprintf("BATCH-%d", now.UnixNano()),
+		Name:        req.Name,
+		TenantID:    req.TenantID,
+		Status:      StatusQueued,
+		Concurrency: min(max(req.Concurrency, 1), s.slots.Capacity()),
+		Constraints: req.Constraints,
+		Items:       make([]Item, len(req.Intents)),
+		CreatedAt:   now,
+	}
+	for i, intent := range req.Intents {
+		if intent == "" {
+			return nil, fmt.Errorf("%w: intent %d is empty", ErrInvalid, i)
+		}
+		b.Items[i] = Item{Index: i, Intent: intent, Status: StatusQueued}
+	}
+
+	// Batches outlive the request that submitted them
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	b.cancel = cancel
+
+	s.mu.Lock()
+	s.batches[b.ID] = b
+	snapshot := s.snapshot(b)
+	s.mu.Unlock()
+
+	go s.run(runCtx, b)
+	return snapshot, nil
+}
+
+// Get returns a batch with its current rollup
+func (s *Service) Get(id string) (*Batch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.batches[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.snapshot(b), nil
+}
+
+// List returns the batches of a tenant, or of all tenants when tenantID is
+// empty, newest first
+func (s *Service) List(tenantID string) []*Batch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*Batch, 0, len(s.batches))
+	for _, b := range s.batches {
+		if tenantID == "" || b.TenantID == tenantID {
+			out = append(out, s.snapshot(b))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out
+}
+
+// Cancel stops scheduling a batch's queued intents and aborts running ones
+func (s *Service) Cancel(id string) (*Batch, error) {
+	s.mu.Lock()
+	b, ok := s.batches[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	b.cancel()
+	return s.Get(id)
+}
+
+func (s *Service) run(ctx context.Context, b *Batch) {
+	defer b.cancel()
+
+	constraintsFile, err := writeConstraints(b.Constraints)
+	if err != nil {
+		s.finishAll(b, StatusFailed, err.Error())
+		return
+	}
+	if constraintsFile != "" {
+		defer os.Remove(constraintsFile)
+	}
+
+	s.setStatus(b, StatusRunning)
+	batchSlots := make(chan struct{}, b.Concurrency)
+	var wg sync.WaitGroup
+	for i := range b.Items {
+		if !acquire(ctx, batchSlots) {
+			break
+		}
+		if s.slots.Acquire(ctx, b.TenantID) != nil {
+			<-batchSlots
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { s.slots.Release(b.TenantID); <-batchSlots }()
+			s.runItem(ctx, b, i, constraintsFile)
+		}(i)
+	}
+	wg.Wait()
+
+	s.finishAll(b, StatusCancelled, "batch cancelled")
+}
+
+// acquire takes a slot unless ctx is done first
+func acquire(ctx context.Context, slots chan struct{}) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// generateOutput is the subset of qlp generate --json a batch records
+type generateOutput struct {
+	IntentID     string `json:"intent_id"`
+	CapsuleID    string `json:"capsule_id"`
+	Status       string `json:"status"`
+	OverallScore int    `json:"overall_score"`
+	Error        string `json:"error"`
+}
+
+func (s *Service) runItem(ctx context.Context, b *Batch, i int, constraintsFile string) {
+	start := time.Now()
+	s.update(b, i, func(item *Item) {
+		item.Status = StatusRunning
+		item.StartedAt = &start
+	})
+
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	args := []string{"generate"}
+	if b.TenantID != "" {
+		args = append(args, "--tenant", b.TenantID)
+	}
+	if constraintsFile != "" {
+		args = append(args, "--constraints", constraintsFile)
+	}
+	data, err := s.exec(ctx, append(args, b.Items[i].Intent)...)
+
+	var out generateOutput
+	if len(data) > 0 {
+		if decodeErr := json.Unmarshal(data, &out); decodeErr != nil && err == nil {
+			err = fmt.Errorf("invalid generate output: %w", decodeErr)
+		}
+	}
+	if err == nil && out.Status != string(models.IntentStatusCompleted) {
+		err = fmt.Errorf("intent %s", out.Status)
+	}
+
+	finished := time.Now()
+	s.update(b, i, func(item *Item) {
+		item.IntentID = out.IntentID
+		item.CapsuleID = out.CapsuleID
+		item.OverallScore = out.OverallScore
+		item.FinishedAt = &finished
+		switch {
+		case err == nil:
+			item.Status = StatusCompleted
+		case ctx.Err() != nil && errors.Is(ctx.Err(), context.Canceled):
+			item.Status = StatusCancelled
+			item.Error = "batch cancelled"
+		default:
+			item.Status = StatusFailed
+			item.Error = err.Error()
+			if out.Error != "" {
+				item.Error = out.Error
+			}
+		}
+	})
+}
+
+func (s *Service) update(b *Batch, i int, fn func(*Item)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&b.Items[i])
+}
+
+func (s *Service) setStatus(b *Batch, status Status) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b.Status = status
+}
+
+// finishAll marks items that never ran with status and settles the batch
+func (s *Service) finishAll(b *Batch, status Status, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for i := range b.Items {
+		if b.Items[i].Status == StatusQueued {
+			b.Items[i].Status = status
+			b.Items[i].Error = reason
+		}
+	}
+	rollup := rollupOf(b, now)
+	switch {
+	case rollup.Cancelled > 0:
+		b.Status = StatusCancelled
+	case rollup.Failed > 0:
+		b.Status = StatusFailed
+	default:
+		b.Status = StatusCompleted
+	}
+	b.CompletedAt = &now
+}
+
+// snapshot copies b with a fresh rollup; the caller holds s.mu
+func (s *Service) snapshot(b *Batch) *Batch {
+	out := *b
+	out.Items = append([]Item(nil), b.Items...)
+	end := time.Now()
+	if b.CompletedAt != nil {
+		end = *b.CompletedAt
+	}
+	out.Rollup = rollupOf(b, end)
+	out.cancel = nil
+	return &out
+}
+
+func rollupOf(b *Batch, end time.Time) Rollup {
+	r := Rollup{Total: len(b.Items), DurationMS: end.Sub(b.CreatedAt).Milliseconds()}
+	scoreSum := 0
+	for _, item := range b.Items {
+		switch item.Status {
+		case StatusQueued:
+			r.Queued++
+		case StatusRunning:
+			r.Running++
+		case StatusCompleted:
+			r.Completed++
+			scoreSum += item.OverallScore
+		case StatusFailed:
+			r.Failed++
+		case StatusCancelled:
+			r.Cancelled++
+		}
+	}
+	if r.Completed > 0 {
+		r.AverageScore = float64(scoreSum) / float64(r.Completed)
+	}
+	return r
+}
+
+// writeConstraints saves shared constraints for qlp generate --constraints
+func writeConstraints(c *models.Constraints) (string, error) {
+	if c.IsZero() {
+		return "", nil
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal constraints: %w", err)
+	}
+	f, err := os.CreateTemp("", "qlp-batch-constraints-*.json")
+	if err != nil {
+		return "", fmt.Errorf("failed to write constraints: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write constraints: %w", err)
+	}
+	return f.Name(), nil
+}
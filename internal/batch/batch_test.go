@@ -0,0 +1,165 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"QLP/internal/models"
+)
+
+func waitDone(t *testing.T, svc *Service, id string) *Batch {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		b, err := svc.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.CompletedAt != nil {
+			return b
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("batch %s did not finish", id)
+	return nil
+}
+
+func TestBatchRollup(t *testing.T) {
+	exec := func(ctx context.Context, args ...string) ([]byte, error) {
+		intent := args[len(args)-1]
+		if intent == "bad" {
+			return json.Marshal(generateOutput{Status: "failed", Error: "validation failed"})
+		}
+		return json.Marshal(generateOutput{IntentID: "QI-" + intent, CapsuleID: "QLCAP-" + intent, Status: "completed", OverallScore: 80})
+	}
+	svc := NewService(exec, 4, 10, 0)
+
+	b, err := svc.Submit(context.Background(), Request{Intents: []string{"a", "bad", "c"}, Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = waitDone(t, svc, b.ID)
+
+	if b.Status != StatusFailed {
+		t.Errorf("status = %s, want failed", b.Status)
+	}
+	r := b.Rollup
+	if r.Total != 3 || r.Completed != 2 || r.Failed != 1 || r.AverageScore != 80 {
+		t.Errorf("rollup = %+v", r)
+	}
+	if b.Items[1].Error != "validation failed" {
+		t.Errorf("item error = %q", b.Items[1].Error)
+	}
+	if b.Items[0].CapsuleID != "QLCAP-a" {
+		t.Errorf("capsule = %q", b.Items[0].CapsuleID)
+	}
+}
+
+func TestBatchConcurrencyLimit(t *testing.T) {
+	var running, peak int32
+	exec := func(ctx context.Context, args ...string) ([]byte, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return json.Marshal(generateOutput{Status: "completed"})
+	}
+	svc := NewService(exec, 8, 0, 0)
+
+	b, err := svc.Submit(context.Background(), Request{Intents: []string{"1", "2", "3", "4", "5", "6"}, Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b = waitDone(t, svc, b.ID); b.Status != StatusCompleted {
+		t.Errorf("status = %s", b.Status)
+	}
+	if peak > 2 {
+		t.Errorf("peak concurrency = %d, want <= 2", peak)
+	}
+}
+
+func TestBatchSharedConstraints(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	exec := func(ctx context.Context, args ...string) ([]byte, error) {
+		i := slices.Index(args, "--constraints")
+		if i < 0 {
+			return nil, errors.New("no constraints passed")
+		}
+		data, err := os.ReadFile(args[i+1])
+		if err != nil {
+			return nil, err
+		}
+		var c models.Constraints
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		seen = append(seen, c.Languages...)
+		mu.Unlock()
+		return json.Marshal(generateOutput{Status: "completed"})
+	}
+	svc := NewService(exec, 2, 0, 0)
+
+	b, err := svc.Submit(context.Background(), Request{
+		Intents:     []string{"a", "b"},
+		Constraints: &models.Constraints{Languages: []string{"go"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b = waitDone(t, svc, b.ID); b.Status != StatusCompleted {
+		t.Fatalf("status = %s: %+v", b.Status, b.Items)
+	}
+	if len(seen) != 2 || seen[0] != "go" || seen[1] != "go" {
+		t.Errorf("constraints seen = %v", seen)
+	}
+}
+
+func TestBatchCancel(t *testing.T) {
+	exec := func(ctx context.Context, args ...string) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	svc := NewService(exec, 1, 0, 0)
+
+	b, err := svc.Submit(context.Background(), Request{Intents: []string{"a", "b", "c"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Cancel(b.ID); err != nil {
+		t.Fatal(err)
+	}
+	b = waitDone(t, svc, b.ID)
+	if b.Status != StatusCancelled || b.Rollup.Cancelled != 3 {
+		t.Errorf("status = %s, rollup = %+v", b.Status, b.Rollup)
+	}
+}
+
+func TestBatchInvalid(t *testing.T) {
+	svc := NewService(nil, 1, 2, 0)
+	for _, req := range []Request{
+		{},
+		{Intents: []string{"a", "b", "c"}},
+		{Intents: []string{"a", ""}},
+	} {
+		if _, err := svc.Submit(context.Background(), req); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Submit(%v) error = %v, want ErrInvalid", req.Intents, err)
+		}
+	}
+	if _, err := svc.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get error = %v", err)
+	}
+}
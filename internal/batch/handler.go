@@ -0,0 +1,114 @@
+package batch
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/quota"
+)
+
+// Routes returns the batch endpoints:
+//
+//	POST /batches               submits intents with shared constraints
+//	GET  /batches?tenant=       lists batches, newest first
+//	GET  /batches/{id}          returns per-intent status and the rollup
+//	POST /batches/{id}/cancel   stops the batch's queued and running intents
+//
+// Batches submitted with an API key run for, and are metered against, the
+// key's tenant; batches of other tenants than the request's are not found.
+func Routes(svc *Service) map[string]http.Handler {
+	return map[string]http.Handler{
+		"POST /batches":             submitHandler(svc),
+		"GET /batches":              listHandler(svc),
+		"GET /batches/{id}":         getHandler(svc),
+		"POST /batches/{id}/cancel": cancelHandler(svc),
+	}
+}
+
+func submitHandler(svc *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid batch request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.TenantID = audit.BodyTenant(r, req.TenantID)
+		b, err := svc.Submit(r.Context(), req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, b)
+	})
+}
+
+func listHandler(svc *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"batches": svc.List(audit.RequestTenant(r)),
+		})
+	})
+}
+
+func getHandler(svc *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := tenantBatch(svc, r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, b)
+	})
+}
+
+func cancelHandler(svc *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := tenantBatch(svc, r); err != nil {
+			writeError(w, err)
+			return
+		}
+		b, err := svc.Cancel(r.PathValue("id"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, b)
+	})
+}
+
+// tenantBatch returns the batch the request names, not found when it belongs
+// to another tenant than the request's
+func tenantBatch(svc *Service, r *http.Request) (*Batch, error) {
+	b, err := svc.Get(r.PathValue("id"))
+	if err != nil {
+		return nil, err
+	}
+	if !audit.TenantAllowed(r, b.TenantID) {
+		return nil, ErrNotFound
+	}
+	return b, nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrInvalid):
+		status = http.StatusBadRequest
+	case errors.Is(err, quota.ErrExceeded):
+		status = http.StatusTooManyRequests
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(quota.NextReset(time.Now())).Seconds())+1))
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
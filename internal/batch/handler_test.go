@@ -0,0 +1,65 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"QLP/internal/audit"
+)
+
+func TestRoutesScopeBatchesToTheAPIKey(t *testing.T) {
+	var tenants []string
+	exec := func(ctx context.Context, args ...string) ([]byte, error) {
+		for i, arg := range args {
+			if arg == "--tenant" {
+				tenants = append(tenants, args[i+1])
+			}
+		}
+		return json.Marshal(generateOutput{Status: "completed", OverallScore: 80})
+	}
+	svc := NewService(exec, 1, 10, 0)
+	mux := http.NewServeMux()
+	for pattern, h := range Routes(svc) {
+		mux.Handle(pattern, h)
+	}
+	as := func(tenant, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(audit.WithTenant(req.Context(), tenant))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := as("t1", http.MethodPost, "/batches", `{"tenant_id":"t2","intents":["a"]}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("submit: %d %s", rec.Code, rec.Body)
+	}
+	var b Batch
+	if err := json.NewDecoder(rec.Body).Decode(&b); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, svc, b.ID)
+	if b.TenantID != "t1" || len(tenants) != 1 || tenants[0] != "t1" {
+		t.Fatalf("batch ran for %q (%v), want the key's tenant", b.TenantID, tenants)
+	}
+
+	if rec := as("t2", http.MethodGet, "/batches/"+b.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("get from another tenant: %d", rec.Code)
+	}
+	if rec := as("t2", http.MethodPost, "/batches/"+b.ID+"/cancel", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("cancel from another tenant: %d", rec.Code)
+	}
+	if rec := as("t2", http.MethodGet, "/batches", ""); strings.Contains(rec.Body.String(), b.ID) {
+		t.Errorf("another tenant lists the batch: %s", rec.Body)
+	}
+	if rec := as("t1", http.MethodGet, "/batches", ""); !strings.Contains(rec.Body.String(), b.ID) {
+		t.Errorf("list: %s", rec.Body)
+	}
+	if rec := as("t1", http.MethodGet, "/batches/"+b.ID, ""); rec.Code != http.StatusOK {
+		t.Errorf("get: %d", rec.Code)
+	}
+}
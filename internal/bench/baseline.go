@@ -0,0 +1,149 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Baseline is a recorded set of scores to compare later runs against
+type Baseline struct {
+	Label      string            `json:"label,omitempty"` // e.g. the model and prompt version
+	RecordedAt time.Time         `json:"recorded_at"`
+	Cases      map[string]Scores `json:"cases"`
+}
+
+// NewBaseline records the scores of the cases that succeeded
+func NewBaseline(label string, results []CaseResult) *Baseline {
+	b := &Baseline{Label: label, RecordedAt: time.Now().UTC(), Cases: make(map[string]Scores)}
+	for _, r := range results {
+		if r.Error == "" {
+			b.Cases[r.ID] = r.Scores
+		}
+	}
+	return b
+}
+
+// LoadBaseline reads a baseline file
+func LoadBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %w", err)
+	}
+	var b Baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("invalid baseline %s: %w", path, err)
+	}
+	return &b, nil
+}
+
+// Save writes the baseline, creating its directory
+func (b *Baseline) Save(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create baseline directory: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Thresholds bound how far scores may fall below the baseline
+type Thresholds struct {
+	MaxDrop     int     // points any score of a single case may drop
+	MaxMeanDrop float64 // points the mean overall score may drop
+}
+
+// DefaultThresholds tolerate the run-to-run noise of LLM generation
+var DefaultThresholds = Thresholds{MaxDrop: 10, MaxMeanDrop: 3}
+
+// Regression is a score that fell below the baseline beyond the threshold
+type Regression struct {
+	CaseID   string `json:"case_id"`
+	Metric   string `json:"metric"`
+	Baseline int    `json:"baseline"`
+	Current  int    `json:"current"`
+	Delta    int    `json:"delta"`
+	Message  string `json:"message"`
+}
+
+// Comparison is the result of comparing a run to a baseline
+type Comparison struct {
+	Baseline     string       `json:"baseline,omitempty"`
+	Results      []CaseResult `json:"results"`
+	Regressions  []Regression `json:"regressions"`
+	MeanOverall  float64      `json:"mean_overall"`
+	MeanBaseline float64      `json:"mean_baseline"`
+	MeanDelta    float64      `json:"mean_delta"`
+	Unbaselined  []string     `json:"unbaselined,omitempty"` // cases with no baseline scores yet
+	Passed       bool         `json:"passed"`
+}
+
+// Compare checks the results against the baseline. A case that fails to
+// generate or validate is a regression when the baseline has scores for it.
+func Compare(baseline *Baseline, results []CaseResult, t Thresholds) *Comparison {
+	c := &Comparison{Baseline: baseline.Label, Results: results, Regressions: []Regression{}}
+	var current, previous float64
+	var compared int
+	for _, r := range results {
+		base, ok := baseline.Cases[r.ID]
+		if !ok {
+			c.Unbaselined = append(c.Unbaselined, r.ID)
+			continue
+		}
+		if r.Error != "" {
+			c.Regressions = append(c.Regressions, Regression{
+				CaseID: r.ID, Metric: "run", Baseline: base.Overall,
+				Delta: -base.Overall, Message: fmt.Sprintf("%s failed: %s", r.ID, r.Error),
+			})
+			continue
+		}
+		compared++
+		current += float64(r.Scores.Overall)
+		previous += float64(base.Overall)
+
+		baseMetrics := base.Metrics()
+		for i, m := range r.Scores.Metrics() {
+			delta := m.Value - baseMetrics[i].Value
+			if -delta > t.MaxDrop {
+				c.Regressions = append(c.Regressions, Regression{
+					CaseID: r.ID, Metric: m.Name, Baseline: baseMetrics[i].Value, Current: m.Value, Delta: delta,
+					Message: fmt.Sprintf("%s %s score dropped %d points (%d -> %d)", r.ID, m.Name, -delta, baseMetrics[i].Value, m.Value),
+				})
+			}
+		}
+	}
+
+	if compared > 0 {
+		c.MeanOverall = current / float64(compared)
+		c.MeanBaseline = previous / float64(compared)
+		c.MeanDelta = c.MeanOverall - c.MeanBaseline
+		if -c.MeanDelta > t.MaxMeanDrop {
+			c.Regressions = append(c.Regressions, Regression{
+				CaseID: "*", Metric: "mean_overall",
+				Baseline: int(c.MeanBaseline), Current: int(c.MeanOverall), Delta: int(c.MeanDelta),
+				Message: fmt.Sprintf("mean overall score dropped %.1f points (%.1f -> %.1f)", -c.MeanDelta, c.MeanBaseline, c.MeanOverall),
+			})
+		}
+	}
+	c.Passed = len(c.Regressions) == 0
+	return c
+}
+
+// Missing lists the baseline cases the run did not include
+func (c *Comparison) Missing(baseline *Baseline) []string {
+	ran := make(map[string]bool, len(c.Results))
+	for _, r := range c.Results {
+		ran[r.ID] = true
+	}
+	var missing []string
+	for _, id := range sortedIDs(baseline.Cases) {
+		if !ran[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
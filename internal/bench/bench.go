@@ -0,0 +1,180 @@
+// Package bench runs a fixed corpus of intents through the qlp pipeline,
+// scores each capsule with the static validators and compares the scores to
+// a stored baseline, so prompt and model changes can be judged by whether
+// generation quality regressed.
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"QLP/internal/e2e"
+)
+
+// Case is one intent of the corpus
+type Case struct {
+	ID     string `json:"id"`
+	Intent string `json:"intent"`
+}
+
+// DefaultCorpus is the fixed corpus run when no corpus file is given. Keep
+// IDs stable: baselines are keyed by them.
+var DefaultCorpus = []Case{
+	{ID: "go-rest-api", Intent: "Create a REST API in Go for user management with JWT authentication and PostgreSQL"},
+	{ID: "python-cli", Intent: "Build a Python command line tool that converts CSV files to JSON with input validation"},
+	{ID: "node-webhook", Intent: "Create a Node.js Express service that receives GitHub webhooks and verifies their signatures"},
+	{ID: "react-dashboard", Intent: "Build a React dashboard that charts metrics from a REST endpoint"},
+	{ID: "terraform-aks", Intent: "Write Terraform for an Azure Kubernetes Service cluster with a private container registry"},
+	{ID: "go-worker", Intent: "Create a Go worker that consumes jobs from Redis with retries and graceful shutdown"},
+}
+
+// LoadCorpus reads a JSON array of cases
+func LoadCorpus(path string) ([]Case, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read corpus: %w", err)
+	}
+	var corpus []Case
+	if err := json.Unmarshal(data, &corpus); err != nil {
+		return nil, fmt.Errorf("invalid corpus file %s: %w", path, err)
+	}
+	seen := make(map[string]bool, len(corpus))
+	for i, c := range corpus {
+		if c.ID == "" || c.Intent == "" {
+			return nil, fmt.Errorf("corpus case %d needs an id and an intent", i)
+		}
+		if seen[c.ID] {
+			return nil, fmt.Errorf("duplicate corpus id %q", c.ID)
+		}
+		seen[c.ID] = true
+	}
+	return corpus, nil
+}
+
+// Scores are the validator scores of a capsule, 0-100
+type Scores struct {
+	Overall      int `json:"overall"`
+	Security     int `json:"security"`
+	Quality      int `json:"quality"`
+	Architecture int `json:"architecture"`
+	Compliance   int `json:"compliance"`
+}
+
+// Metrics returns the scores by name, in a stable order
+func (s Scores) Metrics() []Metric {
+	return []Metric{
+		{"overall", s.Overall},
+		{"security", s.Security},
+		{"quality", s.Quality},
+		{"architecture", s.Architecture},
+		{"compliance", s.Compliance},
+	}
+}
+
+// Metric is a named score
+type Metric struct {
+	Name  string
+	Value int
+}
+
+// CaseResult is the outcome of one case
+type CaseResult struct {
+	ID         string `json:"id"`
+	CapsuleID  string `json:"capsule_id,omitempty"`
+	Scores     Scores `json:"scores"`
+	Issues     int    `json:"issues"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// validateOutput is the subset of qlp validate --json the benchmark uses
+type validateOutput struct {
+	Validation *struct {
+		OverallScore      int               `json:"overall_score"`
+		SecurityScore     int               `json:"security_score"`
+		QualityScore      int               `json:"quality_score"`
+		ArchitectureScore int               `json:"architecture_score"`
+		ComplianceScore   int               `json:"compliance_score"`
+		Issues            []json.RawMessage `json:"issues"`
+	} `json:"validation"`
+}
+
+// Run generates and validates every case, up to parallel at once, and
+// returns the results in corpus order
+func Run(ctx context.Context, exec e2e.Executor, corpus []Case, parallel int) []CaseResult {
+	results := make([]CaseResult, len(corpus))
+	slots := make(chan struct{}, max(parallel, 1))
+	var wg sync.WaitGroup
+	for i, c := range corpus {
+		wg.Add(1)
+		go func(i int, c Case) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			results[i] = runCase(ctx, exec, c)
+		}(i, c)
+	}
+	wg.Wait()
+	return results
+}
+
+func runCase(ctx context.Context, exec e2e.Executor, c Case) CaseResult {
+	result := CaseResult{ID: c.ID}
+	start := time.Now()
+	defer func() { result.DurationMS = time.Since(start).Milliseconds() }()
+
+	var gen struct {
+		CapsuleID string `json:"capsule_id"`
+		Error     string `json:"error"`
+	}
+	data, err := exec(ctx, "generate", c.Intent)
+	json.Unmarshal(data, &gen)
+	if err == nil && gen.CapsuleID == "" {
+		err = fmt.Errorf("generation produced no capsule")
+	}
+	if err != nil {
+		result.Error = fmt.Sprintf("generate: %v", err)
+		if gen.Error != "" {
+			result.Error += ": " + gen.Error
+		}
+		return result
+	}
+	result.CapsuleID = gen.CapsuleID
+
+	// validate exits non-zero on constraint violations, but the scores it
+	// printed are still what the benchmark compares
+	var out validateOutput
+	data, err = exec(ctx, "validate", "--min-score", "0", gen.CapsuleID)
+	if decodeErr := json.Unmarshal(data, &out); decodeErr != nil || out.Validation == nil {
+		if err == nil {
+			err = fmt.Errorf("no validation result")
+		}
+		result.Error = fmt.Sprintf("validate: %v", err)
+		return result
+	}
+	v := out.Validation
+	result.Scores = Scores{
+		Overall:      v.OverallScore,
+		Security:     v.SecurityScore,
+		Quality:      v.QualityScore,
+		Architecture: v.ArchitectureScore,
+		Compliance:   v.ComplianceScore,
+	}
+	result.Issues = len(v.Issues)
+	return result
+}
+
+// sortedIDs returns the keys of a baseline's cases in order
+func sortedIDs(cases map[string]Scores) []string {
+	ids := make([]string, 0, len(cases))
+	for id := range cases {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
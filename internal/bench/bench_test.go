@@ -0,0 +1,89 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeQLP generates one capsule per intent and validates it with fixed scores
+func fakeQLP(scores map[string]Scores) func(ctx context.Context, args ...string) ([]byte, error) {
+	return func(ctx context.Context, args ...string) ([]byte, error) {
+		switch args[0] {
+		case "generate":
+			if args[1] == "broken" {
+				return []byte(`{"status": "failed", "error": "planner failed"}`), errors.New("exit status 1")
+			}
+			return []byte(fmt.Sprintf(`{"capsule_id": %q, "status": "completed"}`, args[1])), nil
+		case "validate":
+			s := scores[args[len(args)-1]]
+			return []byte(fmt.Sprintf(`{"validation": {"overall_score": %d, "security_score": %d, "quality_score": %d, "architecture_score": %d, "compliance_score": %d, "issues": [{}, {}]}}`,
+				s.Overall, s.Security, s.Quality, s.Architecture, s.Compliance)), errors.New("exit status 1")
+		}
+		return nil, errors.New("unexpected command")
+	}
+}
+
+func TestRunAndCompare(t *testing.T) {
+	corpus := []Case{{ID: "api", Intent: "api"}, {ID: "cli", Intent: "cli"}, {ID: "broken", Intent: "broken"}, {ID: "new", Intent: "new"}}
+	results := Run(context.Background(), fakeQLP(map[string]Scores{
+		"api": {Overall: 80, Security: 70, Quality: 85, Architecture: 80, Compliance: 90},
+		"cli": {Overall: 84, Security: 90, Quality: 80, Architecture: 80, Compliance: 80},
+		"new": {Overall: 70},
+	}), corpus, 2)
+
+	if results[0].CapsuleID != "api" || results[0].Scores.Security != 70 || results[0].Issues != 2 || results[0].Error != "" {
+		t.Fatalf("scores from a failing validate exit should be kept: %+v", results[0])
+	}
+	if !strings.Contains(results[2].Error, "planner failed") {
+		t.Errorf("expected the generation error, got %+v", results[2])
+	}
+
+	baseline := &Baseline{Label: "v1", Cases: map[string]Scores{
+		"api":    {Overall: 82, Security: 85, Quality: 85, Architecture: 80, Compliance: 90},
+		"cli":    {Overall: 80, Security: 90, Quality: 80, Architecture: 80, Compliance: 80},
+		"broken": {Overall: 75},
+		"gone":   {Overall: 60},
+	}}
+	c := Compare(baseline, results, DefaultThresholds)
+	if c.Passed || len(c.Regressions) != 2 {
+		t.Fatalf("expected the security drop and the failed case, got %+v", c.Regressions)
+	}
+	if r := c.Regressions[0]; r.CaseID != "api" || r.Metric != "security" || r.Delta != -15 {
+		t.Errorf("unexpected regression %+v", r)
+	}
+	if r := c.Regressions[1]; r.CaseID != "broken" || r.Metric != "run" {
+		t.Errorf("unexpected regression %+v", r)
+	}
+	if c.MeanDelta != 1 || strings.Join(c.Unbaselined, ",") != "new" || strings.Join(c.Missing(baseline), ",") != "gone" {
+		t.Errorf("unexpected comparison %+v", c)
+	}
+
+	if c := Compare(baseline, results[:2], Thresholds{MaxDrop: 20, MaxMeanDrop: 0.5}); !c.Passed {
+		t.Errorf("expected the run to pass looser thresholds, got %+v", c.Regressions)
+	}
+	if c := Compare(baseline, results[:1], Thresholds{MaxDrop: 20, MaxMeanDrop: 1}); c.Passed || c.Regressions[0].Metric != "mean_overall" {
+		t.Errorf("expected the mean to regress, got %+v", c.Regressions)
+	}
+}
+
+func TestBaselineRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bench", "baseline.json")
+	recorded := NewBaseline("gpt-4o/v3", []CaseResult{
+		{ID: "api", Scores: Scores{Overall: 80}},
+		{ID: "broken", Error: "generate: failed"},
+	})
+	if err := recorded.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Label != "gpt-4o/v3" || len(loaded.Cases) != 1 || loaded.Cases["api"].Overall != 80 {
+		t.Errorf("unexpected baseline %+v", loaded)
+	}
+}
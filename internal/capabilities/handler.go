@@ -0,0 +1,36 @@
+package capabilities
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Routes returns the agent type discovery API:
+//
+//	GET /agents/types         list agent types with their task types and resources
+//	GET /agents/types/{name}  show one agent type
+func Routes(r *Registry) map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /agents/types": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"agent_types": r.List(),
+				"task_types":  r.TaskTypes(),
+			})
+		}),
+		"GET /agents/types/{name}": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			a, err := r.Get(req.PathValue("name"))
+			if errors.Is(err, ErrNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			writeJSON(w, http.StatusOK, a)
+		}),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
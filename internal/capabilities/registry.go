@@ -0,0 +1,265 @@
+// Package capabilities is the registry of agent types: what each agent is
+// for, which task types it handles, the instructions it runs with and the
+// resources it needs. Task decomposition picks agents from it and the agent
+// factory configures agents from it.
+package capabilities
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"QLP/internal/config"
+	"QLP/internal/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNotFound is returned for unknown agent types
+var ErrNotFound = errors.New("agent type not found")
+
+// Task metadata keys set when a task is assigned to an agent type
+const (
+	MetadataAgentType = "agent_type"
+	MetadataCPU       = "agent_cpu"
+	MetadataMemoryMB  = "agent_memory_mb"
+	MetadataTimeout   = "agent_timeout"
+)
+
+// Resources are what an agent needs to run. Zero values keep the defaults of
+// the task type.
+type Resources struct {
+	CPU      float64       `json:"cpu,omitempty" yaml:"cpu"`             // cores for sandbox execution
+	MemoryMB int           `json:"memory_mb,omitempty" yaml:"memory_mb"` // sandbox memory limit
+	Timeout  time.Duration `json:"timeout,omitempty" yaml:"timeout"`     // limit for the whole agent run
+}
+
+// AgentType describes a kind of agent
+type AgentType struct {
+	Name        string            `json:"name" yaml:"name"`
+	Description string            `json:"description" yaml:"description"`
+	TaskTypes   []models.TaskType `json:"task_types" yaml:"task_types"`
+	// Prompt replaces the built-in execution instructions of the task type
+	Prompt    string    `json:"prompt,omitempty" yaml:"prompt"`
+	Resources Resources `json:"resources" yaml:"resources"`
+	BuiltIn   bool      `json:"built_in" yaml:"-"`
+}
+
+// Supports reports whether the agent handles the task type
+func (a AgentType) Supports(taskType models.TaskType) bool {
+	for _, t := range a.TaskTypes {
+		if t == taskType {
+			return true
+		}
+	}
+	return false
+}
+
+var validName = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// Validate checks the agent type can be registered
+func (a AgentType) Validate() error {
+	if !validName.MatchString(a.Name) {
+		return fmt.Errorf("invalid agent type name %q: use lowercase letters, digits and dashes", a.Name)
+	}
+	if len(a.TaskTypes) == 0 {
+		return fmt.Errorf("agent type %s must support at least one task type", a.Name)
+	}
+	if a.Resources.CPU < 0 || a.Resources.MemoryMB < 0 || a.Resources.Timeout < 0 {
+		return fmt.Errorf("agent type %s has negative resources", a.Name)
+	}
+	return nil
+}
+
+// builtIns are the agents behind the task types every deployment supports
+var builtIns = []AgentType{
+	{Name: "code-generator", Description: "Writes application code", TaskTypes: []models.TaskType{models.TaskTypeCodegen}},
+	{Name: "infra-engineer", Description: "Writes infrastructure as code", TaskTypes: []models.TaskType{models.TaskTypeInfra}},
+	{Name: "docs-writer", Description: "Writes project documentation", TaskTypes: []models.TaskType{models.TaskTypeDoc}},
+	{Name: "test-engineer", Description: "Writes automated tests", TaskTypes: []models.TaskType{models.TaskTypeTest}},
+	{Name: "code-analyst", Description: "Analyzes code and architecture", TaskTypes: []models.TaskType{models.TaskTypeAnalyze}},
+}
+
+// Registry holds the agent types in registration order
+type Registry struct {
+	mu     sync.RWMutex
+	agents []AgentType
+}
+
+// NewRegistry returns a registry seeded with the built-in agent types
+func NewRegistry() *Registry {
+	r := &Registry{}
+	for _, a := range builtIns {
+		a.BuiltIn = true
+		r.agents = append(r.agents, a)
+	}
+	return r
+}
+
+// Register adds an agent type, replacing any type with the same name, so
+// built-in agents can be customized
+func (r *Registry) Register(a AgentType) error {
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	a.BuiltIn = false
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.agents {
+		if r.agents[i].Name == a.Name {
+			r.agents[i] = a
+			return nil
+		}
+	}
+	r.agents = append(r.agents, a)
+	return nil
+}
+
+// Get returns an agent type by name
+func (r *Registry) Get(name string) (AgentType, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, a := range r.agents {
+		if a.Name == name {
+			return a, nil
+		}
+	}
+	return AgentType{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// List returns every agent type in registration order
+func (r *Registry) List() []AgentType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]AgentType(nil), r.agents...)
+}
+
+// TaskTypes returns the task types some agent supports, sorted
+func (r *Registry) TaskTypes() []models.TaskType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	seen := make(map[models.TaskType]bool)
+	var types []models.TaskType
+	for _, a := range r.agents {
+		for _, t := range a.TaskTypes {
+			if !seen[t] {
+				seen[t] = true
+				types = append(types, t)
+			}
+		}
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// ForTask picks the agent for a task: the agent chosen during decomposition
+// when it supports the task type, otherwise the first agent registered for
+// the task type
+func (r *Registry) ForTask(task models.Task) (AgentType, bool) {
+	if name := task.Metadata[MetadataAgentType]; name != "" {
+		if a, err := r.Get(name); err == nil && a.Supports(task.Type) {
+			return a, true
+		}
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, a := range r.agents {
+		if a.Supports(task.Type) {
+			return a, true
+		}
+	}
+	return AgentType{}, false
+}
+
+// Assign records the agent type and its resource needs on the task metadata,
+// where the sandbox and the agent factory read them
+func Assign(task *models.Task, a AgentType) {
+	if task.Metadata == nil {
+		task.Metadata = make(map[string]string)
+	}
+	task.Metadata[MetadataAgentType] = a.Name
+	if a.Resources.CPU > 0 {
+		task.Metadata[MetadataCPU] = strconv.FormatFloat(a.Resources.CPU, 'f', -1, 64)
+	}
+	if a.Resources.MemoryMB > 0 {
+		task.Metadata[MetadataMemoryMB] = strconv.Itoa(a.Resources.MemoryMB)
+	}
+	if a.Resources.Timeout > 0 {
+		task.Metadata[MetadataTimeout] = a.Resources.Timeout.String()
+	}
+}
+
+// TaskResources reads the resource needs recorded on a task by Assign
+func TaskResources(task models.Task) Resources {
+	var res Resources
+	res.CPU, _ = strconv.ParseFloat(task.Metadata[MetadataCPU], 64)
+	res.MemoryMB, _ = strconv.Atoi(task.Metadata[MetadataMemoryMB])
+	res.Timeout, _ = time.ParseDuration(task.Metadata[MetadataTimeout])
+	return res
+}
+
+// LoadFile registers the agent types listed in a YAML or JSON file:
+//
+//	agents:
+//	  - name: sql-tuner
+//	    description: Tunes slow SQL queries and indexes
+//	    task_types: [analyze]
+//	    prompt: |
+//	      Return the rewritten queries and the indexes to add ...
+//	    resources: {cpu: 1, memory_mb: 512, timeout: 5m}
+func (r *Registry) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read agent types: %w", err)
+	}
+	var file struct {
+		Agents []AgentType `yaml:"agents"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse agent types: %w", err)
+	}
+	for _, a := range file.Agents {
+		if err := r.Register(a); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+var (
+	defaultRegistry   = NewRegistry()
+	defaultRegistryMu sync.RWMutex
+)
+
+// Default returns the process-wide registry
+func Default() *Registry {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return defaultRegistry
+}
+
+// SetDefault replaces the process-wide registry
+func SetDefault(r *Registry) {
+	defaultRegistryMu.Lock()
+	defer defaultRegistryMu.Unlock()
+	defaultRegistry = r
+}
+
+// InitFromEnv builds the process-wide registry from the built-in agents and
+// the agent types file in QLP_AGENT_TYPES_FILE, if set
+func InitFromEnv() (*Registry, error) {
+	r := NewRegistry()
+	if path := config.GetEnvOrDefault("QLP_AGENT_TYPES_FILE", ""); path != "" {
+		if err := r.LoadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	SetDefault(r)
+	return r, nil
+}
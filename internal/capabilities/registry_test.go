@@ -0,0 +1,108 @@
+package capabilities
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"QLP/internal/models"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register(AgentType{Name: "sql-tuner", Description: "Tunes SQL", TaskTypes: []models.TaskType{models.TaskTypeAnalyze, "sql"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []AgentType{
+		{Name: "SQL Tuner", TaskTypes: []models.TaskType{models.TaskTypeAnalyze}},
+		{Name: "no-tasks"},
+		{Name: "negative", TaskTypes: []models.TaskType{models.TaskTypeDoc}, Resources: Resources{MemoryMB: -1}},
+	} {
+		if err := r.Register(bad); err == nil {
+			t.Errorf("Register(%+v) succeeded", bad)
+		}
+	}
+
+	types := r.TaskTypes()
+	if len(types) != 6 || types[len(types)-1] != "test" {
+		t.Errorf("task types = %v", types)
+	}
+
+	// The built-in agent handles analyze tasks unless decomposition picked one
+	task := models.Task{Type: models.TaskTypeAnalyze}
+	if a, ok := r.ForTask(task); !ok || a.Name != "code-analyst" || !a.BuiltIn {
+		t.Errorf("ForTask() = %+v", a)
+	}
+	task.Metadata = map[string]string{MetadataAgentType: "sql-tuner"}
+	if a, _ := r.ForTask(task); a.Name != "sql-tuner" {
+		t.Errorf("ForTask() with agent = %s", a.Name)
+	}
+	if a, ok := r.ForTask(models.Task{Type: "sql"}); !ok || a.Name != "sql-tuner" {
+		t.Errorf("ForTask(sql) = %+v", a)
+	}
+	if _, ok := r.ForTask(models.Task{Type: "unknown"}); ok {
+		t.Error("ForTask() matched an unsupported task type")
+	}
+}
+
+func TestAssignResources(t *testing.T) {
+	task := models.Task{Type: models.TaskTypeDoc}
+	Assign(&task, AgentType{Name: "docs-writer", Resources: Resources{CPU: 0.5, MemoryMB: 256, Timeout: 2 * time.Minute}})
+	if task.Metadata[MetadataAgentType] != "docs-writer" {
+		t.Errorf("metadata = %v", task.Metadata)
+	}
+	if res := TaskResources(task); res.CPU != 0.5 || res.MemoryMB != 256 || res.Timeout != 2*time.Minute {
+		t.Errorf("TaskResources() = %+v", res)
+	}
+	if res := TaskResources(models.Task{}); res != (Resources{}) {
+		t.Errorf("TaskResources() without metadata = %+v", res)
+	}
+}
+
+func TestLoadFileAndRoutes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agents.yaml")
+	os.WriteFile(path, []byte(`agents:
+  - name: threat-modeler
+    description: Builds STRIDE threat models
+    task_types: [analyze, doc]
+    prompt: Return a threat model as Markdown.
+    resources: {memory_mb: 512, timeout: 5m}
+  - name: docs-writer
+    description: Writes docs in the house style
+    task_types: [doc]
+`), 0644)
+
+	r := NewRegistry()
+	if err := r.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	a, err := r.Get("threat-modeler")
+	if err != nil || a.Resources.Timeout != 5*time.Minute || a.Prompt == "" {
+		t.Fatalf("threat-modeler = %+v, %v", a, err)
+	}
+	if docs, _ := r.Get("docs-writer"); docs.BuiltIn || docs.Description != "Writes docs in the house style" {
+		t.Errorf("built-in docs-writer not replaced: %+v", docs)
+	}
+
+	mux := http.NewServeMux()
+	for pattern, h := range Routes(r) {
+		mux.Handle(pattern, h)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/agents/types", nil))
+	var body struct {
+		AgentTypes []AgentType `json:"agent_types"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || len(body.AgentTypes) != 6 {
+		t.Errorf("GET /agents/types = %d %s", rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/agents/types/sql-tuner", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET unknown agent type = %d", rec.Code)
+	}
+}
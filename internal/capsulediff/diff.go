@@ -0,0 +1,344 @@
+// Package capsulediff compares two capsules or drops generated for the same
+// intent and reports what changed, as a unified diff and a readable summary.
+package capsulediff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ChangeKind classifies a file-level change
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeModified ChangeKind = "modified"
+)
+
+// DefaultContextLines is the number of unchanged lines shown around each hunk
+const DefaultContextLines = 3
+
+// maxDiffCells bounds the line-matching table; larger files are reported as a
+// full replacement rather than risking excessive memory use
+const maxDiffCells = 4_000_000
+
+// FileChange describes how one file differs between base and head
+type FileChange struct {
+	Path      string     `json:"path"`
+	Kind      ChangeKind `json:"kind"`
+	Additions int        `json:"additions"`
+	Deletions int        `json:"deletions"`
+	Binary    bool       `json:"binary,omitempty"`
+	Diff      string     `json:"diff,omitempty"`
+}
+
+// Result is the comparison of two file sets
+type Result struct {
+	Base      string       `json:"base,omitempty"`
+	Head      string       `json:"head,omitempty"`
+	Changes   []FileChange `json:"changes"`
+	Unchanged int          `json:"unchanged"`
+	Additions int          `json:"additions"`
+	Deletions int          `json:"deletions"`
+	Summary   *Summary     `json:"summary,omitempty"`
+}
+
+// Identical reports whether base and head have the same files and contents
+func (r *Result) Identical() bool {
+	return len(r.Changes) == 0
+}
+
+// Count returns the number of files with the given kind of change
+func (r *Result) Count(kind ChangeKind) int {
+	n := 0
+	for _, c := range r.Changes {
+		if c.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// UnifiedDiff concatenates every file's diff into a single patch
+func (r *Result) UnifiedDiff() string {
+	var b strings.Builder
+	for _, c := range r.Changes {
+		b.WriteString(c.Diff)
+	}
+	return b.String()
+}
+
+// DiffFiles compares two file maps keyed by path
+func DiffFiles(base, head map[string]string, contextLines int) *Result {
+	paths := make(map[string]bool, len(base)+len(head))
+	for p := range base {
+		paths[p] = true
+	}
+	for p := range head {
+		paths[p] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	result := &Result{Changes: []FileChange{}}
+	for _, p := range sorted {
+		before, inBase := base[p]
+		after, inHead := head[p]
+
+		var change FileChange
+		switch {
+		case inBase && inHead && before == after:
+			result.Unchanged++
+			continue
+		case !inBase:
+			change = diffFile(p, "", after, ChangeAdded, contextLines)
+		case !inHead:
+			change = diffFile(p, before, "", ChangeRemoved, contextLines)
+		default:
+			change = diffFile(p, before, after, ChangeModified, contextLines)
+		}
+
+		result.Additions += change.Additions
+		result.Deletions += change.Deletions
+		result.Changes = append(result.Changes, change)
+	}
+	return result
+}
+
+func diffFile(path, before, after string, kind ChangeKind, contextLines int) FileChange {
+	change := FileChange{Path: path, Kind: kind}
+
+	oldName, newName := "a/"+path, "b/"+path
+	if kind == ChangeAdded {
+		oldName = "/dev/null"
+	}
+	if kind == ChangeRemoved {
+		newName = "/dev/null"
+	}
+
+	if isBinary(before) || isBinary(after) {
+		change.Binary = true
+		change.Diff = fmt.Sprintf("Binary files %s and %s differ\n", oldName, newName)
+		return change
+	}
+
+	diff, additions, deletions := UnifiedDiff(oldName, newName, before, after, contextLines)
+	change.Diff = diff
+	change.Additions = additions
+	change.Deletions = deletions
+	return change
+}
+
+func isBinary(s string) bool {
+	return strings.IndexByte(s, 0) >= 0 || !utf8.ValidString(s)
+}
+
+type opKind byte
+
+const (
+	opEqual  opKind = ' '
+	opDelete opKind = '-'
+	opInsert opKind = '+'
+)
+
+type lineOp struct {
+	kind opKind
+	text string
+}
+
+// UnifiedDiff renders the differences between two texts in unified diff format
+// and returns the number of added and deleted lines
+func UnifiedDiff(oldName, newName, before, after string, contextLines int) (string, int, int) {
+	if contextLines < 0 {
+		contextLines = DefaultContextLines
+	}
+	a, b := splitLines(before), splitLines(after)
+	ops := diffLines(a, b)
+
+	additions, deletions := 0, 0
+	for _, op := range ops {
+		switch op.kind {
+		case opInsert:
+			additions++
+		case opDelete:
+			deletions++
+		}
+	}
+	if additions == 0 && deletions == 0 {
+		return "", 0, 0
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	// Walk the edit script, emitting a hunk for each run of changes along with
+	// surrounding context; runs separated by little context are merged
+	oldLine, newLine := 1, 1
+	i := 0
+	for i < len(ops) {
+		if ops[i].kind == opEqual {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+
+		start := i - contextLines
+		if start < 0 {
+			start = 0
+		}
+		hunkOld := oldLine - (i - start)
+		hunkNew := newLine - (i - start)
+
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != opEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == opEqual {
+				run++
+			}
+			if run == len(ops) || run-end > 2*contextLines {
+				end += min(contextLines, run-end)
+				break
+			}
+			end = run
+		}
+
+		oldCount, newCount := 0, 0
+		var body strings.Builder
+		for _, op := range ops[start:end] {
+			switch op.kind {
+			case opEqual:
+				oldCount++
+				newCount++
+			case opDelete:
+				oldCount++
+			case opInsert:
+				newCount++
+			}
+			body.WriteByte(byte(op.kind))
+			body.WriteString(op.text)
+			body.WriteByte('\n')
+		}
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(hunkOld, oldCount), hunkRange(hunkNew, newCount))
+		out.WriteString(body.String())
+
+		for _, op := range ops[i:end] {
+			if op.kind != opInsert {
+				oldLine++
+			}
+			if op.kind != opDelete {
+				newLine++
+			}
+		}
+		i = end
+	}
+
+	return out.String(), additions, deletions
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines computes a line edit script from the longest common subsequence,
+// after trimming the common prefix and suffix
+func diffLines(a, b []string) []lineOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]lineOp, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, lineOp{opEqual, line})
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(midA)*len(midB) > maxDiffCells {
+		for _, line := range midA {
+			ops = append(ops, lineOp{opDelete, line})
+		}
+		for _, line := range midB {
+			ops = append(ops, lineOp{opInsert, line})
+		}
+	} else {
+		ops = append(ops, lcsOps(midA, midB)...)
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, lineOp{opEqual, line})
+	}
+	return ops
+}
+
+func lcsOps(a, b []string) []lineOp {
+	n, m := len(a), len(b)
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := make([]lineOp, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, lineOp{opEqual, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, lineOp{opDelete, a[i]})
+			i++
+		default:
+			ops = append(ops, lineOp{opInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, lineOp{opDelete, a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, lineOp{opInsert, b[j]})
+	}
+	return ops
+}
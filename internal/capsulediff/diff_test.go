@@ -0,0 +1,120 @@
+package capsulediff
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDiffFilesClassifiesChanges(t *testing.T) {
+	base := map[string]string{
+		"main.go":   "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n",
+		"README.md": "# App\n",
+		"old.txt":   "gone\n",
+	}
+	head := map[string]string{
+		"main.go":   "package main\n\nfunc main() {\n\tprintln(\"hello, world\")\n}\n",
+		"README.md": "# App\n",
+		"new.txt":   "fresh\n",
+	}
+
+	result := DiffFiles(base, head, DefaultContextLines)
+	if result.Unchanged != 1 || len(result.Changes) != 3 {
+		t.Fatalf("expected 3 changes and 1 unchanged file, got %+v", result)
+	}
+	if result.Count(ChangeAdded) != 1 || result.Count(ChangeRemoved) != 1 || result.Count(ChangeModified) != 1 {
+		t.Fatalf("unexpected change kinds: %+v", result.Changes)
+	}
+
+	var modified FileChange
+	for _, c := range result.Changes {
+		if c.Path == "main.go" {
+			modified = c
+		}
+	}
+	want := "--- a/main.go\n+++ b/main.go\n@@ -1,5 +1,5 @@\n package main\n \n func main() {\n-\tprintln(\"hello\")\n+\tprintln(\"hello, world\")\n }\n"
+	if modified.Diff != want {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", modified.Diff, want)
+	}
+	if modified.Additions != 1 || modified.Deletions != 1 {
+		t.Errorf("expected +1 -1, got +%d -%d", modified.Additions, modified.Deletions)
+	}
+
+	if !strings.Contains(result.UnifiedDiff(), "--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1 @@\n+fresh\n") {
+		t.Errorf("added file not rendered against /dev/null:\n%s", result.UnifiedDiff())
+	}
+}
+
+func TestUnifiedDiffSplitsDistantHunks(t *testing.T) {
+	var before, after []string
+	for i := 0; i < 30; i++ {
+		line := "line " + string(rune('a'+i%26))
+		before = append(before, line)
+		after = append(after, line)
+	}
+	after[2] = "changed near top"
+	after[27] = "changed near bottom"
+
+	diff, additions, deletions := UnifiedDiff("a/f", "b/f", strings.Join(before, "\n")+"\n", strings.Join(after, "\n")+"\n", 3)
+	if additions != 2 || deletions != 2 {
+		t.Fatalf("expected +2 -2, got +%d -%d", additions, deletions)
+	}
+	if n := strings.Count(diff, "@@ -"); n != 2 {
+		t.Fatalf("expected 2 hunks, got %d:\n%s", n, diff)
+	}
+	if !strings.Contains(diff, "@@ -1,6 +1,6 @@") || !strings.Contains(diff, "@@ -25,6 +25,6 @@") {
+		t.Errorf("unexpected hunk headers:\n%s", diff)
+	}
+}
+
+func TestLoadCapsuleArchiveUsesProjectFiles(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"manifest.json":            `{"version":"1"}`,
+		"metadata.json":            `{"capsule_id":"QL-CAP-1"}`,
+		"reports/quality.json":     `{}`,
+		"project/api/main.go":      "package main\n",
+		"project/api/go.mod":       "module api\n",
+		"project/api/project.json": `{"name":"api"}`,
+		"tasks/task_1.json":        `{"task_id":"task_1","output":"ignored"}`,
+	} {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+
+	files, err := Load(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(files) != 2 || files["main.go"] != "package main\n" || files["go.mod"] != "module api\n" {
+		t.Errorf("unexpected files: %v", files)
+	}
+}
+
+func TestLoadDropJSON(t *testing.T) {
+	files, err := Load([]byte(`{"id":"drop-1","type":"codebase","files":{"app.py":"print(1)\n"}}`))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if files["app.py"] != "print(1)\n" {
+		t.Errorf("unexpected files: %v", files)
+	}
+
+	if _, err := Load([]byte("not a capsule")); err == nil {
+		t.Error("expected an error for unrecognized input")
+	}
+}
+
+func TestCompareWithoutClientUsesFileSummary(t *testing.T) {
+	engine := NewEngine(nil)
+	result := engine.Compare(t.Context(), map[string]string{"a.go": "x\n"}, map[string]string{"a.go": "y\n", "b.go": "z\n"})
+	if result.Summary == nil || result.Summary.Generated != "diff" {
+		t.Fatalf("expected deterministic summary, got %+v", result.Summary)
+	}
+	if result.Summary.Risk != "high" || len(result.Summary.Highlights) != 2 {
+		t.Errorf("unexpected summary: %+v", result.Summary)
+	}
+}
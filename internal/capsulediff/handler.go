@@ -0,0 +1,80 @@
+package capsulediff
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"QLP/internal/audit"
+	"QLP/internal/storage"
+)
+
+// Routes returns the capsule diff endpoint:
+//
+//	GET /capsules/diff?base=<key>&head=<key>[&format=patch]  compares two stored capsules or drops
+//
+// Other tenants' artifacts are not found.
+func Routes(store storage.ArtifactStore, engine *Engine) map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /capsules/diff": diffHandler(store, engine),
+	}
+}
+
+func diffHandler(store storage.ArtifactStore, engine *Engine) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		baseKey, headKey := q.Get("base"), q.Get("head")
+		if baseKey == "" || headKey == "" {
+			http.Error(w, "base and head artifact keys are required", http.StatusBadRequest)
+			return
+		}
+
+		base, status, err := loadArtifact(r, store, baseKey)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		head, status, err := loadArtifact(r, store, headKey)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		result := engine.Compare(r.Context(), base, head)
+		result.Base, result.Head = baseKey, headKey
+
+		if q.Get("format") == "patch" {
+			w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+			io.WriteString(w, result.UnifiedDiff())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	})
+}
+
+func loadArtifact(r *http.Request, store storage.ArtifactStore, key string) (map[string]string, int, error) {
+	rc, artifact, err := store.Open(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, http.StatusNotFound, fmt.Errorf("artifact %s not found", key)
+		}
+		return nil, http.StatusInternalServerError, err
+	}
+	defer rc.Close()
+	if !audit.TenantAllowed(r, artifact.TenantID) {
+		return nil, http.StatusNotFound, fmt.Errorf("artifact %s not found", key)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(rc, 256<<20))
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	files, err := Load(data)
+	if err != nil {
+		return nil, http.StatusUnprocessableEntity, fmt.Errorf("%s: %w", key, err)
+	}
+	return files, http.StatusOK, nil
+}
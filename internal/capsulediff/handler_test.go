@@ -0,0 +1,48 @@
+package capsulediff
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"QLP/internal/audit"
+	"QLP/internal/storage"
+)
+
+func TestDiffScopesToTheAPIKey(t *testing.T) {
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(tenant, capsule, code string) string {
+		drop := `{"id":"` + capsule + `","type":"codebase","files":{"app.py":"` + code + `"}}`
+		a, err := store.Put(context.Background(), tenant, capsule, "drop.json", strings.NewReader(drop))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a.Key
+	}
+	base, head := put("t1", "c1", "print(1)"), put("t1", "c2", "print(2)")
+	other := put("t2", "c3", "print(3)")
+
+	mux := http.NewServeMux()
+	for pattern, h := range Routes(store, NewEngine(nil)) {
+		mux.Handle(pattern, h)
+	}
+	diff := func(base, head string) int {
+		req := httptest.NewRequest(http.MethodGet, "/capsules/diff?base="+base+"&head="+head, nil)
+		req = req.WithContext(audit.WithTenant(req.Context(), "t1"))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := diff(base, head); code != http.StatusOK {
+		t.Errorf("own capsules: %d", code)
+	}
+	if code := diff(base, other); code != http.StatusNotFound {
+		t.Errorf("another tenant's capsule: %d", code)
+	}
+}
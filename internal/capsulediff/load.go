@@ -0,0 +1,192 @@
+package capsulediff
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// maxEntrySize caps how much of a single archive entry is read
+const maxEntrySize = 16 << 20
+
+// capsuleMetadataFiles change on every run and would drown out real changes
+var capsuleMetadataFiles = map[string]bool{
+	"manifest.json": true,
+	"metadata.json": true,
+	"README.md":     true,
+}
+
+// LoadFile reads the project files from a capsule, drop or project directory
+// on disk
+func LoadFile(filename string) (map[string]string, error) {
+	if info, err := os.Stat(filename); err == nil && info.IsDir() {
+		return loadDir(filename)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	return Load(data)
+}
+
+// Load extracts the project files from an exported capsule (.qlcapsule or
+// .zip), a JSON capsule, or a JSON quantum drop
+func Load(data []byte) (map[string]string, error) {
+	if bytes.HasPrefix(data, []byte("PK")) {
+		return loadZip(data)
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return loadJSON(trimmed)
+	}
+	return nil, fmt.Errorf("unrecognized capsule format: expected a zip archive or JSON document")
+}
+
+func loadZip(data []byte) (map[string]string, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open capsule archive: %w", err)
+	}
+
+	project := make(map[string]string)
+	tasks := make(map[string]string)
+	other := make(map[string]string)
+	for _, f := range reader.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		content, err := readEntry(f)
+		if err != nil {
+			return nil, err
+		}
+
+		name := f.Name
+		switch {
+		case strings.HasPrefix(name, "project/"):
+			// project/<name>/<path>; the project name can differ between runs
+			parts := strings.SplitN(name, "/", 3)
+			if len(parts) == 3 && parts[2] != "project.json" {
+				project[parts[2]] = content
+			}
+		case strings.HasPrefix(name, "tasks/") && path.Ext(name) == ".json":
+			var task struct {
+				TaskID string `json:"task_id"`
+				Output string `json:"output"`
+			}
+			if err := json.Unmarshal([]byte(content), &task); err == nil && task.Output != "" {
+				tasks[path.Join("tasks", task.TaskID)] = task.Output
+			}
+		case strings.HasPrefix(name, "reports/") || capsuleMetadataFiles[name]:
+		default:
+			other[name] = content
+		}
+	}
+
+	// Prefer the merged project; older capsules only carry task outputs
+	switch {
+	case len(project) > 0:
+		return project, nil
+	case len(tasks) > 0:
+		return tasks, nil
+	default:
+		return other, nil
+	}
+}
+
+// loadDir reads a project directory, skipping hidden files and directories
+func loadDir(root string) (map[string]string, error) {
+	files := make(map[string]string)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() > maxEntrySize {
+			return fmt.Errorf("%s exceeds %d bytes", p, maxEntrySize)
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = string(content)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", root, err)
+	}
+	return files, nil
+}
+
+func readEntry(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	content, err := io.ReadAll(io.LimitReader(rc, maxEntrySize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	if len(content) > maxEntrySize {
+		return "", fmt.Errorf("%s exceeds %d bytes", f.Name, maxEntrySize)
+	}
+	return string(content), nil
+}
+
+func loadJSON(data []byte) (map[string]string, error) {
+	var doc struct {
+		// QuantumDrop
+		Files map[string]string `json:"files"`
+		// QLCapsule
+		UnifiedProject *struct {
+			Files map[string]string `json:"files"`
+		} `json:"unified_project"`
+		Tasks []struct {
+			TaskID string `json:"task_id"`
+			Output string `json:"output"`
+		} `json:"tasks"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse capsule JSON: %w", err)
+	}
+
+	switch {
+	case doc.Files != nil:
+		return doc.Files, nil
+	case doc.UnifiedProject != nil && len(doc.UnifiedProject.Files) > 0:
+		return doc.UnifiedProject.Files, nil
+	case len(doc.Tasks) > 0:
+		files := make(map[string]string, len(doc.Tasks))
+		for _, task := range doc.Tasks {
+			if task.Output != "" {
+				files[path.Join("tasks", task.TaskID)] = task.Output
+			}
+		}
+		return files, nil
+	default:
+		return nil, fmt.Errorf("document has no files: expected a quantum drop or capsule")
+	}
+}
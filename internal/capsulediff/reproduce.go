@@ -0,0 +1,213 @@
+package capsulediff
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"QLP/internal/llm"
+)
+
+// Verdict summarizes whether a re-run reproduced a capsule
+type Verdict string
+
+const (
+	// VerdictReproduced means both runs produced the same files
+	VerdictReproduced Verdict = "reproduced"
+	// VerdictConditionsDiffer means the files differ and so did the seed,
+	// models or parameters behind them
+	VerdictConditionsDiffer Verdict = "conditions_differ"
+	// VerdictNondeterministic means the files differ although the recorded
+	// conditions match, as providers only make a best effort with seeds
+	VerdictNondeterministic Verdict = "nondeterministic"
+)
+
+// Reproducibility compares a re-run with the capsule it tried to reproduce
+type Reproducibility struct {
+	Base       string          `json:"base,omitempty"`
+	Head       string          `json:"head,omitempty"`
+	Verdict    Verdict         `json:"verdict"`
+	Unchanged  int             `json:"unchanged"`
+	Changed    []FileChange    `json:"changed"` // Without diffs; qlp-diff shows them
+	Conditions []string        `json:"conditions,omitempty"`
+	BaseRun    *llm.Generation `json:"base_generation,omitempty"`
+	HeadRun    *llm.Generation `json:"head_generation,omitempty"`
+}
+
+// CompareRuns compares the files of two runs of the same intent and
+// explains any difference by what was recorded about their generation
+func CompareRuns(base, head map[string]string, baseRun, headRun *llm.Generation) *Reproducibility {
+	files := DiffFiles(base, head, 0)
+	r := &Reproducibility{
+		Unchanged:  files.Unchanged,
+		Changed:    make([]FileChange, len(files.Changes)),
+		Conditions: compareGenerations(baseRun, headRun),
+		BaseRun:    baseRun,
+		HeadRun:    headRun,
+	}
+	for i, c := range files.Changes {
+		c.Diff = ""
+		r.Changed[i] = c
+	}
+	switch {
+	case files.Identical():
+		r.Verdict = VerdictReproduced
+	case len(r.Conditions) > 0:
+		r.Verdict = VerdictConditionsDiffer
+	default:
+		r.Verdict = VerdictNondeterministic
+	}
+	return r
+}
+
+// compareGenerations lists how the recorded conditions of two runs differ,
+// in a stable order
+func compareGenerations(base, head *llm.Generation) []string {
+	var conditions []string
+	for _, run := range []struct {
+		name string
+		gen  *llm.Generation
+	}{{"base", base}, {"head", head}} {
+		switch {
+		case run.gen == nil:
+			conditions = append(conditions, run.name+" has no generation record")
+		case run.gen.Seed == nil:
+			conditions = append(conditions, run.name+" was generated without a seed")
+		case !run.gen.Seeded:
+			conditions = append(conditions, run.name+" was partly answered by a provider that ignores seeds")
+		}
+	}
+	if base == nil || head == nil {
+		return conditions
+	}
+	if base.Seed != nil && head.Seed != nil && *base.Seed != *head.Seed {
+		conditions = append(conditions, fmt.Sprintf("seed %d differs from %d", *head.Seed, *base.Seed))
+	}
+
+	baseUses, headUses := modelUses(base), modelUses(head)
+	names := make([]string, 0, len(baseUses)+len(headUses))
+	for name := range baseUses {
+		names = append(names, name)
+	}
+	for name := range headUses {
+		if _, ok := baseUses[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b, inBase := baseUses[name]
+		h, inHead := headUses[name]
+		switch {
+		case !inHead:
+			conditions = append(conditions, name+" answered only the base run")
+		case !inBase:
+			conditions = append(conditions, name+" answered only the head run")
+		default:
+			if b.versions != h.versions {
+				conditions = append(conditions, fmt.Sprintf("%s model version %s differs from %s", name, h.versions, b.versions))
+			}
+			if b.fingerprints != h.fingerprints {
+				conditions = append(conditions, fmt.Sprintf("%s backend fingerprint %s differs from %s", name, h.fingerprints, b.fingerprints))
+			}
+			if b.parameters != h.parameters {
+				conditions = append(conditions, fmt.Sprintf("%s parameters %s differ from %s", name, h.parameters, b.parameters))
+			}
+		}
+	}
+	return conditions
+}
+
+// modelUse is what a run recorded for one provider and model, each field
+// joining the distinct values seen
+type modelUse struct {
+	versions, fingerprints, parameters string
+}
+
+func modelUses(g *llm.Generation) map[string]modelUse {
+	type seen struct{ versions, fingerprints, parameters map[string]bool }
+	byName := make(map[string]*seen)
+	for _, use := range g.Models {
+		name := use.Provider
+		if use.Model != "" {
+			name += "/" + use.Model
+		}
+		s, ok := byName[name]
+		if !ok {
+			s = &seen{make(map[string]bool), make(map[string]bool), make(map[string]bool)}
+			byName[name] = s
+		}
+		params, _ := json.Marshal(use.Parameters)
+		s.versions[use.ModelVersion] = true
+		s.fingerprints[use.Fingerprint] = true
+		s.parameters[string(params)] = true
+	}
+
+	uses := make(map[string]modelUse, len(byName))
+	for name, s := range byName {
+		uses[name] = modelUse{versions: joinSet(s.versions), fingerprints: joinSet(s.fingerprints), parameters: joinSet(s.parameters)}
+	}
+	return uses
+}
+
+func joinSet(set map[string]bool) string {
+	values := make([]string, 0, len(set))
+	for v := range set {
+		if v == "" {
+			v = "(none)"
+		}
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+// LoadGeneration reads the generation record from an exported or JSON
+// capsule on disk; drops, directories and older capsules have none
+func LoadGeneration(filename string) (*llm.Generation, error) {
+	if info, err := os.Stat(filename); err == nil && info.IsDir() {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+
+	var metadata struct {
+		Generation *llm.Generation `json:"generation"`
+	}
+	if bytes.HasPrefix(data, []byte("PK")) {
+		reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open capsule archive: %w", err)
+		}
+		for _, f := range reader.File {
+			if f.Name != "metadata.json" {
+				continue
+			}
+			content, err := readEntry(f)
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal([]byte(content), &metadata); err != nil {
+				return nil, fmt.Errorf("failed to parse capsule metadata: %w", err)
+			}
+		}
+		return metadata.Generation, nil
+	}
+
+	var capsule struct {
+		Metadata *json.RawMessage `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &capsule); err != nil || capsule.Metadata == nil {
+		return nil, nil
+	}
+	if err := json.Unmarshal(*capsule.Metadata, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse capsule metadata: %w", err)
+	}
+	return metadata.Generation, nil
+}
@@ -0,0 +1,82 @@
+package capsulediff
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"QLP/internal/llm"
+)
+
+func seededRun(seed int, version string) *llm.Generation {
+	return &llm.Generation{
+		Seed:   &seed,
+		Seeded: true,
+		Models: []llm.ModelUse{{
+			Provider:     "azure_openai",
+			Model:        "gpt-4",
+			ModelVersion: version,
+			Fingerprint:  "fp_44709d6fcb",
+			Parameters:   map[string]interface{}{"seed": seed, "temperature": 0.2},
+			Calls:        7,
+		}},
+	}
+}
+
+func TestCompareRunsExplainsDifferences(t *testing.T) {
+	files := map[string]string{"main.go": "package main\n"}
+	changed := map[string]string{"main.go": "package main\n\nfunc main() {}\n"}
+
+	r := CompareRuns(files, files, seededRun(42, "gpt-4-0613"), seededRun(42, "gpt-4-0613"))
+	if r.Verdict != VerdictReproduced || r.Unchanged != 1 || len(r.Conditions) != 0 {
+		t.Errorf("identical runs = %+v", r)
+	}
+
+	r = CompareRuns(files, changed, seededRun(42, "gpt-4-0613"), seededRun(42, "gpt-4-0613"))
+	if r.Verdict != VerdictNondeterministic || len(r.Changed) != 1 || r.Changed[0].Diff != "" {
+		t.Errorf("same conditions, different files = %+v", r)
+	}
+
+	r = CompareRuns(files, changed, seededRun(42, "gpt-4-0613"), seededRun(7, "gpt-4-1106-preview"))
+	want := []string{
+		"seed 7 differs from 42",
+		`azure_openai/gpt-4 model version ["gpt-4-1106-preview"] differs from ["gpt-4-0613"]`,
+		`azure_openai/gpt-4 parameters ["{\"seed\":7,\"temperature\":0.2}"] differ from ["{\"seed\":42,\"temperature\":0.2}"]`,
+	}
+	if r.Verdict != VerdictConditionsDiffer || strings.Join(r.Conditions, "\n") != strings.Join(want, "\n") {
+		t.Errorf("conditions = %q", r.Conditions)
+	}
+
+	r = CompareRuns(files, changed, nil, &llm.Generation{})
+	if strings.Join(r.Conditions, "\n") != "base has no generation record\nhead was generated without a seed" {
+		t.Errorf("unseeded conditions = %q", r.Conditions)
+	}
+}
+
+func TestLoadGenerationFromCapsules(t *testing.T) {
+	dir := t.TempDir()
+	metadata, _ := json.Marshal(map[string]interface{}{"generation": seededRun(42, "gpt-4-0613")})
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	w, _ := zw.Create("metadata.json")
+	w.Write(metadata)
+	zw.Close()
+	zipFile := filepath.Join(dir, "capsule.qlcapsule")
+	jsonFile := filepath.Join(dir, "capsule.json")
+	os.WriteFile(zipFile, archive.Bytes(), 0644)
+	os.WriteFile(jsonFile, []byte(`{"metadata":`+string(metadata)+`}`), 0644)
+
+	for _, file := range []string{zipFile, jsonFile} {
+		g, err := LoadGeneration(file)
+		if err != nil || g == nil || *g.Seed != 42 || g.Models[0].ModelVersion != "gpt-4-0613" {
+			t.Errorf("%s: generation = %+v, %v", file, g, err)
+		}
+	}
+	if g, err := LoadGeneration(dir); g != nil || err != nil {
+		t.Errorf("directory: generation = %+v, %v", g, err)
+	}
+}
@@ -0,0 +1,132 @@
+package capsulediff
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"QLP/internal/llm"
+	"QLP/internal/logger"
+	"go.uber.org/zap"
+)
+
+// maxPromptDiff bounds how much of the patch is sent to the model; the file
+// list is always included in full
+const maxPromptDiff = 24000
+
+// Summary is a human-readable account of what changed between two runs
+type Summary struct {
+	Headline   string   `json:"headline"`
+	Highlights []string `json:"highlights"`
+	Risk       string   `json:"risk"`
+	Generated  string   `json:"generated_by"`
+}
+
+// Engine diffs capsules and summarizes the changes, using an LLM when one is
+// configured and a deterministic summary otherwise
+type Engine struct {
+	client       llm.Client
+	contextLines int
+}
+
+func NewEngine(client llm.Client) *Engine {
+	return &Engine{client: client, contextLines: DefaultContextLines}
+}
+
+// SetContextLines changes the number of unchanged lines shown around hunks
+func (e *Engine) SetContextLines(n int) {
+	e.contextLines = n
+}
+
+// Compare diffs two file sets and attaches a change summary
+func (e *Engine) Compare(ctx context.Context, base, head map[string]string) *Result {
+	result := DiffFiles(base, head, e.contextLines)
+	result.Summary = DescribeChanges(result)
+
+	if e.client == nil || result.Identical() {
+		return result
+	}
+
+	summary, err := Summarize(ctx, e.client, result)
+	if err != nil {
+		logger.WithComponent("capsulediff").Warn("Semantic change summary failed, using file-level summary",
+			zap.Error(err))
+		return result
+	}
+	result.Summary = summary
+	return result
+}
+
+// Summarize asks the model to explain the change set in plain language
+func Summarize(ctx context.Context, client llm.Client, result *Result) (*Summary, error) {
+	var files strings.Builder
+	for _, c := range result.Changes {
+		fmt.Fprintf(&files, "- %s (%s, +%d -%d)\n", c.Path, c.Kind, c.Additions, c.Deletions)
+	}
+
+	patch := result.UnifiedDiff()
+	if len(patch) > maxPromptDiff {
+		patch = patch[:maxPromptDiff] + "\n... (diff truncated)\n"
+	}
+
+	prompt := fmt.Sprintf(`Two versions of a generated software project were produced for the same intent. Explain what changed from the base version to the head version for a developer reviewing the regeneration.
+
+Changed files:
+%s
+Unified diff:
+%s
+
+Respond with JSON: {"headline": "<one sentence>", "highlights": ["<behavioural or structural change>", ...], "risk": "low|medium|high"}
+Focus on behaviour, APIs, dependencies and configuration rather than formatting.`, files.String(), patch)
+
+	var summary Summary
+	schema := llm.SchemaFor("capsule_diff_summary", "Summary of changes between two generated projects", summary)
+	if err := llm.CompleteJSON(ctx, client, prompt, schema, &summary); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(summary.Headline) == "" {
+		return nil, fmt.Errorf("model returned an empty summary")
+	}
+	summary.Generated = "llm"
+	return &summary, nil
+}
+
+// DescribeChanges builds a summary from the file-level changes alone
+func DescribeChanges(result *Result) *Summary {
+	if result.Identical() {
+		return &Summary{
+			Headline:  fmt.Sprintf("No changes across %d files", result.Unchanged),
+			Risk:      "low",
+			Generated: "diff",
+		}
+	}
+
+	summary := &Summary{
+		Headline: fmt.Sprintf("%d files changed (%d added, %d removed, %d modified), +%d -%d lines",
+			len(result.Changes), result.Count(ChangeAdded), result.Count(ChangeRemoved), result.Count(ChangeModified),
+			result.Additions, result.Deletions),
+		Generated: "diff",
+	}
+	for _, c := range result.Changes {
+		switch c.Kind {
+		case ChangeAdded:
+			summary.Highlights = append(summary.Highlights, fmt.Sprintf("Added %s", c.Path))
+		case ChangeRemoved:
+			summary.Highlights = append(summary.Highlights, fmt.Sprintf("Removed %s", c.Path))
+		default:
+			summary.Highlights = append(summary.Highlights, fmt.Sprintf("Modified %s (+%d -%d)", c.Path, c.Additions, c.Deletions))
+		}
+	}
+
+	// Without semantic analysis, judge risk by the share of the project touched
+	total := len(result.Changes) + result.Unchanged
+	switch {
+	case result.Count(ChangeRemoved) > 0 || len(result.Changes)*2 > total:
+		summary.Risk = "high"
+	case len(result.Changes)*5 > total:
+		summary.Risk = "medium"
+	default:
+		summary.Risk = "low"
+	}
+	return summary
+}
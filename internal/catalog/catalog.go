@@ -0,0 +1,182 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"QLP/internal/packaging"
+	"QLP/internal/storage"
+)
+
+// ErrNotFound is returned for capsules without a catalog entity
+var ErrNotFound = errors.New("service not found")
+
+// Service is a generated service as listed by the catalog API
+type Service struct {
+	CapsuleID   string    `json:"capsule_id"`
+	TenantID    string    `json:"tenant_id"`
+	Name        string    `json:"name"`
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Owner       string    `json:"owner"`
+	Lifecycle   string    `json:"lifecycle"`
+	TechStack   []string  `json:"tech_stack"`
+	Health      Health    `json:"health"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Entity      *Entity   `json:"entity"`
+}
+
+// Catalog reads the entities stored next to each capsule in artifact storage
+type Catalog struct {
+	store storage.ArtifactStore
+}
+
+// New creates a catalog over an artifact store
+func New(store storage.ArtifactStore) *Catalog {
+	return &Catalog{store: store}
+}
+
+// List returns the services of a tenant, or of all tenants when tenantID
+// is empty, newest first
+func (c *Catalog) List(ctx context.Context, tenantID string) ([]Service, error) {
+	artifacts, err := c.store.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	services := []Service{}
+	for _, a := range artifacts {
+		if a.Name != packaging.CatalogInfoFile || (tenantID != "" && a.TenantID != tenantID) {
+			continue
+		}
+		entity, err := c.read(ctx, a.Key)
+		if err != nil {
+			// One unreadable entity should not hide the rest of the catalog
+			continue
+		}
+		services = append(services, service(a, entity))
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].GeneratedAt.After(services[j].GeneratedAt)
+	})
+	return services, nil
+}
+
+// Get returns the service generated as capsuleID
+func (c *Catalog) Get(ctx context.Context, capsuleID string) (*Service, error) {
+	a, err := c.artifact(ctx, capsuleID)
+	if err != nil {
+		return nil, err
+	}
+	entity, err := c.read(ctx, a.Key)
+	if err != nil {
+		return nil, err
+	}
+	s := service(*a, entity)
+	return &s, nil
+}
+
+// Entities returns the latest entity of each of a tenant's services for
+// Backstage to ingest; regenerating a service replaces its entity
+func (c *Catalog) Entities(ctx context.Context, tenantID string) ([]*Entity, error) {
+	services, err := c.List(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	var entities []*Entity
+	seen := make(map[string]bool)
+	for _, s := range services {
+		key := s.Entity.Metadata.Namespace + "/" + s.Entity.Metadata.Name
+		if !seen[key] {
+			seen[key] = true
+			entities = append(entities, s.Entity)
+		}
+	}
+	return entities, nil
+}
+
+// RecordValidation updates a service's health after it was validated again
+func (c *Catalog) RecordValidation(ctx context.Context, capsuleID string, score int, passed bool) error {
+	return c.update(ctx, capsuleID, func(e *Entity) {
+		e.SetValidation(score, passed, time.Now())
+	})
+}
+
+// RecordDrift updates a service's health after its validation environment
+// was checked for drift
+func (c *Catalog) RecordDrift(ctx context.Context, capsuleID, status string, at time.Time) error {
+	return c.update(ctx, capsuleID, func(e *Entity) {
+		e.SetDrift(status, at)
+	})
+}
+
+func (c *Catalog) update(ctx context.Context, capsuleID string, fn func(*Entity)) error {
+	a, err := c.artifact(ctx, capsuleID)
+	if err != nil {
+		return err
+	}
+	entity, err := c.read(ctx, a.Key)
+	if err != nil {
+		return err
+	}
+	fn(entity)
+	data, err := entity.YAML()
+	if err != nil {
+		return err
+	}
+	_, err = c.store.Put(ctx, a.TenantID, a.CapsuleID, a.Name, bytes.NewReader(data))
+	return err
+}
+
+func (c *Catalog) artifact(ctx context.Context, capsuleID string) (*storage.Artifact, error) {
+	artifacts, err := c.store.List(ctx, capsuleID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range artifacts {
+		if artifacts[i].Name == packaging.CatalogInfoFile {
+			return &artifacts[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, capsuleID)
+}
+
+func (c *Catalog) read(ctx context.Context, key string) (*Entity, error) {
+	rc, _, err := c.store.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+func service(a storage.Artifact, e *Entity) Service {
+	s := Service{
+		CapsuleID:   a.CapsuleID,
+		TenantID:    a.TenantID,
+		Name:        e.Metadata.Name,
+		Title:       e.Metadata.Title,
+		Description: e.Metadata.Description,
+		Owner:       e.Spec.Owner,
+		Lifecycle:   e.Spec.Lifecycle,
+		TechStack:   e.Metadata.Tags,
+		Health:      e.Health(),
+		GeneratedAt: a.CreatedAt,
+		Entity:      e,
+	}
+	if at, err := time.Parse(time.RFC3339, e.Metadata.Annotations[AnnotationGeneratedAt]); err == nil && at.Year() > 1 {
+		s.GeneratedAt = at
+	}
+	if s.TechStack == nil {
+		s.TechStack = []string{}
+	}
+	return s
+}
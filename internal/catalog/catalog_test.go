@@ -0,0 +1,196 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"QLP/internal/packaging"
+	"QLP/internal/storage"
+	"QLP/internal/types"
+
+	"gopkg.in/yaml.v3"
+)
+
+func testCapsule(id, name string, score int, passed bool) *packaging.QLCapsule {
+	return &packaging.QLCapsule{
+		Metadata: packaging.CapsuleMetadata{
+			CapsuleID:    id,
+			IntentID:     "QLI-1",
+			IntentText:   "Create an orders REST API",
+			CompletedAt:  time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+			OverallScore: score,
+		},
+		ValidationResults: []types.ValidationResult{{OverallScore: score, Passed: passed}},
+		UnifiedProject: &packaging.UnifiedProject{
+			Name:        name,
+			Description: "Orders service",
+			Files: map[string]string{
+				"go.mod":     "module orders\n\ngo 1.22\n",
+				"main.go":    "package main\n",
+				"handler.go": "package main\n",
+				"Dockerfile": "FROM golang:1.22\n",
+				"web/app.ts": "export {}\n",
+			},
+		},
+	}
+}
+
+func TestFromCapsule(t *testing.T) {
+	e := FromCapsule(testCapsule("QL-CAP-1", "Orders API", 85, true), "acme", DefaultOptions())
+
+	if e.Metadata.Name != "orders-api" || e.Metadata.Namespace != "acme" {
+		t.Errorf("name = %s/%s", e.Metadata.Namespace, e.Metadata.Name)
+	}
+	if e.Spec.Owner != "group:acme" || e.Spec.Lifecycle != "experimental" || e.Spec.Type != "service" {
+		t.Errorf("spec = %+v", e.Spec)
+	}
+	if got := strings.Join(e.Metadata.Tags, ","); got != "go,typescript,go-1-22,docker" {
+		t.Errorf("tags = %s", got)
+	}
+	if e.Metadata.Annotations[AnnotationCapsuleID] != "QL-CAP-1" {
+		t.Errorf("annotations = %v", e.Metadata.Annotations)
+	}
+	h := e.Health()
+	if h.Status != StatusPassing || h.Score == nil || *h.Score != 85 {
+		t.Errorf("health = %+v", h)
+	}
+
+	failing := FromCapsule(testCapsule("QL-CAP-2", "Orders API", 85, false), "acme", DefaultOptions())
+	if failing.Health().Status != StatusFailing {
+		t.Errorf("failed validation reported as %s", failing.Health().Status)
+	}
+}
+
+func TestEntityYAMLRoundTrip(t *testing.T) {
+	e := FromCapsule(testCapsule("QL-CAP-1", "Orders API", 85, true), "", DefaultOptions())
+	data, err := e.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("apiVersion: backstage.io/v1alpha1\nkind: Component\n")) {
+		t.Errorf("unexpected document:\n%s", data)
+	}
+	parsed, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Metadata.Namespace != "default" || parsed.Health().Status != StatusPassing {
+		t.Errorf("parsed = %+v", parsed.Metadata)
+	}
+	if _, err := Parse([]byte("foo: bar\n")); err == nil {
+		t.Error("expected an error for a document without kind and name")
+	}
+}
+
+func storeEntity(t *testing.T, store storage.ArtifactStore, tenant string, capsule *packaging.QLCapsule) {
+	data, err := FromCapsule(capsule, tenant, DefaultOptions()).YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Put(context.Background(), tenant, capsule.Metadata.CapsuleID, packaging.CatalogInfoFile, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	older := testCapsule("QL-CAP-1", "Orders API", 60, true)
+	newer := testCapsule("QL-CAP-2", "Orders API", 90, true)
+	newer.Metadata.CompletedAt = older.Metadata.CompletedAt.Add(time.Hour)
+	storeEntity(t, store, "acme", older)
+	storeEntity(t, store, "acme", newer)
+	storeEntity(t, store, "globex", testCapsule("QL-CAP-3", "Billing", 80, true))
+	store.Put(ctx, "acme", "QL-CAP-1", "capsule.qlcapsule", strings.NewReader("zip"))
+
+	c := New(store)
+	services, err := c.List(ctx, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 2 || services[0].CapsuleID != "QL-CAP-2" {
+		t.Fatalf("services = %+v", services)
+	}
+	if services[1].Health.Status != StatusFailing {
+		t.Errorf("score 60 reported as %s", services[1].Health.Status)
+	}
+	all, _ := c.List(ctx, "")
+	if len(all) != 3 {
+		t.Errorf("all tenants = %d services", len(all))
+	}
+
+	entities, err := c.Entities(ctx, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 1 || entities[0].Metadata.Annotations[AnnotationCapsuleID] != "QL-CAP-2" {
+		t.Errorf("entities = %d, want the latest orders-api only", len(entities))
+	}
+
+	if err := c.RecordValidation(ctx, "QL-CAP-1", 95, true); err != nil {
+		t.Fatal(err)
+	}
+	s, err := c.Get(ctx, "QL-CAP-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Health.Status != StatusPassing || *s.Health.Score != 95 {
+		t.Errorf("health after revalidation = %+v", s.Health)
+	}
+
+	checked := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	if err := c.RecordDrift(ctx, "QL-CAP-1", "drifted", checked); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ = c.Get(ctx, "QL-CAP-1"); s.Health.Drift != "drifted" || !s.Health.DriftCheckedAt.Equal(checked) || *s.Health.Score != 95 {
+		t.Errorf("health after drift check = %+v", s.Health)
+	}
+	if _, err := c.Get(ctx, "QL-CAP-404"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing capsule: %v", err)
+	}
+}
+
+func TestEntitiesHandler(t *testing.T) {
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	storeEntity(t, store, "acme", testCapsule("QL-CAP-1", "Orders API", 85, true))
+	storeEntity(t, store, "acme", testCapsule("QL-CAP-2", "Billing", 85, true))
+	routes := Routes(New(store))
+
+	rec := httptest.NewRecorder()
+	routes["GET /catalog/entities.yaml"].ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/catalog/entities.yaml?tenant=acme", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	dec := yaml.NewDecoder(rec.Body)
+	docs := 0
+	for {
+		var e Entity
+		if err := dec.Decode(&e); err != nil {
+			break
+		}
+		docs++
+	}
+	if docs != 2 {
+		t.Errorf("documents = %d", docs)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/catalog/services/QL-CAP-404", nil)
+	req.SetPathValue("id", "QL-CAP-404")
+	rec = httptest.NewRecorder()
+	routes["GET /catalog/services/{id}"].ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing service status = %d", rec.Code)
+	}
+}
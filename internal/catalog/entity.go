@@ -0,0 +1,288 @@
+// Package catalog describes generated services as Backstage catalog
+// entities: every capsule ships a catalog-info.yaml, and the catalog API
+// lists the services produced per tenant for developer portals to ingest.
+package catalog
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"QLP/internal/config"
+	"QLP/internal/importer"
+	"QLP/internal/packaging"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Annotations QLP sets on the entities it generates
+const (
+	AnnotationCapsuleID   = "qlp.dev/capsule-id"
+	AnnotationIntentID    = "qlp.dev/intent-id"
+	AnnotationTenant      = "qlp.dev/tenant"
+	AnnotationGeneratedAt = "qlp.dev/generated-at"
+	AnnotationScore       = "qlp.dev/validation-score"
+	AnnotationStatus      = "qlp.dev/validation-status"
+	AnnotationValidatedAt = "qlp.dev/validated-at"
+	AnnotationDrift       = "qlp.dev/drift-status"
+	AnnotationDriftAt     = "qlp.dev/drift-checked-at"
+)
+
+// Validation health of a service
+const (
+	StatusPassing = "passing"
+	StatusFailing = "failing"
+	StatusUnknown = "unknown"
+)
+
+// Entity is a Backstage catalog entity (backstage.io/v1alpha1)
+type Entity struct {
+	APIVersion string   `yaml:"apiVersion" json:"apiVersion"`
+	Kind       string   `yaml:"kind" json:"kind"`
+	Metadata   Metadata `yaml:"metadata" json:"metadata"`
+	Spec       Spec     `yaml:"spec" json:"spec"`
+}
+
+// Metadata identifies and describes an entity
+type Metadata struct {
+	Name        string            `yaml:"name" json:"name"`
+	Namespace   string            `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	Title       string            `yaml:"title,omitempty" json:"title,omitempty"`
+	Description string            `yaml:"description,omitempty" json:"description,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty" json:"annotations,omitempty"`
+	Tags        []string          `yaml:"tags,omitempty" json:"tags,omitempty"`
+}
+
+// Spec is the component spec
+type Spec struct {
+	Type      string `yaml:"type" json:"type"`
+	Lifecycle string `yaml:"lifecycle" json:"lifecycle"`
+	Owner     string `yaml:"owner" json:"owner"`
+	System    string `yaml:"system,omitempty" json:"system,omitempty"`
+}
+
+// Options control the generated entities
+type Options struct {
+	Owner     string // entity owner, default group:<tenant>
+	Lifecycle string // Backstage lifecycle, default experimental
+	System    string // optional system the services belong to
+	MinScore  int    // validation score a passing service needs
+}
+
+// DefaultOptions marks services experimental and passing from a score of 70
+func DefaultOptions() Options {
+	return Options{Lifecycle: "experimental", MinScore: 70}
+}
+
+// OptionsFromEnv reads QLP_CATALOG_OWNER, QLP_CATALOG_LIFECYCLE,
+// QLP_CATALOG_SYSTEM and QLP_CATALOG_MIN_SCORE
+func OptionsFromEnv() Options {
+	opts := DefaultOptions()
+	opts.Owner = config.GetEnvOrDefault("QLP_CATALOG_OWNER", "")
+	opts.Lifecycle = config.GetEnvOrDefault("QLP_CATALOG_LIFECYCLE", opts.Lifecycle)
+	opts.System = config.GetEnvOrDefault("QLP_CATALOG_SYSTEM", "")
+	if v, err := strconv.Atoi(config.GetEnvOrDefault("QLP_CATALOG_MIN_SCORE", "")); err == nil && v >= 0 {
+		opts.MinScore = v
+	}
+	return opts
+}
+
+// FromCapsule describes the capsule's service. The namespace is the tenant,
+// so the default tenant lands in Backstage's default namespace.
+func FromCapsule(capsule *packaging.QLCapsule, tenantID string, opts Options) *Entity {
+	meta := capsule.Metadata
+	name := packaging.ProjectName(meta.IntentText)
+	description := meta.IntentText
+	files := map[string]string{}
+	if project := capsule.UnifiedProject; project != nil {
+		if project.Name != "" {
+			name = project.Name
+		}
+		if project.Description != "" {
+			description = project.Description
+		}
+		files = project.Files
+	}
+	if tenantID == "" {
+		tenantID = "default"
+	}
+	owner := opts.Owner
+	if owner == "" {
+		owner = "group:" + entityName(tenantID)
+	}
+	lifecycle := opts.Lifecycle
+	if lifecycle == "" {
+		lifecycle = DefaultOptions().Lifecycle
+	}
+
+	e := &Entity{
+		APIVersion: "backstage.io/v1alpha1",
+		Kind:       "Component",
+		Metadata: Metadata{
+			Name:        entityName(name),
+			Namespace:   entityName(tenantID),
+			Title:       name,
+			Description: description,
+			Annotations: map[string]string{
+				AnnotationCapsuleID:   meta.CapsuleID,
+				AnnotationIntentID:    meta.IntentID,
+				AnnotationTenant:      tenantID,
+				AnnotationGeneratedAt: meta.CompletedAt.UTC().Format(time.RFC3339),
+			},
+			Tags: TechStack(files),
+		},
+		Spec: Spec{Type: "service", Lifecycle: lifecycle, Owner: owner, System: opts.System},
+	}
+
+	validatedAt := meta.CompletedAt
+	passed := true
+	for _, v := range capsule.ValidationResults {
+		passed = passed && v.Passed
+		if v.ValidatedAt.After(validatedAt) {
+			validatedAt = v.ValidatedAt
+		}
+	}
+	if len(capsule.ValidationResults) == 0 && meta.OverallScore == 0 {
+		e.Metadata.Annotations[AnnotationStatus] = StatusUnknown
+	} else {
+		e.SetValidation(meta.OverallScore, passed && meta.OverallScore >= opts.MinScore, validatedAt)
+	}
+	return e
+}
+
+// SetValidation records the outcome of the service's latest validation
+func (e *Entity) SetValidation(score int, passed bool, at time.Time) {
+	if e.Metadata.Annotations == nil {
+		e.Metadata.Annotations = make(map[string]string)
+	}
+	status := StatusFailing
+	if passed {
+		status = StatusPassing
+	}
+	e.Metadata.Annotations[AnnotationScore] = strconv.Itoa(score)
+	e.Metadata.Annotations[AnnotationStatus] = status
+	e.Metadata.Annotations[AnnotationValidatedAt] = at.UTC().Format(time.RFC3339)
+}
+
+// SetDrift records the outcome of the latest drift check of the service's
+// validation environment
+func (e *Entity) SetDrift(status string, at time.Time) {
+	if e.Metadata.Annotations == nil {
+		e.Metadata.Annotations = make(map[string]string)
+	}
+	e.Metadata.Annotations[AnnotationDrift] = status
+	e.Metadata.Annotations[AnnotationDriftAt] = at.UTC().Format(time.RFC3339)
+}
+
+// Health is the service's latest validation and drift check
+type Health struct {
+	Status         string     `json:"status"`
+	Score          *int       `json:"score,omitempty"`
+	ValidatedAt    *time.Time `json:"validated_at,omitempty"`
+	Drift          string     `json:"drift,omitempty"`
+	DriftCheckedAt *time.Time `json:"drift_checked_at,omitempty"`
+}
+
+// Health reads the validation and drift annotations
+func (e *Entity) Health() Health {
+	a := e.Metadata.Annotations
+	h := Health{Status: a[AnnotationStatus]}
+	if h.Status == "" {
+		h.Status = StatusUnknown
+	}
+	if score, err := strconv.Atoi(a[AnnotationScore]); err == nil {
+		h.Score = &score
+	}
+	if at, err := time.Parse(time.RFC3339, a[AnnotationValidatedAt]); err == nil {
+		h.ValidatedAt = &at
+	}
+	h.Drift = a[AnnotationDrift]
+	if at, err := time.Parse(time.RFC3339, a[AnnotationDriftAt]); err == nil {
+		h.DriftCheckedAt = &at
+	}
+	return h
+}
+
+// YAML renders the entity as a catalog-info.yaml document
+func (e *Entity) YAML() ([]byte, error) {
+	return yaml.Marshal(e)
+}
+
+// Parse reads a catalog-info.yaml document
+func Parse(data []byte) (*Entity, error) {
+	var e Entity
+	if err := yaml.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("invalid catalog entity: %w", err)
+	}
+	if e.Kind == "" || e.Metadata.Name == "" {
+		return nil, fmt.Errorf("invalid catalog entity: missing kind or metadata.name")
+	}
+	return &e, nil
+}
+
+var (
+	nonName = regexp.MustCompile(`[^a-z0-9]+`)
+	nonTag  = regexp.MustCompile(`[^a-z0-9+#]+`)
+)
+
+// entityName makes a valid Backstage name of at most 63 characters
+func entityName(s string) string {
+	name := strings.Trim(nonName.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-")
+	}
+	if name == "" {
+		name = "service"
+	}
+	return name
+}
+
+// TechStack lists a project's languages, most files first, followed by
+// its runtimes and infrastructure, as Backstage tags
+func TechStack(files map[string]string) []string {
+	inv := importer.Survey(files)
+	languages := make([]string, 0, len(inv.Languages))
+	for lang := range inv.Languages {
+		languages = append(languages, lang)
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		if inv.Languages[languages[i]] != inv.Languages[languages[j]] {
+			return inv.Languages[languages[i]] > inv.Languages[languages[j]]
+		}
+		return languages[i] < languages[j]
+	})
+
+	var tags []string
+	seen := make(map[string]bool)
+	add := func(tag string) {
+		tag = strings.Trim(nonTag.ReplaceAllString(strings.ToLower(tag), "-"), "-")
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	for _, lang := range languages {
+		add(lang)
+	}
+	runtimes := make([]string, 0, len(inv.Runtimes))
+	for _, runtime := range inv.Runtimes {
+		runtimes = append(runtimes, runtime)
+	}
+	sort.Strings(runtimes)
+	for _, runtime := range runtimes {
+		add(runtime)
+	}
+	if len(inv.Dockerfiles) > 0 {
+		add("docker")
+	}
+	if len(inv.Terraform) > 0 {
+		add("terraform")
+	}
+	if len(inv.Kubernetes) > 0 {
+		add("kubernetes")
+	}
+	return tags
+}
@@ -0,0 +1,81 @@
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"QLP/internal/audit"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Routes returns the catalog endpoints:
+//
+//	GET /catalog/services?tenant=         lists generated services with owner, tech stack and health
+//	GET /catalog/services/{id}            returns the service generated as a capsule
+//	GET /catalog/entities.yaml?tenant=    the latest entity per service as multi-document YAML,
+//	                                      for a Backstage url location
+func Routes(c *Catalog) map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /catalog/services":      listHandler(c),
+		"GET /catalog/services/{id}": getHandler(c),
+		"GET /catalog/entities.yaml": entitiesHandler(c),
+	}
+}
+
+func listHandler(c *Catalog) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		services, err := c.List(r.Context(), audit.RequestTenant(r))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"services": services,
+		})
+	})
+}
+
+func getHandler(c *Catalog) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, err := c.Get(r.Context(), r.PathValue("id"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, s)
+	})
+}
+
+func entitiesHandler(c *Catalog) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entities, err := c.Entities(r.Context(), audit.RequestTenant(r))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		enc := yaml.NewEncoder(w)
+		for _, e := range entities {
+			if err := enc.Encode(e); err != nil {
+				return
+			}
+		}
+		enc.Close()
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, ErrNotFound) {
+		status = http.StatusNotFound
+	}
+	http.Error(w, err.Error(), status)
+}
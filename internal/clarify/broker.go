@@ -0,0 +1,195 @@
+package clarify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSessionNotFound = errors.New("clarification session not found")
+	ErrSessionClosed   = errors.New("clarification session already closed")
+)
+
+// SessionStatus tracks a clarification session through its lifecycle
+type SessionStatus string
+
+const (
+	SessionPending  SessionStatus = "pending"
+	SessionAnswered SessionStatus = "answered"
+	SessionTimedOut SessionStatus = "timed_out"
+)
+
+// closedSessionTTL is how long resolved sessions remain visible via the API
+const closedSessionTTL = time.Hour
+
+// Session is a set of questions awaiting answers
+type Session struct {
+	ID         string            `json:"id"`
+	IntentText string            `json:"intent"`
+	TenantID   string            `json:"tenant_id,omitempty"`
+	Questions  []Question        `json:"questions"`
+	Answers    map[string]string `json:"answers,omitempty"`
+	Status     SessionStatus     `json:"status"`
+	CreatedAt  time.Time         `json:"created_at"`
+	ExpiresAt  time.Time         `json:"expires_at"`
+	ClosedAt   *time.Time        `json:"closed_at,omitempty"`
+}
+
+type session struct {
+	Session
+	done chan struct{}
+}
+
+// Broker holds clarification sessions until they are answered or expire
+type Broker struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+	seq      int
+}
+
+func NewBroker() *Broker {
+	return &Broker{sessions: make(map[string]*session)}
+}
+
+// Open registers a new pending session for an intent of tenantID
+func (b *Broker) Open(tenantID, intentText string, questions []Question, timeout time.Duration) Session {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pruneLocked()
+
+	b.seq++
+	now := time.Now()
+	s := &session{
+		Session: Session{
+			ID:         fmt.Sprintf("CLR-%d-%d", now.Unix(), b.seq),
+			IntentText: intentText,
+			TenantID:   tenantID,
+			Questions:  questions,
+			Status:     SessionPending,
+			CreatedAt:  now,
+			ExpiresAt:  now.Add(timeout),
+		},
+		done: make(chan struct{}),
+	}
+	b.sessions[s.ID] = s
+	return s.snapshot()
+}
+
+// Answer submits answers for a pending session and unblocks the waiting intent.
+// Unknown question IDs are rejected; unanswered questions take their defaults.
+func (b *Broker) Answer(id string, answers map[string]string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.sessions[id]
+	if !ok {
+		return ErrSessionNotFound
+	}
+	if s.Status != SessionPending {
+		return ErrSessionClosed
+	}
+
+	known := make(map[string]bool, len(s.Questions))
+	for _, q := range s.Questions {
+		known[q.ID] = true
+	}
+	for qid := range answers {
+		if !known[qid] {
+			return fmt.Errorf("unknown question %q", qid)
+		}
+	}
+
+	s.Answers = make(map[string]string, len(answers))
+	for qid, value := range answers {
+		s.Answers[qid] = value
+	}
+	b.closeLocked(s, SessionAnswered)
+	return nil
+}
+
+// Wait blocks until the session is answered, the timeout expires or ctx is
+// done. It returns the submitted answers and whether the session timed out.
+func (b *Broker) Wait(ctx context.Context, id string, timeout time.Duration) (map[string]string, bool, error) {
+	b.mu.Lock()
+	s, ok := b.sessions[id]
+	b.mu.Unlock()
+	if !ok {
+		return nil, false, ErrSessionNotFound
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-s.done:
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if s.Status == SessionPending {
+		b.closeLocked(s, SessionTimedOut)
+		return nil, true, nil
+	}
+	return s.snapshot().Answers, false, nil
+}
+
+// Get returns a copy of a session
+func (b *Broker) Get(id string) (Session, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.sessions[id]
+	if !ok {
+		return Session{}, false
+	}
+	return s.snapshot(), true
+}
+
+// Pending returns the sessions awaiting answers, oldest first
+func (b *Broker) Pending() []Session {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pending := make([]Session, 0)
+	for _, s := range b.sessions {
+		if s.Status == SessionPending {
+			pending = append(pending, s.snapshot())
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].CreatedAt.Before(pending[j].CreatedAt) })
+	return pending
+}
+
+func (b *Broker) closeLocked(s *session, status SessionStatus) {
+	now := time.Now()
+	s.Status = status
+	s.ClosedAt = &now
+	close(s.done)
+}
+
+func (b *Broker) pruneLocked() {
+	cutoff := time.Now().Add(-closedSessionTTL)
+	for id, s := range b.sessions {
+		if s.ClosedAt != nil && s.ClosedAt.Before(cutoff) {
+			delete(b.sessions, id)
+		}
+	}
+}
+
+func (s *session) snapshot() Session {
+	copied := s.Session
+	copied.Questions = append([]Question{}, s.Questions...)
+	if s.Answers != nil {
+		copied.Answers = make(map[string]string, len(s.Answers))
+		for k, v := range s.Answers {
+			copied.Answers[k] = v
+		}
+	}
+	return copied
+}
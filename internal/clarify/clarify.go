@@ -0,0 +1,323 @@
+// Package clarify asks targeted follow-up questions about ambiguous intents
+// before task decomposition and records the answers, or the defaults used when
+// nobody answers in time.
+package clarify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/llm"
+	"QLP/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Question is a clarification asked about an intent. Default is used when the
+// question times out or is left unanswered.
+type Question struct {
+	ID      string   `json:"id"`
+	Topic   string   `json:"topic"`
+	Text    string   `json:"question"`
+	Options []string `json:"options,omitempty"`
+	Default string   `json:"default"`
+}
+
+// Answer is the resolved value for one question
+type Answer struct {
+	QuestionID string `json:"question_id"`
+	Topic      string `json:"topic"`
+	Question   string `json:"question"`
+	Value      string `json:"value"`
+	Defaulted  bool   `json:"defaulted"`
+}
+
+// Resolution is the outcome of a clarification round
+type Resolution struct {
+	SessionID string   `json:"session_id,omitempty"`
+	Answers   []Answer `json:"answers"`
+	TimedOut  bool     `json:"timed_out"`
+}
+
+// PromptContext renders the answers for inclusion in the decomposition prompt
+func (r *Resolution) PromptContext() string {
+	if r == nil || len(r.Answers) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\nClarifications:\n")
+	for _, a := range r.Answers {
+		fmt.Fprintf(&b, "- %s %s\n", a.Question, a.Value)
+	}
+	return b.String()
+}
+
+// Metadata records the answers on the intent; defaulted topics are listed so
+// reviewers can see which assumptions were made without the user
+func (r *Resolution) Metadata() map[string]string {
+	if r == nil || len(r.Answers) == 0 {
+		return nil
+	}
+	metadata := map[string]string{"clarification.session": r.SessionID}
+	var defaulted []string
+	for _, a := range r.Answers {
+		metadata["clarification."+a.Topic] = a.Value
+		if a.Defaulted {
+			defaulted = append(defaulted, a.Topic)
+		}
+	}
+	if len(defaulted) > 0 {
+		metadata["clarification.defaulted"] = strings.Join(defaulted, ",")
+	}
+	if r.TimedOut {
+		metadata["clarification.timed_out"] = "true"
+	}
+	return metadata
+}
+
+// Responder collects answers interactively, e.g. from a terminal. It returns
+// answers keyed by question ID; missing answers fall back to defaults.
+type Responder func(ctx context.Context, session Session) map[string]string
+
+// Service generates questions and blocks until they are answered through the
+// broker (API) or responder (CLI), or the timeout expires
+type Service struct {
+	client       llm.Client
+	broker       *Broker
+	responder    Responder
+	timeout      time.Duration
+	maxQuestions int
+}
+
+func NewService(client llm.Client, timeout time.Duration, maxQuestions int) *Service {
+	if maxQuestions <= 0 {
+		maxQuestions = 4
+	}
+	return &Service{
+		client:       client,
+		broker:       NewBroker(),
+		timeout:      timeout,
+		maxQuestions: maxQuestions,
+	}
+}
+
+// Broker exposes pending sessions for the HTTP API
+func (s *Service) Broker() *Broker {
+	return s.broker
+}
+
+// SetResponder answers questions synchronously instead of waiting on the API
+func (s *Service) SetResponder(r Responder) {
+	s.responder = r
+}
+
+// Clarify asks about anything ambiguous in intentText. It returns an empty
+// resolution when the intent is clear.
+func (s *Service) Clarify(ctx context.Context, intentText string) (*Resolution, error) {
+	questions := s.Questions(ctx, intentText)
+	if len(questions) == 0 {
+		return &Resolution{}, nil
+	}
+
+	session := s.broker.Open(audit.TenantFromContext(ctx), intentText, questions, s.timeout)
+	logger.WithComponent("clarify").Info("Awaiting intent clarification",
+		zap.String("session_id", session.ID),
+		zap.Int("questions", len(questions)),
+		zap.Duration("timeout", s.timeout))
+
+	var answers map[string]string
+	timedOut := false
+	if s.responder != nil {
+		answers = s.responder(ctx, session)
+		if err := s.broker.Answer(session.ID, answers); err != nil {
+			// Answered through the API while the terminal was prompting
+			if !errors.Is(err, ErrSessionClosed) {
+				return nil, err
+			}
+			current, _ := s.broker.Get(session.ID)
+			answers = current.Answers
+		}
+	} else {
+		var err error
+		answers, timedOut, err = s.broker.Wait(ctx, session.ID, s.timeout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resolution := resolve(session.ID, questions, answers)
+	resolution.TimedOut = timedOut
+	logger.WithComponent("clarify").Info("Intent clarified",
+		zap.String("session_id", session.ID),
+		zap.Bool("timed_out", timedOut),
+		zap.Int("answers", len(resolution.Answers)))
+	return resolution, nil
+}
+
+func resolve(sessionID string, questions []Question, answers map[string]string) *Resolution {
+	resolution := &Resolution{SessionID: sessionID}
+	for _, q := range questions {
+		value := strings.TrimSpace(answers[q.ID])
+		resolution.Answers = append(resolution.Answers, Answer{
+			QuestionID: q.ID,
+			Topic:      q.Topic,
+			Question:   q.Text,
+			Value:      firstNonEmpty(value, q.Default),
+			Defaulted:  value == "",
+		})
+	}
+	return resolution
+}
+
+// Questions asks the model what is ambiguous about the intent, falling back to
+// keyword heuristics when no model is configured or the call fails
+func (s *Service) Questions(ctx context.Context, intentText string) []Question {
+	if s.client != nil {
+		questions, err := s.generate(ctx, intentText)
+		if err == nil {
+			return questions
+		}
+		logger.WithComponent("clarify").Warn("Question generation failed, using heuristics",
+			zap.Error(err))
+	}
+	return limit(HeuristicQuestions(intentText), s.maxQuestions)
+}
+
+func (s *Service) generate(ctx context.Context, intentText string) ([]Question, error) {
+	prompt := fmt.Sprintf(`A user asked a code generation system for the following:
+
+%s
+
+List up to %d questions whose answers would materially change the design (authentication scheme, expected scale, data store, deployment target, integrations). Ask nothing the request already answers; return an empty list when it is clear enough. Every question needs a sensible default.
+
+Respond with JSON: {"questions": [{"topic": "<snake_case topic>", "question": "<question>", "options": ["<option>", ...], "default": "<default answer>"}]}`,
+		intentText, s.maxQuestions)
+
+	var response struct {
+		Questions []struct {
+			Topic    string   `json:"topic"`
+			Question string   `json:"question"`
+			Options  []string `json:"options"`
+			Default  string   `json:"default"`
+		} `json:"questions"`
+	}
+	schema := llm.SchemaFor("clarifying_questions", "Clarifying questions for an ambiguous intent", response)
+	if err := llm.CompleteJSON(ctx, s.client, prompt, schema, &response); err != nil {
+		return nil, err
+	}
+
+	var questions []Question
+	for _, q := range response.Questions {
+		if strings.TrimSpace(q.Question) == "" {
+			continue
+		}
+		def := q.Default
+		if def == "" && len(q.Options) > 0 {
+			def = q.Options[0]
+		}
+		topic := topicSlug(firstNonEmpty(q.Topic, q.Question))
+		questions = append(questions, Question{
+			ID:      fmt.Sprintf("q%d", len(questions)+1),
+			Topic:   topic,
+			Text:    q.Question,
+			Options: q.Options,
+			Default: def,
+		})
+	}
+	return limit(questions, s.maxQuestions), nil
+}
+
+type heuristic struct {
+	topic    string
+	applies  *regexp.Regexp // the intent needs this decision
+	answered *regexp.Regexp // the intent already made it
+	question Question
+}
+
+var heuristics = []heuristic{
+	{
+		topic:    "auth",
+		applies:  regexp.MustCompile(`(?i)\b(api|service|app|application|portal|dashboard|users?|accounts?)\b`),
+		answered: regexp.MustCompile(`(?i)\b(auth\w*|jwt|oauth2?|oidc|sso|api[ -]?keys?|sessions?|login|public|anonymous)\b`),
+		question: Question{
+			Text:    "Which authentication scheme should the service use?",
+			Options: []string{"JWT bearer tokens", "OAuth2/OIDC", "API keys", "None"},
+			Default: "JWT bearer tokens",
+		},
+	},
+	{
+		topic:    "data_store",
+		applies:  regexp.MustCompile(`(?i)\b(data|store|stores|persist\w*|crud|users?|orders?|records?|inventory|catalog|management)\b`),
+		answered: regexp.MustCompile(`(?i)\b(postgres\w*|mysql|mariadb|sqlite|mongo\w*|redis|dynamo\w*|cosmos\w*|firestore|cassandra|in[- ]memory|stateless)\b`),
+		question: Question{
+			Text:    "Which data store should be used?",
+			Options: []string{"PostgreSQL", "MySQL", "MongoDB", "SQLite", "In-memory"},
+			Default: "PostgreSQL",
+		},
+	},
+	{
+		topic:    "scale",
+		applies:  regexp.MustCompile(`(?i)\b(api|service|app|application|platform|microservices?|backend)\b`),
+		answered: regexp.MustCompile(`(?i)(\b\d[\d,.]*\s*(k|m)?\s*(users|rps|requests|qps|tps)\b|\bscal\w+|\bhigh[- ]traffic\b|\bprototype\b|\bpoc\b|\bmvp\b)`),
+		question: Question{
+			Text:    "What scale should it be designed for?",
+			Options: []string{"Prototype (under 100 requests/s)", "Moderate (about 1k requests/s)", "High (10k+ requests/s, horizontally scaled)"},
+			Default: "Moderate (about 1k requests/s)",
+		},
+	},
+	{
+		topic:    "deployment_target",
+		applies:  regexp.MustCompile(`(?i)\b(api|service|app|application|platform|microservices?|backend|website)\b`),
+		answered: regexp.MustCompile(`(?i)\b(kubernetes|k8s|aks|eks|gke|docker|compose|serverless|lambda|functions?|azure|aws|gcp|vm|heroku|cloud run)\b`),
+		question: Question{
+			Text:    "Where will it be deployed?",
+			Options: []string{"Kubernetes", "Docker Compose", "Serverless functions", "Virtual machine"},
+			Default: "Kubernetes",
+		},
+	},
+}
+
+// HeuristicQuestions returns the standard questions an intent leaves open
+func HeuristicQuestions(intentText string) []Question {
+	var questions []Question
+	for _, h := range heuristics {
+		if !h.applies.MatchString(intentText) || h.answered.MatchString(intentText) {
+			continue
+		}
+		q := h.question
+		q.ID = fmt.Sprintf("q%d", len(questions)+1)
+		q.Topic = h.topic
+		questions = append(questions, q)
+	}
+	return questions
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+func topicSlug(s string) string {
+	slug := strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(s), "_"), "_")
+	if len(slug) > 40 {
+		slug = strings.TrimRight(slug[:40], "_")
+	}
+	return slug
+}
+
+func limit(questions []Question, n int) []Question {
+	if n > 0 && len(questions) > n {
+		return questions[:n]
+	}
+	return questions
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,155 @@
+package clarify
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"QLP/internal/audit"
+)
+
+func TestHeuristicQuestionsSkipDecidedTopics(t *testing.T) {
+	questions := HeuristicQuestions("Build a user management API with JWT auth backed by PostgreSQL")
+
+	topics := make(map[string]bool)
+	for _, q := range questions {
+		topics[q.Topic] = true
+		if q.Default == "" {
+			t.Errorf("question %s has no default", q.ID)
+		}
+	}
+	if topics["auth"] || topics["data_store"] {
+		t.Errorf("asked about topics the intent already answers: %v", topics)
+	}
+	if !topics["scale"] || !topics["deployment_target"] {
+		t.Errorf("expected scale and deployment questions, got %v", topics)
+	}
+
+	if got := HeuristicQuestions("Write a haiku generator CLI"); len(got) != 0 {
+		t.Errorf("expected no questions for a clear intent, got %v", got)
+	}
+}
+
+func TestClarifyTimesOutWithDefaults(t *testing.T) {
+	service := NewService(nil, 20*time.Millisecond, 2)
+
+	resolution, err := service.Clarify(context.Background(), "Build an order service API")
+	if err != nil {
+		t.Fatalf("Clarify failed: %v", err)
+	}
+	if !resolution.TimedOut || len(resolution.Answers) != 2 {
+		t.Fatalf("expected 2 defaulted answers after timeout, got %+v", resolution)
+	}
+	for _, a := range resolution.Answers {
+		if !a.Defaulted || a.Value == "" {
+			t.Errorf("expected default for %s, got %+v", a.Topic, a)
+		}
+	}
+
+	metadata := resolution.Metadata()
+	if metadata["clarification.timed_out"] != "true" || metadata["clarification.auth"] != "JWT bearer tokens" {
+		t.Errorf("unexpected metadata: %v", metadata)
+	}
+	if len(service.Broker().Pending()) != 0 {
+		t.Error("timed out session still pending")
+	}
+}
+
+func TestClarifyAnsweredThroughAPI(t *testing.T) {
+	service := NewService(nil, 5*time.Second, 4)
+	server := httptest.NewServer(routeMux(service.Broker()))
+	defer server.Close()
+
+	go func() {
+		for {
+			if pending := service.Broker().Pending(); len(pending) > 0 {
+				resp, err := http.Post(server.URL+"/clarifications/"+pending[0].ID+"/answers", "application/json",
+					strings.NewReader(`{"answers": {"q1": "OAuth2/OIDC"}}`))
+				if err == nil {
+					resp.Body.Close()
+				}
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	resolution, err := service.Clarify(context.Background(), "Build a customer portal")
+	if err != nil {
+		t.Fatalf("Clarify failed: %v", err)
+	}
+	if resolution.TimedOut {
+		t.Fatal("expected answers before timeout")
+	}
+	if a := resolution.Answers[0]; a.Topic != "auth" || a.Value != "OAuth2/OIDC" || a.Defaulted {
+		t.Errorf("unexpected first answer: %+v", a)
+	}
+	if !strings.Contains(resolution.PromptContext(), "OAuth2/OIDC") {
+		t.Errorf("answer missing from prompt context: %s", resolution.PromptContext())
+	}
+
+	resp, _ := http.Post(server.URL+"/clarifications/"+resolution.SessionID+"/answers", "application/json",
+		strings.NewReader(`{"answers": {}}`))
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected 409 for a closed session, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestTerminalResponderPicksOptions(t *testing.T) {
+	service := NewService(nil, time.Second, 4)
+	service.SetResponder(TerminalResponder(bufio.NewScanner(strings.NewReader("2\n\n")), io.Discard))
+
+	resolution, err := service.Clarify(context.Background(), "Build an inventory API")
+	if err != nil {
+		t.Fatalf("Clarify failed: %v", err)
+	}
+	if resolution.Answers[0].Value != "OAuth2/OIDC" || resolution.Answers[0].Defaulted {
+		t.Errorf("expected option 2 for the first question, got %+v", resolution.Answers[0])
+	}
+	if !resolution.Answers[1].Defaulted {
+		t.Errorf("expected empty line to accept the default, got %+v", resolution.Answers[1])
+	}
+}
+
+func routeMux(broker *Broker) *http.ServeMux {
+	mux := http.NewServeMux()
+	for pattern, h := range Routes(broker) {
+		mux.Handle(pattern, h)
+	}
+	return mux
+}
+
+func TestRoutesScopeToTheAPIKey(t *testing.T) {
+	broker := NewBroker()
+	session := broker.Open("t1", "Build a customer portal", []Question{{ID: "q1", Topic: "auth"}}, time.Minute)
+	mux := routeMux(broker)
+	as := func(tenant, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(audit.WithTenant(req.Context(), tenant))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := as("t2", http.MethodGet, "/clarifications", ""); strings.Contains(rec.Body.String(), session.ID) {
+		t.Errorf("another tenant lists the session: %s", rec.Body)
+	}
+	if rec := as("t2", http.MethodGet, "/clarifications/"+session.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("get from another tenant: %d", rec.Code)
+	}
+	if rec := as("t2", http.MethodPost, "/clarifications/"+session.ID+"/answers", `{"answers": {"q1": "none"}}`); rec.Code != http.StatusNotFound {
+		t.Errorf("answer from another tenant: %d", rec.Code)
+	}
+	if rec := as("t1", http.MethodGet, "/clarifications", ""); !strings.Contains(rec.Body.String(), session.ID) {
+		t.Errorf("list: %s", rec.Body)
+	}
+	if rec := as("t1", http.MethodPost, "/clarifications/"+session.ID+"/answers", `{"answers": {"q1": "OAuth2/OIDC"}}`); rec.Code != http.StatusOK {
+		t.Errorf("answer: %d %s", rec.Code, rec.Body)
+	}
+}
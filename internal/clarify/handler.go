@@ -0,0 +1,94 @@
+package clarify
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"QLP/internal/audit"
+)
+
+// Routes returns the clarification endpoints:
+//
+//	GET  /clarifications                 lists sessions awaiting answers
+//	GET  /clarifications/{id}            returns a session and its questions
+//	POST /clarifications/{id}/answers    submits answers as {"answers": {"q1": "..."}}
+//
+// Requests with an API key only see their tenant's sessions.
+func Routes(broker *Broker) map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /clarifications":               listHandler(broker),
+		"GET /clarifications/{id}":          getHandler(broker),
+		"POST /clarifications/{id}/answers": answerHandler(broker),
+	}
+}
+
+func listHandler(broker *Broker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sessions := make([]Session, 0)
+		for _, session := range broker.Pending() {
+			if audit.TenantAllowed(r, session.TenantID) {
+				sessions = append(sessions, session)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"sessions": sessions,
+		})
+	})
+}
+
+func getHandler(broker *Broker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, ok := tenantSession(broker, r)
+		if !ok {
+			http.Error(w, ErrSessionNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(session)
+	})
+}
+
+func answerHandler(broker *Broker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Answers map[string]string `json:"answers"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil {
+			http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		id := r.PathValue("id")
+		if _, ok := tenantSession(broker, r); !ok {
+			http.Error(w, ErrSessionNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		if err := broker.Answer(id, body.Answers); err != nil {
+			switch {
+			case errors.Is(err, ErrSessionNotFound):
+				http.Error(w, err.Error(), http.StatusNotFound)
+			case errors.Is(err, ErrSessionClosed):
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+			return
+		}
+
+		session, _ := broker.Get(id)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(session)
+	})
+}
+
+// tenantSession returns the session the request names, not found when it
+// belongs to another tenant than the request's
+func tenantSession(broker *Broker, r *http.Request) (Session, bool) {
+	session, ok := broker.Get(r.PathValue("id"))
+	if !ok || !audit.TenantAllowed(r, session.TenantID) {
+		return Session{}, false
+	}
+	return session, true
+}
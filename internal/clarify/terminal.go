@@ -0,0 +1,42 @@
+package clarify
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// TerminalResponder prompts for each question on out and reads answers from
+// scanner. An empty line accepts the default; a number picks an option.
+func TerminalResponder(scanner *bufio.Scanner, out io.Writer) Responder {
+	return func(ctx context.Context, session Session) map[string]string {
+		answers := make(map[string]string)
+		fmt.Fprintf(out, "\n❓ A few questions before planning (press Enter to accept the default):\n")
+
+		for _, q := range session.Questions {
+			if ctx.Err() != nil {
+				break
+			}
+			fmt.Fprintf(out, "\n%s\n", q.Text)
+			for i, option := range q.Options {
+				fmt.Fprintf(out, "  %d. %s\n", i+1, option)
+			}
+			fmt.Fprintf(out, "[%s] > ", q.Default)
+
+			if !scanner.Scan() {
+				break
+			}
+			answer := strings.TrimSpace(scanner.Text())
+			if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(q.Options) {
+				answer = q.Options[n-1]
+			}
+			if answer != "" {
+				answers[q.ID] = answer
+			}
+		}
+		return answers
+	}
+}
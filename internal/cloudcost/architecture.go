@@ -0,0 +1,337 @@
+// Package cloudcost estimates the monthly cost of a generated architecture
+// on Azure, AWS and GCP side by side, so users of a cloud-agnostic intent
+// can choose a target.
+package cloudcost
+
+import (
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kind is a provider-neutral building block of an architecture
+type Kind string
+
+const (
+	KindVM               Kind = "vm"                // 2 vCPU, 8 GB general purpose instance or cluster node
+	KindKubernetes       Kind = "kubernetes"        // Managed Kubernetes control plane
+	KindContainerService Kind = "container_service" // Always-on serverless container, 1 vCPU and 2 GB
+	KindDatabase         Kind = "database"          // Managed PostgreSQL or MySQL, 2 vCPU
+	KindCache            Kind = "cache"             // Managed Redis, about 1 GB
+	KindObjectStorage    Kind = "object_storage"    // 100 GB of hot object storage
+	KindLoadBalancer     Kind = "load_balancer"
+	KindRegistry         Kind = "registry" // Container registry
+	KindQueue            Kind = "queue"    // Managed message queue or topic
+)
+
+// kindOrder is the order of the rows in a comparison
+var kindOrder = []Kind{KindKubernetes, KindVM, KindContainerService, KindDatabase, KindCache,
+	KindObjectStorage, KindLoadBalancer, KindRegistry, KindQueue}
+
+// Component is a number of units of one kind, with where they were found
+type Component struct {
+	Kind     Kind     `json:"kind"`
+	Quantity int      `json:"quantity"`
+	Sources  []string `json:"sources"`
+}
+
+// Architecture is what a project would run in the cloud
+type Architecture struct {
+	Components []Component `json:"components"`
+}
+
+// Empty reports whether nothing billable was found
+func (a *Architecture) Empty() bool {
+	return a == nil || len(a.Components) == 0
+}
+
+// terraformKinds maps the Terraform resources of each provider to the kind
+// they provision
+var terraformKinds = map[string]Kind{
+	"aws_instance":                            KindVM,
+	"aws_autoscaling_group":                   KindVM,
+	"azurerm_linux_virtual_machine":           KindVM,
+	"azurerm_windows_virtual_machine":         KindVM,
+	"azurerm_virtual_machine":                 KindVM,
+	"azurerm_linux_virtual_machine_scale_set": KindVM,
+	"google_compute_instance":                 KindVM,
+	"aws_eks_cluster":                         KindKubernetes,
+	"azurerm_kubernetes_cluster":              KindKubernetes,
+	"google_container_cluster":                KindKubernetes,
+	"aws_ecs_service":                         KindContainerService,
+	"aws_apprunner_service":                   KindContainerService,
+	"azurerm_container_app":                   KindContainerService,
+	"azurerm_container_group":                 KindContainerService,
+	"azurerm_linux_web_app":                   KindContainerService,
+	"google_cloud_run_service":                KindContainerService,
+	"google_cloud_run_v2_service":             KindContainerService,
+	"aws_db_instance":                         KindDatabase,
+	"aws_rds_cluster":                         KindDatabase,
+	"azurerm_postgresql_flexible_server":      KindDatabase,
+	"azurerm_mysql_flexible_server":           KindDatabase,
+	"azurerm_mssql_database":                  KindDatabase,
+	"azurerm_cosmosdb_account":                KindDatabase,
+	"google_sql_database_instance":            KindDatabase,
+	"aws_elasticache_cluster":                 KindCache,
+	"aws_elasticache_replication_group":       KindCache,
+	"azurerm_redis_cache":                     KindCache,
+	"google_redis_instance":                   KindCache,
+	"aws_s3_bucket":                           KindObjectStorage,
+	"azurerm_storage_account":                 KindObjectStorage,
+	"google_storage_bucket":                   KindObjectStorage,
+	"aws_lb":                                  KindLoadBalancer,
+	"aws_alb":                                 KindLoadBalancer,
+	"aws_elb":                                 KindLoadBalancer,
+	"azurerm_lb":                              KindLoadBalancer,
+	"azurerm_application_gateway":             KindLoadBalancer,
+	"google_compute_forwarding_rule":          KindLoadBalancer,
+	"google_compute_global_forwarding_rule":   KindLoadBalancer,
+	"aws_ecr_repository":                      KindRegistry,
+	"azurerm_container_registry":              KindRegistry,
+	"google_artifact_registry_repository":     KindRegistry,
+	"aws_sqs_queue":                           KindQueue,
+	"aws_sns_topic":                           KindQueue,
+	"azurerm_servicebus_namespace":            KindQueue,
+	"azurerm_eventhub_namespace":              KindQueue,
+	"google_pubsub_topic":                     KindQueue,
+}
+
+// nodeResources are the Terraform resources of a cluster's worker nodes
+var nodeResources = map[string]bool{
+	"aws_eks_node_group":                   true,
+	"azurerm_kubernetes_cluster":           true,
+	"azurerm_kubernetes_cluster_node_pool": true,
+	"google_container_cluster":             true,
+	"google_container_node_pool":           true,
+}
+
+// composeImages maps the images of docker-compose services to the managed
+// service that replaces them in the cloud
+var composeImages = map[string]Kind{
+	"postgres":  KindDatabase,
+	"postgis":   KindDatabase,
+	"mysql":     KindDatabase,
+	"mariadb":   KindDatabase,
+	"mongo":     KindDatabase,
+	"redis":     KindCache,
+	"memcached": KindCache,
+	"rabbitmq":  KindQueue,
+	"nats":      KindQueue,
+	"kafka":     KindQueue,
+	"minio":     KindObjectStorage,
+}
+
+var (
+	resourceBlock   = regexp.MustCompile(`(?m)^\s*resource\s+"([a-z0-9_]+)"\s+"([^"]+)"\s*\{`)
+	nodeCountAttr   = regexp.MustCompile(`(?m)^\s*(?:node_count|desired_size|initial_node_count|min_count)\s*=\s*(\d+)`)
+	countAttr       = regexp.MustCompile(`(?m)^\s*(?:count|instances|desired_capacity)\s*=\s*(\d+)`)
+	manifestKind    = regexp.MustCompile(`(?m)^kind:\s*(\w+)`)
+	replicasField   = regexp.MustCompile(`(?m)^\s*replicas:\s*(\d+)`)
+	cpuRequest      = regexp.MustCompile(`(?m)^\s*cpu:\s*["']?(\d+(?:\.\d+)?)(m?)["']?\s*$`)
+	loadBalancerSvc = regexp.MustCompile(`(?m)^\s*type:\s*LoadBalancer\b`)
+	composeService  = regexp.MustCompile(`(?m)^  ([A-Za-z0-9_.-]+):\s*$`)
+	composeImage    = regexp.MustCompile(`^\s+image:\s*["']?([^"'\s]+)`)
+	composeBuild    = regexp.MustCompile(`^\s+build:`)
+)
+
+// Analyze finds the billable building blocks of a project: the resources
+// its Terraform declares, whatever the provider; otherwise the cluster its
+// Kubernetes manifests need, the managed services replacing its
+// docker-compose dependencies, and a container service per image it builds
+func Analyze(files map[string]string) *Architecture {
+	counts := make(map[Kind]int)
+	sources := make(map[Kind][]string)
+	add := func(kind Kind, n int, source string) {
+		if n <= 0 {
+			return
+		}
+		counts[kind] += n
+		sources[kind] = append(sources[kind], source)
+	}
+
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var manifests, compose, dockerfiles []string
+	hasTerraform := false
+	for _, p := range paths {
+		base := strings.ToLower(path.Base(p))
+		switch {
+		case strings.HasSuffix(p, ".tf"):
+			hasTerraform = true
+			analyzeTerraform(files[p], add)
+		case base == "docker-compose.yml" || base == "docker-compose.yaml" || base == "compose.yml" || base == "compose.yaml":
+			compose = append(compose, p)
+		case base == "dockerfile" || strings.HasPrefix(base, "dockerfile."):
+			dockerfiles = append(dockerfiles, p)
+		case (strings.HasSuffix(base, ".yaml") || strings.HasSuffix(base, ".yml")) && base != "catalog-info.yaml" &&
+			strings.Contains(files[p], "apiVersion:") && manifestKind.MatchString(files[p]):
+			manifests = append(manifests, p)
+		}
+	}
+
+	// Terraform describes the deployment in full; the other files only hint at it
+	if !hasTerraform {
+		switch {
+		case len(manifests) > 0:
+			analyzeManifests(files, manifests, add)
+		case len(compose) > 0:
+			for _, p := range compose {
+				analyzeCompose(p, files[p], add)
+			}
+		case len(dockerfiles) > 0:
+			add(KindContainerService, len(dockerfiles), strings.Join(dockerfiles, ", "))
+		}
+	}
+
+	arch := &Architecture{}
+	for _, kind := range kindOrder {
+		if counts[kind] > 0 {
+			arch.Components = append(arch.Components, Component{Kind: kind, Quantity: counts[kind], Sources: sources[kind]})
+		}
+	}
+	return arch
+}
+
+func analyzeTerraform(content string, add func(Kind, int, string)) {
+	blocks := resourceBlock.FindAllStringSubmatchIndex(content, -1)
+	for i, m := range blocks {
+		resourceType := content[m[2]:m[3]]
+		address := resourceType + "." + content[m[4]:m[5]]
+		end := len(content)
+		if i+1 < len(blocks) {
+			end = blocks[i+1][0]
+		}
+		body := content[m[1]:end]
+
+		count := 1
+		if c := countAttr.FindStringSubmatch(body); c != nil {
+			count, _ = strconv.Atoi(c[1])
+		}
+		if kind, ok := terraformKinds[resourceType]; ok {
+			n := count
+			if kind == KindKubernetes {
+				n = 1
+			}
+			add(kind, n, address)
+		}
+		if nodeResources[resourceType] {
+			nodes := 1
+			if c := nodeCountAttr.FindStringSubmatch(body); c != nil {
+				nodes, _ = strconv.Atoi(c[1])
+			}
+			add(KindVM, nodes, address+" nodes")
+		}
+	}
+}
+
+// analyzeManifests sizes a cluster for the workloads' CPU requests, at half
+// a vCPU per replica without one and two vCPUs per node, with two nodes at
+// least for availability
+func analyzeManifests(files map[string]string, manifests []string, add func(Kind, int, string)) {
+	cpu := 0.0
+	var workloads []string
+	loadBalancers := 0
+	for _, p := range manifests {
+		for _, doc := range strings.Split(files[p], "\n---") {
+			kind := manifestKind.FindStringSubmatch(doc)
+			if kind == nil {
+				continue
+			}
+			switch kind[1] {
+			case "Deployment", "StatefulSet", "DaemonSet":
+				replicas := 1
+				if r := replicasField.FindStringSubmatch(doc); r != nil {
+					replicas, _ = strconv.Atoi(r[1])
+				}
+				perReplica := 0.5
+				if c := cpuRequest.FindStringSubmatch(doc); c != nil {
+					perReplica, _ = strconv.ParseFloat(c[1], 64)
+					if c[2] == "m" {
+						perReplica /= 1000
+					}
+				}
+				cpu += float64(replicas) * perReplica
+				workloads = append(workloads, p)
+			case "Service":
+				if loadBalancerSvc.MatchString(doc) {
+					loadBalancers++
+				}
+			case "Ingress":
+				loadBalancers++
+			}
+		}
+	}
+	if len(workloads) == 0 {
+		return
+	}
+	source := strings.Join(dedupe(workloads), ", ")
+	add(KindKubernetes, 1, source)
+	add(KindVM, int(math.Max(2, math.Ceil(cpu/2))), source)
+	if loadBalancers > 0 {
+		// Ingresses and LoadBalancer services share the cluster's load balancer
+		add(KindLoadBalancer, 1, strings.Join(dedupe(manifests), ", "))
+	}
+}
+
+// analyzeCompose maps each docker-compose service to the managed service
+// replacing it, or to a container service
+func analyzeCompose(p, content string, add func(Kind, int, string)) {
+	inServices := false
+	service, image, build := "", "", false
+	flush := func() {
+		if service == "" {
+			return
+		}
+		name := path.Base(strings.SplitN(image, ":", 2)[0])
+		if kind, ok := composeImages[name]; ok && !build {
+			add(kind, 1, p+": "+service)
+		} else if image != "" || build {
+			add(KindContainerService, 1, p+": "+service)
+		}
+		service, image, build = "", "", false
+	}
+	for _, line := range strings.Split(content, "\n") {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") {
+			flush()
+			inServices = strings.HasPrefix(line, "services:")
+			continue
+		}
+		if !inServices {
+			continue
+		}
+		if m := composeService.FindStringSubmatch(line); m != nil {
+			flush()
+			service = m[1]
+			continue
+		}
+		if m := composeImage.FindStringSubmatch(line); m != nil && image == "" {
+			image = m[1]
+		}
+		if composeBuild.MatchString(line) {
+			build = true
+		}
+	}
+	flush()
+}
+
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var out []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
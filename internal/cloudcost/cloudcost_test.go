@@ -0,0 +1,160 @@
+package cloudcost
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"QLP/internal/models"
+)
+
+func quantities(arch *Architecture) map[Kind]int {
+	q := make(map[Kind]int)
+	for _, c := range arch.Components {
+		q[c.Kind] = c.Quantity
+	}
+	return q
+}
+
+func TestAnalyzeTerraform(t *testing.T) {
+	arch := Analyze(map[string]string{
+		"infra/main.tf": `resource "aws_eks_cluster" "main" {
+  name = "orders"
+}
+
+resource "aws_eks_node_group" "workers" {
+  scaling_config {
+    desired_size = 3
+  }
+}
+
+resource "aws_db_instance" "db" {
+  engine = "postgres"
+}
+
+resource "aws_s3_bucket" "assets" {
+  count = 2
+}
+
+resource "aws_iam_role" "ignored" {}
+`,
+		"k8s/deployment.yaml": "apiVersion: apps/v1\nkind: Deployment\nspec:\n  replicas: 10\n",
+	})
+	got := quantities(arch)
+	want := map[Kind]int{KindKubernetes: 1, KindVM: 3, KindDatabase: 1, KindObjectStorage: 2}
+	if len(got) != len(want) {
+		t.Fatalf("components = %+v, Terraform should take precedence over manifests", arch.Components)
+	}
+	for kind, n := range want {
+		if got[kind] != n {
+			t.Errorf("%s = %d, want %d", kind, got[kind], n)
+		}
+	}
+}
+
+func TestAnalyzeManifestsAndCompose(t *testing.T) {
+	arch := Analyze(map[string]string{
+		"k8s/api.yaml": `apiVersion: apps/v1
+kind: Deployment
+spec:
+  replicas: 6
+  template:
+    spec:
+      containers:
+        - name: api
+          resources:
+            requests:
+              cpu: 500m
+---
+apiVersion: v1
+kind: Service
+spec:
+  type: LoadBalancer
+`,
+		"catalog-info.yaml": "apiVersion: backstage.io/v1alpha1\nkind: Component\n",
+	})
+	got := quantities(arch)
+	if got[KindKubernetes] != 1 || got[KindVM] != 2 || got[KindLoadBalancer] != 1 || len(got) != 3 {
+		t.Errorf("manifest components = %+v", arch.Components)
+	}
+
+	arch = Analyze(map[string]string{
+		"docker-compose.yml": `version: "3.9"
+services:
+  api:
+    build: .
+    ports:
+      - "8080:8080"
+  worker:
+    image: ghcr.io/acme/worker:1.2
+  db:
+    image: "postgres:16-alpine"
+  cache:
+    image: redis:7
+volumes:
+  data:
+`,
+		"Dockerfile": "FROM golang:1.22\n",
+	})
+	got = quantities(arch)
+	if got[KindContainerService] != 2 || got[KindDatabase] != 1 || got[KindCache] != 1 || len(got) != 3 {
+		t.Errorf("compose components = %+v", arch.Components)
+	}
+
+	if arch := Analyze(map[string]string{"main.go": "package main\n"}); !arch.Empty() {
+		t.Errorf("library without deployment files = %+v", arch.Components)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	arch := &Architecture{Components: []Component{{Kind: KindVM, Quantity: 2}, {Kind: KindDatabase, Quantity: 1}}}
+	c := Compare(arch, DefaultPriceSheet())
+	if strings.Join(c.Providers, ",") != "azure,aws,gcp" || len(c.Rows) != 2 {
+		t.Fatalf("comparison = %+v", c)
+	}
+	if c.Rows[0].Monthly[1] != 140.16 || c.Totals[2] != 2*48.91+98.62 {
+		t.Errorf("rows = %+v, totals = %v", c.Rows, c.Totals)
+	}
+	if c.Cheapest != GCP || !strings.HasPrefix(c.Summary(), "GCP is the cheapest target") {
+		t.Errorf("cheapest = %s, summary = %s", c.Cheapest, c.Summary())
+	}
+	if Compare(&Architecture{}, DefaultPriceSheet()) != nil {
+		t.Error("an empty architecture has nothing to compare")
+	}
+}
+
+func TestLoadPriceSheet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.json")
+	os.WriteFile(path, []byte(`{"region": "West Europe", "prices": {"gcp": {"vm": {"monthly_usd": 99, "sku": "n2-standard-2"}}}}`), 0644)
+	sheet, err := LoadPriceSheet(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sheet.Region != "West Europe" || sheet.Prices[GCP][KindVM].MonthlyUSD != 99 || sheet.Prices[GCP][KindDatabase].MonthlyUSD == 0 {
+		t.Errorf("sheet = %+v", sheet)
+	}
+
+	os.WriteFile(path, []byte(`{"prices": {"oracle": {}}}`), 0644)
+	if _, err := LoadPriceSheet(path); err == nil {
+		t.Error("expected an error for an unknown provider")
+	}
+}
+
+func TestAgnostic(t *testing.T) {
+	cases := []struct {
+		intent      string
+		constraints *models.Constraints
+		want        bool
+	}{
+		{"Create a REST API for orders with PostgreSQL", nil, true},
+		{"Deploy an orders API on AKS", nil, false},
+		{"Orders API running on Google Cloud Run", nil, false},
+		{"Orders API", &models.Constraints{Cloud: "aws"}, false},
+	}
+	for _, c := range cases {
+		if got := Agnostic(c.intent, c.constraints); got != c.want {
+			t.Errorf("Agnostic(%q) = %v, want %v", c.intent, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package cloudcost
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"QLP/internal/models"
+)
+
+// Row is the monthly cost of one component on each provider, in the order
+// of the comparison's providers
+type Row struct {
+	Kind     Kind      `json:"kind"`
+	Label    string    `json:"label"`
+	Quantity int       `json:"quantity"`
+	Monthly  []float64 `json:"monthly_usd"`
+	SKUs     []string  `json:"skus"`
+}
+
+// Comparison is the estimated monthly cost of an architecture on each
+// provider
+type Comparison struct {
+	Providers []string  `json:"providers"`
+	Rows      []Row     `json:"rows"`
+	Totals    []float64 `json:"totals_usd"`
+	Cheapest  string    `json:"cheapest"`
+	Region    string    `json:"region"`
+	Updated   string    `json:"prices_updated"`
+}
+
+var kindLabels = map[Kind]string{
+	KindVM:               "Compute (2 vCPU, 8 GB)",
+	KindKubernetes:       "Managed Kubernetes",
+	KindContainerService: "Container service (1 vCPU, 2 GB)",
+	KindDatabase:         "Managed database (2 vCPU)",
+	KindCache:            "Managed Redis (1 GB)",
+	KindObjectStorage:    "Object storage (100 GB)",
+	KindLoadBalancer:     "Load balancer",
+	KindRegistry:         "Container registry",
+	KindQueue:            "Messaging",
+}
+
+// ProviderName is the display name of a provider
+func ProviderName(provider string) string {
+	switch provider {
+	case Azure:
+		return "Azure"
+	case AWS:
+		return "AWS"
+	case GCP:
+		return "GCP"
+	}
+	return provider
+}
+
+// Compare prices an architecture on every provider of the sheet; it returns
+// nil for an architecture with nothing billable
+func Compare(arch *Architecture, sheet *PriceSheet) *Comparison {
+	if arch.Empty() {
+		return nil
+	}
+	c := &Comparison{
+		Providers: Providers,
+		Totals:    make([]float64, len(Providers)),
+		Region:    sheet.Region,
+		Updated:   sheet.Updated,
+	}
+	for _, component := range arch.Components {
+		row := Row{
+			Kind:     component.Kind,
+			Label:    kindLabels[component.Kind],
+			Quantity: component.Quantity,
+			Monthly:  make([]float64, len(Providers)),
+			SKUs:     make([]string, len(Providers)),
+		}
+		for i, provider := range Providers {
+			price := sheet.Prices[provider][component.Kind]
+			row.Monthly[i] = price.MonthlyUSD * float64(component.Quantity)
+			row.SKUs[i] = price.SKU
+			c.Totals[i] += row.Monthly[i]
+		}
+		c.Rows = append(c.Rows, row)
+	}
+	cheapest := 0
+	for i, total := range c.Totals {
+		if total < c.Totals[cheapest] {
+			cheapest = i
+		}
+	}
+	c.Cheapest = Providers[cheapest]
+	return c
+}
+
+// Summary is a one-line recommendation from the comparison
+func (c *Comparison) Summary() string {
+	i := 0
+	for j, p := range c.Providers {
+		if p == c.Cheapest {
+			i = j
+		}
+	}
+	var others []string
+	for j, p := range c.Providers {
+		if j != i {
+			others = append(others, fmt.Sprintf("%s $%.2f", ProviderName(p), c.Totals[j]))
+		}
+	}
+	return fmt.Sprintf("%s is the cheapest target at an estimated $%.2f/month (%s), at %s list prices",
+		ProviderName(c.Cheapest), c.Totals[i], strings.Join(others, ", "), c.Region)
+}
+
+// cloudMentions finds an intent asking for a specific provider
+var cloudMentions = regexp.MustCompile(`(?i)\b(azure|aks|aws|amazon|eks|ec2|lambda|gcp|google cloud|gke|cloud run)\b`)
+
+// Agnostic reports whether an intent leaves the cloud provider open: its
+// constraints, including the tenant's defaults, name none and its text
+// does not mention one
+func Agnostic(intentText string, constraints *models.Constraints) bool {
+	if constraints != nil && strings.TrimSpace(constraints.Cloud) != "" {
+		return false
+	}
+	return !cloudMentions.MatchString(intentText)
+}
@@ -0,0 +1,117 @@
+package cloudcost
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"QLP/internal/config"
+)
+
+// Providers compared, in column order
+const (
+	Azure = "azure"
+	AWS   = "aws"
+	GCP   = "gcp"
+)
+
+// Providers is the column order of a comparison
+var Providers = []string{Azure, AWS, GCP}
+
+// Price is the monthly on-demand cost of one unit of a kind on a provider,
+// with the service and SKU it was taken from
+type Price struct {
+	MonthlyUSD float64 `json:"monthly_usd"`
+	SKU        string  `json:"sku"`
+}
+
+// PriceSheet holds the prices of each kind per provider
+type PriceSheet struct {
+	Region  string                    `json:"region"`  // Region the prices apply to, for the report
+	Updated string                    `json:"updated"` // When the prices were taken from the providers' price lists
+	Prices  map[string]map[Kind]Price `json:"prices"`
+}
+
+// DefaultPriceSheet returns the providers' published pay-as-you-go list
+// prices in their US East regions, at 730 hours a month. Prices change;
+// LoadPriceSheet replaces them with a sheet exported from the providers'
+// pricing APIs.
+func DefaultPriceSheet() *PriceSheet {
+	return &PriceSheet{
+		Region:  "US East",
+		Updated: "2026-01",
+		Prices: map[string]map[Kind]Price{
+			Azure: {
+				KindVM:               {70.08, "Virtual Machines D2s v5"},
+				KindKubernetes:       {73.00, "AKS Standard tier"},
+				KindContainerService: {78.84, "Container Apps, 1 vCPU 2 GiB always active"},
+				KindDatabase:         {129.94, "PostgreSQL Flexible Server D2ds v5"},
+				KindCache:            {40.15, "Azure Cache for Redis Basic C1"},
+				KindObjectStorage:    {1.84, "Blob Storage Hot LRS, 100 GB"},
+				KindLoadBalancer:     {18.25, "Load Balancer Standard, 1 rule"},
+				KindRegistry:         {5.00, "Container Registry Basic"},
+				KindQueue:            {9.81, "Service Bus Standard"},
+			},
+			AWS: {
+				KindVM:               {70.08, "EC2 m6i.large"},
+				KindKubernetes:       {73.00, "EKS cluster"},
+				KindContainerService: {36.04, "Fargate, 1 vCPU 2 GB"},
+				KindDatabase:         {124.83, "RDS for PostgreSQL db.m6i.large"},
+				KindCache:            {24.82, "ElastiCache cache.t4g.small"},
+				KindObjectStorage:    {2.30, "S3 Standard, 100 GB"},
+				KindLoadBalancer:     {16.43, "Application Load Balancer"},
+				KindRegistry:         {1.00, "ECR, 10 GB"},
+				KindQueue:            {0.40, "SQS Standard, 1M requests"},
+			},
+			GCP: {
+				KindVM:               {48.91, "Compute Engine e2-standard-2"},
+				KindKubernetes:       {73.00, "GKE Standard cluster"},
+				KindContainerService: {57.82, "Cloud Run, 1 vCPU 2 GiB instance-based"},
+				KindDatabase:         {98.62, "Cloud SQL for PostgreSQL, 2 vCPU 7.5 GB"},
+				KindCache:            {35.77, "Memorystore for Redis Basic, 1 GB"},
+				KindObjectStorage:    {2.00, "Cloud Storage Standard, 100 GB"},
+				KindLoadBalancer:     {18.25, "Cloud Load Balancing, 1 forwarding rule"},
+				KindRegistry:         {1.00, "Artifact Registry, 10 GB"},
+				KindQueue:            {0.40, "Pub/Sub, 10 GB"},
+			},
+		},
+	}
+}
+
+// LoadPriceSheet reads a price sheet from a JSON file. Kinds and providers
+// the file leaves out keep their default prices.
+func LoadPriceSheet(path string) (*PriceSheet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read price sheet: %w", err)
+	}
+	var file PriceSheet
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse price sheet %s: %w", path, err)
+	}
+	sheet := DefaultPriceSheet()
+	if file.Region != "" {
+		sheet.Region = file.Region
+	}
+	if file.Updated != "" {
+		sheet.Updated = file.Updated
+	}
+	for provider, prices := range file.Prices {
+		if sheet.Prices[provider] == nil {
+			return nil, fmt.Errorf("price sheet %s: unknown provider %q", path, provider)
+		}
+		for kind, price := range prices {
+			sheet.Prices[provider][kind] = price
+		}
+	}
+	return sheet, nil
+}
+
+// PriceSheetFromEnv loads the sheet in QLP_PRICING_FILE, or returns the
+// default prices
+func PriceSheetFromEnv() (*PriceSheet, error) {
+	if path := config.GetEnvOrDefault("QLP_PRICING_FILE", ""); path != "" {
+		return LoadPriceSheet(path)
+	}
+	return DefaultPriceSheet(), nil
+}
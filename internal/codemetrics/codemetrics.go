@@ -0,0 +1,314 @@
+// Package codemetrics measures how maintainable generated Go, Python and
+// JavaScript code is: the cyclomatic complexity and length of each function,
+// token-based duplication across files, and a maintainability index per file.
+// Functions and files past the thresholds are reported as hotspots.
+package codemetrics
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Languages the metrics support
+const (
+	Go         = "go"
+	Python     = "python"
+	JavaScript = "javascript" // TypeScript is measured as JavaScript
+)
+
+// Language returns the language of a file, or "" when unsupported
+func Language(file string) string {
+	switch strings.ToLower(path.Ext(file)) {
+	case ".go":
+		return Go
+	case ".py":
+		return Python
+	case ".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx":
+		return JavaScript
+	}
+	return ""
+}
+
+// Function is the measure of one function or method
+type Function struct {
+	Name       string `json:"name"`
+	Line       int    `json:"line"`
+	Length     int    `json:"length"` // Lines, from signature to closing brace
+	Complexity int    `json:"complexity"`
+}
+
+// File is the measure of one source file
+type File struct {
+	Path            string     `json:"path"`
+	Language        string     `json:"language"`
+	Lines           int        `json:"lines"`      // Lines with code
+	Complexity      int        `json:"complexity"` // Cyclomatic complexity of the whole file
+	DuplicatedLines int        `json:"duplicated_lines"`
+	Maintainability float64    `json:"maintainability"` // 0-100, higher is easier to maintain
+	Functions       []Function `json:"functions"`
+}
+
+// Duplicate is a run of tokens repeated from an earlier place
+type Duplicate struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	EndLine   int    `json:"end_line"`
+	OtherFile string `json:"other_file"`
+	OtherLine int    `json:"other_line"`
+	Tokens    int    `json:"tokens"`
+}
+
+// Hotspot is a function or file past a threshold
+type Hotspot struct {
+	File     string  `json:"file"`
+	Line     int     `json:"line"`
+	Function string  `json:"function,omitempty"`
+	Metric   string  `json:"metric"` // complexity, length, duplication or maintainability
+	Value    float64 `json:"value"`
+	Limit    float64 `json:"limit"`
+	Severity string  `json:"severity"` // high or medium
+	Message  string  `json:"message"`
+}
+
+// Thresholds mark functions and files as hotspots
+type Thresholds struct {
+	Complexity      int     // Highest cyclomatic complexity of a function
+	FunctionLength  int     // Longest function, in lines
+	DuplicateTokens int     // Shortest run of tokens reported as duplicated
+	Maintainability float64 // Lowest maintainability index of a file
+}
+
+// DefaultThresholds are the usual limits: complexity 10, 60-line
+// functions, 50-token clones and a maintainability index of 20
+var DefaultThresholds = Thresholds{Complexity: 10, FunctionLength: 60, DuplicateTokens: 50, Maintainability: 20}
+
+// Report holds the metrics of a set of files
+type Report struct {
+	Files           []File      `json:"files"`
+	Duplicates      []Duplicate `json:"duplicates"`
+	Hotspots        []Hotspot   `json:"hotspots"`
+	Maintainability float64     `json:"maintainability"` // Mean over files, weighted by lines
+	Duplication     float64     `json:"duplication"`     // Share of code lines duplicated
+	Score           int         `json:"score"`           // 0-100, for the quality score
+}
+
+// Analyze measures files with the default thresholds. It returns nil when
+// no file is in a supported language.
+func Analyze(files map[string]string) *Report {
+	return AnalyzeWith(files, DefaultThresholds)
+}
+
+// AnalyzeWith measures files, flagging hotspots past thresholds
+func AnalyzeWith(files map[string]string, t Thresholds) *Report {
+	var paths []string
+	for p := range files {
+		if Language(p) != "" {
+			paths = append(paths, p)
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	sort.Strings(paths)
+
+	r := &Report{Files: []File{}, Duplicates: []Duplicate{}, Hotspots: []Hotspot{}}
+	tokens := make([][]lexToken, len(paths))
+	for i, p := range paths {
+		lang := Language(p)
+		tokens[i] = tokenize(lang, files[p])
+		f := measure(p, lang, files[p], tokens[i])
+		r.Files = append(r.Files, f)
+
+		for _, fn := range f.Functions {
+			if fn.Complexity > t.Complexity {
+				r.hotspot(Hotspot{File: p, Line: fn.Line, Function: fn.Name, Metric: "complexity",
+					Value: float64(fn.Complexity), Limit: float64(t.Complexity),
+					Message: fmt.Sprintf("%s has a cyclomatic complexity of %d", fn.Name, fn.Complexity)})
+			}
+			if fn.Length > t.FunctionLength {
+				r.hotspot(Hotspot{File: p, Line: fn.Line, Function: fn.Name, Metric: "length",
+					Value: float64(fn.Length), Limit: float64(t.FunctionLength),
+					Message: fmt.Sprintf("%s is %d lines long", fn.Name, fn.Length)})
+			}
+		}
+		if f.Maintainability < t.Maintainability {
+			h := Hotspot{File: p, Line: 1, Metric: "maintainability", Value: f.Maintainability, Limit: t.Maintainability,
+				Severity: "medium", Message: fmt.Sprintf("maintainability index %.1f", f.Maintainability)}
+			if f.Maintainability < t.Maintainability/2 {
+				h.Severity = "high"
+			}
+			r.Hotspots = append(r.Hotspots, h)
+		}
+	}
+
+	r.findDuplicates(paths, tokens, t.DuplicateTokens)
+
+	lines, duplicated := 0, 0
+	weighted := 0.0
+	for _, f := range r.Files {
+		lines += f.Lines
+		duplicated += f.DuplicatedLines
+		weighted += f.Maintainability * float64(f.Lines)
+	}
+	if lines > 0 {
+		r.Maintainability = round1(weighted / float64(lines))
+		r.Duplication = math.Round(float64(duplicated)/float64(lines)*1000) / 1000
+	}
+
+	sort.SliceStable(r.Hotspots, func(i, j int) bool {
+		hi, hj := r.Hotspots[i], r.Hotspots[j]
+		if hi.Severity != hj.Severity {
+			return hi.Severity == "high"
+		}
+		if hi.File != hj.File {
+			return hi.File < hj.File
+		}
+		return hi.Line < hj.Line
+	})
+	r.Score = r.score()
+	return r
+}
+
+// hotspot records a hotspot, high when it is twice the limit
+func (r *Report) hotspot(h Hotspot) {
+	h.Severity = "medium"
+	if h.Value > 2*h.Limit {
+		h.Severity = "high"
+	}
+	r.Hotspots = append(r.Hotspots, h)
+}
+
+// score takes 10 points per high hotspot, 4 per medium one and up to 20 for
+// duplication
+func (r *Report) score() int {
+	score := 100
+	for _, h := range r.Hotspots {
+		if h.Severity == "high" {
+			score -= 10
+		} else {
+			score -= 4
+		}
+	}
+	score -= min(20, int(math.Round(r.Duplication*50)))
+	return max(score, 0)
+}
+
+// decisions are the tokens that add a path through the code
+var decisions = map[string]map[string]bool{
+	Go:         set("if", "for", "case", "&&", "||"),
+	Python:     set("if", "elif", "for", "while", "except", "and", "or", "case"),
+	JavaScript: set("if", "for", "while", "case", "catch", "&&", "||", "??", "?"),
+}
+
+// measure computes the file metrics and finds its functions
+func measure(p, lang, src string, tokens []lexToken) File {
+	f := File{Path: p, Language: lang, Complexity: 1, Functions: []Function{}}
+	codeLines := make(map[int]bool)
+	operators, operands := make(map[string]bool), make(map[string]bool)
+	for _, t := range tokens {
+		codeLines[t.line] = true
+		if t.kind == operator {
+			operators[t.text] = true
+		} else {
+			operands[t.text] = true
+		}
+		if decisions[lang][t.text] {
+			f.Complexity++
+		}
+	}
+	f.Lines = len(codeLines)
+
+	switch lang {
+	case Go:
+		f.Functions = goFunctions(src)
+	case Python:
+		f.Functions = pythonFunctions(src, tokens)
+	case JavaScript:
+		f.Functions = jsFunctions(tokens)
+	}
+
+	// Halstead volume, then the maintainability index normalized to 0-100
+	volume := float64(len(tokens))
+	if vocabulary := len(operators) + len(operands); vocabulary > 1 {
+		volume *= math.Log2(float64(vocabulary))
+	}
+	mi := 171 - 5.2*math.Log(max(volume, 1)) - 0.23*float64(f.Complexity) - 16.2*math.Log(float64(max(f.Lines, 1)))
+	f.Maintainability = round1(min(max(mi*100/171, 0), 100))
+	return f
+}
+
+// findDuplicates reports every run of at least window tokens already seen
+// earlier, in the same file or another, and counts the lines they cover
+func (r *Report) findDuplicates(paths []string, tokens [][]lexToken, window int) {
+	if window <= 0 {
+		return
+	}
+	type place struct{ file, pos int }
+	seen := make(map[uint64]place)
+	for fi, toks := range tokens {
+		lines := make(map[int]bool)
+		for p := 0; p+window <= len(toks); {
+			h := windowHash(toks[p : p+window])
+			first, ok := seen[h]
+			if !ok {
+				seen[h] = place{fi, p}
+				p++
+				continue
+			}
+			other := tokens[first.file]
+			overlaps := func(n int) bool { return first.file == fi && first.pos+n > p }
+			if overlaps(window) || !sameTokens(other[first.pos:first.pos+window], toks[p:p+window]) {
+				p++
+				continue
+			}
+			n := window
+			for p+n < len(toks) && first.pos+n < len(other) && other[first.pos+n].text == toks[p+n].text && !overlaps(n+1) {
+				n++
+			}
+			r.Duplicates = append(r.Duplicates, Duplicate{
+				File:      paths[fi],
+				Line:      toks[p].line,
+				EndLine:   toks[p+n-1].line,
+				OtherFile: paths[first.file],
+				OtherLine: other[first.pos].line,
+				Tokens:    n,
+			})
+			for _, t := range toks[p : p+n] {
+				lines[t.line] = true
+			}
+			p += n
+		}
+		r.Files[fi].DuplicatedLines = len(lines)
+		if len(lines) > 0 {
+			r.Hotspots = append(r.Hotspots, Hotspot{File: paths[fi], Line: 1, Metric: "duplication",
+				Value: float64(len(lines)), Severity: "medium",
+				Message: fmt.Sprintf("%d of %d lines duplicate code found elsewhere", len(lines), r.Files[fi].Lines)})
+		}
+	}
+}
+
+func windowHash(toks []lexToken) uint64 {
+	h := fnv.New64a()
+	for _, t := range toks {
+		h.Write([]byte(t.text))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+func sameTokens(a, b []lexToken) bool {
+	for i := range a {
+		if a[i].text != b[i].text {
+			return false
+		}
+	}
+	return true
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,163 @@
+package codemetrics
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const goSource = `package orders
+
+import "errors"
+
+type Service struct{}
+
+// Route picks a handler for the order
+func (s *Service) Route(kind string, qty int, vip bool) (string, error) {
+	if qty <= 0 || kind == "" {
+		return "", errors.New("invalid")
+	}
+	switch kind {
+	case "express":
+		if vip && qty > 10 {
+			return "priority", nil
+		}
+		return "express", nil
+	case "bulk":
+		for i := 0; i < qty; i++ {
+			if i > 100 {
+				return "freight", nil
+			}
+		}
+	default:
+	}
+	return "standard", nil
+}
+
+func Total(prices []int) int {
+	sum := 0
+	for _, p := range prices {
+		sum += p
+	}
+	return sum
+}
+`
+
+const pySource = `import os
+
+
+def load(path, strict=False):
+    # read the config
+    if not os.path.exists(path):
+        if strict:
+            raise FileNotFoundError(path)
+        return {}
+    with open(path) as f:
+        return parse(f.read())
+
+
+async def parse(text):
+    return [line for line in text.splitlines() if line and not line.startswith("#")]
+`
+
+const jsSource = `const express = require("express");
+
+function handler(req, res) {
+  if (!req.body || !req.body.id) {
+    return res.status(400).send("missing id");
+  }
+  res.json({ id: req.body.id, ok: req.body.ok ?? true });
+}
+
+class Cart {
+  total(items) {
+    return items.reduce((sum, item) => sum + item.price, 0);
+  }
+}
+
+const validate = async (order) => {
+  try {
+    return order.items.length > 0 ? true : false;
+  } catch (err) {
+    return false;
+  }
+};
+`
+
+func TestAnalyze(t *testing.T) {
+	r := Analyze(map[string]string{
+		"orders/service.go": goSource,
+		"app/config.py":     pySource,
+		"web/server.js":     jsSource,
+		"README.md":         "# docs",
+	})
+	if r == nil || len(r.Files) != 3 {
+		t.Fatalf("report = %+v", r)
+	}
+
+	functions := make(map[string]Function)
+	for _, f := range r.Files {
+		if f.Maintainability <= 0 || f.Maintainability > 100 || f.Lines == 0 {
+			t.Errorf("%s: %+v", f.Path, f)
+		}
+		for _, fn := range f.Functions {
+			functions[f.Path+":"+fn.Name] = fn
+		}
+	}
+	for name, want := range map[string]Function{
+		"orders/service.go:Service.Route": {Line: 8, Length: 20, Complexity: 9},
+		"orders/service.go:Total":         {Line: 29, Length: 7, Complexity: 2},
+		"app/config.py:load":              {Line: 4, Length: 8, Complexity: 3},
+		"app/config.py:parse":             {Line: 14, Length: 2, Complexity: 4},
+		"web/server.js:handler":           {Line: 3, Length: 6, Complexity: 4},
+		"web/server.js:total":             {Line: 11, Length: 3, Complexity: 1},
+		"web/server.js:validate":          {Line: 16, Length: 7, Complexity: 3},
+	} {
+		got, ok := functions[name]
+		want.Name = got.Name
+		if !ok || got != want {
+			t.Errorf("%s = %+v, want %+v", name, got, want)
+		}
+	}
+	if len(r.Hotspots) != 0 || len(r.Duplicates) != 0 || r.Score != 100 {
+		t.Errorf("hotspots %+v, duplicates %+v, score %d", r.Hotspots, r.Duplicates, r.Score)
+	}
+
+	if Analyze(map[string]string{"main.tf": ""}) != nil {
+		t.Error("expected no report without supported files")
+	}
+}
+
+func TestHotspots(t *testing.T) {
+	// A long, branchy function, and the same block pasted into another file
+	var body strings.Builder
+	for i := 0; i < 25; i++ {
+		fmt.Fprintf(&body, "\tif x == %d {\n\t\treturn %d\n\t}\n", i, i*2)
+	}
+	long := "package calc\n\nfunc Lookup(x int) int {\n" + body.String() + "\treturn -1\n}\n"
+	copied := "package calc\n\nfunc Again(x int) int {\n" + body.String() + "\treturn 0\n}\n"
+
+	r := Analyze(map[string]string{"calc/a.go": long, "calc/b.go": copied})
+	metrics := make(map[string]Hotspot)
+	for _, h := range r.Hotspots {
+		metrics[h.File+" "+h.Metric] = h
+	}
+	if h := metrics["calc/a.go complexity"]; h.Function != "Lookup" || h.Value != 26 || h.Severity != "high" {
+		t.Errorf("complexity hotspot = %+v", h)
+	}
+	if h := metrics["calc/a.go length"]; h.Value != 78 || h.Severity != "medium" {
+		t.Errorf("length hotspot = %+v", h)
+	}
+	if h := metrics["calc/b.go duplication"]; h.Value != 77 {
+		t.Errorf("duplication hotspot = %+v", h)
+	}
+	if _, ok := metrics["calc/a.go duplication"]; ok {
+		t.Error("the first occurrence should not be reported as a duplicate")
+	}
+	if len(r.Duplicates) != 1 || r.Duplicates[0].OtherFile != "calc/a.go" || r.Duplicates[0].Line != 3 {
+		t.Errorf("duplicates = %+v", r.Duplicates)
+	}
+	if r.Duplication < 0.4 || r.Score >= 60 {
+		t.Errorf("duplication %.3f, score %d", r.Duplication, r.Score)
+	}
+}
@@ -0,0 +1,230 @@
+package codemetrics
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// goFunctions measures the functions and methods of a Go file. Closures
+// count towards the function that contains them.
+func goFunctions(src string) []Function {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return []Function{}
+	}
+	functions := []Function{}
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		name := fn.Name.Name
+		if fn.Recv != nil && len(fn.Recv.List) > 0 {
+			name = receiverName(fn.Recv.List[0].Type) + "." + name
+		}
+		complexity := 1
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt:
+				complexity++
+			case *ast.CaseClause:
+				if n.List != nil {
+					complexity++
+				}
+			case *ast.CommClause:
+				if n.Comm != nil {
+					complexity++
+				}
+			case *ast.BinaryExpr:
+				if n.Op == token.LAND || n.Op == token.LOR {
+					complexity++
+				}
+			}
+			return true
+		})
+		start, end := fset.Position(fn.Pos()).Line, fset.Position(fn.End()).Line
+		functions = append(functions, Function{Name: name, Line: start, Length: end - start + 1, Complexity: complexity})
+	}
+	return functions
+}
+
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return "?"
+}
+
+var pythonDef = regexp.MustCompile(`^(\s*)(?:async\s+)?def\s+(\w+)`)
+
+// pythonFunctions measures the functions of a Python file. A function ends
+// at the first line of code indented no deeper than its def; nested
+// functions count towards the enclosing one as well.
+func pythonFunctions(src string, tokens []lexToken) []Function {
+	lines := strings.Split(src, "\n")
+	functions := []Function{}
+	for i, line := range lines {
+		m := pythonDef.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		indent := indentation(m[1])
+		end := i
+		for j := i + 1; j < len(lines); j++ {
+			trimmed := strings.TrimSpace(lines[j])
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			if indentation(lines[j][:len(lines[j])-len(strings.TrimLeft(lines[j], " \t"))]) <= indent {
+				break
+			}
+			end = j
+		}
+		complexity := 1
+		for _, t := range tokens {
+			if t.line > i+1 && t.line <= end+1 && decisions[Python][t.text] {
+				complexity++
+			}
+		}
+		functions = append(functions, Function{Name: m[2], Line: i + 1, Length: end - i + 1, Complexity: complexity})
+	}
+	return functions
+}
+
+// indentation measures leading whitespace, a tab counting as 8 spaces
+func indentation(ws string) int {
+	n := 0
+	for _, c := range ws {
+		if c == '\t' {
+			n += 8
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// jsFunctions measures the function declarations, methods and block-bodied
+// arrow functions of a JavaScript or TypeScript file. Nested functions count
+// towards the enclosing one as well.
+func jsFunctions(tokens []lexToken) []Function {
+	functions := []Function{}
+	for k, t := range tokens {
+		var name string
+		open := -1
+		switch {
+		case t.text == "function":
+			name = "<anonymous>"
+			next := k + 1
+			if next < len(tokens) && tokens[next].text == "*" {
+				next++
+			}
+			if next < len(tokens) && tokens[next].kind == operand {
+				name = tokens[next].text
+				next++
+			}
+			if next < len(tokens) && tokens[next].text == "(" {
+				open = blockAfterParams(tokens, next)
+			}
+		case t.kind == operand && k+1 < len(tokens) && tokens[k+1].text == "(" &&
+			(k == 0 || (tokens[k-1].text != "function" && tokens[k-1].text != ".")):
+			// A method: name(params) {
+			name = t.text
+			open = blockAfterParams(tokens, k+1)
+		case t.text == "=>" && k+1 < len(tokens) && tokens[k+1].text == "{":
+			name = arrowName(tokens, k)
+			open = k + 1
+		}
+		if open < 0 {
+			continue
+		}
+		close := matching(tokens, open, "{", "}")
+		if close < 0 {
+			continue
+		}
+		complexity := 1
+		for _, inner := range tokens[open+1 : close] {
+			if decisions[JavaScript][inner.text] {
+				complexity++
+			}
+		}
+		functions = append(functions, Function{Name: name, Line: t.line, Length: tokens[close].line - t.line + 1, Complexity: complexity})
+	}
+	return functions
+}
+
+// blockAfterParams returns the index of the { opening the body after the
+// parameter list starting at paren, or -1
+func blockAfterParams(tokens []lexToken, paren int) int {
+	end := matching(tokens, paren, "(", ")")
+	if end < 0 {
+		return -1
+	}
+	next := end + 1
+	if next < len(tokens) && tokens[next].text == ":" {
+		// TypeScript return type: skip to the body
+		for next < len(tokens) && tokens[next].text != "{" && tokens[next].text != ";" {
+			next++
+		}
+	}
+	if next < len(tokens) && tokens[next].text == "{" {
+		return next
+	}
+	return -1
+}
+
+// arrowName names an arrow function after the variable or property it is
+// assigned to
+func arrowName(tokens []lexToken, arrow int) string {
+	k := arrow - 1
+	if k >= 0 && tokens[k].text == ")" {
+		depth := 0
+		for ; k >= 0; k-- {
+			switch tokens[k].text {
+			case ")":
+				depth++
+			case "(":
+				depth--
+			}
+			if depth == 0 {
+				break
+			}
+		}
+	}
+	k--
+	if k >= 0 && tokens[k].text == "async" {
+		k--
+	}
+	if k >= 1 && (tokens[k].text == "=" || tokens[k].text == ":") && tokens[k-1].kind == operand {
+		return tokens[k-1].text
+	}
+	return "<anonymous>"
+}
+
+// matching returns the index of the token closing the one at start
+func matching(tokens []lexToken, start int, open, close string) int {
+	depth := 0
+	for k := start; k < len(tokens); k++ {
+		switch tokens[k].text {
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return k
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,171 @@
+package codemetrics
+
+import (
+	"go/scanner"
+	"go/token"
+	"strings"
+	"unicode"
+)
+
+// tokenKind separates operands from operators for the Halstead measures
+type tokenKind int
+
+const (
+	operator tokenKind = iota
+	operand
+)
+
+// lexToken is one token of a source file
+type lexToken struct {
+	text string
+	kind tokenKind
+	line int
+}
+
+// tokenize splits a file into tokens without comments or whitespace
+func tokenize(lang, src string) []lexToken {
+	if lang == Go {
+		return tokenizeGo(src)
+	}
+	return tokenizeC(lang, src)
+}
+
+func tokenizeGo(src string) []lexToken {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, []byte(src), nil, 0)
+
+	var tokens []lexToken
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return tokens
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			continue // inserted automatically
+		}
+		t := lexToken{text: tok.String(), kind: operator, line: file.Line(pos)}
+		if tok == token.IDENT || tok.IsLiteral() {
+			t.text, t.kind = lit, operand
+		}
+		tokens = append(tokens, t)
+	}
+}
+
+// multiOperators are the operators of more than one character, longest
+// first
+var multiOperators = []string{
+	">>>=", "===", "!==", "**=", "...", "<<=", ">>=", "//=", ">>>",
+	"=>", "&&", "||", "??", "?.", "==", "!=", "<=", ">=", "+=", "-=", "*=",
+	"/=", "%=", "&=", "|=", "^=", "**", "//", "->", ":=", "<<", ">>", "++", "--",
+}
+
+// keywords are the words counted as operators rather than operands
+var keywords = map[string]map[string]bool{
+	Python: set("and", "as", "assert", "async", "await", "break", "class", "continue", "def", "del", "elif",
+		"else", "except", "finally", "for", "from", "global", "if", "import", "in", "is", "lambda",
+		"nonlocal", "not", "or", "pass", "raise", "return", "try", "while", "with", "yield", "match", "case"),
+	JavaScript: set("async", "await", "break", "case", "catch", "class", "const", "continue", "default",
+		"delete", "do", "else", "export", "extends", "finally", "for", "function", "if", "import", "in",
+		"instanceof", "let", "new", "of", "return", "switch", "throw", "try", "typeof", "var", "void",
+		"while", "yield"),
+}
+
+func set(words ...string) map[string]bool {
+	m := make(map[string]bool, len(words))
+	for _, w := range words {
+		m[w] = true
+	}
+	return m
+}
+
+// tokenizeC is a small lexer for Python and JavaScript: it knows their
+// comments and strings, which is all the metrics need
+func tokenizeC(lang, src string) []lexToken {
+	var tokens []lexToken
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case lang == Python && c == '#', lang == JavaScript && strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case lang == JavaScript && strings.HasPrefix(src[i:], "/*"):
+			end := len(src)
+			if n := strings.Index(src[i+2:], "*/"); n >= 0 {
+				end = i + 2 + n + 2
+			}
+			line += strings.Count(src[i:end], "\n")
+			i = end
+		case c == '"' || c == '\'' || (lang == JavaScript && c == '`'):
+			start, startLine := i, line
+			quote := string(c)
+			if lang == Python && strings.HasPrefix(src[i:], strings.Repeat(quote, 3)) {
+				quote = strings.Repeat(quote, 3)
+			}
+			i += len(quote)
+			for i < len(src) && !strings.HasPrefix(src[i:], quote) {
+				if src[i] == '\n' {
+					if len(quote) == 1 && c != '`' {
+						break // unterminated; the newline is lexed as usual
+					}
+					line++
+				}
+				if src[i] == '\\' && i+1 < len(src) {
+					if src[i+1] == '\n' {
+						line++
+					}
+					i++
+				}
+				i++
+			}
+			if strings.HasPrefix(src[i:], quote) {
+				i += len(quote)
+			}
+			tokens = append(tokens, lexToken{text: src[start:i], kind: operand, line: startLine})
+		case isWordStart(rune(c)):
+			start := i
+			for i < len(src) && isWordPart(rune(src[i])) {
+				i++
+			}
+			word := src[start:i]
+			kind := operand
+			if keywords[lang][word] {
+				kind = operator
+			}
+			tokens = append(tokens, lexToken{text: word, kind: kind, line: line})
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(src) && (isWordPart(rune(src[i])) || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, lexToken{text: src[start:i], kind: operand, line: line})
+		default:
+			op := src[i : i+1]
+			for _, m := range multiOperators {
+				if strings.HasPrefix(src[i:], m) {
+					op = m
+					break
+				}
+			}
+			i += len(op)
+			tokens = append(tokens, lexToken{text: op, kind: operator, line: line})
+		}
+	}
+	return tokens
+}
+
+func isWordStart(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || r >= 0x80
+}
+
+func isWordPart(r rune) bool {
+	return isWordStart(r) || unicode.IsDigit(r)
+}
@@ -17,7 +17,8 @@ func LoadEnv() {
 	}
 	defer file.Close()
 
-	fmt.Printf("📝 Loading environment from .env file\n")
+	// Diagnostics go to stderr so machine-readable command output stays clean
+	fmt.Fprintf(os.Stderr, "📝 Loading environment from .env file\n")
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
@@ -44,7 +45,7 @@ func LoadEnv() {
 	}
 	
 	if err := scanner.Err(); err != nil {
-		fmt.Printf("⚠️  Error reading .env file: %v\\n", err)
+		fmt.Fprintf(os.Stderr, "⚠️  Error reading .env file: %v\n", err)
 	}
 }
 
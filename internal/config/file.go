@@ -0,0 +1,354 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultProfile is used when neither the command line, QLP_PROFILE nor the
+// config file names one
+const DefaultProfile = "dev"
+
+// Candidate config file names, searched in the working directory
+var configFileNames = []string{"qlp.yaml", "qlp.yml", "qlp.json"}
+
+var (
+	keyPattern     = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+	profilePattern = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+)
+
+// File is a qlp.yaml/qlp.json config file. Settings are environment variable
+// names, either flat or nested (llm: {provider: azure} is LLM_PROVIDER):
+//
+//	version: 1
+//	profile: dev
+//	settings:
+//	  QLP_MAX_CONCURRENT_AGENTS: 10
+//	profiles:
+//	  prod:
+//	    qlp: {enable_audit_logging: true}
+type File struct {
+	Version  int                               `yaml:"version" json:"version"`
+	Profile  string                            `yaml:"profile" json:"profile"`
+	Settings map[string]interface{}            `yaml:"settings" json:"settings"`
+	Profiles map[string]map[string]interface{} `yaml:"profiles" json:"profiles"`
+
+	Path string `yaml:"-" json:"-"`
+}
+
+// Issue is a problem found by Validate
+type Issue struct {
+	Severity string `json:"severity"`          // error or warning
+	Section  string `json:"section,omitempty"` // Top-level section other than settings, e.g. install
+	Profile  string `json:"profile,omitempty"`
+	Key      string `json:"key,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (i Issue) String() string {
+	where := "settings"
+	if i.Section != "" {
+		where = i.Section
+	}
+	if i.Profile != "" {
+		where = "profiles." + i.Profile
+	}
+	if i.Key != "" {
+		where += "." + i.Key
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Severity, where, i.Message)
+}
+
+// FindFile returns the config file named by QLP_CONFIG or the first qlp.yaml,
+// qlp.yml or qlp.json in the working directory, or "" when there is none
+func FindFile() string {
+	if path := os.Getenv("QLP_CONFIG"); path != "" {
+		return path
+	}
+	for _, name := range configFileNames {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// LoadFile parses a YAML or JSON config file
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	f := &File{Path: path}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, f)
+	} else {
+		err = yaml.Unmarshal(data, f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Validate checks the file against the config schema. Errors make the file
+// unusable; warnings are reported but the file still applies.
+func (f *File) Validate() []Issue {
+	var issues []Issue
+	if f.Version != 0 && f.Version != 1 {
+		issues = append(issues, Issue{Severity: "error", Message: fmt.Sprintf("unsupported version %d", f.Version)})
+	}
+	if f.Profile != "" && len(f.Profiles) > 0 {
+		if _, ok := f.Profiles[f.Profile]; !ok {
+			issues = append(issues, Issue{Severity: "error", Message: fmt.Sprintf("default profile %q is not defined", f.Profile)})
+		}
+	}
+
+	issues = append(issues, validateSettings("", f.Settings)...)
+	for _, name := range sortedKeys(f.Profiles) {
+		if !profilePattern.MatchString(name) {
+			issues = append(issues, Issue{Severity: "error", Profile: name, Message: "profile names must be lowercase letters, digits and dashes"})
+		}
+		issues = append(issues, validateSettings(name, f.Profiles[name])...)
+	}
+	return issues
+}
+
+// HasErrors reports whether any issue is an error
+func HasErrors(issues []Issue) bool {
+	for _, issue := range issues {
+		if issue.Severity == "error" {
+			return true
+		}
+	}
+	return false
+}
+
+func validateSettings(profile string, settings map[string]interface{}) []Issue {
+	var issues []Issue
+	values := flatten("", settings)
+	for _, key := range sortedKeys(values) {
+		value := values[key]
+		issue := Issue{Severity: "error", Profile: profile, Key: key}
+		switch {
+		case !keyPattern.MatchString(key):
+			issue.Message = "setting names must be environment variable names (A-Z, 0-9, _)"
+		case isBoolKey(key) && value != "true" && value != "false":
+			issue.Message = fmt.Sprintf("expected true or false, got %q", value)
+		case isDurationKey(key) && !isDuration(value):
+			issue.Message = fmt.Sprintf("expected a duration such as 30s or 5m, got %q", value)
+		case isIntKey(key) && !isInt(value):
+			issue.Message = fmt.Sprintf("expected an integer, got %q", value)
+		case IsSecretKey(key) && value != "":
+			issue.Severity = "warning"
+			issue.Message = "secret values belong in the environment or a secret store, not the config file"
+		default:
+			continue
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// Resolve returns the file's settings for profile: the base settings
+// overlaid with the profile's own. The implicit "dev" profile may be left
+// out of the file; any other missing profile is an error.
+func (f *File) Resolve(profile string) (map[string]string, error) {
+	if profile != "" && profile != DefaultProfile && len(f.Profiles) > 0 {
+		if _, ok := f.Profiles[profile]; !ok {
+			return nil, fmt.Errorf("profile %q is not defined in %s (available: %s)", profile, f.Path, strings.Join(sortedKeys(f.Profiles), ", "))
+		}
+	}
+	if issues := f.Validate(); HasErrors(issues) {
+		return nil, fmt.Errorf("invalid config file %s: %s", f.Path, issues[0])
+	}
+
+	values := flatten("", f.Settings)
+	overlay := flatten("", f.Profiles[profile])
+	for key, value := range overlay {
+		values[key] = value
+	}
+	return values, nil
+}
+
+// Setting is one effective value and where it came from
+type Setting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"` // env, profile or file
+}
+
+// Effective is the configuration in force after Init
+type Effective struct {
+	Path     string    `json:"path,omitempty"`
+	Profile  string    `json:"profile"`
+	Settings []Setting `json:"settings"`
+	Issues   []Issue   `json:"issues,omitempty"`
+}
+
+// Init loads .env and then the config file, applying the selected profile.
+// Variables already set in the environment always win over the file, so any
+// setting can be overridden per run. profile may be empty to use QLP_PROFILE,
+// the file's default profile or "dev".
+func Init(profile string) (*Effective, error) {
+	LoadEnv()
+
+	eff := &Effective{Profile: activeProfile(profile, nil)}
+	path := FindFile()
+	if path == "" {
+		return eff, nil
+	}
+	f, err := LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	eff.Path = path
+	eff.Profile = activeProfile(profile, f)
+	eff.Issues = f.Validate()
+
+	values, err := f.Resolve(eff.Profile)
+	if err != nil {
+		return nil, err
+	}
+	fromProfile := flatten("", f.Profiles[eff.Profile])
+
+	for _, key := range sortedKeys(values) {
+		setting := Setting{Key: key, Value: values[key], Source: "file"}
+		if _, ok := fromProfile[key]; ok {
+			setting.Source = "profile"
+		}
+		if current, ok := os.LookupEnv(key); ok && current != "" {
+			setting.Value = current
+			setting.Source = "env"
+		} else {
+			os.Setenv(key, setting.Value)
+		}
+		eff.Settings = append(eff.Settings, setting)
+	}
+	os.Setenv("QLP_PROFILE", eff.Profile)
+	return eff, nil
+}
+
+// Masked returns the settings with secret values hidden, for display
+func (e *Effective) Masked() []Setting {
+	out := make([]Setting, len(e.Settings))
+	for i, s := range e.Settings {
+		if IsSecretKey(s.Key) && s.Value != "" {
+			s.Value = "***"
+		}
+		out[i] = s
+	}
+	return out
+}
+
+func activeProfile(flagValue string, f *File) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if env := os.Getenv("QLP_PROFILE"); env != "" {
+		return env
+	}
+	if f != nil && f.Profile != "" {
+		return f.Profile
+	}
+	return DefaultProfile
+}
+
+// flatten turns nested settings into environment variable names
+func flatten(prefix string, settings map[string]interface{}) map[string]string {
+	values := make(map[string]string)
+	for key, raw := range settings {
+		name := strings.ToUpper(key)
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+		switch v := raw.(type) {
+		case map[string]interface{}:
+			for k, val := range flatten(name, v) {
+				values[k] = val
+			}
+		default:
+			values[name] = formatValue(v)
+		}
+	}
+	return values
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = formatValue(item)
+		}
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func isBoolKey(key string) bool {
+	return strings.HasPrefix(key, "QLP_ENABLE_") || strings.HasSuffix(key, "_ENABLED")
+}
+
+func isDurationKey(key string) bool {
+	return strings.HasSuffix(key, "_TIMEOUT") || strings.HasSuffix(key, "_TTL") || strings.HasSuffix(key, "_INTERVAL")
+}
+
+func isIntKey(key string) bool {
+	return strings.HasSuffix(key, "_PORT") || strings.Contains(key, "_MAX_")
+}
+
+// IsSecretKey reports whether a setting holds a credential, by its name
+func IsSecretKey(key string) bool {
+	if isBoolKey(key) {
+		return false
+	}
+	for _, marker := range []string{"SECRET", "PASSWORD", "TOKEN", "API_KEY", "CONNECTION_STRING"} {
+		if strings.Contains(key, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDuration(value string) bool {
+	_, err := time.ParseDuration(value)
+	return err == nil
+}
+
+func isInt(value string) bool {
+	_, err := strconv.Atoi(value)
+	return err == nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
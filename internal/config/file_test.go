@@ -0,0 +1,126 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testConfig = `version: 1
+profile: staging
+settings:
+  QLP_MAX_CONCURRENT_AGENTS: 10
+  llm:
+    provider: azure
+profiles:
+  staging:
+    QLP_ENABLE_WORKSPACES: true
+  prod:
+    qlp:
+      enable_audit_logging: true
+      agent_timeout: 600s
+    QLP_MAX_CONCURRENT_AGENTS: 50
+`
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+	return path
+}
+
+func TestResolveProfileOverlay(t *testing.T) {
+	f, err := LoadFile(writeConfig(t, "qlp.yaml", testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issues := f.Validate(); len(issues) != 0 {
+		t.Fatalf("Validate() = %v", issues)
+	}
+
+	values, err := f.Resolve("prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"QLP_MAX_CONCURRENT_AGENTS": "50",
+		"LLM_PROVIDER":              "azure",
+		"QLP_ENABLE_AUDIT_LOGGING":  "true",
+		"QLP_AGENT_TIMEOUT":         "600s",
+	}
+	for key, value := range want {
+		if values[key] != value {
+			t.Errorf("%s = %q, want %q", key, values[key], value)
+		}
+	}
+	if _, ok := values["QLP_ENABLE_WORKSPACES"]; ok {
+		t.Error("staging setting leaked into prod")
+	}
+
+	if _, err := f.Resolve("qa"); err == nil {
+		t.Error("Resolve() accepted an undefined profile")
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	f, err := LoadFile(writeConfig(t, "qlp.json", `{
+  "profile": "prod",
+  "settings": {"QLP_ENABLE_AUDIT_LOGGING": "yes", "QLP_DRAIN_TIMEOUT": 60, "bad-key": 1},
+  "profiles": {"Dev": {"AZURE_CLIENT_SECRET": "s3cret"}}
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issues := f.Validate()
+	errors, warnings := 0, 0
+	for _, issue := range issues {
+		if issue.Severity == "error" {
+			errors++
+		} else {
+			warnings++
+		}
+	}
+	// undefined default profile, bool, duration, key name, profile name
+	if errors != 5 || warnings != 1 {
+		t.Errorf("errors=%d warnings=%d, want 5 and 1: %v", errors, warnings, issues)
+	}
+	if !HasErrors(issues) {
+		t.Error("HasErrors() = false")
+	}
+}
+
+func TestInitEnvironmentOverridesFile(t *testing.T) {
+	writeConfig(t, "qlp.yaml", testConfig)
+	t.Setenv("QLP_CONFIG", "")
+	t.Setenv("QLP_PROFILE", "")
+	t.Setenv("QLP_MAX_CONCURRENT_AGENTS", "3")
+	t.Setenv("QLP_ENABLE_WORKSPACES", "")
+	t.Setenv("LLM_PROVIDER", "")
+
+	effective, err := Init("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if effective.Profile != "staging" {
+		t.Errorf("profile = %q, want the file default staging", effective.Profile)
+	}
+	if got := os.Getenv("QLP_MAX_CONCURRENT_AGENTS"); got != "3" {
+		t.Errorf("QLP_MAX_CONCURRENT_AGENTS = %q, want the environment value", got)
+	}
+	if got := os.Getenv("QLP_ENABLE_WORKSPACES"); got != "true" {
+		t.Errorf("QLP_ENABLE_WORKSPACES = %q, want the profile value", got)
+	}
+
+	sources := make(map[string]string)
+	for _, s := range effective.Settings {
+		sources[s.Key] = s.Source
+	}
+	if sources["QLP_MAX_CONCURRENT_AGENTS"] != "env" || sources["QLP_ENABLE_WORKSPACES"] != "profile" || sources["LLM_PROVIDER"] != "file" {
+		t.Errorf("sources = %v", sources)
+	}
+}
@@ -0,0 +1,247 @@
+// Package constraints resolves per-tenant default standards for intents and
+// validates generated files against an intent's constraints.
+package constraints
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"QLP/internal/config"
+	"QLP/internal/models"
+)
+
+// TenantDefaults serves default constraints per tenant from a JSON file of the
+// form {"tenant-id": {...}}; the "*" tenant applies to tenants without an entry
+type TenantDefaults struct {
+	defaults map[string]*models.Constraints
+}
+
+// LoadTenantDefaults reads tenant constraint defaults from path
+func LoadTenantDefaults(path string) (*TenantDefaults, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenant constraints: %w", err)
+	}
+	var defaults map[string]*models.Constraints
+	if err := json.Unmarshal(data, &defaults); err != nil {
+		return nil, fmt.Errorf("failed to parse tenant constraints: %w", err)
+	}
+	return &TenantDefaults{defaults: defaults}, nil
+}
+
+// For returns the defaults for a tenant, or nil
+func (d *TenantDefaults) For(tenantID string) *models.Constraints {
+	if d == nil {
+		return nil
+	}
+	if c, ok := d.defaults[tenantID]; ok {
+		return c
+	}
+	return d.defaults["*"]
+}
+
+var (
+	defaultMu       sync.RWMutex
+	defaultDefaults *TenantDefaults
+)
+
+// Default returns the tenant defaults configured by InitFromEnv, or nil
+func Default() *TenantDefaults {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultDefaults
+}
+
+// SetDefault replaces the process-wide tenant defaults
+func SetDefault(d *TenantDefaults) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultDefaults = d
+}
+
+// InitFromEnv loads tenant defaults from QLP_TENANT_CONSTRAINTS_FILE when set
+func InitFromEnv() (*TenantDefaults, error) {
+	path := config.GetEnvOrDefault("QLP_TENANT_CONSTRAINTS_FILE", "")
+	if path == "" {
+		return nil, nil
+	}
+	defaults, err := LoadTenantDefaults(path)
+	if err != nil {
+		return nil, err
+	}
+	SetDefault(defaults)
+	return defaults, nil
+}
+
+// Resolve merges intent constraints over the tenant's defaults
+func Resolve(tenantID string, intent *models.Constraints) *models.Constraints {
+	return intent.Merge(Default().For(tenantID))
+}
+
+// Violation is a generated file that breaks a constraint
+type Violation struct {
+	Rule    string `json:"rule"`
+	File    string `json:"file"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("[%s] %s: %s", v.Rule, v.File, v.Message)
+}
+
+// extensionLanguages maps source file extensions to language names
+var extensionLanguages = map[string]string{
+	".go":    "go",
+	".py":    "python",
+	".js":    "javascript",
+	".jsx":   "javascript",
+	".mjs":   "javascript",
+	".cjs":   "javascript",
+	".ts":    "typescript",
+	".tsx":   "typescript",
+	".java":  "java",
+	".kt":    "kotlin",
+	".cs":    "csharp",
+	".rb":    "ruby",
+	".rs":    "rust",
+	".php":   "php",
+	".scala": "scala",
+	".swift": "swift",
+}
+
+// languageAliases expands common names for the languages above
+var languageAliases = map[string][]string{
+	"golang":     {"go"},
+	"node":       {"javascript", "typescript"},
+	"nodejs":     {"javascript", "typescript"},
+	"node.js":    {"javascript", "typescript"},
+	"js":         {"javascript"},
+	"ts":         {"typescript", "javascript"},
+	"typescript": {"typescript", "javascript"},
+	"c#":         {"csharp"},
+	".net":       {"csharp"},
+	"dotnet":     {"csharp"},
+}
+
+// cloudMarkers identify provider-specific resources in infrastructure files
+var cloudMarkers = map[string]*regexp.Regexp{
+	"aws":   regexp.MustCompile(`provider\s+"aws"|\baws_[a-z0-9_]+\b|arn:aws:|AWS::`),
+	"gcp":   regexp.MustCompile(`provider\s+"google"|\bgoogle_[a-z0-9_]+\b|gcr\.io/|\.googleapis\.com`),
+	"azure": regexp.MustCompile(`provider\s+"azurerm"|\bazurerm_[a-z0-9_]+\b|Microsoft\.[A-Z][A-Za-z]+/|\.azurecr\.io`),
+}
+
+var cloudAliases = map[string]string{
+	"amazon": "aws", "google": "gcp", "gcloud": "gcp", "azure": "azure", "microsoft": "azure",
+}
+
+var namingPatterns = map[string]*regexp.Regexp{
+	"snake_case": regexp.MustCompile(`^[a-z0-9]+(_[a-z0-9]+)*$`),
+	"kebab-case": regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`),
+	"camelcase":  regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`),
+	"pascalcase": regexp.MustCompile(`^[A-Z][a-zA-Z0-9]*$`),
+}
+
+// Validate checks generated files against constraints and returns violations
+// sorted by file
+func Validate(c *models.Constraints, files map[string]string) []Violation {
+	if c.IsZero() {
+		return nil
+	}
+
+	allowed := allowedLanguages(c.Languages)
+	cloud := strings.ToLower(strings.TrimSpace(c.Cloud))
+	if alias, ok := cloudAliases[cloud]; ok {
+		cloud = alias
+	}
+	naming := namingPatterns[strings.ToLower(strings.TrimSpace(c.Naming))]
+
+	var violations []Violation
+	for filePath, content := range files {
+		ext := strings.ToLower(path.Ext(filePath))
+		language, isSource := extensionLanguages[ext]
+
+		if isSource && len(allowed) > 0 && !allowed[language] {
+			violations = append(violations, Violation{
+				Rule:    "language",
+				File:    filePath,
+				Message: fmt.Sprintf("%s file in a project limited to %s", language, strings.Join(c.Languages, ", ")),
+			})
+		}
+
+		if isSource && naming != nil {
+			base := strings.TrimSuffix(path.Base(filePath), path.Ext(filePath))
+			// Test and declaration suffixes follow language convention, not project naming
+			base = strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(base, "_test"), ".test"), ".d")
+			if !naming.MatchString(base) {
+				violations = append(violations, Violation{
+					Rule:    "naming",
+					File:    filePath,
+					Message: fmt.Sprintf("file name does not follow %s", c.Naming),
+				})
+			}
+		}
+
+		if cloud != "" {
+			for provider, marker := range cloudMarkers {
+				if provider != cloud && marker.MatchString(content) {
+					violations = append(violations, Violation{
+						Rule:    "cloud",
+						File:    filePath,
+						Message: fmt.Sprintf("references %s resources in a %s project", provider, cloud),
+					})
+				}
+			}
+		}
+
+		for _, term := range c.Forbidden {
+			if mentions(filePath, content, term) {
+				violations = append(violations, Violation{
+					Rule:    "forbidden",
+					File:    filePath,
+					Message: fmt.Sprintf("uses forbidden technology %q", term),
+				})
+			}
+		}
+	}
+
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].File != violations[j].File {
+			return violations[i].File < violations[j].File
+		}
+		return violations[i].Rule < violations[j].Rule
+	})
+	return violations
+}
+
+func allowedLanguages(languages []string) map[string]bool {
+	if len(languages) == 0 {
+		return nil
+	}
+	allowed := make(map[string]bool)
+	for _, l := range languages {
+		l = strings.ToLower(strings.TrimSpace(l))
+		if expanded, ok := languageAliases[l]; ok {
+			for _, e := range expanded {
+				allowed[e] = true
+			}
+			continue
+		}
+		allowed[l] = true
+	}
+	return allowed
+}
+
+func mentions(filePath, content, term string) bool {
+	term = strings.TrimSpace(term)
+	if term == "" {
+		return false
+	}
+	pattern := regexp.MustCompile(`(?i)(^|[^A-Za-z0-9])` + regexp.QuoteMeta(term) + `($|[^A-Za-z0-9])`)
+	return pattern.MatchString(filePath) || pattern.MatchString(content)
+}
@@ -0,0 +1,85 @@
+package constraints
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"QLP/internal/models"
+)
+
+func TestValidateLanguageAndNaming(t *testing.T) {
+	c := &models.Constraints{Languages: []string{"golang"}, Naming: "snake_case"}
+	files := map[string]string{
+		"cmd/server/main.go":          "package main\n",
+		"internal/user_store.go":      "package internal\n",
+		"internal/user_store_test.go": "package internal\n",
+		"internal/userHandler.go":     "package internal\n",
+		"scripts/seed.py":             "print('seed')\n",
+		"Dockerfile":                  "FROM golang:1.21\n",
+	}
+
+	violations := Validate(c, files)
+	if len(violations) != 2 {
+		t.Fatalf("expected 2 violations, got %v", violations)
+	}
+	if violations[0].File != "internal/userHandler.go" || violations[0].Rule != "naming" {
+		t.Errorf("unexpected first violation: %v", violations[0])
+	}
+	if violations[1].File != "scripts/seed.py" || violations[1].Rule != "language" {
+		t.Errorf("unexpected second violation: %v", violations[1])
+	}
+}
+
+func TestValidateCloudAndForbidden(t *testing.T) {
+	c := &models.Constraints{Cloud: "azure", Forbidden: []string{"mongodb"}}
+	files := map[string]string{
+		"terraform/main.tf": "provider \"azurerm\" {}\nresource \"aws_s3_bucket\" \"logs\" {}\n",
+		"db.go":             "import \"go.mongodb.org/mongo-driver/mongo\"\n",
+		"store.go":          "// uses mongodbish naming but no driver\n",
+	}
+
+	violations := Validate(c, files)
+	rules := make(map[string]string)
+	for _, v := range violations {
+		rules[v.File] = v.Rule
+	}
+	if rules["terraform/main.tf"] != "cloud" || rules["db.go"] != "forbidden" {
+		t.Errorf("unexpected violations: %v", violations)
+	}
+	if _, ok := rules["store.go"]; ok {
+		t.Errorf("forbidden term matched inside a longer word: %v", violations)
+	}
+}
+
+func TestResolveMergesTenantDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "constraints.json")
+	os.WriteFile(path, []byte(`{
+		"acme": {"languages": ["go"], "cloud": "azure", "notes": ["Use structured logging"]},
+		"*": {"languages": ["python"]}
+	}`), 0o600)
+
+	defaults, err := LoadTenantDefaults(path)
+	if err != nil {
+		t.Fatalf("LoadTenantDefaults failed: %v", err)
+	}
+	SetDefault(defaults)
+	defer SetDefault(nil)
+
+	resolved := Resolve("acme", &models.Constraints{Frameworks: []string{"gin"}, Notes: []string{"Expose /healthz"}})
+	if resolved.Cloud != "azure" || resolved.Languages[0] != "go" || resolved.Frameworks[0] != "gin" {
+		t.Errorf("unexpected merge: %+v", resolved)
+	}
+	if len(resolved.Notes) != 2 {
+		t.Errorf("expected notes from both tenant and intent, got %v", resolved.Notes)
+	}
+	if got := Resolve("globex", nil); got.Languages[0] != "python" {
+		t.Errorf("expected fallback defaults, got %+v", got)
+	}
+
+	standards := strings.Join(resolved.Standards(), "\n")
+	if !strings.Contains(standards, "Use only these languages: go") || !strings.Contains(standards, "Expose /healthz") {
+		t.Errorf("unexpected standards:\n%s", standards)
+	}
+}
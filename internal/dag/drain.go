@@ -0,0 +1,242 @@
+package dag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/config"
+	"QLP/internal/logger"
+	"QLP/internal/models"
+	"go.uber.org/zap"
+)
+
+// ErrDraining is returned by ExecuteTaskGraph when execution stopped because of a drain
+var ErrDraining = errors.New("executor is draining")
+
+// drainState tracks in-flight tasks so shutdown can wait for them
+type drainState struct {
+	drainMu   sync.Mutex
+	draining  bool
+	drainCh   chan struct{}
+	inFlight  int
+	idleCh    chan struct{}
+	drainOnce sync.Once
+}
+
+func newDrainState() drainState {
+	return drainState{drainCh: make(chan struct{})}
+}
+
+// IsDraining reports whether the executor has stopped accepting new tasks
+func (ds *drainState) IsDraining() bool {
+	ds.drainMu.Lock()
+	defer ds.drainMu.Unlock()
+	return ds.draining
+}
+
+// InFlight returns the number of tasks currently executing
+func (ds *drainState) InFlight() int {
+	ds.drainMu.Lock()
+	defer ds.drainMu.Unlock()
+	return ds.inFlight
+}
+
+func (ds *drainState) beginTask() bool {
+	ds.drainMu.Lock()
+	defer ds.drainMu.Unlock()
+	if ds.draining {
+		return false
+	}
+	ds.inFlight++
+	return true
+}
+
+func (ds *drainState) endTask() {
+	ds.drainMu.Lock()
+	defer ds.drainMu.Unlock()
+	ds.inFlight--
+	if ds.inFlight == 0 && ds.idleCh != nil {
+		close(ds.idleCh)
+		ds.idleCh = nil
+	}
+}
+
+// idle returns a channel that is closed once no tasks are in flight
+func (ds *drainState) idle() <-chan struct{} {
+	ds.drainMu.Lock()
+	defer ds.drainMu.Unlock()
+	if ds.inFlight == 0 {
+		ch := make(chan struct{})
+		close(ch)
+		return ch
+	}
+	if ds.idleCh == nil {
+		ds.idleCh = make(chan struct{})
+	}
+	return ds.idleCh
+}
+
+func (ds *drainState) waitInFlight(ctx context.Context) {
+	select {
+	case <-ds.idle():
+	case <-ctx.Done():
+	}
+}
+
+// Drain stops the executor from starting new tasks and waits for in-flight
+// tasks to finish until ctx is done. Progress is logged while waiting.
+func (de *DAGExecutor) Drain(ctx context.Context) error {
+	de.drainOnce.Do(func() {
+		de.drainMu.Lock()
+		de.draining = true
+		de.drainMu.Unlock()
+		close(de.drainCh)
+	})
+
+	logger.WithComponent("dag").Info("Draining executor",
+		zap.Int("in_flight", de.InFlight()))
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	idle := de.idle()
+	for {
+		select {
+		case <-idle:
+			logger.WithComponent("dag").Info("Executor drained")
+			return nil
+		case <-ticker.C:
+			logger.WithComponent("dag").Info("Waiting for in-flight tasks",
+				zap.Int("in_flight", de.InFlight()))
+		case <-ctx.Done():
+			remaining := de.InFlight()
+			logger.WithComponent("dag").Warn("Drain deadline reached with tasks still running",
+				zap.Int("in_flight", remaining))
+			return fmt.Errorf("drain deadline exceeded with %d tasks in flight: %w", remaining, ctx.Err())
+		}
+	}
+}
+
+// Checkpoint records tasks that did not complete so they can be requeued.
+// IntentID names the intent whose run record holds the completed results.
+type Checkpoint struct {
+	GraphID        string                       `json:"graph_id"`
+	IntentID       string                       `json:"intent_id,omitempty"`
+	CreatedAt      time.Time                    `json:"created_at"`
+	CompletedTasks []string                     `json:"completed_tasks"`
+	PendingTasks   []models.Task                `json:"pending_tasks"`
+	TaskStates     map[string]models.TaskStatus `json:"task_states"`
+}
+
+// writeCheckpoint saves unfinished tasks to QLP_CHECKPOINT_DIR (default ./data/checkpoints)
+func (de *DAGExecutor) writeCheckpoint(ctx context.Context, taskGraph *models.TaskGraph) (string, error) {
+	checkpoint := Checkpoint{
+		GraphID:        taskGraph.ID,
+		IntentID:       audit.IntentFromContext(ctx),
+		CreatedAt:      time.Now(),
+		CompletedTasks: make([]string, 0),
+		PendingTasks:   make([]models.Task, 0),
+		TaskStates:     make(map[string]models.TaskStatus),
+	}
+
+	de.mu.RLock()
+	for _, task := range taskGraph.Tasks {
+		status := de.taskStates[task.ID]
+		checkpoint.TaskStates[task.ID] = status
+		if status == models.TaskStatusCompleted {
+			checkpoint.CompletedTasks = append(checkpoint.CompletedTasks, task.ID)
+			continue
+		}
+		task.Status = models.TaskStatusPending
+		checkpoint.PendingTasks = append(checkpoint.PendingTasks, task)
+	}
+	de.mu.RUnlock()
+
+	dir := checkpointDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+
+	graphID := taskGraph.ID
+	if graphID == "" {
+		graphID = "graph"
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s_%d.json", graphID, checkpoint.CreatedAt.Unix()))
+
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	logger.WithComponent("dag").Info("Checkpointed unfinished tasks",
+		zap.String("path", path),
+		zap.Int("pending_tasks", len(checkpoint.PendingTasks)),
+		zap.Int("completed_tasks", len(checkpoint.CompletedTasks)))
+
+	return path, nil
+}
+
+// checkpointDir is QLP_CHECKPOINT_DIR, default ./data/checkpoints
+func checkpointDir() string {
+	return config.GetEnvOrDefault("QLP_CHECKPOINT_DIR", "./data/checkpoints")
+}
+
+// ListCheckpoints returns the paths of the checkpoints in
+// QLP_CHECKPOINT_DIR, sorted by name
+func ListCheckpoints() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(checkpointDir(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// LoadCheckpoint reads a checkpoint and returns it with a task graph
+// containing only the unfinished tasks, with dependencies on completed tasks
+// removed.
+func LoadCheckpoint(path string) (*Checkpoint, *models.TaskGraph, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+
+	completed := make(map[string]bool)
+	for _, id := range checkpoint.CompletedTasks {
+		completed[id] = true
+	}
+
+	graph := &models.TaskGraph{
+		ID:    checkpoint.GraphID,
+		Tasks: make([]models.Task, 0, len(checkpoint.PendingTasks)),
+	}
+	for _, task := range checkpoint.PendingTasks {
+		deps := make([]string, 0, len(task.Dependencies))
+		for _, dep := range task.Dependencies {
+			if !completed[dep] {
+				deps = append(deps, dep)
+				graph.Edges = append(graph.Edges, models.Edge{From: dep, To: task.ID})
+			}
+		}
+		task.Dependencies = deps
+		graph.Tasks = append(graph.Tasks, task)
+	}
+
+	return &checkpoint, graph, nil
+}
@@ -0,0 +1,106 @@
+package dag
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/models"
+)
+
+func TestDrainWaitsForInFlightTasks(t *testing.T) {
+	de := NewDAGExecutor(nil, nil)
+	if !de.beginTask() {
+		t.Fatal("idle executor should start tasks")
+	}
+
+	drained := make(chan error, 1)
+	go func() { drained <- de.Drain(context.Background()) }()
+
+	select {
+	case err := <-drained:
+		t.Fatalf("drain returned with a task in flight: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if de.beginTask() {
+		t.Fatal("draining executor should not start tasks")
+	}
+
+	de.endTask()
+	select {
+	case err := <-drained:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("drain did not return once the task finished")
+	}
+}
+
+func TestDrainDeadline(t *testing.T) {
+	de := NewDAGExecutor(nil, nil)
+	de.beginTask()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := de.Drain(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline to be reported, got %v", err)
+	}
+}
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	t.Setenv("QLP_CHECKPOINT_DIR", t.TempDir())
+
+	graph := &models.TaskGraph{
+		ID: "graph_1",
+		Tasks: []models.Task{
+			{ID: "api"},
+			{ID: "docker", Dependencies: []string{"api"}},
+			{ID: "k8s", Dependencies: []string{"api", "docker"}},
+		},
+	}
+	de := NewDAGExecutor(nil, nil)
+	de.taskStates["api"] = models.TaskStatusCompleted
+	de.taskStates["docker"] = models.TaskStatusInProgress
+	de.taskStates["k8s"] = models.TaskStatusPending
+
+	path, err := de.writeCheckpoint(audit.WithIntent(context.Background(), "intent-1"), graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths, err := ListCheckpoints()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 1 || paths[0] != path {
+		t.Fatalf("expected %s to be listed, got %v", path, paths)
+	}
+
+	checkpoint, pending, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint.IntentID != "intent-1" || checkpoint.GraphID != "graph_1" {
+		t.Fatalf("unexpected checkpoint %+v", checkpoint)
+	}
+	if len(pending.Tasks) != 2 || pending.Tasks[0].ID != "docker" || pending.Tasks[1].ID != "k8s" {
+		t.Fatalf("expected docker and k8s to be pending, got %+v", pending.Tasks)
+	}
+	if len(pending.Tasks[0].Dependencies) != 0 {
+		t.Fatalf("dependency on a completed task kept: %v", pending.Tasks[0].Dependencies)
+	}
+	if deps := pending.Tasks[1].Dependencies; len(deps) != 1 || deps[0] != "docker" {
+		t.Fatalf("expected k8s to depend on docker only, got %v", deps)
+	}
+	if len(pending.Edges) != 1 || pending.Edges[0] != (models.Edge{From: "docker", To: "k8s"}) {
+		t.Fatalf("unexpected edges %+v", pending.Edges)
+	}
+	for _, task := range pending.Tasks {
+		if task.Status != models.TaskStatusPending {
+			t.Fatalf("task %s should be requeued as pending, got %s", task.ID, task.Status)
+		}
+	}
+}
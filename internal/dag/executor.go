@@ -2,16 +2,27 @@ package dag
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"QLP/internal/agents"
+	"QLP/internal/audit"
 	"QLP/internal/events"
+	"QLP/internal/failures"
 	"QLP/internal/logger"
+	"QLP/internal/metrics"
 	"QLP/internal/models"
+	"QLP/internal/quota"
 	"QLP/internal/sandbox"
+	"QLP/internal/stacks"
+	"QLP/internal/statemanager"
+	"QLP/internal/tracing"
 	"QLP/internal/types"
+
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
@@ -22,6 +33,8 @@ type TaskResult struct {
 	ExecutionTime    time.Duration
 	SandboxResult    *sandbox.SandboxExecutionResult
 	ValidationResult *types.ValidationResult
+	SelfReview       *agents.SelfReview
+	Refinements      int
 	Error            error
 	StartTime        time.Time
 	EndTime          time.Time
@@ -36,9 +49,18 @@ type DAGExecutor struct {
 	waitingTasks   chan models.Task
 	projectContext agents.ProjectContext
 	maxConcurrency int
-	semaphore      chan struct{}
+	slots          *quota.FairShare
+	quota          *quota.Tracker
+	stateManager   statemanager.StateManager
+	retryBudget    int
+	onOutput       OutputFunc
+	drainState
 }
 
+// OutputFunc observes each output an agent produces for a task: the first
+// and every refinement, the number of refinements made before it
+type OutputFunc func(ctx context.Context, task models.Task, output string, refinements int)
+
 func NewDAGExecutor(eventBus *events.EventBus, agentFactory *agents.AgentFactory) *DAGExecutor {
 	projectContext := agents.ProjectContext{
 		ProjectType:  "web_api",
@@ -62,7 +84,96 @@ func NewDAGExecutor(eventBus *events.EventBus, agentFactory *agents.AgentFactory
 		waitingTasks:   make(chan models.Task, 100),
 		projectContext: projectContext,
 		maxConcurrency: maxConcurrency,
-		semaphore:      make(chan struct{}, maxConcurrency),
+		slots:          quota.NewFairShare(maxConcurrency),
+		drainState:     newDrainState(),
+	}
+}
+
+// SetPriorSolutions sets prior solutions recalled from generation memory,
+// included in agent prompts for subsequent executions
+func (de *DAGExecutor) SetPriorSolutions(solutions []string) {
+	de.mu.Lock()
+	defer de.mu.Unlock()
+	de.projectContext.PriorSolutions = solutions
+}
+
+// SetStandards sets the organization constraints included in agent prompts for
+// subsequent executions
+func (de *DAGExecutor) SetStandards(standards []string) {
+	de.mu.Lock()
+	defer de.mu.Unlock()
+	de.projectContext.Standards = standards
+}
+
+// SetExistingProject sets the description of the workspace that subsequent
+// executions extend; empty for standalone intents
+func (de *DAGExecutor) SetExistingProject(existing string) {
+	de.mu.Lock()
+	defer de.mu.Unlock()
+	de.projectContext.ExistingProject = existing
+}
+
+// SetStack sets the technology stack that subsequent executions generate
+func (de *DAGExecutor) SetStack(stack stacks.Stack) {
+	de.mu.Lock()
+	defer de.mu.Unlock()
+	de.projectContext.TechStack = stack.TechStack
+	de.projectContext.Guidance = stack.Guidance
+}
+
+// SetStateManager shares task states through sm, keyed by graph ID, so other
+// consumers can follow or resume the execution
+func (de *DAGExecutor) SetStateManager(sm statemanager.StateManager) {
+	de.mu.Lock()
+	defer de.mu.Unlock()
+	de.stateManager = sm
+}
+
+// OnOutput calls fn with each agent output of subsequent executions,
+// including those sent back for refinement; nil stops observing
+func (de *DAGExecutor) OnOutput(fn OutputFunc) {
+	de.mu.Lock()
+	defer de.mu.Unlock()
+	de.onOutput = fn
+}
+
+// observeOutput passes the agent's latest output to the OnOutput observer
+// when the execution produced one
+func (de *DAGExecutor) observeOutput(ctx context.Context, task models.Task, agent *agents.DynamicAgent, err error) {
+	de.mu.RLock()
+	fn := de.onOutput
+	de.mu.RUnlock()
+	if fn == nil || (err != nil && !errors.Is(err, agents.ErrNeedsRefinement)) {
+		return
+	}
+	fn(ctx, task, agent.GetOutput(), agent.Refinements)
+}
+
+// saveState merges the graph's current task states into the shared state.
+// Failures are logged; local execution does not depend on the shared copy.
+func (de *DAGExecutor) saveState(ctx context.Context, taskGraph *models.TaskGraph) {
+	de.mu.RLock()
+	sm := de.stateManager
+	states := make(map[string]models.TaskStatus, len(taskGraph.Tasks))
+	for _, task := range taskGraph.Tasks {
+		states[task.ID] = de.taskStates[task.ID]
+	}
+	de.mu.RUnlock()
+	if sm == nil {
+		return
+	}
+
+	err := statemanager.Update(ctx, sm, taskGraph.ID, func(state *statemanager.DAGState) error {
+		state.Graph = taskGraph
+		for id, status := range states {
+			state.TaskStates[id] = status
+		}
+		return nil
+	})
+	if err != nil {
+		logger.WithComponent("dag").Warn("Failed to save shared DAG state",
+			zap.String("graph_id", taskGraph.ID),
+			zap.Error(err))
 	}
 }
 
@@ -70,13 +181,22 @@ func (de *DAGExecutor) ExecuteTaskGraph(ctx context.Context, taskGraph *models.T
 	logger.WithComponent("dag").Info("Starting DAG execution",
 		zap.Int("task_count", len(taskGraph.Tasks)))
 
+	tenantID := audit.TenantFromContext(ctx)
+	if err := de.admit(ctx, tenantID); err != nil {
+		return err
+	}
+
 	for _, task := range taskGraph.Tasks {
 		de.mu.Lock()
 		de.taskStates[task.ID] = models.TaskStatusPending
 		de.mu.Unlock()
 	}
+	de.saveState(ctx, taskGraph)
+	de.reportLoad()
 
 	completedChan := make(chan string, len(taskGraph.Tasks))
+	failedChan := make(chan string, len(taskGraph.Tasks))
+	retries := de.newRetryBudget()
 
 	var executeTasksRecursively func([]models.Task)
 	executeTasksRecursively = func(tasks []models.Task) {
@@ -90,19 +210,43 @@ func (de *DAGExecutor) ExecuteTaskGraph(ctx context.Context, taskGraph *models.T
 			if exists && (status == models.TaskStatusInProgress || status == models.TaskStatusCompleted) {
 				continue // Skip tasks that are already running or completed
 			}
+
+			// Stop starting new work once a drain has begun
+			if de.IsDraining() {
+				continue
+			}
 			
 			wg.Add(1)
 			go func(t models.Task) {
 				defer wg.Done()
 				
-				// Acquire semaphore (limit concurrency)
-				de.semaphore <- struct{}{}
-				defer func() { <-de.semaphore }()
+				// Take a slot in turn with the other tenants' tasks
+				if err := de.slots.Acquire(ctx, tenantID); err != nil {
+					return
+				}
+				defer de.slots.Release(tenantID)
+
+				if !de.beginTask() {
+					return
+				}
+				de.reportLoad()
+				defer de.reportLoad()
+				defer de.endTask()
 				
-				if err := de.executeTaskWithDynamicAgent(ctx, t, completedChan); err != nil {
+				start := time.Now()
+				defer func() { de.recordUsage(ctx, tenantID, time.Since(start)) }()
+				err := de.executeTaskWithDynamicAgent(ctx, t, completedChan)
+				for err != nil && ctx.Err() == nil && !de.IsDraining() && retries.take() {
+					logger.WithComponent("dag").Warn("Retrying failed task",
+						zap.String("task_id", t.ID),
+						zap.Error(err))
+					err = de.executeTaskWithDynamicAgent(ctx, t, completedChan)
+				}
+				if err != nil {
 					logger.WithComponent("dag").Error("Task execution failed",
 						zap.String("task_id", t.ID),
 						zap.Error(err))
+					failedChan <- t.ID
 				}
 			}(task)
 		}
@@ -112,31 +256,87 @@ func (de *DAGExecutor) ExecuteTaskGraph(ctx context.Context, taskGraph *models.T
 	readyTasks := de.findReadyTasks(taskGraph.Tasks)
 	executeTasksRecursively(readyTasks)
 
-	completedCount := 0
-	for completedCount < len(taskGraph.Tasks) {
+	// Failed tasks and the tasks downstream of them finish the graph too
+	completedCount, finishedCount := 0, 0
+	var failed, skipped []string
+	for finishedCount < len(taskGraph.Tasks) {
 		select {
+		case taskID := <-failedChan:
+			failed = append(failed, taskID)
+			skip := de.skipDependents(taskGraph, taskID)
+			skipped = append(skipped, skip...)
+			finishedCount += 1 + len(skip)
+			if len(skip) > 0 {
+				logger.WithComponent("dag").Warn("Skipping tasks downstream of failed task",
+					zap.String("task_id", taskID),
+					zap.Strings("skipped", skip))
+			}
+			de.saveState(ctx, taskGraph)
 		case taskID := <-completedChan:
 			completedCount++
+			finishedCount++
 			logger.WithComponent("dag").Info("Task completed",
 				zap.String("task_id", taskID),
 				zap.Int("completed_count", completedCount),
 				zap.Int("total_tasks", len(taskGraph.Tasks)))
+			de.saveState(ctx, taskGraph)
 
 			nextTasks := de.findNextReadyTasks(taskID, taskGraph)
 			if len(nextTasks) > 0 {
 				go executeTasksRecursively(nextTasks)
 			}
+		case <-de.drainCh:
+			// Let in-flight tasks finish (Drain enforces the deadline), then checkpoint the rest
+			de.waitInFlight(ctx)
+			de.saveState(context.WithoutCancel(ctx), taskGraph)
+			path, err := de.writeCheckpoint(ctx, taskGraph)
+			if err != nil {
+				logger.WithComponent("dag").Error("Failed to checkpoint unfinished tasks",
+					zap.Error(err))
+			}
+			return fmt.Errorf("%w: %d of %d tasks completed, checkpoint: %s",
+				ErrDraining, completedCount, len(taskGraph.Tasks), path)
 		case <-ctx.Done():
 			return ctx.Err()
 		}
 	}
 
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %d of %d tasks failed (%s), %d skipped",
+			ErrTasksFailed, len(failed), len(taskGraph.Tasks), strings.Join(failed, ", "), len(skipped))
+	}
 	logger.WithComponent("dag").Info("All tasks completed successfully")
 	return nil
 }
 
-func (de *DAGExecutor) executeTaskWithDynamicAgent(ctx context.Context, task models.Task, completedChan chan<- string) error {
+func (de *DAGExecutor) executeTaskWithDynamicAgent(ctx context.Context, task models.Task, completedChan chan<- string) (err error) {
 	startTime := time.Now()
+
+	ctx, span := tracing.StartSpan(ctx, "agent.execute",
+		attribute.String("task.id", task.ID),
+		attribute.String("task.type", string(task.Type)))
+	defer func() { tracing.EndSpan(span, err) }()
+
+	var agentID string
+	defer func() {
+		entry := audit.Entry{
+			Action:       audit.ActionAgentExecute,
+			ResourceType: "agent",
+			ResourceIDs:  []string{task.ID},
+			Details: map[string]interface{}{
+				"task_type":         string(task.Type),
+				"execution_time_ms": time.Since(startTime).Milliseconds(),
+			},
+		}
+		if agentID != "" {
+			entry.ResourceIDs = append(entry.ResourceIDs, agentID)
+		}
+		if err != nil {
+			entry.Outcome = audit.OutcomeFailure
+			entry.Error = err.Error()
+		}
+		audit.Record(ctx, entry)
+	}()
 	
 	// Double-check task state to prevent race conditions
 	de.mu.Lock()
@@ -147,7 +347,7 @@ func (de *DAGExecutor) executeTaskWithDynamicAgent(ctx context.Context, task mod
 	de.taskStates[task.ID] = models.TaskStatusInProgress
 	de.mu.Unlock()
 
-	de.eventBus.Publish(events.Event{
+	de.eventBus.PublishWithContext(ctx, events.Event{
 		ID:        fmt.Sprintf("event_%s_started", task.ID),
 		Type:      events.EventTaskStarted,
 		Timestamp: time.Now(),
@@ -163,7 +363,11 @@ func (de *DAGExecutor) executeTaskWithDynamicAgent(ctx context.Context, task mod
 		zap.String("task_id", task.ID),
 		zap.String("description", task.Description))
 
-	agent, err := de.agentFactory.CreateAgent(ctx, task, de.projectContext)
+	de.mu.RLock()
+	projectContext := de.projectContext
+	de.mu.RUnlock()
+
+	agent, err := de.agentFactory.CreateAgent(ctx, task, projectContext)
 	if err != nil {
 		de.mu.Lock()
 		de.taskStates[task.ID] = models.TaskStatusFailed
@@ -178,7 +382,7 @@ func (de *DAGExecutor) executeTaskWithDynamicAgent(ctx context.Context, task mod
 		}
 		de.mu.Unlock()
 
-		de.eventBus.Publish(events.Event{
+		de.eventBus.PublishWithContext(ctx, events.Event{
 			ID:        fmt.Sprintf("event_%s_failed", task.ID),
 			Type:      events.EventTaskFailed,
 			Timestamp: time.Now(),
@@ -189,10 +393,30 @@ func (de *DAGExecutor) executeTaskWithDynamicAgent(ctx context.Context, task mod
 			},
 		})
 
+		metrics.ObserveAgentExecution(string(task.Type), string(models.TaskStatusFailed), time.Since(startTime))
+		failures.Record(ctx, failures.Failure{Stage: failures.StageTask, RunID: tracing.TraceID(ctx)}, err)
 		return fmt.Errorf("failed to create agent: %w", err)
 	}
 
-	if err := de.agentFactory.ExecuteAgent(ctx, agent); err != nil {
+	agentID = agent.ID
+
+	err = de.agentFactory.ExecuteAgent(ctx, agent)
+	de.observeOutput(ctx, task, agent, err)
+	// Outputs the agent scored low on its self-review go straight back for
+	// refinement; the agent stops asking once its refinement budget is spent
+	for errors.Is(err, agents.ErrNeedsRefinement) {
+		logger.WithComponent("dag").Info("Routing task output to refinement",
+			zap.String("task_id", task.ID),
+			zap.String("agent_id", agent.ID),
+			zap.Int("self_score", agent.SelfReview.Score),
+			zap.Int("refinement", agent.Refinements+1))
+		metrics.RecordRefinement(string(task.Type))
+		if err = agent.Refine(); err == nil {
+			err = de.agentFactory.ExecuteAgent(ctx, agent)
+			de.observeOutput(ctx, task, agent, err)
+		}
+	}
+	if err != nil {
 		de.mu.Lock()
 		de.taskStates[task.ID] = models.TaskStatusFailed
 		de.taskResults[task.ID] = &TaskResult{
@@ -206,7 +430,7 @@ func (de *DAGExecutor) executeTaskWithDynamicAgent(ctx context.Context, task mod
 		}
 		de.mu.Unlock()
 
-		de.eventBus.Publish(events.Event{
+		de.eventBus.PublishWithContext(ctx, events.Event{
 			ID:        fmt.Sprintf("event_%s_failed", task.ID),
 			Type:      events.EventTaskFailed,
 			Timestamp: time.Now(),
@@ -217,6 +441,8 @@ func (de *DAGExecutor) executeTaskWithDynamicAgent(ctx context.Context, task mod
 			},
 		})
 
+		metrics.ObserveAgentExecution(string(task.Type), string(models.TaskStatusFailed), time.Since(startTime))
+		failures.Record(ctx, failures.Failure{Stage: failures.StageTask, RunID: tracing.TraceID(ctx)}, err)
 		return fmt.Errorf("agent execution failed: %w", err)
 	}
 
@@ -229,13 +455,15 @@ func (de *DAGExecutor) executeTaskWithDynamicAgent(ctx context.Context, task mod
 		ExecutionTime:    time.Since(startTime),
 		SandboxResult:    agent.SandboxResult,
 		ValidationResult: agent.ValidationResult,
+		SelfReview:       agent.SelfReview,
+		Refinements:      agent.Refinements,
 		Error:            nil,
 		StartTime:        startTime,
 		EndTime:          time.Now(),
 	}
 	de.mu.Unlock()
 
-	de.eventBus.Publish(events.Event{
+	de.eventBus.PublishWithContext(ctx, events.Event{
 		ID:        fmt.Sprintf("event_%s_completed", task.ID),
 		Type:      events.EventTaskCompleted,
 		Timestamp: time.Now(),
@@ -247,17 +475,22 @@ func (de *DAGExecutor) executeTaskWithDynamicAgent(ctx context.Context, task mod
 		},
 	})
 
+	metrics.ObserveAgentExecution(string(task.Type), string(models.TaskStatusCompleted), time.Since(startTime))
+
 	de.agentFactory.CleanupAgent(agent.ID)
 	completedChan <- task.ID
 
 	return nil
 }
 
+// findReadyTasks returns the tasks that can start immediately. Dependencies
+// outside the graph count when they completed in an earlier execution, which
+// lets a partial graph be re-run against preserved results.
 func (de *DAGExecutor) findReadyTasks(tasks []models.Task) []models.Task {
 	var readyTasks []models.Task
 
 	for _, task := range tasks {
-		if len(task.Dependencies) == 0 {
+		if len(task.Dependencies) == 0 || de.dependenciesCompleted(task.Dependencies) {
 			readyTasks = append(readyTasks, task)
 		}
 	}
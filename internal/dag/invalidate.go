@@ -0,0 +1,63 @@
+package dag
+
+import "QLP/internal/models"
+
+// Downstream returns the tasks that transitively depend on any of taskIDs, in
+// graph order. The seed tasks themselves are not included.
+func Downstream(taskGraph *models.TaskGraph, taskIDs []string) []string {
+	dependents := make(map[string][]string)
+	for _, task := range taskGraph.Tasks {
+		for _, depID := range task.Dependencies {
+			dependents[depID] = append(dependents[depID], task.ID)
+		}
+	}
+
+	seeds := make(map[string]bool, len(taskIDs))
+	for _, id := range taskIDs {
+		seeds[id] = true
+	}
+
+	affected := make(map[string]bool)
+	queue := append([]string{}, taskIDs...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, dependent := range dependents[id] {
+			if affected[dependent] || seeds[dependent] {
+				continue
+			}
+			affected[dependent] = true
+			queue = append(queue, dependent)
+		}
+	}
+
+	var ordered []string
+	for _, task := range taskGraph.Tasks {
+		if affected[task.ID] {
+			ordered = append(ordered, task.ID)
+		}
+	}
+	return ordered
+}
+
+// Subgraph returns the graph restricted to taskIDs. Dependencies on tasks
+// outside the subgraph are kept so they can be satisfied by earlier results.
+func Subgraph(taskGraph *models.TaskGraph, taskIDs []string) *models.TaskGraph {
+	include := make(map[string]bool, len(taskIDs))
+	for _, id := range taskIDs {
+		include[id] = true
+	}
+
+	sub := &models.TaskGraph{ID: taskGraph.ID + "_partial"}
+	for _, task := range taskGraph.Tasks {
+		if include[task.ID] {
+			sub.Tasks = append(sub.Tasks, task)
+		}
+	}
+	for _, edge := range taskGraph.Edges {
+		if include[edge.To] {
+			sub.Edges = append(sub.Edges, edge)
+		}
+	}
+	return sub
+}
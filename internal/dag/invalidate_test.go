@@ -0,0 +1,59 @@
+package dag
+
+import (
+	"reflect"
+	"testing"
+
+	"QLP/internal/models"
+)
+
+func testGraph() *models.TaskGraph {
+	return &models.TaskGraph{
+		ID: "graph",
+		Tasks: []models.Task{
+			{ID: "api"},
+			{ID: "docker", Dependencies: []string{"api"}},
+			{ID: "k8s", Dependencies: []string{"docker"}},
+			{ID: "docs"},
+			{ID: "tests", Dependencies: []string{"api", "docs"}},
+		},
+		Edges: []models.Edge{
+			{From: "api", To: "docker"},
+			{From: "docker", To: "k8s"},
+			{From: "api", To: "tests"},
+			{From: "docs", To: "tests"},
+		},
+	}
+}
+
+func TestDownstreamIsTransitiveAndOrdered(t *testing.T) {
+	graph := testGraph()
+
+	if got := Downstream(graph, []string{"api"}); !reflect.DeepEqual(got, []string{"docker", "k8s", "tests"}) {
+		t.Errorf("Downstream(api) = %v", got)
+	}
+	if got := Downstream(graph, []string{"docker"}); !reflect.DeepEqual(got, []string{"k8s"}) {
+		t.Errorf("Downstream(docker) = %v", got)
+	}
+	if got := Downstream(graph, []string{"k8s"}); len(got) != 0 {
+		t.Errorf("Downstream(k8s) = %v, want none", got)
+	}
+	// Seeds are never reported as downstream of each other
+	if got := Downstream(graph, []string{"api", "docker"}); !reflect.DeepEqual(got, []string{"k8s", "tests"}) {
+		t.Errorf("Downstream(api, docker) = %v", got)
+	}
+}
+
+func TestSubgraphKeepsExternalDependencies(t *testing.T) {
+	sub := Subgraph(testGraph(), []string{"docker", "k8s"})
+
+	if len(sub.Tasks) != 2 || sub.Tasks[0].ID != "docker" || sub.Tasks[1].ID != "k8s" {
+		t.Fatalf("unexpected tasks: %+v", sub.Tasks)
+	}
+	if !reflect.DeepEqual(sub.Tasks[0].Dependencies, []string{"api"}) {
+		t.Errorf("dependency on preserved task dropped: %v", sub.Tasks[0].Dependencies)
+	}
+	if len(sub.Edges) != 2 {
+		t.Errorf("expected edges into docker and k8s, got %+v", sub.Edges)
+	}
+}
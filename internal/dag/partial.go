@@ -0,0 +1,81 @@
+package dag
+
+import (
+	"errors"
+	"sync"
+
+	"QLP/internal/models"
+)
+
+// ErrTasksFailed is returned by ExecuteTaskGraph when tasks failed beyond
+// the retry budget. Every task not downstream of them still ran, so their
+// results can be packaged or the failed tasks retried on their own.
+var ErrTasksFailed = errors.New("tasks failed")
+
+// SetRetryBudget lets each graph re-run up to n failed task executions before
+// the tasks count as failed. The budget is shared by all tasks of a graph, so
+// a run with many failing tasks cannot multiply its cost.
+func (de *DAGExecutor) SetRetryBudget(n int) {
+	de.mu.Lock()
+	defer de.mu.Unlock()
+	de.retryBudget = max(n, 0)
+}
+
+// retryBudget counts down the retries left to a graph execution
+type retryBudget struct {
+	mu        sync.Mutex
+	remaining int
+}
+
+func (de *DAGExecutor) newRetryBudget() *retryBudget {
+	de.mu.RLock()
+	defer de.mu.RUnlock()
+	return &retryBudget{remaining: de.retryBudget}
+}
+
+// take spends one retry, reporting false once the budget is spent
+func (b *retryBudget) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.remaining <= 0 {
+		return false
+	}
+	b.remaining--
+	return true
+}
+
+// skipDependents marks the pending tasks downstream of a failed task as
+// skipped, since their inputs will never be produced, and returns them
+func (de *DAGExecutor) skipDependents(taskGraph *models.TaskGraph, taskID string) []string {
+	de.mu.Lock()
+	defer de.mu.Unlock()
+	var skipped []string
+	for _, id := range Downstream(taskGraph, []string{taskID}) {
+		if de.taskStates[id] == models.TaskStatusPending {
+			de.taskStates[id] = models.TaskStatusSkipped
+			skipped = append(skipped, id)
+		}
+	}
+	return skipped
+}
+
+// TaskStatus returns the state of a task in its latest execution, empty for
+// tasks the executor has not seen
+func (de *DAGExecutor) TaskStatus(taskID string) models.TaskStatus {
+	de.mu.RLock()
+	defer de.mu.RUnlock()
+	return de.taskStates[taskID]
+}
+
+// RestoreResults records the results of tasks completed in an earlier
+// execution, possibly by another process, so a graph of the remaining tasks
+// runs against them and GetTaskResult returns them
+func (de *DAGExecutor) RestoreResults(results map[string]*TaskResult) {
+	de.mu.Lock()
+	defer de.mu.Unlock()
+	for id, r := range results {
+		r.Status = models.TaskStatusCompleted
+		de.taskStates[id] = models.TaskStatusCompleted
+		de.taskResults[id] = r
+	}
+}
@@ -0,0 +1,59 @@
+package dag
+
+import (
+	"reflect"
+	"testing"
+
+	"QLP/internal/models"
+)
+
+func TestSkipDependentsLeavesFinishedTasks(t *testing.T) {
+	de := NewDAGExecutor(nil, nil)
+	graph := testGraph()
+	for _, task := range graph.Tasks {
+		de.taskStates[task.ID] = models.TaskStatusPending
+	}
+	de.taskStates["docs"] = models.TaskStatusCompleted
+	de.taskStates["docker"] = models.TaskStatusFailed
+
+	if got := de.skipDependents(graph, "docker"); !reflect.DeepEqual(got, []string{"k8s"}) {
+		t.Errorf("skipDependents(docker) = %v", got)
+	}
+	// k8s is already skipped, so only tests is new
+	de.taskStates["api"] = models.TaskStatusFailed
+	if got := de.skipDependents(graph, "api"); !reflect.DeepEqual(got, []string{"tests"}) {
+		t.Errorf("skipDependents(api) = %v", got)
+	}
+	if de.TaskStatus("k8s") != models.TaskStatusSkipped || de.TaskStatus("docs") != models.TaskStatusCompleted {
+		t.Errorf("states = %v", de.taskStates)
+	}
+}
+
+func TestRetryBudgetIsSharedByGraph(t *testing.T) {
+	de := NewDAGExecutor(nil, nil)
+	if de.newRetryBudget().take() {
+		t.Error("executor without a budget retried")
+	}
+
+	de.SetRetryBudget(2)
+	budget := de.newRetryBudget()
+	if !budget.take() || !budget.take() || budget.take() {
+		t.Error("budget of 2 did not allow exactly two retries")
+	}
+	if !de.newRetryBudget().take() {
+		t.Error("next graph did not get a fresh budget")
+	}
+}
+
+func TestRestoreResultsSatisfiesDependencies(t *testing.T) {
+	de := NewDAGExecutor(nil, nil)
+	de.RestoreResults(map[string]*TaskResult{"api": {AgentID: "agent-1", Output: "package main"}})
+
+	sub := Subgraph(testGraph(), []string{"docker"})
+	if ready := de.findReadyTasks(sub.Tasks); len(ready) != 1 || ready[0].ID != "docker" {
+		t.Errorf("ready = %v", ready)
+	}
+	if r := de.GetTaskResult("api"); r == nil || r.Status != models.TaskStatusCompleted || r.Output != "package main" {
+		t.Errorf("result = %+v", r)
+	}
+}
@@ -0,0 +1,46 @@
+package dag
+
+import (
+	"context"
+	"time"
+
+	"QLP/internal/logger"
+	"QLP/internal/quota"
+	"go.uber.org/zap"
+)
+
+// SetQuota meters the execution minutes of each tenant's tasks with
+// tracker and holds graphs of tenants beyond their daily quota
+func (de *DAGExecutor) SetQuota(tracker *quota.Tracker) {
+	de.mu.Lock()
+	defer de.mu.Unlock()
+	de.quota = tracker
+}
+
+// admit checks the tenant's quota before a graph starts. The whole graph
+// runs once admitted, so intents are not cut off halfway.
+func (de *DAGExecutor) admit(ctx context.Context, tenantID string) error {
+	de.mu.RLock()
+	tracker := de.quota
+	de.mu.RUnlock()
+	if tracker == nil {
+		return nil
+	}
+	return tracker.Admit(ctx, tenantID)
+}
+
+// recordUsage adds a task's execution time to the tenant's usage. Failures
+// are logged; the task result does not depend on metering.
+func (de *DAGExecutor) recordUsage(ctx context.Context, tenantID string, d time.Duration) {
+	de.mu.RLock()
+	tracker := de.quota
+	de.mu.RUnlock()
+	if tracker == nil {
+		return
+	}
+	if err := tracker.Record(context.WithoutCancel(ctx), tenantID, d); err != nil {
+		logger.WithComponent("dag").Warn("Failed to record execution usage",
+			zap.String("tenant_id", tenantID),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,45 @@
+package dag
+
+import (
+	"QLP/internal/metrics"
+	"QLP/internal/models"
+)
+
+// ScaleStatus is the executor load reported to autoscalers
+type ScaleStatus struct {
+	InFlight       int  `json:"in_flight"`
+	PendingTasks   int  `json:"pending_tasks"`
+	MaxConcurrency int  `json:"max_concurrency"`
+	Draining       bool `json:"draining"`
+
+	// Slots each tenant holds and tasks each has waiting for one
+	TenantsRunning map[string]int `json:"tenants_running,omitempty"`
+	TenantsWaiting map[string]int `json:"tenants_waiting,omitempty"`
+}
+
+// ScaleStatus returns the current load of the executor
+func (de *DAGExecutor) ScaleStatus() ScaleStatus {
+	de.mu.RLock()
+	pending := 0
+	for _, status := range de.taskStates {
+		if status == models.TaskStatusPending {
+			pending++
+		}
+	}
+	de.mu.RUnlock()
+
+	return ScaleStatus{
+		InFlight:       de.InFlight(),
+		PendingTasks:   pending,
+		MaxConcurrency: de.maxConcurrency,
+		Draining:       de.IsDraining(),
+		TenantsRunning: de.slots.Running(),
+		TenantsWaiting: de.slots.Waiting(),
+	}
+}
+
+// reportLoad publishes the executor load as metrics
+func (de *DAGExecutor) reportLoad() {
+	status := de.ScaleStatus()
+	metrics.SetAgentLoad(status.InFlight, status.PendingTasks)
+}
@@ -0,0 +1,29 @@
+package dag
+
+import (
+	"context"
+	"testing"
+
+	"QLP/internal/models"
+)
+
+func TestScaleStatusReportsBacklogAndDrain(t *testing.T) {
+	de := NewDAGExecutor(nil, nil)
+	de.taskStates["api"] = models.TaskStatusCompleted
+	de.taskStates["docker"] = models.TaskStatusPending
+	de.taskStates["k8s"] = models.TaskStatusPending
+	de.beginTask()
+
+	status := de.ScaleStatus()
+	if status.InFlight != 1 || status.PendingTasks != 2 || status.MaxConcurrency != 4 || status.Draining {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	de.endTask()
+	if err := de.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !de.ScaleStatus().Draining {
+		t.Fatal("draining executor should report it")
+	}
+}
@@ -16,12 +16,21 @@ func NewIntentRepository(db *Database) *IntentRepository {
 	return &IntentRepository{db: db}
 }
 
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
 func (r *IntentRepository) Create(intent *models.Intent) error {
 	if !r.db.IsConnected() {
 		// Fallback to file-based storage
 		return r.createFileBased(intent)
 	}
 
+	return r.create(r.db.conn, intent)
+}
+
+func (r *IntentRepository) create(exec execer, intent *models.Intent) error {
 	tasksJSON, err := json.Marshal(intent.Tasks)
 	if err != nil {
 		return fmt.Errorf("failed to marshal tasks: %w", err)
@@ -37,7 +46,7 @@ func (r *IntentRepository) Create(intent *models.Intent) error {
 		VALUES ($1, $2, $3, $4, $5, $6)
 	`
 	
-	_, err = r.db.conn.Exec(query, 
+	_, err = exec.Exec(query, 
 		intent.ID, 
 		intent.UserInput, 
 		tasksJSON, 
@@ -103,6 +112,10 @@ func (r *IntentRepository) Update(intent *models.Intent) error {
 		return r.updateFileBased(intent)
 	}
 
+	return r.update(r.db.conn, intent)
+}
+
+func (r *IntentRepository) update(exec execer, intent *models.Intent) error {
 	tasksJSON, err := json.Marshal(intent.Tasks)
 	if err != nil {
 		return fmt.Errorf("failed to marshal tasks: %w", err)
@@ -125,7 +138,7 @@ func (r *IntentRepository) Update(intent *models.Intent) error {
 		completedAt = *intent.CompletedAt
 	}
 	
-	_, err = r.db.conn.Exec(query,
+	_, err = exec.Exec(query,
 		intent.ID,
 		tasksJSON,
 		metadataJSON,
@@ -0,0 +1,256 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"QLP/internal/events"
+	"QLP/internal/models"
+)
+
+// EventPublisher is the destination the outbox relay forwards events to. An
+// event it refuses, such as with events.ErrBusFull, stays in the outbox and
+// is retried until it has been refused maxAttempts times.
+type EventPublisher interface {
+	TryPublish(event events.Event) error
+}
+
+// Outbox implements the transactional outbox pattern: events are written to
+// the outbox table in the same transaction as the state change they describe,
+// and a background relay publishes them afterwards. A crash between the write
+// and the publish therefore delays the event instead of losing it.
+type Outbox struct {
+	db           *Database
+	publisher    EventPublisher
+	pollInterval time.Duration
+	batchSize    int
+	maxAttempts  int
+}
+
+// NewOutbox creates an outbox relaying to publisher
+func NewOutbox(db *Database, publisher EventPublisher) *Outbox {
+	return &Outbox{
+		db:           db,
+		publisher:    publisher,
+		pollInterval: 2 * time.Second,
+		batchSize:    100,
+		maxAttempts:  10,
+	}
+}
+
+// Enqueue writes events to the outbox inside the caller's transaction
+func (o *Outbox) Enqueue(tx *sql.Tx, evts ...events.Event) error {
+	for _, event := range evts {
+		if event.Timestamp.IsZero() {
+			event.Timestamp = time.Now()
+		}
+		// Pin the idempotency key so relayed retries are recognised as duplicates
+		if event.Headers[events.HeaderIdempotencyKey] == "" {
+			headers := map[string]string{events.HeaderIdempotencyKey: "outbox:" + event.ID}
+			for k, v := range event.Headers {
+				headers[k] = v
+			}
+			event.Headers = headers
+		}
+
+		payload, err := json.Marshal(event.Payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event payload: %w", err)
+		}
+		headers, err := json.Marshal(event.Headers)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event headers: %w", err)
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO event_outbox (event_id, event_type, source, payload, headers, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			event.ID, string(event.Type), event.Source, payload, headers, event.Timestamp)
+		if err != nil {
+			return fmt.Errorf("failed to enqueue event %s: %w", event.ID, err)
+		}
+	}
+	return nil
+}
+
+// withTransaction runs fn in a transaction and enqueues evts in the same
+// transaction. Without a database connection fn is run against the fallback
+// store and the events are published immediately.
+func (o *Outbox) withTransaction(fn func(exec execer) error, fallback func() error, evts ...events.Event) error {
+	if !o.db.IsConnected() {
+		if err := fallback(); err != nil {
+			return err
+		}
+		for _, event := range evts {
+			if err := o.publisher.TryPublish(event); err != nil {
+				log.Printf("⚠️  Event %s dropped, there is no outbox without a database: %v", event.ID, err)
+			}
+		}
+		return nil
+	}
+
+	tx, err := o.db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := o.Enqueue(tx, evts...); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Run relays outbox events until ctx is cancelled
+func (o *Outbox) Run(ctx context.Context) {
+	if !o.db.IsConnected() {
+		return
+	}
+
+	ticker := time.NewTicker(o.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := o.RelayOnce(ctx); err != nil {
+			log.Printf("⚠️  Outbox relay failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce publishes one batch of pending events and marks them as published.
+// Rows are locked with SKIP LOCKED so several relays can run concurrently. A
+// crash after publishing but before commit re-sends the batch, so consumers
+// must be idempotent (the event bus deduplicates by idempotency key). It
+// returns the number of events published, which is short of the batch when
+// the publisher refuses one.
+func (o *Outbox) RelayOnce(ctx context.Context) (int, error) {
+	tx, err := o.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin relay transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, event_id, event_type, source, payload, headers, created_at, attempts
+		FROM event_outbox
+		WHERE published_at IS NULL AND attempts < $1
+		ORDER BY id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`, o.maxAttempts, o.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read outbox: %w", err)
+	}
+
+	batch := make([]pendingEvent, 0)
+	for rows.Next() {
+		var p pendingEvent
+		var eventType string
+		var payload, headers []byte
+		if err := rows.Scan(&p.rowID, &p.event.ID, &eventType, &p.event.Source, &payload, &headers, &p.event.Timestamp, &p.attempts); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan outbox row: %w", err)
+		}
+		p.event.Type = events.EventType(eventType)
+		if err := json.Unmarshal(payload, &p.event.Payload); err != nil {
+			log.Printf("⚠️  Outbox event %s has malformed payload: %v", p.event.ID, err)
+		}
+		if len(headers) > 0 {
+			if err := json.Unmarshal(headers, &p.event.Headers); err != nil {
+				// Keep the idempotency key Enqueue pinned so retries stay duplicates
+				log.Printf("⚠️  Outbox event %s has malformed headers: %v", p.event.ID, err)
+				p.event.Headers = map[string]string{events.HeaderIdempotencyKey: "outbox:" + p.event.ID}
+			}
+		}
+		batch = append(batch, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read outbox: %w", err)
+	}
+
+	published, err := o.relay(ctx, tx, batch)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit relay transaction: %w", err)
+	}
+	return published, nil
+}
+
+// pendingEvent is an outbox row not yet published
+type pendingEvent struct {
+	rowID    int64
+	event    events.Event
+	attempts int // Failed publishes so far
+}
+
+// contextExecer is the part of a transaction the relay writes through
+type contextExecer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// relay publishes a batch in order, marking each event published once the
+// publisher accepts it. At the first refusal the rest of the batch is left
+// for the next poll, in order, and the refused event's attempts go up; once
+// it has failed maxAttempts times it is no longer relayed, so an event the
+// publisher never accepts cannot hold up those after it.
+func (o *Outbox) relay(ctx context.Context, exec contextExecer, batch []pendingEvent) (int, error) {
+	for i, p := range batch {
+		if err := o.publisher.TryPublish(p.event); err != nil {
+			if _, err := exec.ExecContext(ctx, `
+				UPDATE event_outbox SET attempts = attempts + 1
+				WHERE id = $1`, p.rowID); err != nil {
+				return 0, fmt.Errorf("failed to count outbox event attempt: %w", err)
+			}
+			if p.attempts+1 >= o.maxAttempts {
+				log.Printf("⚠️  Outbox event %s given up after %d attempts: %v", p.event.ID, p.attempts+1, err)
+			} else {
+				log.Printf("⚠️  Outbox relay paused, %d events left for the next poll: %v", len(batch)-i, err)
+			}
+			return i, nil
+		}
+		if _, err := exec.ExecContext(ctx, `
+			UPDATE event_outbox SET published_at = CURRENT_TIMESTAMP, attempts = attempts + 1
+			WHERE id = $1`, p.rowID); err != nil {
+			return 0, fmt.Errorf("failed to mark outbox event published: %w", err)
+		}
+	}
+	return len(batch), nil
+}
+
+// CreateWithEvents inserts an intent and records events in one transaction
+func (r *IntentRepository) CreateWithEvents(outbox *Outbox, intent *models.Intent, evts ...events.Event) error {
+	return outbox.withTransaction(
+		func(exec execer) error { return r.create(exec, intent) },
+		func() error { return r.createFileBased(intent) },
+		evts...,
+	)
+}
+
+// UpdateWithEvents updates an intent and records events in one transaction
+func (r *IntentRepository) UpdateWithEvents(outbox *Outbox, intent *models.Intent, evts ...events.Event) error {
+	return outbox.withTransaction(
+		func(exec execer) error { return r.update(exec, intent) },
+		func() error { return r.updateFileBased(intent) },
+		evts...,
+	)
+}
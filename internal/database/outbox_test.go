@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"QLP/internal/events"
+)
+
+// fakePublisher accepts events until its room runs out
+type fakePublisher struct {
+	room      int
+	published []string
+}
+
+func (p *fakePublisher) TryPublish(event events.Event) error {
+	if len(p.published) == p.room {
+		return events.ErrBusFull
+	}
+	p.published = append(p.published, event.ID)
+	return nil
+}
+
+// fakeExec records the outbox rows marked published and those whose
+// attempts were counted
+type fakeExec struct {
+	marked []int64
+	failed []int64
+}
+
+func (e *fakeExec) ExecContext(_ context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if strings.Contains(query, "published_at") {
+		e.marked = append(e.marked, args[0].(int64))
+	} else {
+		e.failed = append(e.failed, args[0].(int64))
+	}
+	return nil, nil
+}
+
+func pendingBatch(ids ...string) []pendingEvent {
+	batch := make([]pendingEvent, len(ids))
+	for i, id := range ids {
+		batch[i] = pendingEvent{rowID: int64(i + 1), event: events.Event{ID: id, Type: events.EventIntentCreated}}
+	}
+	return batch
+}
+
+func TestRelayMarksPublishedEvents(t *testing.T) {
+	publisher := &fakePublisher{room: 10}
+	exec := &fakeExec{}
+	o := NewOutbox(&Database{}, publisher)
+
+	published, err := o.relay(context.Background(), exec, pendingBatch("evt-1", "evt-2", "evt-3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if published != 3 || len(publisher.published) != 3 || len(exec.marked) != 3 {
+		t.Errorf("published %d: %v, marked %v", published, publisher.published, exec.marked)
+	}
+}
+
+func TestRelayLeavesRefusedEventsPending(t *testing.T) {
+	publisher := &fakePublisher{room: 1}
+	exec := &fakeExec{}
+	o := NewOutbox(&Database{}, publisher)
+
+	published, err := o.relay(context.Background(), exec, pendingBatch("evt-1", "evt-2", "evt-3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the accepted event is marked; the refused one and those after it
+	// stay pending so they are relayed, in order, by the next poll
+	if published != 1 || len(exec.marked) != 1 || exec.marked[0] != 1 {
+		t.Errorf("published %d, marked %v", published, exec.marked)
+	}
+	// The refusal counts against the refused event alone
+	if len(exec.failed) != 1 || exec.failed[0] != 2 {
+		t.Errorf("attempts counted for %v", exec.failed)
+	}
+
+	publisher.room = 10
+	published, err = o.relay(context.Background(), exec, pendingBatch("evt-2", "evt-3"))
+	if err != nil || published != 2 {
+		t.Errorf("next poll published %d, %v", published, err)
+	}
+	if got := publisher.published; len(got) != 3 || got[1] != "evt-2" || got[2] != "evt-3" {
+		t.Errorf("published %v", got)
+	}
+}
@@ -132,6 +132,58 @@ CREATE TABLE IF NOT EXISTS events (
     timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Audit log for cloud mutations and agent executions
+CREATE TABLE IF NOT EXISTS audit_log (
+    id VARCHAR(100) PRIMARY KEY,
+    timestamp TIMESTAMP NOT NULL,
+    action VARCHAR(100) NOT NULL,
+    outcome VARCHAR(20) NOT NULL,
+    intent_id VARCHAR(50),
+    tenant_id VARCHAR(100),
+    actor VARCHAR(100) NOT NULL,
+    resource_type VARCHAR(100),
+    resource_ids TEXT[],
+    trace_id VARCHAR(64),
+    error TEXT,
+    details JSONB DEFAULT '{}'
+);
+
+-- Transactional outbox: events written with the state change, relayed afterwards
+CREATE TABLE IF NOT EXISTS event_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    source VARCHAR(100),
+    payload JSONB DEFAULT '{}',
+    headers JSONB DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP,
+    attempts INTEGER DEFAULT 0
+);
+
+-- Processed event keys for idempotent event handling
+CREATE TABLE IF NOT EXISTS processed_events (
+    key VARCHAR(255) PRIMARY KEY,
+    processed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- Generation memory: completed intents reused as context for similar intents
+CREATE TABLE IF NOT EXISTS generation_memory (
+    intent_id VARCHAR(50) PRIMARY KEY REFERENCES intents(id) ON DELETE CASCADE,
+    tenant_id VARCHAR(100) NOT NULL DEFAULT '',
+    capsule_id VARCHAR(50) NOT NULL,
+    user_input TEXT NOT NULL,
+    summary TEXT,
+    overall_score INTEGER NOT NULL,
+    task_outputs JSONB NOT NULL DEFAULT '{}',
+    embedding VECTOR(1536),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+-- Memory recorded before entries carried their tenant is recalled for
+-- requests without one
+ALTER TABLE generation_memory ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(100) NOT NULL DEFAULT '';
+
 -- Create indexes for performance
 CREATE INDEX IF NOT EXISTS idx_intents_status ON intents(status);
 CREATE INDEX IF NOT EXISTS idx_intents_created_at ON intents(created_at);
@@ -145,9 +197,14 @@ CREATE INDEX IF NOT EXISTS idx_quantum_capsules_intent_id ON quantum_capsules(in
 CREATE INDEX IF NOT EXISTS idx_performance_metrics_timestamp ON performance_metrics(timestamp);
 CREATE INDEX IF NOT EXISTS idx_events_timestamp ON events(timestamp);
 CREATE INDEX IF NOT EXISTS idx_events_type ON events(event_type);
+CREATE INDEX IF NOT EXISTS idx_event_outbox_unpublished ON event_outbox(id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_audit_log_timestamp ON audit_log(timestamp);
+CREATE INDEX IF NOT EXISTS idx_audit_log_intent_id ON audit_log(intent_id);
+CREATE INDEX IF NOT EXISTS idx_generation_memory_tenant_id ON generation_memory(tenant_id);
 
 -- Vector similarity search index (for intent embeddings)
 CREATE INDEX IF NOT EXISTS idx_intents_embedding ON intents USING ivfflat (embedding vector_cosine_ops);
+CREATE INDEX IF NOT EXISTS idx_generation_memory_embedding ON generation_memory USING ivfflat (embedding vector_cosine_ops);
 
 -- Trigger to update updated_at timestamp
 CREATE OR REPLACE FUNCTION update_updated_at_column()
@@ -3,16 +3,40 @@ package azure
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"time"
 
 	"QLP/internal/logger"
+	"QLP/internal/metrics"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
 	"go.uber.org/zap"
 )
 
+// Tags written on every QuantumLayer resource group. The expiry lives in
+// Azure with the group, so the cleanup controller or the Automation runbook
+// can enforce the TTL after the process that created it has exited.
+const (
+	TagCreatedBy          = "created-by"        // "quantumlayer"
+	TagCreatedAt          = "created-at"        // RFC 3339
+	TagExpiresAt          = "auto-delete-after" // RFC 3339
+	TagCapsuleID          = "capsule-id"
+	TagCostEstimateUSD    = "cost-estimate-usd"
+	createdByQuantumLayer = "quantumlayer"
+)
+
+// ResourceGroupClient lists and deletes the QuantumLayer resource groups;
+// AzureClient implements it
+type ResourceGroupClient interface {
+	ListResourceGroups(ctx context.Context) ([]*armresources.ResourceGroup, error)
+	DeleteResourceGroup(ctx context.Context, name string) error
+}
+
 // CleanupManager handles automated cleanup of Azure resources
 type CleanupManager struct {
 	logger      logger.Interface
-	azureClient *AzureClient
+	azureClient ResourceGroupClient
+	now         func() time.Time
 }
 
 // CleanupPolicy defines how resources should be cleaned up
@@ -54,15 +78,21 @@ type ExpiredResource struct {
 }
 
 // NewCleanupManager creates a new cleanup manager
-func NewCleanupManager(azureClient *AzureClient) *CleanupManager {
+func NewCleanupManager(azureClient ResourceGroupClient) *CleanupManager {
 	return &CleanupManager{
 		logger:      logger.GetDefaultLogger().WithComponent("azure_cleanup"),
 		azureClient: azureClient,
+		now:         time.Now,
 	}
 }
 
-// StartCleanupScheduler starts the automatic cleanup scheduler
+// StartCleanupScheduler runs the cleanup controller: a sweep on start, so
+// groups left behind while no controller ran are reclaimed right away, then
+// one every check interval until ctx is cancelled
 func (cm *CleanupManager) StartCleanupScheduler(ctx context.Context, policy CleanupPolicy) {
+	if policy.CheckInterval <= 0 {
+		policy.CheckInterval = DefaultCleanupPolicy().CheckInterval
+	}
 	cm.logger.Info("Starting cleanup scheduler",
 		zap.Duration("check_interval", policy.CheckInterval),
 		zap.Duration("max_age", policy.MaxAge),
@@ -73,12 +103,12 @@ func (cm *CleanupManager) StartCleanupScheduler(ctx context.Context, policy Clea
 	defer ticker.Stop()
 
 	for {
+		cm.performScheduledCleanup(ctx, policy)
 		select {
 		case <-ctx.Done():
 			cm.logger.Info("Cleanup scheduler stopped")
 			return
 		case <-ticker.C:
-			cm.performScheduledCleanup(ctx, policy)
 		}
 	}
 }
@@ -86,31 +116,47 @@ func (cm *CleanupManager) StartCleanupScheduler(ctx context.Context, policy Clea
 // performScheduledCleanup performs a scheduled cleanup check
 func (cm *CleanupManager) performScheduledCleanup(ctx context.Context, policy CleanupPolicy) {
 	cm.logger.Debug("Performing scheduled cleanup check")
+	if _, err := cm.Sweep(ctx, policy); err != nil {
+		cm.logger.Error("Failed to find expired resources", zap.Error(err))
+	}
+}
 
+// Sweep deletes the resource groups past their TTL or maximum age and
+// returns the result of each
+func (cm *CleanupManager) Sweep(ctx context.Context, policy CleanupPolicy) ([]CleanupResult, error) {
 	expiredResources, err := cm.findExpiredResources(ctx, policy)
 	if err != nil {
-		cm.logger.Error("Failed to find expired resources", zap.Error(err))
-		return
+		return nil, err
 	}
+	metrics.SetExpiredResourceGroups(len(expiredResources))
 
 	if len(expiredResources) == 0 {
 		cm.logger.Debug("No expired resources found")
-		return
+		return nil, nil
 	}
 
 	cm.logger.Info("Found expired resources for cleanup",
 		zap.Int("count", len(expiredResources)),
 	)
 
+	results := make([]CleanupResult, 0, len(expiredResources))
 	for _, resource := range expiredResources {
 		result := cm.cleanupResource(ctx, resource, policy)
 		cm.logCleanupResult(resource, result)
-		
+		results = append(results, result)
+
+		overdue := time.Duration(0)
+		if !resource.ExpiresAt.IsZero() {
+			overdue = result.EndTime.Sub(resource.ExpiresAt)
+		}
+		metrics.ObserveCleanup(result.Status, result.CostSaved, overdue)
+
 		// Send notification if webhook is configured
 		if policy.NotificationWebhook != "" {
 			cm.sendCleanupNotification(policy.NotificationWebhook, resource, result)
 		}
 	}
+	return results, nil
 }
 
 // findExpiredResources identifies resources that should be cleaned up
@@ -121,7 +167,7 @@ func (cm *CleanupManager) findExpiredResources(ctx context.Context, policy Clean
 	}
 
 	var expiredResources []ExpiredResource
-	now := time.Now()
+	now := cm.now()
 
 	for _, rg := range resourceGroups {
 		if rg.Tags == nil {
@@ -129,26 +175,24 @@ func (cm *CleanupManager) findExpiredResources(ctx context.Context, policy Clean
 		}
 
 		// Check if this is a QuantumLayer resource group
-		createdBy, exists := rg.Tags["created-by"]
-		if !exists || *createdBy != "quantumlayer" {
+		createdBy, exists := rg.Tags[TagCreatedBy]
+		if !exists || createdBy == nil || *createdBy != createdByQuantumLayer || rg.Name == nil {
 			continue
 		}
 
 		resource := ExpiredResource{
 			ResourceGroup: *rg.Name,
+			CreatedAt:     tagTime(rg.Tags, TagCreatedAt),
+			ExpiresAt:     tagTime(rg.Tags, TagExpiresAt),
 		}
 
-		// Parse creation time and expiration
-		if expirationStr, exists := rg.Tags["auto-delete-after"]; exists {
-			if expiresAt, err := time.Parse(time.RFC3339, *expirationStr); err == nil {
-				resource.ExpiresAt = expiresAt
-			}
-		}
-
-		// Parse capsule ID
-		if capsuleID, exists := rg.Tags["capsule-id"]; exists {
+		// Parse capsule ID and cost estimate
+		if capsuleID, exists := rg.Tags[TagCapsuleID]; exists && capsuleID != nil {
 			resource.CapsuleID = *capsuleID
 		}
+		if cost, exists := rg.Tags[TagCostEstimateUSD]; exists && cost != nil {
+			resource.CostEstimate, _ = strconv.ParseFloat(*cost, 64)
+		}
 
 		// Check if resource is expired
 		isExpired := false
@@ -159,7 +203,7 @@ func (cm *CleanupManager) findExpiredResources(ctx context.Context, policy Clean
 		}
 		
 		// Check max age
-		if !resource.CreatedAt.IsZero() && now.Sub(resource.CreatedAt) > policy.MaxAge {
+		if !resource.CreatedAt.IsZero() && policy.MaxAge > 0 && now.Sub(resource.CreatedAt) > policy.MaxAge {
 			isExpired = true
 		}
 
@@ -217,7 +261,14 @@ func (cm *CleanupManager) cleanupResource(ctx context.Context, resource ExpiredR
 				zap.Int("attempt", attempt),
 				zap.Int("max_attempts", policy.RetryAttempts),
 			)
-			time.Sleep(policy.RetryDelay)
+			select {
+			case <-ctx.Done():
+				lastErr = ctx.Err()
+			case <-time.After(policy.RetryDelay):
+			}
+			if ctx.Err() != nil {
+				break
+			}
 		}
 
 		err := cm.azureClient.DeleteResourceGroup(ctx, resource.ResourceGroup)
@@ -253,6 +304,17 @@ func (cm *CleanupManager) cleanupResource(ctx context.Context, resource ExpiredR
 	return result
 }
 
+// tagTime parses an RFC 3339 tag, returning the zero time when it is
+// missing or malformed
+func tagTime(tags map[string]*string, key string) time.Time {
+	if value, ok := tags[key]; ok && value != nil {
+		if t, err := time.Parse(time.RFC3339, *value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
 // logCleanupResult logs the result of a cleanup operation
 func (cm *CleanupManager) logCleanupResult(resource ExpiredResource, result CleanupResult) {
 	fields := []zap.Field{
@@ -368,7 +430,7 @@ func (cm *CleanupManager) GetCleanupStats(ctx context.Context) (map[string]inter
 		}
 
 		// Check TTL expiration
-		if expirationStr, exists := rg.Tags["auto-delete-after"]; exists {
+		if expirationStr, exists := rg.Tags[TagExpiresAt]; exists && expirationStr != nil {
 			if expiresAt, err := time.Parse(time.RFC3339, *expirationStr); err == nil {
 				if now.After(expiresAt) {
 					stats["expired_count"] = stats["expired_count"].(int) + 1
@@ -0,0 +1,103 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+)
+
+type fakeResourceGroups struct {
+	groups  []*armresources.ResourceGroup
+	deleted []string
+	fail    map[string]bool
+}
+
+func (f *fakeResourceGroups) ListResourceGroups(ctx context.Context) ([]*armresources.ResourceGroup, error) {
+	return f.groups, nil
+}
+
+func (f *fakeResourceGroups) DeleteResourceGroup(ctx context.Context, name string) error {
+	if f.fail[name] {
+		return errors.New("delete failed")
+	}
+	f.deleted = append(f.deleted, name)
+	return nil
+}
+
+func group(name string, tags map[string]string) *armresources.ResourceGroup {
+	rg := &armresources.ResourceGroup{Name: stringPtr(name), Tags: make(map[string]*string)}
+	for k, v := range tags {
+		rg.Tags[k] = stringPtr(v)
+	}
+	return rg
+}
+
+func TestSweepEnforcesTTLTags(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) string { return now.Add(d).Format(time.RFC3339) }
+	client := &fakeResourceGroups{
+		groups: []*armresources.ResourceGroup{
+			group("rg-expired", map[string]string{TagCreatedBy: "quantumlayer", TagExpiresAt: at(-time.Hour), TagCostEstimateUSD: "1.50"}),
+			group("rg-in-grace", map[string]string{TagCreatedBy: "quantumlayer", TagExpiresAt: at(-time.Minute)}),
+			group("rg-live", map[string]string{TagCreatedBy: "quantumlayer", TagExpiresAt: at(time.Hour)}),
+			group("rg-too-old", map[string]string{TagCreatedBy: "quantumlayer", TagCreatedAt: at(-48 * time.Hour), TagExpiresAt: at(time.Hour)}),
+			group("rg-foreign", map[string]string{TagCreatedBy: "someone-else", TagExpiresAt: at(-time.Hour)}),
+			group("rg-untagged", nil),
+		},
+	}
+	cm := NewCleanupManager(client)
+	cm.now = func() time.Time { return now }
+
+	policy := CleanupPolicy{MaxAge: 24 * time.Hour, GracePeriod: 5 * time.Minute}
+	results, err := cm.Sweep(context.Background(), policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(client.deleted, ",") != "rg-expired,rg-too-old" {
+		t.Fatalf("deleted %v", client.deleted)
+	}
+	if len(results) != 2 || results[0].Status != "success" || results[0].CostSaved != 1.5 {
+		t.Errorf("unexpected results %+v", results)
+	}
+}
+
+func TestSweepDryRunAndFailures(t *testing.T) {
+	expired := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	client := &fakeResourceGroups{
+		groups: []*armresources.ResourceGroup{
+			group("rg-a", map[string]string{TagCreatedBy: "quantumlayer", TagExpiresAt: expired}),
+		},
+		fail: map[string]bool{"rg-a": true},
+	}
+	cm := NewCleanupManager(client)
+
+	results, _ := cm.Sweep(context.Background(), CleanupPolicy{DryRun: true})
+	if len(client.deleted) != 0 || len(results) != 1 || results[0].Status != "dry_run" {
+		t.Fatalf("dry run should not delete: %+v", results)
+	}
+
+	results, _ = cm.Sweep(context.Background(), CleanupPolicy{RetryAttempts: 1})
+	if results[0].Status != "failed" || results[0].RetryAttempts != 2 {
+		t.Errorf("expected a failed cleanup after retrying: %+v", results[0])
+	}
+}
+
+func TestAutomationRunbook(t *testing.T) {
+	runbook := AutomationRunbook(CleanupPolicy{GracePeriod: 5 * time.Minute, MaxAge: 24 * time.Hour, DryRun: true})
+	for _, want := range []string{
+		`Get-AzResourceGroup -Tag @{ "created-by" = "quantumlayer" }`,
+		`$rg.Tags["auto-delete-after"]`,
+		"New-TimeSpan -Minutes 5",
+		"New-TimeSpan -Minutes 1440",
+		"[bool] $DryRun = $true",
+		"Connect-AzAccount -Identity",
+	} {
+		if !strings.Contains(runbook, want) {
+			t.Errorf("runbook missing %q", want)
+		}
+	}
+}
@@ -5,20 +5,30 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
-	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"QLP/internal/audit"
+	"QLP/internal/config"
 	"QLP/internal/logger"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
 	"go.uber.org/zap"
 )
 
 // AzureClient provides unified access to Azure services for deployment validation
 type AzureClient struct {
-	logger           logger.Interface
-	subscriptionID   string
-	credential       *azidentity.DefaultAzureCredential
-	resourcesClient  *armresources.Client
-	location         string
-	tenantID         string
+	logger               logger.Interface
+	subscriptionID       string
+	credential           azcore.TokenCredential
+	resourcesClient      *armresources.Client
+	resourceGroupsClient *armresources.ResourceGroupsClient
+	providersClient      *armresources.ProvidersClient
+	location             string
+	tenantID             string
+	pollFrequency        time.Duration
+	managementEndpoint   string // Default: https://management.azure.com
 }
 
 // ClientConfig configures the Azure client
@@ -26,45 +36,123 @@ type ClientConfig struct {
 	SubscriptionID string
 	Location       string // Default: "westeurope"
 	TenantID       string
+
+	// AuthMethods is the credential chain, tried in order. Empty infers a
+	// single method from the fields below: managed identity when
+	// UseManagedIdentity is set, then client secret, then certificate, and
+	// otherwise the SDK's default chain.
+	AuthMethods []AuthMethod
+
+	// ClientID is the service principal's application ID, or with managed
+	// or workload identity the user-assigned identity to use
+	ClientID            string
+	ClientSecret        string
+	CertificatePath     string // PEM or PKCS#12 with the private key
+	CertificatePassword string
+	FederatedTokenFile  string // workload identity token; Default: AZURE_FEDERATED_TOKEN_FILE
+	UseManagedIdentity  bool
+
+	MaxRetries    int32         // Default: 5
+	RetryDelay    time.Duration // Initial backoff, doubled per retry. Default: 2s
+	MaxRetryDelay time.Duration // Default: 60s
+	PollFrequency time.Duration // Long-running operation polling. Default: 10s
+}
+
+// ClientConfigFromEnv reads the client configuration from the AZURE_*
+// environment variables
+func ClientConfigFromEnv() ClientConfig {
+	return ClientConfig{
+		SubscriptionID:      config.GetEnvOrDefault("AZURE_SUBSCRIPTION_ID", ""),
+		Location:            config.GetEnvOrDefault("AZURE_LOCATION", "westeurope"),
+		TenantID:            config.GetEnvOrDefault("AZURE_TENANT_ID", ""),
+		ClientID:            config.GetEnvOrDefault("AZURE_CLIENT_ID", ""),
+		ClientSecret:        config.GetEnvOrDefault("AZURE_CLIENT_SECRET", ""),
+		CertificatePath:     config.GetEnvOrDefault("AZURE_CLIENT_CERTIFICATE_PATH", ""),
+		CertificatePassword: config.GetEnvOrDefault("AZURE_CLIENT_CERTIFICATE_PASSWORD", ""),
+		FederatedTokenFile:  config.GetEnvOrDefault("AZURE_FEDERATED_TOKEN_FILE", ""),
+		UseManagedIdentity:  config.GetEnvOrDefault("AZURE_USE_MANAGED_IDENTITY", "false") == "true",
+		AuthMethods:         ParseAuthMethods(config.GetEnvOrDefault("AZURE_AUTH_METHODS", "")),
+	}
 }
 
-// NewAzureClient creates a new Azure client with default credential chain
+// NewAzureClient creates a new Azure client authenticated as configured
 func NewAzureClient(config ClientConfig) (*AzureClient, error) {
 	logger := logger.GetDefaultLogger().WithComponent("azure_client")
-	
+
 	// Default location if not specified
 	if config.Location == "" {
 		config.Location = "westeurope"
 	}
-	
-	// Create credential using default Azure credential chain
-	// This will use: environment variables → managed identity → Azure CLI → interactive
-	credential, err := azidentity.NewDefaultAzureCredential(nil)
+	if config.PollFrequency <= 0 {
+		config.PollFrequency = 10 * time.Second
+	}
+
+	credential, err := newCredential(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Azure credential: %w", err)
 	}
-	
-	// Create ARM resources client
-	resourcesClient, err := armresources.NewClient(config.SubscriptionID, credential, nil)
+
+	// Throttled and failed requests are retried with exponential backoff,
+	// honouring Retry-After
+	options := clientOptions(config)
+
+	// Create ARM resources clients
+	resourcesClient, err := armresources.NewClient(config.SubscriptionID, credential, options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create ARM resources client: %w", err)
 	}
-	
+
+	resourceGroupsClient, err := armresources.NewResourceGroupsClient(config.SubscriptionID, credential, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ARM resource groups client: %w", err)
+	}
+
+	providersClient, err := armresources.NewProvidersClient(config.SubscriptionID, credential, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ARM providers client: %w", err)
+	}
+
 	logger.Info("Azure client initialized",
 		zap.String("subscription_id", config.SubscriptionID),
 		zap.String("location", config.Location),
+		zap.Any("auth", config.authMethods()),
 	)
-	
+
 	return &AzureClient{
-		logger:          logger,
-		subscriptionID:  config.SubscriptionID,
-		credential:      credential,
-		resourcesClient: resourcesClient,
-		location:        config.Location,
-		tenantID:        config.TenantID,
+		logger:               logger,
+		subscriptionID:       config.SubscriptionID,
+		credential:           credential,
+		resourcesClient:      resourcesClient,
+		resourceGroupsClient: resourceGroupsClient,
+		providersClient:      providersClient,
+		location:             config.Location,
+		tenantID:             config.TenantID,
+		pollFrequency:        config.PollFrequency,
 	}, nil
 }
 
+// clientOptions sets the retry policy of the ARM clients
+func clientOptions(config ClientConfig) *arm.ClientOptions {
+	retry := policy.RetryOptions{
+		MaxRetries:    config.MaxRetries,
+		RetryDelay:    config.RetryDelay,
+		MaxRetryDelay: config.MaxRetryDelay,
+	}
+	if retry.MaxRetries <= 0 {
+		retry.MaxRetries = 5
+	}
+	if retry.RetryDelay <= 0 {
+		retry.RetryDelay = 2 * time.Second
+	}
+	if retry.MaxRetryDelay <= 0 {
+		retry.MaxRetryDelay = 60 * time.Second
+	}
+	return &arm.ClientOptions{ClientOptions: policy.ClientOptions{Retry: retry}}
+}
+
+// DefaultResourceGroupTTL is the TTL of resource groups created without one
+const DefaultResourceGroupTTL = time.Hour
+
 // ResourceGroupSpec defines resource group configuration
 type ResourceGroupSpec struct {
 	Name     string
@@ -80,39 +168,60 @@ func (ac *AzureClient) CreateResourceGroup(ctx context.Context, spec ResourceGro
 		zap.String("location", spec.Location),
 		zap.Duration("ttl", spec.TTL),
 	)
-	
+
+	// Creation is idempotent: a redelivered request must not create a second group
+	exists, err := ac.CheckResourceGroupExists(ctx, spec.Name)
+	if err != nil {
+		return fmt.Errorf("failed to check resource group existence: %w", err)
+	}
+	if exists {
+		ac.logger.Info("Resource group already exists, skipping creation",
+			zap.String("name", spec.Name),
+		)
+		return nil
+	}
+
 	// Add TTL and capsule tracking tags
 	if spec.Tags == nil {
 		spec.Tags = make(map[string]*string)
 	}
-	
-	// Add auto-cleanup tag with expiration time
-	expirationTime := time.Now().Add(spec.TTL).Format(time.RFC3339)
-	spec.Tags["auto-delete-after"] = &expirationTime
-	spec.Tags["created-by"] = stringPtr("quantumlayer")
+
+	// Add auto-cleanup tags with creation and expiration time; without a
+	// TTL the group still expires, after DefaultResourceGroupTTL
+	if spec.TTL <= 0 {
+		spec.TTL = DefaultResourceGroupTTL
+	}
+	now := time.Now().UTC()
+	expirationTime := now.Add(spec.TTL).Format(time.RFC3339)
+	spec.Tags[TagExpiresAt] = &expirationTime
+	spec.Tags[TagCreatedAt] = stringPtr(now.Format(time.RFC3339))
+	spec.Tags[TagCreatedBy] = stringPtr(createdByQuantumLayer)
 	spec.Tags["purpose"] = stringPtr("capsule-validation")
-	
+
 	// Create resource group
 	rgParams := armresources.ResourceGroup{
 		Location: &spec.Location,
 		Tags:     spec.Tags,
 	}
-	
-	// For now, stub the creation - actual implementation will depend on final Azure SDK API
-	ac.logger.Info("Resource group creation stubbed - would create:",
-		zap.String("name", spec.Name),
-		zap.String("location", spec.Location),
-		zap.Any("tags", spec.Tags),
-	)
-	
-	// TODO: Replace with actual Azure SDK call once API is verified
-	_ = rgParams
-	
+	if _, err := ac.resourceGroupsClient.CreateOrUpdate(ctx, spec.Name, rgParams, nil); err != nil {
+		return wrapError("create resource group", spec.Name, err)
+	}
+
 	ac.logger.Info("Resource group created successfully",
 		zap.String("name", spec.Name),
 		zap.String("expiration", expirationTime),
 	)
-	
+
+	audit.Record(ctx, audit.Entry{
+		Action:       audit.ActionResourceGroupCreate,
+		ResourceType: "Microsoft.Resources/resourceGroups",
+		ResourceIDs:  []string{ac.resourceGroupID(spec.Name)},
+		Details: map[string]interface{}{
+			"location":          spec.Location,
+			"auto_delete_after": expirationTime,
+		},
+	})
+
 	return nil
 }
 
@@ -121,52 +230,74 @@ func (ac *AzureClient) DeleteResourceGroup(ctx context.Context, name string) err
 	ac.logger.Info("Deleting resource group",
 		zap.String("name", name),
 	)
-	
-	// For now, stub the deletion - actual implementation will depend on final Azure SDK API
-	ac.logger.Info("Resource group deletion stubbed - would delete:",
-		zap.String("name", name),
-	)
-	
-	// TODO: Replace with actual Azure SDK call once API is verified
-	// Example: poller, err := ac.resourcesClient.BeginDelete(ctx, name, &armresources.ResourceGroupsClientBeginDeleteOptions{})
-	
+
+	// Deleting a group is a long-running operation; poll it to completion
+	// so callers know the resources are gone
+	poller, err := ac.resourceGroupsClient.BeginDelete(ctx, name, nil)
+	if err == nil {
+		_, err = poller.PollUntilDone(ctx, &runtime.PollUntilDoneOptions{Frequency: ac.pollFrequency})
+	}
+	if err = wrapError("delete resource group", name, err); err != nil {
+		if IsNotFound(err) {
+			ac.logger.Info("Resource group already deleted", zap.String("name", name))
+			return nil
+		}
+		return err
+	}
+
 	ac.logger.Info("Resource group deleted successfully",
 		zap.String("name", name),
 	)
-	
+
+	audit.Record(ctx, audit.Entry{
+		Action:       audit.ActionResourceGroupDelete,
+		ResourceType: "Microsoft.Resources/resourceGroups",
+		ResourceIDs:  []string{ac.resourceGroupID(name)},
+	})
+
 	return nil
 }
 
 // ListResourceGroups lists all resource groups with QuantumLayer tags
 func (ac *AzureClient) ListResourceGroups(ctx context.Context) ([]*armresources.ResourceGroup, error) {
 	ac.logger.Debug("Listing QuantumLayer resource groups")
-	
+
 	var resourceGroups []*armresources.ResourceGroup
-	
-	// For now, stub the listing - actual implementation will depend on final Azure SDK API
-	ac.logger.Debug("Resource group listing stubbed - would list all QuantumLayer resource groups")
-	
-	// TODO: Replace with actual Azure SDK call once API is verified
-	// Example: pager := ac.resourcesClient.NewListPager(&armresources.ResourceGroupsClientListOptions{})
-	
+
+	filter := "tagName eq 'created-by' and tagValue eq 'quantumlayer'"
+	pager := ac.resourceGroupsClient.NewListPager(&armresources.ResourceGroupsClientListOptions{Filter: &filter})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, wrapError("list resource groups", ac.subscriptionID, err)
+		}
+		resourceGroups = append(resourceGroups, page.Value...)
+	}
+
 	ac.logger.Debug("Found QuantumLayer resource groups",
 		zap.Int("count", len(resourceGroups)),
 	)
-	
+
 	return resourceGroups, nil
 }
 
 // CheckResourceGroupExists verifies if a resource group exists
 func (ac *AzureClient) CheckResourceGroupExists(ctx context.Context, name string) (bool, error) {
-	// For now, stub the existence check - actual implementation will depend on final Azure SDK API
-	ac.logger.Debug("Resource group existence check stubbed",
-		zap.String("name", name),
-	)
-	
-	// TODO: Replace with actual Azure SDK call once API is verified
-	// Example: resp, err := ac.resourcesClient.CheckExistence(ctx, name, &armresources.ResourceGroupsClientCheckExistenceOptions{})
-	
-	return false, nil // Stub: assume doesn't exist
+	resp, err := ac.resourceGroupsClient.CheckExistence(ctx, name, nil)
+	if err != nil {
+		return false, wrapError("check resource group", name, err)
+	}
+	return resp.Success, nil
+}
+
+// GetResourceGroup returns a resource group with its tags and provisioning
+// state
+func (ac *AzureClient) GetResourceGroup(ctx context.Context, name string) (*armresources.ResourceGroup, error) {
+	resp, err := ac.resourceGroupsClient.Get(ctx, name, nil)
+	if err != nil {
+		return nil, wrapError("get resource group", name, err)
+	}
+	return &resp.ResourceGroup, nil
 }
 
 // GetSubscriptionID returns the configured subscription ID
@@ -179,7 +310,20 @@ func (ac *AzureClient) GetLocation() string {
 	return ac.location
 }
 
+// endpoint returns the Azure Resource Manager endpoint for direct REST calls
+func (ac *AzureClient) endpoint() string {
+	if ac.managementEndpoint != "" {
+		return ac.managementEndpoint
+	}
+	return "https://management.azure.com"
+}
+
+// resourceGroupID returns the full ARM ID of a resource group
+func (ac *AzureClient) resourceGroupID(name string) string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", ac.subscriptionID, name)
+}
+
 // Helper function to convert string to *string
 func stringPtr(s string) *string {
 	return &s
-}
\ No newline at end of file
+}
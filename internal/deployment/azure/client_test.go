@@ -0,0 +1,49 @@
+package azure
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+func TestWrapError(t *testing.T) {
+	if wrapError("get resource group", "rg", nil) != nil {
+		t.Fatal("nil error should stay nil")
+	}
+
+	notFound := fmt.Errorf("request failed: %w", &azcore.ResponseError{StatusCode: http.StatusNotFound, ErrorCode: "ResourceGroupNotFound"})
+	err := wrapError("get resource group", "rg", notFound)
+	var azErr *Error
+	if !errors.As(err, &azErr) || azErr.StatusCode != http.StatusNotFound || azErr.Code != "ResourceGroupNotFound" {
+		t.Fatalf("unexpected error %#v", err)
+	}
+	if !IsNotFound(err) || IsThrottled(err) || IsRetryable(err) {
+		t.Errorf("404 classified wrongly: %v", err)
+	}
+
+	throttled := wrapError("list resource groups", "sub", &azcore.ResponseError{StatusCode: http.StatusTooManyRequests})
+	if !IsThrottled(throttled) || !IsRetryable(throttled) {
+		t.Errorf("429 should be throttled and retryable: %v", throttled)
+	}
+	if !IsRetryable(wrapError("delete resource group", "rg", errors.New("connection reset"))) {
+		t.Error("errors without a response should be retryable")
+	}
+	if IsNotFound(notFound) {
+		t.Error("unwrapped SDK errors are not classified")
+	}
+}
+
+func TestClientOptionsDefaults(t *testing.T) {
+	retry := clientOptions(ClientConfig{}).Retry
+	if retry.MaxRetries != 5 || retry.RetryDelay != 2*time.Second || retry.MaxRetryDelay != time.Minute {
+		t.Errorf("unexpected defaults %+v", retry)
+	}
+	retry = clientOptions(ClientConfig{MaxRetries: 2, RetryDelay: time.Second}).Retry
+	if retry.MaxRetries != 2 || retry.RetryDelay != time.Second {
+		t.Errorf("configured values ignored: %+v", retry)
+	}
+}
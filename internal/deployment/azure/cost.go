@@ -0,0 +1,233 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"QLP/internal/config"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// ActualCost is the spend Azure Cost Management has accrued for a
+// validation run. Cost data lags usage by several hours, so a query right
+// after the run usually undercounts; query again later for the final figure.
+type ActualCost struct {
+	TotalUSD  float64            `json:"total_usd"` // in Currency
+	Currency  string             `json:"currency"`  // USD unless the billing account only reports another currency
+	Breakdown map[string]float64 `json:"breakdown"` // by resource type
+	From      time.Time          `json:"from"`
+	To        time.Time          `json:"to"`
+	QueriedAt time.Time          `json:"queried_at"`
+}
+
+// CostAlert reports actual spend exceeding the estimate by more than the
+// configured factor
+type CostAlert struct {
+	EstimatedUSD float64 `json:"estimated_usd"`
+	ActualUSD    float64 `json:"actual_usd"`
+	Factor       float64 `json:"factor"`
+	Message      string  `json:"message"`
+}
+
+// CostQuerier reports the accrued cost of a validation run
+type CostQuerier interface {
+	// QueryCost returns the cost of the resource group, and of resources
+	// tagged with the capsule ID, between from and to
+	QueryCost(ctx context.Context, resourceGroup, capsuleID string, from, to time.Time) (*ActualCost, error)
+}
+
+// DefaultCostAlertFactor is how far actual cost may exceed the estimate
+// before an alert is raised
+const DefaultCostAlertFactor = 1.5
+
+// CostAlertFactorFromEnv reads QLP_AZURE_COST_ALERT_FACTOR
+func CostAlertFactorFromEnv() float64 {
+	factor, err := strconv.ParseFloat(config.GetEnvOrDefault("QLP_AZURE_COST_ALERT_FACTOR", ""), 64)
+	if err != nil || factor <= 0 {
+		return DefaultCostAlertFactor
+	}
+	return factor
+}
+
+// ExpectedCost scales an estimate to the billed duration of a run. Hourly
+// estimates are charged for at least one hour.
+func ExpectedCost(estimate CostEstimate, duration time.Duration) float64 {
+	if estimate.BillingPeriod != "per_hour" {
+		return estimate.TotalUSD
+	}
+	return estimate.TotalUSD * math.Max(1, math.Ceil(duration.Hours()))
+}
+
+// CheckCostOverrun returns an alert when the actual cost exceeds the
+// estimate for the run's duration by more than factor
+func CheckCostOverrun(estimate CostEstimate, actual *ActualCost, factor float64) *CostAlert {
+	if actual == nil || factor <= 0 {
+		return nil
+	}
+	expected := ExpectedCost(estimate, actual.To.Sub(actual.From))
+	if actual.TotalUSD <= expected*factor {
+		return nil
+	}
+	return &CostAlert{
+		EstimatedUSD: expected,
+		ActualUSD:    actual.TotalUSD,
+		Factor:       factor,
+		Message: fmt.Sprintf("actual cost $%.2f exceeds the $%.2f estimate by more than %.1fx",
+			actual.TotalUSD, expected, factor),
+	}
+}
+
+// costQueryAPIVersion is the Cost Management query API version used
+const costQueryAPIVersion = "2023-03-01"
+
+// QueryCost queries Cost Management for the actual cost of the run. The
+// query is scoped to the subscription and filtered to the resource group or
+// the capsule-id tag, so it still answers after the group is deleted.
+func (ac *AzureClient) QueryCost(ctx context.Context, resourceGroup, capsuleID string, from, to time.Time) (*ActualCost, error) {
+	token, err := ac.credential.GetToken(ctx, policy.TokenRequestOptions{
+		Scopes: []string{ac.endpoint() + "/.default"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get management token: %w", err)
+	}
+
+	body, err := json.Marshal(costQuery(resourceGroup, capsuleID, from, to))
+	if err != nil {
+		return nil, err
+	}
+	endpoint := fmt.Sprintf("%s/subscriptions/%s/providers/Microsoft.CostManagement/query?api-version=%s",
+		ac.endpoint(), ac.subscriptionID, costQueryAPIVersion)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, wrapError("query cost", resourceGroup, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, wrapError("query cost", resourceGroup, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &Error{Op: "query cost", Resource: resourceGroup, StatusCode: resp.StatusCode,
+			Err: fmt.Errorf("%s", strings.TrimSpace(string(data)))}
+	}
+
+	cost, err := parseCostQuery(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode cost query: %w", err)
+	}
+	cost.From, cost.To, cost.QueriedAt = from, to, time.Now()
+	return cost, nil
+}
+
+// costQuery builds the Cost Management query for a run: actual cost in USD
+// and the billing currency, grouped by resource type
+func costQuery(resourceGroup, capsuleID string, from, to time.Time) map[string]interface{} {
+	filters := []map[string]interface{}{
+		{"dimensions": map[string]interface{}{"name": "ResourceGroupName", "operator": "In", "values": []string{resourceGroup}}},
+	}
+	if capsuleID != "" {
+		filters = append(filters, map[string]interface{}{
+			"tags": map[string]interface{}{"name": TagCapsuleID, "operator": "In", "values": []string{capsuleID}},
+		})
+	}
+	filter := filters[0]
+	if len(filters) > 1 {
+		filter = map[string]interface{}{"or": filters}
+	}
+	return map[string]interface{}{
+		"type":      "ActualCost",
+		"timeframe": "Custom",
+		"timePeriod": map[string]string{
+			"from": from.UTC().Format(time.RFC3339),
+			"to":   to.UTC().Format(time.RFC3339),
+		},
+		"dataset": map[string]interface{}{
+			"granularity": "None",
+			"aggregation": map[string]interface{}{
+				"totalCost":    map[string]string{"name": "Cost", "function": "Sum"},
+				"totalCostUSD": map[string]string{"name": "CostUSD", "function": "Sum"},
+			},
+			"grouping": []map[string]string{{"type": "Dimension", "name": "ResourceType"}},
+			"filter":   filter,
+		},
+	}
+}
+
+// parseCostQuery sums the rows of a Cost Management query result. The USD
+// column is used when the billing account reports it, the billing currency
+// otherwise.
+func parseCostQuery(data []byte) (*ActualCost, error) {
+	var result struct {
+		Properties struct {
+			Columns []struct {
+				Name string `json:"name"`
+			} `json:"columns"`
+			Rows [][]interface{} `json:"rows"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	column := make(map[string]int)
+	for i, c := range result.Properties.Columns {
+		column[c.Name] = i
+	}
+	costColumn, currency := "CostUSD", "USD"
+	if _, ok := column[costColumn]; !ok {
+		costColumn, currency = "Cost", ""
+	}
+	costIndex, ok := column[costColumn]
+	if !ok {
+		return nil, fmt.Errorf("no cost column in %v", result.Properties.Columns)
+	}
+
+	cost := &ActualCost{Currency: currency, Breakdown: make(map[string]float64)}
+	for _, row := range result.Properties.Rows {
+		value, _ := cellFloat(row, costIndex)
+		resourceType := "unknown"
+		if i, ok := column["ResourceType"]; ok && i < len(row) {
+			if s, ok := row[i].(string); ok && s != "" {
+				resourceType = strings.ToLower(s)
+			}
+		}
+		if i, ok := column["Currency"]; ok && i < len(row) && cost.Currency == "" {
+			cost.Currency, _ = row[i].(string)
+		}
+		cost.Breakdown[resourceType] += value
+		cost.TotalUSD += value
+	}
+	if cost.Currency == "" {
+		cost.Currency = "USD"
+	}
+	return cost, nil
+}
+
+func cellFloat(row []interface{}, i int) (float64, bool) {
+	if i >= len(row) {
+		return 0, false
+	}
+	switch v := row[i].(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
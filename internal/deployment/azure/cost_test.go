@@ -0,0 +1,125 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCostQuery(t *testing.T) {
+	cost, err := parseCostQuery([]byte(`{"properties": {
+		"columns": [{"name": "Cost"}, {"name": "CostUSD"}, {"name": "ResourceType"}, {"name": "Currency"}],
+		"rows": [[0.9, 1.0, "Microsoft.App/containerApps", "GBP"], [0.2, 0.25, "microsoft.storage/storageaccounts", "GBP"]]
+	}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost.TotalUSD != 1.25 || cost.Currency != "USD" || cost.Breakdown["microsoft.app/containerapps"] != 1.0 {
+		t.Errorf("unexpected cost %+v", cost)
+	}
+
+	cost, _ = parseCostQuery([]byte(`{"properties": {
+		"columns": [{"name": "Cost"}, {"name": "ResourceType"}, {"name": "Currency"}],
+		"rows": [[0.5, "Microsoft.Web/sites", "EUR"]]
+	}}`))
+	if cost.TotalUSD != 0.5 || cost.Currency != "EUR" {
+		t.Errorf("without a USD column the billing currency is reported: %+v", cost)
+	}
+	if _, err := parseCostQuery([]byte(`{"properties": {"columns": [{"name": "ResourceType"}]}}`)); err == nil {
+		t.Error("missing cost column should fail")
+	}
+}
+
+func TestCheckCostOverrun(t *testing.T) {
+	estimate := CostEstimate{TotalUSD: 0.30, BillingPeriod: "per_hour"}
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	run := func(total float64, d time.Duration) *ActualCost {
+		return &ActualCost{TotalUSD: total, From: start, To: start.Add(d)}
+	}
+
+	if alert := CheckCostOverrun(estimate, run(0.40, 20*time.Minute), 1.5); alert != nil {
+		t.Errorf("within factor of the one-hour minimum: %+v", alert)
+	}
+	alert := CheckCostOverrun(estimate, run(0.50, 20*time.Minute), 1.5)
+	if alert == nil || alert.EstimatedUSD != 0.30 {
+		t.Fatalf("expected an alert, got %+v", alert)
+	}
+	if !strings.Contains(alert.Message, "$0.50 exceeds the $0.30 estimate") {
+		t.Errorf("unexpected message %q", alert.Message)
+	}
+	if alert := CheckCostOverrun(estimate, run(0.80, 150*time.Minute), 1.5); alert != nil {
+		t.Errorf("estimate scales with billed hours: %+v", alert)
+	}
+	if CheckCostOverrun(estimate, nil, 1.5) != nil {
+		t.Error("no actual cost, no alert")
+	}
+}
+
+func TestQueryCost(t *testing.T) {
+	var query map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/subscriptions/sub/providers/Microsoft.CostManagement/query" || r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unexpected request "+r.URL.Path, http.StatusBadRequest)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&query)
+		w.Write([]byte(`{"properties": {"columns": [{"name": "CostUSD"}, {"name": "ResourceType"}], "rows": [[0.42, "Microsoft.App/containerApps"]]}}`))
+	}))
+	defer server.Close()
+
+	ac := &AzureClient{
+		subscriptionID:     "sub",
+		credential:         &countingCredential{expires: time.Now().Add(time.Hour)},
+		managementEndpoint: server.URL,
+	}
+	from := time.Now().Add(-time.Hour)
+	cost, err := ac.QueryCost(context.Background(), "capsule-rg-abc", "capsule-abc", from, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost.TotalUSD != 0.42 || !cost.From.Equal(from) {
+		t.Errorf("unexpected cost %+v", cost)
+	}
+	filter, _ := json.Marshal(query["dataset"].(map[string]interface{})["filter"])
+	if !strings.Contains(string(filter), `"capsule-rg-abc"`) || !strings.Contains(string(filter), `"capsule-abc"`) {
+		t.Errorf("query not scoped to the run: %s", filter)
+	}
+
+	ac.subscriptionID = "other"
+	if _, err := ac.QueryCost(context.Background(), "rg", "", from, time.Now()); err == nil {
+		t.Error("non-200 responses should fail")
+	}
+}
+
+type fakeCosts struct{ cost *ActualCost }
+
+func (f fakeCosts) QueryCost(ctx context.Context, resourceGroup, capsuleID string, from, to time.Time) (*ActualCost, error) {
+	cost := *f.cost
+	cost.From, cost.To = from, to
+	return &cost, nil
+}
+
+func TestRefreshActualCost(t *testing.T) {
+	dm := NewDeploymentManager(nil, 10)
+	dm.costs = fakeCosts{&ActualCost{TotalUSD: 2.0, Currency: "USD"}}
+	dm.costAlert = 2
+	start := time.Now().Add(-30 * time.Minute)
+	result := &DeploymentResult{
+		ResourceGroup: "rg",
+		StartTime:     start,
+		EndTime:       start.Add(30 * time.Minute),
+		CostEstimate:  CostEstimate{TotalUSD: 0.27, BillingPeriod: "per_hour"},
+	}
+
+	dm.RefreshActualCost(context.Background(), "capsule", result)
+	if result.ActualCost == nil || result.ActualCost.TotalUSD != 2.0 {
+		t.Fatalf("actual cost not recorded: %+v", result.ActualCost)
+	}
+	if result.CostAlert == nil || result.CostAlert.Factor != 2 {
+		t.Errorf("expected a cost alert: %+v", result.CostAlert)
+	}
+}
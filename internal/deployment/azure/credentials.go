@@ -0,0 +1,279 @@
+package azure
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+// AuthMethod is a way for the client to authenticate to Azure
+type AuthMethod string
+
+const (
+	// AuthClientSecret authenticates as a service principal with a secret
+	AuthClientSecret AuthMethod = "client_secret"
+	// AuthCertificate authenticates as a service principal with a certificate
+	AuthCertificate AuthMethod = "certificate"
+	// AuthManagedIdentity uses the managed identity of the host
+	AuthManagedIdentity AuthMethod = "managed_identity"
+	// AuthWorkloadIdentity exchanges the federated token AKS projects into
+	// the pod for an Entra ID token
+	AuthWorkloadIdentity AuthMethod = "workload_identity"
+	// AuthAzureCLI uses the az CLI login, for development machines
+	AuthAzureCLI AuthMethod = "azure_cli"
+	// AuthDefault is the SDK's default chain: environment, workload
+	// identity, managed identity, then developer logins
+	AuthDefault AuthMethod = "default"
+)
+
+// tokenRefreshMargin is how long before expiry cached tokens are refreshed
+const tokenRefreshMargin = 5 * time.Minute
+
+// ParseAuthMethods splits a comma-separated list of auth methods
+func ParseAuthMethods(list string) []AuthMethod {
+	var methods []AuthMethod
+	for _, m := range strings.Split(list, ",") {
+		if m = strings.TrimSpace(strings.ToLower(m)); m != "" {
+			methods = append(methods, AuthMethod(m))
+		}
+	}
+	return methods
+}
+
+// authMethods returns the credential chain of the config: the configured
+// methods, or the single method its credential fields imply
+func (c ClientConfig) authMethods() []AuthMethod {
+	switch {
+	case len(c.AuthMethods) > 0:
+		return c.AuthMethods
+	case c.UseManagedIdentity:
+		return []AuthMethod{AuthManagedIdentity}
+	case c.ClientSecret != "":
+		return []AuthMethod{AuthClientSecret}
+	case c.CertificatePath != "":
+		return []AuthMethod{AuthCertificate}
+	}
+	return []AuthMethod{AuthDefault}
+}
+
+// newCredential creates the credential chain of the config. Credentials are
+// shared between clients with the same identity so their tokens are cached
+// and refreshed once.
+func newCredential(config ClientConfig) (azcore.TokenCredential, error) {
+	key := config.identityKey()
+	sharedCredentials.Lock()
+	defer sharedCredentials.Unlock()
+	if cred, ok := sharedCredentials.m[key]; ok {
+		return cred, nil
+	}
+
+	methods := config.authMethods()
+	chain := make([]azcore.TokenCredential, 0, len(methods))
+	for _, method := range methods {
+		cred, err := buildCredential(method, config)
+		if err != nil {
+			return nil, fmt.Errorf("%s credential: %w", method, err)
+		}
+		chain = append(chain, cred)
+	}
+	cred := chain[0]
+	if len(chain) > 1 {
+		var err error
+		if cred, err = azidentity.NewChainedTokenCredential(chain, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	shared := newRefreshingCredential(cred)
+	sharedCredentials.m[key] = shared
+	return shared, nil
+}
+
+var sharedCredentials = struct {
+	sync.Mutex
+	m map[string]azcore.TokenCredential
+}{m: make(map[string]azcore.TokenCredential)}
+
+// identityKey identifies the credentials of the config without holding its
+// secrets
+func (c ClientConfig) identityKey() string {
+	methods := make([]string, 0, len(c.authMethods()))
+	for _, m := range c.authMethods() {
+		methods = append(methods, string(m))
+	}
+	secret := sha256.Sum256([]byte(c.ClientSecret + "\x00" + c.CertificatePassword))
+	return strings.Join([]string{strings.Join(methods, ","), c.TenantID, c.ClientID,
+		c.CertificatePath, c.FederatedTokenFile, hex.EncodeToString(secret[:8])}, "|")
+}
+
+// buildCredential creates the credential for one auth method
+func buildCredential(method AuthMethod, config ClientConfig) (azcore.TokenCredential, error) {
+	switch method {
+	case AuthClientSecret:
+		if config.TenantID == "" || config.ClientID == "" || config.ClientSecret == "" {
+			return nil, fmt.Errorf("service principal auth requires a tenant ID, client ID and client secret")
+		}
+		return azidentity.NewClientSecretCredential(config.TenantID, config.ClientID, config.ClientSecret, nil)
+	case AuthCertificate:
+		if config.TenantID == "" || config.ClientID == "" || config.CertificatePath == "" {
+			return nil, fmt.Errorf("certificate auth requires a tenant ID, client ID and certificate path")
+		}
+		data, err := os.ReadFile(config.CertificatePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read certificate: %w", err)
+		}
+		var password []byte
+		if config.CertificatePassword != "" {
+			password = []byte(config.CertificatePassword)
+		}
+		certs, key, err := azidentity.ParseCertificates(data, password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		return azidentity.NewClientCertificateCredential(config.TenantID, config.ClientID, certs, key, nil)
+	case AuthManagedIdentity:
+		options := &azidentity.ManagedIdentityCredentialOptions{}
+		if config.ClientID != "" {
+			options.ID = azidentity.ClientID(config.ClientID)
+		}
+		return azidentity.NewManagedIdentityCredential(options)
+	case AuthWorkloadIdentity:
+		// Empty fields fall back to the AZURE_* variables the AKS workload
+		// identity webhook injects
+		return azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+			TenantID:      config.TenantID,
+			ClientID:      config.ClientID,
+			TokenFilePath: config.FederatedTokenFile,
+		})
+	case AuthAzureCLI:
+		return azidentity.NewAzureCLICredential(&azidentity.AzureCLICredentialOptions{TenantID: config.TenantID})
+	case AuthDefault:
+		return azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{TenantID: config.TenantID})
+	}
+	return nil, fmt.Errorf("unknown auth method %q", method)
+}
+
+// refreshingCredential caches tokens per scope and refreshes them ahead of
+// expiry, so long validations and direct GetToken callers such as the SKU
+// lookups and Key Vault never present a token about to lapse. When a refresh
+// fails the cached token is served until it actually expires.
+type refreshingCredential struct {
+	cred   azcore.TokenCredential
+	margin time.Duration
+	now    func() time.Time
+
+	mu     sync.Mutex
+	tokens map[string]azcore.AccessToken
+}
+
+func newRefreshingCredential(cred azcore.TokenCredential) *refreshingCredential {
+	return &refreshingCredential{
+		cred:   cred,
+		margin: tokenRefreshMargin,
+		now:    time.Now,
+		tokens: make(map[string]azcore.AccessToken),
+	}
+}
+
+// GetToken implements azcore.TokenCredential
+func (rc *refreshingCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	key := options.TenantID + "|" + strings.Join(options.Scopes, " ")
+	if options.Claims != "" {
+		// Claims challenges need a fresh token
+		return rc.cred.GetToken(ctx, options)
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	cached, ok := rc.tokens[key]
+	now := rc.now()
+	if ok && now.Add(rc.margin).Before(cached.ExpiresOn) {
+		return cached, nil
+	}
+	token, err := rc.cred.GetToken(ctx, options)
+	if err != nil {
+		if ok && now.Before(cached.ExpiresOn) {
+			return cached, nil
+		}
+		return azcore.AccessToken{}, err
+	}
+	rc.tokens[key] = token
+	return token, nil
+}
+
+// TenantConfigs holds the Azure credentials of each QLP tenant, so tenants
+// deploy validations into their own subscriptions. The "*" entry applies to
+// tenants without their own.
+type TenantConfigs map[string]TenantConfig
+
+// TenantConfig is one tenant's Azure configuration. Empty subscription,
+// tenant and location fields inherit the base configuration; credentials
+// never mix, so a tenant setting any credential field uses only its own.
+type TenantConfig struct {
+	SubscriptionID      string   `json:"subscription_id,omitempty"`
+	TenantID            string   `json:"tenant_id,omitempty"`
+	Location            string   `json:"location,omitempty"`
+	AuthMethods         []string `json:"auth_methods,omitempty"`
+	ClientID            string   `json:"client_id,omitempty"`
+	ClientSecret        string   `json:"client_secret,omitempty"`
+	CertificatePath     string   `json:"certificate_path,omitempty"`
+	CertificatePassword string   `json:"certificate_password,omitempty"`
+	FederatedTokenFile  string   `json:"federated_token_file,omitempty"`
+	UseManagedIdentity  bool     `json:"use_managed_identity,omitempty"`
+}
+
+// LoadTenantConfigs reads tenant Azure configuration from a JSON file of the
+// form {"tenant-id": {"subscription_id": "...", "client_id": "...", ...}}
+func LoadTenantConfigs(path string) (TenantConfigs, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenant Azure configuration: %w", err)
+	}
+	var configs TenantConfigs
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse tenant Azure configuration: %w", err)
+	}
+	return configs, nil
+}
+
+// Resolve returns the client configuration of a tenant
+func (tc TenantConfigs) Resolve(tenantID string, base ClientConfig) ClientConfig {
+	t, ok := tc[tenantID]
+	if !ok {
+		if t, ok = tc["*"]; !ok {
+			return base
+		}
+	}
+
+	config := base
+	if t.SubscriptionID != "" {
+		config.SubscriptionID = t.SubscriptionID
+	}
+	if t.TenantID != "" {
+		config.TenantID = t.TenantID
+	}
+	if t.Location != "" {
+		config.Location = t.Location
+	}
+	if len(t.AuthMethods) > 0 || t.ClientID != "" || t.ClientSecret != "" || t.CertificatePath != "" ||
+		t.FederatedTokenFile != "" || t.UseManagedIdentity {
+		config.AuthMethods = ParseAuthMethods(strings.Join(t.AuthMethods, ","))
+		config.ClientID = t.ClientID
+		config.ClientSecret = t.ClientSecret
+		config.CertificatePath = t.CertificatePath
+		config.CertificatePassword = t.CertificatePassword
+		config.FederatedTokenFile = t.FederatedTokenFile
+		config.UseManagedIdentity = t.UseManagedIdentity
+	}
+	return config
+}
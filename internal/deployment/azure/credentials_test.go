@@ -0,0 +1,155 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+func TestAuthMethods(t *testing.T) {
+	tests := []struct {
+		config ClientConfig
+		want   []AuthMethod
+	}{
+		{ClientConfig{}, []AuthMethod{AuthDefault}},
+		{ClientConfig{ClientSecret: "s"}, []AuthMethod{AuthClientSecret}},
+		{ClientConfig{CertificatePath: "c.pem"}, []AuthMethod{AuthCertificate}},
+		{ClientConfig{ClientSecret: "s", UseManagedIdentity: true}, []AuthMethod{AuthManagedIdentity}},
+		{ClientConfig{AuthMethods: ParseAuthMethods(" Workload_Identity, managed_identity ,")},
+			[]AuthMethod{AuthWorkloadIdentity, AuthManagedIdentity}},
+	}
+	for _, tt := range tests {
+		if got := tt.config.authMethods(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("authMethods(%+v) = %v, want %v", tt.config, got, tt.want)
+		}
+	}
+}
+
+func TestBuildCredential(t *testing.T) {
+	cred, err := buildCredential(AuthClientSecret, ClientConfig{TenantID: "tenant", ClientID: "client", ClientSecret: "secret"})
+	if _, ok := cred.(*azidentity.ClientSecretCredential); err != nil || !ok {
+		t.Errorf("expected a client secret credential, got %T, %v", cred, err)
+	}
+	if _, err := buildCredential(AuthClientSecret, ClientConfig{ClientSecret: "secret"}); err == nil {
+		t.Error("service principal auth without tenant and client IDs should fail")
+	}
+	cred, err = buildCredential(AuthManagedIdentity, ClientConfig{ClientID: "client"})
+	if _, ok := cred.(*azidentity.ManagedIdentityCredential); err != nil || !ok {
+		t.Errorf("expected a managed identity credential, got %T, %v", cred, err)
+	}
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("federated"), 0600)
+	cred, err = buildCredential(AuthWorkloadIdentity, ClientConfig{TenantID: "tenant", ClientID: "client", FederatedTokenFile: tokenFile})
+	if _, ok := cred.(*azidentity.WorkloadIdentityCredential); err != nil || !ok {
+		t.Errorf("expected a workload identity credential, got %T, %v", cred, err)
+	}
+
+	if _, err := buildCredential(AuthCertificate, ClientConfig{TenantID: "tenant", ClientID: "client", CertificatePath: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Error("missing certificate should fail")
+	}
+	if _, err := buildCredential("kerberos", ClientConfig{}); err == nil {
+		t.Error("unknown auth method should fail")
+	}
+}
+
+func TestNewCredentialShared(t *testing.T) {
+	config := ClientConfig{TenantID: "tenant", ClientID: "shared-client", ClientSecret: "secret"}
+	a, err := newCredential(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := newCredential(config)
+	config.ClientSecret = "rotated"
+	c, _ := newCredential(config)
+	if a != b || a == c {
+		t.Error("credentials should be shared per identity and secret")
+	}
+}
+
+type countingCredential struct {
+	calls   int
+	expires time.Time
+	err     error
+}
+
+func (c *countingCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	c.calls++
+	if c.err != nil {
+		return azcore.AccessToken{}, c.err
+	}
+	return azcore.AccessToken{Token: "token", ExpiresOn: c.expires}, nil
+}
+
+func TestRefreshingCredential(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	inner := &countingCredential{expires: now.Add(time.Hour)}
+	rc := newRefreshingCredential(inner)
+	rc.now = func() time.Time { return now }
+	ctx := context.Background()
+	scopes := policy.TokenRequestOptions{Scopes: []string{"https://management.azure.com/.default"}}
+
+	rc.GetToken(ctx, scopes)
+	rc.GetToken(ctx, scopes)
+	if inner.calls != 1 {
+		t.Fatalf("token should be cached, got %d calls", inner.calls)
+	}
+	rc.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{"https://vault.azure.net/.default"}})
+	if inner.calls != 2 {
+		t.Fatalf("scopes are cached separately, got %d calls", inner.calls)
+	}
+
+	// Within the refresh margin the token is renewed
+	now = now.Add(57 * time.Minute)
+	inner.expires = now.Add(time.Hour)
+	if tok, _ := rc.GetToken(ctx, scopes); inner.calls != 3 || !tok.ExpiresOn.Equal(inner.expires) {
+		t.Fatalf("token should be refreshed before expiry, got %d calls", inner.calls)
+	}
+
+	// A failed refresh serves the cached token until it expires
+	inner.err = errors.New("identity provider unavailable")
+	now = now.Add(58 * time.Minute)
+	if _, err := rc.GetToken(ctx, scopes); err != nil {
+		t.Errorf("cached token should be served while valid: %v", err)
+	}
+	now = now.Add(5 * time.Minute)
+	if _, err := rc.GetToken(ctx, scopes); err == nil {
+		t.Error("expired token should not be served")
+	}
+}
+
+func TestTenantConfigsResolve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	os.WriteFile(path, []byte(`{
+		"acme": {"subscription_id": "acme-sub", "tenant_id": "acme-tenant", "auth_methods": ["workload_identity"], "client_id": "acme-app"},
+		"globex": {"location": "eastus"}
+	}`), 0600)
+	configs, err := LoadTenantConfigs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := ClientConfig{SubscriptionID: "base-sub", TenantID: "base-tenant", Location: "uksouth", ClientID: "base-app", ClientSecret: "base-secret"}
+
+	acme := configs.Resolve("acme", base)
+	if acme.SubscriptionID != "acme-sub" || acme.Location != "uksouth" || acme.ClientID != "acme-app" || acme.ClientSecret != "" {
+		t.Errorf("acme should use its own credentials only: %+v", acme)
+	}
+	if !reflect.DeepEqual(acme.authMethods(), []AuthMethod{AuthWorkloadIdentity}) {
+		t.Errorf("acme auth methods = %v", acme.authMethods())
+	}
+	globex := configs.Resolve("globex", base)
+	if globex.Location != "eastus" || globex.ClientSecret != "base-secret" {
+		t.Errorf("globex should inherit the base credentials: %+v", globex)
+	}
+	if got := configs.Resolve("initech", base); !reflect.DeepEqual(got, base) {
+		t.Errorf("unknown tenants use the base config: %+v", got)
+	}
+}
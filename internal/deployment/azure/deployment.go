@@ -2,20 +2,35 @@ package azure
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
+	"QLP/internal/audit"
 	"QLP/internal/logger"
+	"QLP/internal/metering"
+	"QLP/internal/metrics"
+	"QLP/internal/tracing"
 	"QLP/internal/packaging"
+	"QLP/internal/secrets"
+	"QLP/internal/tfstate"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
 // DeploymentManager handles Azure deployment validation for QuantumCapsules
 type DeploymentManager struct {
-	logger      logger.Interface
-	azureClient *AzureClient
-	costLimit   float64 // Maximum cost in USD per deployment
+	logger        logger.Interface
+	azureClient   *AzureClient
+	availability  AvailabilityChecker
+	quota         QuotaChecker
+	costs         CostQuerier
+	stateBackends StateBackendProvisioner
+	terraform     tfstate.Runner
+	kubectl       KubectlRunner
+	costAlert     float64 // Alert when actual cost exceeds the estimate by this factor
+	costLimit     float64 // Maximum cost in USD per deployment
 }
 
 // DeploymentConfig configures a capsule deployment
@@ -23,9 +38,20 @@ type DeploymentConfig struct {
 	CapsuleID       string
 	ResourceGroup   string
 	Location        string
+	FallbackRegions []string // Tried in order when Location fails preflight
 	TTL             time.Duration
 	CostLimitUSD    float64
 	SecurityContext SecurityContext
+
+	// StateBackend is the remote state the Terraform must initialize
+	// against, created first when ProvisionStateBackend is set; nil skips
+	// the check
+	StateBackend          *tfstate.Backend
+	ProvisionStateBackend bool
+
+	// Strategy rolls the containers out; blue/green and canary verify the
+	// new version before it takes all traffic and roll back when it fails
+	Strategy StrategyConfig
 }
 
 // SecurityContext defines security settings for deployment
@@ -40,6 +66,8 @@ type SecurityContext struct {
 type DeploymentResult struct {
 	CapsuleID         string                 `json:"capsule_id"`
 	ResourceGroup     string                 `json:"resource_group"`
+	Location          string                 `json:"location"`
+	RequestedLocation string                 `json:"requested_location,omitempty"` // Set when preflight moved the deployment to a fallback region
 	Status            DeploymentStatus       `json:"status"`
 	StartTime         time.Time              `json:"start_time"`
 	EndTime           time.Time              `json:"end_time"`
@@ -47,10 +75,16 @@ type DeploymentResult struct {
 	HealthChecks      []HealthCheckResult    `json:"health_checks"`
 	TestResults       map[string]TestResult  `json:"test_results"`
 	CostEstimate      CostEstimate           `json:"cost_estimate"`
+	ActualCost        *ActualCost            `json:"actual_cost,omitempty"`
+	CostAlert         *CostAlert             `json:"cost_alert,omitempty"`
 	LogsURL           string                 `json:"logs_url"`
 	DestroyedAt       *time.Time             `json:"destroyed_at,omitempty"`
 	ErrorMessage      string                 `json:"error_message,omitempty"`
+	AvailabilityIssues []string              `json:"availability_issues,omitempty"`
+	Strategy          *StrategyOutcome       `json:"strategy,omitempty"`
 	DeploymentOutputs map[string]interface{} `json:"deployment_outputs"`
+
+	meteredUSD float64 // Actual cost already metered to the tenant
 }
 
 // DeploymentStatus represents the current state of deployment
@@ -99,15 +133,30 @@ type CostEstimate struct {
 
 // NewDeploymentManager creates a new deployment manager
 func NewDeploymentManager(azureClient *AzureClient, costLimit float64) *DeploymentManager {
-	return &DeploymentManager{
+	dm := &DeploymentManager{
 		logger:      logger.GetDefaultLogger().WithComponent("azure_deployment"),
 		azureClient: azureClient,
 		costLimit:   costLimit,
+		costAlert:   CostAlertFactorFromEnv(),
+		terraform:   tfstate.CommandRunner,
+		kubectl:     KubectlCommand,
+	}
+	if azureClient != nil {
+		dm.availability = azureClient
+		dm.quota = azureClient
+		dm.costs = azureClient
+		dm.stateBackends = azureClient
 	}
+	return dm
 }
 
 // Deploy validates a QuantumDrop by deploying it to Azure
-func (dm *DeploymentManager) Deploy(ctx context.Context, capsule *packaging.QuantumDrop, config DeploymentConfig) (*DeploymentResult, error) {
+func (dm *DeploymentManager) Deploy(ctx context.Context, capsule *packaging.QuantumDrop, config DeploymentConfig) (_ *DeploymentResult, err error) {
+	ctx, span := tracing.StartSpan(ctx, "azure.deploy",
+		attribute.String("capsule.id", config.CapsuleID),
+		attribute.String("azure.resource_group", config.ResourceGroup))
+	defer func() { tracing.EndSpan(span, err) }()
+
 	dm.logger.Info("Starting Azure deployment validation",
 		zap.String("capsule_id", config.CapsuleID),
 		zap.String("resource_group", config.ResourceGroup),
@@ -117,12 +166,25 @@ func (dm *DeploymentManager) Deploy(ctx context.Context, capsule *packaging.Quan
 	result := &DeploymentResult{
 		CapsuleID:     config.CapsuleID,
 		ResourceGroup: config.ResourceGroup,
+		Location:      config.Location,
 		Status:        StatusPending,
 		StartTime:     time.Now(),
 		HealthChecks:  make([]HealthCheckResult, 0),
 		TestResults:   make(map[string]TestResult),
 		DeploymentOutputs: make(map[string]interface{}),
 	}
+	defer func() {
+		metrics.ObserveDeployment(string(result.Status), time.Since(result.StartTime))
+	}()
+
+	// Phase 0: Check the region offers the resources and SKUs the
+	// Terraform declares, and the subscription has the quota for them,
+	// before paying for a deployment that cannot succeed
+	if err := dm.preflight(ctx, capsule, &config, result); err != nil {
+		result.Status = StatusFailed
+		result.ErrorMessage = err.Error()
+		return result, err
+	}
 
 	// Phase 1: Create isolated resource group
 	if err := dm.createResourceGroup(ctx, config); err != nil {
@@ -140,28 +202,49 @@ func (dm *DeploymentManager) Deploy(ctx context.Context, capsule *packaging.Quan
 		return result, err
 	}
 
-	// Phase 3: Deploy applications
-	if err := dm.deployApplications(ctx, capsule, config, result); err != nil {
+	// Phase 3: Resolve app configuration and deploy applications
+	resolution, err := dm.resolveEnvironment(ctx, capsule, config)
+	if err != nil {
 		result.Status = StatusFailed
 		result.ErrorMessage = err.Error()
 		dm.cleanup(ctx, config.ResourceGroup)
 		return result, err
 	}
+	result.DeploymentOutputs["environment_sources"] = resolution.Sources
 
-	// Phase 4: Run health checks
-	result.Status = StatusTesting
-	if err := dm.runHealthChecks(ctx, capsule, config, result); err != nil {
-		result.Status = StatusUnhealthy
+	if err := dm.deployApplications(ctx, capsule, config, result, resolution); err != nil {
+		result.Status = StatusFailed
 		result.ErrorMessage = err.Error()
-		// Continue to tests even if health checks fail
-	} else {
-		result.Status = StatusHealthy
+		dm.cleanup(ctx, config.ResourceGroup)
+		return result, err
 	}
 
-	// Phase 5: Run functional tests
-	if err := dm.runFunctionalTests(ctx, capsule, config, result); err != nil {
-		dm.logger.Warn("Functional tests failed", zap.Error(err))
-		// Not marking as failed - tests might be optional
+	result.Status = StatusTesting
+	if config.Strategy.Gradual() {
+		// Phases 4 and 5 run at each step of the rollout. A rolled back
+		// rollout leaves the previous version serving, so the resource
+		// group is kept.
+		if err := dm.rollout(ctx, capsule, config, result); err != nil {
+			result.Status = StatusFailed
+			result.ErrorMessage = err.Error()
+			return result, err
+		}
+		result.Status = StatusHealthy
+	} else {
+		// Phase 4: Run health checks
+		if err := dm.runHealthChecks(ctx, capsule, config, result); err != nil {
+			result.Status = StatusUnhealthy
+			result.ErrorMessage = err.Error()
+			// Continue to tests even if health checks fail
+		} else {
+			result.Status = StatusHealthy
+		}
+
+		// Phase 5: Run functional tests
+		if err := dm.runFunctionalTests(ctx, capsule, config, result); err != nil {
+			dm.logger.Warn("Functional tests failed", zap.Error(err))
+			// Not marking as failed - tests might be optional
+		}
 	}
 
 	// Phase 6: Calculate costs and generate report
@@ -184,6 +267,61 @@ func (dm *DeploymentManager) Deploy(ctx context.Context, capsule *packaging.Quan
 	return result, nil
 }
 
+// preflight fails the deployment when the region does not offer a resource
+// type or VM size the capsule's Terraform declares, or the subscription lacks
+// the vCPU quota for it. When a fallback region passes instead, the
+// deployment moves there.
+func (dm *DeploymentManager) preflight(ctx context.Context, capsule *packaging.QuantumDrop, config *DeploymentConfig, result *DeploymentResult) error {
+	resources := ExtractAzureResources(dm.extractTerraformFiles(capsule))
+	if len(resources) == 0 || config.Location == "" {
+		return nil
+	}
+	result.AvailabilityIssues = dm.preflightIssues(ctx, resources, config.Location)
+	if len(result.AvailabilityIssues) == 0 {
+		return nil
+	}
+	dm.logger.Warn("Deployment failed preflight in region",
+		zap.String("capsule_id", config.CapsuleID),
+		zap.String("location", config.Location),
+		zap.Strings("issues", result.AvailabilityIssues),
+	)
+
+	for _, region := range config.FallbackRegions {
+		region = NormalizeRegion(region)
+		if region == NormalizeRegion(config.Location) || len(dm.preflightIssues(ctx, resources, region)) > 0 {
+			continue
+		}
+		dm.logger.Info("Moving deployment to fallback region",
+			zap.String("capsule_id", config.CapsuleID),
+			zap.String("requested_location", config.Location),
+			zap.String("location", region),
+		)
+		result.RequestedLocation = config.Location
+		config.Location = region
+		result.Location = region
+		return nil
+	}
+
+	err := fmt.Errorf("preflight found %d problems in %s: %s",
+		len(result.AvailabilityIssues), config.Location, strings.Join(result.AvailabilityIssues, "; "))
+	if len(config.FallbackRegions) > 0 {
+		err = fmt.Errorf("%w (fallback regions %s failed preflight too)", err, strings.Join(config.FallbackRegions, ", "))
+	}
+	return err
+}
+
+// preflightIssues lists the availability and quota problems in a region
+func (dm *DeploymentManager) preflightIssues(ctx context.Context, resources []TerraformResource, region string) []string {
+	var issues []string
+	if dm.availability != nil {
+		issues = CheckAvailability(ctx, dm.availability, resources, region)
+	}
+	if dm.quota != nil && len(issues) == 0 {
+		issues = CheckQuota(ctx, dm.quota, resources, region)
+	}
+	return issues
+}
+
 // createResourceGroup creates an isolated resource group for the deployment
 func (dm *DeploymentManager) createResourceGroup(ctx context.Context, config DeploymentConfig) error {
 	spec := ResourceGroupSpec{
@@ -213,6 +351,12 @@ func (dm *DeploymentManager) deployInfrastructure(ctx context.Context, capsule *
 		return nil
 	}
 
+	if config.StateBackend != nil {
+		if err := dm.validateStateBackend(ctx, terraformFiles, *config.StateBackend, config.ProvisionStateBackend, result); err != nil {
+			return err
+		}
+	}
+
 	// TODO: Implement Terraform deployment
 	// 1. Create temporary directory
 	// 2. Write Terraform files
@@ -224,8 +368,54 @@ func (dm *DeploymentManager) deployInfrastructure(ctx context.Context, capsule *
 	return nil
 }
 
+// validateStateBackend runs terraform init in each root module against the
+// remote state backend, so state problems surface before anything is
+// applied. Each module's state lives under the backend's key prefix.
+func (dm *DeploymentManager) validateStateBackend(ctx context.Context, files map[string]string, backend tfstate.Backend, provision bool, result *DeploymentResult) error {
+	if dm.stateBackends == nil {
+		dm.logger.Info("No Azure client, skipping the state backend check")
+		return nil
+	}
+	if provision {
+		if err := dm.stateBackends.ProvisionStateBackend(ctx, backend); err != nil {
+			return fmt.Errorf("failed to provision the Terraform state backend: %w", err)
+		}
+	}
+	key, err := dm.stateBackends.StateBackendKey(ctx, backend)
+	if err != nil {
+		return fmt.Errorf("failed to access the Terraform state backend: %w", err)
+	}
+	result.DeploymentOutputs["state_backend"] = backend
+
+	inits, err := tfstate.ValidateInit(ctx, files, backend, []string{"ARM_ACCESS_KEY=" + key}, dm.terraform)
+	if errors.Is(err, tfstate.ErrNoTerraform) {
+		result.TestResults["terraform_init"] = TestResult{Name: "terraform_init", Status: "skip",
+			Output: err.Error(), Timestamp: time.Now()}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to run terraform init: %w", err)
+	}
+	for _, init := range inits {
+		status := "pass"
+		if !init.Passed {
+			status = "fail"
+		}
+		name := "terraform_init:" + init.Module
+		result.TestResults[name] = TestResult{
+			Name:      name,
+			Status:    status,
+			Duration:  init.Duration,
+			Output:    init.Output,
+			Details:   map[string]interface{}{"state_key": init.StateKey},
+			Timestamp: time.Now(),
+		}
+	}
+	return tfstate.Failed(inits)
+}
+
 // deployApplications deploys containerized applications from the capsule
-func (dm *DeploymentManager) deployApplications(ctx context.Context, capsule *packaging.QuantumDrop, config DeploymentConfig, result *DeploymentResult) error {
+func (dm *DeploymentManager) deployApplications(ctx context.Context, capsule *packaging.QuantumDrop, config DeploymentConfig, result *DeploymentResult, resolution *secrets.Resolution) error {
 	dm.logger.Info("Deploying applications",
 		zap.String("capsule_id", config.CapsuleID),
 	)
@@ -237,17 +427,131 @@ func (dm *DeploymentManager) deployApplications(ctx context.Context, capsule *pa
 		return nil
 	}
 
+	env, containerSecrets := BuildContainerEnv(capsule.Metadata.RequiredEnv, resolution)
+	envNames := make([]string, 0, len(env))
+	for _, e := range env {
+		envNames = append(envNames, e.Name)
+	}
+	dm.logger.Info("Prepared container environment",
+		zap.Strings("env", envNames),
+		zap.Int("secrets", len(containerSecrets)),
+	)
+
 	// TODO: Implement application deployment
 	// 1. Build Docker images
 	// 2. Push to Azure Container Registry
 	// 3. Deploy to Azure Container Apps or AKS
 	// 4. Configure ingress and networking
 	// 5. Set up monitoring
+	// 6. Apply env and containerSecrets to the container template
 
 	dm.logger.Info("Application deployment completed")
 	return nil
 }
 
+// resolveEnvironment resolves the configuration the drop declares. The
+// deployment's Key Vault, when set, takes priority over the default sources.
+func (dm *DeploymentManager) resolveEnvironment(ctx context.Context, capsule *packaging.QuantumDrop, config DeploymentConfig) (*secrets.Resolution, error) {
+	resolver := secrets.Default()
+	if vault := config.SecurityContext.SecretVaultName; vault != "" {
+		source := secrets.NewKeyVaultSource(fmt.Sprintf("https://%s.vault.azure.net", vault), dm.azureClient.credential)
+		resolver = resolver.With(source)
+	}
+	if resolver == nil {
+		resolver = secrets.NewResolver()
+	}
+
+	resolution := resolver.Resolve(ctx, audit.TenantFromContext(ctx), capsule.Metadata.RequiredEnv)
+	if err := secrets.MissingError(resolution.Missing); err != nil {
+		return resolution, err
+	}
+	return resolution, nil
+}
+
+// ContainerEnvVar is an environment variable in a container template; secret
+// values are referenced by name rather than inlined
+type ContainerEnvVar struct {
+	Name      string `json:"name"`
+	Value     string `json:"value,omitempty"`
+	SecretRef string `json:"secretRef,omitempty"`
+}
+
+// ContainerSecret is a secret stored on the container app
+type ContainerSecret struct {
+	Name  string `json:"name"`
+	Value string `json:"-"`
+}
+
+// BuildContainerEnv turns resolved configuration into container env entries,
+// moving secret values into container secrets
+func BuildContainerEnv(requirements []secrets.Requirement, resolution *secrets.Resolution) ([]ContainerEnvVar, []ContainerSecret) {
+	env := make([]ContainerEnvVar, 0, len(requirements))
+	var containerSecrets []ContainerSecret
+	for _, req := range requirements {
+		value, ok := resolution.Values[req.Name]
+		if !ok {
+			continue
+		}
+		if req.Secret {
+			ref := secrets.KeyVaultSecretName(req.Name)
+			containerSecrets = append(containerSecrets, ContainerSecret{Name: ref, Value: value})
+			env = append(env, ContainerEnvVar{Name: req.Name, SecretRef: ref})
+		} else {
+			env = append(env, ContainerEnvVar{Name: req.Name, Value: value})
+		}
+	}
+	return env, containerSecrets
+}
+
+// rollout runs the configured strategy, verifying each step with the
+// health checks and functional tests, and records its outcome
+func (dm *DeploymentManager) rollout(ctx context.Context, capsule *packaging.QuantumDrop, config DeploymentConfig, result *DeploymentResult) error {
+	if err := config.Strategy.Validate(); err != nil {
+		return err
+	}
+	var router TrafficRouter
+	switch config.Strategy.Platform {
+	case PlatformKubernetes:
+		router = NewKubernetesSlots(dm.kubectl, config.Strategy.App, config.Strategy.Namespace)
+	default:
+		router = NewAppServiceSlots(dm.azureClient, config.ResourceGroup, config.Strategy.App, config.Strategy.Slot, config.Location)
+	}
+
+	verify := func(ctx context.Context) error {
+		checks := len(result.HealthChecks)
+		if err := dm.runHealthChecks(ctx, capsule, config, result); err != nil {
+			return err
+		}
+		for _, check := range result.HealthChecks[checks:] {
+			if check.Status != "pass" {
+				return fmt.Errorf("health check %s failed: %s", check.Name, check.Message)
+			}
+		}
+		if err := dm.runFunctionalTests(ctx, capsule, config, result); err != nil {
+			return err
+		}
+		for name, test := range result.TestResults {
+			if test.Status == "fail" {
+				return fmt.Errorf("functional test %s failed: %s", name, test.Output)
+			}
+		}
+		return nil
+	}
+
+	release := Release{Version: config.CapsuleID, Image: config.Strategy.Image}
+	outcome := RunStrategy(ctx, config.Strategy, router, release, verify)
+	result.Strategy = outcome
+	dm.logger.Info("Rollout finished",
+		zap.String("capsule_id", config.CapsuleID),
+		zap.String("strategy", string(outcome.Strategy)),
+		zap.String("status", string(outcome.Status)),
+	)
+	if outcome.Status != StrategySucceeded {
+		return fmt.Errorf("%s rollout %s: %s", outcome.Strategy, outcome.Status, outcome.Error)
+	}
+	return nil
+}
+
 // runHealthChecks performs health checks on deployed services
 func (dm *DeploymentManager) runHealthChecks(ctx context.Context, capsule *packaging.QuantumDrop, config DeploymentConfig, result *DeploymentResult) error {
 	dm.logger.Info("Running health checks",
@@ -331,6 +635,56 @@ func (dm *DeploymentManager) calculateCosts(ctx context.Context, config Deployme
 	dm.logger.Info("Cost calculation completed",
 		zap.Float64("total_cost_usd", result.CostEstimate.TotalUSD),
 	)
+
+	dm.RefreshActualCost(ctx, config.CapsuleID, result)
+}
+
+// RefreshActualCost queries the accrued cost of the run from Cost
+// Management and raises a cost alert when it exceeds the estimate by more
+// than the alert factor. Cost data lags usage, so callers can refresh it
+// again after the run to get the final figure.
+func (dm *DeploymentManager) RefreshActualCost(ctx context.Context, capsuleID string, result *DeploymentResult) {
+	if dm.costs == nil {
+		return
+	}
+	to := result.EndTime
+	if to.IsZero() {
+		to = time.Now()
+	}
+	actual, err := dm.costs.QueryCost(ctx, result.ResourceGroup, capsuleID, result.StartTime, to)
+	if err != nil {
+		dm.logger.Warn("Failed to query actual cost",
+			zap.String("resource_group", result.ResourceGroup),
+			zap.Error(err),
+		)
+		return
+	}
+	result.ActualCost = actual
+	result.CostAlert = CheckCostOverrun(result.CostEstimate, actual, dm.costAlert)
+	metrics.ObserveDeploymentCost(actual.TotalUSD, result.CostAlert != nil)
+	// Each refresh reports the run's accrued total, so only the increase
+	// is metered
+	if actual.Currency == "" || actual.Currency == "USD" {
+		if increase := actual.TotalUSD - result.meteredUSD; increase > 0 {
+			metering.Record(ctx, audit.TenantFromContext(ctx), metering.CloudSpendUSD, increase)
+			result.meteredUSD = actual.TotalUSD
+		}
+	}
+
+	if result.CostAlert != nil {
+		dm.logger.Warn("Actual cost exceeds estimate",
+			zap.String("resource_group", result.ResourceGroup),
+			zap.Float64("actual_usd", actual.TotalUSD),
+			zap.Float64("estimated_usd", result.CostAlert.EstimatedUSD),
+			zap.Float64("factor", result.CostAlert.Factor),
+		)
+		return
+	}
+	dm.logger.Info("Actual cost queried",
+		zap.String("resource_group", result.ResourceGroup),
+		zap.Float64("actual_usd", actual.TotalUSD),
+		zap.String("currency", actual.Currency),
+	)
 }
 
 // scheduleCleanup schedules the cleanup of the resource group after TTL
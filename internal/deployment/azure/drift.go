@@ -0,0 +1,389 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/logger"
+	"QLP/internal/tfstate"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"go.uber.org/zap"
+)
+
+// DriftStatus is whether a validation environment still matches the
+// capsule's infrastructure as code
+type DriftStatus string
+
+const (
+	DriftInSync     DriftStatus = "in_sync"
+	DriftDetected   DriftStatus = "drifted"
+	DriftRemediated DriftStatus = "remediated"
+	DriftUnknown    DriftStatus = "unknown" // The check could not run
+)
+
+// DeployedResource is a resource found in a validation resource group
+type DeployedResource struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Location string `json:"location"`
+}
+
+// ResourceLister lists the QuantumLayer resource groups and the resources
+// deployed in them; AzureClient implements it
+type ResourceLister interface {
+	ListResourceGroups(ctx context.Context) ([]*armresources.ResourceGroup, error)
+	ListResources(ctx context.Context, resourceGroup string) ([]DeployedResource, error)
+}
+
+// CapsuleLoader returns the files of a stored capsule
+type CapsuleLoader func(ctx context.Context, capsuleID string) (map[string]string, error)
+
+// DriftPolicy defines which environments are checked and what happens when
+// they drift
+type DriftPolicy struct {
+	CheckInterval time.Duration // How often environments are checked
+	MinLifetime   time.Duration // Only environments with a TTL at least this long are checked
+	Remediate     bool          // Apply the Terraform to environments whose plan has changes
+	AlertWebhook  string        // Receives the report when an environment starts drifting
+}
+
+// DefaultDriftPolicy checks environments that live two hours or more every
+// 30 minutes, without remediating or alerting
+func DefaultDriftPolicy() DriftPolicy {
+	return DriftPolicy{
+		CheckInterval: 30 * time.Minute,
+		MinLifetime:   2 * time.Hour,
+	}
+}
+
+// DriftReport is the outcome of checking one validation environment
+type DriftReport struct {
+	CapsuleID     string               `json:"capsule_id"`
+	ResourceGroup string               `json:"resource_group"`
+	Status        DriftStatus          `json:"status"`
+	CheckedAt     time.Time            `json:"checked_at"`
+	Missing       []string             `json:"missing,omitempty"`   // Declared in the Terraform, not deployed
+	Unmanaged     []string             `json:"unmanaged,omitempty"` // Deployed, not declared in the Terraform
+	Plans         []tfstate.PlanResult `json:"plans,omitempty"`
+	Error         string               `json:"error,omitempty"`
+}
+
+// Drifted reports whether the environment differs from its Terraform
+func (r *DriftReport) Drifted() bool {
+	return r.Status == DriftDetected || r.Status == DriftRemediated
+}
+
+// DriftDetector periodically compares long-lived validation environments
+// against the Terraform of the capsule they were deployed from. Every
+// environment's resource inventory is compared with the resources the
+// Terraform declares; with a state backend, terraform plan against the
+// environment's validation state also catches changed settings.
+type DriftDetector struct {
+	logger        logger.Interface
+	azure         ResourceLister
+	capsules      CapsuleLoader
+	stateBackends StateBackendProvisioner
+	terraform     tfstate.Runner
+	onReport      func(ctx context.Context, report *DriftReport)
+	client        *http.Client
+	now           func() time.Time
+
+	mu      sync.Mutex
+	reports map[string]*DriftReport // Latest report by resource group
+}
+
+// NewDriftDetector creates a drift detector that compares resource
+// inventories only; SetStateBackend enables terraform plan
+func NewDriftDetector(azure ResourceLister, capsules CapsuleLoader) *DriftDetector {
+	return &DriftDetector{
+		logger:   logger.GetDefaultLogger().WithComponent("azure_drift"),
+		azure:    azure,
+		capsules: capsules,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		now:      time.Now,
+		reports:  make(map[string]*DriftReport),
+	}
+}
+
+// SetStateBackend plans each environment against the validation state the
+// deployment wrote, see ValidationStateBackend
+func (d *DriftDetector) SetStateBackend(backends StateBackendProvisioner, terraform tfstate.Runner) {
+	d.stateBackends = backends
+	d.terraform = terraform
+}
+
+// OnReport sets a function called with every report, to record the drift
+// in the capsule's status
+func (d *DriftDetector) OnReport(fn func(ctx context.Context, report *DriftReport)) {
+	d.onReport = fn
+}
+
+// Reports returns the latest report of each environment checked
+func (d *DriftDetector) Reports() []DriftReport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	reports := make([]DriftReport, 0, len(d.reports))
+	for _, r := range d.reports {
+		reports = append(reports, *r)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].ResourceGroup < reports[j].ResourceGroup })
+	return reports
+}
+
+// Start checks the environments on start and then every check interval
+// until ctx is cancelled
+func (d *DriftDetector) Start(ctx context.Context, policy DriftPolicy) {
+	if policy.CheckInterval <= 0 {
+		policy.CheckInterval = DefaultDriftPolicy().CheckInterval
+	}
+	d.logger.Info("Starting drift detector",
+		zap.Duration("check_interval", policy.CheckInterval),
+		zap.Duration("min_lifetime", policy.MinLifetime),
+		zap.Bool("remediate", policy.Remediate),
+	)
+
+	ticker := time.NewTicker(policy.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.Check(ctx, policy); err != nil {
+			d.logger.Error("Failed to check environments for drift", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			d.logger.Info("Drift detector stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check compares every live validation environment with a TTL of at least
+// the policy's minimum lifetime against its capsule and returns the reports
+func (d *DriftDetector) Check(ctx context.Context, policy DriftPolicy) ([]DriftReport, error) {
+	groups, err := d.azure.ListResourceGroups(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list resource groups: %w", err)
+	}
+	now := d.now()
+	var reports []DriftReport
+	for _, rg := range groups {
+		if rg.Name == nil || rg.Tags == nil {
+			continue
+		}
+		createdBy, capsuleID := rg.Tags[TagCreatedBy], rg.Tags[TagCapsuleID]
+		if createdBy == nil || *createdBy != createdByQuantumLayer || capsuleID == nil || *capsuleID == "" {
+			continue
+		}
+		createdAt, expiresAt := tagTime(rg.Tags, TagCreatedAt), tagTime(rg.Tags, TagExpiresAt)
+		if expiresAt.IsZero() || !now.Before(expiresAt) {
+			continue
+		}
+		if !createdAt.IsZero() && expiresAt.Sub(createdAt) < policy.MinLifetime {
+			continue
+		}
+		reports = append(reports, d.CheckEnvironment(ctx, *rg.Name, *capsuleID, policy))
+	}
+	return reports, nil
+}
+
+// CheckEnvironment compares one validation environment against the
+// Terraform of the capsule deployed to it
+func (d *DriftDetector) CheckEnvironment(ctx context.Context, resourceGroup, capsuleID string, policy DriftPolicy) DriftReport {
+	report := DriftReport{
+		CapsuleID:     capsuleID,
+		ResourceGroup: resourceGroup,
+		Status:        DriftInSync,
+		CheckedAt:     d.now(),
+	}
+	if err := d.check(ctx, &report, policy); err != nil {
+		report.Error = err.Error()
+		if !report.Drifted() {
+			report.Status = DriftUnknown
+		}
+	}
+	d.logger.Info("Checked environment for drift",
+		zap.String("capsule_id", capsuleID),
+		zap.String("resource_group", resourceGroup),
+		zap.String("status", string(report.Status)),
+		zap.Strings("missing", report.Missing),
+		zap.Strings("unmanaged", report.Unmanaged),
+	)
+
+	d.mu.Lock()
+	previous := d.reports[resourceGroup]
+	d.reports[resourceGroup] = &report
+	d.mu.Unlock()
+
+	// Alert once when an environment starts drifting, not on every check
+	if report.Drifted() && (previous == nil || !previous.Drifted()) && policy.AlertWebhook != "" {
+		if err := d.alert(ctx, policy.AlertWebhook, &report); err != nil {
+			d.logger.Warn("Failed to send drift alert",
+				zap.String("resource_group", resourceGroup),
+				zap.Error(err),
+			)
+		}
+	}
+	if d.onReport != nil {
+		d.onReport(ctx, &report)
+	}
+	return report
+}
+
+func (d *DriftDetector) check(ctx context.Context, report *DriftReport, policy DriftPolicy) error {
+	files, err := d.capsules(ctx, report.CapsuleID)
+	if err != nil {
+		return fmt.Errorf("failed to load capsule %s: %w", report.CapsuleID, err)
+	}
+	terraformFiles := make(map[string]string)
+	for path, content := range files {
+		if strings.HasSuffix(path, ".tf") {
+			terraformFiles[path] = content
+		}
+	}
+	if len(terraformFiles) == 0 {
+		return nil
+	}
+
+	deployed, err := d.azure.ListResources(ctx, report.ResourceGroup)
+	if err != nil {
+		return fmt.Errorf("failed to list resources: %w", err)
+	}
+	report.Missing, report.Unmanaged = CompareResources(ExtractAzureResources(terraformFiles), deployed)
+	if len(report.Missing) > 0 || len(report.Unmanaged) > 0 {
+		report.Status = DriftDetected
+	}
+
+	if d.stateBackends == nil || d.terraform == nil {
+		return nil
+	}
+	backend := ValidationStateBackend(report.CapsuleID)
+	key, err := d.stateBackends.StateBackendKey(ctx, backend)
+	if err != nil {
+		return fmt.Errorf("failed to access the Terraform state backend: %w", err)
+	}
+	report.Plans, err = tfstate.Plan(ctx, terraformFiles, backend, []string{"ARM_ACCESS_KEY=" + key}, d.terraform, policy.Remediate)
+	if errors.Is(err, tfstate.ErrNoTerraform) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to run terraform plan: %w", err)
+	}
+
+	var failed []string
+	changed, applied := 0, 0
+	for _, plan := range report.Plans {
+		if plan.Changes {
+			changed++
+		}
+		if plan.Applied {
+			applied++
+		}
+		if plan.Error != "" {
+			failed = append(failed, plan.Module)
+		}
+	}
+	if applied > 0 {
+		audit.Record(ctx, audit.Entry{
+			Action:       audit.ActionDriftRemediate,
+			Outcome:      remediationOutcome(applied == changed),
+			ResourceType: "Microsoft.Resources/resourceGroups",
+			ResourceIDs:  []string{report.ResourceGroup},
+			Details:      map[string]interface{}{"capsule_id": report.CapsuleID, "modules_applied": applied},
+		})
+	}
+	switch {
+	case changed > 0 && applied == changed && report.Status == DriftInSync:
+		report.Status = DriftRemediated
+	case changed > 0:
+		report.Status = DriftDetected
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("terraform failed in %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func remediationOutcome(ok bool) audit.Outcome {
+	if ok {
+		return audit.OutcomeSuccess
+	}
+	return audit.OutcomeFailure
+}
+
+// alert POSTs the report to a webhook
+func (d *DriftDetector) alert(ctx context.Context, webhook string, report *DriftReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// CompareResources compares the resources the Terraform declares with those
+// deployed by ARM resource type. Only the types the Terraform parser maps
+// are compared, since other resources, such as disks and network
+// interfaces, are created alongside them.
+func CompareResources(declared []TerraformResource, deployed []DeployedResource) (missing, unmanaged []string) {
+	known := make(map[string]bool, len(armResourceTypes))
+	for _, resourceType := range armResourceTypes {
+		known[strings.ToLower(resourceType)] = true
+	}
+	declaredTypes := make(map[string]bool)
+	for _, r := range declared {
+		declaredTypes[strings.ToLower(r.ResourceType)] = true
+	}
+	deployedTypes := make(map[string]bool)
+	for _, r := range deployed {
+		resourceType := strings.ToLower(r.Type)
+		deployedTypes[resourceType] = true
+		if known[resourceType] && !declaredTypes[resourceType] {
+			unmanaged = append(unmanaged, r.Type+"/"+r.Name)
+		}
+	}
+	for _, r := range declared {
+		if !deployedTypes[strings.ToLower(r.ResourceType)] {
+			missing = append(missing, r.Address+" ("+r.ResourceType+")")
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(unmanaged)
+	return missing, unmanaged
+}
+
+// ListResources lists the resources in a resource group
+func (ac *AzureClient) ListResources(ctx context.Context, resourceGroup string) ([]DeployedResource, error) {
+	var body struct {
+		Value []DeployedResource `json:"value"`
+	}
+	path := fmt.Sprintf("/resourceGroups/%s/resources?api-version=2021-04-01", url.PathEscape(resourceGroup))
+	if err := ac.getJSON(ctx, "list resources", path, &body); err != nil {
+		return nil, err
+	}
+	return body.Value, nil
+}
@@ -0,0 +1,158 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+)
+
+type fakeEnvironments struct {
+	fakeResourceGroups
+	resources map[string][]DeployedResource
+}
+
+func (f *fakeEnvironments) ListResources(ctx context.Context, resourceGroup string) ([]DeployedResource, error) {
+	return f.resources[resourceGroup], nil
+}
+
+type planExit int
+
+func (e planExit) Error() string { return fmt.Sprintf("exit status %d", int(e)) }
+func (e planExit) ExitCode() int { return int(e) }
+
+const redisTerraform = `resource "azurerm_redis_cache" "cache" {
+  name = "orders"
+}
+`
+
+func TestCompareResources(t *testing.T) {
+	declared := ExtractAzureResources(map[string]string{"main.tf": nodePoolTerraform + redisTerraform})
+	deployed := []DeployedResource{
+		{Name: "aks", Type: "Microsoft.ContainerService/managedClusters"},
+		{Name: "osdisk", Type: "Microsoft.Compute/disks"},
+		{Name: "extra", Type: "Microsoft.KeyVault/vaults"},
+	}
+	missing, unmanaged := CompareResources(declared, deployed)
+	if strings.Join(missing, ",") != "azurerm_redis_cache.cache (Microsoft.Cache/redis)" {
+		t.Errorf("missing = %v", missing)
+	}
+	if strings.Join(unmanaged, ",") != "Microsoft.KeyVault/vaults/extra" {
+		t.Errorf("unmanaged = %v, disks are created alongside VMs and not compared", unmanaged)
+	}
+}
+
+func TestDriftCheck(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) string { return now.Add(d).Format(time.RFC3339) }
+	env := func(name, capsuleID string, created, expires time.Duration) *armresources.ResourceGroup {
+		return group(name, map[string]string{TagCreatedBy: "quantumlayer", TagCapsuleID: capsuleID,
+			TagCreatedAt: at(created), TagExpiresAt: at(expires)})
+	}
+	client := &fakeEnvironments{
+		fakeResourceGroups: fakeResourceGroups{groups: []*armresources.ResourceGroup{
+			env("rg-drifted", "QL-CAP-1", -time.Hour, 24*time.Hour),
+			env("rg-in-sync", "QL-CAP-2", -time.Hour, 24*time.Hour),
+			env("rg-short", "QL-CAP-3", -10*time.Minute, 20*time.Minute),
+			env("rg-expired", "QL-CAP-4", -48*time.Hour, -time.Hour),
+		}},
+		resources: map[string][]DeployedResource{
+			"rg-drifted": {{Name: "aks", Type: "Microsoft.ContainerService/managedClusters"}},
+			"rg-in-sync": {{Name: "aks", Type: "Microsoft.ContainerService/managedClusters"}},
+		},
+	}
+	capsules := func(ctx context.Context, capsuleID string) (map[string]string, error) {
+		if capsuleID == "QL-CAP-1" {
+			return map[string]string{"main.tf": nodePoolTerraform + redisTerraform}, nil
+		}
+		return map[string]string{"main.tf": nodePoolTerraform, "README.md": "# orders"}, nil
+	}
+
+	var alerts []DriftReport
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report DriftReport
+		json.NewDecoder(r.Body).Decode(&report)
+		alerts = append(alerts, report)
+	}))
+	defer webhook.Close()
+
+	d := NewDriftDetector(client, capsules)
+	d.now = func() time.Time { return now }
+	var recorded []string
+	d.OnReport(func(ctx context.Context, r *DriftReport) {
+		recorded = append(recorded, r.CapsuleID+"="+string(r.Status))
+	})
+
+	policy := DefaultDriftPolicy()
+	policy.AlertWebhook = webhook.URL
+	reports, err := d.Check(context.Background(), policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(recorded, ",") != "QL-CAP-1=drifted,QL-CAP-2=in_sync" {
+		t.Fatalf("recorded = %v, want the long-lived live environments only", recorded)
+	}
+	if len(reports[0].Missing) != 1 || len(alerts) != 1 || alerts[0].ResourceGroup != "rg-drifted" {
+		t.Errorf("reports = %+v, alerts = %+v", reports, alerts)
+	}
+
+	// A drift that persists is alerted once
+	if _, err := d.Check(context.Background(), policy); err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 {
+		t.Errorf("alerts = %d, want 1", len(alerts))
+	}
+	if latest := d.Reports(); len(latest) != 2 || latest[0].ResourceGroup != "rg-drifted" {
+		t.Errorf("latest reports = %+v", latest)
+	}
+}
+
+func TestDriftPlanAndRemediate(t *testing.T) {
+	client := &fakeEnvironments{resources: map[string][]DeployedResource{
+		"rg-1": {{Name: "aks", Type: "Microsoft.ContainerService/managedClusters"}},
+	}}
+	capsules := func(ctx context.Context, capsuleID string) (map[string]string, error) {
+		return map[string]string{"main.tf": nodePoolTerraform}, nil
+	}
+	applied := false
+	d := NewDriftDetector(client, capsules)
+	d.SetStateBackend(&fakeStateBackends{}, func(ctx context.Context, dir string, env []string, args ...string) ([]byte, error) {
+		switch args[0] {
+		case "init":
+			if !strings.Contains(strings.Join(args, " "), "-backend-config=key=QL-CAP-1/terraform.tfstate") {
+				t.Errorf("plan not run against the validation state: %v", args)
+			}
+		case "plan":
+			if applied {
+				return []byte("No changes."), nil
+			}
+			return []byte("~ node_count = 3 -> 5"), planExit(2)
+		case "apply":
+			applied = true
+		}
+		return nil, nil
+	})
+
+	policy := DefaultDriftPolicy()
+	report := d.CheckEnvironment(context.Background(), "rg-1", "QL-CAP-1", policy)
+	if report.Status != DriftDetected || len(report.Plans) != 1 || !report.Plans[0].Changes || applied {
+		t.Fatalf("report = %+v", report)
+	}
+
+	policy.Remediate = true
+	report = d.CheckEnvironment(context.Background(), "rg-1", "QL-CAP-1", policy)
+	if report.Status != DriftRemediated || !applied {
+		t.Errorf("report = %+v", report)
+	}
+	report = d.CheckEnvironment(context.Background(), "rg-1", "QL-CAP-1", policy)
+	if report.Status != DriftInSync {
+		t.Errorf("report after remediation = %+v", report)
+	}
+}
@@ -0,0 +1,74 @@
+package azure
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+// Error is a failed Azure operation, with the status and error code ARM
+// returned when the request reached it
+type Error struct {
+	Op         string // e.g. "create resource group"
+	Resource   string // name of the resource operated on
+	StatusCode int    // HTTP status, 0 when no response was received
+	Code       string // ARM error code, e.g. "ResourceGroupNotFound"
+	Err        error
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("azure: %s %s failed", e.Op, e.Resource)
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(" (%d %s)", e.StatusCode, e.Code)
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether retrying the operation later may succeed:
+// throttling, server errors and requests that never got a response
+func (e *Error) Retryable() bool {
+	switch {
+	case e.StatusCode == 0, e.StatusCode == http.StatusRequestTimeout,
+		e.StatusCode == http.StatusTooManyRequests, e.StatusCode >= 500:
+		return true
+	}
+	return e.Code == "Conflict" || e.Code == "AnotherOperationInProgress"
+}
+
+// wrapError turns an SDK error into an *Error for the operation
+func wrapError(op, resource string, err error) error {
+	if err == nil {
+		return nil
+	}
+	e := &Error{Op: op, Resource: resource, Err: err}
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		e.StatusCode = respErr.StatusCode
+		e.Code = respErr.ErrorCode
+	}
+	return e
+}
+
+// IsNotFound reports whether err is an Azure error for a missing resource
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+// IsThrottled reports whether err is an Azure error for a throttled request
+func IsThrottled(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusTooManyRequests
+}
+
+// IsRetryable reports whether err is an Azure error worth retrying
+func IsRetryable(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Retryable()
+}
@@ -0,0 +1,75 @@
+package azure
+
+import (
+	"fmt"
+	"sort"
+
+	"QLP/internal/junit"
+)
+
+// JUnit converts the health checks and functional tests of a deployment into
+// a JUnit report
+func (r *DeploymentResult) JUnit() *junit.TestSuites {
+	health := make([]junit.Case, 0, len(r.HealthChecks))
+	for _, check := range r.HealthChecks {
+		c := junit.Case{
+			Name:     check.Name,
+			Class:    "health." + check.Type,
+			Duration: check.ResponseTime,
+			Failed:   check.Status != "pass",
+			Output:   check.Endpoint,
+		}
+		if c.Failed {
+			c.Message = check.Message
+		}
+		health = append(health, c)
+	}
+
+	names := make([]string, 0, len(r.TestResults))
+	for name := range r.TestResults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	functional := make([]junit.Case, 0, len(names))
+	for _, name := range names {
+		test := r.TestResults[name]
+		c := junit.Case{
+			Name:     test.Name,
+			Class:    "functional",
+			Duration: test.Duration,
+			Failed:   test.Status == "fail",
+			Skipped:  test.Status == "skip",
+			Output:   test.Output,
+		}
+		if c.Name == "" {
+			c.Name = name
+		}
+		if c.Failed || c.Skipped {
+			c.Message = test.Output
+		}
+		functional = append(functional, c)
+	}
+
+	suites := []junit.TestSuite{
+		junit.NewSuite("health", r.StartTime, health),
+		junit.NewSuite("functional", r.StartTime, functional),
+	}
+	if r.Strategy != nil {
+		steps := make([]junit.Case, 0, len(r.Strategy.Steps))
+		for i, step := range r.Strategy.Steps {
+			c := junit.Case{
+				Name:     fmt.Sprintf("%02d_%s", i+1, step.Action),
+				Class:    "strategy." + string(r.Strategy.Strategy),
+				Duration: step.Duration,
+				Failed:   step.Status != "pass",
+				Message:  step.Message,
+			}
+			if step.Percent > 0 {
+				c.Name += fmt.Sprintf("_%d%%", step.Percent)
+			}
+			steps = append(steps, c)
+		}
+		suites = append(suites, junit.NewSuite("strategy", r.StartTime, steps))
+	}
+	return junit.New(r.CapsuleID, suites...)
+}
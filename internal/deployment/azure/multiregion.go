@@ -0,0 +1,152 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"QLP/internal/junit"
+	"QLP/internal/packaging"
+
+	"go.uber.org/zap"
+)
+
+// RegionResult is the outcome of validating a capsule in one region
+type RegionResult struct {
+	Region  string            `json:"region"`
+	Result  *DeploymentResult `json:"result"`
+	Success bool              `json:"success"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// RegionCost is a region's estimated cost, for comparison across regions
+type RegionCost struct {
+	Region   string  `json:"region"`
+	TotalUSD float64 `json:"total_usd"`
+	// DeltaUSD is the difference to the cheapest successful region
+	DeltaUSD float64 `json:"delta_usd"`
+}
+
+// MultiRegionResult aggregates the validation of a capsule across regions
+type MultiRegionResult struct {
+	CapsuleID string         `json:"capsule_id"`
+	Regions   []RegionResult `json:"regions"`
+	StartTime time.Time      `json:"start_time"`
+	Duration  time.Duration  `json:"duration"`
+}
+
+// Succeeded returns the regions the capsule deployed to
+func (m *MultiRegionResult) Succeeded() []string {
+	var regions []string
+	for _, r := range m.Regions {
+		if r.Success {
+			regions = append(regions, r.Region)
+		}
+	}
+	return regions
+}
+
+// Failed returns the regions the capsule failed in
+func (m *MultiRegionResult) Failed() []string {
+	var regions []string
+	for _, r := range m.Regions {
+		if !r.Success {
+			regions = append(regions, r.Region)
+		}
+	}
+	return regions
+}
+
+// CostComparison returns the estimated cost of each successful region, the
+// cheapest first
+func (m *MultiRegionResult) CostComparison() []RegionCost {
+	var costs []RegionCost
+	for _, r := range m.Regions {
+		if r.Success && r.Result != nil {
+			costs = append(costs, RegionCost{Region: r.Region, TotalUSD: r.Result.CostEstimate.TotalUSD})
+		}
+	}
+	sort.SliceStable(costs, func(i, j int) bool { return costs[i].TotalUSD < costs[j].TotalUSD })
+	for i := range costs {
+		costs[i].DeltaUSD = costs[i].TotalUSD - costs[0].TotalUSD
+	}
+	return costs
+}
+
+// ResourceGroups returns the resource group of each region
+func (m *MultiRegionResult) ResourceGroups() []string {
+	groups := make([]string, 0, len(m.Regions))
+	for _, r := range m.Regions {
+		if r.Result != nil {
+			groups = append(groups, r.Result.ResourceGroup)
+		}
+	}
+	return groups
+}
+
+// JUnit combines the region reports, with suites named by region
+func (m *MultiRegionResult) JUnit() *junit.TestSuites {
+	var suites []junit.TestSuite
+	for _, r := range m.Regions {
+		availability := junit.Case{Name: "resource availability", Class: "preflight", Failed: len(r.Result.AvailabilityIssues) > 0}
+		if availability.Failed {
+			availability.Message = fmt.Sprintf("%d resources not available or over quota", len(r.Result.AvailabilityIssues))
+			availability.Details = strings.Join(r.Result.AvailabilityIssues, "\n")
+		}
+		suites = append(suites, junit.NewSuite(r.Region+"/preflight", m.StartTime, []junit.Case{availability}))
+		for _, suite := range r.Result.JUnit().Suites {
+			suite.Name = r.Region + "/" + suite.Name
+			suites = append(suites, suite)
+		}
+	}
+	return junit.New(m.CapsuleID, suites...)
+}
+
+// RegionResourceGroupName names the resource group of one region of a
+// multi-region validation
+func RegionResourceGroupName(resourceGroup, region string) string {
+	return resourceGroup + "-" + NormalizeRegion(region)
+}
+
+// DeployRegions validates the capsule in each region in parallel, each in
+// its own resource group, so region-specific failures such as unavailable
+// SKUs show up side by side
+func (dm *DeploymentManager) DeployRegions(ctx context.Context, capsule *packaging.QuantumDrop, config DeploymentConfig, regions []string) *MultiRegionResult {
+	result := &MultiRegionResult{
+		CapsuleID: config.CapsuleID,
+		Regions:   make([]RegionResult, len(regions)),
+		StartTime: time.Now(),
+	}
+
+	var wg sync.WaitGroup
+	for i, region := range regions {
+		wg.Add(1)
+		go func(i int, region string) {
+			defer wg.Done()
+			regionConfig := config
+			regionConfig.Location = NormalizeRegion(region)
+			regionConfig.FallbackRegions = nil // Each region reports its own preflight
+			regionConfig.ResourceGroup = RegionResourceGroupName(config.ResourceGroup, region)
+
+			deployment, err := dm.Deploy(ctx, capsule, regionConfig)
+			r := RegionResult{Region: regionConfig.Location, Result: deployment, Success: err == nil}
+			if err != nil {
+				r.Error = err.Error()
+			}
+			result.Regions[i] = r
+		}(i, region)
+	}
+	wg.Wait()
+	result.Duration = time.Since(result.StartTime)
+
+	dm.logger.Info("Multi-region deployment validation completed",
+		zap.String("capsule_id", config.CapsuleID),
+		zap.Strings("succeeded", result.Succeeded()),
+		zap.Strings("failed", result.Failed()),
+		zap.Duration("duration", result.Duration),
+	)
+	return result
+}
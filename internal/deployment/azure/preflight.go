@@ -0,0 +1,120 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// VMSize describes a VM size offered in a region
+type VMSize struct {
+	Name   string `json:"name"`
+	Family string `json:"family"` // Quota family, e.g. standardDSv5Family
+	VCPUs  int    `json:"vcpus"`
+}
+
+// Usage is the current usage of a compute quota in a region
+type Usage struct {
+	Name    string `json:"name"` // cores for the regional total, otherwise a VM family
+	Current int64  `json:"current"`
+	Limit   int64  `json:"limit"`
+}
+
+// totalCoresQuota is the regional vCPU quota every VM family counts against
+const totalCoresQuota = "cores"
+
+// QuotaChecker looks up the compute quota available to the subscription
+type QuotaChecker interface {
+	// VMSizeDetails returns the VM sizes offered in a region keyed by
+	// lower-case name
+	VMSizeDetails(ctx context.Context, location string) (map[string]VMSize, error)
+	// ComputeUsage returns the compute quotas in a region keyed by name
+	ComputeUsage(ctx context.Context, location string) (map[string]Usage, error)
+}
+
+// CheckQuota reports the VM families whose vCPU quota in the region cannot
+// fit the VM sizes the resources declare, as messages saying what to change.
+// Lookups that fail are skipped so the deployment itself reports them.
+func CheckQuota(ctx context.Context, checker QuotaChecker, resources []TerraformResource, region string) []string {
+	region = NormalizeRegion(region)
+	needed := make(map[string]int64)
+	users := make(map[string][]string)
+	var sizes map[string]VMSize
+	for _, r := range resources {
+		if len(r.VMSizes) == 0 {
+			continue
+		}
+		if sizes == nil {
+			var err error
+			if sizes, err = checker.VMSizeDetails(ctx, region); err != nil {
+				return nil
+			}
+		}
+		instances := int64(max(r.Instances, 1))
+		for _, name := range r.VMSizes {
+			size, ok := sizes[strings.ToLower(name)]
+			if !ok || size.VCPUs == 0 {
+				continue
+			}
+			cores := int64(size.VCPUs) * instances
+			for _, quota := range []string{size.Family, totalCoresQuota} {
+				if quota == "" {
+					continue
+				}
+				needed[quota] += cores
+				users[quota] = append(users[quota], fmt.Sprintf("%s (%dx %s)", r.Address, instances, name))
+			}
+		}
+	}
+	if len(needed) == 0 {
+		return nil
+	}
+
+	usage, err := checker.ComputeUsage(ctx, region)
+	if err != nil {
+		return nil
+	}
+	quotas := make([]string, 0, len(needed))
+	for quota := range needed {
+		quotas = append(quotas, quota)
+	}
+	sort.Strings(quotas)
+
+	var issues []string
+	for _, quota := range quotas {
+		u, ok := usage[quota]
+		if !ok || u.Current+needed[quota] <= u.Limit {
+			continue
+		}
+		issues = append(issues, fmt.Sprintf(
+			"%s: needs %d vCPUs of the %s quota in %s but only %d of %d are free; request a quota increase, use a smaller VM size or deploy to another region",
+			strings.Join(users[quota], ", "), needed[quota], quota, region, max(u.Limit-u.Current, 0), u.Limit))
+	}
+	return issues
+}
+
+// ComputeUsage returns the subscription's compute quota usage in a region
+func (ac *AzureClient) ComputeUsage(ctx context.Context, location string) (map[string]Usage, error) {
+	var body struct {
+		Value []struct {
+			Name struct {
+				Value string `json:"value"`
+			} `json:"name"`
+			CurrentValue int64 `json:"currentValue"`
+			Limit        int64 `json:"limit"`
+		} `json:"value"`
+	}
+	path := fmt.Sprintf("/providers/Microsoft.Compute/locations/%s/usages?api-version=2023-07-01",
+		url.PathEscape(NormalizeRegion(location)))
+	if err := ac.getJSON(ctx, "list compute usage", path, &body); err != nil {
+		return nil, err
+	}
+
+	usage := make(map[string]Usage, len(body.Value))
+	for _, u := range body.Value {
+		usage[u.Name.Value] = Usage{Name: u.Name.Value, Current: u.CurrentValue, Limit: u.Limit}
+	}
+	return usage, nil
+}
@@ -0,0 +1,124 @@
+package azure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"QLP/internal/packaging"
+)
+
+const nodePoolTerraform = `resource "azurerm_kubernetes_cluster" "main" {
+  default_node_pool {
+    name       = "default"
+    vm_size    = "Standard_D4s_v5"
+    node_count = 3
+  }
+}
+`
+
+type fakeQuota struct {
+	usage map[string]map[string]Usage
+}
+
+func (f *fakeQuota) VMSizeDetails(ctx context.Context, location string) (map[string]VMSize, error) {
+	return map[string]VMSize{
+		"standard_d4s_v5": {Name: "Standard_D4s_v5", Family: "standardDSv5Family", VCPUs: 4},
+	}, nil
+}
+
+func (f *fakeQuota) ComputeUsage(ctx context.Context, location string) (map[string]Usage, error) {
+	return f.usage[location], nil
+}
+
+func TestCheckQuota(t *testing.T) {
+	resources := ExtractAzureResources(map[string]string{"main.tf": nodePoolTerraform})
+	if len(resources) != 1 || resources[0].Instances != 3 {
+		t.Fatalf("expected three nodes, got %+v", resources)
+	}
+	checker := &fakeQuota{usage: map[string]map[string]Usage{
+		"uksouth": {
+			"cores":              {Name: "cores", Current: 4, Limit: 100},
+			"standardDSv5Family": {Name: "standardDSv5Family", Current: 6, Limit: 10},
+		},
+		"westeurope": {
+			"cores":              {Name: "cores", Current: 0, Limit: 20},
+			"standardDSv5Family": {Name: "standardDSv5Family", Current: 0, Limit: 20},
+		},
+	}}
+
+	issues := CheckQuota(context.Background(), checker, resources, "UK South")
+	if len(issues) != 1 {
+		t.Fatalf("expected the family quota to be short, got %v", issues)
+	}
+	for _, want := range []string{"3x Standard_D4s_v5", "needs 12 vCPUs of the standardDSv5Family quota in uksouth", "only 4 of 10 are free", "request a quota increase"} {
+		if !strings.Contains(issues[0], want) {
+			t.Errorf("issue %q missing %q", issues[0], want)
+		}
+	}
+	if issues := CheckQuota(context.Background(), checker, resources, "westeurope"); len(issues) != 0 {
+		t.Errorf("expected westeurope to have quota, got %v", issues)
+	}
+	if issues := CheckQuota(context.Background(), checker, resources, "brazilsouth"); len(issues) != 0 {
+		t.Errorf("unknown usage should be skipped, got %v", issues)
+	}
+}
+
+func TestPreflightFallsBackToRegionWithQuota(t *testing.T) {
+	dm := NewDeploymentManager(nil, 10)
+	dm.quota = &fakeQuota{usage: map[string]map[string]Usage{
+		"uksouth":     {"standardDSv5Family": {Current: 10, Limit: 10}},
+		"westeurope":  {"standardDSv5Family": {Current: 10, Limit: 10}},
+		"northeurope": {"standardDSv5Family": {Current: 0, Limit: 24}},
+	}}
+	drop := &packaging.QuantumDrop{ID: "QD-1", Files: map[string]string{"main.tf": nodePoolTerraform}}
+
+	config := DeploymentConfig{Location: "uksouth", FallbackRegions: []string{"West Europe", "North Europe"}}
+	result := &DeploymentResult{Location: config.Location}
+	if err := dm.preflight(context.Background(), drop, &config, result); err != nil {
+		t.Fatal(err)
+	}
+	if config.Location != "northeurope" || result.Location != "northeurope" || result.RequestedLocation != "uksouth" {
+		t.Errorf("expected the deployment to move to northeurope, got %s (requested %s)", result.Location, result.RequestedLocation)
+	}
+
+	config = DeploymentConfig{Location: "uksouth", FallbackRegions: []string{"westeurope"}}
+	result = &DeploymentResult{Location: config.Location}
+	err := dm.preflight(context.Background(), drop, &config, result)
+	if err == nil || !strings.Contains(err.Error(), "fallback regions westeurope failed preflight too") {
+		t.Fatalf("expected preflight to fail fast, got %v", err)
+	}
+	if config.Location != "uksouth" || len(result.AvailabilityIssues) != 1 {
+		t.Errorf("expected the uksouth shortfall to be reported, got %+v", result)
+	}
+}
+
+func TestComputeUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/subscriptions/sub/providers/Microsoft.Compute/locations/uksouth/usages" {
+			http.Error(w, "unexpected request "+r.URL.Path, http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"value": [{"name": {"value": "cores", "localizedValue": "Total Regional vCPUs"}, "currentValue": 8, "limit": 20}]}`))
+	}))
+	defer server.Close()
+
+	ac := &AzureClient{
+		subscriptionID:     "sub",
+		credential:         &countingCredential{expires: time.Now().Add(time.Hour)},
+		managementEndpoint: server.URL,
+	}
+	usage, err := ac.ComputeUsage(context.Background(), "UK South")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := usage["cores"]; u.Current != 8 || u.Limit != 20 {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+	if _, err := ac.ComputeUsage(context.Background(), "westeurope"); err == nil {
+		t.Error("non-200 responses should fail")
+	}
+}
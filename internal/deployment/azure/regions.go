@@ -0,0 +1,291 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// TerraformResource is an azurerm resource declared in the capsule's
+// Terraform, with the ARM resource type it creates and the VM sizes it asks for
+type TerraformResource struct {
+	Address      string   `json:"address"`       // azurerm_kubernetes_cluster.main
+	ResourceType string   `json:"resource_type"` // Microsoft.ContainerService/managedClusters
+	VMSizes      []string `json:"vm_sizes,omitempty"`
+	Instances    int      `json:"instances,omitempty"` // VMs or nodes of each size, from node_count, instances or count
+}
+
+// armResourceTypes maps azurerm resources to the ARM resource types whose
+// regional availability is checked
+var armResourceTypes = map[string]string{
+	"azurerm_kubernetes_cluster":              "Microsoft.ContainerService/managedClusters",
+	"azurerm_kubernetes_cluster_node_pool":    "Microsoft.ContainerService/managedClusters",
+	"azurerm_container_app":                   "Microsoft.App/containerApps",
+	"azurerm_container_app_environment":       "Microsoft.App/managedEnvironments",
+	"azurerm_container_group":                 "Microsoft.ContainerInstance/containerGroups",
+	"azurerm_container_registry":              "Microsoft.ContainerRegistry/registries",
+	"azurerm_linux_virtual_machine":           "Microsoft.Compute/virtualMachines",
+	"azurerm_windows_virtual_machine":         "Microsoft.Compute/virtualMachines",
+	"azurerm_virtual_machine":                 "Microsoft.Compute/virtualMachines",
+	"azurerm_linux_virtual_machine_scale_set": "Microsoft.Compute/virtualMachineScaleSets",
+	"azurerm_service_plan":                    "Microsoft.Web/serverFarms",
+	"azurerm_linux_web_app":                   "Microsoft.Web/sites",
+	"azurerm_windows_web_app":                 "Microsoft.Web/sites",
+	"azurerm_linux_function_app":              "Microsoft.Web/sites",
+	"azurerm_postgresql_flexible_server":      "Microsoft.DBforPostgreSQL/flexibleServers",
+	"azurerm_mysql_flexible_server":           "Microsoft.DBforMySQL/flexibleServers",
+	"azurerm_mssql_server":                    "Microsoft.Sql/servers",
+	"azurerm_cosmosdb_account":                "Microsoft.DocumentDB/databaseAccounts",
+	"azurerm_redis_cache":                     "Microsoft.Cache/redis",
+	"azurerm_servicebus_namespace":            "Microsoft.ServiceBus/namespaces",
+	"azurerm_eventhub_namespace":              "Microsoft.EventHub/namespaces",
+	"azurerm_storage_account":                 "Microsoft.Storage/storageAccounts",
+	"azurerm_key_vault":                       "Microsoft.KeyVault/vaults",
+	"azurerm_cognitive_account":               "Microsoft.CognitiveServices/accounts",
+}
+
+var (
+	resourceBlock = regexp.MustCompile(`(?m)^\s*resource\s+"(azurerm_[a-z0-9_]+)"\s+"([^"]+)"\s*\{`)
+	vmSizeAttr    = regexp.MustCompile(`(?m)^\s*(?:vm_size|size)\s*=\s*"(Standard_[A-Za-z0-9_]+)"`)
+	instancesAttr = regexp.MustCompile(`(?m)^\s*(?:node_count|instances|count)\s*=\s*(\d+)`)
+)
+
+// ExtractAzureResources lists the azurerm resources declared in .tf files
+func ExtractAzureResources(files map[string]string) []TerraformResource {
+	var resources []TerraformResource
+	for path, content := range files {
+		if !strings.HasSuffix(path, ".tf") {
+			continue
+		}
+		for _, m := range resourceBlock.FindAllStringSubmatchIndex(content, -1) {
+			tfType, name := content[m[2]:m[3]], content[m[4]:m[5]]
+			armType, ok := armResourceTypes[tfType]
+			if !ok {
+				continue
+			}
+			resource := TerraformResource{Address: tfType + "." + name, ResourceType: armType, Instances: 1}
+			body := blockBody(content, m[1]-1)
+			for _, size := range vmSizeAttr.FindAllStringSubmatch(body, -1) {
+				resource.VMSizes = append(resource.VMSizes, size[1])
+			}
+			if count := instancesAttr.FindStringSubmatch(body); count != nil {
+				resource.Instances, _ = strconv.Atoi(count[1])
+			}
+			resources = append(resources, resource)
+		}
+	}
+	sort.Slice(resources, func(i, j int) bool { return resources[i].Address < resources[j].Address })
+	return resources
+}
+
+// blockBody returns the text between the brace at open and its match
+func blockBody(content string, open int) string {
+	depth := 0
+	for i := open; i < len(content); i++ {
+		switch content[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return content[open+1 : i]
+			}
+		}
+	}
+	return content[open+1:]
+}
+
+// AvailabilityChecker reports where resource types and VM sizes can be
+// created
+type AvailabilityChecker interface {
+	// ResourceTypeLocations returns the regions offering an ARM resource type
+	ResourceTypeLocations(ctx context.Context, resourceType string) ([]string, error)
+	// VMSizes returns the VM sizes the subscription can create in a region
+	VMSizes(ctx context.Context, location string) (map[string]bool, error)
+}
+
+// NormalizeRegion turns display names such as "UK South" into region names
+// such as "uksouth"
+func NormalizeRegion(region string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(region), " ", ""))
+}
+
+// ParseRegions splits a comma-separated region list, dropping blanks and
+// duplicates
+func ParseRegions(list string) []string {
+	var regions []string
+	seen := make(map[string]bool)
+	for _, r := range strings.Split(list, ",") {
+		r = NormalizeRegion(r)
+		if r != "" && !seen[r] {
+			seen[r] = true
+			regions = append(regions, r)
+		}
+	}
+	return regions
+}
+
+// CheckAvailability returns the resources that cannot be created in a
+// region: resource types the region does not offer and VM sizes that are not
+// available or restricted for the subscription there. Lookups that fail are
+// skipped rather than reported.
+func CheckAvailability(ctx context.Context, checker AvailabilityChecker, resources []TerraformResource, region string) []string {
+	region = NormalizeRegion(region)
+	var issues []string
+	locations := make(map[string]map[string]bool)
+	var sizes map[string]bool
+	for _, r := range resources {
+		if _, ok := locations[r.ResourceType]; !ok {
+			locations[r.ResourceType] = nil
+			if list, err := checker.ResourceTypeLocations(ctx, r.ResourceType); err == nil {
+				locations[r.ResourceType] = make(map[string]bool)
+				for _, l := range list {
+					locations[r.ResourceType][NormalizeRegion(l)] = true
+				}
+			}
+		}
+		if offered := locations[r.ResourceType]; offered != nil && !offered[region] {
+			issues = append(issues, fmt.Sprintf("%s: %s is not available in %s", r.Address, r.ResourceType, region))
+			continue
+		}
+
+		if len(r.VMSizes) > 0 && sizes == nil {
+			var err error
+			if sizes, err = checker.VMSizes(ctx, region); err != nil {
+				sizes = map[string]bool{}
+			}
+		}
+		for _, size := range r.VMSizes {
+			if len(sizes) > 0 && !sizes[strings.ToLower(size)] {
+				issues = append(issues, fmt.Sprintf("%s: VM size %s is not available in %s", r.Address, size, region))
+			}
+		}
+	}
+	return issues
+}
+
+// ResourceTypeLocations returns the regions offering a resource type, from
+// its resource provider
+func (ac *AzureClient) ResourceTypeLocations(ctx context.Context, resourceType string) ([]string, error) {
+	namespace, typeName, ok := strings.Cut(resourceType, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid resource type %q", resourceType)
+	}
+	provider, err := ac.providersClient.Get(ctx, namespace, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resource provider %s: %w", namespace, err)
+	}
+	for _, rt := range provider.ResourceTypes {
+		if rt.ResourceType == nil || !strings.EqualFold(*rt.ResourceType, typeName) {
+			continue
+		}
+		locations := make([]string, 0, len(rt.Locations))
+		for _, l := range rt.Locations {
+			if l != nil {
+				locations = append(locations, *l)
+			}
+		}
+		return locations, nil
+	}
+	return nil, fmt.Errorf("resource type %s not found", resourceType)
+}
+
+// VMSizes returns the VM sizes the subscription can create in a region, from
+// the compute resource SKUs
+func (ac *AzureClient) VMSizes(ctx context.Context, location string) (map[string]bool, error) {
+	skus, err := ac.VMSizeDetails(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	sizes := make(map[string]bool, len(skus))
+	for name := range skus {
+		sizes[name] = true
+	}
+	return sizes, nil
+}
+
+// VMSizeDetails returns the VM sizes the subscription can create in a
+// region with their quota family and vCPUs, keyed by lower-case name
+func (ac *AzureClient) VMSizeDetails(ctx context.Context, location string) (map[string]VMSize, error) {
+	var body struct {
+		Value []struct {
+			ResourceType string `json:"resourceType"`
+			Name         string `json:"name"`
+			Family       string `json:"family"`
+			Capabilities []struct {
+				Name  string `json:"name"`
+				Value string `json:"value"`
+			} `json:"capabilities"`
+			Restrictions []struct {
+				Type string `json:"type"`
+			} `json:"restrictions"`
+		} `json:"value"`
+	}
+	path := fmt.Sprintf("/providers/Microsoft.Compute/skus?api-version=2021-07-01&$filter=%s",
+		url.QueryEscape(fmt.Sprintf("location eq '%s'", NormalizeRegion(location))))
+	if err := ac.getJSON(ctx, "list compute SKUs", path, &body); err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[string]VMSize)
+	for _, sku := range body.Value {
+		if sku.ResourceType != "virtualMachines" {
+			continue
+		}
+		restricted := false
+		for _, r := range sku.Restrictions {
+			// Zone restrictions still allow non-zonal deployments
+			if r.Type == "Location" {
+				restricted = true
+			}
+		}
+		if restricted {
+			continue
+		}
+		size := VMSize{Name: sku.Name, Family: sku.Family}
+		for _, c := range sku.Capabilities {
+			if c.Name == "vCPUs" {
+				size.VCPUs, _ = strconv.Atoi(c.Value)
+			}
+		}
+		sizes[strings.ToLower(sku.Name)] = size
+	}
+	return sizes, nil
+}
+
+// getJSON GETs a subscription-scoped ARM path and decodes the response
+func (ac *AzureClient) getJSON(ctx context.Context, op, path string, out interface{}) error {
+	token, err := ac.credential.GetToken(ctx, policy.TokenRequestOptions{
+		Scopes: []string{ac.endpoint() + "/.default"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get management token: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/subscriptions/%s%s", ac.endpoint(), ac.subscriptionID, path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return wrapError(op, ac.subscriptionID, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &Error{Op: op, Resource: ac.subscriptionID, StatusCode: resp.StatusCode, Err: fmt.Errorf("%s", resp.Status)}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"QLP/internal/packaging"
+)
+
+const aksTerraform = `resource "azurerm_resource_group" "main" {
+  name     = "rg"
+  location = var.location
+}
+
+resource "azurerm_kubernetes_cluster" "main" {
+  name = "aks"
+  default_node_pool {
+    name    = "default"
+    vm_size = "Standard_D4s_v5"
+  }
+}
+
+resource "azurerm_postgresql_flexible_server" "db" {
+  sku_name = "B_Standard_B1ms"
+}
+`
+
+type fakeAvailability struct {
+	locations map[string][]string
+	sizes     map[string]map[string]bool
+}
+
+func (f *fakeAvailability) ResourceTypeLocations(ctx context.Context, resourceType string) ([]string, error) {
+	locations, ok := f.locations[resourceType]
+	if !ok {
+		return nil, errors.New("provider lookup failed")
+	}
+	return locations, nil
+}
+
+func (f *fakeAvailability) VMSizes(ctx context.Context, location string) (map[string]bool, error) {
+	return f.sizes[location], nil
+}
+
+func TestExtractAzureResources(t *testing.T) {
+	resources := ExtractAzureResources(map[string]string{"infra/main.tf": aksTerraform, "README.md": aksTerraform})
+	if len(resources) != 2 {
+		t.Fatalf("expected the cluster and the database, got %+v", resources)
+	}
+	if resources[0].Address != "azurerm_kubernetes_cluster.main" || resources[0].ResourceType != "Microsoft.ContainerService/managedClusters" {
+		t.Errorf("unexpected cluster: %+v", resources[0])
+	}
+	if len(resources[0].VMSizes) != 1 || resources[0].VMSizes[0] != "Standard_D4s_v5" {
+		t.Errorf("expected the node pool VM size, got %v", resources[0].VMSizes)
+	}
+	if len(resources[1].VMSizes) != 0 {
+		t.Errorf("expected no VM sizes for the database, got %v", resources[1].VMSizes)
+	}
+}
+
+func TestCheckAvailability(t *testing.T) {
+	checker := &fakeAvailability{
+		locations: map[string][]string{
+			"Microsoft.ContainerService/managedClusters": {"UK South", "West Europe"},
+		},
+		sizes: map[string]map[string]bool{
+			"westeurope": {"standard_d4s_v5": true},
+			"uksouth":    {"standard_d2s_v5": true},
+		},
+	}
+	resources := ExtractAzureResources(map[string]string{"main.tf": aksTerraform})
+
+	if issues := CheckAvailability(context.Background(), checker, resources, "West Europe"); len(issues) != 0 {
+		t.Errorf("expected westeurope to offer everything, got %v", issues)
+	}
+	issues := CheckAvailability(context.Background(), checker, resources, "uksouth")
+	if len(issues) != 1 || !strings.Contains(issues[0], "VM size Standard_D4s_v5 is not available in uksouth") {
+		t.Errorf("expected the VM size to be unavailable in uksouth, got %v", issues)
+	}
+	issues = CheckAvailability(context.Background(), checker, resources, "brazilsouth")
+	if len(issues) != 1 || !strings.Contains(issues[0], "managedClusters is not available in brazilsouth") {
+		t.Errorf("expected AKS to be unavailable in brazilsouth, got %v", issues)
+	}
+}
+
+func TestParseRegions(t *testing.T) {
+	got := ParseRegions(" uksouth, West Europe,,UKSouth ")
+	if strings.Join(got, ",") != "uksouth,westeurope" {
+		t.Errorf("unexpected regions %v", got)
+	}
+}
+
+func TestDeployRegionsReportsUnavailableRegions(t *testing.T) {
+	dm := NewDeploymentManager(nil, 10)
+	dm.availability = &fakeAvailability{
+		locations: map[string][]string{"Microsoft.ContainerService/managedClusters": {"westeurope"}},
+		sizes:     map[string]map[string]bool{"westeurope": {"standard_d2s_v5": true}},
+	}
+	drop := &packaging.QuantumDrop{ID: "QD-1", Files: map[string]string{"main.tf": aksTerraform}}
+	config := DeploymentConfig{CapsuleID: "QD-1", ResourceGroup: "capsule-rg-1"}
+
+	result := dm.DeployRegions(context.Background(), drop, config, []string{"uksouth", "westeurope"})
+	if len(result.Succeeded()) != 0 || strings.Join(result.Failed(), ",") != "uksouth,westeurope" {
+		t.Fatalf("expected both regions to fail preflight, got %+v", result.Regions)
+	}
+	for _, r := range result.Regions {
+		if r.Result.ResourceGroup != "capsule-rg-1-"+r.Region || r.Result.Location != r.Region {
+			t.Errorf("expected a resource group per region, got %s in %s", r.Result.ResourceGroup, r.Result.Location)
+		}
+		if r.Result.Status != StatusFailed || len(r.Result.AvailabilityIssues) != 1 {
+			t.Errorf("%s: expected one availability issue, got %+v", r.Region, r.Result)
+		}
+	}
+
+	suites := result.JUnit()
+	if suites.Failures != 2 || suites.Suites[0].Name != "uksouth/preflight" {
+		t.Errorf("expected failed preflight suites per region, got %+v", suites)
+	}
+}
+
+func TestCostComparison(t *testing.T) {
+	result := &MultiRegionResult{Regions: []RegionResult{
+		{Region: "westeurope", Success: true, Result: &DeploymentResult{CostEstimate: CostEstimate{TotalUSD: 0.30}}},
+		{Region: "uksouth", Success: true, Result: &DeploymentResult{CostEstimate: CostEstimate{TotalUSD: 0.25}}},
+		{Region: "brazilsouth", Success: false, Result: &DeploymentResult{}},
+	}}
+	costs := result.CostComparison()
+	if len(costs) != 2 || costs[0].Region != "uksouth" || costs[1].DeltaUSD < 0.049 || costs[1].DeltaUSD > 0.051 {
+		t.Errorf("expected uksouth cheapest by $0.05, got %+v", costs)
+	}
+}
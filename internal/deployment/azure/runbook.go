@@ -0,0 +1,64 @@
+package azure
+
+import "fmt"
+
+// AutomationRunbook generates an Azure Automation PowerShell runbook that
+// enforces the TTL tags without QuantumLayer running: it deletes the
+// QuantumLayer resource groups past their expiry plus the grace period, or
+// older than the maximum age. Import it into an Automation account whose
+// managed identity can delete resource groups in the subscription and link
+// it to an hourly schedule.
+func AutomationRunbook(policy CleanupPolicy) string {
+	dryRun := "$false"
+	if policy.DryRun {
+		dryRun = "$true"
+	}
+
+	return fmt.Sprintf(`<#
+.SYNOPSIS
+  Deletes expired QuantumLayer validation resource groups.
+.DESCRIPTION
+  Generated by QuantumLayer. Resource groups tagged %[1]s=%[2]s are deleted
+  once %[3]s plus a grace period of %[4]d minutes has passed, or when %[5]s
+  is more than %[6]d minutes ago. Run it on a schedule from an Automation
+  account with a managed identity allowed to delete resource groups.
+#>
+param(
+    [bool] $DryRun = %[7]s
+)
+
+$ErrorActionPreference = "Stop"
+Connect-AzAccount -Identity | Out-Null
+
+$now = (Get-Date).ToUniversalTime()
+$grace = New-TimeSpan -Minutes %[4]d
+$maxAge = New-TimeSpan -Minutes %[6]d
+$deleted = 0
+
+foreach ($rg in Get-AzResourceGroup -Tag @{ "%[1]s" = "%[2]s" }) {
+    $expired = $false
+    $expiresAt = $rg.Tags["%[3]s"]
+    if ($expiresAt) {
+        $expired = $now -gt ([DateTime]::Parse($expiresAt).ToUniversalTime() + $grace)
+    }
+    $createdAt = $rg.Tags["%[5]s"]
+    if ($createdAt -and $maxAge.TotalMinutes -gt 0) {
+        $expired = $expired -or ($now - [DateTime]::Parse($createdAt).ToUniversalTime()) -gt $maxAge
+    }
+    if (-not $expired) {
+        continue
+    }
+
+    if ($DryRun) {
+        Write-Output "DRY RUN: would delete $($rg.ResourceGroupName) (expired $expiresAt)"
+        continue
+    }
+    Write-Output "Deleting $($rg.ResourceGroupName) (expired $expiresAt)"
+    Remove-AzResourceGroup -Name $rg.ResourceGroupName -Force -AsJob | Out-Null
+    $deleted++
+}
+
+Write-Output "Expired resource groups deleted: $deleted"
+`, TagCreatedBy, createdByQuantumLayer, TagExpiresAt, int(policy.GracePeriod.Minutes()),
+		TagCreatedAt, int(policy.MaxAge.Minutes()), dryRun)
+}
@@ -0,0 +1,221 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/tfstate"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"go.uber.org/zap"
+)
+
+const storageAPIVersion = "2023-01-01"
+
+// ValidationStateContainer holds the state of validation deployments, apart
+// from the state of the projects' own environments
+const ValidationStateContainer = "qlp-validation"
+
+// ValidationStateBackend returns where the validation deployment of a
+// capsule keeps its Terraform state
+func ValidationStateBackend(capsuleID string) tfstate.Backend {
+	backend := tfstate.BackendFromEnv("")
+	backend.Container = ValidationStateContainer
+	backend.KeyPrefix = capsuleID
+	return backend
+}
+
+// StateBackendProvisioner creates Terraform state backends and returns the
+// credentials terraform init needs for them
+type StateBackendProvisioner interface {
+	ProvisionStateBackend(ctx context.Context, backend tfstate.Backend) error
+	StateBackendKey(ctx context.Context, backend tfstate.Backend) (string, error)
+}
+
+// ProvisionStateBackend creates the resource group, storage account and
+// container of a state backend, leaving the parts that exist untouched.
+// The group is not tagged created-by, so the cleanup controller, which
+// deletes validation groups past their TTL, never removes state.
+func (ac *AzureClient) ProvisionStateBackend(ctx context.Context, backend tfstate.Backend) error {
+	if err := backend.Validate(); err != nil {
+		return err
+	}
+	location := backend.Location
+	if location == "" {
+		location = ac.location
+	}
+
+	exists, err := ac.CheckResourceGroupExists(ctx, backend.ResourceGroup)
+	if err != nil {
+		return fmt.Errorf("failed to check resource group existence: %w", err)
+	}
+	if !exists {
+		if _, err := ac.resourceGroupsClient.CreateOrUpdate(ctx, backend.ResourceGroup, armresources.ResourceGroup{
+			Location: &location,
+			Tags: map[string]*string{
+				"purpose":    stringPtr("terraform-state"),
+				"managed-by": stringPtr(createdByQuantumLayer),
+			},
+		}, nil); err != nil {
+			return wrapError("create resource group", backend.ResourceGroup, err)
+		}
+		audit.Record(ctx, audit.Entry{
+			Action:       audit.ActionResourceGroupCreate,
+			ResourceType: "Microsoft.Resources/resourceGroups",
+			ResourceIDs:  []string{ac.resourceGroupID(backend.ResourceGroup)},
+			Details:      map[string]interface{}{"location": location, "purpose": "terraform-state"},
+		})
+	}
+
+	account := ac.storageAccountPath(backend)
+	err = ac.armRequest(ctx, http.MethodGet, account, nil, nil)
+	if IsNotFound(err) {
+		ac.logger.Info("Creating Terraform state storage account",
+			zap.String("storage_account", backend.StorageAccount),
+			zap.String("resource_group", backend.ResourceGroup))
+		err = ac.armRequest(ctx, http.MethodPut, account, map[string]interface{}{
+			"location": location,
+			"kind":     "StorageV2",
+			"sku":      map[string]string{"name": "Standard_ZRS"},
+			"tags":     map[string]string{"purpose": "terraform-state"},
+			"properties": map[string]interface{}{
+				"minimumTlsVersion":        "TLS1_2",
+				"allowBlobPublicAccess":    false,
+				"supportsHttpsTrafficOnly": true,
+			},
+		}, nil)
+		if err == nil {
+			err = ac.waitForStorageAccount(ctx, account)
+		}
+		if err != nil {
+			return err
+		}
+		audit.Record(ctx, audit.Entry{
+			Action:       audit.ActionStorageAccountCreate,
+			ResourceType: "Microsoft.Storage/storageAccounts",
+			ResourceIDs:  []string{account},
+			Details:      map[string]interface{}{"location": location, "purpose": "terraform-state"},
+		})
+	} else if err != nil {
+		return err
+	}
+
+	// Versioning and soft delete allow recovering an overwritten or deleted state
+	if err := ac.armRequest(ctx, http.MethodPut, account+"/blobServices/default", map[string]interface{}{
+		"properties": map[string]interface{}{
+			"isVersioningEnabled":   true,
+			"deleteRetentionPolicy": map[string]interface{}{"enabled": true, "days": 30},
+		},
+	}, nil); err != nil {
+		return err
+	}
+	return ac.armRequest(ctx, http.MethodPut, account+"/blobServices/default/containers/"+backend.Container, map[string]interface{}{
+		"properties": map[string]string{"publicAccess": "None"},
+	}, nil)
+}
+
+// StateBackendKey returns an access key of the backend's storage account,
+// passed to terraform as ARM_ACCESS_KEY
+func (ac *AzureClient) StateBackendKey(ctx context.Context, backend tfstate.Backend) (string, error) {
+	var keys struct {
+		Keys []struct {
+			Value string `json:"value"`
+		} `json:"keys"`
+	}
+	if err := ac.armRequest(ctx, http.MethodPost, ac.storageAccountPath(backend)+"/listKeys", nil, &keys); err != nil {
+		return "", err
+	}
+	if len(keys.Keys) == 0 {
+		return "", fmt.Errorf("storage account %s has no access keys", backend.StorageAccount)
+	}
+	return keys.Keys[0].Value, nil
+}
+
+// waitForStorageAccount polls until a new storage account is provisioned
+func (ac *AzureClient) waitForStorageAccount(ctx context.Context, account string) error {
+	for {
+		var state struct {
+			Properties struct {
+				ProvisioningState string `json:"provisioningState"`
+			} `json:"properties"`
+		}
+		err := ac.armRequest(ctx, http.MethodGet, account, nil, &state)
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+		switch state.Properties.ProvisioningState {
+		case "Succeeded":
+			return nil
+		case "Failed":
+			return &Error{Op: "create storage account", Resource: account, Err: fmt.Errorf("provisioning failed")}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(ac.pollFrequency):
+		}
+	}
+}
+
+func (ac *AzureClient) storageAccountPath(backend tfstate.Backend) string {
+	return fmt.Sprintf("%s/providers/Microsoft.Storage/storageAccounts/%s",
+		ac.resourceGroupID(backend.ResourceGroup), backend.StorageAccount)
+}
+
+// armRequest calls the Resource Manager REST API for storage resources,
+// which have no SDK client in this module
+func (ac *AzureClient) armRequest(ctx context.Context, method, resourcePath string, body, out interface{}) error {
+	return ac.armRequestVersion(ctx, method, resourcePath, storageAPIVersion, body, out)
+}
+
+// armRequestVersion calls the Resource Manager REST API at apiVersion
+func (ac *AzureClient) armRequestVersion(ctx context.Context, method, resourcePath, apiVersion string, body, out interface{}) error {
+	token, err := ac.credential.GetToken(ctx, policy.TokenRequestOptions{
+		Scopes: []string{ac.endpoint() + "/.default"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get management token: %w", err)
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method,
+		fmt.Sprintf("%s%s?api-version=%s", ac.endpoint(), resourcePath, apiVersion), reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return wrapError(strings.ToLower(method), resourcePath, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return wrapError(strings.ToLower(method), resourcePath, err)
+	}
+	if resp.StatusCode >= 300 {
+		return &Error{Op: strings.ToLower(method), Resource: resourcePath, StatusCode: resp.StatusCode,
+			Err: fmt.Errorf("%s", strings.TrimSpace(string(data)))}
+	}
+	if out != nil && len(data) > 0 {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"QLP/internal/tfstate"
+)
+
+type fakeStateBackends struct {
+	provisioned []tfstate.Backend
+}
+
+func (f *fakeStateBackends) ProvisionStateBackend(ctx context.Context, backend tfstate.Backend) error {
+	f.provisioned = append(f.provisioned, backend)
+	return nil
+}
+
+func (f *fakeStateBackends) StateBackendKey(ctx context.Context, backend tfstate.Backend) (string, error) {
+	return "access-key", nil
+}
+
+func TestValidateStateBackend(t *testing.T) {
+	backends := &fakeStateBackends{}
+	var initArgs []string
+	dm := NewDeploymentManager(nil, 10)
+	dm.stateBackends = backends
+	dm.terraform = func(ctx context.Context, dir string, env []string, args ...string) ([]byte, error) {
+		initArgs = args
+		if env[0] != "ARM_ACCESS_KEY=access-key" {
+			t.Errorf("backend credentials not passed: %v", env)
+		}
+		return []byte("Terraform has been successfully initialized!"), nil
+	}
+
+	backend := tfstate.DefaultBackend("sub", "qlp-validation")
+	backend.KeyPrefix = "capsule-abc"
+	result := &DeploymentResult{TestResults: map[string]TestResult{}, DeploymentOutputs: map[string]interface{}{}}
+	files := map[string]string{"main.tf": nodePoolTerraform}
+	if err := dm.validateStateBackend(context.Background(), files, backend, true, result); err != nil {
+		t.Fatal(err)
+	}
+	if len(backends.provisioned) != 1 {
+		t.Errorf("backend provisioned %d times", len(backends.provisioned))
+	}
+	if !strings.Contains(strings.Join(initArgs, " "), "-backend-config=key=capsule-abc/terraform.tfstate") {
+		t.Errorf("init args = %v", initArgs)
+	}
+	if test := result.TestResults["terraform_init:."]; test.Status != "pass" {
+		t.Errorf("init result = %+v", test)
+	}
+
+	dm.terraform = func(ctx context.Context, dir string, env []string, args ...string) ([]byte, error) {
+		return []byte("Error: storage account not found"), errors.New("exit status 1")
+	}
+	if err := dm.validateStateBackend(context.Background(), files, backend, false, result); err == nil {
+		t.Error("expected a failed init to fail the infrastructure phase")
+	}
+	if len(backends.provisioned) != 1 {
+		t.Error("backend provisioned without being asked to")
+	}
+}
+
+func TestStateBackendKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		want := "/subscriptions/sub/resourceGroups/qlp-tfstate/providers/Microsoft.Storage/storageAccounts/qlptfstate/listKeys"
+		if r.Method != http.MethodPost || r.URL.Path != want {
+			http.Error(w, "unexpected request "+r.Method+" "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"keys": [{"keyName": "key1", "value": "secret"}]}`))
+	}))
+	defer server.Close()
+
+	ac := &AzureClient{
+		subscriptionID:     "sub",
+		credential:         &countingCredential{expires: time.Now().Add(time.Hour)},
+		managementEndpoint: server.URL,
+	}
+	backend := tfstate.Backend{ResourceGroup: "qlp-tfstate", StorageAccount: "qlptfstate", Container: "orders"}
+	key, err := ac.StateBackendKey(context.Background(), backend)
+	if err != nil || key != "secret" {
+		t.Fatalf("key = %q, %v", key, err)
+	}
+
+	backend.StorageAccount = "missing"
+	if _, err := ac.StateBackendKey(context.Background(), backend); !IsNotFound(err) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
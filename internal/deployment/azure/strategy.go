@@ -0,0 +1,288 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"QLP/internal/config"
+)
+
+// Strategy is how a new version of a capsule's containers replaces the one
+// serving traffic
+type Strategy string
+
+const (
+	// StrategyRecreate replaces the running version in place
+	StrategyRecreate Strategy = "recreate"
+	// StrategyBlueGreen deploys next to the live version, verifies it
+	// without traffic and then switches all traffic at once
+	StrategyBlueGreen Strategy = "blue_green"
+	// StrategyCanary shifts traffic to the new version in steps, verifying
+	// each step
+	StrategyCanary Strategy = "canary"
+)
+
+// Platform is where the containers run
+type Platform string
+
+const (
+	PlatformAppService Platform = "app_service"
+	PlatformKubernetes Platform = "kubernetes"
+)
+
+// StrategyConfig configures how a deployment rolls out
+type StrategyConfig struct {
+	Strategy    Strategy      `json:"strategy"`
+	Platform    Platform      `json:"platform,omitempty"`
+	App         string        `json:"app,omitempty"`          // App Service site, or Kubernetes service with <app>-blue and <app>-green deployments
+	Image       string        `json:"image,omitempty"`        // Container image of the new version
+	Slot        string        `json:"slot,omitempty"`         // App Service slot the new version is staged in; "staging" by default
+	Namespace   string        `json:"namespace,omitempty"`    // Kubernetes namespace
+	CanarySteps []int         `json:"canary_steps,omitempty"` // Traffic percentages before the full switch; 10 and 50 by default
+	StepWait    time.Duration `json:"step_wait,omitempty"`    // Time each canary step takes traffic before it is verified
+}
+
+// DefaultCanarySteps are the traffic percentages a canary goes through
+var DefaultCanarySteps = []int{10, 50}
+
+// ParseStrategy reads a strategy name; empty is recreate
+func ParseStrategy(s string) (Strategy, error) {
+	switch Strategy(strings.ReplaceAll(strings.ToLower(s), "-", "_")) {
+	case "", StrategyRecreate:
+		return StrategyRecreate, nil
+	case StrategyBlueGreen, "bluegreen":
+		return StrategyBlueGreen, nil
+	case StrategyCanary:
+		return StrategyCanary, nil
+	}
+	return "", fmt.Errorf("unknown deployment strategy %q (supported: recreate, blue_green, canary)", s)
+}
+
+// ParseCanarySteps reads comma-separated traffic percentages, e.g. "10,25,50"
+func ParseCanarySteps(s string) ([]int, error) {
+	var steps []int
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(field), "%"))
+		if field == "" {
+			continue
+		}
+		p, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid canary step %q", field)
+		}
+		steps = append(steps, p)
+	}
+	return steps, nil
+}
+
+// StrategyConfigFromEnv reads the rollout of validation deployments from
+// QLP_DEPLOY_STRATEGY, QLP_DEPLOY_PLATFORM, QLP_DEPLOY_APP,
+// QLP_DEPLOY_IMAGE, QLP_DEPLOY_SLOT, QLP_DEPLOY_NAMESPACE, QLP_CANARY_STEPS
+// and QLP_CANARY_STEP_WAIT
+func StrategyConfigFromEnv() (StrategyConfig, error) {
+	strategy, err := ParseStrategy(config.GetEnvOrDefault("QLP_DEPLOY_STRATEGY", "recreate"))
+	if err != nil {
+		return StrategyConfig{}, err
+	}
+	steps, err := ParseCanarySteps(config.GetEnvOrDefault("QLP_CANARY_STEPS", ""))
+	if err != nil {
+		return StrategyConfig{}, err
+	}
+	wait, err := time.ParseDuration(config.GetEnvOrDefault("QLP_CANARY_STEP_WAIT", "0s"))
+	if err != nil {
+		return StrategyConfig{}, fmt.Errorf("invalid QLP_CANARY_STEP_WAIT: %w", err)
+	}
+	return StrategyConfig{
+		Strategy:    strategy,
+		Platform:    Platform(config.GetEnvOrDefault("QLP_DEPLOY_PLATFORM", string(PlatformAppService))),
+		App:         config.GetEnvOrDefault("QLP_DEPLOY_APP", ""),
+		Image:       config.GetEnvOrDefault("QLP_DEPLOY_IMAGE", ""),
+		Slot:        config.GetEnvOrDefault("QLP_DEPLOY_SLOT", ""),
+		Namespace:   config.GetEnvOrDefault("QLP_DEPLOY_NAMESPACE", ""),
+		CanarySteps: steps,
+		StepWait:    wait,
+	}, nil
+}
+
+// Gradual reports whether the strategy keeps the live version serving while
+// the new one is verified, so a failure can be rolled back
+func (c StrategyConfig) Gradual() bool {
+	return c.Strategy == StrategyBlueGreen || c.Strategy == StrategyCanary
+}
+
+// Validate checks a gradual strategy has what it needs to run
+func (c StrategyConfig) Validate() error {
+	if !c.Gradual() {
+		return nil
+	}
+	if c.App == "" {
+		return fmt.Errorf("%s deployment needs an app name", c.Strategy)
+	}
+	if c.Image == "" {
+		return fmt.Errorf("%s deployment needs a container image", c.Strategy)
+	}
+	if c.Platform != PlatformAppService && c.Platform != PlatformKubernetes {
+		return fmt.Errorf("unknown platform %q (supported: app_service, kubernetes)", c.Platform)
+	}
+	for _, p := range c.CanarySteps {
+		if p <= 0 || p >= 100 {
+			return fmt.Errorf("canary step %d%% must be between 1 and 99", p)
+		}
+	}
+	return nil
+}
+
+// canarySteps returns the configured steps, in increasing order
+func (c StrategyConfig) canarySteps() []int {
+	steps := c.CanarySteps
+	if len(steps) == 0 {
+		steps = DefaultCanarySteps
+	}
+	steps = append([]int(nil), steps...)
+	sort.Ints(steps)
+	return steps
+}
+
+// Release is the version a strategy rolls out
+type Release struct {
+	Version string `json:"version"`
+	Image   string `json:"image"`
+}
+
+// TrafficRouter moves traffic between the live version of an app and a new
+// one deployed next to it
+type TrafficRouter interface {
+	// Stage deploys the release next to the live version, without traffic
+	Stage(ctx context.Context, release Release) error
+	// Shift sends percent of the traffic to the staged release
+	Shift(ctx context.Context, percent int) error
+	// Promote makes the staged release the live version
+	Promote(ctx context.Context) error
+	// Rollback returns all traffic to the previous live version
+	Rollback(ctx context.Context) error
+}
+
+// StrategyStatus is the outcome of a rollout
+type StrategyStatus string
+
+const (
+	StrategySucceeded  StrategyStatus = "succeeded"
+	StrategyRolledBack StrategyStatus = "rolled_back"
+	StrategyFailed     StrategyStatus = "failed" // The rollout failed and the live version could not be restored
+)
+
+// StrategyStep is one action of a rollout
+type StrategyStep struct {
+	Action    string        `json:"action"` // stage, shift, verify, promote or rollback
+	Percent   int           `json:"percent,omitempty"`
+	Status    string        `json:"status"` // pass, fail
+	Message   string        `json:"message,omitempty"`
+	Duration  time.Duration `json:"duration"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+// StrategyOutcome is the record of a rollout for the deployment report
+type StrategyOutcome struct {
+	Strategy   Strategy       `json:"strategy"`
+	Platform   Platform       `json:"platform,omitempty"`
+	Release    Release        `json:"release"`
+	Status     StrategyStatus `json:"status"`
+	Steps      []StrategyStep `json:"steps"`
+	RolledBack bool           `json:"rolled_back"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// Verifier checks the version serving traffic, failing when its health
+// checks or functional tests fail
+type Verifier func(ctx context.Context) error
+
+// RunStrategy rolls release out through router. A blue/green rollout is
+// verified while staged and again once live; a canary is verified at each
+// traffic step and once live. Any failure after staging rolls back to the
+// previous version.
+func RunStrategy(ctx context.Context, config StrategyConfig, router TrafficRouter, release Release, verify Verifier) *StrategyOutcome {
+	outcome := &StrategyOutcome{
+		Strategy: config.Strategy,
+		Platform: config.Platform,
+		Release:  release,
+		Steps:    make([]StrategyStep, 0),
+	}
+	step := func(action string, percent int, fn func() error) error {
+		start := time.Now()
+		err := fn()
+		s := StrategyStep{Action: action, Percent: percent, Status: "pass", Duration: time.Since(start), Timestamp: start}
+		if err != nil {
+			s.Status = "fail"
+			s.Message = err.Error()
+		}
+		outcome.Steps = append(outcome.Steps, s)
+		return err
+	}
+
+	if err := step("stage", 0, func() error { return router.Stage(ctx, release) }); err != nil {
+		// The live version was never touched
+		outcome.Status = StrategyFailed
+		outcome.Error = err.Error()
+		return outcome
+	}
+
+	err := rollout(ctx, config, router, verify, step)
+	if err == nil {
+		outcome.Status = StrategySucceeded
+		return outcome
+	}
+	outcome.Error = err.Error()
+	// Roll back on a context of its own: the rollout may have failed
+	// because ctx ran out
+	rollbackCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Minute)
+	defer cancel()
+	if rerr := step("rollback", 0, func() error { return router.Rollback(rollbackCtx) }); rerr != nil {
+		outcome.Status = StrategyFailed
+		outcome.Error += "; rollback failed: " + rerr.Error()
+		return outcome
+	}
+	outcome.Status = StrategyRolledBack
+	outcome.RolledBack = true
+	return outcome
+}
+
+func rollout(ctx context.Context, config StrategyConfig, router TrafficRouter, verify Verifier, step func(string, int, func() error) error) error {
+	check := func(percent int) error {
+		return step("verify", percent, func() error { return verify(ctx) })
+	}
+
+	switch config.Strategy {
+	case StrategyBlueGreen:
+		if err := check(0); err != nil {
+			return fmt.Errorf("staged version failed verification: %w", err)
+		}
+	case StrategyCanary:
+		for _, percent := range config.canarySteps() {
+			if err := step("shift", percent, func() error { return router.Shift(ctx, percent) }); err != nil {
+				return err
+			}
+			if config.StepWait > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(config.StepWait):
+				}
+			}
+			if err := check(percent); err != nil {
+				return fmt.Errorf("canary failed verification at %d%%: %w", percent, err)
+			}
+		}
+	}
+
+	if err := step("promote", 100, func() error { return router.Promote(ctx) }); err != nil {
+		return err
+	}
+	if err := check(100); err != nil {
+		return fmt.Errorf("promoted version failed verification: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"QLP/internal/packaging"
+)
+
+// fakeRouter records the calls of a rollout
+type fakeRouter struct {
+	calls    []string
+	stageErr error
+}
+
+func (f *fakeRouter) Stage(ctx context.Context, release Release) error {
+	f.calls = append(f.calls, "stage "+release.Image)
+	return f.stageErr
+}
+
+func (f *fakeRouter) Shift(ctx context.Context, percent int) error {
+	f.calls = append(f.calls, "shift "+strings.Repeat("|", percent/10))
+	return nil
+}
+
+func (f *fakeRouter) Promote(ctx context.Context) error {
+	f.calls = append(f.calls, "promote")
+	return nil
+}
+
+func (f *fakeRouter) Rollback(ctx context.Context) error {
+	f.calls = append(f.calls, "rollback")
+	return nil
+}
+
+// failAfter passes the first n verifications
+func failAfter(n int) Verifier {
+	return func(ctx context.Context) error {
+		if n == 0 {
+			return errors.New("health check failed")
+		}
+		n--
+		return nil
+	}
+}
+
+func actions(outcome *StrategyOutcome) string {
+	var out []string
+	for _, s := range outcome.Steps {
+		out = append(out, s.Action+":"+s.Status)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestRunStrategy(t *testing.T) {
+	release := Release{Version: "QD-1", Image: "acr.io/orders:2"}
+	tests := []struct {
+		name    string
+		config  StrategyConfig
+		verify  Verifier
+		status  StrategyStatus
+		steps   string
+		stepErr error
+	}{
+		{
+			name:   "blue/green",
+			config: StrategyConfig{Strategy: StrategyBlueGreen},
+			verify: failAfter(2),
+			status: StrategySucceeded,
+			steps:  "stage:pass,verify:pass,promote:pass,verify:pass",
+		},
+		{
+			name:   "blue/green fails before the switch",
+			config: StrategyConfig{Strategy: StrategyBlueGreen},
+			verify: failAfter(0),
+			status: StrategyRolledBack,
+			steps:  "stage:pass,verify:fail,rollback:pass",
+		},
+		{
+			name:   "canary",
+			config: StrategyConfig{Strategy: StrategyCanary, CanarySteps: []int{50, 20}},
+			verify: failAfter(3),
+			status: StrategySucceeded,
+			steps:  "stage:pass,shift:pass,verify:pass,shift:pass,verify:pass,promote:pass,verify:pass",
+		},
+		{
+			name:   "canary fails at the second step",
+			config: StrategyConfig{Strategy: StrategyCanary},
+			verify: failAfter(1),
+			status: StrategyRolledBack,
+			steps:  "stage:pass,shift:pass,verify:pass,shift:pass,verify:fail,rollback:pass",
+		},
+		{
+			name:    "staging fails",
+			config:  StrategyConfig{Strategy: StrategyCanary},
+			verify:  failAfter(3),
+			status:  StrategyFailed,
+			steps:   "stage:fail",
+			stepErr: errors.New("slot quota reached"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := &fakeRouter{stageErr: tt.stepErr}
+			outcome := RunStrategy(context.Background(), tt.config, router, release, tt.verify)
+			if outcome.Status != tt.status {
+				t.Errorf("status = %s (%s), want %s", outcome.Status, outcome.Error, tt.status)
+			}
+			if got := actions(outcome); got != tt.steps {
+				t.Errorf("steps = %s, want %s", got, tt.steps)
+			}
+			if outcome.RolledBack != (tt.status == StrategyRolledBack) {
+				t.Errorf("rolled back = %v", outcome.RolledBack)
+			}
+		})
+	}
+
+	// Canary steps go in increasing order
+	router := &fakeRouter{}
+	RunStrategy(context.Background(), StrategyConfig{Strategy: StrategyCanary, CanarySteps: []int{50, 20}}, router, release, failAfter(3))
+	if got := strings.Join(router.calls, ","); got != "stage acr.io/orders:2,shift ||,shift |||||,promote" {
+		t.Errorf("router calls = %s", got)
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	for in, want := range map[string]Strategy{"": StrategyRecreate, "blue-green": StrategyBlueGreen, "Canary": StrategyCanary} {
+		if got, err := ParseStrategy(in); err != nil || got != want {
+			t.Errorf("ParseStrategy(%q) = %s, %v", in, got, err)
+		}
+	}
+	if _, err := ParseStrategy("rolling"); err == nil {
+		t.Error("expected an error for an unknown strategy")
+	}
+	if steps, err := ParseCanarySteps("10%, 25,50"); err != nil || len(steps) != 3 || steps[1] != 25 {
+		t.Errorf("ParseCanarySteps = %v, %v", steps, err)
+	}
+	if err := (StrategyConfig{Strategy: StrategyCanary, App: "orders", Image: "orders:2", Platform: PlatformKubernetes, CanarySteps: []int{100}}).Validate(); err == nil {
+		t.Error("expected a 100% canary step to be rejected")
+	}
+}
+
+func TestKubernetesRollback(t *testing.T) {
+	var calls []string
+	dm := NewDeploymentManager(nil, 10)
+	dm.kubectl = func(ctx context.Context, args ...string) ([]byte, error) {
+		call := strings.Join(args[2:], " ")
+		calls = append(calls, call)
+		switch {
+		case strings.HasPrefix(call, "get service"):
+			return []byte("green"), nil
+		case strings.HasPrefix(call, "get deployment"):
+			return []byte("4"), nil
+		}
+		return nil, nil
+	}
+
+	config := DeploymentConfig{
+		CapsuleID: "QD-1",
+		Strategy: StrategyConfig{
+			Strategy:    StrategyCanary,
+			Platform:    PlatformKubernetes,
+			App:         "orders",
+			Image:       "acr.io/orders:2",
+			CanarySteps: []int{25},
+		},
+	}
+	result := &DeploymentResult{TestResults: make(map[string]TestResult)}
+	// A failing functional test fails the first canary step
+	result.TestResults["smoke"] = TestResult{Name: "smoke", Status: "fail", Output: "500 on /orders"}
+
+	err := dm.rollout(context.Background(), &packaging.QuantumDrop{ID: "QD-1"}, config, result)
+	if err == nil || result.Strategy == nil || result.Strategy.Status != StrategyRolledBack {
+		t.Fatalf("expected a rolled back canary, got %v, %+v", err, result.Strategy)
+	}
+
+	want := []string{
+		"get service orders -o jsonpath={.spec.selector.slot}",
+		"get deployment/orders-green -o jsonpath={.spec.replicas}",
+		`patch service orders --type=merge -p {"spec":{"selector":{"slot":"green"}}}`,
+		"set image deployment/orders-blue *=acr.io/orders:2",
+		"scale deployment/orders-blue --replicas=4",
+		"rollout status deployment/orders-blue --timeout=10m",
+		"scale deployment/orders-blue --replicas=1",
+		"scale deployment/orders-green --replicas=3",
+		`patch service orders --type=merge -p {"spec":{"selector":{"slot":null}}}`,
+		// rollback
+		"scale deployment/orders-green --replicas=4",
+		`patch service orders --type=merge -p {"spec":{"selector":{"slot":"green"}}}`,
+		"scale deployment/orders-blue --replicas=0",
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("kubectl calls:\n%s\nwant:\n%s", strings.Join(calls, "\n"), strings.Join(want, "\n"))
+	}
+
+	suites := result.JUnit()
+	if last := suites.Suites[len(suites.Suites)-1]; last.Name != "strategy" || last.Failures != 1 {
+		t.Errorf("expected a strategy suite with the failed verification, got %+v", last)
+	}
+}
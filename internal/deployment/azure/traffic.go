@@ -0,0 +1,255 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const webAPIVersion = "2023-12-01"
+
+// AppServiceSlots stages releases in a deployment slot of an App Service
+// app, shifts traffic to it with ramp-up rules and promotes it with a slot
+// swap
+type AppServiceSlots struct {
+	client        *AzureClient
+	resourceGroup string
+	app           string
+	slot          string
+	location      string
+	swapped       bool
+}
+
+// NewAppServiceSlots routes the traffic of app in resourceGroup through
+// slot, "staging" when empty
+func NewAppServiceSlots(client *AzureClient, resourceGroup, app, slot, location string) *AppServiceSlots {
+	if slot == "" {
+		slot = "staging"
+	}
+	return &AppServiceSlots{client: client, resourceGroup: resourceGroup, app: app, slot: slot, location: location}
+}
+
+func (a *AppServiceSlots) sitePath() string {
+	return fmt.Sprintf("%s/providers/Microsoft.Web/sites/%s", a.client.resourceGroupID(a.resourceGroup), a.app)
+}
+
+// Stage creates or updates the slot to run the release's image
+func (a *AppServiceSlots) Stage(ctx context.Context, release Release) error {
+	return a.client.armRequestVersion(ctx, http.MethodPut, a.sitePath()+"/slots/"+a.slot, webAPIVersion, map[string]interface{}{
+		"location": a.location,
+		"tags":     map[string]string{"release": release.Version},
+		"properties": map[string]interface{}{
+			"siteConfig": map[string]interface{}{
+				"linuxFxVersion": "DOCKER|" + release.Image,
+			},
+		},
+	}, nil)
+}
+
+// Shift routes percent of the production hostname's traffic to the slot
+func (a *AppServiceSlots) Shift(ctx context.Context, percent int) error {
+	rules := []map[string]interface{}{}
+	if percent > 0 {
+		rules = append(rules, map[string]interface{}{
+			"name":              a.slot,
+			"actionHostName":    fmt.Sprintf("%s-%s.azurewebsites.net", a.app, a.slot),
+			"reroutePercentage": percent,
+		})
+	}
+	return a.client.armRequestVersion(ctx, http.MethodPatch, a.sitePath()+"/config/web", webAPIVersion, map[string]interface{}{
+		"properties": map[string]interface{}{
+			"experiments": map[string]interface{}{"rampUpRules": rules},
+		},
+	}, nil)
+}
+
+// Promote clears the ramp-up rules and swaps the slot into production
+func (a *AppServiceSlots) Promote(ctx context.Context) error {
+	if err := a.Shift(ctx, 0); err != nil {
+		return err
+	}
+	if err := a.swap(ctx); err != nil {
+		return err
+	}
+	a.swapped = true
+	return nil
+}
+
+// Rollback clears the ramp-up rules and, after a swap, swaps back
+func (a *AppServiceSlots) Rollback(ctx context.Context) error {
+	if err := a.Shift(ctx, 0); err != nil {
+		return err
+	}
+	if !a.swapped {
+		return nil
+	}
+	if err := a.swap(ctx); err != nil {
+		return err
+	}
+	a.swapped = false
+	return nil
+}
+
+func (a *AppServiceSlots) swap(ctx context.Context) error {
+	return a.client.armRequestVersion(ctx, http.MethodPost, a.sitePath()+"/slotsswap", webAPIVersion, map[string]interface{}{
+		"targetSlot":   a.slot,
+		"preserveVnet": true,
+	}, nil)
+}
+
+// ErrNoKubectl is returned when the kubectl binary is not installed
+var ErrNoKubectl = errors.New("kubectl is not installed")
+
+// KubectlRunner runs kubectl with args
+type KubectlRunner func(ctx context.Context, args ...string) ([]byte, error)
+
+// KubectlCommand runs the kubectl binary on the PATH against the cluster
+// of KUBECONFIG
+func KubectlCommand(ctx context.Context, args ...string) ([]byte, error) {
+	bin, err := exec.LookPath("kubectl")
+	if err != nil {
+		return nil, ErrNoKubectl
+	}
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Env = os.Environ()
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return out, fmt.Errorf("kubectl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return out, nil
+}
+
+// KubernetesSlots routes traffic between the <app>-blue and <app>-green
+// deployments behind the <app> service, whose slot selector picks the live
+// one. A canary takes the slot out of the selector so the service balances
+// across both, in proportion to their replicas.
+type KubernetesSlots struct {
+	run       KubectlRunner
+	app       string
+	namespace string
+
+	live     string
+	idle     string
+	replicas int
+}
+
+// NewKubernetesSlots routes the traffic of app in namespace
+func NewKubernetesSlots(run KubectlRunner, app, namespace string) *KubernetesSlots {
+	if namespace == "" {
+		namespace = "default"
+	}
+	return &KubernetesSlots{run: run, app: app, namespace: namespace}
+}
+
+func (k *KubernetesSlots) kubectl(ctx context.Context, args ...string) (string, error) {
+	out, err := k.run(ctx, append([]string{"--namespace", k.namespace}, args...)...)
+	return strings.TrimSpace(string(out)), err
+}
+
+func (k *KubernetesSlots) deployment(slot string) string {
+	return "deployment/" + k.app + "-" + slot
+}
+
+// Stage finds the live slot and rolls the release out to the other one at
+// full size, outside the service
+func (k *KubernetesSlots) Stage(ctx context.Context, release Release) error {
+	live, err := k.kubectl(ctx, "get", "service", k.app, "-o", "jsonpath={.spec.selector.slot}")
+	if err != nil {
+		return err
+	}
+	k.live, k.idle = "blue", "green"
+	if live == "green" {
+		k.live, k.idle = "green", "blue"
+	}
+	replicas, err := k.kubectl(ctx, "get", k.deployment(k.live), "-o", "jsonpath={.spec.replicas}")
+	if err != nil {
+		return err
+	}
+	if k.replicas, err = strconv.Atoi(replicas); err != nil || k.replicas < 1 {
+		k.replicas = 1
+	}
+	// The live slot is pinned so the staged pods take no traffic
+	if err := k.selectSlot(ctx, k.live); err != nil {
+		return err
+	}
+	if _, err := k.kubectl(ctx, "set", "image", k.deployment(k.idle), "*="+release.Image); err != nil {
+		return err
+	}
+	if err := k.scale(ctx, k.idle, k.replicas); err != nil {
+		return err
+	}
+	_, err = k.kubectl(ctx, "rollout", "status", k.deployment(k.idle), "--timeout=10m")
+	return err
+}
+
+// Shift balances the service across both slots with percent of the
+// replicas, at least one, in the staged slot
+func (k *KubernetesSlots) Shift(ctx context.Context, percent int) error {
+	if percent <= 0 {
+		return k.selectSlot(ctx, k.live)
+	}
+	canary := (k.replicas*percent + 99) / 100
+	total := k.replicas
+	if canary >= total {
+		// Too few replicas to split; run one extra pod for the canary
+		total = canary + 1
+	}
+	if err := k.scale(ctx, k.idle, canary); err != nil {
+		return err
+	}
+	if err := k.scale(ctx, k.live, total-canary); err != nil {
+		return err
+	}
+	return k.selectSlot(ctx, "")
+}
+
+// Promote points the service at the staged slot at full size and scales
+// the old one down
+func (k *KubernetesSlots) Promote(ctx context.Context) error {
+	if err := k.scale(ctx, k.idle, k.replicas); err != nil {
+		return err
+	}
+	if err := k.selectSlot(ctx, k.idle); err != nil {
+		return err
+	}
+	return k.scale(ctx, k.live, 0)
+}
+
+// Rollback points the service at the previous live slot at full size and
+// scales the staged one down
+func (k *KubernetesSlots) Rollback(ctx context.Context) error {
+	if err := k.scale(ctx, k.live, k.replicas); err != nil {
+		return err
+	}
+	if err := k.selectSlot(ctx, k.live); err != nil {
+		return err
+	}
+	return k.scale(ctx, k.idle, 0)
+}
+
+func (k *KubernetesSlots) scale(ctx context.Context, slot string, replicas int) error {
+	_, err := k.kubectl(ctx, "scale", k.deployment(slot), "--replicas="+strconv.Itoa(replicas))
+	return err
+}
+
+// selectSlot pins the service to slot, or to both slots when empty
+func (k *KubernetesSlots) selectSlot(ctx context.Context, slot string) error {
+	var value interface{}
+	if slot != "" {
+		value = slot
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{"selector": map[string]interface{}{"slot": value}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = k.kubectl(ctx, "patch", "service", k.app, "--type=merge", "-p", string(patch))
+	return err
+}
@@ -0,0 +1,98 @@
+package deployments
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"QLP/internal/audit"
+)
+
+// Routes returns the deployment endpoints:
+//
+//	GET    /deployments?tenant=&capsule=    lists deployments, newest first
+//	GET    /deployments/{id}                returns a deployment
+//	POST   /deployments/{id}/rollback       rolls a deployment back to the last known-good one
+//
+// Deployments of other tenants than the request's are not found. Rollbacks
+// requested with an API key are recorded as the key's.
+func Routes(s *Service) map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /deployments":                listHandler(s),
+		"GET /deployments/{id}":           getHandler(s),
+		"POST /deployments/{id}/rollback": rollbackHandler(s),
+	}
+}
+
+func listHandler(s *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"deployments": s.History().List(audit.RequestTenant(r), q.Get("capsule")),
+		})
+	})
+}
+
+func getHandler(s *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, err := tenantDeployment(s, r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, d)
+	})
+}
+
+func rollbackHandler(s *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var opts Options
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			http.Error(w, "invalid rollback: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := tenantDeployment(s, r); err != nil {
+			writeError(w, err)
+			return
+		}
+		opts.Actor = audit.RequestActor(r, opts.Actor)
+		rollback, err := s.Rollback(r.Context(), r.PathValue("id"), opts)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, rollback)
+	})
+}
+
+// tenantDeployment returns the deployment the request names, not found when
+// it belongs to another tenant than the request's
+func tenantDeployment(s *Service, r *http.Request) (*Record, error) {
+	d, err := s.History().Get(r.PathValue("id"))
+	if err != nil {
+		return nil, err
+	}
+	if !audit.TenantAllowed(r, d.TenantID) {
+		return nil, ErrNotFound
+	}
+	return d, nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrInvalid):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrConflict):
+		status = http.StatusConflict
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,320 @@
+// Package deployments keeps the history of capsule deployments, with a
+// snapshot of the infrastructure code each one applied, and rolls a failed
+// deployment back to the last known-good one on the same target.
+package deployments
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"QLP/internal/failures"
+	"QLP/internal/tfstate"
+	"QLP/internal/trends"
+)
+
+var (
+	// ErrNotFound is returned for unknown deployment IDs
+	ErrNotFound = errors.New("deployment not found")
+	// ErrInvalid is returned for rollbacks missing an actor or reason, or
+	// for deployments that cannot be rolled back
+	ErrInvalid = errors.New("invalid rollback")
+	// ErrConflict is returned when a safety check stops a rollback
+	ErrConflict = errors.New("rollback refused")
+)
+
+// Status is the outcome of a deployment
+type Status string
+
+const (
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// Source is what made a deployment
+type Source string
+
+const (
+	SourceDeploy    Source = "deploy"    // qlp deploy
+	SourcePromotion Source = "promotion" // a promotion into an environment
+	SourceRollback  Source = "rollback"  // a rollback restoring a known-good deployment
+)
+
+// Record is one deployment of a capsule to a target. Each capsule version
+// deploys to a resource group of its own, so the target names what the
+// versions replace one another in: a promotion environment, or the resource
+// group of a validation deployment. Records are only ever appended; a
+// rollback is a record of its own.
+type Record struct {
+	ID             string           `json:"id"`
+	TenantID       string           `json:"tenant_id,omitempty"`
+	CapsuleID      string           `json:"capsule_id"`
+	Target         string           `json:"target"`
+	Environment    string           `json:"environment"`
+	SubscriptionID string           `json:"subscription_id"`
+	Location       string           `json:"location,omitempty"`
+	ResourceGroup  string           `json:"resource_group"`
+	StateBackend   *tfstate.Backend `json:"state_backend,omitempty"`
+	Image          string           `json:"image,omitempty"`
+	Status         Status           `json:"status"`
+	Error          string           `json:"error,omitempty"`
+	Source         Source           `json:"source"`
+	PromotionID    string           `json:"promotion_id,omitempty"`
+	RollbackOf     string           `json:"rollback_of,omitempty"` // The deployment a rollback replaced
+	Actor          string           `json:"actor,omitempty"`
+	Reason         string           `json:"reason,omitempty"`
+	Snapshot       string           `json:"snapshot,omitempty"` // ID of the deployment whose IaC snapshot was applied
+	CreatedAt      time.Time        `json:"created_at"`
+}
+
+// target identifies where a deployment went, across tenants and
+// subscriptions
+func (r Record) target() string {
+	return r.TenantID + "/" + r.SubscriptionID + "/" + r.Target
+}
+
+// IaC returns the infrastructure code of a capsule: its Terraform files and
+// variable files
+func IaC(files map[string]string) map[string]string {
+	iac := make(map[string]string)
+	for p, content := range files {
+		for _, suffix := range []string{".tf", ".tf.json", ".tfvars", ".tfvars.json"} {
+			if strings.HasSuffix(p, suffix) {
+				iac[p] = content
+				break
+			}
+		}
+	}
+	return iac
+}
+
+// History stores deployment records in deployments.jsonl and their IaC
+// snapshots in snapshots/ under a directory
+type History struct {
+	dir string
+
+	mu        sync.Mutex
+	records   []Record
+	snapshots map[string]map[string]string // in memory without a directory
+}
+
+// Open loads the history in dir; an empty dir keeps it in memory only
+func Open(dir string) (*History, error) {
+	h := &History{dir: dir, snapshots: make(map[string]map[string]string)}
+	if dir == "" {
+		return h, nil
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "snapshots"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create deployment history directory: %w", err)
+	}
+	f, err := os.Open(h.path())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return h, nil
+		}
+		return nil, fmt.Errorf("failed to open deployment history: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			return nil, fmt.Errorf("corrupt deployment history %s: %w", h.path(), err)
+		}
+		h.records = append(h.records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read deployment history: %w", err)
+	}
+	return h, nil
+}
+
+func (h *History) path() string {
+	return filepath.Join(h.dir, "deployments.jsonl")
+}
+
+func (h *History) snapshotPath(id string) string {
+	return filepath.Join(h.dir, "snapshots", id+".json")
+}
+
+// Add records a deployment with a snapshot of the infrastructure code it
+// applied, assigning its ID
+func (h *History) Add(r Record, iac map[string]string) (*Record, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.add(r, iac)
+}
+
+func (h *History) add(r Record, iac map[string]string) (*Record, error) {
+	now := time.Now()
+	r.ID = fmt.Sprintf("DEP-%d", now.UnixNano())
+	r.CreatedAt = now
+	if r.Snapshot == "" && len(iac) > 0 {
+		r.Snapshot = r.ID
+		if err := h.saveSnapshot(r.ID, iac); err != nil {
+			return nil, err
+		}
+	}
+	if h.dir != "" {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal deployment: %w", err)
+		}
+		f, err := os.OpenFile(h.path(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open deployment history: %w", err)
+		}
+		defer f.Close()
+		if _, err := f.Write(append(data, '\n')); err != nil {
+			return nil, fmt.Errorf("failed to write deployment: %w", err)
+		}
+	}
+	h.records = append(h.records, r)
+
+	success := 0.0
+	if r.Status == StatusSucceeded {
+		success = 1
+	}
+	trends.Record(context.Background(), trends.Sample{
+		Metric:    trends.DeploymentSuccess,
+		TenantID:  r.TenantID,
+		CapsuleID: r.CapsuleID,
+		Value:     success,
+		At:        r.CreatedAt,
+	})
+	if r.Status == StatusFailed {
+		message := r.Error
+		if message == "" {
+			message = "deployment failed"
+		}
+		failures.Record(context.Background(), failures.Failure{
+			TenantID:  r.TenantID,
+			Stage:     failures.StageDeployment,
+			RunID:     r.ID,
+			CapsuleID: r.CapsuleID,
+			At:        r.CreatedAt,
+		}, errors.New(message))
+	}
+	return &r, nil
+}
+
+func (h *History) saveSnapshot(id string, iac map[string]string) error {
+	if h.dir == "" {
+		h.snapshots[id] = iac
+		return nil
+	}
+	data, err := json.Marshal(iac)
+	if err != nil {
+		return fmt.Errorf("failed to marshal IaC snapshot: %w", err)
+	}
+	// Snapshots are written once and never changed
+	if err := os.WriteFile(h.snapshotPath(id), data, 0444); err != nil {
+		return fmt.Errorf("failed to write IaC snapshot: %w", err)
+	}
+	return nil
+}
+
+// Snapshot returns the infrastructure code a deployment applied
+func (h *History) Snapshot(id string) (map[string]string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.dir == "" {
+		iac, ok := h.snapshots[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: no IaC snapshot for %s", ErrNotFound, id)
+		}
+		return iac, nil
+	}
+	data, err := os.ReadFile(h.snapshotPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: no IaC snapshot for %s", ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to read IaC snapshot: %w", err)
+	}
+	var iac map[string]string
+	if err := json.Unmarshal(data, &iac); err != nil {
+		return nil, fmt.Errorf("corrupt IaC snapshot %s: %w", id, err)
+	}
+	return iac, nil
+}
+
+// Get returns a deployment
+func (h *History) Get(id string) (*Record, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := range h.records {
+		if h.records[i].ID == id {
+			r := h.records[i]
+			return &r, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// List returns the deployments of a tenant, or of all tenants when
+// tenantID is empty, optionally of one capsule, newest first
+func (h *History) List(tenantID, capsuleID string) []Record {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	list := make([]Record, 0)
+	for _, r := range h.records {
+		if (tenantID == "" || r.TenantID == tenantID) && (capsuleID == "" || r.CapsuleID == capsuleID) {
+			list = append(list, r)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list
+}
+
+// knownGood returns the latest succeeded deployment to the target of r
+// before it that was not itself rolled back. Callers hold h.mu.
+func (h *History) knownGood(r Record) *Record {
+	rolledBack := h.rolledBack()
+	for i := len(h.records) - 1; i >= 0; i-- {
+		c := h.records[i]
+		if c.target() == r.target() && c.CreatedAt.Before(r.CreatedAt) &&
+			c.Status == StatusSucceeded && !rolledBack[c.ID] {
+			return &c
+		}
+	}
+	return nil
+}
+
+// newer returns a deployment to the target of r made after it. Callers
+// hold h.mu.
+func (h *History) newer(r Record) *Record {
+	for i := len(h.records) - 1; i >= 0; i-- {
+		c := h.records[i]
+		if c.target() == r.target() && c.CreatedAt.After(r.CreatedAt) {
+			return &c
+		}
+	}
+	return nil
+}
+
+// rolledBack returns the IDs of deployments replaced by a successful
+// rollback. Callers hold h.mu.
+func (h *History) rolledBack() map[string]bool {
+	ids := make(map[string]bool)
+	for _, r := range h.records {
+		if r.Source == SourceRollback && r.Status == StatusSucceeded {
+			ids[r.RollbackOf] = true
+		}
+	}
+	return ids
+}
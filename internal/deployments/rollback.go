@@ -0,0 +1,212 @@
+package deployments
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"QLP/internal/audit"
+	"QLP/internal/logger"
+	"QLP/internal/tfstate"
+
+	"go.uber.org/zap"
+)
+
+// Restorer applies the IaC snapshot of a known-good deployment to that
+// deployment's Terraform state, planning only unless apply is set
+type Restorer interface {
+	Restore(ctx context.Context, good Record, iac map[string]string, apply bool) ([]tfstate.PlanResult, error)
+}
+
+// RestorerFunc adapts a function to a Restorer
+type RestorerFunc func(ctx context.Context, good Record, iac map[string]string, apply bool) ([]tfstate.PlanResult, error)
+
+// Restore calls f
+func (f RestorerFunc) Restore(ctx context.Context, good Record, iac map[string]string, apply bool) ([]tfstate.PlanResult, error) {
+	return f(ctx, good, iac, apply)
+}
+
+// Options are the safety inputs of a rollback
+type Options struct {
+	Actor  string `json:"actor"`
+	Reason string `json:"reason"`
+	DryRun bool   `json:"dry_run,omitempty"` // Plan the restore without applying it
+	Force  bool   `json:"force,omitempty"`   // Roll back even though a later deployment replaced this one
+}
+
+// Rollback is the outcome of rolling a deployment back
+type Rollback struct {
+	RolledBack Record               `json:"rolled_back"`          // The deployment rolled back
+	Restored   Record               `json:"restored"`             // The known-good deployment restored
+	Deployment *Record              `json:"deployment,omitempty"` // The rollback's own record; nil for a dry run
+	DryRun     bool                 `json:"dry_run"`
+	Plans      []tfstate.PlanResult `json:"plans"`
+}
+
+// Service rolls deployments back to the last known-good deployment of
+// their target
+type Service struct {
+	history  *History
+	restorer Restorer
+
+	mu     sync.Mutex
+	active map[string]bool // Targets with a rollback in progress
+}
+
+// New creates a rollback service over history
+func New(history *History, restorer Restorer) *Service {
+	return &Service{history: history, restorer: restorer, active: make(map[string]bool)}
+}
+
+// History returns the deployment history rolled back from
+func (s *Service) History() *History {
+	return s.history
+}
+
+// Rollback restores the target of a deployment to the last known-good
+// deployment before it, by applying that deployment's IaC snapshot to its
+// Terraform state again. It refuses when no known-good deployment with a
+// snapshot and state exists, when the deployment was already rolled back,
+// when a later deployment replaced it (unless forced) and while another
+// rollback of the same target runs. A dry run plans the restore and records
+// nothing but the audit entry.
+func (s *Service) Rollback(ctx context.Context, id string, opts Options) (*Rollback, error) {
+	log := logger.WithComponent("deployments")
+
+	if strings.TrimSpace(opts.Actor) == "" {
+		return nil, fmt.Errorf("%w: an actor is required", ErrInvalid)
+	}
+	if strings.TrimSpace(opts.Reason) == "" {
+		return nil, fmt.Errorf("%w: a reason is required", ErrInvalid)
+	}
+
+	h := s.history
+	h.mu.Lock()
+	var target *Record
+	for i := range h.records {
+		if h.records[i].ID == id {
+			r := h.records[i]
+			target = &r
+		}
+	}
+	var err error
+	var good *Record
+	switch {
+	case target == nil:
+		err = ErrNotFound
+	case h.rolledBack()[id]:
+		err = fmt.Errorf("%w: %s was already rolled back", ErrConflict, id)
+	case !opts.Force && h.newer(*target) != nil:
+		err = fmt.Errorf("%w: %s was replaced by %s; force the rollback to restore over it",
+			ErrConflict, id, h.newer(*target).ID)
+	default:
+		if good = h.knownGood(*target); good == nil {
+			err = fmt.Errorf("%w: no known-good deployment of %s before %s", ErrConflict, target.Target, id)
+		} else if good.Snapshot == "" {
+			err = fmt.Errorf("%w: known-good deployment %s has no IaC snapshot", ErrConflict, good.ID)
+		} else if good.StateBackend == nil {
+			err = fmt.Errorf("%w: known-good deployment %s kept no Terraform state", ErrConflict, good.ID)
+		}
+	}
+	h.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	iac, err := h.Snapshot(good.Snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("%w: known-good deployment %s: %v", ErrConflict, good.ID, err)
+	}
+
+	s.mu.Lock()
+	if s.active[target.target()] {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: a rollback of %s is already running", ErrConflict, target.Target)
+	}
+	s.active[target.target()] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.active, target.target())
+		s.mu.Unlock()
+	}()
+
+	log.Info("Rolling back deployment",
+		zap.String("deployment_id", id),
+		zap.String("known_good", good.ID),
+		zap.String("target", target.Target),
+		zap.Bool("dry_run", opts.DryRun))
+
+	plans, err := s.restorer.Restore(ctx, *good, iac, !opts.DryRun)
+	if err == nil {
+		for _, p := range plans {
+			if p.Error != "" {
+				err = fmt.Errorf("module %s: %s", p.Module, p.Error)
+				break
+			}
+		}
+	}
+
+	result := &Rollback{RolledBack: *target, Restored: *good, DryRun: opts.DryRun, Plans: plans}
+	if result.Plans == nil {
+		result.Plans = make([]tfstate.PlanResult, 0)
+	}
+	if !opts.DryRun {
+		record := Record{
+			TenantID:       target.TenantID,
+			CapsuleID:      good.CapsuleID,
+			Target:         target.Target,
+			Environment:    target.Environment,
+			SubscriptionID: good.SubscriptionID,
+			Location:       good.Location,
+			ResourceGroup:  good.ResourceGroup,
+			StateBackend:   good.StateBackend,
+			Image:          good.Image,
+			Status:         StatusSucceeded,
+			Source:         SourceRollback,
+			PromotionID:    good.PromotionID,
+			RollbackOf:     id,
+			Actor:          opts.Actor,
+			Reason:         opts.Reason,
+			Snapshot:       good.Snapshot,
+		}
+		if err != nil {
+			record.Status = StatusFailed
+			record.Error = err.Error()
+		}
+		added, rerr := h.Add(record, nil)
+		if rerr != nil {
+			log.Error("Failed to record rollback", zap.String("deployment_id", id), zap.Error(rerr))
+		} else {
+			result.Deployment = added
+		}
+	}
+
+	entry := audit.Entry{
+		Action:       audit.ActionDeploymentRollback,
+		ResourceType: "deployment",
+		ResourceIDs:  []string{id},
+		Details: map[string]interface{}{
+			"capsule_id":       target.CapsuleID,
+			"restored":         good.ID,
+			"restored_capsule": good.CapsuleID,
+			"target":           target.Target,
+			"resource_group":   good.ResourceGroup,
+			"reason":           opts.Reason,
+			"dry_run":          opts.DryRun,
+			"forced":           opts.Force,
+		},
+	}
+	if err != nil {
+		entry.Outcome = audit.OutcomeFailure
+		entry.Error = err.Error()
+		log.Warn("Rollback failed", zap.String("deployment_id", id), zap.Error(err))
+	}
+	actx := audit.WithActor(audit.WithTenant(ctx, target.TenantID), opts.Actor)
+	audit.Record(actx, entry)
+
+	if err != nil {
+		return result, fmt.Errorf("rollback of %s failed: %w", id, err)
+	}
+	return result, nil
+}
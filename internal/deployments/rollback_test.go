@@ -0,0 +1,215 @@
+package deployments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"QLP/internal/audit"
+	"QLP/internal/tfstate"
+)
+
+// fakeRestorer records the IaC it was asked to apply
+type fakeRestorer struct {
+	iac   map[string]string
+	apply bool
+	err   error
+}
+
+func (f *fakeRestorer) Restore(ctx context.Context, good Record, iac map[string]string, apply bool) ([]tfstate.PlanResult, error) {
+	f.iac, f.apply = iac, apply
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []tfstate.PlanResult{{Module: ".", Changes: true, Applied: apply}}, nil
+}
+
+func deploy(t *testing.T, h *History, capsule string, status Status, main string) *Record {
+	t.Helper()
+	r, err := h.Add(Record{
+		CapsuleID:      capsule,
+		Target:         "prod",
+		Environment:    "prod",
+		SubscriptionID: "sub",
+		ResourceGroup:  "capsule-rg-" + strings.ToLower(capsule) + "-prod",
+		StateBackend:   &tfstate.Backend{StorageAccount: "qlpstate", Container: "tfstate", KeyPrefix: "prod"},
+		Status:         status,
+		Source:         SourcePromotion,
+	}, IaC(map[string]string{"main.tf": main, "README.md": "docs"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRollback(t *testing.T) {
+	h, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	good := deploy(t, h, "QD-1", StatusSucceeded, "v1")
+	bad := deploy(t, h, "QD-2", StatusFailed, "v2")
+
+	restorer := &fakeRestorer{}
+	s := New(h, restorer)
+	ctx := context.Background()
+
+	if _, err := s.Rollback(ctx, bad.ID, Options{Actor: "alice"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected a reason to be required, got %v", err)
+	}
+	if _, err := s.Rollback(ctx, "DEP-0", Options{Actor: "alice", Reason: "x"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+	if _, err := s.Rollback(ctx, good.ID, Options{Actor: "alice", Reason: "x"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected the first deployment to have nothing to roll back to, got %v", err)
+	}
+
+	// A dry run plans the known-good snapshot without recording anything
+	rb, err := s.Rollback(ctx, bad.ID, Options{Actor: "alice", Reason: "500s", DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restorer.apply || restorer.iac["main.tf"] != "v1" || len(restorer.iac) != 1 {
+		t.Errorf("dry run restored %v, apply %v", restorer.iac, restorer.apply)
+	}
+	if rb.Deployment != nil || rb.Restored.ID != good.ID || len(h.List("", "")) != 2 {
+		t.Errorf("dry run recorded a deployment: %+v", rb)
+	}
+
+	rb, err = s.Rollback(ctx, bad.ID, Options{Actor: "alice", Reason: "500s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !restorer.apply || rb.Deployment == nil || rb.Deployment.CapsuleID != "QD-1" || rb.Deployment.RollbackOf != bad.ID {
+		t.Fatalf("unexpected rollback %+v", rb.Deployment)
+	}
+	if _, err := s.Rollback(ctx, bad.ID, Options{Actor: "alice", Reason: "again", Force: true}); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected a second rollback to be refused, got %v", err)
+	}
+
+	// Reloading the history keeps the records and snapshots
+	h, err = Open(h.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list := h.List("", ""); len(list) != 3 || list[0].Source != SourceRollback {
+		t.Fatalf("reloaded history = %+v", list)
+	}
+	if iac, err := h.Snapshot(rb.Deployment.Snapshot); err != nil || iac["main.tf"] != "v1" {
+		t.Errorf("rollback snapshot = %v, %v", iac, err)
+	}
+}
+
+func TestRollbackSafetyChecks(t *testing.T) {
+	h, _ := Open("")
+	first := deploy(t, h, "QD-1", StatusSucceeded, "v1")
+	second := deploy(t, h, "QD-2", StatusSucceeded, "v2")
+	third := deploy(t, h, "QD-3", StatusSucceeded, "v3")
+
+	restorer := &fakeRestorer{}
+	s := New(h, restorer)
+	ctx := context.Background()
+	opts := Options{Actor: "bob", Reason: "regression"}
+
+	if _, err := s.Rollback(ctx, second.ID, opts); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected a replaced deployment to need force, got %v", err)
+	}
+	opts.Force = true
+	if _, err := s.Rollback(ctx, second.ID, opts); err != nil || restorer.iac["main.tf"] != "v1" {
+		t.Fatalf("forced rollback = %v, restored %v", err, restorer.iac)
+	}
+
+	// second was rolled back, so it is no longer known-good for third
+	opts.Force = true
+	if rb, err := s.Rollback(ctx, third.ID, opts); err != nil || rb.Restored.ID != first.ID {
+		t.Errorf("expected %s to be restored, got %+v, %v", first.ID, rb, err)
+	}
+
+	// A failed restore is recorded as a failed rollback
+	restorer.err = errors.New("terraform apply failed")
+	last := deploy(t, h, "QD-4", StatusFailed, "v4")
+	rb, err := s.Rollback(ctx, last.ID, Options{Actor: "bob", Reason: "regression"})
+	if err == nil || rb == nil || rb.Deployment == nil || rb.Deployment.Status != StatusFailed {
+		t.Errorf("expected a failed rollback record, got %+v, %v", rb, err)
+	}
+}
+
+func TestRollbackHandler(t *testing.T) {
+	h, _ := Open("")
+	deploy(t, h, "QD-1", StatusSucceeded, "v1")
+	bad := deploy(t, h, "QD-2", StatusFailed, "v2")
+
+	mux := http.NewServeMux()
+	for pattern, handler := range Routes(New(h, &fakeRestorer{})) {
+		mux.Handle(pattern, handler)
+	}
+	tests := []struct {
+		body string
+		code int
+	}{
+		{`{"actor":"carol"}`, http.StatusBadRequest},
+		{`{"actor":"carol","reason":"errors","dry_run":true}`, http.StatusOK},
+		{`{"actor":"carol","reason":"errors"}`, http.StatusOK},
+		{`{"actor":"carol","reason":"errors"}`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/deployments/"+bad.ID+"/rollback", strings.NewReader(tt.body)))
+		if rec.Code != tt.code {
+			t.Errorf("%s: status = %d, want %d: %s", tt.body, rec.Code, tt.code, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/deployments?capsule=QD-1", nil))
+	if rec.Code != http.StatusOK || strings.Count(rec.Body.String(), `"capsule_id":"QD-1"`) != 2 {
+		t.Errorf("expected the deployment and its rollback, got %s", rec.Body.String())
+	}
+}
+
+func TestRoutesScopeToTheAPIKey(t *testing.T) {
+	h, _ := Open("")
+	for _, capsule := range []string{"QD-1", "QD-2"} {
+		if _, err := h.Add(Record{TenantID: "t1", CapsuleID: capsule, Target: "prod", Environment: "prod",
+			SubscriptionID: "sub", ResourceGroup: "rg-" + capsule, Status: StatusSucceeded, Source: SourcePromotion,
+			StateBackend: &tfstate.Backend{StorageAccount: "qlpstate", Container: "tfstate", KeyPrefix: "prod"}},
+			IaC(map[string]string{"main.tf": capsule})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bad := h.List("t1", "QD-2")[0]
+
+	mux := http.NewServeMux()
+	for pattern, handler := range Routes(New(h, &fakeRestorer{})) {
+		mux.Handle(pattern, handler)
+	}
+	as := func(tenant, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(audit.WithActor(audit.WithTenant(req.Context(), tenant), "api-key:k-"+tenant))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := as("t2", http.MethodGet, "/deployments/"+bad.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("get from another tenant: %d", rec.Code)
+	}
+	if rec := as("t2", http.MethodPost, "/deployments/"+bad.ID+"/rollback", `{"actor":"carol","reason":"errors"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("rollback from another tenant: %d", rec.Code)
+	}
+	if rec := as("t1", http.MethodGet, "/deployments/"+bad.ID, ""); rec.Code != http.StatusOK {
+		t.Errorf("get: %d", rec.Code)
+	}
+	rec := as("t1", http.MethodPost, "/deployments/"+bad.ID+"/rollback", `{"actor":"carol","reason":"errors"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("rollback: %d %s", rec.Code, rec.Body)
+	}
+	for _, r := range h.List("t1", "QD-1") {
+		if r.Source == SourceRollback && r.Actor != "api-key:k-t1" {
+			t.Errorf("rollback recorded as %q, want the API key", r.Actor)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Package diagnostics publishes the validation findings on generated
+// projects as diagnostics in the shape of the Language Server Protocol's
+// publishDiagnostics notification, keyed by file, line and column, so an
+// editor extension can highlight them inside the generated workspace. Feeds
+// follow an intent from its first task output through every refinement
+// iteration to the packaged capsule.
+package diagnostics
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"QLP/internal/preview"
+)
+
+// Source is the source every diagnostic reports, as editors show it
+const Source = "qlp"
+
+// Severity is an LSP DiagnosticSeverity
+type Severity int
+
+const (
+	SeverityError       Severity = 1
+	SeverityWarning     Severity = 2
+	SeverityInformation Severity = 3
+	SeverityHint        Severity = 4
+)
+
+// Position is a zero-based line and UTF-16 character offset, as in LSP
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range spans from Start to End, End exclusive
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Diagnostic is an LSP Diagnostic. Code is the rule that reported it and
+// Data carries the suggested remediation, if any.
+type Diagnostic struct {
+	Range    Range             `json:"range"`
+	Severity Severity          `json:"severity"`
+	Code     string            `json:"code,omitempty"`
+	Source   string            `json:"source"`
+	Message  string            `json:"message"`
+	Data     map[string]string `json:"data,omitempty"`
+}
+
+// File holds the diagnostics of one file, like LSP PublishDiagnosticsParams.
+// Path is relative to the workspace root, which the extension resolves into
+// the document URI. Files without findings are listed with none, so editors
+// clear the diagnostics a refinement fixed.
+type File struct {
+	Path        string       `json:"path"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// Feed is the current diagnostics of an intent's generated workspace. ID is
+// the capsule ID once the intent is packaged and the intent ID before.
+// Version increases with every update.
+type Feed struct {
+	ID          string       `json:"id"`
+	IntentID    string       `json:"intent_id,omitempty"`
+	CapsuleID   string       `json:"capsule_id,omitempty"`
+	TenantID    string       `json:"tenant_id,omitempty"`
+	Version     int          `json:"version"`
+	Refinements int          `json:"refinements"` // Refinement iterations the feed has followed
+	Final       bool         `json:"final"`       // Whether the capsule is packaged and the feed will not change
+	UpdatedAt   time.Time    `json:"updated_at"`
+	Files       []File       `json:"files"`
+	Workspace   []Diagnostic `json:"workspace"` // Findings on no file of the project
+}
+
+// Count returns the number of diagnostics in the feed
+func (f *Feed) Count() int {
+	n := len(f.Workspace)
+	for _, file := range f.Files {
+		n += len(file.Diagnostics)
+	}
+	return n
+}
+
+// FromPreview converts the findings a capsule's preview anchors to its
+// files into diagnostics, one entry per project file
+func FromPreview(c *preview.Capsule) ([]File, []Diagnostic) {
+	byFile := make(map[string][]Diagnostic)
+	workspace := make([]Diagnostic, 0)
+	for _, a := range c.Annotations {
+		if a.File == "" {
+			workspace = append(workspace, diagnostic(a, ""))
+			continue
+		}
+		byFile[a.File] = append(byFile[a.File], diagnostic(a, c.Files[a.File]))
+	}
+
+	paths := c.Paths()
+	files := make([]File, 0, len(paths))
+	for _, p := range paths {
+		diagnostics := byFile[p]
+		if diagnostics == nil {
+			diagnostics = []Diagnostic{}
+		}
+		sort.SliceStable(diagnostics, func(i, j int) bool {
+			return diagnostics[i].Range.Start.Line < diagnostics[j].Range.Start.Line
+		})
+		files = append(files, File{Path: p, Diagnostics: diagnostics})
+	}
+	return files, workspace
+}
+
+// diagnostic anchors an annotation to the text of its line, from the first
+// non-blank character to the end, or to the start of the file when it has
+// no line
+func diagnostic(a preview.Annotation, content string) Diagnostic {
+	d := Diagnostic{
+		Severity: severity(a.Severity),
+		Code:     a.Source,
+		Source:   Source,
+		Message:  a.Message,
+	}
+	if a.Remediation != "" {
+		d.Data = map[string]string{"remediation": a.Remediation}
+	}
+	if a.Line <= 0 {
+		return d
+	}
+	line := a.Line - 1
+	d.Range = Range{Start: Position{Line: line}, End: Position{Line: line}}
+	lines := strings.Split(content, "\n")
+	if line < len(lines) {
+		text := strings.TrimRight(lines[line], "\r")
+		indent := text[:len(text)-len(strings.TrimLeft(text, " \t"))]
+		d.Range.Start.Character = utf16Len(indent)
+		d.Range.End.Character = utf16Len(text)
+	}
+	return d
+}
+
+// severity maps the findings' severities onto LSP's
+func severity(s string) Severity {
+	switch strings.ToLower(s) {
+	case "critical", "high", "error":
+		return SeverityError
+	case "medium", "warning":
+		return SeverityWarning
+	case "low":
+		return SeverityInformation
+	default:
+		return SeverityHint
+	}
+}
+
+// utf16Len counts characters as LSP positions do by default
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
@@ -0,0 +1,183 @@
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/preview"
+	"QLP/internal/storage"
+)
+
+func TestFromPreview(t *testing.T) {
+	c := &preview.Capsule{
+		Files: map[string]string{
+			"main.go":   "package main\n\n\tmsg := \"héllo 👋\"\n",
+			"README.md": "# API\n",
+		},
+		Annotations: []preview.Annotation{
+			{File: "main.go", Line: 3, Severity: "high", Source: "security/secret", Message: "Hardcoded secret", Remediation: "Read it from the environment"},
+			{File: "main.go", Severity: "low", Source: "metrics/length", Message: "Long file"},
+			{Severity: "medium", Source: "quality/coverage", Message: "No tests"},
+		},
+	}
+	files, workspace := FromPreview(c)
+	if len(files) != 2 || files[0].Path != "README.md" || len(files[0].Diagnostics) != 0 {
+		t.Fatalf("expected every file listed, clean ones with no diagnostics, got %+v", files)
+	}
+
+	diagnostics := files[1].Diagnostics
+	if len(diagnostics) != 2 {
+		t.Fatalf("expected two diagnostics on main.go, got %+v", diagnostics)
+	}
+	fileLevel, onLine := diagnostics[0], diagnostics[1]
+	if fileLevel.Range != (Range{}) || fileLevel.Severity != SeverityInformation {
+		t.Errorf("expected the file-level finding at the start of the file, got %+v", fileLevel)
+	}
+	// The line is a tab, 15 characters and an emoji taking two UTF-16 units
+	want := Range{Start: Position{Line: 2, Character: 1}, End: Position{Line: 2, Character: 18}}
+	if onLine.Range != want || onLine.Severity != SeverityError || onLine.Code != "security/secret" ||
+		onLine.Source != Source || onLine.Data["remediation"] != "Read it from the environment" {
+		t.Errorf("unexpected diagnostic %+v", onLine)
+	}
+	if len(workspace) != 1 || workspace[0].Severity != SeverityWarning {
+		t.Errorf("expected the finding on no file kept workspace-wide, got %+v", workspace)
+	}
+}
+
+func TestFeedsFollowRefinements(t *testing.T) {
+	ctx := context.Background()
+	feeds := NewFeeds(0)
+	feeds.Start("", "intent-1", nil)
+
+	feeds.Update(ctx, "intent-1", "task-1", map[string]string{"Dockerfile": "FROM golang:latest\n"}, 0)
+	first, ok := feeds.Get("intent-1")
+	if !ok || first.Version != 1 || first.Final || first.Count() == 0 {
+		t.Fatalf("expected the first output diagnosed, got %+v", first)
+	}
+
+	feeds.Update(ctx, "intent-1", "task-1", map[string]string{"Dockerfile": "FROM golang:1.24\nUSER 65532\nHEALTHCHECK CMD true\n"}, 1)
+	refined, _ := feeds.Get("intent-1")
+	if refined.Version != 2 || refined.Refinements != 1 || refined.Count() >= first.Count() {
+		t.Errorf("expected the refinement to clear findings, got %+v", refined)
+	}
+
+	sealed := feeds.Seal("", "intent-1", &preview.Capsule{ID: "QL-CAP-1", Files: map[string]string{"main.go": "package main\n"}})
+	if !sealed.Final || sealed.ID != "QL-CAP-1" || sealed.Version != 3 || sealed.Refinements != 1 {
+		t.Errorf("unexpected sealed feed %+v", sealed)
+	}
+	if byIntent, _ := feeds.Get("intent-1"); byIntent.CapsuleID != "QL-CAP-1" {
+		t.Errorf("expected the intent to resolve to its capsule, got %+v", byIntent)
+	}
+	feeds.Update(ctx, "intent-1", "task-1", map[string]string{"late.go": "package late\n"}, 0)
+	if after, _ := feeds.Get("QL-CAP-1"); after.Version != 3 {
+		t.Errorf("expected late outputs ignored once sealed, got %+v", after)
+	}
+
+	feeds.Start("", "intent-1", map[string]map[string]string{"task-2": {"kept.go": "package kept\n"}})
+	if live, _ := feeds.Get("intent-1"); live.Final {
+		t.Error("expected a new run to open a live feed")
+	}
+	feeds.Update(ctx, "intent-1", "task-1", map[string]string{"main.go": "package main\n"}, 0)
+	if live, _ := feeds.Get("intent-1"); len(live.Files) != 2 {
+		t.Errorf("expected the kept task's files diagnosed with the rerun, got %+v", live.Files)
+	}
+	if capsule, _ := feeds.Get("QL-CAP-1"); !capsule.Final {
+		t.Error("expected the previous capsule's feed kept")
+	}
+}
+
+func TestFeedsEvictOldest(t *testing.T) {
+	feeds := NewFeeds(2)
+	now := time.Now()
+	feeds.now = func() time.Time { now = now.Add(time.Second); return now }
+	for i := 1; i <= 3; i++ {
+		feeds.Seal("", fmt.Sprintf("intent-%d", i), &preview.Capsule{ID: fmt.Sprintf("QL-CAP-%d", i)})
+	}
+	if _, ok := feeds.Get("QL-CAP-1"); ok {
+		t.Error("expected the oldest feed evicted")
+	}
+	if _, ok := feeds.Get("intent-1"); ok {
+		t.Error("expected the evicted feed's intent forgotten")
+	}
+	if _, ok := feeds.Get("intent-3"); !ok {
+		t.Error("expected the newest feed kept")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	feeds := NewFeeds(0)
+	feeds.Start("", "intent-1", nil)
+	feeds.Update(context.Background(), "intent-1", "task-1", map[string]string{"Dockerfile": "FROM golang:latest\n"}, 0)
+	loads := 0
+	load := func(ctx context.Context, id string) (*preview.Capsule, string, error) {
+		if id != "QL-CAP-9" {
+			return nil, "", fmt.Errorf("%s: %w", id, storage.ErrNotFound)
+		}
+		loads++
+		return preview.FromFiles(ctx, "", map[string]string{"main.go": "package main\n"}), "t1", nil
+	}
+	mux := http.NewServeMux()
+	for pattern, h := range Routes(feeds, load) {
+		mux.Handle(pattern, h)
+	}
+
+	getAs := func(tenant, id, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/capsules/"+id+"/diagnostics", nil)
+		req = req.WithContext(audit.WithTenant(req.Context(), tenant))
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	get := func(id, etag string) *httptest.ResponseRecorder {
+		return getAs("", id, etag)
+	}
+
+	rec := get("intent-1", "")
+	var feed Feed
+	if err := json.NewDecoder(rec.Body).Decode(&feed); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("expected the live feed, got %d: %v", rec.Code, err)
+	}
+	if feed.ID != "intent-1" || len(feed.Files) != 1 || len(feed.Files[0].Diagnostics) == 0 {
+		t.Errorf("unexpected feed %+v", feed)
+	}
+	etag := rec.Header().Get("ETag")
+	if rec := get("intent-1", etag); rec.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for an unchanged feed, got %d", rec.Code)
+	}
+	feeds.Update(context.Background(), "intent-1", "task-1", map[string]string{"Dockerfile": "FROM golang:1.24\n"}, 1)
+	if rec := get("intent-1", etag); rec.Code != http.StatusOK {
+		t.Errorf("expected the updated feed after a refinement, got %d", rec.Code)
+	}
+
+	if rec := get("QL-CAP-9", ""); rec.Code != http.StatusOK || !json.Valid(rec.Body.Bytes()) {
+		t.Errorf("expected a stored capsule diagnosed, got %d", rec.Code)
+	}
+	get("QL-CAP-9", "")
+	if loads != 1 {
+		t.Errorf("expected the stored capsule's feed cached, loaded %d times", loads)
+	}
+	if rec := get("QL-CAP-0", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown capsules, got %d", rec.Code)
+	}
+
+	// Requests with an API key only see their tenant's feeds
+	if rec := getAs("t2", "QL-CAP-9", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for another tenant's capsule, got %d", rec.Code)
+	}
+	if rec := getAs("t1", "QL-CAP-9", ""); rec.Code != http.StatusOK {
+		t.Errorf("expected the tenant's capsule diagnosed, got %d", rec.Code)
+	}
+	feeds.Start("t1", "intent-2", nil)
+	if rec := getAs("t2", "intent-2", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for another tenant's live feed, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,193 @@
+package diagnostics
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/config"
+	"QLP/internal/logger"
+	"QLP/internal/preview"
+
+	"go.uber.org/zap"
+)
+
+// DefaultMaxFeeds is how many feeds are kept before the least recently
+// updated are dropped
+const DefaultMaxFeeds = 200
+
+// Feeds keeps the diagnostics feed of each running intent and packaged
+// capsule in memory
+type Feeds struct {
+	mu      sync.Mutex
+	feeds   map[string]*feedState // Live feeds by intent ID, final feeds by capsule ID
+	aliases map[string]string     // Intent ID to the capsule its last run packaged
+	max     int
+	now     func() time.Time
+}
+
+type feedState struct {
+	feed        Feed
+	tasks       map[string]map[string]string // Files of each task's latest output
+	refinements map[string]int
+}
+
+// NewFeeds keeps up to limit feeds, DefaultMaxFeeds when limit is not
+// positive
+func NewFeeds(limit int) *Feeds {
+	if limit <= 0 {
+		limit = DefaultMaxFeeds
+	}
+	return &Feeds{
+		feeds:   make(map[string]*feedState),
+		aliases: make(map[string]string),
+		max:     limit,
+		now:     time.Now,
+	}
+}
+
+var (
+	sharedOnce  sync.Once
+	sharedFeeds *Feeds
+)
+
+// Shared returns the feeds of the process, or nil when
+// QLP_ENABLE_DIAGNOSTICS is false. QLP_DIAGNOSTICS_MAX_FEEDS bounds them.
+func Shared() *Feeds {
+	sharedOnce.Do(func() {
+		if config.GetEnvOrDefault("QLP_ENABLE_DIAGNOSTICS", "true") != "true" {
+			return
+		}
+		limit, err := strconv.Atoi(config.GetEnvOrDefault("QLP_DIAGNOSTICS_MAX_FEEDS", strconv.Itoa(DefaultMaxFeeds)))
+		if err != nil {
+			logger.WithComponent("diagnostics").Warn("Invalid QLP_DIAGNOSTICS_MAX_FEEDS, using the default",
+				zap.Int("default", DefaultMaxFeeds))
+		}
+		sharedFeeds = NewFeeds(limit)
+	})
+	return sharedFeeds
+}
+
+// Start opens a new live feed for a run of the intent of tenantID,
+// replacing that of its previous run. Kept holds the files of the tasks the run does not
+// re-execute, keyed by task ID; they are diagnosed with the first update.
+func (f *Feeds) Start(tenantID, intentID string, kept map[string]map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.aliases, intentID)
+	state := f.liveState(tenantID, intentID)
+	for taskID, files := range kept {
+		state.tasks[taskID] = files
+	}
+	f.feeds[intentID] = state
+	f.evict()
+}
+
+func (f *Feeds) liveState(tenantID, intentID string) *feedState {
+	return &feedState{
+		feed:        Feed{ID: intentID, IntentID: intentID, TenantID: tenantID, UpdatedAt: f.now()},
+		tasks:       make(map[string]map[string]string),
+		refinements: make(map[string]int),
+	}
+}
+
+// Update replaces the files of a task's output in the intent's live feed
+// and re-diagnoses the workspace. Refinements is the number of refinement
+// iterations behind the output. Updates after the run is sealed are ignored.
+func (f *Feeds) Update(ctx context.Context, intentID, taskID string, files map[string]string, refinements int) {
+	f.mu.Lock()
+	if _, sealed := f.aliases[intentID]; sealed {
+		f.mu.Unlock()
+		return
+	}
+	state, ok := f.feeds[intentID]
+	if !ok {
+		state = f.liveState(audit.TenantFromContext(ctx), intentID)
+		f.feeds[intentID] = state
+		f.evict()
+	}
+	state.tasks[taskID] = files
+	state.refinements[taskID] = max(state.refinements[taskID], refinements)
+	workspace := make(map[string]string)
+	for _, taskFiles := range state.tasks {
+		for name, content := range taskFiles {
+			workspace[name] = content
+		}
+	}
+	state.feed.Version++
+	version := state.feed.Version
+	f.mu.Unlock()
+
+	// Outputs of concurrent tasks are diagnosed in parallel; the latest
+	// version wins
+	diagnosed, general := FromPreview(preview.FromFiles(ctx, intentID, workspace))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.feeds[intentID] != state || state.feed.Version != version {
+		return
+	}
+	state.feed.Refinements = 0
+	for _, n := range state.refinements {
+		state.feed.Refinements += n
+	}
+	state.feed.Files, state.feed.Workspace = diagnosed, general
+	state.feed.UpdatedAt = f.now()
+}
+
+// Seal replaces the intent's live feed with the final diagnostics of the
+// capsule its run packaged, found by either ID from then on. The intent ID
+// is empty for capsules diagnosed from storage.
+func (f *Feeds) Seal(tenantID, intentID string, c *preview.Capsule) Feed {
+	files, general := FromPreview(c)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	feed := Feed{ID: c.ID, IntentID: intentID, CapsuleID: c.ID, TenantID: tenantID, Version: 1, Final: true,
+		UpdatedAt: f.now(), Files: files, Workspace: general}
+	if live, ok := f.feeds[intentID]; ok && intentID != "" {
+		feed.Version = live.feed.Version + 1
+		feed.Refinements = live.feed.Refinements
+		delete(f.feeds, intentID)
+	}
+	f.feeds[c.ID] = &feedState{feed: feed}
+	if intentID != "" {
+		f.aliases[intentID] = c.ID
+	}
+	f.evict()
+	return feed
+}
+
+// Get returns the feed of a capsule, or of an intent: live while it runs
+// and that of its capsule after
+func (f *Feeds) Get(id string) (Feed, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state, ok := f.feeds[id]
+	if !ok {
+		if state, ok = f.feeds[f.aliases[id]]; !ok {
+			return Feed{}, false
+		}
+	}
+	return state.feed, true
+}
+
+// evict drops the least recently updated feeds beyond the limit
+func (f *Feeds) evict() {
+	for len(f.feeds) > f.max {
+		oldest := ""
+		for key, state := range f.feeds {
+			if oldest == "" || state.feed.UpdatedAt.Before(f.feeds[oldest].feed.UpdatedAt) {
+				oldest = key
+			}
+		}
+		delete(f.feeds, oldest)
+		for intentID, capsuleID := range f.aliases {
+			if capsuleID == oldest {
+				delete(f.aliases, intentID)
+			}
+		}
+	}
+}
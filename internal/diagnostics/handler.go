@@ -0,0 +1,78 @@
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"QLP/internal/audit"
+	"QLP/internal/preview"
+	"QLP/internal/storage"
+)
+
+// Loader reads a stored capsule, and the tenant it belongs to, for the feeds
+// of capsules packaged by another process, returning an error wrapping
+// storage.ErrNotFound when there is none
+type Loader func(ctx context.Context, capsuleID string) (*preview.Capsule, string, error)
+
+// Routes returns the diagnostics endpoint:
+//
+//	GET /capsules/{id}/diagnostics   the diagnostics of a capsule, or of an intent while
+//	                                 its tasks and refinement iterations run
+//
+// The feed's version is its ETag: editors polling with If-None-Match get 304
+// Not Modified until the next update. Capsules without a feed are diagnosed
+// from storage when load is set. Other tenants' feeds are not found.
+func Routes(feeds *Feeds, load Loader) map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /capsules/{id}/diagnostics": feedHandler(feeds, load),
+	}
+}
+
+func feedHandler(feeds *Feeds, load Loader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		feed, ok := feeds.Get(id)
+		if !ok {
+			if load == nil {
+				http.Error(w, "no diagnostics for "+id, http.StatusNotFound)
+				return
+			}
+			c, tenantID, err := load(r.Context(), id)
+			if err != nil {
+				status := http.StatusInternalServerError
+				if errors.Is(err, storage.ErrNotFound) {
+					status = http.StatusNotFound
+				}
+				http.Error(w, err.Error(), status)
+				return
+			}
+			if c.ID == "" {
+				c.ID = id
+			}
+			feed = feeds.Seal(tenantID, "", c)
+		}
+		if !audit.TenantAllowed(r, feed.TenantID) {
+			http.Error(w, "no diagnostics for "+id, http.StatusNotFound)
+			return
+		}
+		if feed.Files == nil {
+			feed.Files = []File{}
+		}
+		if feed.Workspace == nil {
+			feed.Workspace = []Diagnostic{}
+		}
+
+		etag := fmt.Sprintf(`"%s-%d"`, feed.ID, feed.Version)
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "no-cache")
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(feed)
+	})
+}
@@ -0,0 +1,215 @@
+// Package e2e runs end-to-end scenarios against the qlp binary: each
+// scenario generates a capsule from an intent, optionally deploys it, and is
+// scored on generation time, validation score, deployment result and cost.
+// Scenarios run concurrently up to a bound and are aggregated into a
+// scoreboard for nightly regression tracking.
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scenario is one intent to run end to end
+type Scenario struct {
+	Name     string `json:"name"`
+	Intent   string `json:"intent"`
+	Deploy   bool   `json:"deploy,omitempty"`    // deploy the capsule to Azure after generating it
+	MinScore int    `json:"min_score,omitempty"` // minimum validation score to pass
+}
+
+// DefaultScenarios are run when no scenarios file is given
+var DefaultScenarios = []Scenario{
+	{Name: "rest-api", Intent: "Create a REST API for user management with JWT authentication", MinScore: 70},
+	{Name: "cli-tool", Intent: "Build a command line tool that converts CSV files to JSON", MinScore: 70},
+	{Name: "web-app", Intent: "Create a todo list web application with a React frontend and a Go backend", MinScore: 70},
+	{Name: "data-pipeline", Intent: "Build a Python data pipeline that loads CSV files into PostgreSQL", MinScore: 70},
+}
+
+// LoadScenarios reads a JSON array of scenarios
+func LoadScenarios(path string) ([]Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenarios: %w", err)
+	}
+	var scenarios []Scenario
+	if err := json.Unmarshal(data, &scenarios); err != nil {
+		return nil, fmt.Errorf("invalid scenarios file %s: %w", path, err)
+	}
+	for i, s := range scenarios {
+		if s.Intent == "" {
+			return nil, fmt.Errorf("scenario %d has no intent", i)
+		}
+		if s.Name == "" {
+			scenarios[i].Name = fmt.Sprintf("scenario-%d", i+1)
+		}
+	}
+	return scenarios, nil
+}
+
+// Filter keeps the scenarios with the given names, in order
+func Filter(scenarios []Scenario, names []string) ([]Scenario, error) {
+	if len(names) == 0 {
+		return scenarios, nil
+	}
+	byName := make(map[string]Scenario, len(scenarios))
+	for _, s := range scenarios {
+		byName[s.Name] = s
+	}
+	filtered := make([]Scenario, 0, len(names))
+	for _, name := range names {
+		s, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown scenario %q", name)
+		}
+		filtered = append(filtered, s)
+	}
+	return filtered, nil
+}
+
+// Executor runs a qlp subcommand with --json and returns its stdout
+type Executor func(ctx context.Context, args ...string) ([]byte, error)
+
+// CommandExecutor runs the qlp binary at path. A non-zero exit still
+// returns the JSON result printed before it.
+func CommandExecutor(path string) Executor {
+	return func(ctx context.Context, args ...string) ([]byte, error) {
+		cmd := exec.CommandContext(ctx, path, append([]string{"--json"}, args...)...)
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			if tail := lastLine(stderr.String()); tail != "" {
+				err = fmt.Errorf("%w: %s", err, tail)
+			}
+		}
+		return out, err
+	}
+}
+
+// Runner runs scenarios with bounded concurrency
+type Runner struct {
+	Exec        Executor
+	Concurrency int           // scenarios in flight at once; 1 runs them sequentially
+	Timeout     time.Duration // per scenario; zero means no limit
+}
+
+// Run executes the scenarios and returns the scoreboard. Results keep the
+// order of the scenarios regardless of when they finish.
+func (r *Runner) Run(ctx context.Context, scenarios []Scenario) *Scoreboard {
+	board := &Scoreboard{
+		StartedAt:   time.Now(),
+		Concurrency: max(r.Concurrency, 1),
+		Scenarios:   make([]ScenarioResult, len(scenarios)),
+	}
+
+	slots := make(chan struct{}, board.Concurrency)
+	var wg sync.WaitGroup
+	for i, s := range scenarios {
+		wg.Add(1)
+		go func(i int, s Scenario) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+				board.Scenarios[i] = r.runScenario(ctx, s)
+			case <-ctx.Done():
+				board.Scenarios[i] = ScenarioResult{Name: s.Name, Intent: s.Intent, Status: StatusFailed, Error: ctx.Err().Error()}
+			}
+		}(i, s)
+	}
+	wg.Wait()
+
+	board.DurationMS = time.Since(board.StartedAt).Milliseconds()
+	board.summarize()
+	return board
+}
+
+// generateOutput is the subset of qlp generate --json the scoreboard uses
+type generateOutput struct {
+	CapsuleID    string `json:"capsule_id"`
+	Status       string `json:"status"`
+	OverallScore int    `json:"overall_score"`
+	DurationMS   int64  `json:"duration_ms"`
+	Error        string `json:"error"`
+}
+
+// deployOutput is the subset of qlp deploy --json the scoreboard uses
+type deployOutput struct {
+	Status       string `json:"status"`
+	ErrorMessage string `json:"error_message"`
+	CostEstimate struct {
+		TotalUSD float64 `json:"total_usd"`
+	} `json:"cost_estimate"`
+	ActualCost *struct {
+		TotalUSD float64 `json:"total_usd"`
+	} `json:"actual_cost"`
+}
+
+func (r *Runner) runScenario(ctx context.Context, s Scenario) ScenarioResult {
+	result := ScenarioResult{Name: s.Name, Intent: s.Intent, Status: StatusPassed, StartedAt: time.Now()}
+	defer func() { result.DurationMS = time.Since(result.StartedAt).Milliseconds() }()
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	var gen generateOutput
+	err := r.exec(ctx, &gen, "generate", s.Intent)
+	result.CapsuleID = gen.CapsuleID
+	result.GenerationMS = gen.DurationMS
+	result.ValidationScore = gen.OverallScore
+	if err == nil && gen.Status != "completed" {
+		err = fmt.Errorf("intent %s", gen.Status)
+	}
+	if err != nil {
+		return result.fail("generate", err, gen.Error)
+	}
+	if result.ValidationScore < s.MinScore {
+		return result.fail("validate", fmt.Errorf("validation score %d below %d", result.ValidationScore, s.MinScore), "")
+	}
+
+	if !s.Deploy {
+		return result
+	}
+	if result.CapsuleID == "" {
+		return result.fail("deploy", errors.New("generation reported no capsule"), "")
+	}
+	var dep deployOutput
+	err = r.exec(ctx, &dep, "deploy", result.CapsuleID)
+	result.DeployStatus = dep.Status
+	result.CostUSD = dep.CostEstimate.TotalUSD
+	if dep.ActualCost != nil {
+		result.CostUSD = dep.ActualCost.TotalUSD
+	}
+	if err != nil {
+		return result.fail("deploy", err, dep.ErrorMessage)
+	}
+	return result
+}
+
+// exec runs a qlp subcommand and decodes its JSON result into out. Decoding
+// is attempted even when the command fails so the scoreboard keeps partial
+// results.
+func (r *Runner) exec(ctx context.Context, out interface{}, args ...string) error {
+	data, err := r.Exec(ctx, args...)
+	if len(data) > 0 {
+		if decodeErr := json.Unmarshal(data, out); decodeErr != nil && err == nil {
+			err = fmt.Errorf("invalid %s output: %w", args[0], decodeErr)
+		}
+	}
+	return err
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
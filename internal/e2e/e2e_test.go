@@ -0,0 +1,123 @@
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeQLP answers generate and deploy like the qlp binary, tracking how many
+// scenarios run at once
+type fakeQLP struct {
+	running, peak atomic.Int32
+}
+
+func (f *fakeQLP) exec(ctx context.Context, args ...string) ([]byte, error) {
+	n := f.running.Add(1)
+	defer f.running.Add(-1)
+	for {
+		peak := f.peak.Load()
+		if n <= peak || f.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	switch {
+	case args[0] == "generate" && strings.Contains(args[1], "broken"):
+		return []byte(`{"status": "failed", "error": "planner failed"}`), errors.New("exit status 1")
+	case args[0] == "generate" && strings.Contains(args[1], "sloppy"):
+		return []byte(`{"capsule_id": "QL-CAP-2", "status": "completed", "overall_score": 40, "duration_ms": 900}`), nil
+	case args[0] == "generate":
+		return []byte(`{"capsule_id": "QL-CAP-1", "status": "completed", "overall_score": 85, "duration_ms": 1200}`), nil
+	case args[0] == "deploy":
+		return []byte(`{"status": "completed", "cost_estimate": {"total_usd": 0.3}, "actual_cost": {"total_usd": 0.25}}`), nil
+	}
+	return nil, errors.New("unexpected command")
+}
+
+func TestRunnerScoreboard(t *testing.T) {
+	qlp := &fakeQLP{}
+	scenarios := []Scenario{
+		{Name: "api", Intent: "rest api", Deploy: true, MinScore: 70},
+		{Name: "cli", Intent: "cli tool", MinScore: 70},
+		{Name: "broken", Intent: "broken intent"},
+		{Name: "sloppy", Intent: "sloppy intent", MinScore: 70},
+		{Name: "web", Intent: "web app"},
+	}
+	board := (&Runner{Exec: qlp.exec, Concurrency: 2}).Run(context.Background(), scenarios)
+
+	if peak := qlp.peak.Load(); peak != 2 {
+		t.Errorf("expected 2 scenarios in flight at most, peaked at %d", peak)
+	}
+	for i, r := range board.Scenarios {
+		if r.Name != scenarios[i].Name {
+			t.Fatalf("results out of order: %v", board.Scenarios)
+		}
+	}
+	if api := board.Scenarios[0]; api.Status != StatusPassed || api.DeployStatus != "completed" || api.CostUSD != 0.25 {
+		t.Errorf("expected a deployed scenario with its actual cost, got %+v", api)
+	}
+	if broken := board.Scenarios[2]; broken.FailedStage != "generate" || !strings.Contains(broken.Error, "planner failed") {
+		t.Errorf("expected generation to fail with the qlp error, got %+v", broken)
+	}
+	if sloppy := board.Scenarios[3]; sloppy.FailedStage != "validate" {
+		t.Errorf("expected the score to fail validation, got %+v", sloppy)
+	}
+
+	s := board.Summary
+	if s.Total != 5 || s.Passed != 3 || s.Failed != 2 || s.Deployed != 1 || s.TotalCostUSD != 0.25 || s.AvgGenerationMS != 1125 {
+		t.Errorf("unexpected summary %+v", s)
+	}
+	if board.Passed() {
+		t.Error("scoreboard with failures should not pass")
+	}
+}
+
+func TestScoreboardWrite(t *testing.T) {
+	board := &Scoreboard{Label: "nightly <main>", StartedAt: time.Now(), Concurrency: 1, Scenarios: []ScenarioResult{
+		{Name: "api", Status: StatusPassed, ValidationScore: 85, GenerationMS: 1200},
+		{Name: "cli", Status: StatusFailed, FailedStage: "generate", Error: "planner failed"},
+	}}
+	board.summarize()
+
+	paths, err := board.Write(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(paths[0])
+	var decoded Scoreboard
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Summary.Passed != 1 || len(decoded.Scenarios) != 2 {
+		t.Errorf("unexpected JSON scoreboard: %s", data)
+	}
+	page, _ := os.ReadFile(paths[1])
+	for _, want := range []string{"nightly &lt;main&gt;", "1/2", "failed (generate)", "planner failed", "50%"} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("HTML scoreboard missing %q", want)
+		}
+	}
+}
+
+func TestLoadAndFilterScenarios(t *testing.T) {
+	path := t.TempDir() + "/scenarios.json"
+	os.WriteFile(path, []byte(`[{"name": "api", "intent": "rest api", "deploy": true}, {"intent": "cli tool"}]`), 0644)
+
+	scenarios, err := LoadScenarios(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scenarios) != 2 || !scenarios[0].Deploy || scenarios[1].Name != "scenario-2" {
+		t.Errorf("unexpected scenarios %+v", scenarios)
+	}
+	if filtered, err := Filter(scenarios, []string{"scenario-2"}); err != nil || len(filtered) != 1 {
+		t.Errorf("unexpected filter result %+v, %v", filtered, err)
+	}
+	if _, err := Filter(scenarios, []string{"missing"}); err == nil {
+		t.Error("unknown scenario names should fail")
+	}
+}
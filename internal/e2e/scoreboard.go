@@ -0,0 +1,182 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Status is the outcome of a scenario
+type Status string
+
+const (
+	StatusPassed Status = "passed"
+	StatusFailed Status = "failed"
+)
+
+// ScenarioResult is one row of the scoreboard
+type ScenarioResult struct {
+	Name            string    `json:"name"`
+	Intent          string    `json:"intent"`
+	Status          Status    `json:"status"`
+	CapsuleID       string    `json:"capsule_id,omitempty"`
+	GenerationMS    int64     `json:"generation_ms"`
+	ValidationScore int       `json:"validation_score"`
+	DeployStatus    string    `json:"deploy_status,omitempty"`
+	CostUSD         float64   `json:"cost_usd,omitempty"` // actual cost when reported, the estimate otherwise
+	FailedStage     string    `json:"failed_stage,omitempty"`
+	Error           string    `json:"error,omitempty"`
+	StartedAt       time.Time `json:"started_at"`
+	DurationMS      int64     `json:"duration_ms"`
+}
+
+func (r ScenarioResult) fail(stage string, err error, detail string) ScenarioResult {
+	r.Status = StatusFailed
+	r.FailedStage = stage
+	r.Error = err.Error()
+	if detail != "" && detail != r.Error {
+		r.Error += ": " + detail
+	}
+	return r
+}
+
+// Summary aggregates the scenario results
+type Summary struct {
+	Total              int     `json:"total"`
+	Passed             int     `json:"passed"`
+	Failed             int     `json:"failed"`
+	PassRate           float64 `json:"pass_rate"`
+	AvgGenerationMS    int64   `json:"avg_generation_ms"`
+	AvgValidationScore float64 `json:"avg_validation_score"`
+	Deployed           int     `json:"deployed"`
+	TotalCostUSD       float64 `json:"total_cost_usd"`
+}
+
+// Scoreboard is the result of an E2E run
+type Scoreboard struct {
+	Label       string           `json:"label,omitempty"` // e.g. the commit or nightly build
+	StartedAt   time.Time        `json:"started_at"`
+	DurationMS  int64            `json:"duration_ms"`
+	Concurrency int              `json:"concurrency"`
+	Summary     Summary          `json:"summary"`
+	Scenarios   []ScenarioResult `json:"scenarios"`
+}
+
+func (b *Scoreboard) summarize() {
+	s := Summary{Total: len(b.Scenarios)}
+	var generationMS int64
+	var scores, generated int
+	for _, r := range b.Scenarios {
+		if r.Status == StatusPassed {
+			s.Passed++
+		} else {
+			s.Failed++
+		}
+		if r.GenerationMS > 0 {
+			generationMS += r.GenerationMS
+			scores += r.ValidationScore
+			generated++
+		}
+		if r.DeployStatus != "" {
+			s.Deployed++
+		}
+		s.TotalCostUSD += r.CostUSD
+	}
+	if s.Total > 0 {
+		s.PassRate = float64(s.Passed) / float64(s.Total)
+	}
+	if generated > 0 {
+		s.AvgGenerationMS = generationMS / int64(generated)
+		s.AvgValidationScore = float64(scores) / float64(generated)
+	}
+	b.Summary = s
+}
+
+// Passed reports whether every scenario passed
+func (b *Scoreboard) Passed() bool {
+	return b.Summary.Failed == 0
+}
+
+// JSON encodes the scoreboard
+func (b *Scoreboard) JSON() ([]byte, error) {
+	return json.MarshalIndent(b, "", "  ")
+}
+
+// HTML renders the scoreboard as a self-contained page
+func (b *Scoreboard) HTML() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := scoreboardTemplate.Execute(&buf, b); err != nil {
+		return nil, fmt.Errorf("failed to render scoreboard: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Write saves scoreboard.json and scoreboard.html in dir and returns their
+// paths
+func (b *Scoreboard) Write(dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	data, err := b.JSON()
+	if err != nil {
+		return nil, err
+	}
+	page, err := b.HTML()
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{filepath.Join(dir, "scoreboard.json"), filepath.Join(dir, "scoreboard.html")}
+	for i, content := range [][]byte{data, page} {
+		if err := os.WriteFile(paths[i], content, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", paths[i], err)
+		}
+	}
+	return paths, nil
+}
+
+var scoreboardTemplate = template.Must(template.New("scoreboard").Funcs(template.FuncMap{
+	"percent": func(f float64) string { return fmt.Sprintf("%.0f%%", f*100) },
+	"seconds": func(ms int64) string { return fmt.Sprintf("%.1fs", float64(ms)/1000) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>QuantumLayer E2E scoreboard{{if .Label}} · {{.Label}}{{end}}</title>
+<style>
+  body { font-family: -apple-system, "Segoe UI", Roboto, sans-serif; margin: 2rem auto; max-width: 1100px; color: #1f2933; }
+  h1 { margin-bottom: 0.25rem; }
+  .meta { color: #52606d; margin-bottom: 2rem; }
+  .cards { display: grid; grid-template-columns: repeat(auto-fill, minmax(170px, 1fr)); gap: 1rem; }
+  .card { border-radius: 8px; padding: 1rem; background: #f5f7fa; }
+  .card .value { font-size: 2rem; font-weight: 600; }
+  table { width: 100%; border-collapse: collapse; margin-top: 1.5rem; font-size: 0.9rem; }
+  th, td { text-align: left; padding: 0.45rem 0.6rem; border-bottom: 1px solid #e4e7eb; vertical-align: top; }
+  th { background: #f5f7fa; }
+  .ok { color: #2f9e44; } .bad { color: #e03131; }
+</style>
+</head>
+<body>
+<h1>QuantumLayer E2E scoreboard</h1>
+<div class="meta">
+  {{if .Label}}<strong>{{.Label}}</strong> · {{end}}Started {{.StartedAt.Format "2006-01-02 15:04:05 MST"}} · {{seconds .DurationMS}} · {{.Concurrency}} in parallel
+</div>
+
+<div class="cards">
+  <div class="card"><div>Passed</div><div class="value {{if eq .Summary.Failed 0}}ok{{else}}bad{{end}}">{{.Summary.Passed}}/{{.Summary.Total}}</div></div>
+  <div class="card"><div>Pass rate</div><div class="value">{{percent .Summary.PassRate}}</div></div>
+  <div class="card"><div>Avg generation</div><div class="value">{{seconds .Summary.AvgGenerationMS}}</div></div>
+  <div class="card"><div>Avg validation score</div><div class="value">{{printf "%.0f" .Summary.AvgValidationScore}}</div></div>
+  <div class="card"><div>Deploy cost</div><div class="value">${{printf "%.2f" .Summary.TotalCostUSD}}</div></div>
+</div>
+
+<table>
+  <tr><th>Result</th><th>Scenario</th><th>Generation</th><th>Score</th><th>Deploy</th><th>Cost</th><th>Capsule</th><th>Error</th></tr>
+{{range .Scenarios}}  <tr><td>{{if eq .Status "passed"}}<span class="ok">passed</span>{{else}}<span class="bad">failed{{if .FailedStage}} ({{.FailedStage}}){{end}}</span>{{end}}</td><td title="{{.Intent}}">{{.Name}}</td><td>{{seconds .GenerationMS}}</td><td>{{.ValidationScore}}</td><td>{{.DeployStatus}}</td><td>{{if .CostUSD}}${{printf "%.2f" .CostUSD}}{{end}}</td><td>{{.CapsuleID}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
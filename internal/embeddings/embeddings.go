@@ -0,0 +1,123 @@
+// Package embeddings turns text into vectors for the vector store. Providers
+// (OpenAI, Azure OpenAI, a local sentence-transformers server, or the LLM
+// client's own embeddings) are wrapped by an Embedder that batches requests
+// and fits every vector to the dimension and normalization the store expects.
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrDimensionMismatch is returned when a provider's vectors are shorter than
+// the configured dimension
+var ErrDimensionMismatch = errors.New("embedding dimension mismatch")
+
+// Provider embeds a batch of texts, returning one vector per text in order
+type Provider interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	Name() string
+}
+
+// Config controls how provider vectors are shaped for storage
+type Config struct {
+	Dimensions int  // vectors are truncated to this length; 0 keeps the provider's
+	Normalize  bool // scale vectors to unit length
+	BatchSize  int  // texts per provider request; 0 sends everything at once
+}
+
+// DefaultConfig matches the vector(1536) columns of the intents and memory tables
+func DefaultConfig() Config {
+	return Config{Dimensions: 1536, Normalize: true, BatchSize: 64}
+}
+
+// Embedder batches requests to a provider and post-processes the vectors
+type Embedder struct {
+	provider Provider
+	config   Config
+}
+
+// New wraps provider with config
+func New(provider Provider, config Config) *Embedder {
+	return &Embedder{provider: provider, config: config}
+}
+
+// Provider returns the name of the backend
+func (e *Embedder) Provider() string {
+	return e.provider.Name()
+}
+
+// Dimensions returns the configured vector length, 0 if unconstrained
+func (e *Embedder) Dimensions() int {
+	return e.config.Dimensions
+}
+
+// Embed returns one vector per text, splitting the texts into batches
+func (e *Embedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	size := e.config.BatchSize
+	if size <= 0 {
+		size = len(texts)
+	}
+
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += size {
+		batch := texts[start:min(start+size, len(texts))]
+		out, err := e.provider.Embed(ctx, batch)
+		if err != nil {
+			return nil, fmt.Errorf("%s embedding failed: %w", e.provider.Name(), err)
+		}
+		if len(out) != len(batch) {
+			return nil, fmt.Errorf("%s returned %d embeddings for %d texts", e.provider.Name(), len(out), len(batch))
+		}
+		for _, v := range out {
+			v, err := e.shape(v)
+			if err != nil {
+				return nil, err
+			}
+			vectors = append(vectors, v)
+		}
+	}
+	return vectors, nil
+}
+
+// EmbedOne embeds a single text
+func (e *Embedder) EmbedOne(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := e.Embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// shape truncates v to the configured dimension and normalizes it
+func (e *Embedder) shape(v []float32) ([]float32, error) {
+	if d := e.config.Dimensions; d > 0 {
+		if len(v) < d {
+			return nil, fmt.Errorf("%w: %s returned %d, want %d", ErrDimensionMismatch, e.provider.Name(), len(v), d)
+		}
+		v = v[:d]
+	}
+	if e.config.Normalize {
+		v = normalize(v)
+	}
+	return v, nil
+}
+
+// normalize returns v scaled to unit length
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	scale := float32(1 / math.Sqrt(sum))
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = x * scale
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package embeddings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// countingProvider returns vectors of the given length and records batch sizes
+type countingProvider struct {
+	length  int
+	batches []int
+}
+
+func (p *countingProvider) Name() string { return "counting" }
+
+func (p *countingProvider) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	p.batches = append(p.batches, len(texts))
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, p.length)
+		v[0] = float32(len(text))
+		v[1] = 1
+		out[i] = v
+	}
+	return out, nil
+}
+
+func TestEmbedderBatchesAndShapes(t *testing.T) {
+	provider := &countingProvider{length: 8}
+	e := New(provider, Config{Dimensions: 4, Normalize: true, BatchSize: 2})
+
+	vectors, err := e.Embed(context.Background(), []string{"a", "bb", "ccc", "dddd", "eeeee"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 5 || len(provider.batches) != 3 || provider.batches[2] != 1 {
+		t.Fatalf("got %d vectors in batches %v", len(vectors), provider.batches)
+	}
+	for _, v := range vectors {
+		if len(v) != 4 {
+			t.Fatalf("vector not truncated: %d", len(v))
+		}
+		var sum float64
+		for _, x := range v {
+			sum += float64(x * x)
+		}
+		if math.Abs(sum-1) > 1e-5 {
+			t.Fatalf("vector not normalized: |v|^2 = %f", sum)
+		}
+	}
+
+	short := New(&countingProvider{length: 3}, Config{Dimensions: 4})
+	if _, err := short.EmbedOne(context.Background(), "x"); !errors.Is(err, ErrDimensionMismatch) {
+		t.Fatalf("expected ErrDimensionMismatch, got %v", err)
+	}
+}
+
+func TestLocalProviderAndRoutes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Inputs []string `json:"inputs"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		out := make([][]float32, len(body.Inputs))
+		for i := range out {
+			out[i] = []float32{3, 4}
+		}
+		json.NewEncoder(w).Encode(out)
+	}))
+	defer server.Close()
+
+	e := New(NewLocalProvider(server.URL), Config{Normalize: true})
+	handler := Routes(e)["POST /embeddings"]
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/embeddings", strings.NewReader(`{"inputs": ["one", "two"]}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Provider   string      `json:"provider"`
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if resp.Provider != "local" || len(resp.Embeddings) != 2 || resp.Embeddings[1][0] != 0.6 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/embeddings", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("empty inputs: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,48 @@
+package embeddings
+
+import (
+	"fmt"
+	"strconv"
+
+	"QLP/internal/config"
+	"QLP/internal/llm"
+)
+
+// NewFromEnv builds the embedder selected by QLP_EMBEDDING_PROVIDER: openai,
+// azure, local, or llm (the default) which uses fallback's own embeddings
+func NewFromEnv(fallback llm.Client) (*Embedder, error) {
+	cfg := DefaultConfig()
+	var err error
+	if cfg.Dimensions, err = strconv.Atoi(config.GetEnvOrDefault("QLP_EMBEDDING_DIMENSIONS", strconv.Itoa(cfg.Dimensions))); err != nil {
+		return nil, fmt.Errorf("invalid QLP_EMBEDDING_DIMENSIONS: %w", err)
+	}
+	if cfg.BatchSize, err = strconv.Atoi(config.GetEnvOrDefault("QLP_EMBEDDING_BATCH_SIZE", strconv.Itoa(cfg.BatchSize))); err != nil {
+		return nil, fmt.Errorf("invalid QLP_EMBEDDING_BATCH_SIZE: %w", err)
+	}
+	cfg.Normalize = config.GetEnvOrDefault("QLP_EMBEDDING_NORMALIZE", "true") == "true"
+
+	model := config.GetEnvOrDefault("QLP_EMBEDDING_MODEL", "")
+	var provider Provider
+	switch name := config.GetEnvOrDefault("QLP_EMBEDDING_PROVIDER", "llm"); name {
+	case "openai":
+		apiKey := config.GetEnvOrDefault("OPENAI_API_KEY", "")
+		if apiKey == "" {
+			return nil, fmt.Errorf("OPENAI_API_KEY is required for the openai embedding provider")
+		}
+		provider = NewOpenAIProvider(apiKey, model, cfg.Dimensions)
+	case "azure":
+		apiKey := config.GetEnvOrDefault("AZURE_OPENAI_API_KEY", "")
+		endpoint := config.GetEnvOrDefault("AZURE_OPENAI_ENDPOINT", "")
+		if apiKey == "" || endpoint == "" {
+			return nil, fmt.Errorf("AZURE_OPENAI_API_KEY and AZURE_OPENAI_ENDPOINT are required for the azure embedding provider")
+		}
+		provider = NewAzureProvider(apiKey, endpoint, model, cfg.Dimensions)
+	case "local":
+		provider = NewLocalProvider(config.GetEnvOrDefault("QLP_EMBEDDING_URL", "http://localhost:8080"))
+	case "llm":
+		provider = NewClientProvider(fallback)
+	default:
+		return nil, fmt.Errorf("unknown QLP_EMBEDDING_PROVIDER %q", name)
+	}
+	return New(provider, cfg), nil
+}
@@ -0,0 +1,50 @@
+package embeddings
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// maxBatchInputs bounds a single POST /embeddings request
+const maxBatchInputs = 2048
+
+// Routes exposes batch embedding over HTTP
+func Routes(e *Embedder) map[string]http.Handler {
+	return map[string]http.Handler{
+		"POST /embeddings": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var body struct {
+				Inputs []string `json:"inputs"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil || len(body.Inputs) == 0 {
+				http.Error(w, "inputs is required", http.StatusBadRequest)
+				return
+			}
+			if len(body.Inputs) > maxBatchInputs {
+				http.Error(w, "too many inputs", http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			vectors, err := e.Embed(req.Context(), body.Inputs)
+			if err != nil {
+				status := http.StatusBadGateway
+				if errors.Is(err, ErrDimensionMismatch) {
+					status = http.StatusInternalServerError
+				}
+				http.Error(w, err.Error(), status)
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"provider":   e.Provider(),
+				"dimensions": e.Dimensions(),
+				"embeddings": vectors,
+			})
+		}),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,197 @@
+package embeddings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"QLP/internal/llm"
+)
+
+// OpenAIProvider calls the OpenAI or Azure OpenAI embeddings API
+type OpenAIProvider struct {
+	name       string
+	url        string
+	model      string
+	dimensions int
+	header     http.Header
+	client     *http.Client
+}
+
+// NewOpenAIProvider embeds with model (text-embedding-3-small by default).
+// dimensions, when set, asks text-embedding-3 models for shorter vectors.
+func NewOpenAIProvider(apiKey, model string, dimensions int) *OpenAIProvider {
+	if model == "" {
+		model = "text-embedding-3-small"
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+apiKey)
+	return &OpenAIProvider{
+		name:       "openai",
+		url:        "https://api.openai.com/v1/embeddings",
+		model:      model,
+		dimensions: dimensions,
+		header:     header,
+		client:     &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// NewAzureProvider embeds with an Azure OpenAI deployment
+func NewAzureProvider(apiKey, endpoint, deployment string, dimensions int) *OpenAIProvider {
+	if deployment == "" {
+		deployment = "text-embedding-ada-002"
+	}
+	header := http.Header{}
+	header.Set("api-key", apiKey)
+	return &OpenAIProvider{
+		name:       "azure_openai",
+		url:        fmt.Sprintf("%s/openai/deployments/%s/embeddings?api-version=2024-02-01", strings.TrimRight(endpoint, "/"), deployment),
+		model:      deployment,
+		dimensions: dimensions,
+		header:     header,
+		client:     &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+func (p *OpenAIProvider) Name() string {
+	return p.name
+}
+
+func (p *OpenAIProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	reqBody := map[string]interface{}{"input": texts, "model": p.model}
+	// ada-002 rejects the dimensions parameter
+	if p.dimensions > 0 && strings.HasPrefix(p.model, "text-embedding-3") {
+		reqBody["dimensions"] = p.dimensions
+	}
+	data, err := postJSON(ctx, p.client, p.url, p.header, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+			Index     int       `json:"index"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	// The API may return data out of order; Index ties each vector to its input
+	vectors := make([][]float32, len(texts))
+	for _, d := range resp.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("no embedding returned for input %d", i)
+		}
+	}
+	return vectors, nil
+}
+
+// LocalProvider calls a self-hosted sentence-transformers server. It posts
+// {"inputs": [...]} to /embed, the text-embeddings-inference API, and accepts
+// either a bare array of vectors or {"embeddings": [...]} in reply.
+type LocalProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewLocalProvider targets the server at baseURL
+func NewLocalProvider(baseURL string) *LocalProvider {
+	return &LocalProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+func (p *LocalProvider) Name() string {
+	return "local"
+}
+
+func (p *LocalProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	data, err := postJSON(ctx, p.client, p.baseURL+"/embed", nil, map[string]interface{}{"inputs": texts})
+	if err != nil {
+		return nil, err
+	}
+
+	var vectors [][]float32
+	if err := json.Unmarshal(data, &vectors); err == nil {
+		return vectors, nil
+	}
+	var wrapped struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return wrapped.Embeddings, nil
+}
+
+// postJSON sends body to url and returns the response body of a 200 reply
+func postJSON(ctx context.Context, client *http.Client, url string, header http.Header, body interface{}) ([]byte, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding endpoint returned status %d: %s", resp.StatusCode, data)
+	}
+	return data, nil
+}
+
+// ClientProvider embeds one text at a time with an LLM client, for setups
+// without a dedicated embeddings backend
+type ClientProvider struct {
+	client llm.Client
+}
+
+// NewClientProvider wraps client
+func NewClientProvider(client llm.Client) *ClientProvider {
+	return &ClientProvider{client: client}
+}
+
+func (p *ClientProvider) Name() string {
+	return "llm"
+}
+
+func (p *ClientProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		v, err := p.client.GenerateEmbedding(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		vectors[i] = v
+	}
+	return vectors, nil
+}
@@ -0,0 +1,232 @@
+// Package erasure deletes everything stored for a tenant on request: intents
+// and their tasks, capsules and embeddings in Postgres, stored artifacts and
+// their encryption keys, custom validation rules, schedules and workspaces.
+// Audit entries are kept for accountability but stripped of personal data.
+// Each deletion produces a report signed with HMAC-SHA256, so it can be
+// handed to the tenant as proof and verified later.
+package erasure
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/logger"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrNotFound is returned for unknown deletion reports
+	ErrNotFound = errors.New("deletion report not found")
+	// ErrInvalidRequest is returned for deletion requests that are not confirmed
+	ErrInvalidRequest = errors.New("invalid deletion request")
+)
+
+// Report statuses
+const (
+	StatusCompleted = "completed"
+	StatusPartial   = "partial" // Some targets failed; running the deletion again is safe
+)
+
+// Scope is what a deletion covers. Targets run in order and may widen it:
+// the artifact target records the tenant's capsules so later targets can
+// find the intents behind them.
+type Scope struct {
+	TenantID   string
+	CapsuleIDs []string
+	IntentIDs  []string
+}
+
+// AddCapsules records capsule IDs, skipping ones already known
+func (s *Scope) AddCapsules(ids ...string) {
+	s.CapsuleIDs = addUnique(s.CapsuleIDs, ids)
+}
+
+// AddIntents records intent IDs, skipping ones already known
+func (s *Scope) AddIntents(ids ...string) {
+	s.IntentIDs = addUnique(s.IntentIDs, ids)
+}
+
+func addUnique(list, ids []string) []string {
+	seen := make(map[string]bool, len(list))
+	for _, id := range list {
+		seen[id] = true
+	}
+	for _, id := range ids {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			list = append(list, id)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Target is a place tenant data lives in. Purge deletes the tenant's data
+// and returns how many items of each kind it removed. It must be safe to
+// run again after a partial failure.
+type Target interface {
+	Name() string
+	Purge(ctx context.Context, scope *Scope) (map[string]int, error)
+}
+
+// TargetResult is the outcome of purging one target
+type TargetResult struct {
+	Target  string         `json:"target"`
+	Deleted map[string]int `json:"deleted"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// Report records a tenant deletion
+type Report struct {
+	ID          string         `json:"id"`
+	TenantID    string         `json:"tenant_id"`
+	RequestedBy string         `json:"requested_by"`
+	Reason      string         `json:"reason,omitempty"`
+	Status      string         `json:"status"`
+	StartedAt   time.Time      `json:"started_at"`
+	CompletedAt time.Time      `json:"completed_at"`
+	CapsuleIDs  []string       `json:"capsule_ids"`
+	IntentIDs   []string       `json:"intent_ids"`
+	Targets     []TargetResult `json:"targets"`
+	Signature   string         `json:"signature"` // Hex HMAC-SHA256 of the report without its signature
+}
+
+// Request asks for a tenant's data to be deleted. Confirm must repeat the
+// tenant ID.
+type Request struct {
+	TenantID    string `json:"tenant_id"`
+	Confirm     string `json:"confirm"`
+	RequestedBy string `json:"requested_by"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// Service runs tenant deletions across its targets
+type Service struct {
+	targets []Target
+	reports ReportStore
+	key     []byte
+}
+
+// NewService creates a deletion service signing reports with key. Without a
+// key a random one is used, and reports cannot be verified after a restart.
+func NewService(reports ReportStore, key []byte, targets ...Target) *Service {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		rand.Read(key)
+		logger.WithComponent("erasure").Warn("No deletion report signing key set, reports will not verify after a restart")
+	}
+	return &Service{targets: targets, reports: reports, key: key}
+}
+
+// Delete purges the tenant's data from every target and stores the signed
+// report. A target failing does not stop the others; the report is then
+// partial and lists the errors.
+func (s *Service) Delete(ctx context.Context, req Request) (*Report, error) {
+	if req.TenantID == "" || req.Confirm != req.TenantID {
+		return nil, fmt.Errorf("%w: confirm must repeat the tenant ID", ErrInvalidRequest)
+	}
+	if req.RequestedBy == "" {
+		return nil, fmt.Errorf("%w: requested_by is required", ErrInvalidRequest)
+	}
+
+	report := &Report{
+		ID:          fmt.Sprintf("DEL-%d", time.Now().UnixNano()),
+		TenantID:    req.TenantID,
+		RequestedBy: req.RequestedBy,
+		Reason:      req.Reason,
+		Status:      StatusCompleted,
+		StartedAt:   time.Now().UTC(),
+		Targets:     []TargetResult{},
+	}
+	scope := &Scope{TenantID: req.TenantID}
+	for _, t := range s.targets {
+		deleted, err := t.Purge(ctx, scope)
+		result := TargetResult{Target: t.Name(), Deleted: deleted}
+		if result.Deleted == nil {
+			result.Deleted = map[string]int{}
+		}
+		if err != nil {
+			result.Error = err.Error()
+			report.Status = StatusPartial
+			logger.WithComponent("erasure").Error("Tenant deletion target failed",
+				zap.String("tenant_id", req.TenantID),
+				zap.String("target", t.Name()),
+				zap.Error(err))
+		}
+		report.Targets = append(report.Targets, result)
+	}
+	report.CapsuleIDs = append([]string{}, scope.CapsuleIDs...)
+	report.IntentIDs = append([]string{}, scope.IntentIDs...)
+	report.CompletedAt = time.Now().UTC()
+	report.Signature = s.sign(report)
+
+	err := s.reports.Save(ctx, report)
+	s.record(ctx, report, err)
+	if err != nil {
+		return report, fmt.Errorf("failed to store deletion report: %w", err)
+	}
+	return report, nil
+}
+
+// Get returns a stored deletion report
+func (s *Service) Get(ctx context.Context, id string) (*Report, error) {
+	return s.reports.Get(ctx, id)
+}
+
+// List returns a tenant's deletion reports, newest first
+func (s *Service) List(ctx context.Context, tenantID string) ([]*Report, error) {
+	return s.reports.List(ctx, tenantID)
+}
+
+// Verify reports whether a report's signature is valid
+func (s *Service) Verify(report *Report) bool {
+	want, err := hex.DecodeString(report.Signature)
+	if err != nil {
+		return false
+	}
+	got, _ := hex.DecodeString(s.sign(report))
+	return hmac.Equal(got, want)
+}
+
+// sign computes the signature over the report's JSON without the signature
+func (s *Service) sign(report *Report) string {
+	unsigned := *report
+	unsigned.Signature = ""
+	data, _ := json.Marshal(unsigned)
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Service) record(ctx context.Context, report *Report, err error) {
+	entry := audit.Entry{
+		Action:       audit.ActionTenantDelete,
+		Outcome:      audit.OutcomeSuccess,
+		ResourceType: "tenant",
+		ResourceIDs:  []string{report.TenantID},
+		Details: map[string]interface{}{
+			"report_id": report.ID,
+			"status":    report.Status,
+			"intents":   len(report.IntentIDs),
+			"capsules":  len(report.CapsuleIDs),
+			"signature": report.Signature,
+		},
+	}
+	if err == nil && report.Status != StatusCompleted {
+		err = errors.New("some targets failed, see the deletion report")
+	}
+	if err != nil {
+		entry.Outcome = audit.OutcomeFailure
+		entry.Error = err.Error()
+	}
+	audit.Record(audit.WithActor(audit.WithTenant(ctx, report.TenantID), report.RequestedBy), entry)
+}
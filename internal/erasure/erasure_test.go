@@ -0,0 +1,152 @@
+package erasure
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"QLP/internal/storage"
+	"QLP/internal/validation"
+)
+
+// fakeTarget records the scope it saw and returns fixed results
+type fakeTarget struct {
+	name     string
+	deleted  map[string]int
+	capsules []string
+	err      error
+	seen     []string
+}
+
+func (f *fakeTarget) Name() string { return f.name }
+
+func (f *fakeTarget) Purge(_ context.Context, scope *Scope) (map[string]int, error) {
+	f.seen = append([]string{}, scope.CapsuleIDs...)
+	scope.AddCapsules(f.capsules...)
+	return f.deleted, f.err
+}
+
+func TestDeleteRequiresConfirmation(t *testing.T) {
+	svc := NewService(NewMemoryReportStore(), []byte("key"))
+	for _, req := range []Request{
+		{TenantID: "acme", RequestedBy: "dpo@acme.example"},
+		{TenantID: "acme", Confirm: "other", RequestedBy: "dpo@acme.example"},
+		{TenantID: "acme", Confirm: "acme"},
+		{Confirm: "", RequestedBy: "dpo@acme.example"},
+	} {
+		if _, err := svc.Delete(context.Background(), req); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("Delete(%+v) error = %v, want ErrInvalidRequest", req, err)
+		}
+	}
+}
+
+func TestDeleteSignsAndStoresReport(t *testing.T) {
+	first := &fakeTarget{name: "artifacts", deleted: map[string]int{"artifacts": 3}, capsules: []string{"QD-2", "QD-1"}}
+	second := &fakeTarget{name: "postgres", deleted: map[string]int{"intents": 2}}
+	reports := NewMemoryReportStore()
+	svc := NewService(reports, []byte("key"), first, second)
+	ctx := context.Background()
+
+	report, err := svc.Delete(ctx, Request{TenantID: "acme", Confirm: "acme", RequestedBy: "dpo@acme.example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Status != StatusCompleted || len(report.Targets) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	if strings.Join(second.seen, ",") != "QD-1,QD-2" {
+		t.Errorf("second target saw capsules %v, want those the first found", second.seen)
+	}
+	if !svc.Verify(report) {
+		t.Error("fresh report does not verify")
+	}
+
+	stored, err := svc.Get(ctx, report.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !svc.Verify(stored) {
+		t.Error("stored report does not verify")
+	}
+	stored.Targets[0].Deleted["artifacts"] = 0
+	if svc.Verify(stored) {
+		t.Error("tampered report verifies")
+	}
+	if NewService(reports, []byte("other")).Verify(report) {
+		t.Error("report verifies with another key")
+	}
+
+	list, err := svc.List(ctx, "acme")
+	if err != nil || len(list) != 1 {
+		t.Fatalf("List = %v, %v", list, err)
+	}
+	if _, err := svc.Get(ctx, "DEL-0"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get unknown error = %v", err)
+	}
+}
+
+func TestDeleteContinuesPastFailures(t *testing.T) {
+	failing := &fakeTarget{name: "postgres", err: errors.New("connection refused")}
+	after := &fakeTarget{name: "schedules", deleted: map[string]int{"schedules": 1}}
+	svc := NewService(NewMemoryReportStore(), []byte("key"), failing, after)
+
+	report, err := svc.Delete(context.Background(), Request{TenantID: "acme", Confirm: "acme", RequestedBy: "ops"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Status != StatusPartial {
+		t.Errorf("status = %s, want partial", report.Status)
+	}
+	if report.Targets[0].Error == "" || report.Targets[1].Deleted["schedules"] != 1 {
+		t.Errorf("targets = %+v", report.Targets)
+	}
+}
+
+func TestArtifactTargetDeletesOnlyTenant(t *testing.T) {
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, tenant := range []string{"acme", "acme", "globex"} {
+		if _, err := store.Put(ctx, tenant, "QD-"+tenant, "capsule.zip", strings.NewReader(tenant)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scope := &Scope{TenantID: "acme"}
+	deleted, err := ArtifactTarget{Store: store}.Purge(ctx, scope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted["artifacts"] != 1 || strings.Join(scope.CapsuleIDs, ",") != "QD-acme" {
+		t.Errorf("deleted = %v, capsules = %v", deleted, scope.CapsuleIDs)
+	}
+	left, _ := store.ListAll(ctx)
+	if len(left) != 1 || left[0].TenantID != "globex" {
+		t.Errorf("left = %+v", left)
+	}
+}
+
+func TestRuleTargetKeepsSharedRules(t *testing.T) {
+	rules := validation.NewMemoryRuleStore()
+	ctx := context.Background()
+	rules.Put(ctx, validation.Rule{ID: "R-1", TenantID: "acme"})
+	rules.Put(ctx, validation.Rule{ID: "R-2"})
+	rules.Put(ctx, validation.Rule{ID: "R-3", TenantID: "globex"})
+
+	deleted, err := RuleTarget{Store: rules}.Purge(ctx, &Scope{TenantID: "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted["rules"] != 1 {
+		t.Errorf("deleted = %v", deleted)
+	}
+	if _, err := rules.Get(ctx, "R-2"); err != nil {
+		t.Errorf("shared rule deleted: %v", err)
+	}
+	if _, err := rules.Get(ctx, "R-3"); err != nil {
+		t.Errorf("other tenant's rule deleted: %v", err)
+	}
+}
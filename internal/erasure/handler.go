@@ -0,0 +1,104 @@
+package erasure
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"QLP/internal/audit"
+	"QLP/internal/tenants"
+)
+
+// Routes returns the tenant deletion endpoints. Every request must carry
+// "Authorization: Bearer <adminToken>"; reports can be narrowed to a tenant
+// with the tenant query parameter.
+//
+//	POST /tenants/{tenant}/deletion  deletes all of a tenant's data; the body must confirm the tenant ID
+//	GET  /tenants/{tenant}/deletions lists the tenant's deletion reports
+//	GET  /deletions/{id}             returns a signed deletion report
+//	POST /deletions/verify           checks the signature of a report handed back
+func Routes(svc *Service, adminToken string) map[string]http.Handler {
+	routes := map[string]http.Handler{
+		"POST /tenants/{tenant}/deletion": deleteHandler(svc),
+		"GET /tenants/{tenant}/deletions": listHandler(svc),
+		"GET /deletions/{id}":             getHandler(svc),
+		"POST /deletions/verify":          verifyHandler(svc),
+	}
+	for pattern, h := range routes {
+		routes[pattern] = tenants.AdminOnly(adminToken, h)
+	}
+	return routes
+}
+
+func deleteHandler(svc *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "request body must be JSON with confirm and requested_by", http.StatusBadRequest)
+			return
+		}
+		req.TenantID = r.PathValue("tenant")
+		report, err := svc.Delete(r.Context(), req)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrInvalidRequest) {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	})
+}
+
+func listHandler(svc *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reports, err := svc.List(r.Context(), r.PathValue("tenant"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if reports == nil {
+			reports = []*Report{}
+		}
+		writeJSON(w, http.StatusOK, reports)
+	})
+}
+
+func getHandler(svc *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report, err := svc.Get(r.Context(), r.PathValue("id"))
+		if err == nil && !audit.TenantAllowed(r, report.TenantID) {
+			err = ErrNotFound
+		}
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	})
+}
+
+func verifyHandler(svc *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report Report
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil || report.ID == "" {
+			http.Error(w, "request body must be a deletion report", http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"id":    report.ID,
+			"valid": svc.Verify(&report),
+		})
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
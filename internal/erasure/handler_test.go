@@ -0,0 +1,61 @@
+package erasure
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRoutesRequireTheAdminToken(t *testing.T) {
+	target := &fakeTarget{name: "artifacts", deleted: map[string]int{"artifacts": 1}}
+	svc := NewService(NewMemoryReportStore(), []byte("key"), target)
+	mux := http.NewServeMux()
+	for pattern, h := range Routes(svc, "admin-secret") {
+		mux.Handle(pattern, h)
+	}
+	do := func(token, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	body := `{"confirm":"acme","requested_by":"dpo@acme.example"}`
+	for _, token := range []string{"", "qlp_tenant_key"} {
+		if rec := do(token, http.MethodPost, "/tenants/acme/deletion", body); rec.Code != http.StatusUnauthorized {
+			t.Errorf("deletion with token %q: %d", token, rec.Code)
+		}
+	}
+	if target.seen != nil {
+		t.Fatal("unauthenticated deletion reached the targets")
+	}
+
+	rec := do("admin-secret", http.MethodPost, "/tenants/acme/deletion", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("deletion: %d %s", rec.Code, rec.Body)
+	}
+	var report Report
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if rec := do("admin-secret", http.MethodGet, "/deletions/"+report.ID+"?tenant=globex", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("report of another tenant: %d", rec.Code)
+	}
+	if rec := do("admin-secret", http.MethodGet, "/deletions/"+report.ID+"?tenant=acme", ""); rec.Code != http.StatusOK {
+		t.Errorf("report: %d", rec.Code)
+	}
+
+	// Without a token nothing is served
+	for pattern, h := range Routes(svc, "") {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/deletions/"+report.ID, nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s without an admin token: %d", pattern, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package erasure
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"QLP/internal/database"
+	"QLP/internal/logger"
+)
+
+// ReportStore keeps deletion reports. They outlive the data they describe,
+// so they hold IDs and counts only.
+type ReportStore interface {
+	Save(ctx context.Context, report *Report) error
+	Get(ctx context.Context, id string) (*Report, error)
+	// List returns a tenant's reports, newest first
+	List(ctx context.Context, tenantID string) ([]*Report, error)
+}
+
+// MemoryReportStore keeps reports in memory, for tests and deployments
+// without a database
+type MemoryReportStore struct {
+	mu      sync.Mutex
+	reports map[string]Report
+}
+
+// NewMemoryReportStore creates an empty in-memory report store
+func NewMemoryReportStore() *MemoryReportStore {
+	return &MemoryReportStore{reports: make(map[string]Report)}
+}
+
+func (s *MemoryReportStore) Save(_ context.Context, report *Report) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reports[report.ID] = *report
+	return nil
+}
+
+func (s *MemoryReportStore) Get(_ context.Context, id string) (*Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	report, ok := s.reports[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &report, nil
+}
+
+func (s *MemoryReportStore) List(_ context.Context, tenantID string) ([]*Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var reports []*Report
+	for _, report := range s.reports {
+		if report.TenantID == tenantID {
+			r := report
+			reports = append(reports, &r)
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].StartedAt.After(reports[j].StartedAt) })
+	return reports, nil
+}
+
+// PostgresReportStore keeps reports in the tenant_deletions table
+type PostgresReportStore struct {
+	db *sql.DB
+}
+
+// NewPostgresReportStore creates the tenant_deletions table if needed
+func NewPostgresReportStore(db *sql.DB) (*PostgresReportStore, error) {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS tenant_deletions (
+			id VARCHAR(50) PRIMARY KEY,
+			tenant_id VARCHAR(100) NOT NULL,
+			report JSONB NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_tenant_deletions_tenant ON tenant_deletions(tenant_id, created_at DESC);
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tenant_deletions table: %w", err)
+	}
+	return &PostgresReportStore{db: db}, nil
+}
+
+func (s *PostgresReportStore) Save(ctx context.Context, report *Report) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode deletion report: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO tenant_deletions (id, tenant_id, report, created_at) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (id) DO UPDATE SET report = $3`,
+		report.ID, report.TenantID, data, report.StartedAt); err != nil {
+		return fmt.Errorf("failed to save deletion report: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresReportStore) Get(ctx context.Context, id string) (*Report, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, `SELECT report FROM tenant_deletions WHERE id = $1`, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read deletion report: %w", err)
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to decode deletion report: %w", err)
+	}
+	return &report, nil
+}
+
+func (s *PostgresReportStore) List(ctx context.Context, tenantID string) ([]*Report, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT report FROM tenant_deletions WHERE tenant_id = $1 ORDER BY created_at DESC`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deletion reports: %w", err)
+	}
+	defer rows.Close()
+
+	var reports []*Report
+	for rows.Next() {
+		var data []byte
+		var report Report
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to read deletion report: %w", err)
+		}
+		if err := json.Unmarshal(data, &report); err != nil {
+			return nil, fmt.Errorf("failed to decode deletion report: %w", err)
+		}
+		reports = append(reports, &report)
+	}
+	return reports, rows.Err()
+}
+
+// NewReportStoreFromEnv keeps reports in the database at DATABASE_URL,
+// falling back to memory when it is unavailable
+func NewReportStoreFromEnv() (ReportStore, error) {
+	db, err := database.New()
+	if err != nil {
+		return nil, err
+	}
+	if !db.IsConnected() {
+		logger.WithComponent("erasure").Warn("Database unavailable, deletion reports are kept in memory")
+		return NewMemoryReportStore(), nil
+	}
+	return NewPostgresReportStore(db.GetConnection())
+}
@@ -0,0 +1,206 @@
+package erasure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"QLP/internal/scheduler"
+	"QLP/internal/storage"
+	"QLP/internal/validation"
+	"QLP/internal/workspace"
+	"github.com/lib/pq"
+)
+
+// ArtifactTarget deletes a tenant's stored capsules and reports. With
+// encryption at rest it then destroys the tenant's data keys, so copies the
+// deletion cannot reach, such as backups, become unreadable too.
+type ArtifactTarget struct {
+	Store storage.ArtifactStore
+}
+
+func (t ArtifactTarget) Name() string { return "artifacts" }
+
+func (t ArtifactTarget) Purge(ctx context.Context, scope *Scope) (map[string]int, error) {
+	deleted := map[string]int{}
+	artifacts, err := t.Store.ListAll(ctx)
+	if err != nil {
+		return deleted, err
+	}
+	var errs []error
+	for _, a := range artifacts {
+		if a.TenantID != scope.TenantID {
+			continue
+		}
+		scope.AddCapsules(a.CapsuleID)
+		if err := t.Store.Delete(ctx, a.Key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			errs = append(errs, fmt.Errorf("%s: %w", a.Key, err))
+			continue
+		}
+		deleted["artifacts"]++
+	}
+	if encrypted, ok := t.Store.(*storage.EncryptedStore); ok && len(errs) == 0 {
+		n, err := encrypted.Keys().Destroy(scope.TenantID)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		deleted["encryption_keys"] = n
+	}
+	return deleted, errors.Join(errs...)
+}
+
+// PostgresTarget deletes the tenant's intents with their tasks, validation
+// results, capsules and embeddings, and strips the tenant's audit entries
+// of actors and details. Intents are the tenant's when its audit entries or
+// capsules name them, or their metadata carries its tenant_id.
+type PostgresTarget struct {
+	DB *sql.DB
+}
+
+func (t PostgresTarget) Name() string { return "postgres" }
+
+func (t PostgresTarget) Purge(ctx context.Context, scope *Scope) (map[string]int, error) {
+	deleted := map[string]int{}
+	for _, query := range []string{
+		`SELECT DISTINCT intent_id FROM audit_log WHERE tenant_id = $1 AND COALESCE(intent_id, '') <> ''`,
+		`SELECT id FROM intents WHERE metadata->>'tenant_id' = $1`,
+	} {
+		if err := t.collect(ctx, scope, query, scope.TenantID); err != nil {
+			return deleted, err
+		}
+	}
+	if err := t.collect(ctx, scope, `SELECT intent_id FROM quantum_capsules WHERE id = ANY($1) AND intent_id IS NOT NULL`,
+		pq.Array(scope.CapsuleIDs)); err != nil {
+		return deleted, err
+	}
+
+	intents, capsules := pq.Array(scope.IntentIDs), pq.Array(scope.CapsuleIDs)
+	tasks := `SELECT id FROM tasks WHERE intent_id = ANY($1)`
+	// Dependents without ON DELETE CASCADE go first; the rest cascade from intents
+	for _, step := range []struct {
+		kind  string
+		query string
+		args  []interface{}
+	}{
+		{"embeddings", `DELETE FROM generation_memory WHERE intent_id = ANY($1)`, []interface{}{intents}},
+		{"metrics", `DELETE FROM performance_metrics WHERE intent_id = ANY($1) OR task_id IN (` + tasks + `)`, []interface{}{intents}},
+		{"agents", `DELETE FROM agents WHERE task_id IN (` + tasks + `)`, []interface{}{intents}},
+		{"capsules", `DELETE FROM quantum_capsules WHERE intent_id = ANY($1) OR id = ANY($2)`, []interface{}{intents, capsules}},
+		{"intents", `DELETE FROM intents WHERE id = ANY($1)`, []interface{}{intents}},
+		{"audit_entries_redacted", `UPDATE audit_log SET actor = 'redacted', details = '{}', error = NULL, intent_id = NULL
+			WHERE tenant_id = $1 AND actor <> 'redacted'`, []interface{}{scope.TenantID}},
+	} {
+		res, err := t.DB.ExecContext(ctx, step.query, step.args...)
+		if missingTable(err) {
+			continue
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete %s: %w", step.kind, err)
+		}
+		if n, err := res.RowsAffected(); err == nil {
+			deleted[step.kind] = int(n)
+		}
+	}
+	return deleted, nil
+}
+
+// collect adds the intent IDs a query returns to the scope
+func (t PostgresTarget) collect(ctx context.Context, scope *Scope, query string, arg interface{}) error {
+	rows, err := t.DB.QueryContext(ctx, query, arg)
+	if missingTable(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find tenant intents: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return fmt.Errorf("failed to find tenant intents: %w", err)
+		}
+		scope.AddIntents(id)
+	}
+	return rows.Err()
+}
+
+// missingTable reports whether a query failed because an optional table was
+// never created
+func missingTable(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "42P01"
+}
+
+// RuleTarget deletes the tenant's custom validation rules; shared rules stay
+type RuleTarget struct {
+	Store validation.RuleStore
+}
+
+func (t RuleTarget) Name() string { return "validation_rules" }
+
+func (t RuleTarget) Purge(ctx context.Context, scope *Scope) (map[string]int, error) {
+	deleted := map[string]int{}
+	rules, err := t.Store.List(ctx, scope.TenantID)
+	if err != nil {
+		return deleted, err
+	}
+	for _, rule := range rules {
+		if rule.TenantID != scope.TenantID {
+			continue
+		}
+		if err := t.Store.Delete(ctx, rule.ID); err != nil && !errors.Is(err, validation.ErrRuleNotFound) {
+			return deleted, err
+		}
+		deleted["rules"]++
+	}
+	return deleted, nil
+}
+
+// ScheduleTarget deletes the tenant's scheduled generations, so none runs
+// for it again
+type ScheduleTarget struct {
+	Scheduler *scheduler.Scheduler
+}
+
+func (t ScheduleTarget) Name() string { return "schedules" }
+
+func (t ScheduleTarget) Purge(_ context.Context, scope *Scope) (map[string]int, error) {
+	deleted := map[string]int{}
+	for _, job := range t.Scheduler.List(scope.TenantID) {
+		if err := t.Scheduler.Delete(job.ID); err != nil && !errors.Is(err, scheduler.ErrNotFound) {
+			return deleted, err
+		}
+		deleted["schedules"]++
+	}
+	return deleted, nil
+}
+
+// WorkspaceTarget deletes the tenant's multi-service workspaces and the
+// projects composed in them
+type WorkspaceTarget struct {
+	Store *workspace.Store
+}
+
+func (t WorkspaceTarget) Name() string { return "workspaces" }
+
+func (t WorkspaceTarget) Purge(_ context.Context, scope *Scope) (map[string]int, error) {
+	deleted := map[string]int{}
+	workspaces, err := t.Store.List()
+	if err != nil {
+		return deleted, err
+	}
+	for _, ws := range workspaces {
+		if ws.TenantID != scope.TenantID {
+			continue
+		}
+		for _, c := range ws.Capsules {
+			scope.AddCapsules(c.CapsuleID)
+		}
+		if err := t.Store.Delete(ws.ID); err != nil && !errors.Is(err, workspace.ErrNotFound) {
+			return deleted, err
+		}
+		deleted["workspaces"]++
+	}
+	return deleted, nil
+}
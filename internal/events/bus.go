@@ -2,10 +2,16 @@ package events
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
 	"time"
 
 	"QLP/internal/logger"
+	"QLP/internal/metrics"
+	"QLP/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
@@ -15,6 +21,7 @@ type Event struct {
 	Payload   map[string]interface{} `json:"payload"`
 	Timestamp time.Time              `json:"timestamp"`
 	Source    string                 `json:"source"`
+	Headers   map[string]string      `json:"headers,omitempty"`
 }
 
 type EventType string
@@ -26,64 +33,372 @@ const (
 	EventTaskFailed    EventType = "task.failed"
 	EventAgentSpawned  EventType = "agent.spawned"
 	EventAgentStopped  EventType = "agent.stopped"
+
+	// EventArtifactCreated carries an agent's output and its self-review
+	EventArtifactCreated EventType = "artifact.created"
+
+	EventIntentCreated   EventType = "intent.created"
+	EventIntentCompleted EventType = "intent.completed"
+	EventIntentFailed    EventType = "intent.failed"
 )
 
 type Handler func(ctx context.Context, event Event) error
 
+type subscription struct {
+	name    string
+	group   string // consumer group sharing the work; empty for a standalone subscriber
+	handler Handler
+}
+
 type EventBus struct {
-	handlers map[EventType][]Handler
-	mu       sync.RWMutex
-	events   chan Event
+	handlers   map[EventType][]subscription
+	mu         sync.RWMutex
+	events     chan Event
+	dedupStore DedupStore
+	dedupTTL   time.Duration
+	codec      *Codec
+	partitions int
+	queues     []chan Event // per-partition queues, set by Start
+	journal    *Journal
+	enqueueMu  sync.Mutex // serializes senders so an event's chunks are queued together
 }
 
 func NewEventBus() *EventBus {
 	return &EventBus{
-		handlers: make(map[EventType][]Handler),
-		events:   make(chan Event, 1000),
+		handlers:   make(map[EventType][]subscription),
+		events:     make(chan Event, 1000),
+		dedupStore: NewMemoryDedupStore(),
+		dedupTTL:   DefaultDedupTTL,
+		partitions: DefaultPartitions,
+	}
+}
+
+// SetDedupStore replaces the store used to skip already-processed events,
+// e.g. a PostgresDedupStore shared by several processes.
+func (eb *EventBus) SetDedupStore(store DedupStore, ttl time.Duration) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	eb.dedupStore = store
+	if ttl > 0 {
+		eb.dedupTTL = ttl
 	}
 }
 
+// SetCodec compresses and chunks large events on publish and reassembles
+// them before handlers run, as a broker transport with message limits requires
+func (eb *EventBus) SetCodec(codec *Codec) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	eb.codec = codec
+}
+
+// SetPartitions sets how many ordered partitions keyed events are spread
+// over; call before Start
+func (eb *EventBus) SetPartitions(n int) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	if n > 0 {
+		eb.partitions = n
+	}
+}
+
+// SetJournal records published events in journal until their handlers have
+// run, and redelivers the events it still holds when the bus starts; call
+// before Start
+func (eb *EventBus) SetJournal(journal *Journal) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	eb.journal = journal
+}
+
+// Subscribe registers a handler. Its dedup identity is derived from the
+// registration order, so it is only unique within one process; use
+// SubscribeNamed when processes share a dedup store or the identity must be
+// stable across restarts.
 func (eb *EventBus) Subscribe(eventType EventType, handler Handler) {
 	eb.mu.Lock()
 	defer eb.mu.Unlock()
 
-	eb.handlers[eventType] = append(eb.handlers[eventType], handler)
+	name := fmt.Sprintf("%s#%d", eventType, len(eb.handlers[eventType]))
+	eb.handlers[eventType] = append(eb.handlers[eventType], subscription{name: name, handler: handler})
 }
 
+// SubscribeNamed registers a handler under a stable subscriber name. Each
+// subscriber processes a given event at most once within the dedup TTL.
+func (eb *EventBus) SubscribeNamed(eventType EventType, name string, handler Handler) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	eb.handlers[eventType] = append(eb.handlers[eventType], subscription{name: name, handler: handler})
+}
+
+// SubscribeGroup registers member of a consumer group. Each event is handled
+// by one member of the group, chosen by the event's partition key, so replicas
+// subscribed under the same group share the work and every intent stays with
+// the same member.
+func (eb *EventBus) SubscribeGroup(eventType EventType, group, member string, handler Handler) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	eb.handlers[eventType] = append(eb.handlers[eventType], subscription{name: group + "/" + member, group: group, handler: handler})
+}
+
+// ErrBusFull is returned by TryPublish when the queue has no room for an event
+var ErrBusFull = errors.New("event bus full")
+
+// Publish queues an event for its subscribers, dropping it with a warning
+// when the queue is full
 func (eb *EventBus) Publish(event Event) {
-	select {
-	case eb.events <- event:
-	default:
+	if err := eb.TryPublish(event); err != nil {
 		logger.WithComponent("events").Warn("Event bus full, dropping event",
 			zap.String("event_id", event.ID))
 	}
 }
 
+// TryPublish queues an event for its subscribers, returning ErrBusFull when
+// the queue has no room for it and all of its chunks, so the caller can
+// publish it again later. A refused event queues none of its chunks.
+func (eb *EventBus) TryPublish(event Event) error {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	if event.Headers[HeaderIdempotencyKey] == "" {
+		headers := make(map[string]string, len(event.Headers)+1)
+		for k, v := range event.Headers {
+			headers[k] = v
+		}
+		headers[HeaderIdempotencyKey] = idempotencyKey(event)
+		event.Headers = headers
+	}
+
+	eb.mu.RLock()
+	journal := eb.journal
+	eb.mu.RUnlock()
+	if journal != nil {
+		if err := journal.Append(event); err != nil {
+			logger.WithComponent("events").Warn("Event not journaled, it is lost if the process stops before it is handled",
+				zap.String("event_id", event.ID),
+				zap.Error(err))
+		}
+	}
+
+	queued := eb.enqueue(eb.encode(event))
+	metrics.SetEventQueueDepth(eb.QueueDepth())
+	if !queued {
+		return ErrBusFull
+	}
+	return nil
+}
+
+// enqueue queues every one of encoded, or none when the queue has no room
+// for them all. The dispatcher only takes from the queue, so the room seen
+// under enqueueMu cannot shrink before the sends.
+func (eb *EventBus) enqueue(encoded []Event) bool {
+	eb.enqueueMu.Lock()
+	defer eb.enqueueMu.Unlock()
+	if cap(eb.events)-len(eb.events) < len(encoded) {
+		return false
+	}
+	for _, e := range encoded {
+		eb.events <- e
+	}
+	return true
+}
+
+// encode splits event into the events to queue, using the codec when set
+func (eb *EventBus) encode(event Event) []Event {
+	eb.mu.RLock()
+	codec := eb.codec
+	eb.mu.RUnlock()
+
+	if codec == nil {
+		return []Event{event}
+	}
+	encoded, err := codec.Encode(event)
+	if err != nil {
+		logger.WithComponent("events").Warn("Publishing event unencoded",
+			zap.String("event_id", event.ID),
+			zap.Error(err))
+		return []Event{event}
+	}
+	return encoded
+}
+
+// QueueDepth returns the number of events published but not yet dispatched
+func (eb *EventBus) QueueDepth() int {
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+
+	depth := len(eb.events)
+	for _, q := range eb.queues {
+		depth += len(q)
+	}
+	return depth
+}
+
+// PublishWithContext publishes an event carrying the trace context from ctx
+// in its headers so handlers continue the same trace.
+func (eb *EventBus) PublishWithContext(ctx context.Context, event Event) {
+	event.Headers = tracing.InjectHeaders(ctx, event.Headers)
+	eb.Publish(event)
+}
+
+// Start dispatches events until ctx is cancelled. Events with a partition key
+// are handled in publish order per key on one of the partition workers; other
+// events are handled as soon as they arrive.
 func (eb *EventBus) Start(ctx context.Context) {
+	eb.mu.Lock()
+	partitions := make([]chan Event, eb.partitions)
+	for i := range partitions {
+		partitions[i] = make(chan Event, 100)
+	}
+	eb.queues = partitions
+	codec := eb.codec
+	journal := eb.journal
+	eb.mu.Unlock()
+
+	// done marks an event whose handlers have returned as handled in the journal
+	done := func(event Event) {
+		if journal == nil {
+			return
+		}
+		if err := journal.Done(idempotencyKey(event)); err != nil {
+			logger.WithComponent("events").Warn("Handled event not journaled, it is redelivered on restart",
+				zap.String("event_id", event.ID),
+				zap.Error(err))
+		}
+	}
+
+	for i := range partitions {
+		go func(ch <-chan Event) {
+			for {
+				select {
+				case event := <-ch:
+					eb.handleEvent(ctx, event).Wait()
+					done(event)
+					metrics.SetEventQueueDepth(eb.QueueDepth())
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(partitions[i])
+	}
+
 	go func() {
 		for {
 			select {
 			case event := <-eb.events:
-				eb.handleEvent(ctx, event)
+				if codec != nil {
+					decoded, complete, err := codec.Decode(event)
+					if err != nil {
+						logger.WithComponent("events").Error("Dropping undecodable event",
+							zap.String("event_id", event.ID),
+							zap.Error(err))
+						continue
+					}
+					if !complete {
+						continue
+					}
+					event = decoded
+				}
+
+				key := PartitionKey(event)
+				if key == "" {
+					handled := eb.handleEvent(ctx, event)
+					if journal != nil {
+						go func(event Event) {
+							handled.Wait()
+							done(event)
+						}(event)
+					}
+					metrics.SetEventQueueDepth(eb.QueueDepth())
+					continue
+				}
+				select {
+				case partitions[partitionFor(key, len(partitions))] <- event:
+				case <-ctx.Done():
+					return
+				}
 			case <-ctx.Done():
 				return
 			}
 		}
 	}()
+
+	if journal != nil {
+		// Redeliver what was queued when the process last stopped, waiting
+		// rather than dropping when there is more than the queue holds
+		go func() {
+			retry := time.NewTicker(10 * time.Millisecond)
+			defer retry.Stop()
+			for _, event := range journal.Pending() {
+				for encoded := eb.encode(event); !eb.enqueue(encoded); {
+					select {
+					case <-retry.C:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+	}
 }
 
-func (eb *EventBus) handleEvent(ctx context.Context, event Event) {
+// handleEvent runs the event's handlers concurrently; the returned group
+// completes when they have all returned
+func (eb *EventBus) handleEvent(ctx context.Context, event Event) *sync.WaitGroup {
 	eb.mu.RLock()
-	handlers := eb.handlers[event.Type]
+	subs := selectGroupMembers(eb.handlers[event.Type], PartitionKey(event), event.ID)
+	store := eb.dedupStore
+	ttl := eb.dedupTTL
 	eb.mu.RUnlock()
 
-	for _, handler := range handlers {
-		go func(h Handler) {
-			if err := h(ctx, event); err != nil {
+	key := idempotencyKey(event)
+
+	var wg sync.WaitGroup
+	for _, sub := range subs {
+		wg.Add(1)
+		go func(sub subscription) {
+			defer wg.Done()
+			// Members of a group share one claim, so a redelivery that lands on
+			// another member is still recognised as a duplicate
+			owner := sub.name
+			if sub.group != "" {
+				owner = sub.group
+			}
+			dedupKey := owner + ":" + key
+			if store != nil {
+				first, err := store.Claim(ctx, dedupKey, ttl)
+				if err != nil {
+					// Prefer at-least-once delivery over dropping the event
+					logger.WithComponent("events").Warn("Dedup store unavailable, processing event anyway",
+						zap.String("event_id", event.ID),
+						zap.Error(err))
+				} else if !first {
+					logger.WithComponent("events").Debug("Skipping duplicate event",
+						zap.String("event_id", event.ID),
+						zap.String("subscriber", sub.name))
+					return
+				}
+			}
+
+			handlerCtx, span := tracing.StartSpan(tracing.ExtractHeaders(ctx, event.Headers), "event.handle "+string(event.Type),
+				attribute.String("event.id", event.ID),
+				attribute.String("event.source", event.Source))
+			err := sub.handler(handlerCtx, event)
+			tracing.EndSpan(span, err)
+			if err != nil {
 				logger.WithComponent("events").Error("Handler error",
 					zap.String("event_id", event.ID),
+					zap.String("subscriber", sub.name),
 					zap.Error(err))
+				// Allow a redelivery to retry the failed handler
+				if store != nil {
+					store.Release(ctx, dedupKey)
+				}
 			}
-		}(handler)
+		}(sub)
 	}
+	return &wg
 }
@@ -0,0 +1,255 @@
+package events
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"QLP/internal/config"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Headers set on events whose payload was compressed or split by a Codec
+const (
+	HeaderEncoding   = "content-encoding" // "zstd" when compressed
+	HeaderChecksum   = "payload-sha256"   // SHA-256 of the original event JSON
+	HeaderChunkID    = "chunk-id"         // ID of the original event
+	HeaderChunkIndex = "chunk-index"
+	HeaderChunkCount = "chunk-count"
+)
+
+// ErrPayloadIntegrity is returned when a reassembled event does not match its checksum
+var ErrPayloadIntegrity = errors.New("event payload integrity check failed")
+
+// CodecConfig sets when an event type is compressed and chunked
+type CodecConfig struct {
+	CompressThreshold int // compress events whose JSON exceeds this many bytes; 0 never compresses
+	ChunkSize         int // split encoded events into chunks of at most this many bytes; 0 never splits
+}
+
+// DefaultCodecConfig keeps chunks below Kafka's default 1 MiB message limit
+// once base64 and envelope overhead are added
+func DefaultCodecConfig() CodecConfig {
+	return CodecConfig{CompressThreshold: 64 << 10, ChunkSize: 512 << 10}
+}
+
+// Codec compresses and chunks large events before transport and reassembles
+// them on the consuming side. Small events pass through untouched.
+type Codec struct {
+	defaults CodecConfig
+	topics   map[EventType]CodecConfig
+	encoder  *zstd.Encoder
+	decoder  *zstd.Decoder
+
+	mu      sync.Mutex
+	pending map[string]*partialEvent
+	ttl     time.Duration
+}
+
+type partialEvent struct {
+	chunks   [][]byte
+	received int
+	first    time.Time
+}
+
+// NewCodec creates a codec with defaults and per-event-type overrides
+func NewCodec(defaults CodecConfig, topics map[EventType]CodecConfig) *Codec {
+	encoder, _ := zstd.NewWriter(nil)
+	decoder, _ := zstd.NewReader(nil)
+	return &Codec{
+		defaults: defaults,
+		topics:   topics,
+		encoder:  encoder,
+		decoder:  decoder,
+		pending:  make(map[string]*partialEvent),
+		ttl:      5 * time.Minute,
+	}
+}
+
+// CodecFromEnv reads QLP_EVENT_COMPRESS_THRESHOLD and QLP_EVENT_CHUNK_SIZE,
+// with per-type overrides in QLP_EVENT_TOPIC_LIMITS as
+// "task.completed=16384:262144,..." (compress threshold:chunk size)
+func CodecFromEnv() (*Codec, error) {
+	defaults := DefaultCodecConfig()
+	var err error
+	if defaults.CompressThreshold, err = strconv.Atoi(config.GetEnvOrDefault("QLP_EVENT_COMPRESS_THRESHOLD", strconv.Itoa(defaults.CompressThreshold))); err != nil {
+		return nil, fmt.Errorf("invalid QLP_EVENT_COMPRESS_THRESHOLD: %w", err)
+	}
+	if defaults.ChunkSize, err = strconv.Atoi(config.GetEnvOrDefault("QLP_EVENT_CHUNK_SIZE", strconv.Itoa(defaults.ChunkSize))); err != nil {
+		return nil, fmt.Errorf("invalid QLP_EVENT_CHUNK_SIZE: %w", err)
+	}
+
+	topics := make(map[EventType]CodecConfig)
+	for _, entry := range strings.Split(config.GetEnvOrDefault("QLP_EVENT_TOPIC_LIMITS", ""), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, limits, ok := strings.Cut(entry, "=")
+		compress, chunk, ok2 := strings.Cut(limits, ":")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("invalid QLP_EVENT_TOPIC_LIMITS entry %q", entry)
+		}
+		var cfg CodecConfig
+		if cfg.CompressThreshold, err = strconv.Atoi(compress); err != nil {
+			return nil, fmt.Errorf("invalid QLP_EVENT_TOPIC_LIMITS entry %q: %w", entry, err)
+		}
+		if cfg.ChunkSize, err = strconv.Atoi(chunk); err != nil {
+			return nil, fmt.Errorf("invalid QLP_EVENT_TOPIC_LIMITS entry %q: %w", entry, err)
+		}
+		topics[EventType(strings.TrimSpace(name))] = cfg
+	}
+	return NewCodec(defaults, topics), nil
+}
+
+func (c *Codec) configFor(eventType EventType) CodecConfig {
+	if cfg, ok := c.topics[eventType]; ok {
+		return cfg
+	}
+	return c.defaults
+}
+
+// Encode returns the events to transport in place of event: event itself
+// when it is small, otherwise one or more events carrying the compressed
+// original as base64 in Payload["data"]
+func (c *Codec) Encode(event Event) ([]Event, error) {
+	cfg := c.configFor(event.Type)
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event %s: %w", event.ID, err)
+	}
+
+	compress := cfg.CompressThreshold > 0 && len(raw) > cfg.CompressThreshold
+	split := cfg.ChunkSize > 0 && len(raw) > cfg.ChunkSize
+	if !compress && !split {
+		return []Event{event}, nil
+	}
+
+	sum := sha256.Sum256(raw)
+	data := raw
+	encoding := "identity"
+	if compress {
+		data = c.encoder.EncodeAll(raw, nil)
+		encoding = "zstd"
+	}
+
+	size := cfg.ChunkSize
+	if size <= 0 || len(data) <= size {
+		size = len(data)
+	}
+	count := (len(data) + size - 1) / size
+	out := make([]Event, 0, count)
+	for i := 0; i < count; i++ {
+		chunk := data[i*size : min((i+1)*size, len(data))]
+		id := event.ID
+		if count > 1 {
+			id = fmt.Sprintf("%s#%d", event.ID, i)
+		}
+		out = append(out, Event{
+			ID:        id,
+			Type:      event.Type,
+			Timestamp: event.Timestamp,
+			Source:    event.Source,
+			Payload:   map[string]interface{}{"data": base64.StdEncoding.EncodeToString(chunk)},
+			Headers: map[string]string{
+				HeaderEncoding:       encoding,
+				HeaderChecksum:       hex.EncodeToString(sum[:]),
+				HeaderChunkID:        event.ID,
+				HeaderChunkIndex:     strconv.Itoa(i),
+				HeaderChunkCount:     strconv.Itoa(count),
+				HeaderIdempotencyKey: event.Headers[HeaderIdempotencyKey] + "#" + strconv.Itoa(i),
+				// Chunks must share the original's partition to arrive in order
+				HeaderPartitionKey: PartitionKey(event),
+			},
+		})
+	}
+	return out, nil
+}
+
+// Decode reassembles transported events. It returns the original event and
+// true once every chunk of it has arrived; events that were not encoded are
+// returned as they are.
+func (c *Codec) Decode(event Event) (Event, bool, error) {
+	id, ok := event.Headers[HeaderChunkID]
+	if !ok {
+		return event, true, nil
+	}
+	index, err1 := strconv.Atoi(event.Headers[HeaderChunkIndex])
+	count, err2 := strconv.Atoi(event.Headers[HeaderChunkCount])
+	if err1 != nil || err2 != nil || count < 1 || index < 0 || index >= count {
+		return Event{}, false, fmt.Errorf("malformed chunk headers on event %s", event.ID)
+	}
+	encoded, _ := event.Payload["data"].(string)
+	chunk, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return Event{}, false, fmt.Errorf("malformed chunk data on event %s: %w", event.ID, err)
+	}
+
+	data, complete := c.collect(id, index, count, chunk)
+	if !complete {
+		return Event{}, false, nil
+	}
+
+	raw := data
+	switch event.Headers[HeaderEncoding] {
+	case "zstd":
+		if raw, err = c.decoder.DecodeAll(data, nil); err != nil {
+			return Event{}, false, fmt.Errorf("%w: %s: %v", ErrPayloadIntegrity, id, err)
+		}
+	case "identity":
+	default:
+		return Event{}, false, fmt.Errorf("unsupported encoding %q on event %s", event.Headers[HeaderEncoding], id)
+	}
+	sum := sha256.Sum256(raw)
+	if hex.EncodeToString(sum[:]) != event.Headers[HeaderChecksum] {
+		return Event{}, false, fmt.Errorf("%w: %s", ErrPayloadIntegrity, id)
+	}
+
+	var original Event
+	if err := json.Unmarshal(raw, &original); err != nil {
+		return Event{}, false, fmt.Errorf("%w: %s: %v", ErrPayloadIntegrity, id, err)
+	}
+	return original, true, nil
+}
+
+// collect buffers a chunk and returns the joined data once all have arrived.
+// Duplicated chunks are ignored and incomplete events expire after the TTL.
+func (c *Codec) collect(id string, index, count int, chunk []byte) ([]byte, bool) {
+	if count == 1 {
+		return chunk, true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for key, p := range c.pending {
+		if now.Sub(p.first) > c.ttl {
+			delete(c.pending, key)
+		}
+	}
+
+	p, ok := c.pending[id]
+	if !ok || len(p.chunks) != count {
+		p = &partialEvent{chunks: make([][]byte, count), first: now}
+		c.pending[id] = p
+	}
+	if p.chunks[index] == nil {
+		p.chunks[index] = chunk
+		p.received++
+	}
+	if p.received < count {
+		return nil, false
+	}
+	delete(c.pending, id)
+	return bytes.Join(p.chunks, nil), true
+}
@@ -0,0 +1,95 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCodecCompressesAndChunksLargeEvents(t *testing.T) {
+	codec := NewCodec(CodecConfig{CompressThreshold: 1 << 10, ChunkSize: 64}, map[EventType]CodecConfig{
+		EventAgentSpawned: {},
+	})
+	event := Event{
+		ID:      "evt-1",
+		Type:    EventTaskCompleted,
+		Payload: map[string]interface{}{"output": strings.Repeat("generated code ", 2000)},
+		Headers: map[string]string{HeaderIdempotencyKey: "key-1"},
+	}
+
+	encoded, err := codec.Encode(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(encoded) < 2 || encoded[0].Headers[HeaderEncoding] != "zstd" {
+		t.Fatalf("expected compressed chunks, got %d events", len(encoded))
+	}
+
+	// Deliver out of order with a duplicate
+	order := append([]Event{encoded[len(encoded)-1], encoded[0]}, encoded...)
+	var decoded Event
+	completions := 0
+	for _, e := range order {
+		got, complete, err := codec.Decode(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if complete {
+			decoded = got
+			completions++
+		}
+	}
+	if completions != 1 || decoded.ID != "evt-1" || decoded.Payload["output"] != event.Payload["output"] || decoded.Headers[HeaderIdempotencyKey] != "key-1" {
+		t.Fatalf("reassembled %d times: %+v", completions, decoded.Headers)
+	}
+
+	// Per-type overrides disable encoding
+	small, _ := codec.Encode(Event{ID: "evt-2", Type: EventAgentSpawned, Payload: event.Payload})
+	if len(small) != 1 || small[0].Headers[HeaderChunkID] != "" {
+		t.Fatalf("override ignored: %+v", small[0].Headers)
+	}
+}
+
+func TestCodecRejectsCorruptedPayload(t *testing.T) {
+	codec := NewCodec(CodecConfig{CompressThreshold: 10}, nil)
+	encoded, err := codec.Encode(Event{ID: "evt", Type: EventTaskCompleted, Payload: map[string]interface{}{"output": strings.Repeat("x", 100)}})
+	if err != nil || len(encoded) != 1 {
+		t.Fatalf("encode: %v, %d events", err, len(encoded))
+	}
+	encoded[0].Headers[HeaderChecksum] = strings.Repeat("0", 64)
+	if _, _, err := codec.Decode(encoded[0]); !errors.Is(err, ErrPayloadIntegrity) {
+		t.Fatalf("expected ErrPayloadIntegrity, got %v", err)
+	}
+}
+
+func TestEventBusReassemblesChunkedEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bus := NewEventBus()
+	bus.SetCodec(NewCodec(CodecConfig{CompressThreshold: 100, ChunkSize: 32}, nil))
+	received := make(chan Event, 10)
+	bus.Subscribe(EventTaskCompleted, func(ctx context.Context, event Event) error {
+		received <- event
+		return nil
+	})
+	bus.Start(ctx)
+
+	bus.Publish(Event{ID: "big", Type: EventTaskCompleted, Payload: map[string]interface{}{"output": strings.Repeat("abc", 500)}})
+
+	select {
+	case event := <-received:
+		if event.ID != "big" || len(event.Payload["output"].(string)) != 1500 {
+			t.Fatalf("unexpected event %s", event.ID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("chunked event was not delivered")
+	}
+	select {
+	case event := <-received:
+		t.Fatalf("handler ran again for %s", event.ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,132 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// HeaderIdempotencyKey identifies an event across redeliveries. Publishers may
+// set it explicitly; otherwise the bus derives one from the event ID and timestamp.
+const HeaderIdempotencyKey = "idempotency-key"
+
+// DefaultDedupTTL is how long processed events are remembered
+const DefaultDedupTTL = 24 * time.Hour
+
+// DedupStore remembers which subscriber has processed which event
+type DedupStore interface {
+	// Claim marks key as processed and reports whether this is the first claim
+	// within ttl. A false result means the event is a duplicate.
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Release forgets key so a failed handler can process a redelivery
+	Release(ctx context.Context, key string) error
+}
+
+// MemoryDedupStore keeps processed keys in memory with expiry
+type MemoryDedupStore struct {
+	mu        sync.Mutex
+	keys      map[string]time.Time
+	lastPrune time.Time
+}
+
+// NewMemoryDedupStore creates an in-process dedup store
+func NewMemoryDedupStore() *MemoryDedupStore {
+	return &MemoryDedupStore{
+		keys:      make(map[string]time.Time),
+		lastPrune: time.Now(),
+	}
+}
+
+func (s *MemoryDedupStore) Claim(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastPrune) > time.Minute {
+		for k, expiresAt := range s.keys {
+			if now.After(expiresAt) {
+				delete(s.keys, k)
+			}
+		}
+		s.lastPrune = now
+	}
+
+	if expiresAt, ok := s.keys[key]; ok && now.Before(expiresAt) {
+		return false, nil
+	}
+	s.keys[key] = now.Add(ttl)
+	return true, nil
+}
+
+func (s *MemoryDedupStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, key)
+	return nil
+}
+
+// PostgresDedupStore shares processed keys between processes via the processed_events table
+type PostgresDedupStore struct {
+	db *sql.DB
+}
+
+// NewPostgresDedupStore creates the processed_events table if needed
+func NewPostgresDedupStore(db *sql.DB) (*PostgresDedupStore, error) {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS processed_events (
+			key VARCHAR(255) PRIMARY KEY,
+			processed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP NOT NULL
+		)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create processed_events table: %w", err)
+	}
+	return &PostgresDedupStore{db: db}, nil
+}
+
+func (s *PostgresDedupStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	if _, err := s.db.ExecContext(ctx,
+		`DELETE FROM processed_events WHERE key = $1 AND expires_at < $2`, key, now); err != nil {
+		return false, fmt.Errorf("failed to expire processed event: %w", err)
+	}
+
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO processed_events (key, processed_at, expires_at) VALUES ($1, $2, $3)
+		 ON CONFLICT (key) DO NOTHING`, key, now, now.Add(ttl))
+	if err != nil {
+		return false, fmt.Errorf("failed to claim processed event: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim processed event: %w", err)
+	}
+	return rows == 1, nil
+}
+
+func (s *PostgresDedupStore) Release(ctx context.Context, key string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM processed_events WHERE key = $1`, key); err != nil {
+		return fmt.Errorf("failed to release processed event: %w", err)
+	}
+	return nil
+}
+
+// PurgeExpired removes expired keys; call periodically to bound table size
+func (s *PostgresDedupStore) PurgeExpired(ctx context.Context) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM processed_events WHERE expires_at < $1`, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge processed events: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// idempotencyKey returns the key identifying this event across redeliveries
+func idempotencyKey(event Event) string {
+	if key := event.Headers[HeaderIdempotencyKey]; key != "" {
+		return key
+	}
+	return fmt.Sprintf("%s@%d", event.ID, event.Timestamp.UnixNano())
+}
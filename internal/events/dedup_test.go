@@ -0,0 +1,138 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"QLP/internal/logger"
+)
+
+func TestMain(m *testing.M) {
+	config := logger.DefaultConfig()
+	config.Level = logger.ERROR
+	if err := logger.InitLogger(config); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestEventBusSkipsRedeliveredEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bus := NewEventBus()
+	var calls int32
+	done := make(chan struct{}, 10)
+	bus.SubscribeNamed(EventTaskCompleted, "counter", func(ctx context.Context, event Event) error {
+		atomic.AddInt32(&calls, 1)
+		done <- struct{}{}
+		return nil
+	})
+	bus.Start(ctx)
+
+	event := Event{
+		ID:        "event_task_1_completed",
+		Type:      EventTaskCompleted,
+		Timestamp: time.Now(),
+		Headers:   map[string]string{HeaderIdempotencyKey: "task_1:completed"},
+	}
+	bus.Publish(event)
+	<-done
+
+	// Redelivery of the same event must not reach the subscriber again
+	bus.Publish(event)
+	time.Sleep(50 * time.Millisecond)
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected handler to run once, ran %d times", got)
+	}
+}
+
+func TestEventBusRetriesFailedHandlerOnRedelivery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bus := NewEventBus()
+	var calls int32
+	done := make(chan struct{}, 10)
+	bus.SubscribeNamed(EventTaskFailed, "flaky", func(ctx context.Context, event Event) error {
+		n := atomic.AddInt32(&calls, 1)
+		defer func() { done <- struct{}{} }()
+		if n == 1 {
+			return errors.New("transient failure")
+		}
+		return nil
+	})
+	bus.Start(ctx)
+
+	event := Event{ID: "event_task_2_failed", Type: EventTaskFailed, Timestamp: time.Now()}
+	bus.Publish(event)
+	<-done
+	time.Sleep(10 * time.Millisecond) // allow the release after the handler error
+
+	bus.Publish(event)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected failed handler to be retried on redelivery")
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Fatalf("expected 2 handler calls, got %d", got)
+	}
+}
+
+func TestMemoryDedupStoreExpiry(t *testing.T) {
+	store := NewMemoryDedupStore()
+	ctx := context.Background()
+
+	if first, _ := store.Claim(ctx, "k", 10*time.Millisecond); !first {
+		t.Fatal("expected first claim to succeed")
+	}
+	if first, _ := store.Claim(ctx, "k", 10*time.Millisecond); first {
+		t.Fatal("expected duplicate claim to be rejected")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if first, _ := store.Claim(ctx, "k", 10*time.Millisecond); !first {
+		t.Fatal("expected claim to succeed after expiry")
+	}
+}
+
+func TestTryPublishReportsFullBus(t *testing.T) {
+	bus := NewEventBus()
+	for i := 0; i < cap(bus.events); i++ {
+		if err := bus.TryPublish(Event{ID: fmt.Sprintf("evt-%d", i), Type: EventTaskCreated}); err != nil {
+			t.Fatalf("event %d: %v", i, err)
+		}
+	}
+	if err := bus.TryPublish(Event{ID: "overflow", Type: EventTaskCreated}); !errors.Is(err, ErrBusFull) {
+		t.Errorf("publishing to a full bus error = %v", err)
+	}
+}
+
+func TestTryPublishQueuesAllChunksOrNone(t *testing.T) {
+	bus := NewEventBus()
+	bus.SetCodec(NewCodec(CodecConfig{}, map[EventType]CodecConfig{
+		EventTaskCompleted: {CompressThreshold: 1 << 20, ChunkSize: 32},
+	}))
+	for i := 0; i < cap(bus.events)-2; i++ {
+		if err := bus.TryPublish(Event{ID: fmt.Sprintf("evt-%d", i), Type: EventTaskCreated}); err != nil {
+			t.Fatalf("event %d: %v", i, err)
+		}
+	}
+
+	// The event needs more chunks than the two free slots
+	big := Event{ID: "big", Type: EventTaskCompleted, Payload: map[string]interface{}{"output": strings.Repeat("abc", 100)}}
+	if err := bus.TryPublish(big); !errors.Is(err, ErrBusFull) {
+		t.Fatalf("publishing a chunked event to a nearly full bus error = %v", err)
+	}
+	if depth := bus.QueueDepth(); depth != cap(bus.events)-2 {
+		t.Errorf("queue depth = %d, want the refused event's chunks left out", depth)
+	}
+}
@@ -0,0 +1,144 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"QLP/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// journalRecord is a line of the journal: a published event, or the
+// idempotency key of an event whose handlers have run
+type journalRecord struct {
+	Event *Event `json:"event,omitempty"`
+	Done  string `json:"done,omitempty"`
+}
+
+// Journal is an append-only file of published events and of the events
+// that have been handled, so events still queued when the process stops
+// are redelivered on restart
+type Journal struct {
+	mu      sync.Mutex
+	file    *os.File
+	pending []Event
+}
+
+// OpenJournal opens the journal at path, creating it if needed. The events
+// it holds that were never handled are kept for Pending, and the file is
+// compacted to just those.
+func OpenJournal(path string) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create event journal directory: %w", err)
+	}
+	pending, err := readJournal(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compact event journal: %w", err)
+	}
+	j := &Journal{file: f, pending: pending}
+	for i := range pending {
+		if err := j.write(journalRecord{Event: &pending[i]}); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to compact event journal: %w", err)
+	}
+	return j, nil
+}
+
+// readJournal returns the journaled events without a done record, in
+// publish order
+func readJournal(path string) ([]Event, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event journal: %w", err)
+	}
+	defer f.Close()
+
+	var events []Event
+	done := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var rec journalRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// A torn last line from a crash mid-write
+			logger.WithComponent("events").Warn("Skipping unreadable event journal record",
+				zap.Error(err))
+			continue
+		}
+		switch {
+		case rec.Event != nil:
+			events = append(events, *rec.Event)
+		case rec.Done != "":
+			done[rec.Done] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read event journal: %w", err)
+	}
+
+	pending := events[:0]
+	for _, event := range events {
+		if !done[idempotencyKey(event)] {
+			pending = append(pending, event)
+		}
+	}
+	return pending, nil
+}
+
+// Pending returns the events that were journaled but not handled before
+// the journal was opened
+func (j *Journal) Pending() []Event {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]Event(nil), j.pending...)
+}
+
+// Append records a published event
+func (j *Journal) Append(event Event) error {
+	return j.write(journalRecord{Event: &event})
+}
+
+// Done records that the handlers of the event with the given idempotency
+// key have run
+func (j *Journal) Done(key string) error {
+	return j.write(journalRecord{Done: key})
+}
+
+func (j *Journal) write(rec journalRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode event journal record: %w", err)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write event journal: %w", err)
+	}
+	return nil
+}
+
+// Close closes the journal file
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
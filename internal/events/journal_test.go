@@ -0,0 +1,92 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJournalRedeliversUnhandledEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.journal")
+	journal, err := OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first process handles intent-0 and stops before the rest are dispatched
+	bus := NewEventBus()
+	bus.SetJournal(journal)
+	ctx, cancel := context.WithCancel(context.Background())
+	handled := make(chan string, 10)
+	bus.Subscribe(EventIntentCompleted, func(ctx context.Context, event Event) error {
+		handled <- event.ID
+		return nil
+	})
+	bus.Start(ctx)
+	bus.Publish(Event{ID: "intent-0", Type: EventIntentCompleted})
+	select {
+	case <-handled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("event was not delivered")
+	}
+	// Let the handled record reach the journal
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	for i := 1; i <= 3; i++ {
+		bus.Publish(Event{ID: fmt.Sprintf("intent-%d", i), Type: EventIntentCompleted})
+	}
+	journal.Close()
+
+	journal, err = OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+	if pending := journal.Pending(); len(pending) != 3 || pending[0].ID != "intent-1" {
+		t.Fatalf("pending = %+v", pending)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "intent-0") {
+		t.Errorf("journal was not compacted: %s", data)
+	}
+
+	bus = NewEventBus()
+	bus.SetJournal(journal)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	var redelivered []string
+	handled = make(chan string, 10)
+	bus.Subscribe(EventIntentCompleted, func(ctx context.Context, event Event) error {
+		handled <- event.ID
+		return nil
+	})
+	bus.Start(ctx)
+	for len(redelivered) < 3 {
+		select {
+		case id := <-handled:
+			redelivered = append(redelivered, id)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("redelivered %v", redelivered)
+		}
+	}
+}
+
+func TestNewBusFromEnv(t *testing.T) {
+	t.Setenv("QLP_EVENT_BACKEND", "kafka")
+	if bus, err := NewBusFromEnv(); err == nil || bus == nil {
+		t.Errorf("unknown backend = %v, %v", bus, err)
+	}
+
+	t.Setenv("QLP_EVENT_BACKEND", BackendFile)
+	t.Setenv("QLP_EVENT_JOURNAL", filepath.Join(t.TempDir(), "events.journal"))
+	bus, err := NewBusFromEnv()
+	if err != nil || bus.journal == nil {
+		t.Fatalf("file backend = %+v, %v", bus, err)
+	}
+	bus.journal.Close()
+}
@@ -0,0 +1,47 @@
+package events
+
+import (
+	"context"
+	"fmt"
+
+	"QLP/internal/config"
+)
+
+// Manager publishes events and dispatches them to subscribers. EventBus is
+// the in-process implementation used by single-node deployments.
+type Manager interface {
+	Publish(event Event)
+	PublishWithContext(ctx context.Context, event Event)
+	Subscribe(eventType EventType, handler Handler)
+	SubscribeNamed(eventType EventType, name string, handler Handler)
+	SubscribeGroup(eventType EventType, group, member string, handler Handler)
+	Start(ctx context.Context)
+}
+
+var _ Manager = (*EventBus)(nil)
+
+// Event backends selectable with QLP_EVENT_BACKEND
+const (
+	BackendMemory = "memory" // Channels only; queued events are lost on restart
+	BackendFile   = "file"   // Channels with a journal replayed on restart
+)
+
+// NewBusFromEnv creates the event bus selected by QLP_EVENT_BACKEND. The file
+// backend journals published events to QLP_EVENT_JOURNAL (default
+// ./data/events.journal) until their handlers have run, and redelivers the
+// rest when the bus next starts.
+func NewBusFromEnv() (*EventBus, error) {
+	bus := NewEventBus()
+	switch backend := config.GetEnvOrDefault("QLP_EVENT_BACKEND", BackendMemory); backend {
+	case BackendMemory:
+	case BackendFile:
+		journal, err := OpenJournal(config.GetEnvOrDefault("QLP_EVENT_JOURNAL", "./data/events.journal"))
+		if err != nil {
+			return bus, err
+		}
+		bus.SetJournal(journal)
+	default:
+		return bus, fmt.Errorf("unknown event backend %q, expected %s or %s", backend, BackendMemory, BackendFile)
+	}
+	return bus, nil
+}
@@ -0,0 +1,60 @@
+package events
+
+import "hash/fnv"
+
+// HeaderPartitionKey groups events that must be handled in order, typically
+// the intent ID. A broker transport uses it as the message key so all events
+// of one intent land on the same partition.
+const HeaderPartitionKey = "partition-key"
+
+// DefaultPartitions is the number of ordered partitions keyed events are spread over
+const DefaultPartitions = 8
+
+// PartitionKey returns the event's partition key, empty when it has none
+func PartitionKey(event Event) string {
+	return event.Headers[HeaderPartitionKey]
+}
+
+// WithPartitionKey returns headers with the partition key set to key
+func WithPartitionKey(headers map[string]string, key string) map[string]string {
+	out := make(map[string]string, len(headers)+1)
+	for k, v := range headers {
+		out[k] = v
+	}
+	out[HeaderPartitionKey] = key
+	return out
+}
+
+// partitionFor maps a key to one of n partitions
+func partitionFor(key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// selectGroupMembers keeps standalone subscribers and one member of each
+// consumer group, picked by key (or the event ID for unkeyed events) so the
+// same key always reaches the same member
+func selectGroupMembers(subs []subscription, key, eventID string) []subscription {
+	if key == "" {
+		key = eventID
+	}
+	members := make(map[string][]subscription)
+	selected := make([]subscription, 0, len(subs))
+	for _, sub := range subs {
+		if sub.group == "" {
+			selected = append(selected, sub)
+			continue
+		}
+		members[sub.group] = append(members[sub.group], sub)
+	}
+	for _, sub := range subs {
+		group := members[sub.group]
+		if sub.group == "" || group == nil {
+			continue
+		}
+		selected = append(selected, group[partitionFor(key, len(group))])
+		delete(members, sub.group)
+	}
+	return selected
+}
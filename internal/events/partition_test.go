@@ -0,0 +1,112 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyedEventsAreHandledInOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bus := NewEventBus()
+	var mu sync.Mutex
+	seen := make(map[string][]int)
+	done := make(chan struct{}, 100)
+	bus.Subscribe(EventIntentCompleted, func(ctx context.Context, event Event) error {
+		// Uneven handler latency would reorder unkeyed events
+		time.Sleep(time.Duration(event.Payload["seq"].(int)%3) * time.Millisecond)
+		mu.Lock()
+		key := PartitionKey(event)
+		seen[key] = append(seen[key], event.Payload["seq"].(int))
+		mu.Unlock()
+		done <- struct{}{}
+		return nil
+	})
+	bus.Start(ctx)
+
+	for seq := 0; seq < 10; seq++ {
+		for _, intent := range []string{"intent-a", "intent-b"} {
+			bus.Publish(Event{
+				ID:      fmt.Sprintf("%s-%d", intent, seq),
+				Type:    EventIntentCompleted,
+				Payload: map[string]interface{}{"seq": seq},
+				Headers: WithPartitionKey(nil, intent),
+			})
+		}
+	}
+	for i := 0; i < 20; i++ {
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("events were not delivered")
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for intent, seqs := range seen {
+		for i, seq := range seqs {
+			if seq != i {
+				t.Fatalf("%s handled out of order: %v", intent, seqs)
+			}
+		}
+	}
+}
+
+func TestConsumerGroupMembersShareEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bus := NewEventBus()
+	var mu sync.Mutex
+	handledBy := make(map[string]map[string]bool) // intent -> members
+	total := 0
+	done := make(chan struct{}, 100)
+	for _, member := range []string{"replica-1", "replica-2", "replica-3"} {
+		member := member
+		bus.SubscribeGroup(EventTaskCompleted, "validation-service", member, func(ctx context.Context, event Event) error {
+			mu.Lock()
+			key := PartitionKey(event)
+			if handledBy[key] == nil {
+				handledBy[key] = make(map[string]bool)
+			}
+			handledBy[key][member] = true
+			total++
+			mu.Unlock()
+			done <- struct{}{}
+			return nil
+		})
+	}
+	bus.Start(ctx)
+
+	for i := 0; i < 30; i++ {
+		bus.Publish(Event{
+			ID:      fmt.Sprintf("event-%d", i),
+			Type:    EventTaskCompleted,
+			Headers: WithPartitionKey(nil, fmt.Sprintf("intent-%d", i%6)),
+		})
+	}
+	for i := 0; i < 30; i++ {
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("events were not delivered")
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if total != 30 {
+		t.Fatalf("each event should reach exactly one member, got %d deliveries", total)
+	}
+	for intent, members := range handledBy {
+		if len(members) != 1 {
+			t.Fatalf("%s was split across members %v", intent, members)
+		}
+	}
+}
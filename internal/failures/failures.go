@@ -0,0 +1,229 @@
+// Package failures classifies the failures of pipeline runs into a taxonomy
+// of causes, such as unparseable LLM output, compile errors, deployment quota
+// and cost limits, clusters similar failures across runs by the embeddings
+// of their messages, and reports the most frequent causes so prompts and
+// templates can be fixed where it matters most.
+package failures
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/config"
+	"QLP/internal/embeddings"
+	"QLP/internal/llm"
+	"QLP/internal/logger"
+	"QLP/internal/quota"
+	"QLP/internal/sandbox"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidQuery is returned for unknown categories and malformed report
+// parameters
+var ErrInvalidQuery = errors.New("invalid failure query")
+
+// Category is a cause in the failure taxonomy
+type Category string
+
+const (
+	LLMParseError      Category = "llm_parse_error"      // Model output that is not the JSON or code expected
+	CompileError       Category = "compile_error"        // Generated code that does not build
+	DeployQuota        Category = "deploy_quota"         // Cloud quota or SKU availability stopped a deployment
+	HealthCheckTimeout Category = "health_check_timeout" // A deployed service never became healthy
+	CostLimit          Category = "cost_limit"           // A cost, budget or execution quota limit was reached
+	Other              Category = "other"
+)
+
+// Categories lists the taxonomy in report order
+var Categories = []Category{LLMParseError, CompileError, DeployQuota, HealthCheckTimeout, CostLimit, Other}
+
+// Stages of the pipeline failures are recorded at
+const (
+	StageIntent     = "intent"     // Processing an intent end to end
+	StageTask       = "task"       // One agent task of an intent
+	StageDeployment = "deployment" // Deploying a capsule
+)
+
+// defaultTenant holds failures that carry no tenant
+const defaultTenant = "default"
+
+// Failure is one failed run, or one failed step of it
+type Failure struct {
+	TenantID  string    `json:"tenant_id"`
+	Stage     string    `json:"stage"`
+	RunID     string    `json:"run_id,omitempty"` // The trace of an intent, or a deployment ID
+	CapsuleID string    `json:"capsule_id,omitempty"`
+	Category  Category  `json:"category"`
+	Message   string    `json:"message"`
+	At        time.Time `json:"at"`
+}
+
+// rule assigns a category to the messages matching pattern
+type rule struct {
+	category Category
+	pattern  *regexp.Regexp
+}
+
+// rules are tried in order, so the more specific causes come first: a cost
+// limit is often reported as a quota, and a health check as a timeout
+var rules = []rule{
+	{CostLimit, regexp.MustCompile(`(?i)cost[ _-]?limit|budget|spend(ing)? limit|execution quota exceeded|exceeds tenant quota`)},
+	{HealthCheckTimeout, regexp.MustCompile(`(?i)health[ _-]?check|readiness probe|liveness probe|never became (healthy|ready)`)},
+	{DeployQuota, regexp.MustCompile(`(?i)quota|QuotaExceeded|SkuNotAvailable|not available in (region|location)|preflight found|vcpus?`)},
+	{CompileError, regexp.MustCompile(`(?i)compil(e|ation)|build failed|syntax ?error|undefined: |cannot find (package|module|symbol)|TS\d{4}:|error\[E\d{4}\]|go (build|vet)`)},
+	{LLMParseError, regexp.MustCompile(`(?i)unmarshal|invalid character|unexpected end of JSON|failed to parse|no (valid )?json|malformed (llm )?(output|response)|cannot parse`)},
+}
+
+// Classify returns the category of a failure message
+func Classify(message string) Category {
+	for _, r := range rules {
+		if r.pattern.MatchString(message) {
+			return r.category
+		}
+	}
+	return Other
+}
+
+// ClassifyError returns the category of err, recognizing the sentinel errors
+// of execution and sandbox quotas before falling back to its message
+func ClassifyError(err error) Category {
+	if errors.Is(err, quota.ErrExceeded) || errors.Is(err, sandbox.ErrQuotaExceeded) {
+		return CostLimit
+	}
+	return Classify(err.Error())
+}
+
+var (
+	volatile = []struct {
+		pattern     *regexp.Regexp
+		replacement string
+	}{
+		{regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`), "<id>"},
+		{regexp.MustCompile(`"[^"]*"|'[^']*'`), "<str>"},
+		{regexp.MustCompile(`(?:[\w.-]*/)+[\w.-]+`), "<path>"},
+		{regexp.MustCompile(`\b[0-9a-fA-F]{12,}\b|\b\w*\d[\w-]*\b`), "<n>"},
+	}
+	spaces = regexp.MustCompile(`\s+`)
+)
+
+// Signature reduces a failure message to its shape, replacing the IDs,
+// quoted values, paths and numbers that differ between runs, so the same
+// cause has the same signature everywhere it occurs
+func Signature(message string) string {
+	s := message
+	for _, v := range volatile {
+		s = v.pattern.ReplaceAllString(s, v.replacement)
+	}
+	return strings.ToLower(strings.TrimSpace(spaces.ReplaceAllString(s, " ")))
+}
+
+// Embedder turns failure signatures into vectors; *embeddings.Embedder
+// satisfies it
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Analyzer records failures and reports their top causes
+type Analyzer struct {
+	store     Store
+	embedder  Embedder
+	threshold float64
+	now       func() time.Time
+}
+
+// DefaultThreshold is the cosine similarity above which two signatures of a
+// category share a cluster
+const DefaultThreshold = 0.85
+
+// NewAnalyzer records failures in store. Without an embedder, signatures are
+// compared by the words they share.
+func NewAnalyzer(store Store, embedder Embedder) *Analyzer {
+	return &Analyzer{store: store, embedder: embedder, threshold: DefaultThreshold, now: time.Now}
+}
+
+// SetThreshold sets the similarity, between 0 and 1, at which signatures
+// are clustered together
+func (a *Analyzer) SetThreshold(threshold float64) {
+	a.threshold = threshold
+}
+
+var (
+	sharedOnce     sync.Once
+	sharedAnalyzer *Analyzer
+)
+
+// Shared returns the analyzer of the process, keeping failures in the
+// database at DATABASE_URL or in memory when it is unavailable and embedding
+// them with the QLP_EMBEDDING_PROVIDER, or nil unless
+// QLP_ENABLE_FAILURE_ANALYSIS is true
+func Shared() *Analyzer {
+	sharedOnce.Do(func() {
+		if config.GetEnvOrDefault("QLP_ENABLE_FAILURE_ANALYSIS", "false") != "true" {
+			return
+		}
+		log := logger.WithComponent("failures")
+		store, err := NewStoreFromEnv()
+		if err != nil {
+			log.Warn("Failure analysis disabled", zap.Error(err))
+			return
+		}
+		var embedder Embedder
+		if e, err := embeddings.NewFromEnv(llm.NewLLMClient()); err != nil {
+			log.Warn("Failure clustering falls back to word overlap", zap.Error(err))
+		} else {
+			embedder = e
+		}
+		sharedAnalyzer = NewAnalyzer(store, embedder)
+		if threshold, err := strconv.ParseFloat(config.GetEnvOrDefault("QLP_FAILURE_CLUSTER_THRESHOLD", "0.85"), 64); err != nil || threshold <= 0 || threshold > 1 {
+			log.Warn("Invalid QLP_FAILURE_CLUSTER_THRESHOLD, using the default", zap.Float64("default", DefaultThreshold))
+		} else {
+			sharedAnalyzer.SetThreshold(threshold)
+		}
+	})
+	return sharedAnalyzer
+}
+
+// Record classifies err and adds it to the shared analyzer when failure
+// analysis is enabled, taking the tenant from ctx when f has none. Failures
+// to record are logged, so recording never masks the error it records.
+func Record(ctx context.Context, f Failure, err error) {
+	a := Shared()
+	if a == nil || err == nil {
+		return
+	}
+	if f.TenantID == "" {
+		f.TenantID = audit.TenantFromContext(ctx)
+	}
+	f.Message = err.Error()
+	if f.Category == "" {
+		f.Category = ClassifyError(err)
+	}
+	if err := a.Record(ctx, f); err != nil {
+		logger.WithComponent("failures").Warn("Failed to record failure",
+			zap.String("stage", f.Stage),
+			zap.String("tenant_id", f.TenantID),
+			zap.Error(err))
+	}
+}
+
+// Record stores a failure, classifying it by its message unless it carries
+// a category and timestamping it now unless it carries a time
+func (a *Analyzer) Record(ctx context.Context, f Failure) error {
+	if f.TenantID == "" {
+		f.TenantID = defaultTenant
+	}
+	if f.Category == "" {
+		f.Category = Classify(f.Message)
+	}
+	if f.At.IsZero() {
+		f.At = a.now()
+	}
+	f.At = f.At.UTC()
+	return a.store.Add(ctx, f)
+}
@@ -0,0 +1,157 @@
+package failures
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"QLP/internal/quota"
+)
+
+func TestClassify(t *testing.T) {
+	for message, want := range map[string]Category{
+		`failed to parse intent: invalid character 'H' looking for beginning of value`: LLMParseError,
+		"json: cannot unmarshal string into Go value of type []models.Task":            LLMParseError,
+		"go build failed: ./main.go:12:2: undefined: handler":                          CompileError,
+		"src/index.ts(3,7): error TS2322: Type 'string' is not assignable":             CompileError,
+		"preflight found 2 problems in eastus: Standard_D4s_v5 needs 8 vCPUs":          DeployQuota,
+		"QuotaExceeded: operation could not be completed as it results in exceeding":   DeployQuota,
+		"health check api failed: context deadline exceeded":                           HealthCheckTimeout,
+		"deployment would exceed the cost limit of $10.00":                             CostLimit,
+		"connection reset by peer":                                                     Other,
+	} {
+		if got := Classify(message); got != want {
+			t.Errorf("Classify(%q) = %s, want %s", message, got, want)
+		}
+	}
+	if got := ClassifyError(fmt.Errorf("tenant acme: %w", quota.ErrExceeded)); got != CostLimit {
+		t.Errorf("ClassifyError(quota) = %s, want %s", got, CostLimit)
+	}
+}
+
+func TestSignature(t *testing.T) {
+	a := Signature(`failed to parse output of task QL-DEV-001 at ./out/api/main.go: invalid character 'x' at offset 12`)
+	b := Signature(`Failed to parse output of task QL-DEV-207 at ./out/web/app.go:   invalid character '}' at offset 3`)
+	if a != b {
+		t.Errorf("signatures differ:\n%s\n%s", a, b)
+	}
+}
+
+func newTestAnalyzer(t *testing.T, embedder Embedder) *Analyzer {
+	t.Helper()
+	a := NewAnalyzer(NewMemoryStore(), embedder)
+	a.now = func() time.Time { return time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+	at := time.Date(2026, 3, 20, 9, 0, 0, 0, time.UTC)
+	for i, f := range []Failure{
+		{TenantID: "acme", Stage: StageTask, RunID: "r1", Message: "agent execution failed: failed to parse LLM output: unexpected end of JSON input"},
+		{TenantID: "acme", Stage: StageIntent, RunID: "r1", Message: "failed to parse intent: unexpected end of JSON input"},
+		{TenantID: "acme", Stage: StageTask, RunID: "r2", Message: "agent execution failed: failed to parse LLM output: unexpected end of JSON input"},
+		{TenantID: "globex", Stage: StageTask, RunID: "r3", Message: "agent execution failed: failed to parse LLM output: unexpected end of JSON input"},
+		{TenantID: "acme", Stage: StageDeployment, RunID: "DEP-1", Message: "health check api failed: context deadline exceeded"},
+		{TenantID: "acme", Stage: StageDeployment, Message: "preflight found 1 problems in eastus: quota"},
+		{TenantID: "acme", Stage: StageDeployment, RunID: "DEP-9", Message: "old failure", At: at.AddDate(0, -2, 0)},
+	} {
+		if f.At.IsZero() {
+			f.At = at.Add(time.Duration(i) * time.Minute)
+		}
+		if err := a.Record(ctx, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a
+}
+
+func TestTopCauses(t *testing.T) {
+	a := newTestAnalyzer(t, nil)
+	report, err := a.TopCauses(context.Background(), Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total != 6 || report.Categories[LLMParseError] != 4 {
+		t.Fatalf("total %d, categories %v", report.Total, report.Categories)
+	}
+	if len(report.Causes) != 4 {
+		t.Fatalf("causes = %+v", report.Causes)
+	}
+	top := report.Causes[0]
+	if top.Category != LLMParseError || top.Count != 3 || top.Runs != 3 || top.Variants != 1 || top.Share != 0.5 ||
+		len(top.Tenants) != 2 || top.Example != "agent execution failed: failed to parse LLM output: unexpected end of JSON input" {
+		t.Errorf("top cause = %+v", top)
+	}
+
+	// A lower threshold merges the two parse failures
+	a.SetThreshold(0.5)
+	report, err = a.TopCauses(context.Background(), Query{TenantID: "acme", Category: LLMParseError})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Causes) != 1 || report.Causes[0].Count != 3 || report.Causes[0].Runs != 2 || report.Causes[0].Variants != 2 {
+		t.Errorf("causes = %+v", report.Causes)
+	}
+}
+
+// letterEmbedder gives signatures that start with the same letter the same
+// vector, and others orthogonal ones
+type letterEmbedder struct{ err error }
+
+func (e letterEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = make([]float32, 26)
+		vectors[i][(text[0]-'a')%26] = 1
+	}
+	return vectors, nil
+}
+
+func TestTopCausesEmbedded(t *testing.T) {
+	report, err := newTestAnalyzer(t, letterEmbedder{}).TopCauses(context.Background(), Query{Category: LLMParseError})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Causes) != 2 {
+		t.Errorf("causes = %+v", report.Causes)
+	}
+
+	// Falls back to word overlap when the embedder fails
+	report, err = newTestAnalyzer(t, letterEmbedder{err: errors.New("down")}).TopCauses(context.Background(), Query{Category: LLMParseError})
+	if err != nil || len(report.Causes) != 2 {
+		t.Errorf("causes = %+v, err %v", report, err)
+	}
+}
+
+func TestFailuresHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	for pattern, h := range Routes(newTestAnalyzer(t, nil)) {
+		mux.Handle(pattern, h)
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tenants/acme/failures/top?stage=deployment&limit=1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var report Report
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Total != 2 || len(report.Causes) != 1 || report.Causes[0].Category != DeployQuota {
+		t.Errorf("report = %+v", report)
+	}
+
+	for _, bad := range []string{"category=gremlins", "limit=0", "from=yesterday", "from=2026-03-05&to=2026-03-01"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/failures/top?"+bad, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s = %d, want 400", bad, rec.Code)
+		}
+	}
+}
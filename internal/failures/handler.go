@@ -0,0 +1,113 @@
+package failures
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"QLP/internal/audit"
+)
+
+// Routes returns the failure analysis endpoints. from and to are RFC 3339
+// times or dates and default to the last 30 days; limit defaults to 10.
+//
+//	GET /failures/top?tenant=&category=&stage=&from=&to=&limit=
+//	GET /tenants/{tenant}/failures/top?category=&stage=&from=&to=&limit=
+//	GET /failures/taxonomy
+func Routes(a *Analyzer) map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /failures/top":                  topHandler(a),
+		"GET /tenants/{tenant}/failures/top": topHandler(a),
+		"GET /failures/taxonomy":             http.HandlerFunc(taxonomyHandler),
+	}
+}
+
+func topHandler(a *Analyzer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseQuery(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		report, err := a.TopCauses(r.Context(), q)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	})
+}
+
+func taxonomyHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"categories": Categories})
+}
+
+func parseQuery(r *http.Request) (Query, error) {
+	params := r.URL.Query()
+	q := Query{
+		TenantID: audit.RequestTenant(r),
+		Category: Category(params.Get("category")),
+		Stage:    params.Get("stage"),
+	}
+	if tenant := r.PathValue("tenant"); tenant != "" {
+		q.TenantID = tenant
+	}
+	if q.Category != "" && !knownCategory(q.Category) {
+		return q, fmt.Errorf("%w: unknown category %q", ErrInvalidQuery, q.Category)
+	}
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return q, fmt.Errorf("%w: limit must be a positive number", ErrInvalidQuery)
+		}
+		q.Limit = n
+	}
+
+	var err error
+	if q.From, err = parseTime(params.Get("from")); err != nil {
+		return q, err
+	}
+	if q.To, err = parseTime(params.Get("to")); err != nil {
+		return q, err
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return q, fmt.Errorf("%w: from must be before to", ErrInvalidQuery)
+	}
+	return q, nil
+}
+
+func knownCategory(c Category) bool {
+	for _, known := range Categories {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTime reads an RFC 3339 time or a YYYY-MM-DD date
+func parseTime(spec string) (time.Time, error) {
+	if spec == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, spec); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, spec); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%w: time %q is neither RFC 3339 nor YYYY-MM-DD", ErrInvalidQuery, spec)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, ErrInvalidQuery) {
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
@@ -0,0 +1,255 @@
+package failures
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"QLP/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Query selects the failures a report covers. Empty fields select
+// everything; the window defaults to the last 30 days and Limit to 10.
+type Query struct {
+	TenantID string
+	Category Category
+	Stage    string
+	From     time.Time
+	To       time.Time
+	Limit    int
+}
+
+// Cause is a cluster of similar failures: one signature and those close
+// enough to it to share a fix
+type Cause struct {
+	Category  Category  `json:"category"`
+	Signature string    `json:"signature"`
+	Example   string    `json:"example"`  // The latest message with the signature
+	Variants  int       `json:"variants"` // Distinct signatures in the cluster
+	Count     int       `json:"count"`
+	Runs      int       `json:"runs"`
+	Share     float64   `json:"share"` // Of all failures in the report
+	Stages    []string  `json:"stages"`
+	Tenants   []string  `json:"tenants"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// Report ranks the causes of the failures a query selects
+type Report struct {
+	From       time.Time        `json:"from"`
+	To         time.Time        `json:"to"`
+	Total      int              `json:"total"`
+	Categories map[Category]int `json:"categories"`
+	Causes     []Cause          `json:"causes"`
+}
+
+// group is every failure with one signature
+type group struct {
+	category  Category
+	signature string
+	failures  []Failure
+}
+
+// TopCauses clusters the failures q selects and ranks the clusters by how
+// often they occurred
+func (a *Analyzer) TopCauses(ctx context.Context, q Query) (*Report, error) {
+	q = a.withDefaults(q)
+	all, err := a.store.List(ctx, q.TenantID, q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{From: q.From, To: q.To, Categories: make(map[Category]int)}
+	bySignature := make(map[string]*group)
+	var groups []*group
+	for _, f := range all {
+		if (q.Category != "" && f.Category != q.Category) || (q.Stage != "" && f.Stage != q.Stage) {
+			continue
+		}
+		report.Total++
+		report.Categories[f.Category]++
+		sig := Signature(f.Message)
+		key := string(f.Category) + "\x00" + sig
+		g, ok := bySignature[key]
+		if !ok {
+			g = &group{category: f.Category, signature: sig}
+			bySignature[key] = g
+			groups = append(groups, g)
+		}
+		g.failures = append(g.failures, f)
+	}
+	if len(groups) == 0 {
+		report.Causes = []Cause{}
+		return report, nil
+	}
+
+	// Each cluster is led by its most frequent signature
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i].failures) != len(groups[j].failures) {
+			return len(groups[i].failures) > len(groups[j].failures)
+		}
+		return groups[i].signature < groups[j].signature
+	})
+	vectors := a.vectors(ctx, groups)
+
+	type cluster struct {
+		leader  int
+		members []*group
+	}
+	var clusters []*cluster
+	for i, g := range groups {
+		var joined *cluster
+		for _, c := range clusters {
+			if groups[c.leader].category == g.category && cosine(vectors[c.leader], vectors[i]) >= a.threshold {
+				joined = c
+				break
+			}
+		}
+		if joined == nil {
+			joined = &cluster{leader: i}
+			clusters = append(clusters, joined)
+		}
+		joined.members = append(joined.members, g)
+	}
+
+	causes := make([]Cause, 0, len(clusters))
+	for _, c := range clusters {
+		causes = append(causes, summarize(groups[c.leader], c.members, report.Total))
+	}
+	order := make(map[Category]int, len(Categories))
+	for i, c := range Categories {
+		order[c] = i
+	}
+	sort.SliceStable(causes, func(i, j int) bool {
+		if causes[i].Count != causes[j].Count {
+			return causes[i].Count > causes[j].Count
+		}
+		return order[causes[i].Category] < order[causes[j].Category]
+	})
+	if len(causes) > q.Limit {
+		causes = causes[:q.Limit]
+	}
+	report.Causes = causes
+	return report, nil
+}
+
+// summarize describes the cluster led by leader
+func summarize(leader *group, members []*group, total int) Cause {
+	cause := Cause{Category: leader.category, Signature: leader.signature, Variants: len(members)}
+	var latest time.Time
+	for _, f := range leader.failures {
+		if cause.Example == "" || f.At.After(latest) {
+			cause.Example, latest = f.Message, f.At
+		}
+	}
+
+	runs := make(map[string]bool)
+	stages := make(map[string]bool)
+	tenants := make(map[string]bool)
+	for _, g := range members {
+		for _, f := range g.failures {
+			cause.Count++
+			// Failures outside a run count as runs of their own
+			if f.RunID == "" {
+				cause.Runs++
+			} else {
+				runs[f.RunID] = true
+			}
+			stages[f.Stage] = true
+			tenants[f.TenantID] = true
+			if cause.FirstSeen.IsZero() || f.At.Before(cause.FirstSeen) {
+				cause.FirstSeen = f.At
+			}
+			if f.At.After(cause.LastSeen) {
+				cause.LastSeen = f.At
+			}
+		}
+	}
+	cause.Runs += len(runs)
+	cause.Share = float64(cause.Count) / float64(total)
+	cause.Stages = sortedKeys(stages)
+	cause.Tenants = sortedKeys(tenants)
+	return cause
+}
+
+// vectors embeds the signature of each group, comparing them by the words
+// they share when there is no embedder or it fails
+func (a *Analyzer) vectors(ctx context.Context, groups []*group) [][]float32 {
+	texts := make([]string, len(groups))
+	for i, g := range groups {
+		texts[i] = g.signature
+	}
+	if a.embedder != nil {
+		vectors, err := a.embedder.Embed(ctx, texts)
+		if err == nil && len(vectors) == len(texts) {
+			return vectors
+		}
+		logger.WithComponent("failures").Warn("Failed to embed failure signatures, clustering by word overlap",
+			zap.Int("signatures", len(texts)),
+			zap.Error(err))
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = wordVector(text)
+	}
+	return vectors
+}
+
+// wordDimensions is the length of word vectors
+const wordDimensions = 512
+
+// wordVector hashes the words of text into a vector, so that the cosine of
+// two vectors measures the words their texts share
+func wordVector(text string) []float32 {
+	v := make([]float32, wordDimensions)
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '<' || r == '>' || r == '_')
+	}) {
+		h := fnv.New32a()
+		h.Write([]byte(word))
+		v[h.Sum32()%wordDimensions]++
+	}
+	return v
+}
+
+// cosine returns the cosine similarity of a and b, 0 when either is zero
+func cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range min(len(a), len(b)) {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// withDefaults fills in what q leaves open: the last 30 days and the top 10
+func (a *Analyzer) withDefaults(q Query) Query {
+	if q.To.IsZero() {
+		q.To = a.now()
+	}
+	if q.From.IsZero() {
+		q.From = q.To.Add(-30 * 24 * time.Hour)
+	}
+	if q.Limit <= 0 {
+		q.Limit = 10
+	}
+	return q
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,124 @@
+package failures
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"QLP/internal/database"
+	"QLP/internal/logger"
+)
+
+// Store keeps recorded failures
+type Store interface {
+	Add(ctx context.Context, f Failure) error
+	// List returns the failures recorded in [from, to), of one tenant unless
+	// tenantID is empty
+	List(ctx context.Context, tenantID string, from, to time.Time) ([]Failure, error)
+}
+
+// MemoryStore keeps failures in memory, for tests and deployments without a
+// database
+type MemoryStore struct {
+	mu       sync.Mutex
+	failures []Failure
+}
+
+// NewMemoryStore creates an empty in-memory failure store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (s *MemoryStore) Add(_ context.Context, f Failure) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, f)
+	return nil
+}
+
+func (s *MemoryStore) List(_ context.Context, tenantID string, from, to time.Time) ([]Failure, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var failures []Failure
+	for _, f := range s.failures {
+		if (tenantID == "" || f.TenantID == tenantID) && !f.At.Before(from) && f.At.Before(to) {
+			failures = append(failures, f)
+		}
+	}
+	return failures, nil
+}
+
+// PostgresStore keeps failures in the run_failures table, shared by every
+// QLP process
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates the run_failures table if needed
+func NewPostgresStore(db *sql.DB) (*PostgresStore, error) {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS run_failures (
+			tenant_id VARCHAR(100) NOT NULL,
+			stage VARCHAR(50) NOT NULL,
+			run_id VARCHAR(100) NOT NULL DEFAULT '',
+			capsule_id VARCHAR(100) NOT NULL DEFAULT '',
+			category VARCHAR(50) NOT NULL,
+			message TEXT NOT NULL,
+			recorded_at TIMESTAMP WITH TIME ZONE NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_run_failures_recorded ON run_failures (recorded_at, tenant_id)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create run_failures table: %w", err)
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+func (s *PostgresStore) Add(ctx context.Context, f Failure) error {
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO run_failures (tenant_id, stage, run_id, capsule_id, category, message, recorded_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		f.TenantID, f.Stage, f.RunID, f.CapsuleID, string(f.Category), f.Message, f.At); err != nil {
+		return fmt.Errorf("failed to record failure: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) List(ctx context.Context, tenantID string, from, to time.Time) ([]Failure, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT tenant_id, stage, run_id, capsule_id, category, message, recorded_at FROM run_failures
+		 WHERE recorded_at >= $1 AND recorded_at < $2 AND ($3 = '' OR tenant_id = $3)
+		 ORDER BY recorded_at`,
+		from, to, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list failures: %w", err)
+	}
+	defer rows.Close()
+
+	var failures []Failure
+	for rows.Next() {
+		var f Failure
+		var category string
+		if err := rows.Scan(&f.TenantID, &f.Stage, &f.RunID, &f.CapsuleID, &category, &f.Message, &f.At); err != nil {
+			return nil, fmt.Errorf("failed to read failure: %w", err)
+		}
+		f.Category = Category(category)
+		failures = append(failures, f)
+	}
+	return failures, rows.Err()
+}
+
+// NewStoreFromEnv keeps failures in the database at DATABASE_URL, falling
+// back to memory when it is unavailable
+func NewStoreFromEnv() (Store, error) {
+	db, err := database.New()
+	if err != nil {
+		return nil, err
+	}
+	if !db.IsConnected() {
+		logger.WithComponent("failures").Warn("Database unavailable, failures are kept in memory and not shared between processes")
+		return NewMemoryStore(), nil
+	}
+	return NewPostgresStore(db.GetConnection())
+}
@@ -0,0 +1,227 @@
+// Package filetypes maps languages to file extensions, MIME types and project
+// layouts, so generated output is persisted and packaged as a browsable
+// project rather than as loose text files. Binary assets travel as base64.
+package filetypes
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// Base64 is the encoding of binary file content in JSON payloads
+const Base64 = "base64"
+
+// Text is the language of files nothing more specific is known about
+const Text = "text"
+
+// Type describes how files of one language are named and laid out
+type Type struct {
+	Language   string   // Canonical name, e.g. "python"
+	Extensions []string // The first one is used for new files
+	Names      []string // Whole file names, e.g. "Dockerfile"
+	MimeType   string
+	Binary     bool
+	Entry      string // Entry point of a project, e.g. "main.go"
+	TestFile   string // Pattern for the test of %s, e.g. "tests/test_%s.py"
+}
+
+// Extension returns the extension new files of the type get
+func (t Type) Extension() string {
+	if len(t.Extensions) == 0 {
+		return ""
+	}
+	return t.Extensions[0]
+}
+
+// FileName names a file of the type after base, e.g. "code" becomes "code.py".
+// Types known by whole name only, like Dockerfile, keep that name.
+func (t Type) FileName(base string) string {
+	if len(t.Extensions) == 0 && len(t.Names) > 0 {
+		return t.Names[0]
+	}
+	return base + t.Extension()
+}
+
+// TestPath returns where the test of the file named base goes
+func (t Type) TestPath(base string) string {
+	if t.TestFile == "" {
+		return ""
+	}
+	return fmt.Sprintf(t.TestFile, base)
+}
+
+var types = []Type{
+	{Language: "go", Extensions: []string{".go"}, MimeType: "text/x-go", Entry: "main.go", TestFile: "%s_test.go"},
+	{Language: "python", Extensions: []string{".py"}, MimeType: "text/x-python", Entry: "main.py", TestFile: "tests/test_%s.py"},
+	{Language: "javascript", Extensions: []string{".js", ".mjs", ".cjs", ".jsx"}, MimeType: "text/javascript", Entry: "index.js", TestFile: "%s.test.js"},
+	{Language: "typescript", Extensions: []string{".ts", ".tsx"}, MimeType: "text/x-typescript", Entry: "src/index.ts", TestFile: "%s.test.ts"},
+	{Language: "java", Extensions: []string{".java"}, MimeType: "text/x-java", Entry: "src/main/java/Main.java", TestFile: "src/test/java/%sTest.java"},
+	{Language: "kotlin", Extensions: []string{".kt"}, MimeType: "text/x-kotlin", Entry: "src/main/kotlin/Main.kt", TestFile: "src/test/kotlin/%sTest.kt"},
+	{Language: "csharp", Extensions: []string{".cs"}, MimeType: "text/x-csharp", Entry: "Program.cs", TestFile: "Tests/%sTests.cs"},
+	{Language: "fsharp", Extensions: []string{".fs", ".fsx"}, MimeType: "text/x-fsharp", Entry: "Program.fs", TestFile: "Tests/%sTests.fs"},
+	{Language: "rust", Extensions: []string{".rs"}, MimeType: "text/x-rust", Entry: "src/main.rs", TestFile: "tests/%s.rs"},
+	{Language: "ruby", Extensions: []string{".rb"}, MimeType: "text/x-ruby", Entry: "main.rb", TestFile: "spec/%s_spec.rb"},
+	{Language: "php", Extensions: []string{".php"}, MimeType: "text/x-php", Entry: "index.php", TestFile: "tests/%sTest.php"},
+	{Language: "shell", Extensions: []string{".sh", ".bash"}, MimeType: "text/x-shellscript", Entry: "run.sh"},
+	{Language: "powershell", Extensions: []string{".ps1", ".psm1", ".psd1"}, MimeType: "text/x-powershell", Entry: "run.ps1", TestFile: "tests/%s.Tests.ps1"},
+	{Language: "sql", Extensions: []string{".sql"}, MimeType: "application/sql", Entry: "schema.sql"},
+	{Language: "terraform", Extensions: []string{".tf", ".tfvars"}, MimeType: "text/x-terraform", Entry: "main.tf"},
+	{Language: "dockerfile", Names: []string{"Dockerfile"}, MimeType: "text/x-dockerfile", Entry: "Dockerfile"},
+	{Language: "makefile", Names: []string{"Makefile"}, MimeType: "text/x-makefile", Entry: "Makefile"},
+	{Language: "yaml", Extensions: []string{".yaml", ".yml"}, MimeType: "application/yaml", Entry: "config.yaml"},
+	{Language: "json", Extensions: []string{".json"}, MimeType: "application/json", Entry: "data.json"},
+	{Language: "toml", Extensions: []string{".toml"}, MimeType: "application/toml", Entry: "config.toml"},
+	{Language: "ini", Extensions: []string{".ini", ".cfg", ".conf"}, MimeType: "text/plain", Entry: "config.ini"},
+	{Language: "xml", Extensions: []string{".xml"}, MimeType: "application/xml", Entry: "data.xml"},
+	{Language: "html", Extensions: []string{".html", ".htm"}, MimeType: "text/html", Entry: "index.html"},
+	{Language: "css", Extensions: []string{".css"}, MimeType: "text/css", Entry: "styles.css"},
+	{Language: "markdown", Extensions: []string{".md"}, MimeType: "text/markdown", Entry: "README.md"},
+	{Language: "csv", Extensions: []string{".csv"}, MimeType: "text/csv", Entry: "data.csv"},
+	{Language: "svg", Extensions: []string{".svg"}, MimeType: "image/svg+xml", Entry: "image.svg"},
+	{Language: Text, Extensions: []string{".txt"}, MimeType: "text/plain", Entry: "output.txt"},
+
+	// Binary assets
+	{Language: "png", Extensions: []string{".png"}, MimeType: "image/png", Binary: true},
+	{Language: "jpeg", Extensions: []string{".jpg", ".jpeg"}, MimeType: "image/jpeg", Binary: true},
+	{Language: "gif", Extensions: []string{".gif"}, MimeType: "image/gif", Binary: true},
+	{Language: "ico", Extensions: []string{".ico"}, MimeType: "image/x-icon", Binary: true},
+	{Language: "woff2", Extensions: []string{".woff2"}, MimeType: "font/woff2", Binary: true},
+	{Language: "pdf", Extensions: []string{".pdf"}, MimeType: "application/pdf", Binary: true},
+	{Language: "zip", Extensions: []string{".zip"}, MimeType: "application/zip", Binary: true},
+	{Language: "gzip", Extensions: []string{".gz", ".tgz"}, MimeType: "application/gzip", Binary: true},
+}
+
+// aliases are the other names languages go by, as in markdown code fences
+var aliases = map[string]string{
+	"golang": "go", "py": "python", "python3": "python", "js": "javascript", "node": "javascript",
+	"jsx": "javascript", "ts": "typescript", "tsx": "typescript", "c#": "csharp", "cs": "csharp",
+	"f#": "fsharp", "fs": "fsharp", "ps1": "powershell", "pwsh": "powershell", "ps": "powershell",
+	"rs": "rust", "rb": "ruby", "sh": "shell", "bash": "shell", "zsh": "shell", "tf": "terraform",
+	"hcl": "terraform", "docker": "dockerfile", "make": "makefile", "yml": "yaml", "md": "markdown",
+	"htm": "html", "jpg": "jpeg", "txt": Text, "plaintext": Text, "": Text,
+}
+
+var (
+	byLanguage  = make(map[string]Type)
+	byExtension = make(map[string]Type)
+	byName      = make(map[string]Type)
+)
+
+func init() {
+	for _, t := range types {
+		byLanguage[t.Language] = t
+		for _, ext := range t.Extensions {
+			byExtension[ext] = t
+		}
+		for _, name := range t.Names {
+			byName[strings.ToLower(name)] = t
+		}
+	}
+}
+
+// Lookup returns the type of a language, by canonical name or alias
+func Lookup(language string) (Type, bool) {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if canonical, ok := aliases[language]; ok {
+		language = canonical
+	}
+	t, ok := byLanguage[language]
+	return t, ok
+}
+
+// ForLanguage returns the type of a language, or the plain text type
+func ForLanguage(language string) Type {
+	if t, ok := Lookup(language); ok {
+		return t
+	}
+	return byLanguage[Text]
+}
+
+// Detect returns the type of a file from its name, or the plain text type
+func Detect(file string) Type {
+	base := strings.ToLower(path.Base(file))
+	if t, ok := byName[base]; ok {
+		return t
+	}
+	if strings.HasPrefix(base, "dockerfile.") {
+		return byName["dockerfile"]
+	}
+	if t, ok := byExtension[path.Ext(base)]; ok {
+		return t
+	}
+	return byLanguage[Text]
+}
+
+// Known reports whether the file name maps to a registered type
+func Known(file string) bool {
+	return Detect(file).Language != Text || strings.ToLower(path.Ext(file)) == ".txt"
+}
+
+// Extension returns the extension new files in language get
+func Extension(language string) string {
+	return ForLanguage(language).Extension()
+}
+
+// MimeType returns the MIME type of a file, application/octet-stream when the
+// name is not recognized
+func MimeType(file string) string {
+	if !Known(file) {
+		return "application/octet-stream"
+	}
+	return Detect(file).MimeType
+}
+
+// CleanPath makes a generated file path safe to write under a project root:
+// slashes only, no leading slash or "./", and nothing escaping the root. A
+// path without an extension that language gives one gets it. It returns ""
+// for paths that cannot be written.
+func CleanPath(file, language string) string {
+	file = strings.ReplaceAll(strings.TrimSpace(file), `\`, "/")
+	file = path.Clean("/" + file)[1:]
+	if file == "" {
+		return ""
+	}
+	if path.Ext(file) == "" && !Known(file) && language != "" {
+		if t, ok := Lookup(language); ok && len(t.Extensions) > 0 {
+			file += t.Extension()
+		}
+	}
+	return file
+}
+
+// IsBinary reports whether content has to be base64-encoded to travel as
+// text: a binary file type, invalid UTF-8 or NUL bytes
+func IsBinary(file string, content []byte) bool {
+	if Known(file) && Detect(file).Binary {
+		return true
+	}
+	return !utf8.Valid(content) || strings.ContainsRune(string(content), 0)
+}
+
+// Encode returns content as it travels in JSON, with its encoding: base64
+// for binary content, "" for text
+func Encode(file string, content []byte) (string, string) {
+	if IsBinary(file, content) {
+		return base64.StdEncoding.EncodeToString(content), Base64
+	}
+	return string(content), ""
+}
+
+// Decode reverses Encode
+func Decode(content, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(content), nil
+	case Base64:
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode base64 content: %w", err)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
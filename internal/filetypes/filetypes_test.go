@@ -0,0 +1,73 @@
+package filetypes
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	for _, tc := range []struct{ file, language, mime string }{
+		{"cmd/api/main.go", "go", "text/x-go"},
+		{"app/models.py", "python", "text/x-python"},
+		{"web/App.tsx", "typescript", "text/x-typescript"},
+		{"deploy/Dockerfile", "dockerfile", "text/x-dockerfile"},
+		{"Dockerfile.prod", "dockerfile", "text/x-dockerfile"},
+		{"static/logo.PNG", "png", "image/png"},
+		{"notes.txt", Text, "text/plain"},
+		{"LICENSE", Text, "application/octet-stream"},
+	} {
+		if got := Detect(tc.file).Language; got != tc.language {
+			t.Errorf("Detect(%s) = %s, want %s", tc.file, got, tc.language)
+		}
+		if got := MimeType(tc.file); got != tc.mime {
+			t.Errorf("MimeType(%s) = %s, want %s", tc.file, got, tc.mime)
+		}
+	}
+
+	if Extension("golang") != ".go" || Extension("py") != ".py" || Extension("unknown") != ".txt" {
+		t.Error("unexpected extensions")
+	}
+	if got := ForLanguage("python").TestPath("orders"); got != "tests/test_orders.py" {
+		t.Errorf("python test path = %s", got)
+	}
+	if got := ForLanguage("docker").FileName("infra"); got != "Dockerfile" {
+		t.Errorf("dockerfile name = %s", got)
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	for in, want := range map[string]string{
+		"src/app/main":        "src/app/main.py",
+		"/etc/passwd":         "etc/passwd.py",
+		"../../outside.py":    "outside.py",
+		`pkg\util\strings.py`: "pkg/util/strings.py",
+		"./Makefile":          "Makefile",
+		"/":                   "",
+	} {
+		if got := CleanPath(in, "python"); got != want {
+			t.Errorf("CleanPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	png := []byte{0x89, 'P', 'N', 'G', 0x0d, 0x0a, 0x1a, 0x0a, 0x00}
+	content, encoding := Encode("static/logo.png", png)
+	if encoding != Base64 {
+		t.Fatalf("encoding = %q", encoding)
+	}
+	decoded, err := Decode(content, encoding)
+	if err != nil || !bytes.Equal(decoded, png) {
+		t.Errorf("round trip = %v, %v", decoded, err)
+	}
+
+	if content, encoding := Encode("main.go", []byte("package main\n")); encoding != "" || content != "package main\n" {
+		t.Errorf("text encoded as %q", encoding)
+	}
+	if _, encoding := Encode("data.bin", []byte{0xff, 0xfe}); encoding != Base64 {
+		t.Error("invalid UTF-8 should be base64-encoded")
+	}
+	if _, err := Decode("x", "gzip"); err == nil {
+		t.Error("expected an error for an unknown encoding")
+	}
+}
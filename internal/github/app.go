@@ -0,0 +1,158 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AppTokens authenticates as a GitHub App, exchanging a signed JWT for
+// installation tokens that are cached until shortly before they expire
+type AppTokens struct {
+	appID   int64
+	key     *rsa.PrivateKey
+	baseURL string
+	client  *http.Client
+
+	mu            sync.Mutex
+	installations map[string]int64 // owner -> installation ID
+	tokens        map[int64]installationToken
+}
+
+type installationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NewAppTokens creates an app token source from the app ID and its PEM private key
+func NewAppTokens(baseURL string, appID int64, privateKeyPEM []byte) (*AppTokens, error) {
+	key, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &AppTokens{
+		appID:         appID,
+		key:           key,
+		baseURL:       baseURL,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		installations: make(map[string]int64),
+		tokens:        make(map[int64]installationToken),
+	}, nil
+}
+
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("github: private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("github: invalid private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("github: private key is not RSA")
+	}
+	return key, nil
+}
+
+// SetInstallation records the installation for an owner, as delivered in webhooks
+func (a *AppTokens) SetInstallation(owner string, installationID int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.installations[owner] = installationID
+}
+
+// Token returns an installation token for owner, looking up the
+// installation when no webhook has announced it yet
+func (a *AppTokens) Token(ctx context.Context, owner string) (string, error) {
+	a.mu.Lock()
+	id, known := a.installations[owner]
+	cached, ok := a.tokens[id]
+	a.mu.Unlock()
+	if known && ok && time.Until(cached.ExpiresAt) > 5*time.Minute {
+		return cached.Token, nil
+	}
+
+	if !known {
+		var installation struct {
+			ID int64 `json:"id"`
+		}
+		if err := a.appRequest(ctx, http.MethodGet, "/users/"+owner+"/installation", &installation); err != nil {
+			return "", fmt.Errorf("github: app is not installed for %s: %w", owner, err)
+		}
+		id = installation.ID
+		a.SetInstallation(owner, id)
+	}
+
+	var token installationToken
+	if err := a.appRequest(ctx, http.MethodPost, "/app/installations/"+strconv.FormatInt(id, 10)+"/access_tokens", &token); err != nil {
+		return "", err
+	}
+	a.mu.Lock()
+	a.tokens[id] = token
+	a.mu.Unlock()
+	return token.Token, nil
+}
+
+// appRequest calls an endpoint authenticated as the app itself
+func (a *AppTokens) appRequest(ctx context.Context, method, path string, out interface{}) error {
+	jwt, err := a.jwt(time.Now())
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, a.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("github: %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return &APIError{Status: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// jwt signs the RS256 app token GitHub accepts for up to ten minutes;
+// issued-at is backdated to tolerate clock drift
+func (a *AppTokens) jwt(now time.Time) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(a.appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("github: failed to sign app token: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
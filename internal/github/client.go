@@ -0,0 +1,188 @@
+// Package github connects QLP to GitHub as a GitHub App (or with a token):
+// it opens pull requests carrying generated capsules or patches, reports
+// validation results as check runs and PR comments, and re-validates pull
+// requests from webhooks when they are updated.
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"QLP/internal/config"
+)
+
+// DefaultBaseURL is the GitHub.com REST API
+const DefaultBaseURL = "https://api.github.com"
+
+// ErrNotFound is returned for 404 responses
+var ErrNotFound = errors.New("github: not found")
+
+// TokenSource returns the token for requests about a repository owner.
+// Personal access tokens ignore the owner; apps pick the installation.
+type TokenSource interface {
+	Token(ctx context.Context, owner string) (string, error)
+}
+
+// StaticToken is a personal access or Actions token
+type StaticToken string
+
+// Token returns the token
+func (t StaticToken) Token(ctx context.Context, owner string) (string, error) {
+	return string(t), nil
+}
+
+// Client calls the GitHub REST API
+type Client struct {
+	baseURL string
+	tokens  TokenSource
+	client  *http.Client
+}
+
+// NewClient creates a client for baseURL (GitHub.com when empty, or a
+// GitHub Enterprise Server's /api/v3)
+func NewClient(baseURL string, tokens TokenSource) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		tokens:  tokens,
+		client:  &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// NewClientFromEnv authenticates as the GitHub App in QLP_GITHUB_APP_ID
+// with the key in QLP_GITHUB_PRIVATE_KEY or QLP_GITHUB_PRIVATE_KEY_PATH,
+// falling back to GITHUB_TOKEN
+func NewClientFromEnv() (*Client, error) {
+	baseURL := config.GetEnvOrDefault("QLP_GITHUB_API_URL", DefaultBaseURL)
+	if appID := config.GetEnvOrDefault("QLP_GITHUB_APP_ID", ""); appID != "" {
+		id, err := strconv.ParseInt(appID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid QLP_GITHUB_APP_ID: %w", err)
+		}
+		key := []byte(config.GetEnvOrDefault("QLP_GITHUB_PRIVATE_KEY", ""))
+		if path := config.GetEnvOrDefault("QLP_GITHUB_PRIVATE_KEY_PATH", ""); len(key) == 0 && path != "" {
+			if key, err = os.ReadFile(path); err != nil {
+				return nil, fmt.Errorf("failed to read GitHub App private key: %w", err)
+			}
+		}
+		tokens, err := NewAppTokens(baseURL, id, key)
+		if err != nil {
+			return nil, err
+		}
+		return NewClient(baseURL, tokens), nil
+	}
+	if token := config.GetEnvOrDefault("GITHUB_TOKEN", ""); token != "" {
+		return NewClient(baseURL, StaticToken(token)), nil
+	}
+	return nil, errors.New("no GitHub credentials: set QLP_GITHUB_APP_ID and a private key, or GITHUB_TOKEN")
+}
+
+// APIError is a non-2xx GitHub response
+type APIError struct {
+	Status  int
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("github: %d %s", e.Status, e.Message)
+}
+
+// Repo is an owner/name pair
+type Repo struct {
+	Owner string `json:"owner"`
+	Name  string `json:"name"`
+}
+
+// ParseRepo accepts owner/name or a github.com URL
+func ParseRepo(s string) (Repo, error) {
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "/"), ".git")
+	for _, prefix := range []string{"https://github.com/", "http://github.com/", "git@github.com:"} {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Repo{}, fmt.Errorf("invalid repository %q: expected owner/name", s)
+	}
+	return Repo{Owner: parts[0], Name: parts[1]}, nil
+}
+
+func (r Repo) String() string {
+	return r.Owner + "/" + r.Name
+}
+
+func (r Repo) path(format string, args ...interface{}) string {
+	return fmt.Sprintf("/repos/%s/%s", r.Owner, r.Name) + fmt.Sprintf(format, args...)
+}
+
+// do sends a JSON request for owner and decodes a JSON response into out
+func (c *Client) do(ctx context.Context, owner, method, path string, body, out interface{}) error {
+	resp, err := c.send(ctx, owner, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("github: failed to decode %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// send issues a request and returns the response when it succeeded
+func (c *Client) send(ctx context.Context, owner, method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.tokens != nil {
+		token, err := c.tokens.Token(ctx, owner)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("github: %s %s: %w", method, path, err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %s %s", ErrNotFound, method, path)
+		}
+		apiErr := &APIError{Status: resp.StatusCode}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if json.Unmarshal(data, apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		return nil, apiErr
+	}
+	return resp, nil
+}
@@ -0,0 +1,337 @@
+package github
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"QLP/internal/importer"
+)
+
+// fakeGitHub records the API calls QLP makes and answers the ones it needs
+type fakeGitHub struct {
+	mu       sync.Mutex
+	calls    []string
+	bodies   map[string]map[string]interface{}
+	comments []map[string]interface{}
+	tarball  []byte
+}
+
+func newFakeGitHub(t *testing.T) (*fakeGitHub, *httptest.Server) {
+	f := &fakeGitHub{bodies: make(map[string]map[string]interface{})}
+	srv := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeGitHub) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	call := r.Method + " " + r.URL.Path
+	f.calls = append(f.calls, call)
+	var body map[string]interface{}
+	if r.Body != nil {
+		data, _ := io.ReadAll(r.Body)
+		if len(data) > 0 {
+			json.Unmarshal(data, &body)
+			f.bodies[call] = body
+		}
+	}
+
+	reply := func(v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+	switch {
+	case call == "GET /repos/acme/orders":
+		reply(map[string]string{"default_branch": "main"})
+	case call == "GET /repos/acme/orders/git/ref/heads/main":
+		reply(map[string]interface{}{"object": map[string]string{"sha": "base-sha"}})
+	case call == "GET /repos/acme/orders/git/commits/base-sha":
+		reply(map[string]interface{}{"tree": map[string]string{"sha": "base-tree"}})
+	case call == "POST /repos/acme/orders/git/trees":
+		reply(map[string]string{"sha": "new-tree"})
+	case call == "POST /repos/acme/orders/git/commits":
+		reply(map[string]string{"sha": "new-commit"})
+	case call == "POST /repos/acme/orders/git/refs":
+		w.WriteHeader(http.StatusCreated)
+		reply(map[string]string{"ref": "refs/heads/qlp/change"})
+	case call == "POST /repos/acme/orders/pulls":
+		reply(map[string]interface{}{"number": 7, "html_url": "https://github.com/acme/orders/pull/7"})
+	case call == "POST /repos/acme/orders/check-runs":
+		reply(map[string]int64{"id": 99})
+	case call == "PATCH /repos/acme/orders/check-runs/99":
+		reply(map[string]int64{"id": 99})
+	case call == "GET /repos/acme/orders/issues/7/comments":
+		reply(f.comments)
+	case call == "POST /repos/acme/orders/issues/7/comments":
+		f.comments = append(f.comments, map[string]interface{}{"id": len(f.comments) + 1, "body": body["body"]})
+		reply(f.comments[len(f.comments)-1])
+	case strings.HasPrefix(call, "PATCH /repos/acme/orders/issues/comments/"):
+		reply(body)
+	case strings.HasPrefix(call, "GET /repos/acme/orders/tarball/"):
+		w.Write(f.tarball)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		reply(map[string]string{"message": "Not Found"})
+	}
+}
+
+func (f *fakeGitHub) called(call string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.calls {
+		if c == call {
+			return true
+		}
+	}
+	return false
+}
+
+func tarball(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: "acme-orders-abc123/" + name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestParseRepo(t *testing.T) {
+	for _, in := range []string{"acme/orders", "https://github.com/acme/orders.git", "git@github.com:acme/orders.git"} {
+		repo, err := ParseRepo(in)
+		if err != nil || repo.String() != "acme/orders" {
+			t.Errorf("ParseRepo(%q) = %v, %v", in, repo, err)
+		}
+	}
+	if _, err := ParseRepo("orders"); err == nil {
+		t.Error("expected an error without an owner")
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"action":"opened"}`)
+	if !VerifySignature("s3cret", body, sign("s3cret", body)) {
+		t.Error("valid signature rejected")
+	}
+	if VerifySignature("s3cret", body, sign("other", body)) {
+		t.Error("signature with the wrong secret accepted")
+	}
+	if VerifySignature("s3cret", body, "") {
+		t.Error("missing signature accepted")
+	}
+	if VerifySignature("", body, "") || VerifySignature("", body, sign("", body)) {
+		t.Error("delivery accepted without a webhook secret")
+	}
+}
+
+func TestOpenPullRequest(t *testing.T) {
+	fake, srv := newFakeGitHub(t)
+	client := NewClient(srv.URL, StaticToken("token"))
+
+	pr, err := client.OpenPullRequest(context.Background(), PullRequestOptions{
+		Repo:    Repo{Owner: "acme", Name: "orders"},
+		Branch:  "qlp/change",
+		Title:   "Add rate limiting",
+		Body:    "Generated by QLP",
+		Files:   map[string]string{"main.go": "package main\n"},
+		Deleted: []string{"old.go"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.Number != 7 {
+		t.Errorf("pull request number = %d", pr.Number)
+	}
+
+	tree := fake.bodies["POST /repos/acme/orders/git/trees"]
+	if tree["base_tree"] != "base-tree" {
+		t.Errorf("tree not based on the base branch: %v", tree["base_tree"])
+	}
+	entries := tree["tree"].([]interface{})
+	if len(entries) != 2 {
+		t.Fatalf("tree entries = %d", len(entries))
+	}
+	deleted := entries[1].(map[string]interface{})
+	if sha, ok := deleted["sha"]; deleted["path"] != "old.go" || !ok || sha != nil {
+		t.Errorf("deletion entry = %v, want a null sha", deleted)
+	}
+	if ref := fake.bodies["POST /repos/acme/orders/git/refs"]; ref["ref"] != "refs/heads/qlp/change" || ref["sha"] != "new-commit" {
+		t.Errorf("branch = %v", ref)
+	}
+	if pull := fake.bodies["POST /repos/acme/orders/pulls"]; pull["base"] != "main" || pull["head"] != "qlp/change" {
+		t.Errorf("pull request = %v", pull)
+	}
+}
+
+func TestUpsertCommentEditsExisting(t *testing.T) {
+	fake, srv := newFakeGitHub(t)
+	client := NewClient(srv.URL, nil)
+	repo := Repo{Owner: "acme", Name: "orders"}
+
+	if err := client.UpsertComment(context.Background(), repo, 7, commentMarker, "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.UpsertComment(context.Background(), repo, 7, commentMarker, "second"); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.comments) != 1 {
+		t.Errorf("comments = %d, want the first edited in place", len(fake.comments))
+	}
+	if !fake.called("PATCH /repos/acme/orders/issues/comments/1") {
+		t.Error("existing comment was not edited")
+	}
+}
+
+func TestWebhookValidatesPullRequest(t *testing.T) {
+	fake, srv := newFakeGitHub(t)
+	fake.tarball = tarball(t, map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+
+	cfg := DefaultConfig()
+	cfg.WebhookSecret = "s3cret"
+	cfg.MinScore = 50
+	svc := NewService(NewClient(srv.URL, StaticToken("token")), &importer.Analyzer{}, cfg)
+	handler := Routes(svc)["POST /github/webhook"]
+
+	payload := []byte(`{"action":"synchronize","repository":{"name":"orders","owner":{"login":"acme"}},
+		"pull_request":{"number":7,"head":{"ref":"feature","sha":"abc1234567"}}}`)
+
+	req := httptest.NewRequest(http.MethodPost, "/github/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-GitHub-Event", "pull_request")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("unsigned delivery status = %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/github/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-GitHub-Event", "pull_request")
+	req.Header.Set("X-Hub-Signature-256", sign("s3cret", payload))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	svc.Wait()
+
+	if check := fake.bodies["POST /repos/acme/orders/check-runs"]; check["head_sha"] != "abc1234567" || check["name"] != "QLP validation" {
+		t.Errorf("check run = %v", check)
+	}
+	completed := fake.bodies["PATCH /repos/acme/orders/check-runs/99"]
+	if completed["conclusion"] != ConclusionFailure {
+		t.Errorf("conclusion = %v, want failure below the minimum score", completed["conclusion"])
+	}
+	if len(fake.comments) != 1 || !strings.Contains(fake.comments[0]["body"].(string), commentMarker) {
+		t.Errorf("comments = %v", fake.comments)
+	}
+}
+
+func TestWebhookIgnoresOtherEvents(t *testing.T) {
+	fake, srv := newFakeGitHub(t)
+	cfg := DefaultConfig()
+	cfg.WebhookSecret = "s3cret"
+	svc := NewService(NewClient(srv.URL, nil), &importer.Analyzer{}, cfg)
+
+	payload := []byte(`{"action":"closed","repository":{"name":"orders","owner":{"login":"acme"}},
+		"pull_request":{"number":7,"head":{"sha":"abc"}}}`)
+	req := httptest.NewRequest(http.MethodPost, "/github/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-GitHub-Event", "pull_request")
+	req.Header.Set("X-Hub-Signature-256", sign("s3cret", payload))
+	rec := httptest.NewRecorder()
+	svc.ServeHTTP(rec, req)
+	svc.Wait()
+	if rec.Code != http.StatusNoContent || len(fake.calls) != 0 {
+		t.Errorf("closed pull request: status %d, calls %v", rec.Code, fake.calls)
+	}
+
+	// Without a secret every delivery is refused
+	svc = NewService(NewClient(srv.URL, nil), &importer.Analyzer{}, DefaultConfig())
+	req = httptest.NewRequest(http.MethodPost, "/github/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-GitHub-Event", "pull_request")
+	rec = httptest.NewRecorder()
+	svc.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("unsigned delivery without a secret: status %d", rec.Code)
+	}
+}
+
+func TestAppTokens(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	exchanges := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jwt := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		parts := strings.Split(jwt, ".")
+		if len(parts) != 3 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/users/acme/installation":
+			fmt.Fprint(w, `{"id": 42}`)
+		case "/app/installations/42/access_tokens":
+			exchanges++
+			fmt.Fprint(w, `{"token": "ghs_installation", "expires_at": "2099-01-01T00:00:00Z"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	tokens, err := NewAppTokens(srv.URL, 1234, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		token, err := tokens.Token(context.Background(), "acme")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "ghs_installation" {
+			t.Errorf("token = %q", token)
+		}
+	}
+	if exchanges != 1 {
+		t.Errorf("token exchanges = %d, want the token cached", exchanges)
+	}
+}
@@ -0,0 +1,235 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// PullRequestOptions describes a pull request built from generated files
+type PullRequestOptions struct {
+	Repo    Repo
+	Base    string            // target branch (default the repository's default branch)
+	Branch  string            // new branch for the change
+	Title   string            // pull request title, also the commit message
+	Body    string            // pull request description
+	Files   map[string]string // files to create or replace, by path
+	Deleted []string          // paths to remove
+	Draft   bool
+}
+
+// PullRequest is the subset of GitHub's pull request QLP uses
+type PullRequest struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+	State   string `json:"state"`
+	Head    struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+type treeEntry struct {
+	Path    string  `json:"path"`
+	Mode    string  `json:"mode"`
+	Type    string  `json:"type"`
+	Content *string `json:"content,omitempty"`
+	SHA     *string `json:"sha"`
+}
+
+// OpenPullRequest commits the files onto a new branch cut from the base
+// branch through the git data API, so no local checkout is needed, and
+// opens a pull request for it
+func (c *Client) OpenPullRequest(ctx context.Context, opts PullRequestOptions) (*PullRequest, error) {
+	if opts.Branch == "" || opts.Title == "" {
+		return nil, errors.New("github: pull request needs a branch and a title")
+	}
+	if len(opts.Files) == 0 && len(opts.Deleted) == 0 {
+		return nil, errors.New("github: pull request has no changes")
+	}
+	owner := opts.Repo.Owner
+
+	base := opts.Base
+	if base == "" {
+		var repo struct {
+			DefaultBranch string `json:"default_branch"`
+		}
+		if err := c.do(ctx, owner, http.MethodGet, opts.Repo.path(""), nil, &repo); err != nil {
+			return nil, err
+		}
+		base = repo.DefaultBranch
+	}
+
+	var ref struct {
+		Object struct {
+			SHA string `json:"sha"`
+		} `json:"object"`
+	}
+	if err := c.do(ctx, owner, http.MethodGet, opts.Repo.path("/git/ref/heads/%s", base), nil, &ref); err != nil {
+		return nil, fmt.Errorf("github: base branch %s: %w", base, err)
+	}
+	var baseCommit struct {
+		Tree struct {
+			SHA string `json:"sha"`
+		} `json:"tree"`
+	}
+	if err := c.do(ctx, owner, http.MethodGet, opts.Repo.path("/git/commits/%s", ref.Object.SHA), nil, &baseCommit); err != nil {
+		return nil, err
+	}
+
+	entries := make([]treeEntry, 0, len(opts.Files)+len(opts.Deleted))
+	paths := make([]string, 0, len(opts.Files))
+	for p := range opts.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		content := opts.Files[p]
+		entries = append(entries, treeEntry{Path: p, Mode: "100644", Type: "blob", Content: &content})
+	}
+	for _, p := range opts.Deleted {
+		// A null SHA removes the path from the base tree
+		entries = append(entries, treeEntry{Path: p, Mode: "100644", Type: "blob"})
+	}
+
+	var tree struct {
+		SHA string `json:"sha"`
+	}
+	if err := c.do(ctx, owner, http.MethodPost, opts.Repo.path("/git/trees"), map[string]interface{}{
+		"base_tree": baseCommit.Tree.SHA,
+		"tree":      entries,
+	}, &tree); err != nil {
+		return nil, err
+	}
+	var commit struct {
+		SHA string `json:"sha"`
+	}
+	if err := c.do(ctx, owner, http.MethodPost, opts.Repo.path("/git/commits"), map[string]interface{}{
+		"message": opts.Title,
+		"tree":    tree.SHA,
+		"parents": []string{ref.Object.SHA},
+	}, &commit); err != nil {
+		return nil, err
+	}
+	if err := c.do(ctx, owner, http.MethodPost, opts.Repo.path("/git/refs"), map[string]string{
+		"ref": "refs/heads/" + opts.Branch,
+		"sha": commit.SHA,
+	}, nil); err != nil {
+		return nil, fmt.Errorf("github: failed to create branch %s: %w", opts.Branch, err)
+	}
+	return c.CreatePullRequest(ctx, opts.Repo, opts.Branch, base, opts.Title, opts.Body, opts.Draft)
+}
+
+// CreatePullRequest opens a pull request for a branch that already exists,
+// e.g. one pushed by qlp modify
+func (c *Client) CreatePullRequest(ctx context.Context, repo Repo, head, base, title, body string, draft bool) (*PullRequest, error) {
+	if base == "" {
+		var info struct {
+			DefaultBranch string `json:"default_branch"`
+		}
+		if err := c.do(ctx, repo.Owner, http.MethodGet, repo.path(""), nil, &info); err != nil {
+			return nil, err
+		}
+		base = info.DefaultBranch
+	}
+	var pr PullRequest
+	if err := c.do(ctx, repo.Owner, http.MethodPost, repo.path("/pulls"), map[string]interface{}{
+		"title": title,
+		"head":  head,
+		"base":  base,
+		"body":  body,
+		"draft": draft,
+	}, &pr); err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+// GetPullRequest fetches a pull request by number
+func (c *Client) GetPullRequest(ctx context.Context, repo Repo, number int) (*PullRequest, error) {
+	var pr PullRequest
+	if err := c.do(ctx, repo.Owner, http.MethodGet, repo.path("/pulls/%d", number), nil, &pr); err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+// Check run conclusions
+const (
+	ConclusionSuccess = "success"
+	ConclusionFailure = "failure"
+	ConclusionNeutral = "neutral"
+)
+
+// maxCheckOutput is GitHub's limit on check run summary and text
+const maxCheckOutput = 65535
+
+// CheckOutput is the title and markdown shown on a check run
+type CheckOutput struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+	Text    string `json:"text,omitempty"`
+}
+
+// CreateCheckRun starts an in-progress check run on a commit
+func (c *Client) CreateCheckRun(ctx context.Context, repo Repo, name, headSHA, detailsURL string) (int64, error) {
+	body := map[string]interface{}{
+		"name":     name,
+		"head_sha": headSHA,
+		"status":   "in_progress",
+	}
+	if detailsURL != "" {
+		body["details_url"] = detailsURL
+	}
+	var run struct {
+		ID int64 `json:"id"`
+	}
+	if err := c.do(ctx, repo.Owner, http.MethodPost, repo.path("/check-runs"), body, &run); err != nil {
+		return 0, err
+	}
+	return run.ID, nil
+}
+
+// CompleteCheckRun finishes a check run with a conclusion and its output
+func (c *Client) CompleteCheckRun(ctx context.Context, repo Repo, id int64, conclusion string, output CheckOutput) error {
+	output.Summary = truncate(output.Summary, maxCheckOutput)
+	output.Text = truncate(output.Text, maxCheckOutput)
+	return c.do(ctx, repo.Owner, http.MethodPatch, repo.path("/check-runs/%d", id), map[string]interface{}{
+		"status":     "completed",
+		"conclusion": conclusion,
+		"output":     output,
+	}, nil)
+}
+
+// UpsertComment keeps a single comment per marker on a pull request: the
+// comment containing marker is edited in place, or created when missing
+func (c *Client) UpsertComment(ctx context.Context, repo Repo, number int, marker, body string) error {
+	body = marker + "\n" + body
+	var comments []struct {
+		ID   int64  `json:"id"`
+		Body string `json:"body"`
+	}
+	if err := c.do(ctx, repo.Owner, http.MethodGet, repo.path("/issues/%d/comments?per_page=100", number), nil, &comments); err != nil {
+		return err
+	}
+	for _, comment := range comments {
+		if strings.Contains(comment.Body, marker) {
+			return c.do(ctx, repo.Owner, http.MethodPatch, repo.path("/issues/comments/%d", comment.ID), map[string]string{"body": body}, nil)
+		}
+	}
+	return c.do(ctx, repo.Owner, http.MethodPost, repo.path("/issues/%d/comments", number), map[string]string{"body": body}, nil)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	const suffix = "\n\n… truncated"
+	return s[:n-len(suffix)] + suffix
+}
@@ -0,0 +1,368 @@
+package github
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"QLP/internal/config"
+	"QLP/internal/importer"
+	"QLP/internal/logger"
+	"QLP/internal/report"
+
+	"go.uber.org/zap"
+)
+
+// commentMarker identifies QLP's validation comment so re-validation edits
+// it instead of adding another
+const commentMarker = "<!-- qlp-validation -->"
+
+// Config controls how pull requests are validated
+type Config struct {
+	WebhookSecret string        // shared secret for X-Hub-Signature-256, required
+	CheckName     string        // check run name shown on the pull request
+	MinScore      int           // overall score below which the check fails
+	Timeout       time.Duration // limit on validating one pull request
+	Comment       bool          // also post the report as a pull request comment
+}
+
+// DefaultConfig fails the "QLP validation" check below a score of 70
+func DefaultConfig() Config {
+	return Config{
+		CheckName: "QLP validation",
+		MinScore:  70,
+		Timeout:   15 * time.Minute,
+		Comment:   true,
+	}
+}
+
+// ConfigFromEnv reads QLP_GITHUB_WEBHOOK_SECRET, QLP_GITHUB_CHECK_NAME,
+// QLP_GITHUB_MIN_SCORE, QLP_GITHUB_VALIDATION_TIMEOUT and QLP_GITHUB_COMMENT
+func ConfigFromEnv() Config {
+	cfg := DefaultConfig()
+	cfg.WebhookSecret = config.GetEnvOrDefault("QLP_GITHUB_WEBHOOK_SECRET", "")
+	cfg.CheckName = config.GetEnvOrDefault("QLP_GITHUB_CHECK_NAME", cfg.CheckName)
+	if v, err := strconv.Atoi(config.GetEnvOrDefault("QLP_GITHUB_MIN_SCORE", "")); err == nil && v >= 0 {
+		cfg.MinScore = v
+	}
+	if v, err := time.ParseDuration(config.GetEnvOrDefault("QLP_GITHUB_VALIDATION_TIMEOUT", "")); err == nil && v > 0 {
+		cfg.Timeout = v
+	}
+	cfg.Comment = config.GetEnvOrDefault("QLP_GITHUB_COMMENT", "true") == "true"
+	return cfg
+}
+
+// Service receives GitHub webhooks and validates pull requests: every
+// opened, reopened or updated pull request is downloaded at its head
+// commit, assessed by the analyzer and reported as a check run and a
+// pull request comment
+type Service struct {
+	client   *Client
+	analyzer *importer.Analyzer
+	cfg      Config
+
+	mu       sync.Mutex
+	inflight map[string]*validation // repo#number -> running validation
+	wg       sync.WaitGroup
+}
+
+// NewService creates a webhook service
+func NewService(client *Client, analyzer *importer.Analyzer, cfg Config) *Service {
+	if cfg.CheckName == "" {
+		cfg.CheckName = DefaultConfig().CheckName
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultConfig().Timeout
+	}
+	return &Service{
+		client:   client,
+		analyzer: analyzer,
+		cfg:      cfg,
+		inflight: make(map[string]*validation),
+	}
+}
+
+// Routes returns the webhook endpoint:
+//
+//	POST /github/webhook   receives pull_request and check_run events
+func Routes(s *Service) map[string]http.Handler {
+	return map[string]http.Handler{
+		"POST /github/webhook": s,
+	}
+}
+
+type webhookPayload struct {
+	Action       string `json:"action"`
+	Installation *struct {
+		ID int64 `json:"id"`
+	} `json:"installation"`
+	Repository struct {
+		Name  string `json:"name"`
+		Owner struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
+	PullRequest *PullRequest `json:"pull_request"`
+	CheckRun    *struct {
+		Name         string `json:"name"`
+		HeadSHA      string `json:"head_sha"`
+		PullRequests []struct {
+			Number int `json:"number"`
+		} `json:"pull_requests"`
+	} `json:"check_run"`
+}
+
+// ServeHTTP verifies and dispatches a webhook delivery. Validation runs in
+// the background because GitHub expects a reply within ten seconds.
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 25<<20))
+	if err != nil {
+		http.Error(w, "failed to read payload", http.StatusBadRequest)
+		return
+	}
+	if !VerifySignature(s.cfg.WebhookSecret, body, r.Header.Get("X-Hub-Signature-256")) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "invalid payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	repo := Repo{Owner: payload.Repository.Owner.Login, Name: payload.Repository.Name}
+	if payload.Installation != nil {
+		if app, ok := s.client.tokens.(*AppTokens); ok {
+			app.SetInstallation(repo.Owner, payload.Installation.ID)
+		}
+	}
+
+	number, headSHA := 0, ""
+	switch event := r.Header.Get("X-GitHub-Event"); event {
+	case "ping":
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case "pull_request":
+		switch payload.Action {
+		case "opened", "reopened", "synchronize", "ready_for_review":
+			if payload.PullRequest != nil {
+				number, headSHA = payload.PullRequest.Number, payload.PullRequest.Head.SHA
+			}
+		}
+	case "check_run":
+		if run := payload.CheckRun; payload.Action == "rerequested" && run != nil &&
+			run.Name == s.cfg.CheckName && len(run.PullRequests) > 0 {
+			number, headSHA = run.PullRequests[0].Number, run.HeadSHA
+		}
+	}
+	if number == 0 || headSHA == "" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	s.start(repo, number, headSHA)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// VerifySignature checks a webhook body against its X-Hub-Signature-256
+// header. Without a secret no delivery can be verified, so none is accepted.
+func VerifySignature(secret string, body []byte, header string) bool {
+	if secret == "" {
+		return false
+	}
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+type validation struct {
+	cancel context.CancelFunc
+}
+
+// start validates a pull request in the background, cancelling a
+// validation of an older head commit of the same pull request
+func (s *Service) start(repo Repo, number int, headSHA string) {
+	key := fmt.Sprintf("%s#%d", repo, number)
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	v := &validation{cancel: cancel}
+
+	s.mu.Lock()
+	if previous, ok := s.inflight[key]; ok {
+		previous.cancel()
+	}
+	s.inflight[key] = v
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			cancel()
+			s.mu.Lock()
+			if s.inflight[key] == v {
+				delete(s.inflight, key)
+			}
+			s.mu.Unlock()
+		}()
+		if err := s.Validate(ctx, repo, number, headSHA); err != nil {
+			logger.WithComponent("github").Warn("Pull request validation failed",
+				zap.String("repo", repo.String()), zap.Int("pull_request", number), zap.Error(err))
+		}
+	}()
+}
+
+// Wait blocks until background validations have finished
+func (s *Service) Wait() {
+	s.wg.Wait()
+}
+
+// Validate assesses a pull request's head commit and reports the result as
+// a check run and, when configured, a pull request comment
+func (s *Service) Validate(ctx context.Context, repo Repo, number int, headSHA string) error {
+	checkID, err := s.client.CreateCheckRun(ctx, repo, s.cfg.CheckName, headSHA, "")
+	if err != nil {
+		return fmt.Errorf("failed to create check run: %w", err)
+	}
+
+	assessment, err := s.assess(ctx, repo, headSHA)
+	if err != nil {
+		// Report on a fresh context so a timeout still completes the check
+		done, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		output := CheckOutput{Title: "Validation did not complete", Summary: err.Error()}
+		if cerr := s.client.CompleteCheckRun(done, repo, checkID, ConclusionNeutral, output); cerr != nil {
+			return errors.Join(err, cerr)
+		}
+		return err
+	}
+
+	markdown := string(report.Markdown(assessment.Report()))
+	conclusion := ConclusionSuccess
+	title := fmt.Sprintf("Overall score %d/100", assessment.OverallScore)
+	if assessment.OverallScore < s.cfg.MinScore {
+		conclusion = ConclusionFailure
+		title += fmt.Sprintf(", below the minimum of %d", s.cfg.MinScore)
+	}
+	if err := s.client.CompleteCheckRun(ctx, repo, checkID, conclusion, CheckOutput{
+		Title:   title,
+		Summary: markdown,
+	}); err != nil {
+		return fmt.Errorf("failed to complete check run: %w", err)
+	}
+
+	if s.cfg.Comment {
+		icon := "✅"
+		if conclusion == ConclusionFailure {
+			icon = "❌"
+		}
+		comment := fmt.Sprintf("%s **%s** for %s: %s\n\n<details><summary>Report</summary>\n\n%s\n</details>\n",
+			icon, s.cfg.CheckName, shortSHA(headSHA), title, markdown)
+		if err := s.client.UpsertComment(ctx, repo, number, commentMarker, truncate(comment, maxCheckOutput)); err != nil {
+			return fmt.Errorf("failed to comment on pull request: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Service) assess(ctx context.Context, repo Repo, ref string) (*importer.Assessment, error) {
+	dir, err := os.MkdirTemp("", "qlp-github-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	opts := importer.DefaultOptions()
+	if err := s.client.DownloadTarball(ctx, repo, ref, dir, opts.MaxTotalBytes); err != nil {
+		return nil, err
+	}
+	drop, inv, err := importer.Ingest(dir, repo.Name, opts)
+	if err != nil {
+		return nil, err
+	}
+	inv.Source = fmt.Sprintf("%s@%s", repo, ref)
+	return s.analyzer.Analyze(ctx, drop, inv), nil
+}
+
+// DownloadTarball extracts the repository at ref into dir, dropping the
+// archive's top-level directory. Extraction stops at maxBytes.
+func (c *Client) DownloadTarball(ctx context.Context, repo Repo, ref, dir string, maxBytes int64) error {
+	resp, err := c.send(ctx, repo.Owner, http.MethodGet, repo.path("/tarball/%s", ref), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return fmt.Errorf("github: invalid tarball: %w", err)
+	}
+	defer gz.Close()
+
+	var total int64
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("github: invalid tarball: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		// Entries are prefixed with <owner>-<repo>-<sha>/
+		_, rel, ok := strings.Cut(hdr.Name, "/")
+		if !ok || rel == "" {
+			continue
+		}
+		rel = filepath.Clean(filepath.FromSlash(rel))
+		if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if total += hdr.Size; total > maxBytes {
+			return nil
+		}
+		target := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, io.LimitReader(tr, hdr.Size))
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,247 @@
+// Package headless lets the demo and end-to-end binaries under cmd/ run
+// unattended in CI: confirmations and pauses are answered by flags or
+// environment variables, and results are reported as JSON with exit codes.
+package headless
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"QLP/internal/config"
+)
+
+// Exit codes shared by the cmd binaries
+const (
+	ExitPassed    = 0
+	ExitFailed    = 1
+	ExitUsage     = 2 // bad flags or missing configuration
+	ExitCancelled = 3 // declined at a confirmation
+)
+
+// ErrCancelled is recorded when a confirmation is declined
+var ErrCancelled = errors.New("cancelled at confirmation")
+
+// Options are the headless flags:
+//
+//	--yes       answer yes to every confirmation (QLP_ASSUME_YES)
+//	--no-wait   skip pauses for manual verification (QLP_NO_WAIT)
+//	--scenario  scenario to run (QLP_SCENARIO)
+//	--output    text or json (QLP_OUTPUT)
+type Options struct {
+	Yes      bool
+	NoWait   bool
+	Scenario string
+	Output   string
+
+	stdout      io.Writer
+	in          *bufio.Reader
+	interactive bool
+}
+
+// RegisterFlags adds the headless flags to fs, defaulting from the environment
+func RegisterFlags(fs *flag.FlagSet, defaultScenario string) *Options {
+	o := &Options{
+		stdout:      os.Stdout,
+		in:          bufio.NewReader(os.Stdin),
+		interactive: isTerminal(os.Stdin),
+	}
+	fs.BoolVar(&o.Yes, "yes", envBool("QLP_ASSUME_YES"), "answer yes to every confirmation")
+	fs.BoolVar(&o.NoWait, "no-wait", envBool("QLP_NO_WAIT"), "skip pauses for manual verification")
+	fs.StringVar(&o.Scenario, "scenario", envOr("QLP_SCENARIO", defaultScenario), "scenario to run")
+	fs.StringVar(&o.Output, "output", envOr("QLP_OUTPUT", "text"), "result format: text or json")
+	return o
+}
+
+// Parse loads .env and the qlp.yaml profile like the qlp binary, registers
+// the headless flags on the command line, parses it and validates the
+// scenario against the ones the binary supports. Invalid configuration or
+// flags exit with ExitUsage.
+func Parse(scenarios ...string) *Options {
+	if _, err := config.Init(""); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(ExitUsage)
+	}
+	o := RegisterFlags(flag.CommandLine, scenarios[0])
+	flag.Parse()
+	if err := o.validate(scenarios); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(ExitUsage)
+	}
+	o.Start()
+	return o
+}
+
+func (o *Options) validate(scenarios []string) error {
+	if o.Output != "text" && o.Output != "json" {
+		return fmt.Errorf("invalid --output %q: expected text or json", o.Output)
+	}
+	for _, s := range scenarios {
+		if o.Scenario == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown --scenario %q: expected one of %s", o.Scenario, strings.Join(scenarios, ", "))
+}
+
+// JSON reports whether results are printed as JSON
+func (o *Options) JSON() bool {
+	return o.Output == "json"
+}
+
+// Start routes human-readable progress to stderr in JSON mode so stdout
+// carries only the result document
+func (o *Options) Start() {
+	if o.JSON() {
+		os.Stdout = os.Stderr
+	}
+}
+
+// Confirm asks a yes/no question. --yes answers yes; without a terminal the
+// default is used so nothing blocks.
+func (o *Options) Confirm(prompt string, def bool) bool {
+	if o.Yes {
+		return true
+	}
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	if !o.interactive {
+		fmt.Fprintf(os.Stderr, "%s (%s): non-interactive, using default %v (pass --yes to confirm)\n", prompt, hint, def)
+		return def
+	}
+
+	fmt.Fprintf(os.Stderr, "%s (%s): ", prompt, hint)
+	response, _ := o.in.ReadString('\n')
+	switch strings.TrimSpace(strings.ToLower(response)) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	default:
+		return def
+	}
+}
+
+// Pause waits for ENTER unless --no-wait is set or there is no terminal
+func (o *Options) Pause(prompt string) {
+	if o.NoWait || !o.interactive {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s: ", prompt)
+	o.in.ReadString('\n')
+}
+
+// Status is the outcome of a run
+type Status string
+
+const (
+	StatusPassed    Status = "passed"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+// Step is one checked stage of a run
+type Step struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// Result is the structured outcome printed with --output json
+type Result struct {
+	Command    string                 `json:"command"`
+	Scenario   string                 `json:"scenario"`
+	Status     Status                 `json:"status"`
+	Steps      []Step                 `json:"steps"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	StartedAt  time.Time              `json:"started_at"`
+	DurationMS int64                  `json:"duration_ms"`
+
+	exitCode int
+}
+
+// NewResult starts recording a run
+func (o *Options) NewResult(command string) *Result {
+	return &Result{
+		Command:   command,
+		Scenario:  o.Scenario,
+		Status:    StatusPassed,
+		Steps:     []Step{},
+		Details:   make(map[string]interface{}),
+		StartedAt: time.Now(),
+	}
+}
+
+// Step records a stage; a non-nil err fails the run
+func (r *Result) Step(name string, err error) {
+	step := Step{Name: name, Passed: err == nil}
+	if err != nil {
+		step.Message = err.Error()
+		r.fail(err, ExitFailed)
+	}
+	r.Steps = append(r.Steps, step)
+}
+
+// Fail marks the run failed; configuration problems exit with ExitUsage
+func (r *Result) Fail(err error, exitCode int) {
+	r.fail(err, exitCode)
+}
+
+// Cancel marks the run declined at a confirmation
+func (r *Result) Cancel() {
+	r.Status = StatusCancelled
+	r.Error = ErrCancelled.Error()
+	r.exitCode = ExitCancelled
+}
+
+func (r *Result) fail(err error, exitCode int) {
+	if r.Status == StatusFailed {
+		return
+	}
+	r.Status = StatusFailed
+	r.Error = err.Error()
+	r.exitCode = exitCode
+}
+
+// ExitCode is the process exit status for the result
+func (r *Result) ExitCode() int {
+	return r.exitCode
+}
+
+// Finish prints the result in JSON mode and exits with its code
+func (o *Options) Finish(r *Result) {
+	r.DurationMS = time.Since(r.StartedAt).Milliseconds()
+	if o.JSON() {
+		enc := json.NewEncoder(o.stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(r)
+	}
+	os.Exit(r.ExitCode())
+}
+
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+func envBool(key string) bool {
+	v, _ := strconv.ParseBool(os.Getenv(key))
+	return v
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
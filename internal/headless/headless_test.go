@@ -0,0 +1,70 @@
+package headless
+
+import (
+	"errors"
+	"flag"
+	"testing"
+)
+
+func TestRegisterFlagsEnvironmentDefaults(t *testing.T) {
+	t.Setenv("QLP_ASSUME_YES", "true")
+	t.Setenv("QLP_SCENARIO", "keep")
+	t.Setenv("QLP_OUTPUT", "json")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	o := RegisterFlags(fs, "verify")
+	if err := fs.Parse([]string{"--no-wait"}); err != nil {
+		t.Fatal(err)
+	}
+	if !o.Yes || !o.NoWait || o.Scenario != "keep" || !o.JSON() {
+		t.Errorf("options = %+v", o)
+	}
+	if err := o.validate([]string{"verify", "keep"}); err != nil {
+		t.Errorf("validate() error = %v", err)
+	}
+	if err := o.validate([]string{"verify"}); err == nil {
+		t.Error("validate() accepted an unknown scenario")
+	}
+}
+
+func TestConfirmWithoutTerminalUsesDefault(t *testing.T) {
+	o := &Options{}
+	if o.Confirm("Continue?", false) {
+		t.Error("Confirm() = true, want the default false")
+	}
+	if !o.Confirm("Delete?", true) {
+		t.Error("Confirm() = false, want the default true")
+	}
+	o.Yes = true
+	if !o.Confirm("Continue?", false) {
+		t.Error("Confirm() with --yes = false")
+	}
+}
+
+func TestResultExitCodes(t *testing.T) {
+	o := &Options{Scenario: "verify"}
+
+	r := o.NewResult("cmd")
+	r.Step("create", nil)
+	if r.Status != StatusPassed || r.ExitCode() != ExitPassed {
+		t.Errorf("passed run = %s/%d", r.Status, r.ExitCode())
+	}
+
+	r.Step("delete", errors.New("boom"))
+	r.Step("list", errors.New("later"))
+	if r.Status != StatusFailed || r.ExitCode() != ExitFailed || r.Error != "boom" {
+		t.Errorf("failed run = %s/%d %q, want the first failure", r.Status, r.ExitCode(), r.Error)
+	}
+
+	r = o.NewResult("cmd")
+	r.Fail(errors.New("no subscription"), ExitUsage)
+	if r.ExitCode() != ExitUsage {
+		t.Errorf("config failure exit = %d, want %d", r.ExitCode(), ExitUsage)
+	}
+
+	r = o.NewResult("cmd")
+	r.Cancel()
+	if r.Status != StatusCancelled || r.ExitCode() != ExitCancelled {
+		t.Errorf("cancelled run = %s/%d", r.Status, r.ExitCode())
+	}
+}
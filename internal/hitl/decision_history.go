@@ -0,0 +1,535 @@
+package hitl
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DecisionRecordType classifies entries in the decision audit trail
+type DecisionRecordType string
+
+const (
+	DecisionRecordDecision     DecisionRecordType = "decision"
+	DecisionRecordAutoApproval DecisionRecordType = "auto_approval"
+	DecisionRecordOverride     DecisionRecordType = "override"
+)
+
+// DecisionRecord is a single auditable entry: who decided what, when and why
+type DecisionRecord struct {
+	ID             string             `json:"id"`
+	Type           DecisionRecordType `json:"type"`
+	DecisionID     string             `json:"decision_id"`
+	DropID         string             `json:"drop_id"`
+	CapsuleID      string             `json:"capsule_id,omitempty"`
+	Action         HITLAction         `json:"action"`
+	PreviousAction HITLAction         `json:"previous_action,omitempty"`
+	Actor          string             `json:"actor"`
+	Reason         string             `json:"reason"`
+	Confidence     float64            `json:"confidence"`
+	AutoApproved   bool               `json:"auto_approved"`
+	FailedGates    []string           `json:"failed_gates,omitempty"`
+	RecordedAt     time.Time          `json:"recorded_at"`
+	Decision       *HITLDecision      `json:"decision,omitempty"`
+}
+
+// DecisionStore persists decision records so the audit trail survives restarts
+type DecisionStore interface {
+	Append(record DecisionRecord) error
+	Load() ([]DecisionRecord, error)
+}
+
+// FileDecisionStore appends decision records to a JSON Lines file
+type FileDecisionStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileDecisionStore creates a file-backed decision store
+func NewFileDecisionStore(path string) (*FileDecisionStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create audit directory: %w", err)
+		}
+	}
+	return &FileDecisionStore{path: path}, nil
+}
+
+// Append writes a single record to the end of the file
+func (fs *FileDecisionStore) Append(record DecisionRecord) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal decision record: %w", err)
+	}
+
+	f, err := os.OpenFile(fs.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write decision record: %w", err)
+	}
+	return nil
+}
+
+// Load reads all records previously written to the file
+func (fs *FileDecisionStore) Load() ([]DecisionRecord, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	f, err := os.Open(fs.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open audit file: %w", err)
+	}
+	defer f.Close()
+
+	records := make([]DecisionRecord, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var record DecisionRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			log.Printf("Skipping malformed decision record: %v", err)
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit file: %w", err)
+	}
+	return records, nil
+}
+
+// DecisionHistory tracks decision history and provides audit queries
+type DecisionHistory struct {
+	mu        sync.RWMutex
+	decisions []HITLDecision
+	records   []DecisionRecord
+	store     DecisionStore
+}
+
+var (
+	defaultHistory     *DecisionHistory
+	defaultHistoryOnce sync.Once
+)
+
+// DefaultDecisionHistory returns the process-wide decision history, opened
+// once from QLP_HITL_AUDIT_LOG, so every component recording decisions
+// appends to the same file and queries see all of them
+func DefaultDecisionHistory() *DecisionHistory {
+	defaultHistoryOnce.Do(func() {
+		defaultHistory = NewDecisionHistory()
+	})
+	return defaultHistory
+}
+
+// NewDecisionHistory creates a decision history. When QLP_HITL_AUDIT_LOG is
+// set, records are persisted to that file and previous records are loaded.
+// Components of a server share DefaultDecisionHistory instead.
+func NewDecisionHistory() *DecisionHistory {
+	path := os.Getenv("QLP_HITL_AUDIT_LOG")
+	if path == "" {
+		return NewDecisionHistoryWithStore(nil)
+	}
+
+	store, err := NewFileDecisionStore(path)
+	if err != nil {
+		log.Printf("Failed to open HITL audit log, using in-memory history: %v", err)
+		return NewDecisionHistoryWithStore(nil)
+	}
+	return NewDecisionHistoryWithStore(store)
+}
+
+// NewDecisionHistoryWithStore creates a decision history backed by the given store
+func NewDecisionHistoryWithStore(store DecisionStore) *DecisionHistory {
+	dh := &DecisionHistory{
+		decisions: make([]HITLDecision, 0),
+		records:   make([]DecisionRecord, 0),
+		store:     store,
+	}
+
+	if store != nil {
+		records, err := store.Load()
+		if err != nil {
+			log.Printf("Failed to load HITL audit records: %v", err)
+		}
+		for _, record := range records {
+			dh.records = append(dh.records, record)
+			if record.Decision != nil && record.Type != DecisionRecordOverride {
+				dh.decisions = append(dh.decisions, *record.Decision)
+			}
+		}
+	}
+
+	return dh
+}
+
+// RecordDecision records an engine decision, classifying auto-approvals separately
+func (dh *DecisionHistory) RecordDecision(decision *HITLDecision) {
+	recordType := DecisionRecordDecision
+	if decision.AutoApproved {
+		recordType = DecisionRecordAutoApproval
+	}
+
+	record := DecisionRecord{
+		ID:           fmt.Sprintf("%s_%s", decision.ID, recordType),
+		Type:         recordType,
+		DecisionID:   decision.ID,
+		DropID:       decision.DropID,
+		CapsuleID:    decision.CapsuleID,
+		Action:       decision.Action,
+		Actor:        decision.DecisionMadeBy,
+		Reason:       decision.Reason,
+		Confidence:   decision.Confidence,
+		AutoApproved: decision.AutoApproved,
+		FailedGates:  failedGateNames(decision.QualityGates),
+		RecordedAt:   decision.DecisionMadeAt,
+		Decision:     decision,
+	}
+	if record.RecordedAt.IsZero() {
+		record.RecordedAt = time.Now()
+	}
+
+	dh.mu.Lock()
+	dh.decisions = append(dh.decisions, *decision)
+	dh.records = append(dh.records, record)
+	dh.mu.Unlock()
+
+	dh.persist(record)
+}
+
+// RecordOverride records a human override of a previous decision
+func (dh *DecisionHistory) RecordOverride(decisionID string, action HITLAction, actor, reason string) (*DecisionRecord, error) {
+	if actor == "" {
+		return nil, fmt.Errorf("override requires an actor")
+	}
+	if reason == "" {
+		return nil, fmt.Errorf("override requires a reason")
+	}
+
+	dh.mu.Lock()
+	var original *HITLDecision
+	for i := range dh.decisions {
+		if dh.decisions[i].ID == decisionID {
+			original = &dh.decisions[i]
+		}
+	}
+	if original == nil {
+		dh.mu.Unlock()
+		return nil, fmt.Errorf("decision not found: %s", decisionID)
+	}
+
+	previousAction := original.Action
+	for _, r := range dh.records {
+		if r.DecisionID == decisionID && r.Type == DecisionRecordOverride {
+			previousAction = r.Action
+		}
+	}
+
+	now := time.Now()
+	record := DecisionRecord{
+		ID:             fmt.Sprintf("%s_override_%d", decisionID, now.UnixNano()),
+		Type:           DecisionRecordOverride,
+		DecisionID:     decisionID,
+		DropID:         original.DropID,
+		CapsuleID:      original.CapsuleID,
+		Action:         action,
+		PreviousAction: previousAction,
+		Actor:          actor,
+		Reason:         reason,
+		Confidence:     1.0,
+		FailedGates:    failedGateNames(original.QualityGates),
+		RecordedAt:     now,
+	}
+	dh.records = append(dh.records, record)
+	dh.mu.Unlock()
+
+	dh.persist(record)
+	return &record, nil
+}
+
+func (dh *DecisionHistory) persist(record DecisionRecord) {
+	if dh.store == nil {
+		return
+	}
+	if err := dh.store.Append(record); err != nil {
+		log.Printf("Failed to persist HITL decision record %s: %v", record.ID, err)
+	}
+}
+
+// DecisionQuery filters decision records. Zero values match everything.
+type DecisionQuery struct {
+	DropID    string
+	CapsuleID string
+	Actor     string
+	Action    HITLAction
+	Types     []DecisionRecordType
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+func (q DecisionQuery) matches(r DecisionRecord) bool {
+	if q.DropID != "" && r.DropID != q.DropID {
+		return false
+	}
+	if q.CapsuleID != "" && r.CapsuleID != q.CapsuleID {
+		return false
+	}
+	if q.Actor != "" && r.Actor != q.Actor {
+		return false
+	}
+	if q.Action != "" && r.Action != q.Action {
+		return false
+	}
+	if len(q.Types) > 0 {
+		found := false
+		for _, t := range q.Types {
+			if r.Type == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !q.Since.IsZero() && r.RecordedAt.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !r.RecordedAt.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+// Query returns records matching the filter, oldest first
+func (dh *DecisionHistory) Query(q DecisionQuery) []DecisionRecord {
+	dh.mu.RLock()
+	defer dh.mu.RUnlock()
+
+	results := make([]DecisionRecord, 0)
+	for _, r := range dh.records {
+		if q.matches(r) {
+			results = append(results, r)
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].RecordedAt.Before(results[j].RecordedAt)
+	})
+
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[len(results)-q.Limit:]
+	}
+	return results
+}
+
+// GetDecision returns a recorded decision by ID
+func (dh *DecisionHistory) GetDecision(decisionID string) (*HITLDecision, bool) {
+	dh.mu.RLock()
+	defer dh.mu.RUnlock()
+
+	for i := range dh.decisions {
+		if dh.decisions[i].ID == decisionID {
+			decision := dh.decisions[i]
+			return &decision, true
+		}
+	}
+	return nil, false
+}
+
+// GateFailureCount counts how often a quality gate failed
+type GateFailureCount struct {
+	Gate  string `json:"gate"`
+	Count int    `json:"count"`
+}
+
+// DecisionStatsBucket aggregates decisions in a time period
+type DecisionStatsBucket struct {
+	PeriodStart      time.Time `json:"period_start"`
+	Decisions        int       `json:"decisions"`
+	AutoApproved     int       `json:"auto_approved"`
+	Overrides        int       `json:"overrides"`
+	AutoApprovalRate float64   `json:"auto_approval_rate"`
+}
+
+// DecisionStats summarises the decision history for auditors
+type DecisionStats struct {
+	TotalDecisions   int                   `json:"total_decisions"`
+	AutoApproved     int                   `json:"auto_approved"`
+	Overrides        int                   `json:"overrides"`
+	AutoApprovalRate float64               `json:"auto_approval_rate"`
+	OverrideRate     float64               `json:"override_rate"`
+	ActionCounts     map[HITLAction]int    `json:"action_counts"`
+	TopFailedGates   []GateFailureCount    `json:"top_failed_gates"`
+	Buckets          []DecisionStatsBucket `json:"buckets,omitempty"`
+}
+
+// Stats computes aggregate statistics over matching records. When period is
+// positive, results are also grouped into buckets of that length.
+func (dh *DecisionHistory) Stats(q DecisionQuery, period time.Duration) *DecisionStats {
+	q.Limit = 0
+	records := dh.Query(q)
+
+	stats := &DecisionStats{
+		ActionCounts:   make(map[HITLAction]int),
+		TopFailedGates: make([]GateFailureCount, 0),
+	}
+
+	gateCounts := make(map[string]int)
+	buckets := make(map[int64]*DecisionStatsBucket)
+
+	for _, r := range records {
+		var bucket *DecisionStatsBucket
+		if period > 0 {
+			start := r.RecordedAt.Truncate(period)
+			bucket = buckets[start.UnixNano()]
+			if bucket == nil {
+				bucket = &DecisionStatsBucket{PeriodStart: start}
+				buckets[start.UnixNano()] = bucket
+			}
+		}
+
+		if r.Type == DecisionRecordOverride {
+			stats.Overrides++
+			if bucket != nil {
+				bucket.Overrides++
+			}
+			continue
+		}
+
+		stats.TotalDecisions++
+		stats.ActionCounts[r.Action]++
+		if r.AutoApproved {
+			stats.AutoApproved++
+		}
+		for _, gate := range r.FailedGates {
+			gateCounts[gate]++
+		}
+		if bucket != nil {
+			bucket.Decisions++
+			if r.AutoApproved {
+				bucket.AutoApproved++
+			}
+		}
+	}
+
+	if stats.TotalDecisions > 0 {
+		stats.AutoApprovalRate = float64(stats.AutoApproved) / float64(stats.TotalDecisions)
+		stats.OverrideRate = float64(stats.Overrides) / float64(stats.TotalDecisions)
+	}
+
+	for gate, count := range gateCounts {
+		stats.TopFailedGates = append(stats.TopFailedGates, GateFailureCount{Gate: gate, Count: count})
+	}
+	sort.Slice(stats.TopFailedGates, func(i, j int) bool {
+		if stats.TopFailedGates[i].Count != stats.TopFailedGates[j].Count {
+			return stats.TopFailedGates[i].Count > stats.TopFailedGates[j].Count
+		}
+		return stats.TopFailedGates[i].Gate < stats.TopFailedGates[j].Gate
+	})
+
+	for _, bucket := range buckets {
+		if bucket.Decisions > 0 {
+			bucket.AutoApprovalRate = float64(bucket.AutoApproved) / float64(bucket.Decisions)
+		}
+		stats.Buckets = append(stats.Buckets, *bucket)
+	}
+	sort.Slice(stats.Buckets, func(i, j int) bool {
+		return stats.Buckets[i].PeriodStart.Before(stats.Buckets[j].PeriodStart)
+	})
+
+	return stats
+}
+
+// ExportJSON writes matching records, including full decision context, as JSON
+func (dh *DecisionHistory) ExportJSON(w io.Writer, q DecisionQuery) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(dh.Query(q)); err != nil {
+		return fmt.Errorf("failed to export decision records: %w", err)
+	}
+	return nil
+}
+
+// ExportCSV writes matching records as CSV covering who/what/when/why
+func (dh *DecisionHistory) ExportCSV(w io.Writer, q DecisionQuery) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{
+		"recorded_at", "type", "decision_id", "drop_id", "capsule_id", "actor",
+		"action", "previous_action", "auto_approved", "confidence", "failed_gates", "reason",
+	}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, r := range dh.Query(q) {
+		row := []string{
+			r.RecordedAt.UTC().Format(time.RFC3339),
+			string(r.Type),
+			r.DecisionID,
+			r.DropID,
+			r.CapsuleID,
+			r.Actor,
+			string(r.Action),
+			string(r.PreviousAction),
+			strconv.FormatBool(r.AutoApproved),
+			strconv.FormatFloat(r.Confidence, 'f', 2, 64),
+			strings.Join(r.FailedGates, ";"),
+			r.Reason,
+		}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// failedGateNames lists the types of quality gates that did not pass
+func failedGateNames(gates *QualityGates) []string {
+	if gates == nil {
+		return nil
+	}
+
+	names := make([]string, 0)
+	for _, gate := range []*QualityGate{
+		gates.StaticAnalysisGate,
+		gates.SecurityGate,
+		gates.PerformanceGate,
+		gates.ComplianceGate,
+		gates.DeploymentGate,
+		gates.EnterpriseGate,
+	} {
+		if gate == nil || gate.Passed || gate.Status == QualityGateStatusSkipped {
+			continue
+		}
+		names = append(names, string(gate.Type))
+	}
+	return names
+}
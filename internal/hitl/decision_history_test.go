@@ -0,0 +1,90 @@
+package hitl
+
+import (
+	"bytes"
+	"encoding/csv"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDecisionHistoryQueryAndStats(t *testing.T) {
+	dh := NewDecisionHistoryWithStore(nil)
+	base := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	dh.RecordDecision(&HITLDecision{
+		ID: "d1", DropID: "drop-a", Action: HITLActionApprove, AutoApproved: true,
+		DecisionMadeBy: "AI Decision Engine", DecisionMadeAt: base,
+	})
+	dh.RecordDecision(&HITLDecision{
+		ID: "d2", DropID: "drop-b", Action: HITLActionReject, Reason: "security gate failed",
+		DecisionMadeBy: "AI Decision Engine", DecisionMadeAt: base.Add(25 * time.Hour),
+		QualityGates: &QualityGates{
+			SecurityGate:       &QualityGate{Type: QualityGateTypeSecurity, Status: QualityGateStatusFailed},
+			StaticAnalysisGate: &QualityGate{Type: QualityGateTypeStatic, Status: QualityGateStatusPassed, Passed: true},
+		},
+	})
+
+	if _, err := dh.RecordOverride("d2", HITLActionApprove, "alice", "accepted risk"); err != nil {
+		t.Fatalf("RecordOverride returned error: %v", err)
+	}
+	if _, err := dh.RecordOverride("missing", HITLActionApprove, "alice", "x"); err == nil {
+		t.Fatal("expected error overriding unknown decision")
+	}
+
+	overrides := dh.Query(DecisionQuery{Types: []DecisionRecordType{DecisionRecordOverride}})
+	if len(overrides) != 1 || overrides[0].PreviousAction != HITLActionReject {
+		t.Fatalf("unexpected overrides: %+v", overrides)
+	}
+
+	if got := dh.Query(DecisionQuery{DropID: "drop-a"}); len(got) != 1 {
+		t.Fatalf("expected 1 record for drop-a, got %d", len(got))
+	}
+
+	stats := dh.Stats(DecisionQuery{}, 24*time.Hour)
+	if stats.TotalDecisions != 2 || stats.AutoApproved != 1 || stats.Overrides != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if stats.AutoApprovalRate != 0.5 {
+		t.Errorf("expected auto-approval rate 0.5, got %v", stats.AutoApprovalRate)
+	}
+	if len(stats.TopFailedGates) != 1 || stats.TopFailedGates[0].Gate != string(QualityGateTypeSecurity) {
+		t.Errorf("unexpected failed gates: %+v", stats.TopFailedGates)
+	}
+	if len(stats.Buckets) != 3 {
+		t.Errorf("expected 3 daily buckets, got %d", len(stats.Buckets))
+	}
+
+	var buf bytes.Buffer
+	if err := dh.ExportCSV(&buf, DecisionQuery{}); err != nil {
+		t.Fatalf("ExportCSV returned error: %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("exported CSV is invalid: %v", err)
+	}
+	if len(rows) != 4 {
+		t.Errorf("expected header plus 3 rows, got %d", len(rows))
+	}
+}
+
+func TestFileDecisionStoreReload(t *testing.T) {
+	store, err := NewFileDecisionStore(filepath.Join(t.TempDir(), "audit", "decisions.jsonl"))
+	if err != nil {
+		t.Fatalf("NewFileDecisionStore returned error: %v", err)
+	}
+
+	dh := NewDecisionHistoryWithStore(store)
+	dh.RecordDecision(&HITLDecision{ID: "d1", DropID: "drop-a", Action: HITLActionReview, DecisionMadeAt: time.Now()})
+	if _, err := dh.RecordOverride("d1", HITLActionApprove, "bob", "manual review complete"); err != nil {
+		t.Fatalf("RecordOverride returned error: %v", err)
+	}
+
+	reloaded := NewDecisionHistoryWithStore(store)
+	if got := reloaded.Query(DecisionQuery{}); len(got) != 2 {
+		t.Fatalf("expected 2 records after reload, got %d", len(got))
+	}
+	if _, ok := reloaded.GetDecision("d1"); !ok {
+		t.Error("expected decision d1 to be restored")
+	}
+}
@@ -2,13 +2,16 @@ package hitl
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"QLP/internal/llm"
+	"QLP/internal/llm/jsonutil"
 	"QLP/internal/packaging"
+	"QLP/internal/threatmodel"
 	"QLP/internal/validation"
 )
 
@@ -149,6 +152,7 @@ type ValidationSummary struct {
 	PerformanceGrade    string                     `json:"performance_grade"`
 	DeploymentReadiness bool                       `json:"deployment_readiness"`
 	EnterpriseReadiness bool                       `json:"enterprise_readiness"`
+	Degradation         *validation.Degradation    `json:"degradation,omitempty"` // What the deployment validation skipped or guessed
 	ValidationResults   *ComprehensiveValidation   `json:"validation_results"`
 }
 
@@ -157,6 +161,7 @@ type ComprehensiveValidation struct {
 	StaticValidation     *validation.StaticValidationResult     `json:"static_validation"`
 	DeploymentValidation *validation.DeploymentTestResult       `json:"deployment_validation"`
 	EnterpriseValidation *validation.EnterpriseValidationResult `json:"enterprise_validation"`
+	ThreatModel          *threatmodel.Model                     `json:"threat_model,omitempty"`
 }
 
 // HITLRecommendation provides detailed recommendations
@@ -242,7 +247,7 @@ func NewEnhancedDecisionEngine(llmClient llm.Client) *EnhancedDecisionEngine {
 		riskAssessment:  getDefaultRiskAssessment(),
 		complianceRules: getDefaultComplianceRules(),
 		qualityGates:    getDefaultQualityGates(),
-		decisionHistory: NewDecisionHistory(),
+		decisionHistory: DefaultDecisionHistory(),
 	}
 }
 
@@ -283,6 +288,10 @@ func (hde *EnhancedDecisionEngine) MakeEnhancedDecision(ctx context.Context, dro
 	decision.Action = hde.determineAction(qualityGates, aiDecision)
 	decision.Reason = hde.determineReason(qualityGates, aiDecision)
 	decision.Confidence = hde.calculateConfidence(qualityGates, aiDecision)
+	if d := validationSummary.Degradation; d != nil {
+		// A decision can be no surer than the validation it rests on
+		decision.Confidence *= d.Confidence
+	}
 
 	// 5. Generate comprehensive recommendations
 	decision.Recommendations = hde.generateRecommendations(qualityGates, validationResults)
@@ -314,6 +323,22 @@ func (hde *EnhancedDecisionEngine) MakeEnhancedDecision(ctx context.Context, dro
 	return decision, nil
 }
 
+// DecisionHistory returns the audit trail of decisions made by this engine
+func (hde *EnhancedDecisionEngine) DecisionHistory() *DecisionHistory {
+	return hde.decisionHistory
+}
+
+// OverrideDecision records a human override of a previous engine decision
+func (hde *EnhancedDecisionEngine) OverrideDecision(decisionID string, action HITLAction, actor, reason string) (*DecisionRecord, error) {
+	record, err := hde.decisionHistory.RecordOverride(decisionID, action, actor, reason)
+	if err != nil {
+		return nil, fmt.Errorf("failed to override decision: %w", err)
+	}
+
+	log.Printf("HITL decision %s overridden by %s: %s -> %s", decisionID, actor, record.PreviousAction, action)
+	return record, nil
+}
+
 // evaluateQualityGates evaluates all quality gates
 func (hde *EnhancedDecisionEngine) evaluateQualityGates(ctx context.Context, drop *packaging.QuantumDrop, validationResults *ComprehensiveValidation) (*QualityGates, error) {
 	gates := &QualityGates{
@@ -394,6 +419,22 @@ func (hde *EnhancedDecisionEngine) evaluateStaticAnalysisGate(staticResult *vali
 		})
 	}
 
+	// Critical findings from custom validator plugins fail the gate
+	for _, pr := range staticResult.PluginResults {
+		if pr.Blocking() {
+			gate.Status = QualityGateStatusFailed
+			gate.Passed = false
+			gate.Issues = append(gate.Issues, QualityGateIssue{
+				Type:        "Plugin",
+				Severity:    "CRITICAL",
+				Description: fmt.Sprintf("Validator plugin %s reported critical findings", pr.Plugin),
+				Impact:      "Organization rules are violated",
+				Remediation: "Fix the findings reported by the plugin",
+				Blocking:    true,
+			})
+		}
+	}
+
 	return gate
 }
 
@@ -409,6 +450,10 @@ func (hde *EnhancedDecisionEngine) evaluateSecurityGate(validationResults *Compr
 		Issues:      make([]QualityGateIssue, 0),
 	}
 
+	// High-risk threats are reported on the gate; critical ones block it
+	threatIssues, blocked := threatGateIssues(validationResults.ThreatModel)
+	gate.Issues = append(gate.Issues, threatIssues...)
+
 	// Aggregate security scores from all validation layers
 	securityScores := make([]int, 0)
 	if validationResults.StaticValidation != nil {
@@ -444,9 +489,36 @@ func (hde *EnhancedDecisionEngine) evaluateSecurityGate(validationResults *Compr
 		gate.Passed = false
 	}
 
+	if blocked {
+		gate.Status = QualityGateStatusFailed
+		gate.Passed = false
+	} else if len(threatIssues) > 0 && gate.Passed {
+		gate.Status = QualityGateStatusWarning
+	}
+
 	return gate
 }
 
+// threatGateIssues turns the high-risk threats of a threat model into
+// security gate issues and reports whether any is critical
+func threatGateIssues(model *threatmodel.Model) ([]QualityGateIssue, bool) {
+	if model == nil {
+		return nil, false
+	}
+	var issues []QualityGateIssue
+	for _, t := range model.HighRisk() {
+		issues = append(issues, QualityGateIssue{
+			Type:        "Threat: " + t.Category.Title(),
+			Severity:    strings.ToUpper(t.Risk),
+			Description: fmt.Sprintf("%s %s (%s)", t.ID, t.Title, t.Component),
+			Impact:      t.Description,
+			Remediation: strings.Join(t.Mitigations, "; "),
+			Blocking:    t.Risk == threatmodel.RiskCritical,
+		})
+	}
+	return issues, model.Blocking()
+}
+
 // evaluatePerformanceGate evaluates the performance quality gate
 func (hde *EnhancedDecisionEngine) evaluatePerformanceGate(deploymentResult *validation.DeploymentTestResult) *QualityGate {
 	gate := &QualityGate{
@@ -647,6 +719,7 @@ Performance Gate: %s (Score: %d)
 Compliance Gate: %s (Score: %d)
 Deployment Gate: %s (Score: %d)
 Enterprise Gate: %s (Score: %d)
+Threat Model: %s
 
 DECISION CRITERIA:
 1. Enterprise deployment readiness
@@ -689,15 +762,14 @@ RESPOND WITH JSON:
 		qualityGates.PerformanceGate.Status, qualityGates.PerformanceGate.Score,
 		qualityGates.ComplianceGate.Status, qualityGates.ComplianceGate.Score,
 		qualityGates.DeploymentGate.Status, qualityGates.DeploymentGate.Score,
-		qualityGates.EnterpriseGate.Status, qualityGates.EnterpriseGate.Score)
-
-	response, err := hde.llmClient.Complete(ctx, prompt)
-	if err != nil {
-		return nil, fmt.Errorf("LLM decision analysis failed: %w", err)
-	}
+		qualityGates.EnterpriseGate.Status, qualityGates.EnterpriseGate.Score,
+		threatSummary(validationResults.ThreatModel))
 
 	var aiDecision AIDecisionAnalysis
-	if err := json.Unmarshal([]byte(response), &aiDecision); err != nil {
+	if err := jsonutil.CompleteAndDecode(ctx, hde.llmClient, prompt, aiDecisionSchema, &aiDecision, 2); err != nil {
+		if !errors.Is(err, jsonutil.ErrInvalid) {
+			return nil, fmt.Errorf("LLM decision analysis failed: %w", err)
+		}
 		log.Printf("Failed to parse AI decision analysis: %v", err)
 		return hde.fallbackDecisionAnalysis(hde.generateValidationSummary(validationResults, qualityGates)), nil
 	}
@@ -705,6 +777,19 @@ RESPOND WITH JSON:
 	return &aiDecision, nil
 }
 
+// threatSummary describes the threat model for the decision prompt
+func threatSummary(model *threatmodel.Model) string {
+	if model == nil {
+		return "not performed"
+	}
+	counts := model.Counts()
+	summary := fmt.Sprintf("%d threats (%d critical, %d high)", len(model.Threats), counts[threatmodel.RiskCritical], counts[threatmodel.RiskHigh])
+	for _, t := range model.HighRisk() {
+		summary += fmt.Sprintf("\n- [%s] %s: %s", t.Risk, t.Category.Title(), t.Title)
+	}
+	return summary
+}
+
 // Helper methods would continue here...
 
 // AIDecisionAnalysis represents AI-powered decision analysis
@@ -721,19 +806,21 @@ type AIDecisionAnalysis struct {
 	DecisionRationale string   `json:"decision_rationale"`
 }
 
-// DecisionHistory tracks decision history
-type DecisionHistory struct {
-	decisions []HITLDecision
-}
-
-func NewDecisionHistory() *DecisionHistory {
-	return &DecisionHistory{
-		decisions: make([]HITLDecision, 0),
-	}
-}
-
-func (dh *DecisionHistory) RecordDecision(decision *HITLDecision) {
-	dh.decisions = append(dh.decisions, *decision)
+// aiDecisionSchema constrains the LLM decision analysis response
+var aiDecisionSchema = map[string]interface{}{
+	"type":     "object",
+	"required": []string{"recommended_action", "confidence"},
+	"properties": map[string]interface{}{
+		"recommended_action": map[string]interface{}{
+			"type": "string",
+			"enum": []string{"approve", "reject", "review", "modify", "regenerate"},
+		},
+		"confidence":      map[string]interface{}{"type": "number"},
+		"primary_reasons": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		"risk_level":      map[string]interface{}{"type": "string"},
+		"stakeholders":    map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		"alternatives":    map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+	},
 }
 
 // Default configurations
@@ -772,6 +859,9 @@ func (hde *EnhancedDecisionEngine) generateValidationSummary(validationResults *
 	summary := &ValidationSummary{
 		ValidationResults: validationResults,
 	}
+	if validationResults != nil && validationResults.DeploymentValidation != nil {
+		summary.Degradation = validationResults.DeploymentValidation.Degradation
+	}
 
 	// Calculate summary statistics
 	gates := []*QualityGate{
@@ -840,7 +930,37 @@ func (hde *EnhancedDecisionEngine) generateRecommendations(gates *QualityGates,
 }
 
 func (hde *EnhancedDecisionEngine) performRiskAssessment(validation *ComprehensiveValidation, gates *QualityGates) *HITLRiskAssessment {
-	return &HITLRiskAssessment{OverallRisk: "medium"}
+	assessment := &HITLRiskAssessment{OverallRisk: "medium"}
+	if validation == nil || validation.ThreatModel == nil {
+		return assessment
+	}
+
+	// The threat model sets the security risk to its highest threat
+	assessment.SecurityRisk = "low"
+	scores := map[string]float64{threatmodel.RiskHigh: 7, threatmodel.RiskCritical: 9}
+	for _, t := range validation.ThreatModel.HighRisk() {
+		assessment.RiskFactors = append(assessment.RiskFactors, HITLRiskFactor{
+			Type:        t.Category.Title(),
+			Description: t.Title,
+			Probability: "likely",
+			Impact:      t.Risk,
+			RiskScore:   scores[t.Risk],
+		})
+		for _, mitigation := range t.Mitigations {
+			assessment.MitigationSteps = append(assessment.MitigationSteps, HITLMitigationStep{
+				Risk:        t.ID,
+				Action:      mitigation,
+				Responsible: "Development Team",
+			})
+		}
+		if assessment.SecurityRisk != threatmodel.RiskCritical {
+			assessment.SecurityRisk = t.Risk
+		}
+	}
+	if assessment.SecurityRisk == threatmodel.RiskHigh || assessment.SecurityRisk == threatmodel.RiskCritical {
+		assessment.OverallRisk = assessment.SecurityRisk
+	}
+	return assessment
 }
 
 func (hde *EnhancedDecisionEngine) assessBusinessImpact(drop *packaging.QuantumDrop, validation *ComprehensiveValidation) *HITLBusinessImpact {
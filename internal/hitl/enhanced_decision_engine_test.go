@@ -0,0 +1,55 @@
+package hitl
+
+import (
+	"testing"
+
+	"QLP/internal/threatmodel"
+	"QLP/internal/validation"
+)
+
+func TestThreatGateIssues(t *testing.T) {
+	if issues, blocked := threatGateIssues(nil); issues != nil || blocked {
+		t.Error("expected no issues without a threat model")
+	}
+
+	model := &threatmodel.Model{Threats: []threatmodel.Threat{
+		{ID: "T001", Category: threatmodel.InformationDisclosure, Title: "Credentials are hardcoded", Risk: threatmodel.RiskCritical, Mitigations: []string{"Use a secret store", "Rotate"}},
+		{ID: "T002", Category: threatmodel.Spoofing, Title: "Open endpoints", Risk: threatmodel.RiskHigh},
+		{ID: "T003", Category: threatmodel.Repudiation, Title: "No audit trail", Risk: threatmodel.RiskLow},
+	}}
+	issues, blocked := threatGateIssues(model)
+	if !blocked {
+		t.Error("expected a critical threat to block the gate")
+	}
+	if len(issues) != 2 {
+		t.Fatalf("expected issues for the high-risk threats only, got %+v", issues)
+	}
+	if !issues[0].Blocking || issues[0].Severity != "CRITICAL" || issues[0].Remediation != "Use a secret store; Rotate" {
+		t.Errorf("unexpected critical issue: %+v", issues[0])
+	}
+	if issues[1].Blocking || issues[1].Type != "Threat: Spoofing" {
+		t.Errorf("unexpected high issue: %+v", issues[1])
+	}
+
+	model.Threats = model.Threats[1:]
+	if _, blocked := threatGateIssues(model); blocked {
+		t.Error("expected high-risk threats not to block the gate")
+	}
+}
+
+func TestValidationSummaryCarriesDegradation(t *testing.T) {
+	hde := &EnhancedDecisionEngine{}
+	gate := &QualityGate{Status: QualityGateStatusPassed}
+	gates := &QualityGates{StaticAnalysisGate: gate, SecurityGate: gate, PerformanceGate: gate,
+		ComplianceGate: gate, DeploymentGate: gate, EnterpriseGate: gate}
+
+	if summary := hde.generateValidationSummary(&ComprehensiveValidation{}, gates); summary.Degradation != nil {
+		t.Fatalf("expected no degradation without deployment validation, got %+v", summary.Degradation)
+	}
+
+	degradation := &validation.Degradation{Confidence: 0.5, ConfidenceDowngrade: 0.5}
+	results := &ComprehensiveValidation{DeploymentValidation: &validation.DeploymentTestResult{Degradation: degradation}}
+	if summary := hde.generateValidationSummary(results, gates); summary.Degradation != degradation {
+		t.Errorf("expected the deployment degradation in the summary, got %+v", summary.Degradation)
+	}
+}
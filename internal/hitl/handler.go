@@ -0,0 +1,107 @@
+package hitl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Routes returns the decision audit endpoints:
+//
+//	GET /hitl/decisions[?format=json|csv]  exports matching decision records, oldest first
+//	GET /hitl/decisions/stats[?period=24h] summarises matching records, bucketed by period when given
+//
+// Both filter by drop_id, capsule_id, actor, action, type (comma-separated),
+// since, until (RFC3339) and limit.
+func Routes(history *DecisionHistory) map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /hitl/decisions":       exportHandler(history),
+		"GET /hitl/decisions/stats": statsHandler(history),
+	}
+}
+
+func exportHandler(history *DecisionHistory) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q, err := ParseDecisionQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Query().Get("format") {
+		case "csv":
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", `attachment; filename="hitl-decisions.csv"`)
+			err = history.ExportCSV(w, q)
+		case "json", "":
+			w.Header().Set("Content-Type", "application/json")
+			err = history.ExportJSON(w, q)
+		default:
+			http.Error(w, "format must be json or csv", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+func statsHandler(history *DecisionHistory) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q, err := ParseDecisionQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var period time.Duration
+		if v := r.URL.Query().Get("period"); v != "" {
+			if period, err = time.ParseDuration(v); err != nil || period < 0 {
+				http.Error(w, "invalid period", http.StatusBadRequest)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(history.Stats(q, period))
+	})
+}
+
+// ParseDecisionQuery reads a decision filter from drop_id, capsule_id,
+// actor, action, type (comma-separated), since, until (RFC3339) and limit
+func ParseDecisionQuery(values url.Values) (DecisionQuery, error) {
+	q := DecisionQuery{
+		DropID:    values.Get("drop_id"),
+		CapsuleID: values.Get("capsule_id"),
+		Actor:     values.Get("actor"),
+		Action:    HITLAction(values.Get("action")),
+	}
+	if v := values.Get("type"); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			switch recordType := DecisionRecordType(strings.TrimSpace(t)); recordType {
+			case DecisionRecordDecision, DecisionRecordAutoApproval, DecisionRecordOverride:
+				q.Types = append(q.Types, recordType)
+			default:
+				return q, fmt.Errorf("invalid type %q: use decision, auto_approval or override", t)
+			}
+		}
+	}
+	var err error
+	if v := values.Get("since"); v != "" {
+		if q.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return q, fmt.Errorf("invalid since: %w", err)
+		}
+	}
+	if v := values.Get("until"); v != "" {
+		if q.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return q, fmt.Errorf("invalid until: %w", err)
+		}
+	}
+	if v := values.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 0 {
+			return q, fmt.Errorf("invalid limit")
+		}
+	}
+	return q, nil
+}
@@ -0,0 +1,64 @@
+package hitl
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecisionRoutes(t *testing.T) {
+	dh := NewDecisionHistoryWithStore(nil)
+	base := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	dh.RecordDecision(&HITLDecision{ID: "d1", DropID: "drop-a", Action: HITLActionApprove, AutoApproved: true, DecisionMadeAt: base})
+	dh.RecordDecision(&HITLDecision{ID: "d2", DropID: "drop-b", Action: HITLActionReject, DecisionMadeAt: base.Add(time.Hour)})
+	if _, err := dh.RecordOverride("d2", HITLActionApprove, "alice", "accepted risk"); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	for pattern, h := range Routes(dh) {
+		mux.Handle(pattern, h)
+	}
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get("/hitl/decisions?drop_id=drop-b")
+	var records []DecisionRecord
+	if err := json.NewDecoder(rec.Body).Decode(&records); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("export = %d, %v", rec.Code, err)
+	}
+	if len(records) != 2 || records[1].Type != DecisionRecordOverride || records[1].Actor != "alice" {
+		t.Errorf("records = %+v", records)
+	}
+
+	rec = get("/hitl/decisions?format=csv&type=override")
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if rec.Header().Get("Content-Type") != "text/csv" || len(lines) != 2 || !strings.Contains(lines[1], "alice") {
+		t.Errorf("csv export:\n%s", rec.Body.String())
+	}
+
+	rec = get("/hitl/decisions/stats")
+	var stats DecisionStats
+	json.NewDecoder(rec.Body).Decode(&stats)
+	if stats.TotalDecisions != 2 || stats.AutoApproved != 1 || stats.Overrides != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	for _, target := range []string{
+		"/hitl/decisions?format=xml",
+		"/hitl/decisions?type=vote",
+		"/hitl/decisions?since=yesterday",
+		"/hitl/decisions?limit=-1",
+		"/hitl/decisions/stats?period=daily",
+	} {
+		if rec := get(target); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s = %d", target, rec.Code)
+		}
+	}
+}
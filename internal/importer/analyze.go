@@ -0,0 +1,233 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"QLP/internal/packaging"
+	"QLP/internal/remediate"
+	"QLP/internal/report"
+	"QLP/internal/sandbox"
+	"QLP/internal/validation"
+)
+
+// Analyzer runs the validation suite against an imported drop. Nil
+// validators are skipped, e.g. Tests when no container runtime is available.
+type Analyzer struct {
+	Static      *validation.StaticValidator
+	Infra       *validation.InfrastructureValidator
+	Dockerfiles *validation.DockerfileValidator
+	PowerShell  *validation.PowerShellValidator
+	Tests       *sandbox.TestRunner
+}
+
+// Assessment is the outcome of analyzing an imported repository
+type Assessment struct {
+	DropID       string                                   `json:"drop_id"`
+	Name         string                                   `json:"name"`
+	Inventory    *Inventory                               `json:"inventory"`
+	OverallScore int                                      `json:"overall_score"`
+	Static       *validation.StaticValidationResult       `json:"static,omitempty"`
+	Terraform    *validation.InfraValidationResult        `json:"terraform,omitempty"`
+	Kubernetes   *validation.InfraValidationResult        `json:"kubernetes,omitempty"`
+	Dockerfiles  []*validation.DockerfileValidationResult `json:"dockerfiles,omitempty"`
+	PowerShell   []*validation.PowerShellValidationResult `json:"powershell,omitempty"`
+	Tests        []sandbox.TestRun                        `json:"tests,omitempty"`
+	Errors       map[string]string                        `json:"errors,omitempty"`  // stage -> why it did not complete
+	Patches      []remediate.Patch                        `json:"patches,omitempty"` // Remediation patches for the findings the engine fixes
+	Suggestions  []Suggestion                             `json:"suggestions"`
+	AnalyzedAt   time.Time                                `json:"analyzed_at"`
+}
+
+// Analyze validates drop and suggests modernization intents. Stages that
+// fail are recorded in Errors rather than aborting the assessment.
+func (a *Analyzer) Analyze(ctx context.Context, drop *packaging.QuantumDrop, inv *Inventory) *Assessment {
+	as := &Assessment{
+		DropID:    drop.ID,
+		Name:      drop.Name,
+		Inventory: inv,
+		Errors:    make(map[string]string),
+	}
+
+	if a.Static != nil {
+		result, err := a.Static.ValidateQuantumDrop(ctx, drop)
+		if err != nil {
+			as.Errors["static"] = err.Error()
+		} else {
+			as.Static = result
+		}
+	}
+	if a.Infra != nil {
+		if code := concatFiles(drop.Files, inv.Terraform); code != "" {
+			result, err := a.Infra.ValidateInfrastructure(ctx, code, "terraform")
+			if err != nil {
+				as.Errors["terraform"] = err.Error()
+			} else {
+				as.Terraform = result
+			}
+		}
+		if code := concatFiles(drop.Files, inv.Kubernetes); code != "" {
+			result, err := a.Infra.ValidateInfrastructure(ctx, code, "kubernetes")
+			if err != nil {
+				as.Errors["kubernetes"] = err.Error()
+			} else {
+				as.Kubernetes = result
+			}
+		}
+	}
+	if a.Dockerfiles != nil {
+		as.Dockerfiles = a.Dockerfiles.ValidateFiles(ctx, drop.Files)
+	}
+	if a.PowerShell != nil {
+		as.PowerShell = a.PowerShell.ValidateFiles(ctx, drop.Files)
+	}
+	if a.Tests != nil {
+		as.Tests = a.Tests.Run(ctx, drop.Files)
+		for _, run := range as.Tests {
+			if run.Error != "" {
+				as.Errors["tests:"+string(run.Ecosystem)+":"+run.Dir] = run.Error
+			}
+		}
+	}
+
+	as.Patches = remediate.Suggest(drop.Files)
+	as.OverallScore = as.score()
+	as.Suggestions = Suggest(as)
+	as.AnalyzedAt = time.Now()
+	return as
+}
+
+// concatFiles joins the named files, as the infrastructure validator takes one document
+func concatFiles(files map[string]string, paths []string) string {
+	var b strings.Builder
+	for _, p := range paths {
+		if b.Len() > 0 {
+			b.WriteString("\n---\n")
+		}
+		b.WriteString(files[p])
+	}
+	return b.String()
+}
+
+// score averages the stages that produced a score
+func (as *Assessment) score() int {
+	var scores []int
+	if as.Static != nil {
+		scores = append(scores, as.Static.OverallScore)
+	}
+	for _, infra := range []*validation.InfraValidationResult{as.Terraform, as.Kubernetes} {
+		if infra != nil {
+			scores = append(scores, infra.OverallScore)
+		}
+	}
+	for _, d := range as.Dockerfiles {
+		scores = append(scores, d.Score)
+	}
+	for _, ps := range as.PowerShell {
+		scores = append(scores, ps.Score)
+	}
+	for _, run := range as.Tests {
+		if run.Error == "" && run.Passed+run.Failed > 0 {
+			scores = append(scores, int(run.PassRate()))
+		}
+	}
+	if len(scores) == 0 {
+		return 0
+	}
+	total := 0
+	for _, s := range scores {
+		total += s
+	}
+	return total / len(scores)
+}
+
+// Report renders the assessment with the shared validation report layout
+func (as *Assessment) Report() *report.Report {
+	r := report.New("Assessment Report: " + as.Name)
+	r.CapsuleID = as.DropID
+	r.GeneratedAt = as.AnalyzedAt
+	r.ScoreCards = append(r.ScoreCards, report.ScoreCard{Name: "Overall", Score: as.OverallScore,
+		Detail: fmt.Sprintf("%d files, %s", as.Inventory.Files, languageSummary(as.Inventory))})
+
+	if s := as.Static; s != nil {
+		r.ScoreCards = append(r.ScoreCards,
+			report.ScoreCard{Name: "Security", Score: s.SecurityScore, Detail: fmt.Sprintf("%d findings", len(s.SecurityFindings))},
+			report.ScoreCard{Name: "Quality", Score: s.QualityScore},
+			report.ScoreCard{Name: "Architecture", Score: s.ArchitectureScore},
+			report.ScoreCard{Name: "Compliance", Score: s.ComplianceScore},
+		)
+		for _, issue := range s.Issues {
+			r.AddIssue(report.Issue{Severity: issue.Severity, Source: "static", Category: issue.Category,
+				Resource: issue.Resource, Message: issue.Message, Remediation: issue.Remediation})
+		}
+		for _, f := range s.SecurityFindings {
+			r.AddIssue(report.Issue{Severity: f.Severity, Source: "security", Category: f.Type,
+				Resource: f.Location, Message: f.Description, Remediation: f.Recommendation})
+		}
+		r.AddRecommendations(s.Recommendations...)
+		r.AddCodeMetrics(s.CodeMetrics)
+	}
+	r.AddInfra(as.Terraform)
+	r.AddInfra(as.Kubernetes)
+	r.AddPatches(as.Patches)
+	for _, d := range as.Dockerfiles {
+		r.ScoreCards = append(r.ScoreCards, report.ScoreCard{Name: "Dockerfile " + d.Path, Score: d.Score})
+		for _, issue := range d.Issues() {
+			r.AddIssue(report.Issue{Severity: issue.Severity, Source: "dockerfile", Category: issue.Category,
+				Resource: issue.Resource, Message: issue.Message, Remediation: issue.Remediation})
+		}
+	}
+	for _, ps := range as.PowerShell {
+		r.ScoreCards = append(r.ScoreCards, report.ScoreCard{Name: "PowerShell " + ps.Path, Score: ps.Score, Detail: ps.Analyzer})
+		for _, issue := range ps.Issues() {
+			r.AddIssue(report.Issue{Severity: issue.Severity, Source: "powershell", Category: issue.Category,
+				Resource: issue.Resource, Message: issue.Message, Remediation: issue.Remediation})
+		}
+	}
+	for _, run := range as.Tests {
+		message := run.Error
+		if message == "" {
+			message = fmt.Sprintf("%d passed, %d failed", run.Passed, run.Failed)
+			if run.Coverage >= 0 {
+				message += fmt.Sprintf(", %.1f%% coverage", run.Coverage)
+			}
+		}
+		r.Tests = append(r.Tests, report.TestCase{
+			Name:    string(run.Ecosystem) + " test suite",
+			Target:  run.Dir,
+			Passed:  run.Error == "" && run.Failed == 0,
+			Message: message,
+		})
+	}
+	for stage, reason := range as.Errors {
+		r.AddIssue(report.Issue{Severity: "info", Source: "import", Category: stage, Message: "did not complete: " + reason})
+	}
+	for _, s := range as.Suggestions {
+		r.AddRecommendations(fmt.Sprintf("[%s] %s: %s", s.Priority, s.Title, s.Intent))
+	}
+	return r
+}
+
+func languageSummary(inv *Inventory) string {
+	langs := make([]string, 0, len(inv.Languages))
+	for lang := range inv.Languages {
+		langs = append(langs, lang)
+	}
+	sort.Slice(langs, func(i, j int) bool {
+		if inv.Languages[langs[i]] != inv.Languages[langs[j]] {
+			return inv.Languages[langs[i]] > inv.Languages[langs[j]]
+		}
+		return langs[i] < langs[j]
+	})
+	if len(langs) == 0 {
+		return "no recognized languages"
+	}
+	parts := make([]string, len(langs))
+	for i, lang := range langs {
+		parts[i] = fmt.Sprintf("%s %d", lang, inv.Languages[lang])
+	}
+	return strings.Join(parts, ", ")
+}
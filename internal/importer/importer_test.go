@@ -0,0 +1,192 @@
+package importer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"QLP/internal/report"
+	"QLP/internal/sandbox"
+	"QLP/internal/validation"
+)
+
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestIngest(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"go.mod":                   "module example.com/orders\n\ngo 1.19\n",
+		"main.go":                  "package main\n",
+		"store/store.go":           "package store\n",
+		"store/store_test.go":      "package store\n",
+		"web/package.json":         `{"engines": {"node": ">=14"}}`,
+		"web/app.ts":               "export {}\n",
+		"node_modules/x/index.js":  "module.exports = 1\n",
+		".git/config":              "[core]\n",
+		".github/workflows/ci.yml": "on: push\n",
+		"deploy/app.yaml":          "apiVersion: apps/v1\nkind: Deployment\n",
+		"infra/main.tf":            "resource \"azurerm_resource_group\" \"rg\" {}\n",
+		"Dockerfile":               "FROM golang:1.19\n",
+		"assets/logo.png":          "\x89PNG\x00\x00binary",
+	})
+
+	drop, inv, err := Ingest(dir, "orders", DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := drop.Files["node_modules/x/index.js"]; ok {
+		t.Error("dependency directory was ingested")
+	}
+	if _, ok := drop.Files[".git/config"]; ok {
+		t.Error(".git was ingested")
+	}
+	if inv.Files != 11-1 || inv.Skipped != 1 || len(drop.Files) != inv.Files {
+		t.Errorf("files = %d, skipped = %d, drop files = %d", inv.Files, inv.Skipped, len(drop.Files))
+	}
+	if inv.Languages["Go"] != 3 || inv.Languages["TypeScript"] != 1 || inv.PrimaryLanguage() != "Go" {
+		t.Errorf("languages = %v", inv.Languages)
+	}
+	if inv.TestFiles != 1 {
+		t.Errorf("test files = %d", inv.TestFiles)
+	}
+	for name, got := range map[string][]string{
+		"manifests":   inv.Manifests,
+		"dockerfiles": inv.Dockerfiles,
+		"terraform":   inv.Terraform,
+		"kubernetes":  inv.Kubernetes,
+		"ci":          inv.CI,
+	} {
+		if len(got) == 0 {
+			t.Errorf("no %s detected", name)
+		}
+	}
+	if inv.Runtimes["go.mod"] != "go 1.19" || inv.Runtimes["web/package.json"] != "node 14" {
+		t.Errorf("runtimes = %v", inv.Runtimes)
+	}
+}
+
+func TestIngestLimits(t *testing.T) {
+	dir := writeTree(t, map[string]string{"a.py": "1", "b.py": "2", "c.py": "3", "big.py": strings.Repeat("x", 100)})
+	opts := DefaultOptions()
+	opts.MaxFiles = 2
+	opts.MaxFileBytes = 50
+
+	_, inv, err := Ingest(dir, "limits", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Files != 2 || inv.Skipped != 2 || !inv.Truncated {
+		t.Errorf("inventory = %+v", inv)
+	}
+
+	if _, _, err := Ingest(t.TempDir(), "empty", opts); err == nil {
+		t.Error("empty directory ingested")
+	}
+}
+
+func TestSurvey(t *testing.T) {
+	inv := Survey(map[string]string{
+		"go.mod":            "module orders\n\ngo 1.22\n",
+		"main.go":           "package main\n",
+		"deploy/app.yaml":   "apiVersion: apps/v1\nkind: Deployment\n",
+		"catalog-info.yaml": "apiVersion: backstage.io/v1alpha1\nkind: Component\n",
+	})
+	if inv.Files != 4 || inv.Languages["Go"] != 1 || inv.Runtimes["go.mod"] != "go 1.22" {
+		t.Errorf("inventory = %+v", inv)
+	}
+	if len(inv.Kubernetes) != 1 || inv.Kubernetes[0] != "deploy/app.yaml" {
+		t.Errorf("kubernetes = %v, want the catalog entity excluded", inv.Kubernetes)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	inv := &Inventory{
+		Languages: map[string]int{"COBOL": 12, "Python": 3},
+		Manifests: []string{"requirements.txt"},
+		Runtimes:  map[string]string{"go.mod": "go 1.18"},
+	}
+	as := &Assessment{
+		Inventory: inv,
+		Static:    &validation.StaticValidationResult{SecurityScore: 55, QualityScore: 90},
+		Tests:     []sandbox.TestRun{{Ecosystem: sandbox.EcosystemPython, Dir: ".", Passed: 3, Failed: 1}},
+	}
+
+	titles := make(map[string]string)
+	for _, s := range Suggest(as) {
+		titles[s.Title] = s.Priority
+	}
+	for title, priority := range map[string]string{
+		"Port COBOL code":             "high",
+		"Remediate security findings": "high",
+		"Add automated tests":         "high",
+		"Fix failing tests":           "high",
+		"Containerize":                "medium",
+		"Add a CI pipeline":           "medium",
+		"Upgrade runtime":             "medium",
+		"Add infrastructure as code":  "low",
+	} {
+		if titles[title] != priority {
+			t.Errorf("suggestion %q priority = %q, want %q", title, titles[title], priority)
+		}
+	}
+	if _, ok := titles["Refactor for maintainability"]; ok {
+		t.Error("refactor suggested for a quality score of 90")
+	}
+
+	suggestions := Suggest(as)
+	for i := 1; i < len(suggestions); i++ {
+		if priorityRank[suggestions[i-1].Priority] > priorityRank[suggestions[i].Priority] {
+			t.Fatalf("suggestions not ordered by priority: %+v", suggestions)
+		}
+	}
+}
+
+func TestAnalyzeReport(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"app.py":     "print('hi')\n",
+		"Dockerfile": "FROM python:latest\nCOPY . .\nCMD python app.py\n",
+	})
+	drop, inv, err := Ingest(dir, "hello", DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	as := (&Analyzer{Dockerfiles: validation.NewDockerfileValidator()}).Analyze(context.Background(), drop, inv)
+	if len(as.Dockerfiles) != 1 || as.OverallScore != as.Dockerfiles[0].Score {
+		t.Fatalf("assessment = %+v", as)
+	}
+
+	md := string(report.Markdown(as.Report()))
+	for _, want := range []string{"Assessment Report: hello", drop.ID, "Dockerfile Dockerfile", "Add automated tests"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+}
+
+func TestIsGitURL(t *testing.T) {
+	for source, want := range map[string]bool{
+		"https://github.com/acme/orders.git": true,
+		"git@github.com:acme/orders.git":     true,
+		"./orders":                           false,
+		"/srv/repos/orders":                  false,
+	} {
+		if got := IsGitURL(source); got != want {
+			t.Errorf("IsGitURL(%q) = %v", source, got)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+// Package importer brings existing repositories into QLP, the reverse of
+// generating a QuantumDrop from an intent: a local directory or git
+// repository is ingested into a drop, run through the validation suite and
+// assessed, with suggested modernization intents.
+package importer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"QLP/internal/packaging"
+	"QLP/internal/validation"
+)
+
+// Options bound what an import reads
+type Options struct {
+	Ref           string // branch or tag to clone for git sources
+	MaxFiles      int
+	MaxFileBytes  int64
+	MaxTotalBytes int64
+}
+
+// DefaultOptions reads up to 2000 text files of at most 1MiB, 32MiB in total
+func DefaultOptions() Options {
+	return Options{
+		MaxFiles:      2000,
+		MaxFileBytes:  1 << 20,
+		MaxTotalBytes: 32 << 20,
+	}
+}
+
+// skippedDirs hold dependencies and build output rather than source
+var skippedDirs = map[string]bool{
+	"node_modules": true, "vendor": true, "dist": true, "build": true, "target": true,
+	"bin": true, "obj": true, "coverage": true, "__pycache__": true,
+}
+
+var languageByExt = map[string]string{
+	".go": "Go", ".py": "Python", ".js": "JavaScript", ".jsx": "JavaScript", ".mjs": "JavaScript",
+	".ts": "TypeScript", ".tsx": "TypeScript", ".java": "Java", ".kt": "Kotlin", ".cs": "C#",
+	".rb": "Ruby", ".php": "PHP", ".rs": "Rust", ".c": "C", ".h": "C", ".cpp": "C++", ".cc": "C++",
+	".scala": "Scala", ".swift": "Swift", ".sh": "Shell", ".ps1": "PowerShell", ".psm1": "PowerShell", ".sql": "SQL",
+	".tf": "HCL", ".bicep": "Bicep", ".pl": "Perl", ".vb": "Visual Basic", ".fs": "F#", ".cbl": "COBOL", ".cob": "COBOL",
+}
+
+var manifestNames = map[string]bool{
+	"go.mod": true, "package.json": true, "requirements.txt": true, "pyproject.toml": true, "Pipfile": true,
+	"pom.xml": true, "build.gradle": true, "build.gradle.kts": true, "Cargo.toml": true, "Gemfile": true,
+	"composer.json": true,
+}
+
+var (
+	goDirective = regexp.MustCompile(`(?m)^go (1\.\d+(?:\.\d+)?)`)
+	nodeEngine  = regexp.MustCompile(`"node"\s*:\s*"[^0-9"]*(\d+)`)
+)
+
+var testFile = regexp.MustCompile(`(_test\.go|\.test\.[jt]sx?|\.spec\.[jt]sx?|(^|/)test_[^/]+\.py|_test\.py|Test\.java|Tests?\.cs)$`)
+
+// Inventory describes what was ingested
+type Inventory struct {
+	Source      string            `json:"source"`
+	Files       int               `json:"files"`
+	Bytes       int64             `json:"bytes"`
+	Skipped     int               `json:"skipped_files"` // binary, oversized or over the limits
+	Truncated   bool              `json:"truncated"`     // MaxFiles or MaxTotalBytes was reached
+	Languages   map[string]int    `json:"languages"`     // files per language
+	Manifests   []string          `json:"manifests"`
+	TestFiles   int               `json:"test_files"`
+	Dockerfiles []string          `json:"dockerfiles"`
+	Terraform   []string          `json:"terraform"`
+	Kubernetes  []string          `json:"kubernetes"`
+	CI          []string          `json:"ci"`
+	Runtimes    map[string]string `json:"runtimes,omitempty"` // manifest -> declared runtime, e.g. "go 1.21"
+}
+
+// PrimaryLanguage is the language with the most files, or "" when none was recognized
+func (inv *Inventory) PrimaryLanguage() string {
+	best, count := "", 0
+	for lang, n := range inv.Languages {
+		if lang == "HCL" || lang == "Shell" || lang == "SQL" {
+			continue
+		}
+		if n > count || (n == count && lang < best) {
+			best, count = lang, n
+		}
+	}
+	return best
+}
+
+// IsGitURL reports whether source names a remote git repository rather than a local path
+func IsGitURL(source string) bool {
+	for _, prefix := range []string{"https://", "http://", "ssh://", "git://", "git@"} {
+		if strings.HasPrefix(source, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fetch returns a local directory holding source. Git URLs are shallow-cloned
+// into a temporary directory that cleanup removes.
+func Fetch(ctx context.Context, source string, opts Options) (string, func(), error) {
+	if !IsGitURL(source) {
+		info, err := os.Stat(source)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read %s: %w", source, err)
+		}
+		if !info.IsDir() {
+			return "", nil, fmt.Errorf("%s is not a directory", source)
+		}
+		return source, func() {}, nil
+	}
+
+	dir, err := os.MkdirTemp("", "qlp-import-*")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	args := []string{"clone", "--depth", "1", "--single-branch"}
+	if opts.Ref != "" {
+		args = append(args, "--branch", opts.Ref)
+	}
+	cmd := exec.CommandContext(ctx, "git", append(args, "--", source, dir)...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("git clone %s failed: %w: %s", source, err, strings.TrimSpace(stderr.String()))
+	}
+	return dir, cleanup, nil
+}
+
+// Ingest reads the source files under dir into a codebase drop named name.
+// Hidden directories other than .github, dependency and build directories,
+// binary files and files over the limits are skipped.
+func Ingest(dir, name string, opts Options) (*packaging.QuantumDrop, *Inventory, error) {
+	inv := &Inventory{Languages: make(map[string]int), Runtimes: make(map[string]string)}
+	files := make(map[string]string)
+	structure := make(map[string][]string)
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+		if d.IsDir() {
+			if skippedDirs[d.Name()] || (strings.HasPrefix(d.Name(), ".") && d.Name() != ".github") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if inv.Truncated {
+			inv.Skipped++
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() > opts.MaxFileBytes {
+			inv.Skipped++
+			return nil
+		}
+		if (opts.MaxFiles > 0 && inv.Files >= opts.MaxFiles) || (opts.MaxTotalBytes > 0 && inv.Bytes+info.Size() > opts.MaxTotalBytes) {
+			inv.Truncated = true
+			inv.Skipped++
+			return nil
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if bytes.IndexByte(content[:min(len(content), 8000)], 0) >= 0 {
+			inv.Skipped++
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		files[rel] = string(content)
+		structure[path.Dir(rel)] = append(structure[path.Dir(rel)], path.Base(rel))
+		inv.Files++
+		inv.Bytes += info.Size()
+		classify(inv, rel, content)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to ingest %s: %w", dir, err)
+	}
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("no source files found in %s", dir)
+	}
+	for _, list := range [][]string{inv.Manifests, inv.Dockerfiles, inv.Terraform, inv.Kubernetes, inv.CI} {
+		sort.Strings(list)
+	}
+
+	now := time.Now()
+	drop := &packaging.QuantumDrop{
+		ID:          fmt.Sprintf("QD-IMPORT-%d", now.UnixNano()),
+		Type:        packaging.DropTypeCodebase,
+		Name:        name,
+		Description: "Imported from " + name,
+		Files:       files,
+		Structure:   structure,
+		Status:      packaging.DropStatusApproved,
+		CreatedAt:   now,
+		Metadata:    packaging.DropMetadata{FileCount: len(files)},
+		Tasks:       []string{},
+	}
+	return drop, inv, nil
+}
+
+// Survey builds the inventory of files already in memory, such as a
+// capsule's project
+func Survey(files map[string]string) *Inventory {
+	inv := &Inventory{Languages: make(map[string]int), Runtimes: make(map[string]string)}
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		inv.Files++
+		inv.Bytes += int64(len(files[p]))
+		classify(inv, p, []byte(files[p]))
+	}
+	return inv
+}
+
+// classify records what a file tells us about the project
+func classify(inv *Inventory, rel string, content []byte) {
+	base := path.Base(rel)
+	ext := strings.ToLower(path.Ext(rel))
+	if lang, ok := languageByExt[ext]; ok {
+		inv.Languages[lang]++
+	}
+	switch {
+	case manifestNames[base] || ext == ".sln" || ext == ".csproj" || ext == ".fsproj" || ext == ".vbproj":
+		inv.Manifests = append(inv.Manifests, rel)
+		if m := goDirective.FindSubmatch(content); base == "go.mod" && m != nil {
+			inv.Runtimes[rel] = "go " + string(m[1])
+		}
+		if m := nodeEngine.FindSubmatch(content); base == "package.json" && m != nil {
+			inv.Runtimes[rel] = "node " + string(m[1])
+		}
+	case validation.IsDockerfile(rel):
+		inv.Dockerfiles = append(inv.Dockerfiles, rel)
+	case ext == ".tf":
+		inv.Terraform = append(inv.Terraform, rel)
+	case (ext == ".yaml" || ext == ".yml") && strings.HasPrefix(rel, ".github/workflows/"):
+		inv.CI = append(inv.CI, rel)
+	case base == ".gitlab-ci.yml" || base == "azure-pipelines.yml" || base == "Jenkinsfile":
+		inv.CI = append(inv.CI, rel)
+	case base == packaging.CatalogInfoFile:
+		// A Backstage entity, not a Kubernetes manifest
+	case (ext == ".yaml" || ext == ".yml") && bytes.Contains(content, []byte("apiVersion:")) && bytes.Contains(content, []byte("kind:")):
+		inv.Kubernetes = append(inv.Kubernetes, rel)
+	}
+	if testFile.MatchString(rel) {
+		inv.TestFiles++
+	}
+}
@@ -0,0 +1,151 @@
+package importer
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Suggestion is a modernization intent derived from an assessment, ready to
+// hand back to QLP
+type Suggestion struct {
+	Title    string `json:"title"`
+	Intent   string `json:"intent"`
+	Reason   string `json:"reason"`
+	Priority string `json:"priority"` // high, medium or low
+}
+
+var priorityRank = map[string]int{"high": 0, "medium": 1, "low": 2}
+
+// legacyLanguages are worth porting rather than patching
+var legacyLanguages = map[string]bool{"COBOL": true, "Perl": true, "Visual Basic": true}
+
+// Oldest runtime releases still receiving security fixes
+const (
+	minGoMinor   = 22
+	minNodeMajor = 20
+)
+
+// Suggest derives modernization intents from an assessment, most urgent first
+func Suggest(as *Assessment) []Suggestion {
+	inv := as.Inventory
+	lang := inv.PrimaryLanguage()
+	subject := "the project"
+	if lang != "" {
+		subject = "the " + lang + " code"
+	}
+	var out []Suggestion
+	add := func(priority, title, reason, intent string) {
+		out = append(out, Suggestion{Title: title, Intent: intent, Reason: reason, Priority: priority})
+	}
+
+	for l := range inv.Languages {
+		if legacyLanguages[l] {
+			add("high", "Port "+l+" code", fmt.Sprintf("%d %s files", inv.Languages[l], l),
+				fmt.Sprintf("Port the %s programs to a Go service with equivalent behavior, tests for each ported routine and a REST API in front", l))
+		}
+	}
+
+	if s := as.Static; s != nil {
+		serious := 0
+		for _, f := range s.SecurityFindings {
+			if sev := strings.ToLower(f.Severity); sev == "critical" || sev == "high" {
+				serious++
+			}
+		}
+		if serious > 0 || s.SecurityScore < 70 {
+			add("high", "Remediate security findings",
+				fmt.Sprintf("security score %d with %d critical or high findings", s.SecurityScore, serious),
+				fmt.Sprintf("Fix the security vulnerabilities in %s: validate all inputs, remove hard-coded secrets in favor of environment configuration and use parameterized queries", subject))
+		}
+		if s.QualityScore < 60 {
+			add("medium", "Refactor for maintainability", fmt.Sprintf("quality score %d", s.QualityScore),
+				fmt.Sprintf("Refactor %s into smaller modules with clear interfaces, consistent error handling and structured logging", subject))
+		}
+	}
+
+	if inv.TestFiles == 0 {
+		add("high", "Add automated tests", "no test files found",
+			fmt.Sprintf("Add unit and integration tests for %s covering the main code paths, with coverage reporting", subject))
+	}
+	for _, run := range as.Tests {
+		if run.Error == "" && run.Failed > 0 {
+			add("high", "Fix failing tests", fmt.Sprintf("%d of %d %s tests fail in %s", run.Failed, run.Passed+run.Failed, run.Ecosystem, dirName(run.Dir)),
+				fmt.Sprintf("Fix the failing %s tests in %s without weakening their assertions", run.Ecosystem, dirName(run.Dir)))
+		}
+	}
+
+	if len(inv.Dockerfiles) == 0 {
+		if len(inv.Manifests) > 0 {
+			add("medium", "Containerize", "no Dockerfile found",
+				fmt.Sprintf("Add a multi-stage Dockerfile for %s that runs as a non-root user with a health check", subject))
+		}
+	} else {
+		for _, d := range as.Dockerfiles {
+			if d.Score < 70 {
+				add("medium", "Harden "+d.Path, fmt.Sprintf("Dockerfile score %d with %d findings", d.Score, len(d.Findings)),
+					fmt.Sprintf("Harden %s: pin base image versions, use a multi-stage build, run as non-root and add a health check", d.Path))
+			}
+		}
+	}
+
+	if len(inv.CI) == 0 {
+		add("medium", "Add a CI pipeline", "no CI configuration found",
+			fmt.Sprintf("Add a GitHub Actions workflow that builds %s, runs the tests and a security scan on every pull request", subject))
+	}
+
+	switch {
+	case len(inv.Terraform) == 0 && len(inv.Kubernetes) == 0:
+		add("low", "Add infrastructure as code", "no Terraform or Kubernetes manifests found",
+			fmt.Sprintf("Add Terraform for Azure that deploys %s with managed identity, monitoring and a remote state backend", subject))
+	default:
+		if as.Terraform != nil && as.Terraform.OverallScore < 70 {
+			add("medium", "Harden Terraform", fmt.Sprintf("infrastructure score %d", as.Terraform.OverallScore),
+				"Harden the Terraform: enable encryption at rest, restrict network access, tag resources and add a remote state backend")
+		}
+		if as.Kubernetes != nil && as.Kubernetes.OverallScore < 70 {
+			add("medium", "Harden Kubernetes manifests", fmt.Sprintf("Kubernetes score %d", as.Kubernetes.OverallScore),
+				"Harden the Kubernetes manifests: set resource limits, liveness and readiness probes, a restricted security context and network policies")
+		}
+	}
+
+	for manifest, version := range inv.Runtimes {
+		if outdatedRuntime(manifest, version) {
+			add("medium", "Upgrade runtime", fmt.Sprintf("%s targets %s", manifest, version),
+				fmt.Sprintf("Upgrade %s to a supported runtime release and update its dependencies", manifest))
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if priorityRank[out[i].Priority] != priorityRank[out[j].Priority] {
+			return priorityRank[out[i].Priority] < priorityRank[out[j].Priority]
+		}
+		if out[i].Title != out[j].Title {
+			return out[i].Title < out[j].Title
+		}
+		return out[i].Reason < out[j].Reason
+	})
+	return out
+}
+
+// outdatedRuntime reports whether a manifest's declared runtime is out of support
+func outdatedRuntime(manifest, version string) bool {
+	switch path.Base(manifest) {
+	case "go.mod":
+		minor, err := strconv.Atoi(strings.Split(strings.TrimPrefix(version, "go 1."), ".")[0])
+		return err == nil && minor < minGoMinor
+	case "package.json":
+		major, err := strconv.Atoi(strings.Split(strings.TrimPrefix(version, "node "), ".")[0])
+		return err == nil && major < minNodeMajor
+	}
+	return false
+}
+
+func dirName(dir string) string {
+	if dir == "" || dir == "." {
+		return "the repository root"
+	}
+	return strings.TrimSuffix(dir, "/go.mod")
+}
@@ -0,0 +1,294 @@
+// Package install renders the manifests that deploy QLP itself: Kubernetes
+// resources or a Compose file for the API server and, optionally, the
+// operator. Replicas, resources, the image and secret references come from
+// the install section of qlp.yaml, and the non-secret settings of the
+// selected profile become the server's environment. The configuration and
+// the rendered manifests are both validated before anything is written.
+package install
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"QLP/internal/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats
+const (
+	FormatKubernetes = "kubernetes"
+	FormatCompose    = "compose"
+)
+
+// Defaults applied to an empty install section
+const (
+	DefaultNamespace = "qlp"
+	DefaultImage     = "qlp/orchestrator:latest"
+	DefaultPort      = 9090
+)
+
+var (
+	dnsLabel   = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	envName    = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+	secretKey  = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+	cpuPattern = regexp.MustCompile(`^([0-9]+(\.[0-9]+)?)(m?)$`)
+	memPattern = regexp.MustCompile(`^([0-9]+(\.[0-9]+)?)(Ki|Mi|Gi|Ti|k|M|G|T)?$`)
+)
+
+// Config is the install section of qlp.yaml:
+//
+//	install:
+//	  namespace: qlp
+//	  image: qlp/orchestrator:1.4.0
+//	  secret_refs:
+//	    DATABASE_URL: qlp-db/url
+//	    AZURE_OPENAI_API_KEY: qlp-llm/azure-openai-key
+//	  server:
+//	    replicas: 2
+//	    resources: {cpu: 500m, memory: 1Gi, cpu_limit: "2", memory_limit: 4Gi}
+//	  operator:
+//	    enabled: true
+type Config struct {
+	Namespace string `yaml:"namespace" json:"namespace"`
+	Image     string `yaml:"image" json:"image"`
+	// SecretRefs maps environment variables to "secret/key" of a Kubernetes
+	// Secret; Compose reads them from the environment instead
+	SecretRefs map[string]string `yaml:"secret_refs" json:"secret_refs"`
+	Server     Component         `yaml:"server" json:"server"`
+	Operator   Component         `yaml:"operator" json:"operator"`
+}
+
+// Component is one QLP process to deploy
+type Component struct {
+	Enabled   *bool     `yaml:"enabled" json:"enabled"` // Default true for the server, false for the operator
+	Replicas  *int      `yaml:"replicas" json:"replicas"`
+	Port      int       `yaml:"port" json:"port"` // Server only; default QLP_METRICS_PORT or 9090
+	Resources Resources `yaml:"resources" json:"resources"`
+}
+
+// Resources are Kubernetes quantities, e.g. cpu 500m and memory 1Gi
+type Resources struct {
+	CPU         string `yaml:"cpu" json:"cpu"`
+	Memory      string `yaml:"memory" json:"memory"`
+	CPULimit    string `yaml:"cpu_limit" json:"cpu_limit"`
+	MemoryLimit string `yaml:"memory_limit" json:"memory_limit"`
+}
+
+var (
+	defaultServerResources   = Resources{CPU: "500m", Memory: "1Gi", CPULimit: "2", MemoryLimit: "4Gi"}
+	defaultOperatorResources = Resources{CPU: "250m", Memory: "512Mi", CPULimit: "1", MemoryLimit: "2Gi"}
+)
+
+// Load reads the install section of a qlp.yaml or qlp.json file. A file
+// without one yields the defaults.
+func Load(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var f struct {
+		Install Config `yaml:"install" json:"install"`
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &f)
+	} else {
+		err = yaml.Unmarshal(data, &f)
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse install section of %s: %w", path, err)
+	}
+	return f.Install, nil
+}
+
+// withDefaults fills the unset fields; port is QLP_METRICS_PORT from the
+// settings when given
+func (c Config) withDefaults(settings map[string]string) Config {
+	if c.Namespace == "" {
+		c.Namespace = DefaultNamespace
+	}
+	if c.Image == "" {
+		c.Image = DefaultImage
+	}
+	enabled, disabled := true, false
+	one := 1
+	if c.Server.Enabled == nil {
+		c.Server.Enabled = &enabled
+	}
+	if c.Server.Replicas == nil {
+		c.Server.Replicas = &one
+	}
+	if c.Server.Port == 0 {
+		c.Server.Port = DefaultPort
+		if port, err := strconv.Atoi(settings["QLP_METRICS_PORT"]); err == nil {
+			c.Server.Port = port
+		}
+	}
+	c.Server.Resources = c.Server.Resources.withDefaults(defaultServerResources)
+	if c.Operator.Enabled == nil {
+		c.Operator.Enabled = &disabled
+	}
+	if c.Operator.Replicas == nil {
+		c.Operator.Replicas = &one
+	}
+	c.Operator.Resources = c.Operator.Resources.withDefaults(defaultOperatorResources)
+	return c
+}
+
+func (r Resources) withDefaults(d Resources) Resources {
+	if r.CPU == "" {
+		r.CPU = d.CPU
+	}
+	if r.Memory == "" {
+		r.Memory = d.Memory
+	}
+	if r.CPULimit == "" {
+		r.CPULimit = d.CPULimit
+	}
+	if r.MemoryLimit == "" {
+		r.MemoryLimit = d.MemoryLimit
+	}
+	return r
+}
+
+// Validate checks the install section after defaults are applied, for
+// format. Errors stop rendering; warnings are reported alongside the output.
+func (c Config) Validate(settings map[string]string, format string) []config.Issue {
+	var issues []config.Issue
+	add := func(severity, key, format string, args ...interface{}) {
+		issues = append(issues, config.Issue{Severity: severity, Section: "install", Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if format != FormatKubernetes && format != FormatCompose {
+		issues = append(issues, config.Issue{Severity: "error", Section: "install", Message: fmt.Sprintf("unknown format %q, expected %s or %s", format, FormatKubernetes, FormatCompose)})
+	}
+	if !dnsLabel.MatchString(c.Namespace) || len(c.Namespace) > 63 {
+		add("error", "namespace", "%q is not a valid namespace name", c.Namespace)
+	}
+	if strings.ContainsAny(c.Image, " \t") {
+		add("error", "image", "%q is not a valid image reference", c.Image)
+	} else if tag := c.Image[strings.LastIndex(c.Image, "/")+1:]; !strings.ContainsAny(tag, ":@") || strings.HasSuffix(tag, ":latest") {
+		add("warning", "image", "pin a version instead of %q so every replica runs the same build", c.Image)
+	}
+
+	for _, name := range sortedKeys(c.SecretRefs) {
+		ref := c.SecretRefs[name]
+		secret, key, ok := strings.Cut(ref, "/")
+		switch {
+		case !envName.MatchString(name):
+			add("error", "secret_refs."+name, "must be an environment variable name")
+		case !ok || !dnsLabel.MatchString(secret) || !secretKey.MatchString(key):
+			add("error", "secret_refs."+name, "expected secret-name/key, got %q", ref)
+		}
+		if _, inFile := settings[name]; inFile {
+			add("warning", "secret_refs."+name, "also set in the config file; the secret wins")
+		}
+	}
+	for _, name := range sortedKeys(settings) {
+		if config.IsSecretKey(name) && settings[name] != "" {
+			if _, ok := c.SecretRefs[name]; !ok {
+				add("warning", "secret_refs", "%s is a secret but has no secret reference; it is left out of the manifests", name)
+			}
+		}
+	}
+
+	if *c.Server.Enabled {
+		issues = append(issues, c.Server.validate("server")...)
+		if c.Server.Port < 1 || c.Server.Port > 65535 {
+			add("error", "server.port", "%d is not a valid port", c.Server.Port)
+		}
+		if _, ok := c.SecretRefs["DATABASE_URL"]; *c.Server.Replicas > 1 && !ok && settings["DATABASE_URL"] == "" {
+			add("warning", "server.replicas", "replicas without DATABASE_URL keep separate in-memory history, quotas and tenants")
+		}
+	}
+	if *c.Operator.Enabled {
+		issues = append(issues, c.Operator.validate("operator")...)
+		if *c.Operator.Replicas > 1 {
+			add("error", "operator.replicas", "must be 1, concurrent operators would run the same intents twice")
+		}
+		if format == FormatCompose {
+			add("warning", "operator.enabled", "the operator needs Kubernetes and is left out of the Compose file")
+		}
+	}
+	if !*c.Server.Enabled && (!*c.Operator.Enabled || format == FormatCompose) {
+		add("error", "server.enabled", "nothing to deploy with the server disabled")
+	}
+	return issues
+}
+
+func (comp Component) validate(name string) []config.Issue {
+	var issues []config.Issue
+	add := func(key, format string, args ...interface{}) {
+		issues = append(issues, config.Issue{Severity: "error", Section: "install", Key: name + "." + key, Message: fmt.Sprintf(format, args...)})
+	}
+	if *comp.Replicas < 0 {
+		add("replicas", "cannot be negative")
+	}
+	r := comp.Resources
+	cpu, cpuOK := parseCPU(r.CPU)
+	cpuLimit, cpuLimitOK := parseCPU(r.CPULimit)
+	mem, memOK := parseMemory(r.Memory)
+	memLimit, memLimitOK := parseMemory(r.MemoryLimit)
+	for _, q := range []struct {
+		key, value string
+		ok         bool
+	}{
+		{"resources.cpu", r.CPU, cpuOK},
+		{"resources.cpu_limit", r.CPULimit, cpuLimitOK},
+		{"resources.memory", r.Memory, memOK},
+		{"resources.memory_limit", r.MemoryLimit, memLimitOK},
+	} {
+		if !q.ok {
+			add(q.key, "%q is not a valid quantity", q.value)
+		}
+	}
+	if cpuOK && cpuLimitOK && cpu > cpuLimit {
+		add("resources.cpu", "request %s exceeds the limit %s", r.CPU, r.CPULimit)
+	}
+	if memOK && memLimitOK && mem > memLimit {
+		add("resources.memory", "request %s exceeds the limit %s", r.Memory, r.MemoryLimit)
+	}
+	return issues
+}
+
+// parseCPU returns a CPU quantity in cores
+func parseCPU(s string) (float64, bool) {
+	m := cpuPattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, false
+	}
+	v, _ := strconv.ParseFloat(m[1], 64)
+	if m[3] == "m" {
+		v /= 1000
+	}
+	return v, true
+}
+
+// parseMemory returns a memory quantity in bytes
+func parseMemory(s string) (float64, bool) {
+	m := memPattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, false
+	}
+	v, _ := strconv.ParseFloat(m[1], 64)
+	multipliers := map[string]float64{
+		"": 1, "k": 1e3, "M": 1e6, "G": 1e9, "T": 1e12,
+		"Ki": 1 << 10, "Mi": 1 << 20, "Gi": 1 << 30, "Ti": 1 << 40,
+	}
+	return v * multipliers[m[3]], true
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,148 @@
+package install
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"QLP/internal/config"
+)
+
+const testInstall = `install:
+  namespace: apps
+  image: qlp/orchestrator:1.4.0
+  secret_refs:
+    DATABASE_URL: qlp-db/url
+  server:
+    replicas: 3
+    resources: {cpu: 250m, memory_limit: 2Gi}
+  operator:
+    enabled: true
+`
+
+func loadTestConfig(t *testing.T, content string) Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "qlp.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+var testSettings = map[string]string{
+	"QLP_LOG_LEVEL":        "debug",
+	"AZURE_OPENAI_API_KEY": "sk-secret",
+	"DATABASE_URL":         "postgres://local",
+}
+
+func TestRenderKubernetes(t *testing.T) {
+	result, err := Render(loadTestConfig(t, testInstall), testSettings, "prod", FormatKubernetes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"namespace.yaml", "configmap.yaml", "server.yaml", "operator.yaml", "operator-crds.yaml"} {
+		if _, ok := result.Files[name]; !ok {
+			t.Errorf("missing %s", name)
+		}
+	}
+	configMap := result.Files["configmap.yaml"]
+	if !strings.Contains(configMap, "QLP_LOG_LEVEL: debug") || !strings.Contains(configMap, "QLP_PROFILE: prod") {
+		t.Errorf("configmap = %s", configMap)
+	}
+	if strings.Contains(configMap, "sk-secret") || strings.Contains(configMap, "postgres://") {
+		t.Errorf("secret leaked into the configmap: %s", configMap)
+	}
+	server := result.Files["server.yaml"]
+	for _, want := range []string{"replicas: 3", "namespace: apps", "name: qlp-db", "cpu: 250m", "memory: 2Gi", "image: qlp/orchestrator:1.4.0"} {
+		if !strings.Contains(server, want) {
+			t.Errorf("server.yaml is missing %q", want)
+		}
+	}
+	if !strings.Contains(result.Files["operator.yaml"], "serviceAccountName: qlp-operator") {
+		t.Errorf("operator.yaml = %s", result.Files["operator.yaml"])
+	}
+
+	// The API key has no secret reference and is reported, not rendered
+	var warned bool
+	for _, issue := range result.Issues {
+		warned = warned || strings.Contains(issue.Message, "AZURE_OPENAI_API_KEY")
+	}
+	if !warned {
+		t.Errorf("issues = %v", result.Issues)
+	}
+
+	stream := result.Stream()
+	if strings.Index(stream, "# namespace.yaml") > strings.Index(stream, "# operator-crds.yaml") ||
+		strings.Index(stream, "# operator-crds.yaml") > strings.Index(stream, "# server.yaml") {
+		t.Errorf("stream is not in apply order")
+	}
+}
+
+func TestRenderCompose(t *testing.T) {
+	result, err := Render(loadTestConfig(t, testInstall), testSettings, "prod", FormatCompose)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compose, ok := result.Files["docker-compose.yml"]
+	if !ok || len(result.Files) != 1 {
+		t.Fatalf("files = %v", result.Files)
+	}
+	for _, want := range []string{"replicas: 3", "${DATABASE_URL:?set DATABASE_URL}", `cpus: "0.25"`, "memory: 2g"} {
+		if !strings.Contains(compose, want) {
+			t.Errorf("docker-compose.yml is missing %q", want)
+		}
+	}
+	if strings.Contains(compose, "sk-secret") {
+		t.Errorf("secret leaked into the compose file")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		install string
+		key     string
+	}{
+		{"operator replicas", "install:\n  operator: {enabled: true, replicas: 2}\n", "operator.replicas"},
+		{"bad quantity", "install:\n  server:\n    resources: {cpu: lots}\n", "server.resources.cpu"},
+		{"request over limit", "install:\n  server:\n    resources: {memory: 8Gi, memory_limit: 4Gi}\n", "server.resources.memory"},
+		{"bad secret ref", "install:\n  secret_refs: {DATABASE_URL: qlp-db}\n", "secret_refs.DATABASE_URL"},
+		{"bad namespace", "install:\n  namespace: QLP_NS\n", "namespace"},
+		{"nothing to deploy", "install:\n  server: {enabled: false}\n", "server.enabled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Render(loadTestConfig(t, tt.install), nil, "default", FormatKubernetes)
+			if !errors.Is(err, ErrInvalid) {
+				t.Fatalf("err = %v", err)
+			}
+			var found bool
+			for _, issue := range result.Issues {
+				found = found || (issue.Severity == "error" && issue.Key == tt.key)
+			}
+			if !found {
+				t.Errorf("no error for %s in %v", tt.key, result.Issues)
+			}
+		})
+	}
+}
+
+func TestLoadWithoutInstallSection(t *testing.T) {
+	cfg := loadTestConfig(t, "version: 1\nsettings:\n  QLP_LOG_LEVEL: info\n")
+	result, err := Render(cfg, nil, "default", FormatKubernetes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := result.Files["operator.yaml"]; ok {
+		t.Error("operator rendered without being enabled")
+	}
+	if config.HasErrors(result.Issues) || !strings.Contains(result.Files["server.yaml"], "containerPort: 9090") {
+		t.Errorf("issues = %v", result.Issues)
+	}
+}
@@ -0,0 +1,376 @@
+package install
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"QLP/deploy"
+	"QLP/internal/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalid is returned when the configuration has errors
+var ErrInvalid = errors.New("invalid install configuration")
+
+// Labels set on every rendered resource
+const (
+	labelApp       = "app.kubernetes.io/name"
+	labelComponent = "app.kubernetes.io/component"
+	labelManagedBy = "app.kubernetes.io/managed-by"
+)
+
+// workDir holds the files the server keeps without a database
+const workDir = "/var/lib/qlp"
+
+// Result is a rendered deployment
+type Result struct {
+	Format string            `json:"format"`
+	Files  map[string]string `json:"files"` // File name to contents
+	Issues []config.Issue    `json:"issues"`
+}
+
+// Render validates cfg and renders it in format. settings are the resolved
+// settings of the qlp.yaml profile; the non-secret ones configure the
+// server. Configuration errors return ErrInvalid with the issues in the
+// result.
+func Render(cfg Config, settings map[string]string, profile, format string) (*Result, error) {
+	cfg = cfg.withDefaults(settings)
+	result := &Result{Format: format, Issues: cfg.Validate(settings, format)}
+	if config.HasErrors(result.Issues) {
+		return result, ErrInvalid
+	}
+
+	// qlp all-in-one listens on loopback by default; in a container the
+	// Service reaches it on the pod address
+	env := map[string]string{"QLP_PROFILE": profile, "QLP_METRICS_HOST": "0.0.0.0", "QLP_METRICS_PORT": strconv.Itoa(cfg.Server.Port)}
+	for key, value := range settings {
+		if _, ref := cfg.SecretRefs[key]; ref || config.IsSecretKey(key) {
+			continue
+		}
+		env[key] = value
+	}
+
+	var err error
+	if format == FormatCompose {
+		result.Files, err = renderCompose(cfg, env)
+	} else {
+		result.Files, err = renderKubernetes(cfg, env)
+	}
+	if err != nil {
+		return result, err
+	}
+	result.Issues = append(result.Issues, checkManifests(result.Files)...)
+	if config.HasErrors(result.Issues) {
+		return result, fmt.Errorf("rendered manifests are invalid: %s", firstError(result.Issues))
+	}
+	return result, nil
+}
+
+// applyOrder lists the rendered files so that each depends only on earlier ones
+var applyOrder = []string{"namespace.yaml", "operator-crds.yaml", "configmap.yaml", "server.yaml", "operator.yaml", "docker-compose.yml"}
+
+// Stream joins the files into one YAML stream, in the order they can be
+// applied, e.g. piped to kubectl apply -f -
+func (r *Result) Stream() string {
+	var parts []string
+	for _, name := range applyOrder {
+		if data, ok := r.Files[name]; ok {
+			parts = append(parts, "# "+name+"\n"+strings.TrimSuffix(data, "\n")+"\n")
+		}
+	}
+	return strings.Join(parts, "---\n")
+}
+
+// object is a Kubernetes resource; body holds the fields after metadata
+type object struct {
+	APIVersion string                 `yaml:"apiVersion"`
+	Kind       string                 `yaml:"kind"`
+	Metadata   metadata               `yaml:"metadata"`
+	Body       map[string]interface{} `yaml:",inline"`
+}
+
+type metadata struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+func labels(component string) map[string]string {
+	return map[string]string{labelApp: "qlp", labelComponent: component, labelManagedBy: "qlp-install"}
+}
+
+func renderKubernetes(cfg Config, env map[string]string) (map[string]string, error) {
+	ns := cfg.Namespace
+	files := map[string][]object{
+		"namespace.yaml": {{APIVersion: "v1", Kind: "Namespace", Metadata: metadata{Name: ns, Labels: labels("namespace")}}},
+		"configmap.yaml": {{
+			APIVersion: "v1", Kind: "ConfigMap",
+			Metadata: metadata{Name: "qlp-config", Namespace: ns, Labels: labels("config")},
+			Body:     map[string]interface{}{"data": env},
+		}},
+	}
+
+	if *cfg.Server.Enabled {
+		files["server.yaml"] = []object{
+			deployment(cfg, "qlp-server", "server", "", cfg.Server, []string{"all-in-one"}, true),
+			{
+				APIVersion: "v1", Kind: "Service",
+				Metadata: metadata{Name: "qlp-server", Namespace: ns, Labels: labels("server")},
+				Body: map[string]interface{}{"spec": map[string]interface{}{
+					"selector": map[string]string{labelApp: "qlp", labelComponent: "server"},
+					"ports":    []map[string]interface{}{{"name": "http", "port": cfg.Server.Port, "targetPort": "http"}},
+				}},
+			},
+		}
+	}
+
+	out := make(map[string]string, len(files)+2)
+	if *cfg.Operator.Enabled {
+		files["operator.yaml"] = []object{
+			{APIVersion: "v1", Kind: "ServiceAccount", Metadata: metadata{Name: "qlp-operator", Namespace: ns, Labels: labels("operator")}},
+			{
+				APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role",
+				Metadata: metadata{Name: "qlp-operator", Namespace: ns, Labels: labels("operator")},
+				Body: map[string]interface{}{"rules": []map[string]interface{}{
+					{"apiGroups": []string{"qlp.quantumlayer.dev"}, "resources": []string{"intents", "validationruns"}, "verbs": []string{"get", "list", "watch"}},
+					{"apiGroups": []string{"qlp.quantumlayer.dev"}, "resources": []string{"intents/status", "validationruns/status"}, "verbs": []string{"get", "patch", "update"}},
+					{"apiGroups": []string{"qlp.quantumlayer.dev"}, "resources": []string{"capsules"}, "verbs": []string{"get", "list", "create", "patch", "update"}},
+				}},
+			},
+			{
+				APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding",
+				Metadata: metadata{Name: "qlp-operator", Namespace: ns, Labels: labels("operator")},
+				Body: map[string]interface{}{
+					"roleRef":  map[string]string{"apiGroup": "rbac.authorization.k8s.io", "kind": "Role", "name": "qlp-operator"},
+					"subjects": []map[string]string{{"kind": "ServiceAccount", "name": "qlp-operator", "namespace": ns}},
+				},
+			},
+			deployment(cfg, "qlp-operator", "operator", "qlp-operator", cfg.Operator, []string{"operator"}, false),
+		}
+		out["operator-crds.yaml"] = deploy.OperatorCRDs
+	}
+
+	for name, objects := range files {
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		for _, obj := range objects {
+			if err := enc.Encode(obj); err != nil {
+				return nil, fmt.Errorf("failed to render %s: %w", name, err)
+			}
+		}
+		enc.Close()
+		out[name] = buf.String()
+	}
+	return out, nil
+}
+
+func deployment(cfg Config, name, component, serviceAccount string, comp Component, args []string, serve bool) object {
+	var env []map[string]interface{}
+	for _, key := range sortedKeys(cfg.SecretRefs) {
+		secret, secretKey, _ := strings.Cut(cfg.SecretRefs[key], "/")
+		env = append(env, map[string]interface{}{
+			"name":      key,
+			"valueFrom": map[string]interface{}{"secretKeyRef": map[string]string{"name": secret, "key": secretKey}},
+		})
+	}
+	r := comp.Resources
+	container := map[string]interface{}{
+		"name":       component,
+		"image":      cfg.Image,
+		"args":       args,
+		"workingDir": workDir,
+		"envFrom":    []map[string]interface{}{{"configMapRef": map[string]string{"name": "qlp-config"}}},
+		"resources": map[string]interface{}{
+			"requests": map[string]string{"cpu": r.CPU, "memory": r.Memory},
+			"limits":   map[string]string{"cpu": r.CPULimit, "memory": r.MemoryLimit},
+		},
+		"securityContext": map[string]interface{}{
+			"runAsNonRoot":             true,
+			"allowPrivilegeEscalation": false,
+		},
+		"volumeMounts": []map[string]string{{"name": "data", "mountPath": workDir}},
+	}
+	if len(env) > 0 {
+		container["env"] = env
+	}
+	if serve {
+		container["ports"] = []map[string]interface{}{{"name": "http", "containerPort": comp.Port}}
+		probe := map[string]interface{}{"httpGet": map[string]interface{}{"path": "/metrics", "port": "http"}, "periodSeconds": 10}
+		container["readinessProbe"] = probe
+		container["livenessProbe"] = map[string]interface{}{
+			"httpGet":             map[string]interface{}{"path": "/metrics", "port": "http"},
+			"initialDelaySeconds": 30,
+			"periodSeconds":       10,
+		}
+	}
+
+	pod := map[string]interface{}{
+		"containers": []interface{}{container},
+		"volumes":    []map[string]interface{}{{"name": "data", "emptyDir": map[string]string{}}},
+	}
+	if serviceAccount != "" {
+		pod["serviceAccountName"] = serviceAccount
+	}
+	selector := map[string]string{labelApp: "qlp", labelComponent: component}
+	return object{
+		APIVersion: "apps/v1", Kind: "Deployment",
+		Metadata: metadata{Name: name, Namespace: cfg.Namespace, Labels: labels(component)},
+		Body: map[string]interface{}{"spec": map[string]interface{}{
+			"replicas": *comp.Replicas,
+			"selector": map[string]interface{}{"matchLabels": selector},
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": labels(component)},
+				"spec":     pod,
+			},
+		}},
+	}
+}
+
+func renderCompose(cfg Config, env map[string]string) (map[string]string, error) {
+	environment := make(map[string]string, len(env)+len(cfg.SecretRefs))
+	for key, value := range env {
+		environment[key] = value
+	}
+	// Secrets come from the shell or the .env file next to the Compose file
+	for key := range cfg.SecretRefs {
+		environment[key] = "${" + key + ":?set " + key + "}"
+	}
+
+	r := cfg.Server.Resources
+	port := strconv.Itoa(cfg.Server.Port)
+	ports := []string{port + ":" + port}
+	if *cfg.Server.Replicas > 1 {
+		// Replicas cannot share a host port
+		ports = []string{port}
+	}
+	server := map[string]interface{}{
+		"image":       cfg.Image,
+		"command":     []string{"all-in-one"},
+		"working_dir": workDir,
+		"environment": environment,
+		"ports":       ports,
+		"volumes":     []string{"qlp-data:" + workDir},
+		"restart":     "unless-stopped",
+		"healthcheck": map[string]interface{}{
+			"test":     []string{"CMD", "wget", "-qO-", "http://localhost:" + port + "/metrics"},
+			"interval": "10s",
+		},
+		"deploy": map[string]interface{}{
+			"replicas": *cfg.Server.Replicas,
+			"resources": map[string]interface{}{
+				"reservations": map[string]string{"cpus": composeCPU(r.CPU), "memory": composeMemory(r.Memory)},
+				"limits":       map[string]string{"cpus": composeCPU(r.CPULimit), "memory": composeMemory(r.MemoryLimit)},
+			},
+		},
+	}
+	file := map[string]interface{}{
+		"name":     "qlp",
+		"services": map[string]interface{}{"qlp-server": server},
+		"volumes":  map[string]interface{}{"qlp-data": map[string]string{}},
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(file); err != nil {
+		return nil, fmt.Errorf("failed to render docker-compose.yml: %w", err)
+	}
+	enc.Close()
+	return map[string]string{"docker-compose.yml": buf.String()}, nil
+}
+
+// composeCPU converts a Kubernetes CPU quantity to Compose's decimal cores
+func composeCPU(q string) string {
+	cores, _ := parseCPU(q)
+	return strconv.FormatFloat(cores, 'f', -1, 64)
+}
+
+// composeMemory converts a Kubernetes memory quantity to Compose's byte units
+func composeMemory(q string) string {
+	for suffix, unit := range map[string]string{"Ki": "k", "Mi": "m", "Gi": "g", "Ti": "t"} {
+		if strings.HasSuffix(q, suffix) && !strings.Contains(q, ".") {
+			return strings.TrimSuffix(q, suffix) + unit
+		}
+	}
+	bytes, _ := parseMemory(q)
+	return strconv.FormatFloat(bytes, 'f', 0, 64)
+}
+
+// checkManifests parses the rendered files back and checks that every
+// Kubernetes resource is identified and every container is bounded
+func checkManifests(files map[string]string) []config.Issue {
+	var issues []config.Issue
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		dec := yaml.NewDecoder(strings.NewReader(files[name]))
+		for i := 0; ; i++ {
+			var doc map[string]interface{}
+			err := dec.Decode(&doc)
+			if err == io.EOF {
+				break
+			}
+			issue := config.Issue{Severity: "error", Section: name, Key: fmt.Sprintf("document %d", i+1)}
+			if err != nil {
+				issue.Message = fmt.Sprintf("not valid YAML: %v", err)
+				issues = append(issues, issue)
+				break
+			}
+			if name == "docker-compose.yml" {
+				if _, ok := doc["services"].(map[string]interface{}); !ok {
+					issue.Message = "has no services"
+					issues = append(issues, issue)
+				}
+				continue
+			}
+			meta, _ := doc["metadata"].(map[string]interface{})
+			if doc["apiVersion"] == nil || doc["kind"] == nil || meta["name"] == nil {
+				issue.Message = "needs apiVersion, kind and metadata.name"
+				issues = append(issues, issue)
+				continue
+			}
+			if doc["kind"] == "Deployment" && !containersBounded(doc) {
+				issue.Message = fmt.Sprintf("deployment %v has a container without resource limits", meta["name"])
+				issues = append(issues, issue)
+			}
+		}
+	}
+	return issues
+}
+
+func containersBounded(doc map[string]interface{}) bool {
+	spec, _ := doc["spec"].(map[string]interface{})
+	template, _ := spec["template"].(map[string]interface{})
+	pod, _ := template["spec"].(map[string]interface{})
+	containers, _ := pod["containers"].([]interface{})
+	if len(containers) == 0 {
+		return false
+	}
+	for _, c := range containers {
+		container, _ := c.(map[string]interface{})
+		resources, _ := container["resources"].(map[string]interface{})
+		limits, _ := resources["limits"].(map[string]interface{})
+		if limits["cpu"] == nil || limits["memory"] == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func firstError(issues []config.Issue) config.Issue {
+	for _, issue := range issues {
+		if issue.Severity == "error" {
+			return issue
+		}
+	}
+	return config.Issue{}
+}
@@ -0,0 +1,189 @@
+// Package intentlang detects the language an intent is written in and
+// normalizes non-English intents before decomposition, either by translating
+// them to English or by letting the provider read them natively.
+package intentlang
+
+import (
+	"strings"
+	"unicode"
+)
+
+// English is the language decomposition prompts are written in
+const English = "en"
+
+// Detection is the language detected for a text. Confidence is between 0
+// and 1.
+type Detection struct {
+	Code       string  `json:"code"`
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"`
+}
+
+// names maps the detectable languages to their English names
+var names = map[string]string{
+	"en": "English", "de": "German", "es": "Spanish", "fr": "French", "pt": "Portuguese",
+	"it": "Italian", "nl": "Dutch", "ja": "Japanese", "zh": "Chinese", "ko": "Korean",
+	"ru": "Russian", "uk": "Ukrainian", "ar": "Arabic", "he": "Hebrew", "hi": "Hindi",
+	"th": "Thai", "el": "Greek",
+}
+
+// Name returns the English name of a language code, the code itself when it
+// is unknown
+func Name(code string) string {
+	if name, ok := names[code]; ok {
+		return name
+	}
+	return code
+}
+
+// scripts identifies languages written in their own script. Japanese is
+// told from Chinese by its kana, Ukrainian from Russian by its letters.
+var scripts = []struct {
+	code  string
+	table *unicode.RangeTable
+}{
+	{"ja", unicode.Hiragana},
+	{"ja", unicode.Katakana},
+	{"ko", unicode.Hangul},
+	{"zh", unicode.Han},
+	{"ru", unicode.Cyrillic},
+	{"ar", unicode.Arabic},
+	{"he", unicode.Hebrew},
+	{"hi", unicode.Devanagari},
+	{"th", unicode.Thai},
+	{"el", unicode.Greek},
+}
+
+// stopwords are frequent function words of the languages written in Latin
+// script. Intents are short and full of English technical terms, so only
+// words that never name a technology are listed.
+var stopwords = map[string][]string{
+	"en": {"the", "a", "an", "and", "with", "for", "to", "of", "in", "that", "which", "should", "is", "are",
+		"be", "using", "on", "from", "it", "its", "each", "into", "must", "by", "or", "where", "when"},
+	"de": {"der", "die", "das", "und", "mit", "für", "ein", "eine", "einen", "einem", "einer", "ist", "zu",
+		"von", "den", "dem", "des", "auf", "nicht", "soll", "sollen", "wird", "werden", "oder", "auch",
+		"erstelle", "erstellen", "baue", "bitte", "über", "sowie", "jeder", "jede", "dass"},
+	"es": {"el", "la", "los", "las", "y", "con", "para", "que", "un", "una", "del", "en", "por", "es",
+		"crea", "crear", "construye", "se", "al", "sus", "debe", "cada", "usando", "como", "sobre"},
+	"fr": {"le", "la", "les", "et", "avec", "pour", "que", "un", "une", "des", "du", "est", "qui", "dans",
+		"sur", "crée", "créer", "créez", "au", "aux", "chaque", "doit", "utilisant", "il", "être"},
+	"pt": {"o", "os", "as", "e", "com", "para", "que", "um", "uma", "do", "da", "dos", "das", "em", "por",
+		"crie", "criar", "construa", "não", "cada", "deve", "usando", "ao", "sobre"},
+	"it": {"il", "lo", "gli", "e", "con", "per", "che", "un", "una", "di", "del", "della", "dei", "delle",
+		"crea", "creare", "costruisci", "è", "ogni", "deve", "usando", "sono", "nel", "alla"},
+	"nl": {"de", "het", "een", "en", "met", "voor", "van", "dat", "die", "is", "maak", "bouw", "op", "niet",
+		"te", "moet", "elke", "gebruik", "wordt", "naar"},
+}
+
+// letters distinctive of a Latin-script language, each counted as a stopword
+var letters = map[rune]string{
+	'ß': "de", 'ä': "de", 'ö': "de", 'ü': "de",
+	'ñ': "es", '¿': "es", '¡': "es",
+	'ç': "fr", 'è': "fr", 'ê': "fr", 'œ': "fr",
+	'ã': "pt", 'õ': "pt",
+	'ì': "it", 'ò': "it",
+}
+
+var stopwordIndex = func() map[string][]string {
+	index := make(map[string][]string)
+	for code, words := range stopwords {
+		for _, w := range words {
+			index[w] = append(index[w], code)
+		}
+	}
+	return index
+}()
+
+// minForeignHits is how many function words a Latin-script text needs before
+// it is taken for another language than English
+const minForeignHits = 2
+
+// Detect returns the language of text. Texts written mostly in another
+// script are identified by it; Latin-script texts by their function words.
+// Texts with too little to go on are taken for English.
+func Detect(text string) Detection {
+	if d, ok := detectScript(text); ok {
+		return d
+	}
+	return detectLatin(text)
+}
+
+// detectScript identifies texts of which at least a tenth of the letters
+// are in a non-Latin script; code and technology names are Latin even in
+// Japanese intents. Confidence is the share of those letters in the
+// detected language's script.
+func detectScript(text string) (Detection, bool) {
+	counts := make(map[string]int)
+	total, foreign := 0, 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		total++
+		for _, s := range scripts {
+			if unicode.Is(s.table, r) {
+				counts[s.code]++
+				foreign++
+				break
+			}
+		}
+	}
+	if foreign == 0 || foreign*10 < total {
+		return Detection{}, false
+	}
+	code := ""
+	for _, s := range scripts {
+		if code == "" || counts[s.code] > counts[code] {
+			code = s.code
+		}
+	}
+	// Kanji are Han: any kana makes the text Japanese
+	if counts["ja"] > 0 {
+		code = "ja"
+	}
+	if code == "ru" && strings.ContainsAny(strings.ToLower(text), "їієґ") {
+		code = "uk"
+	}
+	inScript := counts[code]
+	if code == "ja" {
+		inScript += counts["zh"]
+	}
+	return Detection{Code: code, Name: Name(code), Confidence: round(float64(inScript) / float64(foreign))}, true
+}
+
+func detectLatin(text string) Detection {
+	hits := make(map[string]int)
+	lower := strings.ToLower(text)
+	for _, r := range lower {
+		if code, ok := letters[r]; ok {
+			hits[code]++
+		}
+	}
+	words := strings.FieldsFunc(lower, func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	for _, w := range words {
+		for _, code := range stopwordIndex[w] {
+			hits[code]++
+		}
+	}
+
+	best, total := English, 0
+	for code, n := range hits {
+		total += n
+		if n > hits[best] || (n == hits[best] && code < best && best != English) {
+			best = code
+		}
+	}
+	if best != English && hits[best] < minForeignHits {
+		best = English
+	}
+	if total == 0 {
+		return Detection{Code: English, Name: Name(English), Confidence: 0.5}
+	}
+	return Detection{Code: best, Name: Name(best), Confidence: round(float64(hits[best]) / float64(total))}
+}
+
+func round(f float64) float64 {
+	return float64(int(f*100+0.5)) / 100
+}
@@ -0,0 +1,82 @@
+package intentlang
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	cases := map[string]string{
+		"Build a REST API that stores orders in PostgreSQL with JWT auth": "en",
+		"Dockerfile for nginx": "en",
+		"Erstelle eine REST-API mit JWT-Authentifizierung und PostgreSQL für Bestellungen":  "de",
+		"Crea una API REST con autenticación JWT y PostgreSQL para gestionar los pedidos":   "es",
+		"Créez une API REST avec une authentification JWT et PostgreSQL pour les commandes": "fr",
+		"JWT 認証付きの REST API を Go で作成し、注文を PostgreSQL に保存してください":                             "ja",
+		"使用 Go 构建一个 REST API，并将订单存储在 PostgreSQL 中":                                          "zh",
+		"Создай REST API на Go с JWT-аутентификацией и хранением заказов в PostgreSQL":      "ru",
+		"Go로 JWT 인증이 있는 REST API를 만들어 주세요":                                                  "ko",
+	}
+	for text, want := range cases {
+		got := Detect(text)
+		if got.Code != want {
+			t.Errorf("Detect(%q) = %s, want %s", text, got.Code, want)
+		}
+		if got.Name == "" || got.Confidence <= 0 || got.Confidence > 1 {
+			t.Errorf("Detect(%q) returned %+v", text, got)
+		}
+	}
+}
+
+type fakeClient struct {
+	response string
+	err      error
+	prompts  []string
+}
+
+func (c *fakeClient) Complete(ctx context.Context, prompt string) (string, error) {
+	c.prompts = append(c.prompts, prompt)
+	return c.response, c.err
+}
+
+func (c *fakeClient) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	return nil, nil
+}
+
+const german = "Erstelle eine REST-API mit JWT-Authentifizierung und PostgreSQL für Bestellungen"
+
+func TestNormalizeTranslates(t *testing.T) {
+	client := &fakeClient{response: `{"translation": "Create a REST API with JWT authentication and PostgreSQL for orders"}`}
+
+	result := NewNormalizer(client, ModeTranslate, nil).Normalize(context.Background(), german)
+	if !result.Translated() || result.Text != "Create a REST API with JWT authentication and PostgreSQL for orders" {
+		t.Fatalf("expected the translation, got %+v", result)
+	}
+	metadata := result.Metadata()
+	if metadata["language"] != "de" || metadata["language.handling"] != HandlingTranslated || metadata["intent.original_text"] != german {
+		t.Errorf("unexpected metadata: %v", metadata)
+	}
+
+	if result := NewNormalizer(client, ModeTranslate, nil).Normalize(context.Background(), "Build a user API with the Go standard library"); result.Handling != HandlingNone || len(client.prompts) != 1 {
+		t.Errorf("expected English intents as they are, got %+v after %d prompts", result, len(client.prompts))
+	}
+}
+
+func TestNormalizeReadsNatively(t *testing.T) {
+	for name, n := range map[string]*Normalizer{
+		"native mode":     NewNormalizer(&fakeClient{}, ModeNative, nil),
+		"native language": NewNormalizer(&fakeClient{}, ModeTranslate, []string{"es", " de"}),
+		"failed":          NewNormalizer(&fakeClient{err: errors.New("provider down")}, ModeTranslate, nil),
+		"not english":     NewNormalizer(&fakeClient{response: `{"translation": "` + german + `"}`}, ModeTranslate, nil),
+	} {
+		result := n.Normalize(context.Background(), german)
+		if result.Handling != HandlingNative || !strings.HasPrefix(result.Text, german) || !strings.Contains(result.Text, "written in German") {
+			t.Errorf("%s: expected the intent read natively, got %+v", name, result)
+		}
+		if _, ok := result.Metadata()["intent.original_text"]; ok {
+			t.Errorf("%s: untranslated intents need no copy of the original", name)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package intentlang
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"QLP/internal/config"
+	"QLP/internal/llm"
+	"QLP/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Mode decides how non-English intents reach decomposition
+type Mode string
+
+const (
+	// ModeTranslate translates intents to English, except those in the
+	// languages the provider is configured to read natively
+	ModeTranslate Mode = "translate"
+	// ModeNative passes every intent to the provider as written
+	ModeNative Mode = "native"
+)
+
+// Handling records what was done with an intent
+const (
+	HandlingNone       = "none"       // The intent is in English
+	HandlingTranslated = "translated" // Decomposition reads the English translation
+	HandlingNative     = "native"     // Decomposition reads the intent as written
+)
+
+// Result is an intent ready for decomposition. Text is what decomposition
+// reads; Original is what the user wrote.
+type Result struct {
+	Original string    `json:"original"`
+	Text     string    `json:"text"`
+	Language Detection `json:"language"`
+	Handling string    `json:"handling"`
+}
+
+// Translated reports whether decomposition reads a translation
+func (r *Result) Translated() bool {
+	return r != nil && r.Handling == HandlingTranslated
+}
+
+// Metadata records the detected language on the intent, with the original
+// and translated text when the intent was translated
+func (r *Result) Metadata() map[string]string {
+	if r == nil {
+		return nil
+	}
+	metadata := map[string]string{
+		"language":            r.Language.Code,
+		"language.name":       r.Language.Name,
+		"language.confidence": strconv.FormatFloat(r.Language.Confidence, 'f', 2, 64),
+		"language.handling":   r.Handling,
+	}
+	if r.Translated() {
+		metadata["intent.original_text"] = r.Original
+		metadata["intent.translated_text"] = r.Text
+	}
+	return metadata
+}
+
+// Normalizer detects the language of intents and translates or annotates
+// those that are not in English
+type Normalizer struct {
+	client llm.Client
+	mode   Mode
+	native map[string]bool
+}
+
+// NewNormalizer handles non-English intents according to mode; native lists
+// the languages read natively in ModeTranslate. Without a client intents
+// are always read natively.
+func NewNormalizer(client llm.Client, mode Mode, native []string) *Normalizer {
+	n := &Normalizer{client: client, mode: mode, native: make(map[string]bool)}
+	for _, code := range native {
+		if code = strings.ToLower(strings.TrimSpace(code)); code != "" {
+			n.native[code] = true
+		}
+	}
+	return n
+}
+
+// NormalizerFromEnv configures a normalizer from QLP_INTENT_LANGUAGE_MODE
+// (translate or native) and QLP_INTENT_NATIVE_LANGUAGES, a comma-separated
+// list of language codes the provider reads natively
+func NormalizerFromEnv(client llm.Client) *Normalizer {
+	mode := Mode(config.GetEnvOrDefault("QLP_INTENT_LANGUAGE_MODE", string(ModeTranslate)))
+	if mode != ModeTranslate && mode != ModeNative {
+		logger.WithComponent("intentlang").Warn("Unknown QLP_INTENT_LANGUAGE_MODE, translating intents",
+			zap.String("mode", string(mode)))
+		mode = ModeTranslate
+	}
+	var native []string
+	if list := config.GetEnvOrDefault("QLP_INTENT_NATIVE_LANGUAGES", ""); list != "" {
+		native = strings.Split(list, ",")
+	}
+	return NewNormalizer(client, mode, native)
+}
+
+// Normalize detects the language of an intent and prepares it for
+// decomposition. Intents whose translation fails are read natively.
+func (n *Normalizer) Normalize(ctx context.Context, text string) *Result {
+	result := &Result{Original: text, Text: text, Language: Detect(text), Handling: HandlingNone}
+	if result.Language.Code == English {
+		return result
+	}
+
+	if n.mode == ModeTranslate && !n.native[result.Language.Code] && n.client != nil {
+		translation, err := n.translate(ctx, text, result.Language)
+		if err == nil {
+			result.Text, result.Handling = translation, HandlingTranslated
+			return result
+		}
+		logger.WithComponent("intentlang").Warn("Failed to translate intent, reading it natively",
+			zap.String("language", result.Language.Code),
+			zap.Error(err))
+	}
+
+	result.Text = text + nativeContext(result.Language)
+	result.Handling = HandlingNative
+	return result
+}
+
+// nativeContext asks for English output when the provider reads an intent
+// as written, so task descriptions and generated code stay consistent
+func nativeContext(lang Detection) string {
+	return fmt.Sprintf("\n\nThe request above is written in %s. Interpret it in %s, but write task descriptions, identifiers, comments and documentation in English.",
+		lang.Name, lang.Name)
+}
+
+func (n *Normalizer) translate(ctx context.Context, text string, lang Detection) (string, error) {
+	prompt := fmt.Sprintf(`Translate this request to a code generation system from %s to English. Keep its meaning, requirements and level of detail exactly; do not answer or expand it. Leave code, identifiers, file names, product and technology names, numbers and quoted strings unchanged.
+
+%s
+
+Respond with JSON: {"translation": "<the request in English>"}`, lang.Name, text)
+
+	var response struct {
+		Translation string `json:"translation"`
+	}
+	schema := llm.SchemaFor("intent_translation", "An intent translated to English", response)
+	if err := llm.CompleteJSON(ctx, n.client, prompt, schema, &response); err != nil {
+		return "", err
+	}
+	translation := strings.TrimSpace(response.Translation)
+	if translation == "" {
+		return "", fmt.Errorf("empty translation")
+	}
+	if got := Detect(translation); got.Code != English {
+		return "", fmt.Errorf("translation reads as %s, not English", got.Name)
+	}
+	return translation, nil
+}
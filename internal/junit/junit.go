@@ -0,0 +1,136 @@
+// Package junit writes test results as JUnit XML, the report format most CI
+// systems understand.
+package junit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+)
+
+// MediaType is the content type of JUnit XML reports
+const MediaType = "application/xml"
+
+// TestSuites is the root <testsuites> element
+type TestSuites struct {
+	XMLName  xml.Name    `xml:"testsuites"`
+	Name     string      `xml:"name,attr,omitempty"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Suites   []TestSuite `xml:"testsuite"`
+}
+
+// TestSuite is a <testsuite> element
+type TestSuite struct {
+	Name      string     `xml:"name,attr"`
+	Tests     int        `xml:"tests,attr"`
+	Failures  int        `xml:"failures,attr"`
+	Skipped   int        `xml:"skipped,attr"`
+	Time      string     `xml:"time,attr"`
+	Timestamp string     `xml:"timestamp,attr,omitempty"`
+	Cases     []TestCase `xml:"testcase"`
+
+	duration time.Duration
+}
+
+// TestCase is a <testcase> element
+type TestCase struct {
+	Name      string   `xml:"name,attr"`
+	ClassName string   `xml:"classname,attr"`
+	Time      string   `xml:"time,attr"`
+	Failure   *Failure `xml:"failure,omitempty"`
+	Skipped   *Skipped `xml:"skipped,omitempty"`
+	SystemOut string   `xml:"system-out,omitempty"`
+}
+
+// Failure marks a failed test case
+type Failure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Details string `xml:",chardata"`
+}
+
+// Skipped marks a skipped test case
+type Skipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// Case is a tool-neutral test outcome to convert into a <testcase>
+type Case struct {
+	Name     string
+	Class    string
+	Duration time.Duration
+	Failed   bool
+	Skipped  bool
+	Message  string // failure or skip reason
+	Details  string // failure details, e.g. failed assertions
+	Output   string
+}
+
+// NewSuite builds a suite from test outcomes
+func NewSuite(name string, started time.Time, cases []Case) TestSuite {
+	suite := TestSuite{Name: name, Cases: make([]TestCase, 0, len(cases))}
+	if !started.IsZero() {
+		suite.Timestamp = started.UTC().Format("2006-01-02T15:04:05")
+	}
+
+	var total time.Duration
+	for _, c := range cases {
+		tc := TestCase{
+			Name:      c.Name,
+			ClassName: c.Class,
+			Time:      seconds(c.Duration),
+			SystemOut: c.Output,
+		}
+		if tc.ClassName == "" {
+			tc.ClassName = name
+		}
+		switch {
+		case c.Skipped:
+			tc.Skipped = &Skipped{Message: c.Message}
+			suite.Skipped++
+		case c.Failed:
+			message := c.Message
+			if message == "" {
+				message = "test failed"
+			}
+			tc.Failure = &Failure{Message: message, Details: c.Details}
+			suite.Failures++
+		}
+		total += c.Duration
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Tests = len(cases)
+	suite.Time = seconds(total)
+	suite.duration = total
+	return suite
+}
+
+// New wraps suites in a <testsuites> report with the totals filled in
+func New(name string, suites ...TestSuite) *TestSuites {
+	report := &TestSuites{Name: name, Suites: suites}
+	var total time.Duration
+	for _, s := range suites {
+		report.Tests += s.Tests
+		report.Failures += s.Failures
+		report.Skipped += s.Skipped
+		total += s.duration
+	}
+	report.Time = seconds(total)
+	return report
+}
+
+// Marshal encodes the report as an indented XML document
+func (t *TestSuites) Marshal() ([]byte, error) {
+	data, err := xml.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JUnit report: %w", err)
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,42 @@
+package junit
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReport(t *testing.T) {
+	suite := NewSuite("functional", time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), []Case{
+		{Name: "health", Duration: 120 * time.Millisecond},
+		{Name: "create user", Class: "functional POST /users", Duration: 2 * time.Second, Failed: true, Details: "status: expected 201, got 500 <html>"},
+		{Name: "load", Skipped: true, Message: "no load profile"},
+	})
+	if suite.Tests != 3 || suite.Failures != 1 || suite.Skipped != 1 || suite.Time != "2.120" {
+		t.Errorf("suite = %+v", suite)
+	}
+	if suite.Cases[0].ClassName != "functional" || suite.Cases[1].Failure.Message != "test failed" {
+		t.Errorf("cases = %+v", suite.Cases)
+	}
+
+	report := New("QL-CAP-1", suite, NewSuite("health", time.Time{}, nil))
+	if report.Tests != 3 || report.Failures != 1 || report.Time != "2.120" {
+		t.Errorf("report totals = %+v", report)
+	}
+
+	data, err := report.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	if !strings.HasPrefix(out, xml.Header) || !strings.Contains(out, "got 500 &lt;html&gt;") ||
+		!strings.Contains(out, `timestamp="2025-01-02T03:04:05"`) {
+		t.Errorf("unexpected XML:\n%s", out)
+	}
+
+	var decoded TestSuites
+	if err := xml.Unmarshal(data, &decoded); err != nil || len(decoded.Suites) != 2 {
+		t.Errorf("round trip failed: %v", err)
+	}
+}
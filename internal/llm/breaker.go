@@ -0,0 +1,335 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"QLP/internal/config"
+	"QLP/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// ErrCircuitOpen is returned when every provider's circuit is open
+var ErrCircuitOpen = errors.New("circuit open")
+
+// CircuitState is the state of a provider's circuit breaker
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"    // Calls flow normally
+	CircuitOpen     CircuitState = "open"      // Calls skip the provider until OpenFor passes
+	CircuitHalfOpen CircuitState = "half_open" // One probe call decides whether to close again
+)
+
+// BreakerConfig sets when a provider's circuit opens
+type BreakerConfig struct {
+	Window    int           // Recent calls the rates are computed over
+	MinCalls  int           // Calls needed in the window before the circuit can open
+	ErrorRate float64       // Share of failed calls that opens the circuit
+	SlowCall  time.Duration // Calls slower than this count as slow
+	SlowRate  float64       // Share of slow calls that opens the circuit
+	OpenFor   time.Duration // Time before an open circuit lets a probe through
+}
+
+// DefaultBreakerConfig opens a circuit when half of the last 20 calls failed
+// or took over a minute, and probes again after 30 seconds
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		Window:    20,
+		MinCalls:  5,
+		ErrorRate: 0.5,
+		SlowCall:  time.Minute,
+		SlowRate:  0.5,
+		OpenFor:   30 * time.Second,
+	}
+}
+
+// BreakerConfigFromEnv reads QLP_LLM_BREAKER_WINDOW, QLP_LLM_BREAKER_MIN_CALLS,
+// QLP_LLM_BREAKER_ERROR_RATE, QLP_LLM_BREAKER_SLOW_CALL,
+// QLP_LLM_BREAKER_SLOW_RATE and QLP_LLM_BREAKER_OPEN_FOR over the defaults
+func BreakerConfigFromEnv() BreakerConfig {
+	cfg := DefaultBreakerConfig()
+	if n, err := strconv.Atoi(config.GetEnvOrDefault("QLP_LLM_BREAKER_WINDOW", "")); err == nil && n > 0 {
+		cfg.Window = n
+	}
+	if n, err := strconv.Atoi(config.GetEnvOrDefault("QLP_LLM_BREAKER_MIN_CALLS", "")); err == nil && n > 0 {
+		cfg.MinCalls = n
+	}
+	if f, err := strconv.ParseFloat(config.GetEnvOrDefault("QLP_LLM_BREAKER_ERROR_RATE", ""), 64); err == nil && f > 0 {
+		cfg.ErrorRate = f
+	}
+	if d, err := time.ParseDuration(config.GetEnvOrDefault("QLP_LLM_BREAKER_SLOW_CALL", "")); err == nil && d > 0 {
+		cfg.SlowCall = d
+	}
+	if f, err := strconv.ParseFloat(config.GetEnvOrDefault("QLP_LLM_BREAKER_SLOW_RATE", ""), 64); err == nil && f > 0 {
+		cfg.SlowRate = f
+	}
+	if d, err := time.ParseDuration(config.GetEnvOrDefault("QLP_LLM_BREAKER_OPEN_FOR", "")); err == nil && d > 0 {
+		cfg.OpenFor = d
+	}
+	return cfg
+}
+
+// ProviderHealth is a provider's circuit as reported by /api/v1/providers
+type ProviderHealth struct {
+	Provider     string        `json:"provider"`
+	State        CircuitState  `json:"state"`
+	ErrorRate    float64       `json:"error_rate"`
+	SlowRate     float64       `json:"slow_rate"`
+	AvgLatency   time.Duration `json:"avg_latency_ns"`
+	Calls        int64         `json:"calls"`
+	Failures     int64         `json:"failures"`
+	OpenedAt     *time.Time    `json:"opened_at,omitempty"`
+	LastError    string        `json:"last_error,omitempty"`
+	LastFailedAt *time.Time    `json:"last_failed_at,omitempty"`
+}
+
+type outcome struct {
+	failed  bool
+	slow    bool
+	latency time.Duration
+}
+
+// Breaker tracks one provider's recent calls and opens its circuit when too
+// many fail or run slow
+type Breaker struct {
+	mu           sync.Mutex
+	provider     string
+	cfg          BreakerConfig
+	state        CircuitState
+	outcomes     []outcome // Ring of the last cfg.Window calls
+	next         int
+	openedAt     time.Time
+	probing      bool
+	calls        int64
+	failures     int64
+	lastError    string
+	lastFailedAt time.Time
+	now          func() time.Time
+}
+
+// NewBreaker creates a closed breaker for provider
+func NewBreaker(provider string, cfg BreakerConfig) *Breaker {
+	return &Breaker{provider: provider, cfg: cfg, state: CircuitClosed, now: time.Now}
+}
+
+// Allow reports whether a call may go to the provider. Once OpenFor has
+// passed, an open circuit turns half-open and lets a single probe through.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		if b.now().Sub(b.openedAt) < b.cfg.OpenFor {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		return true
+	case CircuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Record counts the outcome of a call the breaker allowed
+func (b *Breaker) Record(latency time.Duration, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	o := outcome{failed: err != nil, slow: latency > b.cfg.SlowCall, latency: latency}
+	b.calls++
+	if o.failed {
+		b.failures++
+		b.lastError = err.Error()
+		b.lastFailedAt = b.now()
+	}
+
+	if b.state == CircuitHalfOpen {
+		b.probing = false
+		if o.failed || o.slow {
+			b.open()
+			return
+		}
+		b.state = CircuitClosed
+		b.outcomes, b.next = nil, 0
+		logger.WithComponent("llm").Info("Provider circuit closed", zap.String("provider", b.provider))
+	}
+
+	if len(b.outcomes) < b.cfg.Window {
+		b.outcomes = append(b.outcomes, o)
+	} else {
+		b.outcomes[b.next] = o
+		b.next = (b.next + 1) % b.cfg.Window
+	}
+	if b.state == CircuitClosed && len(b.outcomes) >= b.cfg.MinCalls {
+		errorRate, slowRate, _ := b.rates()
+		if errorRate >= b.cfg.ErrorRate || slowRate >= b.cfg.SlowRate {
+			b.open()
+		}
+	}
+}
+
+// Release gives back a probe that ended without an outcome, such as a call
+// whose caller gave up
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *Breaker) open() {
+	b.state = CircuitOpen
+	b.openedAt = b.now()
+	logger.WithComponent("llm").Warn("Provider circuit opened",
+		zap.String("provider", b.provider),
+		zap.String("last_error", b.lastError),
+		zap.Duration("open_for", b.cfg.OpenFor))
+}
+
+func (b *Breaker) rates() (float64, float64, time.Duration) {
+	if len(b.outcomes) == 0 {
+		return 0, 0, 0
+	}
+	var failed, slow int
+	var latency time.Duration
+	for _, o := range b.outcomes {
+		if o.failed {
+			failed++
+		}
+		if o.slow {
+			slow++
+		}
+		latency += o.latency
+	}
+	n := len(b.outcomes)
+	return float64(failed) / float64(n), float64(slow) / float64(n), latency / time.Duration(n)
+}
+
+// State returns the circuit's state, turning half-open when a probe is due
+func (b *Breaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.cfg.OpenFor {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// Health reports the breaker's state and recent call statistics
+func (b *Breaker) Health() ProviderHealth {
+	state := b.State()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	errorRate, slowRate, latency := b.rates()
+	h := ProviderHealth{
+		Provider:   b.provider,
+		State:      state,
+		ErrorRate:  errorRate,
+		SlowRate:   slowRate,
+		AvgLatency: latency,
+		Calls:      b.calls,
+		Failures:   b.failures,
+		LastError:  b.lastError,
+	}
+	if state != CircuitClosed {
+		openedAt := b.openedAt
+		h.OpenedAt = &openedAt
+	}
+	if !b.lastFailedAt.IsZero() {
+		lastFailedAt := b.lastFailedAt
+		h.LastFailedAt = &lastFailedAt
+	}
+	return h
+}
+
+// breakers holds one breaker per provider for the whole process, so every
+// client NewLLMClient returns shares what the others learned
+var breakers = struct {
+	sync.Mutex
+	m map[string]*Breaker
+}{m: make(map[string]*Breaker)}
+
+// breakerFor returns the breaker of client's provider
+func breakerFor(client Client) *Breaker {
+	name := providerName(client)
+	breakers.Lock()
+	defer breakers.Unlock()
+	b, ok := breakers.m[name]
+	if !ok {
+		b = NewBreaker(name, BreakerConfigFromEnv())
+		breakers.m[name] = b
+	}
+	return b
+}
+
+// ProvidersHealth returns the health of every provider in use, by name
+func ProvidersHealth() []ProviderHealth {
+	breakers.Lock()
+	list := make([]*Breaker, 0, len(breakers.m))
+	for _, b := range breakers.m {
+		list = append(list, b)
+	}
+	breakers.Unlock()
+
+	health := make([]ProviderHealth, len(list))
+	for i, b := range list {
+		health[i] = b.Health()
+	}
+	sort.Slice(health, func(i, j int) bool { return health[i].Provider < health[j].Provider })
+	return health
+}
+
+// countsAgainstProvider reports whether err says something about the
+// provider's health, rather than about the request or its caller
+func countsAgainstProvider(ctx context.Context, err error) bool {
+	if err == nil {
+		return true
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+	return !errors.Is(err, ErrContextLength) && !errors.Is(err, ErrNotSupported) && !errors.Is(err, ErrStructuredOutput)
+}
+
+// ordered returns the clients to try in turn: closed circuits first, then
+// those due a probe, each group in configured order. Providers whose circuit
+// is open are left out.
+func (f *FallbackClient) ordered() []Client {
+	var closed, probing []Client
+	for _, client := range f.clients {
+		switch breakerFor(client).State() {
+		case CircuitClosed:
+			closed = append(closed, client)
+		case CircuitHalfOpen:
+			probing = append(probing, client)
+		}
+	}
+	return append(closed, probing...)
+}
+
+// attempt calls one provider through its breaker
+func (f *FallbackClient) attempt(ctx context.Context, client Client, call func() error) error {
+	b := breakerFor(client)
+	if !b.Allow() {
+		return fmt.Errorf("%s: %w", providerName(client), ErrCircuitOpen)
+	}
+	start := time.Now()
+	err := call()
+	if countsAgainstProvider(ctx, err) {
+		b.Record(time.Since(start), err)
+	} else {
+		b.Release()
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreakerOpensAndProbes(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := NewBreaker("azure_openai", BreakerConfig{Window: 4, MinCalls: 4, ErrorRate: 0.5, SlowCall: time.Second, SlowRate: 0.75, OpenFor: 30 * time.Second})
+	b.now = func() time.Time { return now }
+
+	outage := errors.New("503 service unavailable")
+	b.Record(100*time.Millisecond, nil)
+	b.Record(100*time.Millisecond, outage)
+	b.Record(100*time.Millisecond, nil)
+	if b.State() != CircuitClosed {
+		t.Fatal("opened before MinCalls")
+	}
+	b.Record(100*time.Millisecond, outage)
+	if b.State() != CircuitOpen || b.Allow() {
+		t.Fatalf("state = %s after half the calls failed", b.State())
+	}
+
+	// After OpenFor a single probe goes through; a failed probe reopens
+	now = now.Add(30 * time.Second)
+	if !b.Allow() || b.Allow() {
+		t.Fatal("want exactly one probe when half-open")
+	}
+	b.Record(100*time.Millisecond, outage)
+	if b.State() != CircuitOpen {
+		t.Fatalf("state = %s after a failed probe", b.State())
+	}
+
+	now = now.Add(30 * time.Second)
+	if !b.Allow() {
+		t.Fatal("no probe after the second OpenFor")
+	}
+	b.Record(100*time.Millisecond, nil)
+	h := b.Health()
+	if h.State != CircuitClosed || h.ErrorRate != 0 || h.Failures != 3 || h.LastError != outage.Error() {
+		t.Errorf("health after a good probe = %+v", h)
+	}
+
+	// Slow calls open the circuit too
+	for i := 0; i < 3; i++ {
+		b.Record(2*time.Second, nil)
+	}
+	if b.Health().SlowRate != 0.75 || b.State() != CircuitOpen {
+		t.Errorf("health after slow calls = %+v", b.Health())
+	}
+}
+
+type failingClient struct{ calls int }
+
+func (f *failingClient) Complete(ctx context.Context, prompt string) (string, error) {
+	f.calls++
+	return "", errors.New("connection refused")
+}
+
+func (f *failingClient) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestFallbackSkipsOpenCircuits(t *testing.T) {
+	t.Setenv("QLP_LLM_BREAKER_MIN_CALLS", "2")
+	t.Setenv("QLP_LLM_BREAKER_OPEN_FOR", "1h")
+	failing := &failingClient{}
+	healthy := &scriptedClient{responses: []string{"ok"}}
+	client := NewFallbackClient(failing, healthy)
+
+	for i := 0; i < 5; i++ {
+		if got, err := client.Complete(context.Background(), "prompt"); err != nil || got != "ok" {
+			t.Fatalf("Complete = %q, %v", got, err)
+		}
+	}
+	if failing.calls != 2 {
+		t.Errorf("failing provider called %d times, want 2 before its circuit opened", failing.calls)
+	}
+
+	var found bool
+	for _, h := range ProvidersHealth() {
+		if h.Provider == providerName(failing) {
+			found = true
+			if h.State != CircuitOpen || h.LastError != "connection refused" {
+				t.Errorf("failing provider health = %+v", h)
+			}
+		}
+	}
+	if !found {
+		t.Error("failing provider missing from ProvidersHealth")
+	}
+}
@@ -0,0 +1,181 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"QLP/internal/config"
+)
+
+// defaultContextWindows are the context sizes assumed for each provider,
+// overridden with QLP_CONTEXT_WINDOW_<PROVIDER>, e.g. QLP_CONTEXT_WINDOW_OLLAMA
+var defaultContextWindows = map[string]int{
+	"azure_openai": 8192,
+	"ollama":       8192,
+}
+
+// maxSummaryRounds bounds how often Fit summarizes summaries
+const maxSummaryRounds = 3
+
+// EstimateTokens approximates the tokens of text at four characters each,
+// close enough for English and code with the GPT and Llama tokenizers
+func EstimateTokens(text string) int {
+	return len(text)/4 + 1
+}
+
+// ContextWindow returns the tokens a client accepts for prompt and
+// completion together, 0 when unlimited. A fallback chain is as small as
+// its smallest provider, since any of them may end up answering.
+func ContextWindow(client Client) int {
+	switch c := client.(type) {
+	case *FallbackClient:
+		window := 0
+		for _, inner := range c.clients {
+			if w := ContextWindow(inner); w > 0 && (window == 0 || w < window) {
+				window = w
+			}
+		}
+		return window
+	case *RecordingClient:
+		return ContextWindow(c.next)
+	case *OpenAICompatibleClient:
+		return providerWindow("openai_compatible", c.caps.ContextLength)
+	case *AzureOpenAIClient, *OllamaClient:
+		name := providerName(client)
+		return providerWindow(name, defaultContextWindows[name])
+	default:
+		return 0
+	}
+}
+
+func providerWindow(provider string, fallback int) int {
+	value := config.GetEnvOrDefault("QLP_CONTEXT_WINDOW_"+strings.ToUpper(provider), "")
+	if window, err := strconv.Atoi(value); err == nil && window >= 0 {
+		return window
+	}
+	return fallback
+}
+
+// Budgeter keeps prompts that embed large inputs, such as whole drops of
+// generated files, within the context window of a client's providers
+type Budgeter struct {
+	client Client
+	window int
+}
+
+// NewBudgeter budgets for the context window of client
+func NewBudgeter(client Client) *Budgeter {
+	return &Budgeter{client: client, window: ContextWindow(client)}
+}
+
+// Available returns the input tokens left once a prompt's own overhead and
+// the completion are accounted for, 0 when the window is unlimited
+func (b *Budgeter) Available(ctx context.Context, overhead int) int {
+	if b.window == 0 {
+		return 0
+	}
+	completion := ParametersFromContext(ctx).MaxTokens
+	if completion == 0 {
+		completion = azureMaxTokens
+	}
+	// Leave room for at least a small chunk, however tight the window
+	return max(b.window-overhead-completion, b.window/8)
+}
+
+// Split divides input into chunks that each fit a prompt with overhead
+// tokens of its own, in order and the same way every time. Files framed as
+// "=== path ===" and YAML documents are kept whole where they fit; larger
+// ones are split at line breaks.
+func (b *Budgeter) Split(ctx context.Context, input string, overhead int) []string {
+	available := b.Available(ctx, overhead)
+	if available == 0 || EstimateTokens(input) <= available {
+		return []string{input}
+	}
+
+	var chunks []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+	}
+	for _, section := range sections(input) {
+		if EstimateTokens(current.String()+section) <= available {
+			current.WriteString(section)
+			continue
+		}
+		flush()
+		if EstimateTokens(section) <= available {
+			current.WriteString(section)
+			continue
+		}
+		for _, line := range strings.SplitAfter(section, "\n") {
+			for EstimateTokens(line) > available {
+				cut := max((available-1)*4, 1)
+				flush()
+				chunks = append(chunks, line[:cut])
+				line = line[cut:]
+			}
+			if EstimateTokens(current.String()+line) > available {
+				flush()
+			}
+			current.WriteString(line)
+		}
+	}
+	flush()
+	return chunks
+}
+
+// sections splits input before every "=== path ===" file header and YAML
+// document separator
+func sections(input string) []string {
+	var result []string
+	start := 0
+	for i := 0; i < len(input); {
+		end := strings.IndexByte(input[i:], '\n')
+		if end < 0 {
+			end = len(input) - i
+		} else {
+			end++
+		}
+		line := strings.TrimRight(input[i:i+end], "\r\n")
+		if i > start && (strings.HasPrefix(line, "=== ") || line == "---") {
+			result = append(result, input[start:i])
+			start = i
+		}
+		i += end
+	}
+	return append(result, input[start:])
+}
+
+// Fit returns input unchanged when it fits a prompt with overhead tokens of
+// its own, and otherwise a summary of it: each chunk is summarized on its
+// own and the summaries joined in order, repeating while they do not fit.
+// Summaries still too large after maxSummaryRounds are cut to their first chunk.
+func (b *Budgeter) Fit(ctx context.Context, input string, overhead int) (string, error) {
+	for round := 0; ; round++ {
+		chunks := b.Split(ctx, input, overhead)
+		if len(chunks) == 1 || round == maxSummaryRounds {
+			return chunks[0], nil
+		}
+
+		summaries := make([]string, len(chunks))
+		for i, chunk := range chunks {
+			summary, err := b.client.Complete(ctx, fmt.Sprintf(summarizePrompt, i+1, len(chunks), chunk))
+			if err != nil {
+				return "", fmt.Errorf("failed to summarize part %d of %d: %w", i+1, len(chunks), err)
+			}
+			summaries[i] = strings.TrimSpace(summary)
+		}
+		input = strings.Join(summaries, "\n\n")
+	}
+}
+
+const summarizePrompt = `Summarize part %d of %d of a software project for a reviewer who cannot see the original.
+
+Keep every file path, public type, function signature, dependency, configuration value and anything relevant to security, error handling or deployment. Drop function bodies that add nothing beyond their signatures. Respond with the summary only.
+
+%s`
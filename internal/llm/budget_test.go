@@ -0,0 +1,74 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestContextWindowOfFallbackChain(t *testing.T) {
+	chain := NewFallbackClient(
+		NewOpenAICompatibleClient("http://vllm:8000/v1", "", "qwen", Capabilities{ContextLength: 32768}),
+		NewOllamaClient("http://localhost:11434", "llama3"),
+		NewMockClient(),
+	)
+	if w := ContextWindow(chain); w != 8192 {
+		t.Errorf("window = %d, want the Ollama default", w)
+	}
+	t.Setenv("QLP_CONTEXT_WINDOW_OLLAMA", "65536")
+	if w := ContextWindow(chain); w != 32768 {
+		t.Errorf("window = %d, want the OpenAI-compatible context length", w)
+	}
+	if w := ContextWindow(NewMockClient()); w != 0 {
+		t.Errorf("mock window = %d, want unlimited", w)
+	}
+}
+
+func TestBudgeterSplitKeepsFilesWhole(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 6; i++ {
+		fmt.Fprintf(&input, "=== file%d.go ===\n%s\n\n", i, strings.Repeat("x", 1500))
+	}
+	fmt.Fprintf(&input, "=== big.go ===\n%s", strings.Repeat("y\n", 3000))
+
+	b := &Budgeter{client: NewMockClient(), window: 3000}
+	ctx := WithParameters(context.Background(), Parameters{MaxTokens: 1000})
+	chunks := b.Split(ctx, input.String(), 1000)
+	if strings.Join(chunks, "") != input.String() {
+		t.Fatal("chunks do not reassemble the input")
+	}
+	for i, chunk := range chunks {
+		if EstimateTokens(chunk) > 1000 {
+			t.Errorf("chunk %d has %d tokens", i, EstimateTokens(chunk))
+		}
+		if i < 3 && (!strings.HasPrefix(chunk, "=== file") || strings.Count(chunk, "===") != 4) {
+			t.Errorf("chunk %d does not hold two whole files: %.40q", i, chunk)
+		}
+	}
+	if again := b.Split(ctx, input.String(), 1000); fmt.Sprint(again) != fmt.Sprint(chunks) {
+		t.Error("split is not deterministic")
+	}
+
+	if chunks := NewBudgeter(NewMockClient()).Split(ctx, input.String(), 1000); len(chunks) != 1 {
+		t.Errorf("unlimited window split into %d chunks", len(chunks))
+	}
+}
+
+func TestBudgeterFitSummarizesChunks(t *testing.T) {
+	summarizer := &scriptedClient{responses: []string{"summary"}}
+	b := &Budgeter{client: summarizer, window: 2000}
+	ctx := WithParameters(context.Background(), Parameters{MaxTokens: 500})
+
+	input := "=== a.go ===\n" + strings.Repeat("a", 3000) + "\n=== b.go ===\n" + strings.Repeat("b", 3000)
+	fitted, err := b.Fit(ctx, input, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fitted != "summary\n\nsummary" || summarizer.calls != 2 {
+		t.Errorf("fitted = %q after %d calls", fitted, summarizer.calls)
+	}
+	if fitted, _ := b.Fit(ctx, "small", 500); fitted != "small" {
+		t.Errorf("small input = %q", fitted)
+	}
+}
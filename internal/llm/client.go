@@ -12,7 +12,13 @@ import (
 	"strings"
 	"time"
 
+	"QLP/internal/audit"
+	"QLP/internal/metering"
+	"QLP/internal/metrics"
+	"QLP/internal/tracing"
+
 	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Client interface {
@@ -24,18 +30,36 @@ type FallbackClient struct {
 	clients []Client
 }
 
+// NewFallbackClient tries clients in order, each behind the circuit breaker
+// its provider shares across the process
 func NewFallbackClient(clients ...Client) *FallbackClient {
+	for _, client := range clients {
+		breakerFor(client)
+	}
 	return &FallbackClient{
 		clients: clients,
 	}
 }
 
+// Complete tries each provider in turn, skipping those whose circuit is open
 func (f *FallbackClient) Complete(ctx context.Context, prompt string) (string, error) {
-	var lastErr error
+	lastErr := fmt.Errorf("every provider: %w", ErrCircuitOpen)
 
-	for i, client := range f.clients {
+	for i, client := range f.ordered() {
 		log.Printf("Trying LLM client %d", i+1)
-		response, err := client.Complete(ctx, prompt)
+		start := time.Now()
+		spanCtx, span := tracing.StartSpan(ctx, "llm.complete",
+			attribute.String("llm.provider", providerName(client)),
+			attribute.Int("llm.prompt_chars", len(prompt)))
+		spanCtx, call := startCall(spanCtx, client)
+		var response string
+		err := f.attempt(spanCtx, client, func() (err error) {
+			response, err = client.Complete(spanCtx, prompt)
+			return err
+		})
+		endCall(spanCtx, call, err)
+		tracing.EndSpan(span, err)
+		metrics.ObserveLLMRequest(providerName(client), time.Since(start), err)
 		if err == nil {
 			log.Printf("Successfully used LLM client %d", i+1)
 			return response, nil
@@ -48,6 +72,24 @@ func (f *FallbackClient) Complete(ctx context.Context, prompt string) (string, e
 	return "", fmt.Errorf("all LLM clients failed, last error: %w", lastErr)
 }
 
+// providerName returns the metrics label for a client
+func providerName(client Client) string {
+	switch client.(type) {
+	case *AzureOpenAIClient:
+		return "azure_openai"
+	case *OllamaClient:
+		return "ollama"
+	case *OpenAICompatibleClient:
+		return "openai_compatible"
+	case *MockClient:
+		return "mock"
+	case *ReplayClient:
+		return "replay"
+	default:
+		return fmt.Sprintf("%T", client)
+	}
+}
+
 func (f *FallbackClient) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
 	var lastErr error
 
@@ -62,6 +104,12 @@ func (f *FallbackClient) GenerateEmbedding(ctx context.Context, text string) ([]
 	return nil, fmt.Errorf("all embedding clients failed, last error: %w", lastErr)
 }
 
+// Completion parameters sent to Azure OpenAI
+const (
+	azureMaxTokens   = 2000
+	azureTemperature = 0.1
+)
+
 type AzureOpenAIClient struct {
 	client   *openai.Client
 	model    string
@@ -83,21 +131,32 @@ func NewAzureOpenAIClient(apiKey, endpoint, model string) *AzureOpenAIClient {
 	}
 }
 
+// recordTokens counts a completion's tokens in the provider metrics and
+// towards the usage of the tenant in ctx
+func recordTokens(ctx context.Context, provider string, promptTokens, completionTokens int) {
+	metrics.AddLLMTokens(provider, promptTokens, completionTokens)
+	observeTokens(ctx, promptTokens, completionTokens)
+	metering.Record(ctx, audit.TenantFromContext(ctx), metering.LLMTokens, float64(promptTokens+completionTokens))
+}
+
 func (a *AzureOpenAIClient) Complete(ctx context.Context, prompt string) (string, error) {
+	maxTokens, temperature, topP := azureParameters(ctx)
 	req := openai.ChatCompletionRequest{
 		Model: a.model,
 		Messages: []openai.ChatCompletionMessage{
 			{
 				Role:    openai.ChatMessageRoleSystem,
-				Content: "You are an expert task decomposition agent. Always respond with valid JSON arrays of tasks.",
+				Content: systemPrompt,
 			},
 			{
 				Role:    openai.ChatMessageRoleUser,
 				Content: prompt,
 			},
 		},
-		MaxTokens:   2000,
-		Temperature: 0.1,
+		MaxTokens:   maxTokens,
+		Temperature: temperature,
+		TopP:        topP,
+		Seed:        ParametersFromContext(ctx).Seed,
 	}
 
 	resp, err := a.client.CreateChatCompletion(ctx, req)
@@ -105,6 +164,9 @@ func (a *AzureOpenAIClient) Complete(ctx context.Context, prompt string) (string
 		return "", fmt.Errorf("Azure OpenAI completion failed: %w", err)
 	}
 
+	recordTokens(ctx, "azure_openai", resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	observeResponse(ctx, resp.Model, resp.SystemFingerprint)
+
 	if len(resp.Choices) == 0 {
 		return "", fmt.Errorf("no completion choices returned")
 	}
@@ -154,21 +216,25 @@ func NewOllamaClient(baseURL, model string) *OllamaClient {
 }
 
 type OllamaRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	Stream bool   `json:"stream"`
+	Model   string                 `json:"model"`
+	Prompt  string                 `json:"prompt"`
+	Stream  bool                   `json:"stream"`
+	Options map[string]interface{} `json:"options,omitempty"`
 }
 
 type OllamaResponse struct {
-	Response string `json:"response"`
-	Done     bool   `json:"done"`
+	Response        string `json:"response"`
+	Done            bool   `json:"done"`
+	PromptEvalCount int    `json:"prompt_eval_count,omitempty"`
+	EvalCount       int    `json:"eval_count,omitempty"`
 }
 
 func (o *OllamaClient) Complete(ctx context.Context, prompt string) (string, error) {
 	reqBody := OllamaRequest{
-		Model:  o.model,
-		Prompt: fmt.Sprintf("You are an expert task decomposition agent. Always respond with valid JSON arrays of tasks.\n\n%s", prompt),
-		Stream: false,
+		Model:   o.model,
+		Prompt:  fmt.Sprintf("%s\n\n%s", systemPrompt, prompt),
+		Stream:  false,
+		Options: ollamaOptions(ctx),
 	}
 
 	jsonData, err := json.Marshal(reqBody)
@@ -204,6 +270,8 @@ func (o *OllamaClient) Complete(ctx context.Context, prompt string) (string, err
 		return "", fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
+	recordTokens(ctx, "ollama", ollamaResp.PromptEvalCount, ollamaResp.EvalCount)
+
 	return strings.TrimSpace(ollamaResp.Response), nil
 }
 
@@ -297,7 +365,15 @@ func sqrt(x float32) float32 {
 	return guess
 }
 
+// NewLLMClient returns the configured providers with fallback. With
+// QLP_LLM_MODE=record their interactions are saved to QLP_LLM_CASSETTE; with
+// QLP_LLM_MODE=replay the cassette answers instead, so no API keys are needed.
 func NewLLMClient() Client {
+	mode, cassette := recordingMode()
+	if mode == ModeReplay {
+		return NewReplayClient(cassette)
+	}
+
 	var clients []Client
 
 	// Try Azure OpenAI first (requires environment variables)
@@ -312,6 +388,14 @@ func NewLLMClient() Client {
 		clients = append(clients, azureClient)
 	}
 
+	// Then a self-hosted OpenAI-compatible server such as vLLM or llama.cpp
+	compatClient, err := NewOpenAICompatibleClientFromEnv()
+	if err != nil {
+		log.Printf("Skipping OpenAI-compatible provider: %v", err)
+	} else if compatClient != nil {
+		clients = append(clients, compatClient)
+	}
+
 	// Fallback to Ollama (if configured)
 	ollamaURL := os.Getenv("OLLAMA_BASE_URL")
 	ollamaModel := os.Getenv("OLLAMA_MODEL")
@@ -329,5 +413,8 @@ func NewLLMClient() Client {
 	mockClient := NewMockClient()
 	clients = append(clients, mockClient)
 
+	if mode == ModeRecord {
+		return NewRecordingClient(NewFallbackClient(clients...), cassette)
+	}
 	return NewFallbackClient(clients...)
 }
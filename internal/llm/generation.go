@@ -0,0 +1,108 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// seededProviders accept a sampling seed; the mock and replayed answers are
+// deterministic without one
+var seededProviders = map[string]bool{
+	"azure_openai":      true,
+	"openai_compatible": true,
+	"ollama":            true,
+	"mock":              true,
+	"replay":            true,
+}
+
+// ModelUse is one provider, model and parameter set that answered completions
+type ModelUse struct {
+	Provider     string                 `json:"provider"`
+	Model        string                 `json:"model,omitempty"`
+	ModelVersion string                 `json:"model_version,omitempty"`
+	Fingerprint  string                 `json:"system_fingerprint,omitempty"`
+	Parameters   map[string]interface{} `json:"parameters,omitempty"`
+	Calls        int                    `json:"calls"`
+}
+
+// Generation records what produced an intent's outputs, so a re-run can be
+// made under the same conditions and compared
+type Generation struct {
+	Seed   *int       `json:"seed,omitempty"`
+	Models []ModelUse `json:"models"`
+	// Seeded is true when a seed was set and every answering provider
+	// accepts one, the precondition for reproducing the outputs
+	Seeded bool `json:"seeded"`
+}
+
+// GenerationLog collects the successful completions made with a context
+type GenerationLog struct {
+	mu   sync.Mutex
+	seed *int
+	uses map[string]*ModelUse
+}
+
+type generationKey struct{}
+
+// WithGenerationLog returns a context whose successful completions are
+// recorded in the returned log, along with the seed ctx carries
+func WithGenerationLog(ctx context.Context) (context.Context, *GenerationLog) {
+	log := &GenerationLog{seed: ParametersFromContext(ctx).Seed, uses: make(map[string]*ModelUse)}
+	ctx = context.WithValue(ctx, generationKey{}, log)
+	return WithObserver(ctx, log.observe), log
+}
+
+// GenerationFromContext returns what the generation log of ctx has
+// recorded so far, nil without one
+func GenerationFromContext(ctx context.Context) *Generation {
+	if log, ok := ctx.Value(generationKey{}).(*GenerationLog); ok {
+		return log.Generation()
+	}
+	return nil
+}
+
+func (l *GenerationLog) observe(call Call) {
+	if call.Error != "" {
+		return
+	}
+	params, _ := json.Marshal(call.Parameters)
+	key := call.Provider + "\x00" + call.Model + "\x00" + call.ModelVersion + "\x00" + call.Fingerprint + "\x00" + string(params)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	use, ok := l.uses[key]
+	if !ok {
+		use = &ModelUse{
+			Provider:     call.Provider,
+			Model:        call.Model,
+			ModelVersion: call.ModelVersion,
+			Fingerprint:  call.Fingerprint,
+			Parameters:   call.Parameters,
+		}
+		l.uses[key] = use
+	}
+	use.Calls++
+}
+
+// Generation returns what the log recorded, in a stable order
+func (l *GenerationLog) Generation() *Generation {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	keys := make([]string, 0, len(l.uses))
+	for key := range l.uses {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	g := &Generation{Seed: l.seed, Models: make([]ModelUse, 0, len(keys)), Seeded: l.seed != nil}
+	for _, key := range keys {
+		use := *l.uses[key]
+		g.Models = append(g.Models, use)
+		if !seededProviders[use.Provider] {
+			g.Seeded = false
+		}
+	}
+	return g
+}
@@ -0,0 +1,48 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSeedIsSentAndRecorded(t *testing.T) {
+	var seeds []interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&req)
+		seeds = append(seeds, req["seed"])
+		w.Write([]byte(`{"model":"qwen2.5-coder-32b-0.3","system_fingerprint":"fp_local","choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer server.Close()
+	client := NewFallbackClient(NewOpenAICompatibleClient(server.URL, "", "qwen", Capabilities{}))
+
+	seed := 42
+	ctx, log := WithGenerationLog(WithParameters(context.Background(), Parameters{Seed: &seed}))
+	for _, temperature := range []float64{0.2, 0.2, 0.7} {
+		if _, err := client.Complete(WithParameters(ctx, Parameters{Temperature: &temperature}), "prompt"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(seeds) != 3 || seeds[0] != 42.0 {
+		t.Fatalf("seeds sent = %v", seeds)
+	}
+
+	g := GenerationFromContext(ctx)
+	if len(log.Generation().Models) != len(g.Models) || g.Seed == nil || *g.Seed != 42 || !g.Seeded {
+		t.Fatalf("generation = %+v", g)
+	}
+	if len(g.Models) != 2 || g.Models[0].Calls+g.Models[1].Calls != 3 {
+		t.Fatalf("models = %+v, want one entry per temperature", g.Models)
+	}
+	use := g.Models[0]
+	if use.Provider != "openai_compatible" || use.ModelVersion != "qwen2.5-coder-32b-0.3" || use.Fingerprint != "fp_local" || use.Parameters["seed"] != 42 {
+		t.Errorf("model use = %+v", use)
+	}
+
+	if GenerationFromContext(context.Background()) != nil {
+		t.Error("generation without a log")
+	}
+}
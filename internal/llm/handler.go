@@ -0,0 +1,18 @@
+package llm
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Routes returns the provider health API:
+//
+//	GET /api/v1/providers  list the LLM providers with their circuit state and recent error rate and latency
+func Routes() map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /api/v1/providers": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"providers": ProvidersHealth()})
+		}),
+	}
+}
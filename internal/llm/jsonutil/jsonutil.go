@@ -0,0 +1,255 @@
+// Package jsonutil extracts, repairs and validates JSON returned by LLMs.
+package jsonutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoJSON is returned when a response contains no JSON object or array
+var ErrNoJSON = errors.New("no JSON found in response")
+
+// ErrInvalid is returned when a response still fails to decode after all retries
+var ErrInvalid = errors.New("LLM response is not valid JSON for the expected schema")
+
+// Completer is the subset of an LLM client needed for corrective retries
+type Completer interface {
+	Complete(ctx context.Context, prompt string) (string, error)
+}
+
+// Extract returns the first complete JSON object or array in raw, looking
+// inside markdown code fences first
+func Extract(raw string) (string, error) {
+	s := strings.TrimSpace(raw)
+	if fenced, ok := fencedBlock(s); ok {
+		s = fenced
+	}
+
+	start := strings.IndexAny(s, "{[")
+	if start == -1 {
+		return "", ErrNoJSON
+	}
+
+	// Scan for the matching close bracket, ignoring brackets inside strings
+	depth := 0
+	inString := false
+	escaped := false
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			if escaped {
+				escaped = false
+			} else if c == '\\' {
+				escaped = true
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return s[start : i+1], nil
+			}
+		}
+	}
+
+	// Unbalanced: return the remainder and let repair or decoding report the problem
+	return s[start:], nil
+}
+
+func fencedBlock(s string) (string, bool) {
+	open := strings.Index(s, "```")
+	if open == -1 {
+		return "", false
+	}
+	body := s[open+3:]
+	// Skip the language tag on the fence line
+	if nl := strings.IndexByte(body, '\n'); nl != -1 && !strings.ContainsAny(body[:nl], "{[") {
+		body = body[nl+1:]
+	}
+	if end := strings.Index(body, "```"); end != -1 {
+		body = body[:end]
+	}
+	return strings.TrimSpace(body), true
+}
+
+// Repair fixes common LLM deviations from strict JSON: trailing commas,
+// unquoted or single-quoted keys, single-quoted strings, smart quotes and
+// unclosed brackets
+func Repair(s string) string {
+	s = strings.NewReplacer("“", `"`, "”", `"`, "‘", "'", "’", "'").Replace(s)
+
+	var b strings.Builder
+	var stack []byte
+	inString := false
+	quote := byte('"')
+	escaped := false
+	last := byte(0) // last significant byte written outside strings
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+				b.WriteByte(c)
+			case c == '\\':
+				escaped = true
+				b.WriteByte(c)
+			case c == quote:
+				inString = false
+				b.WriteByte('"')
+			case c == '"' && quote == '\'':
+				b.WriteString(`\"`)
+			default:
+				b.WriteByte(c)
+			}
+			continue
+		}
+
+		switch {
+		case c == '"' || c == '\'':
+			inString = true
+			quote = c
+			b.WriteByte('"')
+		case c == '{' || c == '[':
+			stack = append(stack, c)
+			b.WriteByte(c)
+		case c == '}' || c == ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			b.WriteByte(c)
+		case c == ',':
+			// Drop commas directly followed by a closing bracket
+			j := skipSpace(s, i+1)
+			if j < len(s) && (s[j] == '}' || s[j] == ']') {
+				continue
+			}
+			b.WriteByte(c)
+		case isIdentStart(c) && (last == '{' || last == ','):
+			// Quote bare object keys
+			j := i
+			for j < len(s) && isIdentPart(s[j]) {
+				j++
+			}
+			if k := skipSpace(s, j); k < len(s) && s[k] == ':' {
+				b.WriteByte('"')
+				b.WriteString(s[i:j])
+				b.WriteByte('"')
+				i = j - 1
+				last = '"'
+				continue
+			}
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+		if strings.IndexByte(" \t\r\n", c) == -1 {
+			last = c
+		}
+	}
+
+	if inString {
+		b.WriteByte('"')
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i] == '{' {
+			b.WriteByte('}')
+		} else {
+			b.WriteByte(']')
+		}
+	}
+	return b.String()
+}
+
+func skipSpace(s string, i int) int {
+	for i < len(s) && strings.IndexByte(" \t\r\n", s[i]) != -1 {
+		i++
+	}
+	return i
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || c == '-' || (c >= '0' && c <= '9')
+}
+
+// Decode extracts and repairs JSON from raw, validates it against schema
+// (which may be nil) and unmarshals it into out
+func Decode(raw string, schema map[string]interface{}, out interface{}) error {
+	extracted, err := Extract(raw)
+	if err != nil {
+		return err
+	}
+
+	data := []byte(extracted)
+	if !json.Valid(data) {
+		data = []byte(Repair(extracted))
+	}
+
+	if schema != nil {
+		if err := Validate(data, schema); err != nil {
+			return err
+		}
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return nil
+}
+
+// CompleteFunc performs one completion; it lets callers route retries through
+// provider-specific structured output modes
+type CompleteFunc func(ctx context.Context, prompt string) (string, error)
+
+// Retry calls complete and decodes the response, re-prompting with the
+// validation error on failure. Completion errors are returned immediately;
+// decode failures end in an ErrInvalid-wrapped error after maxAttempts.
+func Retry(ctx context.Context, complete CompleteFunc, prompt string, schema map[string]interface{}, out interface{}, maxAttempts int) error {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	currentPrompt := prompt
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		raw, err := complete(ctx, currentPrompt)
+		if err != nil {
+			return err
+		}
+		if lastErr = Decode(raw, schema, out); lastErr == nil {
+			return nil
+		}
+		currentPrompt = CorrectivePrompt(prompt, raw, lastErr)
+	}
+
+	return fmt.Errorf("%w after %d attempts: %v", ErrInvalid, maxAttempts, lastErr)
+}
+
+// CompleteAndDecode is Retry for a plain Completer
+func CompleteAndDecode(ctx context.Context, c Completer, prompt string, schema map[string]interface{}, out interface{}, maxAttempts int) error {
+	return Retry(ctx, c.Complete, prompt, schema, out, maxAttempts)
+}
+
+// CorrectivePrompt asks the model to fix a response that failed to decode
+func CorrectivePrompt(prompt, previous string, cause error) string {
+	if len(previous) > 2000 {
+		previous = previous[:2000] + "..."
+	}
+	return fmt.Sprintf("%s\n\nYour previous response could not be used: %v\nPrevious response:\n%s\n\nRespond again with corrected JSON only, no prose or markdown.",
+		prompt, cause, previous)
+}
@@ -0,0 +1,95 @@
+package jsonutil
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeRepairsCommonDeviations(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"fenced", "Here you go:\n```json\n{\"score\": 80, \"tags\": [\"a\"]}\n```\nThanks"},
+		{"trailing commas", `{"score": 80, "tags": ["a",],}`},
+		{"unquoted keys", `{score: 80, tags: ["a"]}`},
+		{"single quotes", `{'score': 80, 'tags': ['a']}`},
+		{"truncated", `{"score": 80, "tags": ["a"`},
+		{"prose with braces in strings", `Result: {"score": 80, "tags": ["a}"]} done`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out struct {
+				Score int      `json:"score"`
+				Tags  []string `json:"tags"`
+			}
+			if err := Decode(tt.raw, nil, &out); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if out.Score != 80 || len(out.Tags) != 1 {
+				t.Errorf("decoded %+v", out)
+			}
+		})
+	}
+}
+
+func TestValidateReportsSchemaViolations(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":     "object",
+		"required": []string{"action"},
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{"type": "string", "enum": []string{"approve", "reject"}},
+			"score":  map[string]interface{}{"type": "integer"},
+		},
+	}
+
+	if err := Validate([]byte(`{"action": "approve", "score": 3}`), schema); err != nil {
+		t.Errorf("valid document rejected: %v", err)
+	}
+	for _, doc := range []string{`{"score": 3}`, `{"action": "maybe"}`, `{"action": "approve", "score": 2.5}`, `[]`} {
+		if err := Validate([]byte(doc), schema); err == nil {
+			t.Errorf("expected %s to fail validation", doc)
+		}
+	}
+}
+
+type scriptedCompleter struct {
+	responses []string
+	prompts   []string
+}
+
+func (s *scriptedCompleter) Complete(_ context.Context, prompt string) (string, error) {
+	s.prompts = append(s.prompts, prompt)
+	response := s.responses[0]
+	if len(s.responses) > 1 {
+		s.responses = s.responses[1:]
+	}
+	return response, nil
+}
+
+func TestCompleteAndDecodeRetriesWithCorrectivePrompt(t *testing.T) {
+	schema := map[string]interface{}{"type": "object", "required": []string{"ok"}}
+	c := &scriptedCompleter{responses: []string{"not json", `{"ok": true}`}}
+
+	var out struct {
+		OK bool `json:"ok"`
+	}
+	if err := CompleteAndDecode(context.Background(), c, "check", schema, &out, 3); err != nil {
+		t.Fatalf("CompleteAndDecode: %v", err)
+	}
+	if !out.OK || len(c.prompts) != 2 {
+		t.Fatalf("out=%+v prompts=%d", out, len(c.prompts))
+	}
+	if !strings.Contains(c.prompts[1], "not json") {
+		t.Error("corrective prompt should include the previous response")
+	}
+
+	c = &scriptedCompleter{responses: []string{"still not json"}}
+	err := CompleteAndDecode(context.Background(), c, "check", schema, &out, 2)
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid, got %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package jsonutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Validate checks data against a JSON Schema subset: type, properties,
+// required, items and enum
+func Validate(data []byte, schema map[string]interface{}) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return validateValue("$", v, schema)
+}
+
+func validateValue(path string, v interface{}, schema map[string]interface{}) error {
+	if t, ok := schema["type"].(string); ok {
+		if !matchesType(v, t) {
+			return fmt.Errorf("%s: expected %s, got %s", path, t, typeName(v))
+		}
+	}
+
+	if enum := toSlice(schema["enum"]); enum != nil {
+		found := false
+		for _, allowed := range enum {
+			if fmt.Sprint(allowed) == fmt.Sprint(v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value %v is not one of %v", path, v, enum)
+		}
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for _, name := range toStrings(schema["required"]) {
+			if _, ok := val[name]; !ok {
+				return fmt.Errorf("%s: missing required field %q", path, name)
+			}
+		}
+		if props, ok := schema["properties"].(map[string]interface{}); ok {
+			names := make([]string, 0, len(props))
+			for name := range props {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				propSchema, ok := props[name].(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if fieldValue, present := val[name]; present && fieldValue != nil {
+					if err := validateValue(path+"."+name, fieldValue, propSchema); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range val {
+				if err := validateValue(fmt.Sprintf("%s[%d]", path, i), item, items); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func matchesType(v interface{}, t string) bool {
+	switch t {
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "null":
+		return v == nil
+	default:
+		return true
+	}
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// toSlice accepts both []interface{} (decoded schemas) and []string (schemas built in Go)
+func toSlice(v interface{}) []interface{} {
+	switch s := v.(type) {
+	case []interface{}:
+		return s
+	case []string:
+		out := make([]interface{}, len(s))
+		for i := range s {
+			out[i] = s[i]
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+func toStrings(v interface{}) []string {
+	var out []string
+	for _, item := range toSlice(v) {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
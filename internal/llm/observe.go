@@ -0,0 +1,126 @@
+package llm
+
+import (
+	"context"
+	"time"
+)
+
+// systemPrompt is the instruction the chat providers send ahead of every prompt
+const systemPrompt = "You are an expert task decomposition agent. Always respond with valid JSON arrays of tasks."
+
+// Call describes one provider attempt of a completion, as the provider saw it
+type Call struct {
+	Provider         string                 `json:"provider"`
+	Model            string                 `json:"model,omitempty"`
+	ModelVersion     string                 `json:"model_version,omitempty"`      // The model the provider reports answering
+	Fingerprint      string                 `json:"system_fingerprint,omitempty"` // The provider's backend configuration
+	System           string                 `json:"system,omitempty"`
+	Parameters       map[string]interface{} `json:"parameters,omitempty"`
+	StartedAt        time.Time              `json:"started_at"`
+	Duration         time.Duration          `json:"duration_ns"`
+	PromptTokens     int                    `json:"prompt_tokens,omitempty"`
+	CompletionTokens int                    `json:"completion_tokens,omitempty"`
+	Error            string                 `json:"error,omitempty"`
+}
+
+// Observer receives every provider attempt made with a context
+type Observer func(Call)
+
+type observerKey struct{}
+
+type callKey struct{}
+
+// WithObserver returns a context whose completions report each provider
+// attempt, including the failed ones a fallback recovered from. Observers
+// already set on ctx keep receiving the attempts too.
+func WithObserver(ctx context.Context, fn Observer) context.Context {
+	if parent, ok := ctx.Value(observerKey{}).(Observer); ok {
+		next := fn
+		fn = func(call Call) {
+			next(call)
+			parent(call)
+		}
+	}
+	return context.WithValue(ctx, observerKey{}, fn)
+}
+
+// startCall begins observing an attempt of client; the returned context
+// collects the token counts the provider reports
+func startCall(ctx context.Context, client Client) (context.Context, *Call) {
+	if _, ok := ctx.Value(observerKey{}).(Observer); !ok {
+		return ctx, nil
+	}
+	call := &Call{Provider: providerName(client), StartedAt: time.Now()}
+	call.Model, call.System, call.Parameters = describe(ctx, client)
+	return context.WithValue(ctx, callKey{}, call), call
+}
+
+// endCall reports a finished attempt to the observer of ctx
+func endCall(ctx context.Context, call *Call, err error) {
+	if call == nil {
+		return
+	}
+	call.Duration = time.Since(call.StartedAt)
+	if err != nil {
+		call.Error = err.Error()
+	}
+	ctx.Value(observerKey{}).(Observer)(*call)
+}
+
+// observeTokens adds a provider's token counts to the attempt in ctx
+func observeTokens(ctx context.Context, promptTokens, completionTokens int) {
+	if call, ok := ctx.Value(callKey{}).(*Call); ok {
+		call.PromptTokens += promptTokens
+		call.CompletionTokens += completionTokens
+	}
+}
+
+// observeResponse adds the model version and backend fingerprint a provider
+// reported to the attempt in ctx
+func observeResponse(ctx context.Context, modelVersion, fingerprint string) {
+	if call, ok := ctx.Value(callKey{}).(*Call); ok {
+		call.ModelVersion = modelVersion
+		call.Fingerprint = fingerprint
+	}
+}
+
+// describe returns the model, system instruction and request parameters a
+// client sends with a prompt in ctx
+func describe(ctx context.Context, client Client) (string, string, map[string]interface{}) {
+	switch c := client.(type) {
+	case *AzureOpenAIClient:
+		maxTokens, temperature, topP := azureParameters(ctx)
+		params := map[string]interface{}{"max_tokens": maxTokens, "temperature": temperature}
+		if topP > 0 {
+			params["top_p"] = topP
+		}
+		if seed := ParametersFromContext(ctx).Seed; seed != nil {
+			params["seed"] = *seed
+		}
+		return c.model, systemPrompt, params
+	case *OpenAICompatibleClient:
+		maxTokens, temperature, topP := chatParameters(ParametersFromContext(ctx), 0, 0)
+		params := map[string]interface{}{"base_url": c.baseURL}
+		if maxTokens > 0 {
+			params["max_tokens"] = maxTokens
+		}
+		if temperature > 0 {
+			params["temperature"] = temperature
+		}
+		if topP > 0 {
+			params["top_p"] = topP
+		}
+		if seed := ParametersFromContext(ctx).Seed; seed != nil {
+			params["seed"] = *seed
+		}
+		return c.model, systemPrompt, params
+	case *OllamaClient:
+		params := map[string]interface{}{"stream": false}
+		for key, value := range ollamaOptions(ctx) {
+			params[key] = value
+		}
+		return c.model, systemPrompt, params
+	default:
+		return "", "", nil
+	}
+}
@@ -0,0 +1,230 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"QLP/internal/config"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ErrNotSupported is returned for operations a provider's capabilities
+// exclude; the fallback client moves on to the next provider
+var ErrNotSupported = errors.New("not supported by this provider")
+
+// ErrContextLength is returned when a prompt does not fit a model's context
+var ErrContextLength = errors.New("prompt exceeds the model context length")
+
+// Capabilities describe what an OpenAI-compatible server and model support
+type Capabilities struct {
+	ContextLength  int    // Tokens of prompt and completion together, 0 when unknown
+	Embeddings     bool   // Serves /embeddings
+	EmbeddingModel string // Model for embeddings, the chat model when empty
+	Tools          bool   // Supports function calling for structured output
+}
+
+// OpenAICompatibleClient talks to any server implementing the OpenAI chat
+// completions API, such as vLLM, llama.cpp's server, LM Studio or LocalAI,
+// so air-gapped installations can use models they host themselves
+type OpenAICompatibleClient struct {
+	client     *openai.Client
+	httpClient *http.Client
+	baseURL    string
+	apiKey     string
+	model      string
+	caps       Capabilities
+}
+
+// NewOpenAICompatibleClient connects to baseURL, e.g. http://vllm:8000/v1;
+// apiKey may be empty for servers without authentication
+func NewOpenAICompatibleClient(baseURL, apiKey, model string, caps Capabilities) *OpenAICompatibleClient {
+	cfg := openai.DefaultConfig(apiKey)
+	cfg.BaseURL = strings.TrimSuffix(baseURL, "/")
+	cfg.HTTPClient = &http.Client{Timeout: 5 * time.Minute}
+	return &OpenAICompatibleClient{
+		client:     openai.NewClientWithConfig(cfg),
+		httpClient: cfg.HTTPClient,
+		baseURL:    cfg.BaseURL,
+		apiKey:     apiKey,
+		model:      model,
+		caps:       caps,
+	}
+}
+
+// NewOpenAICompatibleClientFromEnv configures the client from
+// QLP_OPENAI_COMPAT_BASE_URL and QLP_OPENAI_COMPAT_MODEL, returning nil when
+// they are unset. The capability flags are QLP_OPENAI_COMPAT_CONTEXT_LENGTH,
+// QLP_OPENAI_COMPAT_EMBEDDINGS, QLP_OPENAI_COMPAT_EMBEDDING_MODEL and
+// QLP_OPENAI_COMPAT_TOOLS.
+func NewOpenAICompatibleClientFromEnv() (*OpenAICompatibleClient, error) {
+	baseURL := config.GetEnvOrDefault("QLP_OPENAI_COMPAT_BASE_URL", "")
+	if baseURL == "" {
+		return nil, nil
+	}
+	model := config.GetEnvOrDefault("QLP_OPENAI_COMPAT_MODEL", "")
+	if model == "" {
+		return nil, fmt.Errorf("QLP_OPENAI_COMPAT_MODEL is required with QLP_OPENAI_COMPAT_BASE_URL")
+	}
+	contextLength, err := strconv.Atoi(config.GetEnvOrDefault("QLP_OPENAI_COMPAT_CONTEXT_LENGTH", "8192"))
+	if err != nil || contextLength < 0 {
+		return nil, fmt.Errorf("invalid QLP_OPENAI_COMPAT_CONTEXT_LENGTH %q", config.GetEnvOrDefault("QLP_OPENAI_COMPAT_CONTEXT_LENGTH", ""))
+	}
+	caps := Capabilities{
+		ContextLength:  contextLength,
+		Embeddings:     config.GetEnvOrDefault("QLP_OPENAI_COMPAT_EMBEDDINGS", "false") == "true",
+		EmbeddingModel: config.GetEnvOrDefault("QLP_OPENAI_COMPAT_EMBEDDING_MODEL", ""),
+		Tools:          config.GetEnvOrDefault("QLP_OPENAI_COMPAT_TOOLS", "false") == "true",
+	}
+	return NewOpenAICompatibleClient(baseURL, config.GetEnvOrDefault("QLP_OPENAI_COMPAT_API_KEY", ""), model, caps), nil
+}
+
+// request builds a chat request, shrinking max tokens to what the context
+// leaves after the prompt
+func (c *OpenAICompatibleClient) request(ctx context.Context, messages []openai.ChatCompletionMessage) (openai.ChatCompletionRequest, error) {
+	p := ParametersFromContext(ctx)
+	maxTokens, temperature, topP := chatParameters(p, 0, 0)
+	if c.caps.ContextLength > 0 {
+		promptTokens := 0
+		for _, m := range messages {
+			promptTokens += EstimateTokens(m.Content)
+		}
+		available := c.caps.ContextLength - promptTokens
+		if available <= 0 {
+			return openai.ChatCompletionRequest{}, fmt.Errorf("%w: about %d tokens for %d", ErrContextLength, promptTokens, c.caps.ContextLength)
+		}
+		if maxTokens == 0 || maxTokens > available {
+			maxTokens = available
+		}
+	}
+	return openai.ChatCompletionRequest{
+		Model:       c.model,
+		Messages:    messages,
+		MaxTokens:   maxTokens,
+		Temperature: temperature,
+		TopP:        topP,
+		Seed:        p.Seed,
+	}, nil
+}
+
+func (c *OpenAICompatibleClient) Complete(ctx context.Context, prompt string) (string, error) {
+	req, err := c.request(ctx, []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
+		{Role: openai.ChatMessageRoleUser, Content: prompt},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("OpenAI-compatible completion failed: %w", err)
+	}
+	recordTokens(ctx, "openai_compatible", resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	observeResponse(ctx, resp.Model, resp.SystemFingerprint)
+
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no completion choices returned")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// CompleteStructured uses function calling when the server supports it and
+// the schema embedded in the prompt otherwise
+func (c *OpenAICompatibleClient) CompleteStructured(ctx context.Context, prompt string, schema Schema) (string, error) {
+	if !c.caps.Tools {
+		return c.Complete(ctx, withSchemaInstructions(prompt, schema))
+	}
+	name := schema.Name
+	if name == "" {
+		name = "respond"
+	}
+	req, err := c.request(ctx, []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: prompt},
+	})
+	if err != nil {
+		return "", err
+	}
+	req.Tools = []openai.Tool{{
+		Type: openai.ToolTypeFunction,
+		Function: openai.FunctionDefinition{
+			Name:        name,
+			Description: schema.Description,
+			Parameters:  schema.Definition,
+		},
+	}}
+	req.ToolChoice = openai.ToolChoice{
+		Type:     openai.ToolTypeFunction,
+		Function: openai.ToolFunction{Name: name},
+	}
+
+	resp, err := c.client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("OpenAI-compatible structured completion failed: %w", err)
+	}
+	recordTokens(ctx, "openai_compatible", resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	observeResponse(ctx, resp.Model, resp.SystemFingerprint)
+
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no completion choices returned")
+	}
+	message := resp.Choices[0].Message
+	if len(message.ToolCalls) > 0 {
+		return message.ToolCalls[0].Function.Arguments, nil
+	}
+	return message.Content, nil
+}
+
+// GenerateEmbedding calls /embeddings directly, as the OpenAI client only
+// accepts OpenAI's own embedding model names
+func (c *OpenAICompatibleClient) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	if !c.caps.Embeddings {
+		return nil, fmt.Errorf("embeddings: %w", ErrNotSupported)
+	}
+	model := c.caps.EmbeddingModel
+	if model == "" {
+		model = c.model
+	}
+
+	jsonData, err := json.Marshal(map[string]interface{}{"model": model, "input": []string{text}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/embeddings", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("OpenAI-compatible embedding failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OpenAI-compatible embedding failed with status %d", resp.StatusCode)
+	}
+
+	var embeddingResp struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&embeddingResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(embeddingResp.Data) == 0 {
+		return nil, fmt.Errorf("no embedding data returned")
+	}
+	return embeddingResp.Data[0].Embedding, nil
+}
@@ -0,0 +1,59 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAICompatibleClient(t *testing.T) {
+	var chat map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/chat/completions":
+			json.NewDecoder(r.Body).Decode(&chat)
+			w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"local answer"}}],"usage":{"prompt_tokens":12,"completion_tokens":3}}`))
+		case "/v1/embeddings":
+			var req map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&req)
+			if req["model"] != "bge-small" {
+				t.Errorf("embedding model = %v", req["model"])
+			}
+			w.Write([]byte(`{"data":[{"embedding":[0.5,0.25]}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	ctx := WithParameters(context.Background(), Parameters{MaxTokens: 4000})
+	client := NewOpenAICompatibleClient(server.URL+"/v1/", "", "qwen2.5-coder", Capabilities{ContextLength: 1024})
+	got, err := client.Complete(ctx, "Write hello world")
+	if err != nil || got != "local answer" {
+		t.Fatalf("Complete = %q, %v", got, err)
+	}
+	if chat["model"] != "qwen2.5-coder" {
+		t.Errorf("model = %v", chat["model"])
+	}
+	// Max tokens shrink to what the context length leaves after the prompt
+	if maxTokens := chat["max_tokens"].(float64); maxTokens <= 0 || maxTokens >= 1024 {
+		t.Errorf("max_tokens = %v", maxTokens)
+	}
+
+	if _, err := client.Complete(ctx, strings.Repeat("x", 8000)); !errors.Is(err, ErrContextLength) {
+		t.Errorf("oversized prompt err = %v", err)
+	}
+	if _, err := client.GenerateEmbedding(ctx, "text"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("embeddings without capability err = %v", err)
+	}
+
+	client = NewOpenAICompatibleClient(server.URL+"/v1", "", "qwen2.5-coder", Capabilities{Embeddings: true, EmbeddingModel: "bge-small"})
+	embedding, err := client.GenerateEmbedding(ctx, "text")
+	if err != nil || len(embedding) != 2 {
+		t.Errorf("GenerateEmbedding = %v, %v", embedding, err)
+	}
+}
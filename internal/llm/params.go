@@ -0,0 +1,109 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// Parameters tune a completion. Unset fields keep the provider default.
+type Parameters struct {
+	Temperature *float64 `json:"temperature,omitempty" yaml:"temperature,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty" yaml:"top_p,omitempty"`
+	Seed        *int     `json:"seed,omitempty" yaml:"seed,omitempty"` // Sampling seed for providers that support one
+}
+
+// Merge returns p with the fields set in override replacing its own
+func (p Parameters) Merge(override Parameters) Parameters {
+	if override.Temperature != nil {
+		p.Temperature = override.Temperature
+	}
+	if override.MaxTokens != 0 {
+		p.MaxTokens = override.MaxTokens
+	}
+	if override.TopP != nil {
+		p.TopP = override.TopP
+	}
+	if override.Seed != nil {
+		p.Seed = override.Seed
+	}
+	return p
+}
+
+// Validate checks the ranges the providers accept
+func (p Parameters) Validate() error {
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		return fmt.Errorf("temperature %g is outside 0-2", *p.Temperature)
+	}
+	if p.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens %d cannot be negative", p.MaxTokens)
+	}
+	if p.TopP != nil && (*p.TopP <= 0 || *p.TopP > 1) {
+		return fmt.Errorf("top_p %g is outside (0, 1]", *p.TopP)
+	}
+	return nil
+}
+
+type parametersKey struct{}
+
+// WithParameters returns a context whose completions use p, layered over
+// the parameters ctx already carries
+func WithParameters(ctx context.Context, p Parameters) context.Context {
+	return context.WithValue(ctx, parametersKey{}, ParametersFromContext(ctx).Merge(p))
+}
+
+// ParametersFromContext returns the parameters set with WithParameters
+func ParametersFromContext(ctx context.Context) Parameters {
+	p, _ := ctx.Value(parametersKey{}).(Parameters)
+	return p
+}
+
+// azureParameters returns the max tokens, temperature and top_p of an Azure
+// OpenAI request
+func azureParameters(ctx context.Context) (int, float32, float32) {
+	return chatParameters(ParametersFromContext(ctx), azureMaxTokens, azureTemperature)
+}
+
+// chatParameters returns the max tokens, temperature and top_p of an OpenAI
+// chat request, with the given defaults for unset fields. The client omits
+// zero values, so a temperature of 0 is sent as the smallest non-zero float
+// instead of falling back to 1.
+func chatParameters(p Parameters, defaultMaxTokens int, defaultTemperature float32) (int, float32, float32) {
+	maxTokens, temperature, topP := defaultMaxTokens, defaultTemperature, float32(0)
+	if p.MaxTokens > 0 {
+		maxTokens = p.MaxTokens
+	}
+	if p.Temperature != nil {
+		temperature = float32(*p.Temperature)
+		if temperature == 0 {
+			temperature = math.SmallestNonzeroFloat32
+		}
+	}
+	if p.TopP != nil {
+		topP = float32(*p.TopP)
+	}
+	return maxTokens, temperature, topP
+}
+
+// ollamaOptions returns the options of an Ollama request, nil for the model defaults
+func ollamaOptions(ctx context.Context) map[string]interface{} {
+	p := ParametersFromContext(ctx)
+	options := make(map[string]interface{})
+	if p.Temperature != nil {
+		options["temperature"] = *p.Temperature
+	}
+	if p.MaxTokens > 0 {
+		options["num_predict"] = p.MaxTokens
+	}
+	if p.TopP != nil {
+		options["top_p"] = *p.TopP
+	}
+	if p.Seed != nil {
+		options["seed"] = *p.Seed
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return options
+}
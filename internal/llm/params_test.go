@@ -0,0 +1,33 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func float(f float64) *float64 {
+	return &f
+}
+
+func TestParametersLayerInContext(t *testing.T) {
+	ctx := WithParameters(context.Background(), Parameters{Temperature: float(0.7), MaxTokens: 4000})
+	ctx = WithParameters(ctx, Parameters{Temperature: float(0), TopP: float(0.9)})
+
+	maxTokens, temperature, topP := azureParameters(ctx)
+	if maxTokens != 4000 || temperature != math.SmallestNonzeroFloat32 || topP != float32(0.9) {
+		t.Errorf("azure = %d %g %g", maxTokens, temperature, topP)
+	}
+	options := ollamaOptions(ctx)
+	if options["temperature"] != 0.0 || options["num_predict"] != 4000 || options["top_p"] != 0.9 {
+		t.Errorf("ollama = %v", options)
+	}
+
+	// Without parameters the provider defaults apply
+	if maxTokens, temperature, _ := azureParameters(context.Background()); maxTokens != azureMaxTokens || temperature != azureTemperature {
+		t.Errorf("azure defaults = %d %g", maxTokens, temperature)
+	}
+	if options := ollamaOptions(context.Background()); options != nil {
+		t.Errorf("ollama defaults = %v", options)
+	}
+}
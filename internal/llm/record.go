@@ -0,0 +1,224 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"QLP/internal/config"
+	"QLP/internal/secrets"
+)
+
+// Record/replay modes selected with QLP_LLM_MODE
+const (
+	ModeLive   = "live"   // call the providers
+	ModeRecord = "record" // call the providers and save every interaction
+	ModeReplay = "replay" // serve saved interactions without calling providers
+)
+
+// ErrNotRecorded is returned in replay mode for prompts missing from the cassette
+var ErrNotRecorded = errors.New("no recorded LLM interaction for prompt")
+
+// Interaction is a recorded prompt and the responses the providers gave for
+// it, in call order
+type Interaction struct {
+	Key        string    `json:"key"`
+	Kind       string    `json:"kind"` // complete, structured or embedding
+	Schema     string    `json:"schema,omitempty"`
+	Prompt     string    `json:"prompt"`
+	Responses  []string  `json:"responses,omitempty"`
+	Embedding  []float32 `json:"embedding,omitempty"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// Cassette stores interactions as one JSON file per prompt in a directory.
+// Prompts and responses are scrubbed of registered secrets before they are
+// written, and prompts are keyed by a hash of their normalized text so the
+// IDs and timestamps that differ between runs still replay.
+type Cassette struct {
+	dir    string
+	redact func(string) string
+
+	mu    sync.Mutex
+	calls map[string]int // replay position per key
+}
+
+// NewCassette opens a cassette directory
+func NewCassette(dir string) *Cassette {
+	return &Cassette{dir: dir, redact: secrets.Scrub, calls: make(map[string]int)}
+}
+
+var (
+	uuidPattern      = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	timestampPattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:?\d{2})?`)
+	hexIDPattern     = regexp.MustCompile(`\b[0-9a-f]{16,}\b`)
+)
+
+// normalizePrompt replaces per-run values so the same request hashes alike
+func normalizePrompt(prompt string) string {
+	prompt = uuidPattern.ReplaceAllString(prompt, "<uuid>")
+	prompt = timestampPattern.ReplaceAllString(prompt, "<time>")
+	return hexIDPattern.ReplaceAllString(prompt, "<id>")
+}
+
+func (c *Cassette) key(kind, schema, prompt string) string {
+	sum := sha256.Sum256([]byte(kind + "\x00" + schema + "\x00" + normalizePrompt(c.redact(prompt))))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *Cassette) path(key string) string {
+	return filepath.Join(c.dir, key[:24]+".json")
+}
+
+func (c *Cassette) load(key string) (*Interaction, error) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, err
+	}
+	var in Interaction
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, fmt.Errorf("corrupt cassette entry %s: %w", c.path(key), err)
+	}
+	return &in, nil
+}
+
+// Record appends a response for the prompt. The first call of a recording
+// run replaces what an earlier run saved.
+func (c *Cassette) Record(kind, schema, prompt, response string, embedding []float32) error {
+	key := c.key(kind, schema, prompt)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	in, err := c.load(key)
+	if err != nil || c.calls[key] == 0 {
+		in = &Interaction{Key: key, Kind: kind, Schema: schema, Prompt: c.redact(prompt)}
+	}
+	c.calls[key]++
+	if kind == "embedding" {
+		in.Embedding = embedding
+	} else {
+		in.Responses = append(in.Responses, c.redact(response))
+	}
+	in.RecordedAt = time.Now().UTC()
+
+	data, err := json.MarshalIndent(in, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+	tmp := c.path(key) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path(key))
+}
+
+// Replay returns the next recorded response for the prompt, repeating the
+// last one once they run out
+func (c *Cassette) Replay(kind, schema, prompt string) (*Interaction, string, error) {
+	key := c.key(kind, schema, prompt)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	in, err := c.load(key)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w (%s %s)", ErrNotRecorded, kind, key[:12])
+	}
+	if len(in.Responses) == 0 {
+		return in, "", nil
+	}
+	i := min(c.calls[key], len(in.Responses)-1)
+	c.calls[key]++
+	return in, in.Responses[i], nil
+}
+
+// RecordingClient calls the wrapped client and saves every interaction
+type RecordingClient struct {
+	next     Client
+	cassette *Cassette
+}
+
+// NewRecordingClient records next's interactions to cassette
+func NewRecordingClient(next Client, cassette *Cassette) *RecordingClient {
+	return &RecordingClient{next: next, cassette: cassette}
+}
+
+func (r *RecordingClient) Complete(ctx context.Context, prompt string) (string, error) {
+	response, err := r.next.Complete(ctx, prompt)
+	if err == nil {
+		r.record("complete", "", prompt, response, nil)
+	}
+	return response, err
+}
+
+func (r *RecordingClient) CompleteStructured(ctx context.Context, prompt string, schema Schema) (string, error) {
+	response, err := completeStructured(ctx, r.next, prompt, schema)
+	if err == nil {
+		r.record("structured", schema.Name, prompt, response, nil)
+	}
+	return response, err
+}
+
+func (r *RecordingClient) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	embedding, err := r.next.GenerateEmbedding(ctx, text)
+	if err == nil {
+		r.record("embedding", "", text, "", embedding)
+	}
+	return embedding, err
+}
+
+// record saves an interaction; a failed write only costs the recording
+func (r *RecordingClient) record(kind, schema, prompt, response string, embedding []float32) {
+	if err := r.cassette.Record(kind, schema, prompt, response, embedding); err != nil {
+		fmt.Fprintf(os.Stderr, "LLM recording failed: %v\n", err)
+	}
+}
+
+// ReplayClient serves recorded interactions and never calls a provider
+type ReplayClient struct {
+	cassette *Cassette
+}
+
+// NewReplayClient replays interactions from cassette
+func NewReplayClient(cassette *Cassette) *ReplayClient {
+	return &ReplayClient{cassette: cassette}
+}
+
+func (r *ReplayClient) Complete(ctx context.Context, prompt string) (string, error) {
+	ctx, call := startCall(ctx, r)
+	_, response, err := r.cassette.Replay("complete", "", prompt)
+	endCall(ctx, call, err)
+	return response, err
+}
+
+func (r *ReplayClient) CompleteStructured(ctx context.Context, prompt string, schema Schema) (string, error) {
+	_, response, err := r.cassette.Replay("structured", schema.Name, prompt)
+	return response, err
+}
+
+func (r *ReplayClient) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	in, _, err := r.cassette.Replay("embedding", "", text)
+	if err != nil {
+		return nil, err
+	}
+	return in.Embedding, nil
+}
+
+// recordingMode reads QLP_LLM_MODE and the QLP_LLM_CASSETTE directory
+func recordingMode() (string, *Cassette) {
+	mode := config.GetEnvOrDefault("QLP_LLM_MODE", ModeLive)
+	if mode != ModeRecord && mode != ModeReplay {
+		return ModeLive, nil
+	}
+	return mode, NewCassette(config.GetEnvOrDefault("QLP_LLM_CASSETTE", "testdata/llm-cassette"))
+}
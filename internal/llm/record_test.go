@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type scriptedClient struct {
+	responses []string
+	calls     int
+}
+
+func (s *scriptedClient) Complete(ctx context.Context, prompt string) (string, error) {
+	r := s.responses[min(s.calls, len(s.responses)-1)]
+	s.calls++
+	return r, nil
+}
+
+func (s *scriptedClient) CompleteStructured(ctx context.Context, prompt string, schema Schema) (string, error) {
+	return s.Complete(ctx, prompt)
+}
+
+func (s *scriptedClient) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	return []float32{0.5, 0.25}, nil
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	live := &scriptedClient{responses: []string{"first", "second"}}
+	recorder := NewRecordingClient(live, NewCassette(dir))
+
+	prompt := "Plan task 3f2a9c1e-0b4d-4e8a-9c1f-2b3d4e5f6a7b at 2026-01-02T03:04:05Z"
+	for _, want := range []string{"first", "second"} {
+		if got, err := recorder.Complete(ctx, prompt); err != nil || got != want {
+			t.Fatalf("recording Complete = %q, %v; want %q", got, err, want)
+		}
+	}
+	if _, err := recorder.GenerateEmbedding(ctx, "text"); err != nil {
+		t.Fatal(err)
+	}
+
+	replay := NewReplayClient(NewCassette(dir))
+	// A later run differs only in its IDs and timestamps
+	rerun := "Plan task 9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d at 2026-02-03T04:05:06Z"
+	for _, want := range []string{"first", "second", "second"} {
+		if got, err := replay.Complete(ctx, rerun); err != nil || got != want {
+			t.Fatalf("replay Complete = %q, %v; want %q", got, err, want)
+		}
+	}
+	if emb, err := replay.GenerateEmbedding(ctx, "text"); err != nil || len(emb) != 2 || emb[0] != 0.5 {
+		t.Fatalf("replay embedding = %v, %v", emb, err)
+	}
+	if _, err := replay.Complete(ctx, "never recorded"); !errors.Is(err, ErrNotRecorded) {
+		t.Fatalf("expected ErrNotRecorded, got %v", err)
+	}
+}
+
+func TestRecordingOverwritesEarlierRun(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	NewRecordingClient(&scriptedClient{responses: []string{"old"}}, NewCassette(dir)).Complete(ctx, "p")
+	NewRecordingClient(&scriptedClient{responses: []string{"new"}}, NewCassette(dir)).Complete(ctx, "p")
+
+	got, err := NewReplayClient(NewCassette(dir)).Complete(ctx, "p")
+	if err != nil || got != "new" {
+		t.Fatalf("replay = %q, %v; want the latest recording", got, err)
+	}
+}
@@ -0,0 +1,264 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"QLP/internal/llm/jsonutil"
+	"QLP/internal/metrics"
+	"QLP/internal/tracing"
+
+	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrStructuredOutput is returned when no attempt produced output matching the schema
+var ErrStructuredOutput = jsonutil.ErrInvalid
+
+// Schema describes the JSON object a structured completion must produce
+type Schema struct {
+	Name        string
+	Description string
+	Definition  map[string]interface{} // JSON Schema
+}
+
+// SchemaFor derives a JSON Schema from the json tags of a struct value
+func SchemaFor(name, description string, v interface{}) Schema {
+	return Schema{
+		Name:        name,
+		Description: description,
+		Definition:  schemaForType(reflect.TypeOf(v)),
+	}
+}
+
+func schemaForType(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			return map[string]interface{}{"type": "string", "format": "date-time"}
+		}
+		properties := make(map[string]interface{})
+		required := make([]string, 0)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, omitempty := jsonFieldName(field)
+			if name == "-" {
+				continue
+			}
+			properties[name] = schemaForType(field.Type)
+			if !omitempty {
+				required = append(required, name)
+			}
+		}
+		return map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaForType(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaForType(t.Elem())}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "" {
+		return field.Name, false
+	}
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			return name, true
+		}
+	}
+	return name, false
+}
+
+// StructuredCompleter is implemented by providers with native JSON output
+// (function calling or a JSON response mode)
+type StructuredCompleter interface {
+	CompleteStructured(ctx context.Context, prompt string, schema Schema) (string, error)
+}
+
+// CompleteJSON asks client for output matching schema and decodes it into out.
+// Providers with native structured output are used directly; otherwise the
+// schema is embedded in the prompt. Minor deviations are repaired, and
+// responses that still fail to decode are retried with a corrective prompt.
+func CompleteJSON(ctx context.Context, client Client, prompt string, schema Schema, out interface{}) error {
+	complete := func(ctx context.Context, prompt string) (string, error) {
+		return completeStructured(ctx, client, prompt, schema)
+	}
+	return jsonutil.Retry(ctx, complete, prompt, schema.Definition, out, 3)
+}
+
+func completeStructured(ctx context.Context, client Client, prompt string, schema Schema) (string, error) {
+	if sc, ok := client.(StructuredCompleter); ok {
+		return sc.CompleteStructured(ctx, prompt, schema)
+	}
+	return client.Complete(ctx, withSchemaInstructions(prompt, schema))
+}
+
+func withSchemaInstructions(prompt string, schema Schema) string {
+	definition, _ := json.MarshalIndent(schema.Definition, "", "  ")
+	return fmt.Sprintf("%s\n\nRespond with JSON only, no prose or markdown, matching this JSON Schema:\n%s", prompt, definition)
+}
+
+// CompleteStructured tries each provider in turn, skipping those whose
+// circuit is open, using native structured output where the provider
+// supports it
+func (f *FallbackClient) CompleteStructured(ctx context.Context, prompt string, schema Schema) (string, error) {
+	lastErr := fmt.Errorf("every provider: %w", ErrCircuitOpen)
+
+	for _, client := range f.ordered() {
+		start := time.Now()
+		spanCtx, span := tracing.StartSpan(ctx, "llm.complete_structured",
+			attribute.String("llm.provider", providerName(client)),
+			attribute.String("llm.schema", schema.Name))
+		var response string
+		err := f.attempt(spanCtx, client, func() (err error) {
+			response, err = completeStructured(spanCtx, client, prompt, schema)
+			return err
+		})
+		tracing.EndSpan(span, err)
+		metrics.ObserveLLMRequest(providerName(client), time.Since(start), err)
+		if err == nil {
+			return response, nil
+		}
+		lastErr = err
+	}
+
+	return "", fmt.Errorf("all LLM clients failed, last error: %w", lastErr)
+}
+
+// CompleteStructured uses function calling so the model returns arguments matching the schema
+func (a *AzureOpenAIClient) CompleteStructured(ctx context.Context, prompt string, schema Schema) (string, error) {
+	name := schema.Name
+	if name == "" {
+		name = "respond"
+	}
+	maxTokens, temperature, topP := azureParameters(ctx)
+
+	req := openai.ChatCompletionRequest{
+		Model: a.model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: prompt,
+			},
+		},
+		Tools: []openai.Tool{{
+			Type: openai.ToolTypeFunction,
+			Function: openai.FunctionDefinition{
+				Name:        name,
+				Description: schema.Description,
+				Parameters:  schema.Definition,
+			},
+		}},
+		ToolChoice: openai.ToolChoice{
+			Type:     openai.ToolTypeFunction,
+			Function: openai.ToolFunction{Name: name},
+		},
+		MaxTokens:   maxTokens,
+		Temperature: temperature,
+		TopP:        topP,
+		Seed:        ParametersFromContext(ctx).Seed,
+	}
+
+	resp, err := a.client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("Azure OpenAI structured completion failed: %w", err)
+	}
+
+	recordTokens(ctx, "azure_openai", resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	observeResponse(ctx, resp.Model, resp.SystemFingerprint)
+
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no completion choices returned")
+	}
+	message := resp.Choices[0].Message
+	if len(message.ToolCalls) > 0 {
+		return message.ToolCalls[0].Function.Arguments, nil
+	}
+	return message.Content, nil
+}
+
+// CompleteStructured uses Ollama's JSON output format with the schema embedded in the prompt
+func (o *OllamaClient) CompleteStructured(ctx context.Context, prompt string, schema Schema) (string, error) {
+	reqBody := struct {
+		OllamaRequest
+		Format string `json:"format"`
+	}{
+		OllamaRequest: OllamaRequest{
+			Model:   o.model,
+			Prompt:  withSchemaInstructions(prompt, schema),
+			Stream:  false,
+			Options: ollamaOptions(ctx),
+		},
+		Format: "json",
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/api/generate", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Ollama request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Ollama returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var ollamaResp OllamaResponse
+	if err := json.Unmarshal(body, &ollamaResp); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	recordTokens(ctx, "ollama", ollamaResp.PromptEvalCount, ollamaResp.EvalCount)
+
+	return strings.TrimSpace(ollamaResp.Response), nil
+}
@@ -94,6 +94,8 @@ func InitLogger(config Config) error {
 	var writeSyncer zapcore.WriteSyncer
 	if config.OutputPath == "stdout" || config.OutputPath == "" {
 		writeSyncer = zapcore.AddSync(os.Stdout)
+	} else if config.OutputPath == "stderr" {
+		writeSyncer = zapcore.AddSync(os.Stderr)
 	} else {
 		file, err := os.OpenFile(config.OutputPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 		if err != nil {
@@ -103,7 +105,7 @@ func InitLogger(config Config) error {
 	}
 
 	// Create core
-	core := zapcore.NewCore(encoder, writeSyncer, level)
+	core := zapcore.NewCore(encoder, redactingSyncer{writeSyncer}, level)
 
 	// Build logger with options
 	var options []zap.Option
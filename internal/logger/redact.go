@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"io"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+var redactor atomic.Value // func(string) string
+
+// SetRedactor installs a function applied to every log line before it is
+// written, e.g. to mask secret values
+func SetRedactor(fn func(string) string) {
+	redactor.Store(fn)
+}
+
+func redact(p []byte) []byte {
+	if fn, ok := redactor.Load().(func(string) string); ok && fn != nil {
+		return []byte(fn(string(p)))
+	}
+	return p
+}
+
+type redactingSyncer struct {
+	zapcore.WriteSyncer
+}
+
+func (r redactingSyncer) Write(p []byte) (int, error) {
+	if _, err := r.WriteSyncer.Write(redact(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (r redactingWriter) Write(p []byte) (int, error) {
+	if _, err := r.w.Write(redact(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// RedactingWriter wraps w so output passes through the installed redactor;
+// use it for the standard library logger
+func RedactingWriter(w io.Writer) io.Writer {
+	return redactingWriter{w: w}
+}
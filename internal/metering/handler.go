@@ -0,0 +1,88 @@
+package metering
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Routes returns the metering endpoints. month is YYYY-MM and defaults to
+// the current UTC month.
+//
+//	GET /metering/usage?month=                      every tenant's priced usage
+//	GET /tenants/{tenant}/metering?month=           one tenant's priced usage
+//	GET /metering/report.csv?month=&kind=showback   chargeback (default) or showback CSV
+func Routes(m *Meter) map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /metering/usage":            usageListHandler(m),
+		"GET /tenants/{tenant}/metering": usageHandler(m),
+		"GET /metering/report.csv":       reportHandler(m),
+	}
+}
+
+func month(m *Meter, r *http.Request) string {
+	if month := r.URL.Query().Get("month"); month != "" {
+		return month
+	}
+	return m.CurrentMonth()
+}
+
+func usageListHandler(m *Meter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		usage, err := m.UsageAll(r.Context(), month(m, r))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"month":   month(m, r),
+			"tenants": usage,
+		})
+	})
+}
+
+func usageHandler(m *Meter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		usage, err := m.Usage(r.Context(), r.PathValue("tenant"), month(m, r))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, usage)
+	})
+}
+
+func reportHandler(m *Meter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		kind := r.URL.Query().Get("kind")
+		if kind == "" {
+			kind = Chargeback
+		}
+		if kind != Chargeback && kind != Showback {
+			http.Error(w, fmt.Sprintf("invalid kind %q, expected %s or %s", kind, Chargeback, Showback), http.StatusBadRequest)
+			return
+		}
+		usage, err := m.UsageAll(r.Context(), month(m, r))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s-%s.csv", kind, month(m, r))))
+		WriteCSV(w, usage, kind)
+	})
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, ErrInvalidMonth) {
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,301 @@
+// Package metering aggregates what each tenant consumes per UTC calendar
+// month: LLM tokens, sandbox CPU-seconds, validation minutes, stored bytes
+// and cloud validation spend. Usage is priced with configurable rates into
+// chargeback reports, or reported unpriced for showback.
+package metering
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"QLP/internal/config"
+	"QLP/internal/logger"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidMonth is returned for months not in YYYY-MM form
+var ErrInvalidMonth = errors.New("invalid month, expected YYYY-MM")
+
+// Resource is a metered quantity
+type Resource string
+
+const (
+	LLMTokens         Resource = "llm_tokens"
+	SandboxCPUSeconds Resource = "sandbox_cpu_seconds"
+	ValidationMinutes Resource = "validation_minutes"
+	// StorageBytes is the peak of a tenant's stored artifacts in the month
+	StorageBytes Resource = "storage_bytes"
+	// CloudSpendUSD is what cloud validation deployments cost, as reported
+	// by the provider
+	CloudSpendUSD Resource = "cloud_validation_usd"
+)
+
+// Resources lists every metered resource in report order
+var Resources = []Resource{LLMTokens, SandboxCPUSeconds, ValidationMinutes, StorageBytes, CloudSpendUSD}
+
+// rateUnit is what a rate is charged per: its name and size in the
+// resource's own unit
+type rateUnit struct {
+	name string
+	size float64
+}
+
+var rateUnits = map[Resource]rateUnit{
+	LLMTokens:         {"1K tokens", 1000},
+	SandboxCPUSeconds: {"CPU-hour", 3600},
+	ValidationMinutes: {"minute", 1},
+	StorageBytes:      {"GB-month", 1 << 30},
+	CloudSpendUSD:     {"USD", 1},
+}
+
+// monthFormat keys usage by UTC month
+const monthFormat = "2006-01"
+
+// defaultTenant meters usage that carries no tenant
+const defaultTenant = "default"
+
+// Rates are the USD charged per rate unit of each resource: per 1K tokens,
+// CPU-hour, validation minute, GB-month of peak storage and USD of cloud
+// spend. Resources without a rate are reported but not charged.
+type Rates map[Resource]float64
+
+// ParseRates reads rates such as "llm_tokens=0.002,sandbox_cpu_seconds=0.05".
+// Cloud spend is passed through at cost unless a markup rate is given.
+func ParseRates(spec string) (Rates, error) {
+	rates := Rates{CloudSpendUSD: 1}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid metering rate %q, expected resource=usd", pair)
+		}
+		r := Resource(strings.TrimSpace(name))
+		if _, known := rateUnits[r]; !known {
+			return nil, fmt.Errorf("unknown metered resource %q", name)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("invalid rate for %s", name)
+		}
+		rates[r] = rate
+	}
+	return rates, nil
+}
+
+// Line is one resource on a tenant's bill
+type Line struct {
+	Resource  Resource `json:"resource"`
+	Quantity  float64  `json:"quantity"`
+	Rate      float64  `json:"rate_usd"`
+	RateUnit  string   `json:"rate_unit"`
+	ChargeUSD float64  `json:"charge_usd"`
+}
+
+// Usage is what a tenant consumed in one month
+type Usage struct {
+	TenantID string  `json:"tenant_id"`
+	Month    string  `json:"month"`
+	Lines    []Line  `json:"lines"`
+	TotalUSD float64 `json:"total_usd"`
+}
+
+// StorageSource reports the bytes each tenant has stored now
+type StorageSource func(ctx context.Context) (map[string]int64, error)
+
+// Meter records and prices tenants' usage
+type Meter struct {
+	store Store
+	rates Rates
+	now   func() time.Time
+
+	mu      sync.RWMutex
+	storage StorageSource
+}
+
+// NewMeter records usage in store and prices it with rates
+func NewMeter(store Store, rates Rates) *Meter {
+	return &Meter{store: store, rates: rates, now: time.Now}
+}
+
+// NewMeterFromEnv configures a meter from the environment:
+//
+//	QLP_METERING_RATES  USD per rate unit, e.g. "llm_tokens=0.002,sandbox_cpu_seconds=0.05,
+//	                    validation_minutes=0.01,storage_bytes=0.023,cloud_validation_usd=1.1"
+//
+// Usage is kept in the database at DATABASE_URL, or in memory when it is
+// unavailable.
+func NewMeterFromEnv() (*Meter, error) {
+	rates, err := ParseRates(config.GetEnvOrDefault("QLP_METERING_RATES", ""))
+	if err != nil {
+		return nil, err
+	}
+	store, err := NewStoreFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewMeter(store, rates), nil
+}
+
+var (
+	sharedOnce  sync.Once
+	sharedMeter *Meter
+)
+
+// Shared returns the meter of the process, configured from the environment
+// on first use, or nil unless QLP_ENABLE_METERING is true
+func Shared() *Meter {
+	sharedOnce.Do(func() {
+		if config.GetEnvOrDefault("QLP_ENABLE_METERING", "false") != "true" {
+			return
+		}
+		m, err := NewMeterFromEnv()
+		if err != nil {
+			logger.WithComponent("metering").Warn("Usage metering disabled", zap.Error(err))
+			return
+		}
+		sharedMeter = m
+	})
+	return sharedMeter
+}
+
+// Record adds usage to the shared meter when metering is enabled. Failures
+// are logged, so metering never fails the work it measures.
+func Record(ctx context.Context, tenantID string, r Resource, amount float64) {
+	m := Shared()
+	if m == nil {
+		return
+	}
+	if err := m.Record(ctx, tenantID, r, amount); err != nil {
+		logger.WithComponent("metering").Warn("Failed to record usage",
+			zap.String("tenant_id", tenantID),
+			zap.String("resource", string(r)),
+			zap.Error(err))
+	}
+}
+
+// Record adds amount of a resource to the tenant's usage this month.
+// Storage is a level rather than a sum, so only its peak is kept.
+func (m *Meter) Record(ctx context.Context, tenantID string, r Resource, amount float64) error {
+	if amount <= 0 {
+		return nil
+	}
+	if tenantID == "" {
+		tenantID = defaultTenant
+	}
+	month := m.now().UTC().Format(monthFormat)
+	if r == StorageBytes {
+		return m.store.Peak(ctx, tenantID, month, r, amount)
+	}
+	return m.store.Add(ctx, tenantID, month, r, amount)
+}
+
+// SetStorageSource sets where stored bytes are sampled from
+func (m *Meter) SetStorageSource(source StorageSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.storage = source
+}
+
+// SampleStorage records every tenant's stored bytes towards this month's peak
+func (m *Meter) SampleStorage(ctx context.Context) error {
+	m.mu.RLock()
+	source := m.storage
+	m.mu.RUnlock()
+	if source == nil {
+		return nil
+	}
+	stored, err := source(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to sample stored bytes: %w", err)
+	}
+	for tenantID, bytes := range stored {
+		if err := m.Record(ctx, tenantID, StorageBytes, float64(bytes)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunStorageSampler samples stored bytes every interval until ctx is done
+func (m *Meter) RunStorageSampler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := m.SampleStorage(ctx); err != nil {
+			logger.WithComponent("metering").Warn("Storage sampling failed", zap.Error(err))
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// CurrentMonth returns the month usage is recorded in now
+func (m *Meter) CurrentMonth() string {
+	return m.now().UTC().Format(monthFormat)
+}
+
+// UsageAll returns the priced usage of every tenant in month (YYYY-MM),
+// sorted by tenant. The current month's storage is sampled first.
+func (m *Meter) UsageAll(ctx context.Context, month string) ([]Usage, error) {
+	if _, err := time.Parse(monthFormat, month); err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidMonth, month)
+	}
+	if month == m.CurrentMonth() {
+		if err := m.SampleStorage(ctx); err != nil {
+			logger.WithComponent("metering").Warn("Storage sampling failed", zap.Error(err))
+		}
+	}
+	quantities, err := m.store.List(ctx, month)
+	if err != nil {
+		return nil, err
+	}
+	usage := make([]Usage, 0, len(quantities))
+	for tenantID, q := range quantities {
+		usage = append(usage, m.price(tenantID, month, q))
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].TenantID < usage[j].TenantID })
+	return usage, nil
+}
+
+// Usage returns the priced usage of one tenant in month
+func (m *Meter) Usage(ctx context.Context, tenantID, month string) (Usage, error) {
+	all, err := m.UsageAll(ctx, month)
+	if err != nil {
+		return Usage{}, err
+	}
+	for _, u := range all {
+		if u.TenantID == tenantID {
+			return u, nil
+		}
+	}
+	return m.price(tenantID, month, nil), nil
+}
+
+func (m *Meter) price(tenantID, month string, quantities map[Resource]float64) Usage {
+	u := Usage{TenantID: tenantID, Month: month, Lines: make([]Line, 0, len(Resources))}
+	for _, r := range Resources {
+		unit := rateUnits[r]
+		line := Line{Resource: r, Quantity: quantities[r], Rate: m.rates[r], RateUnit: unit.name}
+		line.ChargeUSD = roundCents(line.Quantity / unit.size * line.Rate)
+		u.TotalUSD += line.ChargeUSD
+		u.Lines = append(u.Lines, line)
+	}
+	u.TotalUSD = roundCents(u.TotalUSD)
+	return u
+}
+
+func roundCents(usd float64) float64 {
+	return float64(int64(usd*100+0.5)) / 100
+}
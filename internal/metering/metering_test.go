@@ -0,0 +1,153 @@
+package metering
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRates(t *testing.T) {
+	rates, err := ParseRates("llm_tokens=0.002, sandbox_cpu_seconds=0.05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rates[LLMTokens] != 0.002 || rates[SandboxCPUSeconds] != 0.05 || rates[CloudSpendUSD] != 1 {
+		t.Errorf("rates = %v", rates)
+	}
+	for _, bad := range []string{"llm_tokens", "gpu_hours=1", "llm_tokens=-1", "llm_tokens=cheap"} {
+		if _, err := ParseRates(bad); err == nil {
+			t.Errorf("ParseRates(%q) accepted", bad)
+		}
+	}
+}
+
+func newTestMeter(t *testing.T) *Meter {
+	t.Helper()
+	rates, err := ParseRates("llm_tokens=0.002,sandbox_cpu_seconds=0.36,validation_minutes=0.01,storage_bytes=0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMeter(NewMemoryStore(), rates)
+	m.now = func() time.Time { return time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC) }
+	return m
+}
+
+func TestMeterPricesMonthlyUsage(t *testing.T) {
+	m := newTestMeter(t)
+	ctx := context.Background()
+
+	m.Record(ctx, "acme", LLMTokens, 150000)
+	m.Record(ctx, "acme", LLMTokens, 50000)
+	m.Record(ctx, "acme", SandboxCPUSeconds, 7200)
+	m.Record(ctx, "acme", ValidationMinutes, 12.5)
+	m.Record(ctx, "acme", CloudSpendUSD, 3.2)
+	m.Record(ctx, "", LLMTokens, 1000)
+	m.SetStorageSource(func(context.Context) (map[string]int64, error) {
+		return map[string]int64{"acme": 2 << 30}, nil
+	})
+	// A later, lower level does not lower the month's peak
+	m.Record(ctx, "acme", StorageBytes, 4<<30)
+
+	usage, err := m.UsageAll(ctx, "2026-03")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 2 || usage[0].TenantID != "acme" || usage[1].TenantID != "default" {
+		t.Fatalf("usage = %+v", usage)
+	}
+	charges := make(map[Resource]float64)
+	for _, line := range usage[0].Lines {
+		charges[line.Resource] = line.ChargeUSD
+	}
+	want := map[Resource]float64{
+		LLMTokens:         0.4,  // 200K tokens at 0.002 per 1K
+		SandboxCPUSeconds: 0.72, // 2 CPU-hours at 0.36
+		ValidationMinutes: 0.13, // 12.5 minutes at 0.01, rounded
+		StorageBytes:      2,    // 4 GB peak at 0.5
+		CloudSpendUSD:     3.2,  // passed through at cost
+	}
+	for r, charge := range want {
+		if charges[r] != charge {
+			t.Errorf("%s charge = %v, want %v", r, charges[r], charge)
+		}
+	}
+	if usage[0].TotalUSD != 6.45 {
+		t.Errorf("total = %v, want 6.45", usage[0].TotalUSD)
+	}
+
+	if other, _ := m.UsageAll(ctx, "2026-02"); len(other) != 0 {
+		t.Errorf("February usage = %+v", other)
+	}
+	if _, err := m.UsageAll(ctx, "March"); !errors.Is(err, ErrInvalidMonth) {
+		t.Errorf("invalid month error = %v", err)
+	}
+	if u, _ := m.Usage(ctx, "globex", "2026-03"); u.TotalUSD != 0 || len(u.Lines) != len(Resources) {
+		t.Errorf("usage of a tenant without any = %+v", u)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	m := newTestMeter(t)
+	ctx := context.Background()
+	m.Record(ctx, "acme", LLMTokens, 10000)
+	usage, _ := m.UsageAll(ctx, "2026-03")
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, usage, Chargeback); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1+len(Resources)+1 {
+		t.Fatalf("rows = %v", rows)
+	}
+	if got := strings.Join(rows[1], ","); got != "acme,2026-03,llm_tokens,10000,0.002,1K tokens,0.02" {
+		t.Errorf("first row = %s", got)
+	}
+	if last := rows[len(rows)-1]; last[2] != "total" || last[6] != "0.02" {
+		t.Errorf("total row = %v", last)
+	}
+
+	buf.Reset()
+	WriteCSV(&buf, usage, Showback)
+	rows, _ = csv.NewReader(&buf).ReadAll()
+	if len(rows) != 1+len(Resources) || len(rows[0]) != 4 {
+		t.Errorf("showback rows = %v", rows)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	m := newTestMeter(t)
+	m.Record(context.Background(), "acme", ValidationMinutes, 3)
+	mux := http.NewServeMux()
+	for pattern, h := range Routes(m) {
+		mux.Handle(pattern, h)
+	}
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/metering/usage", http.StatusOK, `"month":"2026-03"`},
+		{"/tenants/acme/metering?month=2026-03", http.StatusOK, `"total_usd":0.03`},
+		{"/metering/report.csv?kind=showback", http.StatusOK, "acme,2026-03,validation_minutes,3"},
+		{"/metering/report.csv?kind=invoice", http.StatusBadRequest, "invalid kind"},
+		{"/metering/usage?month=2026-3", http.StatusBadRequest, "invalid month"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.status || !strings.Contains(rec.Body.String(), tt.body) {
+			t.Errorf("GET %s = %d %s", tt.path, rec.Code, rec.Body.String())
+		}
+	}
+}
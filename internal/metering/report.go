@@ -0,0 +1,54 @@
+package metering
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Report kinds
+const (
+	// Chargeback prices usage with the configured rates
+	Chargeback = "chargeback"
+	// Showback reports quantities only, for tenants that are not billed
+	Showback = "showback"
+)
+
+// WriteCSV writes one row per tenant and resource, and a total row per
+// tenant in chargeback reports
+func WriteCSV(w io.Writer, usage []Usage, kind string) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{"tenant_id", "month", "resource", "quantity"}
+	if kind == Chargeback {
+		header = append(header, "rate_usd", "rate_unit", "charge_usd")
+	}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, u := range usage {
+		for _, line := range u.Lines {
+			row := []string{u.TenantID, u.Month, string(line.Resource), strconv.FormatFloat(line.Quantity, 'f', -1, 64)}
+			if kind == Chargeback {
+				row = append(row,
+					strconv.FormatFloat(line.Rate, 'f', -1, 64),
+					line.RateUnit,
+					strconv.FormatFloat(line.ChargeUSD, 'f', 2, 64))
+			}
+			if err := writer.Write(row); err != nil {
+				return fmt.Errorf("failed to write CSV row: %w", err)
+			}
+		}
+		if kind == Chargeback {
+			row := []string{u.TenantID, u.Month, "total", "", "", "", strconv.FormatFloat(u.TotalUSD, 'f', 2, 64)}
+			if err := writer.Write(row); err != nil {
+				return fmt.Errorf("failed to write CSV row: %w", err)
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
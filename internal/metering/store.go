@@ -0,0 +1,150 @@
+package metering
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"QLP/internal/database"
+	"QLP/internal/logger"
+)
+
+// Store keeps the monthly quantities of each tenant and resource
+type Store interface {
+	Add(ctx context.Context, tenantID, month string, r Resource, amount float64) error
+	// Peak raises the quantity to amount when it is higher
+	Peak(ctx context.Context, tenantID, month string, r Resource, amount float64) error
+	// List returns the quantities of every tenant with usage in month
+	List(ctx context.Context, month string) (map[string]map[Resource]float64, error)
+}
+
+// MemoryStore keeps usage in memory, for tests and deployments without a
+// database
+type MemoryStore struct {
+	mu    sync.Mutex
+	usage map[string]map[string]map[Resource]float64 // month -> tenant -> resource
+}
+
+// NewMemoryStore creates an empty in-memory usage store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{usage: make(map[string]map[string]map[Resource]float64)}
+}
+
+func (s *MemoryStore) tenant(tenantID, month string) map[Resource]float64 {
+	if s.usage[month] == nil {
+		s.usage[month] = make(map[string]map[Resource]float64)
+	}
+	if s.usage[month][tenantID] == nil {
+		s.usage[month][tenantID] = make(map[Resource]float64)
+	}
+	return s.usage[month][tenantID]
+}
+
+func (s *MemoryStore) Add(_ context.Context, tenantID, month string, r Resource, amount float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tenant(tenantID, month)[r] += amount
+	return nil
+}
+
+func (s *MemoryStore) Peak(_ context.Context, tenantID, month string, r Resource, amount float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.tenant(tenantID, month)
+	q[r] = max(q[r], amount)
+	return nil
+}
+
+func (s *MemoryStore) List(_ context.Context, month string) (map[string]map[Resource]float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	usage := make(map[string]map[Resource]float64)
+	for tenantID, q := range s.usage[month] {
+		usage[tenantID] = make(map[Resource]float64, len(q))
+		for r, amount := range q {
+			usage[tenantID][r] = amount
+		}
+	}
+	return usage, nil
+}
+
+// PostgresStore keeps usage in the tenant_usage table, shared by every QLP
+// process
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates the tenant_usage table if needed
+func NewPostgresStore(db *sql.DB) (*PostgresStore, error) {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS tenant_usage (
+			tenant_id VARCHAR(100) NOT NULL,
+			month VARCHAR(7) NOT NULL,
+			resource VARCHAR(50) NOT NULL,
+			amount DOUBLE PRECISION NOT NULL DEFAULT 0,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (tenant_id, month, resource)
+		)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tenant_usage table: %w", err)
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+func (s *PostgresStore) Add(ctx context.Context, tenantID, month string, r Resource, amount float64) error {
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO tenant_usage (tenant_id, month, resource, amount) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (tenant_id, month, resource) DO UPDATE SET amount = tenant_usage.amount + $4, updated_at = NOW()`,
+		tenantID, month, string(r), amount); err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) Peak(ctx context.Context, tenantID, month string, r Resource, amount float64) error {
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO tenant_usage (tenant_id, month, resource, amount) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (tenant_id, month, resource) DO UPDATE SET amount = GREATEST(tenant_usage.amount, $4), updated_at = NOW()`,
+		tenantID, month, string(r), amount); err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) List(ctx context.Context, month string) (map[string]map[Resource]float64, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT tenant_id, resource, amount FROM tenant_usage WHERE month = $1`, month)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := make(map[string]map[Resource]float64)
+	for rows.Next() {
+		var tenantID, resource string
+		var amount float64
+		if err := rows.Scan(&tenantID, &resource, &amount); err != nil {
+			return nil, fmt.Errorf("failed to read usage: %w", err)
+		}
+		if usage[tenantID] == nil {
+			usage[tenantID] = make(map[Resource]float64)
+		}
+		usage[tenantID][Resource(resource)] = amount
+	}
+	return usage, rows.Err()
+}
+
+// NewStoreFromEnv keeps usage in the database at DATABASE_URL, falling back
+// to memory when it is unavailable
+func NewStoreFromEnv() (Store, error) {
+	db, err := database.New()
+	if err != nil {
+		return nil, err
+	}
+	if !db.IsConnected() {
+		logger.WithComponent("metering").Warn("Database unavailable, usage is kept in memory and not shared between processes")
+		return NewMemoryStore(), nil
+	}
+	return NewPostgresStore(db.GetConnection())
+}
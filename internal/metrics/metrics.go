@@ -0,0 +1,349 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"QLP/internal/logger"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+)
+
+const namespace = "qlp"
+
+// Registry holds every QuantumLayer collector plus the Go and process collectors
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests handled, by handler, method and status code.",
+	}, []string{"handler", "method", "code"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency, by handler and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"handler", "method"})
+
+	llmRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "llm",
+		Name:      "requests_total",
+		Help:      "LLM completion requests, by provider and outcome.",
+	}, []string{"provider", "status"})
+
+	llmRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "llm",
+		Name:      "request_duration_seconds",
+		Help:      "LLM completion latency, by provider.",
+		Buckets:   []float64{0.1, 0.5, 1, 2.5, 5, 10, 20, 40, 80, 160},
+	}, []string{"provider"})
+
+	llmTokensTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "llm",
+		Name:      "tokens_total",
+		Help:      "LLM tokens consumed, by provider and token type (prompt or completion).",
+	}, []string{"provider", "type"})
+
+	agentExecutionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "agent",
+		Name:      "executions_total",
+		Help:      "Agent executions, by task type and final status.",
+	}, []string{"task_type", "status"})
+
+	agentExecutionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "agent",
+		Name:      "execution_duration_seconds",
+		Help:      "Agent execution time, by task type.",
+		Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600},
+	}, []string{"task_type"})
+
+	agentRefinementsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "agent",
+		Name:      "refinements_total",
+		Help:      "Agent outputs sent back for refinement after a low self-review score, by task type.",
+	}, []string{"task_type"})
+
+	validationScore = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "validation",
+		Name:      "score",
+		Help:      "Validation scores (0-100), by score kind.",
+		Buckets:   prometheus.LinearBuckets(10, 10, 10),
+	}, []string{"kind"})
+
+	validationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "validation",
+		Name:      "runs_total",
+		Help:      "Validation runs, by mode and pass/fail result.",
+	}, []string{"mode", "passed"})
+
+	deploymentDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "deployment",
+		Name:      "duration_seconds",
+		Help:      "Deployment validation duration, by final status.",
+		Buckets:   []float64{10, 30, 60, 120, 300, 600, 1200, 1800},
+	}, []string{"status"})
+
+	deploymentActualCost = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "deployment",
+		Name:      "actual_cost_usd",
+		Help:      "Actual cost of deployment validation runs reported by Azure Cost Management.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 25},
+	})
+
+	deploymentCostAlertsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "deployment",
+		Name:      "cost_alerts_total",
+		Help:      "Deployment validation runs whose actual cost exceeded the estimate by more than the alert factor.",
+	})
+
+	cleanupResourceGroupsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "azure_cleanup",
+		Name:      "resource_groups_total",
+		Help:      "Expired resource groups processed by the cleanup controller, by outcome.",
+	}, []string{"status"})
+
+	cleanupCostReclaimed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "azure_cleanup",
+		Name:      "cost_reclaimed_usd_total",
+		Help:      "Estimated cost of the resource groups the cleanup controller deleted.",
+	})
+
+	cleanupOverdue = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "azure_cleanup",
+		Name:      "overdue_seconds",
+		Help:      "Time resource groups outlived their TTL before being deleted.",
+		Buckets:   []float64{60, 300, 900, 1800, 3600, 7200, 21600, 86400},
+	})
+
+	cleanupExpired = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "azure_cleanup",
+		Name:      "expired_resource_groups",
+		Help:      "Expired resource groups found by the last cleanup sweep.",
+	})
+
+	eventQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "events",
+		Name:      "queue_depth",
+		Help:      "Events published but not yet dispatched to handlers.",
+	})
+
+	agentsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "agent",
+		Name:      "in_flight",
+		Help:      "Agent executions currently running.",
+	})
+
+	tasksPending = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "agent",
+		Name:      "tasks_pending",
+		Help:      "Tasks waiting for an agent, the backlog autoscalers scale on.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequestsTotal,
+		httpRequestDuration,
+		llmRequestsTotal,
+		llmRequestDuration,
+		llmTokensTotal,
+		agentExecutionsTotal,
+		agentExecutionDuration,
+		agentRefinementsTotal,
+		validationScore,
+		validationsTotal,
+		deploymentDuration,
+		deploymentActualCost,
+		deploymentCostAlertsTotal,
+		cleanupResourceGroupsTotal,
+		cleanupCostReclaimed,
+		cleanupOverdue,
+		cleanupExpired,
+		eventQueueDepth,
+		agentsInFlight,
+		tasksPending,
+	)
+}
+
+// ObserveLLMRequest records the outcome and latency of an LLM completion
+func ObserveLLMRequest(provider string, duration time.Duration, err error) {
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+	llmRequestsTotal.WithLabelValues(provider, status).Inc()
+	llmRequestDuration.WithLabelValues(provider).Observe(duration.Seconds())
+}
+
+// AddLLMTokens records prompt and completion token usage for a provider
+func AddLLMTokens(provider string, promptTokens, completionTokens int) {
+	if promptTokens > 0 {
+		llmTokensTotal.WithLabelValues(provider, "prompt").Add(float64(promptTokens))
+	}
+	if completionTokens > 0 {
+		llmTokensTotal.WithLabelValues(provider, "completion").Add(float64(completionTokens))
+	}
+}
+
+// ObserveAgentExecution records a finished agent execution
+func ObserveAgentExecution(taskType, status string, duration time.Duration) {
+	agentExecutionsTotal.WithLabelValues(taskType, status).Inc()
+	agentExecutionDuration.WithLabelValues(taskType).Observe(duration.Seconds())
+}
+
+// RecordRefinement counts an agent output routed back for refinement
+func RecordRefinement(taskType string) {
+	agentRefinementsTotal.WithLabelValues(taskType).Inc()
+}
+
+// ObserveValidation records a validation run and its scores
+func ObserveValidation(mode string, passed bool, overall, security, quality int) {
+	validationsTotal.WithLabelValues(mode, strconv.FormatBool(passed)).Inc()
+	validationScore.WithLabelValues("overall").Observe(float64(overall))
+	validationScore.WithLabelValues("security").Observe(float64(security))
+	validationScore.WithLabelValues("quality").Observe(float64(quality))
+}
+
+// ObserveDeployment records the duration of a deployment by final status
+func ObserveDeployment(status string, duration time.Duration) {
+	deploymentDuration.WithLabelValues(status).Observe(duration.Seconds())
+}
+
+// ObserveDeploymentCost records the actual cost of a deployment and whether
+// it exceeded the estimate enough to alert
+func ObserveDeploymentCost(actualUSD float64, alert bool) {
+	deploymentActualCost.Observe(actualUSD)
+	if alert {
+		deploymentCostAlertsTotal.Inc()
+	}
+}
+
+// ObserveCleanup records a resource group processed by the cleanup
+// controller; deleted groups add their estimated cost and how long they
+// outlived their TTL
+func ObserveCleanup(status string, costUSD float64, overdue time.Duration) {
+	cleanupResourceGroupsTotal.WithLabelValues(status).Inc()
+	if status == "success" {
+		cleanupCostReclaimed.Add(costUSD)
+		cleanupOverdue.Observe(max(overdue, 0).Seconds())
+	}
+}
+
+// SetExpiredResourceGroups records the expired resource groups found by a
+// cleanup sweep
+func SetExpiredResourceGroups(count int) {
+	cleanupExpired.Set(float64(count))
+}
+
+// SetEventQueueDepth records the number of undispatched events
+func SetEventQueueDepth(depth int) {
+	eventQueueDepth.Set(float64(depth))
+}
+
+// SetAgentLoad records running agent executions and tasks waiting for one
+func SetAgentLoad(inFlight, pending int) {
+	agentsInFlight.Set(float64(inFlight))
+	tasksPending.Set(float64(pending))
+}
+
+// Handler returns the /metrics HTTP handler for the shared registry
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// InstrumentHandler wraps an HTTP handler with latency and status code metrics
+func InstrumentHandler(name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		httpRequestsTotal.WithLabelValues(name, r.Method, strconv.Itoa(rec.status)).Inc()
+		httpRequestDuration.WithLabelValues(name, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// StartServer serves /metrics, plus any extra routes, on addr until ctx is cancelled
+func StartServer(ctx context.Context, addr string, routes map[string]http.Handler) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", InstrumentHandler("metrics", Handler()))
+	for pattern, handler := range routes {
+		mux.Handle(pattern, InstrumentHandler(pattern, handler))
+	}
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	logger.WithComponent("metrics").Info("Metrics server listening",
+		zap.String("addr", addr))
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush lets streaming handlers, such as server-sent events, flush through
+// the recorder
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Constraints are organization standards an intent must follow. They are
+// threaded into task decomposition and agent prompts and checked against the
+// generated files.
+type Constraints struct {
+	Languages   []string `json:"languages,omitempty"`   // allowed implementation languages, e.g. "go"
+	Frameworks  []string `json:"frameworks,omitempty"`  // preferred frameworks, e.g. "gin"
+	Cloud       string   `json:"cloud,omitempty"`       // target cloud: azure, aws or gcp
+	Naming      string   `json:"naming,omitempty"`      // source file naming: snake_case, kebab-case, camelCase, PascalCase
+	Forbidden   []string `json:"forbidden,omitempty"`   // technologies that must not appear
+	Notes       []string `json:"notes,omitempty"`       // free-form standards passed to agents
+	Enforcement string   `json:"enforcement,omitempty"` // "warn" (default) or "strict"
+}
+
+const (
+	EnforcementWarn   = "warn"
+	EnforcementStrict = "strict"
+)
+
+// IsZero reports whether no constraint is set
+func (c *Constraints) IsZero() bool {
+	return c == nil || (len(c.Languages) == 0 && len(c.Frameworks) == 0 && c.Cloud == "" &&
+		c.Naming == "" && len(c.Forbidden) == 0 && len(c.Notes) == 0)
+}
+
+// Strict reports whether violations should fail the intent
+func (c *Constraints) Strict() bool {
+	return c != nil && strings.EqualFold(c.Enforcement, EnforcementStrict)
+}
+
+// Merge returns c with unset fields taken from defaults. Lists set on the
+// intent replace the defaults rather than extending them; notes accumulate.
+func (c *Constraints) Merge(defaults *Constraints) *Constraints {
+	if c == nil && defaults == nil {
+		return nil
+	}
+	merged := &Constraints{}
+	if defaults != nil {
+		*merged = *defaults
+		merged.Notes = append([]string{}, defaults.Notes...)
+	}
+	if c == nil {
+		return merged
+	}
+
+	if len(c.Languages) > 0 {
+		merged.Languages = c.Languages
+	}
+	if len(c.Frameworks) > 0 {
+		merged.Frameworks = c.Frameworks
+	}
+	if c.Cloud != "" {
+		merged.Cloud = c.Cloud
+	}
+	if c.Naming != "" {
+		merged.Naming = c.Naming
+	}
+	if len(c.Forbidden) > 0 {
+		merged.Forbidden = c.Forbidden
+	}
+	if c.Enforcement != "" {
+		merged.Enforcement = c.Enforcement
+	}
+	merged.Notes = append(merged.Notes, c.Notes...)
+	return merged
+}
+
+// Standards renders the constraints as instructions for prompts
+func (c *Constraints) Standards() []string {
+	if c.IsZero() {
+		return nil
+	}
+
+	var standards []string
+	if len(c.Languages) > 0 {
+		standards = append(standards, fmt.Sprintf("Use only these languages: %s", strings.Join(c.Languages, ", ")))
+	}
+	if len(c.Frameworks) > 0 {
+		standards = append(standards, fmt.Sprintf("Use these frameworks: %s", strings.Join(c.Frameworks, ", ")))
+	}
+	if c.Cloud != "" {
+		standards = append(standards, fmt.Sprintf("Target cloud provider: %s (do not use other providers' services)", c.Cloud))
+	}
+	if c.Naming != "" {
+		standards = append(standards, fmt.Sprintf("Name source files using %s", c.Naming))
+	}
+	if len(c.Forbidden) > 0 {
+		standards = append(standards, fmt.Sprintf("Do not use: %s", strings.Join(c.Forbidden, ", ")))
+	}
+	standards = append(standards, c.Notes...)
+	return standards
+}
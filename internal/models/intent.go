@@ -9,6 +9,7 @@ type Intent struct {
 	UserInput       string            `json:"user_input"`
 	Tasks           []Task            `json:"tasks"` // Renamed from ParsedTasks
 	Metadata        map[string]string `json:"metadata"`
+	Constraints     *Constraints      `json:"constraints,omitempty"`
 	Status          IntentStatus      `json:"status"`
 	OverallScore    int               `json:"overall_score"`
 	ExecutionTimeMS int               `json:"execution_time_ms"`
@@ -23,6 +24,7 @@ const (
 	IntentStatusPending    IntentStatus = "pending"
 	IntentStatusProcessing IntentStatus = "processing"
 	IntentStatusCompleted  IntentStatus = "completed"
+	IntentStatusPartial    IntentStatus = "partial" // Packaged without the tasks that failed
 	IntentStatusFailed     IntentStatus = "failed"
 )
 
@@ -35,6 +37,7 @@ type Task struct {
 	Metadata     map[string]string `json:"metadata"`
 	Status       TaskStatus        `json:"status"`
 	AgentID      string            `json:"agent_id,omitempty"`
+	Error        string            `json:"error,omitempty"` // Why the task failed or was skipped
 	CreatedAt    time.Time         `json:"created_at"`
 	CompletedAt  *time.Time        `json:"completed_at,omitempty"`
 }
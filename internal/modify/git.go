@@ -0,0 +1,132 @@
+package modify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"QLP/internal/capsulediff"
+)
+
+// BranchOptions control how a result is exported to git
+type BranchOptions struct {
+	Branch      string // default qlp/<slug of the title>
+	Base        string // branch or tag to start from, default the remote's HEAD
+	Push        bool   // push the branch to the source repository
+	AuthorName  string
+	AuthorEmail string
+}
+
+// Branch is an exported result
+type Branch struct {
+	Name   string `json:"name"`
+	Commit string `json:"commit"`
+	Pushed bool   `json:"pushed"`
+	Remote string `json:"remote"`
+	Patch  string `json:"-"` // the commit as git format-patch output
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// BranchName derives a branch name from a pull request title
+func BranchName(title string) string {
+	slug := strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if len(slug) > 50 {
+		slug = strings.TrimRight(slug[:50], "-")
+	}
+	if slug == "" {
+		slug = "change"
+	}
+	return "qlp/" + slug
+}
+
+// ExportBranch commits the result on a new branch of a fresh clone of repo
+// (a local path or URL) and, when requested, pushes it back. The user's
+// working tree is never touched.
+func ExportBranch(ctx context.Context, repo string, r *Result, opts BranchOptions) (*Branch, error) {
+	title, body := r.PullRequest()
+	if opts.Branch == "" {
+		opts.Branch = BranchName(title)
+	}
+	if opts.AuthorName == "" {
+		opts.AuthorName = "QuantumLayer"
+	}
+	if opts.AuthorEmail == "" {
+		opts.AuthorEmail = "qlp@localhost"
+	}
+
+	dir, err := os.MkdirTemp("", "qlp-modify-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	args := []string{"clone", "--quiet"}
+	if opts.Base != "" {
+		args = append(args, "--branch", opts.Base)
+	}
+	if _, err := git(ctx, "", append(args, "--", repo, dir)...); err != nil {
+		return nil, err
+	}
+	if _, err := git(ctx, dir, "checkout", "--quiet", "-b", opts.Branch); err != nil {
+		return nil, err
+	}
+
+	for _, c := range r.Diff.Changes {
+		p := filepath.Join(dir, filepath.FromSlash(c.Path))
+		if c.Kind == capsulediff.ChangeRemoved {
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(p, []byte(r.Files[c.Path]), 0644); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := git(ctx, dir, "add", "-A"); err != nil {
+		return nil, err
+	}
+	if _, err := git(ctx, dir,
+		"-c", "user.name="+opts.AuthorName, "-c", "user.email="+opts.AuthorEmail,
+		"commit", "--quiet", "-m", title, "-m", body); err != nil {
+		return nil, err
+	}
+
+	branch := &Branch{Name: opts.Branch, Remote: repo}
+	if branch.Commit, err = git(ctx, dir, "rev-parse", "HEAD"); err != nil {
+		return nil, err
+	}
+	if branch.Patch, err = git(ctx, dir, "format-patch", "-1", "--stdout"); err != nil {
+		return nil, err
+	}
+	branch.Patch += "\n"
+	if opts.Push {
+		if _, err := git(ctx, dir, "push", "--quiet", "origin", opts.Branch); err != nil {
+			return nil, err
+		}
+		branch.Pushed = true
+	}
+	return branch, nil
+}
+
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,405 @@
+// Package modify applies change intents ("add rate limiting middleware to
+// this API") to existing code. The agent proposes search/replace edits
+// against an imported repository, the changed project runs its test suites
+// in the sandbox, and changes that break the build or add test failures are
+// revised with the test output. The result is a patch that can be exported
+// as a git branch with a pull request description.
+package modify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"QLP/internal/capsulediff"
+	"QLP/internal/config"
+	"QLP/internal/llm"
+	"QLP/internal/llm/jsonutil"
+	"QLP/internal/logger"
+	"QLP/internal/sandbox"
+
+	"go.uber.org/zap"
+)
+
+// ErrEditNotFound is returned when an edit's search text does not occur
+// exactly once in its file
+var ErrEditNotFound = errors.New("edit does not match the file")
+
+// Change actions
+const (
+	ActionEdit   = "edit"
+	ActionCreate = "create"
+	ActionDelete = "delete"
+)
+
+// Edit replaces the single occurrence of Search with Replace
+type Edit struct {
+	Search  string `json:"search"`
+	Replace string `json:"replace"`
+}
+
+// Change is one file of a plan: edits to an existing file, a new file's
+// content, or a deletion
+type Change struct {
+	Path    string `json:"path"`
+	Action  string `json:"action"`
+	Edits   []Edit `json:"edits,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// Plan is the agent's proposed modification
+type Plan struct {
+	Title   string   `json:"title"`
+	Summary string   `json:"summary"`
+	Changes []Change `json:"changes"`
+}
+
+// Apply returns files with plan applied, leaving files untouched
+func Apply(files map[string]string, plan *Plan) (map[string]string, error) {
+	out := make(map[string]string, len(files))
+	for p, content := range files {
+		out[p] = content
+	}
+	for _, c := range plan.Changes {
+		p := path.Clean(strings.TrimPrefix(c.Path, "/"))
+		if p == "." || strings.HasPrefix(p, "../") {
+			return nil, fmt.Errorf("invalid path %q", c.Path)
+		}
+		content, exists := out[p]
+		switch c.Action {
+		case ActionCreate:
+			if exists {
+				return nil, fmt.Errorf("%s already exists; edit it instead", p)
+			}
+			out[p] = c.Content
+		case ActionDelete:
+			if !exists {
+				return nil, fmt.Errorf("cannot delete %s: no such file", p)
+			}
+			delete(out, p)
+		case ActionEdit:
+			if !exists {
+				return nil, fmt.Errorf("cannot edit %s: no such file", p)
+			}
+			for i, e := range c.Edits {
+				switch n := strings.Count(content, e.Search); {
+				case e.Search == "" || n == 0:
+					return nil, fmt.Errorf("%w: %s edit %d search text not found", ErrEditNotFound, p, i+1)
+				case n > 1:
+					return nil, fmt.Errorf("%w: %s edit %d search text occurs %d times", ErrEditNotFound, p, i+1, n)
+				}
+				content = strings.Replace(content, e.Search, e.Replace, 1)
+			}
+			out[p] = content
+		default:
+			return nil, fmt.Errorf("%s: unknown action %q", p, c.Action)
+		}
+	}
+	return out, nil
+}
+
+// Runner runs the test suites of a file set
+type Runner interface {
+	Run(ctx context.Context, files map[string]string) []sandbox.TestRun
+}
+
+// Config bounds the plan-apply-test loop
+type Config struct {
+	MaxIterations   int // plans including the first one
+	MaxContextFiles int // files shown to the agent in full
+	MaxContextBytes int
+}
+
+// DefaultConfig returns the default limits
+func DefaultConfig() Config {
+	return Config{MaxIterations: 3, MaxContextFiles: 12, MaxContextBytes: 60000}
+}
+
+// ConfigFromEnv reads QLP_MODIFY_MAX_ITERATIONS and
+// QLP_MODIFY_CONTEXT_FILES, keeping the defaults for invalid values
+func ConfigFromEnv() Config {
+	cfg := DefaultConfig()
+	if v, err := strconv.Atoi(config.GetEnvOrDefault("QLP_MODIFY_MAX_ITERATIONS", "")); err == nil && v > 0 {
+		cfg.MaxIterations = v
+	}
+	if v, err := strconv.Atoi(config.GetEnvOrDefault("QLP_MODIFY_CONTEXT_FILES", "")); err == nil && v > 0 {
+		cfg.MaxContextFiles = v
+	}
+	return cfg
+}
+
+// Result is the modification and how it fared in the sandbox
+type Result struct {
+	Intent     string              `json:"intent"`
+	Plan       *Plan               `json:"plan"`
+	Diff       *capsulediff.Result `json:"diff"`
+	Files      map[string]string   `json:"-"` // the modified project
+	Baseline   []sandbox.TestRun   `json:"baseline,omitempty"`
+	Runs       []sandbox.TestRun   `json:"runs,omitempty"`
+	Iterations int                 `json:"iterations"`
+	// Verified is false when no suite could run, so the change was not tested
+	Verified bool `json:"verified"`
+	// Passed is true when every suite builds and fails no more tests than before the change
+	Passed bool `json:"passed"`
+}
+
+// Patch is the change as a unified diff
+func (r *Result) Patch() string {
+	return r.Diff.UnifiedDiff()
+}
+
+// Agent plans and verifies modifications
+type Agent struct {
+	llmClient llm.Client
+	runner    Runner
+	config    Config
+}
+
+// NewAgent creates a modification agent. A nil runner skips the sandbox runs.
+func NewAgent(llmClient llm.Client, runner Runner, cfg Config) *Agent {
+	return &Agent{llmClient: llmClient, runner: runner, config: cfg}
+}
+
+// Modify plans the change intent against files, applies it and runs the
+// tests, revising the plan until it passes or the iterations run out
+func (a *Agent) Modify(ctx context.Context, intent string, files map[string]string) (*Result, error) {
+	result := &Result{Intent: intent}
+	if a.runner != nil {
+		result.Baseline = a.runner.Run(ctx, files)
+	}
+
+	shown := relevantFiles(files, intent, a.config.MaxContextFiles, a.config.MaxContextBytes)
+	var previous *Plan
+	var feedback string
+	for result.Iterations < a.config.MaxIterations {
+		result.Iterations++
+		plan, err := a.plan(ctx, intent, files, shown, previous, feedback)
+		if err != nil {
+			return nil, err
+		}
+		modified, err := Apply(files, plan)
+		if err != nil {
+			logger.WithComponent("modify").Warn("Plan did not apply", zap.Int("iteration", result.Iterations), zap.Error(err))
+			previous, feedback = plan, "The changes could not be applied: "+err.Error()
+			continue
+		}
+		result.Plan = plan
+		result.Files = modified
+		result.Diff = capsulediff.DiffFiles(files, modified, capsulediff.DefaultContextLines)
+
+		if a.runner == nil {
+			break
+		}
+		result.Runs = a.runner.Run(ctx, modified)
+		a.score(result)
+		if result.Passed || !result.Verified {
+			break
+		}
+		previous, feedback = plan, "The tests fail after the change:\n"+testOutput(result.Runs)
+	}
+	if result.Plan == nil {
+		return nil, fmt.Errorf("no applicable change after %d attempts: %s", result.Iterations, feedback)
+	}
+	if result.Diff.Identical() {
+		return nil, errors.New("the plan makes no changes")
+	}
+
+	logger.WithComponent("modify").Info("Modification planned",
+		zap.String("title", result.Plan.Title),
+		zap.Int("files_changed", len(result.Diff.Changes)),
+		zap.Int("iterations", result.Iterations),
+		zap.Bool("verified", result.Verified),
+		zap.Bool("passed", result.Passed))
+	return result, nil
+}
+
+// score compares the runs with the baseline: every suite must build, and
+// suites may not fail more tests than before
+func (a *Agent) score(result *Result) {
+	baseline := make(map[string]sandbox.TestRun)
+	for _, run := range result.Baseline {
+		baseline[string(run.Ecosystem)+":"+run.Dir] = run
+	}
+	result.Verified = false
+	result.Passed = true
+	for _, run := range result.Runs {
+		if run.Output != "" {
+			result.Verified = true
+		}
+		before, ok := baseline[string(run.Ecosystem)+":"+run.Dir]
+		switch {
+		case run.Error != "" && !(ok && before.Error != ""):
+			result.Passed = false
+		case run.Failed > before.Failed:
+			result.Passed = false
+		}
+	}
+	if !result.Verified {
+		result.Passed = false
+	}
+}
+
+func testOutput(runs []sandbox.TestRun) string {
+	var b strings.Builder
+	for _, run := range runs {
+		fmt.Fprintf(&b, "[%s %s] %d passed, %d failed", run.Ecosystem, run.Dir, run.Passed, run.Failed)
+		if run.Error != "" {
+			fmt.Fprintf(&b, ", error: %s", run.Error)
+		}
+		fmt.Fprintf(&b, "\n%s\n", truncate(run.Output, 3000))
+	}
+	return b.String()
+}
+
+var planSchema = map[string]interface{}{
+	"type":     "object",
+	"required": []string{"title", "summary", "changes"},
+	"properties": map[string]interface{}{
+		"title":   map[string]interface{}{"type": "string"},
+		"summary": map[string]interface{}{"type": "string"},
+		"changes": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type":     "object",
+				"required": []string{"path", "action"},
+				"properties": map[string]interface{}{
+					"path":    map[string]interface{}{"type": "string"},
+					"action":  map[string]interface{}{"type": "string", "enum": []interface{}{ActionEdit, ActionCreate, ActionDelete}},
+					"content": map[string]interface{}{"type": "string"},
+					"edits": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type":     "object",
+							"required": []string{"search", "replace"},
+						},
+					},
+				},
+			},
+		},
+	},
+}
+
+func (a *Agent) plan(ctx context.Context, intent string, files map[string]string, shown []string, previous *Plan, feedback string) (*Plan, error) {
+	var plan Plan
+	prompt := planPrompt(intent, files, shown, previous, feedback)
+	if err := jsonutil.CompleteAndDecode(ctx, a.llmClient, prompt, planSchema, &plan, 2); err != nil {
+		return nil, err
+	}
+	if len(plan.Changes) == 0 {
+		return nil, errors.New("the plan has no changes")
+	}
+	return &plan, nil
+}
+
+func planPrompt(intent string, files map[string]string, shown []string, previous *Plan, feedback string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Modify an existing codebase to satisfy this change request:\n%s\n\n", intent)
+	b.WriteString("Make the smallest change that fully implements the request, following the project's existing structure, " +
+		"naming and error handling. Add or update tests for the new behavior. Do not reformat unrelated code.\n")
+
+	paths := sortedKeys(files)
+	if len(paths) > 300 {
+		paths = paths[:300]
+	}
+	fmt.Fprintf(&b, "\nProject files:\n%s\n", strings.Join(paths, "\n"))
+	for _, p := range shown {
+		fmt.Fprintf(&b, "\n=== %s ===\n%s\n", p, files[p])
+	}
+	if previous != nil {
+		data, _ := json.MarshalIndent(previous, "", "  ")
+		fmt.Fprintf(&b, "\nYour previous plan:\n%s\n\n%s\n\nRevise the plan to fix this.\n", truncate(string(data), 8000), truncate(feedback, 6000))
+	}
+	b.WriteString(`
+Return JSON with "title" (a pull request title), "summary" (what changed and why) and "changes": a list of
+{"path", "action": "edit"|"create"|"delete", "edits": [{"search", "replace"}], "content"}.
+For "edit", each "search" must be an exact, unique excerpt of the current file including whitespace, and
+"replace" its new text. For "create", "content" is the complete new file.`)
+	return b.String()
+}
+
+// entryPoints are always worth showing: manifests and typical main files
+var entryPoints = regexp.MustCompile(`(^|/)(go\.mod|package\.json|requirements\.txt|pyproject\.toml|pom\.xml|main\.(go|py)|app\.(py|js|ts)|server\.(go|js|ts)|index\.(js|ts)|routes?\.\w+|router\.\w+)$`)
+
+var words = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9]{3,}`)
+
+// relevantFiles picks the files to show in full: entry points and the files
+// that mention the intent's words most, within the byte budget
+func relevantFiles(files map[string]string, intent string, maxFiles, maxBytes int) []string {
+	var terms []string
+	for _, w := range words.FindAllString(strings.ToLower(intent), -1) {
+		if !stopWords[w] {
+			terms = append(terms, w)
+		}
+	}
+
+	type candidate struct {
+		path  string
+		score int
+	}
+	var candidates []candidate
+	for p, content := range files {
+		score := 0
+		if entryPoints.MatchString(p) {
+			score += 10
+		}
+		lowerPath, lowerContent := strings.ToLower(p), strings.ToLower(content)
+		for _, term := range terms {
+			if strings.Contains(lowerPath, term) {
+				score += 5
+			}
+			score += min(strings.Count(lowerContent, term), 5)
+		}
+		if score > 0 {
+			candidates = append(candidates, candidate{p, score})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].path < candidates[j].path
+	})
+
+	var picked []string
+	used := 0
+	for _, c := range candidates {
+		if len(picked) == maxFiles {
+			break
+		}
+		if used+len(files[c.path]) > maxBytes {
+			continue
+		}
+		picked = append(picked, c.path)
+		used += len(files[c.path])
+	}
+	sort.Strings(picked)
+	return picked
+}
+
+var stopWords = map[string]bool{
+	"this": true, "that": true, "with": true, "from": true, "into": true, "should": true, "have": true,
+	"each": true, "when": true, "make": true, "code": true, "file": true, "files": true, "add": true,
+	"support": true, "existing": true, "the": true, "and": true, "for": true,
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "\n... (truncated)"
+}
@@ -0,0 +1,198 @@
+package modify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"QLP/internal/capsulediff"
+	"QLP/internal/sandbox"
+)
+
+var project = map[string]string{
+	"go.mod":         "module api\n\ngo 1.22\n",
+	"main.go":        "package main\n\nfunc main() {\n\tserve(routes())\n}\n",
+	"routes.go":      "package main\n\nfunc routes() {}\n",
+	"store/store.go": "package store\n",
+	"docs/notes.txt": "unrelated\n",
+}
+
+func TestApply(t *testing.T) {
+	plan := &Plan{Changes: []Change{
+		{Path: "main.go", Action: ActionEdit, Edits: []Edit{{Search: "serve(routes())", Replace: "serve(rateLimit(routes()))"}}},
+		{Path: "ratelimit.go", Action: ActionCreate, Content: "package main\n"},
+		{Path: "docs/notes.txt", Action: ActionDelete},
+	}}
+	out, err := Apply(project, plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out["main.go"], "rateLimit(routes())") || out["ratelimit.go"] == "" {
+		t.Errorf("changes not applied: %v", out)
+	}
+	if _, ok := out["docs/notes.txt"]; ok {
+		t.Error("file not deleted")
+	}
+	if strings.Contains(project["main.go"], "rateLimit") {
+		t.Error("Apply modified its input")
+	}
+
+	for name, change := range map[string]Change{
+		"missing search":   {Path: "main.go", Action: ActionEdit, Edits: []Edit{{Search: "nope", Replace: "x"}}},
+		"ambiguous search": {Path: "routes.go", Action: ActionEdit, Edits: []Edit{{Search: "a", Replace: "x"}}},
+		"create existing":  {Path: "main.go", Action: ActionCreate, Content: "x"},
+		"delete missing":   {Path: "gone.go", Action: ActionDelete},
+		"escape":           {Path: "../etc/passwd", Action: ActionCreate, Content: "x"},
+		"unknown action":   {Path: "main.go", Action: "rename"},
+	} {
+		if _, err := Apply(project, &Plan{Changes: []Change{change}}); err == nil {
+			t.Errorf("%s: Apply succeeded", name)
+		}
+	}
+	if _, err := Apply(project, &Plan{Changes: []Change{{Path: "main.go", Action: ActionEdit, Edits: []Edit{{Search: "nope"}}}}}); !errors.Is(err, ErrEditNotFound) {
+		t.Errorf("error = %v, want ErrEditNotFound", err)
+	}
+}
+
+type scriptedClient struct {
+	plans   []*Plan
+	prompts []string
+}
+
+func (c *scriptedClient) Complete(ctx context.Context, prompt string) (string, error) {
+	c.prompts = append(c.prompts, prompt)
+	plan := c.plans[0]
+	c.plans = c.plans[1:]
+	data, _ := json.Marshal(plan)
+	return string(data), nil
+}
+
+func (c *scriptedClient) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	return nil, nil
+}
+
+type scriptedRunner struct {
+	runs [][]sandbox.TestRun
+}
+
+func (r *scriptedRunner) Run(ctx context.Context, files map[string]string) []sandbox.TestRun {
+	run := r.runs[0]
+	r.runs = r.runs[1:]
+	return run
+}
+
+func TestModifyRevisesAgainstErrorsAndTests(t *testing.T) {
+	wrong := &Plan{Title: "Rate limit", Changes: []Change{{Path: "main.go", Action: ActionEdit, Edits: []Edit{{Search: "missing", Replace: "x"}}}}}
+	breaks := &Plan{Title: "Rate limit", Changes: []Change{{Path: "ratelimit.go", Action: ActionCreate, Content: "package main\nbroken"}}}
+	fixed := &Plan{Title: "Add rate limiting", Summary: "Wraps the routes in a token bucket limiter.", Changes: []Change{
+		{Path: "ratelimit.go", Action: ActionCreate, Content: "package main\n\nfunc rateLimit() {}\n"},
+		{Path: "main.go", Action: ActionEdit, Edits: []Edit{{Search: "serve(routes())", Replace: "serve(rateLimit(routes()))"}}},
+	}}
+	client := &scriptedClient{plans: []*Plan{wrong, breaks, fixed}}
+	runner := &scriptedRunner{runs: [][]sandbox.TestRun{
+		{{Ecosystem: sandbox.EcosystemGo, Dir: ".", Passed: 4, Failed: 1, Output: "baseline"}},
+		{{Ecosystem: sandbox.EcosystemGo, Dir: ".", Error: "build failed", Output: "syntax error"}},
+		{{Ecosystem: sandbox.EcosystemGo, Dir: ".", Passed: 5, Failed: 1, Output: "ok"}},
+	}}
+
+	result, err := NewAgent(client, runner, DefaultConfig()).Modify(context.Background(), "add rate limiting middleware to this API", project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Iterations != 3 || !result.Verified || !result.Passed {
+		t.Fatalf("iterations = %d, verified = %v, passed = %v", result.Iterations, result.Verified, result.Passed)
+	}
+	if !strings.Contains(client.prompts[1], "could not be applied") || !strings.Contains(client.prompts[2], "syntax error") {
+		t.Error("revisions did not include the apply error and test output")
+	}
+	if !strings.Contains(client.prompts[0], "=== main.go ===") {
+		t.Error("entry point not shown to the agent")
+	}
+	if len(result.Diff.Changes) != 2 || !strings.Contains(result.Patch(), "+\tserve(rateLimit(routes()))") {
+		t.Errorf("patch = %s", result.Patch())
+	}
+
+	title, body := result.PullRequest()
+	if title != "Add rate limiting" {
+		t.Errorf("title = %q", title)
+	}
+	for _, want := range []string{"token bucket", "`ratelimit.go` | added", "4 passed, 1 failed", "5 passed, 1 failed"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("PR body missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestRelevantFiles(t *testing.T) {
+	files := map[string]string{
+		"main.go":               "package main",
+		"internal/auth/jwt.go":  "package auth // token validation",
+		"internal/billing/x.go": "package billing",
+		"README.md":             "docs",
+	}
+	got := relevantFiles(files, "Rotate the JWT signing token", 5, 1000)
+	want := "internal/auth/jwt.go,main.go"
+	if strings.Join(got, ",") != want {
+		t.Errorf("relevantFiles = %v, want %s", got, want)
+	}
+}
+
+func TestExportBranch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	ctx := context.Background()
+	repo := t.TempDir()
+	for p, content := range project {
+		full := filepath.Join(repo, filepath.FromSlash(p))
+		os.MkdirAll(filepath.Dir(full), 0755)
+		os.WriteFile(full, []byte(content), 0644)
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet", "--initial-branch", "main"},
+		{"add", "-A"},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "--quiet", "-m", "initial"},
+	} {
+		if _, err := git(ctx, repo, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plan := &Plan{Title: "Add rate limiting", Changes: []Change{
+		{Path: "ratelimit.go", Action: ActionCreate, Content: "package main\n"},
+		{Path: "docs/notes.txt", Action: ActionDelete},
+	}}
+	result := &Result{Intent: "add rate limiting", Plan: plan}
+	var err error
+	if result.Files, err = Apply(project, plan); err != nil {
+		t.Fatal(err)
+	}
+	result.Diff = diffOf(project, result.Files)
+
+	branch, err := ExportBranch(ctx, repo, result, BranchOptions{Push: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch.Name != "qlp/add-rate-limiting" || !branch.Pushed || !strings.Contains(branch.Patch, "Subject: [PATCH] Add rate limiting") {
+		t.Errorf("branch = %+v", branch)
+	}
+	files, err := git(ctx, repo, "ls-tree", "-r", "--name-only", branch.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(files, "ratelimit.go") || strings.Contains(files, "docs/notes.txt") {
+		t.Errorf("branch files = %s", files)
+	}
+	if head, _ := git(ctx, repo, "rev-parse", "--abbrev-ref", "HEAD"); head != "main" {
+		t.Errorf("source checkout moved to %s", head)
+	}
+}
+
+func diffOf(base, head map[string]string) *capsulediff.Result {
+	return capsulediff.DiffFiles(base, head, capsulediff.DefaultContextLines)
+}
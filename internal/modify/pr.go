@@ -0,0 +1,63 @@
+package modify
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PullRequest returns the title and Markdown body of a pull request for the result
+func (r *Result) PullRequest() (string, string) {
+	title := strings.TrimSpace(r.Plan.Title)
+	if title == "" {
+		title = r.Intent
+	}
+
+	var b strings.Builder
+	if r.Plan.Summary != "" {
+		fmt.Fprintf(&b, "%s\n\n", strings.TrimSpace(r.Plan.Summary))
+	}
+	fmt.Fprintf(&b, "**Change request:** %s\n\n", r.Intent)
+
+	fmt.Fprintf(&b, "## Changes (+%d −%d)\n\n| File | Change | + | − |\n|---|---|---:|---:|\n", r.Diff.Additions, r.Diff.Deletions)
+	for _, c := range r.Diff.Changes {
+		fmt.Fprintf(&b, "| `%s` | %s | %d | %d |\n", c.Path, c.Kind, c.Additions, c.Deletions)
+	}
+
+	b.WriteString("\n## Validation\n\n")
+	switch {
+	case len(r.Runs) == 0:
+		b.WriteString("The test suites were not run.\n")
+	case !r.Verified:
+		b.WriteString("⚠️ The test suites could not be run in the sandbox; the change is untested.\n")
+	default:
+		if r.Passed {
+			b.WriteString("✅ Every suite builds and no new test failures were introduced.\n\n")
+		} else {
+			b.WriteString("❌ The change breaks the build or adds test failures; review before merging.\n\n")
+		}
+		b.WriteString("| Suite | Before | After |\n|---|---|---|\n")
+		before := make(map[string]string)
+		for _, run := range r.Baseline {
+			before[string(run.Ecosystem)+":"+run.Dir] = runSummary(run.Passed, run.Failed, run.Error)
+		}
+		for _, run := range r.Runs {
+			key := string(run.Ecosystem) + ":" + run.Dir
+			prior := before[key]
+			if prior == "" {
+				prior = "—"
+			}
+			fmt.Fprintf(&b, "| %s `%s` | %s | %s |\n", run.Ecosystem, run.Dir, prior, runSummary(run.Passed, run.Failed, run.Error))
+		}
+	}
+	if r.Iterations > 1 {
+		fmt.Fprintf(&b, "\nThe plan was revised %d time(s) against apply errors and test output.\n", r.Iterations-1)
+	}
+	return title, b.String()
+}
+
+func runSummary(passed, failed int, err string) string {
+	if err != "" {
+		return "did not run"
+	}
+	return fmt.Sprintf("%d passed, %d failed", passed, failed)
+}
@@ -0,0 +1,214 @@
+package netpol
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy is a networking.k8s.io/v1 NetworkPolicy
+type Policy struct {
+	APIVersion string     `yaml:"apiVersion"`
+	Kind       string     `yaml:"kind"`
+	Metadata   Metadata   `yaml:"metadata"`
+	Spec       PolicySpec `yaml:"spec"`
+}
+
+// Metadata is the object metadata of a policy
+type Metadata struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+// PolicySpec selects the pods a policy applies to and the traffic it allows
+type PolicySpec struct {
+	PodSelector Selector `yaml:"podSelector"`
+	PolicyTypes []string `yaml:"policyTypes,omitempty"`
+	Ingress     []Rule   `yaml:"ingress,omitempty"`
+	Egress      []Rule   `yaml:"egress,omitempty"`
+}
+
+// Selector is a label selector, empty selecting every pod
+type Selector struct {
+	MatchLabels      map[string]string `yaml:"matchLabels,omitempty"`
+	MatchExpressions []yaml.Node       `yaml:"matchExpressions,omitempty"`
+}
+
+// Rule allows traffic from or to its peers on its ports, any peer or port
+// when they are left out
+type Rule struct {
+	From  []Peer       `yaml:"from,omitempty"`
+	To    []Peer       `yaml:"to,omitempty"`
+	Ports []PolicyPort `yaml:"ports,omitempty"`
+}
+
+// Peer is a set of pods or an IP range
+type Peer struct {
+	PodSelector       *Selector `yaml:"podSelector,omitempty"`
+	NamespaceSelector *Selector `yaml:"namespaceSelector,omitempty"`
+	IPBlock           *IPBlock  `yaml:"ipBlock,omitempty"`
+}
+
+// IPBlock is a CIDR range
+type IPBlock struct {
+	CIDR   string   `yaml:"cidr"`
+	Except []string `yaml:"except,omitempty"`
+}
+
+// PolicyPort is a port and protocol traffic is allowed on
+type PolicyPort struct {
+	Protocol string `yaml:"protocol,omitempty"`
+	Port     int    `yaml:"port"`
+}
+
+// Workload is a Deployment, StatefulSet, DaemonSet or CronJob of the
+// project with the labels of its pods and the ports its containers listen on
+type Workload struct {
+	Name      string
+	Namespace string
+	Labels    map[string]string
+	Ports     []PolicyPort
+	File      string
+}
+
+// Empty reports whether s selects every pod
+func (s Selector) Empty() bool {
+	return len(s.MatchLabels) == 0 && len(s.MatchExpressions) == 0
+}
+
+// Selects reports whether s matches pods labelled labels. Selectors with
+// match expressions are assumed to match.
+func (s Selector) Selects(labels map[string]string) bool {
+	if len(s.MatchExpressions) > 0 {
+		return true
+	}
+	for k, v := range s.MatchLabels {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Types returns the policy types of p, defaulted the way Kubernetes does:
+// Ingress, plus Egress when the policy has egress rules
+func (p Policy) Types() []string {
+	if len(p.Spec.PolicyTypes) > 0 {
+		return p.Spec.PolicyTypes
+	}
+	types := []string{"Ingress"}
+	if len(p.Spec.Egress) > 0 {
+		types = append(types, "Egress")
+	}
+	return types
+}
+
+type podTemplate struct {
+	Metadata struct {
+		Labels map[string]string `yaml:"labels"`
+	} `yaml:"metadata"`
+	Spec struct {
+		Containers []struct {
+			Ports []struct {
+				ContainerPort int    `yaml:"containerPort"`
+				Protocol      string `yaml:"protocol"`
+			} `yaml:"ports"`
+		} `yaml:"containers"`
+	} `yaml:"spec"`
+}
+
+type object struct {
+	Kind     string   `yaml:"kind"`
+	Metadata Metadata `yaml:"metadata"`
+	Spec     struct {
+		Selector    Selector    `yaml:"selector"`
+		Template    podTemplate `yaml:"template"`
+		JobTemplate struct {
+			Spec struct {
+				Template podTemplate `yaml:"template"`
+			} `yaml:"spec"`
+		} `yaml:"jobTemplate"`
+	} `yaml:"spec"`
+}
+
+// Workloads returns the workloads the project's manifests deploy
+func Workloads(files map[string]string) []Workload {
+	var workloads []Workload
+	eachObject(files, func(file string, doc *yaml.Node, kind string) {
+		switch kind {
+		case "Deployment", "StatefulSet", "DaemonSet", "CronJob":
+		default:
+			return
+		}
+		var obj object
+		if doc.Decode(&obj) != nil || obj.Metadata.Name == "" {
+			return
+		}
+		template := obj.Spec.Template
+		if kind == "CronJob" {
+			template = obj.Spec.JobTemplate.Spec.Template
+		}
+		w := Workload{Name: obj.Metadata.Name, Namespace: obj.Metadata.Namespace, File: file, Labels: template.Metadata.Labels}
+		if len(w.Labels) == 0 {
+			w.Labels = obj.Spec.Selector.MatchLabels
+		}
+		if len(w.Labels) == 0 {
+			w.Labels = map[string]string{"app": w.Name}
+		}
+		for _, c := range template.Spec.Containers {
+			for _, p := range c.Ports {
+				if p.ContainerPort == 0 {
+					continue
+				}
+				protocol := p.Protocol
+				if protocol == "" {
+					protocol = "TCP"
+				}
+				w.Ports = append(w.Ports, PolicyPort{Protocol: protocol, Port: p.ContainerPort})
+			}
+		}
+		workloads = append(workloads, w)
+	})
+	return workloads
+}
+
+// Policies returns the NetworkPolicies in the project's manifests
+func Policies(files map[string]string) []Policy {
+	var policies []Policy
+	eachObject(files, func(_ string, doc *yaml.Node, kind string) {
+		var p Policy
+		if kind == "NetworkPolicy" && doc.Decode(&p) == nil {
+			policies = append(policies, p)
+		}
+	})
+	return policies
+}
+
+// eachObject calls fn with every object in the YAML files, in file order
+func eachObject(files map[string]string, fn func(file string, doc *yaml.Node, kind string)) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if ext := path.Ext(name); ext == ".yaml" || ext == ".yml" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		dec := yaml.NewDecoder(strings.NewReader(files[name]))
+		for {
+			var doc yaml.Node
+			if err := dec.Decode(&doc); err != nil {
+				break
+			}
+			var head struct {
+				Kind string `yaml:"kind"`
+			}
+			if doc.Decode(&head) == nil && head.Kind != "" {
+				fn(name, &doc, head.Kind)
+			}
+		}
+	}
+}
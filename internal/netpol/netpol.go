@@ -0,0 +1,212 @@
+// Package netpol gives generated Kubernetes manifests NetworkPolicies: a
+// default deny of all ingress and egress per namespace, DNS egress, and
+// explicit allows for the traffic the project's architecture implies.
+package netpol
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"QLP/internal/archdocs"
+)
+
+// File is where the generated policies go, next to the project's manifests
+const File = "network-policies.yaml"
+
+// Names of the namespace-wide policies
+const (
+	DefaultDenyName = "default-deny-all"
+	AllowDNSName    = "allow-dns-egress"
+)
+
+// externalPorts are the ports of backing services the sources talk to but
+// the project does not deploy, reached outside the cluster
+var externalPorts = map[string]int{
+	"PostgreSQL":    5432,
+	"MySQL":         3306,
+	"MongoDB":       27017,
+	"Redis":         6379,
+	"Elasticsearch": 9200,
+	"Kafka":         9092,
+	"RabbitMQ":      5672,
+	"NATS":          4222,
+}
+
+const header = `# NetworkPolicies generated by QuantumLayer. Every namespace denies all
+# ingress and egress except DNS; each workload is allowed the traffic its
+# dependencies imply. Add egress rules for external endpoints the services
+# call, e.g. third-party APIs.
+`
+
+// Generate returns the policies for the workloads in the project's
+// manifests, nil when it deploys none
+func Generate(project string, files map[string]string) []Policy {
+	workloads := Workloads(files)
+	if len(workloads) == 0 {
+		return nil
+	}
+	byName := make(map[string]Workload)
+	var namespaces []string
+	for _, w := range workloads {
+		byName[w.Name] = w
+		if !contains(namespaces, w.Namespace) {
+			namespaces = append(namespaces, w.Namespace)
+		}
+	}
+	sort.Strings(namespaces)
+
+	var policies []Policy
+	for _, ns := range namespaces {
+		policies = append(policies, DefaultDeny(ns)...)
+	}
+
+	arch := archdocs.ExtractArchitecture(project, files)
+	components := make(map[string]archdocs.Component)
+	for _, c := range arch.Components {
+		components[c.ID] = c
+	}
+	for _, w := range workloads {
+		self := componentID(arch, w.Name)
+		var spec PolicySpec
+		called := false
+		for _, e := range arch.Edges {
+			switch self {
+			case e.To:
+				called = true
+				if caller, ok := byName[components[e.From].Label]; ok {
+					spec.Ingress = append(spec.Ingress, Rule{From: []Peer{peer(caller, w.Namespace)}, Ports: w.Ports})
+				}
+			case e.From:
+				target := components[e.To]
+				if dep, ok := byName[target.Label]; ok {
+					spec.Egress = append(spec.Egress, Rule{To: []Peer{peer(dep, w.Namespace)}, Ports: dep.Ports})
+				} else if port, ok := externalPorts[target.Label]; ok {
+					spec.Egress = append(spec.Egress, Rule{
+						To:    []Peer{{IPBlock: &IPBlock{CIDR: "0.0.0.0/0"}}},
+						Ports: []PolicyPort{{Protocol: "TCP", Port: port}},
+					})
+				}
+			}
+		}
+		// Services nothing else calls are the project's entry points
+		if !called && components[self].Kind == archdocs.KindService && len(w.Ports) > 0 {
+			spec.Ingress = append(spec.Ingress, Rule{Ports: w.Ports})
+		}
+		if len(spec.Ingress) > 0 {
+			spec.PolicyTypes = append(spec.PolicyTypes, "Ingress")
+		}
+		if len(spec.Egress) > 0 {
+			spec.PolicyTypes = append(spec.PolicyTypes, "Egress")
+		}
+		if len(spec.PolicyTypes) == 0 {
+			continue
+		}
+		spec.PodSelector = Selector{MatchLabels: w.Labels}
+		policies = append(policies, newPolicy(w.Name+"-traffic", w.Namespace, spec))
+	}
+	return policies
+}
+
+// DefaultDeny returns the policies denying all traffic in namespace except
+// DNS lookups
+func DefaultDeny(namespace string) []Policy {
+	dns := []PolicyPort{{Protocol: "UDP", Port: 53}, {Protocol: "TCP", Port: 53}}
+	return []Policy{
+		newPolicy(DefaultDenyName, namespace, PolicySpec{PolicyTypes: []string{"Ingress", "Egress"}}),
+		newPolicy(AllowDNSName, namespace, PolicySpec{
+			PolicyTypes: []string{"Egress"},
+			Egress: []Rule{{
+				To: []Peer{{
+					NamespaceSelector: &Selector{MatchLabels: map[string]string{namespaceLabel: "kube-system"}},
+					PodSelector:       &Selector{MatchLabels: map[string]string{"k8s-app": "kube-dns"}},
+				}},
+				Ports: dns,
+			}},
+		}),
+	}
+}
+
+// Render writes policies as a multi-document manifest
+func Render(policies []Policy) (string, error) {
+	var b strings.Builder
+	b.WriteString(header)
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	for _, p := range policies {
+		if err := enc.Encode(p); err != nil {
+			return "", fmt.Errorf("rendering network policy %s: %w", p.Metadata.Name, err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Inject returns the policies file for a project whose manifests deploy
+// workloads without any NetworkPolicy, nil otherwise
+func Inject(project string, files map[string]string) (map[string]string, error) {
+	if len(Policies(files)) > 0 {
+		return nil, nil
+	}
+	policies := Generate(project, files)
+	if len(policies) == 0 {
+		return nil, nil
+	}
+	content, err := Render(policies)
+	if err != nil {
+		return nil, err
+	}
+	file := path.Join(path.Dir(Workloads(files)[0].File), File)
+	if _, exists := files[file]; exists {
+		file = path.Join(path.Dir(file), "qlp-"+File)
+	}
+	return map[string]string{file: content}, nil
+}
+
+func newPolicy(name, namespace string, spec PolicySpec) Policy {
+	return Policy{
+		APIVersion: "networking.k8s.io/v1",
+		Kind:       "NetworkPolicy",
+		Metadata: Metadata{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "quantumlayer"},
+		},
+		Spec: spec,
+	}
+}
+
+// namespaceLabel is set by Kubernetes on every namespace to its name
+const namespaceLabel = "kubernetes.io/metadata.name"
+
+// peer selects w's pods from a policy in namespace
+func peer(w Workload, namespace string) Peer {
+	p := Peer{PodSelector: &Selector{MatchLabels: w.Labels}}
+	if w.Namespace != namespace && w.Namespace != "" {
+		p.NamespaceSelector = &Selector{MatchLabels: map[string]string{namespaceLabel: w.Namespace}}
+	}
+	return p
+}
+
+func componentID(arch archdocs.Architecture, name string) string {
+	for _, c := range arch.Components {
+		if c.Label == name {
+			return c.ID
+		}
+	}
+	return ""
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package netpol
+
+import (
+	"strings"
+	"testing"
+)
+
+var manifests = map[string]string{
+	"k8s/api.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+  namespace: shop
+spec:
+  template:
+    metadata:
+      labels:
+        app: api
+    spec:
+      containers:
+      - name: api
+        ports:
+        - containerPort: 8080
+        env:
+        - name: DATABASE_URL
+          value: postgres://app@postgres:5432/shop
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: api
+  namespace: shop
+`,
+	"k8s/postgres.yaml": `apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: postgres
+  namespace: shop
+spec:
+  selector:
+    matchLabels:
+      app: postgres
+  template:
+    spec:
+      containers:
+      - name: postgres
+        ports:
+        - containerPort: 5432
+`,
+	"main.go": `import "github.com/redis/go-redis/v9"`,
+}
+
+func TestWorkloads(t *testing.T) {
+	workloads := Workloads(manifests)
+	if len(workloads) != 2 {
+		t.Fatalf("expected 2 workloads, got %+v", workloads)
+	}
+	db := workloads[1]
+	if db.Name != "postgres" || db.Labels["app"] != "postgres" || db.Ports[0] != (PolicyPort{Protocol: "TCP", Port: 5432}) {
+		t.Errorf("expected the selector labels and container port of postgres, got %+v", db)
+	}
+}
+
+func TestGenerateAllowsArchitectureTraffic(t *testing.T) {
+	policies := Generate("shop", manifests)
+	byName := make(map[string]Policy)
+	for _, p := range policies {
+		byName[p.Metadata.Name] = p
+	}
+	if len(policies) != 4 {
+		t.Fatalf("expected default deny, DNS and one policy per workload, got %d", len(policies))
+	}
+	if deny := byName[DefaultDenyName]; !deny.Spec.PodSelector.Empty() || len(deny.Spec.PolicyTypes) != 2 || deny.Metadata.Namespace != "shop" {
+		t.Errorf("expected a namespace-wide deny of ingress and egress, got %+v", deny)
+	}
+
+	api := byName["api-traffic"].Spec
+	if len(api.Ingress) != 1 || api.Ingress[0].From != nil || api.Ingress[0].Ports[0].Port != 8080 {
+		t.Errorf("expected the entry service open on its port, got %+v", api.Ingress)
+	}
+	if len(api.Egress) != 2 || api.Egress[0].To[0].PodSelector.MatchLabels["app"] != "postgres" || api.Egress[0].Ports[0].Port != 5432 {
+		t.Errorf("expected egress to postgres, got %+v", api.Egress)
+	}
+	if redis := api.Egress[1]; redis.To[0].IPBlock == nil || redis.Ports[0].Port != 6379 {
+		t.Errorf("expected egress to the external Redis, got %+v", redis)
+	}
+
+	db := byName["postgres-traffic"].Spec
+	if len(db.Ingress) != 1 || db.Ingress[0].From[0].PodSelector.MatchLabels["app"] != "api" || len(db.Egress) != 0 {
+		t.Errorf("expected postgres reachable from api only, got %+v", db)
+	}
+}
+
+func TestInject(t *testing.T) {
+	added, err := Inject("shop", manifests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, ok := added["k8s/"+File]
+	if !ok {
+		t.Fatalf("expected the policies next to the manifests, got %v", added)
+	}
+	if !strings.Contains(content, "kind: NetworkPolicy") || !strings.Contains(content, "podSelector: {}") {
+		t.Errorf("unexpected manifest:\n%s", content)
+	}
+
+	files := map[string]string{"k8s/" + File: content}
+	for k, v := range manifests {
+		files[k] = v
+	}
+	if added, _ := Inject("shop", files); added != nil {
+		t.Errorf("expected nothing added to manifests with policies, got %v", added)
+	}
+	if findings := Validate("shop", files); len(findings) != 0 {
+		t.Errorf("expected the generated policies to validate, got %+v", findings)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	findings := Validate("shop", manifests)
+	if len(findings) != 1 || findings[0].Severity != SeverityHigh || !strings.Contains(findings[0].Remediation, DefaultDenyName) {
+		t.Fatalf("expected missing policies with the generated ones as remediation, got %+v", findings)
+	}
+
+	files := map[string]string{"k8s/policy.yaml": `apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: ingress-only
+  namespace: shop
+spec:
+  podSelector: {}
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: stale
+  namespace: shop
+spec:
+  podSelector:
+    matchLabels:
+      app: worker
+  ingress:
+  - {}
+`}
+	for k, v := range manifests {
+		files[k] = v
+	}
+	findings = Validate("shop", files)
+	if len(findings) != 4 {
+		t.Fatalf("expected 4 findings, got %+v", findings)
+	}
+	if f := findings[0]; f.Severity != SeverityMedium || f.Resource != "Namespace/shop" || !strings.Contains(f.Remediation, AllowDNSName) {
+		t.Errorf("expected missing default egress deny, got %+v", f)
+	}
+	if f := findings[1]; f.Resource != "Workload/api" {
+		t.Errorf("expected api unreachable, got %+v", f)
+	}
+	if f := findings[3]; f.Severity != SeverityLow || f.Resource != "NetworkPolicy/stale" {
+		t.Errorf("expected the stale policy reported, got %+v", f)
+	}
+}
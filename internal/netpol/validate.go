@@ -0,0 +1,153 @@
+package netpol
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Finding severities, as the infrastructure validator reports them
+const (
+	SeverityHigh   = "HIGH"
+	SeverityMedium = "MEDIUM"
+	SeverityLow    = "LOW"
+)
+
+// Finding is a gap in the network policies of a project. Remediation is
+// the policy manifest that closes it, when one can be generated.
+type Finding struct {
+	Severity    string `json:"severity"`
+	Resource    string `json:"resource"`
+	Message     string `json:"message"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// Validate checks that the NetworkPolicies in the manifests deny traffic by
+// default in every namespace with workloads, let each workload that listens
+// on a port be reached, and select pods that exist
+func Validate(project string, files map[string]string) []Finding {
+	workloads := Workloads(files)
+	if len(workloads) == 0 {
+		return nil
+	}
+	policies := Policies(files)
+	if len(policies) == 0 {
+		f := Finding{
+			Severity: SeverityHigh,
+			Resource: "NetworkPolicy",
+			Message:  "No NetworkPolicies: every pod accepts traffic from and sends traffic to anywhere",
+		}
+		if content, err := Render(Generate(project, files)); err == nil {
+			f.Remediation = content
+		}
+		return []Finding{f}
+	}
+
+	var findings []Finding
+	namespaces := make(map[string]bool)
+	for _, w := range workloads {
+		namespaces[w.Namespace] = true
+	}
+	for _, ns := range sortedKeys(namespaces) {
+		ingress, egress := defaultDeny(policies, ns)
+		if ingress && egress {
+			continue
+		}
+		f := Finding{Severity: SeverityHigh, Resource: "Namespace/" + namespaceName(ns)}
+		switch {
+		case !ingress && !egress:
+			f.Message = fmt.Sprintf("Namespace %s has no default-deny policy", namespaceName(ns))
+		case !ingress:
+			f.Message = fmt.Sprintf("Namespace %s does not deny ingress by default", namespaceName(ns))
+		default:
+			f.Severity = SeverityMedium
+			f.Message = fmt.Sprintf("Namespace %s does not deny egress by default", namespaceName(ns))
+		}
+		if content, err := Render(DefaultDeny(ns)); err == nil {
+			f.Remediation = content
+		}
+		findings = append(findings, f)
+	}
+
+	for _, w := range workloads {
+		if len(w.Ports) == 0 || allowsIngress(policies, w) {
+			continue
+		}
+		findings = append(findings, Finding{
+			Severity: SeverityMedium,
+			Resource: "Workload/" + w.Name,
+			Message:  fmt.Sprintf("No NetworkPolicy allows ingress to %s, which listens on port %d", w.Name, w.Ports[0].Port),
+		})
+	}
+
+	for _, p := range policies {
+		if p.Spec.PodSelector.Empty() || selectsAny(p, workloads) {
+			continue
+		}
+		findings = append(findings, Finding{
+			Severity: SeverityLow,
+			Resource: "NetworkPolicy/" + p.Metadata.Name,
+			Message:  fmt.Sprintf("NetworkPolicy %s selects no pods the project deploys", p.Metadata.Name),
+		})
+	}
+	return findings
+}
+
+// defaultDeny reports whether a policy selecting every pod in namespace
+// denies its ingress and egress
+func defaultDeny(policies []Policy, namespace string) (ingress, egress bool) {
+	for _, p := range policies {
+		if p.Metadata.Namespace != namespace || !p.Spec.PodSelector.Empty() {
+			continue
+		}
+		for _, t := range p.Types() {
+			switch {
+			case t == "Ingress" && len(p.Spec.Ingress) == 0:
+				ingress = true
+			case t == "Egress" && len(p.Spec.Egress) == 0:
+				egress = true
+			}
+		}
+	}
+	return ingress, egress
+}
+
+// allowsIngress reports whether a policy selecting w's pods allows ingress.
+// Without a default deny, traffic to pods no policy selects is allowed.
+func allowsIngress(policies []Policy, w Workload) bool {
+	isolated := false
+	for _, p := range policies {
+		if p.Metadata.Namespace != w.Namespace || !p.Spec.PodSelector.Selects(w.Labels) || !contains(p.Types(), "Ingress") {
+			continue
+		}
+		isolated = true
+		if len(p.Spec.Ingress) > 0 {
+			return true
+		}
+	}
+	return !isolated
+}
+
+func selectsAny(p Policy, workloads []Workload) bool {
+	for _, w := range workloads {
+		if w.Namespace == p.Metadata.Namespace && p.Spec.PodSelector.Selects(w.Labels) {
+			return true
+		}
+	}
+	return false
+}
+
+func namespaceName(ns string) string {
+	if ns == "" {
+		return "default"
+	}
+	return ns
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
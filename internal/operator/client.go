@@ -0,0 +1,175 @@
+package operator
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned for resources that do not exist
+var ErrNotFound = errors.New("resource not found")
+
+// Client reads and writes QLP custom resources
+type Client interface {
+	// List decodes the resources of a kind in namespace, or in every
+	// namespace when it is empty, into out, a pointer to a slice
+	List(ctx context.Context, resource, namespace string, out interface{}) error
+	// Get decodes one resource into out
+	Get(ctx context.Context, resource, namespace, name string, out interface{}) error
+	// Apply creates obj, or merges it into the existing resource of its name
+	Apply(ctx context.Context, resource, namespace, name string, obj interface{}) error
+	// PatchStatus merges status into the resource's status subresource
+	PatchStatus(ctx context.Context, resource, namespace, name string, status interface{}) error
+}
+
+// Service account files mounted into every pod
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// RESTClient talks to the Kubernetes API server over HTTP
+type RESTClient struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+// NewRESTClient talks to the API server at server, authenticating with
+// token when it is set. Pointing it at "kubectl proxy" runs the operator
+// outside the cluster.
+func NewRESTClient(server, token string, httpClient *http.Client) *RESTClient {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &RESTClient{server: strings.TrimSuffix(server, "/"), token: token, http: httpClient}
+}
+
+// NewInClusterClient uses the service account of the pod the operator runs in
+func NewInClusterClient() (*RESTClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a Kubernetes pod: KUBERNETES_SERVICE_HOST is not set")
+	}
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("cluster CA contains no certificates")
+	}
+	httpClient := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}},
+	}
+	return NewRESTClient("https://"+net.JoinHostPort(host, port), strings.TrimSpace(string(token)), httpClient), nil
+}
+
+// InNamespace reads the namespace of the pod the operator runs in, empty
+// outside a pod
+func InNamespace() string {
+	data, err := os.ReadFile(serviceAccountDir + "/namespace")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func (c *RESTClient) path(resource, namespace, name string) string {
+	p := "/apis/" + APIVersion
+	if namespace != "" {
+		p += "/namespaces/" + namespace
+	}
+	p += "/" + resource
+	if name != "" {
+		p += "/" + name
+	}
+	return p
+}
+
+func (c *RESTClient) List(ctx context.Context, resource, namespace string, out interface{}) error {
+	var list struct {
+		Items json.RawMessage `json:"items"`
+	}
+	if err := c.do(ctx, http.MethodGet, c.path(resource, namespace, ""), "", nil, &list); err != nil {
+		return err
+	}
+	if len(list.Items) == 0 || string(list.Items) == "null" {
+		return nil
+	}
+	return json.Unmarshal(list.Items, out)
+}
+
+func (c *RESTClient) Get(ctx context.Context, resource, namespace, name string, out interface{}) error {
+	return c.do(ctx, http.MethodGet, c.path(resource, namespace, name), "", nil, out)
+}
+
+func (c *RESTClient) Apply(ctx context.Context, resource, namespace, name string, obj interface{}) error {
+	err := c.do(ctx, http.MethodPatch, c.path(resource, namespace, name), "application/merge-patch+json", obj, nil)
+	if errors.Is(err, ErrNotFound) {
+		return c.do(ctx, http.MethodPost, c.path(resource, namespace, ""), "application/json", obj, nil)
+	}
+	return err
+}
+
+func (c *RESTClient) PatchStatus(ctx context.Context, resource, namespace, name string, status interface{}) error {
+	body := map[string]interface{}{"status": status}
+	return c.do(ctx, http.MethodPatch, c.path(resource, namespace, name)+"/status", "application/merge-patch+json", body, nil)
+}
+
+func (c *RESTClient) do(ctx context.Context, method, path, contentType string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", path, err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.server+path, reader)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrNotFound, path)
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,342 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"QLP/internal/e2e"
+	"QLP/internal/logger"
+	"QLP/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// LabelIntent is set on Capsule resources to the name of their Intent
+const LabelIntent = Group + "/intent"
+
+// DefaultMinScore is the validation score a ValidationRun needs by default
+const DefaultMinScore = 70
+
+// Controller drives Intents and ValidationRuns through the pipeline, running
+// each as a qlp subprocess. Resources are listed every resync interval;
+// those whose spec changed since they last finished are run again.
+type Controller struct {
+	client    Client
+	exec      e2e.Executor
+	namespace string
+	interval  time.Duration
+	slots     chan struct{}
+	now       func() time.Time
+
+	mu       sync.Mutex
+	inflight map[string]bool // namespace/kind/name of the resources being run
+}
+
+// NewController reconciles the resources in namespace, or in every
+// namespace when it is empty, running up to concurrency of them at once
+func NewController(client Client, exec e2e.Executor, namespace string, interval time.Duration, concurrency int) *Controller {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Controller{
+		client:    client,
+		exec:      exec,
+		namespace: namespace,
+		interval:  interval,
+		slots:     make(chan struct{}, concurrency),
+		now:       time.Now,
+		inflight:  make(map[string]bool),
+	}
+}
+
+// Run reconciles every resync interval until ctx is cancelled. Resources
+// interrupted by the shutdown are left running and picked up on restart.
+func (c *Controller) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		if err := c.Reconcile(ctx); err != nil {
+			logger.WithComponent("operator").Warn("Reconcile failed",
+				zap.Error(err))
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Reconcile starts the Intents and ValidationRuns that have not finished
+// for their current generation and are not already running
+func (c *Controller) Reconcile(ctx context.Context) error {
+	_, err := c.reconcile(ctx)
+	return err
+}
+
+// reconcile returns a group that completes when the runs it started have
+func (c *Controller) reconcile(ctx context.Context) (*sync.WaitGroup, error) {
+	var wg sync.WaitGroup
+	var intents []Intent
+	if err := c.client.List(ctx, ResourceIntents, c.namespace, &intents); err != nil {
+		return &wg, fmt.Errorf("failed to list intents: %w", err)
+	}
+	for _, in := range intents {
+		if done(in.Status.Phase, in.Status.ObservedGeneration, in.Metadata.Generation) {
+			continue
+		}
+		in := in
+		c.start(ctx, &wg, ResourceIntents, in.Metadata, func() { c.runIntent(ctx, in) })
+	}
+
+	var runs []ValidationRun
+	if err := c.client.List(ctx, ResourceValidationRuns, c.namespace, &runs); err != nil {
+		return &wg, fmt.Errorf("failed to list validation runs: %w", err)
+	}
+	for _, run := range runs {
+		if done(run.Status.Phase, run.Status.ObservedGeneration, run.Metadata.Generation) {
+			continue
+		}
+		run := run
+		c.start(ctx, &wg, ResourceValidationRuns, run.Metadata, func() { c.runValidation(ctx, run) })
+	}
+	return &wg, nil
+}
+
+// start runs fn in the background once a slot is free, unless the
+// resource is already being run
+func (c *Controller) start(ctx context.Context, wg *sync.WaitGroup, resource string, meta ObjectMeta, fn func()) {
+	key := meta.Namespace + "/" + resource + "/" + meta.Name
+	c.mu.Lock()
+	if c.inflight[key] {
+		c.mu.Unlock()
+		return
+	}
+	c.inflight[key] = true
+	c.mu.Unlock()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer func() {
+			c.mu.Lock()
+			delete(c.inflight, key)
+			c.mu.Unlock()
+		}()
+		select {
+		case c.slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		defer func() { <-c.slots }()
+		fn()
+	}()
+}
+
+// generateOutput is the subset of qlp generate --json the operator records
+type generateOutput struct {
+	IntentID     string `json:"intent_id"`
+	CapsuleID    string `json:"capsule_id"`
+	Status       string `json:"status"`
+	OverallScore int    `json:"overall_score"`
+	Error        string `json:"error"`
+}
+
+func (c *Controller) runIntent(ctx context.Context, in Intent) {
+	log := logger.WithComponent("operator").With(
+		zap.String("namespace", in.Metadata.Namespace),
+		zap.String("intent", in.Metadata.Name))
+	gen := in.Metadata.Generation
+	status := in.Status
+	status.ObservedGeneration = gen
+	status.Phase = PhaseRunning
+	status.Conditions = setCondition(status.Conditions, c.condition(ConditionProgressing, "True", "Generating", "Generating a capsule from the intent", gen))
+	status.Conditions = setCondition(status.Conditions, c.condition(ConditionReady, "False", "Generating", "", gen))
+	if !c.patchStatus(ctx, ResourceIntents, in.Metadata, status) {
+		return
+	}
+
+	args := []string{"generate"}
+	if in.Spec.TenantID != "" {
+		args = append(args, "--tenant", in.Spec.TenantID)
+	}
+	out, err := c.generate(ctx, append(args, in.Spec.Intent)...)
+	if ctx.Err() != nil {
+		return
+	}
+	if err == nil && out.OverallScore < in.Spec.MinScore {
+		err = fmt.Errorf("validation score %d is below the minimum %d", out.OverallScore, in.Spec.MinScore)
+	}
+	status.IntentID = out.IntentID
+	status.OverallScore = out.OverallScore
+
+	if out.CapsuleID != "" {
+		capsule := Capsule{
+			APIVersion: APIVersion,
+			Kind:       "Capsule",
+			Metadata: ObjectMeta{
+				Name:      in.Metadata.Name,
+				Namespace: in.Metadata.Namespace,
+				Labels:    map[string]string{LabelIntent: in.Metadata.Name},
+				OwnerReferences: []OwnerReference{{
+					APIVersion: APIVersion,
+					Kind:       "Intent",
+					Name:       in.Metadata.Name,
+					UID:        in.Metadata.UID,
+					Controller: true,
+				}},
+			},
+			Spec: CapsuleSpec{
+				CapsuleID:    out.CapsuleID,
+				IntentRef:    in.Metadata.Name,
+				IntentID:     out.IntentID,
+				OverallScore: out.OverallScore,
+			},
+		}
+		if applyErr := c.client.Apply(ctx, ResourceCapsules, in.Metadata.Namespace, capsule.Metadata.Name, capsule); applyErr != nil {
+			log.Warn("Failed to record capsule", zap.String("capsule_id", out.CapsuleID), zap.Error(applyErr))
+			if err == nil {
+				err = fmt.Errorf("failed to record capsule %s: %w", out.CapsuleID, applyErr)
+			}
+		} else {
+			status.CapsuleRef = capsule.Metadata.Name
+			status.CapsuleID = out.CapsuleID
+		}
+	}
+
+	if err != nil {
+		log.Warn("Intent failed", zap.Error(err))
+		status.Phase = PhaseFailed
+		status.Conditions = setCondition(status.Conditions, c.condition(ConditionReady, "False", "GenerationFailed", err.Error(), gen))
+	} else {
+		log.Info("Intent generated", zap.String("capsule_id", out.CapsuleID))
+		status.Phase = PhaseSucceeded
+		status.Conditions = setCondition(status.Conditions, c.condition(ConditionReady, "True", "CapsuleGenerated",
+			fmt.Sprintf("Capsule %s scored %d", out.CapsuleID, out.OverallScore), gen))
+	}
+	status.Conditions = setCondition(status.Conditions, c.condition(ConditionProgressing, "False", "Finished", "", gen))
+	c.patchStatus(ctx, ResourceIntents, in.Metadata, status)
+}
+
+// generate runs qlp generate, failing unless the intent completed
+func (c *Controller) generate(ctx context.Context, args ...string) (generateOutput, error) {
+	data, err := c.exec(ctx, args...)
+	var out generateOutput
+	if len(data) > 0 {
+		if decodeErr := json.Unmarshal(data, &out); decodeErr != nil && err == nil {
+			err = fmt.Errorf("invalid generate output: %w", decodeErr)
+		}
+	}
+	if out.Error != "" {
+		err = errors.New(out.Error)
+	}
+	if err == nil && out.Status != string(models.IntentStatusCompleted) {
+		err = fmt.Errorf("intent %s", out.Status)
+	}
+	return out, err
+}
+
+// validateOutput is the subset of qlp validate --json the operator records
+type validateOutput struct {
+	CapsuleID  string `json:"capsule_id"`
+	Passed     bool   `json:"passed"`
+	Validation struct {
+		OverallScore int `json:"overall_score"`
+	} `json:"validation"`
+}
+
+func (c *Controller) runValidation(ctx context.Context, run ValidationRun) {
+	gen := run.Metadata.Generation
+	status := run.Status
+	status.ObservedGeneration = gen
+	status.Phase = PhaseRunning
+	status.Conditions = setCondition(status.Conditions, c.condition(ConditionProgressing, "True", "Validating", "", gen))
+	if !c.patchStatus(ctx, ResourceValidationRuns, run.Metadata, status) {
+		return
+	}
+
+	out, err := c.validate(ctx, run)
+	if ctx.Err() != nil {
+		return
+	}
+	status.CapsuleID = out.CapsuleID
+	status.OverallScore = out.Validation.OverallScore
+	status.Passed = out.Passed
+	switch {
+	case errors.Is(err, ErrNotFound):
+		// The Intent may still be generating it; retried on the next resync
+		status.Phase = PhasePending
+		status.Conditions = setCondition(status.Conditions, c.condition(ConditionReady, "False", "CapsuleNotFound", err.Error(), gen))
+	case err != nil:
+		status.Phase = PhaseFailed
+		status.Conditions = setCondition(status.Conditions, c.condition(ConditionReady, "False", "ValidationError", err.Error(), gen))
+	case !out.Passed:
+		status.Phase = PhaseFailed
+		status.Conditions = setCondition(status.Conditions, c.condition(ConditionReady, "True", "ValidationFailed",
+			fmt.Sprintf("Capsule %s scored %d", out.CapsuleID, out.Validation.OverallScore), gen))
+	default:
+		status.Phase = PhaseSucceeded
+		status.Conditions = setCondition(status.Conditions, c.condition(ConditionReady, "True", "ValidationPassed",
+			fmt.Sprintf("Capsule %s scored %d", out.CapsuleID, out.Validation.OverallScore), gen))
+	}
+	status.Conditions = setCondition(status.Conditions, c.condition(ConditionProgressing, "False", "Finished", "", gen))
+	c.patchStatus(ctx, ResourceValidationRuns, run.Metadata, status)
+}
+
+// validate resolves the capsule of a run and runs qlp validate on it. A
+// capsule below the minimum score is a result, not an error.
+func (c *Controller) validate(ctx context.Context, run ValidationRun) (validateOutput, error) {
+	capsuleID := run.Spec.CapsuleID
+	if capsuleID == "" {
+		if run.Spec.CapsuleRef == "" {
+			return validateOutput{}, errors.New("spec needs capsuleRef or capsuleId")
+		}
+		var capsule Capsule
+		if err := c.client.Get(ctx, ResourceCapsules, run.Metadata.Namespace, run.Spec.CapsuleRef, &capsule); err != nil {
+			return validateOutput{}, fmt.Errorf("capsule %s: %w", run.Spec.CapsuleRef, err)
+		}
+		capsuleID = capsule.Spec.CapsuleID
+	}
+	minScore := run.Spec.MinScore
+	if minScore == 0 {
+		minScore = DefaultMinScore
+	}
+
+	data, err := c.exec(ctx, "validate", "--min-score", strconv.Itoa(minScore), capsuleID)
+	out := validateOutput{CapsuleID: capsuleID}
+	if len(data) > 0 && json.Unmarshal(data, &out) == nil && out.CapsuleID != "" {
+		return out, nil
+	}
+	if err == nil {
+		err = errors.New("validate printed no result")
+	}
+	return out, err
+}
+
+func (c *Controller) condition(conditionType, status, reason, message string, gen int64) Condition {
+	return Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: gen,
+		LastTransitionTime: c.now().UTC().Truncate(time.Second),
+	}
+}
+
+func (c *Controller) patchStatus(ctx context.Context, resource string, meta ObjectMeta, status interface{}) bool {
+	if err := c.client.PatchStatus(ctx, resource, meta.Namespace, meta.Name, status); err != nil {
+		logger.WithComponent("operator").Warn("Failed to update status",
+			zap.String("resource", resource),
+			zap.String("namespace", meta.Namespace),
+			zap.String("name", meta.Name),
+			zap.Error(err))
+		return false
+	}
+	return true
+}
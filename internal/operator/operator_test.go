@@ -0,0 +1,202 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeAPIServer serves custom resources from memory, applying merge
+// patches to the top-level fields
+type fakeAPIServer struct {
+	mu      sync.Mutex
+	objects map[string]map[string]interface{} // by path
+}
+
+func newFakeAPIServer(t *testing.T) (*fakeAPIServer, *RESTClient) {
+	api := &fakeAPIServer{objects: make(map[string]map[string]interface{})}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	return api, NewRESTClient(srv.URL, "token", srv.Client())
+}
+
+func (a *fakeAPIServer) put(path string, obj interface{}) {
+	data, _ := json.Marshal(obj)
+	var m map[string]interface{}
+	json.Unmarshal(data, &m)
+	a.mu.Lock()
+	a.objects[path] = m
+	a.mu.Unlock()
+}
+
+func (a *fakeAPIServer) get(path string, out interface{}) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	obj, ok := a.objects[path]
+	if ok {
+		data, _ := json.Marshal(obj)
+		json.Unmarshal(data, out)
+	}
+	return ok
+}
+
+func (a *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	path := strings.TrimSuffix(r.URL.Path, "/status")
+	body, _ := io.ReadAll(r.Body)
+	var patch map[string]interface{}
+	json.Unmarshal(body, &patch)
+
+	switch r.Method {
+	case http.MethodGet:
+		if obj, ok := a.objects[path]; ok {
+			json.NewEncoder(w).Encode(obj)
+			return
+		}
+		items := []interface{}{}
+		for p, obj := range a.objects {
+			if strings.HasPrefix(p, path+"/") {
+				items = append(items, obj)
+			}
+		}
+		if len(items) == 0 && !strings.HasSuffix(path, "s") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+	case http.MethodPatch:
+		obj, ok := a.objects[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for k, v := range patch {
+			obj[k] = v
+		}
+	case http.MethodPost:
+		meta := patch["metadata"].(map[string]interface{})
+		a.objects[path+"/"+meta["name"].(string)] = patch
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+const intentsPath = "/apis/" + APIVersion + "/namespaces/apps/" + ResourceIntents
+
+func TestIntentGeneratesCapsule(t *testing.T) {
+	api, client := newFakeAPIServer(t)
+	api.put(intentsPath+"/todo-api", Intent{
+		APIVersion: APIVersion, Kind: "Intent",
+		Metadata: ObjectMeta{Name: "todo-api", Namespace: "apps", UID: "uid-1", Generation: 1},
+		Spec:     IntentSpec{Intent: "Create a todo REST API", TenantID: "acme", MinScore: 70},
+	})
+	api.put(intentsPath+"/low-score", Intent{
+		APIVersion: APIVersion, Kind: "Intent",
+		Metadata: ObjectMeta{Name: "low-score", Namespace: "apps", Generation: 1},
+		Spec:     IntentSpec{Intent: "Create a blog", MinScore: 90},
+	})
+
+	var mu sync.Mutex
+	var calls [][]string
+	exec := func(ctx context.Context, args ...string) ([]byte, error) {
+		mu.Lock()
+		calls = append(calls, args)
+		mu.Unlock()
+		id := "CAP-todo"
+		if args[len(args)-1] == "Create a blog" {
+			id = "CAP-blog"
+		}
+		return json.Marshal(map[string]interface{}{
+			"intent_id": "INT-1", "capsule_id": id, "status": "completed", "overall_score": 85,
+		})
+	}
+	ctrl := NewController(client, exec, "apps", 0, 2)
+	wg, err := ctrl.reconcile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	var in Intent
+	api.get(intentsPath+"/todo-api", &in)
+	if in.Status.Phase != PhaseSucceeded || in.Status.CapsuleRef != "todo-api" || in.Status.ObservedGeneration != 1 {
+		t.Fatalf("status = %+v", in.Status)
+	}
+	if len(in.Status.Conditions) != 2 || in.Status.Conditions[1].Type != ConditionReady || in.Status.Conditions[1].Status != "True" {
+		t.Errorf("conditions = %+v", in.Status.Conditions)
+	}
+	var capsule Capsule
+	api.get("/apis/"+APIVersion+"/namespaces/apps/"+ResourceCapsules+"/todo-api", &capsule)
+	if capsule.Spec.CapsuleID != "CAP-todo" || capsule.Metadata.OwnerReferences[0].UID != "uid-1" {
+		t.Errorf("capsule = %+v", capsule)
+	}
+
+	api.get(intentsPath+"/low-score", &in)
+	if in.Status.Phase != PhaseFailed || !strings.Contains(in.Status.Conditions[1].Message, "below the minimum 90") {
+		t.Errorf("low score status = %+v", in.Status)
+	}
+
+	// Finished intents are not run again until their spec changes
+	wg, _ = ctrl.reconcile(context.Background())
+	wg.Wait()
+	if len(calls) != 2 {
+		t.Errorf("generate ran %d times", len(calls))
+	}
+	for _, args := range calls {
+		if args[len(args)-1] == "Create a todo REST API" && (len(args) != 4 || args[2] != "acme") {
+			t.Errorf("generate args = %v", args)
+		}
+	}
+}
+
+func TestValidationRun(t *testing.T) {
+	api, client := newFakeAPIServer(t)
+	runs := "/apis/" + APIVersion + "/namespaces/apps/" + ResourceValidationRuns
+	api.put("/apis/"+APIVersion+"/namespaces/apps/"+ResourceCapsules+"/todo-api", Capsule{
+		Metadata: ObjectMeta{Name: "todo-api", Namespace: "apps"},
+		Spec:     CapsuleSpec{CapsuleID: "CAP-todo"},
+	})
+	api.put(runs+"/nightly", ValidationRun{
+		Metadata: ObjectMeta{Name: "nightly", Namespace: "apps", Generation: 2},
+		Spec:     ValidationRunSpec{CapsuleRef: "todo-api", MinScore: 80},
+	})
+	api.put(runs+"/missing", ValidationRun{
+		Metadata: ObjectMeta{Name: "missing", Namespace: "apps", Generation: 1},
+		Spec:     ValidationRunSpec{CapsuleRef: "gone"},
+	})
+
+	exec := func(ctx context.Context, args ...string) ([]byte, error) {
+		if strings.Join(args, " ") != "validate --min-score 80 CAP-todo" {
+			t.Errorf("validate args = %v", args)
+		}
+		out, _ := json.Marshal(map[string]interface{}{
+			"capsule_id": "CAP-todo", "passed": false, "validation": map[string]int{"overall_score": 72},
+		})
+		return out, errors.New("exit status 1")
+	}
+	wg, err := NewController(client, exec, "apps", 0, 1).reconcile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	var run ValidationRun
+	api.get(runs+"/nightly", &run)
+	if run.Status.Phase != PhaseFailed || run.Status.OverallScore != 72 || run.Status.ObservedGeneration != 2 {
+		t.Errorf("nightly status = %+v", run.Status)
+	}
+	api.get(runs+"/missing", &run)
+	if run.Status.Phase != PhasePending || run.Status.Conditions[1].Reason != "CapsuleNotFound" {
+		t.Errorf("missing capsule status = %+v", run.Status)
+	}
+}
@@ -0,0 +1,150 @@
+// Package operator reconciles QLP custom resources in a Kubernetes cluster,
+// so intents can be declared as manifests and applied through GitOps. An
+// Intent is generated into a capsule, recorded as a Capsule resource owned
+// by the Intent, and a ValidationRun revalidates a capsule on demand.
+// Progress is reported through status phases and conditions.
+package operator
+
+import "time"
+
+// API group and version of the QLP custom resources
+const (
+	Group      = "qlp.quantumlayer.dev"
+	Version    = "v1alpha1"
+	APIVersion = Group + "/" + Version
+)
+
+// Plural resource names, as they appear in API paths
+const (
+	ResourceIntents        = "intents"
+	ResourceCapsules       = "capsules"
+	ResourceValidationRuns = "validationruns"
+)
+
+// Phases of an Intent or ValidationRun
+const (
+	PhasePending   = "Pending" // Waiting for something else, e.g. the capsule to validate
+	PhaseRunning   = "Running"
+	PhaseSucceeded = "Succeeded"
+	PhaseFailed    = "Failed"
+)
+
+// Condition types
+const (
+	ConditionReady       = "Ready"       // The capsule or validation result is available
+	ConditionProgressing = "Progressing" // The pipeline is running
+)
+
+// ObjectMeta is the subset of Kubernetes object metadata the operator uses
+type ObjectMeta struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace,omitempty"`
+	UID             string            `json:"uid,omitempty"`
+	Generation      int64             `json:"generation,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	OwnerReferences []OwnerReference  `json:"ownerReferences,omitempty"`
+}
+
+// OwnerReference makes a resource garbage-collected with its owner
+type OwnerReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	UID        string `json:"uid"`
+	Controller bool   `json:"controller,omitempty"`
+}
+
+// Condition follows the Kubernetes status condition conventions
+type Condition struct {
+	Type               string    `json:"type"`
+	Status             string    `json:"status"` // "True", "False" or "Unknown"
+	Reason             string    `json:"reason,omitempty"`
+	Message            string    `json:"message,omitempty"`
+	ObservedGeneration int64     `json:"observedGeneration,omitempty"`
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+}
+
+// Intent asks for a capsule to be generated from a natural-language intent
+type Intent struct {
+	APIVersion string       `json:"apiVersion"`
+	Kind       string       `json:"kind"`
+	Metadata   ObjectMeta   `json:"metadata"`
+	Spec       IntentSpec   `json:"spec"`
+	Status     IntentStatus `json:"status,omitempty"`
+}
+
+type IntentSpec struct {
+	Intent   string `json:"intent"`
+	TenantID string `json:"tenantId,omitempty"` // Tenant the intent is metered against
+	MinScore int    `json:"minScore,omitempty"` // Validation score below which the intent fails
+}
+
+type IntentStatus struct {
+	Phase              string      `json:"phase,omitempty"`
+	ObservedGeneration int64       `json:"observedGeneration,omitempty"`
+	IntentID           string      `json:"intentId,omitempty"`
+	CapsuleRef         string      `json:"capsuleRef,omitempty"` // Name of the Capsule resource
+	CapsuleID          string      `json:"capsuleId,omitempty"`
+	OverallScore       int         `json:"overallScore,omitempty"`
+	Conditions         []Condition `json:"conditions,omitempty"`
+}
+
+// Capsule records a generated capsule, stored in QLP's artifact store
+type Capsule struct {
+	APIVersion string      `json:"apiVersion"`
+	Kind       string      `json:"kind"`
+	Metadata   ObjectMeta  `json:"metadata"`
+	Spec       CapsuleSpec `json:"spec"`
+}
+
+type CapsuleSpec struct {
+	CapsuleID    string `json:"capsuleId"`
+	IntentRef    string `json:"intentRef,omitempty"` // Name of the Intent it was generated from
+	IntentID     string `json:"intentId,omitempty"`
+	OverallScore int    `json:"overallScore,omitempty"`
+}
+
+// ValidationRun validates a capsule, named by its Capsule resource or ID
+type ValidationRun struct {
+	APIVersion string              `json:"apiVersion"`
+	Kind       string              `json:"kind"`
+	Metadata   ObjectMeta          `json:"metadata"`
+	Spec       ValidationRunSpec   `json:"spec"`
+	Status     ValidationRunStatus `json:"status,omitempty"`
+}
+
+type ValidationRunSpec struct {
+	CapsuleRef string `json:"capsuleRef,omitempty"`
+	CapsuleID  string `json:"capsuleId,omitempty"`
+	MinScore   int    `json:"minScore,omitempty"` // Default 70
+}
+
+type ValidationRunStatus struct {
+	Phase              string      `json:"phase,omitempty"`
+	ObservedGeneration int64       `json:"observedGeneration,omitempty"`
+	CapsuleID          string      `json:"capsuleId,omitempty"`
+	OverallScore       int         `json:"overallScore,omitempty"`
+	Passed             bool        `json:"passed"`
+	Conditions         []Condition `json:"conditions,omitempty"`
+}
+
+// setCondition adds or replaces the condition of its type, keeping the
+// transition time when the status did not change
+func setCondition(conditions []Condition, c Condition) []Condition {
+	for i, existing := range conditions {
+		if existing.Type != c.Type {
+			continue
+		}
+		if existing.Status == c.Status {
+			c.LastTransitionTime = existing.LastTransitionTime
+		}
+		conditions[i] = c
+		return conditions
+	}
+	return append(conditions, c)
+}
+
+// done reports whether a phase is final for the observed generation
+func done(phase string, observed, generation int64) bool {
+	return observed == generation && (phase == PhaseSucceeded || phase == PhaseFailed)
+}
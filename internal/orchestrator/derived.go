@@ -0,0 +1,158 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"QLP/internal/audit"
+	"QLP/internal/catalog"
+	"QLP/internal/logger"
+	"QLP/internal/models"
+	"QLP/internal/netpol"
+	"QLP/internal/packaging"
+	"QLP/internal/tfstate"
+	"QLP/internal/threatmodel"
+
+	"go.uber.org/zap"
+)
+
+// derivedDrops runs the agents that write drops from the task drops, such
+// as the architecture docs, unit tests and threat model. They are reviewed
+// like the other drops and added to the capsule project when approved.
+func (o *Orchestrator) derivedDrops(ctx context.Context, intent *models.Intent, drops []packaging.QuantumDrop) []packaging.QuantumDrop {
+	files := make(map[string]string)
+	for _, drop := range drops {
+		if drop.Metadata.Derived || drop.Type == packaging.DropTypeAnalysis {
+			continue
+		}
+		for path, content := range drop.Files {
+			files[path] = content
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	var derived []packaging.QuantumDrop
+	project := packaging.ProjectName(intent.UserInput)
+	if o.docsAgent != nil {
+		docs := o.docsAgent.Generate(ctx, project, intent.UserInput, files)
+		drop := o.quantumDropGen.DerivedDrop("ARCHDOCS", packaging.DropTypeDocumentation,
+			"Architecture Documentation", "Component diagram, API reference, runbook and ADRs", docs)
+		logger.WithComponent("orchestrator").Info("Architecture docs written",
+			zap.String("drop_id", drop.ID),
+			zap.Int("file_count", len(docs)))
+		derived = append(derived, drop)
+	}
+	if o.testAgent != nil {
+		result, err := o.testAgent.Generate(ctx, files)
+		if err != nil {
+			logger.WithComponent("orchestrator").Warn("No unit tests generated",
+				zap.Error(err))
+		} else {
+			drop := o.quantumDropGen.DerivedDrop("TESTGEN", packaging.DropTypeTesting,
+				"Generated Unit Tests", "Unit tests for the generated sources, run in the sandbox", result.Files)
+			// Tests that missed the thresholds, or never ran, need a reviewer
+			drop.Metadata.ValidationPassed = result.MetThresholds
+			drop.Metadata.HITLRequired = !result.MetThresholds
+			drop.Metadata.ReviewNotes = append(drop.Metadata.ReviewNotes, result.Summary())
+			logger.WithComponent("orchestrator").Info("Unit tests written",
+				zap.String("drop_id", drop.ID),
+				zap.String("summary", result.Summary()))
+			derived = append(derived, drop)
+		}
+	}
+	if o.threatAgent != nil {
+		model := o.threatAgent.Analyze(ctx, project, files)
+		drop := o.quantumDropGen.DerivedDrop("THREATS", packaging.DropTypeAnalysis,
+			"Threat Model", "STRIDE threats, attack surface and mitigations",
+			map[string]string{threatmodel.MarkdownPath: model.Markdown(), threatmodel.JSONPath: model.JSON()})
+		high := model.HighRisk()
+		drop.Metadata.ValidationPassed = !model.Blocking()
+		drop.Metadata.HITLRequired = len(high) > 0
+		for _, t := range high {
+			drop.Metadata.ReviewNotes = append(drop.Metadata.ReviewNotes, threatNote(t))
+		}
+		flagThreats(drops, high)
+		logger.WithComponent("orchestrator").Info("Threat model built",
+			zap.String("drop_id", drop.ID),
+			zap.Int("threats", len(model.Threats)),
+			zap.Int("high_risk", len(high)),
+			zap.Bool("blocking", model.Blocking()))
+		derived = append(derived, drop)
+	}
+	return derived
+}
+
+// flagThreats sends the drops containing evidence of high-risk threats to
+// review. Critical threats drop the security score below the rework
+// threshold of the HITL decision.
+func flagThreats(drops []packaging.QuantumDrop, threats []threatmodel.Threat) {
+	for _, t := range threats {
+		file, _, _ := strings.Cut(t.Evidence, ":")
+		if file == "" {
+			continue
+		}
+		for i := range drops {
+			if _, ok := drops[i].Files[file]; !ok {
+				continue
+			}
+			drops[i].Metadata.HITLRequired = true
+			drops[i].Metadata.ReviewNotes = append(drops[i].Metadata.ReviewNotes, threatNote(t))
+			if t.Risk == threatmodel.RiskCritical {
+				drops[i].Metadata.SecurityScore = min(drops[i].Metadata.SecurityScore, 49)
+			}
+		}
+	}
+}
+
+func threatNote(t threatmodel.Threat) string {
+	note := fmt.Sprintf("Threat %s [%s, %s] %s", t.ID, t.Risk, t.Category.Title(), t.Title)
+	if t.Evidence != "" {
+		note += " in " + t.Evidence
+	}
+	return note
+}
+
+// derivedFiles adds the files of approved derived drops to the capsule
+// project, task drops reach it through the task outputs, the remote state
+// backend of its Terraform, NetworkPolicies for its Kubernetes workloads and
+// the service's Backstage catalog entity
+func (o *Orchestrator) derivedFiles(ctx context.Context, capsule *packaging.QLCapsule) map[string]string {
+	files := make(map[string]string)
+	for _, drop := range o.quantumDrops {
+		if !drop.Metadata.Derived {
+			continue
+		}
+		if drop.Status != packaging.DropStatusApproved && drop.Status != packaging.DropStatusModified {
+			continue
+		}
+		for path, content := range drop.Files {
+			files[path] = content
+		}
+	}
+	if project := capsule.UnifiedProject; o.stateBackends && project != nil {
+		for path, content := range tfstate.Inject(project.Files, tfstate.BackendFromEnv(project.Name)) {
+			files[path] = content
+		}
+	}
+	if project := capsule.UnifiedProject; o.networkPolicies && project != nil {
+		policies, err := netpol.Inject(project.Name, project.Files)
+		if err != nil {
+			logger.WithComponent("orchestrator").Warn("No network policies written", zap.Error(err))
+		}
+		for path, content := range policies {
+			files[path] = content
+		}
+	}
+	if o.catalogOptions != nil {
+		entity := catalog.FromCapsule(capsule, audit.TenantFromContext(ctx), *o.catalogOptions)
+		if data, err := entity.YAML(); err != nil {
+			logger.WithComponent("orchestrator").Warn("No catalog entity written", zap.Error(err))
+		} else {
+			files[packaging.CatalogInfoFile] = string(data)
+		}
+	}
+	return files
+}
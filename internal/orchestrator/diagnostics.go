@@ -0,0 +1,47 @@
+package orchestrator
+
+import (
+	"context"
+
+	"QLP/internal/audit"
+	"QLP/internal/diagnostics"
+	"QLP/internal/models"
+	"QLP/internal/packaging"
+	"QLP/internal/preview"
+)
+
+// feedDiagnostics opens a diagnostics feed for a run of the intent, updated
+// with every task output the run produces, refinements included. The
+// outputs of the graph's tasks outside rerun are kept from earlier runs;
+// a nil rerun runs them all.
+func (o *Orchestrator) feedDiagnostics(ctx context.Context, intentID string, rerun []string) {
+	feeds := diagnostics.Shared()
+	if feeds == nil {
+		return
+	}
+	kept := make(map[string]map[string]string)
+	if rerun != nil && o.taskGraph != nil {
+		running := make(map[string]bool, len(rerun))
+		for _, id := range rerun {
+			running[id] = true
+		}
+		for _, task := range o.taskGraph.Tasks {
+			if result := o.dagExecutor.GetTaskResult(task.ID); result != nil && !running[task.ID] {
+				kept[task.ID] = o.quantumDropGen.TaskFiles(task, result.Output)
+			}
+		}
+	}
+	feeds.Start(audit.TenantFromContext(ctx), intentID, kept)
+	o.dagExecutor.OnOutput(func(ctx context.Context, task models.Task, output string, refinements int) {
+		feeds.Update(ctx, intentID, task.ID, o.quantumDropGen.TaskFiles(task, output), refinements)
+	})
+}
+
+// sealDiagnostics finalizes the run's feed with the diagnostics of the
+// capsule it packaged
+func (o *Orchestrator) sealDiagnostics(ctx context.Context, intentID string, capsule *packaging.QLCapsule) {
+	o.dagExecutor.OnOutput(nil)
+	if feeds := diagnostics.Shared(); feeds != nil {
+		feeds.Seal(audit.TenantFromContext(ctx), intentID, preview.FromCapsule(ctx, capsule))
+	}
+}
@@ -2,47 +2,140 @@ package orchestrator
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"QLP/internal/agents"
+	"QLP/internal/archdocs"
+	"QLP/internal/audit"
+	"QLP/internal/catalog"
+	"QLP/internal/clarify"
+	"QLP/internal/intentlang"
+	"QLP/internal/requirements"
+	"QLP/internal/cloudcost"
+	"QLP/internal/config"
+	"QLP/internal/constraints"
 	"QLP/internal/dag"
 	"QLP/internal/database"
+	"QLP/internal/embeddings"
 	"QLP/internal/events"
+	"QLP/internal/failures"
 	"QLP/internal/llm"
 	"QLP/internal/logger"
 	"QLP/internal/models"
 	"QLP/internal/packaging"
+	"QLP/internal/quota"
 	"QLP/internal/parser"
+	"QLP/internal/remediate"
+	"QLP/internal/sandbox"
+	"QLP/internal/stacks"
+	"QLP/internal/statemanager"
+	"QLP/internal/storage"
+	"QLP/internal/testgen"
+	"QLP/internal/threatmodel"
+	"QLP/internal/tracing"
 	"QLP/internal/types"
+	"QLP/internal/validation"
 	"QLP/internal/vector"
+	"QLP/internal/workspace"
+
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
+// ErrConstraintViolation is returned when generated output breaks strictly
+// enforced intent constraints
+var ErrConstraintViolation = errors.New("generated output violates intent constraints")
+
 type Orchestrator struct {
 	intentParser     *parser.IntentParser
 	taskGraph        *models.TaskGraph
 	eventBus         *events.EventBus
 	dagExecutor      *dag.DAGExecutor
+	agentFactory     *agents.AgentFactory
 	capsulePackager  *packaging.CapsuleOrchestrator
 	quantumDropGen   *packaging.QuantumDropGenerator
 	executionResults map[string]*packaging.AgentExecutionResult
 	quantumDrops     []packaging.QuantumDrop
+	hitlDecisions    []packaging.HITLDecision
 	hitlEnabled      bool
 	db               *database.Database
 	intentRepo       *database.IntentRepository
 	vectorService    *vector.VectorService
+	memory           *vector.GenerationMemory
+	memoryClone      bool
 	llmClient        llm.Client
+	outbox           *database.Outbox
+	dockerfileLinter *validation.DockerfileValidator
+	powershellLinter *validation.PowerShellValidator
+	docsAgent        *archdocs.Agent
+	testAgent        *testgen.Agent
+	threatAgent      *threatmodel.Agent
+	catalogOptions   *catalog.Options
+	stateBackends    bool
+	networkPolicies  bool
+	remediation      bool
+	autoRemediate    bool
+	prices           *cloudcost.PriceSheet
+	clarifier        *clarify.Service
+	languages        *intentlang.Normalizer
+	workspaces       *workspace.Store
+	lastIntent       *models.Intent
+	lastCapsuleID    string
+	partialResults   string
 }
 
 func New() *Orchestrator {
 	llmClient := llm.NewLLMClient()
 	intentParser := parser.NewIntentParser(llmClient)
-	eventBus := events.NewEventBus()
+	eventBus, err := events.NewBusFromEnv()
+	if err != nil {
+		logger.Logger.Warn("Event journal disabled, queued events are lost on restart",
+			zap.Error(err))
+	}
+	if codec, err := events.CodecFromEnv(); err != nil {
+		logger.Logger.Warn("Event payload compression disabled",
+			zap.Error(err))
+	} else {
+		eventBus.SetCodec(codec)
+	}
+	if partitions, err := strconv.Atoi(config.GetEnvOrDefault("QLP_EVENT_PARTITIONS", "")); err == nil {
+		eventBus.SetPartitions(partitions)
+	}
 	agentFactory := agents.NewAgentFactory(llmClient, eventBus)
 	dagExecutor := dag.NewDAGExecutor(eventBus, agentFactory)
-	capsulePackager := packaging.NewCapsuleOrchestrator("./output")
+	if budget, err := strconv.Atoi(config.GetEnvOrDefault("QLP_TASK_RETRY_BUDGET", "2")); err == nil {
+		dagExecutor.SetRetryBudget(budget)
+	}
+	if config.GetEnvOrDefault("QLP_ENABLE_EXECUTION_QUOTAS", "false") == "true" {
+		if tracker, err := quota.SharedTracker(); err != nil {
+			logger.Logger.Warn("Execution quotas disabled",
+				zap.Error(err))
+		} else {
+			dagExecutor.SetQuota(tracker)
+		}
+	}
+	capsulePackager := packaging.NewCapsuleOrchestrator(config.GetEnvOrDefault("QLP_OUTPUT_DIR", "./output"))
+	capsulePackager.SetIntentDirectories(config.GetEnvOrDefault("QLP_INTENT_DIRECTORIES", "true") == "true")
 	quantumDropGen := packaging.NewQuantumDropGenerator()
+	if config.GetEnvOrDefault("QLP_CROSS_FILE_FIXUP", "true") == "true" {
+		quantumDropGen.SetFixupClient(llmClient)
+	}
+	dockerfileLinter := validation.NewDockerfileValidator()
+	if config.GetEnvOrDefault("QLP_ENABLE_BUILDX_VALIDATION", "false") == "true" {
+		var platforms []string
+		if p := config.GetEnvOrDefault("QLP_BUILDX_PLATFORMS", ""); p != "" {
+			platforms = strings.Split(p, ",")
+		}
+		dockerfileLinter.EnableMultiArchBuild(platforms)
+	}
+	if config.GetEnvOrDefault("QLP_ENABLE_DEPENDENCY_PINNING", "false") == "true" {
+		quantumDropGen.SetDependencyResolver(sandbox.NewDependencyResolver())
+	}
 
 	// Initialize database connection
 	db, err := database.New()
@@ -54,11 +147,58 @@ func New() *Orchestrator {
 	
 	intentRepo := database.NewIntentRepository(db)
 	vectorService := vector.NewVectorService(db, llmClient)
+	if embedder, err := embeddings.NewFromEnv(llmClient); err != nil {
+		logger.Logger.Warn("Embedding provider misconfigured, using the LLM client",
+			zap.Error(err))
+	} else {
+		vectorService.SetEmbedder(embedder)
+	}
 
-	return &Orchestrator{
+	// Generation memory recalls prior successful solutions as prompt context
+	var memory *vector.GenerationMemory
+	if config.GetEnvOrDefault("QLP_MEMORY_ENABLED", "true") == "true" {
+		memory = vector.NewGenerationMemory(vectorService, db)
+		minScore, err := strconv.Atoi(config.GetEnvOrDefault("QLP_MEMORY_MIN_SCORE", "70"))
+		if err != nil {
+			minScore = 70
+		}
+		minSimilarity, err := strconv.ParseFloat(config.GetEnvOrDefault("QLP_MEMORY_MIN_SIMILARITY", "0.75"), 64)
+		if err != nil {
+			minSimilarity = 0.75
+		}
+		memory.SetThresholds(minScore, minSimilarity)
+	}
+
+	// Share DAG state through Postgres so concurrent consumers see one
+	// versioned copy per graph
+	if db != nil && db.IsConnected() {
+		ttl, err := time.ParseDuration(config.GetEnvOrDefault("QLP_DAG_STATE_TTL", "24h"))
+		if err != nil {
+			ttl = statemanager.DefaultTTL
+		}
+		if sm, err := statemanager.NewPostgresStateManager(db.GetConnection(), ttl); err != nil {
+			logger.Logger.Warn("Shared DAG state disabled",
+				zap.Error(err))
+		} else {
+			dagExecutor.SetStateManager(sm)
+		}
+	}
+
+	// Share processed-event IDs through Postgres when available so replays are skipped
+	if db != nil && db.IsConnected() {
+		if store, err := events.NewPostgresDedupStore(db.GetConnection()); err != nil {
+			logger.Logger.Warn("Using in-memory event deduplication",
+				zap.Error(err))
+		} else {
+			eventBus.SetDedupStore(store, events.DefaultDedupTTL)
+		}
+	}
+
+	o := &Orchestrator{
 		intentParser:     intentParser,
 		eventBus:         eventBus,
 		dagExecutor:      dagExecutor,
+		agentFactory:     agentFactory,
 		capsulePackager:  capsulePackager,
 		quantumDropGen:   quantumDropGen,
 		executionResults: make(map[string]*packaging.AgentExecutionResult),
@@ -67,7 +207,92 @@ func New() *Orchestrator {
 		db:               db,
 		intentRepo:       intentRepo,
 		vectorService:    vectorService,
+		memory:           memory,
+		memoryClone:      config.GetEnvOrDefault("QLP_MEMORY_CLONE", "false") == "true",
 		llmClient:        llmClient,
+		outbox:           database.NewOutbox(db, eventBus),
+		dockerfileLinter: dockerfileLinter,
+		powershellLinter: validation.NewPowerShellValidator(),
+		partialResults:   config.GetEnvOrDefault("QLP_PARTIAL_RESULTS", PartialPackage),
+	}
+	if formats := reportFormats(); len(formats) > 0 {
+		capsulePackager.SetReportRenderer(o.reportRenderer(formats))
+	}
+	if config.GetEnvOrDefault("QLP_ENABLE_ARCHITECTURE_DOCS", "true") == "true" {
+		o.docsAgent = archdocs.NewAgent(llmClient)
+	}
+	if config.GetEnvOrDefault("QLP_ENABLE_TEST_GENERATION", "false") == "true" {
+		o.testAgent = testgen.NewAgent(llmClient, sandbox.NewTestRunner(), testgen.ConfigFromEnv())
+	}
+	if config.GetEnvOrDefault("QLP_ENABLE_THREAT_MODEL", "true") == "true" {
+		o.threatAgent = threatmodel.NewAgent(llmClient)
+	}
+	o.stateBackends = config.GetEnvOrDefault("QLP_ENABLE_TFSTATE_BACKEND", "true") == "true"
+	o.networkPolicies = config.GetEnvOrDefault("QLP_ENABLE_NETWORK_POLICIES", "true") == "true"
+	o.remediation = config.GetEnvOrDefault("QLP_ENABLE_REMEDIATION", "true") == "true"
+	o.autoRemediate = o.remediation && config.GetEnvOrDefault("QLP_AUTO_REMEDIATE", "false") == "true"
+	if config.GetEnvOrDefault("QLP_ENABLE_COST_COMPARISON", "true") == "true" {
+		if prices, err := cloudcost.PriceSheetFromEnv(); err != nil {
+			logger.WithComponent("orchestrator").Warn("Cloud cost comparison disabled", zap.Error(err))
+		} else {
+			o.prices = prices
+		}
+	}
+	if config.GetEnvOrDefault("QLP_ENABLE_INTENT_LANGUAGE", "true") == "true" {
+		o.languages = intentlang.NormalizerFromEnv(llmClient)
+	}
+	if config.GetEnvOrDefault("QLP_ENABLE_CATALOG_INFO", "true") == "true" {
+		opts := catalog.OptionsFromEnv()
+		o.catalogOptions = &opts
+	}
+	capsulePackager.SetProjectExtender(o.derivedFiles)
+	return o
+}
+
+// StartBackground starts the event bus and the outbox relay that publishes
+// intent state transitions committed to the database.
+func (o *Orchestrator) StartBackground(ctx context.Context) {
+	o.eventBus.Start(ctx)
+	go o.outbox.Run(ctx)
+}
+
+// SetClarifier enables the clarification phase before task decomposition
+func (o *Orchestrator) SetClarifier(clarifier *clarify.Service) {
+	o.clarifier = clarifier
+}
+
+// LastResult returns the most recently completed intent and its capsule ID
+func (o *Orchestrator) LastResult() (*models.Intent, string) {
+	return o.lastIntent, o.lastCapsuleID
+}
+
+// SetArtifactStore persists exported capsules to durable artifact storage.
+// Task attachments larger than QLP_ARTIFACT_INLINE_LIMIT bytes are stored
+// there too and passed around as references.
+func (o *Orchestrator) SetArtifactStore(store storage.ArtifactStore) {
+	o.capsulePackager.SetArtifactStore(store)
+	inlineLimit, err := strconv.Atoi(config.GetEnvOrDefault("QLP_ARTIFACT_INLINE_LIMIT", strconv.Itoa(storage.DefaultInlineLimit)))
+	if err != nil {
+		inlineLimit = storage.DefaultInlineLimit
+	}
+	o.agentFactory.SetArtifactStore(store, inlineLimit)
+}
+
+// intentEvent builds an intent state transition event carrying the trace context
+func intentEvent(ctx context.Context, eventType events.EventType, intent *models.Intent) events.Event {
+	return events.Event{
+		ID:        fmt.Sprintf("%s_%s", intent.ID, eventType),
+		Type:      eventType,
+		Timestamp: time.Now(),
+		Source:    "orchestrator",
+		// Keyed by intent so its state transitions are handled in order
+		Headers: events.WithPartitionKey(tracing.InjectHeaders(ctx, nil), intent.ID),
+		Payload: map[string]interface{}{
+			"intent_id":     intent.ID,
+			"status":        string(intent.Status),
+			"task_count":    len(intent.Tasks),
+			"overall_score": intent.OverallScore,
+		},
 	}
 }
 
@@ -76,13 +301,13 @@ func (o *Orchestrator) Start(ctx context.Context) error {
 
 	o.eventBus.Start(ctx)
 
-	o.eventBus.Subscribe(events.EventTaskStarted, func(ctx context.Context, event events.Event) error {
+	o.eventBus.SubscribeNamed(events.EventTaskStarted, "orchestrator.log_task_started", func(ctx context.Context, event events.Event) error {
 		logger.WithComponent("orchestrator").Info("Task started",
 			zap.Any("task_id", event.Payload["task_id"]))
 		return nil
 	})
 
-	o.eventBus.Subscribe(events.EventTaskCompleted, func(ctx context.Context, event events.Event) error {
+	o.eventBus.SubscribeNamed(events.EventTaskCompleted, "orchestrator.log_task_completed", func(ctx context.Context, event events.Event) error {
 		logger.WithComponent("orchestrator").Info("Task completed",
 			zap.Any("task_id", event.Payload["task_id"]))
 		return nil
@@ -111,6 +336,12 @@ func (o *Orchestrator) Start(ctx context.Context) error {
 	return nil
 }
 
+// Drain stops new agent executions and waits for in-flight ones until ctx is done.
+// Unfinished tasks are checkpointed by the DAG executor so they can be requeued.
+func (o *Orchestrator) Drain(ctx context.Context) error {
+	return o.dagExecutor.Drain(ctx)
+}
+
 func (o *Orchestrator) ProcessIntent(ctx context.Context, userInput string) (*models.Intent, error) {
 	intent, err := o.intentParser.ParseIntent(ctx, userInput)
 	if err != nil {
@@ -149,19 +380,115 @@ func (o *Orchestrator) buildTaskGraph(tasks []models.Task) (*models.TaskGraph, e
 }
 
 func (o *Orchestrator) ProcessAndExecuteIntent(ctx context.Context, intentText string) error {
+	return o.ProcessAndExecuteConstrainedIntent(ctx, intentText, nil)
+}
+
+// ProcessAndExecuteConstrainedIntent processes an intent under organization
+// constraints, merged over the tenant's defaults. Generated drops that violate
+// them are sent to review; with strict enforcement the intent fails.
+func (o *Orchestrator) ProcessAndExecuteConstrainedIntent(ctx context.Context, intentText string, intentConstraints *models.Constraints) (err error) {
+	ctx, span := tracing.StartSpan(ctx, "intent.process")
+	defer func() { tracing.EndSpan(span, err) }()
+	defer func() {
+		failures.Record(ctx, failures.Failure{Stage: failures.StageIntent, RunID: tracing.TraceID(ctx)}, err)
+	}()
+	// Capsules record the seed, models and parameters behind them
+	ctx, _ = llm.WithGenerationLog(ctx)
+
 	logger.WithComponent("orchestrator").Info("Processing intent",
-		zap.String("intent_text", intentText))
+		zap.String("intent_text", intentText),
+		zap.String("trace_id", tracing.TraceID(ctx)))
 	
 	startTime := time.Now()
 	
-	// Step 1: Parse intent
-	intent, err := o.intentParser.ParseIntent(ctx, intentText)
+	// Step 0: Decompose non-English intents in English, or natively when the
+	// provider reads their language, then ask about anything ambiguous
+	decompositionText := intentText
+	var language *intentlang.Result
+	if o.languages != nil {
+		language = o.languages.Normalize(ctx, intentText)
+		decompositionText = language.Text
+		if language.Handling != intentlang.HandlingNone {
+			logger.WithComponent("orchestrator").Info("Intent is not in English",
+				zap.String("language", language.Language.Code),
+				zap.Float64("confidence", language.Language.Confidence),
+				zap.String("handling", language.Handling))
+		}
+	}
+	// Similarity search and memory match English intents
+	searchText := intentText
+	if language.Translated() {
+		searchText = language.Text
+	}
+	// Requirements documents attached to the intent are planned for
+	var document *requirements.Document
+	if attachment, ok := requirements.AttachmentFromContext(ctx); ok {
+		document, err = requirements.Ingest(ctx, o.llmClient, attachment)
+		if err != nil {
+			return fmt.Errorf("failed to ingest requirements: %w", err)
+		}
+		decompositionText += document.PromptContext()
+		logger.WithComponent("orchestrator").Info("Ingested requirements document",
+			zap.String("document", document.Name),
+			zap.String("kind", document.Kind),
+			zap.Int("requirements", len(document.Requirements)))
+	}
+	var clarification *clarify.Resolution
+	if o.clarifier != nil {
+		clarification, err = o.clarifier.Clarify(ctx, decompositionText)
+		if err != nil {
+			return fmt.Errorf("failed to clarify intent: %w", err)
+		}
+		decompositionText += clarification.PromptContext()
+	}
+
+	// Follow-up intents extend their workspace rather than replacing it
+	ws, err := o.resolveWorkspace(ctx)
+	if err != nil {
+		return err
+	}
+	if existing := ws.PromptContext(); existing != "" {
+		decompositionText += "\n\n" + existing
+	}
+	o.dagExecutor.SetExistingProject(ws.PromptContext())
+
+	// Step 1: Parse intent within the organization's standards
+	resolvedConstraints := constraints.Resolve(audit.TenantFromContext(ctx), intentConstraints)
+	intent, err := o.intentParser.ParseConstrainedIntent(ctx, decompositionText, resolvedConstraints)
 	if err != nil {
 		return fmt.Errorf("failed to parse intent: %w", err)
 	}
+	intent.UserInput = intentText
+	for k, v := range language.Metadata() {
+		intent.Metadata[k] = v
+	}
+	for k, v := range clarification.Metadata() {
+		intent.Metadata[k] = v
+	}
+	if document != nil {
+		requirements.Trace(ctx, o.llmClient, document, intent.Tasks)
+		for k, v := range document.Metadata() {
+			intent.Metadata[k] = v
+		}
+	}
+	if ws != nil {
+		intent.Metadata["workspace.id"] = ws.ID
+	}
+	if !resolvedConstraints.IsZero() {
+		if data, err := json.Marshal(resolvedConstraints); err == nil {
+			intent.Metadata["constraints"] = string(data)
+		}
+	}
+	o.dagExecutor.SetStandards(resolvedConstraints.Standards())
+	stack := stacks.Resolve(resolvedConstraints, decompositionText)
+	o.dagExecutor.SetStack(stack)
+	intent.Metadata["stack"] = stack.Name
+	intent.Metadata["stack.build_tool"] = stack.BuildTool
+	span.SetAttributes(attribute.String("intent.id", intent.ID))
+	ctx = audit.WithIntent(ctx, intent.ID)
 	
 	// Step 1.1: Check for similar intents first
-	suggestions, err := o.vectorService.GetIntentSuggestions(ctx, intentText)
+	suggestions, err := o.vectorService.GetIntentSuggestions(ctx, searchText)
 	if err != nil {
 		logger.WithComponent("orchestrator").Warn("Failed to get intent suggestions",
 			zap.Error(err))
@@ -170,10 +497,13 @@ func (o *Orchestrator) ProcessAndExecuteIntent(ctx context.Context, intentText s
 			zap.Strings("suggestions", suggestions))
 	}
 	
+	// Recall prior successful solutions to use as prompt context
+	o.recallPriorSolutions(ctx, searchText)
+
 	// Step 1.2: Persist intent to database
 	intent.Status = models.IntentStatusProcessing
 	intent.UpdatedAt = time.Now()
-	if err := o.intentRepo.Create(intent); err != nil {
+	if err := o.intentRepo.CreateWithEvents(o.outbox, intent, intentEvent(ctx, events.EventIntentCreated, intent)); err != nil {
 		logger.WithComponent("orchestrator").Warn("Failed to save intent to database",
 			zap.Error(err))
 		// Continue execution even if database save fails
@@ -182,8 +512,21 @@ func (o *Orchestrator) ProcessAndExecuteIntent(ctx context.Context, intentText s
 			zap.String("intent_id", intent.ID))
 	}
 	
+	// Record the failure transition if any later step aborts processing
+	defer func() {
+		if err == nil {
+			return
+		}
+		intent.Status = models.IntentStatusFailed
+		intent.UpdatedAt = time.Now()
+		if updateErr := o.intentRepo.UpdateWithEvents(o.outbox, intent, intentEvent(ctx, events.EventIntentFailed, intent)); updateErr != nil {
+			logger.WithComponent("orchestrator").Warn("Failed to record intent failure",
+				zap.Error(updateErr))
+		}
+	}()
+	
 	// Step 1.3: Generate and store intent embedding
-	if err := o.vectorService.StoreIntentEmbedding(ctx, intent.ID, intentText); err != nil {
+	if err := o.vectorService.StoreIntentEmbedding(ctx, intent.ID, searchText); err != nil {
 		logger.WithComponent("orchestrator").Warn("Failed to store intent embedding",
 			zap.Error(err))
 		// Continue execution even if embedding storage fails
@@ -213,8 +556,24 @@ func (o *Orchestrator) ProcessAndExecuteIntent(ctx context.Context, intentText s
 		zap.Int("agent_count", len(taskGraph.Tasks)),
 		zap.Int("task_count", len(taskGraph.Tasks)))
 	
-	if err := o.dagExecutor.ExecuteTaskGraph(ctx, taskGraph); err != nil {
-		return fmt.Errorf("failed to execute task graph: %w", err)
+	o.feedDiagnostics(ctx, intent.ID, nil)
+	execErr := o.dagExecutor.ExecuteTaskGraph(ctx, taskGraph)
+	o.recordTaskStatuses(intent)
+	if errors.Is(execErr, dag.ErrTasksFailed) {
+		// Keep what completed, so the failed tasks can be retried on their own
+		o.saveRunRecord(intent)
+		if !o.packagesPartial(intent) {
+			return fmt.Errorf("failed to execute task graph: %w", execErr)
+		}
+		logger.WithComponent("orchestrator").Warn("Packaging the results of the tasks that completed",
+			zap.Strings("unfinished_tasks", UnfinishedTasks(intent)),
+			zap.Error(execErr))
+	} else if errors.Is(execErr, dag.ErrDraining) {
+		// Keep what completed, so qlp resume can finish the checkpointed tasks
+		o.saveRunRecord(intent)
+		return fmt.Errorf("failed to execute task graph: %w", execErr)
+	} else if execErr != nil {
+		return fmt.Errorf("failed to execute task graph: %w", execErr)
 	}
 	
 	// Collect real execution results from agents
@@ -228,8 +587,18 @@ func (o *Orchestrator) ProcessAndExecuteIntent(ctx context.Context, intentText s
 	if err != nil {
 		return fmt.Errorf("failed to generate QuantumDrops: %w", err)
 	}
-	
+
+	var violations []constraints.Violation
+	for i := range quantumDrops {
+		violations = append(violations, o.prepareDrop(ctx, intent, &quantumDrops[i])...)
+	}
+	if len(violations) > 0 && intent.Constraints.Strict() {
+		return fmt.Errorf("%w: %s", ErrConstraintViolation, violations[0])
+	}
+	quantumDrops = append(quantumDrops, o.derivedDrops(ctx, intent, quantumDrops)...)
+
 	o.quantumDrops = quantumDrops
+	o.hitlDecisions = nil
 	logger.WithComponent("orchestrator").Info("Generated QuantumDrops",
 		zap.Int("drop_count", len(quantumDrops)))
 	for _, drop := range quantumDrops {
@@ -257,23 +626,42 @@ func (o *Orchestrator) ProcessAndExecuteIntent(ctx context.Context, intentText s
 	if err != nil {
 		return fmt.Errorf("failed to generate QuantumCapsule: %w", err)
 	}
+	o.sealDiagnostics(ctx, intent.ID, capsule)
+
+	// Step 6.1: Add the new service to the workspace
+	if ws != nil {
+		if err := o.composeIntoWorkspace(ws, intent, capsule.Metadata.CapsuleID); err != nil {
+			return fmt.Errorf("failed to compose workspace: %w", err)
+		}
+	}
+
+	if unfinished := UnfinishedTasks(intent); len(unfinished) > 0 {
+		capsule.Metadata.Environment["failed_tasks"] = unfinished
+	}
 
 	// Step 7: Update intent completion in database
 	executionTime := time.Since(startTime)
-	intent.Status = models.IntentStatusCompleted
+	intent.Status = completionStatus(intent)
 	intent.OverallScore = capsule.Metadata.OverallScore
 	intent.ExecutionTimeMS = int(executionTime.Milliseconds())
 	completedAt := time.Now()
 	intent.CompletedAt = &completedAt
 	intent.UpdatedAt = completedAt
 	
-	if err := o.intentRepo.Update(intent); err != nil {
+	if err := o.intentRepo.UpdateWithEvents(o.outbox, intent, intentEvent(ctx, events.EventIntentCompleted, intent)); err != nil {
 		logger.WithComponent("orchestrator").Warn("Failed to update intent completion in database",
 			zap.Error(err))
 	} else {
 		logger.WithComponent("orchestrator").Info("Intent completion saved to database")
 	}
 	
+	o.recordTrends(ctx, ws, capsule, executionTime)
+	
+	// Step 7.1: Remember this solution for future intents
+	o.rememberSolution(ctx, intent, capsule)
+	o.lastIntent = intent
+	o.lastCapsuleID = capsule.Metadata.CapsuleID
+
 	// Step 8: Display results
 	logger.WithComponent("orchestrator").Info("QuantumCapsule generated",
 		zap.String("capsule_id", capsule.Metadata.CapsuleID),
@@ -287,6 +675,59 @@ func (o *Orchestrator) ProcessAndExecuteIntent(ctx context.Context, intentText s
 	return nil
 }
 
+// recallPriorSolutions feeds similar past solutions into agent prompts. With
+// QLP_MEMORY_CLONE set the closest match is offered as a project to adapt.
+func (o *Orchestrator) recallPriorSolutions(ctx context.Context, intentText string) {
+	if o.memory == nil {
+		return
+	}
+
+	recollections, err := o.memory.Recall(ctx, intentText, 3)
+	if err != nil {
+		logger.WithComponent("orchestrator").Warn("Failed to recall prior solutions",
+			zap.Error(err))
+		o.dagExecutor.SetPriorSolutions(nil)
+		return
+	}
+
+	for _, r := range recollections {
+		logger.WithComponent("orchestrator").Info("Recalled prior solution",
+			zap.String("intent_id", r.Entry.IntentID),
+			zap.String("capsule_id", r.Entry.CapsuleID),
+			zap.Float64("similarity", r.Similarity))
+	}
+	o.dagExecutor.SetPriorSolutions(vector.PromptContext(recollections, o.memoryClone))
+}
+
+// rememberSolution stores a completed intent and its capsule in generation memory
+func (o *Orchestrator) rememberSolution(ctx context.Context, intent *models.Intent, capsule *packaging.QLCapsule) {
+	if o.memory == nil {
+		return
+	}
+
+	descriptions := make([]string, 0, len(intent.Tasks))
+	outputs := make(map[string]string)
+	for _, task := range intent.Tasks {
+		descriptions = append(descriptions, task.Description)
+		if result, ok := o.executionResults[task.ID]; ok && result.Status == "completed" {
+			outputs[string(task.Type)] += result.Output + "\n"
+		}
+	}
+
+	entry := vector.MemoryEntry{
+		IntentID:     intent.ID,
+		CapsuleID:    capsule.Metadata.CapsuleID,
+		UserInput:    intent.UserInput,
+		Summary:      strings.Join(descriptions, "; "),
+		OverallScore: capsule.Metadata.OverallScore,
+		TaskOutputs:  outputs,
+	}
+	if err := o.memory.Remember(ctx, entry); err != nil {
+		logger.WithComponent("orchestrator").Warn("Failed to remember solution",
+			zap.Error(err))
+	}
+}
+
 func (o *Orchestrator) collectAgentResults(tasks []models.Task) map[string]*packaging.AgentExecutionResult {
 	results := make(map[string]*packaging.AgentExecutionResult)
 	
@@ -349,6 +790,11 @@ func (o *Orchestrator) processHITLDecisions(ctx context.Context, intent models.I
 	
 	for i := range o.quantumDrops {
 		drop := &o.quantumDrops[i]
+
+		// Drops kept from an earlier run of this intent were already reviewed
+		if drop.Status == packaging.DropStatusApproved || drop.Status == packaging.DropStatusModified || drop.Status == packaging.DropStatusRejected {
+			continue
+		}
 		
 		if !drop.Metadata.HITLRequired {
 			// Auto-approve drops that don't require HITL
@@ -362,6 +808,7 @@ func (o *Orchestrator) processHITLDecisions(ctx context.Context, intent models.I
 		// For production, this would interface with actual UI/CLI for human input
 		// For now, simulate intelligent auto-decision based on validation scores
 		decision := o.simulateHITLDecision(*drop)
+		o.hitlDecisions = append(o.hitlDecisions, decision)
 		
 		switch decision.Decision {
 		case packaging.HITLActionContinue:
@@ -404,6 +851,125 @@ func (o *Orchestrator) processHITLDecisions(ctx context.Context, intent models.I
 	return nil
 }
 
+// prepareDrop remediates common findings, lints Dockerfiles and PowerShell scripts, checks the
+// intent's constraints and, for codebases, resolves imports and module names across the generated
+// files and pins dependencies before review. It returns the constraint violations found.
+func (o *Orchestrator) prepareDrop(ctx context.Context, intent *models.Intent, drop *packaging.QuantumDrop) []constraints.Violation {
+	o.remediateDrop(drop)
+	o.validateDockerfiles(ctx, drop)
+	o.validatePowerShell(ctx, drop)
+	violations := o.checkConstraints(intent, drop)
+	if drop.Type != packaging.DropTypeCodebase {
+		return violations
+	}
+	report := o.quantumDropGen.ReconcileDrop(ctx, drop)
+	logger.WithComponent("orchestrator").Info("Cross-file consistency checked",
+		zap.String("drop_id", drop.ID),
+		zap.String("module", report.Module),
+		zap.Int("unresolved_issues", len(report.Issues)),
+		zap.Strings("fixes", report.FixedBy))
+	o.quantumDropGen.PinDependencies(ctx, drop)
+	return violations
+}
+
+// remediateDrop patches the findings the remediation engine fixes. With
+// QLP_AUTO_REMEDIATE the patches are applied to the drop, otherwise they are
+// offered in review notes and the capsule's remediation.patch.
+func (o *Orchestrator) remediateDrop(drop *packaging.QuantumDrop) {
+	if !o.remediation {
+		return
+	}
+	patches := remediate.Suggest(drop.Files)
+	if len(patches) == 0 {
+		return
+	}
+	if o.autoRemediate {
+		applied := remediate.Apply(drop.Files, patches)
+		drop.Metadata.FileCount = len(drop.Files)
+		for _, p := range patches {
+			drop.Metadata.ReviewNotes = append(drop.Metadata.ReviewNotes,
+				fmt.Sprintf("Auto-remediated %s: %s", p.File, strings.Join(p.Changes, "; ")))
+		}
+		logger.WithComponent("orchestrator").Info("Applied remediation patches",
+			zap.String("drop_id", drop.ID),
+			zap.Strings("files", applied))
+		return
+	}
+	for _, p := range patches {
+		drop.Metadata.ReviewNotes = append(drop.Metadata.ReviewNotes,
+			fmt.Sprintf("Remediation patch available for %s: %s", p.File, strings.Join(p.Changes, "; ")))
+	}
+}
+
+// checkConstraints validates a drop's files against the intent's constraints;
+// violations are recorded as review notes and send the drop to review
+func (o *Orchestrator) checkConstraints(intent *models.Intent, drop *packaging.QuantumDrop) []constraints.Violation {
+	violations := constraints.Validate(intent.Constraints, drop.Files)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	for _, v := range violations {
+		drop.Metadata.ReviewNotes = append(drop.Metadata.ReviewNotes, "Constraint violation "+v.String())
+	}
+	drop.Metadata.ValidationPassed = false
+	drop.Metadata.HITLRequired = true
+
+	logger.WithComponent("orchestrator").Warn("Drop violates intent constraints",
+		zap.String("drop_id", drop.ID),
+		zap.Int("violations", len(violations)),
+		zap.String("first", violations[0].String()))
+	return violations
+}
+
+// validateDockerfiles lints any Dockerfiles in a drop and records findings and
+// auto-fix suggestions as review notes. High severity findings or a failed
+// multi-arch build send the drop to review.
+func (o *Orchestrator) validateDockerfiles(ctx context.Context, drop *packaging.QuantumDrop) {
+	for _, result := range o.dockerfileLinter.ValidateFiles(ctx, drop.Files) {
+		for _, issue := range result.Issues() {
+			drop.Metadata.ReviewNotes = append(drop.Metadata.ReviewNotes,
+				fmt.Sprintf("[%s] %s: %s (fix: %s)", issue.Severity, issue.Resource, issue.Message, issue.Remediation))
+			if issue.Severity == "HIGH" || issue.Severity == "CRITICAL" {
+				drop.Metadata.ValidationPassed = false
+				drop.Metadata.HITLRequired = true
+			}
+		}
+		if len(result.AppliedFixes) > 0 {
+			drop.Metadata.ReviewNotes = append(drop.Metadata.ReviewNotes,
+				fmt.Sprintf("Auto-fix available for %s: %s", result.Path, strings.Join(result.AppliedFixes, "; ")))
+		}
+
+		logger.WithComponent("orchestrator").Info("Dockerfile validated",
+			zap.String("drop_id", drop.ID),
+			zap.String("dockerfile", result.Path),
+			zap.Int("score", result.Score),
+			zap.Int("findings", len(result.Findings)))
+	}
+}
+
+// validatePowerShell lints any PowerShell scripts in a drop and records
+// findings as review notes. High severity findings send the drop to review.
+func (o *Orchestrator) validatePowerShell(ctx context.Context, drop *packaging.QuantumDrop) {
+	for _, result := range o.powershellLinter.ValidateFiles(ctx, drop.Files) {
+		for _, issue := range result.Issues() {
+			drop.Metadata.ReviewNotes = append(drop.Metadata.ReviewNotes,
+				fmt.Sprintf("[%s] %s: %s (fix: %s)", issue.Severity, issue.Resource, issue.Message, issue.Remediation))
+			if issue.Severity == "HIGH" {
+				drop.Metadata.ValidationPassed = false
+				drop.Metadata.HITLRequired = true
+			}
+		}
+
+		logger.WithComponent("orchestrator").Info("PowerShell script validated",
+			zap.String("drop_id", drop.ID),
+			zap.String("script", result.Path),
+			zap.String("analyzer", result.Analyzer),
+			zap.Int("score", result.Score),
+			zap.Int("findings", len(result.Findings)))
+	}
+}
+
 // simulateHITLDecision simulates intelligent human decision making based on validation scores
 func (o *Orchestrator) simulateHITLDecision(drop packaging.QuantumDrop) packaging.HITLDecision {
 	decision := packaging.HITLDecision{
@@ -0,0 +1,353 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/config"
+	"QLP/internal/dag"
+	"QLP/internal/events"
+	"QLP/internal/failures"
+	"QLP/internal/logger"
+	"QLP/internal/models"
+	"QLP/internal/packaging"
+	"QLP/internal/tracing"
+	"QLP/internal/types"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// What an intent produces when some of its tasks fail, set by
+// QLP_PARTIAL_RESULTS
+const (
+	PartialPackage = "package" // Package the drops of the tasks that completed; the intent is partial
+	PartialFail    = "fail"    // Fail the intent without a capsule
+)
+
+// ErrNothingToRetry is returned by RetryFailed for intents whose tasks all
+// completed
+var ErrNothingToRetry = errors.New("intent has no failed tasks")
+
+// RetryResult reports which failed tasks completed on retry
+type RetryResult struct {
+	IntentID  string               `json:"intent_id"`
+	Status    models.IntentStatus  `json:"status"`
+	Retried   []string             `json:"retried_tasks"`
+	Recovered []string             `json:"recovered_tasks"`
+	Failed    []string             `json:"failed_tasks,omitempty"`
+	Capsule   *packaging.QLCapsule `json:"-"`
+}
+
+// recordTaskStatuses copies the outcome of each executed task onto the
+// intent, so its record shows which tasks completed, failed or were skipped
+func (o *Orchestrator) recordTaskStatuses(intent *models.Intent) {
+	for i := range intent.Tasks {
+		task := &intent.Tasks[i]
+		status := o.dagExecutor.TaskStatus(task.ID)
+		if status == "" {
+			continue
+		}
+		task.Status, task.Error = status, ""
+		r := o.dagExecutor.GetTaskResult(task.ID)
+		if r != nil {
+			task.AgentID = r.AgentID
+		}
+		switch {
+		case status == models.TaskStatusCompleted && r != nil:
+			completedAt := r.EndTime
+			task.CompletedAt = &completedAt
+		case status == models.TaskStatusFailed && r != nil && r.Error != nil:
+			task.Error = r.Error.Error()
+		case status == models.TaskStatusSkipped:
+			task.Error = "skipped: depends on a failed task"
+		}
+	}
+}
+
+// UnfinishedTasks returns the tasks of intent that failed or were skipped
+func UnfinishedTasks(intent *models.Intent) []string {
+	var ids []string
+	for _, task := range intent.Tasks {
+		if task.Status == models.TaskStatusFailed || task.Status == models.TaskStatusSkipped {
+			ids = append(ids, task.ID)
+		}
+	}
+	return ids
+}
+
+// packagesPartial reports whether an intent whose tasks partly failed is
+// still packaged: the policy allows it and some task completed
+func (o *Orchestrator) packagesPartial(intent *models.Intent) bool {
+	if o.partialResults != PartialPackage {
+		return false
+	}
+	for _, task := range intent.Tasks {
+		if task.Status == models.TaskStatusCompleted {
+			return true
+		}
+	}
+	return false
+}
+
+// completionStatus is completed when every task completed, partial otherwise
+func completionStatus(intent *models.Intent) models.IntentStatus {
+	if len(UnfinishedTasks(intent)) > 0 {
+		return models.IntentStatusPartial
+	}
+	return models.IntentStatusCompleted
+}
+
+// runRecord is what retrying the failed tasks of an intent needs, possibly
+// in another process: the intent with its task statuses and the results of
+// the tasks that completed
+type runRecord struct {
+	Intent  *models.Intent         `json:"intent"`
+	Results map[string]savedResult `json:"results"`
+	SavedAt time.Time              `json:"saved_at"`
+}
+
+// savedResult is the part of a completed task's result that packaging uses
+type savedResult struct {
+	AgentID          string                  `json:"agent_id"`
+	Output           string                  `json:"output"`
+	ExecutionTime    time.Duration           `json:"execution_time"`
+	ValidationResult *types.ValidationResult `json:"validation_result,omitempty"`
+	StartTime        time.Time               `json:"start_time"`
+	EndTime          time.Time               `json:"end_time"`
+}
+
+// runRecordPath keeps run records next to the drain checkpoints, in
+// QLP_CHECKPOINT_DIR (default ./data/checkpoints)
+func runRecordPath(intentID string) string {
+	return filepath.Join(config.GetEnvOrDefault("QLP_CHECKPOINT_DIR", "./data/checkpoints"), "intents", intentID+".json")
+}
+
+// saveRunRecord writes the run record of an intent with failed or
+// checkpointed tasks.
+// Failures are logged; the intent itself does not depend on the record.
+func (o *Orchestrator) saveRunRecord(intent *models.Intent) {
+	record := runRecord{Intent: intent, Results: make(map[string]savedResult), SavedAt: time.Now()}
+	for _, task := range intent.Tasks {
+		if r := o.dagExecutor.GetTaskResult(task.ID); r != nil && task.Status == models.TaskStatusCompleted {
+			record.Results[task.ID] = savedResult{
+				AgentID:          r.AgentID,
+				Output:           r.Output,
+				ExecutionTime:    r.ExecutionTime,
+				ValidationResult: r.ValidationResult,
+				StartTime:        r.StartTime,
+				EndTime:          r.EndTime,
+			}
+		}
+	}
+
+	path := runRecordPath(intent.ID)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err == nil {
+		var data []byte
+		if data, err = json.Marshal(record); err == nil {
+			err = os.WriteFile(path, data, 0644)
+		}
+	}
+	if err != nil {
+		logger.WithComponent("orchestrator").Warn("Failed to save run record, failed tasks cannot be retried",
+			zap.String("intent_id", intent.ID),
+			zap.Error(err))
+	}
+}
+
+// loadRunRecord reads the run record of an intent
+func loadRunRecord(intentID string) (*runRecord, error) {
+	data, err := os.ReadFile(runRecordPath(intentID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no failed tasks recorded for intent %s: %w", intentID, ErrNothingToRetry)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run record: %w", err)
+	}
+	var record runRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse run record: %w", err)
+	}
+	if record.Intent == nil {
+		return nil, fmt.Errorf("run record of intent %s has no intent", intentID)
+	}
+	return &record, nil
+}
+
+// RetryFailed re-runs only the tasks of an intent that failed or were
+// skipped, against the saved results of those that completed, and packages
+// a new capsule. Retried capsules are not composed into workspaces again.
+func (o *Orchestrator) RetryFailed(ctx context.Context, intentID string) (result *RetryResult, err error) {
+	record, err := loadRunRecord(intentID)
+	if err != nil {
+		return nil, err
+	}
+	retry := UnfinishedTasks(record.Intent)
+	if len(retry) == 0 {
+		return nil, ErrNothingToRetry
+	}
+	return o.rerun(ctx, record, retry)
+}
+
+// ResumeCheckpoint re-runs the tasks a drain checkpointed, against the saved
+// results of those that completed, and packages the capsule the interrupted
+// run would have. The checkpoint is removed once its tasks ran, or once
+// a new drain checkpointed them again.
+func (o *Orchestrator) ResumeCheckpoint(ctx context.Context, path string) (*RetryResult, error) {
+	checkpoint, pending, err := dag.LoadCheckpoint(path)
+	if err != nil {
+		return nil, err
+	}
+	if checkpoint.IntentID == "" {
+		return nil, fmt.Errorf("checkpoint %s does not name its intent", path)
+	}
+	record, err := loadRunRecord(checkpoint.IntentID)
+	if err != nil {
+		return nil, err
+	}
+	resume := make([]string, 0, len(pending.Tasks))
+	for _, task := range pending.Tasks {
+		resume = append(resume, task.ID)
+	}
+	if len(resume) == 0 {
+		return nil, ErrNothingToRetry
+	}
+
+	result, err := o.rerun(ctx, record, resume)
+	if err == nil || errors.Is(err, dag.ErrDraining) {
+		if rmErr := os.Remove(path); rmErr != nil {
+			logger.WithComponent("orchestrator").Warn("Failed to remove resumed checkpoint",
+				zap.String("path", path),
+				zap.Error(rmErr))
+		}
+	}
+	return result, err
+}
+
+// rerun executes the retry tasks of a run record and packages a new capsule
+func (o *Orchestrator) rerun(ctx context.Context, record *runRecord, retry []string) (result *RetryResult, err error) {
+	intent := record.Intent
+
+	ctx, span := tracing.StartSpan(ctx, "intent.retry_failed",
+		attribute.String("intent.id", intent.ID),
+		attribute.Int("retry.tasks", len(retry)))
+	defer func() { tracing.EndSpan(span, err) }()
+	defer func() {
+		failures.Record(ctx, failures.Failure{Stage: failures.StageIntent, RunID: tracing.TraceID(ctx)}, err)
+	}()
+	ctx = audit.WithIntent(ctx, intent.ID)
+	result = &RetryResult{IntentID: intent.ID, Retried: retry}
+
+	logger.WithComponent("orchestrator").Info("Retrying failed tasks",
+		zap.String("intent_id", intent.ID),
+		zap.Strings("tasks", retry))
+
+	restored := make(map[string]*dag.TaskResult, len(record.Results))
+	for id, r := range record.Results {
+		restored[id] = &dag.TaskResult{
+			AgentID:          r.AgentID,
+			Output:           r.Output,
+			ExecutionTime:    r.ExecutionTime,
+			ValidationResult: r.ValidationResult,
+			StartTime:        r.StartTime,
+			EndTime:          r.EndTime,
+		}
+	}
+	o.dagExecutor.RestoreResults(restored)
+	taskGraph, err := o.buildTaskGraph(intent.Tasks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build task graph: %w", err)
+	}
+	o.taskGraph = taskGraph
+
+	o.feedDiagnostics(ctx, intent.ID, retry)
+	execErr := o.dagExecutor.ExecuteTaskGraph(ctx, dag.Subgraph(taskGraph, retry))
+	if errors.Is(execErr, dag.ErrDraining) {
+		o.recordTaskStatuses(intent)
+		o.saveRunRecord(intent)
+	}
+	if execErr != nil && !errors.Is(execErr, dag.ErrTasksFailed) {
+		return nil, fmt.Errorf("failed to re-execute tasks: %w", execErr)
+	}
+	o.recordTaskStatuses(intent)
+	o.executionResults = o.collectAgentResults(taskGraph.Tasks)
+	result.Failed = UnfinishedTasks(intent)
+	failed := make(map[string]bool, len(result.Failed))
+	for _, id := range result.Failed {
+		failed[id] = true
+	}
+	for _, id := range retry {
+		if !failed[id] {
+			result.Recovered = append(result.Recovered, id)
+		}
+	}
+	if len(result.Failed) > 0 {
+		o.saveRunRecord(intent)
+		if !o.packagesPartial(intent) {
+			result.Status = models.IntentStatusFailed
+			return result, fmt.Errorf("failed to re-execute tasks: %w", execErr)
+		}
+	}
+
+	quantumDrops, err := o.quantumDropGen.GenerateQuantumDrops(*intent, o.convertToTaskExecutionResults(taskGraph.Tasks))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate QuantumDrops: %w", err)
+	}
+	var violations []string
+	for i := range quantumDrops {
+		for _, v := range o.prepareDrop(ctx, intent, &quantumDrops[i]) {
+			violations = append(violations, v.String())
+		}
+	}
+	if len(violations) > 0 && intent.Constraints.Strict() {
+		return nil, fmt.Errorf("%w: %s", ErrConstraintViolation, violations[0])
+	}
+	o.quantumDrops = append(quantumDrops, o.derivedDrops(ctx, intent, quantumDrops)...)
+	o.hitlDecisions = nil
+	if o.hitlEnabled {
+		if err := o.processHITLDecisions(ctx, *intent); err != nil {
+			return nil, fmt.Errorf("failed to process HITL decisions: %w", err)
+		}
+	} else {
+		o.autoApproveAllDrops()
+	}
+
+	capsule, err := o.generateQuantumCapsule(ctx, *intent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate QuantumCapsule: %w", err)
+	}
+	o.sealDiagnostics(ctx, intent.ID, capsule)
+	capsule.Metadata.Environment["retried_tasks"] = retry
+	if len(result.Failed) > 0 {
+		capsule.Metadata.Environment["failed_tasks"] = result.Failed
+	}
+
+	intent.Status = completionStatus(intent)
+	intent.OverallScore = capsule.Metadata.OverallScore
+	completedAt := time.Now()
+	intent.CompletedAt = &completedAt
+	intent.UpdatedAt = completedAt
+	if err := o.intentRepo.UpdateWithEvents(o.outbox, intent, intentEvent(ctx, events.EventIntentCompleted, intent)); err != nil {
+		logger.WithComponent("orchestrator").Warn("Failed to update intent in database",
+			zap.Error(err))
+	}
+	o.saveRunRecord(intent)
+	o.lastIntent = intent
+	o.lastCapsuleID = capsule.Metadata.CapsuleID
+	result.Status = intent.Status
+	result.Capsule = capsule
+
+	logger.WithComponent("orchestrator").Info("Failed tasks retried",
+		zap.String("intent_id", intent.ID),
+		zap.String("capsule_id", capsule.Metadata.CapsuleID),
+		zap.Strings("recovered", result.Recovered),
+		zap.Strings("failed", result.Failed))
+	return result, nil
+}
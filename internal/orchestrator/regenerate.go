@@ -0,0 +1,248 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"QLP/internal/audit"
+	"QLP/internal/dag"
+	"QLP/internal/logger"
+	"QLP/internal/models"
+	"QLP/internal/packaging"
+	"QLP/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// ErrNothingToRegenerate is returned when no intent has completed in this session
+var ErrNothingToRegenerate = errors.New("no completed intent to regenerate")
+
+// RegenerationRequest selects part of the last capsule to regenerate. Target
+// is a drop ID, drop type (e.g. "infrastructure"), task ID, or the path of a
+// generated file such as "Dockerfile".
+type RegenerationRequest struct {
+	Target      string `json:"target"`
+	Requirement string `json:"requirement,omitempty"`
+}
+
+// RegenerationResult reports which tasks were re-run and which drops were
+// replaced or kept intact
+type RegenerationResult struct {
+	Regenerated   []string             `json:"regenerated_tasks"`
+	Invalidated   []string             `json:"invalidated_tasks,omitempty"`
+	ReplacedDrops []string             `json:"replaced_drops"`
+	KeptDrops     []string             `json:"kept_drops,omitempty"`
+	Violations    []string             `json:"constraint_violations,omitempty"`
+	Capsule       *packaging.QLCapsule `json:"-"`
+}
+
+// ParseRegenerationCommand recognizes "regenerate <target>[: requirement]"
+func ParseRegenerationCommand(text string) (RegenerationRequest, bool) {
+	text = strings.TrimSpace(text)
+	lower := strings.ToLower(text)
+	if !strings.HasPrefix(lower, "regenerate ") {
+		return RegenerationRequest{}, false
+	}
+
+	rest := strings.TrimSpace(text[len("regenerate "):])
+	var req RegenerationRequest
+	if target, requirement, ok := strings.Cut(rest, ":"); ok {
+		req.Target, req.Requirement = strings.TrimSpace(target), strings.TrimSpace(requirement)
+	} else {
+		req.Target = rest
+	}
+	req.Target = strings.TrimPrefix(req.Target, "the ")
+	return req, req.Target != ""
+}
+
+// Regenerate re-runs the tasks behind one drop, task or file of the last
+// capsule, plus every task downstream of them, and rebuilds the capsule. Drops
+// untouched by the re-run keep their content and review decisions.
+func (o *Orchestrator) Regenerate(ctx context.Context, req RegenerationRequest) (result *RegenerationResult, err error) {
+	if o.lastIntent == nil || o.taskGraph == nil {
+		return nil, ErrNothingToRegenerate
+	}
+	intent := o.lastIntent
+
+	ctx, span := tracing.StartSpan(ctx, "intent.regenerate",
+		attribute.String("intent.id", intent.ID),
+		attribute.String("regenerate.target", req.Target))
+	defer func() { tracing.EndSpan(span, err) }()
+	ctx = audit.WithIntent(ctx, intent.ID)
+
+	targets, err := o.resolveRegenerationTargets(req.Target)
+	if err != nil {
+		return nil, err
+	}
+	downstream := dag.Downstream(o.taskGraph, targets)
+	result = &RegenerationResult{Regenerated: targets, Invalidated: downstream}
+
+	logger.WithComponent("orchestrator").Info("Regenerating part of capsule",
+		zap.String("intent_id", intent.ID),
+		zap.String("target", req.Target),
+		zap.Strings("tasks", targets),
+		zap.Strings("invalidated", downstream))
+
+	if req.Requirement != "" {
+		o.addTaskRequirement(targets, req.Requirement)
+	}
+
+	rerun := append(append([]string{}, targets...), downstream...)
+	o.feedDiagnostics(ctx, intent.ID, rerun)
+	if err := o.dagExecutor.ExecuteTaskGraph(ctx, dag.Subgraph(o.taskGraph, rerun)); err != nil {
+		return nil, fmt.Errorf("failed to re-execute tasks: %w", err)
+	}
+	for id, r := range o.collectAgentResults(o.taskGraph.Tasks) {
+		o.executionResults[id] = r
+	}
+
+	quantumDrops, err := o.quantumDropGen.GenerateQuantumDrops(*intent, o.convertToTaskExecutionResults(o.taskGraph.Tasks))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate QuantumDrops: %w", err)
+	}
+	merged := o.mergeRegeneratedDrops(ctx, intent, quantumDrops, rerun, result)
+	o.quantumDrops = append(merged, o.derivedDrops(ctx, intent, merged)...)
+	if len(result.Violations) > 0 && intent.Constraints.Strict() {
+		return result, fmt.Errorf("%w: %s", ErrConstraintViolation, result.Violations[0])
+	}
+
+	if o.hitlEnabled {
+		if err := o.processHITLDecisions(ctx, *intent); err != nil {
+			return nil, fmt.Errorf("failed to process HITL decisions: %w", err)
+		}
+	} else {
+		o.autoApproveAllDrops()
+	}
+
+	capsule, err := o.generateQuantumCapsule(ctx, *intent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate QuantumCapsule: %w", err)
+	}
+	o.sealDiagnostics(ctx, intent.ID, capsule)
+	capsule.Metadata.Environment["regenerated_from"] = o.lastCapsuleID
+	capsule.Metadata.Environment["regenerated_tasks"] = rerun
+	o.lastCapsuleID = capsule.Metadata.CapsuleID
+	result.Capsule = capsule
+
+	logger.WithComponent("orchestrator").Info("Capsule regenerated",
+		zap.String("capsule_id", capsule.Metadata.CapsuleID),
+		zap.Strings("replaced_drops", result.ReplacedDrops),
+		zap.Strings("kept_drops", result.KeptDrops))
+	return result, nil
+}
+
+// resolveRegenerationTargets maps a target to the task IDs that produce it
+func (o *Orchestrator) resolveRegenerationTargets(target string) ([]string, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return nil, fmt.Errorf("regeneration target is required")
+	}
+
+	for _, task := range o.taskGraph.Tasks {
+		if task.ID == target {
+			return []string{task.ID}, nil
+		}
+	}
+
+	for _, drop := range o.quantumDrops {
+		if strings.EqualFold(drop.ID, target) || strings.EqualFold(string(drop.Type), target) {
+			if len(drop.Tasks) == 0 {
+				return nil, fmt.Errorf("drop %s has no tasks to regenerate", drop.ID)
+			}
+			return drop.Tasks, nil
+		}
+	}
+
+	// A generated file: narrow to the drop's tasks whose output mentions it
+	for _, drop := range o.quantumDrops {
+		for filePath := range drop.Files {
+			if !strings.EqualFold(filePath, target) && !strings.EqualFold(path.Base(filePath), target) {
+				continue
+			}
+			var producers []string
+			for _, taskID := range drop.Tasks {
+				if r, ok := o.executionResults[taskID]; ok && strings.Contains(r.Output, path.Base(filePath)) {
+					producers = append(producers, taskID)
+				}
+			}
+			if len(producers) == 0 {
+				producers = drop.Tasks
+			}
+			return producers, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no drop, task or file matches %q", target)
+}
+
+// addTaskRequirement appends an extra requirement to the targeted tasks so
+// their agents see it on the next execution. The graph shares its task slice
+// with the intent, so both see the change.
+func (o *Orchestrator) addTaskRequirement(taskIDs []string, requirement string) {
+	selected := make(map[string]bool, len(taskIDs))
+	for _, id := range taskIDs {
+		selected[id] = true
+	}
+	note := "\n\nAdditional requirement: " + requirement
+
+	for i := range o.taskGraph.Tasks {
+		if selected[o.taskGraph.Tasks[i].ID] {
+			o.taskGraph.Tasks[i].Description += note
+		}
+	}
+}
+
+// mergeRegeneratedDrops replaces drops built from re-run tasks and keeps the
+// rest of the previous drops unchanged
+func (o *Orchestrator) mergeRegeneratedDrops(ctx context.Context, intent *models.Intent, generated []packaging.QuantumDrop, rerun []string, result *RegenerationResult) []packaging.QuantumDrop {
+	rerunSet := make(map[string]bool, len(rerun))
+	for _, id := range rerun {
+		rerunSet[id] = true
+	}
+	affected := func(drop packaging.QuantumDrop) bool {
+		for _, id := range drop.Tasks {
+			if rerunSet[id] {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Derived drops are written again from the merged drops
+	previous := make(map[packaging.DropType]packaging.QuantumDrop, len(o.quantumDrops))
+	for _, drop := range o.quantumDrops {
+		if !drop.Metadata.Derived {
+			previous[drop.Type] = drop
+		}
+	}
+
+	merged := make([]packaging.QuantumDrop, 0, len(generated))
+	seen := make(map[packaging.DropType]bool)
+	for _, drop := range generated {
+		seen[drop.Type] = true
+		if old, ok := previous[drop.Type]; ok && !affected(old) && !affected(drop) {
+			merged = append(merged, old)
+			result.KeptDrops = append(result.KeptDrops, old.ID)
+			continue
+		}
+		for _, v := range o.prepareDrop(ctx, intent, &drop) {
+			result.Violations = append(result.Violations, v.String())
+		}
+		merged = append(merged, drop)
+		result.ReplacedDrops = append(result.ReplacedDrops, drop.ID)
+	}
+
+	// Keep earlier drops whose type produced nothing this time only if none of
+	// their tasks were re-run
+	for _, old := range o.quantumDrops {
+		if !seen[old.Type] && !affected(old) && !old.Metadata.Derived {
+			merged = append(merged, old)
+			result.KeptDrops = append(result.KeptDrops, old.ID)
+		}
+	}
+	return merged
+}
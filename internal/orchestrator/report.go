@@ -0,0 +1,98 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"QLP/internal/audit"
+	"QLP/internal/cloudcost"
+	"QLP/internal/codemetrics"
+	"QLP/internal/config"
+	"QLP/internal/constraints"
+	"QLP/internal/logger"
+	"QLP/internal/models"
+	"QLP/internal/packaging"
+	"QLP/internal/remediate"
+	"QLP/internal/report"
+	"QLP/internal/requirements"
+
+	"go.uber.org/zap"
+)
+
+// reportFormats returns the report formats from QLP_REPORT_FORMATS; "none"
+// disables report rendering
+func reportFormats() []string {
+	value := config.GetEnvOrDefault("QLP_REPORT_FORMATS", "html,markdown,sarif")
+	if value == "none" {
+		return nil
+	}
+	var formats []string
+	for _, f := range strings.Split(value, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			formats = append(formats, f)
+		}
+	}
+	return formats
+}
+
+// reportRenderer renders the validation report for each capsule, including
+// the HITL decisions taken on its drops, the code hotspots, the remediation patches for its
+// project, the coverage of the requirements document attached to the intent and, when the
+// intent leaves the cloud provider open, the cost of its architecture on each provider
+func (o *Orchestrator) reportRenderer(formats []string) packaging.ReportRenderer {
+	return func(ctx context.Context, intent models.Intent, capsule *packaging.QLCapsule) map[string][]byte {
+		r := report.FromCapsule(capsule)
+		r.AddDecisions(o.hitlDecisions)
+		if capsule.UnifiedProject != nil {
+			r.AddCodeMetrics(codemetrics.Analyze(capsule.UnifiedProject.Files))
+		}
+		var patches []remediate.Patch
+		if o.remediation && capsule.UnifiedProject != nil {
+			patches = remediate.Suggest(capsule.UnifiedProject.Files)
+			r.AddPatches(patches)
+		}
+		if o.prices != nil && capsule.UnifiedProject != nil &&
+			cloudcost.Agnostic(intent.UserInput, constraints.Resolve(audit.TenantFromContext(ctx), intent.Constraints)) {
+			r.AddCostComparison(cloudcost.Compare(cloudcost.Analyze(capsule.UnifiedProject.Files), o.prices))
+		}
+		var coverage *requirements.Matrix
+		if document := requirements.FromIntent(intent); document != nil {
+			coverage = requirements.NewMatrix(document, capsule.Tasks, o.quantumDrops)
+			r.AddRequirements(coverage)
+		}
+
+		reports := make(map[string][]byte)
+		for _, format := range formats {
+			data, _, err := report.Render(r, format)
+			if err != nil {
+				logger.WithComponent("orchestrator").Warn("Failed to render validation report",
+					zap.String("format", format),
+					zap.Error(err))
+				continue
+			}
+			reports["report."+reportExtension(format)] = data
+		}
+		if len(patches) > 0 {
+			reports[remediate.PatchFile] = []byte(remediate.Combined(patches))
+		}
+		if coverage != nil {
+			if data, err := json.MarshalIndent(coverage, "", "  "); err == nil {
+				reports[requirements.TraceabilityFile] = data
+			}
+		}
+		return reports
+	}
+}
+
+func reportExtension(format string) string {
+	switch format {
+	case report.FormatMarkdown:
+		return "md"
+	case report.FormatSARIF:
+		return "sarif"
+	case report.FormatJUnit:
+		return "junit.xml"
+	}
+	return format
+}
@@ -0,0 +1,38 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"QLP/internal/dag"
+)
+
+// ScaleStatus is the load signal for KEDA's metrics-api scaler or an HPA
+// external metric: scale on pending_tasks + queue_depth against
+// max_concurrency per replica
+type ScaleStatus struct {
+	dag.ScaleStatus
+	QueueDepth int `json:"queue_depth"`
+}
+
+// ScaleStatus returns the current executor and event queue load
+func (o *Orchestrator) ScaleStatus() ScaleStatus {
+	return ScaleStatus{ScaleStatus: o.dagExecutor.ScaleStatus(), QueueDepth: o.eventBus.QueueDepth()}
+}
+
+// ScaleRoutes returns GET /scale-status. A draining replica answers 503 so
+// readiness probes take it out of rotation while it finishes its tasks.
+func (o *Orchestrator) ScaleRoutes() map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /scale-status": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			status := o.ScaleStatus()
+			code := http.StatusOK
+			if status.Draining {
+				code = http.StatusServiceUnavailable
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(status)
+		}),
+	}
+}
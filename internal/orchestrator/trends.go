@@ -0,0 +1,34 @@
+package orchestrator
+
+import (
+	"context"
+	"time"
+
+	"QLP/internal/packaging"
+	"QLP/internal/trends"
+	"QLP/internal/workspace"
+)
+
+// recordTrends adds the quality samples of a completed intent: its capsule
+// score, how long it took and whether each drop went without review. The
+// workspace names the project; standalone intents have none.
+func (o *Orchestrator) recordTrends(ctx context.Context, ws *workspace.Workspace, capsule *packaging.QLCapsule, executionTime time.Duration) {
+	sample := trends.Sample{CapsuleID: capsule.Metadata.CapsuleID}
+	if ws != nil {
+		sample.Project = ws.Name
+	}
+
+	sample.Metric, sample.Value = trends.ValidationScore, float64(capsule.Metadata.OverallScore)
+	trends.Record(ctx, sample)
+	sample.Metric, sample.Value = trends.GenerationLatency, executionTime.Seconds()
+	trends.Record(ctx, sample)
+
+	sample.Metric = trends.AutoApproval
+	for _, drop := range o.quantumDrops {
+		sample.Value = 0
+		if !o.hitlEnabled || !drop.Metadata.HITLRequired {
+			sample.Value = 1
+		}
+		trends.Record(ctx, sample)
+	}
+}
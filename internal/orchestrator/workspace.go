@@ -0,0 +1,84 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"QLP/internal/audit"
+	"QLP/internal/logger"
+	"QLP/internal/models"
+	"QLP/internal/packaging"
+	"QLP/internal/workspace"
+
+	"go.uber.org/zap"
+)
+
+// SetWorkspaceStore enables multi-intent workspaces: intents processed with a
+// workspace reference in their context extend that project
+func (o *Orchestrator) SetWorkspaceStore(store *workspace.Store) {
+	o.workspaces = store
+}
+
+// resolveWorkspace returns the workspace referenced by ctx, creating it when a
+// new name is given. It returns nil for standalone intents.
+func (o *Orchestrator) resolveWorkspace(ctx context.Context) (*workspace.Workspace, error) {
+	ref := workspace.FromContext(ctx)
+	if ref == "" || o.workspaces == nil {
+		return nil, nil
+	}
+
+	ws, err := o.workspaces.Resolve(ref)
+	if errors.Is(err, workspace.ErrNotFound) && ref != "last" {
+		ws, err = o.workspaces.Create(ref, audit.TenantFromContext(ctx))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open workspace %q: %w", ref, err)
+	}
+
+	logger.WithComponent("orchestrator").Info("Extending workspace",
+		zap.String("workspace_id", ws.ID),
+		zap.String("workspace", ws.Name),
+		zap.Int("services", len(ws.Services)))
+	return ws, nil
+}
+
+// composeIntoWorkspace adds the approved drops of a completed intent to its
+// workspace as a new service alongside the existing ones
+func (o *Orchestrator) composeIntoWorkspace(ws *workspace.Workspace, intent *models.Intent, capsuleID string) error {
+	add := workspace.Addition{
+		IntentID:   intent.ID,
+		IntentText: intent.UserInput,
+		CapsuleID:  capsuleID,
+		Files:      make(map[string]string),
+		Infra:      make(map[string]string),
+	}
+	for _, drop := range o.quantumDrops {
+		if drop.Status != packaging.DropStatusApproved && drop.Status != packaging.DropStatusModified {
+			continue
+		}
+		target := add.Files
+		if drop.Type == packaging.DropTypeInfrastructure {
+			target = add.Infra
+		}
+		for p, content := range drop.Files {
+			target[p] = content
+		}
+	}
+
+	composition, err := o.workspaces.Apply(ws, add)
+	if err != nil {
+		return err
+	}
+	for _, conflict := range composition.Conflicts {
+		logger.WithComponent("orchestrator").Warn("Workspace infrastructure conflict",
+			zap.String("workspace_id", ws.ID),
+			zap.String("conflict", conflict))
+	}
+	logger.WithComponent("orchestrator").Info("Composed intent into workspace",
+		zap.String("workspace_id", ws.ID),
+		zap.String("service", composition.Service),
+		zap.Int("files", len(composition.Written)))
+	intent.Metadata["workspace.service"] = composition.Service
+	return nil
+}
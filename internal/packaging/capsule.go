@@ -8,14 +8,21 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"time"
 
+	"QLP/internal/llm"
 	"QLP/internal/models"
 	"QLP/internal/sandbox"
+	"QLP/internal/secrets"
 	"QLP/internal/types"
 )
 
+// CatalogInfoFile is the Backstage catalog entity of the generated service,
+// at the root of the project
+const CatalogInfoFile = "catalog-info.yaml"
+
 type CapsulePackager struct {
 	outputDir     string
 	fileGenerator *FileGenerator
@@ -32,6 +39,8 @@ type QLCapsule struct {
 	Artifacts     []ArtifactReference  `json:"artifacts"`
 	Manifest      CapsuleManifest      `json:"manifest"`
 	UnifiedProject *UnifiedProject     `json:"unified_project,omitempty"`
+	// Reports are rendered human-readable reports keyed by file name, e.g. report.html
+	Reports       map[string][]byte    `json:"-"`
 }
 
 type CapsuleMetadata struct {
@@ -39,6 +48,7 @@ type CapsuleMetadata struct {
 	Version         string                 `json:"version"`
 	IntentID        string                 `json:"intent_id"`
 	IntentText      string                 `json:"intent_text"`
+	Language        string                 `json:"language,omitempty"` // Language the intent was written in
 	CreatedAt       time.Time              `json:"created_at"`
 	CompletedAt     time.Time              `json:"completed_at"`
 	Duration        time.Duration          `json:"duration"`
@@ -48,6 +58,7 @@ type CapsuleMetadata struct {
 	OverallScore    int                    `json:"overall_score"`
 	Tags            []string               `json:"tags"`
 	Environment     map[string]interface{} `json:"environment"`
+	Generation      *llm.Generation        `json:"generation,omitempty"` // Seed, models and parameters that produced the capsule
 }
 
 type TaskArtifact struct {
@@ -216,6 +227,7 @@ func (cp *CapsulePackager) PackageCapsule(ctx context.Context, intent models.Int
 		Manifest: cp.buildManifest(),
 		UnifiedProject: unifiedProject,
 	}
+	capsule.Metadata.Generation = llm.GenerationFromContext(ctx)
 
 	return capsule, nil
 }
@@ -315,7 +327,23 @@ func (cp *CapsulePackager) exportAsZip(capsule *QLCapsule) ([]byte, error) {
 			return nil, fmt.Errorf("failed to create report file %s: %w", name, err)
 		}
 		
-		if _, err := reportWriter.Write(reportData); err != nil {
+		if _, err := reportWriter.Write([]byte(secrets.Scrub(string(reportData)))); err != nil {
+			return nil, fmt.Errorf("failed to write report %s: %w", name, err)
+		}
+	}
+
+	// Add rendered reports alongside the JSON ones
+	reportNames := make([]string, 0, len(capsule.Reports))
+	for name := range capsule.Reports {
+		reportNames = append(reportNames, name)
+	}
+	sort.Strings(reportNames)
+	for _, name := range reportNames {
+		reportWriter, err := zipWriter.Create("reports/" + name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create report file %s: %w", name, err)
+		}
+		if _, err := reportWriter.Write([]byte(secrets.Scrub(string(capsule.Reports[name])))); err != nil {
 			return nil, fmt.Errorf("failed to write report %s: %w", name, err)
 		}
 	}
@@ -339,7 +367,11 @@ func (cp *CapsulePackager) exportAsZip(capsule *QLCapsule) ([]byte, error) {
 }
 
 func (cp *CapsulePackager) exportAsJSON(capsule *QLCapsule) ([]byte, error) {
-	return json.MarshalIndent(capsule, "", "  ")
+	data, err := json.MarshalIndent(capsule, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(secrets.Scrub(string(data))), nil
 }
 
 func (cp *CapsulePackager) exportAsTarGz(capsule *QLCapsule) ([]byte, error) {
@@ -377,6 +409,7 @@ func (cp *CapsulePackager) buildMetadata(intent models.Intent, results []TaskExe
 		Version:         "1.0.0",
 		IntentID:        intent.ID,
 		IntentText:      intent.UserInput,
+		Language:        intent.Metadata["language"],
 		CreatedAt:       intent.CreatedAt,
 		CompletedAt:     time.Now(),
 		Duration:        time.Since(intent.CreatedAt),
@@ -514,6 +547,26 @@ func (cp *CapsulePackager) extractLLMOutput(agentOutput string) string {
 	return strings.Join(llmOutput, "\n")
 }
 
+// extendProject adds files to the unified project, creating it when no task
+// produced files
+func (cp *CapsulePackager) extendProject(capsule *QLCapsule, intent models.Intent, files map[string]string) {
+	if len(files) == 0 {
+		return
+	}
+	if capsule.UnifiedProject == nil {
+		capsule.UnifiedProject = &UnifiedProject{
+			Name:        ProjectName(intent.UserInput),
+			Type:        "project",
+			Description: intent.UserInput,
+			Files:       make(map[string]string),
+		}
+	}
+	for path, content := range files {
+		capsule.UnifiedProject.Files[path] = content
+	}
+	capsule.UnifiedProject.Structure = cp.projectMerger.generateProjectStructure(capsule.UnifiedProject.Files)
+}
+
 // addUnifiedProject adds the merged project structure to the capsule
 func (cp *CapsulePackager) addUnifiedProject(zipWriter *zip.Writer, project *UnifiedProject) error {
 	log.Printf("Adding unified project '%s' with %d files", project.Name, len(project.Files))
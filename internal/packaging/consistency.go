@@ -0,0 +1,378 @@
+package packaging
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"QLP/internal/llm"
+)
+
+// ConsistencyIssueKind classifies a cross-file problem in a drop
+type ConsistencyIssueKind string
+
+const (
+	IssueModuleMismatch    ConsistencyIssueKind = "module_mismatch"
+	IssueUnresolvedImport  ConsistencyIssueKind = "unresolved_import"
+	IssueUnresolvedSymbol  ConsistencyIssueKind = "unresolved_symbol"
+	IssuePackageNameClash  ConsistencyIssueKind = "package_name_clash"
+	IssueMissingModuleFile ConsistencyIssueKind = "missing_module_file"
+)
+
+// ConsistencyIssue is a reference in one file that does not resolve against the rest of the drop
+type ConsistencyIssue struct {
+	Kind   ConsistencyIssueKind `json:"kind"`
+	File   string               `json:"file"`
+	Detail string               `json:"detail"`
+}
+
+// ConsistencyReport summarises cross-file checks for a drop
+type ConsistencyReport struct {
+	Module   string             `json:"module"`
+	Packages map[string]string  `json:"packages"` // import path -> package name
+	Issues   []ConsistencyIssue `json:"issues"`
+	FixedBy  []string           `json:"fixed_by,omitempty"`
+}
+
+// Consistent reports whether the drop has no unresolved cross-file references
+func (r *ConsistencyReport) Consistent() bool {
+	return len(r.Issues) == 0
+}
+
+// goPackage is the symbol table of one directory in the drop
+type goPackage struct {
+	name    string
+	dir     string
+	symbols map[string]bool
+	files   []string
+}
+
+// goFile is the import map of one Go file
+type goFile struct {
+	path    string
+	pkg     string
+	imports map[string]string // local name -> import path
+	refs    map[string][]string
+}
+
+// projectGraph is the symbol and import map of the Go files in a drop
+type projectGraph struct {
+	module   string
+	packages map[string]*goPackage // import path -> package
+	files    []*goFile
+}
+
+func buildProjectGraph(files map[string]string) *projectGraph {
+	g := &projectGraph{
+		module:   modulePath(files["go.mod"]),
+		packages: make(map[string]*goPackage),
+	}
+
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		if strings.HasSuffix(p, ".go") {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	fset := token.NewFileSet()
+	for _, p := range paths {
+		f, err := parser.ParseFile(fset, p, files[p], parser.SkipObjectResolution)
+		if err != nil {
+			continue // Syntax problems are reported by the validators
+		}
+
+		dir := path.Dir(p)
+		importPath := g.module
+		if dir != "." {
+			importPath = path.Join(g.module, dir)
+		}
+		pkg, ok := g.packages[importPath]
+		if !ok {
+			pkg = &goPackage{name: f.Name.Name, dir: dir, symbols: make(map[string]bool)}
+			g.packages[importPath] = pkg
+		}
+		pkg.files = append(pkg.files, p)
+		for name := range topLevelSymbols(f) {
+			pkg.symbols[name] = true
+		}
+
+		gf := &goFile{path: p, pkg: f.Name.Name, imports: make(map[string]string), refs: make(map[string][]string)}
+		for _, imp := range f.Imports {
+			ip, _ := strconv.Unquote(imp.Path.Value)
+			local := path.Base(ip)
+			if imp.Name != nil {
+				local = imp.Name.Name
+			}
+			gf.imports[local] = ip
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if id, ok := sel.X.(*ast.Ident); ok {
+					if _, imported := gf.imports[id.Name]; imported {
+						gf.refs[id.Name] = append(gf.refs[id.Name], sel.Sel.Name)
+					}
+				}
+			}
+			return true
+		})
+		g.files = append(g.files, gf)
+	}
+	return g
+}
+
+func topLevelSymbols(f *ast.File) map[string]bool {
+	symbols := make(map[string]bool)
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil {
+				symbols[d.Name.Name] = true
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					symbols[s.Name.Name] = true
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						symbols[n.Name] = true
+					}
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+func modulePath(goMod string) string {
+	for _, line := range strings.Split(goMod, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "module ") {
+			return strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module ")), `"`)
+		}
+	}
+	return ""
+}
+
+// localSuffix returns the drop-relative import path when ip refers to a
+// directory in the drop under a different module prefix
+func (g *projectGraph) localSuffix(ip string) (string, bool) {
+	for importPath, pkg := range g.packages {
+		if pkg.dir == "." || importPath == ip {
+			continue
+		}
+		if strings.HasSuffix(ip, "/"+pkg.dir) {
+			return importPath, true
+		}
+	}
+	return "", false
+}
+
+func (g *projectGraph) check() *ConsistencyReport {
+	report := &ConsistencyReport{
+		Module:   g.module,
+		Packages: make(map[string]string),
+		Issues:   make([]ConsistencyIssue, 0),
+	}
+	for ip, pkg := range g.packages {
+		report.Packages[ip] = pkg.name
+	}
+	if len(g.files) == 0 {
+		return report
+	}
+	if g.module == "" {
+		report.Issues = append(report.Issues, ConsistencyIssue{
+			Kind:   IssueMissingModuleFile,
+			File:   "go.mod",
+			Detail: "drop contains Go files but no module declaration",
+		})
+	}
+
+	// Files in one directory must share a package name
+	for _, pkg := range g.packages {
+		for _, p := range pkg.files {
+			for _, f := range g.files {
+				if f.path == p && strings.TrimSuffix(f.pkg, "_test") != strings.TrimSuffix(pkg.name, "_test") {
+					report.Issues = append(report.Issues, ConsistencyIssue{
+						Kind:   IssuePackageNameClash,
+						File:   p,
+						Detail: fmt.Sprintf("package %s, but %s declares package %s", f.pkg, pkg.files[0], pkg.name),
+					})
+				}
+			}
+		}
+	}
+
+	for _, f := range g.files {
+		for local, ip := range f.imports {
+			if target, ok := g.packages[ip]; ok {
+				for _, sym := range f.refs[local] {
+					if !target.symbols[sym] {
+						report.Issues = append(report.Issues, ConsistencyIssue{
+							Kind:   IssueUnresolvedSymbol,
+							File:   f.path,
+							Detail: fmt.Sprintf("%s.%s is not declared in %s", local, sym, ip),
+						})
+					}
+				}
+				continue
+			}
+			if g.module != "" && strings.HasPrefix(ip, g.module+"/") {
+				report.Issues = append(report.Issues, ConsistencyIssue{
+					Kind:   IssueUnresolvedImport,
+					File:   f.path,
+					Detail: fmt.Sprintf("import %q has no matching package in the drop", ip),
+				})
+				continue
+			}
+			if want, ok := g.localSuffix(ip); ok {
+				report.Issues = append(report.Issues, ConsistencyIssue{
+					Kind:   IssueModuleMismatch,
+					File:   f.path,
+					Detail: fmt.Sprintf("import %q should be %q to match go.mod module %s", ip, want, g.module),
+				})
+			}
+		}
+	}
+
+	sort.Slice(report.Issues, func(i, j int) bool {
+		if report.Issues[i].File != report.Issues[j].File {
+			return report.Issues[i].File < report.Issues[j].File
+		}
+		return report.Issues[i].Detail < report.Issues[j].Detail
+	})
+	return report
+}
+
+// CheckConsistency builds the project graph of a drop's files and reports
+// unresolved imports, undeclared symbols and module name mismatches
+func CheckConsistency(files map[string]string) *ConsistencyReport {
+	return buildProjectGraph(files).check()
+}
+
+// rewriteModulePrefixes points imports of local packages that use the wrong
+// module prefix at the module declared in go.mod
+func rewriteModulePrefixes(files map[string]string) int {
+	g := buildProjectGraph(files)
+	if g.module == "" {
+		return 0
+	}
+
+	rewritten := 0
+	for _, f := range g.files {
+		content := files[f.path]
+		for _, ip := range f.imports {
+			if _, ok := g.packages[ip]; ok {
+				continue
+			}
+			if want, ok := g.localSuffix(ip); ok {
+				content = strings.ReplaceAll(content, strconv.Quote(ip), strconv.Quote(want))
+				rewritten++
+			}
+		}
+		files[f.path] = content
+	}
+	return rewritten
+}
+
+// ReconcileDrop runs cross-file consistency checks on a codebase drop and
+// repairs what it can: module prefixes are rewritten deterministically, and
+// files with remaining issues get a targeted fix-up prompt when a client is
+// configured. Drops that are still inconsistent are held for review.
+func (qdg *QuantumDropGenerator) ReconcileDrop(ctx context.Context, drop *QuantumDrop) *ConsistencyReport {
+	report := CheckConsistency(drop.Files)
+	if report.Consistent() {
+		return report
+	}
+
+	var fixedBy []string
+	if n := rewriteModulePrefixes(drop.Files); n > 0 {
+		fixedBy = append(fixedBy, fmt.Sprintf("rewrote %d import prefixes", n))
+		report = CheckConsistency(drop.Files)
+	}
+
+	const maxFixupRounds = 2
+	for round := 0; round < maxFixupRounds && !report.Consistent() && qdg.fixupClient != nil; round++ {
+		fixed := qdg.fixupFiles(ctx, drop.Files, report)
+		if fixed == 0 {
+			break
+		}
+		fixedBy = append(fixedBy, fmt.Sprintf("LLM fix-up round %d repaired %d files", round+1, fixed))
+		report = CheckConsistency(drop.Files)
+	}
+	report.FixedBy = fixedBy
+
+	if !report.Consistent() {
+		drop.Status = DropStatusPending
+		drop.Metadata.HITLRequired = true
+		for _, issue := range report.Issues {
+			drop.Metadata.ReviewNotes = append(drop.Metadata.ReviewNotes,
+				fmt.Sprintf("%s: %s (%s)", issue.File, issue.Detail, issue.Kind))
+		}
+		log.Printf("Drop %s has %d unresolved cross-file issues, holding for review", drop.ID, len(report.Issues))
+	}
+
+	drop.Metadata.FileCount = len(drop.Files)
+	drop.Metadata.TotalLines = qdg.countTotalLines(drop.Files)
+	drop.Structure = qdg.generateDropStructure(drop.Files)
+	return report
+}
+
+// fixupFiles asks the LLM to repair each file with issues and returns how many changed
+func (qdg *QuantumDropGenerator) fixupFiles(ctx context.Context, files map[string]string, report *ConsistencyReport) int {
+	byFile := make(map[string][]ConsistencyIssue)
+	for _, issue := range report.Issues {
+		if _, ok := files[issue.File]; ok {
+			byFile[issue.File] = append(byFile[issue.File], issue)
+		}
+	}
+
+	packages := make([]string, 0, len(report.Packages))
+	for ip, name := range report.Packages {
+		packages = append(packages, fmt.Sprintf("%s (package %s)", ip, name))
+	}
+	sort.Strings(packages)
+
+	fixed := 0
+	for file, issues := range byFile {
+		var problems strings.Builder
+		for _, issue := range issues {
+			fmt.Fprintf(&problems, "- %s\n", issue.Detail)
+		}
+
+		prompt := fmt.Sprintf(`Fix cross-file inconsistencies in %s. Change only what is needed to resolve these problems:
+%s
+Module path (go.mod): %s
+Packages available in this project:
+%s
+
+Current file:
+%s
+
+Respond with JSON: {"content": "<the complete corrected file>"}`,
+			file, problems.String(), report.Module, strings.Join(packages, "\n"), files[file])
+
+		var result struct {
+			Content string `json:"content"`
+		}
+		schema := llm.SchemaFor("file_fixup", "Corrected file content", result)
+		if err := llm.CompleteJSON(ctx, qdg.fixupClient, prompt, schema, &result); err != nil {
+			log.Printf("Fix-up for %s failed: %v", file, err)
+			continue
+		}
+		if content := strings.TrimSpace(result.Content); content != "" && content != strings.TrimSpace(files[file]) {
+			files[file] = content + "\n"
+			fixed++
+		}
+	}
+	return fixed
+}
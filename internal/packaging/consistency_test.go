@@ -0,0 +1,70 @@
+package packaging
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCheckConsistency(t *testing.T) {
+	files := map[string]string{
+		"go.mod":                 "module shop\n\ngo 1.21\n",
+		"cmd/main.go":            "package main\n\nimport \"github.com/example/shop/internal/cart\"\n\nfunc main() { cart.New() }\n",
+		"internal/cart/cart.go":  "package cart\n\nfunc New() *Cart { return &Cart{} }\n\ntype Cart struct{}\n",
+		"internal/api/server.go": "package api\n\nimport \"shop/internal/cart\"\n\nvar c = cart.Open()\n",
+	}
+
+	report := CheckConsistency(files)
+	kinds := make(map[ConsistencyIssueKind]string)
+	for _, issue := range report.Issues {
+		kinds[issue.Kind] = issue.File
+	}
+	if kinds[IssueModuleMismatch] != "cmd/main.go" {
+		t.Errorf("expected module mismatch in cmd/main.go, got %+v", report.Issues)
+	}
+	if kinds[IssueUnresolvedSymbol] != "internal/api/server.go" {
+		t.Errorf("expected unresolved symbol in internal/api/server.go, got %+v", report.Issues)
+	}
+}
+
+func TestReconcileDropRewritesModulePrefix(t *testing.T) {
+	drop := &QuantumDrop{
+		ID:     "drop-1",
+		Status: DropStatusReady,
+		Files: map[string]string{
+			"go.mod":                "module shop\n",
+			"cmd/main.go":           "package main\n\nimport \"github.com/example/shop/internal/cart\"\n\nfunc main() { cart.New() }\n",
+			"internal/cart/cart.go": "package cart\n\nfunc New() {}\n",
+		},
+	}
+
+	report := NewQuantumDropGenerator().ReconcileDrop(context.Background(), drop)
+	if !report.Consistent() {
+		t.Fatalf("expected drop to be consistent after rewrite, got %+v", report.Issues)
+	}
+	if !strings.Contains(drop.Files["cmd/main.go"], `"shop/internal/cart"`) {
+		t.Errorf("import was not rewritten:\n%s", drop.Files["cmd/main.go"])
+	}
+	if drop.Status != DropStatusReady {
+		t.Errorf("expected drop to stay ready, got %s", drop.Status)
+	}
+}
+
+func TestReconcileDropHoldsInconsistentDrop(t *testing.T) {
+	drop := &QuantumDrop{
+		ID:     "drop-2",
+		Status: DropStatusReady,
+		Files: map[string]string{
+			"go.mod":      "module shop\n",
+			"cmd/main.go": "package main\n\nimport \"shop/internal/missing\"\n\nfunc main() { missing.Run() }\n",
+		},
+	}
+
+	report := NewQuantumDropGenerator().ReconcileDrop(context.Background(), drop)
+	if report.Consistent() {
+		t.Fatal("expected unresolved import to be reported")
+	}
+	if drop.Status != DropStatusPending || !drop.Metadata.HITLRequired {
+		t.Errorf("expected drop held for review, got status %s hitl %v", drop.Status, drop.Metadata.HITLRequired)
+	}
+}
@@ -0,0 +1,77 @@
+package packaging
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+
+	"QLP/internal/sandbox"
+)
+
+// DependencyResolver produces lockfiles for the manifests in a file set
+type DependencyResolver interface {
+	Resolve(ctx context.Context, files map[string]string) *sandbox.DependencyResolution
+}
+
+// SetDependencyResolver enables lockfile generation for codebase drops
+func (qdg *QuantumDropGenerator) SetDependencyResolver(resolver DependencyResolver) {
+	qdg.dependencyResolver = resolver
+}
+
+// PinDependencies resolves dependencies for a drop and adds the resulting
+// lockfiles before it is packaged. Go modules always get a go.sum, since
+// generated Dockerfiles copy it; an empty one is valid and is filled by
+// `go mod download` during the image build.
+func (qdg *QuantumDropGenerator) PinDependencies(ctx context.Context, drop *QuantumDrop) {
+	if qdg.dependencyResolver != nil && len(sandbox.DetectDependencyProjects(drop.Files)) > 0 {
+		resolution := qdg.dependencyResolver.Resolve(ctx, drop.Files)
+
+		pinned := make([]string, 0, len(resolution.Files))
+		for p, content := range resolution.Files {
+			if drop.Files[p] != content {
+				drop.Files[p] = content
+				pinned = append(pinned, p)
+			}
+		}
+		sort.Strings(pinned)
+		if len(pinned) > 0 {
+			log.Printf("📌 Pinned dependencies for drop %s: %s", drop.ID, strings.Join(pinned, ", "))
+		}
+
+		failed := make([]string, 0, len(resolution.Errors))
+		for project := range resolution.Errors {
+			failed = append(failed, project)
+		}
+		sort.Strings(failed)
+		for _, project := range failed {
+			drop.Metadata.ReviewNotes = append(drop.Metadata.ReviewNotes,
+				fmt.Sprintf("Dependencies for %s could not be pinned: %s", project, resolution.Errors[project]))
+		}
+	}
+
+	for _, project := range sandbox.DetectDependencyProjects(drop.Files) {
+		if project.Ecosystem != sandbox.EcosystemGo {
+			continue
+		}
+		goSum := path.Join(project.Dir, "go.sum")
+		if _, ok := drop.Files[goSum]; !ok {
+			drop.Files[goSum] = ""
+		}
+	}
+
+	drop.Metadata.FileCount = len(drop.Files)
+	drop.Metadata.TotalLines = qdg.countTotalLines(drop.Files)
+	drop.Structure = qdg.generateDropStructure(drop.Files)
+}
+
+// isDependencyManifest reports whether a generated file is a manifest or lockfile
+func isDependencyManifest(p string) bool {
+	switch path.Base(p) {
+	case "go.mod", "go.sum", "package.json", "package-lock.json", "requirements.txt", "requirements.in":
+		return true
+	}
+	return false
+}
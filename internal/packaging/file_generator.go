@@ -3,8 +3,12 @@ package packaging
 import (
 	"encoding/json"
 	"fmt"
-	"path/filepath"
+	"log"
+	"regexp"
+	"sort"
 	"strings"
+
+	"QLP/internal/filetypes"
 )
 
 // ProjectStructure represents the JSON structure returned by agents
@@ -16,11 +20,13 @@ type ProjectStructure struct {
 	} `json:"project_structure"`
 }
 
-// File represents a single file in the project structure
+// File represents a single file in the project structure. Binary assets
+// carry their content base64-encoded with Encoding set to "base64".
 type File struct {
-	Path    string `json:"path"`
-	Type    string `json:"type"`
-	Content string `json:"content"`
+	Path     string `json:"path"`
+	Type     string `json:"type"`
+	Content  string `json:"content"`
+	Encoding string `json:"encoding,omitempty"`
 }
 
 // FileGenerator handles parsing LLM output and generating proper file structures
@@ -44,6 +50,16 @@ func (fg *FileGenerator) ParseLLMOutput(taskID, taskType, llmOutput string) (*Pr
 		return &projectStruct, nil
 	}
 	
+	// Other JSON envelopes of files become real files, not a file holding JSON
+	if envelope := fg.parseFileEnvelope(taskID, taskType, cleanedOutput); envelope != nil {
+		return envelope, nil
+	}
+	
+	// Code fences in prose become one file each
+	if blocks := fg.parseCodeBlocks(taskID, taskType, llmOutput); blocks != nil {
+		return blocks, nil
+	}
+	
 	// Fallback: treat as single file based on task type
 	return fg.createFallbackStructure(taskID, taskType, llmOutput), nil
 }
@@ -66,6 +82,142 @@ func (fg *FileGenerator) cleanMarkdownCodeBlocks(content string) string {
 	return strings.TrimSpace(content)
 }
 
+// parseFileEnvelope reads the envelopes agents use besides ProjectStructure:
+// {"files": {"path": "content"}} and {"files": [{"path": ..., "content": ...}]},
+// optionally nested under "project". It returns nil for anything else.
+func (fg *FileGenerator) parseFileEnvelope(taskID, taskType, content string) *ProjectStructure {
+	var envelope struct {
+		Files   json.RawMessage `json:"files"`
+		Project *struct {
+			Files json.RawMessage `json:"files"`
+		} `json:"project"`
+	}
+	if err := json.Unmarshal([]byte(content), &envelope); err != nil {
+		return nil
+	}
+	raw := envelope.Files
+	if len(raw) == 0 && envelope.Project != nil {
+		raw = envelope.Project.Files
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+	
+	var files []File
+	var byPath map[string]string
+	if err := json.Unmarshal(raw, &byPath); err == nil {
+		paths := make([]string, 0, len(byPath))
+		for path := range byPath {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			files = append(files, File{Path: path, Type: filetypes.Detect(path).Language, Content: byPath[path]})
+		}
+	} else if err := json.Unmarshal(raw, &files); err != nil {
+		return nil
+	}
+	
+	projectStruct := &ProjectStructure{}
+	projectStruct.ProjectStructure.ProjectName = fmt.Sprintf("task-%s", taskID)
+	projectStruct.ProjectStructure.ProjectType = taskType
+	for _, file := range files {
+		if file.Path == "" {
+			continue
+		}
+		if file.Type == "" {
+			file.Type = filetypes.Detect(file.Path).Language
+		}
+		projectStruct.ProjectStructure.Files = append(projectStruct.ProjectStructure.Files, file)
+	}
+	if len(projectStruct.ProjectStructure.Files) == 0 {
+		return nil
+	}
+	return projectStruct
+}
+
+// codeFence matches a fenced code block; the info string holds the language
+// and optionally the file path, as in ```go cmd/api/main.go or ```go:main.go
+var codeFence = regexp.MustCompile("(?ms)^```([^\\n`]*)\\n(.*?)^```[ \\t]*$")
+
+// parseCodeBlocks splits prose with fenced code blocks into one file per
+// block, named from the fence or after the language. Documentation and
+// analysis keep their code blocks inline, so it returns nil for them, and
+// for output without blocks.
+func (fg *FileGenerator) parseCodeBlocks(taskID, taskType, content string) *ProjectStructure {
+	if taskType == "doc" || taskType == "analyze" {
+		return nil
+	}
+	matches := codeFence.FindAllStringSubmatch(content, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	
+	projectStruct := &ProjectStructure{}
+	projectStruct.ProjectStructure.ProjectName = fmt.Sprintf("task-%s", taskID)
+	projectStruct.ProjectStructure.ProjectType = taskType
+	used := make(map[string]bool)
+	for _, m := range matches {
+		language, filePath := fg.parseFenceInfo(m[1])
+		body := m[2]
+		if filePath == "" {
+			var detected string
+			filePath, detected = fg.determineFileInfo(taskType, body)
+			if language == "" || language == filetypes.Text {
+				language = detected
+			} else if filetypes.Detect(filePath).Language != filetypes.ForLanguage(language).Language {
+				filePath = fg.defaultFileName(taskType, body, filetypes.ForLanguage(language))
+			}
+		} else if language == "" {
+			language = filetypes.Detect(filePath).Language
+		}
+		filePath = uniquePath(filePath, used)
+		projectStruct.ProjectStructure.Files = append(projectStruct.ProjectStructure.Files, File{
+			Path:    filePath,
+			Type:    filetypes.ForLanguage(language).Language,
+			Content: body,
+		})
+	}
+	return projectStruct
+}
+
+// parseFenceInfo reads the language and file path from a fence info string
+func (fg *FileGenerator) parseFenceInfo(info string) (string, string) {
+	info = strings.TrimSpace(info)
+	if lang, file, ok := strings.Cut(info, ":"); ok && !strings.Contains(lang, " ") {
+		return lang, strings.TrimSpace(file)
+	}
+	fields := strings.Fields(info)
+	switch {
+	case len(fields) == 0:
+		return "", ""
+	case len(fields) == 1 && strings.Contains(fields[0], "."):
+		// ```main.go names the file alone
+		if filetypes.Known(fields[0]) {
+			return "", fields[0]
+		}
+		return fields[0], ""
+	case len(fields) == 1:
+		return fields[0], ""
+	}
+	file := strings.TrimPrefix(fields[1], "title=")
+	return fields[0], strings.Trim(file, `"'`)
+}
+
+// uniquePath suffixes a path already used: code.go, code_2.go, ...
+func uniquePath(p string, used map[string]bool) string {
+	candidate := p
+	for n := 2; used[candidate]; n++ {
+		ext := filetypes.Detect(p).Extension()
+		if !strings.HasSuffix(p, ext) {
+			ext = ""
+		}
+		candidate = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(p, ext), n, ext)
+	}
+	used[candidate] = true
+	return candidate
+}
+
 // createFallbackStructure creates a basic structure when JSON parsing fails
 func (fg *FileGenerator) createFallbackStructure(taskID, taskType, content string) *ProjectStructure {
 	projectStruct := &ProjectStructure{}
@@ -88,83 +240,135 @@ func (fg *FileGenerator) createFallbackStructure(taskID, taskType, content strin
 
 // determineFileInfo intelligently determines file name and type based on content and task type
 func (fg *FileGenerator) determineFileInfo(taskType, content string) (string, string) {
+	t := filetypes.ForLanguage(fg.detectLanguage(taskType, content))
+	return fg.defaultFileName(taskType, content, t), t.Language
+}
+
+// detectLanguage guesses the language of task output from its content
+func (fg *FileGenerator) detectLanguage(taskType, content string) string {
 	switch taskType {
-	case "codegen":
-		if strings.Contains(content, "package main") || strings.Contains(content, "func main") {
-			return "main.go", "go"
-		}
-		if strings.Contains(content, "import (") || strings.Contains(content, "package ") {
-			return "code.go", "go"
-		}
-		if strings.Contains(content, "def ") || strings.Contains(content, "import ") {
-			return "code.py", "python"
-		}
-		if strings.Contains(content, "function ") || strings.Contains(content, "const ") {
-			return "code.js", "javascript"
+	case "codegen", "test":
+		switch {
+		case strings.Contains(content, "public class ") || strings.Contains(content, "import org.junit"):
+			return "java"
+		case strings.Contains(content, "package ") || strings.Contains(content, "func Test"):
+			return "go"
+		case strings.Contains(content, "def ") || strings.Contains(content, "import pytest"):
+			return "python"
+		case strings.Contains(content, "interface ") && strings.Contains(content, ": string"):
+			return "typescript"
+		case strings.Contains(content, "function ") || strings.Contains(content, "const ") ||
+			strings.Contains(content, "describe("):
+			return "javascript"
+		case strings.Contains(content, "import "):
+			return "python"
 		}
-		return "code.txt", "text"
+		return filetypes.Text
 		
 	case "infra":
-		if strings.Contains(content, "apiVersion:") || strings.Contains(content, "kind:") {
-			return "deployment.yaml", "yaml"
+		switch {
+		case strings.Contains(content, "resource ") || strings.Contains(content, "provider "):
+			return "terraform"
+		case strings.HasPrefix(strings.TrimSpace(content), "FROM ") || strings.Contains(content, "\nRUN "):
+			return "dockerfile"
 		}
-		if strings.Contains(content, "resource ") || strings.Contains(content, "provider ") {
-			return "main.tf", "terraform"
+		return "yaml"
+		
+	case "doc":
+		return "markdown"
+		
+	case "analyze":
+		if strings.Contains(content, "# ") || strings.Contains(content, "## ") {
+			return "markdown"
 		}
-		if strings.Contains(content, "FROM ") || strings.Contains(content, "RUN ") {
-			return "Dockerfile", "dockerfile"
+		return filetypes.Text
+	}
+	return filetypes.Text
+}
+
+// defaultFileName names the single file of a task's output after the task
+// type, using the language's extension and layout
+func (fg *FileGenerator) defaultFileName(taskType, content string, t filetypes.Type) string {
+	switch taskType {
+	case "codegen":
+		if t.Language == "go" && !strings.Contains(content, "package main") {
+			return "code.go"
 		}
-		if strings.Contains(content, "version:") && strings.Contains(content, "services:") {
-			return "docker-compose.yml", "yaml"
+		if t.Entry == "" || t.Language == filetypes.Text {
+			return t.FileName("code")
 		}
-		return "infrastructure.yaml", "yaml"
+		return t.Entry
 		
 	case "test":
-		if strings.Contains(content, "func Test") || strings.Contains(content, "package ") {
-			return "main_test.go", "go"
-		}
-		if strings.Contains(content, "def test_") || strings.Contains(content, "import pytest") {
-			return "test_main.py", "python"
+		if test := t.TestPath("main"); test != "" {
+			return test
 		}
-		if strings.Contains(content, "describe(") || strings.Contains(content, "it(") {
-			return "test.spec.js", "javascript"
+		return t.FileName("test")
+		
+	case "infra":
+		switch {
+		case t.Language == "yaml" && strings.Contains(content, "services:") && !strings.Contains(content, "apiVersion:"):
+			return "docker-compose.yml"
+		case t.Language == "yaml" && strings.Contains(content, "apiVersion:"):
+			return "deployment.yaml"
+		case t.Entry != "" && t.Language != "yaml":
+			return t.Entry
 		}
-		return "test.txt", "text"
+		return t.FileName("infrastructure")
 		
 	case "doc":
-		if strings.Contains(content, "# ") || strings.Contains(content, "## ") {
-			return "README.md", "markdown"
+		if strings.Contains(content, "# ") {
+			return "README.md"
 		}
-		return "documentation.md", "markdown"
+		return t.FileName("documentation")
 		
 	case "analyze":
-		if strings.Contains(content, "# ") || strings.Contains(content, "## ") {
-			return "analysis_report.md", "markdown"
+		if t.Language == "markdown" {
+			return "analysis_report.md"
 		}
-		return "analysis.txt", "text"
-		
-	default:
-		return "output.txt", "text"
+		return t.FileName("analysis")
 	}
+	return t.FileName("output")
 }
 
-// GenerateFileStructure creates the final file structure for packaging
+// GenerateFileStructure creates the final file structure for packaging.
+// Paths are cleaned so nothing escapes the project root, extensionless paths
+// get their language's extension, and base64 content is decoded.
 func (fg *FileGenerator) GenerateFileStructure(projectStruct *ProjectStructure) map[string]string {
 	fileMap := make(map[string]string)
+	languages := make(map[string]bool)
 	
 	for _, file := range projectStruct.ProjectStructure.Files {
-		// Ensure proper directory structure
-		cleanPath := filepath.Clean(file.Path)
-		fileMap[cleanPath] = file.Content
+		cleanPath := filetypes.CleanPath(file.Path, file.Type)
+		if cleanPath == "" {
+			log.Printf("Skipping file with unusable path %q", file.Path)
+			continue
+		}
+		content, err := filetypes.Decode(file.Content, file.Encoding)
+		if err != nil {
+			log.Printf("Skipping %s: %v", cleanPath, err)
+			continue
+		}
+		fileMap[cleanPath] = string(content)
+		if t := filetypes.Detect(cleanPath); !t.Binary {
+			languages[t.Language] = true
+		}
 	}
 	
 	// Add a project manifest if not present
 	if _, exists := fileMap["project.json"]; !exists {
+		langs := make([]string, 0, len(languages))
+		for lang := range languages {
+			langs = append(langs, lang)
+		}
+		sort.Strings(langs)
+		
 		manifest := map[string]interface{}{
-			"name":    projectStruct.ProjectStructure.ProjectName,
-			"type":    projectStruct.ProjectStructure.ProjectType,
-			"files":   len(projectStruct.ProjectStructure.Files),
-			"version": "1.0.0",
+			"name":      projectStruct.ProjectStructure.ProjectName,
+			"type":      projectStruct.ProjectStructure.ProjectType,
+			"files":     len(projectStruct.ProjectStructure.Files),
+			"languages": langs,
+			"version":   "1.0.0",
 		}
 		
 		manifestJSON, _ := json.MarshalIndent(manifest, "", "  ")
@@ -176,32 +380,5 @@ func (fg *FileGenerator) GenerateFileStructure(projectStruct *ProjectStructure)
 
 // GetFileExtension returns the appropriate file extension for a file type
 func (fg *FileGenerator) GetFileExtension(fileType string) string {
-	extensions := map[string]string{
-		"go":         ".go",
-		"python":     ".py",
-		"javascript": ".js",
-		"typescript": ".ts",
-		"yaml":       ".yaml",
-		"yml":        ".yml",
-		"json":       ".json",
-		"markdown":   ".md",
-		"text":       ".txt",
-		"dockerfile": "",
-		"terraform":  ".tf",
-		"html":       ".html",
-		"css":        ".css",
-		"sql":        ".sql",
-		"xml":        ".xml",
-		"toml":       ".toml",
-		"ini":        ".ini",
-		"conf":       ".conf",
-		"svg":        ".svg",
-		"csv":        ".csv",
-	}
-	
-	if ext, exists := extensions[fileType]; exists {
-		return ext
-	}
-	
-	return ".txt"
-}
\ No newline at end of file
+	return filetypes.Extension(fileType)
+}
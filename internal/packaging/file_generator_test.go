@@ -0,0 +1,120 @@
+package packaging
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestParseLLMOutputCodeBlocks(t *testing.T) {
+	fg := NewFileGenerator()
+	output := "Here is the service.\n\n```go cmd/api/main.go\npackage main\n\nfunc main() {}\n```\n\n" +
+		"And a helper:\n\n```python\ndef helper():\n    return 1\n```\n\n```python\ndef other():\n    return 2\n```\n\n" +
+		"```Dockerfile\nFROM golang:1.24\n```\n"
+	project, err := fg.ParseLLMOutput("T1", "codegen", output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, f := range project.ProjectStructure.Files {
+		got[f.Path] = f.Type
+	}
+	for path, lang := range map[string]string{
+		"cmd/api/main.go": "go",
+		"main.py":         "python",
+		"main_2.py":       "python",
+		"Dockerfile":      "dockerfile",
+	} {
+		if got[path] != lang {
+			t.Errorf("%s = %q, want %q (files %v)", path, got[path], lang, got)
+		}
+	}
+
+	// Documentation keeps its examples inline
+	doc, _ := fg.ParseLLMOutput("T2", "doc", "# Usage\n\n```bash\nmake run\n```\n")
+	if files := doc.ProjectStructure.Files; len(files) != 1 || files[0].Path != "README.md" {
+		t.Errorf("doc files = %+v", files)
+	}
+}
+
+func TestParseLLMOutputFileEnvelope(t *testing.T) {
+	fg := NewFileGenerator()
+	for _, output := range []string{
+		`{"files": {"main.go": "package main\n\nfunc main() {}", "go.mod": "module shop\n\ngo 1.24"}}`,
+		"```json\n" + `{"files": [{"path": "main.go", "content": "package main\n\nfunc main() {}"}, {"path": "go.mod", "content": "module shop\n\ngo 1.24"}]}` + "\n```",
+		`{"project": {"files": {"main.go": "package main\n\nfunc main() {}", "go.mod": "module shop\n\ngo 1.24"}}}`,
+	} {
+		project, err := fg.ParseLLMOutput("T1", "codegen", output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files := fg.GenerateFileStructure(project)
+		if !strings.HasPrefix(files["main.go"], "package main") || !strings.HasPrefix(files["go.mod"], "module shop") {
+			t.Errorf("envelope %s parsed to %v", output, keys(files))
+		}
+	}
+
+	// JSON that is not an envelope stays a single file
+	project, _ := fg.ParseLLMOutput("T2", "codegen", `{"files": 3}`)
+	if files := project.ProjectStructure.Files; len(files) != 1 {
+		t.Errorf("non-envelope files = %+v", files)
+	}
+}
+
+func TestDetermineFileInfo(t *testing.T) {
+	fg := NewFileGenerator()
+	for _, tc := range []struct{ taskType, content, name, lang string }{
+		{"codegen", "package main\n\nfunc main() {}", "main.go", "go"},
+		{"codegen", "package orders", "code.go", "go"},
+		{"codegen", "def run():\n    pass", "main.py", "python"},
+		{"codegen", "const x = 1", "index.js", "javascript"},
+		{"codegen", "just words", "code.txt", "text"},
+		{"test", "def test_run():\n    assert True", "tests/test_main.py", "python"},
+		{"infra", "resource \"aws_s3_bucket\" \"b\" {}", "main.tf", "terraform"},
+		{"infra", "FROM alpine\nRUN true", "Dockerfile", "dockerfile"},
+		{"infra", "apiVersion: v1\nkind: Service", "deployment.yaml", "yaml"},
+		{"analyze", "## Findings", "analysis_report.md", "markdown"},
+	} {
+		name, lang := fg.determineFileInfo(tc.taskType, tc.content)
+		if name != tc.name || lang != tc.lang {
+			t.Errorf("%s %q = %s %s, want %s %s", tc.taskType, tc.content, name, lang, tc.name, tc.lang)
+		}
+	}
+}
+
+func TestGenerateFileStructureLayout(t *testing.T) {
+	fg := NewFileGenerator()
+	logo := []byte{0x89, 'P', 'N', 'G', 0x00, 0x01}
+	project := &ProjectStructure{}
+	project.ProjectStructure.ProjectName = "shop"
+	project.ProjectStructure.Files = []File{
+		{Path: "src/shop/app", Type: "python", Content: "print()"},
+		{Path: "../../etc/cron", Type: "shell", Content: "rm -rf /"},
+		{Path: "static/logo.png", Content: base64.StdEncoding.EncodeToString(logo), Encoding: "base64"},
+		{Path: "broken.png", Content: "not base64!", Encoding: "base64"},
+	}
+	files := fg.GenerateFileStructure(project)
+	if _, ok := files["src/shop/app.py"]; !ok {
+		t.Errorf("nested file missing its extension: %v", keys(files))
+	}
+	if _, ok := files["etc/cron.sh"]; !ok {
+		t.Errorf("escaping path not rooted: %v", keys(files))
+	}
+	if files["static/logo.png"] != string(logo) {
+		t.Errorf("binary asset not decoded: %q", files["static/logo.png"])
+	}
+	if _, ok := files["broken.png"]; ok {
+		t.Error("undecodable asset should be skipped")
+	}
+	if !strings.Contains(files["project.json"], `"python"`) {
+		t.Errorf("manifest = %s", files["project.json"])
+	}
+}
+
+func keys(m map[string]string) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
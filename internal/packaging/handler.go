@@ -0,0 +1,311 @@
+package packaging
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/storage"
+)
+
+// ChecksumsFile is added last to every downloaded archive and lists the
+// sha256 of each file in it, in the format of sha256sum
+const ChecksumsFile = "SHA256SUMS"
+
+// Routes returns the capsule download endpoint:
+//
+//	GET /capsules/{id}/download[?format=zip|tar.gz][&path=<prefix>...]  streams a stored capsule as an archive
+//
+// Without format the Accept header picks the archive, zip by default. Each
+// path keeps the files under it, given from the capsule root (reports/) or
+// the project root (k8s/). The response carries the sha256 of the stored
+// capsule in X-Capsule-SHA256 and that of the archive sent in the
+// Content-Digest trailer. Only capsules of the requested tenant, that of the
+// API key by default, are found.
+func Routes(store storage.ArtifactStore) map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /capsules/{id}/download": downloadHandler(store),
+	}
+}
+
+func downloadHandler(store storage.ArtifactStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capsuleID := r.PathValue("id")
+		format, ok := archiveFormat(r)
+		if !ok {
+			http.Error(w, "format must be zip or tar.gz", http.StatusNotAcceptable)
+			return
+		}
+
+		artifact, err := capsuleArchive(r.Context(), store, capsuleID, audit.RequestTenant(r))
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		archive, sourceSum, err := spoolArchive(r.Context(), store, artifact.Key)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, errNotArchive) {
+				status = http.StatusUnprocessableEntity
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		defer archive.Close()
+
+		files := selectFiles(archive.File, r.URL.Query()["path"])
+		if len(files) == 0 {
+			http.Error(w, "no files in the capsule match the requested paths", http.StatusNotFound)
+			return
+		}
+
+		digest := sha256.New()
+		out := io.MultiWriter(w, digest)
+		w.Header().Set("Trailer", "Content-Digest")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", capsuleID+"."+format))
+		w.Header().Set("X-Capsule-SHA256", sourceSum)
+		if format == "tar.gz" {
+			w.Header().Set("Content-Type", "application/gzip")
+			err = writeTarGz(out, files)
+		} else {
+			w.Header().Set("Content-Type", "application/zip")
+			err = writeZip(out, files)
+		}
+		if err != nil {
+			// Headers are gone; abort so the client sees a truncated response
+			// instead of an archive that looks complete
+			log.Printf("Capsule download of %s failed: %v", capsuleID, err)
+			panic(http.ErrAbortHandler)
+		}
+		w.Header().Set("Content-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(digest.Sum(nil))+":")
+	})
+}
+
+// archiveFormat reads the requested archive format from the format
+// parameter, then the Accept header
+func archiveFormat(r *http.Request) (string, bool) {
+	switch r.URL.Query().Get("format") {
+	case "zip", "qlcapsule":
+		return "zip", true
+	case "tar.gz", "tgz":
+		return "tar.gz", true
+	case "":
+	default:
+		return "", false
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(accept, ";")
+		switch strings.TrimSpace(mediaType) {
+		case "application/zip":
+			return "zip", true
+		case "application/gzip", "application/x-gzip", "application/x-gtar", "application/x-tar":
+			return "tar.gz", true
+		}
+	}
+	return "zip", true
+}
+
+// capsuleArchive returns the stored zip archive of a tenant's capsule
+func capsuleArchive(ctx context.Context, store storage.ArtifactStore, capsuleID, tenantID string) (*storage.Artifact, error) {
+	artifacts, err := store.List(ctx, capsuleID)
+	if err != nil {
+		return nil, err
+	}
+	artifacts = storage.OfTenant(artifacts, tenantID)
+	var latest *storage.Artifact
+	for i, a := range artifacts {
+		switch strings.ToLower(path.Ext(a.Name)) {
+		case ".qlcapsule", ".zip":
+			if latest == nil || a.CreatedAt.After(latest.CreatedAt) {
+				latest = &artifacts[i]
+			}
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("%w: no capsule archive for %s", storage.ErrNotFound, capsuleID)
+	}
+	return latest, nil
+}
+
+// errNotArchive is returned for stored capsules that are not zip archives
+var errNotArchive = errors.New("capsule is not an archive")
+
+// spooledArchive is a capsule archive copied to a temporary file, which zip
+// needs to read it at random
+type spooledArchive struct {
+	*zip.Reader
+	file *os.File
+}
+
+func (a *spooledArchive) Close() error {
+	a.file.Close()
+	return os.Remove(a.file.Name())
+}
+
+// spoolArchive copies a stored archive to disk, hashing it on the way, so
+// capsules of any size are served without holding them in memory
+func spoolArchive(ctx context.Context, store storage.ArtifactStore, key string) (*spooledArchive, string, error) {
+	rc, _, err := store.Open(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rc.Close()
+
+	file, err := os.CreateTemp("", "qlp-capsule-*.zip")
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.New()
+	size, err := io.Copy(file, io.TeeReader(rc, sum))
+	if err == nil {
+		var reader *zip.Reader
+		if reader, err = zip.NewReader(file, size); err == nil {
+			return &spooledArchive{Reader: reader, file: file}, hex.EncodeToString(sum.Sum(nil)), nil
+		}
+		err = fmt.Errorf("%w: %v", errNotArchive, err)
+	}
+	file.Close()
+	os.Remove(file.Name())
+	return nil, "", err
+}
+
+// selectFiles keeps the files under any of prefixes, all files without
+// prefixes. A prefix is matched from the capsule root and from the root of
+// the project inside it.
+func selectFiles(files []*zip.File, prefixes []string) []*zip.File {
+	var cleaned []string
+	for _, p := range prefixes {
+		if p = strings.TrimPrefix(path.Clean("/"+p), "/"); p != "" {
+			cleaned = append(cleaned, p)
+		}
+	}
+
+	var selected []*zip.File
+	for _, f := range files {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		if len(cleaned) == 0 {
+			selected = append(selected, f)
+			continue
+		}
+		names := []string{f.Name}
+		if rest, ok := strings.CutPrefix(f.Name, "project/"); ok {
+			if _, inProject, ok := strings.Cut(rest, "/"); ok {
+				names = append(names, inProject)
+			}
+		}
+		for _, p := range cleaned {
+			if matchesPrefix(names, p) {
+				selected = append(selected, f)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+func matchesPrefix(names []string, prefix string) bool {
+	for _, name := range names {
+		if name == prefix || strings.HasPrefix(name, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// checksums collects the sha256 of each file written to an archive
+type checksums struct {
+	lines []string
+}
+
+func (c *checksums) copy(dst io.Writer, f *zip.File) error {
+	src, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer src.Close()
+	sum := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(dst, sum), src); err != nil {
+		return fmt.Errorf("failed to copy %s: %w", f.Name, err)
+	}
+	c.lines = append(c.lines, hex.EncodeToString(sum.Sum(nil))+"  "+f.Name+"\n")
+	return nil
+}
+
+func (c *checksums) content() []byte {
+	return []byte(strings.Join(c.lines, ""))
+}
+
+func writeZip(w io.Writer, files []*zip.File) error {
+	zw := zip.NewWriter(w)
+	var sums checksums
+	for _, f := range files {
+		header := &zip.FileHeader{Name: f.Name, Method: zip.Deflate, Modified: f.Modified}
+		dst, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if err := sums.copy(dst, f); err != nil {
+			return err
+		}
+	}
+	dst, err := zw.CreateHeader(&zip.FileHeader{Name: ChecksumsFile, Method: zip.Deflate, Modified: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	if _, err := dst.Write(sums.content()); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeTarGz(w io.Writer, files []*zip.File) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	var sums checksums
+	for _, f := range files {
+		header := &tar.Header{
+			Name:     f.Name,
+			Mode:     0644,
+			Size:     int64(f.UncompressedSize64),
+			ModTime:  f.Modified,
+			Typeflag: tar.TypeReg,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if err := sums.copy(tw, f); err != nil {
+			return err
+		}
+	}
+	content := sums.content()
+	if err := tw.WriteHeader(&tar.Header{Name: ChecksumsFile, Mode: 0644, Size: int64(len(content)), ModTime: time.Now().UTC(), Typeflag: tar.TypeReg}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(content); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,153 @@
+package packaging
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"QLP/internal/storage"
+)
+
+func storedCapsule(t *testing.T) storage.ArtifactStore {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"metadata.json":                      `{"capsule_id": "QL-CAP-1"}`,
+		"reports/security_report.json":       `{}`,
+		"project/orders/cmd/main.go":         "package main\n",
+		"project/orders/k8s/deployment.yaml": "apiVersion: apps/v1\nkind: Deployment\n",
+		"project/orders/k8s/service.yaml":    "apiVersion: v1\nkind: Service\n",
+		"project/orders/k8sextra/notes.txt":  "not a manifest\n",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, content)
+	}
+	zw.Close()
+
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Put(context.Background(), "t1", "QL-CAP-1", "ql_capsule_QL-CAP-1_20250101_000000.qlcapsule", &buf); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func serve(t *testing.T, handler http.Handler, target string, header http.Header) *http.Response {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.Handle("GET /capsules/{id}/download", handler)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	req, _ := http.NewRequest(http.MethodGet, server.URL+target, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestDownloadCapsuleZip(t *testing.T) {
+	handler := Routes(storedCapsule(t))["GET /capsules/{id}/download"]
+	resp := serve(t, handler, "/capsules/QL-CAP-1/download?path=k8s/&path=reports", nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/zip" {
+		t.Fatalf("status %d, type %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if len(resp.Header.Get("X-Capsule-SHA256")) != 64 {
+		t.Errorf("X-Capsule-SHA256 = %q", resp.Header.Get("X-Capsule-SHA256"))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(body)
+	if want := "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"; resp.Trailer.Get("Content-Digest") != want {
+		t.Errorf("Content-Digest = %q, want %q", resp.Trailer.Get("Content-Digest"), want)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	var sums string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		if f.Name == ChecksumsFile {
+			rc, _ := f.Open()
+			data, _ := io.ReadAll(rc)
+			sums = string(data)
+		}
+	}
+	sort.Strings(names)
+	want := []string{ChecksumsFile, "project/orders/k8s/deployment.yaml", "project/orders/k8s/service.yaml", "reports/security_report.json"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("files = %v, want %v", names, want)
+	}
+	service := sha256.Sum256([]byte("apiVersion: v1\nkind: Service\n"))
+	if !strings.Contains(sums, hex.EncodeToString(service[:])+"  project/orders/k8s/service.yaml\n") {
+		t.Errorf("%s = %q", ChecksumsFile, sums)
+	}
+}
+
+func TestDownloadCapsuleTarGz(t *testing.T) {
+	handler := Routes(storedCapsule(t))["GET /capsules/{id}/download"]
+	resp := serve(t, handler, "/capsules/QL-CAP-1/download?path=cmd", http.Header{"Accept": {"application/gzip"}})
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/gzip" {
+		t.Fatalf("status %d, type %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, h.Name)
+	}
+	if strings.Join(names, ",") != "project/orders/cmd/main.go,"+ChecksumsFile {
+		t.Errorf("files = %v", names)
+	}
+}
+
+func TestDownloadCapsuleErrors(t *testing.T) {
+	handler := Routes(storedCapsule(t))["GET /capsules/{id}/download"]
+	for target, status := range map[string]int{
+		"/capsules/QL-CAP-1/download?format=rar":      http.StatusNotAcceptable,
+		"/capsules/QL-CAP-2/download":                 http.StatusNotFound,
+		"/capsules/QL-CAP-1/download?path=terraform/": http.StatusNotFound,
+		"/capsules/QL-CAP-1/download?tenant=t2":       http.StatusNotFound,
+		"/capsules/QL-CAP-1/download?tenant=t1":       http.StatusOK,
+	} {
+		if resp := serve(t, handler, target, nil); resp.StatusCode != status {
+			t.Errorf("%s = %d, want %d", target, resp.StatusCode, status)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package packaging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"QLP/internal/filetypes"
+	"QLP/internal/secrets"
+)
+
+// Sections of the directory written for every intent, next to its capsule
+const (
+	IntentSourceDir  = "source"     // Application code, tests and docs
+	IntentInfraDir   = "infra"      // Dockerfiles, manifests, Terraform and CI
+	IntentReportsDir = "reports"    // Security, quality and rendered reports
+	IntentLogsDir    = "logs"       // One log per task
+	IntentIndexFile  = "index.json" // IntentIndex of everything above
+)
+
+// IntentIndex is the manifest of an intent directory
+type IntentIndex struct {
+	IntentID  string       `json:"intent_id"`
+	Intent    string       `json:"intent"`
+	CapsuleID string       `json:"capsule_id"`
+	Capsule   string       `json:"capsule,omitempty"` // Exported capsule file, when there is one
+	CreatedAt time.Time    `json:"created_at"`
+	Files     []IndexEntry `json:"files"`
+}
+
+// IndexEntry is one file of an intent directory
+type IndexEntry struct {
+	Path     string `json:"path"` // Relative to the intent directory, with forward slashes
+	Section  string `json:"section"`
+	Language string `json:"language,omitempty"`
+	Size     int    `json:"size"`
+	SHA256   string `json:"sha256"`
+	TaskID   string `json:"task_id,omitempty"`
+}
+
+// infraDirs are top-level project directories holding deployment files
+var infraDirs = map[string]bool{
+	"k8s": true, "kubernetes": true, "deploy": true, "deployments": true, "manifests": true,
+	"helm": true, "charts": true, "terraform": true, "infra": true, "infrastructure": true,
+}
+
+// isInfraFile reports whether a project file deploys or builds the project
+// rather than being part of it
+func isInfraFile(file, content string) bool {
+	base := path.Base(file)
+	switch {
+	case strings.HasPrefix(base, "Dockerfile"), base == ".dockerignore", base == "Jenkinsfile", base == ".gitlab-ci.yml",
+		strings.HasPrefix(base, "docker-compose"), strings.HasPrefix(base, "compose."):
+		return true
+	case strings.HasPrefix(file, ".github/workflows/"):
+		return true
+	case infraDirs[strings.SplitN(file, "/", 2)[0]] && strings.Contains(file, "/"):
+		return true
+	}
+	switch filetypes.Detect(file).Language {
+	case "terraform":
+		return true
+	case "yaml":
+		return file != CatalogInfoFile && strings.Contains(content, "apiVersion:") && strings.Contains(content, "kind:")
+	}
+	return false
+}
+
+// WriteIntentDirectory writes the outputs of capsule as real files into a
+// fresh directory named after its intent under root, replacing whatever an
+// earlier run left there, and returns the directory with its index.
+// capsuleFile is recorded in the index when the capsule was exported.
+func WriteIntentDirectory(root string, capsule *QLCapsule, capsuleFile string) (string, *IntentIndex, error) {
+	name := capsule.Metadata.IntentID
+	if name == "" {
+		name = capsule.Metadata.CapsuleID
+	}
+	name = filepath.Base(filepath.Clean("/" + name))
+	if name == "/" || name == "." {
+		return "", nil, fmt.Errorf("capsule has neither an intent nor a capsule ID")
+	}
+	dir := filepath.Join(root, name)
+
+	// Build the tree beside its final place so readers never see half of it
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return "", nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	staging, err := os.MkdirTemp(root, "."+name+"-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create intent directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+	if err := os.Chmod(staging, 0755); err != nil {
+		return "", nil, fmt.Errorf("failed to create intent directory: %w", err)
+	}
+
+	index := &IntentIndex{
+		IntentID:  capsule.Metadata.IntentID,
+		Intent:    capsule.Metadata.IntentText,
+		CapsuleID: capsule.Metadata.CapsuleID,
+		Capsule:   capsuleFile,
+		CreatedAt: time.Now().UTC(),
+		Files:     []IndexEntry{},
+	}
+	write := func(entry IndexEntry, content []byte) error {
+		full := filepath.Join(staging, filepath.FromSlash(entry.Path))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", entry.Path, err)
+		}
+		if err := os.WriteFile(full, content, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", entry.Path, err)
+		}
+		sum := sha256.Sum256(content)
+		entry.Size, entry.SHA256 = len(content), hex.EncodeToString(sum[:])
+		index.Files = append(index.Files, entry)
+		return nil
+	}
+	for _, dir := range []string{IntentSourceDir, IntentInfraDir, IntentReportsDir, IntentLogsDir} {
+		if err := os.MkdirAll(filepath.Join(staging, dir), 0755); err != nil {
+			return "", nil, fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+
+	// Project files, split into source and infra
+	if project := capsule.UnifiedProject; project != nil {
+		paths := make([]string, 0, len(project.Files))
+		for p := range project.Files {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			clean := filetypes.CleanPath(p, "")
+			if clean == "" {
+				continue
+			}
+			content := project.Files[p]
+			section := IntentSourceDir
+			if isInfraFile(clean, content) {
+				section = IntentInfraDir
+			}
+			t := filetypes.Detect(clean)
+			if !filetypes.IsBinary(clean, []byte(content)) {
+				content = secrets.Scrub(content)
+			}
+			if err := write(IndexEntry{Path: section + "/" + clean, Section: section, Language: t.Language}, []byte(content)); err != nil {
+				return "", nil, err
+			}
+		}
+	}
+
+	// Reports, named as inside the capsule
+	reports := map[string]interface{}{
+		"execution_summary.json":  capsule.ExecutionSummary,
+		"security_report.json":    capsule.SecurityReport,
+		"quality_report.json":     capsule.QualityReport,
+		"validation_results.json": capsule.ValidationResults,
+	}
+	for name, data := range reports {
+		content, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to marshal %s: %w", name, err)
+		}
+		if err := write(IndexEntry{Path: IntentReportsDir + "/" + name, Section: IntentReportsDir, Language: "json"}, []byte(secrets.Scrub(string(content)))); err != nil {
+			return "", nil, err
+		}
+	}
+	for name, content := range capsule.Reports {
+		clean := filetypes.CleanPath(name, "")
+		if clean == "" {
+			continue
+		}
+		entry := IndexEntry{Path: IntentReportsDir + "/" + clean, Section: IntentReportsDir, Language: filetypes.Detect(clean).Language}
+		if err := write(entry, []byte(secrets.Scrub(string(content)))); err != nil {
+			return "", nil, err
+		}
+	}
+
+	// Task logs
+	failures := make(map[string][]string)
+	for _, e := range capsule.ExecutionSummary.ErrorSummary {
+		failures[e.TaskID] = append(failures[e.TaskID], e.Message)
+	}
+	for _, task := range capsule.Tasks {
+		name := strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(task.TaskID)
+		if name == "" {
+			continue
+		}
+		entry := IndexEntry{Path: IntentLogsDir + "/" + name + ".log", Section: IntentLogsDir, Language: filetypes.Text, TaskID: task.TaskID}
+		if err := write(entry, []byte(secrets.Scrub(taskLog(task, failures[task.TaskID])))); err != nil {
+			return "", nil, err
+		}
+	}
+
+	sort.Slice(index.Files, func(i, j int) bool { return index.Files[i].Path < index.Files[j].Path })
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal index: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(staging, IntentIndexFile), data, 0644); err != nil {
+		return "", nil, fmt.Errorf("failed to write index: %w", err)
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return "", nil, fmt.Errorf("failed to clear intent directory: %w", err)
+	}
+	if err := os.Rename(staging, dir); err != nil {
+		return "", nil, fmt.Errorf("failed to move intent directory into place: %w", err)
+	}
+	return dir, index, nil
+}
+
+// taskLog renders what happened to one task as plain text
+func taskLog(task TaskArtifact, failures []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "task:        %s\n", task.TaskID)
+	fmt.Fprintf(&b, "type:        %s\n", task.Type)
+	fmt.Fprintf(&b, "description: %s\n", task.Description)
+	fmt.Fprintf(&b, "status:      %s\n", task.Status)
+	fmt.Fprintf(&b, "agent:       %s\n", task.AgentID)
+	fmt.Fprintf(&b, "duration:    %s\n", task.ExecutionTime)
+	for _, e := range failures {
+		fmt.Fprintf(&b, "error:       %s\n", e)
+	}
+
+	if sr := task.SandboxResult; sr != nil {
+		fmt.Fprintf(&b, "\n== sandbox ==\nsuccess: %t\nsecurity score: %d\n", sr.Success, sr.SecurityScore)
+		if sr.Message != "" {
+			fmt.Fprintf(&b, "message: %s\n", sr.Message)
+		}
+		for _, cmd := range sr.Results {
+			fmt.Fprintf(&b, "\n$ %s\nexit %d after %s\n", cmd.Command, cmd.ExitCode, cmd.Duration)
+			if cmd.Stdout != "" {
+				fmt.Fprintf(&b, "-- stdout --\n%s\n", strings.TrimRight(cmd.Stdout, "\n"))
+			}
+			if cmd.Stderr != "" {
+				fmt.Fprintf(&b, "-- stderr --\n%s\n", strings.TrimRight(cmd.Stderr, "\n"))
+			}
+		}
+	}
+
+	if vr := task.ValidationResult; vr != nil {
+		fmt.Fprintf(&b, "\n== validation ==\nscore: %d\npassed: %t\n", vr.OverallScore, vr.Passed)
+	}
+
+	if task.Output != "" {
+		fmt.Fprintf(&b, "\n== output ==\n%s\n", strings.TrimRight(task.Output, "\n"))
+	}
+	return b.String()
+}
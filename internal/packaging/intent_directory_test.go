@@ -0,0 +1,86 @@
+package packaging
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"QLP/internal/models"
+	"QLP/internal/sandbox"
+)
+
+func TestWriteIntentDirectory(t *testing.T) {
+	root := t.TempDir()
+	capsule := &QLCapsule{
+		Metadata: CapsuleMetadata{CapsuleID: "QL-CAP-1", IntentID: "intent-1", IntentText: "orders API"},
+		Tasks: []TaskArtifact{{
+			TaskID: "T1", Type: models.TaskTypeCodegen, Status: models.TaskStatusCompleted, Output: "package main",
+			SandboxResult: &sandbox.SandboxExecutionResult{Success: true, Results: []sandbox.CommandResult{{Command: "go build ./...", Stdout: "ok"}}},
+		}},
+		UnifiedProject: &UnifiedProject{Files: map[string]string{
+			"cmd/main.go":      "package main\n\nfunc main() {}\n",
+			"go.mod":           "module orders\n",
+			"Dockerfile":       "FROM golang:1.24\n",
+			"k8s/service.yaml": "apiVersion: v1\nkind: Service\n",
+			"main.tf":          "resource \"null_resource\" \"x\" {}\n",
+			"config.yaml":      "port: 8080\n",
+		}},
+		Reports: map[string][]byte{"report.html": []byte("<html></html>")},
+	}
+
+	// A stale file from an earlier run is cleared
+	if err := os.MkdirAll(filepath.Join(root, "intent-1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "intent-1", "stale.go"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, index, err := WriteIntentDirectory(root, capsule, "output/ql_capsule_QL-CAP-1.qlcapsule")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != filepath.Join(root, "intent-1") {
+		t.Errorf("dir = %s", dir)
+	}
+	for _, file := range []string{
+		"source/cmd/main.go", "source/go.mod", "source/config.yaml",
+		"infra/Dockerfile", "infra/k8s/service.yaml", "infra/main.tf",
+		"reports/report.html", "reports/security_report.json", "logs/T1.log", "index.json",
+	} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(file))); err != nil {
+			t.Errorf("missing %s", file)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stale.go")); !os.IsNotExist(err) {
+		t.Error("stale file survived")
+	}
+	if main, _ := os.ReadFile(filepath.Join(dir, "source", "cmd", "main.go")); string(main) != "package main\n\nfunc main() {}\n" {
+		t.Errorf("main.go = %q", main)
+	}
+	if log, _ := os.ReadFile(filepath.Join(dir, "logs", "T1.log")); !strings.Contains(string(log), "$ go build ./...") {
+		t.Errorf("task log = %s", log)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, IntentIndexFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written IntentIndex
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatal(err)
+	}
+	if written.CapsuleID != "QL-CAP-1" || written.Capsule == "" || len(written.Files) != len(index.Files) {
+		t.Errorf("index = %+v", written)
+	}
+	for _, f := range written.Files {
+		if f.SHA256 == "" || !strings.HasPrefix(f.Path, f.Section+"/") {
+			t.Errorf("index entry %+v", f)
+		}
+		if f.Path == "logs/T1.log" && f.TaskID != "T1" {
+			t.Errorf("log entry without its task: %+v", f)
+		}
+	}
+}
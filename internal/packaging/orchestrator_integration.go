@@ -1,15 +1,20 @@
 package packaging
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"QLP/internal/audit"
 	"QLP/internal/models"
 	"QLP/internal/sandbox"
+	"QLP/internal/secrets"
+	"QLP/internal/storage"
 	"QLP/internal/types"
 )
 
@@ -20,14 +25,27 @@ type CapsuleOrchestrator struct {
 	outputDir   string
 	autoExport  bool
 	exportFormat string
+	artifactStore storage.ArtifactStore
+	reportRenderer ReportRenderer
+	projectExtender ProjectExtender
+	intentDirs     bool
 }
 
+// ReportRenderer renders human-readable reports for a capsule generated
+// from intent, keyed by file name
+type ReportRenderer func(ctx context.Context, intent models.Intent, capsule *QLCapsule) map[string][]byte
+
+// ProjectExtender returns files to add to the unified project of a capsule,
+// keyed by path, such as the docs written from the approved drops
+type ProjectExtender func(ctx context.Context, capsule *QLCapsule) map[string]string
+
 func NewCapsuleOrchestrator(outputDir string) *CapsuleOrchestrator {
 	return &CapsuleOrchestrator{
 		packager:     NewCapsulePackager(outputDir),
 		outputDir:    outputDir,
 		autoExport:   true,
 		exportFormat: "qlcapsule",
+		intentDirs:   true,
 	}
 }
 
@@ -48,13 +66,33 @@ func (co *CapsuleOrchestrator) ProcessIntentExecution(ctx context.Context, inten
 		return nil, fmt.Errorf("capsule validation failed: %w", err)
 	}
 
+	// Add files that were not produced by tasks
+	if co.projectExtender != nil {
+		co.packager.extendProject(capsule, intent, co.projectExtender(ctx, capsule))
+	}
+
+	// Render human-readable reports to ship inside the capsule
+	if co.reportRenderer != nil {
+		capsule.Reports = co.reportRenderer(ctx, intent, capsule)
+	}
+
 	// Auto-export if enabled
+	var capsuleFile string
 	if co.autoExport {
-		if err := co.exportCapsuleToFile(ctx, capsule); err != nil {
+		if capsuleFile, err = co.exportCapsuleToFile(ctx, capsule); err != nil {
 			log.Printf("Warning: Failed to auto-export capsule: %v", err)
 		}
 	}
 
+	// Lay the outputs out as real files in a directory of their own
+	if co.intentDirs {
+		if dir, index, err := WriteIntentDirectory(co.outputDir, capsule, capsuleFile); err != nil {
+			log.Printf("Warning: Failed to write intent directory: %v", err)
+		} else {
+			log.Printf("Intent outputs written to: %s (%d files)", dir, len(index.Files))
+		}
+	}
+
 	log.Printf("Capsule generated successfully: %s", capsule.Metadata.CapsuleID)
 	return capsule, nil
 }
@@ -100,7 +138,9 @@ func (co *CapsuleOrchestrator) mapAgentStatusToTaskStatus(agentStatus string) mo
 	}
 }
 
-func (co *CapsuleOrchestrator) exportCapsuleToFile(ctx context.Context, capsule *QLCapsule) error {
+// exportCapsuleToFile writes capsule to the output directory and returns
+// the file it wrote
+func (co *CapsuleOrchestrator) exportCapsuleToFile(ctx context.Context, capsule *QLCapsule) (string, error) {
 	// Generate filename
 	timestamp := time.Now().Format("20060102_150405")
 	filename := fmt.Sprintf("ql_capsule_%s_%s.%s", 
@@ -114,18 +154,45 @@ func (co *CapsuleOrchestrator) exportCapsuleToFile(ctx context.Context, capsule
 	// Export capsule
 	data, err := co.packager.ExportCapsule(ctx, capsule, co.exportFormat)
 	if err != nil {
-		return fmt.Errorf("failed to export capsule: %w", err)
+		return "", fmt.Errorf("failed to export capsule: %w", err)
 	}
 
 	// Write real file to disk
 	err = os.WriteFile(fullPath, data, 0644)
 	if err != nil {
-		return fmt.Errorf("failed to write capsule file: %w", err)
+		return "", fmt.Errorf("failed to write capsule file: %w", err)
 	}
 	
 	log.Printf("Capsule exported to: %s (%d bytes)", fullPath, len(data))
+
+	// Persist to durable artifact storage so the capsule can be shared via presigned links
+	if co.artifactStore != nil {
+		tenantID := audit.TenantFromContext(ctx)
+		artifact, err := co.artifactStore.Put(ctx, tenantID, capsule.Metadata.CapsuleID, filename, bytes.NewReader(data))
+		if err != nil {
+			return fullPath, fmt.Errorf("failed to store capsule artifact: %w", err)
+		}
+		log.Printf("Capsule stored as artifact: %s", artifact.Key)
+
+		// Reports are stored separately too so they can be downloaded without the capsule
+		for name, report := range capsule.Reports {
+			reportName := fmt.Sprintf("ql_capsule_%s_%s", capsule.Metadata.CapsuleID, name)
+			if _, err := co.artifactStore.Put(ctx, tenantID, capsule.Metadata.CapsuleID, reportName, bytes.NewReader([]byte(secrets.Scrub(string(report))))); err != nil {
+				return fullPath, fmt.Errorf("failed to store report artifact: %w", err)
+			}
+		}
+
+		// The catalog entity is stored on its own for the service catalog
+		if project := capsule.UnifiedProject; project != nil {
+			if entity, ok := project.Files[CatalogInfoFile]; ok {
+				if _, err := co.artifactStore.Put(ctx, tenantID, capsule.Metadata.CapsuleID, CatalogInfoFile, strings.NewReader(entity)); err != nil {
+					return fullPath, fmt.Errorf("failed to store catalog entity: %w", err)
+				}
+			}
+		}
+	}
 	
-	return nil
+	return fullPath, nil
 }
 
 // Agent execution result structure for integration
@@ -334,6 +401,27 @@ func (co *CapsuleOrchestrator) SetExportFormat(format string) {
 	co.exportFormat = format
 }
 
+// SetArtifactStore enables durable storage of exported capsules
+func (co *CapsuleOrchestrator) SetArtifactStore(store storage.ArtifactStore) {
+	co.artifactStore = store
+}
+
+// SetReportRenderer renders reports into every capsule produced
+func (co *CapsuleOrchestrator) SetReportRenderer(renderer ReportRenderer) {
+	co.reportRenderer = renderer
+}
+
+// SetProjectExtender adds files to the project of every capsule produced
+func (co *CapsuleOrchestrator) SetProjectExtender(extender ProjectExtender) {
+	co.projectExtender = extender
+}
+
+// SetIntentDirectories writes the outputs of every intent into a directory
+// of their own under the output directory, as WriteIntentDirectory does
+func (co *CapsuleOrchestrator) SetIntentDirectories(enabled bool) {
+	co.intentDirs = enabled
+}
+
 func (co *CapsuleOrchestrator) SetOutputDirectory(dir string) {
 	co.outputDir = dir
 	co.packager.outputDir = dir
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"QLP/internal/models"
+	"QLP/internal/stacks"
 )
 
 // ProjectMerger handles merging multiple task outputs into a single coherent project
@@ -24,6 +25,9 @@ func (pm *ProjectMerger) MergeTasksIntoProject(intent models.Intent, taskResults
 	// Determine overall project type and name from intent
 	projectName := pm.generateProjectName(intent.UserInput)
 	projectType := pm.determineProjectType(taskResults)
+	if intent.Metadata["stack"] == stacks.JavaSpring {
+		projectType = stacks.JavaSpring
+	}
 	
 	unifiedProject := &UnifiedProject{
 		Name:        projectName,
@@ -59,6 +63,11 @@ func (pm *ProjectMerger) MergeTasksIntoProject(intent models.Intent, taskResults
 	
 	// Organize files into proper project structure
 	unifiedProject.Files = pm.organizeProjectFiles(allFiles, projectType)
+	if projectType == stacks.JavaSpring {
+		for path, content := range stacks.ScaffoldJava(unifiedProject.Files, projectName, intent.Metadata["stack.build_tool"]) {
+			unifiedProject.Files[path] = content
+		}
+	}
 	unifiedProject.Structure = pm.generateProjectStructure(unifiedProject.Files)
 	
 	return unifiedProject, nil
@@ -74,6 +83,8 @@ func (pm *ProjectMerger) mergeTaskFiles(task models.Task, taskFiles map[string]s
 				// Organize Go files into proper structure
 				newPath := pm.organizeGoFile(path, content)
 				allFiles[newPath] = content
+			} else if strings.HasSuffix(path, ".java") {
+				allFiles[stacks.JavaSourcePath(path, content)] = content
 			} else {
 				allFiles[path] = content
 			}
@@ -97,6 +108,9 @@ func (pm *ProjectMerger) mergeTaskFiles(task models.Task, taskFiles map[string]s
 			if strings.HasSuffix(path, "_test.go") || strings.HasSuffix(path, ".test.go") {
 				newPath := fmt.Sprintf("tests/%s", filepath.Base(path))
 				allFiles[newPath] = content
+			} else if strings.HasSuffix(path, ".java") {
+				// JUnit tests live beside the sources in the Maven layout
+				allFiles[stacks.JavaSourcePath(path, content)] = content
 			} else {
 				newPath := fmt.Sprintf("tests/%s", path)
 				allFiles[newPath] = content
@@ -193,6 +207,11 @@ func (pm *ProjectMerger) organizeProjectFiles(files map[string]string, projectTy
 	return organized
 }
 
+// ProjectName is the name given to the unified project of an intent
+func ProjectName(userInput string) string {
+	return NewProjectMerger().generateProjectName(userInput)
+}
+
 // generateProjectStructure creates a directory structure map
 func (pm *ProjectMerger) generateProjectStructure(files map[string]string) map[string][]string {
 	structure := make(map[string][]string)
@@ -243,6 +262,9 @@ func (pm *ProjectMerger) determineProjectType(taskResults []TaskExecutionResult)
 	hasAPI := false
 	
 	for _, result := range taskResults {
+		if strings.Contains(result.Output, "@SpringBootApplication") || strings.Contains(result.Output, "<artifactId>spring-boot") {
+			return stacks.JavaSpring
+		}
 		if strings.Contains(result.Output, "package main") || strings.Contains(result.Output, "go.mod") {
 			hasGo = true
 		}
@@ -7,7 +7,9 @@ import (
 	"strings"
 	"time"
 
+	"QLP/internal/llm"
 	"QLP/internal/models"
+	"QLP/internal/secrets"
 )
 
 // QuantumDrop represents a specialized, categorized output that can be reviewed independently
@@ -54,6 +56,10 @@ type DropMetadata struct {
 	ValidationPassed bool             `json:"validation_passed"`
 	HITLRequired    bool              `json:"hitl_required"`
 	ReviewNotes     []string          `json:"review_notes,omitempty"`
+	RequiredEnv     []secrets.Requirement `json:"required_env,omitempty"`
+	// Derived drops are written by agents from the other drops rather than by
+	// tasks, and reach the capsule through the project extender
+	Derived bool `json:"derived,omitempty"`
 }
 
 // HITLDecision represents human feedback on a QuantumDrop
@@ -76,7 +82,9 @@ const (
 
 // QuantumDropGenerator creates specialized QuantumDrops from task results
 type QuantumDropGenerator struct {
-	fileGenerator *FileGenerator
+	fileGenerator      *FileGenerator
+	fixupClient        llm.Client
+	dependencyResolver DependencyResolver
 }
 
 func NewQuantumDropGenerator() *QuantumDropGenerator {
@@ -85,6 +93,11 @@ func NewQuantumDropGenerator() *QuantumDropGenerator {
 	}
 }
 
+// SetFixupClient enables LLM fix-up prompts for files that fail cross-file consistency checks
+func (qdg *QuantumDropGenerator) SetFixupClient(client llm.Client) {
+	qdg.fixupClient = client
+}
+
 // GenerateQuantumDrops creates categorized drops from task results
 func (qdg *QuantumDropGenerator) GenerateQuantumDrops(intent models.Intent, taskResults []TaskExecutionResult) ([]QuantumDrop, error) {
 	log.Printf("Generating QuantumDrops from %d task results", len(taskResults))
@@ -138,6 +151,27 @@ func (qdg *QuantumDropGenerator) GenerateQuantumDrops(intent models.Intent, task
 	return drops, nil
 }
 
+// DerivedDrop builds a drop from files an agent wrote after the task drops,
+// such as generated docs. The kind is used in the drop ID, e.g. "ARCHDOCS".
+func (qdg *QuantumDropGenerator) DerivedDrop(kind string, dropType DropType, name, description string, files map[string]string) QuantumDrop {
+	return QuantumDrop{
+		ID:          fmt.Sprintf("QD-%s-%d", kind, time.Now().Unix()),
+		Type:        dropType,
+		Name:        name,
+		Description: description,
+		Files:       files,
+		Structure:   qdg.generateDropStructure(files),
+		Metadata: DropMetadata{
+			FileCount:        len(files),
+			TotalLines:       qdg.countTotalLines(files),
+			ValidationPassed: true,
+			Derived:          true,
+		},
+		Status:    DropStatusReady,
+		CreatedAt: time.Now(),
+	}
+}
+
 func (qdg *QuantumDropGenerator) groupTasksByType(taskResults []TaskExecutionResult) map[models.TaskType][]TaskExecutionResult {
 	groups := make(map[models.TaskType][]TaskExecutionResult)
 	
@@ -206,6 +240,7 @@ func (qdg *QuantumDropGenerator) generateInfrastructureDrop(intent models.Intent
 		SecurityScore:   qdg.calculateSecurityScore(tasks),
 		ValidationPassed: qdg.checkValidationPassed(tasks),
 		HITLRequired:    len(drop.Files) > 3, // Require HITL for complex infrastructure
+		RequiredEnv:     secrets.DetectRequirements(drop.Files),
 	}
 	
 	drop.Structure = qdg.generateDropStructure(drop.Files)
@@ -256,6 +291,9 @@ func (qdg *QuantumDropGenerator) generateCodebaseDrop(intent models.Intent, task
 			} else if strings.HasSuffix(path, ".json") && strings.Contains(path, "package") {
 				drop.Files[path] = content
 				technologies["Node.js"] = true
+			} else if isDependencyManifest(path) {
+				drop.Files[path] = content
+				technologies["Python"] = true
 			}
 		}
 	}
@@ -276,6 +314,7 @@ func (qdg *QuantumDropGenerator) generateCodebaseDrop(intent models.Intent, task
 		SecurityScore:   qdg.calculateSecurityScore(tasks),
 		ValidationPassed: qdg.checkValidationPassed(tasks),
 		HITLRequired:    len(drop.Files) > 5, // Require HITL for complex codebases
+		RequiredEnv:     secrets.DetectRequirements(drop.Files),
 	}
 	
 	drop.Structure = qdg.generateDropStructure(drop.Files)
@@ -579,6 +618,20 @@ func (qdg *QuantumDropGenerator) generateProjectName(userInput string) string {
 	return "quantum-project"
 }
 
+// TaskFiles returns the files an agent's output for a task describes, as
+// its drop would hold them
+func (qdg *QuantumDropGenerator) TaskFiles(task models.Task, agentOutput string) map[string]string {
+	llmOutput := qdg.extractLLMOutput(agentOutput)
+	if llmOutput == "" {
+		llmOutput = agentOutput
+	}
+	projectStruct, err := qdg.fileGenerator.ParseLLMOutput(task.ID, string(task.Type), llmOutput)
+	if err != nil {
+		return nil
+	}
+	return qdg.fileGenerator.GenerateFileStructure(projectStruct)
+}
+
 func (qdg *QuantumDropGenerator) extractLLMOutput(agentOutput string) string {
 	lines := strings.Split(agentOutput, "\n")
 	var llmOutput []string
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	"QLP/internal/filetypes"
 	"QLP/internal/models"
 	"QLP/internal/types"
 )
@@ -216,13 +217,14 @@ func (cp *CapsulePackager) collectArtifacts(results []TaskExecutionResult) []Art
 	for _, result := range results {
 		// Main task output artifact
 		if result.Output != "" {
+			outputPath := cp.taskOutputPath(result)
 			artifact := ArtifactReference{
 				Name:      fmt.Sprintf("%s_output", result.Task.ID),
 				Type:      "task_output",
-				Path:      fmt.Sprintf("outputs/%s.txt", result.Task.ID),
+				Path:      outputPath,
 				Size:      int64(len(result.Output)),
 				Checksum:  cp.calculateChecksum([]byte(result.Output)),
-				MimeType:  cp.determineMimeType(result.Task.Type),
+				MimeType:  filetypes.MimeType(outputPath),
 				CreatedAt: time.Now(),
 				Metadata: map[string]string{
 					"task_id":   result.Task.ID,
@@ -506,7 +508,7 @@ func (cp *CapsulePackager) extractTaskArtifacts(result TaskExecutionResult) []st
 	artifacts := []string{}
 	
 	if result.Output != "" {
-		artifacts = append(artifacts, fmt.Sprintf("outputs/%s.txt", result.Task.ID))
+		artifacts = append(artifacts, cp.taskOutputPath(result))
 	}
 	
 	if result.SandboxResult != nil {
@@ -14,21 +14,15 @@ func (cp *CapsulePackager) calculateChecksum(data []byte) string {
 	return fmt.Sprintf("%x", hash)
 }
 
-func (cp *CapsulePackager) determineMimeType(taskType models.TaskType) string {
-	switch taskType {
-	case models.TaskTypeCodegen:
-		return "text/x-go"
-	case models.TaskTypeTest:
-		return "text/x-go"
-	case models.TaskTypeInfra:
-		return "text/x-terraform"
-	case models.TaskTypeDoc:
-		return "text/markdown"
-	case models.TaskTypeAnalyze:
-		return "text/plain"
-	default:
-		return "text/plain"
+// taskOutputPath is where a task's output is stored in the capsule, named
+// for the language the output is in
+func (cp *CapsulePackager) taskOutputPath(result TaskExecutionResult) string {
+	output := cp.extractLLMOutput(result.Output)
+	if output == "" {
+		output = result.Output
 	}
+	name, _ := cp.fileGenerator.determineFileInfo(string(result.Task.Type), output)
+	return fmt.Sprintf("outputs/%s/%s", result.Task.ID, name)
 }
 
 func (cp *CapsulePackager) generateREADME(capsule *QLCapsule) string {
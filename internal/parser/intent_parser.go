@@ -2,12 +2,14 @@ package parser
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
+	"QLP/internal/capabilities"
 	"QLP/internal/llm"
+	"QLP/internal/llm/jsonutil"
 	"QLP/internal/models"
 )
 
@@ -22,23 +24,31 @@ func NewIntentParser(llmClient llm.Client) *IntentParser {
 }
 
 func (p *IntentParser) ParseIntent(ctx context.Context, userInput string) (*models.Intent, error) {
-	prompt := p.buildParsingPrompt(userInput)
+	return p.ParseConstrainedIntent(ctx, userInput, nil)
+}
+
+// ParseConstrainedIntent decomposes an intent into tasks that respect the given
+// organization constraints, which are recorded on the returned intent
+func (p *IntentParser) ParseConstrainedIntent(ctx context.Context, userInput string, constraints *models.Constraints) (*models.Intent, error) {
+	agents := capabilities.Default()
+	prompt := p.buildParsingPrompt(userInput) + formatAgents(agents) + formatConstraints(constraints)
 
-	response, err := p.llmClient.Complete(ctx, prompt)
-	if err != nil {
+	var taskData []taskSpec
+	if err := jsonutil.CompleteAndDecode(ctx, p.llmClient, prompt, taskListSchema(agents), &taskData, 3); err != nil {
+		if errors.Is(err, jsonutil.ErrInvalid) {
+			return nil, fmt.Errorf("failed to extract tasks from LLM response: %w", err)
+		}
 		return nil, fmt.Errorf("failed to parse intent with LLM: %w", err)
 	}
 
-	tasks, err := p.extractTasksFromResponse(response)
-	if err != nil {
-		return nil, fmt.Errorf("failed to extract tasks from LLM response: %w", err)
-	}
+	tasks := p.buildTasks(taskData, agents)
 
 	intent := &models.Intent{
 		ID:              generateID(),
 		UserInput:       userInput,
 		Tasks:           tasks,
 		Metadata:        p.extractMetadata(userInput),
+		Constraints:     constraints,
 		Status:          models.IntentStatusPending,
 		OverallScore:    0,
 		ExecutionTimeMS: 0,
@@ -58,7 +68,7 @@ User Intent: %s
 
 For each task, provide:
 1. A unique identifier (task_id)
-2. Task type (codegen, infra, doc, test, analyze)
+2. Task type and, optionally, the agent to run it (see the available agents below)
 3. Clear description of what needs to be done
 4. Dependencies on other tasks (use task IDs)
 5. Priority level (high, medium, low)
@@ -82,27 +92,73 @@ Focus on creating tasks that are:
 `, userInput)
 }
 
-func (p *IntentParser) extractTasksFromResponse(response string) ([]models.Task, error) {
-	response = strings.TrimSpace(response)
+// formatConstraints asks the decomposition to plan within org standards
+func formatConstraints(constraints *models.Constraints) string {
+	standards := constraints.Standards()
+	if len(standards) == 0 {
+		return ""
+	}
+	return "\nThe project must follow these organization standards; write task descriptions that respect them:\n- " +
+		strings.Join(standards, "\n- ") + "\n"
+}
 
-	if strings.HasPrefix(response, "```json") {
-		response = strings.TrimPrefix(response, "```json")
-		response = strings.TrimSuffix(response, "```")
-		response = strings.TrimSpace(response)
+// formatAgents lists the registered agents so decomposition can use the
+// task types they add and pick a specialist for a task
+func formatAgents(agents *capabilities.Registry) string {
+	var b strings.Builder
+	b.WriteString("\nAvailable agents. Set \"agent\" on a task to pick a specialist; otherwise the first agent listed for its type runs it:\n")
+	for _, a := range agents.List() {
+		fmt.Fprintf(&b, "- %s (task types: %s): %s\n", a.Name, joinTaskTypes(a.TaskTypes), a.Description)
 	}
+	return b.String()
+}
 
-	var taskData []struct {
-		ID           string   `json:"id"`
-		Type         string   `json:"type"`
-		Description  string   `json:"description"`
-		Dependencies []string `json:"dependencies"`
-		Priority     string   `json:"priority"`
+func joinTaskTypes(types []models.TaskType) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = string(t)
 	}
+	return strings.Join(names, ", ")
+}
+
+// taskSpec is a task as returned by the LLM before ID normalisation
+type taskSpec struct {
+	ID           string   `json:"id"`
+	Type         string   `json:"type"`
+	Agent        string   `json:"agent,omitempty"`
+	Description  string   `json:"description"`
+	Dependencies []string `json:"dependencies"`
+	Priority     string   `json:"priority"`
+}
 
-	if err := json.Unmarshal([]byte(response), &taskData); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal task data: %w", err)
+// taskListSchema constrains the task decomposition response to the task
+// types and agents in the registry
+func taskListSchema(agents *capabilities.Registry) map[string]interface{} {
+	var taskTypes, agentNames []string
+	for _, t := range agents.TaskTypes() {
+		taskTypes = append(taskTypes, string(t))
 	}
+	for _, a := range agents.List() {
+		agentNames = append(agentNames, a.Name)
+	}
+	return map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type":     "object",
+			"required": []string{"id", "type", "description"},
+			"properties": map[string]interface{}{
+				"id":           map[string]interface{}{"type": "string"},
+				"type":         map[string]interface{}{"type": "string", "enum": taskTypes},
+				"agent":        map[string]interface{}{"type": "string", "enum": agentNames},
+				"description":  map[string]interface{}{"type": "string"},
+				"dependencies": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				"priority":     map[string]interface{}{"type": "string"},
+			},
+		},
+	}
+}
 
+func (p *IntentParser) buildTasks(taskData []taskSpec, agents *capabilities.Registry) []models.Task {
 	tasks := make([]models.Task, len(taskData))
 	now := time.Now()
 
@@ -118,9 +174,12 @@ func (p *IntentParser) extractTasksFromResponse(response string) ([]models.Task,
 			Status:       models.TaskStatusPending,
 			CreatedAt:    now,
 		}
+		if a, err := agents.Get(td.Agent); err == nil && a.Supports(tasks[i].Type) {
+			capabilities.Assign(&tasks[i], a)
+		}
 	}
 
-	return tasks, nil
+	return tasks
 }
 
 func (p *IntentParser) extractMetadata(userInput string) map[string]string {
@@ -162,13 +221,7 @@ func (p *IntentParser) generateProfessionalTaskID(taskType string, sequence int)
 	return fmt.Sprintf("QL-%s-%s-%03d", prefix, timestamp, sequence)
 }
 
-func (p *IntentParser) convertDependenciesToProfessionalIDs(dependencies []string, taskData []struct {
-	ID           string   `json:"id"`
-	Type         string   `json:"type"`
-	Description  string   `json:"description"`
-	Dependencies []string `json:"dependencies"`
-	Priority     string   `json:"priority"`
-}) []string {
+func (p *IntentParser) convertDependenciesToProfessionalIDs(dependencies []string, taskData []taskSpec) []string {
 	if len(dependencies) == 0 {
 		return dependencies
 	}
@@ -0,0 +1,367 @@
+// Package preview serves a local web UI for reviewing a capsule before it is
+// deployed: the project's file tree with syntax highlighting and validation
+// findings anchored to their lines, the task DAG and the rendered reports.
+package preview
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"QLP/internal/capsulediff"
+	"QLP/internal/codemetrics"
+	"QLP/internal/packaging"
+	"QLP/internal/sarif"
+	"QLP/internal/types"
+	"QLP/internal/validation"
+)
+
+// maxEntrySize caps how much of a single archive entry is read
+const maxEntrySize = 16 << 20
+
+// Annotation is a validation finding, anchored to a line of a project file
+// when the validator reported one. File-level findings have no line and
+// capsule-level findings no file.
+type Annotation struct {
+	File        string `json:"file,omitempty"`
+	Line        int    `json:"line,omitempty"`
+	Severity    string `json:"severity"`
+	Source      string `json:"source"`
+	Message     string `json:"message"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// Task is a node of the capsule's task DAG. Level is its depth: tasks
+// without dependencies are level 0.
+type Task struct {
+	ID           string   `json:"id"`
+	Type         string   `json:"type"`
+	Description  string   `json:"description"`
+	Status       string   `json:"status"`
+	Dependencies []string `json:"dependencies"`
+	Score        int      `json:"score,omitempty"`
+	Level        int      `json:"level"`
+}
+
+// Capsule is what the preview shows of a capsule
+type Capsule struct {
+	ID          string            `json:"id"`
+	Intent      string            `json:"intent,omitempty"`
+	Score       int               `json:"score"`
+	Files       map[string]string `json:"-"`
+	Tasks       []Task            `json:"tasks"`
+	Reports     map[string][]byte `json:"-"`
+	Annotations []Annotation      `json:"annotations"`
+}
+
+// Load reads a capsule exported as an archive or JSON document, or a
+// project directory's files, and annotates its files with the findings
+// recorded in its reports and those of the offline validators
+func Load(ctx context.Context, data []byte) (*Capsule, error) {
+	files, err := capsulediff.Load(data)
+	if err != nil {
+		return nil, err
+	}
+	c := &Capsule{Files: files, Reports: make(map[string][]byte)}
+	if bytes.HasPrefix(data, []byte("PK")) {
+		err = c.readArchive(data)
+	} else {
+		err = c.readJSON(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.Analyze(ctx)
+	return c, nil
+}
+
+// FromFiles previews a project without capsule metadata, e.g. a directory
+func FromFiles(ctx context.Context, id string, files map[string]string) *Capsule {
+	c := &Capsule{ID: id, Files: files, Tasks: []Task{}, Reports: make(map[string][]byte)}
+	c.Analyze(ctx)
+	return c
+}
+
+func (c *Capsule) readArchive(data []byte) error {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("failed to open capsule archive: %w", err)
+	}
+	var tasks []packaging.TaskArtifact
+	for _, f := range reader.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		switch {
+		case f.Name == "metadata.json":
+			var meta packaging.CapsuleMetadata
+			if err := readJSONEntry(f, &meta); err != nil {
+				return err
+			}
+			c.ID, c.Intent, c.Score = meta.CapsuleID, meta.IntentText, meta.OverallScore
+		case strings.HasPrefix(f.Name, "tasks/") && path.Ext(f.Name) == ".json":
+			var task packaging.TaskArtifact
+			if err := readJSONEntry(f, &task); err != nil {
+				return err
+			}
+			tasks = append(tasks, task)
+		case strings.HasPrefix(f.Name, "reports/"):
+			content, err := readEntry(f)
+			if err != nil {
+				return err
+			}
+			c.Reports[strings.TrimPrefix(f.Name, "reports/")] = content
+		}
+	}
+	c.setTasks(tasks)
+	c.recordedFindings()
+	return nil
+}
+
+// FromCapsule previews a packaged capsule before it is exported
+func FromCapsule(ctx context.Context, capsule *packaging.QLCapsule) *Capsule {
+	files := make(map[string]string)
+	if capsule.UnifiedProject != nil {
+		for name, content := range capsule.UnifiedProject.Files {
+			files[name] = content
+		}
+	}
+	c := &Capsule{Files: files, Reports: make(map[string][]byte)}
+	for name, content := range capsule.Reports {
+		c.Reports[name] = content
+	}
+	c.readCapsule(capsule)
+	c.Analyze(ctx)
+	return c
+}
+
+func (c *Capsule) readJSON(data []byte) error {
+	var capsule packaging.QLCapsule
+	if err := json.Unmarshal(data, &capsule); err != nil {
+		return fmt.Errorf("failed to parse capsule: %w", err)
+	}
+	c.readCapsule(&capsule)
+	return nil
+}
+
+func (c *Capsule) readCapsule(capsule *packaging.QLCapsule) {
+	c.ID, c.Intent, c.Score = capsule.Metadata.CapsuleID, capsule.Metadata.IntentText, capsule.Metadata.OverallScore
+	c.setTasks(capsule.Tasks)
+	if security, err := json.Marshal(capsule.SecurityReport); err == nil && len(capsule.SecurityReport.CriticalIssues) > 0 {
+		c.Reports["security_report.json"] = security
+	}
+	c.recordedFindings()
+}
+
+// setTasks orders the tasks by level, then ID
+func (c *Capsule) setTasks(artifacts []packaging.TaskArtifact) {
+	byID := make(map[string]packaging.TaskArtifact)
+	for _, t := range artifacts {
+		byID[t.TaskID] = t
+	}
+	levels := make(map[string]int)
+	var level func(id string, seen map[string]bool) int
+	level = func(id string, seen map[string]bool) int {
+		if l, ok := levels[id]; ok {
+			return l
+		}
+		if seen[id] {
+			return 0 // A cycle, which the DAG never has
+		}
+		seen[id] = true
+		l := 0
+		for _, dep := range byID[id].Dependencies {
+			if _, ok := byID[dep]; ok {
+				l = max(l, level(dep, seen)+1)
+			}
+		}
+		levels[id] = l
+		return l
+	}
+
+	c.Tasks = make([]Task, 0, len(artifacts))
+	for _, t := range artifacts {
+		task := Task{
+			ID:           t.TaskID,
+			Type:         string(t.Type),
+			Description:  t.Description,
+			Status:       string(t.Status),
+			Dependencies: t.Dependencies,
+			Level:        level(t.TaskID, make(map[string]bool)),
+		}
+		if t.ValidationResult != nil {
+			task.Score = t.ValidationResult.OverallScore
+		}
+		c.Tasks = append(c.Tasks, task)
+	}
+	sort.Slice(c.Tasks, func(i, j int) bool {
+		if c.Tasks[i].Level != c.Tasks[j].Level {
+			return c.Tasks[i].Level < c.Tasks[j].Level
+		}
+		return c.Tasks[i].ID < c.Tasks[j].ID
+	})
+}
+
+// recordedFindings annotates the findings of the capsule's SARIF reports
+// and security report
+func (c *Capsule) recordedFindings() {
+	for name, content := range c.Reports {
+		if path.Ext(name) != ".sarif" {
+			continue
+		}
+		var log sarif.Log
+		if json.Unmarshal(content, &log) != nil {
+			continue
+		}
+		for _, run := range log.Runs {
+			for _, r := range run.Results {
+				a := Annotation{Severity: sarifSeverity(r.Level), Source: r.RuleID, Message: r.Message.Text}
+				if len(r.Locations) > 0 {
+					loc := r.Locations[0].PhysicalLocation
+					a.File = loc.ArtifactLocation.URI
+					if loc.Region != nil {
+						a.Line = loc.Region.StartLine
+					}
+				}
+				c.annotate(a)
+			}
+		}
+	}
+	if content, ok := c.Reports["security_report.json"]; ok {
+		var report struct {
+			CriticalIssues []types.SecurityIssue `json:"critical_issues"`
+		}
+		if json.Unmarshal(content, &report) == nil {
+			for _, issue := range report.CriticalIssues {
+				file, line := splitLocation(issue.Location)
+				c.annotate(Annotation{File: file, Line: line, Severity: strings.ToLower(issue.Severity),
+					Source: "security/" + issue.Type, Message: issue.Description})
+			}
+		}
+	}
+}
+
+// Analyze annotates the findings of the validators that run offline: the
+// Dockerfile linter and the code metrics
+func (c *Capsule) Analyze(ctx context.Context) {
+	for _, result := range validation.NewDockerfileValidator().ValidateFiles(ctx, c.Files) {
+		for _, f := range result.Findings {
+			c.annotate(Annotation{File: result.Path, Line: f.Line, Severity: strings.ToLower(f.Severity),
+				Source: "dockerfile/" + f.Rule, Message: f.Message, Remediation: f.Suggestion})
+		}
+	}
+	if metrics := codemetrics.Analyze(c.Files); metrics != nil {
+		for _, h := range metrics.Hotspots {
+			c.annotate(Annotation{File: h.File, Line: h.Line, Severity: h.Severity,
+				Source: "metrics/" + h.Metric, Message: h.Message})
+		}
+	}
+	sort.SliceStable(c.Annotations, func(i, j int) bool {
+		a, b := c.Annotations[i], c.Annotations[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+}
+
+// annotate records a finding once, as capsule-level when its file is not
+// part of the project
+func (c *Capsule) annotate(a Annotation) {
+	if _, ok := c.Files[a.File]; !ok {
+		a.File, a.Line = "", 0
+	}
+	for _, existing := range c.Annotations {
+		if existing == a {
+			return
+		}
+	}
+	c.Annotations = append(c.Annotations, a)
+}
+
+// FileAnnotations returns the findings on a file
+func (c *Capsule) FileAnnotations(file string) []Annotation {
+	annotations := make([]Annotation, 0)
+	for _, a := range c.Annotations {
+		if a.File == file {
+			annotations = append(annotations, a)
+		}
+	}
+	return annotations
+}
+
+// Paths returns the project's files in order
+func (c *Capsule) Paths() []string {
+	paths := make([]string, 0, len(c.Files))
+	for p := range c.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// ReportNames returns the capsule's reports in order
+func (c *Capsule) ReportNames() []string {
+	names := make([]string, 0, len(c.Reports))
+	for name := range c.Reports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var locationLine = regexp.MustCompile(`^(.+):(\d+)(?::\d+)?$`)
+
+// splitLocation splits "path:line" locations
+func splitLocation(location string) (string, int) {
+	if m := locationLine.FindStringSubmatch(location); m != nil {
+		line, _ := strconv.Atoi(m[2])
+		return m[1], line
+	}
+	return location, 0
+}
+
+func sarifSeverity(level string) string {
+	switch level {
+	case "error":
+		return "high"
+	case "warning":
+		return "medium"
+	case "note":
+		return "low"
+	default:
+		return "info"
+	}
+}
+
+func readEntry(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+	content, err := io.ReadAll(io.LimitReader(rc, maxEntrySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	return content, nil
+}
+
+func readJSONEntry(f *zip.File, v interface{}) error {
+	content, err := readEntry(f)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", f.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package preview
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func capsuleArchive(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"metadata.json":          `{"capsule_id":"QL-CAP-1","intent_text":"Create an API","overall_score":82}`,
+		"tasks/QLI-1-1.json":     `{"task_id":"QLI-1-1","type":"codegen","description":"Write the API","status":"completed"}`,
+		"tasks/QLI-1-2.json":     `{"task_id":"QLI-1-2","type":"test","description":"Test it","status":"completed","dependencies":["QLI-1-1"]}`,
+		"tasks/QLI-1-3.json":     `{"task_id":"QLI-1-3","type":"infra","description":"Deploy it","status":"failed","dependencies":["QLI-1-1","QLI-1-2"]}`,
+		"project/api/main.go":    "package main\n\nfunc main() {\n\tdb.Query(\"SELECT \" + input)\n}\n",
+		"project/api/Dockerfile": "FROM golang:latest\nCOPY . .\n",
+		"reports/report.html":    "<html><body>Report</body></html>",
+		"reports/report.sarif": `{"runs":[{"results":[
+			{"ruleId":"security/sql_injection","level":"error","message":{"text":"SQL built from input"},
+			 "locations":[{"physicalLocation":{"artifactLocation":{"uri":"main.go"},"region":{"startLine":4}}}]},
+			{"ruleId":"quality/coverage","level":"warning","message":{"text":"No tests"},
+			 "locations":[{"physicalLocation":{"artifactLocation":{"uri":"README.md"}}}]}]}]}`,
+		"reports/security_report.json": `{"critical_issues":[{"type":"sql_injection","severity":"HIGH","description":"SQL built from input","location":"main.go:4"}]}`,
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestLoad(t *testing.T) {
+	c, err := Load(context.Background(), capsuleArchive(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ID != "QL-CAP-1" || c.Intent != "Create an API" || c.Score != 82 {
+		t.Errorf("unexpected metadata %+v", c)
+	}
+	if strings.Join(c.Paths(), ",") != "Dockerfile,main.go" {
+		t.Errorf("unexpected files %v", c.Paths())
+	}
+	levels := make(map[string]int)
+	for _, task := range c.Tasks {
+		levels[task.ID] = task.Level
+	}
+	if levels["QLI-1-1"] != 0 || levels["QLI-1-2"] != 1 || levels["QLI-1-3"] != 2 {
+		t.Errorf("unexpected DAG levels %v", levels)
+	}
+	if strings.Join(c.ReportNames(), ",") != "report.html,report.sarif,security_report.json" {
+		t.Errorf("unexpected reports %v", c.ReportNames())
+	}
+
+	var onQuery, general, dockerfile int
+	for _, a := range c.Annotations {
+		switch {
+		case a.File == "main.go" && a.Line == 4:
+			onQuery++
+		case a.File == "" && a.Message == "No tests":
+			general++
+		case a.File == "Dockerfile" && a.Line > 0:
+			dockerfile++
+		}
+	}
+	if onQuery != 1 {
+		t.Errorf("expected the finding both reports record anchored to main.go:4 once, got %+v", c.Annotations)
+	}
+	if general != 1 {
+		t.Errorf("expected findings on files outside the project kept capsule-wide, got %+v", c.Annotations)
+	}
+	if dockerfile == 0 {
+		t.Errorf("expected the Dockerfile linted, got %+v", c.Annotations)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	c, err := Load(context.Background(), capsuleArchive(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(Handler(c))
+	defer server.Close()
+
+	get := func(path string) *http.Response {
+		t.Helper()
+		res, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+
+	if res := get("/"); res.StatusCode != http.StatusOK || !strings.HasPrefix(res.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("expected the UI, got %d %s", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+
+	var summary struct {
+		ID    string `json:"id"`
+		Files []struct {
+			Path     string `json:"path"`
+			Findings int    `json:"findings"`
+		} `json:"files"`
+		Tasks       []Task       `json:"tasks"`
+		Annotations []Annotation `json:"annotations"`
+	}
+	if err := json.NewDecoder(get("/api/capsule").Body).Decode(&summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.ID != "QL-CAP-1" || len(summary.Files) != 2 || len(summary.Tasks) != 3 || len(summary.Annotations) != 1 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+
+	var file struct {
+		Language    string       `json:"language"`
+		Content     string       `json:"content"`
+		Annotations []Annotation `json:"annotations"`
+	}
+	if err := json.NewDecoder(get("/api/files/main.go").Body).Decode(&file); err != nil {
+		t.Fatal(err)
+	}
+	if file.Language != "go" || !strings.Contains(file.Content, "db.Query") || len(file.Annotations) == 0 {
+		t.Errorf("unexpected file %+v", file)
+	}
+	if res := get("/api/files/missing.go"); res.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing file, got %d", res.StatusCode)
+	}
+
+	res := get("/reports/report.html")
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Security-Policy") != "sandbox" {
+		t.Errorf("expected the report served sandboxed, got %d %v", res.StatusCode, res.Header)
+	}
+}
@@ -0,0 +1,169 @@
+package preview
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+//go:embed ui/index.html
+var indexHTML []byte
+
+// Routes returns the preview endpoints:
+//
+//	GET /                   the preview UI
+//	GET /api/capsule        the capsule's summary, file tree, tasks, reports and findings
+//	GET /api/files/{path}   a project file's content and the findings anchored to it
+//	GET /reports/{name}     a report as the capsule packaged it
+func Routes(c *Capsule) map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /{$}":                 indexHandler(),
+		"GET /api/capsule":         capsuleHandler(c),
+		"GET /api/files/{path...}": fileHandler(c),
+		"GET /reports/{name}":      reportHandler(c),
+	}
+}
+
+// Handler serves the preview of a capsule
+func Handler(c *Capsule) http.Handler {
+	mux := http.NewServeMux()
+	for pattern, h := range Routes(c) {
+		mux.Handle(pattern, h)
+	}
+	return mux
+}
+
+// Serve serves the preview on listener until ctx is cancelled
+func Serve(ctx context.Context, listener net.Listener, c *Capsule) error {
+	server := &http.Server{
+		Handler:           Handler(c),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func indexHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(indexHTML)
+	})
+}
+
+type fileEntry struct {
+	Path     string `json:"path"`
+	Size     int    `json:"size"`
+	Findings int    `json:"findings"`
+}
+
+func capsuleHandler(c *Capsule) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counts := make(map[string]int)
+		general := make([]Annotation, 0)
+		for _, a := range c.Annotations {
+			if a.File == "" {
+				general = append(general, a)
+			}
+			counts[a.File]++
+		}
+		files := make([]fileEntry, 0, len(c.Files))
+		for _, p := range c.Paths() {
+			files = append(files, fileEntry{Path: p, Size: len(c.Files[p]), Findings: counts[p]})
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"id":          c.ID,
+			"intent":      c.Intent,
+			"score":       c.Score,
+			"files":       files,
+			"tasks":       c.Tasks,
+			"reports":     c.ReportNames(),
+			"findings":    len(c.Annotations),
+			"annotations": general,
+		})
+	})
+}
+
+func fileHandler(c *Capsule) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("path")
+		content, ok := c.Files[name]
+		if !ok {
+			http.Error(w, "file not found: "+name, http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"path":        name,
+			"language":    language(name),
+			"content":     content,
+			"annotations": c.FileAnnotations(name),
+		})
+	})
+}
+
+func reportHandler(c *Capsule) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		content, ok := c.Reports[name]
+		if !ok {
+			http.Error(w, "report not found: "+name, http.StatusNotFound)
+			return
+		}
+		contentType := mime.TypeByExtension(path.Ext(name))
+		switch path.Ext(name) {
+		case ".html":
+		case ".json", ".sarif":
+			contentType = "application/json"
+		default:
+			// Markdown and patches read best as they are
+			contentType = "text/plain; charset=utf-8"
+		}
+		w.Header().Set("Content-Type", contentType)
+		// Reports are generated content: never let them run scripts
+		w.Header().Set("Content-Security-Policy", "sandbox")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Write(content)
+	})
+}
+
+// languages maps file extensions to the highlighter's grammars
+var languages = map[string]string{
+	".go": "go", ".js": "js", ".jsx": "js", ".ts": "js", ".tsx": "js", ".mjs": "js",
+	".py": "python", ".java": "java", ".cs": "java", ".kt": "java", ".rs": "rust",
+	".tf": "hcl", ".hcl": "hcl", ".yaml": "yaml", ".yml": "yaml", ".json": "json",
+	".sh": "shell", ".sql": "sql", ".md": "markdown", ".toml": "yaml",
+}
+
+// language returns the highlighter grammar of a file, "" for plain text
+func language(name string) string {
+	base := strings.ToLower(path.Base(name))
+	switch {
+	case base == "dockerfile" || strings.HasPrefix(base, "dockerfile.") || strings.HasSuffix(base, ".dockerfile"):
+		return "dockerfile"
+	case base == "makefile":
+		return "shell"
+	}
+	return languages[strings.ToLower(path.Ext(name))]
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,169 @@
+package promotion
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"QLP/internal/audit"
+)
+
+// Routes returns the promotion endpoints:
+//
+//	GET    /promotions/environments         lists the pipeline's environments in order
+//	POST   /promotions                      requests a promotion
+//	GET    /promotions?tenant=&capsule=     lists promotions, newest first
+//	GET    /promotions/{id}                 returns a promotion
+//	POST   /promotions/{id}/approve         approves a promotion waiting for approval
+//	POST   /promotions/{id}/reject          rejects a promotion waiting for approval
+//	GET    /promotions/{id}/history         returns the promotion's history, oldest first
+//	GET    /capsules/{id}/promotions        lists a capsule's promotions, newest first
+//
+// Requests authenticated by an API key act as the key, for their key's
+// tenant: tenant_id, requested_by and actor in bodies are only read without
+// one, and promotions of other tenants are not found.
+func Routes(s *Service) map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /promotions/environments":  environmentsHandler(s),
+		"POST /promotions":              requestHandler(s),
+		"GET /promotions":               listHandler(s),
+		"GET /promotions/{id}":          getHandler(s),
+		"POST /promotions/{id}/approve": decisionHandler(s, true),
+		"POST /promotions/{id}/reject":  decisionHandler(s, false),
+		"GET /promotions/{id}/history":  historyHandler(s),
+		"GET /capsules/{id}/promotions": capsuleHandler(s),
+	}
+}
+
+// decision is the body of approve and reject requests
+type decision struct {
+	Actor  string `json:"actor"`
+	Reason string `json:"reason"`
+}
+
+func environmentsHandler(s *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.Pipeline())
+	})
+}
+
+func requestHandler(s *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid promotion: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.TenantID = audit.BodyTenant(r, req.TenantID)
+		req.RequestedBy = audit.RequestActor(r, req.RequestedBy)
+		p, err := s.Request(r.Context(), req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, p)
+	})
+}
+
+func listHandler(s *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"promotions": s.List(audit.RequestTenant(r), q.Get("capsule")),
+		})
+	})
+}
+
+func getHandler(s *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := tenantPromotion(s, r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+	})
+}
+
+func decisionHandler(s *Service, approve bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var d decision
+		if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+			http.Error(w, "invalid decision: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := tenantPromotion(s, r); err != nil {
+			writeError(w, err)
+			return
+		}
+		decide := s.Reject
+		if approve {
+			decide = s.Approve
+		}
+		p, err := decide(r.Context(), r.PathValue("id"), audit.RequestActor(r, d.Actor), d.Reason)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+	})
+}
+
+func historyHandler(s *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := tenantPromotion(s, r); err != nil {
+			writeError(w, err)
+			return
+		}
+		events, err := s.History(r.PathValue("id"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"events": events,
+		})
+	})
+}
+
+func capsuleHandler(s *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"promotions": s.List(audit.RequestTenant(r), r.PathValue("id")),
+		})
+	})
+}
+
+// tenantPromotion returns the promotion the request names, not found when it
+// belongs to another tenant than the request's
+func tenantPromotion(s *Service, r *http.Request) (*Promotion, error) {
+	p, err := s.Get(r.PathValue("id"))
+	if err != nil {
+		return nil, err
+	}
+	if !audit.TenantAllowed(r, p.TenantID) {
+		return nil, ErrNotFound
+	}
+	return p, nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrInvalid):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrConflict):
+		status = http.StatusConflict
+	case errors.Is(err, ErrForbidden):
+		status = http.StatusForbidden
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
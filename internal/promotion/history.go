@@ -0,0 +1,100 @@
+package promotion
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is one entry of the promotion history: a change of a promotion's
+// status, who made it and why, with the promotion as it was afterwards.
+// Events are only ever appended.
+type Event struct {
+	Seq         int       `json:"seq"`
+	PromotionID string    `json:"promotion_id"`
+	Status      Status    `json:"status"`
+	Actor       string    `json:"actor,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	At          time.Time `json:"at"`
+	Promotion   Promotion `json:"promotion"`
+}
+
+// EventStore persists the promotion history
+type EventStore interface {
+	Append(event Event) error
+	Load() ([]Event, error)
+}
+
+// FileEventStore appends events to a JSON Lines file
+type FileEventStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileEventStore creates a file-backed event store
+func NewFileEventStore(path string) (*FileEventStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create promotion history directory: %w", err)
+	}
+	return &FileEventStore{path: path}, nil
+}
+
+// Append writes a single event to the end of the file
+func (fs *FileEventStore) Append(event Event) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal promotion event: %w", err)
+	}
+	f, err := os.OpenFile(fs.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open promotion history: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write promotion event: %w", err)
+	}
+	return nil
+}
+
+// Load reads all events previously written to the file
+func (fs *FileEventStore) Load() ([]Event, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	f, err := os.Open(fs.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open promotion history: %w", err)
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			return nil, fmt.Errorf("corrupt promotion history %s: %w", fs.path, err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read promotion history: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,45 @@
+package promotion
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"QLP/internal/tfstate"
+)
+
+var nonEnvName = regexp.MustCompile(`[^A-Z0-9_]+`)
+
+// Overlay returns the capsule's files with an environment's parameters
+// added: an auto-loaded tfvars file in every Terraform root module and a
+// .env.<environment> file for the applications. The environment parameter
+// is set to the environment's name unless params sets it.
+func Overlay(files map[string]string, environment string, params map[string]string) map[string]string {
+	values := map[string]string{"environment": environment}
+	for k, v := range params {
+		values[k] = v
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var tfvars, dotenv strings.Builder
+	for _, k := range keys {
+		tfvars.WriteString(k + " = " + strconv.Quote(values[k]) + "\n")
+		dotenv.WriteString(nonEnvName.ReplaceAllString(strings.ToUpper(k), "_") + "=" + values[k] + "\n")
+	}
+
+	out := make(map[string]string, len(files)+2)
+	for p, content := range files {
+		out[p] = content
+	}
+	for _, module := range tfstate.RootModules(files) {
+		out[path.Join(module, "qlp_"+environment+".auto.tfvars")] = tfvars.String()
+	}
+	out[".env."+environment] = dotenv.String()
+	return out
+}
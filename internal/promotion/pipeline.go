@@ -0,0 +1,115 @@
+package promotion
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"QLP/internal/config"
+)
+
+// Environment is a stage a capsule is promoted through, deployed to its own
+// subscription with its own parameters
+type Environment struct {
+	Name             string            `json:"name"`
+	SubscriptionID   string            `json:"subscription_id,omitempty"`
+	Location         string            `json:"location,omitempty"`
+	Parameters       map[string]string `json:"parameters,omitempty"` // Overlaid on the capsule when promoted here
+	RequiresApproval bool              `json:"requires_approval"`
+	Approvers        []string          `json:"approvers,omitempty"` // Who may approve, as api-key:<id> behind API keys; anyone but the requester when empty
+	Rollout          *Rollout          `json:"rollout,omitempty"`
+}
+
+// Rollout is how a promotion replaces the version running in an
+// environment; blue/green and canary roll back when the new version fails
+// its checks
+type Rollout struct {
+	Strategy    string `json:"strategy"`           // recreate, blue_green or canary
+	Platform    string `json:"platform,omitempty"` // app_service or kubernetes
+	App         string `json:"app,omitempty"`
+	CanarySteps []int  `json:"canary_steps,omitempty"`
+}
+
+// Pipeline is the ordered environments a capsule is promoted through. The
+// first is where capsules are validated; each later one is promoted to from
+// the one before it.
+type Pipeline struct {
+	Environments []Environment `json:"environments"`
+}
+
+// DefaultPipeline is dev, staging and prod in the subscription of
+// AZURE_SUBSCRIPTION_ID, with staging and prod gated on approval
+func DefaultPipeline() *Pipeline {
+	subscription := config.GetEnvOrDefault("AZURE_SUBSCRIPTION_ID", "")
+	location := config.GetEnvOrDefault("AZURE_LOCATION", "westeurope")
+	return &Pipeline{Environments: []Environment{
+		{Name: "dev", SubscriptionID: subscription, Location: location},
+		{Name: "staging", SubscriptionID: subscription, Location: location, RequiresApproval: true},
+		{Name: "prod", SubscriptionID: subscription, Location: location, RequiresApproval: true},
+	}}
+}
+
+// LoadPipeline reads a pipeline from a JSON file
+func LoadPipeline(path string) (*Pipeline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read promotion environments: %w", err)
+	}
+	var p Pipeline
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse promotion environments %s: %w", path, err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("promotion environments %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+// PipelineFromEnv loads the pipeline in QLP_PROMOTION_ENVIRONMENTS, or
+// returns the default one
+func PipelineFromEnv() (*Pipeline, error) {
+	if path := config.GetEnvOrDefault("QLP_PROMOTION_ENVIRONMENTS", ""); path != "" {
+		return LoadPipeline(path)
+	}
+	return DefaultPipeline(), nil
+}
+
+// Validate checks there is somewhere to promote to and every environment
+// has a unique name
+func (p *Pipeline) Validate() error {
+	if len(p.Environments) < 2 {
+		return fmt.Errorf("a pipeline needs at least two environments")
+	}
+	seen := make(map[string]bool)
+	for _, env := range p.Environments {
+		if env.Name == "" {
+			return fmt.Errorf("environment has no name")
+		}
+		if seen[env.Name] {
+			return fmt.Errorf("duplicate environment %q", env.Name)
+		}
+		seen[env.Name] = true
+	}
+	return nil
+}
+
+// Get returns an environment by name
+func (p *Pipeline) Get(name string) (*Environment, bool) {
+	for i := range p.Environments {
+		if p.Environments[i].Name == name {
+			return &p.Environments[i], true
+		}
+	}
+	return nil, false
+}
+
+// Previous returns the environment promoted from into name, or nil for the
+// first environment
+func (p *Pipeline) Previous(name string) *Environment {
+	for i := 1; i < len(p.Environments); i++ {
+		if p.Environments[i].Name == name {
+			return &p.Environments[i-1]
+		}
+	}
+	return nil
+}
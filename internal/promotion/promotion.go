@@ -0,0 +1,505 @@
+// Package promotion moves capsules through a pipeline of environments, e.g.
+// dev → staging → prod. A capsule validated in the first environment is
+// promoted one environment at a time, with that environment's parameters
+// overlaid on it; gated environments wait for a human approval recorded in
+// the HITL decision history. Every change is appended to an immutable
+// promotion history.
+package promotion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/hitl"
+	"QLP/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrNotFound is returned for unknown promotion IDs
+	ErrNotFound = errors.New("promotion not found")
+	// ErrInvalid is returned for promotions that cannot be requested
+	ErrInvalid = errors.New("invalid promotion")
+	// ErrConflict is returned for decisions on promotions not awaiting one,
+	// and for promotions to an environment the capsule is already being
+	// promoted to
+	ErrConflict = errors.New("promotion conflict")
+	// ErrForbidden is returned when the actor may not approve a promotion
+	ErrForbidden = errors.New("not an approver")
+)
+
+// Status is where a promotion is in its lifecycle
+type Status string
+
+const (
+	StatusPendingApproval Status = "pending_approval"
+	StatusApproved        Status = "approved"
+	StatusRejected        Status = "rejected"
+	StatusDeploying       Status = "deploying"
+	StatusPromoted        Status = "promoted"
+	StatusFailed          Status = "failed"
+)
+
+// open reports whether a promotion has not reached an outcome yet
+func (s Status) open() bool {
+	return s == StatusPendingApproval || s == StatusApproved || s == StatusDeploying
+}
+
+// Promotion is a request to promote a capsule into an environment
+type Promotion struct {
+	ID          string            `json:"id"`
+	TenantID    string            `json:"tenant_id,omitempty"`
+	CapsuleID   string            `json:"capsule_id"`
+	From        string            `json:"from"`
+	To          string            `json:"to"`
+	Parameters  map[string]string `json:"parameters,omitempty"` // The overlay applied, environment defaults included
+	Image       string            `json:"image,omitempty"`
+	Status      Status            `json:"status"`
+	RequestedBy string            `json:"requested_by"`
+	Reason      string            `json:"reason,omitempty"`
+	DecidedBy   string            `json:"decided_by,omitempty"`
+	Deployment  *Deployment       `json:"deployment,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// Request asks for a capsule to be promoted into an environment
+type Request struct {
+	TenantID    string            `json:"tenant_id,omitempty"`
+	CapsuleID   string            `json:"capsule_id"`
+	Environment string            `json:"environment"`
+	RequestedBy string            `json:"requested_by"`
+	Reason      string            `json:"reason,omitempty"`
+	Parameters  map[string]string `json:"parameters,omitempty"` // Override the environment's parameters
+	Image       string            `json:"image,omitempty"`      // Container image the environment's rollout deploys
+}
+
+// Target is what a Deployer deploys: a capsule's files with the
+// environment's overlay applied
+type Target struct {
+	PromotionID string
+	TenantID    string
+	CapsuleID   string
+	Environment Environment
+	Files       map[string]string
+	Image       string
+}
+
+// Deployment is where a promoted capsule was deployed
+type Deployment struct {
+	SubscriptionID string      `json:"subscription_id,omitempty"`
+	Location       string      `json:"location,omitempty"`
+	ResourceGroup  string      `json:"resource_group,omitempty"`
+	Status         string      `json:"status"`
+	RolledBack     bool        `json:"rolled_back,omitempty"` // The rollout failed and the previous version kept serving
+	Result         interface{} `json:"result,omitempty"`
+}
+
+// Deployer deploys a promoted capsule into its environment
+type Deployer interface {
+	Deploy(ctx context.Context, target Target) (*Deployment, error)
+}
+
+// DeployerFunc adapts a function to a Deployer
+type DeployerFunc func(ctx context.Context, target Target) (*Deployment, error)
+
+// Deploy calls f
+func (f DeployerFunc) Deploy(ctx context.Context, target Target) (*Deployment, error) {
+	return f(ctx, target)
+}
+
+// CapsuleLoader returns the files of a stored capsule
+type CapsuleLoader func(ctx context.Context, capsuleID string) (map[string]string, error)
+
+// ValidationCheck returns an error unless a capsule passed validation in
+// the first environment
+type ValidationCheck func(ctx context.Context, capsuleID string) error
+
+// Service requests, decides and deploys promotions
+type Service struct {
+	pipeline  *Pipeline
+	capsules  CapsuleLoader
+	deployer  Deployer
+	store     EventStore
+	decisions *hitl.DecisionHistory
+	validated ValidationCheck
+	timeout   time.Duration
+
+	mu         sync.Mutex
+	promotions map[string]*Promotion
+	events     []Event
+	wg         sync.WaitGroup
+}
+
+// New loads the promotion history from store; a nil store keeps it in
+// memory only. Promotions cut off by a restart while deploying are
+// recorded as failed.
+func New(pipeline *Pipeline, capsules CapsuleLoader, deployer Deployer, store EventStore) (*Service, error) {
+	if err := pipeline.Validate(); err != nil {
+		return nil, err
+	}
+	s := &Service{
+		pipeline:   pipeline,
+		capsules:   capsules,
+		deployer:   deployer,
+		store:      store,
+		decisions:  hitl.NewDecisionHistoryWithStore(nil),
+		timeout:    2 * time.Hour,
+		promotions: make(map[string]*Promotion),
+	}
+	if store == nil {
+		return s, nil
+	}
+	events, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events {
+		p := e.Promotion
+		s.promotions[e.PromotionID] = &p
+		s.events = append(s.events, e)
+	}
+	for _, p := range s.promotions {
+		if p.Status == StatusDeploying {
+			p.Error = "interrupted by restart"
+			if err := s.record(p, StatusFailed, "", p.Error); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// SetDecisionHistory records approval requests and decisions in dh, so
+// they appear in the HITL audit trail
+func (s *Service) SetDecisionHistory(dh *hitl.DecisionHistory) {
+	s.decisions = dh
+}
+
+// SetValidationCheck requires capsules to pass check before they leave the
+// first environment
+func (s *Service) SetValidationCheck(check ValidationCheck) {
+	s.validated = check
+}
+
+// SetTimeout bounds each deployment
+func (s *Service) SetTimeout(timeout time.Duration) {
+	s.timeout = timeout
+}
+
+// Pipeline returns the environments promotions move through
+func (s *Service) Pipeline() *Pipeline {
+	return s.pipeline
+}
+
+// Request starts promoting a capsule into the environment after the last
+// one it reached. Gated environments wait for Approve; others deploy
+// straight away.
+func (s *Service) Request(ctx context.Context, req Request) (*Promotion, error) {
+	if req.CapsuleID == "" {
+		return nil, fmt.Errorf("%w: capsule_id is required", ErrInvalid)
+	}
+	if req.RequestedBy == "" {
+		return nil, fmt.Errorf("%w: requested_by is required", ErrInvalid)
+	}
+	env, ok := s.pipeline.Get(req.Environment)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown environment %q", ErrInvalid, req.Environment)
+	}
+	from := s.pipeline.Previous(env.Name)
+	if from == nil {
+		return nil, fmt.Errorf("%w: %s is where capsules are validated, not promoted to", ErrInvalid, env.Name)
+	}
+	if from == &s.pipeline.Environments[0] {
+		if s.validated != nil {
+			if err := s.validated(ctx, req.CapsuleID); err != nil {
+				return nil, fmt.Errorf("%w: capsule %s has not passed validation in %s: %v", ErrInvalid, req.CapsuleID, from.Name, err)
+			}
+		}
+	} else if !s.reached(req.CapsuleID, from.Name) {
+		return nil, fmt.Errorf("%w: capsule %s has not been promoted to %s", ErrInvalid, req.CapsuleID, from.Name)
+	}
+
+	params := make(map[string]string)
+	for k, v := range env.Parameters {
+		params[k] = v
+	}
+	for k, v := range req.Parameters {
+		params[k] = v
+	}
+	now := time.Now()
+	p := &Promotion{
+		ID:          fmt.Sprintf("PROMO-%d", now.UnixNano()),
+		TenantID:    req.TenantID,
+		CapsuleID:   req.CapsuleID,
+		From:        from.Name,
+		To:          env.Name,
+		Parameters:  params,
+		Image:       req.Image,
+		RequestedBy: req.RequestedBy,
+		Reason:      req.Reason,
+		CreatedAt:   now,
+	}
+
+	s.mu.Lock()
+	for _, other := range s.promotions {
+		if other.CapsuleID == p.CapsuleID && other.To == p.To && other.Status.open() {
+			err := fmt.Errorf("%w: %s is already being promoted to %s by %s", ErrConflict, p.CapsuleID, p.To, other.ID)
+			s.mu.Unlock()
+			return nil, err
+		}
+	}
+	status := StatusApproved
+	if env.RequiresApproval {
+		status = StatusPendingApproval
+	}
+	err := s.record(p, status, req.RequestedBy, req.Reason)
+	out := *p
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if env.RequiresApproval {
+		s.decisions.RecordDecision(&hitl.HITLDecision{
+			ID:             p.ID,
+			DropID:         p.CapsuleID,
+			CapsuleID:      p.CapsuleID,
+			Action:         hitl.HITLActionReview,
+			Reason:         fmt.Sprintf("promotion of %s from %s to %s requested by %s", p.CapsuleID, p.From, p.To, p.RequestedBy),
+			Stakeholders:   env.Approvers,
+			DecisionMadeBy: "promotion",
+			DecisionMadeAt: now,
+			ReviewRequired: true,
+		})
+	} else {
+		s.start(p.ID)
+	}
+	return &out, nil
+}
+
+// Approve lets a promotion waiting for approval deploy. The requester
+// cannot approve their own promotion.
+func (s *Service) Approve(ctx context.Context, id, actor, reason string) (*Promotion, error) {
+	p, err := s.decide(id, actor, reason, StatusApproved, hitl.HITLActionApprove)
+	if err != nil {
+		return nil, err
+	}
+	s.start(id)
+	return p, nil
+}
+
+// Reject ends a promotion waiting for approval
+func (s *Service) Reject(ctx context.Context, id, actor, reason string) (*Promotion, error) {
+	return s.decide(id, actor, reason, StatusRejected, hitl.HITLActionReject)
+}
+
+func (s *Service) decide(id, actor, reason string, status Status, action hitl.HITLAction) (*Promotion, error) {
+	if actor == "" {
+		return nil, fmt.Errorf("%w: actor is required", ErrInvalid)
+	}
+	if reason == "" {
+		return nil, fmt.Errorf("%w: reason is required", ErrInvalid)
+	}
+
+	s.mu.Lock()
+	p, ok := s.promotions[id]
+	if !ok {
+		s.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	out := *p
+	var err error
+	switch env, _ := s.pipeline.Get(p.To); {
+	case p.Status != StatusPendingApproval:
+		err = fmt.Errorf("%w: promotion %s is %s", ErrConflict, id, p.Status)
+	case actor == p.RequestedBy:
+		err = fmt.Errorf("%w: %s requested promotion %s", ErrForbidden, actor, id)
+	case env != nil && len(env.Approvers) > 0 && !contains(env.Approvers, actor):
+		err = fmt.Errorf("%w: %s cannot approve promotions to %s", ErrForbidden, actor, p.To)
+	default:
+		p.DecidedBy = actor
+		err = s.record(p, status, actor, reason)
+		out = *p
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.decisions.RecordOverride(id, action, actor, reason); err != nil {
+		logger.WithComponent("promotion").Warn("Failed to record promotion decision",
+			zap.String("promotion_id", id), zap.Error(err))
+	}
+	return &out, nil
+}
+
+// start deploys an approved promotion in the background
+func (s *Service) start(id string) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.deploy(id)
+	}()
+}
+
+func (s *Service) deploy(id string) {
+	log := logger.WithComponent("promotion")
+
+	s.mu.Lock()
+	p := s.promotions[id]
+	env, _ := s.pipeline.Get(p.To)
+	err := s.record(p, StatusDeploying, "", "")
+	target := Target{PromotionID: p.ID, TenantID: p.TenantID, CapsuleID: p.CapsuleID, Environment: *env, Image: p.Image}
+	params := p.Parameters
+	s.mu.Unlock()
+	if err != nil {
+		log.Error("Failed to record promotion", zap.String("promotion_id", id), zap.Error(err))
+		return
+	}
+
+	ctx := audit.WithTenant(context.Background(), target.TenantID)
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	log.Info("Promoting capsule",
+		zap.String("promotion_id", id),
+		zap.String("capsule_id", target.CapsuleID),
+		zap.String("environment", env.Name))
+
+	var deployment *Deployment
+	files, err := s.capsules(ctx, target.CapsuleID)
+	if err == nil {
+		target.Files = Overlay(files, env.Name, params)
+		deployment, err = s.deployer.Deploy(ctx, target)
+	}
+
+	entry := audit.Entry{
+		Action:       audit.ActionCapsulePromote,
+		ResourceType: "capsule",
+		ResourceIDs:  []string{target.CapsuleID},
+		Details:      map[string]interface{}{"promotion_id": id, "environment": env.Name},
+	}
+	status := StatusPromoted
+	if err != nil {
+		status = StatusFailed
+		entry.Outcome = audit.OutcomeFailure
+		entry.Error = err.Error()
+		log.Warn("Promotion failed", zap.String("promotion_id", id), zap.Error(err))
+	}
+	audit.Record(ctx, entry)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p.Deployment = deployment
+	if err != nil {
+		p.Error = err.Error()
+	}
+	if err := s.record(p, status, "", p.Error); err != nil {
+		log.Error("Failed to record promotion", zap.String("promotion_id", id), zap.Error(err))
+	}
+}
+
+// record appends a status change to the history and applies it. Callers
+// hold s.mu.
+func (s *Service) record(p *Promotion, status Status, actor, reason string) error {
+	now := time.Now()
+	next := *p
+	next.Status = status
+	next.UpdatedAt = now
+	event := Event{
+		Seq:         len(s.events) + 1,
+		PromotionID: p.ID,
+		Status:      status,
+		Actor:       actor,
+		Reason:      reason,
+		At:          now,
+		Promotion:   next,
+	}
+	if s.store != nil {
+		if err := s.store.Append(event); err != nil {
+			return err
+		}
+	}
+	*p = next
+	s.promotions[p.ID] = p
+	s.events = append(s.events, event)
+	return nil
+}
+
+// reached reports whether a capsule was promoted into an environment
+func (s *Service) reached(capsuleID, environment string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.promotions {
+		if p.CapsuleID == capsuleID && p.To == environment && p.Status == StatusPromoted {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns a promotion
+func (s *Service) Get(id string) (*Promotion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.promotions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	out := *p
+	return &out, nil
+}
+
+// List returns the promotions of a tenant, or of all tenants when tenantID
+// is empty, optionally for one capsule, newest first
+func (s *Service) List(tenantID, capsuleID string) []*Promotion {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]*Promotion, 0)
+	for _, p := range s.promotions {
+		if (tenantID == "" || p.TenantID == tenantID) && (capsuleID == "" || p.CapsuleID == capsuleID) {
+			out := *p
+			list = append(list, &out)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list
+}
+
+// History returns the events of a promotion, oldest first
+func (s *Service) History(id string) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.promotions[id]; !ok {
+		return nil, ErrNotFound
+	}
+	events := make([]Event, 0)
+	for _, e := range s.events {
+		if e.PromotionID == id {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// Wait blocks until the deployments in progress finish
+func (s *Service) Wait() {
+	s.wg.Wait()
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,285 @@
+package promotion
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"QLP/internal/audit"
+	"QLP/internal/hitl"
+)
+
+type fakeDeployer struct {
+	mu      sync.Mutex
+	targets []Target
+	err     error
+}
+
+func (f *fakeDeployer) Deploy(ctx context.Context, target Target) (*Deployment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.targets = append(f.targets, target)
+	return &Deployment{SubscriptionID: target.Environment.SubscriptionID, Status: "completed"}, f.err
+}
+
+func testPipeline() *Pipeline {
+	return &Pipeline{Environments: []Environment{
+		{Name: "dev", SubscriptionID: "sub-dev"},
+		{Name: "staging", SubscriptionID: "sub-staging", Parameters: map[string]string{"replicas": "2"}},
+		{Name: "prod", SubscriptionID: "sub-prod", Parameters: map[string]string{"replicas": "3"}, RequiresApproval: true, Approvers: []string{"alice", "bob"}},
+	}}
+}
+
+func loadFiles(ctx context.Context, capsuleID string) (map[string]string, error) {
+	return map[string]string{
+		"infra/main.tf": `variable "replicas" {}`,
+		"app/main.go":   "package main",
+	}, nil
+}
+
+func newTestService(t *testing.T, store EventStore) (*Service, *fakeDeployer) {
+	t.Helper()
+	deployer := &fakeDeployer{}
+	s, err := New(testPipeline(), loadFiles, deployer, store)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return s, deployer
+}
+
+func TestOverlay(t *testing.T) {
+	files, _ := loadFiles(context.Background(), "capsule-1")
+	out := Overlay(files, "prod", map[string]string{"replicas": "3", "log-level": "warn"})
+
+	tfvars := out["infra/qlp_prod.auto.tfvars"]
+	for _, want := range []string{`environment = "prod"`, `replicas = "3"`, `log-level = "warn"`} {
+		if !strings.Contains(tfvars, want) {
+			t.Errorf("tfvars missing %q:\n%s", want, tfvars)
+		}
+	}
+	if env := out[".env.prod"]; !strings.Contains(env, "LOG_LEVEL=warn\n") || !strings.Contains(env, "ENVIRONMENT=prod\n") {
+		t.Errorf(".env.prod = %q", env)
+	}
+	if _, ok := files["infra/qlp_prod.auto.tfvars"]; ok {
+		t.Error("overlay modified the capsule's files")
+	}
+}
+
+func TestPromotionThroughPipeline(t *testing.T) {
+	ctx := context.Background()
+	s, deployer := newTestService(t, nil)
+	s.SetValidationCheck(func(ctx context.Context, capsuleID string) error {
+		if capsuleID != "capsule-1" {
+			return errors.New("validation failing")
+		}
+		return nil
+	})
+	decisions := hitl.NewDecisionHistoryWithStore(nil)
+	s.SetDecisionHistory(decisions)
+
+	if _, err := s.Request(ctx, Request{CapsuleID: "capsule-2", Environment: "staging", RequestedBy: "carol"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("unvalidated capsule: err = %v, want ErrInvalid", err)
+	}
+	if _, err := s.Request(ctx, Request{CapsuleID: "capsule-1", Environment: "prod", RequestedBy: "carol"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("skipping staging: err = %v, want ErrInvalid", err)
+	}
+
+	staging, err := s.Request(ctx, Request{CapsuleID: "capsule-1", Environment: "staging", RequestedBy: "carol"})
+	if err != nil {
+		t.Fatalf("request staging: %v", err)
+	}
+	if staging.Status != StatusApproved || staging.From != "dev" {
+		t.Errorf("staging promotion = %+v", staging)
+	}
+	s.Wait()
+	if got, _ := s.Get(staging.ID); got.Status != StatusPromoted || got.Deployment.SubscriptionID != "sub-staging" {
+		t.Fatalf("staging after deploy = %+v", got)
+	}
+
+	prod, err := s.Request(ctx, Request{CapsuleID: "capsule-1", Environment: "prod", RequestedBy: "carol", Parameters: map[string]string{"replicas": "5"}})
+	if err != nil {
+		t.Fatalf("request prod: %v", err)
+	}
+	if prod.Status != StatusPendingApproval || prod.Parameters["replicas"] != "5" {
+		t.Errorf("prod promotion = %+v", prod)
+	}
+	if _, err := s.Request(ctx, Request{CapsuleID: "capsule-1", Environment: "prod", RequestedBy: "carol"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("second prod request: err = %v, want ErrConflict", err)
+	}
+	if _, err := s.Approve(ctx, prod.ID, "carol", "lgtm"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("requester approving: err = %v, want ErrForbidden", err)
+	}
+	if _, err := s.Approve(ctx, prod.ID, "mallory", "lgtm"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("non-approver approving: err = %v, want ErrForbidden", err)
+	}
+	if _, err := s.Approve(ctx, prod.ID, "alice", "change window open"); err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	if _, err := s.Reject(ctx, prod.ID, "bob", "too late"); !errors.Is(err, ErrConflict) {
+		t.Errorf("rejecting an approved promotion: err = %v, want ErrConflict", err)
+	}
+	s.Wait()
+
+	if got, _ := s.Get(prod.ID); got.Status != StatusPromoted || got.DecidedBy != "alice" {
+		t.Errorf("prod after deploy = %+v", got)
+	}
+	last := deployer.targets[len(deployer.targets)-1]
+	if !strings.Contains(last.Files["infra/qlp_prod.auto.tfvars"], `replicas = "5"`) {
+		t.Errorf("prod overlay = %q", last.Files["infra/qlp_prod.auto.tfvars"])
+	}
+
+	records := decisions.Query(hitl.DecisionQuery{CapsuleID: "capsule-1"})
+	if len(records) != 2 || records[1].Action != hitl.HITLActionApprove || records[1].Actor != "alice" {
+		t.Errorf("HITL records = %+v", records)
+	}
+
+	events, _ := s.History(prod.ID)
+	var statuses []string
+	for _, e := range events {
+		statuses = append(statuses, string(e.Status))
+	}
+	if got := strings.Join(statuses, ","); got != "pending_approval,approved,deploying,promoted" {
+		t.Errorf("history = %s", got)
+	}
+}
+
+func TestFailedDeployment(t *testing.T) {
+	s, deployer := newTestService(t, nil)
+	deployer.err = errors.New("quota exceeded")
+
+	p, err := s.Request(context.Background(), Request{CapsuleID: "capsule-1", Environment: "staging", RequestedBy: "carol"})
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	s.Wait()
+	if got, _ := s.Get(p.ID); got.Status != StatusFailed || got.Error != "quota exceeded" {
+		t.Errorf("promotion = %+v", got)
+	}
+	// A failed promotion neither blocks a retry nor unlocks the next environment
+	if _, err := s.Request(context.Background(), Request{CapsuleID: "capsule-1", Environment: "prod", RequestedBy: "carol"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("prod after failed staging: err = %v, want ErrInvalid", err)
+	}
+	deployer.err = nil
+	if _, err := s.Request(context.Background(), Request{CapsuleID: "capsule-1", Environment: "staging", RequestedBy: "carol"}); err != nil {
+		t.Errorf("retry: %v", err)
+	}
+	s.Wait()
+}
+
+func TestHistoryPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "promotions.jsonl")
+	store, err := NewFileEventStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := newTestService(t, store)
+	p, err := s.Request(context.Background(), Request{TenantID: "acme", CapsuleID: "capsule-1", Environment: "staging", RequestedBy: "carol"})
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	s.Wait()
+
+	reloaded, _ := newTestService(t, store)
+	got, err := reloaded.Get(p.ID)
+	if err != nil || got.Status != StatusPromoted {
+		t.Fatalf("reloaded promotion = %+v, %v", got, err)
+	}
+	if events, _ := reloaded.History(p.ID); len(events) != 3 {
+		t.Errorf("reloaded history has %d events, want 3", len(events))
+	}
+	if list := reloaded.List("other", ""); len(list) != 0 {
+		t.Errorf("other tenant sees %d promotions", len(list))
+	}
+	// prod is reachable because staging's promotion was reloaded
+	if _, err := reloaded.Request(context.Background(), Request{CapsuleID: "capsule-1", Environment: "prod", RequestedBy: "carol"}); err != nil {
+		t.Errorf("prod after reload: %v", err)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	s, _ := newTestService(t, nil)
+	mux := http.NewServeMux()
+	for pattern, h := range Routes(s) {
+		mux.Handle(pattern, h)
+	}
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+		return rec
+	}
+
+	if rec := do("POST", "/promotions", `{"capsule_id":"capsule-1","environment":"dev","requested_by":"carol"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("promote to dev: %d", rec.Code)
+	}
+	if rec := do("POST", "/promotions", `{"capsule_id":"capsule-1","environment":"staging","requested_by":"carol"}`); rec.Code != http.StatusAccepted {
+		t.Fatalf("promote to staging: %d %s", rec.Code, rec.Body)
+	}
+	s.Wait()
+	if rec := do("GET", "/promotions/environments", ""); !strings.Contains(rec.Body.String(), `"staging"`) {
+		t.Errorf("environments: %s", rec.Body)
+	}
+	if rec := do("GET", "/capsules/capsule-1/promotions", ""); !strings.Contains(rec.Body.String(), `"promoted"`) {
+		t.Errorf("capsule promotions: %s", rec.Body)
+	}
+	if rec := do("POST", "/promotions/PROMO-0/approve", `{"actor":"alice","reason":"ok"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("approve unknown: %d", rec.Code)
+	}
+}
+
+func TestRoutesActAsTheAPIKey(t *testing.T) {
+	s, _ := newTestService(t, nil)
+	mux := http.NewServeMux()
+	for pattern, h := range Routes(s) {
+		mux.Handle(pattern, h)
+	}
+
+	// as authenticates the request like tenants.Middleware does
+	as := func(tenant, key, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req = req.WithContext(audit.WithActor(audit.WithTenant(req.Context(), tenant), "api-key:"+key))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := as("t1", "k1", "POST", "/promotions", `{"capsule_id":"capsule-1","environment":"staging","tenant_id":"t2","requested_by":"alice"}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("promote to staging: %d %s", rec.Code, rec.Body)
+	}
+	s.Wait()
+	staging := s.List("t1", "capsule-1")
+	if len(staging) != 1 || staging[0].RequestedBy != "api-key:k1" {
+		t.Fatalf("expected a promotion of t1 requested by the key, got %+v", staging)
+	}
+
+	rec = as("t1", "k1", "POST", "/promotions", `{"capsule_id":"capsule-1","environment":"prod"}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("promote to prod: %d %s", rec.Code, rec.Body)
+	}
+	prod := s.List("t1", "capsule-1")[0]
+	for _, path := range []string{"/promotions/" + prod.ID, "/promotions/" + prod.ID + "/history"} {
+		if rec := as("t2", "k2", "GET", path, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("%s from another tenant: %d", path, rec.Code)
+		}
+		if rec := as("t1", "k2", "GET", path, ""); rec.Code != http.StatusOK {
+			t.Errorf("%s: %d", path, rec.Code)
+		}
+	}
+	if rec := as("t2", "k2", "POST", "/promotions/"+prod.ID+"/approve", `{"actor":"alice","reason":"ok"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("approve from another tenant: %d", rec.Code)
+	}
+	// Naming an approver in the body does not make the key one
+	if rec := as("t1", "k2", "POST", "/promotions/"+prod.ID+"/approve", `{"actor":"alice","reason":"ok"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("approve as a named approver: %d", rec.Code)
+	}
+	if rec := as("t1", "k1", "POST", "/promotions/"+prod.ID+"/reject", `{"actor":"bob","reason":"no"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("reject own promotion: %d", rec.Code)
+	}
+}
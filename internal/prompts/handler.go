@@ -0,0 +1,104 @@
+package prompts
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Routes returns the prompt management API:
+//
+//	GET  /prompts                   list prompts with rollout and per-version stats
+//	GET  /prompts/{name}            show one prompt with all revisions
+//	POST /prompts/{name}/revisions  add a revision {"template", "author", "note"}
+//	PUT  /prompts/{name}/rollout    start or stop an A/B test {"candidate", "percent"}
+//	POST /prompts/{name}/promote    make a version stable {"version"}
+//	POST /prompts/{name}/rollback   restore the previously stable version
+//
+// Prompts are shared by every tenant, so API keys need the admin scope to
+// change them.
+func Routes(r *Registry) map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /prompts": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"prompts": r.List()})
+		}),
+		"GET /prompts/{name}": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			p, err := r.Get(req.PathValue("name"))
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, p)
+		}),
+		"POST /prompts/{name}/revisions": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var body struct {
+				Template string `json:"template"`
+				Author   string `json:"author"`
+				Note     string `json:"note"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.Template == "" {
+				http.Error(w, "template is required", http.StatusBadRequest)
+				return
+			}
+			rev, err := r.AddRevision(req.PathValue("name"), body.Template, body.Author, body.Note)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusCreated, rev)
+		}),
+		"PUT /prompts/{name}/rollout": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var body struct {
+				Candidate int `json:"candidate"`
+				Percent   int `json:"percent"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				http.Error(w, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			if err := r.SetRollout(req.PathValue("name"), body.Candidate, body.Percent); err != nil {
+				writeError(w, err)
+				return
+			}
+			p, _ := r.Get(req.PathValue("name"))
+			writeJSON(w, http.StatusOK, p)
+		}),
+		"POST /prompts/{name}/promote": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var body struct {
+				Version int `json:"version"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				http.Error(w, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			if err := r.Promote(req.PathValue("name"), body.Version); err != nil {
+				writeError(w, err)
+				return
+			}
+			p, _ := r.Get(req.PathValue("name"))
+			writeJSON(w, http.StatusOK, p)
+		}),
+		"POST /prompts/{name}/rollback": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if _, err := r.Rollback(req.PathValue("name")); err != nil {
+				writeError(w, err)
+				return
+			}
+			p, _ := r.Get(req.PathValue("name"))
+			writeJSON(w, http.StatusOK, p)
+		}),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
@@ -0,0 +1,369 @@
+package prompts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"QLP/internal/config"
+	"QLP/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// ErrNotFound is returned for unknown prompts or versions
+var ErrNotFound = errors.New("prompt not found")
+
+// Revision is an immutable version of a prompt template
+type Revision struct {
+	Version   int       `json:"version"`
+	Template  string    `json:"template"`
+	Checksum  string    `json:"checksum"`
+	Author    string    `json:"author"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Rollout controls which revision is served. A candidate receives
+// CandidatePercent of traffic for A/B comparison against the stable version.
+type Rollout struct {
+	Stable           int `json:"stable"`
+	Candidate        int `json:"candidate,omitempty"`
+	CandidatePercent int `json:"candidate_percent,omitempty"`
+}
+
+// VersionStats tracks how outputs generated with a revision scored in validation
+type VersionStats struct {
+	Uses         int     `json:"uses"`
+	Scored       int     `json:"scored"`
+	TotalScore   int     `json:"total_score"`
+	AverageScore float64 `json:"average_score"`
+	Passed       int     `json:"passed"`
+}
+
+// Prompt is a named, versioned prompt template
+type Prompt struct {
+	Name      string                `json:"name"`
+	Revisions []Revision            `json:"revisions"`
+	Rollout   Rollout               `json:"rollout"`
+	History   []int                 `json:"history"` // previously stable versions, most recent last
+	Stats     map[int]*VersionStats `json:"stats"`
+	UpdatedAt time.Time             `json:"updated_at"`
+}
+
+func (p *Prompt) revision(version int) (*Revision, bool) {
+	for i := range p.Revisions {
+		if p.Revisions[i].Version == version {
+			return &p.Revisions[i], true
+		}
+	}
+	return nil, false
+}
+
+func (p *Prompt) stats(version int) *VersionStats {
+	if p.Stats == nil {
+		p.Stats = make(map[int]*VersionStats)
+	}
+	s, ok := p.Stats[version]
+	if !ok {
+		s = &VersionStats{}
+		p.Stats[version] = s
+	}
+	return s
+}
+
+// Registry stores versioned prompts and persists them as JSON
+type Registry struct {
+	mu      sync.RWMutex
+	prompts map[string]*Prompt
+	path    string
+}
+
+// NewRegistry loads a registry from path. An empty path keeps prompts in memory only.
+func NewRegistry(path string) (*Registry, error) {
+	r := &Registry{
+		prompts: make(map[string]*Prompt),
+		path:    path,
+	}
+	if path == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, fmt.Errorf("failed to read prompt registry: %w", err)
+	}
+	if err := json.Unmarshal(data, &r.prompts); err != nil {
+		return nil, fmt.Errorf("failed to parse prompt registry: %w", err)
+	}
+	return r, nil
+}
+
+// Register seeds a prompt with its built-in template as version 1. It is a
+// no-op when the prompt already exists, so edits made through the API survive restarts.
+func (r *Registry) Register(name, template string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.prompts[name]; exists {
+		return nil
+	}
+	r.prompts[name] = &Prompt{
+		Name:      name,
+		Revisions: []Revision{newRevision(1, template, "system", "built-in default")},
+		Rollout:   Rollout{Stable: 1},
+		Stats:     make(map[int]*VersionStats),
+		UpdatedAt: time.Now(),
+	}
+	return r.save()
+}
+
+// AddRevision appends a new immutable revision. It is not served until it is
+// rolled out or promoted.
+func (r *Registry) AddRevision(name, template, author, note string) (*Revision, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.prompts[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	rev := newRevision(len(p.Revisions)+1, template, author, note)
+	p.Revisions = append(p.Revisions, rev)
+	p.UpdatedAt = time.Now()
+	return &rev, r.save()
+}
+
+// SetRollout sends percent of traffic to the candidate version. A percent of
+// zero ends the experiment.
+func (r *Registry) SetRollout(name string, candidate, percent int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.prompts[name]
+	if !ok {
+		return ErrNotFound
+	}
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("rollout percent must be between 0 and 100")
+	}
+	if percent == 0 {
+		p.Rollout.Candidate = 0
+		p.Rollout.CandidatePercent = 0
+	} else {
+		if _, ok := p.revision(candidate); !ok {
+			return fmt.Errorf("%w: version %d", ErrNotFound, candidate)
+		}
+		p.Rollout.Candidate = candidate
+		p.Rollout.CandidatePercent = percent
+	}
+	p.UpdatedAt = time.Now()
+	return r.save()
+}
+
+// Promote makes version the stable revision and ends any running experiment
+func (r *Registry) Promote(name string, version int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.prompts[name]
+	if !ok {
+		return ErrNotFound
+	}
+	if _, ok := p.revision(version); !ok {
+		return fmt.Errorf("%w: version %d", ErrNotFound, version)
+	}
+	if p.Rollout.Stable != version {
+		p.History = append(p.History, p.Rollout.Stable)
+	}
+	p.Rollout = Rollout{Stable: version}
+	p.UpdatedAt = time.Now()
+
+	logger.WithComponent("prompts").Info("Prompt version promoted",
+		zap.String("prompt", name),
+		zap.Int("version", version))
+	return r.save()
+}
+
+// Rollback restores the previously stable version and ends any running experiment
+func (r *Registry) Rollback(name string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.prompts[name]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	if len(p.History) == 0 {
+		return 0, fmt.Errorf("prompt %s has no previous version to roll back to", name)
+	}
+	previous := p.History[len(p.History)-1]
+	p.History = p.History[:len(p.History)-1]
+	p.Rollout = Rollout{Stable: previous}
+	p.UpdatedAt = time.Now()
+
+	logger.WithComponent("prompts").Info("Prompt version rolled back",
+		zap.String("prompt", name),
+		zap.Int("version", previous))
+	return previous, r.save()
+}
+
+// Select picks the revision to serve for key. The same key always lands in
+// the same arm of an experiment.
+func (r *Registry) Select(name, key string) (Revision, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.prompts[name]
+	if !ok {
+		return Revision{}, ErrNotFound
+	}
+
+	version := p.Rollout.Stable
+	if p.Rollout.CandidatePercent > 0 && bucket(name, key) < p.Rollout.CandidatePercent {
+		version = p.Rollout.Candidate
+	}
+	rev, ok := p.revision(version)
+	if !ok {
+		return Revision{}, fmt.Errorf("%w: version %d", ErrNotFound, version)
+	}
+	p.stats(version).Uses++
+	return *rev, r.save()
+}
+
+// RecordScore attributes the validation score of a generated output to the
+// prompt version that produced it
+func (r *Registry) RecordScore(name string, version, score int, passed bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.prompts[name]
+	if !ok {
+		return ErrNotFound
+	}
+	s := p.stats(version)
+	s.Scored++
+	s.TotalScore += score
+	s.AverageScore = float64(s.TotalScore) / float64(s.Scored)
+	if passed {
+		s.Passed++
+	}
+	return r.save()
+}
+
+// Get returns a copy of a prompt
+func (r *Registry) Get(name string) (*Prompt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.prompts[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return clonePrompt(p), nil
+}
+
+// List returns copies of all prompts sorted by name
+func (r *Registry) List() []*Prompt {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]*Prompt, 0, len(r.prompts))
+	for _, p := range r.prompts {
+		list = append(list, clonePrompt(p))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// save writes the registry to disk; callers hold the write lock
+func (r *Registry) save() error {
+	if r.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(r.prompts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal prompt registry: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return fmt.Errorf("failed to create prompt registry directory: %w", err)
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write prompt registry: %w", err)
+	}
+	return os.Rename(tmp, r.path)
+}
+
+func newRevision(version int, template, author, note string) Revision {
+	sum := sha256.Sum256([]byte(template))
+	return Revision{
+		Version:   version,
+		Template:  template,
+		Checksum:  hex.EncodeToString(sum[:]),
+		Author:    author,
+		Note:      note,
+		CreatedAt: time.Now(),
+	}
+}
+
+func clonePrompt(p *Prompt) *Prompt {
+	c := *p
+	c.Revisions = append([]Revision(nil), p.Revisions...)
+	c.History = append([]int(nil), p.History...)
+	c.Stats = make(map[int]*VersionStats, len(p.Stats))
+	for v, s := range p.Stats {
+		copied := *s
+		c.Stats[v] = &copied
+	}
+	return &c
+}
+
+func bucket(name, key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return int(h.Sum32() % 100)
+}
+
+var (
+	defaultRegistry   *Registry
+	defaultRegistryMu sync.RWMutex
+)
+
+// Default returns the process-wide registry, or nil when prompt versioning is disabled
+func Default() *Registry {
+	defaultRegistryMu.RLock()
+	defer defaultRegistryMu.RUnlock()
+	return defaultRegistry
+}
+
+// SetDefault replaces the process-wide registry
+func SetDefault(r *Registry) {
+	defaultRegistryMu.Lock()
+	defer defaultRegistryMu.Unlock()
+	defaultRegistry = r
+}
+
+// InitFromEnv loads the process-wide registry from QLP_PROMPT_STORE
+// (default ./data/prompts.json)
+func InitFromEnv() (*Registry, error) {
+	r, err := NewRegistry(config.GetEnvOrDefault("QLP_PROMPT_STORE", "./data/prompts.json"))
+	if err != nil {
+		return nil, err
+	}
+	SetDefault(r)
+	return r, nil
+}
@@ -0,0 +1,82 @@
+package prompts
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"QLP/internal/logger"
+)
+
+func TestMain(m *testing.M) {
+	config := logger.DefaultConfig()
+	config.Level = logger.ERROR
+	if err := logger.InitLogger(config); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestRolloutSplitsTrafficDeterministically(t *testing.T) {
+	r, _ := NewRegistry("")
+	r.Register("greeting", "v1")
+	r.AddRevision("greeting", "v2", "tester", "")
+	if err := r.SetRollout("greeting", 2, 30); err != nil {
+		t.Fatalf("SetRollout: %v", err)
+	}
+
+	candidate := 0
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("agent-%d", i)
+		first, _ := r.Select("greeting", key)
+		again, _ := r.Select("greeting", key)
+		if first.Version != again.Version {
+			t.Fatalf("key %s switched versions between selections", key)
+		}
+		if first.Version == 2 {
+			candidate++
+		}
+	}
+	if candidate < 200 || candidate > 400 {
+		t.Errorf("candidate served %d/1000, want about 300", candidate)
+	}
+}
+
+func TestPromoteRollbackAndPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prompts.json")
+	r, _ := NewRegistry(path)
+	r.Register("greeting", "v1")
+	r.AddRevision("greeting", "v2", "tester", "")
+	if err := r.Promote("greeting", 2); err != nil {
+		t.Fatalf("Promote: %v", err)
+	}
+	r.RecordScore("greeting", 2, 80, true)
+	r.RecordScore("greeting", 2, 60, false)
+	if _, err := r.Select("greeting", "agent-1"); err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+
+	reloaded, err := NewRegistry(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	p, _ := reloaded.Get("greeting")
+	if p.Rollout.Stable != 2 {
+		t.Errorf("stable = %d, want 2", p.Rollout.Stable)
+	}
+	if got := p.Stats[2].AverageScore; got != 70 {
+		t.Errorf("average score = %v, want 70", got)
+	}
+	if got := p.Stats[2].Uses; got != 1 {
+		t.Errorf("uses = %d, want 1", got)
+	}
+
+	version, err := reloaded.Rollback("greeting")
+	if err != nil || version != 1 {
+		t.Fatalf("Rollback = %d, %v; want 1", version, err)
+	}
+	if _, err := reloaded.Rollback("greeting"); err == nil {
+		t.Error("expected error rolling back past the first version")
+	}
+}
@@ -0,0 +1,147 @@
+package quota
+
+import (
+	"context"
+	"sync"
+)
+
+// FairShare limits how many executions run at once. When slots are taken,
+// the next free one goes to the waiting tenant with the fewest running
+// executions, and among equals to the one that has waited longest, so a
+// burst from one tenant does not starve the others.
+type FairShare struct {
+	mu       sync.Mutex
+	capacity int
+	total    int
+	running  map[string]int
+	waiting  map[string][]*waiter
+	seq      uint64
+}
+
+type waiter struct {
+	seq     uint64
+	ready   chan struct{}
+	granted bool
+}
+
+// NewFairShare creates a scheduler with capacity slots
+func NewFairShare(capacity int) *FairShare {
+	return &FairShare{
+		capacity: max(capacity, 1),
+		running:  make(map[string]int),
+		waiting:  make(map[string][]*waiter),
+	}
+}
+
+// Capacity returns the number of slots
+func (f *FairShare) Capacity() int {
+	return f.capacity
+}
+
+// Acquire takes a slot for the tenant, waiting for its turn when none is
+// free. It returns ctx's error if ctx is done first.
+func (f *FairShare) Acquire(ctx context.Context, tenantID string) error {
+	tenantID = tenantOrDefault(tenantID)
+	f.mu.Lock()
+	if f.total < f.capacity && len(f.waiting) == 0 {
+		f.grant(tenantID)
+		f.mu.Unlock()
+		return nil
+	}
+	f.seq++
+	w := &waiter{seq: f.seq, ready: make(chan struct{})}
+	f.waiting[tenantID] = append(f.waiting[tenantID], w)
+	f.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		f.mu.Lock()
+		if w.granted {
+			// The slot arrived as ctx ended; hand it on
+			f.mu.Unlock()
+			f.Release(tenantID)
+			return ctx.Err()
+		}
+		f.remove(tenantID, w)
+		f.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Release returns a slot taken by Acquire
+func (f *FairShare) Release(tenantID string) {
+	tenantID = tenantOrDefault(tenantID)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.running[tenantID] > 0 {
+		f.running[tenantID]--
+		f.total--
+	}
+	if f.running[tenantID] == 0 {
+		delete(f.running, tenantID)
+	}
+	f.dispatch()
+}
+
+// Running returns the slots each tenant holds
+func (f *FairShare) Running() map[string]int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	running := make(map[string]int, len(f.running))
+	for tenantID, n := range f.running {
+		running[tenantID] = n
+	}
+	return running
+}
+
+// Waiting returns the number of executions each tenant has waiting
+func (f *FairShare) Waiting() map[string]int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	waiting := make(map[string]int, len(f.waiting))
+	for tenantID, queue := range f.waiting {
+		waiting[tenantID] = len(queue)
+	}
+	return waiting
+}
+
+// dispatch hands free slots to waiters; the caller holds f.mu
+func (f *FairShare) dispatch() {
+	for f.total < f.capacity && len(f.waiting) > 0 {
+		next := ""
+		for tenantID, queue := range f.waiting {
+			if next == "" ||
+				f.running[tenantID] < f.running[next] ||
+				(f.running[tenantID] == f.running[next] && queue[0].seq < f.waiting[next][0].seq) {
+				next = tenantID
+			}
+		}
+		w := f.waiting[next][0]
+		f.remove(next, w)
+		w.granted = true
+		f.grant(next)
+		close(w.ready)
+	}
+}
+
+func (f *FairShare) grant(tenantID string) {
+	f.running[tenantID]++
+	f.total++
+}
+
+func (f *FairShare) remove(tenantID string, w *waiter) {
+	queue := f.waiting[tenantID]
+	for i, queued := range queue {
+		if queued == w {
+			queue = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(queue) == 0 {
+		delete(f.waiting, tenantID)
+	} else {
+		f.waiting[tenantID] = queue
+	}
+}
@@ -0,0 +1,78 @@
+package quota
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFairShareAlternatesTenants(t *testing.T) {
+	f := NewFairShare(2)
+	ctx := context.Background()
+
+	// acme bursts and takes both slots
+	f.Acquire(ctx, "acme")
+	f.Acquire(ctx, "acme")
+
+	var wg sync.WaitGroup
+	start := func(tenantID string) {
+		queued := f.Waiting()[tenantID]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := f.Acquire(ctx, tenantID); err != nil {
+				t.Error(err)
+			}
+		}()
+		// Let the waiter queue before the next one
+		waitFor(t, func() bool { return f.Waiting()[tenantID] > queued })
+	}
+	start("acme")
+	start("acme")
+	start("globex")
+
+	// globex queued last but runs nothing, so it gets the first free slot
+	f.Release("acme")
+	waitFor(t, func() bool { return f.Running()["globex"] == 1 })
+	if f.Waiting()["acme"] != 2 {
+		t.Errorf("waiting = %v, want both acme tasks still queued", f.Waiting())
+	}
+	f.Release("acme")
+	f.Release("globex")
+	wg.Wait()
+
+	if running := f.Running(); running["acme"] != 2 || running["globex"] != 0 {
+		t.Errorf("running = %v", running)
+	}
+}
+
+func TestFairShareAcquireCancelled(t *testing.T) {
+	f := NewFairShare(1)
+	ctx := context.Background()
+	f.Acquire(ctx, "acme")
+
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := f.Acquire(short, "globex"); err == nil {
+		t.Fatal("Acquire succeeded with no free slot")
+	}
+	if len(f.Waiting()) != 0 {
+		t.Errorf("cancelled waiter left queued: %v", f.Waiting())
+	}
+	f.Release("acme")
+	if err := f.Acquire(ctx, "globex"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,47 @@
+package quota
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Routes returns the execution usage endpoints:
+//
+//	GET /usage                   today's execution minutes of every tenant against its limit
+//	GET /tenants/{tenant}/usage  today's execution minutes of one tenant
+func Routes(tracker *Tracker) map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /usage":                  usageListHandler(tracker),
+		"GET /tenants/{tenant}/usage": usageHandler(tracker),
+	}
+}
+
+func usageListHandler(tracker *Tracker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		usage, err := tracker.UsageAll(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"mode":    tracker.Mode(),
+			"tenants": usage,
+		})
+	})
+}
+
+func usageHandler(tracker *Tracker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		usage, err := tracker.Usage(r.Context(), r.PathValue("tenant"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, usage)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
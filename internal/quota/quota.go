@@ -0,0 +1,273 @@
+// Package quota meters the execution minutes each tenant uses per UTC day
+// and holds tenants to a daily limit, rejecting or queueing work beyond it.
+// FairShare hands out execution slots so a tenant submitting a burst of
+// work cannot hold every slot while other tenants wait.
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"QLP/internal/config"
+	"QLP/internal/logger"
+	"go.uber.org/zap"
+)
+
+// ErrExceeded is returned for work of a tenant that has used up its daily
+// execution minutes
+var ErrExceeded = errors.New("execution quota exceeded")
+
+// What happens to work beyond a tenant's quota
+const (
+	ModeReject = "reject" // Fails at once; HTTP callers answer 429
+	ModeQueue  = "queue"  // Waits for the quota to reset at UTC midnight
+)
+
+// defaultTenant meters work that carries no tenant
+const defaultTenant = "default"
+
+// dayFormat keys usage by UTC day
+const dayFormat = "2006-01-02"
+
+// Limits are the daily execution minutes of each tenant; zero is unlimited
+type Limits struct {
+	DailyMinutes float64
+	PerTenant    map[string]float64
+}
+
+// ParseLimits builds limits from a default and overrides such as
+// "acme=600,globex=60"
+func ParseLimits(dailyMinutes float64, overrides string) (Limits, error) {
+	limits := Limits{DailyMinutes: dailyMinutes, PerTenant: make(map[string]float64)}
+	for _, pair := range strings.Split(overrides, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return limits, fmt.Errorf("invalid quota override %q, expected tenant=minutes", pair)
+		}
+		minutes, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil || minutes < 0 {
+			return limits, fmt.Errorf("invalid quota minutes for tenant %s", parts[0])
+		}
+		limits.PerTenant[strings.TrimSpace(parts[0])] = minutes
+	}
+	return limits, nil
+}
+
+// For returns a tenant's daily minutes
+func (l Limits) For(tenantID string) float64 {
+	if minutes, ok := l.PerTenant[tenantID]; ok {
+		return minutes
+	}
+	return l.DailyMinutes
+}
+
+// Usage is what a tenant used on one day
+type Usage struct {
+	TenantID         string    `json:"tenant_id"`
+	Day              string    `json:"day"`
+	Minutes          float64   `json:"minutes"`
+	LimitMinutes     float64   `json:"limit_minutes"` // 0 is unlimited
+	RemainingMinutes *float64  `json:"remaining_minutes,omitempty"`
+	Exceeded         bool      `json:"exceeded"`
+	ResetsAt         time.Time `json:"resets_at"`
+}
+
+// Tracker meters and limits tenants' execution minutes
+type Tracker struct {
+	store Store
+	mode  string
+	now   func() time.Time
+	poll  time.Duration // How often queued work rechecks its quota
+
+	mu     sync.RWMutex
+	limits Limits
+}
+
+// NewTracker meters usage in store. mode is ModeReject or ModeQueue.
+func NewTracker(store Store, limits Limits, mode string) (*Tracker, error) {
+	if mode != ModeReject && mode != ModeQueue {
+		return nil, fmt.Errorf("invalid quota mode %q, expected %s or %s", mode, ModeReject, ModeQueue)
+	}
+	return &Tracker{store: store, limits: limits, mode: mode, now: time.Now, poll: time.Minute}, nil
+}
+
+// NewTrackerFromEnv configures a tracker from the environment:
+//
+//	QLP_TENANT_DAILY_MINUTES            execution minutes per tenant and UTC day, 0 for unlimited
+//	QLP_TENANT_DAILY_MINUTES_OVERRIDES  per-tenant limits, e.g. "acme=600,globex=60"
+//	QLP_QUOTA_MODE                      reject (default) or queue work beyond the limit
+//
+// Usage is kept in the database at DATABASE_URL, so every QLP process
+// counts against the same quota, or in memory when it is unavailable.
+func NewTrackerFromEnv() (*Tracker, error) {
+	minutes, err := strconv.ParseFloat(config.GetEnvOrDefault("QLP_TENANT_DAILY_MINUTES", "0"), 64)
+	if err != nil || minutes < 0 {
+		return nil, fmt.Errorf("invalid QLP_TENANT_DAILY_MINUTES")
+	}
+	limits, err := ParseLimits(minutes, config.GetEnvOrDefault("QLP_TENANT_DAILY_MINUTES_OVERRIDES", ""))
+	if err != nil {
+		return nil, err
+	}
+	store, err := NewStoreFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewTracker(store, limits, config.GetEnvOrDefault("QLP_QUOTA_MODE", ModeReject))
+}
+
+var (
+	sharedOnce    sync.Once
+	sharedTracker *Tracker
+	sharedErr     error
+)
+
+// SharedTracker returns the tracker every executor in the process meters
+// with, configured from the environment on first use
+func SharedTracker() (*Tracker, error) {
+	sharedOnce.Do(func() {
+		sharedTracker, sharedErr = NewTrackerFromEnv()
+	})
+	return sharedTracker, sharedErr
+}
+
+// SetLimit sets a tenant's daily execution minutes, zero being unlimited,
+// overriding the configured limits
+func (t *Tracker) SetLimit(tenantID string, minutes float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	perTenant := make(map[string]float64, len(t.limits.PerTenant)+1)
+	for id, m := range t.limits.PerTenant {
+		perTenant[id] = m
+	}
+	perTenant[tenantOrDefault(tenantID)] = minutes
+	t.limits.PerTenant = perTenant
+}
+
+// Mode returns what happens to work beyond the quota
+func (t *Tracker) Mode() string {
+	return t.mode
+}
+
+// NextReset returns when the daily quotas reset: the next UTC midnight
+func NextReset(now time.Time) time.Time {
+	y, m, d := now.UTC().Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+}
+
+// Usage returns what a tenant used today
+func (t *Tracker) Usage(ctx context.Context, tenantID string) (Usage, error) {
+	tenantID = tenantOrDefault(tenantID)
+	now := t.now()
+	day := now.UTC().Format(dayFormat)
+	seconds, err := t.store.Get(ctx, tenantID, day)
+	if err != nil {
+		return Usage{}, err
+	}
+	return t.usage(tenantID, day, seconds, now), nil
+}
+
+// UsageAll returns what every tenant that ran anything used today, and the
+// tenants with their own limit
+func (t *Tracker) UsageAll(ctx context.Context) ([]Usage, error) {
+	now := t.now()
+	day := now.UTC().Format(dayFormat)
+	used, err := t.store.List(ctx, day)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.RLock()
+	for tenantID := range t.limits.PerTenant {
+		if _, ok := used[tenantID]; !ok {
+			used[tenantID] = 0
+		}
+	}
+	t.mu.RUnlock()
+	usage := make([]Usage, 0, len(used))
+	for tenantID, seconds := range used {
+		usage = append(usage, t.usage(tenantID, day, seconds, now))
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].TenantID < usage[j].TenantID })
+	return usage, nil
+}
+
+func (t *Tracker) usage(tenantID, day string, seconds float64, now time.Time) Usage {
+	t.mu.RLock()
+	limit := t.limits.For(tenantID)
+	t.mu.RUnlock()
+	u := Usage{
+		TenantID:     tenantID,
+		Day:          day,
+		Minutes:      seconds / 60,
+		LimitMinutes: limit,
+		ResetsAt:     NextReset(now),
+	}
+	if u.LimitMinutes > 0 {
+		remaining := max(u.LimitMinutes-u.Minutes, 0)
+		u.RemainingMinutes = &remaining
+		u.Exceeded = remaining == 0
+	}
+	return u
+}
+
+// Check returns ErrExceeded when the tenant has no minutes left today
+func (t *Tracker) Check(ctx context.Context, tenantID string) error {
+	u, err := t.Usage(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if u.Exceeded {
+		return fmt.Errorf("%w: tenant %s used %.1f of %.0f minutes today, resets at %s",
+			ErrExceeded, u.TenantID, u.Minutes, u.LimitMinutes, u.ResetsAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Admit lets work of the tenant start. Beyond the quota it returns
+// ErrExceeded in reject mode and waits for the reset in queue mode. Work
+// once admitted runs to the end, and all of its minutes count.
+func (t *Tracker) Admit(ctx context.Context, tenantID string) error {
+	err := t.Check(ctx, tenantID)
+	if t.mode != ModeQueue || !errors.Is(err, ErrExceeded) {
+		return err
+	}
+	logger.WithComponent("quota").Info("Execution queued until the tenant's quota resets",
+		zap.String("tenant_id", tenantOrDefault(tenantID)),
+		zap.Error(err))
+
+	ticker := time.NewTicker(t.poll)
+	defer ticker.Stop()
+	for errors.Is(err, ErrExceeded) {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("%w (queued until %v)", ctx.Err(), err)
+		}
+		err = t.Check(ctx, tenantID)
+	}
+	return err
+}
+
+// Record adds execution time to the tenant's usage today
+func (t *Tracker) Record(ctx context.Context, tenantID string, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	return t.store.Add(ctx, tenantOrDefault(tenantID), t.now().UTC().Format(dayFormat), d.Seconds())
+}
+
+func tenantOrDefault(tenantID string) string {
+	if tenantID == "" {
+		return defaultTenant
+	}
+	return tenantID
+}
@@ -0,0 +1,121 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits(120, "acme=600, globex=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits.For("acme") != 600 || limits.For("globex") != 0 || limits.For("initech") != 120 {
+		t.Errorf("limits = %+v", limits)
+	}
+	for _, bad := range []string{"acme", "acme=-1", "acme=lots"} {
+		if _, err := ParseLimits(0, bad); err == nil {
+			t.Errorf("ParseLimits(%q) accepted", bad)
+		}
+	}
+}
+
+// clock is a settable time shared with waiting goroutines
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestTracker(t *testing.T, mode string, now *clock) *Tracker {
+	t.Helper()
+	limits, _ := ParseLimits(0, "acme=10")
+	tracker, err := NewTracker(NewMemoryStore(), limits, mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.now = now.Now
+	tracker.poll = 5 * time.Millisecond
+	return tracker
+}
+
+func TestTrackerRejectsBeyondQuota(t *testing.T) {
+	now := &clock{now: time.Date(2026, 3, 1, 22, 0, 0, 0, time.UTC)}
+	tracker := newTestTracker(t, ModeReject, now)
+	ctx := context.Background()
+
+	if err := tracker.Admit(ctx, "acme"); err != nil {
+		t.Fatal(err)
+	}
+	tracker.Record(ctx, "acme", 6*time.Minute)
+	tracker.Record(ctx, "globex", time.Hour)
+	u, _ := tracker.Usage(ctx, "acme")
+	if u.Minutes != 6 || *u.RemainingMinutes != 4 || u.Exceeded {
+		t.Errorf("usage = %+v", u)
+	}
+
+	tracker.Record(ctx, "acme", 5*time.Minute)
+	if err := tracker.Admit(ctx, "acme"); !errors.Is(err, ErrExceeded) {
+		t.Errorf("Admit over quota = %v, want ErrExceeded", err)
+	}
+	if err := tracker.Admit(ctx, "globex"); err != nil {
+		t.Errorf("unlimited tenant rejected: %v", err)
+	}
+
+	all, _ := tracker.UsageAll(ctx)
+	if len(all) != 2 || all[0].TenantID != "acme" || !all[0].Exceeded || all[1].RemainingMinutes != nil {
+		t.Errorf("UsageAll = %+v", all)
+	}
+
+	// The quota resets with the UTC day
+	now.Advance(3 * time.Hour)
+	if err := tracker.Admit(ctx, "acme"); err != nil {
+		t.Errorf("Admit after reset = %v", err)
+	}
+}
+
+func TestTrackerQueuesUntilReset(t *testing.T) {
+	now := &clock{now: time.Date(2026, 3, 1, 23, 59, 0, 0, time.UTC)}
+	tracker := newTestTracker(t, ModeQueue, now)
+	ctx := context.Background()
+	tracker.Record(ctx, "acme", 11*time.Minute)
+
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := tracker.Admit(short, "acme"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("queued Admit = %v, want to wait until ctx ends", err)
+	}
+
+	admitted := make(chan error, 1)
+	go func() { admitted <- tracker.Admit(ctx, "acme") }()
+	time.Sleep(10 * time.Millisecond)
+	now.Advance(2 * time.Minute)
+	select {
+	case err := <-admitted:
+		if err != nil {
+			t.Errorf("Admit after reset = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queued work was not admitted after the reset")
+	}
+}
+
+func TestNewTrackerRejectsUnknownMode(t *testing.T) {
+	if _, err := NewTracker(NewMemoryStore(), Limits{}, "drop"); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
@@ -0,0 +1,147 @@
+package quota
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"QLP/internal/database"
+	"QLP/internal/logger"
+)
+
+// Store keeps the execution seconds each tenant used per day
+type Store interface {
+	Add(ctx context.Context, tenantID, day string, seconds float64) error
+	Get(ctx context.Context, tenantID, day string) (float64, error)
+	// List returns the seconds of every tenant with usage on day
+	List(ctx context.Context, day string) (map[string]float64, error)
+}
+
+// MemoryStore keeps usage in memory, for tests and deployments without a
+// database. Days other than the latest are dropped.
+type MemoryStore struct {
+	mu    sync.Mutex
+	day   string
+	usage map[string]float64
+}
+
+// NewMemoryStore creates an empty in-memory usage store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{usage: make(map[string]float64)}
+}
+
+func (s *MemoryStore) Add(_ context.Context, tenantID, day string, seconds float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if day != s.day {
+		if day < s.day {
+			return nil
+		}
+		s.day = day
+		s.usage = make(map[string]float64)
+	}
+	s.usage[tenantID] += seconds
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, tenantID, day string) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if day != s.day {
+		return 0, nil
+	}
+	return s.usage[tenantID], nil
+}
+
+func (s *MemoryStore) List(_ context.Context, day string) (map[string]float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	usage := make(map[string]float64)
+	if day == s.day {
+		for tenantID, seconds := range s.usage {
+			usage[tenantID] = seconds
+		}
+	}
+	return usage, nil
+}
+
+// PostgresStore keeps usage in the execution_usage table, shared by every
+// QLP process
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates the execution_usage table if needed
+func NewPostgresStore(db *sql.DB) (*PostgresStore, error) {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS execution_usage (
+			tenant_id VARCHAR(100) NOT NULL,
+			day VARCHAR(10) NOT NULL,
+			seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (tenant_id, day)
+		)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create execution_usage table: %w", err)
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+func (s *PostgresStore) Add(ctx context.Context, tenantID, day string, seconds float64) error {
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO execution_usage (tenant_id, day, seconds) VALUES ($1, $2, $3)
+		 ON CONFLICT (tenant_id, day) DO UPDATE SET seconds = execution_usage.seconds + $3, updated_at = NOW()`,
+		tenantID, day, seconds); err != nil {
+		return fmt.Errorf("failed to record execution usage: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) Get(ctx context.Context, tenantID, day string) (float64, error) {
+	var seconds float64
+	err := s.db.QueryRowContext(ctx,
+		`SELECT seconds FROM execution_usage WHERE tenant_id = $1 AND day = $2`, tenantID, day).Scan(&seconds)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read execution usage: %w", err)
+	}
+	return seconds, nil
+}
+
+func (s *PostgresStore) List(ctx context.Context, day string) (map[string]float64, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT tenant_id, seconds FROM execution_usage WHERE day = $1`, day)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list execution usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := make(map[string]float64)
+	for rows.Next() {
+		var tenantID string
+		var seconds float64
+		if err := rows.Scan(&tenantID, &seconds); err != nil {
+			return nil, fmt.Errorf("failed to read execution usage: %w", err)
+		}
+		usage[tenantID] = seconds
+	}
+	return usage, rows.Err()
+}
+
+// NewStoreFromEnv keeps usage in the database at DATABASE_URL, falling back
+// to memory when it is unavailable
+func NewStoreFromEnv() (Store, error) {
+	db, err := database.New()
+	if err != nil {
+		return nil, err
+	}
+	if !db.IsConnected() {
+		logger.WithComponent("quota").Warn("Database unavailable, execution usage is kept in memory and not shared between processes")
+		return NewMemoryStore(), nil
+	}
+	return NewPostgresStore(db.GetConnection())
+}
@@ -0,0 +1,66 @@
+package remediate
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var httpURL = regexp.MustCompile(`http://([A-Za-z0-9.-]+)`)
+
+// httpsExts are the files URLs are rewritten in. Markup is left alone, its
+// http:// URLs are mostly namespaces.
+var httpsExts = map[string]bool{
+	".go": true, ".js": true, ".ts": true, ".py": true, ".java": true, ".cs": true, ".rb": true,
+	".yaml": true, ".yml": true, ".json": true, ".toml": true, ".env": true, ".properties": true, ".tf": true,
+}
+
+// identifierHosts serve http:// URLs that are identifiers rather than
+// endpoints, such as schemas and license links
+var identifierHosts = []string{"w3.org", "json-schema.org", "apache.org", "purl.org", "xmlsoap.org", "opensource.org"}
+
+// internalSuffixes are hosts that stay inside the cluster or machine
+var internalSuffixes = []string{".local", ".svc", ".internal", ".localhost", ".test"}
+
+// httpsURLs switches URLs of public hosts from HTTP to HTTPS
+func httpsURLs(name, content string, _ map[string]string) (string, []change) {
+	if !httpsExts[path.Ext(name)] {
+		return content, nil
+	}
+	var changes []change
+	seen := make(map[string]bool)
+	fixed := httpURL.ReplaceAllStringFunc(content, func(url string) string {
+		host := strings.ToLower(strings.TrimPrefix(url, "http://"))
+		if !publicHost(host) {
+			return url
+		}
+		if !seen[host] {
+			seen[host] = true
+			changes = append(changes, change{FixHTTPS, fmt.Sprintf("Use HTTPS for %s", host)})
+		}
+		return "https://" + url[len("http://"):]
+	})
+	return fixed, changes
+}
+
+// publicHost reports whether host is a named host outside the cluster that
+// is reached as an endpoint
+func publicHost(host string) bool {
+	host = strings.TrimSuffix(host, ".")
+	if !strings.Contains(host, ".") || net.ParseIP(host) != nil {
+		return false
+	}
+	for _, suffix := range internalSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return false
+		}
+	}
+	for _, h := range identifierHosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return false
+		}
+	}
+	return true
+}
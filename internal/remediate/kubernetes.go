@@ -0,0 +1,246 @@
+package remediate
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"QLP/internal/archdocs"
+)
+
+var (
+	kindLine          = regexp.MustCompile(`^kind:\s*(\w+)`)
+	imageLine         = regexp.MustCompile(`^(\s*)(- )?image:\s*\S`)
+	containerNameLine = regexp.MustCompile(`^\s*(?:- )?name:\s*["']?([\w.-]+)`)
+	containerPortLine = regexp.MustCompile(`containerPort:\s*(\d+)`)
+)
+
+// podKinds run containers; servingKinds are those kept running, which
+// probes apply to
+var (
+	podKinds     = map[string]bool{"Deployment": true, "StatefulSet": true, "DaemonSet": true, "ReplicaSet": true, "Pod": true, "Job": true, "CronJob": true}
+	servingKinds = map[string]bool{"Deployment": true, "StatefulSet": true, "DaemonSet": true, "ReplicaSet": true, "Pod": true}
+)
+
+// healthPaths are the endpoints probes use when the project serves one
+var healthPaths = []string{"/healthz", "/health", "/livez", "/readyz"}
+
+// container is a container of a pod spec in a manifest
+type container struct {
+	kind       string
+	name       string
+	image      int // Line of the image key
+	indent     int // Column of the container's keys
+	start, end int // Lines of the list item, end exclusive
+	init       bool
+}
+
+func (c container) text(lines []string) string {
+	return strings.Join(lines[c.start:c.end], "\n")
+}
+
+// containerResources sets requests and limits on containers without
+// resources
+func containerResources(name, content string, _ map[string]string) (string, []change) {
+	return editContainers(name, content, func(c container, lines []string) ([]string, *change) {
+		if strings.Contains(c.text(lines), "resources:") {
+			return nil, nil
+		}
+		return indentLines(c.indent,
+			"resources:",
+			"  requests:",
+			"    cpu: 100m",
+			"    memory: 128Mi",
+			"  limits:",
+			"    cpu: 500m",
+			"    memory: 512Mi",
+		), &change{FixResourceLimits, fmt.Sprintf("Set CPU and memory requests and limits on container %s", c.name)}
+	})
+}
+
+// containerProbes adds readiness and liveness probes to long-running
+// containers that listen on a port, on the project's health endpoint when
+// it has one and the port otherwise
+func containerProbes(name, content string, files map[string]string) (string, []change) {
+	var health string
+	return editContainers(name, content, func(c container, lines []string) ([]string, *change) {
+		text := c.text(lines)
+		if !servingKinds[c.kind] || c.init || strings.Contains(text, "livenessProbe:") || strings.Contains(text, "readinessProbe:") {
+			return nil, nil
+		}
+		port := containerPortLine.FindStringSubmatch(text)
+		if port == nil {
+			return nil, nil
+		}
+		if health == "" {
+			health = healthPath(files)
+		}
+		check := []string{"  tcpSocket:", "    port: " + port[1]}
+		if health != "none" {
+			check = []string{"  httpGet:", "    path: " + health, "    port: " + port[1]}
+		}
+		var probe []string
+		for _, p := range []struct {
+			name  string
+			delay int
+		}{{"readinessProbe", 5}, {"livenessProbe", 15}} {
+			probe = append(probe, p.name+":")
+			probe = append(probe, check...)
+			probe = append(probe, fmt.Sprintf("  initialDelaySeconds: %d", p.delay), "  periodSeconds: 10")
+		}
+		return indentLines(c.indent, probe...),
+			&change{FixHealthProbes, fmt.Sprintf("Add readiness and liveness probes on port %s to container %s", port[1], c.name)}
+	})
+}
+
+// healthPath returns the health endpoint the project serves, "none" when
+// it has none
+func healthPath(files map[string]string) string {
+	routes := archdocs.ExtractRoutes(files)
+	for _, p := range healthPaths {
+		for _, r := range routes {
+			if r.Path == p && (r.Method == "GET" || r.Method == "ANY" || r.Method == "") {
+				return p
+			}
+		}
+	}
+	return "none"
+}
+
+// editContainers appends the lines edit returns to each container in the
+// manifest's pod specs
+func editContainers(name, content string, edit func(c container, lines []string) ([]string, *change)) (string, []change) {
+	if ext := path.Ext(name); ext != ".yaml" && ext != ".yml" {
+		return content, nil
+	}
+	lines := strings.Split(content, "\n")
+	containers := findContainers(lines)
+	var changes []change
+	// Bottom up, so insertions keep the line numbers above them
+	for i := len(containers) - 1; i >= 0; i-- {
+		c := containers[i]
+		insert, done := edit(c, lines)
+		if done == nil {
+			continue
+		}
+		at := c.end
+		for at > c.image+1 && strings.TrimSpace(lines[at-1]) == "" {
+			at--
+		}
+		lines = append(lines[:at], append(insert, lines[at:]...)...)
+		changes = append([]change{*done}, changes...)
+	}
+	if len(changes) == 0 {
+		return content, nil
+	}
+	return strings.Join(lines, "\n"), changes
+}
+
+// findContainers locates the containers of every pod spec, document by
+// document
+func findContainers(lines []string) []container {
+	var containers []container
+	kind := ""
+	for i, line := range lines {
+		if strings.HasPrefix(line, "---") {
+			kind = ""
+			continue
+		}
+		if m := kindLine.FindStringSubmatch(line); m != nil {
+			kind = m[1]
+		}
+		m := imageLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		c := container{image: i, start: i, indent: len(m[1])}
+		if m[2] != "" {
+			c.indent += 2
+		} else {
+			for j := i - 1; j >= 0; j-- {
+				if strings.TrimSpace(lines[j]) == "" {
+					continue
+				}
+				ind := indentation(lines[j])
+				if ind == c.indent-2 && strings.HasPrefix(strings.TrimSpace(lines[j]), "- ") {
+					c.start = j
+				}
+				if ind < c.indent {
+					break
+				}
+			}
+		}
+		c.end = len(lines)
+		for j := i + 1; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) != "" && indentation(lines[j]) < c.indent {
+				c.end = j
+				break
+			}
+		}
+		for j := c.start; j < c.end; j++ {
+			trimmed, ind := strings.TrimSpace(lines[j]), indentation(lines[j])
+			if ind == c.indent && strings.HasPrefix(trimmed, "name:") || ind == c.indent-2 && strings.HasPrefix(trimmed, "- name:") {
+				if m := containerNameLine.FindStringSubmatch(lines[j]); m != nil {
+					c.name = m[1]
+				}
+				break
+			}
+		}
+		c.init = parentKey(lines, c) == "initContainers:"
+		c.kind = kind
+		containers = append(containers, c)
+	}
+	// Documents declare their kind anywhere at the top level
+	for i := range containers {
+		if containers[i].kind == "" {
+			containers[i].kind = documentKind(lines, containers[i].image)
+		}
+	}
+	filtered := containers[:0]
+	for _, c := range containers {
+		if podKinds[c.kind] {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered
+}
+
+// parentKey returns the key holding the list the container is in
+func parentKey(lines []string, c container) string {
+	for j := c.start - 1; j >= 0; j-- {
+		trimmed := strings.TrimSpace(lines[j])
+		if trimmed == "" {
+			continue
+		}
+		ind := indentation(lines[j])
+		if ind < c.indent-2 || (ind == c.indent-2 && !strings.HasPrefix(trimmed, "- ")) {
+			return trimmed
+		}
+	}
+	return ""
+}
+
+// documentKind finds the kind of the document holding line when it is
+// declared after the containers
+func documentKind(lines []string, line int) string {
+	for j := line + 1; j < len(lines) && !strings.HasPrefix(lines[j], "---"); j++ {
+		if m := kindLine.FindStringSubmatch(lines[j]); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+func indentLines(indent int, lines ...string) []string {
+	pad := strings.Repeat(" ", indent)
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = pad + l
+	}
+	return out
+}
@@ -0,0 +1,118 @@
+// Package remediate turns common validation findings into patches: resource
+// limits and health probes for Kubernetes containers, HTTPS for plain HTTP
+// endpoints and version constraints for unpinned Terraform providers. The
+// patches are unified diffs attached to the validation report, and can be
+// applied to a drop's files directly.
+package remediate
+
+import (
+	"sort"
+	"strings"
+
+	"QLP/internal/capsulediff"
+)
+
+// Fixes the engine applies
+const (
+	FixResourceLimits   = "resource_limits"
+	FixHealthProbes     = "health_probes"
+	FixHTTPS            = "https"
+	FixProviderVersions = "provider_versions"
+)
+
+// PatchFile is the name of the combined patch attached to reports
+const PatchFile = "remediation.patch"
+
+// Patch fixes the findings in one file. Diff applies with git apply or
+// patch -p1 from the project root.
+type Patch struct {
+	File    string   `json:"file"`
+	Fixes   []string `json:"fixes"`
+	Changes []string `json:"changes"` // What the patch does, one line per change
+	Diff    string   `json:"diff"`
+	Content string   `json:"-"` // The patched file
+}
+
+// change is what a fixer did to a file
+type change struct {
+	fix         string
+	description string
+}
+
+// fixer rewrites a file, returning the new content and what it changed
+type fixer func(name, content string, files map[string]string) (string, []change)
+
+var fixers = []fixer{containerResources, containerProbes, httpsURLs, pinProviders}
+
+// Suggest returns the patches fixing the findings in files, ordered by file
+func Suggest(files map[string]string) []Patch {
+	before := make(map[string]string)
+	after := make(map[string]string)
+	changes := make(map[string][]change)
+	for _, name := range sortedKeys(files) {
+		content := files[name]
+		for _, fix := range fixers {
+			var done []change
+			content, done = fix(name, content, files)
+			changes[name] = append(changes[name], done...)
+		}
+		if content != files[name] {
+			before[name] = files[name]
+			after[name] = content
+		}
+	}
+	for name, add := range providerFiles(files) {
+		after[name] = add.content
+		changes[name] = add.changes
+	}
+
+	var patches []Patch
+	for _, c := range capsulediff.DiffFiles(before, after, capsulediff.DefaultContextLines).Changes {
+		p := Patch{File: c.Path, Diff: c.Diff, Content: after[c.Path]}
+		for _, ch := range changes[c.Path] {
+			if !contains(p.Fixes, ch.fix) {
+				p.Fixes = append(p.Fixes, ch.fix)
+			}
+			p.Changes = append(p.Changes, ch.description)
+		}
+		patches = append(patches, p)
+	}
+	return patches
+}
+
+// Apply writes the patched files into files and returns their names
+func Apply(files map[string]string, patches []Patch) []string {
+	applied := make([]string, 0, len(patches))
+	for _, p := range patches {
+		files[p.File] = p.Content
+		applied = append(applied, p.File)
+	}
+	return applied
+}
+
+// Combined joins the patches into one patch for the whole project
+func Combined(patches []Patch) string {
+	var b strings.Builder
+	for _, p := range patches {
+		b.WriteString(p.Diff)
+	}
+	return b.String()
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
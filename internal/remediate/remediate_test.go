@@ -0,0 +1,177 @@
+package remediate
+
+import (
+	"strings"
+	"testing"
+)
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: migrate
+        image: api:1.0
+        command: ["migrate"]
+      containers:
+      - name: api
+        image: api:1.0
+        ports:
+        - containerPort: 8080
+        env:
+        - name: PAYMENTS_URL
+          value: http://payments.example.com/v1
+        - name: CACHE_URL
+          value: http://cache.shop.svc.cluster.local:6379
+      - image: sidecar:1.0
+        name: sidecar
+        resources:
+          limits:
+            cpu: 100m
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: seed
+spec:
+  template:
+    spec:
+      containers:
+        - name: seed
+          image: api:1.0
+          ports:
+            - containerPort: 9000
+`
+
+func TestKubernetesPatches(t *testing.T) {
+	files := map[string]string{
+		"k8s/deployment.yaml": deployment,
+		"main.go":             `http.HandleFunc("/healthz", health)`,
+	}
+	patches := Suggest(files)
+	if len(patches) != 1 {
+		t.Fatalf("expected one patch, got %+v", patches)
+	}
+	p := patches[0]
+	if strings.Join(p.Fixes, ",") != "resource_limits,health_probes,https" {
+		t.Errorf("unexpected fixes %v", p.Fixes)
+	}
+	want := []string{
+		"Set CPU and memory requests and limits on container migrate",
+		"Set CPU and memory requests and limits on container api",
+		"Set CPU and memory requests and limits on container seed",
+		"Add readiness and liveness probes on port 8080 to container api",
+		"Use HTTPS for payments.example.com",
+	}
+	if strings.Join(p.Changes, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected changes:\n%s", strings.Join(p.Changes, "\n"))
+	}
+
+	expected := `          value: http://cache.shop.svc.cluster.local:6379
+        resources:
+          requests:
+            cpu: 100m
+            memory: 128Mi
+          limits:
+            cpu: 500m
+            memory: 512Mi
+        readinessProbe:
+          httpGet:
+            path: /healthz
+            port: 8080
+          initialDelaySeconds: 5
+          periodSeconds: 10
+        livenessProbe:
+          httpGet:
+            path: /healthz
+            port: 8080
+          initialDelaySeconds: 15
+          periodSeconds: 10
+      - image: sidecar:1.0`
+	if !strings.Contains(p.Content, expected) {
+		t.Errorf("expected the api container patched in place:\n%s", p.Content)
+	}
+	if !strings.Contains(p.Content, "            - containerPort: 9000\n          resources:\n") {
+		t.Errorf("expected the job's container indented as its keys:\n%s", p.Content)
+	}
+	if !strings.Contains(p.Content, "http://cache.shop.svc.cluster.local") {
+		t.Error("expected in-cluster URLs left on HTTP")
+	}
+	if !strings.HasPrefix(p.Diff, "--- a/k8s/deployment.yaml\n+++ b/k8s/deployment.yaml\n") {
+		t.Errorf("expected a unified diff of the manifest:\n%s", p.Diff)
+	}
+
+	if again := Suggest(map[string]string{"k8s/deployment.yaml": p.Content, "main.go": files["main.go"]}); len(again) != 0 {
+		t.Errorf("expected a patched manifest to need nothing more, got %+v", again)
+	}
+}
+
+func TestProviderVersionPatches(t *testing.T) {
+	files := map[string]string{
+		"infra/main.tf": `terraform {
+  required_providers {
+    azurerm = {
+      source = "hashicorp/azurerm"
+    }
+    aws = { source = "hashicorp/aws" }
+    random = {
+      source  = "hashicorp/random"
+      version = "3.6.0"
+    }
+  }
+}
+
+resource "kubernetes_namespace" "app" {}
+`,
+		"modules/net/main.tf": `provider "google" {}
+resource "google_compute_network" "vpc" {}
+`,
+	}
+	patches := Suggest(files)
+	if len(patches) != 2 {
+		t.Fatalf("expected the module patched and a versions file added, got %+v", patches)
+	}
+
+	main := patches[0].Content
+	for _, want := range []string{
+		"    azurerm = {\n      source = \"hashicorp/azurerm\"\n      version = \"~> 4.0\"\n    }",
+		`aws = { source = "hashicorp/aws", version = "~> 5.0" }`,
+		`version = "3.6.0"`,
+		"    kubernetes = {\n      source  = \"hashicorp/kubernetes\"\n      version = \"~> 2.0\"\n    }\n  }\n}",
+	} {
+		if !strings.Contains(main, want) {
+			t.Errorf("expected %q in:\n%s", want, main)
+		}
+	}
+	if len(patches[0].Changes) != 3 {
+		t.Errorf("expected three changes, got %v", patches[0].Changes)
+	}
+
+	versions := patches[1]
+	if versions.File != "modules/net/versions.tf" || !strings.HasPrefix(versions.Diff, "--- /dev/null\n") ||
+		!strings.Contains(versions.Content, `version = "~> 6.0"`) {
+		t.Errorf("expected a new versions file pinning google, got %+v", versions)
+	}
+}
+
+func TestHTTPSLeavesIdentifiersAlone(t *testing.T) {
+	content := `{"$schema": "http://json-schema.org/draft-07/schema#", "api": "http://api.example.com", "local": "http://localhost:8080", "ip": "http://10.0.0.1"}`
+	fixed, changes := httpsURLs("config.json", content, nil)
+	if len(changes) != 1 || !strings.Contains(fixed, "https://api.example.com") || strings.Count(fixed, "https://") != 1 {
+		t.Errorf("expected only the API URL switched, got %s", fixed)
+	}
+}
+
+func TestApply(t *testing.T) {
+	files := map[string]string{"main.tf": `resource "aws_s3_bucket" "b" {}`}
+	applied := Apply(files, Suggest(files))
+	if len(applied) != 1 || applied[0] != VersionsFile || !strings.Contains(files[VersionsFile], "hashicorp/aws") {
+		t.Errorf("expected the versions file written, got %v: %v", applied, files)
+	}
+	if !strings.Contains(Combined(Suggest(map[string]string{"x.tf": `provider "aws" {}`})), "+++ b/versions.tf") {
+		t.Error("expected the combined patch to add the versions file")
+	}
+}
@@ -0,0 +1,199 @@
+package remediate
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// providerVersions are the version constraints unpinned providers get: the
+// current major version, taking its minor and patch releases
+var providerVersions = map[string]string{
+	"aws":        "~> 5.0",
+	"azurerm":    "~> 4.0",
+	"azuread":    "~> 3.0",
+	"google":     "~> 6.0",
+	"kubernetes": "~> 2.0",
+	"helm":       "~> 2.0",
+	"random":     "~> 3.0",
+	"null":       "~> 3.0",
+	"tls":        "~> 4.0",
+	"local":      "~> 2.0",
+}
+
+var (
+	requiredProviders = regexp.MustCompile(`required_providers\s*\{`)
+	providerEntry     = regexp.MustCompile(`(?m)^([ \t]*)([\w-]+)\s*=\s*\{`)
+	providerBlock     = regexp.MustCompile(`(?m)^\s*provider\s+"([\w-]+)"`)
+	resourceBlock     = regexp.MustCompile(`(?m)^\s*(?:resource|data)\s+"([a-z0-9]+)_`)
+)
+
+// VersionsFile is where modules without required_providers get theirs
+const VersionsFile = "versions.tf"
+
+type fileAddition struct {
+	content string
+	changes []change
+}
+
+// pinProviders adds version constraints to the entries of required_providers
+// blocks that have none, and declares the providers the module uses without
+// requiring them in its first required_providers block
+func pinProviders(name, content string, files map[string]string) (string, []change) {
+	if path.Ext(name) != ".tf" {
+		return content, nil
+	}
+	type edit struct {
+		at   int
+		text string
+	}
+	var edits []edit
+	var changes []change
+	for i, loc := range requiredProviders.FindAllStringIndex(content, -1) {
+		end := matchingBrace(content, loc[1]-1)
+		if end < 0 {
+			continue
+		}
+		block := content[loc[1]:end]
+		for _, m := range providerEntry.FindAllStringSubmatchIndex(block, -1) {
+			open := loc[1] + m[1] - 1
+			close := matchingBrace(content, open)
+			provider := block[m[4]:m[5]]
+			version, known := providerVersions[provider]
+			if close < 0 || !known || strings.Contains(content[open:close], "version") {
+				continue
+			}
+			body := content[open+1 : close]
+			if strings.Contains(body, "\n") {
+				indent := block[m[2]:m[3]] + "  "
+				edits = append(edits, edit{strings.LastIndex(content[:close], "\n"), fmt.Sprintf("\n%sversion = %q", indent, version)})
+			} else {
+				at := open + 1 + len(strings.TrimRight(body, " "))
+				sep := ","
+				if strings.TrimSpace(body) == "" {
+					sep = ""
+				}
+				edits = append(edits, edit{at, fmt.Sprintf("%s version = %q", sep, version)})
+			}
+			changes = append(changes, change{FixProviderVersions, fmt.Sprintf("Pin provider %s to %s", provider, version)})
+		}
+		if i == 0 && firstRequiredProviders(files, path.Dir(name)) == name {
+			missing := undeclaredProviders(files, path.Dir(name))
+			var b strings.Builder
+			for _, provider := range missing {
+				fmt.Fprintf(&b, "    %s = {\n      source  = \"hashicorp/%s\"\n      version = %q\n    }\n", provider, provider, providerVersions[provider])
+				changes = append(changes, change{FixProviderVersions, fmt.Sprintf("Require provider %s %s", provider, providerVersions[provider])})
+			}
+			if b.Len() > 0 {
+				edits = append(edits, edit{strings.LastIndex(content[:end], "\n") + 1, b.String()})
+			}
+		}
+	}
+	if len(edits) == 0 {
+		return content, nil
+	}
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].at > edits[j].at })
+	for _, e := range edits {
+		content = content[:e.at] + e.text + content[e.at:]
+	}
+	return content, changes
+}
+
+// providerFiles declares the providers of modules without any
+// required_providers block in a versions file
+func providerFiles(files map[string]string) map[string]fileAddition {
+	added := make(map[string]fileAddition)
+	dirs := make(map[string]bool)
+	for name := range files {
+		if path.Ext(name) == ".tf" {
+			dirs[path.Dir(name)] = true
+		}
+	}
+	for dir := range dirs {
+		if firstRequiredProviders(files, dir) != "" {
+			continue
+		}
+		missing := undeclaredProviders(files, dir)
+		if len(missing) == 0 {
+			continue
+		}
+		var b strings.Builder
+		var changes []change
+		b.WriteString("terraform {\n  required_providers {\n")
+		for _, provider := range missing {
+			fmt.Fprintf(&b, "    %s = {\n      source  = \"hashicorp/%s\"\n      version = %q\n    }\n", provider, provider, providerVersions[provider])
+			changes = append(changes, change{FixProviderVersions, fmt.Sprintf("Require provider %s %s", provider, providerVersions[provider])})
+		}
+		b.WriteString("  }\n}\n")
+		file := path.Join(dir, VersionsFile)
+		if _, exists := files[file]; exists {
+			file = path.Join(dir, "qlp_"+VersionsFile)
+		}
+		added[file] = fileAddition{content: b.String(), changes: changes}
+	}
+	return added
+}
+
+// firstRequiredProviders returns the first file of the module with a
+// required_providers block
+func firstRequiredProviders(files map[string]string, dir string) string {
+	for _, name := range sortedKeys(files) {
+		if path.Ext(name) == ".tf" && path.Dir(name) == dir && requiredProviders.MatchString(files[name]) {
+			return name
+		}
+	}
+	return ""
+}
+
+// undeclaredProviders returns the providers with a known version the
+// module's provider, resource and data blocks use but its
+// required_providers blocks leave out
+func undeclaredProviders(files map[string]string, dir string) []string {
+	used := make(map[string]bool)
+	declared := make(map[string]bool)
+	for name, content := range files {
+		if path.Ext(name) != ".tf" || path.Dir(name) != dir {
+			continue
+		}
+		for _, m := range providerBlock.FindAllStringSubmatch(content, -1) {
+			used[m[1]] = true
+		}
+		for _, m := range resourceBlock.FindAllStringSubmatch(content, -1) {
+			used[m[1]] = true
+		}
+		for _, loc := range requiredProviders.FindAllStringIndex(content, -1) {
+			if end := matchingBrace(content, loc[1]-1); end > 0 {
+				for _, m := range providerEntry.FindAllStringSubmatch(content[loc[1]:end], -1) {
+					declared[m[2]] = true
+				}
+			}
+		}
+	}
+	var missing []string
+	for provider := range used {
+		if _, known := providerVersions[provider]; known && !declared[provider] {
+			missing = append(missing, provider)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// matchingBrace returns the index of the brace closing the one at open
+func matchingBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
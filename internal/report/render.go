@@ -0,0 +1,375 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"QLP/internal/cloudcost"
+	"QLP/internal/junit"
+	"QLP/internal/remediate"
+	"QLP/internal/requirements"
+	"QLP/internal/sarif"
+	"QLP/internal/validation"
+)
+
+// Supported output formats
+const (
+	FormatHTML     = "html"
+	FormatMarkdown = "markdown"
+	FormatSARIF    = "sarif"
+	FormatJUnit    = "junit"
+)
+
+// Render renders the report as HTML, Markdown ("md" is accepted as an alias)
+// SARIF or JUnit XML, returning the content and its MIME type. The HTML is
+// self-contained and print-ready, so browsers can save it as PDF.
+func Render(r *Report, format string) ([]byte, string, error) {
+	switch strings.ToLower(format) {
+	case FormatHTML, "htm":
+		data, err := HTML(r)
+		return data, "text/html; charset=utf-8", err
+	case FormatMarkdown, "md":
+		return Markdown(r), "text/markdown; charset=utf-8", nil
+	case FormatSARIF:
+		data, err := SARIF(r).Marshal()
+		return data, sarif.MediaType, err
+	case FormatJUnit:
+		data, err := JUnit(r).Marshal()
+		return data, junit.MediaType, err
+	default:
+		return nil, "", fmt.Errorf("unsupported report format: %s", format)
+	}
+}
+
+// Markdown renders the report as GitHub-flavored Markdown
+func Markdown(r *Report) []byte {
+	r.sortIssues()
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", r.Title)
+	if r.CapsuleID != "" {
+		fmt.Fprintf(&b, "- **Capsule**: %s\n", r.CapsuleID)
+	}
+	if r.Intent != "" {
+		fmt.Fprintf(&b, "- **Intent**: %s\n", r.Intent)
+	}
+	fmt.Fprintf(&b, "- **Generated**: %s\n\n", r.GeneratedAt.Format("2006-01-02 15:04:05 MST"))
+
+	b.WriteString("## Scores\n\n| Area | Score | Grade | Detail |\n|---|---:|---|---|\n")
+	for _, card := range r.ScoreCards {
+		fmt.Fprintf(&b, "| %s | %d/100 | %s %s | %s |\n", card.Name, card.Score, gradeIcon(card.Grade()), card.Grade(), mdCell(card.Detail))
+	}
+
+	fmt.Fprintf(&b, "\n## Issues (%d)\n\n", len(r.Issues))
+	if len(r.Issues) == 0 {
+		b.WriteString("No issues found.\n")
+	} else {
+		b.WriteString("| Severity | Source | Resource | Issue | Remediation |\n|---|---|---|---|---|\n")
+		for _, issue := range r.Issues {
+			message := issue.Message
+			if issue.Category != "" {
+				message = issue.Category + ": " + message
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", issue.Severity, issue.Source,
+				mdCell(issue.Resource), mdCell(message), mdCell(issue.Remediation))
+		}
+	}
+
+	if len(r.Tests) > 0 {
+		passed := 0
+		for _, tc := range r.Tests {
+			if tc.Passed {
+				passed++
+			}
+		}
+		fmt.Fprintf(&b, "\n## Tests (%d/%d passed)\n\n| Result | Test | Target | Duration | Message |\n|---|---|---|---:|---|\n", passed, len(r.Tests))
+		for _, tc := range r.Tests {
+			result := "✅"
+			if !tc.Passed {
+				result = "❌"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %v | %s |\n", result, mdCell(tc.Name), mdCell(tc.Target), tc.Duration, mdCell(tc.Message))
+		}
+	}
+
+	if c := r.CostComparison; c != nil {
+		fmt.Fprintf(&b, "\n## Cloud Cost Comparison\n\nEstimated monthly cost at %s list prices (%s).\n\n| Component | Qty |", c.Region, c.Updated)
+		for _, p := range c.Providers {
+			fmt.Fprintf(&b, " %s |", cloudcost.ProviderName(p))
+		}
+		b.WriteString("\n|---|---:|" + strings.Repeat("---:|", len(c.Providers)) + "\n")
+		for _, row := range c.Rows {
+			fmt.Fprintf(&b, "| %s | %d |", row.Label, row.Quantity)
+			for _, cost := range row.Monthly {
+				fmt.Fprintf(&b, " $%.2f |", cost)
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("| **Total** | |")
+		for i, total := range c.Totals {
+			if c.Providers[i] == c.Cheapest {
+				fmt.Fprintf(&b, " **$%.2f** |", total)
+			} else {
+				fmt.Fprintf(&b, " $%.2f |", total)
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	if m := r.CodeMetrics; m != nil && len(m.Hotspots) > 0 {
+		b.WriteString("\n## Code Hotspots\n\n| Severity | Location | Metric | Value | Limit | Detail |\n|---|---|---|---:|---:|---|\n")
+		for _, h := range m.Hotspots {
+			fmt.Fprintf(&b, "| %s | %s:%d | %s | %g | %g | %s |\n", h.Severity, mdCell(h.File), h.Line, h.Metric, h.Value, h.Limit, mdCell(h.Message))
+		}
+	}
+
+	if len(r.Compliance) > 0 {
+		b.WriteString("\n## Compliance Matrix\n")
+		for _, p := range r.Compliance {
+			fmt.Fprintf(&b, "\n### %s %s: %d passed, %d failed (%d/100)\n\n| Control | Title | Status | Evidence |\n|---|---|---|---|\n",
+				p.Name, p.Version, p.Passed, p.Failed, p.Score)
+			for _, c := range p.Controls {
+				fmt.Fprintf(&b, "| %s | %s | %s %s | %s |\n", c.ID, mdCell(c.Title), controlIcon(c.Status), c.Status, mdCell(evidenceSummary(c.Evidence)))
+			}
+		}
+	}
+
+	if m := r.Requirements; m != nil {
+		fmt.Fprintf(&b, "\n## Requirements Coverage\n\n%d of %d requirements from %s covered, %d partially.\n\n| Requirement | Priority | Statement | Tasks | Artifacts | Status |\n|---|---|---|---|---|---|\n",
+			m.Covered, len(m.Rows), m.Document, m.Partial)
+		for _, row := range m.Rows {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s %s |\n", row.ID, row.Priority, mdCell(row.Text),
+				mdCell(strings.Join(row.Tasks, ", ")), mdCell(strings.Join(row.Artifacts, ", ")), coverageIcon(row.Status), row.Status)
+		}
+	}
+
+	if len(r.Patches) > 0 {
+		fmt.Fprintf(&b, "\n## Remediation Patches\n\nApply them all from the project root with `git apply %s`.\n", remediate.PatchFile)
+		for _, p := range r.Patches {
+			fmt.Fprintf(&b, "\n### %s\n\n", p.File)
+			for _, c := range p.Changes {
+				fmt.Fprintf(&b, "- %s\n", c)
+			}
+			fmt.Fprintf(&b, "\n```diff\n%s```\n", p.Diff)
+		}
+	}
+
+	if len(r.Decisions) > 0 {
+		b.WriteString("\n## Review Decisions\n\n| Drop | Decision | Changes | Feedback |\n|---|---|---:|---|\n")
+		for _, d := range r.Decisions {
+			fmt.Fprintf(&b, "| %s | %s | %d | %s |\n", d.DropID, d.Decision, d.Changes, mdCell(d.Feedback))
+		}
+	}
+
+	if len(r.Recommendations) > 0 {
+		b.WriteString("\n## Recommendations\n\n")
+		for _, rec := range r.Recommendations {
+			fmt.Fprintf(&b, "- %s\n", rec)
+		}
+	}
+	return []byte(b.String())
+}
+
+// HTML renders the report as a standalone HTML page
+func HTML(r *Report) ([]byte, error) {
+	r.sortIssues()
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, r); err != nil {
+		return nil, fmt.Errorf("failed to render report: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// SARIF converts the report's issues into a SARIF log. Issues without a file
+// are reported against the capsule README.
+func SARIF(r *Report) *sarif.Log {
+	b := sarif.NewBuilder("qlp", "1.0.0")
+	b.SetDefaultLocation("README.md")
+	for _, issue := range r.Issues {
+		ruleID := issue.Source
+		if issue.Category != "" {
+			ruleID += "/" + issue.Category
+		}
+		b.Add(sarif.Finding{
+			RuleID:      ruleID,
+			Severity:    issue.Severity,
+			Message:     issue.Message,
+			Location:    issue.Resource,
+			Remediation: issue.Remediation,
+			Tags:        sarif.SortedTags(issue.Source),
+		})
+	}
+	return b.Log()
+}
+
+// JUnit converts the report's test cases into a JUnit report
+func JUnit(r *Report) *junit.TestSuites {
+	cases := make([]junit.Case, 0, len(r.Tests))
+	for _, tc := range r.Tests {
+		cases = append(cases, junit.Case{
+			Name:     tc.Name,
+			Class:    tc.Target,
+			Duration: tc.Duration,
+			Failed:   !tc.Passed,
+			Message:  tc.Message,
+		})
+	}
+	return junit.New(r.Title, junit.NewSuite("deployment", r.GeneratedAt, cases))
+}
+
+func mdCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// evidenceSummary lists the outcome of each check behind a control
+func evidenceSummary(evidence []validation.CheckEvidence) string {
+	parts := make([]string, 0, len(evidence))
+	for _, e := range evidence {
+		if e.Line > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %s (line %d)", e.Check, e.Evidence, e.Line))
+		} else {
+			parts = append(parts, e.Check+": "+e.Evidence)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+func coverageIcon(status requirements.Status) string {
+	switch status {
+	case requirements.StatusCovered:
+		return "✅"
+	case requirements.StatusPartial:
+		return "⚠️"
+	default:
+		return "❌"
+	}
+}
+
+func controlIcon(status string) string {
+	switch status {
+	case validation.ControlPass:
+		return "✅"
+	case validation.ControlFail:
+		return "❌"
+	default:
+		return "➖"
+	}
+}
+
+func gradeIcon(grade string) string {
+	switch grade {
+	case "pass":
+		return "🟢"
+	case "warn":
+		return "🟡"
+	default:
+		return "🔴"
+	}
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"providerName": cloudcost.ProviderName,
+	"evidence":     evidenceSummary,
+	"join":         strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+  body { font-family: -apple-system, "Segoe UI", Roboto, sans-serif; margin: 2rem auto; max-width: 1100px; color: #1f2933; }
+  h1 { margin-bottom: 0.25rem; }
+  .meta { color: #52606d; margin-bottom: 2rem; }
+  .cards { display: grid; grid-template-columns: repeat(auto-fill, minmax(170px, 1fr)); gap: 1rem; }
+  .card { border-radius: 8px; padding: 1rem; border-top: 6px solid; background: #f5f7fa; }
+  .card.pass { border-color: #2f9e44; } .card.warn { border-color: #f59f00; } .card.fail { border-color: #e03131; }
+  .card .score { font-size: 2rem; font-weight: 600; }
+  .card .detail { color: #52606d; font-size: 0.85rem; }
+  table { width: 100%; border-collapse: collapse; margin-top: 0.5rem; font-size: 0.9rem; }
+  th, td { text-align: left; padding: 0.45rem 0.6rem; border-bottom: 1px solid #e4e7eb; vertical-align: top; }
+  th { background: #f5f7fa; }
+  .sev { font-weight: 600; text-transform: uppercase; font-size: 0.75rem; }
+  .sev.critical { color: #c92a2a; } .sev.high { color: #e8590c; } .sev.medium { color: #f08c00; } .sev.low, .sev.info { color: #1971c2; }
+  .ok { color: #2f9e44; } .bad { color: #e03131; }
+  pre.diff { background: #f5f7fa; padding: 0.75rem; overflow-x: auto; font-size: 0.8rem; }
+  @media print { body { margin: 0; max-width: none; } .card { break-inside: avoid; } }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="meta">
+  {{if .CapsuleID}}Capsule <strong>{{.CapsuleID}}</strong> · {{end}}Generated {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}
+  {{if .Intent}}<br>Intent: {{.Intent}}{{end}}
+</div>
+
+<h2>Scores</h2>
+<div class="cards">
+{{range .ScoreCards}}  <div class="card {{.Grade}}"><div>{{.Name}}</div><div class="score">{{.Score}}</div><div class="detail">{{.Detail}}</div></div>
+{{end}}</div>
+
+<h2>Issues ({{len .Issues}})</h2>
+{{if .Issues}}<table>
+  <tr><th>Severity</th><th>Source</th><th>Resource</th><th>Issue</th><th>Remediation</th></tr>
+{{range .Issues}}  <tr><td class="sev {{.Severity}}">{{.Severity}}</td><td>{{.Source}}</td><td>{{.Resource}}</td><td>{{if .Category}}<strong>{{.Category}}</strong>: {{end}}{{.Message}}</td><td>{{.Remediation}}</td></tr>
+{{end}}</table>{{else}}<p class="ok">No issues found.</p>{{end}}
+
+{{if .Tests}}<h2>Tests</h2>
+<table>
+  <tr><th>Result</th><th>Test</th><th>Target</th><th>Duration</th><th>Message</th></tr>
+{{range .Tests}}  <tr><td>{{if .Passed}}<span class="ok">passed</span>{{else}}<span class="bad">failed</span>{{end}}</td><td>{{.Name}}</td><td>{{.Target}}</td><td>{{.Duration}}</td><td>{{.Message}}</td></tr>
+{{end}}</table>{{end}}
+
+{{with $c := .CostComparison}}<h2>Cloud Cost Comparison</h2>
+<p>Estimated monthly cost at {{.Region}} list prices ({{.Updated}}).</p>
+<table>
+  <tr><th>Component</th><th>Qty</th>{{range .Providers}}<th>{{providerName .}}</th>{{end}}</tr>
+{{range $row := .Rows}}  <tr><td>{{.Label}}</td><td>{{.Quantity}}</td>{{range $i, $cost := .Monthly}}<td title="{{index $row.SKUs $i}}">${{printf "%.2f" $cost}}</td>{{end}}</tr>
+{{end}}  <tr><th>Total</th><th></th>{{range $i, $total := .Totals}}<th{{if eq (index $c.Providers $i) $c.Cheapest}} class="ok"{{end}}>${{printf "%.2f" $total}}</th>{{end}}</tr>
+</table>{{end}}
+
+{{with .CodeMetrics}}{{if .Hotspots}}<h2>Code Hotspots</h2>
+<table>
+  <tr><th>Severity</th><th>Location</th><th>Metric</th><th>Value</th><th>Limit</th><th>Detail</th></tr>
+{{range .Hotspots}}  <tr><td class="sev {{.Severity}}">{{.Severity}}</td><td>{{.File}}:{{.Line}}</td><td>{{.Metric}}</td><td>{{.Value}}</td><td>{{.Limit}}</td><td>{{.Message}}</td></tr>
+{{end}}</table>{{end}}{{end}}
+
+{{if .Compliance}}<h2>Compliance Matrix</h2>
+{{range .Compliance}}<h3>{{.Name}} {{.Version}}: {{.Passed}} passed, {{.Failed}} failed ({{.Score}}/100)</h3>
+<table>
+  <tr><th>Control</th><th>Title</th><th>Status</th><th>Evidence</th></tr>
+{{range .Controls}}  <tr><td>{{.ID}}</td><td>{{.Title}}</td><td>{{if eq .Status "pass"}}<span class="ok">pass</span>{{else if eq .Status "fail"}}<span class="bad">fail</span>{{else}}n/a{{end}}</td><td>{{evidence .Evidence}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}
+
+{{with .Requirements}}<h2>Requirements Coverage</h2>
+<p>{{.Covered}} of {{len .Rows}} requirements from {{.Document}} covered, {{.Partial}} partially.</p>
+<table>
+  <tr><th>Requirement</th><th>Priority</th><th>Statement</th><th>Tasks</th><th>Artifacts</th><th>Status</th></tr>
+{{range .Rows}}  <tr><td>{{.ID}}</td><td>{{.Priority}}</td><td>{{.Text}}</td><td>{{join .Tasks ", "}}</td><td>{{join .Artifacts ", "}}</td><td>{{if eq .Status "covered"}}<span class="ok">covered</span>{{else if eq .Status "partial"}}partial{{else}}<span class="bad">uncovered</span>{{end}}</td></tr>
+{{end}}</table>{{end}}
+
+{{if .Patches}}<h2>Remediation Patches</h2>
+<p>Apply them all from the project root with <code>git apply remediation.patch</code>.</p>
+{{range .Patches}}<h3>{{.File}}</h3>
+<ul>
+{{range .Changes}}  <li>{{.}}</li>
+{{end}}</ul>
+<pre class="diff">{{.Diff}}</pre>
+{{end}}{{end}}
+
+{{if .Decisions}}<h2>Review Decisions</h2>
+<table>
+  <tr><th>Drop</th><th>Decision</th><th>Changes</th><th>Feedback</th><th>Time</th></tr>
+{{range .Decisions}}  <tr><td>{{.DropID}}</td><td>{{.Decision}}</td><td>{{.Changes}}</td><td>{{.Feedback}}</td><td>{{.Timestamp.Format "15:04:05"}}</td></tr>
+{{end}}</table>{{end}}
+
+{{if .Recommendations}}<h2>Recommendations</h2>
+<ul>
+{{range .Recommendations}}  <li>{{.}}</li>
+{{end}}</ul>{{end}}
+</body>
+</html>
+`))
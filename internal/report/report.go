@@ -0,0 +1,401 @@
+// Package report turns capsule validation results, infrastructure and
+// deployment test results and HITL decisions into a human-readable report
+// rendered as HTML or Markdown.
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"QLP/internal/cloudcost"
+	"QLP/internal/codemetrics"
+	"QLP/internal/packaging"
+	"QLP/internal/remediate"
+	"QLP/internal/requirements"
+	"QLP/internal/validation"
+)
+
+// Report is the renderer-neutral content of a validation report
+type Report struct {
+	Title           string      `json:"title"`
+	CapsuleID       string      `json:"capsule_id,omitempty"`
+	IntentID        string      `json:"intent_id,omitempty"`
+	Intent          string      `json:"intent,omitempty"`
+	GeneratedAt     time.Time   `json:"generated_at"`
+	ScoreCards      []ScoreCard `json:"score_cards"`
+	Issues          []Issue     `json:"issues"`
+	Tests           []TestCase  `json:"tests,omitempty"`
+	Decisions       []Decision  `json:"decisions,omitempty"`
+	Recommendations []string    `json:"recommendations"`
+
+	CostComparison *cloudcost.Comparison       `json:"cost_comparison,omitempty"`
+	CodeMetrics    *codemetrics.Report         `json:"code_metrics,omitempty"`
+	Compliance     []validation.BaselineResult `json:"compliance,omitempty"`   // Compliance matrix, one column per baseline profile
+	Patches        []remediate.Patch           `json:"patches,omitempty"`      // Remediation patches for the findings the engine fixes
+	Requirements   *requirements.Matrix        `json:"requirements,omitempty"` // Coverage of the attached requirements document
+}
+
+// ScoreCard is a headline score out of 100
+type ScoreCard struct {
+	Name   string `json:"name"`
+	Score  int    `json:"score"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Grade buckets the score: pass from 80, warn from 60, fail below
+func (s ScoreCard) Grade() string {
+	switch {
+	case s.Score >= 80:
+		return "pass"
+	case s.Score >= 60:
+		return "warn"
+	default:
+		return "fail"
+	}
+}
+
+// Issue is one finding from any validator
+type Issue struct {
+	Severity    string `json:"severity"`
+	Source      string `json:"source"`
+	Category    string `json:"category,omitempty"`
+	Resource    string `json:"resource,omitempty"`
+	Message     string `json:"message"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// TestCase is one deployment or functional test
+type TestCase struct {
+	Name     string        `json:"name"`
+	Target   string        `json:"target,omitempty"`
+	Passed   bool          `json:"passed"`
+	Duration time.Duration `json:"duration"`
+	Message  string        `json:"message,omitempty"`
+}
+
+// Decision is a HITL review outcome for a drop
+type Decision struct {
+	DropID    string    `json:"drop_id"`
+	Decision  string    `json:"decision"`
+	Feedback  string    `json:"feedback,omitempty"`
+	Changes   int       `json:"changes,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+var severityRank = map[string]int{"critical": 0, "high": 1, "medium": 2, "low": 3, "info": 4}
+
+// New starts an empty report
+func New(title string) *Report {
+	return &Report{
+		Title:           title,
+		GeneratedAt:     time.Now(),
+		ScoreCards:      []ScoreCard{},
+		Issues:          []Issue{},
+		Recommendations: []string{},
+	}
+}
+
+// FromCapsule builds a report from a packaged capsule's metadata, security
+// and quality reports
+func FromCapsule(capsule *packaging.QLCapsule) *Report {
+	r := New("Validation Report: " + capsule.Metadata.IntentText)
+	r.CapsuleID = capsule.Metadata.CapsuleID
+	r.IntentID = capsule.Metadata.IntentID
+	r.Intent = capsule.Metadata.IntentText
+
+	security := capsule.SecurityReport
+	quality := capsule.QualityReport
+	r.ScoreCards = append(r.ScoreCards,
+		ScoreCard{Name: "Overall", Score: capsule.Metadata.OverallScore,
+			Detail: fmt.Sprintf("%d/%d tasks succeeded", capsule.Metadata.SuccessfulTasks, capsule.Metadata.TotalTasks)},
+		ScoreCard{Name: "Security", Score: security.SecurityScore,
+			Detail: fmt.Sprintf("risk %s, %d vulnerabilities", security.OverallRiskLevel, security.VulnerabilitiesFound)},
+		ScoreCard{Name: "Quality", Score: quality.OverallQualityScore},
+		ScoreCard{Name: "Compliance", Score: security.ComplianceScore},
+		ScoreCard{Name: "Documentation", Score: quality.DocumentationScore},
+		ScoreCard{Name: "Best Practices", Score: quality.BestPracticesScore},
+	)
+
+	for _, issue := range security.CriticalIssues {
+		r.AddIssue(Issue{Severity: issue.Severity, Source: "security", Category: issue.Type,
+			Resource: issue.Location, Message: issue.Description})
+	}
+	for _, violation := range security.SandboxViolations {
+		r.AddIssue(Issue{Severity: "high", Source: "sandbox", Message: violation})
+	}
+	for _, e := range capsule.ExecutionSummary.ErrorSummary {
+		r.AddIssue(Issue{Severity: e.Severity, Source: "execution", Category: e.ErrorType,
+			Resource: e.TaskID, Message: e.Message})
+	}
+
+	r.AddRecommendations(security.RecommendedActions...)
+	for _, rec := range quality.Recommendations {
+		r.AddRecommendations(fmt.Sprintf("[%s] %s", rec.Priority, rec.Description))
+	}
+	return r
+}
+
+// AddInfra adds infrastructure validation scores and findings
+func (r *Report) AddInfra(result *validation.InfraValidationResult) {
+	if result == nil {
+		return
+	}
+	r.ScoreCards = append(r.ScoreCards, ScoreCard{Name: "Infrastructure", Score: result.OverallScore,
+		Detail: fmt.Sprintf("deployment risk %s", result.DeploymentRisk)})
+
+	for _, issue := range result.CriticalIssues {
+		r.AddIssue(Issue{Severity: issue.Severity, Source: "infrastructure", Category: issue.Category,
+			Resource: issue.Resource, Message: issue.Message, Remediation: issue.Remediation})
+	}
+	if tf := result.TerraformResult; tf != nil {
+		r.ScoreCards = append(r.ScoreCards, ScoreCard{Name: "Terraform Security", Score: tf.SecurityScore,
+			Detail: fmt.Sprintf("%d resources", tf.ResourceCount)})
+		for _, issue := range tf.SecurityIssues {
+			r.AddIssue(Issue{Severity: issue.Severity, Source: "terraform", Category: issue.ID,
+				Resource: issue.Resource, Message: issue.Title + ": " + issue.Description, Remediation: issue.Remediation})
+		}
+		for _, v := range tf.PolicyViolations {
+			r.AddIssue(Issue{Severity: v.Severity, Source: "terraform", Category: v.Policy,
+				Resource: v.Resource, Message: v.Violation, Remediation: v.Action})
+		}
+	}
+	if k8s := result.KubernetesResult; k8s != nil {
+		r.ScoreCards = append(r.ScoreCards, ScoreCard{Name: "Kubernetes Readiness", Score: k8s.ProductionReadiness})
+		for _, issue := range k8s.Issues {
+			r.AddIssue(Issue{Severity: issue.Severity, Source: "kubernetes", Category: issue.Type,
+				Resource: issue.Resource, Message: issue.Message, Remediation: issue.Suggestion})
+		}
+		r.AddRecommendations(k8s.Recommendations...)
+	}
+	if sec := result.SecurityResult; sec != nil {
+		for _, f := range sec.CriticalFindings {
+			r.AddIssue(Issue{Severity: f.Severity, Source: "infrastructure-security", Category: f.Rule,
+				Resource: f.Resource, Message: f.Finding, Remediation: f.Remediation})
+		}
+		r.AddRecommendations(sec.SecurityRecommendations...)
+	}
+	if c := result.ComplianceResult; c != nil {
+		for _, issue := range c.ComplianceIssues {
+			r.AddIssue(Issue{Severity: "medium", Source: "compliance", Category: issue.Framework + " " + issue.Control,
+				Message: issue.Finding, Remediation: issue.Remediation})
+		}
+		r.AddRecommendations(c.RequiredActions...)
+		r.addBaselines(c.Baselines)
+	}
+	r.AddRecommendations(result.Recommendations...)
+}
+
+// addBaselines adds baseline results to the compliance matrix. A profile
+// checked against several documents fails the controls any of them fails.
+func (r *Report) addBaselines(results []validation.BaselineResult) {
+	for _, b := range results {
+		var merged *validation.BaselineResult
+		for i := range r.Compliance {
+			if r.Compliance[i].Profile == b.Profile {
+				merged = &r.Compliance[i]
+			}
+		}
+		if merged == nil {
+			b.Controls = append([]validation.ControlResult(nil), b.Controls...)
+			r.Compliance = append(r.Compliance, b)
+			continue
+		}
+		for i, c := range b.Controls {
+			if i >= len(merged.Controls) || merged.Controls[i].ID != c.ID {
+				continue
+			}
+			m := &merged.Controls[i]
+			m.Evidence = mergeEvidence(m.Evidence, c.Evidence)
+			switch {
+			case c.Status == validation.ControlFail:
+				m.Status, m.Remediation = c.Status, c.Remediation
+			case c.Status == validation.ControlPass && m.Status == validation.ControlNotApplicable:
+				m.Status = c.Status
+			}
+		}
+		merged.Tally()
+	}
+}
+
+// mergeEvidence adds the evidence of another document to a control's,
+// replacing checks that did not apply and skipping repeats
+func mergeEvidence(evidence, more []validation.CheckEvidence) []validation.CheckEvidence {
+	merged := append([]validation.CheckEvidence(nil), evidence...)
+	for _, e := range more {
+		add := true
+		for i, existing := range merged {
+			if existing.Check != e.Check {
+				continue
+			}
+			switch {
+			case existing == e || e.Status == validation.ControlNotApplicable:
+				add = false
+			case existing.Status == validation.ControlNotApplicable:
+				merged[i], add = e, false
+			}
+		}
+		if add {
+			merged = append(merged, e)
+		}
+	}
+	return merged
+}
+
+// AddDeployment adds deployment test scores, test cases and findings
+func (r *Report) AddDeployment(result *validation.DeploymentTestResult) {
+	if result == nil {
+		return
+	}
+	r.ScoreCards = append(r.ScoreCards,
+		ScoreCard{Name: "Performance", Score: result.PerformanceScore,
+			Detail: fmt.Sprintf("%.0f rps, %.1f%% errors", result.ThroughputRPS, result.ErrorRate*100)},
+		ScoreCard{Name: "Reliability", Score: result.ReliabilityScore},
+	)
+	if chaos := result.Resilience; chaos != nil && chaos.Skipped == "" {
+		passed := 0
+		for _, e := range chaos.Ran() {
+			if e.Passed {
+				passed++
+			}
+		}
+		r.ScoreCards = append(r.ScoreCards, ScoreCard{Name: "Resilience", Score: chaos.Score,
+			Detail: fmt.Sprintf("%d of %d chaos experiments passed", passed, len(chaos.Ran()))})
+	}
+
+	for _, tc := range result.TestResults {
+		message := tc.ErrorMessage
+		if message == "" && tc.ExpectedCode != 0 {
+			message = fmt.Sprintf("expected %d, got %d", tc.ExpectedCode, tc.ActualCode)
+		}
+		r.Tests = append(r.Tests, TestCase{
+			Name:     tc.Name,
+			Target:   strings.TrimSpace(tc.Method + " " + tc.Endpoint),
+			Passed:   tc.Success,
+			Duration: tc.ResponseTime,
+			Message:  message,
+		})
+	}
+	for _, f := range result.SecurityFindings {
+		r.AddIssue(Issue{Severity: f.Severity, Source: "deployment-security", Category: f.Type,
+			Resource: f.Location, Message: f.Description})
+	}
+	for _, issue := range result.Issues {
+		r.AddIssue(Issue{Severity: "medium", Source: "deployment", Message: issue})
+	}
+	if d := result.Degradation; d != nil {
+		for _, s := range d.SkippedChecks {
+			r.AddIssue(Issue{Severity: "low", Source: "deployment-degradation", Category: s.Check,
+				Message: "Check skipped: " + s.Reason})
+		}
+		for _, f := range d.Fallbacks {
+			r.AddIssue(Issue{Severity: "low", Source: "deployment-degradation", Category: f,
+				Message: fmt.Sprintf("Fallback used; validation confidence %.0f%%", d.Confidence*100)})
+		}
+	}
+	r.AddRecommendations(result.Recommendations...)
+}
+
+// AddDecisions records HITL review outcomes
+func (r *Report) AddDecisions(decisions []packaging.HITLDecision) {
+	for _, d := range decisions {
+		r.Decisions = append(r.Decisions, Decision{
+			DropID:    d.DropID,
+			Decision:  string(d.Decision),
+			Feedback:  d.Feedback,
+			Changes:   len(d.Changes),
+			Timestamp: d.Timestamp,
+		})
+	}
+}
+
+// AddCostComparison adds the estimated monthly cost on each cloud provider
+// and recommends the cheapest
+func (r *Report) AddCostComparison(c *cloudcost.Comparison) {
+	if c == nil {
+		return
+	}
+	r.CostComparison = c
+	r.AddRecommendations(c.Summary())
+}
+
+// AddCodeMetrics adds the maintainability score and lists the complexity,
+// length, duplication and maintainability hotspots
+func (r *Report) AddCodeMetrics(m *codemetrics.Report) {
+	if m == nil {
+		return
+	}
+	r.CodeMetrics = m
+	r.ScoreCards = append(r.ScoreCards, ScoreCard{Name: "Maintainability", Score: m.Score,
+		Detail: fmt.Sprintf("index %.1f, %.0f%% duplicated, %d hotspots", m.Maintainability, m.Duplication*100, len(m.Hotspots))})
+}
+
+// AddPatches attaches remediation patches, recommending each change
+func (r *Report) AddPatches(patches []remediate.Patch) {
+	r.Patches = append(r.Patches, patches...)
+	for _, p := range patches {
+		r.AddRecommendations(fmt.Sprintf("Apply %s from %s: %s", p.File, remediate.PatchFile, strings.Join(p.Changes, "; ")))
+	}
+}
+
+// AddRequirements adds the coverage matrix of the intent's requirements
+// document and recommends implementing the requirements left uncovered
+func (r *Report) AddRequirements(m *requirements.Matrix) {
+	if m == nil {
+		return
+	}
+	r.Requirements = m
+	r.ScoreCards = append(r.ScoreCards, ScoreCard{Name: "Requirements", Score: m.Score(),
+		Detail: fmt.Sprintf("%d of %d covered, %d partially", m.Covered, len(m.Rows), m.Partial)})
+	for _, row := range m.Rows {
+		if row.Status == requirements.StatusUncovered && row.Priority == requirements.PriorityMust {
+			r.AddRecommendations(fmt.Sprintf("Implement %s, which no completed task covers: %s", row.ID, row.Text))
+		}
+	}
+}
+
+// AddIssue records a finding, normalizing its severity
+func (r *Report) AddIssue(issue Issue) {
+	issue.Severity = strings.ToLower(issue.Severity)
+	if _, ok := severityRank[issue.Severity]; !ok {
+		issue.Severity = "info"
+	}
+	r.Issues = append(r.Issues, issue)
+}
+
+// AddRecommendations appends recommendations, skipping duplicates
+func (r *Report) AddRecommendations(recs ...string) {
+	for _, rec := range recs {
+		rec = strings.TrimSpace(rec)
+		if rec == "" {
+			continue
+		}
+		duplicate := false
+		for _, existing := range r.Recommendations {
+			if existing == rec {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			r.Recommendations = append(r.Recommendations, rec)
+		}
+	}
+}
+
+// SeverityCounts returns the number of issues per severity
+func (r *Report) SeverityCounts() map[string]int {
+	counts := make(map[string]int)
+	for _, issue := range r.Issues {
+		counts[issue.Severity]++
+	}
+	return counts
+}
+
+// sortIssues orders issues by severity, most severe first
+func (r *Report) sortIssues() {
+	sort.SliceStable(r.Issues, func(i, j int) bool {
+		return severityRank[r.Issues[i].Severity] < severityRank[r.Issues[j].Severity]
+	})
+}
@@ -0,0 +1,259 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"QLP/internal/cloudcost"
+	"QLP/internal/codemetrics"
+	"QLP/internal/models"
+	"QLP/internal/packaging"
+	"QLP/internal/remediate"
+	"QLP/internal/requirements"
+	"QLP/internal/types"
+	"QLP/internal/validation"
+)
+
+func testReport() *Report {
+	capsule := &packaging.QLCapsule{
+		Metadata: packaging.CapsuleMetadata{
+			CapsuleID:       "QL-CAP-1",
+			IntentText:      "Create a users API",
+			OverallScore:    82,
+			TotalTasks:      3,
+			SuccessfulTasks: 3,
+		},
+		SecurityReport: packaging.SecurityReport{
+			SecurityScore:      55,
+			OverallRiskLevel:   types.SecurityRiskLevelHigh,
+			CriticalIssues:     []types.SecurityIssue{{Type: "sql_injection", Severity: "HIGH", Description: "query built from input", Location: "db.go"}},
+			RecommendedActions: []string{"Use parameterized queries"},
+		},
+		QualityReport: packaging.QualityReport{OverallQualityScore: 74},
+	}
+
+	r := FromCapsule(capsule)
+	r.AddInfra(&validation.InfraValidationResult{
+		OverallScore: 90,
+		KubernetesResult: &validation.KubernetesValidationResult{
+			Issues: []validation.KubernetesIssue{{Type: "resources", Severity: "critical", Resource: "deployment/api", Message: "no limits | requests"}},
+		},
+		Recommendations: []string{"Use parameterized queries", "Add resource limits"},
+	})
+	r.AddDeployment(&validation.DeploymentTestResult{
+		PerformanceScore: 88,
+		ReliabilityScore: 40,
+		TestResults: []validation.TestCaseResult{
+			{Name: "health", Method: "GET", Endpoint: "/health", ExpectedCode: 200, ActualCode: 200, Success: true},
+			{Name: "create user", Method: "POST", Endpoint: "/users", ExpectedCode: 201, ActualCode: 500},
+		},
+	})
+	r.AddDecisions([]packaging.HITLDecision{{DropID: "drop-1", Decision: packaging.HITLActionModify, Feedback: "<b>tidy</b>", Timestamp: time.Now()}})
+	return r
+}
+
+func TestReportCollectsFindings(t *testing.T) {
+	r := testReport()
+	if len(r.ScoreCards) != 10 {
+		t.Errorf("score cards = %d, want 10", len(r.ScoreCards))
+	}
+	counts := r.SeverityCounts()
+	if counts["critical"] != 1 || counts["high"] != 1 {
+		t.Errorf("severity counts = %v", counts)
+	}
+	if len(r.Recommendations) != 2 {
+		t.Errorf("recommendations = %v, want duplicates removed", r.Recommendations)
+	}
+	if r.Tests[1].Passed || r.Tests[1].Message != "expected 201, got 500" {
+		t.Errorf("failed test = %+v", r.Tests[1])
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	md := string(Markdown(testReport()))
+	for _, want := range []string{
+		"# Validation Report: Create a users API",
+		"| Security | 55/100 | 🔴 fail |",
+		"## Issues (2)",
+		`no limits \| requests`,
+		"## Tests (1/2 passed)",
+		"| drop-1 | modify | 0 |",
+		"- Add resource limits",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+	// Critical issues are listed before high ones
+	if strings.Index(md, "| critical |") > strings.Index(md, "| high |") {
+		t.Error("issues not sorted by severity")
+	}
+}
+
+func TestHTMLEscapesContent(t *testing.T) {
+	data, contentType, err := Render(testReport(), "html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(contentType, "text/html") {
+		t.Errorf("content type = %q", contentType)
+	}
+	html := string(data)
+	if strings.Contains(html, "<b>tidy</b>") || !strings.Contains(html, "&lt;b&gt;tidy&lt;/b&gt;") {
+		t.Error("HITL feedback was not escaped")
+	}
+	if !strings.Contains(html, `class="card fail"`) || !strings.Contains(html, `class="sev critical"`) {
+		t.Error("score card or severity classes missing")
+	}
+
+	if _, _, err := Render(testReport(), "pdf"); err == nil {
+		t.Error("Render() accepted an unsupported format")
+	}
+}
+
+func TestSARIF(t *testing.T) {
+	run := SARIF(testReport()).Runs[0]
+	if len(run.Results) != 2 {
+		t.Fatalf("results = %d, want 2", len(run.Results))
+	}
+	for _, result := range run.Results {
+		if len(result.Locations) != 1 || result.Level != "error" {
+			t.Errorf("result = %+v", result)
+		}
+	}
+}
+
+func TestCostComparisonRendering(t *testing.T) {
+	r := testReport()
+	arch := cloudcost.Analyze(map[string]string{"docker-compose.yml": "services:\n  api:\n    build: .\n  db:\n    image: postgres:16\n"})
+	r.AddCostComparison(cloudcost.Compare(arch, cloudcost.DefaultPriceSheet()))
+	if r.CostComparison == nil || !strings.Contains(r.Recommendations[len(r.Recommendations)-1], "cheapest target") {
+		t.Fatalf("comparison not added: %+v", r.Recommendations)
+	}
+
+	md := string(Markdown(r))
+	if !strings.Contains(md, "## Cloud Cost Comparison") || !strings.Contains(md, "| Component | Qty | Azure | AWS | GCP |") ||
+		!strings.Contains(md, "| Managed database (2 vCPU) | 1 | $129.94 | $124.83 | $98.62 |") {
+		t.Errorf("markdown comparison missing:\n%s", md)
+	}
+	html, err := HTML(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(html), `<td title="RDS for PostgreSQL db.m6i.large">$124.83</td>`) ||
+		!strings.Contains(string(html), `<th class="ok">$`) {
+		t.Errorf("HTML comparison missing:\n%s", html)
+	}
+}
+
+func TestCodeHotspotsRendering(t *testing.T) {
+	r := testReport()
+	var body strings.Builder
+	for i := 0; i < 12; i++ {
+		body.WriteString("\tif x == " + string(rune('a'+i)) + " {\n\t\treturn 1\n\t}\n")
+	}
+	r.AddCodeMetrics(codemetrics.Analyze(map[string]string{"calc.go": "package calc\n\nfunc Pick(x rune) int {\n" + body.String() + "\treturn 0\n}\n"}))
+	if card := r.ScoreCards[len(r.ScoreCards)-1]; card.Name != "Maintainability" || card.Score != 96 {
+		t.Fatalf("score card = %+v", card)
+	}
+
+	md := string(Markdown(r))
+	if !strings.Contains(md, "## Code Hotspots") || !strings.Contains(md, "| medium | calc.go:3 | complexity | 13 | 10 | Pick has a cyclomatic complexity of 13 |") {
+		t.Errorf("markdown hotspots missing:\n%s", md)
+	}
+	html, err := HTML(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(html), `<td>calc.go:3</td><td>complexity</td><td>13</td>`) {
+		t.Errorf("HTML hotspots missing:\n%s", html)
+	}
+}
+
+func TestComplianceMatrix(t *testing.T) {
+	r := New("Compliance")
+	terraform, _ := validation.EvaluateBaseline("pci-dss", `resource "aws_s3_bucket" "b" { sse_algorithm = "aws:kms" }`)
+	kubernetes, _ := validation.EvaluateBaseline("pci-dss", "apiVersion: apps/v1\nkind: Deployment\nspec:\n  securityContext:\n    runAsUser: 0\n")
+	r.AddInfra(&validation.InfraValidationResult{ComplianceResult: &validation.ComplianceValidationResult{Baselines: []validation.BaselineResult{*terraform}}})
+	r.AddInfra(&validation.InfraValidationResult{ComplianceResult: &validation.ComplianceValidationResult{Baselines: []validation.BaselineResult{*kubernetes}}})
+
+	if len(r.Compliance) != 1 {
+		t.Fatalf("expected one column per profile, got %d", len(r.Compliance))
+	}
+	pci := r.Compliance[0]
+	statuses := make(map[string]string)
+	for _, c := range pci.Controls {
+		statuses[c.ID] = c.Status
+	}
+	if statuses["3.5.1"] != validation.ControlFail || statuses["2.2.1"] != validation.ControlFail {
+		t.Errorf("expected controls failed by either document to fail: %v", statuses)
+	}
+	if pci.Passed+pci.Failed == 0 || pci.Score != pci.Passed*100/(pci.Passed+pci.Failed) {
+		t.Errorf("expected the merged profile to be rescored: %+v", pci)
+	}
+	if terraform.Controls[3].Status != validation.ControlPass {
+		t.Errorf("expected merging to leave the validation result alone: %+v", terraform.Controls[3])
+	}
+
+	md := string(Markdown(r))
+	if !strings.Contains(md, "### PCI DSS 4.0:") || !strings.Contains(md, "| 2.2.1 | System components are configured securely | ❌ fail | non_root: runAsUser: 0 (line 5) |") {
+		t.Errorf("markdown matrix missing:\n%s", md)
+	}
+	html, err := HTML(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(html), `<td>2.2.1</td><td>System components are configured securely</td><td><span class="bad">fail</span></td>`) {
+		t.Errorf("HTML matrix missing:\n%s", html)
+	}
+}
+
+func TestRemediationPatches(t *testing.T) {
+	r := New("Remediation")
+	r.AddPatches(remediate.Suggest(map[string]string{"main.tf": `provider "aws" {}`}))
+	if len(r.Patches) != 1 || len(r.Recommendations) != 1 || !strings.Contains(r.Recommendations[0], "Apply versions.tf from remediation.patch") {
+		t.Fatalf("expected the versions file patch recommended, got %+v", r.Recommendations)
+	}
+
+	md := string(Markdown(r))
+	if !strings.Contains(md, "## Remediation Patches") || !strings.Contains(md, "```diff\n--- /dev/null\n+++ b/versions.tf\n") {
+		t.Errorf("markdown patches missing:\n%s", md)
+	}
+	html, err := HTML(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(html), `<pre class="diff">--- /dev/null`) || !strings.Contains(string(html), `&#43;      version = &#34;~&gt; 5.0&#34;`) {
+		t.Errorf("html patches missing:\n%s", html)
+	}
+}
+
+func TestRequirementsCoverage(t *testing.T) {
+	r := New("Requirements")
+	doc := &requirements.Document{Name: "spec.md", Requirements: []requirements.Requirement{
+		{ID: "REQ-001", Text: "Orders are stored in PostgreSQL", Priority: requirements.PriorityMust, Tasks: []string{"task_1"}},
+		{ID: "REQ-002", Text: "Admins can export orders", Priority: requirements.PriorityMust},
+	}}
+	r.AddRequirements(requirements.NewMatrix(doc, []packaging.TaskArtifact{{TaskID: "task_1", Status: models.TaskStatusCompleted}},
+		[]packaging.QuantumDrop{{Tasks: []string{"task_1"}, Files: map[string]string{"db/schema.sql": ""}}}))
+
+	if card := r.ScoreCards[len(r.ScoreCards)-1]; card.Name != "Requirements" || card.Score != 50 {
+		t.Fatalf("score card = %+v", card)
+	}
+	if len(r.Recommendations) != 1 || !strings.Contains(r.Recommendations[0], "Implement REQ-002") {
+		t.Errorf("expected the uncovered requirement recommended, got %v", r.Recommendations)
+	}
+
+	md := string(Markdown(r))
+	if !strings.Contains(md, "## Requirements Coverage") || !strings.Contains(md, "| REQ-001 | must | Orders are stored in PostgreSQL | task_1 | db/schema.sql | ✅ covered |") {
+		t.Errorf("markdown coverage missing:\n%s", md)
+	}
+	html, err := HTML(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(html), `<td>REQ-002</td><td>must</td><td>Admins can export orders</td><td></td><td></td><td><span class="bad">uncovered</span></td>`) {
+		t.Errorf("HTML coverage missing:\n%s", html)
+	}
+}
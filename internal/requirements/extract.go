@@ -0,0 +1,197 @@
+package requirements
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Kinds of documents text is extracted from
+const (
+	KindMarkdown   = "markdown"
+	KindText       = "text"
+	KindPDF        = "pdf"
+	KindDocx       = "docx"
+	KindTranscript = "transcript"
+)
+
+// MaxDocumentSize caps the documents read
+const MaxDocumentSize = 20 << 20
+
+// ErrNoText is returned for documents without extractable text, such as
+// scanned PDFs
+var ErrNoText = errors.New("document has no extractable text")
+
+// Extract returns the text of a document and its kind, told by its name.
+// Markdown keeps its headings and lists; Word headings and list items are
+// rendered the same way. Transcripts (WebVTT, SRT) lose their cue numbers
+// and timings.
+func Extract(name string, data []byte) (string, string, error) {
+	if len(data) > MaxDocumentSize {
+		return "", "", fmt.Errorf("%s is larger than %d MB", name, MaxDocumentSize>>20)
+	}
+	var text, kind string
+	var err error
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md", ".markdown":
+		text, kind = string(data), KindMarkdown
+	case ".pdf":
+		text, err = pdfText(data)
+		kind = KindPDF
+	case ".docx":
+		text, err = docxText(data)
+		kind = KindDocx
+	case ".vtt", ".srt":
+		text, kind = transcriptText(string(data)), KindTranscript
+	case ".txt", "":
+		if !utf8.Valid(data) {
+			return "", "", fmt.Errorf("%s is not UTF-8 text", name)
+		}
+		text, kind = string(data), KindText
+	default:
+		return "", "", fmt.Errorf("unsupported requirements document %s: use Markdown, PDF, docx, text or a WebVTT/SRT transcript", name)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	if text == "" {
+		return "", "", fmt.Errorf("failed to read %s: %w", name, ErrNoText)
+	}
+	return text, kind, nil
+}
+
+var (
+	cueTiming = regexp.MustCompile(`^\d{1,2}:\d{2}(:\d{2})?[.,]\d{3}\s+-->`)
+	cueNumber = regexp.MustCompile(`^\d+$`)
+	voiceTag  = regexp.MustCompile(`<v\s+([^>]+)>`)
+	cueTag    = regexp.MustCompile(`</?[a-z][^>]*>`)
+)
+
+// transcriptText keeps the spoken lines of a WebVTT or SRT transcript,
+// prefixed by their speaker when the cues name one
+func transcriptText(s string) string {
+	var lines []string
+	skip := false
+	for _, line := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			skip = false
+			continue
+		case skip:
+			continue
+		case strings.HasPrefix(line, "WEBVTT"), cueNumber.MatchString(line), cueTiming.MatchString(line):
+			continue
+		case strings.HasPrefix(line, "NOTE"), strings.HasPrefix(line, "STYLE"), strings.HasPrefix(line, "REGION"):
+			skip = true // Blocks up to the next blank line
+			continue
+		}
+		if m := voiceTag.FindStringSubmatch(line); m != nil {
+			line = strings.TrimSpace(m[1]) + ": " + line[len(m[0]):]
+		}
+		line = strings.TrimSpace(cueTag.ReplaceAllString(line, ""))
+		if line != "" && (len(lines) == 0 || lines[len(lines)-1] != line) {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// headingStyle matches the style IDs of Word's heading styles; localized
+// Word drops non-ASCII letters from them, so German headings are
+// "berschrift1"
+var headingStyle = regexp.MustCompile(`^(?i)(heading|berschrift|titre|encabezado|titolo)\s*(\d)$`)
+
+// docxText reads the paragraphs of a Word document, rendering headings and
+// list items as Markdown
+func docxText(data []byte) (string, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("not a docx archive: %w", err)
+	}
+	var document *zip.File
+	for _, f := range reader.File {
+		if f.Name == "word/document.xml" {
+			document = f
+		}
+	}
+	if document == nil {
+		return "", fmt.Errorf("not a docx archive: no word/document.xml")
+	}
+	rc, err := document.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	var b, paragraph strings.Builder
+	prefix := ""
+	decoder := xml.NewDecoder(io.LimitReader(rc, MaxDocumentSize))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("invalid word/document.xml: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				paragraph.Reset()
+				prefix = ""
+			case "pStyle":
+				style := attr(t, "val")
+				if m := headingStyle.FindStringSubmatch(style); m != nil {
+					prefix = strings.Repeat("#", int(m[2][0]-'0')) + " "
+				} else if strings.EqualFold(style, "Title") {
+					prefix = "# "
+				} else if strings.HasPrefix(strings.ToLower(style), "list") && prefix == "" {
+					prefix = "- "
+				}
+			case "numPr":
+				if prefix == "" {
+					prefix = "- "
+				}
+			case "tab":
+				paragraph.WriteString("\t")
+			case "br", "cr":
+				paragraph.WriteString("\n")
+			case "t":
+				var text string
+				if err := decoder.DecodeElement(&text, &t); err != nil {
+					return "", fmt.Errorf("invalid word/document.xml: %w", err)
+				}
+				paragraph.WriteString(text)
+			}
+		case xml.EndElement:
+			if t.Name.Local == "p" {
+				if text := strings.TrimSpace(paragraph.String()); text != "" {
+					b.WriteString(prefix + text + "\n")
+				}
+				if strings.HasPrefix(prefix, "#") {
+					b.WriteString("\n")
+				}
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+func attr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,253 @@
+package requirements
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	streamStart = []byte("stream")
+	streamEnd   = []byte("endstream")
+)
+
+// pdfText extracts the text shown by the content streams of a PDF, plain or
+// Flate-compressed. It reads documents exported by word processors; text
+// drawn with embedded CID fonts or scanned pages yields ErrNoText.
+func pdfText(data []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("%PDF")) {
+		return "", errors.New("not a PDF document")
+	}
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return "", errors.New("encrypted PDF documents are not supported")
+	}
+
+	var b strings.Builder
+	for offset := 0; ; {
+		i := bytes.Index(data[offset:], streamStart)
+		if i < 0 {
+			break
+		}
+		start := offset + i + len(streamStart)
+		end := bytes.Index(data[start:], streamEnd)
+		if end < 0 {
+			break
+		}
+		// The keyword is followed by an end of line, and the stream's
+		// dictionary precedes it, after the object's header
+		dictionary := data[max(0, offset+i-1024) : offset+i]
+		if d := bytes.LastIndex(dictionary, []byte("obj")); d >= 0 {
+			dictionary = dictionary[d:]
+		}
+		content := bytes.TrimLeft(data[start:start+end], "\r\n")
+		offset = start + end + len(streamEnd)
+
+		if bytes.Contains(dictionary, []byte("/FlateDecode")) {
+			r, err := zlib.NewReader(bytes.NewReader(content))
+			if err != nil {
+				continue
+			}
+			inflated, err := io.ReadAll(io.LimitReader(r, MaxDocumentSize))
+			r.Close()
+			if err != nil && len(inflated) == 0 {
+				continue
+			}
+			content = inflated
+		} else if bytes.Contains(dictionary, []byte("/Filter")) {
+			continue // Images and fonts
+		}
+		if bytes.Contains(content, []byte("BT")) {
+			b.WriteString(contentText(content))
+			b.WriteString("\n")
+		}
+	}
+	text := collapseBlankLines(b.String())
+	if strings.TrimSpace(text) == "" {
+		return "", ErrNoText
+	}
+	return text, nil
+}
+
+// contentText interprets the text operators of a content stream: strings
+// shown by Tj, TJ, ' and " are written out, and line moves start new lines
+func contentText(content []byte) string {
+	var b, line strings.Builder
+	var operands []float64
+	inArray := false
+	newline := func() {
+		if s := strings.TrimSpace(line.String()); s != "" {
+			b.WriteString(s + "\n")
+		}
+		line.Reset()
+	}
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			s, n := literalString(content[i:])
+			line.WriteString(s)
+			i += n
+		case c == '<' && i+1 < len(content) && content[i+1] != '<':
+			s, n := hexString(content[i:])
+			line.WriteString(s)
+			i += n
+		case c == '[':
+			inArray = true
+			i++
+		case c == ']':
+			inArray = false
+			i++
+		case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(content) && (content[j] == '.' || (content[j] >= '0' && content[j] <= '9')) {
+				j++
+			}
+			if v, err := strconv.ParseFloat(string(content[i:j]), 64); err == nil {
+				// Large negative kerning in a TJ array separates words
+				if inArray && v < -200 {
+					line.WriteString(" ")
+				}
+				operands = append(operands, v)
+			}
+			i = j
+		case isOperatorByte(c):
+			j := i + 1
+			for j < len(content) && isOperatorByte(content[j]) {
+				j++
+			}
+			switch string(content[i:j]) {
+			case "'", `"`, "T*", "ET":
+				newline()
+			case "Td", "TD":
+				if len(operands) >= 2 && operands[len(operands)-1] != 0 {
+					newline()
+				} else if line.Len() > 0 {
+					line.WriteString(" ")
+				}
+			case "Tm":
+				newline()
+			}
+			operands = operands[:0]
+			i = j
+		default:
+			i++
+		}
+	}
+	newline()
+	return b.String()
+}
+
+func isOperatorByte(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '*' || c == '\'' || c == '"'
+}
+
+// literalString decodes a parenthesized string, returning it and the number
+// of bytes it spans
+func literalString(s []byte) (string, int) {
+	var b strings.Builder
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '(':
+			if depth > 0 {
+				b.WriteByte(c)
+			}
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return b.String(), i + 1
+			}
+			b.WriteByte(c)
+		case '\\':
+			i++
+			if i >= len(s) {
+				return b.String(), i
+			}
+			switch e := s[i]; {
+			case e == 'n', e == 'r':
+				b.WriteByte(' ')
+			case e == 't':
+				b.WriteByte('\t')
+			case e >= '0' && e <= '7':
+				j := i
+				for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+					j++
+				}
+				v, _ := strconv.ParseUint(string(s[i:j]), 8, 8)
+				b.WriteString(latin1(byte(v)))
+				i = j - 1
+			case e == '\n', e == '\r':
+				// Line continuation
+			default:
+				b.WriteString(latin1(e))
+			}
+		default:
+			b.WriteString(latin1(c))
+		}
+	}
+	return b.String(), len(s)
+}
+
+// hexString decodes a <...> string of single-byte codes, returning it and
+// the number of bytes it spans. Two-byte codes of CID fonts decode to
+// nothing readable and are dropped.
+func hexString(s []byte) (string, int) {
+	end := bytes.IndexByte(s, '>')
+	if end < 0 {
+		return "", len(s)
+	}
+	digits := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\n' || r == '\r' || r == '\t' {
+			return -1
+		}
+		return r
+	}, string(s[1:end]))
+	if len(digits)%2 == 1 {
+		digits += "0"
+	}
+	var b strings.Builder
+	for i := 0; i+1 < len(digits); i += 2 {
+		v, err := strconv.ParseUint(digits[i:i+2], 16, 8)
+		if err != nil {
+			return "", end + 1
+		}
+		if v < 0x20 && v != '\t' {
+			return "", end + 1
+		}
+		b.WriteString(latin1(byte(v)))
+	}
+	return b.String(), end + 1
+}
+
+// latin1 decodes a byte of the standard encodings, which agree with
+// Latin-1 on letters
+func latin1(c byte) string {
+	return string(rune(c))
+}
+
+func collapseBlankLines(s string) string {
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			if !blank && len(lines) > 0 {
+				lines = append(lines, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,273 @@
+// Package requirements ingests a requirements document attached to an intent
+// (Markdown, PDF, Word or a meeting transcript), extracts the structured
+// requirements decomposition plans tasks for, traces each requirement to the
+// tasks and generated files that implement it and reports their coverage.
+package requirements
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"QLP/internal/llm"
+	"QLP/internal/logger"
+	"QLP/internal/models"
+	"go.uber.org/zap"
+)
+
+// Priorities, as MoSCoW without won't-haves
+const (
+	PriorityMust   = "must"
+	PriorityShould = "should"
+	PriorityCould  = "could"
+)
+
+// MetadataKey holds the document's requirements, as JSON, in the metadata of
+// the intent it is attached to
+const MetadataKey = "requirements"
+
+const (
+	// maxPromptChars caps how much of a document the extraction prompt quotes
+	maxPromptChars = 48000
+	// maxRequirements caps the requirements taken from a document
+	maxRequirements = 100
+)
+
+// Requirement is one requirement of a document. Tasks are the IDs of the
+// tasks planned for it, once traced.
+type Requirement struct {
+	ID       string   `json:"id"`
+	Text     string   `json:"text"`
+	Section  string   `json:"section,omitempty"`
+	Priority string   `json:"priority"`
+	Tasks    []string `json:"tasks,omitempty"`
+}
+
+// Document is the requirements extracted from an attached document
+type Document struct {
+	Name         string        `json:"name"`
+	Kind         string        `json:"kind"`
+	Summary      string        `json:"summary,omitempty"`
+	Requirements []Requirement `json:"requirements"`
+}
+
+// Attachment is a requirements document as the user attached it
+type Attachment struct {
+	Name string
+	Data []byte
+}
+
+type contextKey struct{}
+
+// WithAttachment attaches a requirements document to the intent ctx carries
+func WithAttachment(ctx context.Context, a Attachment) context.Context {
+	return context.WithValue(ctx, contextKey{}, a)
+}
+
+// AttachmentFromContext returns the requirements document attached to ctx,
+// if any
+func AttachmentFromContext(ctx context.Context) (Attachment, bool) {
+	a, ok := ctx.Value(contextKey{}).(Attachment)
+	return a, ok
+}
+
+// Ingest extracts the requirements of an attached document, with the model
+// when client is set and from its lists and modal sentences otherwise or
+// when the model fails
+func Ingest(ctx context.Context, client llm.Client, a Attachment) (*Document, error) {
+	text, kind, err := Extract(a.Name, a.Data)
+	if err != nil {
+		return nil, err
+	}
+	doc := &Document{Name: path.Base(a.Name), Kind: kind}
+	if client != nil {
+		if err := doc.extractWithLLM(ctx, client, text); err != nil {
+			logger.WithComponent("requirements").Warn("Failed to extract requirements with the model, reading them from the document's structure",
+				zap.String("document", doc.Name),
+				zap.Error(err))
+		}
+	}
+	if len(doc.Requirements) == 0 {
+		doc.Requirements = Heuristic(text)
+	}
+	if len(doc.Requirements) == 0 {
+		return nil, fmt.Errorf("no requirements found in %s", doc.Name)
+	}
+	if len(doc.Requirements) > maxRequirements {
+		doc.Requirements = doc.Requirements[:maxRequirements]
+	}
+	for i := range doc.Requirements {
+		doc.Requirements[i].ID = fmt.Sprintf("REQ-%03d", i+1)
+	}
+	return doc, nil
+}
+
+func (d *Document) extractWithLLM(ctx context.Context, client llm.Client, text string) error {
+	if len(text) > maxPromptChars {
+		text = text[:maxPromptChars] + "\n[document truncated]"
+	}
+	hint := ""
+	if d.Kind == KindTranscript {
+		hint = " It is the transcript of a conversation: keep only what the participants agreed the system must do, not ideas they dropped."
+	}
+	prompt := fmt.Sprintf(`Extract the software requirements from this requirements document (%s).%s
+
+Write each requirement as one testable statement of what the system must do, in the document's order, with the section it appears in. Split compound requirements; merge duplicates; leave out background, rationale and project logistics. Prioritize as must, should or could.
+
+<document>
+%s
+</document>
+
+Respond with JSON: {"summary": "<one sentence describing the system>", "requirements": [{"text": "<requirement>", "section": "<section>", "priority": "must|should|could"}]}`,
+		d.Name, hint, text)
+
+	var response struct {
+		Summary      string `json:"summary,omitempty"`
+		Requirements []struct {
+			Text     string `json:"text"`
+			Section  string `json:"section,omitempty"`
+			Priority string `json:"priority"`
+		} `json:"requirements"`
+	}
+	schema := llm.SchemaFor("requirements", "Requirements extracted from a document", response)
+	if err := llm.CompleteJSON(ctx, client, prompt, schema, &response); err != nil {
+		return err
+	}
+	d.Summary = strings.TrimSpace(response.Summary)
+	for _, r := range response.Requirements {
+		if text := strings.TrimSpace(r.Text); text != "" {
+			d.Requirements = append(d.Requirements, Requirement{Text: text,
+				Section: strings.TrimSpace(r.Section), Priority: normalizePriority(r.Priority, text)})
+		}
+	}
+	return nil
+}
+
+var (
+	headingLine  = regexp.MustCompile(`^#{1,6}\s+(.+?)\s*#*$`)
+	listLine     = regexp.MustCompile(`^(?:[-*+•]|\d+[.)]|[a-zA-Z][.)])\s+(.+)$`)
+	speakerLabel = regexp.MustCompile(`^[A-Z][\w .'-]{0,30}:\s+`)
+	sentenceEnd  = regexp.MustCompile(`([.!?])\s+`)
+	// requirementSection names sections whose list items are requirements
+	requirementSection = regexp.MustCompile(`(?i)requirement|feature|scope|stor(y|ies)|acceptance|functional|capabilit|must|need`)
+	// modal marks statements of what the system is to do
+	modal = regexp.MustCompile(`(?i)\b(must|shall|should|needs? to|has to|have to|required|we need|we want|i want|it would be nice|nice to have)\b`)
+
+	mustWords  = regexp.MustCompile(`(?i)\b(must|shall|required|mandatory|has to|have to|needs? to|we need|critical)\b`)
+	couldWords = regexp.MustCompile(`(?i)\b(could|may|optional|nice to have|would be nice|ideally)\b`)
+)
+
+// Heuristic reads requirements from a document's structure: list items in
+// requirement sections, and statements anywhere that say what the system
+// must, shall or should do
+func Heuristic(text string) []Requirement {
+	var requirements []Requirement
+	seen := make(map[string]bool)
+	add := func(statement, section string) {
+		statement = strings.TrimSpace(strings.Trim(statement, "*_`"))
+		key := strings.ToLower(statement)
+		if len(statement) < 12 || seen[key] {
+			return
+		}
+		seen[key] = true
+		requirements = append(requirements, Requirement{Text: statement, Section: section,
+			Priority: normalizePriority("", statement)})
+	}
+
+	section := ""
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if m := headingLine.FindStringSubmatch(line); m != nil {
+			section = strings.TrimSuffix(m[1], ":")
+			continue
+		}
+		if m := listLine.FindStringSubmatch(line); m != nil {
+			if requirementSection.MatchString(section) || modal.MatchString(m[1]) {
+				add(m[1], section)
+			}
+			continue
+		}
+		line = speakerLabel.ReplaceAllString(line, "")
+		for _, sentence := range strings.Split(sentenceEnd.ReplaceAllString(line, "$1\n"), "\n") {
+			if modal.MatchString(sentence) {
+				add(sentence, section)
+			}
+		}
+	}
+	return requirements
+}
+
+// normalizePriority maps a stated priority, or the statement's wording, to
+// must, should or could
+func normalizePriority(priority, statement string) string {
+	switch p := strings.ToLower(strings.TrimSpace(priority)); {
+	case strings.HasPrefix(p, "must"), p == "high", p == "critical":
+		return PriorityMust
+	case strings.HasPrefix(p, "should"), p == "medium":
+		return PriorityShould
+	case strings.HasPrefix(p, "could"), p == "low", p == "optional":
+		return PriorityCould
+	}
+	switch {
+	case mustWords.MatchString(statement):
+		return PriorityMust
+	case couldWords.MatchString(statement):
+		return PriorityCould
+	default:
+		return PriorityShould
+	}
+}
+
+// PromptContext renders the requirements for the decomposition prompt
+func (d *Document) PromptContext() string {
+	if d == nil || len(d.Requirements) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n\nRequirements from %s", d.Name)
+	if d.Summary != "" {
+		fmt.Fprintf(&b, " (%s)", d.Summary)
+	}
+	b.WriteString(". Plan tasks that together implement every one of them:\n")
+	for _, r := range d.Requirements {
+		fmt.Fprintf(&b, "- %s [%s] %s\n", r.ID, r.Priority, r.Text)
+	}
+	return b.String()
+}
+
+// Metadata records the document and its requirements on the intent
+func (d *Document) Metadata() map[string]string {
+	if d == nil {
+		return nil
+	}
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil
+	}
+	return map[string]string{
+		MetadataKey:             string(data),
+		"requirements.document": d.Name,
+		"requirements.count":    strconv.Itoa(len(d.Requirements)),
+	}
+}
+
+// FromIntent returns the requirements document recorded on an intent, nil
+// when it has none
+func FromIntent(intent models.Intent) *Document {
+	data, ok := intent.Metadata[MetadataKey]
+	if !ok {
+		return nil
+	}
+	var d Document
+	if err := json.Unmarshal([]byte(data), &d); err != nil {
+		return nil
+	}
+	return &d
+}
@@ -0,0 +1,199 @@
+package requirements
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"QLP/internal/models"
+	"QLP/internal/packaging"
+)
+
+const spec = `# Order service
+
+Background: the shop outgrew its spreadsheet.
+
+## Functional requirements
+
+- Customers can place orders with one or more items
+- Orders are stored in PostgreSQL
+- Admins could export orders as CSV
+
+## Security
+
+The API must authenticate requests with JWT. Rate limiting would be nice to have.
+`
+
+func TestHeuristic(t *testing.T) {
+	requirements := Heuristic(spec)
+	var got []string
+	for _, r := range requirements {
+		got = append(got, r.Priority+"|"+r.Section+"|"+r.Text)
+	}
+	want := []string{
+		"should|Functional requirements|Customers can place orders with one or more items",
+		"should|Functional requirements|Orders are stored in PostgreSQL",
+		"could|Functional requirements|Admins could export orders as CSV",
+		"must|Security|The API must authenticate requests with JWT.",
+		"could|Security|Rate limiting would be nice to have.",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requirements:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestExtractTranscript(t *testing.T) {
+	vtt := "WEBVTT\n\nNOTE recorded on the call\n\n1\n00:00:01.000 --> 00:00:04.000\n<v Dana>We need a dashboard showing daily signups.</v>\n\n2\n00:00:04.500 --> 00:00:06.000\n<v Sam>Sounds good.</v>\n"
+	text, kind, err := Extract("kickoff.vtt", []byte(vtt))
+	if err != nil || kind != KindTranscript {
+		t.Fatalf("Extract = %q, %q, %v", text, kind, err)
+	}
+	if text != "Dana: We need a dashboard showing daily signups.\nSam: Sounds good." {
+		t.Errorf("transcript text = %q", text)
+	}
+	if r := Heuristic(text); len(r) != 1 || r[0].Text != "We need a dashboard showing daily signups." || r[0].Priority != PriorityMust {
+		t.Errorf("transcript requirements = %+v", r)
+	}
+}
+
+func TestExtractDocx(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("word/document.xml")
+	fmt.Fprint(w, `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Requirements</w:t></w:r></w:p>
+<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/></w:numPr></w:pPr><w:r><w:t xml:space="preserve">Users can reset </w:t></w:r><w:r><w:t>their password</w:t></w:r></w:p>
+</w:body></w:document>`)
+	zw.Close()
+
+	text, kind, err := Extract("spec.docx", buf.Bytes())
+	if err != nil || kind != KindDocx {
+		t.Fatalf("Extract = %q, %q, %v", text, kind, err)
+	}
+	if text != "# Requirements\n\n- Users can reset their password" {
+		t.Errorf("docx text = %q", text)
+	}
+}
+
+func TestExtractPDF(t *testing.T) {
+	content := "BT /F1 12 Tf 72 720 Td (The service must) Tj ( expose) Tj 0 -14 Td [(a health) -250 (check.)] TJ ET"
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write([]byte(content))
+	zw.Close()
+
+	for name, stream := range map[string]string{
+		"plain":      fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		"compressed": fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), compressed.String()),
+	} {
+		pdf := "%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\n4 0 obj\n" + stream + "\nendobj\n%%EOF\n"
+		text, kind, err := Extract("spec.pdf", []byte(pdf))
+		if err != nil || kind != KindPDF {
+			t.Fatalf("%s: Extract = %q, %q, %v", name, text, kind, err)
+		}
+		if text != "The service must expose\na health check." {
+			t.Errorf("%s: pdf text = %q", name, text)
+		}
+	}
+
+	if _, _, err := Extract("scan.pdf", []byte("%PDF-1.4\n1 0 obj\n<< /Filter /DCTDecode >>\nstream\n\xff\xd8\nendstream\nendobj\n")); err == nil {
+		t.Error("expected an error for a PDF without text")
+	}
+	if _, _, err := Extract("spec.odt", []byte("x")); err == nil {
+		t.Error("expected an error for an unsupported document")
+	}
+}
+
+type fakeClient struct {
+	responses []string
+}
+
+func (c *fakeClient) Complete(ctx context.Context, prompt string) (string, error) {
+	response := c.responses[0]
+	if len(c.responses) > 1 {
+		c.responses = c.responses[1:]
+	}
+	return response, nil
+}
+
+func (c *fakeClient) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	return nil, nil
+}
+
+func TestIngestAndTrace(t *testing.T) {
+	client := &fakeClient{responses: []string{
+		`{"summary": "An order service", "requirements": [
+			{"text": "Customers can place orders", "section": "Functional", "priority": "Must"},
+			{"text": "Admins can export orders as CSV", "priority": "low"}]}`,
+		`{"links": [{"requirement": "REQ-001", "tasks": ["task_1", "task_9"]}, {"requirement": "REQ-002", "tasks": []}]}`,
+	}}
+	doc, err := Ingest(context.Background(), client, Attachment{Name: "docs/spec.md", Data: []byte(spec)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Name != "spec.md" || doc.Summary != "An order service" || len(doc.Requirements) != 2 ||
+		doc.Requirements[0].ID != "REQ-001" || doc.Requirements[0].Priority != PriorityMust || doc.Requirements[1].Priority != PriorityCould {
+		t.Fatalf("unexpected document: %+v", doc)
+	}
+	if prompt := doc.PromptContext(); !strings.Contains(prompt, "- REQ-002 [could] Admins can export orders as CSV") {
+		t.Errorf("prompt context = %q", prompt)
+	}
+
+	tasks := []models.Task{{ID: "task_1", Description: "Implement order placement"}, {ID: "task_2", Description: "Write docs"}}
+	Trace(context.Background(), client, doc, tasks)
+	if got := doc.Requirements[0].Tasks; len(got) != 1 || got[0] != "task_1" {
+		t.Errorf("expected unknown tasks dropped, got %v", got)
+	}
+	if tasks[0].Metadata[TaskMetadataKey] != "REQ-001" || tasks[1].Metadata != nil {
+		t.Errorf("unexpected task metadata: %v, %v", tasks[0].Metadata, tasks[1].Metadata)
+	}
+
+	intent := models.Intent{Metadata: doc.Metadata()}
+	if restored := FromIntent(intent); restored == nil || restored.Requirements[0].Tasks[0] != "task_1" {
+		t.Errorf("expected the document restored from the intent, got %+v", restored)
+	}
+}
+
+func TestTraceByWordsAndCoverage(t *testing.T) {
+	doc := &Document{Name: "spec.md", Requirements: []Requirement{
+		{ID: "REQ-001", Text: "Orders are stored in PostgreSQL"},
+		{ID: "REQ-002", Text: "Customers receive order confirmation emails"},
+		{ID: "REQ-003", Text: "Admins can export reports as CSV"},
+	}}
+	tasks := []models.Task{
+		{ID: "task_1", Description: "Create the PostgreSQL schema for stored orders"},
+		{ID: "task_2", Description: "Send confirmation emails to customers"},
+		{ID: "task_3", Description: "Add order storage migrations"},
+	}
+	Trace(context.Background(), nil, doc, tasks)
+	if got := doc.Requirements[0].Tasks; len(got) != 2 || got[0] != "task_1" || got[1] != "task_3" {
+		t.Errorf("REQ-001 traced to %v", got)
+	}
+	if got := doc.Requirements[1].Tasks; len(got) != 1 || got[0] != "task_2" {
+		t.Errorf("REQ-002 traced to %v", got)
+	}
+	if got := doc.Requirements[2].Tasks; len(got) != 0 {
+		t.Errorf("REQ-003 traced to %v", got)
+	}
+
+	m := NewMatrix(doc, []packaging.TaskArtifact{
+		{TaskID: "task_1", Status: models.TaskStatusCompleted, Artifacts: []string{"tasks/task_1.txt"}},
+		{TaskID: "task_2", Status: models.TaskStatusCompleted},
+		{TaskID: "task_3", Status: models.TaskStatusFailed},
+	}, []packaging.QuantumDrop{{Tasks: []string{"task_2"}, Files: map[string]string{"mail/send.go": "", "mail/template.html": ""}}})
+
+	statuses := []Status{m.Rows[0].Status, m.Rows[1].Status, m.Rows[2].Status}
+	if statuses[0] != StatusPartial || statuses[1] != StatusCovered || statuses[2] != StatusUncovered {
+		t.Errorf("statuses = %v", statuses)
+	}
+	if strings.Join(m.Rows[0].Artifacts, ",") != "tasks/task_1.txt" || strings.Join(m.Rows[1].Artifacts, ",") != "mail/send.go,mail/template.html" {
+		t.Errorf("artifacts = %v, %v", m.Rows[0].Artifacts, m.Rows[1].Artifacts)
+	}
+	if m.Covered != 1 || m.Partial != 1 || m.Uncovered != 1 || m.Score() != 50 {
+		t.Errorf("matrix totals = %d/%d/%d, score %d", m.Covered, m.Partial, m.Uncovered, m.Score())
+	}
+}
@@ -0,0 +1,262 @@
+package requirements
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"QLP/internal/llm"
+	"QLP/internal/logger"
+	"QLP/internal/models"
+	"QLP/internal/packaging"
+	"go.uber.org/zap"
+)
+
+// TraceabilityFile is the capsule report tracing requirements to tasks and
+// artifacts
+const TraceabilityFile = "traceability.json"
+
+// TaskMetadataKey lists, comma-separated, the requirements a task implements
+// in its metadata
+const TaskMetadataKey = "requirements"
+
+// minOverlap is the share of a requirement's words a task description needs
+// to be traced to it without the model
+const minOverlap = 0.3
+
+// Trace maps each requirement to the tasks planned for it, asking the model
+// when client is set and matching their words otherwise or when the model
+// fails. The requirements each task implements are recorded in its metadata.
+func Trace(ctx context.Context, client llm.Client, doc *Document, tasks []models.Task) {
+	links := make(map[string][]string)
+	if client != nil {
+		var err error
+		if links, err = traceWithLLM(ctx, client, doc, tasks); err != nil {
+			logger.WithComponent("requirements").Warn("Failed to trace requirements with the model, matching their words",
+				zap.Error(err))
+		}
+	}
+	if len(links) == 0 {
+		links = traceByWords(doc, tasks)
+	}
+
+	byTask := make(map[string][]string)
+	for i := range doc.Requirements {
+		r := &doc.Requirements[i]
+		r.Tasks = links[r.ID]
+		for _, taskID := range r.Tasks {
+			byTask[taskID] = append(byTask[taskID], r.ID)
+		}
+	}
+	for i := range tasks {
+		if ids := byTask[tasks[i].ID]; len(ids) > 0 {
+			if tasks[i].Metadata == nil {
+				tasks[i].Metadata = make(map[string]string)
+			}
+			tasks[i].Metadata[TaskMetadataKey] = strings.Join(ids, ",")
+		}
+	}
+}
+
+func traceWithLLM(ctx context.Context, client llm.Client, doc *Document, tasks []models.Task) (map[string][]string, error) {
+	var b strings.Builder
+	b.WriteString("Requirements:\n")
+	for _, r := range doc.Requirements {
+		fmt.Fprintf(&b, "- %s: %s\n", r.ID, r.Text)
+	}
+	b.WriteString("\nTasks:\n")
+	known := make(map[string]bool)
+	for _, t := range tasks {
+		known[t.ID] = true
+		fmt.Fprintf(&b, "- %s (%s): %s\n", t.ID, t.Type, t.Description)
+	}
+	prompt := fmt.Sprintf(`A code generation system planned these tasks to implement these requirements.
+
+%s
+For every requirement, list the tasks whose output implements it, or none when no task does.
+
+Respond with JSON: {"links": [{"requirement": "<requirement ID>", "tasks": ["<task ID>", ...]}]}`, b.String())
+
+	var response struct {
+		Links []struct {
+			Requirement string   `json:"requirement"`
+			Tasks       []string `json:"tasks"`
+		} `json:"links"`
+	}
+	schema := llm.SchemaFor("requirement_trace", "Tasks implementing each requirement", response)
+	if err := llm.CompleteJSON(ctx, client, prompt, schema, &response); err != nil {
+		return nil, err
+	}
+	links := make(map[string][]string)
+	for _, l := range response.Links {
+		for _, taskID := range l.Tasks {
+			if known[taskID] && !contains(links[l.Requirement], taskID) {
+				links[l.Requirement] = append(links[l.Requirement], taskID)
+			}
+		}
+	}
+	return links, nil
+}
+
+// traceByWords links requirements to the tasks whose descriptions share
+// enough of their words, or to the closest task when none does
+func traceByWords(doc *Document, tasks []models.Task) map[string][]string {
+	taskWords := make([]map[string]bool, len(tasks))
+	for i, t := range tasks {
+		taskWords[i] = words(t.Description)
+	}
+	links := make(map[string][]string)
+	for _, r := range doc.Requirements {
+		required := words(r.Text)
+		if len(required) == 0 {
+			continue
+		}
+		best, bestScore := -1, 0.0
+		for i := range tasks {
+			shared := 0
+			for w := range required {
+				if taskWords[i][w] {
+					shared++
+				}
+			}
+			score := float64(shared) / float64(len(required))
+			if score >= minOverlap {
+				links[r.ID] = append(links[r.ID], tasks[i].ID)
+			}
+			if shared >= 2 && score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if len(links[r.ID]) == 0 && best >= 0 {
+			links[r.ID] = []string{tasks[best].ID}
+		}
+	}
+	return links
+}
+
+// ignored are words too common in requirements to link them to tasks
+var ignored = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "that": true, "this": true, "must": true,
+	"shall": true, "should": true, "could": true, "will": true, "can": true, "are": true, "from": true,
+	"into": true, "each": true, "all": true, "any": true, "their": true, "its": true, "have": true,
+	"has": true, "system": true, "user": true, "users": true, "able": true, "when": true, "need": true,
+	"needs": true, "using": true, "use": true, "be": true, "not": true, "per": true,
+}
+
+// words returns the distinctive words of a text, crudely stemmed
+func words(text string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(w) < 3 || ignored[w] {
+			continue
+		}
+		if len(w) > 4 {
+			w = strings.TrimSuffix(strings.TrimSuffix(w, "s"), "e")
+		}
+		set[w] = true
+	}
+	return set
+}
+
+// Status is how far a requirement is implemented
+type Status string
+
+const (
+	StatusCovered   Status = "covered"   // Every task planned for it completed
+	StatusPartial   Status = "partial"   // Some of its tasks completed
+	StatusUncovered Status = "uncovered" // No task was planned for it, or none completed
+)
+
+// Coverage is a row of the coverage matrix: a requirement, the tasks
+// planned for it and the files they generated
+type Coverage struct {
+	Requirement
+	Artifacts []string `json:"artifacts"`
+	Status    Status   `json:"status"`
+}
+
+// Matrix traces every requirement of a document to tasks and artifacts
+type Matrix struct {
+	Document  string     `json:"document"`
+	Kind      string     `json:"kind"`
+	Summary   string     `json:"summary,omitempty"`
+	Rows      []Coverage `json:"requirements"`
+	Covered   int        `json:"covered"`
+	Partial   int        `json:"partial"`
+	Uncovered int        `json:"uncovered"`
+}
+
+// NewMatrix builds the coverage matrix of a document from the outcome of
+// the capsule's tasks. Artifacts are the files of the drops the tasks
+// contributed to, or the task outputs when no drop holds them.
+func NewMatrix(doc *Document, tasks []packaging.TaskArtifact, drops []packaging.QuantumDrop) *Matrix {
+	byID := make(map[string]packaging.TaskArtifact)
+	for _, t := range tasks {
+		byID[t.TaskID] = t
+	}
+	files := make(map[string][]string)
+	for _, drop := range drops {
+		for _, taskID := range drop.Tasks {
+			for name := range drop.Files {
+				files[taskID] = append(files[taskID], name)
+			}
+		}
+	}
+
+	m := &Matrix{Document: doc.Name, Kind: doc.Kind, Summary: doc.Summary, Rows: make([]Coverage, 0, len(doc.Requirements))}
+	for _, r := range doc.Requirements {
+		row := Coverage{Requirement: r, Artifacts: []string{}, Status: StatusUncovered}
+		completed := 0
+		for _, taskID := range r.Tasks {
+			task, ok := byID[taskID]
+			if !ok || task.Status != models.TaskStatusCompleted {
+				continue
+			}
+			completed++
+			artifacts := files[taskID]
+			if len(artifacts) == 0 {
+				artifacts = task.Artifacts
+			}
+			for _, a := range artifacts {
+				if !contains(row.Artifacts, a) {
+					row.Artifacts = append(row.Artifacts, a)
+				}
+			}
+		}
+		sort.Strings(row.Artifacts)
+		switch {
+		case completed == 0:
+			m.Uncovered++
+		case completed < len(r.Tasks):
+			row.Status = StatusPartial
+			m.Partial++
+		default:
+			row.Status = StatusCovered
+			m.Covered++
+		}
+		m.Rows = append(m.Rows, row)
+	}
+	return m
+}
+
+// Score is the share of requirements implemented out of 100, partially
+// implemented ones counting half
+func (m *Matrix) Score() int {
+	if len(m.Rows) == 0 {
+		return 100
+	}
+	return (200*m.Covered + 100*m.Partial) / (2 * len(m.Rows))
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -1,10 +1,10 @@
 package sandbox
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
-	"strings"
 	"time"
 
 	"github.com/docker/docker/api/types"
@@ -12,6 +12,7 @@ import (
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 )
 
 type ContainerSandbox struct {
@@ -30,6 +31,13 @@ type SandboxConfig struct {
 	TimeoutSeconds int64
 	ReadOnly       bool
 	NoNetwork      bool
+	Platform       string // PlatformWindows for a Windows container, empty for Linux
+
+	// Set by a Lease to keep tenants apart
+	TenantID   string
+	Labels     map[string]string
+	ScratchDir string // Host directory mounted as WorkingDir instead of a tmpfs
+	Network    string // Tenant network to join instead of the default bridge
 }
 
 type ResourceLimits struct {
@@ -75,6 +83,9 @@ func (cs *ContainerSandbox) Execute(ctx context.Context, command []string, stdin
 	if err := cs.pullImage(ctx); err != nil {
 		return nil, fmt.Errorf("failed to pull image: %w", err)
 	}
+	if err := cs.ensureNetwork(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create tenant network: %w", err)
+	}
 
 	containerConfig := cs.buildContainerConfig(command)
 	hostConfig := cs.buildHostConfig()
@@ -136,6 +147,7 @@ func (cs *ContainerSandbox) buildContainerConfig(command []string) *container.Co
 		Cmd:          command,
 		Env:          cs.config.Environment,
 		WorkingDir:   cs.config.WorkingDir,
+		Labels:       cs.config.Labels,
 		AttachStdin:  true,
 		AttachStdout: true,
 		AttachStderr: true,
@@ -169,7 +181,15 @@ func (cs *ContainerSandbox) buildHostConfig() *container.HostConfig {
 		CapAdd:  []string{"CHOWN", "DAC_OVERRIDE", "FOWNER", "SETGID", "SETUID"},
 	}
 
-	if cs.config.WorkingDir != "" {
+	if cs.config.WorkingDir != "" && cs.config.ScratchDir != "" {
+		hostConfig.Mounts = []mount.Mount{
+			{
+				Type:   mount.TypeBind,
+				Source: cs.config.ScratchDir,
+				Target: cs.config.WorkingDir,
+			},
+		}
+	} else if cs.config.WorkingDir != "" {
 		hostConfig.Mounts = []mount.Mount{
 			{
 				Type:     mount.TypeTmpfs,
@@ -184,21 +204,74 @@ func (cs *ContainerSandbox) buildHostConfig() *container.HostConfig {
 
 	if cs.config.NoNetwork {
 		hostConfig.NetworkMode = "none"
+	} else if cs.config.Network != "" {
+		hostConfig.NetworkMode = container.NetworkMode(cs.config.Network)
+	}
+	if cs.isWindows() {
+		cs.windowsHostConfig(hostConfig)
 	}
 
 	return hostConfig
 }
 
+func (cs *ContainerSandbox) isWindows() bool {
+	return cs.config.Platform == PlatformWindows
+}
+
+// networkDisabled reports whether the sandbox runs without a network
+func (cs *ContainerSandbox) networkDisabled() bool {
+	return cs.config.NoNetwork || (cs.isWindows() && !cs.config.NetworkPolicy.AllowOutbound)
+}
+
 func (cs *ContainerSandbox) buildNetworkConfig() *network.NetworkingConfig {
-	if cs.config.NoNetwork {
+	if cs.networkDisabled() {
 		return &network.NetworkingConfig{}
 	}
 
+	name := "bridge"
+	if cs.isWindows() {
+		name = "nat"
+	}
+	if cs.config.Network != "" {
+		name = cs.config.Network
+	}
 	return &network.NetworkingConfig{
 		EndpointsConfig: map[string]*network.EndpointSettings{
-			"bridge": {},
+			name: {},
+		},
+	}
+}
+
+// ensureNetwork creates the tenant network the sandbox joins, if it does
+// not exist yet. Networks without outbound access are internal; Windows
+// hosts use nat networks.
+func (cs *ContainerSandbox) ensureNetwork(ctx context.Context) error {
+	if cs.networkDisabled() || cs.config.Network == "" {
+		return nil
+	}
+	driver := "bridge"
+	if cs.isWindows() {
+		driver = "nat"
+	}
+	_, err := cs.client.NetworkInspect(ctx, cs.config.Network, types.NetworkInspectOptions{})
+	if err == nil || !client.IsErrNotFound(err) {
+		return err
+	}
+	_, err = cs.client.NetworkCreate(ctx, cs.config.Network, types.NetworkCreate{
+		Driver:   driver,
+		Internal: !cs.config.NetworkPolicy.AllowOutbound,
+		Labels: map[string]string{
+			LabelManaged: "true",
+			LabelTenant:  cs.config.TenantID,
 		},
+	})
+	if err != nil {
+		// Another sandbox of the tenant may have created it meanwhile
+		if _, inspectErr := cs.client.NetworkInspect(ctx, cs.config.Network, types.NetworkInspectOptions{}); inspectErr == nil {
+			return nil
+		}
 	}
+	return err
 }
 
 func (cs *ContainerSandbox) writeStdin(ctx context.Context, stdin string) error {
@@ -266,19 +339,13 @@ func (cs *ContainerSandbox) getLogs(ctx context.Context) (string, string, error)
 	}
 	defer logs.Close()
 
-	content, err := io.ReadAll(logs)
-	if err != nil {
+	// Logs are multiplexed when the container has no TTY
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, logs); err != nil {
 		return "", "", err
 	}
 
-	logContent := string(content)
-	parts := strings.SplitN(logContent, "\n", 2)
-	
-	if len(parts) == 2 {
-		return parts[0], parts[1], nil
-	}
-	
-	return logContent, "", nil
+	return stdout.String(), stderr.String(), nil
 }
 
 func (cs *ContainerSandbox) collectMetrics(ctx context.Context) error {
@@ -0,0 +1,353 @@
+package sandbox
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"sort"
+	"strings"
+
+	"QLP/internal/audit"
+)
+
+// Ecosystem is a package manager whose lockfile the resolver can produce
+type Ecosystem string
+
+const (
+	EcosystemGo     Ecosystem = "go"
+	EcosystemNode   Ecosystem = "node"
+	EcosystemPython Ecosystem = "python"
+	EcosystemDotnet Ecosystem = "dotnet"
+	EcosystemMaven  Ecosystem = "maven"
+	EcosystemGradle Ecosystem = "gradle"
+)
+
+// Markers delimit the base64 tar of lockfiles in container output
+const (
+	lockfileBegin = "---QLP-LOCKFILES-BEGIN---"
+	lockfileEnd   = "---QLP-LOCKFILES-END---"
+)
+
+// DependencyProject is one manifest found in a file set
+type DependencyProject struct {
+	Ecosystem Ecosystem
+	Dir       string // directory of the manifest, relative to the file set root
+}
+
+// DependencyResolution holds the lockfiles produced for a file set
+type DependencyResolution struct {
+	Files  map[string]string // updated manifests and lockfiles, keyed by path
+	Errors map[string]string // project dir -> failure reason
+}
+
+// DependencyResolver runs each ecosystem's resolver in a sandbox container
+// to pin versions and produce lockfiles (go.sum, package-lock.json,
+// pinned requirements.txt, NuGet packages.lock.json)
+type DependencyResolver struct {
+	images         map[Ecosystem]string
+	timeoutSeconds int64
+	run            func(ctx context.Context, config *SandboxConfig, command []string, stdin string) (*ExecutionResult, error)
+}
+
+func NewDependencyResolver() *DependencyResolver {
+	return &DependencyResolver{
+		images: map[Ecosystem]string{
+			EcosystemGo:     "golang:1.21-alpine",
+			EcosystemNode:   "node:20-alpine",
+			EcosystemPython: "python:3.12-alpine",
+			EcosystemDotnet: "mcr.microsoft.com/dotnet/sdk:8.0",
+		},
+		timeoutSeconds: 600,
+		run:            runInContainer,
+	}
+}
+
+// runInContainer executes command in a fresh container built from config,
+// within the sandbox quota of the tenant in ctx
+func runInContainer(ctx context.Context, config *SandboxConfig, command []string, stdin string) (*ExecutionResult, error) {
+	lease, err := SharedIsolation().Acquire(ctx, audit.TenantFromContext(ctx), config.ResourceLimits)
+	if err != nil {
+		return nil, err
+	}
+	defer lease.Release()
+	lease.Apply(config)
+
+	sandbox, err := NewContainerSandbox(config)
+	if err != nil {
+		return nil, err
+	}
+	return sandbox.Execute(ctx, command, stdin)
+}
+
+// DetectDependencyProjects finds manifests in files that need a lockfile.
+// A .NET solution covers the projects beneath it; projects outside any
+// solution count on their own. Likewise a Maven parent pom.xml covers its
+// modules and a Gradle settings file covers the builds of its subprojects.
+func DetectDependencyProjects(files map[string]string) []DependencyProject {
+	var projects []DependencyProject
+	solutions := make(map[string]bool)
+	poms := make(map[string]bool)
+	gradleSettings := make(map[string]bool)
+	for p := range files {
+		switch base := path.Base(p); {
+		case strings.HasSuffix(base, ".sln"):
+			solutions[path.Dir(p)] = true
+		case base == "pom.xml":
+			poms[path.Dir(p)] = true
+		case base == "settings.gradle" || base == "settings.gradle.kts":
+			gradleSettings[path.Dir(p)] = true
+		}
+	}
+	dotnet := make(map[string]bool)
+	gradle := make(map[string]bool)
+	for p := range files {
+		dir := path.Dir(p)
+		switch base := path.Base(p); {
+		case isDotnetProjectFile(base) && !underRoot(solutions, dir):
+			dotnet[dir] = true
+		case (base == "build.gradle" || base == "build.gradle.kts") && !underRoot(gradleSettings, dir) && !poms[dir]:
+			gradle[dir] = true
+		}
+	}
+	for dir := range solutions {
+		dotnet[dir] = true
+	}
+	for dir := range gradleSettings {
+		if !poms[dir] {
+			gradle[dir] = true
+		}
+	}
+	for dir := range dotnet {
+		projects = append(projects, DependencyProject{Ecosystem: EcosystemDotnet, Dir: dir})
+	}
+	for dir := range gradle {
+		projects = append(projects, DependencyProject{Ecosystem: EcosystemGradle, Dir: dir})
+	}
+	for dir := range poms {
+		if dir == "." || !underRoot(poms, path.Dir(dir)) {
+			projects = append(projects, DependencyProject{Ecosystem: EcosystemMaven, Dir: dir})
+		}
+	}
+
+	for p := range files {
+		dir := path.Dir(p)
+		switch path.Base(p) {
+		case "go.mod":
+			projects = append(projects, DependencyProject{Ecosystem: EcosystemGo, Dir: dir})
+		case "package.json":
+			if !strings.Contains(p, "node_modules/") {
+				projects = append(projects, DependencyProject{Ecosystem: EcosystemNode, Dir: dir})
+			}
+		case "requirements.txt":
+			projects = append(projects, DependencyProject{Ecosystem: EcosystemPython, Dir: dir})
+		case "requirements.in":
+			// requirements.in is compiled into requirements.txt; count the directory once
+			if _, ok := files[path.Join(dir, "requirements.txt")]; !ok {
+				projects = append(projects, DependencyProject{Ecosystem: EcosystemPython, Dir: dir})
+			}
+		}
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		if projects[i].Dir != projects[j].Dir {
+			return projects[i].Dir < projects[j].Dir
+		}
+		return projects[i].Ecosystem < projects[j].Ecosystem
+	})
+	return projects
+}
+
+// isDotnetProjectFile reports whether name is a C#, F# or Visual Basic project
+func isDotnetProjectFile(name string) bool {
+	switch path.Ext(name) {
+	case ".csproj", ".fsproj", ".vbproj":
+		return true
+	}
+	return false
+}
+
+// underRoot reports whether dir is one of roots or beneath one
+func underRoot(roots map[string]bool, dir string) bool {
+	for {
+		if roots[dir] {
+			return true
+		}
+		if dir == "." || dir == "/" {
+			return false
+		}
+		dir = path.Dir(dir)
+	}
+}
+
+// Resolve produces lockfiles for every manifest in files. A failing project
+// is recorded in Errors and does not stop the others.
+func (dr *DependencyResolver) Resolve(ctx context.Context, files map[string]string) *DependencyResolution {
+	resolution := &DependencyResolution{
+		Files:  make(map[string]string),
+		Errors: make(map[string]string),
+	}
+
+	for _, project := range DetectDependencyProjects(files) {
+		// Maven and Gradle builds have no resolver: Spring Boot's
+		// dependency management already pins every starter version
+		if _, ok := dr.images[project.Ecosystem]; !ok {
+			continue
+		}
+		key := fmt.Sprintf("%s:%s", project.Ecosystem, project.Dir)
+		lockfiles, err := dr.resolveProject(ctx, project, files)
+		if err != nil {
+			log.Printf("⚠️ Dependency resolution failed for %s: %v", key, err)
+			resolution.Errors[key] = err.Error()
+			continue
+		}
+		for name, content := range lockfiles {
+			resolution.Files[path.Join(project.Dir, name)] = content
+		}
+		log.Printf("📌 Pinned %s dependencies in %s (%d files)", project.Ecosystem, project.Dir, len(lockfiles))
+	}
+	return resolution
+}
+
+func (dr *DependencyResolver) resolveProject(ctx context.Context, project DependencyProject, files map[string]string) (map[string]string, error) {
+	script, outputs := resolverScript(project.Ecosystem, files, project.Dir)
+
+	archive, err := tarProject(files, project.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive project: %w", err)
+	}
+
+	config := &SandboxConfig{
+		Image:       dr.images[project.Ecosystem],
+		WorkingDir:  "/workspace",
+		Environment: DefaultSandboxConfig().Environment,
+		ResourceLimits: ResourceLimits{
+			CPUQuota:   100000,
+			CPUPeriod:  100000,
+			Memory:     1024 * 1024 * 1024,
+			MemorySwap: 1024 * 1024 * 1024,
+			PidsLimit:  int64Ptr(512),
+			DiskQuota:  2048 * 1024 * 1024,
+		},
+		NetworkPolicy: NetworkPolicy{
+			AllowOutbound: true, // Resolvers download module metadata
+			BlockedPorts:  []string{"22", "23", "25"},
+		},
+		TimeoutSeconds: dr.timeoutSeconds,
+	}
+
+	command := []string{"sh", "-c", fmt.Sprintf(
+		"set -e; base64 -d | tar -x -C /workspace; cd /workspace; %s >&2; echo %s; tar -c %s 2>/dev/null | base64; echo %s",
+		script, lockfileBegin, strings.Join(outputs, " "), lockfileEnd)}
+
+	result, err := dr.run(ctx, config, command, base64.StdEncoding.EncodeToString(archive))
+	if err != nil {
+		return nil, err
+	}
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("resolver exited with %d: %s", result.ExitCode, lastLines(result.Stderr, 5))
+	}
+	return parseLockfileOutput(result.Stdout)
+}
+
+// resolverScript returns the shell command that pins dependencies for an
+// ecosystem and the files it produces
+func resolverScript(ecosystem Ecosystem, files map[string]string, dir string) (string, []string) {
+	switch ecosystem {
+	case EcosystemGo:
+		return "go mod tidy", []string{"go.mod", "go.sum"}
+	case EcosystemNode:
+		return "npm install --package-lock-only --ignore-scripts --no-audit --no-fund", []string{"package.json", "package-lock.json"}
+	case EcosystemDotnet:
+		// Every project of a solution gets its own packages.lock.json;
+		// Windows-only projects restore fine on Linux with Windows targeting
+		return "dotnet restore --use-lock-file -p:EnableWindowsTargeting=true", []string{"$(find . -name packages.lock.json)"}
+	default:
+		// Compile requirements.in when present, otherwise treat the loose
+		// requirements.txt as the input and replace it with pinned versions
+		input := "requirements.in"
+		if _, ok := files[path.Join(dir, "requirements.in")]; !ok {
+			input = "/tmp/requirements.in"
+			return "cp requirements.txt " + input + " && " + pipCompile(input), []string{"requirements.txt"}
+		}
+		return pipCompile(input), []string{"requirements.txt"}
+	}
+}
+
+func pipCompile(input string) string {
+	return "pip install --quiet pip-tools && pip-compile --quiet --generate-hashes --output-file requirements.txt " + input
+}
+
+// tarProject archives the files under dir with paths relative to dir
+func tarProject(files map[string]string, dir string) ([]byte, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	prefix := ""
+	if dir != "." {
+		prefix = dir + "/"
+	}
+	for p, content := range files {
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		header := &tar.Header{
+			Name: strings.TrimPrefix(p, prefix),
+			Mode: 0644,
+			Size: int64(len(content)),
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// parseLockfileOutput decodes the tar of lockfiles printed between the markers
+func parseLockfileOutput(stdout string) (map[string]string, error) {
+	start := strings.Index(stdout, lockfileBegin)
+	end := strings.LastIndex(stdout, lockfileEnd)
+	if start == -1 || end < start {
+		return nil, fmt.Errorf("resolver output did not contain lockfiles")
+	}
+	encoded := strings.Join(strings.Fields(stdout[start+len(lockfileBegin):end]), "")
+	archive, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode lockfiles: %w", err)
+	}
+
+	lockfiles := make(map[string]string)
+	tr := tar.NewReader(bytes.NewReader(archive))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read lockfiles: %w", err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		lockfiles[header.Name] = string(content)
+	}
+	return lockfiles, nil
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,110 @@
+package sandbox
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestDetectDependencyProjects(t *testing.T) {
+	files := map[string]string{
+		"go.mod":                          "module shop\n",
+		"web/package.json":                "{}",
+		"web/node_modules/x/package.json": "{}",
+		"worker/requirements.txt":         "requests\n",
+		"worker/requirements.in":          "requests\n",
+	}
+
+	projects := DetectDependencyProjects(files)
+	if len(projects) != 3 {
+		t.Fatalf("expected 3 projects, got %+v", projects)
+	}
+	if projects[0] != (DependencyProject{Ecosystem: EcosystemGo, Dir: "."}) {
+		t.Errorf("unexpected first project %+v", projects[0])
+	}
+}
+
+func TestDetectDotnetProjects(t *testing.T) {
+	files := map[string]string{
+		"Shop.sln":                      "",
+		"src/Shop/Shop.csproj":          "<Project />",
+		"tests/Shop.Tests/Tests.fsproj": "<Project />",
+		"tools/Lint/Lint.vbproj":        "<Project />",
+	}
+
+	projects := DetectDependencyProjects(files)
+	if len(projects) != 1 || projects[0] != (DependencyProject{Ecosystem: EcosystemDotnet, Dir: "."}) {
+		t.Fatalf("expected the solution to cover its projects, got %+v", projects)
+	}
+
+	delete(files, "Shop.sln")
+	projects = DetectDependencyProjects(files)
+	if len(projects) != 3 {
+		t.Fatalf("expected each project without a solution, got %+v", projects)
+	}
+}
+
+func TestDetectJavaProjects(t *testing.T) {
+	files := map[string]string{
+		"pom.xml":                 "<project />",
+		"api/pom.xml":             "<project />",
+		"core/pom.xml":            "<project />",
+		"tools/settings.gradle":   "include 'lint'",
+		"tools/build.gradle":      "",
+		"tools/lint/build.gradle": "",
+		"batch/build.gradle.kts":  "",
+	}
+
+	want := []DependencyProject{
+		{Ecosystem: EcosystemMaven, Dir: "."},
+		{Ecosystem: EcosystemGradle, Dir: "batch"},
+		{Ecosystem: EcosystemGradle, Dir: "tools"},
+	}
+	projects := DetectDependencyProjects(files)
+	if len(projects) != len(want) {
+		t.Fatalf("expected parent pom and Gradle roots, got %+v", projects)
+	}
+	for i := range want {
+		if projects[i] != want[i] {
+			t.Errorf("project %d = %+v, want %+v", i, projects[i], want[i])
+		}
+	}
+
+	// Java builds have no resolver and are left alone
+	resolver := NewDependencyResolver()
+	resolver.run = func(ctx context.Context, config *SandboxConfig, command []string, stdin string) (*ExecutionResult, error) {
+		t.Errorf("resolver ran for %s", config.Image)
+		return &ExecutionResult{}, nil
+	}
+	if resolution := resolver.Resolve(context.Background(), files); len(resolution.Errors)+len(resolution.Files) != 0 {
+		t.Errorf("unexpected resolution %+v", resolution)
+	}
+}
+
+func TestResolveCollectsLockfiles(t *testing.T) {
+	resolver := NewDependencyResolver()
+	resolver.run = func(ctx context.Context, config *SandboxConfig, command []string, stdin string) (*ExecutionResult, error) {
+		if config.Image != "golang:1.21-alpine" || !strings.Contains(command[2], "go mod tidy") {
+			t.Errorf("unexpected invocation %s %v", config.Image, command)
+		}
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		content := "example.com/dep v1.0.0 h1:abc=\n"
+		tw.WriteHeader(&tar.Header{Name: "go.sum", Mode: 0644, Size: int64(len(content))})
+		tw.Write([]byte(content))
+		tw.Close()
+		encoded := base64.StdEncoding.EncodeToString(buf.Bytes())
+		return &ExecutionResult{Stdout: lockfileBegin + "\n" + encoded[:10] + "\n" + encoded[10:] + "\n" + lockfileEnd + "\n"}, nil
+	}
+
+	resolution := resolver.Resolve(context.Background(), map[string]string{"svc/go.mod": "module svc\n"})
+	if len(resolution.Errors) != 0 {
+		t.Fatalf("unexpected errors %v", resolution.Errors)
+	}
+	if !strings.Contains(resolution.Files["svc/go.sum"], "example.com/dep") {
+		t.Errorf("expected go.sum for svc, got %v", resolution.Files)
+	}
+}
@@ -4,28 +4,40 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"path"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 
+	"QLP/internal/audit"
+	"QLP/internal/capabilities"
 	"QLP/internal/models"
+	"QLP/internal/stacks"
 )
 
+// javaSandboxImage builds Java output with Maven
+const javaSandboxImage = "maven:3.9-eclipse-temurin-21"
+
 type SandboxedExecutor struct {
 	defaultConfig *SandboxConfig
+	isolation     *Isolation
 }
 
 func NewSandboxedExecutor() *SandboxedExecutor {
 	return &SandboxedExecutor{
 		defaultConfig: DefaultSandboxConfig(),
+		isolation:     SharedIsolation(),
 	}
 }
 
+// Execute runs the commands in the agent output in sandbox containers of
+// the tenant in ctx, within the tenant's quota. The commands share a
+// scratch directory, removed when they finish.
 func (se *SandboxedExecutor) Execute(ctx context.Context, task models.Task, agentOutput string) (*SandboxExecutionResult, error) {
 	config := se.buildTaskSpecificConfig(task)
-	
-	sandbox, err := NewContainerSandbox(config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create sandbox: %w", err)
+	if isCodeTask(task.Type) && isJavaOutput(agentOutput) {
+		config.Image = javaSandboxImage
 	}
 
 	commands := se.parseAgentOutputToCommands(task, agentOutput)
@@ -40,7 +52,19 @@ func (se *SandboxedExecutor) Execute(ctx context.Context, task models.Task, agen
 		}, nil
 	}
 
-	log.Printf("Executing %d commands in sandbox for task %s", len(commands), task.ID)
+	lease, err := se.isolation.Acquire(ctx, audit.TenantFromContext(ctx), config.ResourceLimits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire sandbox: %w", err)
+	}
+	defer lease.Release()
+	lease.Apply(config)
+
+	sandbox, err := NewContainerSandbox(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sandbox: %w", err)
+	}
+
+	log.Printf("Executing %d commands in sandbox %s of tenant %s for task %s", len(commands), lease.ExecutionID, lease.TenantID, task.ID)
 
 	var results []CommandResult
 	var totalDuration time.Duration
@@ -81,6 +105,19 @@ func (se *SandboxedExecutor) Execute(ctx context.Context, task models.Task, agen
 		if se.detectSuspiciousActivity(result) {
 			securityScore -= 20
 		}
+
+		// A bind-mounted scratch directory has no size limit of its own
+		if quota := config.ResourceLimits.DiskQuota; quota > 0 && lease.ScratchBytes() > quota {
+			return &SandboxExecutionResult{
+				TaskID:        task.ID,
+				Success:       false,
+				Output:        se.aggregateOutput(results),
+				ExecutionTime: totalDuration,
+				SecurityScore: securityScore,
+				Message:       fmt.Sprintf("Sandbox disk quota of %d bytes exceeded at command %d", quota, i+1),
+				Results:       results,
+			}, nil
+		}
 	}
 
 	success := len(results) > 0 && results[len(results)-1].ExitCode == 0
@@ -109,6 +146,16 @@ func (se *SandboxedExecutor) buildTaskSpecificConfig(task models.Task) *SandboxC
 		NoNetwork:      se.shouldDisableNetwork(task.Type),
 	}
 
+	// Resource needs declared by the agent type override the task type defaults
+	resources := capabilities.TaskResources(task)
+	if resources.CPU > 0 {
+		config.ResourceLimits.CPUQuota = int64(resources.CPU * float64(config.ResourceLimits.CPUPeriod))
+	}
+	if resources.MemoryMB > 0 {
+		config.ResourceLimits.Memory = int64(resources.MemoryMB) * 1024 * 1024
+		config.ResourceLimits.MemorySwap = config.ResourceLimits.Memory
+	}
+
 	return config
 }
 
@@ -193,7 +240,7 @@ func (se *SandboxedExecutor) getNetworkPolicyForTaskType(taskType models.TaskTyp
 	case models.TaskTypeCodegen, models.TaskTypeTest:
 		return NetworkPolicy{
 			AllowOutbound: true, // Need to download dependencies
-			AllowedHosts:  []string{"proxy.golang.org", "sum.golang.org", "github.com"},
+			AllowedHosts:  []string{"proxy.golang.org", "sum.golang.org", "github.com", "repo.maven.apache.org"},
 			BlockedPorts:  []string{"22", "23", "25"},
 		}
 	case models.TaskTypeInfra:
@@ -240,7 +287,11 @@ func (se *SandboxedExecutor) parseAgentOutputToCommands(task models.Task, output
 
 	switch task.Type {
 	case models.TaskTypeCodegen, models.TaskTypeTest:
-		commands = se.parseGoCommands(output)
+		if isJavaOutput(output) {
+			commands = se.parseJavaCommands(output)
+		} else {
+			commands = se.parseGoCommands(output)
+		}
 	case models.TaskTypeInfra:
 		commands = se.parseInfraCommands(output)
 	case models.TaskTypeDoc:
@@ -303,6 +354,59 @@ func (se *SandboxedExecutor) parseGoCommands(output string) []SandboxCommand {
 	return commands
 }
 
+var javaTypeBlock = regexp.MustCompile(`(?m)^\s*(public\s+)?((final|abstract|sealed)\s+)*(class|interface|enum|record)\s+\w+`)
+
+func isCodeTask(taskType models.TaskType) bool {
+	return taskType == models.TaskTypeCodegen || taskType == models.TaskTypeTest
+}
+
+// isJavaOutput reports whether agent output holds Java sources rather than Go
+func isJavaOutput(output string) bool {
+	return strings.Contains(output, "```java") || strings.Contains(output, "@SpringBootApplication")
+}
+
+// parseJavaCommands writes the Java sources in the Maven layout, with the
+// pom.xml from the output or a scaffolded Spring Boot one, and builds and
+// tests them with Maven
+func (se *SandboxedExecutor) parseJavaCommands(output string) []SandboxCommand {
+	files := make(map[string]string)
+	for i, block := range extractCodeBlocks(output, "java") {
+		switch {
+		case strings.Contains(block, "<project"):
+			files["pom.xml"] = block
+		case javaTypeBlock.MatchString(block):
+			files[stacks.JavaSourcePath(fmt.Sprintf("Source%d.java", i), block)] = block
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	for p, content := range stacks.ScaffoldJava(files, "sandbox", stacks.BuildMaven) {
+		if p != "Dockerfile" {
+			files[p] = content
+		}
+	}
+
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var commands []SandboxCommand
+	for _, p := range paths {
+		commands = append(commands, SandboxCommand{
+			Command:     []string{"sh", "-c", fmt.Sprintf("mkdir -p %s && cat > %s", path.Dir(p), p)},
+			Stdin:       files[p],
+			Description: fmt.Sprintf("Create %s", p),
+		})
+	}
+	return append(commands, SandboxCommand{
+		Command:     []string{"mvn", "-q", "-B", "package"},
+		Description: "Build and test with Maven",
+	})
+}
+
 func (se *SandboxedExecutor) parseInfraCommands(output string) []SandboxCommand {
 	var commands []SandboxCommand
 
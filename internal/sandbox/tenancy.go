@@ -0,0 +1,371 @@
+package sandbox
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"QLP/internal/config"
+	"QLP/internal/metering"
+)
+
+// Labels put on sandbox containers and networks, so they can be listed and
+// cleaned up per tenant with docker ps --filter label=...
+const (
+	LabelManaged   = "qlp.sandbox"
+	LabelTenant    = "qlp.tenant"
+	LabelExecution = "qlp.execution"
+)
+
+// defaultTenant runs executions that carry no tenant
+const defaultTenant = "default"
+
+// ErrQuotaExceeded is returned for executions that need more than their
+// tenant's whole quota, so waiting would never let them run
+var ErrQuotaExceeded = errors.New("sandbox execution exceeds tenant quota")
+
+// TenantQuota bounds what one tenant's sandboxes use at the same time. Zero
+// fields are unlimited.
+type TenantQuota struct {
+	MaxConcurrent int     `json:"max_concurrent"`
+	CPU           float64 `json:"cpu"` // Cores across the tenant's running sandboxes
+	MemoryMB      int64   `json:"memory_mb"`
+}
+
+// TenantUsage is what a tenant's running sandboxes hold
+type TenantUsage struct {
+	Running  int     `json:"running"`
+	CPU      float64 `json:"cpu"`
+	MemoryMB int64   `json:"memory_mb"`
+}
+
+// Isolation keeps tenants' sandboxes apart: executions wait for room in
+// their tenant's quota, and each gets labels naming the tenant, a scratch
+// directory of its own and the tenant's private Docker network.
+type Isolation struct {
+	quotas      map[string]TenantQuota // Keyed by tenant ID, "*" is the fallback
+	scratchRoot string                 // Tmpfs working directories when empty
+
+	mu      sync.Mutex
+	usage   map[string]*TenantUsage
+	changed chan struct{} // Closed and replaced whenever usage drops
+}
+
+// NewIsolation creates an isolation manager with per-tenant quotas, "*"
+// applying to tenants not listed. Scratch directories are created under
+// scratchRoot; without one, sandboxes work in a tmpfs.
+func NewIsolation(quotas map[string]TenantQuota, scratchRoot string) (*Isolation, error) {
+	if scratchRoot != "" {
+		abs, err := filepath.Abs(scratchRoot)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sandbox scratch directory: %w", err)
+		}
+		if err := os.MkdirAll(abs, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create sandbox scratch directory: %w", err)
+		}
+		scratchRoot = abs
+	}
+	if quotas == nil {
+		quotas = map[string]TenantQuota{}
+	}
+	return &Isolation{
+		quotas:      quotas,
+		scratchRoot: scratchRoot,
+		usage:       make(map[string]*TenantUsage),
+		changed:     make(chan struct{}),
+	}, nil
+}
+
+// NewIsolationFromEnv configures isolation from the environment:
+//
+//	QLP_SANDBOX_TENANT_MAX_CONCURRENT  sandboxes a tenant runs at once (default 4)
+//	QLP_SANDBOX_TENANT_CPU             cores across them (default 4)
+//	QLP_SANDBOX_TENANT_MEMORY_MB       memory across them (default 8192)
+//	QLP_SANDBOX_TENANT_QUOTAS_FILE     JSON quotas keyed by tenant ID, overriding the above
+//	QLP_SANDBOX_SCRATCH_DIR            root of the scratch directories (default ./data/sandbox, empty for tmpfs)
+func NewIsolationFromEnv() (*Isolation, error) {
+	var fallback TenantQuota
+	var err error
+	if fallback.MaxConcurrent, err = strconv.Atoi(config.GetEnvOrDefault("QLP_SANDBOX_TENANT_MAX_CONCURRENT", "4")); err != nil {
+		return nil, fmt.Errorf("invalid QLP_SANDBOX_TENANT_MAX_CONCURRENT: %w", err)
+	}
+	if fallback.CPU, err = strconv.ParseFloat(config.GetEnvOrDefault("QLP_SANDBOX_TENANT_CPU", "4"), 64); err != nil {
+		return nil, fmt.Errorf("invalid QLP_SANDBOX_TENANT_CPU: %w", err)
+	}
+	if fallback.MemoryMB, err = strconv.ParseInt(config.GetEnvOrDefault("QLP_SANDBOX_TENANT_MEMORY_MB", "8192"), 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid QLP_SANDBOX_TENANT_MEMORY_MB: %w", err)
+	}
+
+	quotas := map[string]TenantQuota{}
+	if path := config.GetEnvOrDefault("QLP_SANDBOX_TENANT_QUOTAS_FILE", ""); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sandbox quotas: %w", err)
+		}
+		if err := json.Unmarshal(data, &quotas); err != nil {
+			return nil, fmt.Errorf("failed to parse sandbox quotas: %w", err)
+		}
+	}
+	if _, ok := quotas["*"]; !ok {
+		quotas["*"] = fallback
+	}
+	return NewIsolation(quotas, config.GetEnvOrDefault("QLP_SANDBOX_SCRATCH_DIR", "./data/sandbox"))
+}
+
+var (
+	sharedOnce      sync.Once
+	sharedIsolation *Isolation
+)
+
+// SharedIsolation returns the isolation manager every sandbox in the
+// process goes through, configured from the environment on first use
+func SharedIsolation() *Isolation {
+	sharedOnce.Do(func() {
+		iso, err := NewIsolationFromEnv()
+		if err != nil {
+			log.Printf("⚠️ Invalid sandbox isolation settings, using tmpfs scratch and default quotas: %v", err)
+			iso, _ = NewIsolation(map[string]TenantQuota{"*": {MaxConcurrent: 4, CPU: 4, MemoryMB: 8192}}, "")
+		}
+		sharedIsolation = iso
+	})
+	return sharedIsolation
+}
+
+// Quota returns the quota that applies to a tenant
+func (iso *Isolation) Quota(tenantID string) TenantQuota {
+	iso.mu.Lock()
+	defer iso.mu.Unlock()
+	return iso.quota(tenantID)
+}
+
+// quota looks up a tenant's quota; iso.mu must be held
+func (iso *Isolation) quota(tenantID string) TenantQuota {
+	if q, ok := iso.quotas[tenantID]; ok {
+		return q
+	}
+	return iso.quotas["*"]
+}
+
+// SetQuota sets a tenant's quota, overriding the configured ones. Waiting
+// executions are rechecked against it.
+func (iso *Isolation) SetQuota(tenantID string, quota TenantQuota) {
+	iso.mu.Lock()
+	defer iso.mu.Unlock()
+	quotas := make(map[string]TenantQuota, len(iso.quotas)+1)
+	for id, q := range iso.quotas {
+		quotas[id] = q
+	}
+	quotas[tenantID] = quota
+	iso.quotas = quotas
+	close(iso.changed)
+	iso.changed = make(chan struct{})
+}
+
+// Usage returns what each tenant's running sandboxes hold
+func (iso *Isolation) Usage() map[string]TenantUsage {
+	iso.mu.Lock()
+	defer iso.mu.Unlock()
+	usage := make(map[string]TenantUsage, len(iso.usage))
+	for tenantID, u := range iso.usage {
+		usage[tenantID] = *u
+	}
+	return usage
+}
+
+// Acquire reserves room for an execution with the given limits in its
+// tenant's quota, waiting for the tenant's other sandboxes to finish when
+// there is none. The lease must be released when the execution ends.
+func (iso *Isolation) Acquire(ctx context.Context, tenantID string, limits ResourceLimits) (*Lease, error) {
+	if tenantID == "" {
+		tenantID = defaultTenant
+	}
+	cpu, memoryMB := limitsCPU(limits), limits.Memory/(1024*1024)
+	quota := iso.Quota(tenantID)
+	if (quota.CPU > 0 && cpu > quota.CPU) || (quota.MemoryMB > 0 && memoryMB > quota.MemoryMB) {
+		return nil, fmt.Errorf("%w: %s needs %.2f CPU and %d MB, its quota is %.2f CPU and %d MB",
+			ErrQuotaExceeded, tenantID, cpu, memoryMB, quota.CPU, quota.MemoryMB)
+	}
+
+	for {
+		iso.mu.Lock()
+		quota = iso.quota(tenantID)
+		u := iso.usage[tenantID]
+		if u == nil {
+			u = &TenantUsage{}
+			iso.usage[tenantID] = u
+		}
+		if fits(quota, u, cpu, memoryMB) {
+			u.Running++
+			u.CPU += cpu
+			u.MemoryMB += memoryMB
+			iso.mu.Unlock()
+			break
+		}
+		changed := iso.changed
+		iso.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for sandbox quota of tenant %s: %w", tenantID, ctx.Err())
+		}
+	}
+
+	lease := &Lease{
+		TenantID:    tenantID,
+		ExecutionID: fmt.Sprintf("SBX-%d", time.Now().UnixNano()),
+		iso:         iso,
+		cpu:         cpu,
+		memoryMB:    memoryMB,
+		started:     time.Now(),
+	}
+	if iso.scratchRoot != "" {
+		lease.ScratchDir = filepath.Join(iso.scratchRoot, tenantSlug(tenantID), lease.ExecutionID)
+		if err := os.MkdirAll(lease.ScratchDir, 0700); err != nil {
+			lease.Release()
+			return nil, fmt.Errorf("failed to create sandbox scratch directory: %w", err)
+		}
+	}
+	return lease, nil
+}
+
+func fits(quota TenantQuota, u *TenantUsage, cpu float64, memoryMB int64) bool {
+	if quota.MaxConcurrent > 0 && u.Running >= quota.MaxConcurrent {
+		return false
+	}
+	if quota.CPU > 0 && u.CPU+cpu > quota.CPU+1e-9 {
+		return false
+	}
+	return quota.MemoryMB <= 0 || u.MemoryMB+memoryMB <= quota.MemoryMB
+}
+
+// release returns a lease's share of the quota and wakes the waiters
+func (iso *Isolation) release(l *Lease) {
+	iso.mu.Lock()
+	defer iso.mu.Unlock()
+	if u := iso.usage[l.TenantID]; u != nil {
+		u.Running--
+		u.CPU -= l.cpu
+		u.MemoryMB -= l.memoryMB
+		if u.Running <= 0 {
+			delete(iso.usage, l.TenantID)
+		}
+	}
+	close(iso.changed)
+	iso.changed = make(chan struct{})
+}
+
+// Lease is one execution's share of its tenant's quota
+type Lease struct {
+	TenantID    string
+	ExecutionID string
+	ScratchDir  string // Host directory mounted as the working directory, "" for tmpfs
+
+	iso      *Isolation
+	cpu      float64
+	memoryMB int64
+	started  time.Time
+	once     sync.Once
+}
+
+// Apply labels a sandbox with the lease's tenant and execution, mounts its
+// scratch directory and puts it on the tenant's network
+func (l *Lease) Apply(config *SandboxConfig) {
+	config.TenantID = l.TenantID
+	config.Labels = map[string]string{
+		LabelManaged:   "true",
+		LabelTenant:    l.TenantID,
+		LabelExecution: l.ExecutionID,
+	}
+	config.ScratchDir = l.ScratchDir
+	if !config.NoNetwork {
+		config.Network = TenantNetwork(l.TenantID, !config.NetworkPolicy.AllowOutbound)
+	}
+}
+
+// ScratchBytes returns how much the execution has written to its scratch
+// directory
+func (l *Lease) ScratchBytes() int64 {
+	if l.ScratchDir == "" {
+		return 0
+	}
+	var total int64
+	filepath.WalkDir(l.ScratchDir, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
+
+// Release frees the lease's quota and removes its scratch directory, and
+// meters the CPU-seconds the execution held: its cores, one when unlimited,
+// for as long as it ran. It is safe to call more than once.
+func (l *Lease) Release() {
+	l.once.Do(func() {
+		cores := l.cpu
+		if cores <= 0 {
+			cores = 1
+		}
+		metering.Record(context.Background(), l.TenantID, metering.SandboxCPUSeconds, cores*time.Since(l.started).Seconds())
+
+		if l.ScratchDir != "" {
+			if err := os.RemoveAll(l.ScratchDir); err != nil {
+				log.Printf("⚠️ Failed to remove sandbox scratch directory %s: %v", l.ScratchDir, err)
+			}
+		}
+		l.iso.release(l)
+	})
+}
+
+// TenantNetwork names the Docker network a tenant's sandboxes share, apart
+// from every other tenant's. Internal networks have no outbound access.
+func TenantNetwork(tenantID string, internal bool) string {
+	name := "qlp-sandbox-" + tenantSlug(tenantID)
+	if internal {
+		name += "-internal"
+	}
+	return name
+}
+
+// tenantSlug makes a tenant ID safe for Docker names and paths; the hash
+// keeps IDs that differ only in replaced characters apart
+func tenantSlug(tenantID string) string {
+	slug := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return '-'
+		}
+	}, tenantID)
+	if len(slug) > 32 {
+		slug = slug[:32]
+	}
+	sum := sha256.Sum256([]byte(tenantID))
+	return slug + "-" + hex.EncodeToString(sum[:4])
+}
+
+// limitsCPU is the number of cores the limits allow
+func limitsCPU(limits ResourceLimits) float64 {
+	if limits.CPUQuota <= 0 || limits.CPUPeriod <= 0 {
+		return 0
+	}
+	return float64(limits.CPUQuota) / float64(limits.CPUPeriod)
+}
@@ -0,0 +1,151 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/mount"
+)
+
+func oneCPU() ResourceLimits {
+	return ResourceLimits{CPUQuota: 100000, CPUPeriod: 100000, Memory: 1024 * 1024 * 1024}
+}
+
+func TestAcquireWaitsForTenantQuota(t *testing.T) {
+	iso, err := NewIsolation(map[string]TenantQuota{"*": {MaxConcurrent: 1}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	first, err := iso.Acquire(ctx, "acme", oneCPU())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another tenant is not held up by acme
+	other, err := iso.Acquire(ctx, "globex", oneCPU())
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Release()
+
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := iso.Acquire(short, "acme", oneCPU()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second acme execution acquired while the first runs: %v", err)
+	}
+
+	acquired := make(chan *Lease)
+	go func() {
+		lease, err := iso.Acquire(ctx, "acme", oneCPU())
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- lease
+	}()
+	first.Release()
+	first.Release() // Releasing twice must not free the quota twice
+	select {
+	case lease := <-acquired:
+		if u := iso.Usage()["acme"]; u.Running != 1 || u.CPU != 1 || u.MemoryMB != 1024 {
+			t.Errorf("usage = %+v", u)
+		}
+		lease.Release()
+	case <-time.After(time.Second):
+		t.Fatal("waiting execution never acquired the released quota")
+	}
+	if len(iso.Usage()) != 0 {
+		t.Errorf("usage after release = %v", iso.Usage())
+	}
+}
+
+func TestAcquireEnforcesCPUAndMemory(t *testing.T) {
+	iso, err := NewIsolation(map[string]TenantQuota{
+		"*":    {CPU: 1.5, MemoryMB: 4096},
+		"acme": {CPU: 4, MemoryMB: 512},
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := iso.Acquire(ctx, "acme", oneCPU()); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("acme 1 GB execution error = %v, want ErrQuotaExceeded", err)
+	}
+	first, err := iso.Acquire(ctx, "globex", oneCPU())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Release()
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := iso.Acquire(short, "globex", oneCPU()); err == nil {
+		t.Error("globex ran 2 CPUs against a quota of 1.5")
+	}
+}
+
+func TestLeaseIsolatesScratchAndNetwork(t *testing.T) {
+	root := t.TempDir()
+	iso, err := NewIsolation(nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	acme, err := iso.Acquire(ctx, "acme", oneCPU())
+	if err != nil {
+		t.Fatal(err)
+	}
+	globex, err := iso.Acquire(ctx, "globex", oneCPU())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer globex.Release()
+
+	if acme.ScratchDir == globex.ScratchDir || !strings.HasPrefix(acme.ScratchDir, root) {
+		t.Fatalf("scratch dirs %s and %s", acme.ScratchDir, globex.ScratchDir)
+	}
+	os.WriteFile(filepath.Join(acme.ScratchDir, "out.bin"), make([]byte, 100), 0644)
+	if acme.ScratchBytes() != 100 {
+		t.Errorf("ScratchBytes = %d", acme.ScratchBytes())
+	}
+
+	config := &SandboxConfig{WorkingDir: "/workspace", NetworkPolicy: NetworkPolicy{AllowOutbound: true}}
+	acme.Apply(config)
+	cs := &ContainerSandbox{config: config}
+	if cs.buildContainerConfig(nil).Labels[LabelTenant] != "acme" {
+		t.Errorf("labels = %v", config.Labels)
+	}
+	host := cs.buildHostConfig()
+	if len(host.Mounts) != 1 || host.Mounts[0].Type != mount.TypeBind || host.Mounts[0].Source != acme.ScratchDir {
+		t.Errorf("mounts = %+v", host.Mounts)
+	}
+	if string(host.NetworkMode) != TenantNetwork("acme", false) {
+		t.Errorf("network mode = %s", host.NetworkMode)
+	}
+	if _, ok := cs.buildNetworkConfig().EndpointsConfig[TenantNetwork("acme", false)]; !ok {
+		t.Error("sandbox does not join the tenant network")
+	}
+
+	acme.Release()
+	if _, err := os.Stat(acme.ScratchDir); !os.IsNotExist(err) {
+		t.Errorf("scratch dir left after release: %v", err)
+	}
+}
+
+func TestTenantNetworkNames(t *testing.T) {
+	if TenantNetwork("Acme Corp", false) == TenantNetwork("acme-corp", false) {
+		t.Error("tenants differing in replaced characters share a network")
+	}
+	if TenantNetwork("acme", true) == TenantNetwork("acme", false) {
+		t.Error("internal and outbound networks are the same")
+	}
+	name := TenantNetwork("../../etc", false)
+	if strings.ContainsAny(name, "./ ") {
+		t.Errorf("unsafe network name %q", name)
+	}
+}
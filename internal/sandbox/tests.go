@@ -0,0 +1,292 @@
+package sandbox
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TestRun is the outcome of running one project's test suite
+type TestRun struct {
+	Ecosystem Ecosystem `json:"ecosystem"`
+	Dir       string    `json:"dir"`
+	Passed    int       `json:"passed"`
+	Failed    int       `json:"failed"`
+	Coverage  float64   `json:"coverage"` // percent of statements or lines, -1 when not reported
+	Output    string    `json:"output,omitempty"`
+	Error     string    `json:"error,omitempty"` // the suite could not run
+}
+
+// PassRate is the percentage of tests that passed
+func (r TestRun) PassRate() float64 {
+	if r.Passed+r.Failed == 0 {
+		return 0
+	}
+	return float64(r.Passed) * 100 / float64(r.Passed+r.Failed)
+}
+
+// TestRunner runs the test suites of a file set in sandbox containers: go
+// test, pytest, jest, dotnet test and JUnit through Maven or Gradle, with
+// coverage. .NET projects that
+// target Windows run in Windows containers when the host supports them.
+type TestRunner struct {
+	images         map[Ecosystem]string
+	windowsImages  map[Ecosystem]string
+	timeoutSeconds int64
+	run            func(ctx context.Context, config *SandboxConfig, command []string, stdin string) (*ExecutionResult, error)
+	windows        func(ctx context.Context) bool
+}
+
+func NewTestRunner() *TestRunner {
+	return &TestRunner{
+		images: map[Ecosystem]string{
+			EcosystemGo:     "golang:1.21",
+			EcosystemNode:   "node:20-alpine",
+			EcosystemPython: "python:3.12-slim",
+			EcosystemDotnet: "mcr.microsoft.com/dotnet/sdk:8.0",
+			EcosystemMaven:  "maven:3.9-eclipse-temurin-21",
+			EcosystemGradle: "gradle:8-jdk21",
+		},
+		windowsImages: map[Ecosystem]string{
+			EcosystemDotnet: "mcr.microsoft.com/dotnet/sdk:8.0-windowsservercore-ltsc2022",
+		},
+		timeoutSeconds: 600,
+		run:            runInContainer,
+		windows:        WindowsSupported,
+	}
+}
+
+// Run runs the tests of every project in files. Python projects are found by
+// requirements.txt, Node projects by package.json, Go modules by go.mod,
+// .NET projects by their solution or project files and Java projects by
+// pom.xml or their Gradle build.
+func (tr *TestRunner) Run(ctx context.Context, files map[string]string) []TestRun {
+	var runs []TestRun
+	for _, project := range DetectDependencyProjects(files) {
+		run := TestRun{Ecosystem: project.Ecosystem, Dir: project.Dir, Coverage: -1}
+		if err := tr.runProject(ctx, project, files, &run); err != nil {
+			log.Printf("⚠️ Tests could not run for %s:%s: %v", project.Ecosystem, project.Dir, err)
+			run.Error = err.Error()
+		}
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+func (tr *TestRunner) runProject(ctx context.Context, project DependencyProject, files map[string]string, run *TestRun) error {
+	archive, err := tarProject(files, project.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to archive project: %w", err)
+	}
+
+	config := &SandboxConfig{
+		Image:       tr.images[project.Ecosystem],
+		WorkingDir:  "/workspace",
+		Environment: append(DefaultSandboxConfig().Environment, "CI=true"),
+		ResourceLimits: ResourceLimits{
+			CPUQuota:   200000,
+			CPUPeriod:  100000,
+			Memory:     2048 * 1024 * 1024,
+			MemorySwap: 2048 * 1024 * 1024,
+			PidsLimit:  int64Ptr(1024),
+			DiskQuota:  4096 * 1024 * 1024,
+		},
+		NetworkPolicy: NetworkPolicy{
+			AllowOutbound: true, // Test dependencies are downloaded
+			BlockedPorts:  []string{"22", "23", "25"},
+		},
+		TimeoutSeconds: tr.timeoutSeconds,
+	}
+
+	// The suite's exit code only says whether some test failed, which the
+	// parsed counts already tell
+	command := []string{"sh", "-c", fmt.Sprintf(
+		"base64 -d | tar -x -C /workspace; cd /workspace; %s 2>&1; true", testScript(project.Ecosystem))}
+	if project.Ecosystem == EcosystemDotnet && RequiresWindows(files, project.Dir) {
+		if !tr.windows(ctx) {
+			return fmt.Errorf("project targets Windows, which needs a Docker host running Windows containers")
+		}
+		config.Platform = PlatformWindows
+		config.Image = tr.windowsImages[project.Ecosystem]
+		config.WorkingDir = windowsWorkDir
+		config.Environment = []string{"CI=true"}
+		command = windowsCommand(windowsTestScript + "; exit 0")
+	}
+
+	result, err := tr.run(ctx, config, command, base64.StdEncoding.EncodeToString(archive))
+	if err != nil {
+		return err
+	}
+	run.Output = lastLines(result.Stdout+result.Stderr, 200)
+	switch project.Ecosystem {
+	case EcosystemGo:
+		parseGoTestOutput(result.Stdout, run)
+	case EcosystemNode:
+		parseJestOutput(result.Stdout, run)
+	case EcosystemDotnet:
+		parseDotnetTestOutput(result.Stdout, run)
+	case EcosystemMaven, EcosystemGradle:
+		parseJUnitOutput(result.Stdout, run)
+	default:
+		parsePytestOutput(result.Stdout, run)
+	}
+	if run.Passed+run.Failed == 0 {
+		return fmt.Errorf("no test results in output: %s", lastLines(result.Stdout+result.Stderr, 5))
+	}
+	return nil
+}
+
+func testScript(ecosystem Ecosystem) string {
+	switch ecosystem {
+	case EcosystemGo:
+		return "go test -v -cover ./..."
+	case EcosystemNode:
+		return "npm install --no-audit --no-fund --silent && npx --yes jest --coverage --coverageReporters=text-summary"
+	case EcosystemDotnet:
+		// coverlet writes Cobertura reports; their first lines carry the totals
+		return `dotnet test --collect:"XPlat Code Coverage" --results-directory /tmp/results; find /tmp/results -name coverage.cobertura.xml -exec head -n 2 {} \;`
+	case EcosystemMaven:
+		// JaCoCo is attached from the command line so poms without the
+		// plugin still report coverage
+		return "mvn -B -q -Dmaven.test.failure.ignore=true org.jacoco:jacoco-maven-plugin:0.8.12:prepare-agent test " +
+			"org.jacoco:jacoco-maven-plugin:0.8.12:report; " + junitReports("surefire-reports")
+	case EcosystemGradle:
+		return "gradle --no-daemon -q test --continue; " + junitReports("test-results")
+	default:
+		return "pip install --quiet -r requirements.txt pytest pytest-cov && python -m pytest -q -rf --cov=. --cov-report=term"
+	}
+}
+
+// junitReports prints the <testsuite> element of every JUnit XML report
+// under a directory named reportDir, followed by the JaCoCo CSV reports
+func junitReports(reportDir string) string {
+	return fmt.Sprintf(`find . -path '*/%s/*.xml' -exec grep -h '<testsuite ' {} +; find . -name '*.csv' -path '*jacoco*' -exec cat {} +`, reportDir)
+}
+
+// windowsTestScript is the PowerShell equivalent of the .NET test script
+const windowsTestScript = `dotnet test --collect:"XPlat Code Coverage" --results-directory C:\results 2>&1; ` +
+	`Get-ChildItem C:\results -Recurse -Filter coverage.cobertura.xml | ForEach-Object { Get-Content $_.FullName -TotalCount 2 }`
+
+var (
+	goCoverage     = regexp.MustCompile(`coverage: ([0-9.]+)% of statements`)
+	jestTests      = regexp.MustCompile(`Tests:\s+(.*)\s+total`)
+	jestCount      = regexp.MustCompile(`(\d+) (passed|failed)`)
+	jestCoverage   = regexp.MustCompile(`Lines\s*:\s*([0-9.]+)%`)
+	pytestCount    = regexp.MustCompile(`(\d+) (passed|failed|error)`)
+	pytestCoverage = regexp.MustCompile(`(?m)^TOTAL\s.*\s([0-9]+)%\s*$`)
+	pytestSummary  = regexp.MustCompile(`(?m)^=*\s*(.*(?:passed|failed|error).*) in [0-9.]+s`)
+	dotnetSummary  = regexp.MustCompile(`(?:Passed|Failed)!\s+-\s+Failed:\s+(\d+),\s+Passed:\s+(\d+)`)
+	dotnetCoverage = regexp.MustCompile(`<coverage\s[^>]*line-rate="([0-9.]+)"`)
+	junitSuite     = regexp.MustCompile(`<testsuite\s[^>]*>`)
+	junitAttr      = regexp.MustCompile(`\s(tests|failures|errors|skipped)="(\d+)"`)
+)
+
+// parseGoTestOutput counts the -v results of top-level tests and averages
+// the package coverage
+func parseGoTestOutput(out string, run *TestRun) {
+	for _, line := range strings.Split(out, "\n") {
+		switch {
+		case strings.HasPrefix(line, "--- PASS:"):
+			run.Passed++
+		case strings.HasPrefix(line, "--- FAIL:"):
+			run.Failed++
+		}
+	}
+	var total float64
+	matches := goCoverage.FindAllStringSubmatch(out, -1)
+	for _, m := range matches {
+		v, _ := strconv.ParseFloat(m[1], 64)
+		total += v
+	}
+	if len(matches) > 0 {
+		run.Coverage = total / float64(len(matches))
+	}
+}
+
+func parseJestOutput(out string, run *TestRun) {
+	if m := jestTests.FindStringSubmatch(out); m != nil {
+		for _, c := range jestCount.FindAllStringSubmatch(m[1], -1) {
+			n, _ := strconv.Atoi(c[1])
+			if c[2] == "passed" {
+				run.Passed += n
+			} else {
+				run.Failed += n
+			}
+		}
+	}
+	if m := jestCoverage.FindStringSubmatch(out); m != nil {
+		run.Coverage, _ = strconv.ParseFloat(m[1], 64)
+	}
+}
+
+func parsePytestOutput(out string, run *TestRun) {
+	if m := pytestSummary.FindAllStringSubmatch(out, -1); m != nil {
+		for _, c := range pytestCount.FindAllStringSubmatch(m[len(m)-1][1], -1) {
+			n, _ := strconv.Atoi(c[1])
+			if c[2] == "passed" {
+				run.Passed += n
+			} else {
+				run.Failed += n
+			}
+		}
+	}
+	if m := pytestCoverage.FindStringSubmatch(out); m != nil {
+		run.Coverage, _ = strconv.ParseFloat(m[1], 64)
+	}
+}
+
+// parseDotnetTestOutput sums the summary line of each test assembly and
+// averages the line rate of the Cobertura reports printed after the run
+func parseDotnetTestOutput(out string, run *TestRun) {
+	for _, m := range dotnetSummary.FindAllStringSubmatch(out, -1) {
+		failed, _ := strconv.Atoi(m[1])
+		passed, _ := strconv.Atoi(m[2])
+		run.Failed += failed
+		run.Passed += passed
+	}
+	var total float64
+	matches := dotnetCoverage.FindAllStringSubmatch(out, -1)
+	for _, m := range matches {
+		v, _ := strconv.ParseFloat(m[1], 64)
+		total += v * 100
+	}
+	if len(matches) > 0 {
+		run.Coverage = total / float64(len(matches))
+	}
+}
+
+// parseJUnitOutput sums the counts of the <testsuite> elements of JUnit
+// reports and computes line coverage from the JaCoCo CSV rows, whose eighth
+// and ninth columns are LINE_MISSED and LINE_COVERED
+func parseJUnitOutput(out string, run *TestRun) {
+	for _, suite := range junitSuite.FindAllString(out, -1) {
+		counts := make(map[string]int)
+		for _, a := range junitAttr.FindAllStringSubmatch(suite, -1) {
+			counts[a[1]], _ = strconv.Atoi(a[2])
+		}
+		failed := counts["failures"] + counts["errors"]
+		run.Failed += failed
+		run.Passed += max(counts["tests"]-failed-counts["skipped"], 0)
+	}
+	var missed, covered int
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), ",")
+		if len(fields) < 9 {
+			continue
+		}
+		m, err1 := strconv.Atoi(fields[7])
+		c, err2 := strconv.Atoi(fields[8])
+		if err1 != nil || err2 != nil {
+			continue // the header row
+		}
+		missed += m
+		covered += c
+	}
+	if missed+covered > 0 {
+		run.Coverage = float64(covered) * 100 / float64(missed+covered)
+	}
+}
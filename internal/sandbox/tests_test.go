@@ -0,0 +1,133 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseTestOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		parse    func(string, *TestRun)
+		output   string
+		passed   int
+		failed   int
+		coverage float64
+	}{
+		{
+			name:  "go",
+			parse: parseGoTestOutput,
+			output: `=== RUN   TestAdd
+--- PASS: TestAdd (0.00s)
+=== RUN   TestDiv
+    --- PASS: TestDiv/ok (0.00s)
+--- FAIL: TestDiv (0.00s)
+FAIL	shop/calc	0.01s
+ok  	shop/store	0.02s	coverage: 80.0% of statements
+ok  	shop/api	0.02s	coverage: 60.0% of statements`,
+			passed: 1, failed: 1, coverage: 70,
+		},
+		{
+			name:  "jest",
+			parse: parseJestOutput,
+			output: `Tests:       1 failed, 7 passed, 8 total
+=============================== Coverage summary ===============================
+Statements   : 81.2% ( 13/16 )
+Lines        : 75.5% ( 12/16 )`,
+			passed: 7, failed: 1, coverage: 75.5,
+		},
+		{
+			name:  "pytest",
+			parse: parsePytestOutput,
+			output: `Name        Stmts   Miss  Cover
+app.py         20      5    75%
+TOTAL          20      5    75%
+FAILED test_app.py::test_div - ZeroDivisionError
+========== 1 failed, 4 passed in 0.12s ==========`,
+			passed: 4, failed: 1, coverage: 75,
+		},
+		{
+			name:  "dotnet",
+			parse: parseDotnetTestOutput,
+			output: `Failed!  - Failed:     1, Passed:     5, Skipped:     0, Total:     6, Duration: 41 ms - Shop.Tests.dll (net8.0)
+Passed!  - Failed:     0, Passed:     3, Skipped:     1, Total:     4, Duration: 12 ms - Api.Tests.dll (net8.0)
+<?xml version="1.0" encoding="utf-8"?>
+<coverage line-rate="0.8" branch-rate="0.5" version="1.9" timestamp="1700000000">
+<?xml version="1.0" encoding="utf-8"?>
+<coverage line-rate="0.6" branch-rate="0.5" version="1.9" timestamp="1700000000">`,
+			passed: 8, failed: 1, coverage: 70,
+		},
+		{
+			name:  "junit",
+			parse: parseJUnitOutput,
+			output: `<testsuite xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" name="com.acme.OrderServiceTest" time="0.21" tests="4" errors="0" skipped="1" failures="1">
+<testsuite name="com.acme.web.OrderControllerTest" tests="3" skipped="0" failures="0" errors="1" timestamp="2024-05-01T10:00:00" hostname="sandbox" time="1.2">
+GROUP,PACKAGE,CLASS,INSTRUCTION_MISSED,INSTRUCTION_COVERED,BRANCH_MISSED,BRANCH_COVERED,LINE_MISSED,LINE_COVERED,COMPLEXITY_MISSED,COMPLEXITY_COVERED,METHOD_MISSED,METHOD_COVERED
+orders,com.acme,OrderService,10,90,1,3,4,16,1,5,0,4
+orders,com.acme.web,OrderController,12,40,0,0,6,14,1,3,1,3`,
+			passed: 4, failed: 2, coverage: 75,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := TestRun{Coverage: -1}
+			tt.parse(tt.output, &run)
+			if run.Passed != tt.passed || run.Failed != tt.failed || run.Coverage != tt.coverage {
+				t.Errorf("got %d passed, %d failed, %.1f%% coverage", run.Passed, run.Failed, run.Coverage)
+			}
+		})
+	}
+}
+
+func TestRunReportsSuitesWithoutResults(t *testing.T) {
+	runner := NewTestRunner()
+	runner.run = func(ctx context.Context, config *SandboxConfig, command []string, stdin string) (*ExecutionResult, error) {
+		if strings.Contains(command[2], "pytest") {
+			return nil, errors.New("docker unavailable")
+		}
+		return &ExecutionResult{Stdout: "# shop\n./main_test.go:5:2: undefined: Foo\nFAIL\tshop [build failed]\n"}, nil
+	}
+
+	runs := runner.Run(context.Background(), map[string]string{
+		"go.mod":                  "module shop\n",
+		"worker/requirements.txt": "requests\n",
+	})
+	if len(runs) != 2 {
+		t.Fatalf("expected 2 runs, got %+v", runs)
+	}
+	if runs[0].Error == "" || !strings.Contains(runs[0].Output, "undefined: Foo") {
+		t.Errorf("expected the build failure with its output, got %+v", runs[0])
+	}
+	if runs[1].Error != "docker unavailable" || runs[1].Output != "" {
+		t.Errorf("expected the sandbox error without output, got %+v", runs[1])
+	}
+}
+
+func TestRunWindowsDotnetProjects(t *testing.T) {
+	files := map[string]string{
+		"App/App.csproj": "<Project><PropertyGroup><TargetFramework>net8.0-windows</TargetFramework><UseWPF>true</UseWPF></PropertyGroup></Project>",
+	}
+	runner := NewTestRunner()
+	runner.windows = func(context.Context) bool { return false }
+	runner.run = func(ctx context.Context, config *SandboxConfig, command []string, stdin string) (*ExecutionResult, error) {
+		t.Fatal("a Windows project ran without Windows containers")
+		return nil, nil
+	}
+	if runs := runner.Run(context.Background(), files); len(runs) != 1 || !strings.Contains(runs[0].Error, "Windows containers") {
+		t.Fatalf("expected the run to be refused, got %+v", runs)
+	}
+
+	runner.windows = func(context.Context) bool { return true }
+	runner.run = func(ctx context.Context, config *SandboxConfig, command []string, stdin string) (*ExecutionResult, error) {
+		if config.Platform != PlatformWindows || !strings.Contains(config.Image, "windowsservercore") || command[0] != "powershell" {
+			t.Errorf("unexpected invocation %s %s %v", config.Platform, config.Image, command)
+		}
+		return &ExecutionResult{Stdout: "Passed!  - Failed:     0, Passed:     2, Skipped:     0, Total:     2"}, nil
+	}
+	runs := runner.Run(context.Background(), files)
+	if len(runs) != 1 || runs[0].Error != "" || runs[0].Passed != 2 {
+		t.Fatalf("expected 2 passing tests, got %+v", runs)
+	}
+}
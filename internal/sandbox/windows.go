@@ -0,0 +1,127 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"QLP/internal/config"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// PlatformWindows runs a sandbox in a Windows container. Such sandboxes need
+// a Docker host running Windows containers; see WindowsSupported.
+const PlatformWindows = "windows"
+
+// windowsWorkDir is where projects are unpacked in Windows containers
+const windowsWorkDir = `C:\workspace`
+
+var (
+	windowsOnce      sync.Once
+	windowsSupported bool
+)
+
+// WindowsSupported reports whether Windows containers can run. With
+// QLP_SANDBOX_WINDOWS=auto (the default) the Docker daemon is asked for its
+// OS type once; true and false skip the check.
+func WindowsSupported(ctx context.Context) bool {
+	windowsOnce.Do(func() {
+		switch config.GetEnvOrDefault("QLP_SANDBOX_WINDOWS", "auto") {
+		case "true":
+			windowsSupported = true
+		case "false":
+			windowsSupported = false
+		default:
+			windowsSupported = daemonOSType(ctx) == PlatformWindows
+		}
+	})
+	return windowsSupported
+}
+
+func daemonOSType(ctx context.Context) string {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return ""
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	info, err := cli.Info(ctx)
+	if err != nil {
+		log.Printf("⚠️ Could not ask the Docker daemon for its OS type, Windows containers are disabled: %v", err)
+		return ""
+	}
+	return info.OSType
+}
+
+// windowsIsolation is the isolation of Windows sandboxes, set by
+// QLP_SANDBOX_WINDOWS_ISOLATION. Hyper-V isolation gives every container its
+// own kernel, so it is the default for untrusted code.
+func windowsIsolation() container.Isolation {
+	switch config.GetEnvOrDefault("QLP_SANDBOX_WINDOWS_ISOLATION", "hyperv") {
+	case "process":
+		return container.IsolationProcess
+	default:
+		return container.IsolationHyperV
+	}
+}
+
+// windowsCommand returns a PowerShell command that unpacks the base64 tar
+// written to stdin into the working directory and runs script there
+func windowsCommand(script string) []string {
+	return []string{"powershell", "-NoLogo", "-NoProfile", "-NonInteractive", "-Command", fmt.Sprintf(
+		"$ErrorActionPreference = 'Continue'; "+
+			"[IO.File]::WriteAllBytes('C:\\qlp.tar', [Convert]::FromBase64String([Console]::In.ReadToEnd())); "+
+			"tar -x -f C:\\qlp.tar -C %s; Set-Location %s; %s",
+		windowsWorkDir, windowsWorkDir, script)}
+}
+
+var windowsProject = regexp.MustCompile(`(?i)<TargetFrameworks?>[^<]*-windows|<UseWPF>\s*true|<UseWindowsForms>\s*true`)
+
+// RequiresWindows reports whether a .NET project under dir targets Windows,
+// through a -windows target framework, WPF or Windows Forms, and so only
+// builds in a Windows container
+func RequiresWindows(files map[string]string, dir string) bool {
+	prefix := ""
+	if dir != "." {
+		prefix = dir + "/"
+	}
+	for p, content := range files {
+		if strings.HasPrefix(p, prefix) && isDotnetProjectFile(path.Base(p)) && windowsProject.MatchString(content) {
+			return true
+		}
+	}
+	return false
+}
+
+// windowsHostConfig drops the settings Windows containers do not support:
+// capabilities, seccomp, pid limits, swap, tmpfs mounts and a read-only
+// root. CPU is limited by count instead of CFS quota.
+func (cs *ContainerSandbox) windowsHostConfig(hostConfig *container.HostConfig) {
+	limits := cs.config.ResourceLimits
+	hostConfig.Isolation = windowsIsolation()
+	hostConfig.ReadonlyRootfs = false
+	hostConfig.SecurityOpt = nil
+	hostConfig.CapDrop = nil
+	hostConfig.CapAdd = nil
+	hostConfig.Resources = container.Resources{Memory: limits.Memory}
+	if limits.CPUQuota > 0 && limits.CPUPeriod > 0 {
+		hostConfig.Resources.NanoCPUs = limits.CPUQuota * 1e9 / limits.CPUPeriod
+	}
+	if cs.config.ScratchDir == "" {
+		hostConfig.Mounts = nil
+	}
+	// nat networks cannot be made internal, so sandboxes without outbound
+	// access get no network at all
+	if !cs.config.NetworkPolicy.AllowOutbound {
+		hostConfig.NetworkMode = "none"
+	}
+}
@@ -0,0 +1,280 @@
+// Package sarif builds SARIF 2.1.0 logs so security and quality findings can
+// be consumed by GitHub code scanning and other SARIF tools.
+package sarif
+
+import (
+	"encoding/json"
+	pathpkg "path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MediaType is the content type clients send in Accept to request SARIF
+const MediaType = "application/sarif+json"
+
+const (
+	schemaURI = "https://json.schemastore.org/sarif-2.1.0.json"
+	version   = "2.1.0"
+)
+
+// Log is a SARIF log with a single run
+type Log struct {
+	Schema  string `json:"$schema"`
+	Version string `json:"version"`
+	Runs    []Run  `json:"runs"`
+}
+
+type Run struct {
+	Tool    Tool     `json:"tool"`
+	Results []Result `json:"results"`
+}
+
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+type Driver struct {
+	Name           string `json:"name"`
+	Version        string `json:"version,omitempty"`
+	InformationURI string `json:"informationUri,omitempty"`
+	Rules          []Rule `json:"rules"`
+}
+
+type Rule struct {
+	ID               string          `json:"id"`
+	Name             string          `json:"name,omitempty"`
+	ShortDescription *Message        `json:"shortDescription,omitempty"`
+	Help             *Message        `json:"help,omitempty"`
+	Properties       *RuleProperties `json:"properties,omitempty"`
+}
+
+type RuleProperties struct {
+	Tags             []string `json:"tags,omitempty"`
+	SecuritySeverity string   `json:"security-severity,omitempty"`
+}
+
+type Result struct {
+	RuleID    string     `json:"ruleId"`
+	Level     string     `json:"level"`
+	Message   Message    `json:"message"`
+	Locations []Location `json:"locations,omitempty"`
+}
+
+type Message struct {
+	Text string `json:"text"`
+}
+
+type Location struct {
+	PhysicalLocation PhysicalLocation `json:"physicalLocation"`
+}
+
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           *Region          `json:"region,omitempty"`
+}
+
+type ArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type Region struct {
+	StartLine int `json:"startLine"`
+}
+
+// Finding is a tool-neutral finding to convert into a SARIF result
+type Finding struct {
+	RuleID      string // stable identifier, e.g. "security/sql_injection"
+	Severity    string // critical, high, medium, low or info
+	Message     string
+	Location    string // "path", "path:line" or a resource name
+	Remediation string
+	Tags        []string // e.g. CWE-89, OWASP A03
+}
+
+// Builder accumulates findings into a SARIF log
+type Builder struct {
+	log        *Log
+	rules      map[string]int
+	defaultURI string
+}
+
+// NewBuilder starts a log for the named tool
+func NewBuilder(toolName, toolVersion string) *Builder {
+	return &Builder{
+		log: &Log{
+			Schema:  schemaURI,
+			Version: version,
+			Runs: []Run{{
+				Tool: Tool{Driver: Driver{
+					Name:           toolName,
+					Version:        toolVersion,
+					InformationURI: "https://github.com/qlp-hq/QLP",
+					Rules:          []Rule{},
+				}},
+				Results: []Result{},
+			}},
+		},
+		rules: make(map[string]int),
+	}
+}
+
+// SetDefaultLocation sets the file reported for findings without one, since
+// code scanning rejects results that have no location
+func (b *Builder) SetDefaultLocation(uri string) {
+	b.defaultURI = uri
+}
+
+// Add appends a finding, registering its rule on first use
+func (b *Builder) Add(f Finding) {
+	run := &b.log.Runs[0]
+	ruleID := RuleID(f.RuleID)
+	if f.Message == "" {
+		f.Message = "Finding " + ruleID
+	}
+	if _, ok := b.rules[ruleID]; !ok {
+		rule := Rule{
+			ID:               ruleID,
+			Name:             ruleName(ruleID),
+			ShortDescription: &Message{Text: firstSentence(f.Message)},
+			Properties: &RuleProperties{
+				Tags:             f.Tags,
+				SecuritySeverity: securitySeverity(f.Severity),
+			},
+		}
+		if f.Remediation != "" {
+			rule.Help = &Message{Text: f.Remediation}
+		}
+		b.rules[ruleID] = len(run.Tool.Driver.Rules)
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+	}
+
+	result := Result{
+		RuleID:  ruleID,
+		Level:   Level(f.Severity),
+		Message: Message{Text: f.Message},
+	}
+	if loc := location(f.Location); loc != nil {
+		result.Locations = []Location{*loc}
+	} else if b.defaultURI != "" {
+		if f.Location != "" {
+			result.Message.Text += " (" + f.Location + ")"
+		}
+		result.Locations = []Location{{PhysicalLocation: PhysicalLocation{ArtifactLocation: ArtifactLocation{URI: b.defaultURI}}}}
+	}
+	run.Results = append(run.Results, result)
+}
+
+// Log returns the built log
+func (b *Builder) Log() *Log {
+	return b.log
+}
+
+// Marshal encodes the log as indented JSON
+func (l *Log) Marshal() ([]byte, error) {
+	return json.MarshalIndent(l, "", "  ")
+}
+
+// AcceptsSARIF reports whether an Accept header asks for SARIF
+func AcceptsSARIF(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		if strings.TrimSpace(strings.SplitN(part, ";", 2)[0]) == MediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// Level maps a severity onto a SARIF result level
+func Level(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical", "high", "error":
+		return "error"
+	case "medium", "warning":
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// securitySeverity is the CVSS-like score GitHub uses to rank security alerts
+func securitySeverity(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical":
+		return "9.5"
+	case "high":
+		return "8.0"
+	case "medium":
+		return "5.5"
+	case "low":
+		return "3.0"
+	default:
+		return "1.0"
+	}
+}
+
+var nonRuleChars = regexp.MustCompile(`[^a-z0-9/._-]+`)
+
+// RuleID normalizes a rule identifier
+func RuleID(id string) string {
+	id = strings.Trim(nonRuleChars.ReplaceAllString(strings.ToLower(id), "-"), "-")
+	if id == "" {
+		return "finding"
+	}
+	return id
+}
+
+func ruleName(id string) string {
+	name := id[strings.LastIndex(id, "/")+1:]
+	parts := strings.FieldsFunc(name, func(r rune) bool { return r == '-' || r == '_' || r == '.' })
+	for i, p := range parts {
+		parts[i] = strings.ToUpper(p[:1]) + p[1:]
+	}
+	return strings.Join(parts, "")
+}
+
+func firstSentence(s string) string {
+	if i := strings.Index(s, ". "); i > 0 {
+		return s[:i+1]
+	}
+	return s
+}
+
+// location parses "path" or "path:line"; anything that does not look like a
+// file name, such as a Kubernetes resource, is not a physical location
+func location(loc string) *Location {
+	loc = strings.TrimSpace(loc)
+	if loc == "" {
+		return nil
+	}
+	path, line := loc, 0
+	if i := strings.LastIndex(loc, ":"); i > 0 {
+		if n, err := strconv.Atoi(loc[i+1:]); err == nil {
+			path, line = loc[:i], n
+		}
+	}
+	base := pathpkg.Base(path)
+	if strings.ContainsAny(path, " ") || (pathpkg.Ext(base) == "" && base != "Dockerfile" && base != "Makefile") {
+		return nil
+	}
+	l := &Location{PhysicalLocation: PhysicalLocation{ArtifactLocation: ArtifactLocation{URI: strings.TrimPrefix(path, "./")}}}
+	if line > 0 {
+		l.PhysicalLocation.Region = &Region{StartLine: line}
+	}
+	return l
+}
+
+// SortedTags returns tags deduplicated and sorted
+func SortedTags(tags ...string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, t := range tags {
+		if t != "" && !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
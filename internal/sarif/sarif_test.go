@@ -0,0 +1,65 @@
+package sarif
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	b := NewBuilder("qlp-test", "1.0.0")
+	b.SetDefaultLocation("main.go")
+	b.Add(Finding{RuleID: "security/SQL Injection", Severity: "HIGH", Message: "Query built from input. Use parameters.", Location: "db/users.go:42", Tags: []string{"CWE-89"}})
+	b.Add(Finding{RuleID: "security/sql injection", Severity: "high", Message: "Another query", Location: "db/orders.go"})
+	b.Add(Finding{RuleID: "kubernetes/resources", Severity: "medium", Message: "No limits", Location: "deployment/api"})
+	b.Add(Finding{Severity: "info"})
+
+	run := b.Log().Runs[0]
+	if got := len(run.Tool.Driver.Rules); got != 3 {
+		t.Fatalf("rules = %d, want 3", got)
+	}
+	rule := run.Tool.Driver.Rules[0]
+	if rule.ID != "security/sql-injection" || rule.Name != "SqlInjection" || rule.ShortDescription.Text != "Query built from input." {
+		t.Errorf("rule = %+v", rule)
+	}
+	if rule.Properties.SecuritySeverity != "8.0" {
+		t.Errorf("security-severity = %q", rule.Properties.SecuritySeverity)
+	}
+
+	first := run.Results[0]
+	if first.Level != "error" || first.Locations[0].PhysicalLocation.ArtifactLocation.URI != "db/users.go" ||
+		first.Locations[0].PhysicalLocation.Region.StartLine != 42 {
+		t.Errorf("first result = %+v", first)
+	}
+	// Resources that are not files fall back to the default location
+	k8s := run.Results[2]
+	if k8s.Level != "warning" || k8s.Locations[0].PhysicalLocation.ArtifactLocation.URI != "main.go" ||
+		k8s.Message.Text != "No limits (deployment/api)" {
+		t.Errorf("kubernetes result = %+v", k8s)
+	}
+	if last := run.Results[3]; last.RuleID != "finding" || last.Level != "note" || last.Message.Text == "" {
+		t.Errorf("empty finding = %+v", last)
+	}
+
+	data, err := b.Log().Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded["version"] != "2.1.0" {
+		t.Errorf("marshalled log = %s", data)
+	}
+}
+
+func TestAcceptsSARIF(t *testing.T) {
+	for accept, want := range map[string]bool{
+		"application/sarif+json":                   true,
+		"application/json, application/sarif+json": true,
+		"application/sarif+json;q=0.9":             true,
+		"application/json":                         false,
+		"":                                         false,
+	} {
+		if got := AcceptsSARIF(accept); got != want {
+			t.Errorf("AcceptsSARIF(%q) = %v, want %v", accept, got, want)
+		}
+	}
+}
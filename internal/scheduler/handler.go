@@ -0,0 +1,162 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"QLP/internal/audit"
+)
+
+// Routes returns the schedule endpoints:
+//
+//	POST   /schedules                creates a job
+//	GET    /schedules?tenant=        lists jobs, optionally for one tenant
+//	GET    /schedules/{id}           returns a job
+//	DELETE /schedules/{id}           deletes a job and its history
+//	POST   /schedules/{id}/enable    resumes a job from its next cron time
+//	POST   /schedules/{id}/disable   stops firing a job
+//	POST   /schedules/{id}/run       runs a job now
+//	GET    /schedules/{id}/runs      returns the run history, newest first
+//
+// Jobs created with an API key belong to the key's tenant, and jobs of other
+// tenants than the request's are not found.
+func Routes(s *Scheduler) map[string]http.Handler {
+	return map[string]http.Handler{
+		"POST /schedules":              createHandler(s),
+		"GET /schedules":               listHandler(s),
+		"GET /schedules/{id}":          getHandler(s),
+		"DELETE /schedules/{id}":       deleteHandler(s),
+		"POST /schedules/{id}/enable":  enableHandler(s, true),
+		"POST /schedules/{id}/disable": enableHandler(s, false),
+		"POST /schedules/{id}/run":     runHandler(s),
+		"GET /schedules/{id}/runs":     historyHandler(s),
+	}
+}
+
+func createHandler(s *Scheduler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		job := Job{Enabled: true}
+		if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+			http.Error(w, "invalid schedule: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		job.TenantID = audit.BodyTenant(r, job.TenantID)
+		created, err := s.Create(job)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, created)
+	})
+}
+
+func listHandler(s *Scheduler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"schedules": s.List(audit.RequestTenant(r)),
+		})
+	})
+}
+
+func getHandler(s *Scheduler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		job, err := tenantJob(s, r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	})
+}
+
+func deleteHandler(s *Scheduler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := tenantJob(s, r); err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := s.Delete(r.PathValue("id")); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func enableHandler(s *Scheduler, enabled bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := tenantJob(s, r); err != nil {
+			writeError(w, err)
+			return
+		}
+		job, err := s.SetEnabled(r.PathValue("id"), enabled)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	})
+}
+
+func runHandler(s *Scheduler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := tenantJob(s, r); err != nil {
+			writeError(w, err)
+			return
+		}
+		run, err := s.Trigger(r.PathValue("id"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, run)
+	})
+}
+
+func historyHandler(s *Scheduler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := tenantJob(s, r); err != nil {
+			writeError(w, err)
+			return
+		}
+		runs, err := s.History(r.PathValue("id"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"runs": runs,
+		})
+	})
+}
+
+// tenantJob returns the job the request names, not found when it belongs to
+// another tenant than the request's
+func tenantJob(s *Scheduler, r *http.Request) (*Job, error) {
+	job, err := s.Get(r.PathValue("id"))
+	if err != nil {
+		return nil, err
+	}
+	if !audit.TenantAllowed(r, job.TenantID) {
+		return nil, ErrNotFound
+	}
+	return job, nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrInvalid):
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
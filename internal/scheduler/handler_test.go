@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"QLP/internal/audit"
+)
+
+func TestRoutesScopeJobsToTheAPIKey(t *testing.T) {
+	var mu sync.Mutex
+	var calls [][]string
+	exec := func(ctx context.Context, args ...string) ([]byte, error) {
+		mu.Lock()
+		calls = append(calls, args)
+		mu.Unlock()
+		return json.Marshal(cliOutput{CapsuleID: "QLCAP-1", Passed: true})
+	}
+	s, err := New(exec, "", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	for pattern, h := range Routes(s) {
+		mux.Handle(pattern, h)
+	}
+	as := func(tenant, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(audit.WithTenant(req.Context(), tenant))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := as("t1", http.MethodPost, "/schedules", `{"tenant_id":"t2","name":"nightly","cron":"@daily","action":"validate","target":"-rf"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body)
+	}
+	var job Job
+	if err := json.NewDecoder(rec.Body).Decode(&job); err != nil {
+		t.Fatal(err)
+	}
+	if job.TenantID != "t1" {
+		t.Fatalf("job created for %q, want the key's tenant", job.TenantID)
+	}
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodGet, ""},
+		{http.MethodPost, "/enable"},
+		{http.MethodPost, "/disable"},
+		{http.MethodPost, "/run"},
+		{http.MethodGet, "/runs"},
+		{http.MethodDelete, ""},
+	} {
+		if rec := as("t2", route.method, "/schedules/"+job.ID+route.path, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s from another tenant: %d", route.method, route.path, rec.Code)
+		}
+	}
+
+	if rec := as("t1", http.MethodPost, "/schedules/"+job.ID+"/run", ""); rec.Code != http.StatusAccepted {
+		t.Fatalf("run: %d %s", rec.Code, rec.Body)
+	}
+	waitRuns(t, s)
+	want := []string{"validate", "--tenant", "t1", "--", "-rf"}
+	if len(calls) != 1 || !reflect.DeepEqual(calls[0], want) {
+		t.Fatalf("ran %v, want %v", calls, want)
+	}
+	if rec := as("t1", http.MethodDelete, "/schedules/"+job.ID, ""); rec.Code != http.StatusNoContent {
+		t.Errorf("delete: %d", rec.Code)
+	}
+}
@@ -0,0 +1,590 @@
+// Package scheduler triggers intents on cron schedules stored per tenant,
+// e.g. nightly re-validation of a capsule against the latest security rules.
+// Runs execute as qlp subprocesses and are kept as per-job history.
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"QLP/internal/e2e"
+	"QLP/internal/logger"
+	"QLP/internal/models"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrNotFound is returned for unknown job IDs
+	ErrNotFound = errors.New("schedule not found")
+	// ErrInvalid is returned for jobs that cannot be scheduled
+	ErrInvalid = errors.New("invalid schedule")
+)
+
+// Action is what a job runs
+type Action string
+
+const (
+	// ActionGenerate runs qlp generate for the job's intent
+	ActionGenerate Action = "generate"
+	// ActionValidate runs qlp validate against the job's capsule or path
+	ActionValidate Action = "validate"
+)
+
+// OverlapPolicy decides what happens when a job is due while its previous run is still going
+type OverlapPolicy string
+
+const (
+	// OverlapSkip records the new run as skipped
+	OverlapSkip OverlapPolicy = "skip"
+	// OverlapAllow starts the new run alongside the old one
+	OverlapAllow OverlapPolicy = "allow"
+	// OverlapReplace cancels the running run and starts the new one
+	OverlapReplace OverlapPolicy = "replace"
+)
+
+// Job is a scheduled intent
+type Job struct {
+	ID          string              `json:"id"`
+	TenantID    string              `json:"tenant_id,omitempty"`
+	Name        string              `json:"name"`
+	Cron        string              `json:"cron"`
+	Timezone    string              `json:"timezone,omitempty"` // IANA name, UTC by default
+	Action      Action              `json:"action"`
+	Intent      string              `json:"intent,omitempty"`    // generate
+	Target      string              `json:"target,omitempty"`    // validate: capsule ID, file or directory
+	MinScore    int                 `json:"min_score,omitempty"` // validate
+	Constraints *models.Constraints `json:"constraints,omitempty"`
+	Overlap     OverlapPolicy       `json:"overlap"`
+	Enabled     bool                `json:"enabled"`
+	NextRunAt   *time.Time          `json:"next_run_at,omitempty"`
+	LastRunAt   *time.Time          `json:"last_run_at,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+// RunStatus is the outcome of one run
+type RunStatus string
+
+const (
+	RunRunning   RunStatus = "running"
+	RunSucceeded RunStatus = "succeeded"
+	RunFailed    RunStatus = "failed"
+	RunSkipped   RunStatus = "skipped"
+	RunCancelled RunStatus = "cancelled"
+)
+
+// Run is one execution of a job
+type Run struct {
+	ID           string     `json:"id"`
+	JobID        string     `json:"job_id"`
+	Trigger      string     `json:"trigger"` // "schedule" or "manual"
+	Status       RunStatus  `json:"status"`
+	ScheduledAt  time.Time  `json:"scheduled_at"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	IntentID     string     `json:"intent_id,omitempty"`
+	CapsuleID    string     `json:"capsule_id,omitempty"`
+	OverallScore int        `json:"overall_score"`
+	Error        string     `json:"error,omitempty"`
+}
+
+// Scheduler owns the jobs, fires them when due and records their runs
+type Scheduler struct {
+	exec         e2e.Executor
+	path         string
+	historyLimit int
+	timeout      time.Duration
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	runs    map[string][]*Run                        // per job, oldest first
+	running map[string]map[string]context.CancelFunc // job ID -> run ID -> cancel
+	ctx     context.Context
+	wg      sync.WaitGroup
+}
+
+// state is the persisted form of the scheduler
+type state struct {
+	Jobs map[string]*Job   `json:"jobs"`
+	Runs map[string][]*Run `json:"runs"`
+}
+
+// New loads a scheduler from path; an empty path keeps schedules in memory
+// only. Runs use exec (the qlp binary) and keep the last historyLimit runs per job.
+func New(exec e2e.Executor, path string, historyLimit int, timeout time.Duration) (*Scheduler, error) {
+	s := &Scheduler{
+		exec:         exec,
+		path:         path,
+		historyLimit: historyLimit,
+		timeout:      timeout,
+		jobs:         make(map[string]*Job),
+		runs:         make(map[string][]*Run),
+		running:      make(map[string]map[string]context.CancelFunc),
+		ctx:          context.Background(),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read schedules: %w", err)
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("failed to parse schedules: %w", err)
+	}
+	if st.Jobs != nil {
+		s.jobs = st.Jobs
+	}
+	if st.Runs != nil {
+		s.runs = st.Runs
+	}
+	// Runs cut off by a restart never finished
+	now := time.Now()
+	for _, runs := range s.runs {
+		for _, r := range runs {
+			if r.Status == RunRunning {
+				r.Status = RunFailed
+				r.Error = "interrupted by restart"
+				r.FinishedAt = &now
+			}
+		}
+	}
+	return s, nil
+}
+
+// Create validates and stores a job, computing its first run time
+func (s *Scheduler) Create(job Job) (*Job, error) {
+	now := time.Now()
+	job.ID = fmt.Sprintf("SCHED-%d", now.UnixNano())
+	job.CreatedAt = now
+	job.UpdatedAt = now
+	job.LastRunAt = nil
+	if job.Action == "" {
+		job.Action = ActionGenerate
+	}
+	if job.Overlap == "" {
+		job.Overlap = OverlapSkip
+	}
+	next, err := validate(&job, now)
+	if err != nil {
+		return nil, err
+	}
+	job.NextRunAt = next
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.ID] = &job
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	out := job
+	return &out, nil
+}
+
+// validate checks a job and returns its next run time when enabled
+func validate(job *Job, now time.Time) (*time.Time, error) {
+	if job.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalid)
+	}
+	switch job.Action {
+	case ActionGenerate:
+		if job.Intent == "" {
+			return nil, fmt.Errorf("%w: intent is required for generate", ErrInvalid)
+		}
+	case ActionValidate:
+		if job.Target == "" {
+			return nil, fmt.Errorf("%w: target is required for validate", ErrInvalid)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown action %q", ErrInvalid, job.Action)
+	}
+	switch job.Overlap {
+	case OverlapSkip, OverlapAllow, OverlapReplace:
+	default:
+		return nil, fmt.Errorf("%w: unknown overlap policy %q", ErrInvalid, job.Overlap)
+	}
+	cron, err := ParseCron(job.Cron)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	loc, err := time.LoadLocation(job.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if !job.Enabled {
+		return nil, nil
+	}
+	next := cron.Next(now.In(loc))
+	if next.IsZero() {
+		return nil, fmt.Errorf("%w: %q never fires", ErrInvalid, job.Cron)
+	}
+	return &next, nil
+}
+
+// Get returns a job
+func (s *Scheduler) Get(id string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	out := *job
+	return &out, nil
+}
+
+// List returns the jobs of tenantID (all tenants when empty), by name
+func (s *Scheduler) List(tenantID string) []*Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		if tenantID == "" || job.TenantID == tenantID {
+			out := *job
+			list = append(list, &out)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// SetEnabled turns a job on or off; enabling schedules its next run from now
+func (s *Scheduler) SetEnabled(id string, enabled bool) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	updated := *job
+	updated.Enabled = enabled
+	updated.UpdatedAt = time.Now()
+	next, err := validate(&updated, updated.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	updated.NextRunAt = next
+	*job = updated
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// Delete removes a job and its history, cancelling any running run
+func (s *Scheduler) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[id]; !ok {
+		return ErrNotFound
+	}
+	for _, cancel := range s.running[id] {
+		cancel()
+	}
+	delete(s.jobs, id)
+	delete(s.runs, id)
+	return s.save()
+}
+
+// History returns a job's runs, newest first
+func (s *Scheduler) History(id string) ([]Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[id]; !ok {
+		return nil, ErrNotFound
+	}
+	runs := s.runs[id]
+	out := make([]Run, len(runs))
+	for i, r := range runs {
+		out[len(runs)-1-i] = *r
+	}
+	return out, nil
+}
+
+// Trigger runs a job now, whether or not it is enabled, applying its overlap policy
+func (s *Scheduler) Trigger(id string) (*Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	run := s.fire(job, time.Now(), "manual")
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	out := *run
+	return &out, nil
+}
+
+// Start fires due jobs every interval until ctx is cancelled, then waits for
+// running runs to stop
+func (s *Scheduler) Start(ctx context.Context, interval time.Duration) {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.tick(time.Now())
+		select {
+		case <-ctx.Done():
+			s.wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tick fires every enabled job due at now. Runs missed while the process
+// was down are coalesced into one.
+func (s *Scheduler) tick(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fired := false
+	for _, job := range s.jobs {
+		if !job.Enabled || job.NextRunAt == nil || job.NextRunAt.After(now) {
+			continue
+		}
+		s.fire(job, *job.NextRunAt, "schedule")
+		next, err := validate(job, now)
+		if err != nil {
+			logger.WithComponent("scheduler").Error("Disabling unschedulable job",
+				zap.String("job_id", job.ID), zap.Error(err))
+			job.Enabled = false
+		}
+		job.NextRunAt = next
+		fired = true
+	}
+	if fired {
+		if err := s.save(); err != nil {
+			logger.WithComponent("scheduler").Error("Failed to save schedules", zap.Error(err))
+		}
+	}
+}
+
+// fire starts or skips a run of job per its overlap policy; the caller holds s.mu
+func (s *Scheduler) fire(job *Job, scheduledAt time.Time, trigger string) *Run {
+	now := time.Now()
+	run := &Run{
+		ID:          fmt.Sprintf("RUN-%d", now.UnixNano()),
+		JobID:       job.ID,
+		Trigger:     trigger,
+		Status:      RunRunning,
+		ScheduledAt: scheduledAt,
+	}
+	job.LastRunAt = &now
+	s.record(run)
+
+	if active := s.running[job.ID]; len(active) > 0 {
+		switch job.Overlap {
+		case OverlapSkip:
+			run.Status = RunSkipped
+			run.Error = "previous run still in progress"
+			run.FinishedAt = &now
+			return run
+		case OverlapReplace:
+			for _, cancel := range active {
+				cancel()
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	if s.running[job.ID] == nil {
+		s.running[job.ID] = make(map[string]context.CancelFunc)
+	}
+	s.running[job.ID][run.ID] = cancel
+	run.StartedAt = &now
+
+	spec := *job
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+		result := s.execute(ctx, &spec)
+		s.finish(job.ID, run, result, ctx.Err())
+	}()
+
+	logger.WithComponent("scheduler").Info("Scheduled run started",
+		zap.String("job_id", job.ID),
+		zap.String("run_id", run.ID),
+		zap.String("trigger", trigger))
+	return run
+}
+
+// record appends run to its job's history, dropping the oldest beyond the limit
+func (s *Scheduler) record(run *Run) {
+	runs := append(s.runs[run.JobID], run)
+	if s.historyLimit > 0 && len(runs) > s.historyLimit {
+		runs = runs[len(runs)-s.historyLimit:]
+	}
+	s.runs[run.JobID] = runs
+}
+
+func (s *Scheduler) finish(jobID string, run *Run, result runResult, ctxErr error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.running[jobID], run.ID)
+	if len(s.running[jobID]) == 0 {
+		delete(s.running, jobID)
+	}
+
+	now := time.Now()
+	run.FinishedAt = &now
+	run.IntentID = result.IntentID
+	run.CapsuleID = result.CapsuleID
+	run.OverallScore = result.OverallScore
+	switch {
+	case result.err == nil:
+		run.Status = RunSucceeded
+	case errors.Is(ctxErr, context.Canceled):
+		run.Status = RunCancelled
+		run.Error = "cancelled"
+	default:
+		run.Status = RunFailed
+		run.Error = result.err.Error()
+	}
+
+	if _, ok := s.jobs[jobID]; !ok {
+		return // deleted while running
+	}
+	if err := s.save(); err != nil {
+		logger.WithComponent("scheduler").Error("Failed to save run", zap.String("run_id", run.ID), zap.Error(err))
+	}
+}
+
+// runResult is what a run records from the qlp --json output
+type runResult struct {
+	IntentID     string
+	CapsuleID    string
+	OverallScore int
+	err          error
+}
+
+// cliOutput covers the --json output of qlp generate and qlp validate
+type cliOutput struct {
+	IntentID     string `json:"intent_id"`
+	CapsuleID    string `json:"capsule_id"`
+	Status       string `json:"status"`
+	OverallScore int    `json:"overall_score"`
+	Error        string `json:"error"`
+	Passed       bool   `json:"passed"`
+	MinScore     int    `json:"min_score"`
+	Validation   *struct {
+		OverallScore int `json:"overall_score"`
+	} `json:"validation"`
+}
+
+func (s *Scheduler) execute(ctx context.Context, job *Job) runResult {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	var args []string
+	constraintsFile, err := writeConstraints(job.Constraints)
+	if err != nil {
+		return runResult{err: err}
+	}
+	if constraintsFile != "" {
+		defer os.Remove(constraintsFile)
+		args = append(args, "--constraints", constraintsFile)
+	}
+	if job.TenantID != "" {
+		args = append(args, "--tenant", job.TenantID)
+	}
+	// "--" ends the flags, so an intent or target starting with "-" is not
+	// read as one
+	switch job.Action {
+	case ActionValidate:
+		if job.MinScore > 0 {
+			args = append(args, "--min-score", strconv.Itoa(job.MinScore))
+		}
+		args = append([]string{"validate"}, append(args, "--", job.Target)...)
+	default:
+		args = append([]string{"generate"}, append(args, "--", job.Intent)...)
+	}
+
+	data, err := s.exec(ctx, args...)
+	var out cliOutput
+	if len(data) > 0 {
+		if decodeErr := json.Unmarshal(data, &out); decodeErr != nil && err == nil {
+			err = fmt.Errorf("invalid %s output: %w", job.Action, decodeErr)
+		}
+	}
+	result := runResult{IntentID: out.IntentID, CapsuleID: out.CapsuleID, OverallScore: out.OverallScore}
+	if out.Validation != nil {
+		result.OverallScore = out.Validation.OverallScore
+	}
+
+	switch {
+	case out.Error != "":
+		result.err = errors.New(out.Error)
+	case job.Action == ActionValidate && len(data) > 0 && !out.Passed:
+		result.err = fmt.Errorf("validation failed: score %d, minimum %d", result.OverallScore, out.MinScore)
+	case err != nil:
+		result.err = err
+	case job.Action == ActionGenerate && out.Status != string(models.IntentStatusCompleted):
+		result.err = fmt.Errorf("intent %s", out.Status)
+	}
+	return result
+}
+
+// save writes the jobs and history to disk; callers hold s.mu
+func (s *Scheduler) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(state{Jobs: s.jobs, Runs: s.runs}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal schedules: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create schedule directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write schedules: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// writeConstraints saves a job's constraints for qlp --constraints
+func writeConstraints(c *models.Constraints) (string, error) {
+	if c.IsZero() {
+		return "", nil
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal constraints: %w", err)
+	}
+	f, err := os.CreateTemp("", "qlp-schedule-constraints-*.json")
+	if err != nil {
+		return "", fmt.Errorf("failed to write constraints: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write constraints: %w", err)
+	}
+	return f.Name(), nil
+}
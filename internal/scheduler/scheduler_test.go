@@ -0,0 +1,239 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	from := time.Date(2026, 3, 7, 23, 30, 0, 0, time.UTC) // a Saturday
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"*/15 * * * *", from, time.Date(2026, 3, 7, 23, 45, 0, 0, time.UTC)},
+		{"@daily", from, time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{"0 2 * * mon-fri", from, time.Date(2026, 3, 9, 2, 0, 0, 0, time.UTC)},
+		{"30 1 1,15 * *", from, time.Date(2026, 3, 15, 1, 30, 0, 0, time.UTC)},
+		{"0 0 * feb 7", from, time.Date(2027, 2, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", from, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// 02:30 does not exist on the spring-forward day in New York
+		{"30 2 * * *", time.Date(2026, 3, 7, 12, 0, 0, 0, ny), time.Date(2026, 3, 9, 2, 30, 0, 0, ny)},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+		}
+		if got := c.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%q Next(%v) = %v, want %v", tt.expr, tt.from, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "0 0 * * funday"} {
+		if _, err := ParseCron(bad); err == nil {
+			t.Errorf("ParseCron(%q) succeeded", bad)
+		}
+	}
+}
+
+func generated(score int) []byte {
+	data, _ := json.Marshal(cliOutput{IntentID: "QI-1", CapsuleID: "QLCAP-1", Status: "completed", OverallScore: score})
+	return data
+}
+
+func waitRuns(t *testing.T, s *Scheduler) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() { s.wg.Wait(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("runs did not finish")
+	}
+}
+
+func TestSchedulerFiresDueJobs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	var calls atomic.Int32
+	exec := func(ctx context.Context, args ...string) ([]byte, error) {
+		calls.Add(1)
+		if args[0] != "validate" || args[len(args)-1] != "QLCAP-1" {
+			return nil, errors.New("unexpected args")
+		}
+		data, _ := json.Marshal(cliOutput{CapsuleID: "QLCAP-1", Passed: true, Validation: &struct {
+			OverallScore int `json:"overall_score"`
+		}{OverallScore: 91}})
+		return data, nil
+	}
+	s, err := New(exec, path, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := s.Create(Job{TenantID: "acme", Name: "nightly", Cron: "0 3 * * *", Action: ActionValidate, Target: "QLCAP-1", Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.NextRunAt == nil || job.NextRunAt.Hour() != 3 {
+		t.Fatalf("next run = %v", job.NextRunAt)
+	}
+
+	s.tick(job.NextRunAt.Add(-time.Second))
+	waitRuns(t, s)
+	if calls.Load() != 0 {
+		t.Fatal("job fired early")
+	}
+	s.tick(job.NextRunAt.Add(time.Second))
+	waitRuns(t, s)
+
+	runs, err := s.History(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Status != RunSucceeded || runs[0].OverallScore != 91 || runs[0].Trigger != "schedule" {
+		t.Fatalf("runs = %+v", runs)
+	}
+	if got, _ := s.Get(job.ID); !got.NextRunAt.After(*job.NextRunAt) {
+		t.Errorf("next run not advanced: %v", got.NextRunAt)
+	}
+
+	// Jobs and history survive a restart
+	reloaded, err := New(exec, path, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs := reloaded.List("acme"); len(jobs) != 1 || jobs[0].Name != "nightly" {
+		t.Errorf("reloaded jobs = %+v", jobs)
+	}
+	if runs, _ := reloaded.History(job.ID); len(runs) != 1 {
+		t.Errorf("reloaded runs = %d", len(runs))
+	}
+	if jobs := reloaded.List("other"); len(jobs) != 0 {
+		t.Errorf("other tenant sees %d jobs", len(jobs))
+	}
+}
+
+func TestSchedulerOverlapPolicies(t *testing.T) {
+	release := make(chan struct{})
+	exec := func(ctx context.Context, args ...string) ([]byte, error) {
+		select {
+		case <-release:
+			return generated(80), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	for _, tt := range []struct {
+		policy OverlapPolicy
+		want   []RunStatus // newest first
+	}{
+		{OverlapSkip, []RunStatus{RunSkipped, RunSucceeded}},
+		{OverlapAllow, []RunStatus{RunSucceeded, RunSucceeded}},
+		{OverlapReplace, []RunStatus{RunSucceeded, RunCancelled}},
+	} {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			s, _ := New(exec, "", 10, 0)
+			job, err := s.Create(Job{Name: "api", Cron: "@hourly", Intent: "build an API", Overlap: tt.policy, Enabled: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Trigger(job.ID); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Trigger(job.ID); err != nil {
+				t.Fatal(err)
+			}
+			// Let the replaced run observe its cancellation before releasing the rest
+			time.Sleep(20 * time.Millisecond)
+			for range 2 {
+				select {
+				case release <- struct{}{}:
+				case <-time.After(50 * time.Millisecond):
+				}
+			}
+			waitRuns(t, s)
+
+			runs, _ := s.History(job.ID)
+			if len(runs) != len(tt.want) {
+				t.Fatalf("runs = %+v", runs)
+			}
+			for i, want := range tt.want {
+				if runs[i].Status != want {
+					t.Errorf("run %d status = %s, want %s", i, runs[i].Status, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSchedulerEnableDisable(t *testing.T) {
+	var calls atomic.Int32
+	exec := func(ctx context.Context, args ...string) ([]byte, error) {
+		calls.Add(1)
+		return generated(75), nil
+	}
+	s, _ := New(exec, "", 2, 0)
+	job, err := s.Create(Job{Name: "rebuild", Cron: "*/5 * * * *", Intent: "rebuild", Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	disabled, err := s.SetEnabled(job.ID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if disabled.NextRunAt != nil {
+		t.Errorf("disabled job has next run %v", disabled.NextRunAt)
+	}
+	s.tick(time.Now().Add(time.Hour))
+	waitRuns(t, s)
+	if calls.Load() != 0 {
+		t.Fatal("disabled job fired")
+	}
+
+	if _, err := s.SetEnabled(job.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		s.tick(time.Now().Add(time.Duration(i) * 6 * time.Minute))
+		waitRuns(t, s)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("calls = %d, want 3", calls.Load())
+	}
+	// History keeps the last two runs
+	if runs, _ := s.History(job.ID); len(runs) != 2 {
+		t.Errorf("history = %d runs, want 2", len(runs))
+	}
+}
+
+func TestSchedulerInvalidJobs(t *testing.T) {
+	s, _ := New(nil, "", 10, 0)
+	for _, job := range []Job{
+		{Cron: "@daily", Intent: "x"},
+		{Name: "a", Cron: "@daily"},
+		{Name: "a", Cron: "@daily", Action: ActionValidate},
+		{Name: "a", Cron: "bogus", Intent: "x"},
+		{Name: "a", Cron: "@daily", Intent: "x", Timezone: "Mars/Olympus"},
+		{Name: "a", Cron: "@daily", Intent: "x", Overlap: "queue"},
+		{Name: "a", Cron: "0 0 30 2 *", Intent: "x", Enabled: true},
+	} {
+		if _, err := s.Create(job); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Create(%+v) error = %v, want ErrInvalid", job, err)
+		}
+	}
+	if _, err := s.Trigger("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Trigger error = %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package secrets
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"QLP/internal/config"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+var (
+	defaultMu       sync.RWMutex
+	defaultResolver *Resolver
+)
+
+// Default returns the resolver configured by InitFromEnv, or nil
+func Default() *Resolver {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultResolver
+}
+
+// SetDefault replaces the process-wide resolver
+func SetDefault(r *Resolver) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultResolver = r
+}
+
+// InitFromEnv builds the resolver chain named by QLP_SECRET_SOURCES
+// (comma-separated, in priority order: keyvault, tenant, env) and installs it
+// as the default
+func InitFromEnv() (*Resolver, error) {
+	var sources []Source
+	for _, name := range strings.Split(config.GetEnvOrDefault("QLP_SECRET_SOURCES", "tenant,env"), ",") {
+		switch strings.TrimSpace(name) {
+		case "keyvault":
+			vaultURL := config.GetEnvOrDefault("QLP_KEYVAULT_URL", "")
+			if vaultURL == "" {
+				return nil, fmt.Errorf("QLP_KEYVAULT_URL is required for the keyvault secret source")
+			}
+			credential, err := azidentity.NewDefaultAzureCredential(nil)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create Azure credential: %w", err)
+			}
+			sources = append(sources, NewKeyVaultSource(vaultURL, credential))
+		case "tenant":
+			path := config.GetEnvOrDefault("QLP_TENANT_SECRETS_FILE", "")
+			if path == "" {
+				continue
+			}
+			source, err := LoadTenantSource(path)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, source)
+		case "env":
+			sources = append(sources, EnvSource{Prefix: config.GetEnvOrDefault("QLP_SECRETS_ENV_PREFIX", "QLP_APP_")})
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown secret source %q", name)
+		}
+	}
+
+	resolver := NewResolver(sources...)
+	SetDefault(resolver)
+	return resolver, nil
+}
@@ -0,0 +1,242 @@
+// Package secrets declares the configuration generated apps need at deploy
+// time, resolves values from Key Vault, tenant config or the environment, and
+// scrubs resolved values from logs and reports.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"QLP/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Requirement is an environment variable a generated app reads at runtime
+type Requirement struct {
+	Name     string   `json:"name"`
+	Secret   bool     `json:"secret"`
+	Required bool     `json:"required"`
+	Files    []string `json:"files,omitempty"`
+}
+
+var (
+	envReferencePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`os\.(?:Getenv|LookupEnv)\("([A-Z][A-Z0-9_]*)"\)`),        // Go
+		regexp.MustCompile(`process\.env\.([A-Z][A-Z0-9_]*)`),                        // Node.js
+		regexp.MustCompile(`process\.env\[["']([A-Z][A-Z0-9_]*)["']\]`),              // Node.js
+		regexp.MustCompile(`os\.(?:getenv|environ\.get)\(["']([A-Z][A-Z0-9_]*)["']`), // Python
+		regexp.MustCompile(`os\.environ\[["']([A-Z][A-Z0-9_]*)["']\]`),               // Python
+		regexp.MustCompile(`\$\{([A-Z][A-Z0-9_]*)(?::?-[^}]*)?\}`),                   // compose, shell
+		regexp.MustCompile(`(?m)^\s*-?\s*name:\s*["']?([A-Z][A-Z0-9_]*)["']?\s*$`),   // Kubernetes env
+	}
+	secretNamePattern = regexp.MustCompile(`(?i)(PASSWORD|PASSWD|SECRET|TOKEN|API_?KEY|PRIVATE_?KEY|CREDENTIAL|DSN|CONNECTION_STRING|DATABASE_URL|DB_URL|REDIS_URL|MONGO)`)
+
+	// optionalVars have sensible defaults in every runtime we generate for
+	optionalVars = map[string]bool{
+		"PORT": true, "HOST": true, "NODE_ENV": true, "GIN_MODE": true, "LOG_LEVEL": true,
+		"ENV": true, "ENVIRONMENT": true, "HOME": true, "PATH": true, "PWD": true, "DEBUG": true,
+	}
+)
+
+// DetectRequirements scans generated files for environment variable references
+func DetectRequirements(files map[string]string) []Requirement {
+	found := make(map[string]*Requirement)
+
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		for _, pattern := range envReferencePatterns {
+			for _, match := range pattern.FindAllStringSubmatch(files[p], -1) {
+				name := match[1]
+				req, ok := found[name]
+				if !ok {
+					req = &Requirement{
+						Name:     name,
+						Secret:   secretNamePattern.MatchString(name),
+						Required: !optionalVars[name],
+					}
+					found[name] = req
+				}
+				if len(req.Files) == 0 || req.Files[len(req.Files)-1] != p {
+					req.Files = append(req.Files, p)
+				}
+			}
+		}
+	}
+
+	requirements := make([]Requirement, 0, len(found))
+	for _, req := range found {
+		requirements = append(requirements, *req)
+	}
+	sort.Slice(requirements, func(i, j int) bool { return requirements[i].Name < requirements[j].Name })
+	return requirements
+}
+
+// Source looks up configuration values for a tenant
+type Source interface {
+	Name() string
+	Lookup(ctx context.Context, tenantID, name string) (string, bool, error)
+}
+
+// Resolution holds the values resolved for a set of requirements. Values are
+// never serialized; only the source of each value is reported.
+type Resolution struct {
+	Values  map[string]string `json:"-"`
+	Sources map[string]string `json:"sources"`
+	Missing []string          `json:"missing,omitempty"`
+}
+
+// Env returns the resolved values as sorted KEY=VALUE pairs
+func (r *Resolution) Env() []string {
+	env := make([]string, 0, len(r.Values))
+	for name, value := range r.Values {
+		env = append(env, name+"="+value)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// Resolver resolves requirements from an ordered chain of sources; the first
+// source with a value wins
+type Resolver struct {
+	sources []Source
+}
+
+func NewResolver(sources ...Source) *Resolver {
+	return &Resolver{sources: sources}
+}
+
+// With returns a resolver that consults sources before r's own
+func (r *Resolver) With(sources ...Source) *Resolver {
+	chained := append([]Source{}, sources...)
+	if r != nil {
+		chained = append(chained, r.sources...)
+	}
+	return &Resolver{sources: chained}
+}
+
+// Resolve looks up every requirement for a tenant. Resolved secret values are
+// registered with the default scrubber before they are returned.
+func (r *Resolver) Resolve(ctx context.Context, tenantID string, requirements []Requirement) *Resolution {
+	resolution := &Resolution{
+		Values:  make(map[string]string),
+		Sources: make(map[string]string),
+	}
+
+	for _, req := range requirements {
+		resolved := false
+		for _, source := range r.sources {
+			value, ok, err := source.Lookup(ctx, tenantID, req.Name)
+			if err != nil {
+				logger.WithComponent("secrets").Warn("Secret source lookup failed",
+					zap.String("source", source.Name()),
+					zap.String("name", req.Name),
+					zap.Error(err))
+				continue
+			}
+			if !ok {
+				continue
+			}
+			if req.Secret {
+				Register(value)
+			}
+			resolution.Values[req.Name] = value
+			resolution.Sources[req.Name] = source.Name()
+			resolved = true
+			break
+		}
+		if !resolved && req.Required {
+			resolution.Missing = append(resolution.Missing, req.Name)
+		}
+	}
+
+	logger.WithComponent("secrets").Info("Resolved deployment configuration",
+		zap.String("tenant_id", tenantID),
+		zap.Int("resolved", len(resolution.Values)),
+		zap.Strings("missing", resolution.Missing))
+	return resolution
+}
+
+// Scrubber replaces registered secret values with a mask
+type Scrubber struct {
+	mu       sync.RWMutex
+	values   map[string]bool
+	replacer *strings.Replacer
+}
+
+const scrubMask = "[REDACTED]"
+
+// minScrubLength avoids masking short values that would corrupt unrelated text
+const minScrubLength = 4
+
+func NewScrubber() *Scrubber {
+	return &Scrubber{values: make(map[string]bool)}
+}
+
+// Add registers values to be masked
+func (s *Scrubber) Add(values ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := false
+	for _, v := range values {
+		if len(v) >= minScrubLength && !s.values[v] {
+			s.values[v] = true
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+
+	// Replace longer values first so a secret containing another is fully masked
+	sorted := make([]string, 0, len(s.values))
+	for v := range s.values {
+		sorted = append(sorted, v)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	pairs := make([]string, 0, 2*len(sorted))
+	for _, v := range sorted {
+		pairs = append(pairs, v, scrubMask)
+	}
+	s.replacer = strings.NewReplacer(pairs...)
+}
+
+// Scrub masks every registered value in text
+func (s *Scrubber) Scrub(text string) string {
+	s.mu.RLock()
+	replacer := s.replacer
+	s.mu.RUnlock()
+	if replacer == nil {
+		return text
+	}
+	return replacer.Replace(text)
+}
+
+var defaultScrubber = NewScrubber()
+
+// Register adds values to the process-wide scrubber used for logs and reports
+func Register(values ...string) {
+	defaultScrubber.Add(values...)
+}
+
+// Scrub masks registered secret values in text
+func Scrub(text string) string {
+	return defaultScrubber.Scrub(text)
+}
+
+// MissingError describes required configuration that no source provided
+func MissingError(missing []string) error {
+	if len(missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("missing required configuration: %s", strings.Join(missing, ", "))
+}
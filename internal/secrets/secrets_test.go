@@ -0,0 +1,66 @@
+package secrets
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDetectRequirements(t *testing.T) {
+	files := map[string]string{
+		"cmd/main.go":        `dsn := os.Getenv("DATABASE_URL"); port := os.Getenv("PORT")`,
+		"web/server.js":      `const key = process.env.STRIPE_API_KEY; const region = process.env["REGION"]`,
+		"docker-compose.yml": "environment:\n  - DATABASE_URL=${DATABASE_URL}\n",
+	}
+
+	requirements := DetectRequirements(files)
+	byName := make(map[string]Requirement)
+	for _, r := range requirements {
+		byName[r.Name] = r
+	}
+
+	if len(requirements) != 4 {
+		t.Fatalf("expected 4 requirements, got %+v", requirements)
+	}
+	if r := byName["DATABASE_URL"]; !r.Secret || !r.Required || len(r.Files) != 2 {
+		t.Errorf("unexpected DATABASE_URL requirement %+v", r)
+	}
+	if r := byName["PORT"]; r.Secret || r.Required {
+		t.Errorf("PORT should be optional and not secret, got %+v", r)
+	}
+	if r := byName["REGION"]; r.Secret || !r.Required {
+		t.Errorf("unexpected REGION requirement %+v", r)
+	}
+}
+
+func TestResolveAndScrub(t *testing.T) {
+	t.Setenv("TEST_APP_DATABASE_URL", "postgres://app:hunter22@db/app")
+	tenant := &TenantSource{values: map[string]map[string]string{
+		"acme": {"REGION": "westeurope"},
+	}}
+	resolver := NewResolver(tenant, EnvSource{Prefix: "TEST_APP_"})
+
+	resolution := resolver.Resolve(context.Background(), "acme", []Requirement{
+		{Name: "DATABASE_URL", Secret: true, Required: true},
+		{Name: "REGION", Required: true},
+		{Name: "STRIPE_API_KEY", Secret: true, Required: true},
+	})
+
+	if resolution.Sources["DATABASE_URL"] != "env" || resolution.Sources["REGION"] != "tenant" {
+		t.Errorf("unexpected sources %v", resolution.Sources)
+	}
+	if len(resolution.Missing) != 1 || resolution.Missing[0] != "STRIPE_API_KEY" {
+		t.Errorf("expected STRIPE_API_KEY missing, got %v", resolution.Missing)
+	}
+
+	scrubbed := Scrub("connecting to postgres://app:hunter22@db/app in westeurope")
+	if strings.Contains(scrubbed, "hunter22") || !strings.Contains(scrubbed, "westeurope") {
+		t.Errorf("unexpected scrub result %q", scrubbed)
+	}
+}
+
+func TestKeyVaultSecretName(t *testing.T) {
+	if got := KeyVaultSecretName("DATABASE_URL"); got != "database-url" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,140 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// EnvSource reads values from the process environment under a prefix, so
+// QLP_APP_DATABASE_URL provides DATABASE_URL
+type EnvSource struct {
+	Prefix string
+}
+
+func (s EnvSource) Name() string { return "env" }
+
+func (s EnvSource) Lookup(ctx context.Context, tenantID, name string) (string, bool, error) {
+	value, ok := os.LookupEnv(s.Prefix + name)
+	return value, ok, nil
+}
+
+// TenantSource serves per-tenant values from a JSON file of the form
+// {"tenant-id": {"NAME": "value"}}; the "*" tenant applies to all tenants
+type TenantSource struct {
+	values map[string]map[string]string
+}
+
+// LoadTenantSource reads tenant configuration from path
+func LoadTenantSource(path string) (*TenantSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenant secrets: %w", err)
+	}
+	var values map[string]map[string]string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse tenant secrets: %w", err)
+	}
+	return &TenantSource{values: values}, nil
+}
+
+func (s *TenantSource) Name() string { return "tenant" }
+
+func (s *TenantSource) Lookup(ctx context.Context, tenantID, name string) (string, bool, error) {
+	if value, ok := s.values[tenantID][name]; ok {
+		return value, true, nil
+	}
+	value, ok := s.values["*"][name]
+	return value, ok, nil
+}
+
+// KeyVaultSource reads secrets from Azure Key Vault. Variable names are mapped
+// to vault secret names by lowercasing and replacing underscores with dashes;
+// when a tenant is set, "<tenant>--<name>" is tried before the shared name.
+type KeyVaultSource struct {
+	vaultURL   string
+	credential azcore.TokenCredential
+	client     *http.Client
+}
+
+const keyVaultAPIVersion = "7.4"
+
+func NewKeyVaultSource(vaultURL string, credential azcore.TokenCredential) *KeyVaultSource {
+	return &KeyVaultSource{
+		vaultURL:   strings.TrimSuffix(vaultURL, "/"),
+		credential: credential,
+		client:     &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (s *KeyVaultSource) Name() string { return "keyvault" }
+
+var keyVaultNameInvalid = regexp.MustCompile(`[^a-z0-9-]`)
+
+// KeyVaultSecretName maps an environment variable name to a valid vault secret name
+func KeyVaultSecretName(name string) string {
+	return keyVaultNameInvalid.ReplaceAllString(strings.ReplaceAll(strings.ToLower(name), "_", "-"), "")
+}
+
+func (s *KeyVaultSource) Lookup(ctx context.Context, tenantID, name string) (string, bool, error) {
+	names := []string{KeyVaultSecretName(name)}
+	if tenantID != "" {
+		names = append([]string{KeyVaultSecretName(tenantID) + "--" + names[0]}, names...)
+	}
+
+	for _, secretName := range names {
+		value, ok, err := s.get(ctx, secretName)
+		if err != nil || ok {
+			return value, ok, err
+		}
+	}
+	return "", false, nil
+}
+
+func (s *KeyVaultSource) get(ctx context.Context, secretName string) (string, bool, error) {
+	token, err := s.credential.GetToken(ctx, policy.TokenRequestOptions{
+		Scopes: []string{"https://vault.azure.net/.default"},
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get Key Vault token: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/secrets/%s?api-version=%s", s.vaultURL, url.PathEscape(secretName), keyVaultAPIVersion)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", false, fmt.Errorf("Key Vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", false, fmt.Errorf("Key Vault returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var secret struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", false, fmt.Errorf("failed to decode Key Vault secret: %w", err)
+	}
+	return secret.Value, true, nil
+}
@@ -0,0 +1,298 @@
+package stacks
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultGroupID is the Maven group of scaffolded projects
+const DefaultGroupID = "com.example"
+
+var (
+	javaPackageDecl = regexp.MustCompile(`(?m)^\s*package\s+([\w.]+)\s*;`)
+	javaTypeDecl    = regexp.MustCompile(`(?m)^\s*public\s+(?:(?:final|abstract|sealed|static)\s+)*(?:class|interface|enum|record|@interface)\s+(\w+)`)
+	javaTestMarker  = regexp.MustCompile(`@(Test|ParameterizedTest|SpringBootTest|WebMvcTest|DataJpaTest)\b`)
+)
+
+// IsJavaProject reports whether files hold a Maven or Gradle build or Java sources
+func IsJavaProject(files map[string]string) bool {
+	for p := range files {
+		switch path.Base(p) {
+		case "pom.xml", "build.gradle", "build.gradle.kts":
+			return true
+		}
+		if strings.HasSuffix(p, ".java") {
+			return true
+		}
+	}
+	return false
+}
+
+// JavaSourcePath returns where a Java file belongs in the Maven layout: the
+// directory of its package declaration under src/main/java, or src/test/java
+// for tests, named after its public type. Paths already under src/ that are
+// named after the type are kept.
+func JavaSourcePath(original, content string) string {
+	name := path.Base(original)
+	if m := javaTypeDecl.FindStringSubmatch(content); m != nil {
+		name = m[1] + ".java"
+	}
+	if (strings.HasPrefix(original, "src/main/java/") || strings.HasPrefix(original, "src/test/java/")) && path.Base(original) == name {
+		return original
+	}
+	root := "src/main/java"
+	if javaTestMarker.MatchString(content) {
+		root = "src/test/java"
+	}
+	if m := javaPackageDecl.FindStringSubmatch(content); m != nil {
+		return path.Join(root, strings.ReplaceAll(m[1], ".", "/"), name)
+	}
+	return path.Join(root, name)
+}
+
+// ScaffoldJava returns the files a generated Spring Boot project is missing:
+// the build file, the @SpringBootApplication class, application.properties
+// and a Dockerfile. Existing files are never replaced. buildTool is used only
+// when the project has no build file yet.
+func ScaffoldJava(files map[string]string, artifact, buildTool string) map[string]string {
+	artifact = artifactID(artifact)
+	added := make(map[string]string)
+
+	hasBuild := false
+	for p := range files {
+		switch path.Base(p) {
+		case "build.gradle", "build.gradle.kts":
+			hasBuild = true
+			buildTool = BuildGradle
+		case "pom.xml":
+			hasBuild = true
+			buildTool = BuildMaven
+		}
+	}
+	if !hasBuild {
+		if buildTool == BuildGradle {
+			added["build.gradle"] = gradleBuild()
+			added["settings.gradle"] = fmt.Sprintf("rootProject.name = '%s'\n", artifact)
+		} else {
+			added["pom.xml"] = mavenPOM(artifact)
+		}
+	}
+
+	pkg := basePackage(files, artifact)
+	hasApplication, hasProperties := false, false
+	for p, content := range files {
+		if strings.HasSuffix(p, ".java") && strings.Contains(content, "@SpringBootApplication") {
+			hasApplication = true
+		}
+		if strings.HasPrefix(path.Base(p), "application.") && strings.Contains(p, "src/main/resources/") {
+			hasProperties = true
+		}
+	}
+	if !hasApplication {
+		added[path.Join("src/main/java", strings.ReplaceAll(pkg, ".", "/"), "Application.java")] = applicationClass(pkg)
+	}
+	if !hasProperties {
+		added["src/main/resources/application.properties"] = fmt.Sprintf(
+			"spring.application.name=%s\nserver.port=8080\nmanagement.endpoints.web.exposure.include=health\nmanagement.endpoint.health.probes.enabled=true\n", artifact)
+	}
+	if _, ok := files["Dockerfile"]; !ok {
+		added["Dockerfile"] = JavaDockerfile(buildTool)
+	}
+	return added
+}
+
+// JavaDockerfile builds the Spring Boot jar in a builder stage and runs it
+// on a JRE as a non-root user
+func JavaDockerfile(buildTool string) string {
+	build := `FROM maven:3.9-eclipse-temurin-21 AS build
+WORKDIR /src
+COPY pom.xml .
+RUN mvn -q -B dependency:go-offline
+COPY src ./src
+RUN mvn -q -B package -DskipTests
+`
+	jar := "/src/target/*.jar"
+	if buildTool == BuildGradle {
+		build = `FROM gradle:8-jdk21 AS build
+WORKDIR /src
+COPY . .
+RUN gradle --no-daemon -q bootJar
+`
+		jar = "/src/build/libs/*.jar"
+	}
+	return build + `
+FROM eclipse-temurin:21-jre
+RUN apt-get update && apt-get install -y --no-install-recommends curl && rm -rf /var/lib/apt/lists/*
+WORKDIR /app
+COPY --from=build ` + jar + ` app.jar
+USER 65532:65532
+EXPOSE 8080
+HEALTHCHECK --interval=30s --timeout=5s CMD curl -fs http://localhost:8080/actuator/health || exit 1
+ENTRYPOINT ["java", "-jar", "/app/app.jar"]
+`
+}
+
+// basePackage is the shortest package among the Java sources, so the
+// application class scans all of them; projects without sources get
+// com.example.<artifact>
+func basePackage(files map[string]string, artifact string) string {
+	var packages []string
+	for p, content := range files {
+		if !strings.HasSuffix(p, ".java") || javaTestMarker.MatchString(content) {
+			continue
+		}
+		if m := javaPackageDecl.FindStringSubmatch(content); m != nil {
+			packages = append(packages, m[1])
+		}
+	}
+	if len(packages) == 0 {
+		return DefaultGroupID + "." + strings.ReplaceAll(artifact, "-", "")
+	}
+	sort.Strings(packages)
+	base := packages[0]
+	for _, pkg := range packages[1:] {
+		for base != "" && pkg != base && !strings.HasPrefix(pkg, base+".") {
+			i := strings.LastIndex(base, ".")
+			if i < 0 {
+				base = ""
+				break
+			}
+			base = base[:i]
+		}
+	}
+	if base == "" {
+		return packages[0]
+	}
+	return base
+}
+
+var nonArtifactChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// artifactID turns a project name into a Maven artifact ID
+func artifactID(name string) string {
+	id := strings.Trim(nonArtifactChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if id == "" || id[0] >= '0' && id[0] <= '9' {
+		id = "app" + id
+	}
+	return id
+}
+
+func applicationClass(pkg string) string {
+	return fmt.Sprintf(`package %s;
+
+import org.springframework.boot.SpringApplication;
+import org.springframework.boot.autoconfigure.SpringBootApplication;
+
+@SpringBootApplication
+public class Application {
+
+    public static void main(String[] args) {
+        SpringApplication.run(Application.class, args);
+    }
+}
+`, pkg)
+}
+
+func mavenPOM(artifact string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<project xmlns="http://maven.apache.org/POM/4.0.0"
+         xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"
+         xsi:schemaLocation="http://maven.apache.org/POM/4.0.0 https://maven.apache.org/xsd/maven-4.0.0.xsd">
+    <modelVersion>4.0.0</modelVersion>
+
+    <parent>
+        <groupId>org.springframework.boot</groupId>
+        <artifactId>spring-boot-starter-parent</artifactId>
+        <version>3.3.5</version>
+        <relativePath/>
+    </parent>
+
+    <groupId>%s</groupId>
+    <artifactId>%s</artifactId>
+    <version>0.0.1-SNAPSHOT</version>
+
+    <properties>
+        <java.version>21</java.version>
+    </properties>
+
+    <dependencies>
+        <dependency>
+            <groupId>org.springframework.boot</groupId>
+            <artifactId>spring-boot-starter-web</artifactId>
+        </dependency>
+        <dependency>
+            <groupId>org.springframework.boot</groupId>
+            <artifactId>spring-boot-starter-validation</artifactId>
+        </dependency>
+        <dependency>
+            <groupId>org.springframework.boot</groupId>
+            <artifactId>spring-boot-starter-actuator</artifactId>
+        </dependency>
+        <dependency>
+            <groupId>org.springframework.boot</groupId>
+            <artifactId>spring-boot-starter-test</artifactId>
+            <scope>test</scope>
+        </dependency>
+    </dependencies>
+
+    <build>
+        <plugins>
+            <plugin>
+                <groupId>org.springframework.boot</groupId>
+                <artifactId>spring-boot-maven-plugin</artifactId>
+            </plugin>
+        </plugins>
+    </build>
+</project>
+`, DefaultGroupID, artifact)
+}
+
+func gradleBuild() string {
+	return fmt.Sprintf(`plugins {
+    id 'java'
+    id 'jacoco'
+    id 'org.springframework.boot' version '3.3.5'
+    id 'io.spring.dependency-management' version '1.1.6'
+}
+
+group = '%s'
+version = '0.0.1-SNAPSHOT'
+
+java {
+    toolchain {
+        languageVersion = JavaLanguageVersion.of(21)
+    }
+}
+
+repositories {
+    mavenCentral()
+}
+
+dependencies {
+    implementation 'org.springframework.boot:spring-boot-starter-web'
+    implementation 'org.springframework.boot:spring-boot-starter-validation'
+    implementation 'org.springframework.boot:spring-boot-starter-actuator'
+    testImplementation 'org.springframework.boot:spring-boot-starter-test'
+    testRuntimeOnly 'org.junit.platform:junit-platform-launcher'
+}
+
+tasks.named('test') {
+    useJUnitPlatform()
+    finalizedBy jacocoTestReport
+}
+
+jacocoTestReport {
+    reports {
+        csv.required = true
+    }
+}
+
+// Only the executable boot jar is built
+jar {
+    enabled = false
+}
+`, DefaultGroupID)
+}
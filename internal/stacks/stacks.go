@@ -0,0 +1,139 @@
+// Package stacks chooses the technology stack generated code targets: Go by
+// default, or Java with Spring Boot when the intent, the tenant's
+// constraints or the deployment default ask for it. A stack carries the
+// guidance agents follow per task type and scaffolds the build files a
+// generated project is missing.
+package stacks
+
+import (
+	"regexp"
+	"strings"
+
+	"QLP/internal/config"
+	"QLP/internal/models"
+)
+
+// Stack names
+const (
+	Go         = "go"
+	JavaSpring = "java-spring"
+)
+
+// Build tools
+const (
+	BuildGo     = "go"
+	BuildMaven  = "maven"
+	BuildGradle = "gradle"
+)
+
+// Stack is a generation target
+type Stack struct {
+	Name      string   `json:"name"`
+	Language  string   `json:"language"`
+	BuildTool string   `json:"build_tool"`
+	TechStack []string `json:"tech_stack"`
+	// Guidance is added to the prompts of tasks of each type; task types
+	// without an entry get the generic instructions
+	Guidance map[models.TaskType]string `json:"-"`
+}
+
+// GoStack is the default stack
+func GoStack() Stack {
+	return Stack{
+		Name:      Go,
+		Language:  "go",
+		BuildTool: BuildGo,
+		TechStack: []string{"Go", "HTTP", "JSON"},
+	}
+}
+
+// JavaSpringStack is Java 21 with Spring Boot 3, built with Maven or Gradle
+func JavaSpringStack(buildTool string) Stack {
+	if buildTool != BuildGradle {
+		buildTool = BuildMaven
+	}
+	buildFile, buildCmd := "pom.xml", "mvn -q -B package"
+	if buildTool == BuildGradle {
+		buildFile, buildCmd = "build.gradle and settings.gradle", "gradle --no-daemon -q build"
+	}
+	return Stack{
+		Name:      JavaSpring,
+		Language:  "java",
+		BuildTool: buildTool,
+		TechStack: []string{"Java 21", "Spring Boot 3", buildToolNames[buildTool], "JUnit 5"},
+		Guidance: map[models.TaskType]string{
+			models.TaskTypeCodegen: `
+TARGET STACK: Java 21 with Spring Boot 3, built with ` + buildTool + ` (` + buildCmd + ` must succeed).
+- ` + buildFile + ` using the Spring Boot plugin with spring-boot-starter-web, spring-boot-starter-validation, spring-boot-starter-actuator and spring-boot-starter-test
+- Sources under src/main/java/<package path>/, one public class per file named after the class
+- One @SpringBootApplication class; REST endpoints in @RestController classes, logic in @Service classes
+- src/main/resources/application.properties with server.port=8080 and management.endpoints.web.exposure.include=health
+- The health endpoint is /actuator/health
+`,
+			models.TaskTypeTest: `
+TARGET STACK: JUnit 5 tests for a Spring Boot 3 application.
+- Tests under src/test/java/<package path>/, in the package of the class under test, named <Class>Test
+- @WebMvcTest with MockMvc for controllers, plain unit tests for services, one @SpringBootTest that loads the context
+- AssertJ or JUnit assertions; no network or database access
+`,
+			models.TaskTypeInfra: `
+TARGET STACK: a Spring Boot jar built with ` + buildTool + `.
+- Multi-stage Dockerfile: build with ` + builderImage(buildTool) + `, run with eclipse-temurin:21-jre as a non-root user
+- EXPOSE 8080 and a HEALTHCHECK on /actuator/health
+- Kubernetes probes on /actuator/health/liveness and /actuator/health/readiness
+`,
+		},
+	}
+}
+
+var buildToolNames = map[string]string{BuildMaven: "Maven", BuildGradle: "Gradle"}
+
+var (
+	javaMention   = regexp.MustCompile(`(?i)\b(java|spring|spring boot|springboot|maven|gradle|jvm|junit)\b`)
+	gradleMention = regexp.MustCompile(`(?i)\bgradle\b`)
+)
+
+// Resolve returns the stack for an intent. Java is chosen when the
+// constraints allow Java or prefer Spring, or when the intent names Java,
+// Spring, Maven or Gradle; otherwise QLP_DEFAULT_STACK (go or java) applies.
+func Resolve(c *models.Constraints, intentText string) Stack {
+	var terms []string
+	if c != nil {
+		terms = append(append(terms, c.Languages...), c.Frameworks...)
+	}
+	buildTool := BuildMaven
+	if gradleMention.MatchString(intentText) || gradleMention.MatchString(strings.Join(terms, " ")) {
+		buildTool = BuildGradle
+	}
+
+	if c != nil && len(c.Languages) > 0 {
+		for _, l := range c.Languages {
+			if strings.EqualFold(strings.TrimSpace(l), "java") {
+				return JavaSpringStack(buildTool)
+			}
+		}
+		// Languages are limited to ones other than Java
+		return GoStack()
+	}
+	if javaMention.MatchString(strings.Join(terms, " ")) || javaMention.MatchString(intentText) {
+		return JavaSpringStack(buildTool)
+	}
+	return Default()
+}
+
+// Default returns the stack set by QLP_DEFAULT_STACK, Go unless it is java
+func Default() Stack {
+	switch strings.ToLower(config.GetEnvOrDefault("QLP_DEFAULT_STACK", Go)) {
+	case "java", JavaSpring:
+		return JavaSpringStack(config.GetEnvOrDefault("QLP_JAVA_BUILD_TOOL", BuildMaven))
+	default:
+		return GoStack()
+	}
+}
+
+func builderImage(buildTool string) string {
+	if buildTool == BuildGradle {
+		return "gradle:8-jdk21"
+	}
+	return "maven:3.9-eclipse-temurin-21"
+}
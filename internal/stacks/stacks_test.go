@@ -0,0 +1,144 @@
+package stacks
+
+import (
+	"strings"
+	"testing"
+
+	"QLP/internal/models"
+)
+
+func TestResolve(t *testing.T) {
+	t.Setenv("QLP_DEFAULT_STACK", "go")
+
+	tests := []struct {
+		name        string
+		constraints *models.Constraints
+		intent      string
+		stack       string
+		buildTool   string
+	}{
+		{"default", nil, "Build a REST API for todos", Go, BuildGo},
+		{"intent names spring", nil, "Build a Spring Boot REST API for orders", JavaSpring, BuildMaven},
+		{"intent names gradle", nil, "A Java service built with Gradle", JavaSpring, BuildGradle},
+		{"javascript is not java", nil, "Build a JavaScript frontend", Go, BuildGo},
+		{"constraint allows java", &models.Constraints{Languages: []string{"Java"}}, "Build a REST API", JavaSpring, BuildMaven},
+		{"constraint excludes java", &models.Constraints{Languages: []string{"go"}}, "Build a Spring REST API", Go, BuildGo},
+		{"framework preference", &models.Constraints{Frameworks: []string{"spring-boot"}}, "Build a REST API", JavaSpring, BuildMaven},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Resolve(tt.constraints, tt.intent)
+			if s.Name != tt.stack || s.BuildTool != tt.buildTool {
+				t.Errorf("Resolve = %s/%s, want %s/%s", s.Name, s.BuildTool, tt.stack, tt.buildTool)
+			}
+		})
+	}
+}
+
+func TestResolveDefaultStack(t *testing.T) {
+	t.Setenv("QLP_DEFAULT_STACK", "java")
+	t.Setenv("QLP_JAVA_BUILD_TOOL", "gradle")
+
+	s := Resolve(nil, "Build a REST API")
+	if s.Name != JavaSpring || s.BuildTool != BuildGradle {
+		t.Errorf("Resolve = %s/%s, want java-spring/gradle", s.Name, s.BuildTool)
+	}
+	if s.Guidance[models.TaskTypeCodegen] == "" || s.Guidance[models.TaskTypeTest] == "" {
+		t.Error("Java stack has no codegen or test guidance")
+	}
+}
+
+func TestJavaSourcePath(t *testing.T) {
+	tests := []struct {
+		original, content, want string
+	}{
+		{"OrderController.java", "package com.acme.orders;\n\n@RestController\npublic class OrderController {}",
+			"src/main/java/com/acme/orders/OrderController.java"},
+		{"controller.java", "package com.acme.orders;\npublic final class OrderController {}",
+			"src/main/java/com/acme/orders/OrderController.java"},
+		{"OrderControllerTest.java", "package com.acme.orders;\nclass OrderControllerTest {\n  @Test\n  void lists() {}\n}",
+			"src/test/java/com/acme/orders/OrderControllerTest.java"},
+		{"Main.java", "public record Point(int x, int y) {}", "src/main/java/Point.java"},
+		{"src/main/java/com/acme/App.java", "package com.other;", "src/main/java/com/acme/App.java"},
+		{"src/main/java/Main_2.java", "package com.acme;\npublic class App {}", "src/main/java/com/acme/App.java"},
+	}
+	for _, tt := range tests {
+		if got := JavaSourcePath(tt.original, tt.content); got != tt.want {
+			t.Errorf("JavaSourcePath(%q) = %q, want %q", tt.original, got, tt.want)
+		}
+	}
+}
+
+func TestScaffoldJavaMaven(t *testing.T) {
+	files := map[string]string{
+		"src/main/java/com/acme/orders/web/OrderController.java":     "package com.acme.orders.web;\npublic class OrderController {}",
+		"src/main/java/com/acme/orders/service/OrderService.java":    "package com.acme.orders.service;\npublic class OrderService {}",
+		"src/test/java/com/acme/orders/web/OrderControllerTest.java": "package com.acme.orders.web;\nclass OrderControllerTest { @Test void x() {} }",
+		"src/main/resources/application.properties":                  "server.port=9090\n",
+	}
+	added := ScaffoldJava(files, "Order Service", BuildMaven)
+
+	pom, ok := added["pom.xml"]
+	if !ok {
+		t.Fatalf("no pom.xml in %v", keys(added))
+	}
+	for _, want := range []string{"<artifactId>order-service</artifactId>", "spring-boot-starter-web", "spring-boot-starter-test", "<java.version>21</java.version>"} {
+		if !strings.Contains(pom, want) {
+			t.Errorf("pom.xml missing %q", want)
+		}
+	}
+	app, ok := added["src/main/java/com/acme/orders/Application.java"]
+	if !ok {
+		t.Fatalf("no application class in the common package: %v", keys(added))
+	}
+	if !strings.Contains(app, "package com.acme.orders;") || !strings.Contains(app, "@SpringBootApplication") {
+		t.Errorf("application class = %s", app)
+	}
+	if _, ok := added["src/main/resources/application.properties"]; ok {
+		t.Error("existing application.properties replaced")
+	}
+	dockerfile := added["Dockerfile"]
+	if !strings.Contains(dockerfile, "mvn -q -B package -DskipTests") || !strings.Contains(dockerfile, "USER 65532:65532") {
+		t.Errorf("Dockerfile = %s", dockerfile)
+	}
+}
+
+func TestScaffoldJavaKeepsExistingBuild(t *testing.T) {
+	files := map[string]string{
+		"build.gradle":                    "plugins { id 'java' }",
+		"src/main/java/com/acme/App.java": "package com.acme;\n@SpringBootApplication\npublic class App {}",
+		"Dockerfile":                      "FROM scratch",
+	}
+	added := ScaffoldJava(files, "acme", BuildMaven)
+	for _, p := range []string{"pom.xml", "build.gradle", "settings.gradle", "Dockerfile"} {
+		if _, ok := added[p]; ok {
+			t.Errorf("%s scaffolded although the project has a build", p)
+		}
+	}
+	for p := range added {
+		if strings.HasSuffix(p, ".java") {
+			t.Errorf("second application class %s", p)
+		}
+	}
+	if got := JavaDockerfile(BuildGradle); !strings.Contains(got, "gradle --no-daemon -q bootJar") {
+		t.Errorf("Gradle Dockerfile = %s", got)
+	}
+}
+
+func TestScaffoldJavaEmpty(t *testing.T) {
+	added := ScaffoldJava(map[string]string{}, "2fa", BuildGradle)
+	if !strings.Contains(added["settings.gradle"], "'app2fa'") {
+		t.Errorf("settings.gradle = %q", added["settings.gradle"])
+	}
+	if _, ok := added["src/main/java/com/example/app2fa/Application.java"]; !ok {
+		t.Errorf("no default application class: %v", keys(added))
+	}
+}
+
+func keys(m map[string]string) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
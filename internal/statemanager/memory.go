@@ -0,0 +1,102 @@
+package statemanager
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// MemoryStateManager keeps DAG state in process, for single-instance runs and tests
+type MemoryStateManager struct {
+	ttl time.Duration
+
+	mu     sync.Mutex
+	states map[string]memoryEntry
+	locks  map[string]memoryLease
+}
+
+type memoryEntry struct {
+	data      []byte // JSON, so callers never share maps with the store
+	version   int64
+	expiresAt time.Time
+}
+
+type memoryLease struct {
+	owner     string
+	expiresAt time.Time
+}
+
+// NewMemoryStateManager keeps state for ttl after each write
+func NewMemoryStateManager(ttl time.Duration) *MemoryStateManager {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &MemoryStateManager{ttl: ttl, states: make(map[string]memoryEntry), locks: make(map[string]memoryLease)}
+}
+
+func (m *MemoryStateManager) Get(_ context.Context, intentID string) (*DAGState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.states[intentID]
+	if !ok || time.Now().After(entry.expiresAt) {
+		delete(m.states, intentID)
+		return nil, ErrNotFound
+	}
+	var state DAGState
+	if err := json.Unmarshal(entry.data, &state); err != nil {
+		return nil, err
+	}
+	state.Version = entry.version
+	return &state, nil
+}
+
+func (m *MemoryStateManager) Put(_ context.Context, state *DAGState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	current := m.states[state.IntentID]
+	if now.After(current.expiresAt) {
+		current = memoryEntry{}
+	}
+	if current.version != state.Version {
+		return ErrConflict
+	}
+
+	state.UpdatedAt = now
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	state.Version++
+	m.states[state.IntentID] = memoryEntry{data: data, version: state.Version, expiresAt: now.Add(m.ttl)}
+	return nil
+}
+
+func (m *MemoryStateManager) Delete(_ context.Context, intentID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.states, intentID)
+	return nil
+}
+
+func (m *MemoryStateManager) Lock(_ context.Context, intentID, owner string, ttl time.Duration) (func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if lease, ok := m.locks[intentID]; ok && lease.owner != owner && now.Before(lease.expiresAt) {
+		return nil, ErrLocked
+	}
+	m.locks[intentID] = memoryLease{owner: owner, expiresAt: now.Add(ttl)}
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.locks[intentID].owner == owner {
+			delete(m.locks, intentID)
+		}
+	}, nil
+}
@@ -0,0 +1,80 @@
+package statemanager
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"QLP/internal/models"
+)
+
+func TestPutRejectsStaleVersions(t *testing.T) {
+	ctx := context.Background()
+	sm := NewMemoryStateManager(time.Hour)
+
+	state := &DAGState{IntentID: "intent-1", TaskStates: map[string]models.TaskStatus{"a": models.TaskStatusPending}}
+	if err := sm.Put(ctx, state); err != nil || state.Version != 1 {
+		t.Fatalf("first put: version %d, %v", state.Version, err)
+	}
+
+	first, _ := sm.Get(ctx, "intent-1")
+	second, _ := sm.Get(ctx, "intent-1")
+	first.TaskStates["a"] = models.TaskStatusCompleted
+	if err := sm.Put(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	second.TaskStates["a"] = models.TaskStatusFailed
+	if err := sm.Put(ctx, second); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected ErrConflict for a stale write, got %v", err)
+	}
+}
+
+func TestUpdateRetriesConcurrentWriters(t *testing.T) {
+	ctx := context.Background()
+	sm := NewMemoryStateManager(time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := Update(ctx, sm, "intent-1", func(s *DAGState) error {
+				s.TaskStates[string(rune('a'+i))] = models.TaskStatusCompleted
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	state, err := sm.Get(ctx, "intent-1")
+	if err != nil || len(state.TaskStates) != 8 || state.Version != 8 {
+		t.Fatalf("lost updates: %d states at version %d, %v", len(state.TaskStates), state.Version, err)
+	}
+}
+
+func TestStateExpiresAndLocksAreExclusive(t *testing.T) {
+	ctx := context.Background()
+	sm := NewMemoryStateManager(10 * time.Millisecond)
+	sm.Put(ctx, &DAGState{IntentID: "intent-1"})
+	time.Sleep(20 * time.Millisecond)
+	if _, err := sm.Get(ctx, "intent-1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected expired state, got %v", err)
+	}
+
+	unlock, err := sm.Lock(ctx, "intent-1", "worker-1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sm.Lock(ctx, "intent-1", "worker-2", time.Minute); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	unlock()
+	if _, err := sm.Lock(ctx, "intent-1", "worker-2", time.Minute); err != nil {
+		t.Fatalf("lock after release: %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package statemanager
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PostgresStateManager shares DAG state between processes via the dag_state
+// and dag_state_locks tables
+type PostgresStateManager struct {
+	db  *sql.DB
+	ttl time.Duration
+}
+
+// NewPostgresStateManager creates the tables if needed
+func NewPostgresStateManager(db *sql.DB, ttl time.Duration) (*PostgresStateManager, error) {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS dag_state (
+			intent_id VARCHAR(255) PRIMARY KEY,
+			state JSONB NOT NULL,
+			version BIGINT NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS dag_state_locks (
+			intent_id VARCHAR(255) PRIMARY KEY,
+			owner VARCHAR(255) NOT NULL,
+			expires_at TIMESTAMP NOT NULL
+		)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dag_state tables: %w", err)
+	}
+	return &PostgresStateManager{db: db, ttl: ttl}, nil
+}
+
+func (p *PostgresStateManager) Get(ctx context.Context, intentID string) (*DAGState, error) {
+	var data []byte
+	var version int64
+	err := p.db.QueryRowContext(ctx,
+		`SELECT state, version FROM dag_state WHERE intent_id = $1 AND expires_at > $2`,
+		intentID, time.Now()).Scan(&data, &version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dag state: %w", err)
+	}
+
+	var state DAGState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("corrupt dag state for %s: %w", intentID, err)
+	}
+	state.Version = version
+	return &state, nil
+}
+
+func (p *PostgresStateManager) Put(ctx context.Context, state *DAGState) error {
+	now := time.Now()
+	state.UpdatedAt = now
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dag state: %w", err)
+	}
+
+	// Insert new state, or update only when the stored version is the one the
+	// caller read; an expired row counts as absent
+	res, err := p.db.ExecContext(ctx, `
+		INSERT INTO dag_state (intent_id, state, version, updated_at, expires_at)
+		VALUES ($1, $2, $3 + 1, $4, $5)
+		ON CONFLICT (intent_id) DO UPDATE
+		SET state = EXCLUDED.state, version = EXCLUDED.version,
+		    updated_at = EXCLUDED.updated_at, expires_at = EXCLUDED.expires_at
+		WHERE dag_state.version = $3 OR (dag_state.expires_at < $4 AND $3 = 0)`,
+		state.IntentID, data, state.Version, now, now.Add(p.ttl))
+	if err != nil {
+		return fmt.Errorf("failed to store dag state: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to store dag state: %w", err)
+	}
+	if rows == 0 {
+		return ErrConflict
+	}
+	state.Version++
+	return nil
+}
+
+func (p *PostgresStateManager) Delete(ctx context.Context, intentID string) error {
+	if _, err := p.db.ExecContext(ctx, `DELETE FROM dag_state WHERE intent_id = $1`, intentID); err != nil {
+		return fmt.Errorf("failed to delete dag state: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgresStateManager) Lock(ctx context.Context, intentID, owner string, ttl time.Duration) (func(), error) {
+	now := time.Now()
+	res, err := p.db.ExecContext(ctx, `
+		INSERT INTO dag_state_locks (intent_id, owner, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (intent_id) DO UPDATE SET owner = EXCLUDED.owner, expires_at = EXCLUDED.expires_at
+		WHERE dag_state_locks.owner = $2 OR dag_state_locks.expires_at < $4`,
+		intentID, owner, now.Add(ttl), now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock dag state: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock dag state: %w", err)
+	}
+	if rows == 0 {
+		return nil, ErrLocked
+	}
+
+	return func() {
+		p.db.ExecContext(context.Background(),
+			`DELETE FROM dag_state_locks WHERE intent_id = $1 AND owner = $2`, intentID, owner)
+	}, nil
+}
+
+// PurgeExpired removes expired state and leases; call periodically to bound table size
+func (p *PostgresStateManager) PurgeExpired(ctx context.Context) (int64, error) {
+	now := time.Now()
+	res, err := p.db.ExecContext(ctx, `DELETE FROM dag_state WHERE expires_at < $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge dag state: %w", err)
+	}
+	if _, err := p.db.ExecContext(ctx, `DELETE FROM dag_state_locks WHERE expires_at < $1`, now); err != nil {
+		return 0, fmt.Errorf("failed to purge dag state locks: %w", err)
+	}
+	return res.RowsAffected()
+}
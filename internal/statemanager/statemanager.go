@@ -0,0 +1,80 @@
+// Package statemanager persists DAG execution state per intent so several
+// consumers can share it. Every write is a compare-and-swap on a version
+// number, state expires after a TTL, and read-modify-write cycles can be
+// serialized with a lease lock.
+package statemanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"QLP/internal/models"
+)
+
+var (
+	// ErrNotFound is returned for unknown or expired intents
+	ErrNotFound = errors.New("dag state not found")
+	// ErrConflict is returned when the stored version moved since it was read
+	ErrConflict = errors.New("dag state was modified concurrently")
+	// ErrLocked is returned when another owner holds the intent's lock
+	ErrLocked = errors.New("dag state is locked")
+)
+
+// DefaultTTL is how long DAG state is kept after its last write
+const DefaultTTL = 24 * time.Hour
+
+// DAGState is the stored execution state of one intent's task graph
+type DAGState struct {
+	IntentID   string                       `json:"intent_id"`
+	Graph      *models.TaskGraph            `json:"graph,omitempty"`
+	TaskStates map[string]models.TaskStatus `json:"task_states"`
+	Version    int64                        `json:"version"` // 0 for state not yet stored
+	UpdatedAt  time.Time                    `json:"updated_at"`
+}
+
+// StateManager stores DAG state
+type StateManager interface {
+	// Get returns the current state of an intent
+	Get(ctx context.Context, intentID string) (*DAGState, error)
+	// Put stores state if the stored version still equals state.Version,
+	// then increments state.Version. It returns ErrConflict otherwise.
+	Put(ctx context.Context, state *DAGState) error
+	// Delete removes an intent's state
+	Delete(ctx context.Context, intentID string) error
+	// Lock takes an exclusive lease on an intent for ttl. It returns
+	// ErrLocked while another owner holds an unexpired lease; the returned
+	// function releases it.
+	Lock(ctx context.Context, intentID, owner string, ttl time.Duration) (func(), error)
+}
+
+// maxUpdateAttempts bounds the retries of Update under contention
+const maxUpdateAttempts = 10
+
+// Update applies fn to the intent's state (a new one when none is stored)
+// and saves it, retrying with fresh state when a concurrent writer wins
+func Update(ctx context.Context, sm StateManager, intentID string, fn func(*DAGState) error) error {
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		state, err := sm.Get(ctx, intentID)
+		if errors.Is(err, ErrNotFound) {
+			state = &DAGState{IntentID: intentID, TaskStates: make(map[string]models.TaskStatus)}
+		} else if err != nil {
+			return err
+		}
+		if err := fn(state); err != nil {
+			return err
+		}
+		err = sm.Put(ctx, state)
+		if !errors.Is(err, ErrConflict) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt+1) * 10 * time.Millisecond):
+		}
+	}
+	return fmt.Errorf("%w: gave up on %s after %d attempts", ErrConflict, intentID, maxUpdateAttempts)
+}
@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// blobDir holds the content of LocalStore artifacts, one file per SHA-256
+// at .blobs/sha256/<first two hex digits>/<hash>
+const blobDir = ".blobs"
+
+// pointerPrefix starts an artifact file that points at a blob rather than
+// holding content. Artifacts written before deduplication hold their content
+// directly and are read as they are.
+const pointerPrefix = "qlp-blob:sha256:"
+
+// pointerSize is the exact size of a pointer file: prefix, hash, newline
+const pointerSize = len(pointerPrefix) + sha256.Size*2 + 1
+
+// blobGracePeriod keeps recently written or reused blobs out of garbage
+// collection. Blobs are only removed by CollectGarbage, which counts the
+// pointers on disk; a Put in another process may have stored or refreshed a
+// blob without having written its pointer yet.
+const blobGracePeriod = 10 * time.Minute
+
+// Usage summarizes how much deduplication saves
+type Usage struct {
+	Artifacts    int   `json:"artifacts"`
+	Blobs        int   `json:"blobs"`
+	LogicalBytes int64 `json:"logical_bytes"` // Sum of artifact sizes
+	StoredBytes  int64 `json:"stored_bytes"`  // Sum of blob sizes
+}
+
+// putBlob stores content under its hash, unless an identical blob exists,
+// and points key at it
+func (s *LocalStore) putBlob(key string, r io.Reader) error {
+	tmpDir := filepath.Join(s.root, blobDir, "tmp")
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	// Hash while writing to a temp file so readers never see partial blobs
+	tmp, err := os.CreateTemp(tmpDir, "upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create artifact: %w", err)
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), r)
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write artifact: %w", err)
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blob := s.blobPath(hash)
+	if _, err := os.Stat(blob); err == nil {
+		// Identical content is already stored; refresh it for the grace period
+		os.Remove(tmp.Name())
+		now := time.Now()
+		os.Chtimes(blob, now, now)
+	} else {
+		if err := os.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+			os.Remove(tmp.Name())
+			return fmt.Errorf("failed to create blob directory: %w", err)
+		}
+		if err := os.Rename(tmp.Name(), blob); err != nil {
+			os.Remove(tmp.Name())
+			return fmt.Errorf("failed to store blob: %w", err)
+		}
+	}
+
+	// A blob left without pointers, here or by an overwritten artifact, is
+	// removed by the next CollectGarbage
+	return writePointer(s.pathFor(key), hash)
+}
+
+// unlink removes the artifact at key. Its blob stays until CollectGarbage
+// finds nothing points at it, as other processes may share it.
+func (s *LocalStore) unlink(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.pathFor(key)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to delete artifact: %w", err)
+	}
+	return nil
+}
+
+// countRefs walks the artifacts and counts the pointers to each blob
+func (s *LocalStore) countRefs() (map[string]int, error) {
+	artifacts, err := s.ListAll(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	refs := make(map[string]int)
+	for _, a := range artifacts {
+		p := s.pathFor(a.Key)
+		if info, err := os.Stat(p); err == nil {
+			if hash := readPointer(p, info); hash != "" {
+				refs[hash]++
+			}
+		}
+	}
+	return refs, nil
+}
+
+// CollectGarbage removes blobs no artifact on disk points at, left behind by
+// deleted or overwritten artifacts and by crashes. It returns the number of
+// blobs and bytes freed.
+func (s *LocalStore) CollectGarbage(_ context.Context) (int, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refs, err := s.countRefs()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	removed, freed := 0, int64(0)
+	cutoff := time.Now().Add(-blobGracePeriod)
+	err = s.walkBlobs(func(p, hash string, info os.FileInfo) {
+		// Also sweeps uploads abandoned in tmp
+		if refs[hash] > 0 || info.ModTime().After(cutoff) {
+			return
+		}
+		if os.Remove(p) == nil {
+			removed++
+			freed += info.Size()
+		}
+	})
+	if err != nil {
+		return removed, freed, fmt.Errorf("failed to collect blobs: %w", err)
+	}
+	return removed, freed, nil
+}
+
+// Usage reports the logical size of the stored artifacts against the space
+// their blobs take
+func (s *LocalStore) Usage(ctx context.Context) (Usage, error) {
+	var u Usage
+	artifacts, err := s.ListAll(ctx)
+	if err != nil {
+		return u, err
+	}
+	u.Artifacts = len(artifacts)
+	for _, a := range artifacts {
+		u.LogicalBytes += a.SizeBytes
+	}
+	err = s.walkBlobs(func(_, hash string, info os.FileInfo) {
+		if hash != "" {
+			u.Blobs++
+			u.StoredBytes += info.Size()
+		}
+	})
+	if err != nil {
+		return u, fmt.Errorf("failed to measure blobs: %w", err)
+	}
+	// Artifacts from before deduplication hold their own content
+	u.StoredBytes += s.inlineBytes(artifacts)
+	return u, nil
+}
+
+func (s *LocalStore) inlineBytes(artifacts []Artifact) int64 {
+	var total int64
+	for _, a := range artifacts {
+		p := s.pathFor(a.Key)
+		if info, err := os.Stat(p); err == nil && readPointer(p, info) == "" {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+// walkBlobs calls fn for every file in the blob directory; hash is empty
+// for temp files
+func (s *LocalStore) walkBlobs(fn func(p, hash string, info os.FileInfo)) error {
+	root := filepath.Join(s.root, blobDir)
+	err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // Removed meanwhile
+		}
+		hash := d.Name()
+		if filepath.Base(filepath.Dir(p)) == "tmp" || !isHash(hash) {
+			hash = ""
+		}
+		fn(p, hash, info)
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *LocalStore) blobPath(hash string) string {
+	return filepath.Join(s.root, blobDir, "sha256", hash[:2], hash)
+}
+
+// contentPath is the file holding an artifact's content: its blob, or the
+// artifact file itself for artifacts stored before deduplication
+func (s *LocalStore) contentPath(key string) string {
+	p := s.pathFor(key)
+	if info, err := os.Stat(p); err == nil {
+		if hash := readPointer(p, info); hash != "" {
+			return s.blobPath(hash)
+		}
+	}
+	return p
+}
+
+// readPointer returns the blob hash an artifact file points at, or "" when
+// it holds content
+func readPointer(p string, info os.FileInfo) string {
+	if info.Size() != int64(pointerSize) {
+		return ""
+	}
+	data, err := os.ReadFile(p)
+	if err != nil || !strings.HasPrefix(string(data), pointerPrefix) {
+		return ""
+	}
+	hash := strings.TrimSuffix(strings.TrimPrefix(string(data), pointerPrefix), "\n")
+	if !isHash(hash) {
+		return ""
+	}
+	return hash
+}
+
+// writePointer atomically points the artifact file at p to a blob
+func writePointer(p, hash string) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return fmt.Errorf("failed to create artifact directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create artifact: %w", err)
+	}
+	_, err = tmp.WriteString(pointerPrefix + hash + "\n")
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), p)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to store artifact: %w", err)
+	}
+	return nil
+}
+
+func isHash(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
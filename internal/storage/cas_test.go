@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalStoreDeduplicates(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	dockerfile := []byte("FROM golang:1.24\nRUN go build ./...\n")
+
+	a, err := store.Put(ctx, "acme", "capsule-1", "Dockerfile", bytes.NewReader(dockerfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Put(ctx, "globex", "capsule-2", "Dockerfile", bytes.NewReader(dockerfile)); err != nil {
+		t.Fatal(err)
+	}
+	if a.SizeBytes != int64(len(dockerfile)) {
+		t.Errorf("size = %d", a.SizeBytes)
+	}
+
+	usage, err := store.Usage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Artifacts != 2 || usage.Blobs != 1 || usage.LogicalBytes != 2*int64(len(dockerfile)) || usage.StoredBytes != int64(len(dockerfile)) {
+		t.Errorf("usage = %+v", usage)
+	}
+
+	rc, artifact, err := store.Open(ctx, "globex/capsule-2/Dockerfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if !bytes.Equal(data, dockerfile) || artifact.TenantID != "globex" {
+		t.Errorf("read %q from %+v", data, artifact)
+	}
+
+	// The blob survives until garbage collection finds no reference to it
+	if err := store.Delete(ctx, a.Key); err != nil {
+		t.Fatal(err)
+	}
+	ageBlobs(t, dir)
+	if removed, _, _ := store.CollectGarbage(ctx); removed != 0 {
+		t.Errorf("collected %d blobs still referenced", removed)
+	}
+	if err := store.Delete(ctx, "globex/capsule-2/Dockerfile"); err != nil {
+		t.Fatal(err)
+	}
+	if usage, _ := store.Usage(ctx); usage.Blobs != 1 || usage.Artifacts != 0 {
+		t.Errorf("usage after deleting everything = %+v", usage)
+	}
+	if removed, _, _ := store.CollectGarbage(ctx); removed != 1 {
+		t.Errorf("collected %d blobs after deleting everything", removed)
+	}
+
+	// Overwriting an artifact leaves its old content to garbage collection
+	store.Put(ctx, "acme", "capsule-3", "app.zip", strings.NewReader("v1"))
+	store.Put(ctx, "acme", "capsule-3", "app.zip", strings.NewReader("v2"))
+	ageBlobs(t, dir)
+	store.CollectGarbage(ctx)
+	if usage, _ := store.Usage(ctx); usage.Blobs != 1 || usage.StoredBytes != 2 {
+		t.Errorf("usage after overwrite = %+v", usage)
+	}
+
+	if _, err := store.Put(ctx, ".blobs", "sha256", "x", strings.NewReader("x")); err == nil {
+		t.Error("expected hidden segments to be rejected")
+	}
+}
+
+// ageBlobs backdates every blob past the grace period
+func ageBlobs(t *testing.T, dir string) {
+	t.Helper()
+	old := time.Now().Add(-2 * blobGracePeriod)
+	filepath.WalkDir(filepath.Join(dir, blobDir), func(p string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			os.Chtimes(p, old, old)
+		}
+		return nil
+	})
+}
+
+// A store in another process deleting its last pointer to a blob must not
+// remove content this store points at
+func TestLocalStoreSharedAcrossProcesses(t *testing.T) {
+	dir := t.TempDir()
+	first, _ := NewLocalStore(dir)
+	second, _ := NewLocalStore(dir)
+	ctx := context.Background()
+
+	if _, err := first.Put(ctx, "acme", "capsule-1", "go.mod", strings.NewReader("module app\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.Put(ctx, "acme", "capsule-2", "go.mod", strings.NewReader("module app\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Delete(ctx, "acme/capsule-1/go.mod"); err != nil {
+		t.Fatal(err)
+	}
+	ageBlobs(t, dir)
+	first.CollectGarbage(ctx)
+	rc, _, err := second.Open(ctx, "acme/capsule-2/go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "module app\n" {
+		t.Errorf("shared content = %q", data)
+	}
+}
+
+func TestLocalStoreLegacyFilesAndGarbage(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// An artifact written before deduplication holds its content
+	legacy := filepath.Join(dir, "acme", "capsule-1", "report.json")
+	os.MkdirAll(filepath.Dir(legacy), 0755)
+	os.WriteFile(legacy, []byte(`{"ok":true}`), 0644)
+	rc, artifact, err := store.Open(ctx, "acme/capsule-1/report.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != `{"ok":true}` || artifact.SizeBytes != 11 {
+		t.Errorf("legacy read %q, %+v", data, artifact)
+	}
+
+	// A blob orphaned by a crash, old enough to collect, and a fresh one
+	if _, err := store.Put(ctx, "acme", "capsule-1", "main.go", strings.NewReader("package main\n")); err != nil {
+		t.Fatal(err)
+	}
+	orphan := store.blobPath(strings.Repeat("ab", 32))
+	os.MkdirAll(filepath.Dir(orphan), 0755)
+	os.WriteFile(orphan, []byte("orphaned"), 0644)
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(orphan, old, old)
+	fresh := store.blobPath(strings.Repeat("cd", 32))
+	os.MkdirAll(filepath.Dir(fresh), 0755)
+	os.WriteFile(fresh, []byte("in flight"), 0644)
+
+	removed, freed, err := store.CollectGarbage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 || freed != 8 {
+		t.Errorf("collected %d blobs, %d bytes", removed, freed)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Error("blob within the grace period was collected")
+	}
+	rc, _, err = store.Open(ctx, "acme/capsule-1/main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc.Close()
+
+	artifacts, _ := store.ListAll(ctx)
+	if len(artifacts) != 2 {
+		t.Errorf("artifacts = %+v", artifacts)
+	}
+}
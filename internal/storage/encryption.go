@@ -0,0 +1,586 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDecrypt is returned when an encrypted artifact cannot be authenticated:
+// it was tampered with, truncated or belongs to another tenant
+var ErrDecrypt = errors.New("artifact decryption failed")
+
+// encryptedMagic starts every encrypted artifact. Artifacts stored before
+// encryption was enabled are served as they are.
+const encryptedMagic = "QLPENC1\x00"
+
+// Encrypted artifacts are a header (magic, data key version, nonce prefix)
+// followed by records of up to chunkSize plaintext bytes sealed with
+// AES-256-GCM. Every record but the last is full; the last, possibly empty,
+// is sealed as final so truncation is detected.
+const (
+	chunkSize   = 64 << 10
+	noncePrefix = 8
+	headerSize  = len(encryptedMagic) + 4 + noncePrefix
+	recordSize  = chunkSize + 16
+)
+
+// KeyWrapper protects data keys with a master key held elsewhere, such as a
+// KMS or Key Vault key. Wrap returns the ID of the master key used, which
+// Unwrap gets back, so master keys can rotate without losing old data keys.
+type KeyWrapper interface {
+	Wrap(ctx context.Context, key []byte) (wrapped []byte, keyID string, err error)
+	Unwrap(ctx context.Context, wrapped []byte, keyID string) ([]byte, error)
+}
+
+// LocalKeyWrapper wraps data keys with AES-256-GCM master keys from
+// configuration, for deployments without a key management service
+type LocalKeyWrapper struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// NewLocalKeyWrapper parses "id:base64key,id:base64key" master keys, each
+// 32 bytes. The first wraps new data keys; the others still unwrap.
+func NewLocalKeyWrapper(spec string) (*LocalKeyWrapper, error) {
+	w := &LocalKeyWrapper{keys: make(map[string]cipher.AEAD)}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(pair, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("invalid master key %q, expected id:base64key", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("master key %s must be 32 bytes, base64-encoded", id)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		if w.current == "" {
+			w.current = id
+		}
+		w.keys[id] = aead
+	}
+	if w.current == "" {
+		return nil, fmt.Errorf("no master key configured")
+	}
+	return w, nil
+}
+
+func (w *LocalKeyWrapper) Wrap(_ context.Context, key []byte) ([]byte, string, error) {
+	aead := w.keys[w.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, "", err
+	}
+	return aead.Seal(nonce, nonce, key, []byte(w.current)), w.current, nil
+}
+
+func (w *LocalKeyWrapper) Unwrap(_ context.Context, wrapped []byte, keyID string) ([]byte, error) {
+	aead, ok := w.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown master key %q", keyID)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, fmt.Errorf("wrapped key too short")
+	}
+	key, err := aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key with %s: %w", keyID, err)
+	}
+	return key, nil
+}
+
+// DataKey is one version of a tenant's data key, as persisted: wrapped by a
+// master key and never in the clear
+type DataKey struct {
+	Version   int       `json:"version"`
+	Wrapped   []byte    `json:"wrapped"`
+	MasterKey string    `json:"master_key"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TenantKeys manages per-tenant data keys in <dir>/<tenant>.json. The newest
+// version encrypts new artifacts; older ones keep decrypting what they
+// encrypted. Unwrapped keys are cached in memory.
+type TenantKeys struct {
+	dir     string
+	wrapper KeyWrapper
+
+	mu        sync.Mutex
+	unwrapped map[string][]byte // "<tenant>/<version>" to key
+}
+
+// NewTenantKeys creates a key manager persisting wrapped keys under dir
+func NewTenantKeys(dir string, wrapper KeyWrapper) (*TenantKeys, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create key directory: %w", err)
+	}
+	return &TenantKeys{dir: dir, wrapper: wrapper, unwrapped: make(map[string][]byte)}, nil
+}
+
+// Versions lists a tenant's data keys, oldest first
+func (tk *TenantKeys) Versions(tenantID string) ([]DataKey, error) {
+	tk.mu.Lock()
+	defer tk.mu.Unlock()
+	return tk.load(tenantID)
+}
+
+// Rotate creates a new data key version for a tenant, used for everything
+// it stores from now on
+func (tk *TenantKeys) Rotate(ctx context.Context, tenantID string) (DataKey, error) {
+	tk.mu.Lock()
+	defer tk.mu.Unlock()
+	keys, err := tk.load(tenantID)
+	if err != nil {
+		return DataKey{}, err
+	}
+	return tk.generate(ctx, tenantID, keys)
+}
+
+// Rewrap wraps the data keys of tenantID, or of every tenant when it is
+// empty, again with the current master key, after the master key was
+// rotated. It returns how many keys were rewrapped.
+func (tk *TenantKeys) Rewrap(ctx context.Context, tenantID string) (int, error) {
+	tk.mu.Lock()
+	defer tk.mu.Unlock()
+	tenants := []string{tenantID}
+	if tenantID == "" {
+		entries, err := os.ReadDir(tk.dir)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list tenant keys: %w", err)
+		}
+		tenants = tenants[:0]
+		for _, e := range entries {
+			if id, ok := strings.CutSuffix(e.Name(), ".json"); ok && !e.IsDir() {
+				tenants = append(tenants, id)
+			}
+		}
+	}
+	count := 0
+	for _, tenantID := range tenants {
+		keys, err := tk.load(tenantID)
+		if err != nil {
+			return count, err
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		for i, k := range keys {
+			plain, err := tk.wrapper.Unwrap(ctx, k.Wrapped, k.MasterKey)
+			if err != nil {
+				return count, fmt.Errorf("tenant %s key v%d: %w", tenantID, k.Version, err)
+			}
+			if keys[i].Wrapped, keys[i].MasterKey, err = tk.wrapper.Wrap(ctx, plain); err != nil {
+				return count, fmt.Errorf("tenant %s key v%d: %w", tenantID, k.Version, err)
+			}
+		}
+		if err := tk.save(tenantID, keys); err != nil {
+			return count, err
+		}
+		count += len(keys)
+	}
+	return count, nil
+}
+
+// Destroy deletes every data key of a tenant, making whatever was encrypted
+// with them unreadable. It returns how many keys were destroyed.
+func (tk *TenantKeys) Destroy(tenantID string) (int, error) {
+	tk.mu.Lock()
+	defer tk.mu.Unlock()
+	keys, err := tk.load(tenantID)
+	if err != nil {
+		return 0, err
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	if err := os.Remove(filepath.Join(tk.dir, tenantID+".json")); err != nil {
+		return 0, fmt.Errorf("failed to destroy keys of tenant %s: %w", tenantID, err)
+	}
+	for _, k := range keys {
+		delete(tk.unwrapped, fmt.Sprintf("%s/%d", tenantID, k.Version))
+	}
+	return len(keys), nil
+}
+
+// current returns the newest data key of a tenant, creating the first one
+func (tk *TenantKeys) current(ctx context.Context, tenantID string) (int, []byte, error) {
+	tk.mu.Lock()
+	keys, err := tk.load(tenantID)
+	if err == nil && len(keys) == 0 {
+		var k DataKey
+		k, err = tk.generate(ctx, tenantID, keys)
+		keys = append(keys, k)
+	}
+	tk.mu.Unlock()
+	if err != nil {
+		return 0, nil, err
+	}
+	version := keys[len(keys)-1].Version
+	key, err := tk.key(ctx, tenantID, version)
+	return version, key, err
+}
+
+// key returns a tenant's data key by version
+func (tk *TenantKeys) key(ctx context.Context, tenantID string, version int) ([]byte, error) {
+	tk.mu.Lock()
+	defer tk.mu.Unlock()
+	cacheKey := fmt.Sprintf("%s/%d", tenantID, version)
+	if key, ok := tk.unwrapped[cacheKey]; ok {
+		return key, nil
+	}
+	keys, err := tk.load(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		if k.Version != version {
+			continue
+		}
+		key, err := tk.wrapper.Unwrap(ctx, k.Wrapped, k.MasterKey)
+		if err != nil {
+			return nil, err
+		}
+		tk.unwrapped[cacheKey] = key
+		return key, nil
+	}
+	return nil, fmt.Errorf("tenant %s has no data key v%d", tenantID, version)
+}
+
+func (tk *TenantKeys) generate(ctx context.Context, tenantID string, keys []DataKey) (DataKey, error) {
+	plain := make([]byte, 32)
+	if _, err := rand.Read(plain); err != nil {
+		return DataKey{}, err
+	}
+	wrapped, masterKey, err := tk.wrapper.Wrap(ctx, plain)
+	if err != nil {
+		return DataKey{}, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	k := DataKey{Version: 1, Wrapped: wrapped, MasterKey: masterKey, CreatedAt: time.Now().UTC()}
+	if len(keys) > 0 {
+		k.Version = keys[len(keys)-1].Version + 1
+	}
+	if err := tk.save(tenantID, append(keys, k)); err != nil {
+		return DataKey{}, err
+	}
+	tk.unwrapped[fmt.Sprintf("%s/%d", tenantID, k.Version)] = plain
+	return k, nil
+}
+
+func (tk *TenantKeys) load(tenantID string) ([]DataKey, error) {
+	if !validSegment(tenantID) {
+		return nil, fmt.Errorf("invalid tenant %q", tenantID)
+	}
+	data, err := os.ReadFile(filepath.Join(tk.dir, tenantID+".json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keys of tenant %s: %w", tenantID, err)
+	}
+	var keys []DataKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse keys of tenant %s: %w", tenantID, err)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Version < keys[j].Version })
+	return keys, nil
+}
+
+func (tk *TenantKeys) save(tenantID string, keys []DataKey) error {
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(tk.dir, ".keys-*")
+	if err != nil {
+		return fmt.Errorf("failed to save keys of tenant %s: %w", tenantID, err)
+	}
+	_, err = tmp.Write(data)
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(tk.dir, tenantID+".json"))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save keys of tenant %s: %w", tenantID, err)
+	}
+	return nil
+}
+
+// EncryptedStore encrypts artifacts at rest with their tenant's data key and
+// decrypts them transparently on Open. Sizes reported are plaintext sizes.
+// Encrypted content is unique per write, so identical artifacts are no
+// longer deduplicated.
+type EncryptedStore struct {
+	inner ArtifactStore
+	keys  *TenantKeys
+}
+
+// NewEncryptedStore wraps inner with envelope encryption
+func NewEncryptedStore(inner ArtifactStore, keys *TenantKeys) *EncryptedStore {
+	return &EncryptedStore{inner: inner, keys: keys}
+}
+
+// Keys returns the tenant key manager, for rotation
+func (s *EncryptedStore) Keys() *TenantKeys {
+	return s.keys
+}
+
+func (s *EncryptedStore) Put(ctx context.Context, tenantID, capsuleID, name string, r io.Reader) (*Artifact, error) {
+	if tenantID == "" {
+		tenantID = DefaultTenant
+	}
+	version, key, err := s.keys.current(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data key: %w", err)
+	}
+	sealer, err := newSealReader(r, key, version, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	artifact, err := s.inner.Put(ctx, tenantID, capsuleID, name, sealer)
+	if err != nil {
+		return nil, err
+	}
+	artifact.SizeBytes = sealer.plaintext
+	return artifact, nil
+}
+
+func (s *EncryptedStore) List(ctx context.Context, capsuleID string) ([]Artifact, error) {
+	artifacts, err := s.inner.List(ctx, capsuleID)
+	if err != nil {
+		return nil, err
+	}
+	return s.plaintextSizes(ctx, artifacts), nil
+}
+
+func (s *EncryptedStore) ListAll(ctx context.Context) ([]Artifact, error) {
+	artifacts, err := s.inner.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.plaintextSizes(ctx, artifacts), nil
+}
+
+func (s *EncryptedStore) Open(ctx context.Context, key string) (io.ReadCloser, *Artifact, error) {
+	rc, artifact, err := s.inner.Open(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := make([]byte, headerSize)
+	n, err := io.ReadFull(rc, header)
+	if err != nil || string(header[:len(encryptedMagic)]) != encryptedMagic {
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			rc.Close()
+			return nil, nil, fmt.Errorf("failed to read artifact: %w", err)
+		}
+		// Stored before encryption was enabled
+		return readCloser{io.MultiReader(bytes.NewReader(header[:n]), rc), rc}, artifact, nil
+	}
+
+	version := int(binary.BigEndian.Uint32(header[len(encryptedMagic):]))
+	dataKey, err := s.keys.key(ctx, artifact.TenantID, version)
+	if err != nil {
+		rc.Close()
+		return nil, nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		rc.Close()
+		return nil, nil, err
+	}
+	artifact.SizeBytes = plaintextSize(artifact.SizeBytes)
+	return &openReader{
+		src:    rc,
+		aead:   aead,
+		prefix: header[len(encryptedMagic)+4:],
+		aad:    artifact.TenantID,
+		buf:    make([]byte, recordSize),
+	}, artifact, nil
+}
+
+func (s *EncryptedStore) Delete(ctx context.Context, key string) error {
+	return s.inner.Delete(ctx, key)
+}
+
+// CollectGarbage passes garbage collection through to the wrapped store
+func (s *EncryptedStore) CollectGarbage(ctx context.Context) (int, int64, error) {
+	if gc, ok := s.inner.(garbageCollector); ok {
+		return gc.CollectGarbage(ctx)
+	}
+	return 0, 0, nil
+}
+
+// plaintextSizes corrects the sizes of encrypted artifacts, peeking at each
+// one's header
+func (s *EncryptedStore) plaintextSizes(ctx context.Context, artifacts []Artifact) []Artifact {
+	for i, a := range artifacts {
+		rc, _, err := s.inner.Open(ctx, a.Key)
+		if err != nil {
+			continue
+		}
+		magic := make([]byte, len(encryptedMagic))
+		if _, err := io.ReadFull(rc, magic); err == nil && string(magic) == encryptedMagic {
+			artifacts[i].SizeBytes = plaintextSize(a.SizeBytes)
+		}
+		rc.Close()
+	}
+	return artifacts
+}
+
+// plaintextSize computes the content size of an encrypted artifact: full
+// records hold chunkSize bytes, the final one the remainder
+func plaintextSize(stored int64) int64 {
+	body := stored - int64(headerSize)
+	if body < 16 {
+		return 0
+	}
+	full := body / recordSize
+	last := body % recordSize
+	if last == 0 {
+		// Cannot happen for a well-formed artifact; the final record is short
+		return full * chunkSize
+	}
+	return full*chunkSize + last - 16
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// recordNonce is the nonce prefix followed by the record number
+func recordNonce(prefix []byte, counter uint32) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefix:], counter)
+	return nonce
+}
+
+// recordAAD binds a record to the tenant and marks the final one
+func recordAAD(tenantID string, final bool) []byte {
+	flag := byte(0)
+	if final {
+		flag = 1
+	}
+	return append([]byte(tenantID), flag)
+}
+
+// sealReader encrypts a stream as it is read
+type sealReader struct {
+	src       io.Reader
+	aead      cipher.AEAD
+	prefix    []byte
+	aad       string
+	counter   uint32
+	buf       []byte
+	out       bytes.Buffer
+	done      bool
+	plaintext int64
+}
+
+func newSealReader(src io.Reader, key []byte, version int, tenantID string) (*sealReader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	s := &sealReader{src: src, aead: aead, prefix: make([]byte, noncePrefix), aad: tenantID, buf: make([]byte, chunkSize)}
+	if _, err := rand.Read(s.prefix); err != nil {
+		return nil, err
+	}
+	s.out.WriteString(encryptedMagic)
+	binary.Write(&s.out, binary.BigEndian, uint32(version))
+	s.out.Write(s.prefix)
+	return s, nil
+}
+
+func (s *sealReader) Read(p []byte) (int, error) {
+	for s.out.Len() == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(s.src, s.buf)
+		final := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !final {
+			return 0, err
+		}
+		s.plaintext += int64(n)
+		s.out.Write(s.aead.Seal(nil, recordNonce(s.prefix, s.counter), s.buf[:n], recordAAD(s.aad, final)))
+		s.counter++
+		s.done = final
+	}
+	return s.out.Read(p)
+}
+
+// openReader decrypts and authenticates records as they are read
+type openReader struct {
+	src     io.ReadCloser
+	aead    cipher.AEAD
+	prefix  []byte
+	aad     string
+	counter uint32
+	buf     []byte
+	plain   []byte
+	done    bool
+}
+
+func (o *openReader) Read(p []byte) (int, error) {
+	for len(o.plain) == 0 {
+		if o.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(o.src, o.buf)
+		final := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !final {
+			return 0, err
+		}
+		if n == 0 {
+			return 0, fmt.Errorf("%w: truncated", ErrDecrypt)
+		}
+		plain, err := o.aead.Open(o.buf[:0], recordNonce(o.prefix, o.counter), o.buf[:n], recordAAD(o.aad, final))
+		if err != nil {
+			return 0, ErrDecrypt
+		}
+		o.plain = plain
+		o.counter++
+		o.done = final
+	}
+	n := copy(p, o.plain)
+	o.plain = o.plain[n:]
+	return n, nil
+}
+
+func (o *openReader) Close() error {
+	return o.src.Close()
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"QLP/internal/audit"
+)
+
+func masterKey(t *testing.T, id string) string {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return id + ":" + base64.StdEncoding.EncodeToString(key)
+}
+
+func newEncryptedStore(t *testing.T, dir, masterKeys string) *EncryptedStore {
+	local, err := NewLocalStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapper, err := NewLocalKeyWrapper(masterKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := NewTenantKeys(filepath.Join(dir, ".keys"), wrapper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewEncryptedStore(local, keys)
+}
+
+func readAll(t *testing.T, store ArtifactStore, key string) ([]byte, *Artifact, error) {
+	t.Helper()
+	rc, artifact, err := store.Open(context.Background(), key)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	return data, artifact, err
+}
+
+func TestEncryptedStoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store := newEncryptedStore(t, dir, masterKey(t, "m1"))
+	ctx := context.Background()
+
+	for _, size := range []int{0, 1, chunkSize, 2*chunkSize + 5} {
+		content := make([]byte, size)
+		rand.Read(content)
+		name := filepath.Base(t.Name()) + "-" + string(rune('a'+size%26)) + ".bin"
+		artifact, err := store.Put(ctx, "acme", "capsule-1", name, bytes.NewReader(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if artifact.SizeBytes != int64(size) {
+			t.Errorf("put size = %d, want %d", artifact.SizeBytes, size)
+		}
+
+		// At rest the content is unreadable without the key; a byte or
+		// two may turn up in the ciphertext by chance
+		raw, _, _ := readAll(t, store.inner, artifact.Key)
+		if size > 8 && bytes.Contains(raw, content) {
+			t.Errorf("%d bytes stored in the clear", size)
+		}
+
+		data, opened, err := readAll(t, store, artifact.Key)
+		if err != nil || !bytes.Equal(data, content) || opened.SizeBytes != int64(size) {
+			t.Errorf("size %d: read %d bytes (reported %d), err %v", size, len(data), opened.SizeBytes, err)
+		}
+	}
+
+	listed, err := store.List(ctx, "capsule-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range listed {
+		if a.Name == "TestEncryptedStoreRoundTrip-f.bin" && a.SizeBytes != 2*chunkSize+5 {
+			t.Errorf("listed size = %d", a.SizeBytes)
+		}
+	}
+
+	// Artifacts stored before encryption are served as they are
+	legacy := filepath.Join(dir, "acme", "capsule-2", "README.md")
+	os.MkdirAll(filepath.Dir(legacy), 0755)
+	os.WriteFile(legacy, []byte("# hi"), 0644)
+	if data, _, err := readAll(t, store, "acme/capsule-2/README.md"); err != nil || string(data) != "# hi" {
+		t.Errorf("legacy read %q, %v", data, err)
+	}
+}
+
+func TestEncryptedStoreTampering(t *testing.T) {
+	dir := t.TempDir()
+	store := newEncryptedStore(t, dir, masterKey(t, "m1"))
+	ctx := context.Background()
+
+	content := bytes.Repeat([]byte("secret "), 20000)
+	a, err := store.Put(ctx, "acme", "capsule-1", "app.zip", bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _, _ := readAll(t, store.inner, a.Key)
+
+	// Flipped bit, truncation after a full record, and a copy into another tenant
+	flipped := append([]byte(nil), raw...)
+	flipped[len(flipped)-1] ^= 1
+	for name, data := range map[string][]byte{
+		"acme/capsule-1/flipped.zip":   flipped,
+		"acme/capsule-1/truncated.zip": raw[:headerSize+recordSize],
+		"globex/capsule-1/app.zip":     raw,
+	} {
+		if _, err := store.inner.Put(ctx, filepath.Dir(filepath.Dir(name)), "capsule-1", filepath.Base(name), bytes.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := readAll(t, store, name); !errors.Is(err, ErrDecrypt) {
+			t.Errorf("%s: expected ErrDecrypt, got %v", name, err)
+		}
+	}
+}
+
+func TestTenantKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	oldMaster := masterKey(t, "m1")
+	store := newEncryptedStore(t, dir, oldMaster)
+	ctx := context.Background()
+
+	store.Put(ctx, "acme", "capsule-1", "v1.txt", bytes.NewReader([]byte("first")))
+	if _, err := store.Keys().Rotate(ctx, "acme"); err != nil {
+		t.Fatal(err)
+	}
+	store.Put(ctx, "acme", "capsule-1", "v2.txt", bytes.NewReader([]byte("second")))
+	versions, _ := store.Keys().Versions("acme")
+	if len(versions) != 2 || versions[1].Version != 2 {
+		t.Fatalf("versions = %+v", versions)
+	}
+
+	// Rotate the master key, rewrap, then destroy the old master key
+	newMaster := masterKey(t, "m2")
+	rotated := newEncryptedStore(t, dir, newMaster+","+oldMaster)
+	if n, err := rotated.Keys().Rewrap(ctx, ""); err != nil || n != 2 {
+		t.Fatalf("rewrapped %d, %v", n, err)
+	}
+	onlyNew := newEncryptedStore(t, dir, newMaster)
+	for key, want := range map[string]string{"acme/capsule-1/v1.txt": "first", "acme/capsule-1/v2.txt": "second"} {
+		if data, _, err := readAll(t, onlyNew, key); err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v", key, data, err)
+		}
+	}
+	if _, _, err := readAll(t, newEncryptedStore(t, dir, oldMaster), "acme/capsule-1/v1.txt"); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("expected the old master key alone to fail, got %v", err)
+	}
+
+	// The rotate endpoint starts version 3
+	routes := KeyRoutes(store.Keys())
+	mux := http.NewServeMux()
+	for pattern, h := range routes {
+		mux.Handle(pattern, h)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/artifact-keys/acme/rotate", nil))
+	if rec.Code != http.StatusCreated || !bytes.Contains(rec.Body.Bytes(), []byte(`"version":3`)) {
+		t.Errorf("rotate = %d %s", rec.Code, rec.Body)
+	}
+
+	// Rewrapping with another tenant's API key leaves acme's keys alone
+	req := httptest.NewRequest(http.MethodPost, "/artifact-keys/rewrap", nil)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req.WithContext(audit.WithTenant(req.Context(), "globex")))
+	if rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte(`"rewrapped":0`)) {
+		t.Errorf("rewrap for another tenant = %d %s", rec.Code, rec.Body)
+	}
+}
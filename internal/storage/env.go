@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"QLP/internal/config"
+	"QLP/internal/logger"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+// InitFromEnv creates the local artifact store, starts the retention sweeper
+// and returns the HTTP routes for listing and downloading artifacts.
+//
+//	QLP_ARTIFACT_DIR               storage root (default ./data/artifacts)
+//	QLP_ARTIFACT_SIGNING_KEY       HMAC key for presigned URLs
+//	QLP_ARTIFACT_BASE_URL          public base URL used in download links
+//	QLP_ARTIFACT_LINK_TTL          presigned URL lifetime (default 1h)
+//	QLP_ARTIFACT_RETENTION_DAYS    default retention in days (default 30, 0 keeps forever)
+//	QLP_ARTIFACT_TENANT_RETENTION  per-tenant overrides, e.g. "acme=7,globex=90"
+//
+// and, with encryption enabled (see OpenFromEnv), the data key endpoints.
+func InitFromEnv(ctx context.Context, baseURL string) (ArtifactStore, map[string]http.Handler, error) {
+	store, err := OpenFromEnv()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secret := config.GetEnvOrDefault("QLP_ARTIFACT_SIGNING_KEY", "")
+	if secret == "" {
+		logger.WithComponent("storage").Warn("QLP_ARTIFACT_SIGNING_KEY not set, download links will not survive a restart")
+	}
+	signer, err := NewSigner(secret, config.GetEnvOrDefault("QLP_ARTIFACT_BASE_URL", baseURL))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	linkTTL, err := time.ParseDuration(config.GetEnvOrDefault("QLP_ARTIFACT_LINK_TTL", "1h"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid QLP_ARTIFACT_LINK_TTL: %w", err)
+	}
+
+	days, err := strconv.Atoi(config.GetEnvOrDefault("QLP_ARTIFACT_RETENTION_DAYS", "30"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid QLP_ARTIFACT_RETENTION_DAYS: %w", err)
+	}
+	policy, err := ParseRetentionPolicy(days, config.GetEnvOrDefault("QLP_ARTIFACT_TENANT_RETENTION", ""))
+	if err != nil {
+		return nil, nil, err
+	}
+	go NewRetentionSweeper(store, policy).Run(ctx, time.Hour)
+
+	routes := Routes(store, signer, linkTTL)
+	if encrypted, ok := store.(*EncryptedStore); ok {
+		for pattern, h := range KeyRoutes(encrypted.Keys()) {
+			routes[pattern] = h
+		}
+	}
+	return store, routes, nil
+}
+
+// OpenFromEnv opens the local artifact store, encrypted at rest when
+// QLP_ARTIFACT_ENCRYPTION is set:
+//
+//	QLP_ARTIFACT_ENCRYPTION    off (default), local or keyvault
+//	QLP_ARTIFACT_MASTER_KEYS   local master keys, "id:base64key,..." newest first
+//	QLP_ARTIFACT_KEYVAULT_KEY  Key Vault key URL, https://<vault>.vault.azure.net/keys/<name>
+//	QLP_ARTIFACT_KEY_DIR       wrapped tenant data keys (default <QLP_ARTIFACT_DIR>/.keys)
+func OpenFromEnv() (ArtifactStore, error) {
+	dir := config.GetEnvOrDefault("QLP_ARTIFACT_DIR", "./data/artifacts")
+	local, err := NewLocalStore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var wrapper KeyWrapper
+	switch mode := config.GetEnvOrDefault("QLP_ARTIFACT_ENCRYPTION", "off"); mode {
+	case "off", "":
+		return local, nil
+	case "local":
+		if wrapper, err = NewLocalKeyWrapper(config.GetEnvOrDefault("QLP_ARTIFACT_MASTER_KEYS", "")); err != nil {
+			return nil, fmt.Errorf("invalid QLP_ARTIFACT_MASTER_KEYS: %w", err)
+		}
+	case "keyvault":
+		keyURL := config.GetEnvOrDefault("QLP_ARTIFACT_KEYVAULT_KEY", "")
+		if keyURL == "" {
+			return nil, fmt.Errorf("QLP_ARTIFACT_KEYVAULT_KEY is required for keyvault artifact encryption")
+		}
+		credential, err := azidentity.NewDefaultAzureCredential(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Azure credential: %w", err)
+		}
+		wrapper = NewKeyVaultKeyWrapper(keyURL, credential)
+	default:
+		return nil, fmt.Errorf("unknown QLP_ARTIFACT_ENCRYPTION %q", mode)
+	}
+
+	keys, err := NewTenantKeys(config.GetEnvOrDefault("QLP_ARTIFACT_KEY_DIR", filepath.Join(dir, ".keys")), wrapper)
+	if err != nil {
+		return nil, err
+	}
+	return NewEncryptedStore(local, keys), nil
+}
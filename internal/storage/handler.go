@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/filetypes"
+)
+
+// Routes returns the artifact HTTP endpoints:
+//
+//	GET /capsules/{id}/artifacts  lists a capsule's artifacts with presigned download URLs
+//	GET /artifacts/download       streams an artifact when the URL signature is valid
+//
+// Capsules are listed for the requested tenant, that of the API key by
+// default; other tenants' capsules are not found.
+func Routes(store ArtifactStore, signer *Signer, linkTTL time.Duration) map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /capsules/{id}/artifacts": listHandler(store, signer, linkTTL),
+		"GET /artifacts/download":      downloadHandler(store, signer),
+	}
+}
+
+func listHandler(store ArtifactStore, signer *Signer, linkTTL time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capsuleID := r.PathValue("id")
+		artifacts, err := store.List(r.Context(), capsuleID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		artifacts = OfTenant(artifacts, audit.RequestTenant(r))
+		if len(artifacts) == 0 {
+			http.Error(w, "capsule not found", http.StatusNotFound)
+			return
+		}
+
+		for i := range artifacts {
+			downloadURL, expires := signer.SignURL(artifacts[i].Key, linkTTL)
+			artifacts[i].DownloadURL = downloadURL
+			artifacts[i].ExpiresAt = &expires
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"capsule_id": capsuleID,
+			"artifacts":  artifacts,
+		})
+	})
+}
+
+func downloadHandler(store ArtifactStore, signer *Signer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		key := q.Get("key")
+		if err := signer.Verify(key, q.Get("expires"), q.Get("sig")); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		rc, artifact, err := store.Open(r.Context(), key)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				http.Error(w, "artifact not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rc.Close()
+
+		w.Header().Set("Content-Type", filetypes.MimeType(artifact.Name))
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", artifact.Name))
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", artifact.SizeBytes))
+		io.Copy(w, rc)
+	})
+}
+
+// KeyRoutes returns the data key endpoints of an encrypted store:
+//
+//	GET  /artifact-keys/{tenant}         lists a tenant's data key versions, without key material
+//	POST /artifact-keys/{tenant}/rotate  starts a new data key version for the tenant
+//	POST /artifact-keys/rewrap           rewraps every data key with the current master key
+//
+// Rewrapping with an API key only rewraps the keys of its tenant.
+func KeyRoutes(keys *TenantKeys) map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /artifact-keys/{tenant}":         keyListHandler(keys),
+		"POST /artifact-keys/{tenant}/rotate": keyRotateHandler(keys),
+		"POST /artifact-keys/rewrap":          keyRewrapHandler(keys),
+	}
+}
+
+// keyVersion is a data key as listed, without the wrapped key
+type keyVersion struct {
+	Version   int       `json:"version"`
+	MasterKey string    `json:"master_key"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func keyListHandler(keys *TenantKeys) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID := r.PathValue("tenant")
+		versions, err := keys.Versions(tenantID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		listed := make([]keyVersion, 0, len(versions))
+		for _, k := range versions {
+			listed = append(listed, keyVersion{Version: k.Version, MasterKey: k.MasterKey, CreatedAt: k.CreatedAt})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"tenant_id": tenantID,
+			"keys":      listed,
+		})
+	})
+}
+
+func keyRotateHandler(keys *TenantKeys) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID := r.PathValue("tenant")
+		k, err := keys.Rotate(r.Context(), tenantID)
+		recordKeyChange(r, audit.ActionArtifactKeyRotate, tenantID, map[string]interface{}{"version": k.Version}, err)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(keyVersion{Version: k.Version, MasterKey: k.MasterKey, CreatedAt: k.CreatedAt})
+	})
+}
+
+func keyRewrapHandler(keys *TenantKeys) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID := audit.TenantFromContext(r.Context())
+		count, err := keys.Rewrap(r.Context(), tenantID)
+		recordKeyChange(r, audit.ActionArtifactKeyRewrap, tenantID, map[string]interface{}{"rewrapped": count}, err)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"rewrapped": count})
+	})
+}
+
+// recordKeyChange audits a key operation; the actor is the request's API
+// key, or comes from an optional {"actor": "..."} body
+func recordKeyChange(r *http.Request, action audit.Action, tenantID string, details map[string]interface{}, err error) {
+	var body struct {
+		Actor string `json:"actor"`
+	}
+	json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&body)
+	entry := audit.Entry{
+		Action:       action,
+		Outcome:      audit.OutcomeSuccess,
+		ResourceType: "artifact_key",
+		Details:      details,
+	}
+	if tenantID != "" {
+		entry.ResourceIDs = []string{tenantID}
+	}
+	if err != nil {
+		entry.Outcome = audit.OutcomeFailure
+		entry.Error = err.Error()
+	}
+	ctx := r.Context()
+	if tenantID != "" {
+		ctx = audit.WithTenant(ctx, tenantID)
+	}
+	audit.Record(audit.WithActor(ctx, audit.RequestActor(r, body.Actor)), entry)
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"QLP/internal/audit"
+)
+
+func TestSignerVerify(t *testing.T) {
+	signer, err := NewSigner("secret", "https://qlp.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, expires := signer.SignURL("acme/QL-CAP-1/app.zip", time.Hour)
+	if !strings.HasPrefix(link, "https://qlp.example.com/artifacts/download?") || time.Until(expires) < 59*time.Minute {
+		t.Fatalf("link %s expires %v", link, expires)
+	}
+	u, _ := url.Parse(link)
+	q := u.Query()
+	if err := signer.Verify(q.Get("key"), q.Get("expires"), q.Get("sig")); err != nil {
+		t.Errorf("valid link: %v", err)
+	}
+
+	other, _ := NewSigner("other", "")
+	expired, _ := signer.SignURL("acme/QL-CAP-1/app.zip", -time.Minute)
+	eq, _ := url.Parse(expired)
+	tests := []struct {
+		name              string
+		signer            *Signer
+		key, expires, sig string
+	}{
+		{"other key", signer, "globex/QL-CAP-1/app.zip", q.Get("expires"), q.Get("sig")},
+		{"extended expiry", signer, q.Get("key"), q.Get("expires") + "0", q.Get("sig")},
+		{"other secret", other, q.Get("key"), q.Get("expires"), q.Get("sig")},
+		{"expired", signer, eq.Query().Get("key"), eq.Query().Get("expires"), eq.Query().Get("sig")},
+		{"malformed expiry", signer, q.Get("key"), "soon", q.Get("sig")},
+	}
+	for _, tt := range tests {
+		if err := tt.signer.Verify(tt.key, tt.expires, tt.sig); err == nil {
+			t.Errorf("%s: verified", tt.name)
+		}
+	}
+}
+
+func TestArtifactRoutesFilterTenant(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	store.Put(ctx, "acme", "QL-CAP-1", "app.zip", strings.NewReader("acme's capsule"))
+	store.Put(ctx, "globex", "QL-CAP-2", "app.zip", strings.NewReader("globex's capsule"))
+	signer, _ := NewSigner("secret", "")
+
+	mux := http.NewServeMux()
+	for pattern, h := range Routes(store, signer, time.Hour) {
+		mux.Handle(pattern, h)
+	}
+	list := func(capsuleID, tenant string) (int, []Artifact) {
+		req := httptest.NewRequest(http.MethodGet, "/capsules/"+capsuleID+"/artifacts", nil)
+		if tenant != "" {
+			req = req.WithContext(audit.WithTenant(req.Context(), tenant))
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		var body struct {
+			Artifacts []Artifact `json:"artifacts"`
+		}
+		json.NewDecoder(rec.Body).Decode(&body)
+		return rec.Code, body.Artifacts
+	}
+
+	status, artifacts := list("QL-CAP-1", "acme")
+	if status != http.StatusOK || len(artifacts) != 1 || artifacts[0].DownloadURL == "" {
+		t.Fatalf("acme listing its capsule = %d %+v", status, artifacts)
+	}
+	if status, _ := list("QL-CAP-1", "globex"); status != http.StatusNotFound {
+		t.Errorf("globex listing acme's capsule = %d", status)
+	}
+	if status, artifacts := list("QL-CAP-2", ""); status != http.StatusOK || len(artifacts) != 1 {
+		t.Errorf("listing without a tenant = %d %+v", status, artifacts)
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, artifacts[0].DownloadURL, nil))
+	if data, _ := io.ReadAll(rec.Body); rec.Code != http.StatusOK || string(data) != "acme's capsule" {
+		t.Errorf("download = %d %q", rec.Code, data)
+	}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, strings.Replace(artifacts[0].DownloadURL, "acme", "globex", 1), nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("download with a tampered key = %d", rec.Code)
+	}
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+const keyVaultAPIVersion = "7.4"
+
+// KeyVaultKeyWrapper wraps data keys with an Azure Key Vault RSA key, so the
+// master key never leaves the vault. New data keys are wrapped with the
+// latest version of the key; the versioned key ID is kept with each data key,
+// so rotating the key in the vault leaves existing data keys readable.
+type KeyVaultKeyWrapper struct {
+	keyURL     string // https://<vault>.vault.azure.net/keys/<name>
+	credential azcore.TokenCredential
+	client     *http.Client
+}
+
+// NewKeyVaultKeyWrapper creates a wrapper for the key at keyURL
+func NewKeyVaultKeyWrapper(keyURL string, credential azcore.TokenCredential) *KeyVaultKeyWrapper {
+	return &KeyVaultKeyWrapper{
+		keyURL:     strings.TrimSuffix(keyURL, "/"),
+		credential: credential,
+		client:     &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (w *KeyVaultKeyWrapper) Wrap(ctx context.Context, key []byte) ([]byte, string, error) {
+	return w.call(ctx, w.keyURL+"/wrapkey", key)
+}
+
+func (w *KeyVaultKeyWrapper) Unwrap(ctx context.Context, wrapped []byte, keyID string) ([]byte, error) {
+	if !strings.HasPrefix(keyID, w.keyURL+"/") {
+		return nil, fmt.Errorf("data key was wrapped by %s, not %s", keyID, w.keyURL)
+	}
+	key, _, err := w.call(ctx, keyID+"/unwrapkey", wrapped)
+	return key, err
+}
+
+// call runs a wrapkey or unwrapkey operation and returns its result and the
+// versioned key ID that performed it
+func (w *KeyVaultKeyWrapper) call(ctx context.Context, endpoint string, value []byte) ([]byte, string, error) {
+	token, err := w.credential.GetToken(ctx, policy.TokenRequestOptions{
+		Scopes: []string{"https://vault.azure.net/.default"},
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get Key Vault token: %w", err)
+	}
+
+	body, _ := json.Marshal(map[string]string{
+		"alg":   "RSA-OAEP-256",
+		"value": base64.RawURLEncoding.EncodeToString(value),
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"?api-version="+keyVaultAPIVersion, bytes.NewReader(body))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("Key Vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, "", fmt.Errorf("Key Vault returned status %d: %s", resp.StatusCode, string(msg))
+	}
+
+	var result struct {
+		KID   string `json:"kid"`
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, "", fmt.Errorf("failed to decode Key Vault response: %w", err)
+	}
+	out, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(result.Value, "="))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode Key Vault response: %w", err)
+	}
+	return out, result.KID, nil
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// LocalStore keeps artifacts on the local filesystem as <root>/<tenant>/<capsule>/<name>.
+// Content is stored once per SHA-256 under <root>/.blobs; artifact files
+// point at their blob, see cas.go.
+type LocalStore struct {
+	root string
+
+	mu sync.Mutex // Serializes pointer updates with garbage collection
+}
+
+// NewLocalStore creates a filesystem artifact store rooted at dir
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create artifact directory: %w", err)
+	}
+	return &LocalStore{root: dir}, nil
+}
+
+func (s *LocalStore) Put(_ context.Context, tenantID, capsuleID, name string, r io.Reader) (*Artifact, error) {
+	if tenantID == "" {
+		tenantID = DefaultTenant
+	}
+	for _, part := range []string{tenantID, capsuleID, name} {
+		if !validSegment(part) {
+			return nil, fmt.Errorf("invalid artifact path segment: %q", part)
+		}
+	}
+
+	key := path.Join(tenantID, capsuleID, name)
+	if err := s.putBlob(key, r); err != nil {
+		return nil, err
+	}
+	return s.stat(key)
+}
+
+func (s *LocalStore) List(ctx context.Context, capsuleID string) ([]Artifact, error) {
+	all, err := s.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := make([]Artifact, 0)
+	for _, a := range all {
+		if a.CapsuleID == capsuleID {
+			artifacts = append(artifacts, a)
+		}
+	}
+	return artifacts, nil
+}
+
+func (s *LocalStore) ListAll(_ context.Context) ([]Artifact, error) {
+	artifacts := make([]Artifact, 0)
+	err := filepath.WalkDir(s.root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && p != s.root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir // The blob store
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.Count(key, "/") != 2 {
+			return nil
+		}
+		artifact, err := s.stat(key)
+		if err != nil {
+			return err
+		}
+		artifacts = append(artifacts, *artifact)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list artifacts: %w", err)
+	}
+	return artifacts, nil
+}
+
+func (s *LocalStore) Open(_ context.Context, key string) (io.ReadCloser, *Artifact, error) {
+	if !validKey(key) {
+		return nil, nil, ErrNotFound
+	}
+	artifact, err := s.stat(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(s.contentPath(key))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open artifact: %w", err)
+	}
+	return f, artifact, nil
+}
+
+func (s *LocalStore) Delete(_ context.Context, key string) error {
+	if !validKey(key) {
+		return ErrNotFound
+	}
+	if err := s.unlink(key); err != nil {
+		return err
+	}
+	// Remove the capsule directory once it is empty
+	os.Remove(filepath.Dir(s.pathFor(key)))
+	return nil
+}
+
+func (s *LocalStore) pathFor(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
+
+func (s *LocalStore) stat(key string) (*Artifact, error) {
+	info, err := os.Stat(s.pathFor(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to stat artifact: %w", err)
+	}
+	size := info.Size()
+	if hash := readPointer(s.pathFor(key), info); hash != "" {
+		blob, err := os.Stat(s.blobPath(hash))
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat blob of %s: %w", key, err)
+		}
+		size = blob.Size()
+	}
+
+	parts := strings.SplitN(key, "/", 3)
+	return &Artifact{
+		Key:       key,
+		TenantID:  parts[0],
+		CapsuleID: parts[1],
+		Name:      parts[2],
+		SizeBytes: size,
+		CreatedAt: info.ModTime(),
+	}, nil
+}
+
+func validSegment(s string) bool {
+	// Hidden names are reserved for the blob store and uploads in progress
+	return s != "" && !strings.HasPrefix(s, ".") && !strings.ContainsAny(s, `/\`)
+}
+
+func validKey(key string) bool {
+	parts := strings.Split(key, "/")
+	if len(parts) != 3 {
+		return false
+	}
+	for _, p := range parts {
+		if !validSegment(p) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+
+	"QLP/internal/types"
+)
+
+// DefaultInlineLimit is the largest attachment kept inline in a task result
+const DefaultInlineLimit = 256 << 10
+
+// refScheme prefixes the URI of an artifact reference
+const refScheme = "artifact://"
+
+// ErrChecksumMismatch is returned when a referenced artifact's content does
+// not match the checksum recorded in its reference
+var ErrChecksumMismatch = errors.New("artifact checksum mismatch")
+
+// RefURI returns the reference URI for an artifact key
+func RefURI(key string) string {
+	return refScheme + key
+}
+
+// KeyFromURI returns the artifact key of a reference URI
+func KeyFromURI(uri string) (string, error) {
+	if !strings.HasPrefix(uri, refScheme) {
+		return "", fmt.Errorf("not an artifact URI: %q", uri)
+	}
+	return strings.TrimPrefix(uri, refScheme), nil
+}
+
+// OffloadAttachments moves attachments larger than inlineLimit into store and
+// records references to them in result.ArtifactRefs. Attachments that fail
+// to upload stay inline.
+func OffloadAttachments(ctx context.Context, store ArtifactStore, tenantID, capsuleID string, result *types.TaskResult, inlineLimit int) error {
+	names := make([]string, 0, len(result.Attachments))
+	for name, data := range result.Attachments {
+		if len(data) > inlineLimit {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		data := result.Attachments[name]
+		sum := sha256.Sum256(data)
+		artifact, err := store.Put(ctx, tenantID, capsuleID, result.TaskID+"-"+name, bytes.NewReader(data))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to offload %s: %w", name, err))
+			continue
+		}
+		if result.ArtifactRefs == nil {
+			result.ArtifactRefs = make(map[string]types.ArtifactRef)
+		}
+		result.ArtifactRefs[name] = types.ArtifactRef{
+			URI:       RefURI(artifact.Key),
+			SHA256:    hex.EncodeToString(sum[:]),
+			SizeBytes: artifact.SizeBytes,
+		}
+		delete(result.Attachments, name)
+	}
+	return errors.Join(errs...)
+}
+
+// OpenRef streams a referenced artifact. The reader returns
+// ErrChecksumMismatch at the end of the content if it does not match the
+// reference, so callers must read to EOF before trusting what they copied.
+func OpenRef(ctx context.Context, store ArtifactStore, ref types.ArtifactRef) (io.ReadCloser, error) {
+	key, err := KeyFromURI(ref.URI)
+	if err != nil {
+		return nil, err
+	}
+	rc, artifact, err := store.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if artifact.SizeBytes != ref.SizeBytes {
+		rc.Close()
+		return nil, fmt.Errorf("%w: %s is %d bytes, reference says %d", ErrChecksumMismatch, key, artifact.SizeBytes, ref.SizeBytes)
+	}
+	return &verifyingReader{rc: rc, hash: sha256.New(), want: ref.SHA256}, nil
+}
+
+// verifyingReader hashes content as it is read and checks it at EOF
+type verifyingReader struct {
+	rc   io.ReadCloser
+	hash hash.Hash
+	want string
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.rc.Read(p)
+	v.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(v.hash.Sum(nil)) != v.want {
+		return n, ErrChecksumMismatch
+	}
+	return n, err
+}
+
+func (v *verifyingReader) Close() error {
+	return v.rc.Close()
+}
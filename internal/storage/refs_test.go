@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"QLP/internal/types"
+)
+
+func TestOffloadAttachmentsAndOpenRef(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	large := bytes.Repeat([]byte("x"), 64)
+	result := &types.TaskResult{
+		TaskID:      "task_1",
+		Attachments: map[string][]byte{"junit.xml": []byte("<ok/>"), "deployment_result.json": large},
+	}
+	if err := OffloadAttachments(ctx, store, "acme", "capsule-1", result, 16); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, inline := result.Attachments["deployment_result.json"]; inline || len(result.Attachments) != 1 {
+		t.Fatalf("large attachment should be offloaded, inline: %v", result.Attachments)
+	}
+	ref, ok := result.ArtifactRefs["deployment_result.json"]
+	if !ok || ref.SizeBytes != 64 || ref.URI != "artifact://acme/capsule-1/task_1-deployment_result.json" {
+		t.Fatalf("unexpected reference %+v", ref)
+	}
+
+	rc, err := OpenRef(ctx, store, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || !bytes.Equal(data, large) {
+		t.Fatalf("read %d bytes, err %v", len(data), err)
+	}
+
+	// Same size, different content
+	os.WriteFile(filepath.Join(dir, "acme", "capsule-1", "task_1-deployment_result.json"), bytes.Repeat([]byte("y"), 64), 0644)
+	rc, err = OpenRef(ctx, store, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if _, err := io.ReadAll(rc); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"QLP/internal/logger"
+	"go.uber.org/zap"
+)
+
+// RetentionPolicy defines how long artifacts are kept, optionally per tenant
+type RetentionPolicy struct {
+	Default   time.Duration
+	PerTenant map[string]time.Duration
+}
+
+// For returns the retention period for a tenant
+func (p RetentionPolicy) For(tenantID string) time.Duration {
+	if d, ok := p.PerTenant[tenantID]; ok {
+		return d
+	}
+	return p.Default
+}
+
+// ParseRetentionPolicy builds a policy from a default day count and a
+// "tenant=days,tenant=days" override list
+func ParseRetentionPolicy(defaultDays int, overrides string) (RetentionPolicy, error) {
+	policy := RetentionPolicy{
+		Default:   time.Duration(defaultDays) * 24 * time.Hour,
+		PerTenant: make(map[string]time.Duration),
+	}
+
+	for _, pair := range strings.Split(overrides, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return policy, fmt.Errorf("invalid retention override %q, expected tenant=days", pair)
+		}
+		days, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || days <= 0 {
+			return policy, fmt.Errorf("invalid retention days for tenant %s", parts[0])
+		}
+		policy.PerTenant[strings.TrimSpace(parts[0])] = time.Duration(days) * 24 * time.Hour
+	}
+
+	return policy, nil
+}
+
+// garbageCollector is implemented by stores that deduplicate content
+type garbageCollector interface {
+	CollectGarbage(ctx context.Context) (int, int64, error)
+}
+
+// RetentionSweeper deletes artifacts older than their tenant's retention period
+type RetentionSweeper struct {
+	store  ArtifactStore
+	policy RetentionPolicy
+}
+
+// NewRetentionSweeper creates a sweeper for store
+func NewRetentionSweeper(store ArtifactStore, policy RetentionPolicy) *RetentionSweeper {
+	return &RetentionSweeper{store: store, policy: policy}
+}
+
+// Sweep deletes expired artifacts once and returns how many were removed
+func (rs *RetentionSweeper) Sweep(ctx context.Context) (int, error) {
+	artifacts, err := rs.store.ListAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	now := time.Now()
+	for _, a := range artifacts {
+		retention := rs.policy.For(a.TenantID)
+		if retention <= 0 || now.Sub(a.CreatedAt) < retention {
+			continue
+		}
+		if err := rs.store.Delete(ctx, a.Key); err != nil {
+			logger.WithComponent("storage").Warn("Failed to delete expired artifact",
+				zap.String("key", a.Key),
+				zap.Error(err))
+			continue
+		}
+		deleted++
+	}
+
+	if deleted > 0 {
+		logger.WithComponent("storage").Info("Retention sweep removed expired artifacts",
+			zap.Int("deleted", deleted))
+	}
+
+	// Content-addressed stores also drop blobs nothing points at any more
+	if gc, ok := rs.store.(garbageCollector); ok {
+		blobs, freed, err := gc.CollectGarbage(ctx)
+		if err != nil {
+			return deleted, err
+		}
+		if blobs > 0 {
+			logger.WithComponent("storage").Info("Removed unreferenced blobs",
+				zap.Int("blobs", blobs),
+				zap.Int64("bytes", freed))
+		}
+	}
+	return deleted, nil
+}
+
+// Run sweeps on the given interval until ctx is cancelled
+func (rs *RetentionSweeper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := rs.Sweep(ctx); err != nil {
+			logger.WithComponent("storage").Error("Retention sweep failed", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRetentionPolicy(t *testing.T) {
+	policy, err := ParseRetentionPolicy(30, "acme=7, globex=365")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.For("acme") != 7*24*time.Hour || policy.For("globex") != 365*24*time.Hour || policy.For("initech") != 30*24*time.Hour {
+		t.Errorf("policy = %+v", policy)
+	}
+	for _, overrides := range []string{"acme", "acme=0", "acme=week"} {
+		if _, err := ParseRetentionPolicy(30, overrides); err == nil {
+			t.Errorf("%q parsed", overrides)
+		}
+	}
+}
+
+func TestRetentionSweep(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, tenant := range []string{"acme", "globex"} {
+		for _, capsule := range []string{"old", "new"} {
+			if _, err := store.Put(ctx, tenant, capsule, "app.zip", strings.NewReader(tenant+"/"+capsule)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// Every capsule named old was stored 10 days ago, with its content
+	past := time.Now().Add(-10 * 24 * time.Hour)
+	for _, tenant := range []string{"acme", "globex"} {
+		os.Chtimes(filepath.Join(dir, tenant, "old", "app.zip"), past, past)
+	}
+	ageBlobs(t, dir)
+
+	sweeper := NewRetentionSweeper(store, RetentionPolicy{
+		Default:   30 * 24 * time.Hour,
+		PerTenant: map[string]time.Duration{"acme": 7 * 24 * time.Hour},
+	})
+	deleted, err := sweeper.Sweep(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Errorf("deleted %d artifacts", deleted)
+	}
+	artifacts, _ := store.ListAll(ctx)
+	var keys []string
+	for _, a := range artifacts {
+		keys = append(keys, a.Key)
+	}
+	if strings.Join(keys, " ") != "acme/new/app.zip globex/new/app.zip globex/old/app.zip" {
+		t.Errorf("kept %v", keys)
+	}
+	// The expired artifact's content went with it
+	if usage, _ := store.Usage(ctx); usage.Blobs != 3 {
+		t.Errorf("usage after sweep = %+v", usage)
+	}
+}
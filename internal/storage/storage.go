@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound is returned when an artifact does not exist
+var ErrNotFound = errors.New("artifact not found")
+
+// DefaultTenant is used for artifacts produced without a tenant in context
+const DefaultTenant = "default"
+
+// Artifact describes a stored capsule or drop file
+type Artifact struct {
+	Key         string     `json:"key"`
+	TenantID    string     `json:"tenant_id"`
+	CapsuleID   string     `json:"capsule_id"`
+	Name        string     `json:"name"`
+	SizeBytes   int64      `json:"size_bytes"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DownloadURL string     `json:"download_url,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// ArtifactStore persists capsule artifacts durably
+type ArtifactStore interface {
+	Put(ctx context.Context, tenantID, capsuleID, name string, r io.Reader) (*Artifact, error)
+	List(ctx context.Context, capsuleID string) ([]Artifact, error)
+	ListAll(ctx context.Context) ([]Artifact, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, *Artifact, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// OfTenant keeps the artifacts of a tenant, or all of them when tenantID is
+// empty
+func OfTenant(artifacts []Artifact, tenantID string) []Artifact {
+	if tenantID == "" {
+		return artifacts
+	}
+	kept := artifacts[:0]
+	for _, a := range artifacts {
+		if a.TenantID == tenantID {
+			kept = append(kept, a)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,362 @@
+package tenants
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/config"
+)
+
+// Routes returns the tenant admin endpoints. Every request must carry
+// "Authorization: Bearer <adminToken>"; the acting admin comes from an
+// "actor" field in the body, or the actor query parameter on DELETE.
+//
+//	POST   /admin/tenants                       creates a tenant and returns its first API key
+//	GET    /admin/tenants                       lists tenants
+//	GET    /admin/tenants/{tenant}              returns a tenant
+//	PATCH  /admin/tenants/{tenant}              sets the name, plan or limits
+//	POST   /admin/tenants/{tenant}/keys         issues a key with {"name", "scopes", "ttl"}
+//	GET    /admin/tenants/{tenant}/keys         lists the tenant's keys, without secrets
+//	DELETE /admin/tenants/{tenant}/keys/{key}   revokes a key
+//	POST   /admin/tenants/{tenant}/keys/rotate  replaces a key, {"key_id", "grace": "24h"} keeps the old one working meanwhile
+//	POST   /admin/tenants/{tenant}/suspend      refuses the tenant's API keys, with a reason
+//	POST   /admin/tenants/{tenant}/resume       accepts them again
+func Routes(svc *Service, adminToken string) map[string]http.Handler {
+	routes := map[string]http.Handler{
+		"POST /admin/tenants":                       createHandler(svc),
+		"GET /admin/tenants":                        listHandler(svc),
+		"GET /admin/tenants/{tenant}":               getHandler(svc),
+		"PATCH /admin/tenants/{tenant}":             updateHandler(svc),
+		"POST /admin/tenants/{tenant}/keys":         createKeyHandler(svc),
+		"GET /admin/tenants/{tenant}/keys":          listKeysHandler(svc),
+		"DELETE /admin/tenants/{tenant}/keys/{key}": revokeKeyHandler(svc),
+		"POST /admin/tenants/{tenant}/keys/rotate":  rotateHandler(svc),
+		"POST /admin/tenants/{tenant}/suspend":      suspendHandler(svc),
+		"POST /admin/tenants/{tenant}/resume":       resumeHandler(svc),
+	}
+	for pattern, h := range routes {
+		routes[pattern] = AdminOnly(adminToken, h)
+	}
+	return routes
+}
+
+// KeyRequirement decides when Middleware refuses requests without an API key
+type KeyRequirement int
+
+const (
+	KeysOnceTenantsExist KeyRequirement = iota // Required as soon as any tenant exists
+	KeysRequired                               // Required on every request
+	KeysOptional                               // Never required
+)
+
+// KeyRequirementFromEnv reads QLP_REQUIRE_API_KEY: true requires a key on
+// every request, false on none, and unset once any tenant exists
+func KeyRequirementFromEnv() KeyRequirement {
+	switch config.GetEnvOrDefault("QLP_REQUIRE_API_KEY", "") {
+	case "true":
+		return KeysRequired
+	case "false":
+		return KeysOptional
+	}
+	return KeysOnceTenantsExist
+}
+
+// Middleware resolves the API key of each request to the route pattern,
+// from "Authorization: Bearer" or X-API-Key, to its tenant and attaches
+// the tenant and key to the request context. Unknown keys are refused with
+// 401; suspended tenants and keys without the scope the request needs (see
+// RequiredScope) with 403, as are keys naming another tenant in the
+// {tenant} path value or the tenant or tenant_id query parameter. Requests
+// without a key are refused with 401 when require calls for one.
+func Middleware(svc *Service, require KeyRequirement, pattern string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := requestKey(r)
+		if key == "" {
+			required := require == KeysRequired
+			if require == KeysOnceTenantsExist {
+				var err error
+				if required, err = svc.HasTenants(r.Context()); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+			if required {
+				http.Error(w, "API key required", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		t, apiKey, err := svc.Authenticate(r.Context(), key)
+		switch {
+		case errors.Is(err, ErrSuspended):
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		case errors.Is(err, ErrInvalidKey):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if scope := RequiredScope(r.Method, pattern); !apiKey.Allows(scope) {
+			http.Error(w, fmt.Sprintf("API key %s lacks the %s scope", apiKey.Prefix, scope), http.StatusForbidden)
+			return
+		}
+		q := r.URL.Query()
+		for _, requested := range []string{r.PathValue("tenant"), q.Get("tenant"), q.Get("tenant_id")} {
+			if requested != "" && requested != t.ID {
+				http.Error(w, fmt.Sprintf("API key %s belongs to another tenant", apiKey.Prefix), http.StatusForbidden)
+				return
+			}
+		}
+		ctx := audit.WithActor(audit.WithTenant(r.Context(), t.ID), "api-key:"+apiKey.ID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func requestKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return ""
+}
+
+// AdminOnly refuses requests without "Authorization: Bearer <token>", and
+// every request when token is empty
+func AdminOnly(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			http.Error(w, "admin token required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// issued is a tenant with an API key that was just issued
+type issued struct {
+	*Tenant
+	APIKey string `json:"api_key"`
+}
+
+func createHandler(svc *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID    string `json:"id"`
+			Actor string `json:"actor"`
+			Update
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "request body must be JSON with id and optional name, plan and limits", http.StatusBadRequest)
+			return
+		}
+		t, key, err := svc.Create(r.Context(), req.ID, req.Update, req.Actor)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, issued{Tenant: t, APIKey: key})
+	})
+}
+
+func listHandler(svc *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		list, err := svc.List(r.Context())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"tenants": list})
+	})
+}
+
+func getHandler(svc *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, err := svc.Get(r.Context(), r.PathValue("tenant"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, t)
+	})
+}
+
+func updateHandler(svc *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Actor string `json:"actor"`
+			Update
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "request body must be JSON with name, plan or limits", http.StatusBadRequest)
+			return
+		}
+		t, err := svc.Update(r.Context(), r.PathValue("tenant"), req.Update, req.Actor)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, t)
+	})
+}
+
+func createKeyHandler(svc *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Actor  string   `json:"actor"`
+			Name   string   `json:"name"`
+			Scopes []string `json:"scopes"`
+			TTL    string   `json:"ttl"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "request body must be JSON with scopes and optional name and ttl", http.StatusBadRequest)
+			return
+		}
+		ttl, ok := duration(w, "ttl", req.TTL)
+		if !ok {
+			return
+		}
+		k, key, err := svc.CreateKey(r.Context(), r.PathValue("tenant"),
+			KeyRequest{Name: req.Name, Scopes: req.Scopes, TTL: ttl}, req.Actor)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"key": k, "api_key": key})
+	})
+}
+
+func listKeysHandler(svc *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys, err := svc.ListKeys(r.Context(), r.PathValue("tenant"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"keys": keys})
+	})
+}
+
+func revokeKeyHandler(svc *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := svc.RevokeKey(r.Context(), r.PathValue("tenant"), r.PathValue("key"), r.URL.Query().Get("actor")); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func rotateHandler(svc *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Actor string `json:"actor"`
+			KeyID string `json:"key_id"`
+			Grace string `json:"grace"`
+		}
+		if err := decodeOptional(r, &req); err != nil {
+			http.Error(w, "request body must be JSON with optional actor, key_id and grace", http.StatusBadRequest)
+			return
+		}
+		grace, ok := duration(w, "grace", req.Grace)
+		if !ok {
+			return
+		}
+		t, key, err := svc.RotateKey(r.Context(), r.PathValue("tenant"), req.KeyID, grace, req.Actor)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, issued{Tenant: t, APIKey: key})
+	})
+}
+
+// duration parses an optional non-negative duration field, answering 400
+// when it is invalid
+func duration(w http.ResponseWriter, field, value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, true
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		http.Error(w, fmt.Sprintf("invalid %s %q", field, value), http.StatusBadRequest)
+		return 0, false
+	}
+	return d, true
+}
+
+func suspendHandler(svc *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Actor  string `json:"actor"`
+			Reason string `json:"reason"`
+		}
+		if err := decodeOptional(r, &req); err != nil {
+			http.Error(w, "request body must be JSON with optional actor and reason", http.StatusBadRequest)
+			return
+		}
+		t, err := svc.Suspend(r.Context(), r.PathValue("tenant"), req.Reason, req.Actor)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, t)
+	})
+}
+
+func resumeHandler(svc *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Actor string `json:"actor"`
+		}
+		if err := decodeOptional(r, &req); err != nil {
+			http.Error(w, "request body must be JSON with optional actor", http.StatusBadRequest)
+			return
+		}
+		t, err := svc.Resume(r.Context(), r.PathValue("tenant"), req.Actor)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, t)
+	})
+}
+
+// decodeOptional decodes a JSON body that may be empty
+func decodeOptional(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrKeyNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrExists):
+		status = http.StatusConflict
+	case errors.Is(err, ErrInvalidRequest):
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
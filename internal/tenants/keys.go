@@ -0,0 +1,256 @@
+package tenants
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"QLP/internal/audit"
+)
+
+// keyPrefix starts every API key, so leaked keys are easy to search for
+const keyPrefix = "qlp_"
+
+// API key scopes
+const (
+	ScopeGenerate = "generate" // Submit intents, batches, validations and schedules
+	ScopeRead     = "read"     // Read results, capsules and reports
+	ScopeDeploy   = "deploy"   // Promote capsules and roll deployments back
+	ScopeAdmin    = "admin"    // Change the shared prompts and manage artifact data keys
+)
+
+// AllScopes are the scopes of a tenant's first key. ScopeAdmin is only
+// held by keys issued with it.
+var AllScopes = []string{ScopeGenerate, ScopeRead, ScopeDeploy}
+
+// APIKey is a key a tenant authenticates with. Only a hash of the key is
+// stored; the key itself is returned once, when it is issued.
+type APIKey struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name,omitempty"`
+	Prefix    string     `json:"prefix"` // Start of the key, to tell keys apart
+	Hash      string     `json:"hash,omitempty"`
+	Scopes    []string   `json:"scopes,omitempty"` // Empty on keys issued before scoping, which hold every scope but admin
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // Set on keys issued with a lifetime or replaced with a grace period
+}
+
+// Allows reports whether the key holds scope
+func (k APIKey) Allows(scope string) bool {
+	if len(k.Scopes) == 0 {
+		return scope != ScopeAdmin
+	}
+	return slices.Contains(k.Scopes, scope)
+}
+
+func (k APIKey) expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// KeyRequest asks for a new API key. TTL, when set, makes the key expire.
+type KeyRequest struct {
+	Name   string        `json:"name"`
+	Scopes []string      `json:"scopes"`
+	TTL    time.Duration `json:"-"`
+}
+
+// RequiredScope returns the scope a request needs on a route: artifact
+// data keys and changes to the prompts every tenant shares need ScopeAdmin,
+// other reads ScopeRead, promotions and deployment rollbacks ScopeDeploy,
+// and every other change ScopeGenerate
+func RequiredScope(method, pattern string) string {
+	path := pattern
+	if _, p, ok := strings.Cut(pattern, " "); ok {
+		path = p
+	}
+	read := method == http.MethodGet || method == http.MethodHead
+	switch {
+	case strings.HasPrefix(path, "/artifact-keys"), strings.HasPrefix(path, "/prompts") && !read:
+		return ScopeAdmin
+	case read:
+		return ScopeRead
+	case strings.HasPrefix(path, "/promotions"), strings.HasPrefix(path, "/deployments"):
+		return ScopeDeploy
+	}
+	return ScopeGenerate
+}
+
+// CreateKey issues an additional API key with the requested scopes,
+// returned alongside the stored key and not retrievable again
+func (s *Service) CreateKey(ctx context.Context, tenantID string, req KeyRequest, actor string) (APIKey, string, error) {
+	var key string
+	var apiKey APIKey
+	err := validScopes(req.Scopes)
+	if err == nil && req.TTL < 0 {
+		err = fmt.Errorf("%w: key lifetime cannot be negative", ErrInvalidRequest)
+	}
+	if err == nil {
+		_, err = s.modify(ctx, tenantID, func(t *Tenant) error {
+			now := s.now().UTC()
+			key, apiKey = newKey(req.Name, req.Scopes, now)
+			if req.TTL > 0 {
+				expires := now.Add(req.TTL)
+				apiKey.ExpiresAt = &expires
+			}
+			t.APIKeys = append(prune(t.APIKeys, now), apiKey)
+			return nil
+		})
+	}
+	s.record(ctx, audit.ActionTenantKeyCreate, tenantID, actor, map[string]interface{}{
+		"key_id": apiKey.ID,
+		"name":   req.Name,
+		"scopes": req.Scopes,
+	}, err)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	apiKey.Hash = ""
+	return apiKey, key, nil
+}
+
+// ListKeys returns a tenant's keys that have not expired, without hashes
+func (s *Service) ListKeys(ctx context.Context, tenantID string) ([]APIKey, error) {
+	t, err := s.Get(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return prune(t.APIKeys, s.now().UTC()), nil
+}
+
+// RevokeKey stops an API key from being accepted at once
+func (s *Service) RevokeKey(ctx context.Context, tenantID, keyID, actor string) error {
+	_, err := s.modify(ctx, tenantID, func(t *Tenant) error {
+		i := slices.IndexFunc(t.APIKeys, func(k APIKey) bool { return k.ID == keyID })
+		if i < 0 {
+			return fmt.Errorf("%w: %s", ErrKeyNotFound, keyID)
+		}
+		t.APIKeys = slices.Delete(t.APIKeys, i, i+1)
+		return nil
+	})
+	s.record(ctx, audit.ActionTenantKeyRevoke, tenantID, actor, map[string]interface{}{"key_id": keyID}, err)
+	return err
+}
+
+// RotateKey replaces an API key with a new one of the same name and
+// scopes, returned alongside the tenant. keyID may be empty for tenants
+// with a single key. The old key stops working after grace, or at once
+// without one.
+func (s *Service) RotateKey(ctx context.Context, tenantID, keyID string, grace time.Duration, actor string) (*Tenant, string, error) {
+	var key string
+	var apiKey APIKey
+	t, err := s.modify(ctx, tenantID, func(t *Tenant) error {
+		now := s.now().UTC()
+		t.APIKeys = prune(t.APIKeys, now)
+		i := slices.IndexFunc(t.APIKeys, func(k APIKey) bool { return k.ID == keyID })
+		if keyID == "" && len(t.APIKeys) == 1 {
+			i = 0
+		}
+		if i < 0 {
+			if keyID == "" {
+				return fmt.Errorf("%w: tenant %s has %d keys, name the one to rotate", ErrInvalidRequest, t.ID, len(t.APIKeys))
+			}
+			return fmt.Errorf("%w: %s", ErrKeyNotFound, keyID)
+		}
+		old := t.APIKeys[i]
+		key, apiKey = newKey(old.Name, old.Scopes, now)
+		if grace > 0 {
+			expires := now.Add(grace)
+			if old.ExpiresAt == nil || old.ExpiresAt.After(expires) {
+				t.APIKeys[i].ExpiresAt = &expires
+			}
+		} else {
+			t.APIKeys = slices.Delete(t.APIKeys, i, i+1)
+		}
+		t.APIKeys = append(t.APIKeys, apiKey)
+		return nil
+	})
+	s.record(ctx, audit.ActionTenantKeyRotate, tenantID, actor, map[string]interface{}{
+		"replaced_key_id": keyID,
+		"key_id":          apiKey.ID,
+		"grace":           grace.String(),
+	}, err)
+	if err != nil {
+		return nil, "", err
+	}
+	return t, key, nil
+}
+
+// Authenticate returns the tenant an API key belongs to and the stored key,
+// without its hash. Keys of suspended tenants return ErrSuspended.
+func (s *Service) Authenticate(ctx context.Context, key string) (*Tenant, APIKey, error) {
+	if !strings.HasPrefix(key, keyPrefix) {
+		return nil, APIKey{}, ErrInvalidKey
+	}
+	hash := hashKey(key)
+	t, err := s.store.FindByKey(ctx, hash)
+	if errors.Is(err, ErrNotFound) {
+		return nil, APIKey{}, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, APIKey{}, err
+	}
+	i := slices.IndexFunc(t.APIKeys, func(k APIKey) bool { return k.Hash == hash })
+	if i < 0 {
+		return nil, APIKey{}, ErrInvalidKey
+	}
+	apiKey := t.APIKeys[i]
+	apiKey.Hash = ""
+	if apiKey.expired(s.now().UTC()) {
+		return nil, APIKey{}, fmt.Errorf("%w: key %s expired", ErrInvalidKey, apiKey.Prefix)
+	}
+	if t.Status == StatusSuspended {
+		return redact(t), apiKey, fmt.Errorf("%w: %s", ErrSuspended, t.ID)
+	}
+	return redact(t), apiKey, nil
+}
+
+// newKey generates an API key and its stored form
+func newKey(name string, scopes []string, now time.Time) (string, APIKey) {
+	secret := make([]byte, 24)
+	rand.Read(secret)
+	key := keyPrefix + hex.EncodeToString(secret)
+	return key, APIKey{
+		ID:        fmt.Sprintf("KEY-%d", now.UnixNano()),
+		Name:      name,
+		Prefix:    key[:len(keyPrefix)+8],
+		Hash:      hashKey(key),
+		Scopes:    slices.Clone(scopes),
+		CreatedAt: now,
+	}
+}
+
+func validScopes(scopes []string) error {
+	known := append(slices.Clone(AllScopes), ScopeAdmin)
+	if len(scopes) == 0 {
+		return fmt.Errorf("%w: a key needs at least one of the scopes %s", ErrInvalidRequest, strings.Join(known, ", "))
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(known, scope) {
+			return fmt.Errorf("%w: unknown scope %q, expected %s", ErrInvalidRequest, scope, strings.Join(known, ", "))
+		}
+	}
+	return nil
+}
+
+// prune drops expired keys
+func prune(keys []APIKey, now time.Time) []APIKey {
+	kept := make([]APIKey, 0, len(keys))
+	for _, k := range keys {
+		if !k.expired(now) {
+			kept = append(kept, k)
+		}
+	}
+	return kept
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,212 @@
+package tenants
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"QLP/internal/database"
+	"QLP/internal/logger"
+)
+
+// Store keeps tenants, including the hashes of their API keys
+type Store interface {
+	// Create adds a tenant, returning ErrExists when its ID is taken
+	Create(ctx context.Context, t *Tenant) error
+	Save(ctx context.Context, t *Tenant) error
+	Get(ctx context.Context, id string) (*Tenant, error)
+	List(ctx context.Context) ([]*Tenant, error)
+	// FindByKey returns the tenant with an API key of the given hash
+	FindByKey(ctx context.Context, hash string) (*Tenant, error)
+}
+
+// MemoryStore keeps tenants in memory, for tests and deployments without a
+// database
+type MemoryStore struct {
+	mu      sync.Mutex
+	tenants map[string]Tenant
+}
+
+// NewMemoryStore creates an empty in-memory tenant store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tenants: make(map[string]Tenant)}
+}
+
+func (s *MemoryStore) Create(_ context.Context, t *Tenant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tenants[t.ID]; ok {
+		return fmt.Errorf("%w: %s", ErrExists, t.ID)
+	}
+	s.tenants[t.ID] = clone(t)
+	return nil
+}
+
+func (s *MemoryStore) Save(_ context.Context, t *Tenant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tenants[t.ID] = clone(t)
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, id string) (*Tenant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tenants[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	c := clone(&t)
+	return &c, nil
+}
+
+func (s *MemoryStore) List(_ context.Context) ([]*Tenant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]*Tenant, 0, len(s.tenants))
+	for _, t := range s.tenants {
+		c := clone(&t)
+		list = append(list, &c)
+	}
+	return list, nil
+}
+
+func (s *MemoryStore) FindByKey(_ context.Context, hash string) (*Tenant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tenants {
+		for _, k := range t.APIKeys {
+			if k.Hash == hash {
+				c := clone(&t)
+				return &c, nil
+			}
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// clone copies a tenant so callers cannot change stored keys
+func clone(t *Tenant) Tenant {
+	c := *t
+	c.APIKeys = append([]APIKey(nil), t.APIKeys...)
+	return c
+}
+
+// PostgresStore keeps tenants in the tenants table, shared by every QLP
+// process
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates the tenants table if needed
+func NewPostgresStore(db *sql.DB) (*PostgresStore, error) {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS tenants (
+			id VARCHAR(100) PRIMARY KEY,
+			tenant JSONB NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_tenants_api_keys ON tenants USING GIN ((tenant->'api_keys') jsonb_path_ops);
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tenants table: %w", err)
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+func (s *PostgresStore) Create(ctx context.Context, t *Tenant) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("failed to encode tenant: %w", err)
+	}
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO tenants (id, tenant, created_at, updated_at) VALUES ($1, $2, $3, $3)
+		 ON CONFLICT (id) DO NOTHING`,
+		t.ID, data, t.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create tenant: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", ErrExists, t.ID)
+	}
+	return nil
+}
+
+func (s *PostgresStore) Save(ctx context.Context, t *Tenant) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("failed to encode tenant: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO tenants (id, tenant, created_at, updated_at) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (id) DO UPDATE SET tenant = $2, updated_at = $4`,
+		t.ID, data, t.CreatedAt, t.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to save tenant: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) Get(ctx context.Context, id string) (*Tenant, error) {
+	return s.queryOne(ctx, `SELECT tenant FROM tenants WHERE id = $1`, id)
+}
+
+func (s *PostgresStore) FindByKey(ctx context.Context, hash string) (*Tenant, error) {
+	match, _ := json.Marshal([]map[string]string{{"hash": hash}})
+	return s.queryOne(ctx, `SELECT tenant FROM tenants WHERE tenant->'api_keys' @> $1::jsonb`, string(match))
+}
+
+func (s *PostgresStore) queryOne(ctx context.Context, query string, arg string) (*Tenant, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, query, arg).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenant: %w", err)
+	}
+	var t Tenant
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("failed to decode tenant: %w", err)
+	}
+	return &t, nil
+}
+
+func (s *PostgresStore) List(ctx context.Context) ([]*Tenant, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT tenant FROM tenants ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tenants: %w", err)
+	}
+	defer rows.Close()
+
+	var list []*Tenant
+	for rows.Next() {
+		var data []byte
+		var t Tenant
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to read tenant: %w", err)
+		}
+		if err := json.Unmarshal(data, &t); err != nil {
+			return nil, fmt.Errorf("failed to decode tenant: %w", err)
+		}
+		list = append(list, &t)
+	}
+	return list, rows.Err()
+}
+
+// NewStoreFromEnv keeps tenants in the database at DATABASE_URL, falling
+// back to memory when it is unavailable
+func NewStoreFromEnv() (Store, error) {
+	db, err := database.New()
+	if err != nil {
+		return nil, err
+	}
+	if !db.IsConnected() {
+		logger.WithComponent("tenants").Warn("Database unavailable, tenants are kept in memory and lost on restart")
+		return NewMemoryStore(), nil
+	}
+	return NewPostgresStore(db.GetConnection())
+}
@@ -0,0 +1,343 @@
+// Package tenants manages the lifecycle of tenants: creating them, setting
+// their plan and limits, issuing, rotating and revoking their scoped API
+// keys, and suspending and resuming them. Every change is audited.
+// Middleware resolves the API key of an incoming request to its tenant and
+// refuses suspended tenants and keys without the scope the endpoint needs.
+package tenants
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/config"
+	"QLP/internal/logger"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrNotFound is returned for unknown tenants
+	ErrNotFound = errors.New("tenant not found")
+	// ErrExists is returned when creating a tenant whose ID is taken
+	ErrExists = errors.New("tenant already exists")
+	// ErrInvalidRequest is returned for invalid IDs, plans and limits
+	ErrInvalidRequest = errors.New("invalid tenant request")
+	// ErrInvalidKey is returned for unknown and expired API keys
+	ErrInvalidKey = errors.New("invalid API key")
+	// ErrKeyNotFound is returned for unknown key IDs of a tenant
+	ErrKeyNotFound = errors.New("API key not found")
+	// ErrSuspended is returned for API keys of suspended tenants
+	ErrSuspended = errors.New("tenant is suspended")
+)
+
+// Tenant statuses
+const (
+	StatusActive    = "active"
+	StatusSuspended = "suspended"
+)
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,99}$`)
+
+// Limits bound what a tenant uses. Zero fields are unlimited.
+type Limits struct {
+	DailyMinutes  float64 `json:"daily_minutes"`  // Execution minutes per UTC day
+	MaxConcurrent int     `json:"max_concurrent"` // Sandboxes running at once
+	CPU           float64 `json:"cpu"`            // Cores across the running sandboxes
+	MemoryMB      int64   `json:"memory_mb"`      // Memory across the running sandboxes
+}
+
+// Plans are the limits a tenant gets when put on a plan
+var Plans = map[string]Limits{
+	"free":       {DailyMinutes: 60, MaxConcurrent: 1, CPU: 1, MemoryMB: 2048},
+	"standard":   {DailyMinutes: 600, MaxConcurrent: 4, CPU: 4, MemoryMB: 8192},
+	"enterprise": {MaxConcurrent: 16, CPU: 16, MemoryMB: 32768},
+}
+
+// Tenant is a customer of QLP and its configuration
+type Tenant struct {
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	Plan            string    `json:"plan"`
+	Limits          Limits    `json:"limits"`
+	Status          string    `json:"status"`
+	SuspendedReason string    `json:"suspended_reason,omitempty"`
+	APIKeys         []APIKey  `json:"api_keys"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// Update changes a tenant. Nil fields are left as they are; a new plan
+// resets the limits to the plan's before the limit fields apply.
+type Update struct {
+	Name          *string  `json:"name,omitempty"`
+	Plan          *string  `json:"plan,omitempty"`
+	DailyMinutes  *float64 `json:"daily_minutes,omitempty"`
+	MaxConcurrent *int     `json:"max_concurrent,omitempty"`
+	CPU           *float64 `json:"cpu,omitempty"`
+	MemoryMB      *int64   `json:"memory_mb,omitempty"`
+}
+
+// apply changes t in place, rejecting unknown plans and negative limits
+func (u Update) apply(t *Tenant) error {
+	if u.Name != nil {
+		t.Name = *u.Name
+	}
+	if u.Plan != nil {
+		limits, ok := Plans[*u.Plan]
+		if !ok {
+			return fmt.Errorf("%w: unknown plan %q", ErrInvalidRequest, *u.Plan)
+		}
+		t.Plan = *u.Plan
+		t.Limits = limits
+	}
+	if u.DailyMinutes != nil {
+		t.Limits.DailyMinutes = *u.DailyMinutes
+	}
+	if u.MaxConcurrent != nil {
+		t.Limits.MaxConcurrent = *u.MaxConcurrent
+	}
+	if u.CPU != nil {
+		t.Limits.CPU = *u.CPU
+	}
+	if u.MemoryMB != nil {
+		t.Limits.MemoryMB = *u.MemoryMB
+	}
+	l := t.Limits
+	if l.DailyMinutes < 0 || l.MaxConcurrent < 0 || l.CPU < 0 || l.MemoryMB < 0 {
+		return fmt.Errorf("%w: limits cannot be negative", ErrInvalidRequest)
+	}
+	return nil
+}
+
+// Service manages tenants in its store
+type Service struct {
+	store       Store
+	defaultPlan string
+	now         func() time.Time
+
+	mu    sync.Mutex // Serializes read-modify-write changes
+	hooks []func(Tenant)
+
+	hasTenants atomic.Bool // Set once any tenant exists
+}
+
+// NewService manages tenants in store. New tenants without a plan are put
+// on defaultPlan.
+func NewService(store Store, defaultPlan string) (*Service, error) {
+	if _, ok := Plans[defaultPlan]; !ok {
+		return nil, fmt.Errorf("%w: unknown default plan %q", ErrInvalidRequest, defaultPlan)
+	}
+	return &Service{store: store, defaultPlan: defaultPlan, now: time.Now}, nil
+}
+
+// NewServiceFromEnv keeps tenants in the database at DATABASE_URL, or in
+// memory when it is unavailable. QLP_TENANT_DEFAULT_PLAN is the plan of new
+// tenants (default standard).
+func NewServiceFromEnv() (*Service, error) {
+	store, err := NewStoreFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewService(store, config.GetEnvOrDefault("QLP_TENANT_DEFAULT_PLAN", "standard"))
+}
+
+// OnChange calls fn with a tenant whenever it is created or changed, so
+// its limits can be enforced
+func (s *Service) OnChange(fn func(Tenant)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, fn)
+}
+
+// ApplyAll calls the OnChange hooks for every stored tenant, applying
+// their limits at startup
+func (s *Service) ApplyAll(ctx context.Context) error {
+	list, err := s.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, t := range list {
+		s.changed(t)
+	}
+	return nil
+}
+
+func (s *Service) changed(t *Tenant) {
+	s.mu.Lock()
+	hooks := s.hooks
+	s.mu.Unlock()
+	for _, fn := range hooks {
+		fn(*redact(t))
+	}
+}
+
+// Create adds an active tenant with a first API key holding every scope,
+// which is returned alongside the tenant and cannot be retrieved again
+func (s *Service) Create(ctx context.Context, id string, u Update, actor string) (*Tenant, string, error) {
+	if !validID.MatchString(id) {
+		return nil, "", fmt.Errorf("%w: tenant ID %q must be 1-100 letters, digits, dots, dashes or underscores", ErrInvalidRequest, id)
+	}
+	now := s.now().UTC()
+	t := &Tenant{
+		ID:        id,
+		Name:      id,
+		Plan:      s.defaultPlan,
+		Limits:    Plans[s.defaultPlan],
+		Status:    StatusActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := u.apply(t); err != nil {
+		return nil, "", err
+	}
+	key, apiKey := newKey("default", AllScopes, now)
+	t.APIKeys = []APIKey{apiKey}
+
+	err := s.store.Create(ctx, t)
+	s.record(ctx, audit.ActionTenantCreate, id, actor, map[string]interface{}{
+		"plan":   t.Plan,
+		"limits": t.Limits,
+		"key_id": apiKey.ID,
+	}, err)
+	if err != nil {
+		return nil, "", err
+	}
+	s.changed(t)
+	return redact(t), key, nil
+}
+
+// Get returns a tenant without its key hashes
+func (s *Service) Get(ctx context.Context, id string) (*Tenant, error) {
+	t, err := s.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return redact(t), nil
+}
+
+// List returns every tenant, sorted by ID
+func (s *Service) List(ctx context.Context) ([]*Tenant, error) {
+	list, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	for i, t := range list {
+		list[i] = redact(t)
+	}
+	return list, nil
+}
+
+// HasTenants reports whether any tenant exists. Once one was seen it stays
+// true, so keys remain required after the last tenant is deleted.
+func (s *Service) HasTenants(ctx context.Context) (bool, error) {
+	if s.hasTenants.Load() {
+		return true, nil
+	}
+	list, err := s.store.List(ctx)
+	if err != nil {
+		return false, err
+	}
+	if len(list) > 0 {
+		s.hasTenants.Store(true)
+	}
+	return len(list) > 0, nil
+}
+
+// Update changes a tenant's name, plan or limits
+func (s *Service) Update(ctx context.Context, id string, u Update, actor string) (*Tenant, error) {
+	var before Tenant
+	t, err := s.modify(ctx, id, func(t *Tenant) error {
+		before = *t
+		return u.apply(t)
+	})
+	details := map[string]interface{}{}
+	if t != nil {
+		details["plan"] = t.Plan
+		details["limits"] = t.Limits
+		details["previous_plan"] = before.Plan
+		details["previous_limits"] = before.Limits
+	}
+	s.record(ctx, audit.ActionTenantUpdate, id, actor, details, err)
+	return t, err
+}
+
+// Suspend stops a tenant's API keys from being accepted until it is resumed
+func (s *Service) Suspend(ctx context.Context, id, reason, actor string) (*Tenant, error) {
+	t, err := s.modify(ctx, id, func(t *Tenant) error {
+		t.Status = StatusSuspended
+		t.SuspendedReason = reason
+		return nil
+	})
+	s.record(ctx, audit.ActionTenantSuspend, id, actor, map[string]interface{}{"reason": reason}, err)
+	return t, err
+}
+
+// Resume accepts a suspended tenant's API keys again
+func (s *Service) Resume(ctx context.Context, id, actor string) (*Tenant, error) {
+	t, err := s.modify(ctx, id, func(t *Tenant) error {
+		t.Status = StatusActive
+		t.SuspendedReason = ""
+		return nil
+	})
+	s.record(ctx, audit.ActionTenantResume, id, actor, nil, err)
+	return t, err
+}
+
+// modify applies change to a stored tenant and saves it
+func (s *Service) modify(ctx context.Context, id string, change func(*Tenant) error) (*Tenant, error) {
+	s.mu.Lock()
+	t, err := s.store.Get(ctx, id)
+	if err == nil {
+		err = change(t)
+	}
+	if err == nil {
+		t.UpdatedAt = s.now().UTC()
+		err = s.store.Save(ctx, t)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	s.changed(t)
+	return redact(t), nil
+}
+
+// redact copies a tenant without its key hashes
+func redact(t *Tenant) *Tenant {
+	c := *t
+	c.APIKeys = make([]APIKey, len(t.APIKeys))
+	for i, k := range t.APIKeys {
+		k.Hash = ""
+		c.APIKeys[i] = k
+	}
+	return &c
+}
+
+func (s *Service) record(ctx context.Context, action audit.Action, tenantID, actor string, details map[string]interface{}, err error) {
+	entry := audit.Entry{
+		Action:       action,
+		Outcome:      audit.OutcomeSuccess,
+		ResourceType: "tenant",
+		ResourceIDs:  []string{tenantID},
+		Details:      details,
+	}
+	if err != nil {
+		entry.Outcome = audit.OutcomeFailure
+		entry.Error = err.Error()
+		logger.WithComponent("tenants").Warn("Tenant change failed",
+			zap.String("tenant_id", tenantID),
+			zap.String("action", string(action)),
+			zap.Error(err))
+	}
+	if actor != "" {
+		ctx = audit.WithActor(ctx, actor)
+	}
+	audit.Record(audit.WithTenant(ctx, tenantID), entry)
+}
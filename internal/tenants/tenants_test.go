@@ -0,0 +1,338 @@
+package tenants
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"QLP/internal/audit"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	svc, err := NewService(NewMemoryStore(), "standard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+func TestCreateAppliesPlanAndLimits(t *testing.T) {
+	svc := newTestService(t)
+	ctx := context.Background()
+	var applied []Tenant
+	svc.OnChange(func(t Tenant) { applied = append(applied, t) })
+
+	plan, minutes := "free", 90.0
+	tenant, key, err := svc.Create(ctx, "acme", Update{Plan: &plan, DailyMinutes: &minutes}, "ops@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(key, keyPrefix) || len(tenant.APIKeys) != 1 || tenant.APIKeys[0].Hash != "" {
+		t.Errorf("key = %q, keys = %+v", key, tenant.APIKeys)
+	}
+	want := Limits{DailyMinutes: 90, MaxConcurrent: 1, CPU: 1, MemoryMB: 2048}
+	if tenant.Plan != "free" || tenant.Limits != want || tenant.Status != StatusActive {
+		t.Errorf("tenant = %+v", tenant)
+	}
+	if len(applied) != 1 || applied[0].Limits != want {
+		t.Errorf("OnChange saw %+v", applied)
+	}
+
+	if _, _, err := svc.Create(ctx, "acme", Update{}, ""); !errors.Is(err, ErrExists) {
+		t.Errorf("duplicate create error = %v", err)
+	}
+	unknown := "platinum"
+	for _, u := range []struct {
+		id     string
+		update Update
+	}{
+		{"bad id!", Update{}},
+		{"", Update{}},
+		{"globex", Update{Plan: &unknown}},
+		{"globex", Update{DailyMinutes: func() *float64 { m := -1.0; return &m }()}},
+	} {
+		if _, _, err := svc.Create(ctx, u.id, u.update, ""); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("Create(%q, %+v) error = %v", u.id, u.update, err)
+		}
+	}
+
+	entries, _ := audit.Default().Query(ctx, audit.Filter{TenantID: "acme", Action: audit.ActionTenantCreate})
+	if len(entries) == 0 || entries[0].Actor != "ops@example.com" {
+		t.Errorf("audit entries = %+v", entries)
+	}
+}
+
+func TestRotateKeyAndSuspend(t *testing.T) {
+	svc := newTestService(t)
+	ctx := context.Background()
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	_, first, err := svc.Create(ctx, "acme", Update{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tenant, _, err := svc.Authenticate(ctx, first); err != nil || tenant.ID != "acme" {
+		t.Fatalf("Authenticate = %+v, %v", tenant, err)
+	}
+
+	now = now.Add(time.Second)
+	tenant, second, err := svc.RotateKey(ctx, "acme", "", time.Hour, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tenant.APIKeys) != 2 || tenant.APIKeys[0].ExpiresAt == nil {
+		t.Fatalf("keys after rotation with grace = %+v", tenant.APIKeys)
+	}
+	if _, _, err := svc.Authenticate(ctx, first); err != nil {
+		t.Errorf("old key refused within the grace period: %v", err)
+	}
+	now = now.Add(time.Hour)
+	if _, _, err := svc.Authenticate(ctx, first); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("old key after the grace period error = %v", err)
+	}
+
+	now = now.Add(time.Second)
+	tenant, third, err := svc.RotateKey(ctx, "acme", "", 0, "")
+	if err != nil || len(tenant.APIKeys) != 1 {
+		t.Fatalf("rotation without grace = %+v, %v", tenant, err)
+	}
+	if _, _, err := svc.Authenticate(ctx, second); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("replaced key error = %v", err)
+	}
+
+	if _, err := svc.Suspend(ctx, "acme", "unpaid invoice", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := svc.Authenticate(ctx, third); !errors.Is(err, ErrSuspended) {
+		t.Errorf("suspended tenant error = %v", err)
+	}
+	if _, err := svc.Resume(ctx, "acme", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := svc.Authenticate(ctx, third); err != nil {
+		t.Errorf("resumed tenant error = %v", err)
+	}
+	if _, err := svc.Suspend(ctx, "globex", "", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("suspending an unknown tenant error = %v", err)
+	}
+}
+
+func TestRoutesAndMiddleware(t *testing.T) {
+	svc := newTestService(t)
+	mux := http.NewServeMux()
+	for pattern, h := range Routes(svc, "admin-secret") {
+		mux.Handle(pattern, h)
+	}
+	var seen string
+	mux.Handle("/whoami", Middleware(svc, KeysOptional, "GET /whoami", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = audit.TenantFromContext(r.Context())
+	})))
+
+	do := func(method, path, auth, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if auth != "" {
+			req.Header.Set("Authorization", "Bearer "+auth)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodGet, "/admin/tenants", "wrong", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong admin token = %d", rec.Code)
+	}
+	rec := do(http.MethodPost, "/admin/tenants", "admin-secret", `{"id":"acme","plan":"enterprise","actor":"ops"}`)
+	if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"api_key":"qlp_`) {
+		t.Fatalf("create = %d %s", rec.Code, rec.Body.String())
+	}
+	tenant, _ := svc.Get(context.Background(), "acme")
+	_, key, _ := svc.RotateKey(context.Background(), "acme", "", 0, "")
+	if tenant.Plan != "enterprise" {
+		t.Errorf("plan = %s", tenant.Plan)
+	}
+
+	if rec := do(http.MethodPatch, "/admin/tenants/acme", "admin-secret", `{"cpu":8}`); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"cpu":8`) {
+		t.Errorf("update = %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodPatch, "/admin/tenants/globex", "admin-secret", `{"cpu":8}`); rec.Code != http.StatusNotFound {
+		t.Errorf("update of unknown tenant = %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/admin/tenants/acme/keys/rotate", "admin-secret", `{"grace":"soon"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid grace = %d", rec.Code)
+	}
+
+	if do(http.MethodGet, "/whoami", key, ""); seen != "acme" {
+		t.Errorf("middleware attached tenant %q", seen)
+	}
+	if rec := do(http.MethodGet, "/whoami", "qlp_unknown", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unknown key = %d", rec.Code)
+	}
+	do(http.MethodPost, "/admin/tenants/acme/suspend", "admin-secret", "")
+	if rec := do(http.MethodGet, "/whoami", key, ""); rec.Code != http.StatusForbidden {
+		t.Errorf("suspended tenant = %d", rec.Code)
+	}
+}
+
+func TestScopedKeys(t *testing.T) {
+	svc := newTestService(t)
+	ctx := context.Background()
+	if _, _, err := svc.Create(ctx, "acme", Update{}, ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, scopes := range [][]string{nil, {"owner"}} {
+		if _, _, err := svc.CreateKey(ctx, "acme", KeyRequest{Scopes: scopes}, ""); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("CreateKey with scopes %v error = %v", scopes, err)
+		}
+	}
+	ci, ciKey, err := svc.CreateKey(ctx, "acme", KeyRequest{Name: "ci", Scopes: []string{ScopeGenerate, ScopeRead}}, "ops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ci.Hash != "" || !ci.Allows(ScopeRead) || ci.Allows(ScopeDeploy) {
+		t.Errorf("ci key = %+v", ci)
+	}
+	if _, _, err := svc.RotateKey(ctx, "acme", "", 0, ""); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("rotating one of several keys without naming it error = %v", err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(audit.ActorFromContext(r.Context())))
+	})
+	tests := []struct {
+		method, pattern string
+		status          int
+	}{
+		{http.MethodGet, "GET /batches/{id}", http.StatusOK},
+		{http.MethodPost, "POST /batches", http.StatusOK},
+		{http.MethodPost, "POST /promotions/{id}/approve", http.StatusForbidden},
+		{http.MethodPost, "POST /deployments/{id}/rollback", http.StatusForbidden},
+		{http.MethodGet, "GET /prompts/{name}", http.StatusOK},
+		{http.MethodPost, "POST /prompts/{name}/promote", http.StatusForbidden},
+		{http.MethodPost, "POST /artifact-keys/rewrap", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/", nil)
+		req.Header.Set("X-API-Key", ciKey)
+		rec := httptest.NewRecorder()
+		Middleware(svc, KeysRequired, tt.pattern, handler).ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s with a generate and read key = %d, want %d", tt.pattern, rec.Code, tt.status)
+		}
+		if rec.Code == http.StatusOK && rec.Body.String() != "api-key:"+ci.ID {
+			t.Errorf("actor = %q", rec.Body.String())
+		}
+	}
+	rec := httptest.NewRecorder()
+	Middleware(svc, KeysRequired, "GET /batches/{id}", handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("request without a key = %d", rec.Code)
+	}
+
+	// Only keys issued with the admin scope hold it, legacy ones included
+	if (APIKey{}).Allows(ScopeAdmin) {
+		t.Error("a key issued before scoping holds the admin scope")
+	}
+	admin, adminKey, err := svc.CreateKey(ctx, "acme", KeyRequest{Name: "ops", Scopes: []string{ScopeAdmin}}, "ops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("X-API-Key", adminKey)
+	rec = httptest.NewRecorder()
+	Middleware(svc, KeysRequired, "POST /prompts/{name}/promote", handler).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("promoting a prompt with an admin key = %d", rec.Code)
+	}
+	if err := svc.RevokeKey(ctx, "acme", admin.ID, "ops"); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, _ := svc.ListKeys(ctx, "acme")
+	if len(keys) != 2 {
+		t.Fatalf("keys = %+v", keys)
+	}
+	if err := svc.RevokeKey(ctx, "acme", ci.ID, "ops"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := svc.Authenticate(ctx, ciKey); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("revoked key error = %v", err)
+	}
+	if err := svc.RevokeKey(ctx, "acme", ci.ID, "ops"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("revoking twice error = %v", err)
+	}
+}
+
+func TestMiddlewareRefusesOtherTenants(t *testing.T) {
+	svc := newTestService(t)
+	ctx := context.Background()
+	if _, _, err := svc.Create(ctx, "acme", Update{}, ""); err != nil {
+		t.Fatal(err)
+	}
+	_, globexKey, err := svc.Create(ctx, "globex", Update{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	for _, pattern := range []string{"POST /tenants/{tenant}/deletion", "GET /tenants/{tenant}/usage", "GET /schedules"} {
+		mux.Handle(pattern, Middleware(svc, KeysRequired, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(audit.TenantFromContext(r.Context())))
+		})))
+	}
+	tests := []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodPost, "/tenants/acme/deletion", http.StatusForbidden},
+		{http.MethodGet, "/tenants/acme/usage", http.StatusForbidden},
+		{http.MethodGet, "/schedules?tenant=acme", http.StatusForbidden},
+		{http.MethodGet, "/tenants/globex/usage", http.StatusOK},
+		{http.MethodGet, "/schedules?tenant=globex", http.StatusOK},
+		{http.MethodGet, "/schedules", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("X-API-Key", globexKey)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s %s with globex's key = %d, want %d", tt.method, tt.path, rec.Code, tt.status)
+		}
+		if rec.Code == http.StatusOK && rec.Body.String() != "globex" {
+			t.Errorf("%s %s tenant = %q", tt.method, tt.path, rec.Body.String())
+		}
+	}
+}
+
+func TestMiddlewareRequiresKeysOnceTenantsExist(t *testing.T) {
+	svc := newTestService(t)
+	handler := Middleware(svc, KeysOnceTenantsExist, "GET /batches", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	get := func() int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/batches", nil))
+		return rec.Code
+	}
+
+	if code := get(); code != http.StatusOK {
+		t.Errorf("request without a key before any tenant exists = %d", code)
+	}
+	if _, _, err := svc.Create(context.Background(), "acme", Update{}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if code := get(); code != http.StatusUnauthorized {
+		t.Errorf("request without a key once a tenant exists = %d", code)
+	}
+
+	for value, want := range map[string]KeyRequirement{"": KeysOnceTenantsExist, "true": KeysRequired, "false": KeysOptional} {
+		t.Setenv("QLP_REQUIRE_API_KEY", value)
+		if got := KeyRequirementFromEnv(); got != want {
+			t.Errorf("QLP_REQUIRE_API_KEY=%q requirement = %d, want %d", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+// Package testgen writes unit tests for generated projects. Each source file
+// gets a test file in the idiom of its language (table-driven Go tests,
+// pytest, jest); the suites run in the sandbox and failing or thin tests are
+// rewritten with the test output until the pass rate and coverage thresholds
+// are met or the iterations run out.
+package testgen
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"QLP/internal/config"
+	"QLP/internal/llm"
+	"QLP/internal/llm/jsonutil"
+	"QLP/internal/logger"
+	"QLP/internal/sandbox"
+
+	"go.uber.org/zap"
+)
+
+// Runner runs the test suites of a file set
+type Runner interface {
+	Run(ctx context.Context, files map[string]string) []sandbox.TestRun
+}
+
+// Config holds the thresholds of the generate-run-fix loop
+type Config struct {
+	MinPassRate   float64 // percent of tests passing
+	MinCoverage   float64 // percent of statements or lines covered
+	MaxIterations int     // runs including the first one
+	MaxFiles      int     // source files given tests
+}
+
+// DefaultConfig returns the default thresholds
+func DefaultConfig() Config {
+	return Config{MinPassRate: 90, MinCoverage: 60, MaxIterations: 3, MaxFiles: 20}
+}
+
+// ConfigFromEnv reads the thresholds from QLP_TESTGEN_MIN_PASS_RATE,
+// QLP_TESTGEN_MIN_COVERAGE, QLP_TESTGEN_MAX_ITERATIONS and
+// QLP_TESTGEN_MAX_FILES, keeping the defaults for invalid values
+func ConfigFromEnv() Config {
+	cfg := DefaultConfig()
+	if v, err := strconv.ParseFloat(config.GetEnvOrDefault("QLP_TESTGEN_MIN_PASS_RATE", ""), 64); err == nil {
+		cfg.MinPassRate = v
+	}
+	if v, err := strconv.ParseFloat(config.GetEnvOrDefault("QLP_TESTGEN_MIN_COVERAGE", ""), 64); err == nil {
+		cfg.MinCoverage = v
+	}
+	if v, err := strconv.Atoi(config.GetEnvOrDefault("QLP_TESTGEN_MAX_ITERATIONS", "")); err == nil && v > 0 {
+		cfg.MaxIterations = v
+	}
+	if v, err := strconv.Atoi(config.GetEnvOrDefault("QLP_TESTGEN_MAX_FILES", "")); err == nil && v > 0 {
+		cfg.MaxFiles = v
+	}
+	return cfg
+}
+
+// Result is the generated tests and how they fared in the last run
+type Result struct {
+	Files      map[string]string `json:"files"`
+	Runs       []sandbox.TestRun `json:"runs"`
+	Iterations int               `json:"iterations"`
+	PassRate   float64           `json:"pass_rate"`
+	Coverage   float64           `json:"coverage"` // -1 when no suite reported coverage
+	// Verified is false when the suites could not run, so the tests were not
+	// checked at all
+	Verified bool `json:"verified"`
+	// MetThresholds is true when every suite ran and reached the thresholds
+	MetThresholds bool `json:"met_thresholds"`
+}
+
+// Summary describes the result for review notes
+func (r *Result) Summary() string {
+	if !r.Verified {
+		return fmt.Sprintf("%d test files generated; the tests could not be run in the sandbox", len(r.Files))
+	}
+	coverage := "not reported"
+	if r.Coverage >= 0 {
+		coverage = fmt.Sprintf("%.1f%%", r.Coverage)
+	}
+	return fmt.Sprintf("%d test files generated; %.1f%% of tests pass, coverage %s after %d run(s)",
+		len(r.Files), r.PassRate, coverage, r.Iterations)
+}
+
+// Agent writes unit tests for generated projects
+type Agent struct {
+	llmClient llm.Client
+	runner    Runner
+	config    Config
+}
+
+// NewAgent creates a test-writer agent. A nil runner skips the sandbox runs.
+func NewAgent(llmClient llm.Client, runner Runner, cfg Config) *Agent {
+	return &Agent{llmClient: llmClient, runner: runner, config: cfg}
+}
+
+// Generate writes tests for the source files of a project, runs them and
+// rewrites the tests of failing suites until the thresholds are met
+func (a *Agent) Generate(ctx context.Context, files map[string]string) (*Result, error) {
+	sources := SourceFiles(files)
+	if len(sources) > a.config.MaxFiles {
+		sources = sources[:a.config.MaxFiles]
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no source files without tests")
+	}
+
+	result := &Result{Files: make(map[string]string), Coverage: -1}
+	testFor := make(map[string]string) // test path -> source path
+	for _, source := range sources {
+		testPath := TestPath(source)
+		content, err := a.writeTest(ctx, files, source, "", "")
+		if err != nil {
+			logger.WithComponent("testgen").Warn("Failed to write tests",
+				zap.String("file", source),
+				zap.Error(err))
+			continue
+		}
+		result.Files[testPath] = content
+		testFor[testPath] = source
+	}
+	if len(result.Files) == 0 {
+		return nil, fmt.Errorf("no tests could be written for %d source files", len(sources))
+	}
+	if a.runner == nil {
+		return result, nil
+	}
+
+	for result.Iterations < a.config.MaxIterations {
+		result.Iterations++
+		result.Runs = a.runner.Run(ctx, merge(files, result.Files))
+		a.score(result)
+		if result.MetThresholds || !result.Verified || result.Iterations == a.config.MaxIterations {
+			break
+		}
+
+		for _, run := range result.Runs {
+			if a.passes(run) {
+				continue
+			}
+			for _, testPath := range a.toRevise(run, result.Files) {
+				content, err := a.writeTest(ctx, files, testFor[testPath], result.Files[testPath], run.Output)
+				if err != nil {
+					logger.WithComponent("testgen").Warn("Failed to revise tests",
+						zap.String("file", testPath),
+						zap.Error(err))
+					continue
+				}
+				result.Files[testPath] = content
+			}
+		}
+	}
+
+	logger.WithComponent("testgen").Info("Tests generated",
+		zap.Int("files", len(result.Files)),
+		zap.Int("iterations", result.Iterations),
+		zap.Float64("pass_rate", result.PassRate),
+		zap.Float64("coverage", result.Coverage),
+		zap.Bool("met_thresholds", result.MetThresholds))
+	return result, nil
+}
+
+func (a *Agent) passes(run sandbox.TestRun) bool {
+	return run.Error == "" && run.PassRate() >= a.config.MinPassRate &&
+		(run.Coverage < 0 || run.Coverage >= a.config.MinCoverage)
+}
+
+// score totals the runs. Suites without results, such as builds that failed,
+// count against the thresholds; when no suite produced any output the
+// sandbox is unavailable and the tests are unverified.
+func (a *Agent) score(result *Result) {
+	var passed, total, ran int
+	var coverage float64
+	var covered int
+	result.MetThresholds = true
+	for _, run := range result.Runs {
+		if !a.passes(run) {
+			result.MetThresholds = false
+		}
+		if run.Output != "" {
+			ran++
+		}
+		passed += run.Passed
+		total += run.Passed + run.Failed
+		if run.Coverage >= 0 {
+			coverage += run.Coverage
+			covered++
+		}
+	}
+	result.Verified = ran > 0
+	if !result.Verified {
+		result.MetThresholds = false
+	}
+	if total > 0 {
+		result.PassRate = float64(passed) * 100 / float64(total)
+	}
+	result.Coverage = -1
+	if covered > 0 {
+		result.Coverage = coverage / float64(covered)
+	}
+}
+
+// toRevise picks the generated tests of a failing suite named in its output,
+// or all of the suite's tests when none is named, as with low coverage or
+// build failures
+func (a *Agent) toRevise(run sandbox.TestRun, tests map[string]string) []string {
+	var inSuite, named []string
+	for _, testPath := range sortedKeys(tests) {
+		if run.Dir != "." && !strings.HasPrefix(testPath, run.Dir+"/") {
+			continue
+		}
+		inSuite = append(inSuite, testPath)
+		if strings.Contains(run.Output, path.Base(testPath)) {
+			named = append(named, testPath)
+		}
+	}
+	if len(named) > 0 {
+		return named
+	}
+	return inSuite
+}
+
+var testSchema = map[string]interface{}{
+	"type":     "object",
+	"required": []string{"content"},
+	"properties": map[string]interface{}{
+		"content": map[string]interface{}{"type": "string"},
+	},
+}
+
+// writeTest asks the LLM for the test file of a source file, or for a fixed
+// version of previous given the output of the failing run
+func (a *Agent) writeTest(ctx context.Context, files map[string]string, source, previous, output string) (string, error) {
+	var out struct {
+		Content string `json:"content"`
+	}
+	prompt := testPrompt(files, source, previous, output)
+	if err := jsonutil.CompleteAndDecode(ctx, a.llmClient, prompt, testSchema, &out, 2); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(out.Content) == "" {
+		return "", fmt.Errorf("empty test file")
+	}
+	return out.Content, nil
+}
+
+func testPrompt(files map[string]string, source, previous, output string) string {
+	lang := languageOf(source)
+	var b strings.Builder
+	fmt.Fprintf(&b, "Write unit tests for %s in %s.\n\n", source, TestPath(source))
+	fmt.Fprintf(&b, "Use %s. Test the exported behavior, including error paths and edge cases; "+
+		"avoid network access, real databases and sleeps by using fakes or mocks.\n", lang.style)
+	if manifest := manifestFor(files, source); manifest != "" {
+		fmt.Fprintf(&b, "\n%s:\n%s\n", manifest, truncate(files[manifest], 2000))
+	}
+	fmt.Fprintf(&b, "\n%s:\n%s\n", source, truncate(files[source], 8000))
+	if previous != "" {
+		fmt.Fprintf(&b, "\nThe previous tests fail or cover too little:\n%s\n", truncate(previous, 6000))
+		fmt.Fprintf(&b, "\nTest output:\n%s\n", truncate(output, 4000))
+		b.WriteString("\nFix the tests so they pass against the code as written and cover more of it. Do not change the code under test.\n")
+	}
+	b.WriteString(`
+Return JSON with "content": the complete test file.`)
+	return b.String()
+}
+
+type language struct {
+	ext   string
+	style string
+}
+
+var languages = []language{
+	{".go", "table-driven tests with the standard testing package in the same package"},
+	{".py", "pytest with plain assert statements"},
+	{".js", "jest with describe/it blocks"},
+	{".ts", "jest with describe/it blocks and ts-jest"},
+}
+
+func languageOf(file string) language {
+	for _, l := range languages {
+		if path.Ext(file) == l.ext {
+			return l
+		}
+	}
+	return language{}
+}
+
+// SourceFiles returns the files worth testing: Go, Python, JavaScript and
+// TypeScript sources that are not tests themselves and have no tests yet
+func SourceFiles(files map[string]string) []string {
+	var sources []string
+	for _, file := range sortedKeys(files) {
+		if languageOf(file).ext == "" || isTest(file) || strings.Contains(file, "node_modules/") {
+			continue
+		}
+		base := path.Base(file)
+		if base == "__init__.py" || base == "setup.py" || strings.HasSuffix(base, ".config.js") || strings.HasSuffix(base, ".d.ts") {
+			continue
+		}
+		if _, ok := files[TestPath(file)]; ok {
+			continue
+		}
+		// A Go main function alone only wires the program together
+		if path.Ext(file) == ".go" && strings.Contains(files[file], "package main") && strings.Count(files[file], "\nfunc ") <= 1 {
+			continue
+		}
+		sources = append(sources, file)
+	}
+	return sources
+}
+
+func isTest(file string) bool {
+	base := path.Base(file)
+	return strings.HasSuffix(base, "_test.go") ||
+		strings.HasPrefix(base, "test_") || strings.HasSuffix(base, "_test.py") ||
+		strings.Contains(base, ".test.") || strings.Contains(base, ".spec.")
+}
+
+// TestPath is where the tests of a source file go: foo_test.go next to
+// foo.go, test_foo.py next to foo.py and foo.test.js next to foo.js
+func TestPath(source string) string {
+	dir, base := path.Split(source)
+	ext := path.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+	switch ext {
+	case ".go":
+		return dir + name + "_test.go"
+	case ".py":
+		return dir + "test_" + name + ".py"
+	default:
+		return dir + name + ".test" + ext
+	}
+}
+
+// manifestFor finds the nearest go.mod, package.json or requirements.txt
+// above a source file
+func manifestFor(files map[string]string, source string) string {
+	for dir := path.Dir(source); ; dir = path.Dir(dir) {
+		for _, name := range []string{"go.mod", "package.json", "requirements.txt"} {
+			if p := path.Join(dir, name); files[p] != "" {
+				return p
+			}
+		}
+		if dir == "." || dir == "/" {
+			return ""
+		}
+	}
+}
+
+func merge(files, tests map[string]string) map[string]string {
+	merged := make(map[string]string, len(files)+len(tests))
+	for p, c := range files {
+		merged[p] = c
+	}
+	for p, c := range tests {
+		merged[p] = c
+	}
+	return merged
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "\n..."
+}
@@ -0,0 +1,125 @@
+package testgen
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"QLP/internal/sandbox"
+)
+
+func TestTestPath(t *testing.T) {
+	for source, want := range map[string]string{
+		"internal/store/store.go": "internal/store/store_test.go",
+		"app/models.py":           "app/test_models.py",
+		"src/cart.js":             "src/cart.test.js",
+		"src/cart.ts":             "src/cart.test.ts",
+	} {
+		if got := TestPath(source); got != want {
+			t.Errorf("TestPath(%s) = %s, want %s", source, got, want)
+		}
+	}
+}
+
+func TestSourceFiles(t *testing.T) {
+	files := map[string]string{
+		"main.go":         "package main\n\nfunc main() {}\n",
+		"store.go":        "package main\n\nfunc main() {}\n\nfunc Save() {}\n",
+		"cart.go":         "package shop\n",
+		"cart_test.go":    "package shop\n",
+		"app/__init__.py": "",
+		"app/models.py":   "class User: pass\n",
+		"jest.config.js":  "module.exports = {}\n",
+		"README.md":       "# Shop\n",
+	}
+	want := []string{"app/models.py", "store.go"}
+	if got := SourceFiles(files); !reflect.DeepEqual(got, want) {
+		t.Errorf("SourceFiles() = %v, want %v", got, want)
+	}
+}
+
+type fakeClient struct {
+	prompts []string
+}
+
+func (c *fakeClient) Complete(ctx context.Context, prompt string) (string, error) {
+	c.prompts = append(c.prompts, prompt)
+	content := "package shop\n// attempt " + string(rune('0'+len(c.prompts))) + "\n"
+	data, _ := json.Marshal(map[string]string{"content": content})
+	return string(data), nil
+}
+
+func (c *fakeClient) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	return nil, nil
+}
+
+type fakeRunner struct {
+	runs [][]sandbox.TestRun
+	seen []map[string]string
+}
+
+func (r *fakeRunner) Run(ctx context.Context, files map[string]string) []sandbox.TestRun {
+	r.seen = append(r.seen, files)
+	run := r.runs[0]
+	r.runs = r.runs[1:]
+	return run
+}
+
+var project = map[string]string{
+	"go.mod":  "module shop\n",
+	"cart.go": "package shop\n\nfunc Total() int { return 0 }\n",
+}
+
+func TestGenerateRevisesFailingTests(t *testing.T) {
+	client := &fakeClient{}
+	runner := &fakeRunner{runs: [][]sandbox.TestRun{
+		{{Dir: ".", Passed: 1, Failed: 1, Coverage: 80, Output: "--- FAIL: TestTotal\n    cart_test.go:9: got 1"}},
+		{{Dir: ".", Passed: 2, Coverage: 75, Output: "ok shop"}},
+	}}
+
+	result, err := NewAgent(client, runner, DefaultConfig()).Generate(context.Background(), project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Iterations != 2 || !result.MetThresholds || !result.Verified || result.PassRate != 100 || result.Coverage != 75 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if len(client.prompts) != 2 || !strings.Contains(client.prompts[1], "cart_test.go:9: got 1") {
+		t.Fatalf("expected a revision prompt with the failure, got %q", client.prompts)
+	}
+	if got := result.Files["cart_test.go"]; !strings.Contains(got, "attempt 2") {
+		t.Errorf("expected the revised tests, got %q", got)
+	}
+	if runner.seen[0]["cart.go"] == "" || runner.seen[0]["cart_test.go"] == "" {
+		t.Errorf("expected the sources and tests to run together, got %v", runner.seen[0])
+	}
+}
+
+func TestGenerateStopsAtMaxIterations(t *testing.T) {
+	low := []sandbox.TestRun{{Dir: ".", Passed: 3, Coverage: 20, Output: "ok shop coverage: 20.0%"}}
+	runner := &fakeRunner{runs: [][]sandbox.TestRun{low, low, low}}
+	cfg := DefaultConfig()
+	cfg.MaxIterations = 2
+
+	result, err := NewAgent(&fakeClient{}, runner, cfg).Generate(context.Background(), project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Iterations != 2 || result.MetThresholds || !strings.Contains(result.Summary(), "coverage 20.0%") {
+		t.Errorf("unexpected result %+v: %s", result, result.Summary())
+	}
+}
+
+func TestGenerateWithoutSandbox(t *testing.T) {
+	runner := &fakeRunner{runs: [][]sandbox.TestRun{{{Dir: ".", Coverage: -1, Error: "docker unavailable"}}}}
+
+	result, err := NewAgent(&fakeClient{}, runner, DefaultConfig()).Generate(context.Background(), project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verified || result.MetThresholds || result.Iterations != 1 {
+		t.Errorf("expected unverified tests after one attempt, got %+v", result)
+	}
+}
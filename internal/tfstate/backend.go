@@ -0,0 +1,119 @@
+// Package tfstate gives generated Terraform a remote state backend: an
+// azurerm backend block per root module, guidance on managing the state,
+// and a check that terraform init succeeds against the backend.
+package tfstate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"QLP/internal/config"
+)
+
+// Backend is an Azure Storage state backend
+type Backend struct {
+	ResourceGroup  string `json:"resource_group"`
+	StorageAccount string `json:"storage_account"`
+	Container      string `json:"container"`
+	KeyPrefix      string `json:"key_prefix,omitempty"` // prepended to each root module's state key
+	Location       string `json:"location,omitempty"`
+}
+
+var (
+	nonAlnum     = regexp.MustCompile(`[^a-z0-9]+`)
+	nonContainer = regexp.MustCompile(`[^a-z0-9-]+`)
+)
+
+// DefaultBackend shares one storage account per subscription, named from
+// a hash of the subscription ID since account names are global, with a
+// container per project
+func DefaultBackend(subscriptionID, project string) Backend {
+	sum := sha256.Sum256([]byte(subscriptionID))
+	return Backend{
+		ResourceGroup:  "qlp-tfstate",
+		StorageAccount: "qlptf" + hex.EncodeToString(sum[:])[:19],
+		Container:      ContainerName(project),
+	}
+}
+
+// BackendFromEnv is the default backend for project, with the names in
+// QLP_TFSTATE_RESOURCE_GROUP, QLP_TFSTATE_STORAGE_ACCOUNT and
+// QLP_TFSTATE_CONTAINER taking precedence to reference an existing backend
+func BackendFromEnv(project string) Backend {
+	b := DefaultBackend(config.GetEnvOrDefault("AZURE_SUBSCRIPTION_ID", ""), project)
+	b.ResourceGroup = config.GetEnvOrDefault("QLP_TFSTATE_RESOURCE_GROUP", b.ResourceGroup)
+	b.StorageAccount = config.GetEnvOrDefault("QLP_TFSTATE_STORAGE_ACCOUNT", b.StorageAccount)
+	b.Container = config.GetEnvOrDefault("QLP_TFSTATE_CONTAINER", b.Container)
+	b.Location = config.GetEnvOrDefault("AZURE_LOCATION", "westeurope")
+	return b
+}
+
+// ContainerName makes a valid blob container name: 3-63 lowercase
+// letters, digits and single hyphens
+func ContainerName(project string) string {
+	name := nonContainer.ReplaceAllString(strings.ToLower(project), "-")
+	for strings.Contains(name, "--") {
+		name = strings.ReplaceAll(name, "--", "-")
+	}
+	name = strings.Trim(name, "-")
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-")
+	}
+	if len(name) < 3 {
+		name = "tfstate"
+	}
+	return name
+}
+
+// Validate checks the names Azure will accept
+func (b Backend) Validate() error {
+	if b.ResourceGroup == "" {
+		return fmt.Errorf("state backend has no resource group")
+	}
+	if n := len(b.StorageAccount); n < 3 || n > 24 || nonAlnum.MatchString(b.StorageAccount) {
+		return fmt.Errorf("invalid storage account name %q: 3-24 lowercase letters and digits", b.StorageAccount)
+	}
+	if b.Container != ContainerName(b.Container) {
+		return fmt.Errorf("invalid container name %q", b.Container)
+	}
+	return nil
+}
+
+// StateKey is the blob holding a root module's state, e.g. infra.tfstate
+// for the module in infra/ and terraform.tfstate for the project root
+func (b Backend) StateKey(module string) string {
+	key := "terraform"
+	if module != "" && module != "." {
+		key = strings.ReplaceAll(module, "/", "-")
+	}
+	return path.Join(b.KeyPrefix, key+".tfstate")
+}
+
+// Block renders the backend configuration of a root module
+func (b Backend) Block(module string) string {
+	var sb strings.Builder
+	sb.WriteString("# Remote state in Azure Storage; blob leases lock the state during runs.\n")
+	sb.WriteString("# See docs/terraform-state.md to create the backend or point at another one.\n")
+	sb.WriteString("terraform {\n  backend \"azurerm\" {\n")
+	fmt.Fprintf(&sb, "    resource_group_name  = %q\n", b.ResourceGroup)
+	fmt.Fprintf(&sb, "    storage_account_name = %q\n", b.StorageAccount)
+	fmt.Fprintf(&sb, "    container_name       = %q\n", b.Container)
+	fmt.Fprintf(&sb, "    key                  = %q\n", b.StateKey(module))
+	sb.WriteString("  }\n}\n")
+	return sb.String()
+}
+
+// ConfigArgs are the terraform init flags that point a root module at b,
+// overriding its backend block
+func (b Backend) ConfigArgs(module string) []string {
+	return []string{
+		"-backend-config=resource_group_name=" + b.ResourceGroup,
+		"-backend-config=storage_account_name=" + b.StorageAccount,
+		"-backend-config=container_name=" + b.Container,
+		"-backend-config=key=" + b.StateKey(module),
+	}
+}
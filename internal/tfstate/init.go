@@ -0,0 +1,134 @@
+package tfstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNoTerraform is returned when the terraform binary is not installed
+var ErrNoTerraform = errors.New("terraform is not installed")
+
+// Runner runs terraform with args in dir, with env added to the environment
+type Runner func(ctx context.Context, dir string, env []string, args ...string) ([]byte, error)
+
+// CommandRunner runs the terraform binary on the PATH
+func CommandRunner(ctx context.Context, dir string, env []string, args ...string) ([]byte, error) {
+	bin, err := exec.LookPath("terraform")
+	if err != nil {
+		return nil, ErrNoTerraform
+	}
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	return cmd.CombinedOutput()
+}
+
+// InitResult is the outcome of terraform init in one root module
+type InitResult struct {
+	Module   string        `json:"module"`
+	StateKey string        `json:"state_key"`
+	Passed   bool          `json:"passed"`
+	Output   string        `json:"output,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// ValidateInit writes the project to a temporary directory and runs
+// terraform init in each root module against backend b, which overrides
+// any backend block in the files. env carries the backend credentials,
+// e.g. ARM_ACCESS_KEY. An error means init could not run at all.
+func ValidateInit(ctx context.Context, files map[string]string, b Backend, env []string, run Runner) ([]InitResult, error) {
+	modules := RootModules(files)
+	if len(modules) == 0 {
+		return nil, nil
+	}
+	dir, err := writeProject(files, b, modules)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	env = append(env[:len(env):len(env)], "TF_IN_AUTOMATION=1")
+	var results []InitResult
+	for _, module := range modules {
+		start := time.Now()
+		out, err := initModule(ctx, dir, module, b, env, run)
+		if errors.Is(err, ErrNoTerraform) {
+			return nil, err
+		}
+		result := InitResult{
+			Module:   module,
+			StateKey: b.StateKey(module),
+			Passed:   err == nil,
+			Output:   strings.TrimSpace(string(out)),
+			Duration: time.Since(start),
+		}
+		if err != nil && result.Output == "" {
+			result.Output = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// writeProject writes the project to a temporary directory, adding the
+// backend block to the root modules that have none; the caller removes it
+func writeProject(files map[string]string, b Backend, modules []string) (string, error) {
+	dir, err := os.MkdirTemp("", "qlp-terraform-*")
+	if err != nil {
+		return "", err
+	}
+	for p, content := range files {
+		target := filepath.Join(dir, filepath.FromSlash(p))
+		if !strings.HasPrefix(target, dir+string(filepath.Separator)) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+	for _, module := range modules {
+		if HasBackend(files, module) {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(moduleDir(dir, module), "qlp_backend.tf"), []byte(b.Block(module)), 0644); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// initModule runs terraform init in a root module written by writeProject
+func initModule(ctx context.Context, dir, module string, b Backend, env []string, run Runner) ([]byte, error) {
+	args := append([]string{"init", "-input=false", "-no-color", "-reconfigure"}, b.ConfigArgs(module)...)
+	return run(ctx, moduleDir(dir, module), env, args...)
+}
+
+func moduleDir(dir, module string) string {
+	return filepath.Join(dir, filepath.FromSlash(module))
+}
+
+// Failed summarizes the modules whose init failed, or returns nil
+func Failed(results []InitResult) error {
+	var failed []string
+	for _, r := range results {
+		if !r.Passed {
+			failed = append(failed, r.Module)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("terraform init failed against the state backend in %s", strings.Join(failed, ", "))
+}
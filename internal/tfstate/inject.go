@@ -0,0 +1,168 @@
+package tfstate
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Files added to projects
+const (
+	BackendFile   = "backend.tf"
+	GuidePath     = "docs/terraform-state.md"
+	BootstrapPath = "scripts/bootstrap-tfstate.sh"
+)
+
+var (
+	backendBlock = regexp.MustCompile(`(?m)^\s*(backend\s+"[^"]+"|cloud)\s*\{`)
+	moduleSource = regexp.MustCompile(`(?m)^\s*source\s*=\s*"(\.{1,2}/[^"]*)"`)
+)
+
+// RootModules returns the directories Terraform is run in: those with .tf
+// files that are neither referenced as a local module source nor under a
+// modules/ directory. The project root is ".".
+func RootModules(files map[string]string) []string {
+	dirs := make(map[string]bool)
+	for p := range files {
+		if strings.HasSuffix(p, ".tf") {
+			dirs[path.Dir(p)] = true
+		}
+	}
+	for p, content := range files {
+		if !strings.HasSuffix(p, ".tf") {
+			continue
+		}
+		for _, m := range moduleSource.FindAllStringSubmatch(content, -1) {
+			delete(dirs, path.Clean(path.Join(path.Dir(p), m[1])))
+		}
+	}
+
+	var modules []string
+	for dir := range dirs {
+		if dir == "modules" || strings.HasPrefix(dir, "modules/") || strings.Contains(dir, "/modules/") {
+			continue
+		}
+		modules = append(modules, dir)
+	}
+	sort.Strings(modules)
+	return modules
+}
+
+// HasBackend reports whether a module already configures its state
+func HasBackend(files map[string]string, module string) bool {
+	for p, content := range files {
+		if strings.HasSuffix(p, ".tf") && path.Dir(p) == module && backendBlock.MatchString(content) {
+			return true
+		}
+	}
+	return false
+}
+
+// Inject returns the files that give every root module without a backend
+// the remote state backend b, plus the state guidance and bootstrap script.
+// Nothing is returned for projects without Terraform.
+func Inject(files map[string]string, b Backend) map[string]string {
+	modules := RootModules(files)
+	if len(modules) == 0 {
+		return nil
+	}
+	added := make(map[string]string)
+	for _, module := range modules {
+		if HasBackend(files, module) {
+			continue
+		}
+		file := path.Join(module, BackendFile)
+		if _, exists := files[file]; exists {
+			file = path.Join(module, "qlp_backend.tf")
+		}
+		added[file] = b.Block(module)
+	}
+	added[GuidePath] = Guidance(b, modules)
+	added[BootstrapPath] = Bootstrap(b)
+	return added
+}
+
+// Guidance explains how the project's Terraform state is managed
+func Guidance(b Backend, modules []string) string {
+	var sb strings.Builder
+	sb.WriteString("# Terraform State\n\n")
+	sb.WriteString("Terraform records what it manages in a state file. Local state is lost with the\n")
+	sb.WriteString("machine it lives on and cannot be shared, so this project keeps its state in\n")
+	sb.WriteString("Azure Storage, where blob leases lock it while a run is in progress.\n\n")
+
+	sb.WriteString("## Backend\n\n")
+	sb.WriteString("| Setting | Value |\n|---|---|\n")
+	fmt.Fprintf(&sb, "| Resource group | `%s` |\n", b.ResourceGroup)
+	fmt.Fprintf(&sb, "| Storage account | `%s` |\n", b.StorageAccount)
+	fmt.Fprintf(&sb, "| Container | `%s` |\n\n", b.Container)
+	sb.WriteString("| Root module | State key |\n|---|---|\n")
+	for _, module := range modules {
+		fmt.Fprintf(&sb, "| `%s` | `%s` |\n", module, b.StateKey(module))
+	}
+
+	sb.WriteString("\n## Creating the backend\n\n")
+	fmt.Fprintf(&sb, "Run `%s` once with the Azure CLI logged in. It creates the resource group,\n", BootstrapPath)
+	sb.WriteString("a storage account with blob versioning and soft delete for recovering earlier\n")
+	sb.WriteString("state, and the container, and does nothing for the parts that already exist.\n\n")
+
+	sb.WriteString("## Initializing\n\n")
+	sb.WriteString("```bash\nterraform init\n```\n\n")
+	sb.WriteString("Terraform authenticates to the storage account with your Azure CLI login. In CI,\n")
+	sb.WriteString("set `ARM_USE_AZUREAD=true` with a service principal or workload identity that\n")
+	sb.WriteString("has the Storage Blob Data Contributor role on the container, or `ARM_ACCESS_KEY`.\n\n")
+
+	sb.WriteString("## Using another backend\n\n")
+	sb.WriteString("Override the settings at init without editing the backend block, e.g. per\n")
+	sb.WriteString("environment:\n\n")
+	sb.WriteString("```bash\nterraform init -reconfigure \\\n")
+	sb.WriteString("  -backend-config=storage_account_name=<account> \\\n")
+	sb.WriteString("  -backend-config=container_name=<container> \\\n")
+	sb.WriteString("  -backend-config=key=<environment>.tfstate\n```\n\n")
+	sb.WriteString("To move existing local state into the backend, run `terraform init -migrate-state`.\n\n")
+
+	sb.WriteString("## Practices\n\n")
+	sb.WriteString("- Keep one state per root module and environment; never share a key.\n")
+	sb.WriteString("- Never commit `terraform.tfstate` or `.terraform/`; state can contain secrets.\n")
+	sb.WriteString("- If a run dies holding the lock, release it with `terraform force-unlock <id>` once\n")
+	sb.WriteString("  nobody else is running Terraform.\n")
+	sb.WriteString("- Restrict access to the storage account: whoever can read the state can read\n")
+	sb.WriteString("  every secret Terraform manages.\n")
+	return sb.String()
+}
+
+// Bootstrap renders an idempotent Azure CLI script creating the backend
+func Bootstrap(b Backend) string {
+	location := b.Location
+	if location == "" {
+		location = "westeurope"
+	}
+	return fmt.Sprintf(`#!/usr/bin/env bash
+# Creates the Terraform state backend referenced by backend.tf. Safe to re-run.
+set -euo pipefail
+
+RESOURCE_GROUP="${TFSTATE_RESOURCE_GROUP:-%s}"
+STORAGE_ACCOUNT="${TFSTATE_STORAGE_ACCOUNT:-%s}"
+CONTAINER="${TFSTATE_CONTAINER:-%s}"
+LOCATION="${TFSTATE_LOCATION:-%s}"
+
+az group create --name "$RESOURCE_GROUP" --location "$LOCATION" \
+  --tags purpose=terraform-state managed-by=quantumlayer --output none
+
+if ! az storage account show --name "$STORAGE_ACCOUNT" --resource-group "$RESOURCE_GROUP" --output none 2>/dev/null; then
+  az storage account create --name "$STORAGE_ACCOUNT" --resource-group "$RESOURCE_GROUP" \
+    --location "$LOCATION" --sku Standard_ZRS --kind StorageV2 --min-tls-version TLS1_2 \
+    --allow-blob-public-access false --https-only true --output none
+fi
+
+az storage account blob-service-properties update --account-name "$STORAGE_ACCOUNT" \
+  --resource-group "$RESOURCE_GROUP" --enable-versioning true \
+  --enable-delete-retention true --delete-retention-days 30 --output none
+
+az storage container create --name "$CONTAINER" --account-name "$STORAGE_ACCOUNT" \
+  --auth-mode login --output none
+
+echo "Terraform state backend ready: $STORAGE_ACCOUNT/$CONTAINER"
+`, b.ResourceGroup, b.StorageAccount, b.Container, location)
+}
@@ -0,0 +1,86 @@
+package tfstate
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+// PlanResult is the outcome of terraform plan, and of terraform apply when
+// the changes were applied, in one root module
+type PlanResult struct {
+	Module   string        `json:"module"`
+	StateKey string        `json:"state_key"`
+	Changes  bool          `json:"changes"`           // The deployed resources differ from the configuration
+	Applied  bool          `json:"applied,omitempty"` // The changes were applied
+	Output   string        `json:"output,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// exitChanges is the exit code of terraform plan -detailed-exitcode when
+// the plan has changes
+const exitChanges = 2
+
+// Plan runs terraform plan in each root module against the state in
+// backend b, refreshing it from the deployed resources, so a plan with
+// changes means the deployment drifted from the files. With apply set, the
+// modules with changes are applied to bring the deployment back in line.
+// A module that could not be planned has Error set; an error means
+// terraform could not run at all.
+func Plan(ctx context.Context, files map[string]string, b Backend, env []string, run Runner, apply bool) ([]PlanResult, error) {
+	modules := RootModules(files)
+	if len(modules) == 0 {
+		return nil, nil
+	}
+	dir, err := writeProject(files, b, modules)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	env = append(env[:len(env):len(env)], "TF_IN_AUTOMATION=1")
+	var results []PlanResult
+	for _, module := range modules {
+		start := time.Now()
+		result := PlanResult{Module: module, StateKey: b.StateKey(module)}
+		out, err := initModule(ctx, dir, module, b, env, run)
+		if err == nil {
+			out, err = run(ctx, moduleDir(dir, module), env,
+				"plan", "-input=false", "-no-color", "-lock=false", "-detailed-exitcode")
+			result.Changes = exitCode(err) == exitChanges
+			if result.Changes {
+				err = nil
+			}
+		}
+		if errors.Is(err, ErrNoTerraform) {
+			return nil, err
+		}
+		result.Output = strings.TrimSpace(string(out))
+		if err != nil {
+			result.Error = err.Error()
+		} else if result.Changes && apply {
+			out, err = run(ctx, moduleDir(dir, module), env,
+				"apply", "-input=false", "-no-color", "-auto-approve")
+			result.Applied = err == nil
+			result.Output += "\n\n" + strings.TrimSpace(string(out))
+			if err != nil {
+				result.Error = err.Error()
+			}
+		}
+		result.Duration = time.Since(start)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// exitCode returns the exit code of a failed command, or 0
+func exitCode(err error) int {
+	var exit interface{ ExitCode() int }
+	if errors.As(err, &exit) {
+		return exit.ExitCode()
+	}
+	return 0
+}
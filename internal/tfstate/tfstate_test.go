@@ -0,0 +1,187 @@
+package tfstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var project = map[string]string{
+	"main.tf":                  "module \"network\" {\n  source = \"./network\"\n}\n",
+	"network/main.tf":          "resource \"azurerm_virtual_network\" \"vnet\" {}\n",
+	"modules/db/main.tf":       "resource \"azurerm_postgresql_flexible_server\" \"db\" {}\n",
+	"envs/prod/main.tf":        "module \"db\" {\n  source = \"../../modules/db\"\n}\n",
+	"envs/prod/backend.tf":     "terraform {\n  backend \"azurerm\" {}\n}\n",
+	"envs/staging/main.tf":     "module \"db\" {\n  source = \"../../modules/db\"\n}\n",
+	"envs/staging/backend.tf":  "# not a backend\n",
+	"app/main.go":              "package main\n",
+	"envs/staging/vars.tfvars": "region = \"westeurope\"\n",
+}
+
+func TestRootModules(t *testing.T) {
+	got := strings.Join(RootModules(project), ",")
+	if got != ".,envs/prod,envs/staging" {
+		t.Errorf("root modules = %s", got)
+	}
+	if RootModules(map[string]string{"main.go": "package main\n"}) != nil {
+		t.Error("expected no root modules without Terraform")
+	}
+}
+
+func TestInject(t *testing.T) {
+	b := DefaultBackend("sub-1", "Orders API")
+	added := Inject(project, b)
+
+	root, ok := added["backend.tf"]
+	if !ok {
+		t.Fatalf("no backend for the project root: %v", added)
+	}
+	for _, want := range []string{`backend "azurerm"`, `storage_account_name = "` + b.StorageAccount + `"`, `container_name       = "orders-api"`, `key                  = "terraform.tfstate"`} {
+		if !strings.Contains(root, want) {
+			t.Errorf("root backend missing %s:\n%s", want, root)
+		}
+	}
+	if _, ok := added["envs/prod/backend.tf"]; ok {
+		t.Error("existing backend was replaced")
+	}
+	staging, ok := added["envs/staging/qlp_backend.tf"]
+	if !ok || !strings.Contains(staging, `key                  = "envs-staging.tfstate"`) {
+		t.Errorf("staging backend = %q; an existing backend.tf must not be overwritten", staging)
+	}
+	if _, ok := added["network/backend.tf"]; ok {
+		t.Error("local module got a backend")
+	}
+	if !strings.Contains(added[GuidePath], "`envs/staging` | `envs-staging.tfstate`") {
+		t.Errorf("guidance does not list the state keys:\n%s", added[GuidePath])
+	}
+	if !strings.Contains(added[BootstrapPath], `STORAGE_ACCOUNT="${TFSTATE_STORAGE_ACCOUNT:-`+b.StorageAccount+`}"`) {
+		t.Error("bootstrap script does not create the referenced account")
+	}
+	if Inject(map[string]string{"main.go": "package main\n"}, b) != nil {
+		t.Error("projects without Terraform should be left alone")
+	}
+}
+
+func TestBackendNames(t *testing.T) {
+	b := DefaultBackend("sub-1", "My  Service__v2!")
+	if err := b.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.StorageAccount) != 24 || b.Container != "my-service-v2" {
+		t.Errorf("backend = %+v", b)
+	}
+	if DefaultBackend("sub-2", "x").StorageAccount == b.StorageAccount {
+		t.Error("subscriptions should get their own storage accounts")
+	}
+	if ContainerName("x") != "tfstate" {
+		t.Errorf("short names = %s", ContainerName("x"))
+	}
+	b.StorageAccount = "Not-Valid"
+	if err := b.Validate(); err == nil {
+		t.Error("expected an invalid account name to fail")
+	}
+	b.KeyPrefix = "QL-CAP-1"
+	if key := b.StateKey("infra"); key != "QL-CAP-1/infra.tfstate" {
+		t.Errorf("state key = %s", key)
+	}
+}
+
+func TestValidateInit(t *testing.T) {
+	b := DefaultBackend("sub-1", "orders")
+	var dirs []string
+	run := func(ctx context.Context, dir string, env []string, args ...string) ([]byte, error) {
+		dirs = append(dirs, dir)
+		joined := strings.Join(args, " ")
+		if !strings.Contains(joined, "-backend-config=container_name=orders") || env[0] != "ARM_ACCESS_KEY=secret" {
+			t.Errorf("init not pointed at the backend: %s %v", joined, env)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "main.tf")); err != nil {
+			t.Errorf("module files not written: %v", err)
+		}
+		if strings.HasSuffix(dir, "staging") {
+			return []byte("Error: Failed to get existing workspaces"), errors.New("exit status 1")
+		}
+		return []byte("Terraform has been successfully initialized!"), nil
+	}
+
+	results, err := ValidateInit(context.Background(), project, b, []string{"ARM_ACCESS_KEY=secret"}, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || len(dirs) != 3 {
+		t.Fatalf("results = %+v", results)
+	}
+	if !results[0].Passed || results[2].Passed || results[2].Module != "envs/staging" {
+		t.Errorf("results = %+v", results)
+	}
+	if err := Failed(results); err == nil || !strings.Contains(err.Error(), "envs/staging") {
+		t.Errorf("Failed = %v", err)
+	}
+
+	noTerraform := func(ctx context.Context, dir string, env []string, args ...string) ([]byte, error) {
+		return nil, ErrNoTerraform
+	}
+	if _, err := ValidateInit(context.Background(), project, b, nil, noTerraform); !errors.Is(err, ErrNoTerraform) {
+		t.Errorf("expected ErrNoTerraform, got %v", err)
+	}
+}
+
+type exitError int
+
+func (e exitError) Error() string { return fmt.Sprintf("exit status %d", int(e)) }
+func (e exitError) ExitCode() int { return int(e) }
+
+func TestPlan(t *testing.T) {
+	b := DefaultBackend("sub-1", "orders")
+	var applied []string
+	run := func(ctx context.Context, dir string, env []string, args ...string) ([]byte, error) {
+		switch args[0] {
+		case "plan":
+			if strings.HasSuffix(dir, "staging") {
+				return []byte("Note: Objects have changed outside of Terraform"), exitError(2)
+			}
+			return []byte("No changes."), nil
+		case "apply":
+			applied = append(applied, dir)
+			return []byte("Apply complete!"), nil
+		}
+		return nil, nil
+	}
+
+	results, err := Plan(context.Background(), project, b, nil, run, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[0].Changes || !results[2].Changes || results[2].Error != "" {
+		t.Fatalf("results = %+v", results)
+	}
+	if len(applied) != 0 {
+		t.Error("applied without being asked to")
+	}
+
+	results, err = Plan(context.Background(), project, b, nil, run, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 1 || !results[2].Applied || results[0].Applied {
+		t.Errorf("applied = %v, results = %+v", applied, results)
+	}
+
+	failing := func(ctx context.Context, dir string, env []string, args ...string) ([]byte, error) {
+		if args[0] == "plan" {
+			return []byte("Error: Unauthorized"), exitError(1)
+		}
+		return nil, nil
+	}
+	results, err = Plan(context.Background(), project, b, nil, failing, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Changes || results[0].Error == "" {
+		t.Errorf("a failed plan should not count as changes: %+v", results[0])
+	}
+}
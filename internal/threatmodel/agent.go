@@ -0,0 +1,137 @@
+package threatmodel
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"QLP/internal/llm"
+	"QLP/internal/llm/jsonutil"
+	"QLP/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// Paths of the threat model in the project
+const (
+	MarkdownPath = "docs/threat-model.md"
+	JSONPath     = "docs/threat-model.json"
+)
+
+// Agent builds threat models for generated systems
+type Agent struct {
+	llmClient llm.Client
+}
+
+// NewAgent creates a threat modeling agent. A nil client uses the rules only.
+func NewAgent(llmClient llm.Client) *Agent {
+	return &Agent{llmClient: llmClient}
+}
+
+// Analyze builds the threat model of a project from its files
+func (a *Agent) Analyze(ctx context.Context, project string, files map[string]string) *Model {
+	an := newAnalysis(project, files)
+	m := &Model{Project: project, Architecture: an.arch, AttackSurface: an.surface}
+	for _, r := range rules {
+		for _, t := range r(an) {
+			t.Source = "rule"
+			m.Threats = append(m.Threats, t)
+		}
+	}
+
+	if a.llmClient != nil {
+		threats, err := a.suggest(ctx, m)
+		if err != nil {
+			logger.WithComponent("threatmodel").Warn("LLM threat analysis failed, using rule findings only",
+				zap.String("project", project),
+				zap.Error(err))
+		}
+		m.Threats = append(m.Threats, threats...)
+	}
+
+	m.sortThreats()
+	return m
+}
+
+var threatSchema = map[string]interface{}{
+	"type":     "object",
+	"required": []string{"threats"},
+	"properties": map[string]interface{}{
+		"threats": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type":     "object",
+				"required": []string{"category", "component", "title", "description", "risk", "mitigations"},
+				"properties": map[string]interface{}{
+					"category":    map[string]interface{}{"type": "string", "enum": categoryNames()},
+					"component":   map[string]interface{}{"type": "string"},
+					"title":       map[string]interface{}{"type": "string"},
+					"description": map[string]interface{}{"type": "string"},
+					"risk":        map[string]interface{}{"type": "string", "enum": []string{RiskLow, RiskMedium, RiskHigh, RiskCritical}},
+					"mitigations": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				},
+			},
+		},
+	},
+}
+
+func categoryNames() []string {
+	names := make([]string, len(Categories))
+	for i, c := range Categories {
+		names[i] = string(c)
+	}
+	return names
+}
+
+// suggest asks the LLM for design-level threats the rules cannot see. The
+// suggestions have no evidence in the files, so they are capped at high and
+// only rule findings can be critical and block the security gate.
+func (a *Agent) suggest(ctx context.Context, m *Model) ([]Threat, error) {
+	var out struct {
+		Threats []Threat `json:"threats"`
+	}
+	if err := jsonutil.CompleteAndDecode(ctx, a.llmClient, suggestPrompt(m), threatSchema, &out, 2); err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool)
+	for _, t := range m.Threats {
+		known[strings.ToLower(t.Title)] = true
+	}
+	var threats []Threat
+	for _, t := range out.Threats {
+		if known[strings.ToLower(t.Title)] {
+			continue
+		}
+		known[strings.ToLower(t.Title)] = true
+		if t.Risk == RiskCritical {
+			t.Risk = RiskHigh
+		}
+		t.Source = "llm"
+		t.Evidence = ""
+		threats = append(threats, t)
+	}
+	return threats, nil
+}
+
+func suggestPrompt(m *Model) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "You are a security architect threat modeling the generated system %q with STRIDE.\n\n", m.Project)
+	b.WriteString("Components and data flows (Mermaid):\n")
+	b.WriteString(m.Architecture.Mermaid())
+	b.WriteString("\nAttack surface:\n")
+	for _, e := range m.AttackSurface {
+		fmt.Fprintf(&b, "- %s %s (component %s, authenticated: %t)\n", e.Kind, e.Name, e.Component, e.Authenticated)
+	}
+	if len(m.Threats) > 0 {
+		b.WriteString("\nThreats already found in the code:\n")
+		for _, t := range m.Threats {
+			fmt.Fprintf(&b, "- [%s] %s: %s\n", t.Category, t.Title, t.Risk)
+		}
+	}
+	b.WriteString(`
+List further threats specific to this design across the STRIDE categories: spoofing, tampering, repudiation, information_disclosure, denial_of_service and elevation_of_privilege. Do not repeat the threats already found. For each give the category, the affected component from the diagram, a short title, a description of the attack, a risk of low, medium, high or critical, and concrete mitigations.
+
+Return JSON with "threats": the list.`)
+	return b.String()
+}
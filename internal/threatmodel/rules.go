@@ -0,0 +1,564 @@
+package threatmodel
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"QLP/internal/archdocs"
+
+	"gopkg.in/yaml.v3"
+)
+
+// analysis is the evidence the rules look at
+type analysis struct {
+	files   map[string]string
+	paths   []string
+	arch    archdocs.Architecture
+	routes  []archdocs.Route
+	surface []Entry
+}
+
+func newAnalysis(project string, files map[string]string) *analysis {
+	a := &analysis{files: files, arch: archdocs.ExtractArchitecture(project, files), routes: archdocs.ExtractRoutes(files)}
+	for p := range files {
+		a.paths = append(a.paths, p)
+	}
+	sort.Strings(a.paths)
+	a.surface = a.attackSurface()
+	return a
+}
+
+// sources are the application sources, without tests and examples
+func (a *analysis) sources() []string {
+	var sources []string
+	for _, p := range a.paths {
+		switch path.Ext(p) {
+		case ".go", ".py", ".js", ".ts", ".java", ".cs":
+		default:
+			continue
+		}
+		base := path.Base(p)
+		if strings.HasSuffix(base, "_test.go") || strings.HasPrefix(base, "test_") || strings.Contains(base, ".test.") || strings.Contains(base, ".spec.") {
+			continue
+		}
+		sources = append(sources, p)
+	}
+	return sources
+}
+
+// anySource reports whether a pattern matches any application source
+func (a *analysis) anySource(re *regexp.Regexp) bool {
+	for _, p := range a.sources() {
+		if re.MatchString(a.files[p]) {
+			return true
+		}
+	}
+	return false
+}
+
+// find returns "file:line" for each line of the files matching re
+func (a *analysis) find(re *regexp.Regexp, paths []string) []string {
+	var hits []string
+	for _, p := range paths {
+		for i, line := range strings.Split(a.files[p], "\n") {
+			if re.MatchString(line) {
+				hits = append(hits, fmt.Sprintf("%s:%d", p, i+1))
+			}
+		}
+	}
+	return hits
+}
+
+func (a *analysis) withExt(exts ...string) []string {
+	var paths []string
+	for _, p := range a.paths {
+		for _, ext := range exts {
+			if path.Ext(p) == ext {
+				paths = append(paths, p)
+			}
+		}
+	}
+	return paths
+}
+
+// component names the component a file belongs to: the service whose name is
+// a directory of the file, otherwise the first service
+func (a *analysis) component(file string) string {
+	first := ""
+	for _, c := range a.arch.Components {
+		if c.Kind != archdocs.KindService {
+			continue
+		}
+		if first == "" {
+			first = c.Label
+		}
+		if strings.HasPrefix(file, c.Label+"/") || strings.Contains(file, "/"+c.Label+"/") {
+			return c.Label
+		}
+	}
+	return first
+}
+
+var authPattern = regexp.MustCompile(`(?i)(authorization|bearer|jwt|oauth|api[-_]?key|authenticate|login_required|passport|requireauth|authmiddleware|auth_middleware|@login|httpbasicauth|basicauth|depends\(\s*get_current_user)`)
+
+// publicPath matches endpoints meant to be unauthenticated
+var publicPath = regexp.MustCompile(`(?i)(health|ready|live|metrics|docs|swagger|openapi|login|signup|register|token|^/$)`)
+
+func (a *analysis) attackSurface() []Entry {
+	var surface []Entry
+	globalAuth := a.anySource(authPattern)
+	for _, r := range a.routes {
+		file, _, _ := strings.Cut(r.Source, ":")
+		authenticated := globalAuth
+		if content, ok := a.files[file]; ok && authPattern.MatchString(content) {
+			authenticated = true
+		}
+		surface = append(surface, Entry{Kind: "endpoint", Name: r.Method + " " + r.Path, Component: a.component(file), Authenticated: authenticated, Evidence: r.Source})
+	}
+
+	expose := regexp.MustCompile(`(?i)^\s*EXPOSE\s+(.+)$`)
+	for _, p := range a.paths {
+		if base := path.Base(p); base != "Dockerfile" && !strings.HasPrefix(base, "Dockerfile.") {
+			continue
+		}
+		for i, line := range strings.Split(a.files[p], "\n") {
+			if m := expose.FindStringSubmatch(line); m != nil {
+				for _, port := range strings.Fields(m[1]) {
+					surface = append(surface, Entry{Kind: "port", Name: port, Component: a.component(p), Evidence: fmt.Sprintf("%s:%d", p, i+1)})
+				}
+			}
+		}
+	}
+
+	for _, p := range a.paths {
+		base := strings.ToLower(path.Base(p))
+		if strings.HasPrefix(base, "docker-compose") || strings.HasPrefix(base, "compose.") {
+			surface = append(surface, composePorts(p, a.files[p])...)
+		} else if ext := path.Ext(p); ext == ".yaml" || ext == ".yml" {
+			surface = append(surface, kubernetesExposure(p, a.files[p])...)
+		}
+	}
+	return surface
+}
+
+func composePorts(file, content string) []Entry {
+	var compose struct {
+		Services map[string]struct {
+			Ports []interface{} `yaml:"ports"`
+		} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal([]byte(content), &compose); err != nil {
+		return nil
+	}
+	var entries []Entry
+	names := make([]string, 0, len(compose.Services))
+	for name := range compose.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, port := range compose.Services[name].Ports {
+			entries = append(entries, Entry{Kind: "port", Name: fmt.Sprint(port), Component: name, Evidence: file})
+		}
+	}
+	return entries
+}
+
+func kubernetesExposure(file, content string) []Entry {
+	var entries []Entry
+	dec := yaml.NewDecoder(strings.NewReader(content))
+	for {
+		var obj struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Name string `yaml:"name"`
+			} `yaml:"metadata"`
+			Spec struct {
+				Type  string `yaml:"type"`
+				Rules []struct {
+					Host string `yaml:"host"`
+				} `yaml:"rules"`
+			} `yaml:"spec"`
+		}
+		if err := dec.Decode(&obj); err != nil {
+			break
+		}
+		switch {
+		case obj.Kind == "Service" && (obj.Spec.Type == "LoadBalancer" || obj.Spec.Type == "NodePort"):
+			entries = append(entries, Entry{Kind: "load_balancer", Name: obj.Metadata.Name + " (" + obj.Spec.Type + ")", Component: obj.Metadata.Name, Evidence: file})
+		case obj.Kind == "Ingress":
+			for _, rule := range obj.Spec.Rules {
+				host := rule.Host
+				if host == "" {
+					host = "*"
+				}
+				entries = append(entries, Entry{Kind: "ingress", Name: host, Component: obj.Metadata.Name, Evidence: file})
+			}
+		}
+	}
+	return entries
+}
+
+// rule finds one kind of threat in the evidence
+type rule func(a *analysis) []Threat
+
+var rules = []rule{
+	unauthenticatedEndpoints,
+	hardcodedSecrets,
+	sqlInjection,
+	unencryptedTransport,
+	missingRateLimiting,
+	missingServerTimeouts,
+	missingAuditTrail,
+	rootContainers,
+	privilegedWorkloads,
+	exposedDatastores,
+	wildcardCORS,
+	openNetworks,
+}
+
+func unauthenticatedEndpoints(a *analysis) []Threat {
+	var open, writes []string
+	var evidence string
+	for _, e := range a.surface {
+		if e.Kind != "endpoint" || e.Authenticated || publicPath.MatchString(strings.SplitN(e.Name, " ", 2)[1]) {
+			continue
+		}
+		if evidence == "" {
+			evidence = e.Evidence
+		}
+		open = append(open, e.Name)
+		method := strings.SplitN(e.Name, " ", 2)[0]
+		if method != "GET" && method != "HEAD" && method != "OPTIONS" {
+			writes = append(writes, e.Name)
+		}
+	}
+	if len(open) == 0 {
+		return nil
+	}
+	file, _, _ := strings.Cut(evidence, ":")
+	threats := []Threat{{
+		Category:    Spoofing,
+		Component:   a.component(file),
+		Title:       "Endpoints accept unauthenticated requests",
+		Description: fmt.Sprintf("No authentication was found for %s, so any client can call them as any user.", summarize(open)),
+		Risk:        RiskMedium,
+		Mitigations: []string{"Require authentication (OAuth 2.0/OIDC bearer tokens or API keys) in middleware for every non-public route"},
+		Evidence:    evidence,
+	}}
+	if len(writes) > 0 {
+		threats[0].Risk = RiskHigh
+		threats = append(threats, Threat{
+			Category:    ElevationOfPrivilege,
+			Component:   a.component(file),
+			Title:       "Data can be changed without authorization",
+			Description: fmt.Sprintf("%s modify data without authentication or authorization checks.", summarize(writes)),
+			Risk:        RiskHigh,
+			Mitigations: []string{"Authorize each write against the caller's roles or ownership of the resource"},
+			Evidence:    evidence,
+		})
+	}
+	return threats
+}
+
+var (
+	secretPattern  = regexp.MustCompile(`(?i)\b\w*(?:password|passwd|secret|api[_-]?key|access[_-]?key|private[_-]?key|token)\w*["']?\s*(?::=|=|:)\s*["']([^"'\s]{6,})["']`)
+	placeholderish = regexp.MustCompile(`(?i)(example|changeme|change-me|your|xxx|placeholder|dummy|<|\$\{|\{\{|os\.getenv|process\.env)`)
+)
+
+func hardcodedSecrets(a *analysis) []Threat {
+	var hits []string
+	for _, p := range append(a.sources(), a.withExt(".yaml", ".yml", ".json", ".tf")...) {
+		if strings.Contains(p, ".example") {
+			continue
+		}
+		for i, line := range strings.Split(a.files[p], "\n") {
+			if m := secretPattern.FindStringSubmatch(line); m != nil && !placeholderish.MatchString(m[1]) {
+				hits = append(hits, fmt.Sprintf("%s:%d", p, i+1))
+			}
+		}
+	}
+	if len(hits) == 0 {
+		return nil
+	}
+	file, _, _ := strings.Cut(hits[0], ":")
+	return []Threat{{
+		Category:    InformationDisclosure,
+		Component:   a.component(file),
+		Title:       "Credentials are hardcoded",
+		Description: fmt.Sprintf("%d credential values are written into the code or configuration, where anyone with the source or image can read them.", len(hits)),
+		Risk:        RiskCritical,
+		Mitigations: []string{"Read credentials from environment variables or a secret store and rotate the exposed values"},
+		Evidence:    hits[0],
+	}}
+}
+
+// sqlConcat matches queries assembled with +, Sprintf, f-strings, % or JS
+// template literals; placeholders passed separately do not match
+var sqlConcat = regexp.MustCompile(strings.Join([]string{
+	`(?i)\b(select|insert into|update|delete from)\s[^"'` + "`" + `]*["'` + "`" + `]\s*\+`,
+	`(?i)Sprintf\(\s*"(select|insert|update|delete)\s`,
+	`(?i)\bf["'](select|insert|update|delete)\s[^"']*\{`,
+	`(?i)["'](select|insert|update|delete)\s[^"']*%s[^"']*["']\s*%`,
+	"(?i)`(select|insert|update|delete)\\s[^`]*\\$\\{",
+}, "|"))
+
+func sqlInjection(a *analysis) []Threat {
+	hits := a.find(sqlConcat, a.sources())
+	if len(hits) == 0 {
+		return nil
+	}
+	component := a.component(strings.SplitN(hits[0], ":", 2)[0])
+	for _, c := range a.arch.Components {
+		if c.Kind == archdocs.KindDatastore {
+			component = c.Label
+			break
+		}
+	}
+	return []Threat{{
+		Category:    Tampering,
+		Component:   component,
+		Title:       "SQL is built from request input",
+		Description: fmt.Sprintf("Queries are assembled with string formatting in %d places, which allows SQL injection.", len(hits)),
+		Risk:        RiskHigh,
+		Mitigations: []string{"Use parameterized queries or the ORM's query builder for every value"},
+		Evidence:    hits[0],
+	}}
+}
+
+var tlsPattern = regexp.MustCompile(`(?i)(ListenAndServeTLS|tls\.Config|ssl_context|certfile|https\.createServer|cert-manager|\btls:|ssl_certificate)`)
+
+func unencryptedTransport(a *analysis) []Threat {
+	if len(a.surface) == 0 {
+		return nil
+	}
+	for _, p := range a.paths {
+		if tlsPattern.MatchString(a.files[p]) {
+			return nil
+		}
+	}
+	return []Threat{{
+		Category:    InformationDisclosure,
+		Component:   a.surface[0].Component,
+		Title:       "Traffic is not encrypted in transit",
+		Description: "No TLS configuration was found for the exposed endpoints, so credentials and data cross the network in clear text.",
+		Risk:        RiskMedium,
+		Mitigations: []string{"Terminate TLS at the ingress or load balancer, or serve HTTPS directly"},
+		Evidence:    a.surface[0].Evidence,
+	}}
+}
+
+var rateLimitPattern = regexp.MustCompile(`(?i)(rate.?limit|limiter|throttl|slowapi)`)
+
+func missingRateLimiting(a *analysis) []Threat {
+	if len(a.routes) == 0 {
+		return nil
+	}
+	for _, p := range a.paths {
+		if rateLimitPattern.MatchString(a.files[p]) {
+			return nil
+		}
+	}
+	return []Threat{{
+		Category:    DenialOfService,
+		Component:   a.component(strings.SplitN(a.routes[0].Source, ":", 2)[0]),
+		Title:       "Requests are not rate limited",
+		Description: "A single client can exhaust the service and its backing stores with request floods.",
+		Risk:        RiskMedium,
+		Mitigations: []string{"Rate limit per client at the gateway or in middleware", "Set resource limits and autoscaling for the workloads"},
+	}}
+}
+
+var (
+	bareListen     = regexp.MustCompile(`http\.ListenAndServe\(`)
+	serverTimeouts = regexp.MustCompile(`Read(Header)?Timeout`)
+)
+
+func missingServerTimeouts(a *analysis) []Threat {
+	hits := a.find(bareListen, a.withExt(".go"))
+	if len(hits) == 0 || a.anySource(serverTimeouts) {
+		return nil
+	}
+	return []Threat{{
+		Category:    DenialOfService,
+		Component:   a.component(strings.SplitN(hits[0], ":", 2)[0]),
+		Title:       "HTTP server has no timeouts",
+		Description: "http.ListenAndServe never times out slow clients, so a few slow connections can hold every worker (Slowloris).",
+		Risk:        RiskMedium,
+		Mitigations: []string{"Serve with an http.Server that sets ReadHeaderTimeout, ReadTimeout, WriteTimeout and IdleTimeout"},
+		Evidence:    hits[0],
+	}}
+}
+
+var (
+	loggingPattern = regexp.MustCompile(`(?i)(\blog\.|logger|logging|console\.(log|info|error)|zap\.|slog\.|winston|pino)`)
+	auditPattern   = regexp.MustCompile(`(?i)audit`)
+)
+
+func missingAuditTrail(a *analysis) []Threat {
+	if len(a.routes) == 0 || a.anySource(auditPattern) {
+		return nil
+	}
+	component := a.component(strings.SplitN(a.routes[0].Source, ":", 2)[0])
+	if !a.anySource(loggingPattern) {
+		return []Threat{{
+			Category:    Repudiation,
+			Component:   component,
+			Title:       "Requests are not logged",
+			Description: "Without request logs, actions cannot be traced to callers and incidents cannot be investigated.",
+			Risk:        RiskMedium,
+			Mitigations: []string{"Log every request with the authenticated caller, action and outcome to a central store"},
+		}}
+	}
+	return []Threat{{
+		Category:    Repudiation,
+		Component:   component,
+		Title:       "Changes have no audit trail",
+		Description: "Writes are logged as diagnostics only; there is no tamper-evident record of who changed what.",
+		Risk:        RiskLow,
+		Mitigations: []string{"Record writes with caller identity in an append-only audit log"},
+	}}
+}
+
+var userDirective = regexp.MustCompile(`(?im)^\s*USER\s+(\S+)`)
+
+func rootContainers(a *analysis) []Threat {
+	var threats []Threat
+	for _, p := range a.paths {
+		if base := path.Base(p); base != "Dockerfile" && !strings.HasPrefix(base, "Dockerfile.") {
+			continue
+		}
+		users := userDirective.FindAllStringSubmatch(a.files[p], -1)
+		if len(users) > 0 && users[len(users)-1][1] != "root" && users[len(users)-1][1] != "0" {
+			continue
+		}
+		threats = append(threats, Threat{
+			Category:    ElevationOfPrivilege,
+			Component:   a.component(p),
+			Title:       "Container runs as root",
+			Description: "A compromise of the process gives root inside the container, which eases container escapes.",
+			Risk:        RiskMedium,
+			Mitigations: []string{"Add a non-root USER to the image and set runAsNonRoot in the pod security context"},
+			Evidence:    p,
+		})
+	}
+	return threats
+}
+
+var (
+	privileged   = regexp.MustCompile(`privileged:\s*true`)
+	escalation   = regexp.MustCompile(`(allowPrivilegeEscalation|hostNetwork|hostPID):\s*true`)
+	manifestExts = []string{".yaml", ".yml"}
+)
+
+func privilegedWorkloads(a *analysis) []Threat {
+	var threats []Threat
+	if hits := a.find(privileged, a.withExt(manifestExts...)); len(hits) > 0 {
+		threats = append(threats, Threat{
+			Category:    ElevationOfPrivilege,
+			Component:   a.component(strings.SplitN(hits[0], ":", 2)[0]),
+			Title:       "Privileged containers",
+			Description: "Privileged containers have full access to the host, so a compromised container compromises the node.",
+			Risk:        RiskCritical,
+			Mitigations: []string{"Remove privileged: true and grant only the specific capabilities needed"},
+			Evidence:    hits[0],
+		})
+	}
+	if hits := a.find(escalation, a.withExt(manifestExts...)); len(hits) > 0 {
+		threats = append(threats, Threat{
+			Category:    ElevationOfPrivilege,
+			Component:   a.component(strings.SplitN(hits[0], ":", 2)[0]),
+			Title:       "Workloads can escalate privileges or share host namespaces",
+			Description: "Privilege escalation or host namespaces widen what an attacker gains from a compromised container.",
+			Risk:        RiskHigh,
+			Mitigations: []string{"Set allowPrivilegeEscalation: false and do not share host network or PID namespaces"},
+			Evidence:    hits[0],
+		})
+	}
+	return threats
+}
+
+func exposedDatastores(a *analysis) []Threat {
+	datastores := make(map[string]bool)
+	for _, c := range a.arch.Components {
+		if c.Kind == archdocs.KindDatastore {
+			datastores[c.Label] = true
+		}
+	}
+	var threats []Threat
+	for _, e := range a.surface {
+		if e.Kind == "port" && datastores[e.Component] {
+			threats = append(threats, Threat{
+				Category:    InformationDisclosure,
+				Component:   e.Component,
+				Title:       "Data store is published outside the private network",
+				Description: fmt.Sprintf("Port %s of %s is published to the host, exposing the data store to direct access.", e.Name, e.Component),
+				Risk:        RiskHigh,
+				Mitigations: []string{"Keep data stores on the internal network only and require authentication and TLS for connections"},
+				Evidence:    e.Evidence,
+			})
+		}
+	}
+	return threats
+}
+
+var corsWildcard = regexp.MustCompile(`(?i)(allow[-_]?origins?["']?\s*[:=(,]\s*\[?\s*["']\*["']|Access-Control-Allow-Origin["']?\s*,\s*["']\*|origin:\s*["']\*["'])`)
+
+func wildcardCORS(a *analysis) []Threat {
+	hits := a.find(corsWildcard, a.sources())
+	if len(hits) == 0 {
+		return nil
+	}
+	return []Threat{{
+		Category:    InformationDisclosure,
+		Component:   a.component(strings.SplitN(hits[0], ":", 2)[0]),
+		Title:       "CORS allows every origin",
+		Description: "Any website can call the API from a user's browser and read the responses.",
+		Risk:        RiskMedium,
+		Mitigations: []string{"Allow only the known front-end origins"},
+		Evidence:    hits[0],
+	}}
+}
+
+var (
+	openCIDR     = regexp.MustCompile(`0\.0\.0\.0/0`)
+	publicAccess = regexp.MustCompile(`(publicly_accessible|public_network_access_enabled|allow_blob_public_access)\s*=\s*true`)
+)
+
+func openNetworks(a *analysis) []Threat {
+	var threats []Threat
+	tf := a.withExt(".tf")
+	if hits := a.find(publicAccess, tf); len(hits) > 0 {
+		threats = append(threats, Threat{
+			Category:    InformationDisclosure,
+			Component:   "infrastructure",
+			Title:       "Cloud resources are publicly accessible",
+			Description: "Managed resources are reachable from the internet instead of only from the application network.",
+			Risk:        RiskHigh,
+			Mitigations: []string{"Disable public access and use private endpoints or VPC peering"},
+			Evidence:    hits[0],
+		})
+	}
+	if hits := a.find(openCIDR, tf); len(hits) > 0 {
+		threats = append(threats, Threat{
+			Category:    InformationDisclosure,
+			Component:   "infrastructure",
+			Title:       "Network rules allow traffic from anywhere",
+			Description: "Security groups or firewall rules accept connections from 0.0.0.0/0.",
+			Risk:        RiskMedium,
+			Mitigations: []string{"Restrict ingress to the load balancer and known address ranges"},
+			Evidence:    hits[0],
+		})
+	}
+	return threats
+}
+
+func summarize(items []string) string {
+	if len(items) > 3 {
+		return fmt.Sprintf("%s and %d more endpoints", strings.Join(items[:3], ", "), len(items)-3)
+	}
+	return strings.Join(items, ", ")
+}
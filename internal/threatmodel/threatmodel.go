@@ -0,0 +1,199 @@
+// Package threatmodel produces a STRIDE threat model for a generated system:
+// its components and data flows, the attack surface it exposes, and the
+// threats to each component with suggested mitigations. Rule-based checks
+// find the common weaknesses in the files; the LLM adds threats specific to
+// the design.
+package threatmodel
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"QLP/internal/archdocs"
+)
+
+// Category is a STRIDE threat category
+type Category string
+
+const (
+	Spoofing              Category = "spoofing"
+	Tampering             Category = "tampering"
+	Repudiation           Category = "repudiation"
+	InformationDisclosure Category = "information_disclosure"
+	DenialOfService       Category = "denial_of_service"
+	ElevationOfPrivilege  Category = "elevation_of_privilege"
+)
+
+// Categories lists the STRIDE categories in order
+var Categories = []Category{Spoofing, Tampering, Repudiation, InformationDisclosure, DenialOfService, ElevationOfPrivilege}
+
+// Title returns the display name of the category
+func (c Category) Title() string {
+	switch c {
+	case InformationDisclosure:
+		return "Information Disclosure"
+	case DenialOfService:
+		return "Denial of Service"
+	case ElevationOfPrivilege:
+		return "Elevation of Privilege"
+	}
+	return strings.ToUpper(string(c[:1])) + string(c[1:])
+}
+
+// Risk levels of threats, from the likelihood and impact of each
+const (
+	RiskLow      = "low"
+	RiskMedium   = "medium"
+	RiskHigh     = "high"
+	RiskCritical = "critical"
+)
+
+var riskRank = map[string]int{RiskLow: 1, RiskMedium: 2, RiskHigh: 3, RiskCritical: 4}
+
+// Threat is one identified threat to a component
+type Threat struct {
+	ID          string   `json:"id"`
+	Category    Category `json:"category"`
+	Component   string   `json:"component"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Risk        string   `json:"risk"`
+	Mitigations []string `json:"mitigations"`
+	Evidence    string   `json:"evidence,omitempty"` // file or file:line the threat was found in
+	Source      string   `json:"source"`             // "rule" or "llm"
+}
+
+// HighRisk reports whether the threat is high or critical
+func (t Threat) HighRisk() bool {
+	return riskRank[t.Risk] >= riskRank[RiskHigh]
+}
+
+// Entry is a point of the attack surface: an endpoint, an exposed port or a
+// publicly reachable resource
+type Entry struct {
+	Kind          string `json:"kind"` // endpoint, port, load_balancer, ingress
+	Name          string `json:"name"`
+	Component     string `json:"component,omitempty"`
+	Authenticated bool   `json:"authenticated"`
+	Evidence      string `json:"evidence,omitempty"`
+}
+
+// Model is the threat model of a generated system
+type Model struct {
+	Project       string                `json:"project"`
+	Architecture  archdocs.Architecture `json:"architecture"` // components and data flows
+	AttackSurface []Entry               `json:"attack_surface"`
+	Threats       []Threat              `json:"threats"`
+}
+
+// HighRisk returns the high and critical threats
+func (m *Model) HighRisk() []Threat {
+	var threats []Threat
+	for _, t := range m.Threats {
+		if t.HighRisk() {
+			threats = append(threats, t)
+		}
+	}
+	return threats
+}
+
+// Blocking reports whether a critical threat was found, which fails the
+// security gate
+func (m *Model) Blocking() bool {
+	for _, t := range m.Threats {
+		if t.Risk == RiskCritical {
+			return true
+		}
+	}
+	return false
+}
+
+// Counts returns the number of threats per risk level
+func (m *Model) Counts() map[string]int {
+	counts := make(map[string]int)
+	for _, t := range m.Threats {
+		counts[t.Risk]++
+	}
+	return counts
+}
+
+// sortThreats orders threats by risk, highest first, then by category and
+// numbers them
+func (m *Model) sortThreats() {
+	order := make(map[Category]int)
+	for i, c := range Categories {
+		order[c] = i
+	}
+	sort.SliceStable(m.Threats, func(i, j int) bool {
+		a, b := m.Threats[i], m.Threats[j]
+		if riskRank[a.Risk] != riskRank[b.Risk] {
+			return riskRank[a.Risk] > riskRank[b.Risk]
+		}
+		return order[a.Category] < order[b.Category]
+	})
+	for i := range m.Threats {
+		m.Threats[i].ID = fmt.Sprintf("T%03d", i+1)
+	}
+}
+
+// JSON encodes the model for the capsule
+func (m *Model) JSON() string {
+	data, _ := json.MarshalIndent(m, "", "  ")
+	return string(data)
+}
+
+// Markdown renders the model as a document for reviewers
+func (m *Model) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s Threat Model\n\n", m.Project)
+	counts := m.Counts()
+	fmt.Fprintf(&b, "%d threats: %d critical, %d high, %d medium, %d low.\n\n",
+		len(m.Threats), counts[RiskCritical], counts[RiskHigh], counts[RiskMedium], counts[RiskLow])
+
+	b.WriteString("## Components and Data Flows\n\n```mermaid\n")
+	b.WriteString(m.Architecture.Mermaid())
+	b.WriteString("```\n\n## Attack Surface\n\n")
+	if len(m.AttackSurface) == 0 {
+		b.WriteString("No exposed endpoints or ports were found.\n")
+	} else {
+		b.WriteString("| Kind | Entry | Component | Authenticated | Evidence |\n|---|---|---|---|---|\n")
+		for _, e := range m.AttackSurface {
+			auth := "-"
+			if e.Kind == "endpoint" {
+				auth = "no"
+				if e.Authenticated {
+					auth = "yes"
+				}
+			}
+			fmt.Fprintf(&b, "| %s | `%s` | %s | %s | %s |\n", e.Kind, e.Name, e.Component, auth, e.Evidence)
+		}
+	}
+
+	b.WriteString("\n## Threats\n")
+	for _, c := range Categories {
+		var threats []Threat
+		for _, t := range m.Threats {
+			if t.Category == c {
+				threats = append(threats, t)
+			}
+		}
+		fmt.Fprintf(&b, "\n### %s\n\n", c.Title())
+		if len(threats) == 0 {
+			b.WriteString("No threats identified.\n")
+			continue
+		}
+		for _, t := range threats {
+			fmt.Fprintf(&b, "- **%s %s** (%s, %s): %s", t.ID, t.Title, t.Risk, t.Component, t.Description)
+			if t.Evidence != "" {
+				fmt.Fprintf(&b, " Found in `%s`.", t.Evidence)
+			}
+			b.WriteString("\n")
+			for _, mitigation := range t.Mitigations {
+				fmt.Fprintf(&b, "  - Mitigation: %s\n", mitigation)
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,189 @@
+package threatmodel
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var sampleProject = map[string]string{
+	"main.go": `package main
+
+import "net/http"
+
+const dbPassword = "s3cr3tpass"
+
+func main() {
+	http.HandleFunc("GET /orders", listOrders)
+	http.HandleFunc("POST /orders", createOrder)
+	http.HandleFunc("/health", health)
+	http.ListenAndServe(":8080", nil)
+}
+`,
+	"Dockerfile": "FROM golang:1.21\nCOPY . .\nRUN go build -o app\nEXPOSE 8080\nCMD [\"./app\"]\n",
+	"docker-compose.yml": `services:
+  app:
+    build: .
+    ports:
+      - "8080:8080"
+    depends_on:
+      - db
+  db:
+    image: postgres:16
+    ports:
+      - "5432:5432"
+`,
+}
+
+func threatsByTitle(m *Model) map[string]Threat {
+	threats := make(map[string]Threat)
+	for _, t := range m.Threats {
+		threats[t.Title] = t
+	}
+	return threats
+}
+
+func TestAnalyzeFindsRuleThreats(t *testing.T) {
+	m := NewAgent(nil).Analyze(context.Background(), "orders", sampleProject)
+	threats := threatsByTitle(m)
+
+	want := map[string]struct {
+		category Category
+		risk     string
+	}{
+		"Endpoints accept unauthenticated requests":           {Spoofing, RiskHigh},
+		"Data can be changed without authorization":           {ElevationOfPrivilege, RiskHigh},
+		"Credentials are hardcoded":                           {InformationDisclosure, RiskCritical},
+		"Container runs as root":                              {ElevationOfPrivilege, RiskMedium},
+		"Data store is published outside the private network": {InformationDisclosure, RiskHigh},
+		"HTTP server has no timeouts":                         {DenialOfService, RiskMedium},
+		"Requests are not rate limited":                       {DenialOfService, RiskMedium},
+	}
+	for title, w := range want {
+		got, ok := threats[title]
+		if !ok {
+			t.Errorf("missing threat %q", title)
+			continue
+		}
+		if got.Category != w.category || got.Risk != w.risk || got.Source != "rule" {
+			t.Errorf("%q: expected %s/%s from a rule, got %+v", title, w.category, w.risk, got)
+		}
+	}
+	if got := threats["Credentials are hardcoded"].Evidence; got != "main.go:5" {
+		t.Errorf("expected the secret to be found at main.go:5, got %q", got)
+	}
+	if !m.Blocking() {
+		t.Error("expected the hardcoded credential to block")
+	}
+
+	var endpoints, ports int
+	for _, e := range m.AttackSurface {
+		switch e.Kind {
+		case "endpoint":
+			endpoints++
+			if e.Authenticated {
+				t.Errorf("expected %s to be unauthenticated", e.Name)
+			}
+		case "port":
+			ports++
+		}
+	}
+	if endpoints != 3 || ports != 3 {
+		t.Errorf("expected 3 endpoints and 3 ports, got %d and %d: %+v", endpoints, ports, m.AttackSurface)
+	}
+}
+
+func TestAnalyzeHardenedProject(t *testing.T) {
+	files := map[string]string{
+		"main.go": `package main
+
+func main() {
+	r.Use(AuthMiddleware, rateLimiter)
+	r.HandleFunc("/orders", createOrder).Methods("POST")
+	srv := &http.Server{ReadHeaderTimeout: 5 * time.Second}
+	log.Fatal(srv.ListenAndServeTLS("cert.pem", "key.pem"))
+}
+
+func audit(user, action string) { logger.Info(action) }
+`,
+		"Dockerfile": "FROM golang:1.21\nUSER 65532\n",
+	}
+	m := NewAgent(nil).Analyze(context.Background(), "orders", files)
+	if len(m.Threats) != 0 {
+		t.Errorf("expected no threats, got %+v", m.Threats)
+	}
+	if m.Blocking() || len(m.HighRisk()) != 0 {
+		t.Error("expected a hardened project not to block")
+	}
+}
+
+type fakeClient struct {
+	response string
+	err      error
+}
+
+func (c *fakeClient) Complete(ctx context.Context, prompt string) (string, error) {
+	return c.response, c.err
+}
+
+func (c *fakeClient) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	return nil, nil
+}
+
+func TestAnalyzeMergesSuggestions(t *testing.T) {
+	client := &fakeClient{response: `{"threats": [
+		{"category": "spoofing", "component": "app", "title": "Endpoints accept unauthenticated requests", "description": "dup", "risk": "low", "mitigations": []},
+		{"category": "tampering", "component": "db", "title": "Order totals can be changed in transit", "description": "d", "risk": "critical", "mitigations": ["Sign order payloads"]}
+	]}`}
+	m := NewAgent(client).Analyze(context.Background(), "orders", sampleProject)
+	threats := threatsByTitle(m)
+
+	if threats["Endpoints accept unauthenticated requests"].Source != "rule" {
+		t.Error("expected the rule finding to be kept over a duplicate suggestion")
+	}
+	suggested, ok := threats["Order totals can be changed in transit"]
+	if !ok {
+		t.Fatal("expected the suggested threat to be added")
+	}
+	if suggested.Risk != RiskHigh || suggested.Source != "llm" {
+		t.Errorf("expected a suggestion capped at high, got %+v", suggested)
+	}
+}
+
+func TestAnalyzeWithoutLLM(t *testing.T) {
+	withError := NewAgent(&fakeClient{err: errors.New("unavailable")}).Analyze(context.Background(), "orders", sampleProject)
+	rulesOnly := NewAgent(nil).Analyze(context.Background(), "orders", sampleProject)
+	if len(withError.Threats) != len(rulesOnly.Threats) {
+		t.Errorf("expected the rule findings when the LLM fails, got %d threats instead of %d", len(withError.Threats), len(rulesOnly.Threats))
+	}
+}
+
+func TestModelOrderingAndMarkdown(t *testing.T) {
+	m := &Model{Project: "orders", Threats: []Threat{
+		{Category: Repudiation, Title: "No audit", Risk: RiskLow},
+		{Category: Tampering, Title: "SQL", Risk: RiskHigh, Mitigations: []string{"Parameterize"}},
+		{Category: Spoofing, Title: "Open", Risk: RiskHigh, Evidence: "main.go:3"},
+	}}
+	m.sortThreats()
+	if m.Threats[0].Title != "Open" || m.Threats[0].ID != "T001" || m.Threats[2].ID != "T003" {
+		t.Errorf("expected threats ordered by risk then category, got %+v", m.Threats)
+	}
+
+	md := m.Markdown()
+	for _, want := range []string{
+		"3 threats: 0 critical, 2 high, 0 medium, 1 low.",
+		"No exposed endpoints or ports were found.",
+		"- **T001 Open** (high, ): ",
+		"Found in `main.go:3`.",
+		"  - Mitigation: Parameterize",
+		"### Denial of Service\n\nNo threats identified.",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("expected markdown to contain %q:\n%s", want, md)
+		}
+	}
+	if !strings.Contains(m.JSON(), `"id": "T002"`) {
+		t.Error("expected the JSON to carry threat IDs")
+	}
+}
@@ -0,0 +1,91 @@
+package trends
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"QLP/internal/audit"
+)
+
+// Routes returns the quality trend endpoints. from and to are RFC 3339 times
+// or dates and default to the last 30 days; interval is hour, day (default),
+// week or a duration; metric is a comma-separated list, all by default.
+//
+//	GET /trends?tenant=&project=&metric=&from=&to=&interval=&group_by=tenant|project
+//	GET /tenants/{tenant}/trends?project=&metric=&from=&to=&interval=&group_by=project
+func Routes(t *Tracker) map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /trends":                  seriesHandler(t),
+		"GET /tenants/{tenant}/trends": seriesHandler(t),
+	}
+}
+
+func seriesHandler(t *Tracker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseQuery(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		q = t.withDefaults(q)
+		series, err := t.Series(r.Context(), q)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"tenant_id": q.TenantID,
+			"project":   q.Project,
+			"from":      q.From.UTC(),
+			"to":        q.To.UTC(),
+			"interval":  q.Interval.String(),
+			"series":    series,
+		})
+	})
+}
+
+func parseQuery(r *http.Request) (Query, error) {
+	params := r.URL.Query()
+	q := Query{
+		TenantID: audit.RequestTenant(r),
+		Project:  params.Get("project"),
+		GroupBy:  GroupBy(params.Get("group_by")),
+	}
+	if tenant := r.PathValue("tenant"); tenant != "" {
+		q.TenantID = tenant
+	}
+	switch q.GroupBy {
+	case GroupNone, GroupTenant, GroupProject:
+	default:
+		return q, fmt.Errorf("%w: group_by must be tenant or project", ErrInvalidQuery)
+	}
+
+	var err error
+	if q.Metrics, err = ParseMetrics(params.Get("metric")); err != nil {
+		return q, err
+	}
+	if q.Interval, err = ParseInterval(params.Get("interval")); err != nil {
+		return q, err
+	}
+	if q.From, err = ParseTime(params.Get("from")); err != nil {
+		return q, err
+	}
+	if q.To, err = ParseTime(params.Get("to")); err != nil {
+		return q, err
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return q, fmt.Errorf("%w: from must be before to", ErrInvalidQuery)
+	}
+	return q, nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, ErrInvalidQuery) {
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
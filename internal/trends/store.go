@@ -0,0 +1,123 @@
+package trends
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"QLP/internal/database"
+	"QLP/internal/logger"
+)
+
+// Store keeps quality samples
+type Store interface {
+	Add(ctx context.Context, s Sample) error
+	// List returns the samples recorded in [from, to), of one tenant unless
+	// tenantID is empty
+	List(ctx context.Context, tenantID string, from, to time.Time) ([]Sample, error)
+}
+
+// MemoryStore keeps samples in memory, for tests and deployments without a
+// database
+type MemoryStore struct {
+	mu      sync.Mutex
+	samples []Sample
+}
+
+// NewMemoryStore creates an empty in-memory sample store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (s *MemoryStore) Add(_ context.Context, sample Sample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples = append(s.samples, sample)
+	return nil
+}
+
+func (s *MemoryStore) List(_ context.Context, tenantID string, from, to time.Time) ([]Sample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var samples []Sample
+	for _, sample := range s.samples {
+		if (tenantID == "" || sample.TenantID == tenantID) && !sample.At.Before(from) && sample.At.Before(to) {
+			samples = append(samples, sample)
+		}
+	}
+	return samples, nil
+}
+
+// PostgresStore keeps samples in the quality_samples table, shared by every
+// QLP process
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates the quality_samples table if needed
+func NewPostgresStore(db *sql.DB) (*PostgresStore, error) {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS quality_samples (
+			metric VARCHAR(50) NOT NULL,
+			tenant_id VARCHAR(100) NOT NULL,
+			project VARCHAR(200) NOT NULL DEFAULT '',
+			capsule_id VARCHAR(100) NOT NULL DEFAULT '',
+			value DOUBLE PRECISION NOT NULL,
+			recorded_at TIMESTAMP WITH TIME ZONE NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_quality_samples_recorded ON quality_samples (recorded_at, tenant_id)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create quality_samples table: %w", err)
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+func (s *PostgresStore) Add(ctx context.Context, sample Sample) error {
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO quality_samples (metric, tenant_id, project, capsule_id, value, recorded_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		string(sample.Metric), sample.TenantID, sample.Project, sample.CapsuleID, sample.Value, sample.At); err != nil {
+		return fmt.Errorf("failed to record quality sample: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) List(ctx context.Context, tenantID string, from, to time.Time) ([]Sample, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT metric, tenant_id, project, capsule_id, value, recorded_at FROM quality_samples
+		 WHERE recorded_at >= $1 AND recorded_at < $2 AND ($3 = '' OR tenant_id = $3)
+		 ORDER BY recorded_at`,
+		from, to, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list quality samples: %w", err)
+	}
+	defer rows.Close()
+
+	var samples []Sample
+	for rows.Next() {
+		var sample Sample
+		var metric string
+		if err := rows.Scan(&metric, &sample.TenantID, &sample.Project, &sample.CapsuleID, &sample.Value, &sample.At); err != nil {
+			return nil, fmt.Errorf("failed to read quality sample: %w", err)
+		}
+		sample.Metric = Metric(metric)
+		samples = append(samples, sample)
+	}
+	return samples, rows.Err()
+}
+
+// NewStoreFromEnv keeps samples in the database at DATABASE_URL, falling
+// back to memory when it is unavailable
+func NewStoreFromEnv() (Store, error) {
+	db, err := database.New()
+	if err != nil {
+		return nil, err
+	}
+	if !db.IsConnected() {
+		logger.WithComponent("trends").Warn("Database unavailable, quality samples are kept in memory and not shared between processes")
+		return NewMemoryStore(), nil
+	}
+	return NewPostgresStore(db.GetConnection())
+}
@@ -0,0 +1,327 @@
+// Package trends records quality samples of every run, such as validation
+// scores, auto-approvals, deployment outcomes and generation latency, and
+// returns them as time series per tenant and project, so teams can chart
+// whether generation quality is improving.
+package trends
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/config"
+	"QLP/internal/logger"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidQuery is returned for unknown metrics, intervals and malformed times
+var ErrInvalidQuery = errors.New("invalid trend query")
+
+// Metric is a recorded quality measure
+type Metric string
+
+const (
+	// ValidationScore is the overall score of each capsule, 0 to 100
+	ValidationScore Metric = "validation_score"
+	// AutoApproval is 1 for each drop approved without human review and 0
+	// for each sent to review, so its mean is the auto-approval rate
+	AutoApproval Metric = "hitl_auto_approval"
+	// DeploymentSuccess is 1 for each successful deployment and 0 for each
+	// failed one, so its mean is the success rate
+	DeploymentSuccess Metric = "deployment_success"
+	// GenerationLatency is the seconds from intent to capsule
+	GenerationLatency Metric = "generation_latency_seconds"
+)
+
+// Metrics lists every metric in report order
+var Metrics = []Metric{ValidationScore, AutoApproval, DeploymentSuccess, GenerationLatency}
+
+// defaultTenant holds samples that carry no tenant
+const defaultTenant = "default"
+
+// Sample is one measurement. CapsuleID ties samples without a project, such
+// as deployments, to the project their capsule was generated for.
+type Sample struct {
+	Metric    Metric    `json:"metric"`
+	TenantID  string    `json:"tenant_id"`
+	Project   string    `json:"project,omitempty"`
+	CapsuleID string    `json:"capsule_id,omitempty"`
+	Value     float64   `json:"value"`
+	At        time.Time `json:"at"`
+}
+
+// Point aggregates the samples of one interval
+type Point struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+	Mean  float64   `json:"mean"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+}
+
+// Series is one metric over time, for a tenant or project when grouped by one
+type Series struct {
+	Metric   Metric  `json:"metric"`
+	TenantID string  `json:"tenant_id,omitempty"`
+	Project  string  `json:"project,omitempty"`
+	Points   []Point `json:"points"`
+}
+
+// GroupBy splits series by tenant or project
+type GroupBy string
+
+const (
+	GroupNone    GroupBy = ""
+	GroupTenant  GroupBy = "tenant"
+	GroupProject GroupBy = "project"
+)
+
+// Query selects samples and how to aggregate them. Empty fields select
+// everything; Interval defaults to a day.
+type Query struct {
+	TenantID string
+	Project  string
+	Metrics  []Metric
+	From     time.Time
+	To       time.Time
+	Interval time.Duration
+	GroupBy  GroupBy
+}
+
+// Tracker records samples and aggregates them into series
+type Tracker struct {
+	store Store
+	now   func() time.Time
+}
+
+// NewTracker records samples in store
+func NewTracker(store Store) *Tracker {
+	return &Tracker{store: store, now: time.Now}
+}
+
+var (
+	sharedOnce    sync.Once
+	sharedTracker *Tracker
+)
+
+// Shared returns the tracker of the process, keeping samples in the
+// database at DATABASE_URL or in memory when it is unavailable, or nil
+// unless QLP_ENABLE_TRENDS is true
+func Shared() *Tracker {
+	sharedOnce.Do(func() {
+		if config.GetEnvOrDefault("QLP_ENABLE_TRENDS", "false") != "true" {
+			return
+		}
+		store, err := NewStoreFromEnv()
+		if err != nil {
+			logger.WithComponent("trends").Warn("Quality trends disabled", zap.Error(err))
+			return
+		}
+		sharedTracker = NewTracker(store)
+	})
+	return sharedTracker
+}
+
+// Record adds a sample to the shared tracker when trends are enabled, taking
+// the tenant from ctx when s has none. Failures are logged, so recording
+// never fails the work it measures.
+func Record(ctx context.Context, s Sample) {
+	t := Shared()
+	if t == nil {
+		return
+	}
+	if s.TenantID == "" {
+		s.TenantID = audit.TenantFromContext(ctx)
+	}
+	if err := t.Record(ctx, s); err != nil {
+		logger.WithComponent("trends").Warn("Failed to record quality sample",
+			zap.String("metric", string(s.Metric)),
+			zap.String("tenant_id", s.TenantID),
+			zap.Error(err))
+	}
+}
+
+// Record stores a sample, timestamped now unless it carries a time
+func (t *Tracker) Record(ctx context.Context, s Sample) error {
+	if s.TenantID == "" {
+		s.TenantID = defaultTenant
+	}
+	if s.At.IsZero() {
+		s.At = t.now()
+	}
+	s.At = s.At.UTC()
+	return t.store.Add(ctx, s)
+}
+
+// Series returns one series per metric selected by q, per tenant or project
+// when grouped, in report order
+func (t *Tracker) Series(ctx context.Context, q Query) ([]Series, error) {
+	q = t.withDefaults(q)
+	samples, err := t.store.List(ctx, q.TenantID, q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+
+	// A capsule's project is that of the samples recorded with both
+	projects := make(map[string]string)
+	for _, s := range samples {
+		if s.Project != "" && s.CapsuleID != "" {
+			projects[s.CapsuleID] = s.Project
+		}
+	}
+
+	type key struct {
+		metric          Metric
+		tenant, project string
+	}
+	grouped := make(map[key][]Sample)
+	wanted := make(map[Metric]bool, len(q.Metrics))
+	for _, m := range q.Metrics {
+		wanted[m] = true
+	}
+	for _, s := range samples {
+		if s.Project == "" {
+			s.Project = projects[s.CapsuleID]
+		}
+		if !wanted[s.Metric] || (q.Project != "" && s.Project != q.Project) {
+			continue
+		}
+		k := key{metric: s.Metric}
+		switch q.GroupBy {
+		case GroupTenant:
+			k.tenant = s.TenantID
+		case GroupProject:
+			k.project = s.Project
+		}
+		grouped[k] = append(grouped[k], s)
+	}
+
+	order := make(map[Metric]int, len(Metrics))
+	for i, m := range Metrics {
+		order[m] = i
+	}
+	keys := make([]key, 0, len(grouped))
+	for k := range grouped {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.metric != b.metric {
+			return order[a.metric] < order[b.metric]
+		}
+		if a.tenant != b.tenant {
+			return a.tenant < b.tenant
+		}
+		return a.project < b.project
+	})
+
+	series := make([]Series, 0, len(keys))
+	for _, k := range keys {
+		series = append(series, Series{Metric: k.metric, TenantID: k.tenant, Project: k.project, Points: bucket(grouped[k], q.Interval)})
+	}
+	return series, nil
+}
+
+// withDefaults fills in what q leaves open: every metric, daily intervals
+// and the last 30 days
+func (t *Tracker) withDefaults(q Query) Query {
+	if q.Interval <= 0 {
+		q.Interval = 24 * time.Hour
+	}
+	if q.To.IsZero() {
+		q.To = t.now()
+	}
+	if q.From.IsZero() {
+		q.From = q.To.Add(-30 * 24 * time.Hour)
+	}
+	if len(q.Metrics) == 0 {
+		q.Metrics = Metrics
+	}
+	return q
+}
+
+// bucket aggregates samples into points of interval length, skipping
+// intervals without samples
+func bucket(samples []Sample, interval time.Duration) []Point {
+	byStart := make(map[int64]*Point)
+	sums := make(map[int64]float64)
+	for _, s := range samples {
+		start := s.At.Truncate(interval)
+		k := start.UnixNano()
+		p, ok := byStart[k]
+		if !ok {
+			p = &Point{Start: start, Min: s.Value, Max: s.Value}
+			byStart[k] = p
+		}
+		p.Count++
+		p.Min = min(p.Min, s.Value)
+		p.Max = max(p.Max, s.Value)
+		sums[k] += s.Value
+	}
+
+	points := make([]Point, 0, len(byStart))
+	for k, p := range byStart {
+		p.Mean = sums[k] / float64(p.Count)
+		points = append(points, *p)
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Start.Before(points[j].Start) })
+	return points
+}
+
+// ParseMetrics reads a comma-separated list of metrics
+func ParseMetrics(spec string) ([]Metric, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	known := make(map[Metric]bool, len(Metrics))
+	for _, m := range Metrics {
+		known[m] = true
+	}
+	var metrics []Metric
+	for _, name := range strings.Split(spec, ",") {
+		m := Metric(strings.TrimSpace(name))
+		if !known[m] {
+			return nil, fmt.Errorf("%w: unknown metric %q", ErrInvalidQuery, m)
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
+
+// ParseInterval reads hour, day or week, or a Go duration such as 6h
+func ParseInterval(spec string) (time.Duration, error) {
+	switch spec {
+	case "":
+		return 0, nil
+	case "hour":
+		return time.Hour, nil
+	case "day":
+		return 24 * time.Hour, nil
+	case "week":
+		return 7 * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(spec)
+	if err != nil || d < time.Minute {
+		return 0, fmt.Errorf("%w: interval must be hour, day, week or a duration of at least 1m", ErrInvalidQuery)
+	}
+	return d, nil
+}
+
+// ParseTime reads an RFC 3339 time or a YYYY-MM-DD date
+func ParseTime(spec string) (time.Time, error) {
+	if spec == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, spec); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, spec); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%w: time %q is neither RFC 3339 nor YYYY-MM-DD", ErrInvalidQuery, spec)
+}
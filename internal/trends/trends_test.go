@@ -0,0 +1,105 @@
+package trends
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestTracker(t *testing.T) *Tracker {
+	t.Helper()
+	tr := NewTracker(NewMemoryStore())
+	tr.now = func() time.Time { return time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 9, 0, 0, 0, time.UTC) }
+	for _, s := range []Sample{
+		{Metric: ValidationScore, TenantID: "acme", Project: "shop", CapsuleID: "C1", Value: 70, At: day(2)},
+		{Metric: ValidationScore, TenantID: "acme", Project: "shop", CapsuleID: "C2", Value: 80, At: day(2)},
+		{Metric: ValidationScore, TenantID: "acme", Project: "shop", CapsuleID: "C3", Value: 90, At: day(3)},
+		{Metric: ValidationScore, TenantID: "acme", Project: "blog", CapsuleID: "C4", Value: 50, At: day(3)},
+		{Metric: ValidationScore, TenantID: "globex", Value: 60, At: day(3)},
+		{Metric: DeploymentSuccess, TenantID: "acme", CapsuleID: "C3", Value: 1, At: day(4)},
+		{Metric: DeploymentSuccess, TenantID: "acme", CapsuleID: "C4", Value: 0, At: day(4)},
+		{Metric: AutoApproval, TenantID: "acme", Project: "shop", CapsuleID: "C3", Value: 1, At: day(3)},
+		{Metric: AutoApproval, TenantID: "acme", Project: "shop", CapsuleID: "C3", Value: 0, At: day(3)},
+		{Metric: ValidationScore, TenantID: "acme", Project: "shop", Value: 10, At: day(2).AddDate(0, -2, 0)}, // Outside the default window
+	} {
+		if err := tr.Record(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tr
+}
+
+func TestSeriesPerProject(t *testing.T) {
+	tr := newTestTracker(t)
+	series, err := tr.Series(context.Background(), Query{TenantID: "acme", Project: "shop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 3 || series[0].Metric != ValidationScore || series[1].Metric != AutoApproval || series[2].Metric != DeploymentSuccess {
+		t.Fatalf("series = %+v", series)
+	}
+
+	scores := series[0].Points
+	if len(scores) != 2 || scores[0].Count != 2 || scores[0].Mean != 75 || scores[0].Min != 70 || scores[0].Max != 80 || scores[1].Mean != 90 {
+		t.Errorf("score points = %+v", scores)
+	}
+	if rate := series[1].Points; len(rate) != 1 || rate[0].Mean != 0.5 {
+		t.Errorf("auto-approval points = %+v", rate)
+	}
+	// Deployments carry no project; their capsule ties them to shop
+	if deploys := series[2].Points; len(deploys) != 1 || deploys[0].Count != 1 || deploys[0].Mean != 1 {
+		t.Errorf("deployment points = %+v", deploys)
+	}
+}
+
+func TestSeriesGroupedByTenant(t *testing.T) {
+	tr := newTestTracker(t)
+	series, err := tr.Series(context.Background(), Query{Metrics: []Metric{ValidationScore}, GroupBy: GroupTenant, Interval: 7 * 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 2 || series[0].TenantID != "acme" || series[1].TenantID != "globex" {
+		t.Fatalf("series = %+v", series)
+	}
+	if points := series[0].Points; len(points) != 1 || points[0].Count != 4 || points[0].Mean != 72.5 {
+		t.Errorf("acme points = %+v", points)
+	}
+}
+
+func TestTrendsHandler(t *testing.T) {
+	tr := newTestTracker(t)
+	mux := http.NewServeMux()
+	for pattern, h := range Routes(tr) {
+		mux.Handle(pattern, h)
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tenants/acme/trends?metric=validation_score&group_by=project&from=2026-03-01", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		TenantID string   `json:"tenant_id"`
+		Interval string   `json:"interval"`
+		Series   []Series `json:"series"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.TenantID != "acme" || body.Interval != "24h0m0s" || len(body.Series) != 2 || body.Series[0].Project != "blog" {
+		t.Errorf("body = %+v", body)
+	}
+
+	for _, bad := range []string{"metric=happiness", "interval=fortnight", "group_by=team", "from=yesterday", "from=2026-03-05&to=2026-03-01"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/trends?"+bad, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s = %d, want 400", bad, rec.Code)
+		}
+	}
+}
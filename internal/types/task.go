@@ -49,9 +49,18 @@ type TaskResult struct {
 	ErrorMessage string                 `json:"error_message,omitempty"`
 	Metadata     map[string]interface{} `json:"metadata"`
 	Attachments  map[string][]byte      `json:"attachments,omitempty"`
+	ArtifactRefs map[string]ArtifactRef `json:"artifact_refs,omitempty"` // attachments moved to artifact storage
 	Validation   *ValidationResult      `json:"validation,omitempty"`
 }
 
+// ArtifactRef points at an attachment held in artifact storage instead of
+// inline, so events and results stay small for large capsules
+type ArtifactRef struct {
+	URI       string `json:"uri"`
+	SHA256    string `json:"sha256"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
 // TaskType represents different types of tasks
 type TaskType string
 
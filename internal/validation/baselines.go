@@ -0,0 +1,396 @@
+package validation
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"QLP/internal/audit"
+	"QLP/internal/config"
+)
+
+// Control statuses in a baseline evaluation
+const (
+	ControlPass          = "pass"
+	ControlFail          = "fail"
+	ControlNotApplicable = "not_applicable"
+)
+
+// DefaultBaselines are the profiles checked when neither the run nor the
+// tenant selects any
+var DefaultBaselines = []string{"cis", "nist-800-53", "pci-dss"}
+
+// ErrUnknownBaseline is returned when a run or tenant selects a profile that
+// does not exist
+var ErrUnknownBaseline = errors.New("unknown baseline profile")
+
+// Baseline is a security baseline profile: controls mapped to the checks
+// that give evidence of them. Profiles are JSON files in baselines/.
+type Baseline struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Version  string            `json:"version"`
+	Controls []BaselineControl `json:"controls"`
+}
+
+// BaselineControl is a control of a baseline and the checks it maps to
+type BaselineControl struct {
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Checks      []string `json:"checks"`
+	Remediation string   `json:"remediation"`
+}
+
+// BaselineResult is a profile's column of the compliance matrix
+type BaselineResult struct {
+	Profile  string          `json:"profile"`
+	Name     string          `json:"name"`
+	Version  string          `json:"version"`
+	Score    int             `json:"score"` // Share of the applicable controls that pass
+	Passed   int             `json:"passed"`
+	Failed   int             `json:"failed"`
+	Controls []ControlResult `json:"controls"`
+}
+
+// ControlResult is a control's pass/fail status with the evidence of each
+// check it maps to
+type ControlResult struct {
+	ID          string          `json:"id"`
+	Title       string          `json:"title"`
+	Status      string          `json:"status"`
+	Evidence    []CheckEvidence `json:"evidence"`
+	Remediation string          `json:"remediation,omitempty"`
+}
+
+// CheckEvidence is the outcome of one check against the code
+type CheckEvidence struct {
+	Check    string `json:"check"`
+	Status   string `json:"status"`
+	Line     int    `json:"line,omitempty"`
+	Evidence string `json:"evidence"`
+}
+
+//go:embed baselines/*.json
+var baselineFiles embed.FS
+
+var baselines = mustLoadBaselines()
+
+func mustLoadBaselines() map[string]*Baseline {
+	entries, err := baselineFiles.ReadDir("baselines")
+	if err != nil {
+		panic(err)
+	}
+	loaded := make(map[string]*Baseline, len(entries))
+	for _, entry := range entries {
+		data, err := baselineFiles.ReadFile(path.Join("baselines", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var b Baseline
+		if err := json.Unmarshal(data, &b); err != nil {
+			panic(fmt.Sprintf("baseline %s: %v", entry.Name(), err))
+		}
+		for _, control := range b.Controls {
+			for _, check := range control.Checks {
+				if _, ok := baselineChecks[check]; !ok {
+					panic(fmt.Sprintf("baseline %s: control %s maps to unknown check %q", b.ID, control.ID, check))
+				}
+			}
+		}
+		loaded[b.ID] = &b
+	}
+	return loaded
+}
+
+// Baselines returns the available profiles, ordered by ID
+func Baselines() []*Baseline {
+	list := make([]*Baseline, 0, len(baselines))
+	for _, b := range baselines {
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// LookupBaselines checks that every ID names a profile
+func LookupBaselines(ids []string) error {
+	for _, id := range ids {
+		if _, ok := baselines[id]; !ok {
+			return fmt.Errorf("%w %q", ErrUnknownBaseline, id)
+		}
+	}
+	return nil
+}
+
+// BaselineSelection is which profiles each tenant's validations check
+type BaselineSelection struct {
+	Default   []string
+	PerTenant map[string][]string
+}
+
+// ParseBaselineSelection reads the default profiles, comma-separated, and
+// per-tenant overrides of the form "acme=pci-dss+cis,globex=nist-800-53"
+func ParseBaselineSelection(defaults, overrides string) (*BaselineSelection, error) {
+	s := &BaselineSelection{Default: splitList(defaults, ","), PerTenant: make(map[string][]string)}
+	if len(s.Default) == 0 {
+		s.Default = DefaultBaselines
+	}
+	if err := LookupBaselines(s.Default); err != nil {
+		return nil, err
+	}
+	for _, pair := range splitList(overrides, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid baseline override %q, expected tenant=profile+profile", pair)
+		}
+		profiles := splitList(parts[1], "+")
+		if err := LookupBaselines(profiles); err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", parts[0], err)
+		}
+		s.PerTenant[strings.TrimSpace(parts[0])] = profiles
+	}
+	return s, nil
+}
+
+// BaselineSelectionFromEnv reads QLP_BASELINE_PROFILES and
+// QLP_BASELINE_TENANT_PROFILES
+func BaselineSelectionFromEnv() (*BaselineSelection, error) {
+	return ParseBaselineSelection(
+		config.GetEnvOrDefault("QLP_BASELINE_PROFILES", strings.Join(DefaultBaselines, ",")),
+		config.GetEnvOrDefault("QLP_BASELINE_TENANT_PROFILES", ""))
+}
+
+// For returns the profiles checked for tenantID
+func (s *BaselineSelection) For(tenantID string) []string {
+	if s == nil {
+		return DefaultBaselines
+	}
+	if profiles, ok := s.PerTenant[tenantID]; ok {
+		return profiles
+	}
+	return s.Default
+}
+
+type baselinesKey struct{}
+
+// WithBaselines selects the profiles the validations run with ctx check,
+// overriding the tenant's selection
+func WithBaselines(ctx context.Context, ids []string) context.Context {
+	if len(ids) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, baselinesKey{}, ids)
+}
+
+// selected returns the profiles a run checks: those attached to ctx, else
+// those of the tenant ctx carries
+func (s *BaselineSelection) selected(ctx context.Context) []string {
+	if ids, ok := ctx.Value(baselinesKey{}).([]string); ok {
+		return ids
+	}
+	return s.For(audit.TenantFromContext(ctx))
+}
+
+// EvaluateBaseline checks code against every control of profile id
+func EvaluateBaseline(id, code string) (*BaselineResult, error) {
+	b, ok := baselines[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownBaseline, id)
+	}
+	evidence := make(map[string]CheckEvidence)
+	result := &BaselineResult{Profile: b.ID, Name: b.Name, Version: b.Version, Controls: make([]ControlResult, 0, len(b.Controls))}
+	for _, control := range b.Controls {
+		cr := ControlResult{ID: control.ID, Title: control.Title, Status: ControlNotApplicable}
+		for _, check := range control.Checks {
+			e, ok := evidence[check]
+			if !ok {
+				e = baselineChecks[check].evaluate(code)
+				e.Check = check
+				evidence[check] = e
+			}
+			cr.Evidence = append(cr.Evidence, e)
+			switch {
+			case e.Status == ControlFail:
+				cr.Status = ControlFail
+			case e.Status == ControlPass && cr.Status == ControlNotApplicable:
+				cr.Status = ControlPass
+			}
+		}
+		if cr.Status == ControlFail {
+			cr.Remediation = control.Remediation
+		}
+		result.Controls = append(result.Controls, cr)
+	}
+	result.Tally()
+	return result, nil
+}
+
+// Tally counts the passed and failed controls and scores the profile; a
+// profile none of whose controls apply scores 100
+func (b *BaselineResult) Tally() {
+	b.Passed, b.Failed = 0, 0
+	for _, c := range b.Controls {
+		switch c.Status {
+		case ControlPass:
+			b.Passed++
+		case ControlFail:
+			b.Failed++
+		}
+	}
+	b.Score = 100
+	if applicable := b.Passed + b.Failed; applicable > 0 {
+		b.Score = b.Passed * 100 / applicable
+	}
+}
+
+// baselineCheck looks for evidence of a control in infrastructure code. A
+// violation fails the check; otherwise it passes when no evidence is
+// required or the evidence is found.
+type baselineCheck struct {
+	applies   *regexp.Regexp // nil applies to all code
+	violation *regexp.Regexp
+	evidence  *regexp.Regexp
+	missing   string // Why the check fails without evidence
+	evaluate  func(code string) CheckEvidence
+}
+
+var (
+	kubernetesOrDockerfile = regexp.MustCompile(`(?m)^\s*(FROM\s+\S+|apiVersion:)`)
+	kubernetesWorkload     = regexp.MustCompile(`(?m)^\s*kind:\s*(Deployment|Pod|StatefulSet|DaemonSet|Job|CronJob|ReplicaSet)\s*$`)
+)
+
+var baselineChecks = map[string]*baselineCheck{
+	"encryption_at_rest": {
+		violation: regexp.MustCompile(`(?i)(encrypt(ed|ion)?|storage_encrypted)"?\s*[=:]\s*"?false`),
+		evidence:  regexp.MustCompile(`(?i)(encrypt(ed|ion)?"?\s*[=:]\s*"?true|kms_key|server_side_encryption|storage_encrypted|sse_algorithm|encryption_configuration|disk_encryption)`),
+		missing:   "no encryption at rest configured",
+	},
+	"encryption_in_transit": {
+		violation: regexp.MustCompile(`(?im)(protocol"?\s*[=:]\s*"?http"?\s*$|min_tls_version"?\s*[=:]\s*"?(tls)?1[._][01]"?)`),
+		evidence:  regexp.MustCompile(`(?i)(\btls\b|https|ssl_policy|min_tls_version|certificate_arn|ssl_enforcement)`),
+		missing:   "no TLS configured",
+	},
+	"no_public_ingress": {
+		evaluate: publicIngress,
+	},
+	"network_segmentation": {
+		evidence: regexp.MustCompile(`(?i)(security_group|network_security_group|networkpolicy|network_policy|firewall|subnet)`),
+		missing:  "no security groups, firewalls or network policies",
+	},
+	"access_control": {
+		evidence: regexp.MustCompile(`(?i)(\biam\b|aws_iam|rbac|rolebinding|role_assignment|serviceaccount|service_account|managed_identity)`),
+		missing:  "no IAM roles, RBAC bindings or identities",
+	},
+	"least_privilege": {
+		violation: regexp.MustCompile(`(?i)("?actions?"?\s*[=:]\s*\[?\s*"\*(:\*)?"|cluster-admin|privileged:\s*true|allowprivilegeescalation:\s*true)`),
+	},
+	"audit_logging": {
+		evidence: regexp.MustCompile(`(?i)(cloudtrail|audit|flow_log|access_logs?|logging|diagnostic_setting|log_analytics)`),
+		missing:  "no audit, access or flow logging",
+	},
+	"no_hardcoded_secrets": {
+		violation: regexp.MustCompile(`(?i)(password|passwd|secret|api_key|access_key|token)\w*"?\s*[=:]\s*"[^"$\{\s][^"]{3,}"`),
+	},
+	"non_root": {
+		applies:   kubernetesOrDockerfile,
+		violation: regexp.MustCompile(`(?im)(^\s*USER\s+(root|0)(:\S+)?\s*$|runAsUser:\s*0\s*$|runAsNonRoot:\s*false)`),
+		evidence:  regexp.MustCompile(`(?im)(^\s*USER\s+\S+|runAsNonRoot:\s*true)`),
+		missing:   "containers run as root",
+	},
+	"resource_limits": {
+		applies:  kubernetesWorkload,
+		evidence: regexp.MustCompile(`(?m)^\s*limits:`),
+		missing:  "containers have no resource limits",
+	},
+	"backups": {
+		evidence: regexp.MustCompile(`(?i)(backup|snapshot|versioning|point_in_time_recovery|retention_period|geo_redundant)`),
+		missing:  "no backups, snapshots or versioning",
+	},
+}
+
+func init() {
+	for _, check := range baselineChecks {
+		if check.evaluate == nil {
+			check.evaluate = check.match
+		}
+	}
+}
+
+func (c *baselineCheck) match(code string) CheckEvidence {
+	if c.applies != nil && !c.applies.MatchString(code) {
+		return CheckEvidence{Status: ControlNotApplicable, Evidence: "no matching resources"}
+	}
+	if c.violation != nil {
+		if loc := c.violation.FindStringIndex(code); loc != nil {
+			return evidenceAt(code, loc[0], ControlFail)
+		}
+	}
+	if c.evidence == nil {
+		return CheckEvidence{Status: ControlPass, Evidence: "no violations found"}
+	}
+	if loc := c.evidence.FindStringIndex(code); loc != nil {
+		return evidenceAt(code, loc[0], ControlPass)
+	}
+	return CheckEvidence{Status: ControlFail, Evidence: c.missing}
+}
+
+var (
+	openCIDR      = regexp.MustCompile(`(0\.0\.0\.0/0|::/0)`)
+	ruleBlock     = regexp.MustCompile(`(?i)^\s*"?(ingress|egress)\b`)
+	egressType    = regexp.MustCompile(`(?i)type"?\s*[=:]\s*"?egress`)
+	resourceBlock = regexp.MustCompile(`^\s*resource\s+"`)
+)
+
+// publicIngress fails on open address ranges outside egress rules
+func publicIngress(code string) CheckEvidence {
+	inEgress := false
+	offset := 0
+	for _, line := range strings.Split(code, "\n") {
+		switch m := ruleBlock.FindStringSubmatch(line); {
+		case m != nil:
+			inEgress = strings.EqualFold(m[1], "egress")
+		case egressType.MatchString(line):
+			inEgress = true
+		case resourceBlock.MatchString(line):
+			inEgress = false
+		}
+		if !inEgress && openCIDR.MatchString(line) {
+			return evidenceAt(code, offset, ControlFail)
+		}
+		offset += len(line) + 1
+	}
+	return CheckEvidence{Status: ControlPass, Evidence: "no ingress from 0.0.0.0/0"}
+}
+
+// evidenceAt quotes the line of code at offset
+func evidenceAt(code string, offset int, status string) CheckEvidence {
+	start := strings.LastIndex(code[:offset], "\n") + 1
+	end := strings.IndexByte(code[offset:], '\n')
+	if end < 0 {
+		end = len(code)
+	} else {
+		end += offset
+	}
+	line := strings.TrimSpace(code[start:end])
+	if len(line) > 120 {
+		line = line[:120] + "..."
+	}
+	return CheckEvidence{Status: status, Line: strings.Count(code[:start], "\n") + 1, Evidence: line}
+}
+
+// splitList splits s on sep, dropping blanks
+func splitList(s, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(s, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
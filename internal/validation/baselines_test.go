@@ -0,0 +1,143 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"QLP/internal/audit"
+)
+
+const baselineTerraform = `resource "aws_s3_bucket" "data" {
+  bucket = "data"
+  server_side_encryption_configuration {
+    rule {
+      apply_server_side_encryption_by_default { sse_algorithm = "aws:kms" }
+    }
+  }
+  versioning { enabled = true }
+}
+
+resource "aws_security_group" "web" {
+  ingress {
+    from_port   = 22
+    to_port     = 22
+    cidr_blocks = ["0.0.0.0/0"]
+  }
+  egress {
+    cidr_blocks = ["0.0.0.0/0"]
+  }
+}
+
+resource "aws_db_instance" "db" {
+  password = "hunter22"
+}
+`
+
+func TestBaselineProfilesLoad(t *testing.T) {
+	ids := make([]string, 0)
+	for _, b := range Baselines() {
+		ids = append(ids, b.ID)
+		if b.Name == "" || b.Version == "" || len(b.Controls) == 0 {
+			t.Errorf("profile %s is incomplete: %+v", b.ID, b)
+		}
+	}
+	if got := strings.Join(ids, ","); got != "cis,gdpr,hipaa,nist-800-53,pci-dss,soc2" {
+		t.Errorf("unexpected profiles %s", got)
+	}
+	if err := LookupBaselines([]string{"pci-dss", "iso-27001"}); !errors.Is(err, ErrUnknownBaseline) {
+		t.Errorf("expected an unknown profile error, got %v", err)
+	}
+}
+
+func TestEvaluateBaselineRecordsEvidence(t *testing.T) {
+	result, err := EvaluateBaseline("pci-dss", baselineTerraform)
+	if err != nil {
+		t.Fatal(err)
+	}
+	controls := make(map[string]ControlResult)
+	for _, c := range result.Controls {
+		controls[c.ID] = c
+	}
+
+	ingress := controls["1.3.1"]
+	if ingress.Status != ControlFail || ingress.Evidence[0].Line != 15 || ingress.Remediation == "" {
+		t.Errorf("expected public ingress to fail on line 15 with a remediation: %+v", ingress)
+	}
+	if c := controls["3.5.1"]; c.Status != ControlPass || c.Evidence[0].Line != 3 {
+		t.Errorf("expected encryption at rest to pass on line 3: %+v", c)
+	}
+	if c := controls["8.6.2"]; c.Status != ControlFail || c.Evidence[0].Evidence != `password = "hunter22"` {
+		t.Errorf("expected the hardcoded password as evidence: %+v", c)
+	}
+	if c := controls["2.2.1"]; c.Status != ControlNotApplicable {
+		t.Errorf("expected the container check not to apply to Terraform: %+v", c)
+	}
+	if result.Score != result.Passed*100/(result.Passed+result.Failed) || result.Failed == 0 {
+		t.Errorf("unexpected tally: %+v", result)
+	}
+}
+
+func TestPublicIngressIgnoresEgress(t *testing.T) {
+	code := `resource "aws_security_group" "web" {
+  egress {
+    cidr_blocks = ["0.0.0.0/0"]
+  }
+}`
+	if e := publicIngress(code); e.Status != ControlPass {
+		t.Errorf("expected open egress to pass: %+v", e)
+	}
+}
+
+func TestBaselineSelection(t *testing.T) {
+	if _, err := ParseBaselineSelection("cis", "acme=pci-dss+nope"); !errors.Is(err, ErrUnknownBaseline) {
+		t.Fatalf("expected an unknown profile error, got %v", err)
+	}
+	if _, err := ParseBaselineSelection("cis", "acme"); err == nil {
+		t.Fatal("expected an override without profiles to be rejected")
+	}
+	s, err := ParseBaselineSelection("", "acme=pci-dss+cis, globex=nist-800-53")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(s.For("acme"), ","); got != "pci-dss,cis" {
+		t.Errorf("expected acme's profiles, got %s", got)
+	}
+	if got := strings.Join(s.For("initech"), ","); got != strings.Join(DefaultBaselines, ",") {
+		t.Errorf("expected the defaults for other tenants, got %s", got)
+	}
+
+	ctx := audit.WithTenant(context.Background(), "globex")
+	if got := strings.Join(s.selected(ctx), ","); got != "nist-800-53" {
+		t.Errorf("expected the tenant's profiles, got %s", got)
+	}
+	if got := strings.Join(s.selected(WithBaselines(ctx, []string{"soc2"})), ","); got != "soc2" {
+		t.Errorf("expected the run's profiles to win, got %s", got)
+	}
+}
+
+func TestValidateComplianceUsesSelectedBaselines(t *testing.T) {
+	iv := &InfrastructureValidator{}
+	result := iv.validateCompliance(WithBaselines(context.Background(), []string{"pci-dss", "cis"}), baselineTerraform)
+	if len(result.Baselines) != 2 || result.Baselines[0].Profile != "pci-dss" || result.Baselines[1].Profile != "cis" {
+		t.Fatalf("expected the selected profiles in order, got %+v", result.Baselines)
+	}
+	if result.PolicyCompliance != (result.Baselines[0].Score+result.Baselines[1].Score)/2 {
+		t.Errorf("expected policy compliance to average the profiles, got %d", result.PolicyCompliance)
+	}
+	soc2, _ := EvaluateBaseline("soc2", baselineTerraform)
+	if result.SOC2Compliance != soc2.Score {
+		t.Errorf("expected the SOC 2 score of its profile, got %d", result.SOC2Compliance)
+	}
+
+	var found bool
+	for _, issue := range result.ComplianceIssues {
+		if issue.Framework == "PCI DSS" && issue.Control == "1.3.1" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected a PCI DSS 1.3.1 issue, got %+v", result.ComplianceIssues)
+	}
+}
@@ -0,0 +1,514 @@
+package validation
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/config"
+	"QLP/internal/packaging"
+	"QLP/internal/sandbox"
+)
+
+// ErrInvalidBatch is returned for batch validations that cannot run
+var ErrInvalidBatch = errors.New("invalid batch validation")
+
+// DefaultBatchMinScore is the score every artifact of a batch must reach
+// to pass the quality gate when the request sets none
+const DefaultBatchMinScore = 70
+
+// BatchArtifact is one artifact of a batch validation: a single file or a
+// set of files validated together
+type BatchArtifact struct {
+	ID    string            `json:"id,omitempty"`   // Its path when it is a single file
+	Type  string            `json:"type,omitempty"` // Drop type, or terraform, kubernetes or dockerfile; detected when empty
+	Files map[string]string `json:"files"`
+}
+
+// BatchRequest is the body of POST /validate/batch. Files is a shorthand
+// for one artifact per file.
+type BatchRequest struct {
+	TenantID    string            `json:"tenant_id,omitempty"` // Whose custom rules apply
+	Artifacts   []BatchArtifact   `json:"artifacts,omitempty"`
+	Files       map[string]string `json:"files,omitempty"`
+	MinScore    int               `json:"min_score,omitempty"`    // Every artifact and dependency scan must reach it; DefaultBatchMinScore by default
+	MaxCritical int               `json:"max_critical,omitempty"` // Critical findings tolerated across the batch
+	Baselines   []string          `json:"baselines,omitempty"`    // Compliance profiles for infrastructure artifacts; the tenant's by default
+}
+
+// BatchArtifactResult is the validation of one artifact
+type BatchArtifactResult struct {
+	ID             string                  `json:"id"`
+	Kind           string                  `json:"kind"` // static or infrastructure
+	Project        string                  `json:"project,omitempty"`
+	Score          int                     `json:"score"`
+	Critical       int                     `json:"critical"`
+	Passed         bool                    `json:"passed"`
+	SameAs         string                  `json:"same_as,omitempty"` // An identical artifact whose validation was reused
+	Error          string                  `json:"error,omitempty"`
+	Static         *StaticValidationResult `json:"static,omitempty"`
+	Infrastructure *InfraValidationResult  `json:"infrastructure,omitempty"`
+	Duration       time.Duration           `json:"duration"`
+}
+
+// DependencyScan is the scan of the dependency manifest of one project,
+// shared by every artifact in the project
+type DependencyScan struct {
+	Project   string          `json:"project"` // Directory of the manifest
+	Ecosystem string          `json:"ecosystem"`
+	Manifest  string          `json:"manifest"`
+	Findings  []PluginFinding `json:"findings"`
+	Score     int             `json:"score"`
+	Passed    bool            `json:"passed"`
+}
+
+// QualityGate is the combined outcome of a batch
+type QualityGate struct {
+	Passed      bool     `json:"passed"`
+	MinScore    int      `json:"min_score"`
+	MaxCritical int      `json:"max_critical"`
+	Critical    int      `json:"critical"`
+	Failures    []string `json:"failures,omitempty"`
+}
+
+// BatchResult is the aggregate report of a batch validation
+type BatchResult struct {
+	OverallScore int                   `json:"overall_score"` // Mean of the artifact and dependency scores
+	Artifacts    []BatchArtifactResult `json:"artifacts"`
+	Dependencies []DependencyScan      `json:"dependencies"`
+	Gate         QualityGate           `json:"gate"`
+	Validations  int                   `json:"validations"` // Validations run after deduplicating identical artifacts
+	Duration     time.Duration         `json:"duration"`
+}
+
+// BatchValidator validates many artifacts at once, at most maxConcurrent at
+// a time. Identical artifacts are validated once, and each project's
+// dependency manifest is scanned once for all its artifacts.
+type BatchValidator struct {
+	static        *StaticValidator
+	infra         *InfrastructureValidator
+	scanner       *SecurityScanner
+	maxConcurrent int
+	maxArtifacts  int
+}
+
+// NewBatchValidator validates at most maxConcurrent artifacts at a time
+func NewBatchValidator(static *StaticValidator, infra *InfrastructureValidator, maxConcurrent, maxArtifacts int) *BatchValidator {
+	return &BatchValidator{
+		static:        static,
+		infra:         infra,
+		scanner:       NewSecurityScanner(),
+		maxConcurrent: max(maxConcurrent, 1),
+		maxArtifacts:  maxArtifacts,
+	}
+}
+
+// NewBatchValidatorFromEnv bounds batches by QLP_VALIDATION_MAX_CONCURRENT
+// and QLP_VALIDATION_BATCH_MAX_ARTIFACTS
+func NewBatchValidatorFromEnv(static *StaticValidator, infra *InfrastructureValidator) *BatchValidator {
+	maxConcurrent, err := strconv.Atoi(config.GetEnvOrDefault("QLP_VALIDATION_MAX_CONCURRENT", "4"))
+	if err != nil {
+		maxConcurrent = 4
+	}
+	maxArtifacts, err := strconv.Atoi(config.GetEnvOrDefault("QLP_VALIDATION_BATCH_MAX_ARTIFACTS", "200"))
+	if err != nil {
+		maxArtifacts = 200
+	}
+	return NewBatchValidator(static, infra, maxConcurrent, maxArtifacts)
+}
+
+// Validate validates the artifacts of req concurrently and gates the batch
+// on their scores, its dependency scans and its critical findings
+func (bv *BatchValidator) Validate(ctx context.Context, req BatchRequest) (*BatchResult, error) {
+	start := time.Now()
+	artifacts, err := req.artifacts()
+	if err != nil {
+		return nil, err
+	}
+	if bv.maxArtifacts > 0 && len(artifacts) > bv.maxArtifacts {
+		return nil, fmt.Errorf("%w: %d artifacts exceeds the limit of %d", ErrInvalidBatch, len(artifacts), bv.maxArtifacts)
+	}
+	if err := LookupBaselines(req.Baselines); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBatch, err)
+	}
+	if req.TenantID != "" {
+		ctx = audit.WithTenant(ctx, req.TenantID)
+	}
+	ctx = WithBaselines(ctx, req.Baselines)
+	gate := QualityGate{MinScore: req.MinScore, MaxCritical: req.MaxCritical}
+	if gate.MinScore <= 0 {
+		gate.MinScore = DefaultBatchMinScore
+	}
+
+	// Every project is scanned once, whichever artifacts it holds
+	all := make(map[string]string)
+	for _, a := range artifacts {
+		for p, content := range a.Files {
+			all[p] = content
+		}
+	}
+	projects := sandbox.DetectDependencyProjects(all)
+	scans := make([]DependencyScan, len(projects))
+	for i, project := range projects {
+		scans[i] = bv.scanDependencies(project, all)
+		scans[i].Passed = scans[i].Score >= gate.MinScore
+	}
+
+	// Identical artifacts share one validation
+	results := make([]BatchArtifactResult, len(artifacts))
+	first := make(map[string]int)
+	var unique []int
+	for i, a := range artifacts {
+		key := a.key()
+		if j, ok := first[key]; ok {
+			results[i].SameAs = artifacts[j].ID
+			continue
+		}
+		first[key] = i
+		unique = append(unique, i)
+	}
+
+	slots := make(chan struct{}, bv.maxConcurrent)
+	var wg sync.WaitGroup
+	for _, i := range unique {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				results[i] = BatchArtifactResult{Error: ctx.Err().Error()}
+				return
+			}
+			defer func() { <-slots }()
+			results[i] = bv.validateArtifact(ctx, artifacts[i])
+		}(i)
+	}
+	wg.Wait()
+
+	result := &BatchResult{Artifacts: results, Dependencies: scans, Validations: len(unique)}
+	total, count := 0, 0
+	for i := range results {
+		r := &results[i]
+		if r.SameAs != "" {
+			sameAs := r.SameAs
+			*r = results[first[artifacts[i].key()]]
+			r.SameAs = sameAs
+			r.Duration = 0
+		}
+		r.ID = artifacts[i].ID
+		r.Project = projectOf(projects, artifacts[i])
+		r.Passed = r.Error == "" && r.Score >= gate.MinScore
+		switch {
+		case r.Error != "":
+			gate.Failures = append(gate.Failures, fmt.Sprintf("%s failed: %s", r.ID, r.Error))
+		case !r.Passed:
+			gate.Failures = append(gate.Failures, fmt.Sprintf("%s scored %d", r.ID, r.Score))
+		}
+		gate.Critical += r.Critical
+		total += r.Score
+		count++
+	}
+	for _, scan := range scans {
+		for _, f := range scan.Findings {
+			if strings.EqualFold(f.Severity, "critical") {
+				gate.Critical++
+			}
+		}
+		if !scan.Passed {
+			gate.Failures = append(gate.Failures, fmt.Sprintf("dependencies of %s scored %d", scan.Manifest, scan.Score))
+		}
+		total += scan.Score
+		count++
+	}
+	if gate.Critical > gate.MaxCritical {
+		gate.Failures = append(gate.Failures, fmt.Sprintf("%d critical findings (at most %d allowed)", gate.Critical, gate.MaxCritical))
+	}
+	if count > 0 {
+		result.OverallScore = total / count
+	}
+	gate.Passed = len(gate.Failures) == 0
+	result.Gate = gate
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+// artifacts returns the artifacts of a request, with IDs
+func (req BatchRequest) artifacts() ([]BatchArtifact, error) {
+	artifacts := append([]BatchArtifact(nil), req.Artifacts...)
+	paths := make([]string, 0, len(req.Files))
+	for p := range req.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		artifacts = append(artifacts, BatchArtifact{ID: p, Files: map[string]string{p: req.Files[p]}})
+	}
+	if len(artifacts) == 0 {
+		return nil, fmt.Errorf("%w: no artifacts", ErrInvalidBatch)
+	}
+	seen := make(map[string]bool)
+	for i := range artifacts {
+		a := &artifacts[i]
+		if len(a.Files) == 0 {
+			return nil, fmt.Errorf("%w: artifact %d has no files", ErrInvalidBatch, i)
+		}
+		if a.ID == "" {
+			if len(a.Files) > 1 {
+				return nil, fmt.Errorf("%w: artifact %d of several files needs an id", ErrInvalidBatch, i)
+			}
+			for p := range a.Files {
+				a.ID = p
+			}
+		}
+		if seen[a.ID] {
+			return nil, fmt.Errorf("%w: duplicate artifact %q", ErrInvalidBatch, a.ID)
+		}
+		seen[a.ID] = true
+	}
+	return artifacts, nil
+}
+
+// key identifies an artifact's content and type
+func (a BatchArtifact) key() string {
+	paths := make([]string, 0, len(a.Files))
+	for p := range a.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00", a.Type)
+	for _, p := range paths {
+		// Identical content under another path validates the same
+		if len(paths) > 1 {
+			fmt.Fprintf(h, "%s\x00", p)
+		}
+		fmt.Fprintf(h, "%d\x00%s", len(a.Files[p]), a.Files[p])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// infraType returns the infrastructure type of a single-file artifact, or
+// "" for code
+func (a BatchArtifact) infraType() string {
+	switch strings.ToLower(a.Type) {
+	case "terraform", "tf", "kubernetes", "k8s", "docker", "dockerfile":
+		return a.Type
+	case "":
+	default:
+		return ""
+	}
+	if len(a.Files) != 1 {
+		return ""
+	}
+	for p, content := range a.Files {
+		base := strings.ToLower(path.Base(p))
+		switch {
+		case strings.HasSuffix(base, ".tf"):
+			return "terraform"
+		case base == "dockerfile" || strings.HasPrefix(base, "dockerfile.") || strings.HasSuffix(base, ".dockerfile"):
+			return "dockerfile"
+		case (strings.HasSuffix(base, ".yaml") || strings.HasSuffix(base, ".yml")) &&
+			strings.Contains(content, "apiVersion:") && strings.Contains(content, "kind:"):
+			return "kubernetes"
+		}
+	}
+	return ""
+}
+
+func (bv *BatchValidator) validateArtifact(ctx context.Context, a BatchArtifact) BatchArtifactResult {
+	start := time.Now()
+	var r BatchArtifactResult
+	if infraType := a.infraType(); infraType != "" {
+		r.Kind = "infrastructure"
+		paths := make([]string, 0, len(a.Files))
+		for p := range a.Files {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		contents := make([]string, len(paths))
+		for i, p := range paths {
+			contents[i] = a.Files[p]
+		}
+		code := strings.Join(contents, "\n")
+		result, err := bv.infra.ValidateInfrastructure(ctx, code, infraType)
+		if err != nil {
+			r.Error = err.Error()
+		} else {
+			r.Infrastructure = result
+			r.Score = result.OverallScore
+			for _, issue := range result.CriticalIssues {
+				if strings.EqualFold(issue.Severity, "critical") {
+					r.Critical++
+				}
+			}
+		}
+	} else {
+		r.Kind = "static"
+		dropType := packaging.DropType(a.Type)
+		if a.Type == "" {
+			dropType = packaging.DropTypeCodebase
+		}
+		result, err := bv.static.ValidateQuantumDrop(ctx, &packaging.QuantumDrop{
+			ID:        a.ID,
+			Type:      dropType,
+			Name:      a.ID,
+			Files:     a.Files,
+			Status:    packaging.DropStatusReady,
+			CreatedAt: time.Now(),
+			Metadata:  packaging.DropMetadata{FileCount: len(a.Files)},
+		})
+		if err != nil {
+			r.Error = err.Error()
+		} else {
+			r.Static = result
+			r.Score = result.OverallScore
+			for _, issue := range result.Issues {
+				if strings.EqualFold(issue.Severity, "critical") {
+					r.Critical++
+				}
+			}
+		}
+	}
+	r.Duration = time.Since(start)
+	return r
+}
+
+// projectOf returns the directory of the innermost project holding all of
+// an artifact's files, or ""
+func projectOf(projects []sandbox.DependencyProject, a BatchArtifact) string {
+	best := ""
+	for _, project := range projects {
+		within := true
+		for p := range a.Files {
+			if project.Dir != "." && !strings.HasPrefix(p, project.Dir+"/") {
+				within = false
+				break
+			}
+		}
+		if within && len(project.Dir) >= len(best) {
+			best = project.Dir
+		}
+	}
+	return best
+}
+
+// manifestNames are the manifests scanned for each ecosystem
+var manifestNames = map[sandbox.Ecosystem][]string{
+	sandbox.EcosystemGo:     {"go.mod"},
+	sandbox.EcosystemNode:   {"package.json"},
+	sandbox.EcosystemPython: {"requirements.txt", "requirements.in"},
+	sandbox.EcosystemMaven:  {"pom.xml"},
+	sandbox.EcosystemGradle: {"build.gradle", "build.gradle.kts"},
+}
+
+// scanDependencies checks a project's manifest for dependencies with known
+// CVEs and for versions that are not pinned
+func (bv *BatchValidator) scanDependencies(project sandbox.DependencyProject, files map[string]string) DependencyScan {
+	scan := DependencyScan{Project: project.Dir, Ecosystem: string(project.Ecosystem), Findings: make([]PluginFinding, 0)}
+	var content string
+	for _, name := range manifestNames[project.Ecosystem] {
+		p := path.Join(project.Dir, name)
+		if c, ok := files[p]; ok {
+			scan.Manifest, content = p, c
+			break
+		}
+	}
+
+	for _, issue := range bv.scanner.checkCVEDatabase(content) {
+		scan.Findings = append(scan.Findings, PluginFinding{
+			Rule:        "known-vulnerability",
+			Severity:    strings.ToLower(issue.Severity),
+			Message:     issue.Description,
+			Location:    scan.Manifest,
+			Remediation: "upgrade to a fixed version",
+		})
+	}
+	for name, version := range manifestDependencies(project.Ecosystem, scan.Manifest, content) {
+		if severity := unpinnedSeverity(project.Ecosystem, version); severity != "" {
+			scan.Findings = append(scan.Findings, PluginFinding{
+				Rule:        "unpinned-dependency",
+				Severity:    severity,
+				Message:     fmt.Sprintf("%s is not pinned to a version (%q)", name, version),
+				Location:    scan.Manifest,
+				Remediation: "pin an exact version and commit the lockfile",
+			})
+		}
+	}
+	sort.SliceStable(scan.Findings, func(i, j int) bool { return scan.Findings[i].Message < scan.Findings[j].Message })
+	scan.Score = FindingsScore(scan.Findings)
+	return scan
+}
+
+// manifestDependencies returns the dependencies of a manifest and their
+// version constraints
+func manifestDependencies(ecosystem sandbox.Ecosystem, manifest, content string) map[string]string {
+	deps := make(map[string]string)
+	switch ecosystem {
+	case sandbox.EcosystemNode:
+		var pkg struct {
+			Dependencies    map[string]string `json:"dependencies"`
+			DevDependencies map[string]string `json:"devDependencies"`
+		}
+		if json.Unmarshal([]byte(content), &pkg) == nil {
+			for name, v := range pkg.DevDependencies {
+				deps[name] = v
+			}
+			for name, v := range pkg.Dependencies {
+				deps[name] = v
+			}
+		}
+	case sandbox.EcosystemPython:
+		scanner := bufio.NewScanner(strings.NewReader(content))
+		for scanner.Scan() {
+			line := strings.TrimSpace(strings.SplitN(scanner.Text(), "#", 2)[0])
+			if line == "" || strings.HasPrefix(line, "-") {
+				continue
+			}
+			name, version := line, ""
+			if i := strings.IndexAny(line, "=<>~!"); i > 0 {
+				name, version = strings.TrimSpace(line[:i]), strings.TrimSpace(line[i:])
+			}
+			deps[name] = version
+		}
+	case sandbox.EcosystemGo:
+		// go.mod versions are always exact
+	}
+	return deps
+}
+
+// unpinnedSeverity rates a version constraint that lets the resolved
+// version change between builds, or returns "" for an exact one
+func unpinnedSeverity(ecosystem sandbox.Ecosystem, version string) string {
+	v := strings.TrimSpace(version)
+	switch ecosystem {
+	case sandbox.EcosystemNode:
+		switch {
+		case v == "" || v == "*" || v == "latest" || v == "x":
+			return "high"
+		case strings.HasPrefix(v, "git") || strings.HasPrefix(v, "http"):
+			return "medium"
+		case strings.ContainsAny(v, "^~<>| "):
+			return "low"
+		}
+	case sandbox.EcosystemPython:
+		switch {
+		case v == "":
+			return "medium"
+		case !strings.HasPrefix(v, "=="):
+			return "low"
+		}
+	}
+	return ""
+}
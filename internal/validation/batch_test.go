@@ -0,0 +1,123 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowLLM fails every completion after a pause, recording how many ran at
+// once
+type slowLLM struct {
+	mu      sync.Mutex
+	running int
+	peak    int
+	calls   int
+}
+
+func (l *slowLLM) Complete(ctx context.Context, prompt string) (string, error) {
+	l.mu.Lock()
+	l.running++
+	l.calls++
+	l.peak = max(l.peak, l.running)
+	l.mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	l.mu.Lock()
+	l.running--
+	l.mu.Unlock()
+	return "", errors.New("no model")
+}
+
+func (l *slowLLM) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	return nil, errors.New("no model")
+}
+
+func TestBatchValidator(t *testing.T) {
+	llm := &slowLLM{}
+	static := NewStaticValidator(llm)
+	static.SetPlugins(nil)
+	bv := NewBatchValidator(static, &InfrastructureValidator{}, 2, 0)
+
+	handler := "package main\n\nfunc main() {}\n"
+	result, err := bv.Validate(context.Background(), BatchRequest{
+		Files: map[string]string{
+			"orders/go.mod":       "module orders\n\nrequire google.golang.org/grpc v1.56.0\n",
+			"orders/main.go":      handler,
+			"orders/copy.go":      handler,
+			"web/package.json":    `{"dependencies":{"express":"*","lodash":"4.17.21"}}`,
+			"web/index.js":        "console.log('hi')\n",
+			"orders/Dockerfile":   "FROM golang:latest\nADD . /app\n",
+			"web/requirements.in": "",
+		},
+		MinScore: 40,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Artifacts) != 7 || result.Validations != 6 {
+		t.Fatalf("%d artifacts in %d validations, want 7 in 6", len(result.Artifacts), result.Validations)
+	}
+	if llm.peak > 2 {
+		t.Errorf("%d validations ran at once, want at most 2", llm.peak)
+	}
+	// The five code artifacts validated make three LLM calls each
+	if llm.calls != 15 {
+		t.Errorf("%d LLM calls, want 15", llm.calls)
+	}
+
+	byID := make(map[string]BatchArtifactResult)
+	for _, a := range result.Artifacts {
+		byID[a.ID] = a
+	}
+	if a := byID["orders/main.go"]; a.SameAs != "orders/copy.go" || a.Score != byID["orders/copy.go"].Score || a.Project != "orders" {
+		t.Errorf("duplicate artifact = %+v", a)
+	}
+	if a := byID["orders/Dockerfile"]; a.Kind != "infrastructure" || a.Infrastructure == nil || a.Infrastructure.DockerfileResult == nil {
+		t.Errorf("Dockerfile artifact = %+v", a)
+	}
+
+	// One scan per project: go.mod, package.json, and the python project
+	if len(result.Dependencies) != 3 {
+		t.Fatalf("dependency scans = %+v", result.Dependencies)
+	}
+	for _, scan := range result.Dependencies {
+		switch scan.Manifest {
+		case "orders/go.mod":
+			if len(scan.Findings) != 1 || scan.Findings[0].Rule != "known-vulnerability" {
+				t.Errorf("go.mod findings = %+v", scan.Findings)
+			}
+		case "web/package.json":
+			if len(scan.Findings) != 1 || !strings.Contains(scan.Findings[0].Message, "express") || scan.Findings[0].Severity != "high" {
+				t.Errorf("package.json findings = %+v", scan.Findings)
+			}
+		}
+	}
+	if !result.Gate.Passed || result.OverallScore < 40 {
+		t.Errorf("gate = %+v, overall %d", result.Gate, result.OverallScore)
+	}
+
+	// A stricter gate fails on the artifacts below it
+	result, _ = bv.Validate(context.Background(), BatchRequest{Files: map[string]string{"main.go": handler}, MinScore: 95})
+	if result.Gate.Passed || len(result.Gate.Failures) != 1 || result.Artifacts[0].Passed {
+		t.Errorf("strict gate = %+v", result.Gate)
+	}
+
+	if _, err := bv.Validate(context.Background(), BatchRequest{Artifacts: []BatchArtifact{{Files: map[string]string{"a.go": "", "b.go": ""}}}}); !errors.Is(err, ErrInvalidBatch) {
+		t.Errorf("expected an artifact of several files without an id to be rejected, got %v", err)
+	}
+}
+
+func TestBatchHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	batchHandler(NewBatchValidator(NewStaticValidator(&slowLLM{}), &InfrastructureValidator{}, 1, 1)).ServeHTTP(rec,
+		httptest.NewRequest(http.MethodPost, "/validate/batch", strings.NewReader(`{"files":{"a.go":"","b.go":"x"}}`)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "exceeds the limit") {
+		t.Errorf("status %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,79 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+
+	"QLP/internal/llm"
+	"QLP/internal/types"
+)
+
+// promptOverheadTokens is roughly what the validation prompts take up
+// besides the code they embed
+const promptOverheadTokens = 1200
+
+// scoreInChunks scores input with assess, chunk by chunk when it does not
+// fit the context window of client, and merges the scores weighted by the
+// size of their chunk
+func scoreInChunks(ctx context.Context, client llm.Client, input string, assess func(ctx context.Context, chunk string) (int, error)) (int, error) {
+	chunks := llm.NewBudgeter(client).Split(ctx, input, promptOverheadTokens)
+	if len(chunks) == 1 {
+		return assess(ctx, input)
+	}
+	scores := make([]int, len(chunks))
+	weights := make([]int, len(chunks))
+	for i, chunk := range chunks {
+		score, err := assess(ctx, chunk)
+		if err != nil {
+			return 0, fmt.Errorf("part %d of %d: %w", i+1, len(chunks), err)
+		}
+		scores[i], weights[i] = score, llm.EstimateTokens(chunk)
+	}
+	return weightedScore(scores, weights), nil
+}
+
+// weightedScore averages scores by weight, rounding to the nearest point
+func weightedScore(scores, weights []int) int {
+	total, sum := 0, 0
+	for i, score := range scores {
+		total += score * weights[i]
+		sum += weights[i]
+	}
+	if sum == 0 {
+		return 0
+	}
+	return (total + sum/2) / sum
+}
+
+// mergeSecurityFindings concatenates the findings of each chunk in order,
+// dropping repeats of the same finding at the same location
+func mergeSecurityFindings(lists [][]types.SecurityFinding) []types.SecurityFinding {
+	seen := make(map[string]bool)
+	merged := make([]types.SecurityFinding, 0)
+	for _, findings := range lists {
+		for _, f := range findings {
+			key := f.Type + "\x00" + f.Location + "\x00" + f.Description
+			if !seen[key] {
+				seen[key] = true
+				merged = append(merged, f)
+			}
+		}
+	}
+	return merged
+}
+
+// mergeQualityFindings is mergeSecurityFindings for quality findings
+func mergeQualityFindings(lists [][]QualityFinding) []QualityFinding {
+	seen := make(map[string]bool)
+	merged := make([]QualityFinding, 0)
+	for _, findings := range lists {
+		for _, f := range findings {
+			key := f.Type + "\x00" + f.Location + "\x00" + f.Description
+			if !seen[key] {
+				seen[key] = true
+				merged = append(merged, f)
+			}
+		}
+	}
+	return merged
+}
@@ -0,0 +1,52 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"QLP/internal/llm"
+	"QLP/internal/types"
+)
+
+func TestScoreInChunksWeightsBySize(t *testing.T) {
+	t.Setenv("QLP_CONTEXT_WINDOW_OLLAMA", "5000")
+	client := llm.NewOllamaClient("http://localhost:11434", "llama3")
+
+	// Whole manifests fill each chunk, the last one a third as large
+	manifests := strings.Repeat("---\n"+strings.Repeat("k: v\n", 1200), 2) + "---\n" + strings.Repeat("k: v\n", 400)
+	var sizes []int
+	score, err := scoreInChunks(context.Background(), client, manifests, func(ctx context.Context, chunk string) (int, error) {
+		sizes = append(sizes, len(chunk))
+		if len(sizes) == 3 {
+			return 40, nil
+		}
+		return 90, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 3 || score <= 40 || score >= 90 {
+		t.Fatalf("score = %d over chunks %v", score, sizes)
+	}
+	if want := weightedScore([]int{90, 90, 40}, []int{sizes[0]/4 + 1, sizes[1]/4 + 1, sizes[2]/4 + 1}); score != want {
+		t.Errorf("score = %d, want %d", score, want)
+	}
+
+	_, err = scoreInChunks(context.Background(), client, manifests, func(ctx context.Context, chunk string) (int, error) {
+		return 0, fmt.Errorf("provider down")
+	})
+	if err == nil || !strings.Contains(err.Error(), "part 1 of 3") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestMergeSecurityFindingsDropsRepeats(t *testing.T) {
+	sqli := types.SecurityFinding{Type: "Injection", Location: "db.go:12", Description: "SQL injection"}
+	xss := types.SecurityFinding{Type: "XSS", Location: "web.go:3", Description: "Unescaped output"}
+	merged := mergeSecurityFindings([][]types.SecurityFinding{{sqli}, {xss, sqli}})
+	if len(merged) != 2 || merged[0] != sqli || merged[1] != xss {
+		t.Errorf("merged = %+v", merged)
+	}
+}
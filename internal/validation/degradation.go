@@ -0,0 +1,136 @@
+package validation
+
+import (
+	"math"
+
+	"QLP/internal/packaging"
+)
+
+// Checks a deployment validation runs, in order
+const (
+	CheckBuild            = "build"
+	CheckIntegrationTests = "integration_tests"
+	CheckStartup          = "startup"
+	CheckHealth           = "health_check"
+	CheckLoadTest         = "load_test"
+	CheckSecurityScan     = "security_scan"
+	CheckResilience       = "resilience"
+	CheckPerformance      = "performance_monitoring"
+)
+
+// Fallbacks a deployment validation uses when the LLM analysis is unusable
+const (
+	FallbackHeuristicAnalysis = "heuristic_project_analysis" // Language and build commands guessed from file names
+	FallbackLegacyBuild       = "legacy_build"               // Build detected from marker files without an analysis
+)
+
+// checkWeights is how much of the confidence in a deployment validation
+// each check carries. They add up to 1.
+var checkWeights = map[string]float64{
+	CheckBuild:            0.25,
+	CheckIntegrationTests: 0.15,
+	CheckStartup:          0.15,
+	CheckHealth:           0.1,
+	CheckLoadTest:         0.1,
+	CheckSecurityScan:     0.15,
+	CheckResilience:       0.05,
+	CheckPerformance:      0.05,
+}
+
+// fallbackWeights is the confidence each fallback costs
+var fallbackWeights = map[string]float64{
+	FallbackHeuristicAnalysis: 0.1,
+	FallbackLegacyBuild:       0.05,
+}
+
+// SkippedCheck is a check a deployment validation did not run
+type SkippedCheck struct {
+	Check  string `json:"check"`
+	Reason string `json:"reason"`
+}
+
+// Degradation reports how much of a deployment validation ran: the checks
+// it skipped, the fallbacks it used and the errors it recovered from.
+// Confidence is the share of the validation that can be trusted, from 0 to 1.
+type Degradation struct {
+	SkippedChecks       []SkippedCheck     `json:"skipped_checks,omitempty"`
+	Fallbacks           []string           `json:"fallbacks,omitempty"`
+	Errors              []*ValidationError `json:"errors,omitempty"`
+	Confidence          float64            `json:"confidence"`
+	ConfidenceDowngrade float64            `json:"confidence_downgrade"`
+}
+
+// Skip records checks that did not run and why. A check is recorded once.
+func (ea *ErrorAggregator) Skip(reason string, checks ...string) {
+	for _, check := range checks {
+		if !ea.skippedCheck(check) {
+			ea.skipped = append(ea.skipped, SkippedCheck{Check: check, Reason: reason})
+		}
+	}
+}
+
+// Fallback records a fallback used in place of the LLM analysis
+func (ea *ErrorAggregator) Fallback(name string) {
+	for _, f := range ea.fallbacks {
+		if f == name {
+			return
+		}
+	}
+	ea.fallbacks = append(ea.fallbacks, name)
+}
+
+func (ea *ErrorAggregator) skippedCheck(check string) bool {
+	for _, s := range ea.skipped {
+		if s.Check == check {
+			return true
+		}
+	}
+	return false
+}
+
+// Degradation summarizes what the aggregator collected, nil when the
+// validation ran in full
+func (ea *ErrorAggregator) Degradation() *Degradation {
+	if len(ea.errors) == 0 && len(ea.skipped) == 0 && len(ea.fallbacks) == 0 {
+		return nil
+	}
+	d := &Degradation{
+		SkippedChecks: ea.skipped,
+		Fallbacks:     ea.fallbacks,
+		Errors:        ea.errors,
+	}
+	for _, s := range ea.skipped {
+		d.ConfidenceDowngrade += checkWeights[s.Check]
+	}
+	for _, f := range ea.fallbacks {
+		d.ConfidenceDowngrade += fallbackWeights[f]
+	}
+	d.ConfidenceDowngrade = math.Round(math.Min(d.ConfidenceDowngrade, 1)*100) / 100
+	d.Confidence = math.Round((1-d.ConfidenceDowngrade)*100) / 100
+	return d
+}
+
+// Skipped reports whether check did not run and why
+func (d *Degradation) Skipped(check string) (string, bool) {
+	if d == nil {
+		return "", false
+	}
+	for _, s := range d.SkippedChecks {
+		if s.Check == check {
+			return s.Reason, true
+		}
+	}
+	return "", false
+}
+
+// Annotate records the degradation in the capsule's metadata, under
+// "degradation", when the validation did not run in full
+func (d *Degradation) Annotate(capsule *packaging.QLCapsule) {
+	if d == nil || capsule == nil {
+		return
+	}
+	if capsule.Metadata.Environment == nil {
+		capsule.Metadata.Environment = make(map[string]interface{})
+	}
+	capsule.Metadata.Environment["degradation"] = d
+}
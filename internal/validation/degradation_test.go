@@ -0,0 +1,103 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"QLP/internal/packaging"
+	"QLP/internal/types"
+)
+
+type failingLLM struct{}
+
+func (failingLLM) Complete(context.Context, string) (string, error) {
+	return "", errors.New("provider unavailable")
+}
+
+func (failingLLM) GenerateEmbedding(context.Context, string) ([]float32, error) {
+	return nil, errors.New("provider unavailable")
+}
+
+func TestDegradationNilWhenValidationRanInFull(t *testing.T) {
+	if d := NewErrorAggregator().Degradation(); d != nil {
+		t.Fatalf("expected no degradation, got %+v", d)
+	}
+}
+
+func TestDegradationConfidence(t *testing.T) {
+	agg := NewErrorAggregator()
+	agg.Skip("service is unhealthy", CheckLoadTest, CheckResilience)
+	agg.Skip("again", CheckLoadTest)
+	agg.Fallback(FallbackHeuristicAnalysis)
+	agg.Fallback(FallbackHeuristicAnalysis)
+
+	d := agg.Degradation()
+	if len(d.SkippedChecks) != 2 || len(d.Fallbacks) != 1 {
+		t.Fatalf("expected each check and fallback recorded once: %+v", d)
+	}
+	if d.ConfidenceDowngrade != 0.25 || d.Confidence != 0.75 {
+		t.Errorf("expected confidence 0.75 after a 0.25 downgrade, got %v after %v", d.Confidence, d.ConfidenceDowngrade)
+	}
+	if reason, ok := d.Skipped(CheckLoadTest); !ok || reason != "service is unhealthy" {
+		t.Errorf("expected the first reason for the load test, got %q", reason)
+	}
+	if _, ok := d.Skipped(CheckBuild); ok {
+		t.Error("expected the build not to be skipped")
+	}
+}
+
+func TestValidateDeploymentReportsDegradation(t *testing.T) {
+	dv := NewDeploymentValidator(failingLLM{})
+	dv.workingDir = t.TempDir()
+	capsule := &types.QuantumCapsule{ID: "cap-1", Drops: []types.QuantumDrop{
+		{ID: "drop-1", Files: map[string]string{"README.md": "# notes"}},
+	}}
+
+	result, err := dv.ValidateDeployment(context.Background(), capsule)
+	if err != nil {
+		t.Fatalf("expected partial results, got %v", err)
+	}
+	d := result.Degradation
+	if d == nil {
+		t.Fatal("expected a degradation section")
+	}
+	if len(d.Fallbacks) != 1 || d.Fallbacks[0] != FallbackLegacyBuild {
+		t.Errorf("expected the legacy build fallback, got %v", d.Fallbacks)
+	}
+	if len(d.Errors) != 2 || d.Errors[0].Code != ErrorCodeLLMParsingFailed || d.Errors[1].Code != ErrorCodeUnsupportedFormat {
+		t.Errorf("expected the analysis and build errors, got %+v", d.Errors)
+	}
+	if len(d.SkippedChecks) != 6 {
+		t.Errorf("expected every check after the build skipped, resilience testing being disabled: %+v", d.SkippedChecks)
+	}
+	if reason, ok := d.Skipped(CheckStartup); !ok || reason != "build failed" {
+		t.Errorf("expected startup skipped because the build failed, got %q", reason)
+	}
+	if d.Confidence != 0.25 {
+		t.Errorf("expected confidence 0.25, got %v", d.Confidence)
+	}
+
+	suites := result.JUnit("cap-1")
+	if suites.Skipped != 3 || suites.Failures != 1 {
+		t.Errorf("expected the build to fail and the later stages to be skipped, got %d failed, %d skipped",
+			suites.Failures, suites.Skipped)
+	}
+}
+
+func TestDegradationAnnotatesCapsule(t *testing.T) {
+	capsule := &packaging.QLCapsule{}
+	var clean *Degradation
+	clean.Annotate(capsule)
+	if _, ok := capsule.Metadata.Environment["degradation"]; ok {
+		t.Fatal("expected no degradation recorded for a full validation")
+	}
+
+	agg := NewErrorAggregator()
+	agg.Skip("service did not start", CheckHealth)
+	d := agg.Degradation()
+	d.Annotate(capsule)
+	if capsule.Metadata.Environment["degradation"] != d {
+		t.Errorf("expected the degradation in the capsule metadata, got %v", capsule.Metadata.Environment)
+	}
+}
@@ -0,0 +1,506 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"QLP/internal/logger"
+	"go.uber.org/zap"
+)
+
+// DockerfileFinding is a single lint result, using hadolint rule IDs where one exists
+type DockerfileFinding struct {
+	Rule       string `json:"rule"`
+	Severity   string `json:"severity"`
+	Line       int    `json:"line"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion"`
+	Fixable    bool   `json:"fixable"`
+}
+
+// MultiArchBuildResult reports a buildx build across target platforms
+type MultiArchBuildResult struct {
+	Platforms []string      `json:"platforms"`
+	Success   bool          `json:"success"`
+	Output    string        `json:"output,omitempty"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// DockerfileValidationResult contains lint findings and optional build results for one Dockerfile
+type DockerfileValidationResult struct {
+	Path         string                `json:"path"`
+	Findings     []DockerfileFinding   `json:"findings"`
+	Score        int                   `json:"score"`
+	FixedContent string                `json:"fixed_content,omitempty"`
+	AppliedFixes []string              `json:"applied_fixes,omitempty"`
+	Build        *MultiArchBuildResult `json:"build,omitempty"`
+}
+
+// DockerfileValidator lints Dockerfiles and optionally validates multi-arch builds with buildx
+type DockerfileValidator struct {
+	platforms []string
+}
+
+// NewDockerfileValidator creates a lint-only Dockerfile validator
+func NewDockerfileValidator() *DockerfileValidator {
+	return &DockerfileValidator{}
+}
+
+// EnableMultiArchBuild turns on buildx validation for the given platforms
+// (linux/amd64 and linux/arm64 when none are given)
+func (dv *DockerfileValidator) EnableMultiArchBuild(platforms []string) {
+	if len(platforms) == 0 {
+		platforms = []string{"linux/amd64", "linux/arm64"}
+	}
+	dv.platforms = platforms
+}
+
+// IsDockerfile reports whether a path names a Dockerfile
+func IsDockerfile(p string) bool {
+	base := strings.ToLower(path.Base(p))
+	return base == "dockerfile" || strings.HasPrefix(base, "dockerfile.") || strings.HasSuffix(base, ".dockerfile")
+}
+
+// ValidateFiles lints every Dockerfile in files and, when enabled, builds it
+// for each platform using files as the build context
+func (dv *DockerfileValidator) ValidateFiles(ctx context.Context, files map[string]string) []*DockerfileValidationResult {
+	paths := make([]string, 0)
+	for p := range files {
+		if IsDockerfile(p) {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	results := make([]*DockerfileValidationResult, 0, len(paths))
+	for _, p := range paths {
+		result := dv.Lint(p, files[p])
+		if len(dv.platforms) > 0 {
+			result.Build = dv.ValidateBuild(ctx, files, p)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// dockerInstruction is one logical line of a Dockerfile
+type dockerInstruction struct {
+	line  int
+	cmd   string
+	args  string
+	stage int
+}
+
+func parseDockerfile(content string) []dockerInstruction {
+	var instructions []dockerInstruction
+	stage := -1
+	var current strings.Builder
+	start := 0
+
+	lines := strings.Split(content, "\n")
+	for i, raw := range lines {
+		line := strings.TrimSpace(raw)
+		if current.Len() == 0 {
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			start = i + 1
+		}
+		if strings.HasSuffix(line, "\\") {
+			current.WriteString(strings.TrimSuffix(line, "\\"))
+			current.WriteByte(' ')
+			continue
+		}
+		current.WriteString(line)
+
+		fields := strings.SplitN(current.String(), " ", 2)
+		cmd := strings.ToUpper(fields[0])
+		args := ""
+		if len(fields) == 2 {
+			args = strings.TrimSpace(fields[1])
+		}
+		if cmd == "FROM" {
+			stage++
+		}
+		instructions = append(instructions, dockerInstruction{line: start, cmd: cmd, args: args, stage: stage})
+		current.Reset()
+	}
+	return instructions
+}
+
+var secretEnvPattern = regexp.MustCompile(`(?i)(password|passwd|secret|token|api_?key|private_?key)\w*\s*[= ]\s*\S+`)
+
+// Lint checks a Dockerfile for common problems and computes an auto-fixed version
+func (dv *DockerfileValidator) Lint(filePath, content string) *DockerfileValidationResult {
+	instructions := parseDockerfile(content)
+	findings := make([]DockerfileFinding, 0)
+
+	stageNames := make(map[string]bool)
+	finalStage := 0
+	for _, in := range instructions {
+		if in.cmd == "FROM" {
+			finalStage = in.stage
+		}
+	}
+
+	var lastUser *dockerInstruction
+	hasHealthcheck := false
+	for i := range instructions {
+		in := instructions[i]
+		switch in.cmd {
+		case "FROM":
+			image, alias := parseFrom(in.args)
+			if alias != "" {
+				stageNames[strings.ToLower(alias)] = true
+			}
+			if image == "scratch" || stageNames[strings.ToLower(image)] || strings.Contains(image, "$") {
+				continue
+			}
+			if strings.Contains(image, "@sha256:") {
+				continue
+			}
+			if !hasTag(image) {
+				findings = append(findings, DockerfileFinding{
+					Rule: "DL3006", Severity: "MEDIUM", Line: in.line,
+					Message:    fmt.Sprintf("Image %s is not tagged", image),
+					Suggestion: fmt.Sprintf("Pin an explicit version, e.g. FROM %s:<version>", image),
+				})
+			} else if strings.HasSuffix(image, ":latest") {
+				findings = append(findings, DockerfileFinding{
+					Rule: "DL3007", Severity: "MEDIUM", Line: in.line,
+					Message:    fmt.Sprintf("Image %s uses the latest tag", image),
+					Suggestion: fmt.Sprintf("Pin an explicit version instead of latest, e.g. FROM %s:<version>", strings.TrimSuffix(image, ":latest")),
+				})
+			}
+		case "USER":
+			if in.stage == finalStage {
+				lastUser = &instructions[i]
+			}
+		case "HEALTHCHECK":
+			if in.stage == finalStage && !strings.EqualFold(in.args, "NONE") {
+				hasHealthcheck = true
+			}
+		case "ADD":
+			src := strings.Fields(in.args)
+			if len(src) > 0 && !strings.Contains(src[0], "://") && !isArchive(src[0]) && !strings.HasPrefix(src[0], "--") {
+				findings = append(findings, DockerfileFinding{
+					Rule: "DL3020", Severity: "LOW", Line: in.line,
+					Message:    "Use COPY instead of ADD for files and folders",
+					Suggestion: "Replace ADD with COPY",
+					Fixable:    true,
+				})
+			}
+		case "RUN":
+			if strings.Contains(in.args, "apk add") && !strings.Contains(in.args, "--no-cache") {
+				findings = append(findings, DockerfileFinding{
+					Rule: "DL3019", Severity: "LOW", Line: in.line,
+					Message:    "apk add without --no-cache leaves the package index in the image",
+					Suggestion: "Use apk add --no-cache",
+					Fixable:    true,
+				})
+			}
+			if strings.Contains(in.args, "apt-get install") && !strings.Contains(in.args, "rm -rf /var/lib/apt/lists") {
+				findings = append(findings, DockerfileFinding{
+					Rule: "DL3009", Severity: "LOW", Line: in.line,
+					Message:    "apt-get lists are not deleted after installing packages",
+					Suggestion: "Append && rm -rf /var/lib/apt/lists/* to the RUN instruction",
+				})
+			}
+			if strings.Contains(in.args, "sudo ") {
+				findings = append(findings, DockerfileFinding{
+					Rule: "DL3004", Severity: "HIGH", Line: in.line,
+					Message:    "Do not use sudo in RUN instructions",
+					Suggestion: "Run as root for that step and switch USER afterwards",
+				})
+			}
+		case "ENV", "ARG":
+			if secretEnvPattern.MatchString(in.args) {
+				findings = append(findings, DockerfileFinding{
+					Rule: "QLP-DF001", Severity: "HIGH", Line: in.line,
+					Message:    fmt.Sprintf("%s appears to set a secret that will be stored in the image", in.cmd),
+					Suggestion: "Inject secrets at runtime or use BuildKit secret mounts",
+				})
+			}
+		case "CMD", "ENTRYPOINT":
+			if !strings.HasPrefix(in.args, "[") {
+				findings = append(findings, DockerfileFinding{
+					Rule: "DL3025", Severity: "LOW", Line: in.line,
+					Message:    fmt.Sprintf("%s uses shell form, so signals are not forwarded to the process", in.cmd),
+					Suggestion: fmt.Sprintf("Use JSON form, e.g. %s [\"executable\", \"arg\"]", in.cmd),
+				})
+			}
+		}
+	}
+
+	if lastUser == nil || isRootUser(lastUser.args) {
+		line := 0
+		if lastUser != nil {
+			line = lastUser.line
+		}
+		findings = append(findings, DockerfileFinding{
+			Rule: "DL3002", Severity: "HIGH", Line: line,
+			Message:    "Final stage runs as root",
+			Suggestion: "Add USER 65532:65532 (or a named non-root user) before CMD/ENTRYPOINT",
+			Fixable:    true,
+		})
+	}
+	if !hasHealthcheck {
+		finding := DockerfileFinding{
+			Rule: "QLP-DF002", Severity: "LOW",
+			Message:    "No HEALTHCHECK defined for the final stage",
+			Suggestion: "Add a HEALTHCHECK that probes the service, e.g. HEALTHCHECK CMD wget -qO- http://localhost:<port>/health || exit 1",
+		}
+		if port := exposedPort(instructions, finalStage); port != "" {
+			finding.Suggestion = fmt.Sprintf("Add HEALTHCHECK CMD wget -qO- http://localhost:%s/health || exit 1", port)
+			finding.Fixable = true
+		}
+		findings = append(findings, finding)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Line < findings[j].Line })
+
+	result := &DockerfileValidationResult{
+		Path:     filePath,
+		Findings: findings,
+		Score:    dockerfileScore(findings),
+	}
+	if fixed, applied := dv.AutoFix(content); len(applied) > 0 {
+		result.FixedContent = fixed
+		result.AppliedFixes = applied
+	}
+	return result
+}
+
+// AutoFix applies the fixes that are safe without knowing the application:
+// COPY for local ADD, apk --no-cache, a non-root USER and a HEALTHCHECK on
+// the exposed port
+func (dv *DockerfileValidator) AutoFix(content string) (string, []string) {
+	instructions := parseDockerfile(content)
+	lines := strings.Split(content, "\n")
+	applied := make([]string, 0)
+
+	finalStage := 0
+	for _, in := range instructions {
+		if in.cmd == "FROM" {
+			finalStage = in.stage
+		}
+	}
+
+	for _, in := range instructions {
+		idx := in.line - 1
+		switch in.cmd {
+		case "ADD":
+			src := strings.Fields(in.args)
+			if len(src) > 0 && !strings.Contains(src[0], "://") && !isArchive(src[0]) && !strings.HasPrefix(src[0], "--") {
+				lines[idx] = strings.Replace(lines[idx], "ADD", "COPY", 1)
+				applied = append(applied, fmt.Sprintf("line %d: ADD replaced with COPY", in.line))
+			}
+		case "RUN":
+			if strings.Contains(in.args, "apk add") && !strings.Contains(in.args, "--no-cache") {
+				for j := idx; j < len(lines); j++ {
+					if strings.Contains(lines[j], "apk add") {
+						lines[j] = strings.Replace(lines[j], "apk add", "apk add --no-cache", 1)
+						applied = append(applied, fmt.Sprintf("line %d: added --no-cache to apk add", j+1))
+						break
+					}
+				}
+			}
+		}
+	}
+
+	// Insert USER and HEALTHCHECK before the final CMD/ENTRYPOINT, or at the end
+	insertAt := len(lines)
+	runsAsRoot := true
+	hasHealthcheck := false
+	for _, in := range instructions {
+		if in.stage != finalStage {
+			continue
+		}
+		switch in.cmd {
+		case "USER":
+			runsAsRoot = isRootUser(in.args)
+		case "HEALTHCHECK":
+			hasHealthcheck = !strings.EqualFold(in.args, "NONE")
+		case "CMD", "ENTRYPOINT":
+			if in.line-1 < insertAt {
+				insertAt = in.line - 1
+			}
+		}
+	}
+	for insertAt > 0 && insertAt == len(lines) && strings.TrimSpace(lines[insertAt-1]) == "" {
+		insertAt--
+	}
+
+	var inserted []string
+	if !hasHealthcheck {
+		if port := exposedPort(instructions, finalStage); port != "" {
+			inserted = append(inserted, fmt.Sprintf("HEALTHCHECK --interval=30s --timeout=5s CMD wget -qO- http://localhost:%s/health || exit 1", port))
+			applied = append(applied, "added HEALTHCHECK on port "+port)
+		}
+	}
+	if runsAsRoot {
+		inserted = append(inserted, "USER 65532:65532")
+		applied = append(applied, "added non-root USER 65532:65532 to the final stage")
+	}
+	if len(inserted) > 0 {
+		lines = append(lines[:insertAt], append(inserted, lines[insertAt:]...)...)
+	}
+
+	return strings.Join(lines, "\n"), applied
+}
+
+// ValidateBuild builds the Dockerfile for each configured platform with
+// buildx, without loading or pushing the result
+func (dv *DockerfileValidator) ValidateBuild(ctx context.Context, files map[string]string, dockerfilePath string) *MultiArchBuildResult {
+	result := &MultiArchBuildResult{Platforms: dv.platforms}
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+
+	dir, err := os.MkdirTemp("", "qlp-buildx-*")
+	if err != nil {
+		result.Output = err.Error()
+		return result
+	}
+	defer os.RemoveAll(dir)
+
+	for p, content := range files {
+		target := filepath.Join(dir, filepath.FromSlash(path.Clean("/"+p)))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			result.Output = err.Error()
+			return result
+		}
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			result.Output = err.Error()
+			return result
+		}
+	}
+
+	buildCtx, cancel := context.WithTimeout(ctx, 20*time.Minute)
+	defer cancel()
+
+	contextDir := filepath.Join(dir, filepath.FromSlash(path.Dir(path.Clean("/"+dockerfilePath))))
+	cmd := exec.CommandContext(buildCtx, "docker", "buildx", "build",
+		"--platform", strings.Join(dv.platforms, ","),
+		"--file", filepath.Join(dir, filepath.FromSlash(path.Clean("/"+dockerfilePath))),
+		"--progress", "plain",
+		contextDir)
+	output, err := cmd.CombinedOutput()
+	result.Output = lastLines(string(output), 30)
+	result.Success = err == nil
+
+	logger.WithComponent("validation").Info("Multi-arch Docker build validated",
+		zap.String("dockerfile", dockerfilePath),
+		zap.Strings("platforms", dv.platforms),
+		zap.Bool("success", result.Success))
+	return result
+}
+
+// Issues converts findings and build failures to validation issues
+func (r *DockerfileValidationResult) Issues() []ValidationIssue {
+	issues := make([]ValidationIssue, 0, len(r.Findings))
+	for _, f := range r.Findings {
+		resource := r.Path
+		if f.Line > 0 {
+			resource = fmt.Sprintf("%s:%d", r.Path, f.Line)
+		}
+		issues = append(issues, ValidationIssue{
+			Severity:    f.Severity,
+			Category:    "Dockerfile " + f.Rule,
+			Message:     f.Message,
+			Resource:    resource,
+			Remediation: f.Suggestion,
+		})
+	}
+	if r.Build != nil && !r.Build.Success {
+		issues = append(issues, ValidationIssue{
+			Severity:    "HIGH",
+			Category:    "Dockerfile Build",
+			Message:     fmt.Sprintf("Build failed for %s", strings.Join(r.Build.Platforms, ", ")),
+			Resource:    r.Path,
+			Remediation: "Check base image availability and architecture-specific steps for each platform",
+		})
+	}
+	return issues
+}
+
+func parseFrom(args string) (image, alias string) {
+	fields := strings.Fields(args)
+	for len(fields) > 0 && strings.HasPrefix(fields[0], "--") {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return "", ""
+	}
+	image = strings.ToLower(fields[0])
+	if len(fields) >= 3 && strings.EqualFold(fields[1], "AS") {
+		alias = fields[2]
+	}
+	return image, alias
+}
+
+func hasTag(image string) bool {
+	// A colon after the last slash is a tag; one before it is a registry port
+	return strings.Contains(image[strings.LastIndex(image, "/")+1:], ":")
+}
+
+func isArchive(src string) bool {
+	for _, ext := range []string{".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tar.xz"} {
+		if strings.HasSuffix(src, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func isRootUser(user string) bool {
+	name := strings.SplitN(strings.TrimSpace(user), ":", 2)[0]
+	return name == "root" || name == "0"
+}
+
+func exposedPort(instructions []dockerInstruction, stage int) string {
+	for _, in := range instructions {
+		if in.stage == stage && in.cmd == "EXPOSE" {
+			fields := strings.Fields(in.args)
+			if len(fields) > 0 {
+				return strings.SplitN(fields[0], "/", 2)[0]
+			}
+		}
+	}
+	return ""
+}
+
+func dockerfileScore(findings []DockerfileFinding) int {
+	score := 100
+	for _, f := range findings {
+		switch f.Severity {
+		case "CRITICAL":
+			score -= 25
+		case "HIGH":
+			score -= 15
+		case "MEDIUM":
+			score -= 8
+		default:
+			score -= 3
+		}
+	}
+	if score < 0 {
+		score = 0
+	}
+	return score
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
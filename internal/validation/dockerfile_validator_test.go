@@ -0,0 +1,62 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleDockerfile = `FROM golang:1.21-alpine AS builder
+WORKDIR /app
+COPY go.mod go.sum ./
+RUN go mod download
+ADD . .
+RUN go build -o main .
+
+FROM alpine:latest
+RUN apk add ca-certificates
+COPY --from=builder /app/main .
+EXPOSE 8080
+CMD ["./main"]
+`
+
+func TestDockerfileLint(t *testing.T) {
+	result := NewDockerfileValidator().Lint("Dockerfile", sampleDockerfile)
+
+	rules := make(map[string]int)
+	for _, f := range result.Findings {
+		rules[f.Rule] = f.Line
+	}
+	for rule, line := range map[string]int{"DL3007": 8, "DL3020": 5, "DL3019": 9, "DL3002": 0, "QLP-DF002": 0} {
+		got, ok := rules[rule]
+		if !ok {
+			t.Errorf("expected %s finding, got %+v", rule, result.Findings)
+		} else if got != line {
+			t.Errorf("%s reported on line %d, want %d", rule, got, line)
+		}
+	}
+	if _, ok := rules["DL3006"]; ok {
+		t.Error("build stage reference should not be reported as untagged")
+	}
+	if result.Score >= 100 {
+		t.Errorf("expected score to be reduced, got %d", result.Score)
+	}
+}
+
+func TestDockerfileAutoFix(t *testing.T) {
+	fixed, applied := NewDockerfileValidator().AutoFix(sampleDockerfile)
+	if len(applied) != 4 {
+		t.Errorf("expected 4 fixes, got %v", applied)
+	}
+	for _, want := range []string{"COPY . .", "apk add --no-cache", "USER 65532:65532\nCMD", "localhost:8080/health"} {
+		if !strings.Contains(fixed, want) {
+			t.Errorf("fixed Dockerfile missing %q:\n%s", want, fixed)
+		}
+	}
+
+	result := NewDockerfileValidator().Lint("Dockerfile", fixed)
+	for _, f := range result.Findings {
+		if f.Fixable {
+			t.Errorf("fixable finding remains after auto-fix: %+v", f)
+		}
+	}
+}
@@ -144,7 +144,9 @@ func isRetryableError(code ValidationErrorCode) bool {
 
 // ErrorAggregator collects multiple validation errors
 type ErrorAggregator struct {
-	errors []*ValidationError
+	errors    []*ValidationError
+	skipped   []SkippedCheck
+	fallbacks []string
 }
 
 // NewErrorAggregator creates a new error aggregator
@@ -0,0 +1,497 @@
+package validation
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// GoFinding is an issue the Go analyzer found at a precise position
+type GoFinding struct {
+	Rule        string `json:"rule"`
+	Severity    string `json:"severity"` // high, medium or low
+	Message     string `json:"message"`
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+	Column      int    `json:"column"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// Location returns the finding position as file:line:column
+func (f GoFinding) Location() string {
+	return fmt.Sprintf("%s:%d:%d", f.File, f.Line, f.Column)
+}
+
+// GoAnalysis is the result of analyzing the Go files of a drop
+type GoAnalysis struct {
+	Files    int         `json:"files"`
+	Score    int         `json:"score"`
+	Findings []GoFinding `json:"findings"`
+}
+
+// QualityFindings converts the findings for the static validation result
+func (a *GoAnalysis) QualityFindings() []QualityFinding {
+	findings := make([]QualityFinding, 0, len(a.Findings))
+	for _, f := range a.Findings {
+		findings = append(findings, QualityFinding{
+			Type:           f.Rule,
+			Severity:       strings.ToUpper(f.Severity),
+			Description:    f.Message,
+			Location:       f.Location(),
+			Recommendation: f.Remediation,
+			Category:       "Go analysis",
+		})
+	}
+	return findings
+}
+
+// AnalyzeGo parses and type-checks the Go files among files, one package
+// per directory, and reports unused variables and imports, unchecked
+// errors, context misuse and HTTP servers and clients without timeouts.
+// Standard library imports are type-checked from GOROOT; other imports are
+// opaque, so checks that need their types are skipped for them. It returns
+// nil when there are no Go files.
+func AnalyzeGo(files map[string]string) *GoAnalysis {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if strings.HasSuffix(name, ".go") {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+
+	a := &goAnalyzer{fset: token.NewFileSet()}
+	packages := make(map[string][]*ast.File)
+	var keys []string
+	for _, name := range names {
+		f, err := parser.ParseFile(a.fset, name, files[name], parser.SkipObjectResolution)
+		if err != nil {
+			a.syntaxErrors(name, err)
+			continue
+		}
+		key := path.Dir(name) + "/" + f.Name.Name
+		if packages[key] == nil {
+			keys = append(keys, key)
+		}
+		packages[key] = append(packages[key], f)
+	}
+	for _, key := range keys {
+		a.check(packages[key])
+	}
+
+	sort.SliceStable(a.findings, func(i, j int) bool {
+		fi, fj := a.findings[i], a.findings[j]
+		if fi.File != fj.File {
+			return fi.File < fj.File
+		}
+		if fi.Line != fj.Line {
+			return fi.Line < fj.Line
+		}
+		return fi.Column < fj.Column
+	})
+	score := 100
+	for _, f := range a.findings {
+		score -= severityPenalty(f.Severity)
+	}
+	if a.findings == nil {
+		a.findings = []GoFinding{}
+	}
+	return &GoAnalysis{Files: len(names), Score: max(score, 0), Findings: a.findings}
+}
+
+// goAnalyzer collects the findings of one analysis
+type goAnalyzer struct {
+	fset     *token.FileSet
+	findings []GoFinding
+}
+
+func (a *goAnalyzer) report(pos token.Pos, rule, severity, message, remediation string) {
+	p := a.fset.Position(pos)
+	a.findings = append(a.findings, GoFinding{
+		Rule:        rule,
+		Severity:    severity,
+		Message:     message,
+		File:        p.Filename,
+		Line:        p.Line,
+		Column:      p.Column,
+		Remediation: remediation,
+	})
+}
+
+func (a *goAnalyzer) syntaxErrors(name string, err error) {
+	list, ok := err.(scanner.ErrorList)
+	if !ok {
+		a.findings = append(a.findings, GoFinding{Rule: "syntax-error", Severity: "high", Message: err.Error(), File: name, Line: 1, Column: 1})
+		return
+	}
+	for _, e := range list {
+		a.findings = append(a.findings, GoFinding{Rule: "syntax-error", Severity: "high", Message: e.Msg,
+			File: e.Pos.Filename, Line: e.Pos.Line, Column: e.Pos.Column, Remediation: "Fix the syntax so the file compiles"})
+	}
+}
+
+// check type-checks one package and runs the checks on each of its files
+func (a *goAnalyzer) check(files []*ast.File) {
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	conf := types.Config{
+		Importer: goImports,
+		Error: func(err error) {
+			te, ok := err.(types.Error)
+			if !ok {
+				return
+			}
+			switch {
+			case strings.Contains(te.Msg, "declared and not used"):
+				a.report(te.Pos, "unused-variable", "medium", te.Msg, "Remove the variable or use it")
+			case strings.Contains(te.Msg, "imported and not used"):
+				a.report(te.Pos, "unused-import", "low", te.Msg, "Remove the import")
+			}
+		},
+	}
+	conf.Check(files[0].Name.Name, a.fset, files, info)
+
+	for _, f := range files {
+		c := &goFileCheck{goAnalyzer: a, info: info, imports: fileImports(f)}
+		c.run(f)
+	}
+}
+
+// goFileCheck runs the syntax checks of one file, resolving package
+// qualifiers through its imports when the types are unknown
+type goFileCheck struct {
+	*goAnalyzer
+	info    *types.Info
+	imports map[string]string // local name to import path
+}
+
+func fileImports(f *ast.File) map[string]string {
+	imports := make(map[string]string)
+	for _, imp := range f.Imports {
+		p := strings.Trim(imp.Path.Value, `"`)
+		name := path.Base(p)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		imports[name] = p
+	}
+	return imports
+}
+
+var errorType = types.Universe.Lookup("error").Type()
+
+func (c *goFileCheck) run(f *ast.File) {
+	// Functions with a context or a request in scope, innermost last
+	var scopes []ast.Node
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil {
+			scopes = scopes[:len(scopes)-1]
+			return true
+		}
+		scopes = append(scopes, n)
+
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			c.contextParams(n.Type)
+		case *ast.FuncLit:
+			c.contextParams(n.Type)
+		case *ast.StructType:
+			for _, field := range n.Fields.List {
+				if c.isQualified(field.Type, "context", "Context") {
+					c.report(field.Pos(), "context-in-struct", "low",
+						"context.Context stored in a struct field",
+						"Pass the context as the first parameter of the calls that need it")
+				}
+			}
+		case *ast.ExprStmt:
+			if call, ok := n.X.(*ast.CallExpr); ok {
+				c.uncheckedError(call)
+			}
+		case *ast.AssignStmt:
+			c.discardedResults(n)
+		case *ast.CallExpr:
+			c.call(n, scopes)
+		case *ast.CompositeLit:
+			c.literal(n)
+		}
+		return true
+	})
+}
+
+// contextParams flags a context that is not the first parameter
+func (c *goFileCheck) contextParams(ft *ast.FuncType) {
+	i := 0
+	for _, field := range ft.Params.List {
+		if c.isQualified(field.Type, "context", "Context") && i > 0 {
+			c.report(field.Pos(), "context-not-first", "low",
+				"context.Context should be the first parameter",
+				"Move the context to the first parameter, named ctx")
+		}
+		i += max(len(field.Names), 1)
+	}
+}
+
+// uncheckedError flags a call statement whose error result is dropped
+func (c *goFileCheck) uncheckedError(call *ast.CallExpr) {
+	results := c.results(call)
+	if len(results) == 0 || !types.Identical(results[len(results)-1], errorType) || c.errorIgnorable(call) {
+		return
+	}
+	c.report(call.Pos(), "unchecked-error", "high",
+		fmt.Sprintf("error returned by %s is not checked", types.ExprString(call.Fun)),
+		"Handle the error, or return it wrapped with context")
+}
+
+// discardedResults flags errors assigned to the blank identifier and
+// context cancel functions that are thrown away
+func (c *goFileCheck) discardedResults(as *ast.AssignStmt) {
+	if len(as.Rhs) != 1 {
+		return
+	}
+	call, ok := as.Rhs[0].(*ast.CallExpr)
+	if !ok {
+		return
+	}
+	if pkg, name := c.callee(call); pkg == "context" && (name == "WithCancel" || name == "WithTimeout" || name == "WithDeadline") {
+		if len(as.Lhs) == 2 && isBlank(as.Lhs[1]) {
+			c.report(as.Lhs[1].Pos(), "lost-cancel", "high",
+				fmt.Sprintf("the cancel function returned by context.%s is discarded", name),
+				"Keep the cancel function and defer it so the context's resources are released")
+		}
+		return
+	}
+	results := c.results(call)
+	if len(results) != len(as.Lhs) || len(results) == 0 || c.errorIgnorable(call) {
+		return
+	}
+	last := len(results) - 1
+	if types.Identical(results[last], errorType) && isBlank(as.Lhs[last]) {
+		c.report(as.Lhs[last].Pos(), "discarded-error", "medium",
+			fmt.Sprintf("error returned by %s is assigned to _", types.ExprString(call.Fun)),
+			"Handle the error instead of discarding it")
+	}
+}
+
+// call flags fresh root contexts where a context is already in scope and
+// requests through the default HTTP client or servers without timeouts
+func (c *goFileCheck) call(call *ast.CallExpr, scopes []ast.Node) {
+	pkg, name := c.callee(call)
+	switch {
+	case pkg == "context" && (name == "Background" || name == "TODO"):
+		if from := c.contextInScope(scopes); from != "" {
+			c.report(call.Pos(), "context-background", "medium",
+				fmt.Sprintf("context.%s() used where %s is available", name, from),
+				fmt.Sprintf("Derive the context from %s so cancellation and deadlines propagate", from))
+		}
+	case pkg == "net/http" && (name == "ListenAndServe" || name == "ListenAndServeTLS"):
+		c.report(call.Pos(), "server-without-timeouts", "high",
+			fmt.Sprintf("http.%s starts a server without read or write timeouts", name),
+			"Use an http.Server with ReadHeaderTimeout, ReadTimeout, WriteTimeout and IdleTimeout set")
+	case pkg == "net/http" && (name == "Get" || name == "Post" || name == "Head" || name == "PostForm"):
+		c.report(call.Pos(), "client-without-timeout", "medium",
+			fmt.Sprintf("http.%s uses the default client, which has no timeout", name),
+			"Use an http.Client with a Timeout, or a request with a context deadline")
+	}
+}
+
+// literal flags HTTP servers and clients built without timeouts
+func (c *goFileCheck) literal(lit *ast.CompositeLit) {
+	fields := make(map[string]bool)
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if key, ok := kv.Key.(*ast.Ident); ok {
+				fields[key.Name] = true
+			}
+		}
+	}
+	switch {
+	case c.isQualified(lit.Type, "net/http", "Server"):
+		if !fields["ReadHeaderTimeout"] && !fields["ReadTimeout"] {
+			c.report(lit.Pos(), "server-without-timeouts", "high",
+				"http.Server has no ReadHeaderTimeout or ReadTimeout, so slow clients can hold connections open",
+				"Set ReadHeaderTimeout (or ReadTimeout), WriteTimeout and IdleTimeout")
+		}
+	case c.isQualified(lit.Type, "net/http", "Client"):
+		if !fields["Timeout"] {
+			c.report(lit.Pos(), "client-without-timeout", "medium",
+				"http.Client has no Timeout",
+				"Set a Timeout on the client")
+		}
+	}
+}
+
+// contextInScope names the context available to the innermost function,
+// if any: its context parameter or its request's context
+func (c *goFileCheck) contextInScope(scopes []ast.Node) string {
+	for i := len(scopes) - 1; i >= 0; i-- {
+		var ft *ast.FuncType
+		switch fn := scopes[i].(type) {
+		case *ast.FuncDecl:
+			ft = fn.Type
+		case *ast.FuncLit:
+			ft = fn.Type
+		default:
+			continue
+		}
+		for _, field := range ft.Params.List {
+			if len(field.Names) == 0 || field.Names[0].Name == "_" {
+				continue
+			}
+			switch {
+			case c.isQualified(field.Type, "context", "Context"):
+				return field.Names[0].Name
+			case isStar(field.Type) && c.isQualified(field.Type.(*ast.StarExpr).X, "net/http", "Request"):
+				return field.Names[0].Name + ".Context()"
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+// results returns the result types of a call, or nil when unknown
+func (c *goFileCheck) results(call *ast.CallExpr) []types.Type {
+	tv, ok := c.info.Types[call]
+	if !ok || tv.Type == nil || tv.IsType() {
+		return nil
+	}
+	if tuple, ok := tv.Type.(*types.Tuple); ok {
+		out := make([]types.Type, tuple.Len())
+		for i := range out {
+			out[i] = tuple.At(i).Type()
+		}
+		return out
+	}
+	if basic, ok := tv.Type.(*types.Basic); ok && basic.Kind() == types.Invalid {
+		return nil
+	}
+	return []types.Type{tv.Type}
+}
+
+// errorIgnorable reports calls whose errors are conventionally ignored:
+// fmt printing and writes to in-memory buffers
+func (c *goFileCheck) errorIgnorable(call *ast.CallExpr) bool {
+	if pkg, _ := c.callee(call); pkg == "fmt" {
+		return true
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	if s, ok := c.info.Selections[sel]; ok {
+		switch types.TypeString(s.Recv(), nil) {
+		case "*bytes.Buffer", "bytes.Buffer", "*strings.Builder", "strings.Builder", "hash.Hash":
+			return true
+		}
+	}
+	return false
+}
+
+// callee returns the package path and name of a call to a package-level
+// function
+func (c *goFileCheck) callee(call *ast.CallExpr) (string, string) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return "", ""
+	}
+	if fn, ok := c.info.Uses[sel.Sel].(*types.Func); ok {
+		if fn.Pkg() == nil || fn.Type().(*types.Signature).Recv() != nil {
+			return "", ""
+		}
+		return fn.Pkg().Path(), fn.Name()
+	}
+	if x, ok := sel.X.(*ast.Ident); ok {
+		if _, local := c.info.Uses[x].(*types.PkgName); local || c.info.Uses[x] == nil {
+			return c.imports[x.Name], sel.Sel.Name
+		}
+	}
+	return "", ""
+}
+
+// isQualified reports whether expr names the type pkg.name, through its
+// type when known or through the file's imports
+func (c *goFileCheck) isQualified(expr ast.Expr, pkg, name string) bool {
+	if tv, ok := c.info.Types[expr]; ok && tv.Type != nil {
+		if named, ok := tv.Type.(*types.Named); ok {
+			obj := named.Obj()
+			return obj.Pkg() != nil && obj.Pkg().Path() == pkg && obj.Name() == name
+		}
+	}
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	return ok && c.imports[x.Name] == pkg && sel.Sel.Name == name
+}
+
+func isBlank(expr ast.Expr) bool {
+	id, ok := expr.(*ast.Ident)
+	return ok && id.Name == "_"
+}
+
+func isStar(expr ast.Expr) bool {
+	_, ok := expr.(*ast.StarExpr)
+	return ok
+}
+
+// goImporter type-checks standard library imports from GOROOT, once per
+// process, and stands in empty packages for the rest, or for everything
+// when GOROOT is not available
+type goImporter struct {
+	mu     sync.Mutex
+	source types.Importer
+	stubs  map[string]*types.Package
+}
+
+var goImports = &goImporter{
+	source: importer.ForCompiler(token.NewFileSet(), "source", nil),
+	stubs:  make(map[string]*types.Package),
+}
+
+func (g *goImporter) Import(importPath string) (*types.Package, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if pkg, ok := g.stubs[importPath]; ok {
+		return pkg, nil
+	}
+	if first, _, _ := strings.Cut(importPath, "/"); !strings.Contains(first, ".") && importPath != "C" {
+		if pkg, err := g.source.Import(importPath); err == nil {
+			return pkg, nil
+		}
+	}
+	pkg := types.NewPackage(importPath, stubName(importPath))
+	pkg.MarkComplete()
+	g.stubs[importPath] = pkg
+	return pkg, nil
+}
+
+// stubName guesses the package name of an import path, skipping major
+// version suffixes such as /v2
+func stubName(importPath string) string {
+	name := path.Base(importPath)
+	if len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = path.Base(path.Dir(importPath))
+	}
+	name = strings.TrimPrefix(name, "go-")
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' {
+			return '_'
+		}
+		return r
+	}, name)
+}
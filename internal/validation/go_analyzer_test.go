@@ -0,0 +1,101 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeGo(t *testing.T) {
+	analysis := AnalyzeGo(map[string]string{
+		"cmd/api/main.go": `package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type service struct {
+	ctx context.Context
+}
+
+func handle(w http.ResponseWriter, r *http.Request) {
+	ctx, _ := context.WithTimeout(context.Background(), time.Second)
+	unused := 42
+	json.NewEncoder(w).Encode(ctx.Value("user"))
+	w.Write([]byte("ok"))
+	resp, _ := http.Get("http://example.com")
+	defer resp.Body.Close()
+}
+
+func load(name string, ctx context.Context) error {
+	_, err := os.ReadFile(name)
+	return err
+}
+
+func main() {
+	r := chi.NewRouter()
+	r.Get("/", handle)
+	load("config.json", context.Background())
+	srv := &http.Server{Addr: ":8080", Handler: r}
+	srv.ListenAndServe()
+	http.ListenAndServe(":8081", nil)
+}
+`,
+		"cmd/api/util.go": "package main\n\nfunc helper( {\n",
+		"README.md":       "not go",
+	})
+	if analysis == nil || analysis.Files != 2 {
+		t.Fatalf("analysis = %+v", analysis)
+	}
+
+	got := make(map[string]bool)
+	for _, f := range analysis.Findings {
+		got[f.Location()+" "+f.Rule] = true
+	}
+	for _, want := range []string{
+		"cmd/api/main.go:14:2 context-in-struct",
+		"cmd/api/main.go:18:7 lost-cancel",
+		"cmd/api/main.go:18:32 context-background",
+		"cmd/api/main.go:19:2 unused-variable",
+		"cmd/api/main.go:20:2 unchecked-error",
+		"cmd/api/main.go:21:2 unchecked-error",
+		"cmd/api/main.go:22:8 discarded-error",
+		"cmd/api/main.go:22:13 client-without-timeout",
+		"cmd/api/main.go:26:24 context-not-first",
+		"cmd/api/main.go:34:2 unchecked-error",
+		"cmd/api/main.go:35:10 server-without-timeouts",
+		"cmd/api/main.go:36:2 unchecked-error",
+		"cmd/api/main.go:37:2 server-without-timeouts",
+		"cmd/api/main.go:37:2 unchecked-error",
+		"cmd/api/util.go:3:14 syntax-error",
+	} {
+		if !got[want] {
+			t.Errorf("missing %s", want)
+		}
+	}
+	if len(got) != 15 {
+		t.Errorf("%d findings, want 15: %+v", len(got), analysis.Findings)
+	}
+	// chi is opaque, so its calls are neither resolved nor flagged
+	for _, f := range analysis.Findings {
+		if strings.Contains(f.Message, "chi") || strings.Contains(f.Message, "r.Get") {
+			t.Errorf("unexpected finding on an unresolved import: %+v", f)
+		}
+	}
+	if analysis.Score != 0 {
+		t.Errorf("score = %d", analysis.Score)
+	}
+
+	if AnalyzeGo(map[string]string{"app.py": "print()"}) != nil {
+		t.Error("expected no analysis without Go files")
+	}
+	clean := AnalyzeGo(map[string]string{"main.go": "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n"})
+	if clean.Score != 100 || len(clean.Findings) != 0 {
+		t.Errorf("clean analysis = %+v", clean)
+	}
+}
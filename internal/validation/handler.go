@@ -0,0 +1,455 @@
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/junit"
+	"QLP/internal/packaging"
+	"QLP/internal/sarif"
+)
+
+// Routes returns the validation endpoints. Both answer with JSON results, or
+// with a SARIF log when the request sends Accept: application/sarif+json.
+//
+//	POST /validate                 static validation of a set of files
+//	POST /validate/infrastructure  Terraform, Kubernetes or Dockerfile validation
+//	POST /validate/deployment/junit  convert deployment test results to JUnit XML
+//	POST /validate/batch           validates many artifacts concurrently behind one quality gate
+//	GET  /validate/baselines       lists the security baseline profiles compliance is checked against
+//
+// Long validations can run in the background instead, streaming the
+// progress of each check as server-sent events:
+//
+//	POST /validations              starts a static or infrastructure validation
+//	GET  /validations/{id}         returns the run, with its result once finished
+//	GET  /validations/{id}/stream  streams the run's progress events until it ends
+func Routes(static *StaticValidator, infra *InfrastructureValidator) map[string]http.Handler {
+	runs := NewRuns(time.Hour)
+	batch := NewBatchValidatorFromEnv(static, infra)
+	return map[string]http.Handler{
+		"POST /validate":                  staticHandler(static),
+		"POST /validate/infrastructure":   infraHandler(infra),
+		"POST /validate/deployment/junit": junitHandler(),
+		"POST /validate/batch":            batchHandler(batch),
+		"GET /validate/baselines":         http.HandlerFunc(baselinesHandler),
+		"POST /validations":               startRunHandler(runs, static, infra),
+		"GET /validations/{id}":           getRunHandler(runs),
+		"GET /validations/{id}/stream":    streamRunHandler(runs),
+	}
+}
+
+// staticRequest is the body of POST /validate. The tenant's custom rules
+// are applied along with the built-in checks.
+type staticRequest struct {
+	TenantID  string            `json:"tenant_id"`
+	CapsuleID string            `json:"capsule_id"`
+	Type      string            `json:"type"`
+	Files     map[string]string `json:"files"`
+}
+
+// infraRequest is the body of POST /validate/infrastructure. Baselines
+// selects the compliance profiles, by default the tenant's.
+type infraRequest struct {
+	TenantID  string   `json:"tenant_id"`
+	Type      string   `json:"type"`
+	Path      string   `json:"path"`
+	Code      string   `json:"code"`
+	Baselines []string `json:"baselines"`
+}
+
+func staticHandler(validator *StaticValidator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req staticRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Files) == 0 {
+			http.Error(w, "request body must be JSON with a non-empty files map", http.StatusBadRequest)
+			return
+		}
+		result, err := validator.ValidateQuantumDrop(audit.WithTenant(r.Context(), audit.BodyTenant(r, req.TenantID)), req.drop())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeResult(w, r, result, func() *sarif.Log { return result.SARIF(DefaultSARIFLocation(req.Files)) })
+	})
+}
+
+// drop wraps the files of a static validation request in a QuantumDrop
+func (req staticRequest) drop() *packaging.QuantumDrop {
+	dropType := packaging.DropType(req.Type)
+	if req.Type == "" {
+		dropType = packaging.DropTypeCodebase
+	}
+	return &packaging.QuantumDrop{
+		ID:        req.CapsuleID,
+		Type:      dropType,
+		Name:      req.CapsuleID,
+		Files:     req.Files,
+		Status:    packaging.DropStatusReady,
+		CreatedAt: time.Now(),
+		Metadata:  packaging.DropMetadata{FileCount: len(req.Files)},
+	}
+}
+
+func infraHandler(validator *InfrastructureValidator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req infraRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" || req.Type == "" {
+			http.Error(w, "request body must be JSON with type and code", http.StatusBadRequest)
+			return
+		}
+		if err := LookupBaselines(req.Baselines); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx := WithBaselines(audit.WithTenant(r.Context(), audit.BodyTenant(r, req.TenantID)), req.Baselines)
+		result, err := validator.ValidateInfrastructure(ctx, req.Code, req.Type)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		path := req.Path
+		if path == "" {
+			path = "main." + req.Type
+		}
+		writeResult(w, r, result, func() *sarif.Log { return result.SARIF(path) })
+	})
+}
+
+// junitHandler converts a DeploymentTestResult, as returned by the deployment
+// validator, into a JUnit report named by the ?name= query parameter
+func junitHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var result DeploymentTestResult
+		if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
+			http.Error(w, "request body must be a deployment test result", http.StatusBadRequest)
+			return
+		}
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			name = "deployment"
+		}
+		data, err := result.JUnit(name).Marshal()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", junit.MediaType)
+		w.Header().Set("Content-Disposition", `attachment; filename="junit.xml"`)
+		w.Write(data)
+	})
+}
+
+// batchHandler answers with the aggregate report of a batch, whether or not
+// it passed the quality gate
+func batchHandler(validator *BatchValidator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req BatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid batch validation: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		result, err := validator.Validate(r.Context(), req)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrInvalidBatch) {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	})
+}
+
+// runRequest is the body of POST /validations: a static validation request
+// or, with kind infrastructure, an infrastructure one
+type runRequest struct {
+	Kind string `json:"kind"` // static (default) or infrastructure
+	staticRequest
+	Path      string   `json:"path"`
+	Code      string   `json:"code"`
+	Baselines []string `json:"baselines"`
+}
+
+func startRunHandler(runs *Runs, static *StaticValidator, infra *InfrastructureValidator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req runRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid validation request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		var validate func(ctx context.Context) (interface{}, error)
+		switch req.Kind {
+		case "", "static":
+			if len(req.Files) == 0 {
+				http.Error(w, "a static validation needs a non-empty files map", http.StatusBadRequest)
+				return
+			}
+			req.Kind = "static"
+			validate = func(ctx context.Context) (interface{}, error) {
+				return static.ValidateQuantumDrop(ctx, req.drop())
+			}
+		case "infrastructure":
+			if req.Code == "" || req.Type == "" {
+				http.Error(w, "an infrastructure validation needs type and code", http.StatusBadRequest)
+				return
+			}
+			if err := LookupBaselines(req.Baselines); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			validate = func(ctx context.Context) (interface{}, error) {
+				return infra.ValidateInfrastructure(WithBaselines(ctx, req.Baselines), req.Code, req.Type)
+			}
+		default:
+			http.Error(w, fmt.Sprintf("unknown validation kind %q (supported: static, infrastructure)", req.Kind), http.StatusBadRequest)
+			return
+		}
+		run := runs.Start(audit.WithTenant(r.Context(), audit.BodyTenant(r, req.TenantID)), req.Kind, validate)
+		w.Header().Set("Location", "/validations/"+run.ID)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(run)
+	})
+}
+
+func baselinesHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"baselines": Baselines(), "default": DefaultBaselines})
+}
+
+func getRunHandler(runs *Runs) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		run, err := tenantRun(runs, r)
+		if err != nil {
+			writeRunError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(run)
+	})
+}
+
+// tenantRun returns the run the request names, not found when it belongs to
+// another tenant than the request's
+func tenantRun(runs *Runs, r *http.Request) (*Run, error) {
+	run, err := runs.Get(r.PathValue("id"))
+	if err != nil {
+		return nil, err
+	}
+	if !audit.TenantAllowed(r, run.TenantID) {
+		return nil, ErrRunNotFound
+	}
+	return run, nil
+}
+
+// streamRunHandler sends a run's progress events as server-sent events,
+// each with its sequence number as the event ID so a reconnecting client
+// resumes after Last-Event-ID (or ?after=). A final "end" event carries the
+// finished run.
+func streamRunHandler(runs *Runs) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+		after := r.Header.Get("Last-Event-ID")
+		if after == "" {
+			after = r.URL.Query().Get("after")
+		}
+		last, _ := strconv.Atoi(after)
+		if _, err := tenantRun(runs, r); err != nil {
+			writeRunError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(15 * time.Second)
+		defer keepAlive.Stop()
+		for {
+			events, done, changed, err := runs.Events(id, last)
+			if err != nil {
+				return
+			}
+			for _, event := range events {
+				data, _ := json.Marshal(event)
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, data)
+				last = event.Seq
+			}
+			if done {
+				if run, err := runs.Get(id); err == nil {
+					data, _ := json.Marshal(run)
+					fmt.Fprintf(w, "event: end\ndata: %s\n\n", data)
+				}
+				flusher.Flush()
+				return
+			}
+			flusher.Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-changed:
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+				flusher.Flush()
+			}
+		}
+	})
+}
+
+// RuleRoutes returns the endpoints managing tenants' custom validation
+// rules, which take effect on the next validation:
+//
+//	GET    /rules?tenant=                lists the rules applied to a tenant's validations
+//	POST   /rules                        creates a rule
+//	GET    /rules/{id}?tenant=           returns a rule
+//	PUT    /rules/{id}                   replaces a rule, bumping its version
+//	DELETE /rules/{id}?tenant=&actor=    deletes a rule
+//
+// Requests authenticated by an API key manage their key's tenant's rules as
+// the key; tenant_id and actor are only read without one.
+func RuleRoutes(rules *Rules) map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /rules":         listRulesHandler(rules),
+		"POST /rules":        saveRuleHandler(rules),
+		"GET /rules/{id}":    getRuleHandler(rules),
+		"PUT /rules/{id}":    saveRuleHandler(rules),
+		"DELETE /rules/{id}": deleteRuleHandler(rules),
+	}
+}
+
+// ruleRequest is the body of POST /rules and PUT /rules/{id}
+type ruleRequest struct {
+	Rule
+	Actor string `json:"actor"`
+}
+
+func listRulesHandler(rules *Rules) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		list, err := rules.List(r.Context(), audit.RequestTenant(r))
+		if err != nil {
+			writeRuleError(w, err)
+			return
+		}
+		if list == nil {
+			list = []Rule{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"rules": list})
+	})
+}
+
+func getRuleHandler(rules *Rules) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule, err := rules.Get(r.Context(), audit.RequestTenant(r), r.PathValue("id"))
+		if err != nil {
+			writeRuleError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rule)
+	})
+}
+
+// saveRuleHandler creates a rule, or replaces the one named in the path
+func saveRuleHandler(rules *Rules) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ruleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid validation rule: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if tenant := audit.RequestTenant(r); tenant != "" {
+			req.Rule.TenantID = tenant
+		}
+		req.Actor = audit.RequestActor(r, req.Actor)
+		status := http.StatusOK
+		var rule *Rule
+		var err error
+		if id := r.PathValue("id"); id != "" {
+			rule, err = rules.Update(r.Context(), id, req.Rule, req.Actor)
+		} else {
+			status = http.StatusCreated
+			rule, err = rules.Create(r.Context(), req.Rule, req.Actor)
+		}
+		if err != nil {
+			writeRuleError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(rule)
+	})
+}
+
+func deleteRuleHandler(rules *Rules) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if err := rules.Delete(r.Context(), audit.RequestTenant(r), r.PathValue("id"), audit.RequestActor(r, q.Get("actor"))); err != nil {
+			writeRuleError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func writeRuleError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrRuleNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrInvalidRule):
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func writeRunError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, ErrRunNotFound) {
+		status = http.StatusNotFound
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func writeResult(w http.ResponseWriter, r *http.Request, result interface{}, toSARIF func() *sarif.Log) {
+	if sarif.AcceptsSARIF(r.Header.Get("Accept")) {
+		data, err := toSARIF().Marshal()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", sarif.MediaType)
+		w.Write(data)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// DefaultSARIFLocation picks a stable file to anchor findings that have no
+// location
+func DefaultSARIFLocation(files map[string]string) string {
+	first := ""
+	for path := range files {
+		if first == "" || path < first {
+			first = path
+		}
+	}
+	return first
+}
@@ -2,7 +2,7 @@ package validation
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -10,12 +10,14 @@ import (
 
 	"QLP/internal/llm"
 	"QLP/internal/logger"
+	"QLP/internal/netpol"
 	"go.uber.org/zap"
 )
 
 // InfrastructureValidator provides comprehensive validation for infrastructure code
 type InfrastructureValidator struct {
 	llmClient llm.Client
+	baselines *BaselineSelection
 }
 
 // InfraValidationResult represents comprehensive infrastructure validation results
@@ -23,6 +25,7 @@ type InfraValidationResult struct {
 	OverallScore        int                       `json:"overall_score"`
 	TerraformResult     *TerraformValidationResult `json:"terraform_result,omitempty"`
 	KubernetesResult    *KubernetesValidationResult `json:"kubernetes_result,omitempty"`
+	DockerfileResult    *DockerfileValidationResult `json:"dockerfile_result,omitempty"`
 	SecurityResult      *SecurityValidationResult  `json:"security_result"`
 	CostEstimation      *CostEstimation           `json:"cost_estimation"`
 	ComplianceResult    *ComplianceValidationResult `json:"compliance_result"`
@@ -97,6 +100,7 @@ type ComplianceValidationResult struct {
 	ComplianceIssues    []ComplianceIssue   `json:"compliance_issues"`
 	RequiredActions     []string            `json:"required_actions"`
 	CertificationReady  bool                `json:"certification_ready"`
+	Baselines           []BaselineResult    `json:"baselines,omitempty"` // Compliance matrix of the selected baseline profiles
 }
 
 // Supporting types
@@ -157,6 +161,7 @@ type KubernetesIssue struct {
 	Resource    string `json:"resource"`
 	Message     string `json:"message"`
 	Suggestion  string `json:"suggestion"`
+	Remediation string `json:"remediation,omitempty"` // manifest that fixes the issue
 }
 
 type ComplianceIssue struct {
@@ -169,8 +174,15 @@ type ComplianceIssue struct {
 
 // NewInfrastructureValidator creates a new infrastructure validator
 func NewInfrastructureValidator() *InfrastructureValidator {
+	selection, err := BaselineSelectionFromEnv()
+	if err != nil {
+		logger.WithComponent("validation").Warn("Invalid baseline profile selection, using the defaults",
+			zap.Strings("defaults", DefaultBaselines),
+			zap.Error(err))
+	}
 	return &InfrastructureValidator{
 		llmClient: llm.NewLLMClient(),
+		baselines: selection,
 	}
 }
 
@@ -185,46 +197,75 @@ func (iv *InfrastructureValidator) ValidateInfrastructure(ctx context.Context, i
 		Recommendations:  make([]string, 0),
 	}
 	
+	report := progressFrom(ctx)
+	report.started(CheckValidation)
+
 	// Determine infrastructure type and validate accordingly
 	switch strings.ToLower(infraType) {
 	case "terraform", "tf":
+		report.started("terraform")
 		terraformResult, err := iv.validateTerraform(ctx, infrastructureCode)
 		if err != nil {
+			report.failed("terraform", err)
 			return nil, fmt.Errorf("terraform validation failed: %w", err)
 		}
 		result.TerraformResult = terraformResult
 		
 	case "kubernetes", "k8s":
+		report.started("kubernetes")
 		kubernetesResult, err := iv.validateKubernetes(ctx, infrastructureCode)
 		if err != nil {
+			report.failed("kubernetes", err)
 			return nil, fmt.Errorf("kubernetes validation failed: %w", err)
 		}
 		result.KubernetesResult = kubernetesResult
+
+	case "docker", "dockerfile":
+		report.started("dockerfile")
+		result.DockerfileResult = NewDockerfileValidator().Lint("Dockerfile", infrastructureCode)
 		
 	default:
 		// Try to auto-detect infrastructure type
 		detectedType := iv.detectInfrastructureType(infrastructureCode)
 		switch detectedType {
 		case "terraform":
+			report.started("terraform")
 			terraformResult, _ := iv.validateTerraform(ctx, infrastructureCode)
 			result.TerraformResult = terraformResult
 		case "kubernetes":
+			report.started("kubernetes")
 			kubernetesResult, _ := iv.validateKubernetes(ctx, infrastructureCode)
 			result.KubernetesResult = kubernetesResult
+		case "dockerfile":
+			report.started("dockerfile")
+			result.DockerfileResult = NewDockerfileValidator().Lint("Dockerfile", infrastructureCode)
 		}
 	}
+	reportInfraChecks(report, result)
 	
 	// Universal validations (apply to all infrastructure types)
+	report.started("security")
 	securityResult := iv.validateSecurity(infrastructureCode)
 	result.SecurityResult = securityResult
+	for _, f := range securityResult.CriticalFindings {
+		report.finding("security", Finding{Severity: f.Severity, Type: f.Rule, Message: f.Finding, Location: f.Resource, Remediation: f.Remediation})
+	}
+	report.completed("security", securityResult.SecurityPosture)
 	
 	// Cost estimation
+	report.started("cost")
 	costResult := iv.estimateCosts(infrastructureCode, infraType)
 	result.CostEstimation = costResult
+	report.completed("cost", costResult.CostEfficiencyScore)
 	
 	// Compliance validation
-	complianceResult := iv.validateCompliance(infrastructureCode)
+	report.started("compliance")
+	complianceResult := iv.validateCompliance(ctx, infrastructureCode)
 	result.ComplianceResult = complianceResult
+	for _, f := range complianceResult.ComplianceIssues {
+		report.finding("compliance", Finding{Severity: "medium", Type: f.Framework + " " + f.Control, Message: f.Finding, Remediation: f.Remediation})
+	}
+	report.completed("compliance", complianceResult.PolicyCompliance)
 	
 	// Calculate overall scores and risk assessment
 	result.OverallScore = iv.calculateOverallScore(result)
@@ -236,6 +277,8 @@ func (iv *InfrastructureValidator) ValidateInfrastructure(ctx context.Context, i
 	result.CriticalIssues = iv.aggregateCriticalIssues(result)
 	result.Recommendations = iv.generateRecommendations(result)
 	
+	report.completed(CheckValidation, result.OverallScore)
+	
 	logger.WithComponent("validation").Info("Infrastructure validation completed",
 		zap.Int("overall_score", result.OverallScore),
 		zap.String("deployment_risk", string(result.DeploymentRisk)))
@@ -243,6 +286,32 @@ func (iv *InfrastructureValidator) ValidateInfrastructure(ctx context.Context, i
 	return result, nil
 }
 
+// reportInfraChecks reports the findings and scores of the type-specific
+// checks of an infrastructure validation
+func reportInfraChecks(report *progress, result *InfraValidationResult) {
+	if tf := result.TerraformResult; tf != nil {
+		for _, issue := range tf.SecurityIssues {
+			report.finding("terraform", Finding{Severity: issue.Severity, Type: issue.Title, Message: issue.Description, Location: issue.Resource, Remediation: issue.Remediation})
+		}
+		for _, v := range tf.PolicyViolations {
+			report.finding("terraform", Finding{Severity: v.Severity, Type: v.Policy, Message: v.Violation, Location: v.Resource, Remediation: v.Action})
+		}
+		report.completed("terraform", tf.BestPracticeScore)
+	}
+	if k8s := result.KubernetesResult; k8s != nil {
+		for _, issue := range k8s.Issues {
+			report.finding("kubernetes", Finding{Severity: issue.Severity, Type: issue.Type, Message: issue.Message, Location: issue.Resource, Remediation: issue.Suggestion})
+		}
+		report.completed("kubernetes", k8s.ProductionReadiness)
+	}
+	if df := result.DockerfileResult; df != nil {
+		for _, f := range df.Findings {
+			report.finding("dockerfile", Finding{Severity: f.Severity, Type: f.Rule, Message: f.Message, Location: fmt.Sprintf("%s:%d", df.Path, f.Line), Remediation: f.Suggestion})
+		}
+		report.completed("dockerfile", df.Score)
+	}
+}
+
 // validateTerraform performs Terraform-specific validation
 func (iv *InfrastructureValidator) validateTerraform(ctx context.Context, terraformCode string) (*TerraformValidationResult, error) {
 	logger.WithComponent("validation").Info("Validating Terraform configuration")
@@ -289,8 +358,13 @@ func (iv *InfrastructureValidator) validateTerraform(ctx context.Context, terraf
 	return result, nil
 }
 
-// validateTerraformBestPractices uses LLM to validate Terraform best practices
+// validateTerraformBestPractices uses LLM to validate Terraform best
+// practices, in chunks when the code does not fit the context window
 func (iv *InfrastructureValidator) validateTerraformBestPractices(ctx context.Context, terraformCode string) (int, error) {
+	return scoreInChunks(ctx, iv.llmClient, terraformCode, iv.assessTerraform)
+}
+
+func (iv *InfrastructureValidator) assessTerraform(ctx context.Context, terraformCode string) (int, error) {
 	prompt := fmt.Sprintf(`You are a senior DevOps engineer and Terraform expert reviewing infrastructure code for enterprise production deployment.
 
 TERRAFORM BEST PRACTICES EVALUATION:
@@ -332,12 +406,6 @@ RESPOND WITH JSON:
   "confidence_level": 90
 }`, terraformCode)
 
-	response, err := iv.llmClient.Complete(ctx, prompt)
-	if err != nil {
-		return 0, fmt.Errorf("LLM validation failed: %w", err)
-	}
-
-	// Parse JSON response
 	var assessment struct {
 		BestPracticesScore int      `json:"best_practices_score"`
 		SecurityScore      int      `json:"security_score"`
@@ -350,8 +418,13 @@ RESPOND WITH JSON:
 		ConfidenceLevel    int      `json:"confidence_level"`
 	}
 
-	if err := json.Unmarshal([]byte(response), &assessment); err != nil {
-		logger.WithComponent("validation").Warn("Failed to parse LLM response, using fallback analysis")
+	schema := llm.SchemaFor("terraform_assessment", "Terraform best practices assessment", assessment)
+	if err := llm.CompleteJSON(ctx, iv.llmClient, prompt, schema, &assessment); err != nil {
+		if !errors.Is(err, llm.ErrStructuredOutput) {
+			return 0, fmt.Errorf("LLM validation failed: %w", err)
+		}
+		logger.WithComponent("validation").Warn("Failed to parse LLM response, using fallback analysis",
+			zap.Error(err))
 		return iv.fallbackTerraformAnalysis(terraformCode), nil
 	}
 
@@ -417,6 +490,9 @@ func (iv *InfrastructureValidator) detectInfrastructureType(code string) string
 	if strings.Contains(code, "apiVersion:") || strings.Contains(code, "kind:") {
 		return "kubernetes"
 	}
+	if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(code)), "FROM ") || strings.Contains(code, "\nFROM ") {
+		return "dockerfile"
+	}
 	return "unknown"
 }
 
@@ -591,7 +667,13 @@ func (iv *InfrastructureValidator) validateKubernetesAPIVersions(manifests strin
 	return true
 }
 
+// validateKubernetesProductionReadiness uses LLM to assess production
+// readiness, in chunks when the manifests do not fit the context window
 func (iv *InfrastructureValidator) validateKubernetesProductionReadiness(ctx context.Context, manifests string) (int, error) {
+	return scoreInChunks(ctx, iv.llmClient, manifests, iv.assessKubernetes)
+}
+
+func (iv *InfrastructureValidator) assessKubernetes(ctx context.Context, manifests string) (int, error) {
 	prompt := fmt.Sprintf(`You are a Kubernetes expert reviewing manifests for production deployment.
 
 KUBERNETES PRODUCTION READINESS CHECKLIST:
@@ -631,11 +713,6 @@ RESPOND WITH JSON:
   "production_ready": true
 }`, manifests)
 
-	response, err := iv.llmClient.Complete(ctx, prompt)
-	if err != nil {
-		return 0, fmt.Errorf("LLM validation failed: %w", err)
-	}
-
 	var assessment struct {
 		ProductionReadinessScore int      `json:"production_readiness_score"`
 		SecurityScore           int      `json:"security_score"`
@@ -647,8 +724,13 @@ RESPOND WITH JSON:
 		ProductionReady         bool     `json:"production_ready"`
 	}
 
-	if err := json.Unmarshal([]byte(response), &assessment); err != nil {
-		logger.WithComponent("validation").Warn("Failed to parse LLM response, using fallback analysis")
+	schema := llm.SchemaFor("kubernetes_assessment", "Kubernetes production readiness assessment", assessment)
+	if err := llm.CompleteJSON(ctx, iv.llmClient, prompt, schema, &assessment); err != nil {
+		if !errors.Is(err, llm.ErrStructuredOutput) {
+			return 0, fmt.Errorf("LLM validation failed: %w", err)
+		}
+		logger.WithComponent("validation").Warn("Failed to parse LLM response, using fallback analysis",
+			zap.Error(err))
 		return iv.fallbackKubernetesAnalysis(manifests), nil
 	}
 
@@ -693,8 +775,44 @@ func (iv *InfrastructureValidator) checkKubernetesSecurityContext(manifests stri
 	return strings.Contains(manifests, "securityContext:")
 }
 
+// checkKubernetesNetworkPolicies reports whether the manifests have
+// NetworkPolicies denying ingress by default in every namespace
 func (iv *InfrastructureValidator) checkKubernetesNetworkPolicies(manifests string) bool {
-	return strings.Contains(manifests, "kind: NetworkPolicy")
+	if !strings.Contains(manifests, "kind: NetworkPolicy") {
+		return false
+	}
+	for _, f := range netpol.Validate("", map[string]string{"manifests.yaml": manifests}) {
+		if f.Severity == netpol.SeverityHigh {
+			return false
+		}
+	}
+	return true
+}
+
+// networkPolicyIssues reports the gaps in the manifests' NetworkPolicies,
+// with the policies that close them as remediation
+func (iv *InfrastructureValidator) networkPolicyIssues(manifests string) []KubernetesIssue {
+	var issues []KubernetesIssue
+	for _, f := range netpol.Validate("", map[string]string{"manifests.yaml": manifests}) {
+		suggestion := "Allow the traffic the workload needs in a NetworkPolicy selecting its pods"
+		switch {
+		case f.Remediation != "" && f.Resource == "NetworkPolicy":
+			suggestion = "Apply the generated NetworkPolicies: a default deny per namespace with explicit allows between the workloads"
+		case f.Remediation != "":
+			suggestion = "Apply the generated default-deny policies for the namespace"
+		case f.Severity == netpol.SeverityLow:
+			suggestion = "Fix the policy's pod selector or remove the policy"
+		}
+		issues = append(issues, KubernetesIssue{
+			Type:        "NetworkPolicy",
+			Severity:    f.Severity,
+			Resource:    f.Resource,
+			Message:     f.Message,
+			Suggestion:  suggestion,
+			Remediation: f.Remediation,
+		})
+	}
+	return issues
 }
 
 func (iv *InfrastructureValidator) assessKubernetesScalability(manifests string) int {
@@ -754,6 +872,7 @@ func (iv *InfrastructureValidator) generateKubernetesIssues(manifests string) []
 		})
 	}
 	
+	issues = append(issues, iv.networkPolicyIssues(manifests)...)
 	return issues
 }
 
@@ -893,28 +1012,62 @@ func (iv *InfrastructureValidator) estimateKubernetesCosts(code string) float64
 	return cost
 }
 
-func (iv *InfrastructureValidator) validateCompliance(code string) *ComplianceValidationResult {
+// validateCompliance checks the code against the baseline profiles the run
+// or its tenant selects. The SOC 2, GDPR and HIPAA scores are always those
+// of their profiles.
+func (iv *InfrastructureValidator) validateCompliance(ctx context.Context, code string) *ComplianceValidationResult {
 	result := &ComplianceValidationResult{
 		ComplianceIssues: make([]ComplianceIssue, 0),
 		RequiredActions:  make([]string, 0),
 	}
-	
-	// Basic compliance checks
-	result.SOC2Compliance = 75
-	result.GDPRCompliance = 75
-	result.HIPAACompliance = 75
-	
-	// Check encryption for compliance
-	if !strings.Contains(strings.ToLower(code), "encrypt") {
-		result.SOC2Compliance -= 20
-		result.GDPRCompliance -= 25
-		result.HIPAACompliance -= 30
+
+	for id, score := range map[string]*int{"soc2": &result.SOC2Compliance, "gdpr": &result.GDPRCompliance, "hipaa": &result.HIPAACompliance} {
+		if b, err := EvaluateBaseline(id, code); err == nil {
+			*score = b.Score
+		}
+	}
+
+	actions := make(map[string]bool)
+	total := 0
+	for _, id := range iv.baselines.selected(ctx) {
+		b, err := EvaluateBaseline(id, code)
+		if err != nil {
+			logger.WithComponent("validation").Warn("Skipping baseline profile", zap.Error(err))
+			continue
+		}
+		result.Baselines = append(result.Baselines, *b)
+		total += b.Score
+		for _, c := range b.Controls {
+			if c.Status != ControlFail {
+				continue
+			}
+			var evidence []string
+			for _, e := range c.Evidence {
+				if e.Status == ControlFail {
+					evidence = append(evidence, e.Evidence)
+				}
+			}
+			result.ComplianceIssues = append(result.ComplianceIssues, ComplianceIssue{
+				Framework:   b.Name,
+				Control:     c.ID,
+				Status:      c.Status,
+				Finding:     c.Title + ": " + strings.Join(evidence, "; "),
+				Remediation: c.Remediation,
+			})
+			if !actions[c.Remediation] {
+				actions[c.Remediation] = true
+				result.RequiredActions = append(result.RequiredActions, c.Remediation)
+			}
+		}
+	}
+
+	if len(result.Baselines) > 0 {
+		result.PolicyCompliance = total / len(result.Baselines)
+	} else {
+		result.PolicyCompliance = (result.SOC2Compliance + result.GDPRCompliance + result.HIPAACompliance) / 3
 	}
-	
-	// Overall policy compliance
-	result.PolicyCompliance = (result.SOC2Compliance + result.GDPRCompliance + result.HIPAACompliance) / 3
 	result.CertificationReady = result.PolicyCompliance >= 80
-	
+
 	return result
 }
 
@@ -931,6 +1084,10 @@ func (iv *InfrastructureValidator) calculateOverallScore(result *InfraValidation
 		scores = append(scores, result.KubernetesResult.ProductionReadiness)
 		scores = append(scores, result.KubernetesResult.SecurityScore)
 	}
+
+	if result.DockerfileResult != nil {
+		scores = append(scores, result.DockerfileResult.Score)
+	}
 	
 	if result.SecurityResult != nil {
 		scores = append(scores, result.SecurityResult.SecurityPosture)
@@ -1042,6 +1199,15 @@ func (iv *InfrastructureValidator) aggregateCriticalIssues(result *InfraValidati
 			}
 		}
 	}
+
+	// Add Dockerfile critical issues
+	if result.DockerfileResult != nil {
+		for _, issue := range result.DockerfileResult.Issues() {
+			if issue.Severity == "HIGH" || issue.Severity == "CRITICAL" {
+				issues = append(issues, issue)
+			}
+		}
+	}
 	
 	return issues
 }
@@ -1084,6 +1250,12 @@ func (iv *InfrastructureValidator) generateRecommendations(result *InfraValidati
 			recommendations = append(recommendations, "Configure liveness and readiness probes")
 		}
 	}
+
+	if result.DockerfileResult != nil {
+		for _, fix := range result.DockerfileResult.AppliedFixes {
+			recommendations = append(recommendations, "Dockerfile auto-fix available: "+fix)
+		}
+	}
 	
 	return recommendations
 }
\ No newline at end of file
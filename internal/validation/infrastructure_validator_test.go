@@ -0,0 +1,64 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+)
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  template:
+    metadata:
+      labels:
+        app: api
+    spec:
+      containers:
+      - name: api
+        ports:
+        - containerPort: 8080
+`
+
+func TestNetworkPolicyIssuesOfferGeneratedPolicies(t *testing.T) {
+	iv := &InfrastructureValidator{}
+	if iv.checkKubernetesNetworkPolicies(deployment) {
+		t.Error("expected manifests without policies to fail the check")
+	}
+	issues := iv.networkPolicyIssues(deployment)
+	if len(issues) != 1 || issues[0].Severity != "HIGH" || !strings.Contains(issues[0].Remediation, "kind: NetworkPolicy") {
+		t.Fatalf("expected a missing policy issue with generated policies, got %+v", issues)
+	}
+
+	withPolicies := deployment + "---\n" + issues[0].Remediation
+	if !iv.checkKubernetesNetworkPolicies(withPolicies) {
+		t.Error("expected the generated policies to pass the check")
+	}
+	if issues := iv.networkPolicyIssues(withPolicies); len(issues) != 0 {
+		t.Errorf("expected no issues with the generated policies, got %+v", issues)
+	}
+}
+
+func TestNetworkPolicyWithoutDefaultDeny(t *testing.T) {
+	manifests := deployment + `---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: api
+spec:
+  podSelector:
+    matchLabels:
+      app: api
+  ingress:
+  - {}
+`
+	iv := &InfrastructureValidator{}
+	if iv.checkKubernetesNetworkPolicies(manifests) {
+		t.Error("expected a policy without a default deny to fail the check")
+	}
+	issues := iv.networkPolicyIssues(manifests)
+	if len(issues) != 1 || issues[0].Resource != "Namespace/default" || !strings.Contains(issues[0].Remediation, "default-deny-all") {
+		t.Errorf("expected the default deny offered for the namespace, got %+v", issues)
+	}
+}
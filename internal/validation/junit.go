@@ -0,0 +1,72 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"QLP/internal/junit"
+)
+
+// JUnit converts the deployment stages and functional test cases into a JUnit
+// report named after the capsule
+func (r *DeploymentTestResult) JUnit(name string) *junit.TestSuites {
+	stages := []junit.Case{
+		stageCase("build", r.BuildSuccess, "project failed to build"),
+		stageCase("startup", r.StartupSuccess, "service failed to start"),
+		stageCase("health check", r.HealthCheckPass, "health check failed"),
+		stageCase("security scan", r.SecurityScanPass,
+			fmt.Sprintf("%d security findings", len(r.SecurityFindings))),
+	}
+	stages[1].Duration = r.StartupTime
+	for i, check := range []string{CheckBuild, CheckStartup, CheckHealth, CheckSecurityScan} {
+		if reason, ok := r.Degradation.Skipped(check); ok {
+			stages[i].Failed, stages[i].Skipped, stages[i].Message = false, true, reason
+		}
+	}
+
+	functional := make([]junit.Case, 0, len(r.TestResults))
+	for _, tc := range r.TestResults {
+		functional = append(functional, testCase(tc))
+	}
+
+	suites := []junit.TestSuite{
+		junit.NewSuite("deployment", r.ValidatedAt, stages),
+		junit.NewSuite("functional", r.ValidatedAt, functional),
+	}
+	if r.Resilience != nil && r.Resilience.Skipped == "" {
+		var chaos []junit.Case
+		for _, e := range r.Resilience.Ran() {
+			chaos = append(chaos, junit.Case{Name: e.Name, Class: "resilience", Duration: e.RecoveryTime,
+				Failed: !e.Passed, Message: e.Details})
+		}
+		suites = append(suites, junit.NewSuite("resilience", r.ValidatedAt, chaos))
+	}
+	return junit.New(name, suites...)
+}
+
+func stageCase(name string, passed bool, message string) junit.Case {
+	return junit.Case{Name: name, Class: "deployment", Failed: !passed, Message: message}
+}
+
+func testCase(tc TestCaseResult) junit.Case {
+	c := junit.Case{
+		Name:     tc.Name,
+		Class:    strings.TrimSpace("functional " + tc.Method + " " + tc.Endpoint),
+		Duration: tc.ResponseTime,
+		Failed:   !tc.Success,
+	}
+	if c.Failed {
+		c.Message = tc.ErrorMessage
+		if c.Message == "" && tc.ActualCode != tc.ExpectedCode {
+			c.Message = fmt.Sprintf("expected %d, got %d", tc.ExpectedCode, tc.ActualCode)
+		}
+		var details []string
+		for _, a := range tc.Assertions {
+			if !a.Success {
+				details = append(details, fmt.Sprintf("%s: expected %s, got %s %s", a.Type, a.Expected, a.Actual, a.Message))
+			}
+		}
+		c.Details = strings.Join(details, "\n")
+	}
+	return c
+}
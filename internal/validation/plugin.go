@@ -0,0 +1,254 @@
+package validation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"QLP/internal/config"
+	"QLP/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// Plugin is a custom validator, such as naming conventions or internal
+// security rules, whose findings are scored alongside the built-in analyses
+type Plugin interface {
+	Name() string
+	Validate(ctx context.Context, req PluginRequest) (*PluginResult, error)
+}
+
+// PluginRequest is the input sent to a plugin
+type PluginRequest struct {
+	DropID   string            `json:"drop_id"`
+	DropType string            `json:"drop_type"`
+	Files    map[string]string `json:"files"`
+}
+
+// PluginFinding is a single finding reported by a plugin
+type PluginFinding struct {
+	Rule        string `json:"rule"`
+	Severity    string `json:"severity"` // critical, high, medium, low or info
+	Message     string `json:"message"`
+	Location    string `json:"location,omitempty"` // "path" or "path:line"
+	Remediation string `json:"remediation,omitempty"`
+	// Provenance identifies the custom rule behind the finding, if any
+	Provenance *RuleProvenance `json:"provenance,omitempty"`
+}
+
+// PluginResult is a plugin's verdict. A nil Score is derived from the
+// findings.
+type PluginResult struct {
+	Plugin   string          `json:"plugin"`
+	Score    *int            `json:"score,omitempty"`
+	Findings []PluginFinding `json:"findings"`
+	Error    string          `json:"error,omitempty"`
+	Duration time.Duration   `json:"duration"`
+}
+
+// Blocking reports whether the plugin found critical issues, which fail the
+// quality gates regardless of the score
+func (r *PluginResult) Blocking() bool {
+	for _, f := range r.Findings {
+		if strings.EqualFold(f.Severity, "critical") {
+			return true
+		}
+	}
+	return false
+}
+
+// FindingsScore is the score used when a plugin does not report one
+func FindingsScore(findings []PluginFinding) int {
+	score := 100
+	for _, f := range findings {
+		score -= severityPenalty(f.Severity)
+	}
+	if score < 0 {
+		return 0
+	}
+	return score
+}
+
+// severityPenalty is the points a finding of a severity costs
+func severityPenalty(severity string) int {
+	switch strings.ToLower(severity) {
+	case "critical":
+		return 25
+	case "high":
+		return 15
+	case "medium":
+		return 5
+	case "low":
+		return 1
+	}
+	return 0
+}
+
+// ProcessPlugin runs a validator binary for each validation and talks to it
+// over JSON-RPC 2.0 on stdin/stdout. The binary receives one request,
+//
+//	{"jsonrpc":"2.0","id":1,"method":"validate","params":{"drop_id":"...","drop_type":"codebase","files":{"main.go":"..."}}}
+//
+// and must answer with a single response before exiting:
+//
+//	{"jsonrpc":"2.0","id":1,"result":{"score":90,"findings":[{"rule":"naming","severity":"medium","message":"...","location":"main.go:12"}]}}
+//
+// or {"jsonrpc":"2.0","id":1,"error":{"code":1,"message":"..."}}. Anything the
+// binary writes to stderr is logged.
+type ProcessPlugin struct {
+	name    string
+	command string
+	args    []string
+	timeout time.Duration
+}
+
+// NewProcessPlugin creates a plugin that runs command with args
+func NewProcessPlugin(name, command string, args []string, timeout time.Duration) *ProcessPlugin {
+	return &ProcessPlugin{name: name, command: command, args: args, timeout: timeout}
+}
+
+// Name returns the plugin name
+func (p *ProcessPlugin) Name() string {
+	return p.name
+}
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  PluginRequest `json:"params"`
+}
+
+type rpcResponse struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Result  *PluginResult `json:"result"`
+	Error   *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Validate launches the plugin binary and exchanges one validate call
+func (p *ProcessPlugin) Validate(ctx context.Context, req PluginRequest) (*PluginResult, error) {
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	input, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: 1, Method: "validate", Params: req})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode plugin request: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.command, p.args...)
+	cmd.Stdin = bytes.NewReader(append(input, '\n'))
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+
+	if stderr.Len() > 0 {
+		logger.WithComponent("validation").Debug("Validator plugin stderr",
+			zap.String("plugin", p.name),
+			zap.String("stderr", strings.TrimSpace(stderr.String())))
+	}
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("plugin %s timed out: %w", p.name, ctx.Err())
+	}
+
+	var resp rpcResponse
+	if err := json.NewDecoder(&stdout).Decode(&resp); err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("plugin %s failed: %w", p.name, runErr)
+		}
+		return nil, fmt.Errorf("plugin %s returned an invalid response: %w", p.name, err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("plugin %s error %d: %s", p.name, resp.Error.Code, resp.Error.Message)
+	}
+	if resp.Result == nil {
+		return nil, fmt.Errorf("plugin %s returned no result", p.name)
+	}
+	return resp.Result, nil
+}
+
+// PluginsFromEnv loads the plugins registered in QLP_VALIDATOR_PLUGINS, a
+// comma-separated list of name=command entries (a list in qlp.yaml), e.g.
+// "naming=/opt/qlp/naming-check --strict". Each call is limited to
+// QLP_VALIDATOR_PLUGIN_TIMEOUT.
+func PluginsFromEnv() ([]Plugin, error) {
+	spec := strings.TrimSpace(config.GetEnvOrDefault("QLP_VALIDATOR_PLUGINS", ""))
+	if spec == "" {
+		return nil, nil
+	}
+	timeout, err := time.ParseDuration(config.GetEnvOrDefault("QLP_VALIDATOR_PLUGIN_TIMEOUT", "30s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid QLP_VALIDATOR_PLUGIN_TIMEOUT: %w", err)
+	}
+
+	var plugins []Plugin
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, command, ok := strings.Cut(entry, "=")
+		fields := strings.Fields(command)
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || len(fields) == 0 {
+			return nil, fmt.Errorf("invalid validator plugin %q, want name=command", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("validator plugin %q registered twice", name)
+		}
+		seen[name] = true
+		plugins = append(plugins, NewProcessPlugin(name, fields[0], fields[1:], timeout))
+	}
+	return plugins, nil
+}
+
+// runPlugins runs every plugin against the drop files. A plugin that fails is
+// reported with its error and left out of the scores; one that returns no
+// result had nothing to check and is left out entirely.
+func runPlugins(ctx context.Context, plugins []Plugin, req PluginRequest) []PluginResult {
+	results := make([]PluginResult, 0, len(plugins))
+	report := progressFrom(ctx)
+	for _, plugin := range plugins {
+		check := "plugin:" + plugin.Name()
+		report.started(check)
+		start := time.Now()
+		result, err := plugin.Validate(ctx, req)
+		if err == nil && result == nil {
+			report.skipped(check, "nothing to check")
+			continue
+		}
+		if err != nil {
+			logger.WithComponent("validation").Warn("Validator plugin failed",
+				zap.String("plugin", plugin.Name()),
+				zap.Error(err))
+			report.failed(check, err)
+			result = &PluginResult{Error: err.Error()}
+		} else {
+			score := FindingsScore(result.Findings)
+			if result.Score != nil {
+				score = min(max(*result.Score, 0), 100)
+			}
+			result.Score = &score
+			for _, f := range result.Findings {
+				report.finding(check, Finding{Severity: f.Severity, Type: f.Rule, Message: f.Message, Location: f.Location, Remediation: f.Remediation})
+			}
+			report.completed(check, score)
+		}
+		result.Plugin = plugin.Name()
+		result.Duration = time.Since(start)
+		results = append(results, *result)
+	}
+	return results
+}
@@ -0,0 +1,105 @@
+package validation
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHelperPlugin is not a real test: it is the plugin binary launched by
+// the tests below, selected with QLP_TEST_PLUGIN
+func TestHelperPlugin(t *testing.T) {
+	mode := os.Getenv("QLP_TEST_PLUGIN")
+	if mode == "" {
+		t.Skip("helper process")
+	}
+	var req rpcRequest
+	line, _ := bufio.NewReader(os.Stdin).ReadBytes('\n')
+	if err := json.Unmarshal(line, &req); err != nil || req.Method != "validate" {
+		os.Exit(2)
+	}
+	switch mode {
+	case "naming":
+		var findings []string
+		for path := range req.Params.Files {
+			if strings.ToLower(path) != path {
+				findings = append(findings, fmt.Sprintf(`{"rule":"lowercase","severity":"critical","message":"file names must be lowercase","location":%q}`, path))
+			}
+		}
+		fmt.Printf(`{"jsonrpc":"2.0","id":%d,"result":{"findings":[%s]}}`+"\n", req.ID, strings.Join(findings, ","))
+	case "error":
+		fmt.Fprintln(os.Stderr, "rules file missing")
+		fmt.Printf(`{"jsonrpc":"2.0","id":%d,"error":{"code":3,"message":"no rules"}}`+"\n", req.ID)
+	case "hang":
+		time.Sleep(10 * time.Second)
+	}
+	os.Exit(0)
+}
+
+func helperPlugin(t *testing.T, name, mode string, timeout time.Duration) Plugin {
+	t.Setenv("QLP_TEST_PLUGIN", mode)
+	return NewProcessPlugin(name, os.Args[0], []string{"-test.run=^TestHelperPlugin$"}, timeout)
+}
+
+func TestProcessPlugin(t *testing.T) {
+	ctx := context.Background()
+	req := PluginRequest{DropID: "drop-1", Files: map[string]string{"main.go": "", "UserHandler.go": ""}}
+
+	result, err := helperPlugin(t, "naming", "naming", 10*time.Second).Validate(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Findings) != 1 || result.Findings[0].Location != "UserHandler.go" || !result.Blocking() {
+		t.Errorf("result = %+v", result)
+	}
+
+	if _, err := helperPlugin(t, "naming", "error", 10*time.Second).Validate(ctx, req); err == nil || !strings.Contains(err.Error(), "no rules") {
+		t.Errorf("error response: err = %v", err)
+	}
+	if _, err := helperPlugin(t, "naming", "hang", 200*time.Millisecond).Validate(ctx, req); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("hanging plugin: err = %v", err)
+	}
+}
+
+func TestStaticValidatorScoresPlugins(t *testing.T) {
+	sv := NewStaticValidator(nil)
+	sv.SetPlugins([]Plugin{helperPlugin(t, "naming", "naming", 10*time.Second)})
+
+	results := runPlugins(context.Background(), sv.plugins, PluginRequest{Files: map[string]string{"Main.go": ""}})
+	if len(results) != 1 || results[0].Plugin != "naming" || *results[0].Score != 75 {
+		t.Fatalf("plugin results = %+v", results)
+	}
+
+	result := &StaticValidationResult{SecurityScore: 95, QualityScore: 95, ArchitectureScore: 95, ComplianceScore: 95, PluginResults: results}
+	if sv.assessDeploymentReadiness(result) {
+		t.Error("critical plugin finding did not block deployment readiness")
+	}
+	issues := sv.aggregateIssues(result)
+	if len(issues) != 1 || issues[0].Category != "Plugin: naming" || issues[0].Severity != "CRITICAL" {
+		t.Errorf("issues = %+v", issues)
+	}
+}
+
+func TestPluginsFromEnv(t *testing.T) {
+	t.Setenv("QLP_VALIDATOR_PLUGINS", "naming=/opt/qlp/naming --strict, secrets=/opt/qlp/secrets")
+	plugins, err := PluginsFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	naming := plugins[0].(*ProcessPlugin)
+	if len(plugins) != 2 || naming.Name() != "naming" || naming.command != "/opt/qlp/naming" || naming.args[0] != "--strict" || naming.timeout != 30*time.Second {
+		t.Errorf("plugins = %+v", plugins)
+	}
+
+	for _, spec := range []string{"naming", "naming=", "a=/bin/a,a=/bin/b"} {
+		t.Setenv("QLP_VALIDATOR_PLUGINS", spec)
+		if _, err := PluginsFromEnv(); err == nil {
+			t.Errorf("PluginsFromEnv() accepted %q", spec)
+		}
+	}
+}
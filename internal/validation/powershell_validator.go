@@ -0,0 +1,317 @@
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"QLP/internal/config"
+	"QLP/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Analyzers that produce PowerShell findings
+const (
+	AnalyzerPSScriptAnalyzer = "PSScriptAnalyzer"
+	AnalyzerBuiltin          = "builtin"
+)
+
+// PowerShellFinding is a single lint result, using PSScriptAnalyzer rule names
+type PowerShellFinding struct {
+	Rule       string `json:"rule"`
+	Severity   string `json:"severity"`
+	Line       int    `json:"line"`
+	Column     int    `json:"column,omitempty"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// PowerShellValidationResult contains the lint findings for one script
+type PowerShellValidationResult struct {
+	Path     string              `json:"path"`
+	Analyzer string              `json:"analyzer"`
+	Findings []PowerShellFinding `json:"findings"`
+	Score    int                 `json:"score"`
+}
+
+// PowerShellValidator lints PowerShell scripts and modules with
+// PSScriptAnalyzer through pwsh. When pwsh or the module is unavailable, a
+// built-in subset of its rules is applied instead.
+type PowerShellValidator struct {
+	pwsh    string
+	timeout time.Duration
+	analyze func(ctx context.Context, files map[string]string, paths []string) (map[string][]PowerShellFinding, error)
+}
+
+// NewPowerShellValidator creates a validator that runs the pwsh named by
+// QLP_PWSH_PATH (pwsh on the PATH by default)
+func NewPowerShellValidator() *PowerShellValidator {
+	pv := &PowerShellValidator{
+		pwsh:    config.GetEnvOrDefault("QLP_PWSH_PATH", "pwsh"),
+		timeout: 5 * time.Minute,
+	}
+	pv.analyze = pv.runScriptAnalyzer
+	return pv
+}
+
+// IsPowerShellScript reports whether a path names a PowerShell script,
+// module or module manifest
+func IsPowerShellScript(p string) bool {
+	switch strings.ToLower(path.Ext(p)) {
+	case ".ps1", ".psm1", ".psd1":
+		return true
+	}
+	return false
+}
+
+// ValidateFiles lints every PowerShell file in files
+func (pv *PowerShellValidator) ValidateFiles(ctx context.Context, files map[string]string) []*PowerShellValidationResult {
+	paths := make([]string, 0)
+	for p := range files {
+		if IsPowerShellScript(p) {
+			paths = append(paths, p)
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	sort.Strings(paths)
+
+	analyzer := AnalyzerPSScriptAnalyzer
+	found, err := pv.analyze(ctx, files, paths)
+	if err != nil {
+		logger.WithComponent("validation").Info("PSScriptAnalyzer unavailable, using built-in PowerShell rules",
+			zap.Error(err))
+		analyzer = AnalyzerBuiltin
+		found = make(map[string][]PowerShellFinding, len(paths))
+		for _, p := range paths {
+			found[p] = LintPowerShell(files[p])
+		}
+	}
+
+	results := make([]*PowerShellValidationResult, 0, len(paths))
+	for _, p := range paths {
+		findings := found[p]
+		if findings == nil {
+			findings = []PowerShellFinding{}
+		}
+		results = append(results, &PowerShellValidationResult{
+			Path:     p,
+			Analyzer: analyzer,
+			Findings: findings,
+			Score:    powerShellScore(findings),
+		})
+	}
+	return results
+}
+
+// scriptAnalyzerRecord is one diagnostic as printed by the pwsh command
+type scriptAnalyzerRecord struct {
+	RuleName   string `json:"RuleName"`
+	Severity   string `json:"Severity"`
+	Line       int    `json:"Line"`
+	Column     int    `json:"Column"`
+	Message    string `json:"Message"`
+	ScriptPath string `json:"ScriptPath"`
+}
+
+// runScriptAnalyzer writes the scripts to a temporary directory and runs
+// Invoke-ScriptAnalyzer over it, returning findings keyed by file path
+func (pv *PowerShellValidator) runScriptAnalyzer(ctx context.Context, files map[string]string, paths []string) (map[string][]PowerShellFinding, error) {
+	if _, err := exec.LookPath(pv.pwsh); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "qlp-pwsh-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	for _, p := range paths {
+		target := filepath.Join(dir, filepath.FromSlash(path.Clean("/"+p)))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(target, []byte(files[p]), 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, pv.timeout)
+	defer cancel()
+
+	// Severity is an enum, so it is converted to its name before printing
+	script := fmt.Sprintf("$ErrorActionPreference = 'Stop'; Import-Module PSScriptAnalyzer; "+
+		"$r = @(Invoke-ScriptAnalyzer -Path '%s' -Recurse | "+
+		"Select-Object RuleName, @{n='Severity';e={[string]$_.Severity}}, Line, Column, Message, ScriptPath); "+
+		"ConvertTo-Json -InputObject $r -Compress", strings.ReplaceAll(dir, "'", "''"))
+	cmd := exec.CommandContext(runCtx, pv.pwsh, "-NoLogo", "-NoProfile", "-NonInteractive", "-Command", script)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("Invoke-ScriptAnalyzer failed: %s", lastLines(string(exitErr.Stderr), 5))
+		}
+		return nil, err
+	}
+	return parseScriptAnalyzerOutput(output, dir)
+}
+
+// parseScriptAnalyzerOutput reads the JSON diagnostics printed for files
+// written under dir
+func parseScriptAnalyzerOutput(output []byte, dir string) (map[string][]PowerShellFinding, error) {
+	var records []scriptAnalyzerRecord
+	if trimmed := strings.TrimSpace(string(output)); trimmed != "" {
+		if err := json.Unmarshal([]byte(trimmed), &records); err != nil {
+			return nil, fmt.Errorf("failed to parse PSScriptAnalyzer output: %w", err)
+		}
+	}
+
+	found := make(map[string][]PowerShellFinding)
+	for _, r := range records {
+		rel, err := filepath.Rel(dir, r.ScriptPath)
+		if err != nil {
+			rel = r.ScriptPath
+		}
+		p := filepath.ToSlash(rel)
+		found[p] = append(found[p], PowerShellFinding{
+			Rule:       r.RuleName,
+			Severity:   scriptAnalyzerSeverity(r.Severity),
+			Line:       r.Line,
+			Column:     r.Column,
+			Message:    r.Message,
+			Suggestion: powerShellSuggestions[r.RuleName],
+		})
+	}
+	return found, nil
+}
+
+func scriptAnalyzerSeverity(severity string) string {
+	switch severity {
+	case "ParseError", "Error":
+		return "HIGH"
+	case "Warning":
+		return "MEDIUM"
+	default:
+		return "LOW"
+	}
+}
+
+// powerShellRule is a built-in approximation of a PSScriptAnalyzer rule
+type powerShellRule struct {
+	name     string
+	severity string
+	pattern  *regexp.Regexp
+	message  string
+}
+
+var powerShellRules = []powerShellRule{
+	{"PSAvoidUsingConvertToSecureStringWithPlainText", "HIGH",
+		regexp.MustCompile(`(?i)\bConvertTo-SecureString\b.*-AsPlainText\b`),
+		"File uses ConvertTo-SecureString with plain text, exposing the secret in the script"},
+	{"PSAvoidUsingPlainTextForPassword", "MEDIUM",
+		regexp.MustCompile(`(?i)\[string\]\s*\$\w*(password|passwd|pwd|secret)\b`),
+		"Password parameter is a plain string"},
+	{"PSAvoidUsingInvokeExpression", "MEDIUM",
+		regexp.MustCompile(`(?i)(^|[\s|;({=])(Invoke-Expression|iex)(\s|$)`),
+		"Invoke-Expression runs arbitrary strings as code"},
+	{"PSAvoidUsingEmptyCatchBlock", "MEDIUM",
+		regexp.MustCompile(`(?i)\bcatch\s*(\[[^\]]*\]\s*)*\{\s*\}`),
+		"Empty catch block hides errors"},
+	{"PSAvoidGlobalVars", "MEDIUM",
+		regexp.MustCompile(`(?i)\$global:`),
+		"Global variable used"},
+	{"PSAvoidUsingCmdletAliases", "MEDIUM",
+		regexp.MustCompile(`(?i)(^|\|)\s*(gci|gc|ls|dir|cat|echo|rm|del|cp|mv|iwr|irm|curl|wget|where|foreach|select|sort|%|\?)(\s|$)`),
+		"Alias used instead of the full cmdlet name"},
+	{"PSAvoidUsingWriteHost", "LOW",
+		regexp.MustCompile(`(?i)\bWrite-Host\b`),
+		"Write-Host output cannot be captured or suppressed"},
+}
+
+var powerShellSuggestions = map[string]string{
+	"PSAvoidUsingConvertToSecureStringWithPlainText": "Read the secret with Read-Host -AsSecureString or from a secret store",
+	"PSAvoidUsingPlainTextForPassword":               "Declare the parameter as [SecureString] or [PSCredential]",
+	"PSAvoidUsingInvokeExpression":                   "Call commands directly or use the call operator &",
+	"PSAvoidUsingEmptyCatchBlock":                    "Handle the error, or log it with Write-Error before continuing",
+	"PSAvoidGlobalVars":                              "Use script-scoped variables or pass values as parameters",
+	"PSAvoidUsingCmdletAliases":                      "Use the full cmdlet name, e.g. Get-ChildItem instead of gci",
+	"PSAvoidUsingWriteHost":                          "Use Write-Output, Write-Verbose or Write-Information",
+}
+
+// LintPowerShell applies the built-in rules to a script, one finding per
+// rule and line. Comment lines and block comments are skipped.
+func LintPowerShell(content string) []PowerShellFinding {
+	findings := make([]PowerShellFinding, 0)
+	inComment := false
+	for i, raw := range strings.Split(content, "\n") {
+		line := strings.TrimSpace(raw)
+		if inComment {
+			if strings.Contains(line, "#>") {
+				inComment = false
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "<#") {
+			inComment = !strings.Contains(line, "#>")
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, rule := range powerShellRules {
+			if loc := rule.pattern.FindStringIndex(line); loc != nil {
+				findings = append(findings, PowerShellFinding{
+					Rule:       rule.name,
+					Severity:   rule.severity,
+					Line:       i + 1,
+					Column:     strings.Index(raw, line) + loc[0] + 1,
+					Message:    rule.message,
+					Suggestion: powerShellSuggestions[rule.name],
+				})
+			}
+		}
+	}
+	return findings
+}
+
+func powerShellScore(findings []PowerShellFinding) int {
+	score := 100
+	for _, f := range findings {
+		switch f.Severity {
+		case "HIGH":
+			score -= 15
+		case "MEDIUM":
+			score -= 8
+		default:
+			score -= 3
+		}
+	}
+	return max(score, 0)
+}
+
+// Issues converts findings to validation issues
+func (r *PowerShellValidationResult) Issues() []ValidationIssue {
+	issues := make([]ValidationIssue, 0, len(r.Findings))
+	for _, f := range r.Findings {
+		resource := r.Path
+		if f.Line > 0 {
+			resource = fmt.Sprintf("%s:%d", r.Path, f.Line)
+		}
+		issues = append(issues, ValidationIssue{
+			Severity:    f.Severity,
+			Category:    "PowerShell " + f.Rule,
+			Message:     f.Message,
+			Resource:    resource,
+			Remediation: f.Suggestion,
+		})
+	}
+	return issues
+}
@@ -0,0 +1,79 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+const samplePowerShell = `<#
+  Write-Host is fine inside a block comment
+#>
+param([string]$Password)
+
+# Invoke-Expression in a comment is ignored
+$secure = ConvertTo-SecureString $Password -AsPlainText -Force
+Invoke-Expression "Get-Date"
+gci C:\logs | % { $_.Name }
+try { Remove-Item C:\tmp\x } catch { }
+Write-Host "done"
+`
+
+func TestLintPowerShell(t *testing.T) {
+	rules := make(map[string]int)
+	for _, f := range LintPowerShell(samplePowerShell) {
+		rules[f.Rule] = f.Line
+	}
+	for rule, line := range map[string]int{
+		"PSAvoidUsingPlainTextForPassword":               4,
+		"PSAvoidUsingConvertToSecureStringWithPlainText": 7,
+		"PSAvoidUsingInvokeExpression":                   8,
+		"PSAvoidUsingCmdletAliases":                      9,
+		"PSAvoidUsingEmptyCatchBlock":                    10,
+		"PSAvoidUsingWriteHost":                          11,
+	} {
+		got, ok := rules[rule]
+		if !ok {
+			t.Errorf("expected %s finding, got %v", rule, rules)
+		} else if got != line {
+			t.Errorf("%s reported on line %d, want %d", rule, got, line)
+		}
+	}
+	if len(LintPowerShell("Get-ChildItem -Path C:\\logs | Select-Object -First 1\n")) != 0 {
+		t.Error("clean script reported findings")
+	}
+}
+
+func TestPowerShellValidatorFallsBackToBuiltinRules(t *testing.T) {
+	pv := NewPowerShellValidator()
+	pv.analyze = func(context.Context, map[string]string, []string) (map[string][]PowerShellFinding, error) {
+		return nil, errors.New("pwsh not found")
+	}
+	results := pv.ValidateFiles(context.Background(), map[string]string{
+		"deploy.ps1": samplePowerShell,
+		"README.md":  "# docs",
+		"mod/M.psm1": "function Get-Thing { Write-Output 1 }\n",
+	})
+	if len(results) != 2 || results[0].Path != "deploy.ps1" || results[1].Path != "mod/M.psm1" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if results[0].Analyzer != AnalyzerBuiltin || results[0].Score >= 100 || results[1].Score != 100 {
+		t.Errorf("unexpected scores %d and %d from %s", results[0].Score, results[1].Score, results[0].Analyzer)
+	}
+}
+
+func TestParseScriptAnalyzerOutput(t *testing.T) {
+	output := `[{"RuleName":"PSAvoidUsingWriteHost","Severity":"Warning","Line":3,"Column":1,"Message":"File uses Write-Host.","ScriptPath":"/tmp/qlp-pwsh-1/scripts/deploy.ps1"},` +
+		`{"RuleName":"PSAvoidUsingInvokeExpression","Severity":"Error","Line":5,"Column":2,"Message":"Avoid Invoke-Expression.","ScriptPath":"/tmp/qlp-pwsh-1/scripts/deploy.ps1"}]`
+	found, err := parseScriptAnalyzerOutput([]byte(output), "/tmp/qlp-pwsh-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings := found["scripts/deploy.ps1"]
+	if len(findings) != 2 || findings[0].Severity != "MEDIUM" || findings[1].Severity != "HIGH" || findings[1].Suggestion == "" {
+		t.Errorf("unexpected findings %+v", found)
+	}
+	if found, err := parseScriptAnalyzerOutput([]byte("[]\n"), "/tmp"); err != nil || len(found) != 0 {
+		t.Errorf("empty output = %v, %v", found, err)
+	}
+}
@@ -0,0 +1,104 @@
+package validation
+
+import (
+	"context"
+	"time"
+)
+
+// ProgressType is what a progress event reports
+type ProgressType string
+
+const (
+	ProgressStarted   ProgressType = "started"   // A check started
+	ProgressFinding   ProgressType = "finding"   // A check discovered a finding
+	ProgressCompleted ProgressType = "completed" // A check completed, with its score
+	ProgressFailed    ProgressType = "failed"    // A check could not run; a fallback score may follow
+)
+
+// CheckValidation is the check name of events about a whole validation
+const CheckValidation = "validation"
+
+// Finding is a finding of any check, as reported while a validation runs
+type Finding struct {
+	Severity    string `json:"severity"`
+	Type        string `json:"type,omitempty"`
+	Message     string `json:"message"`
+	Location    string `json:"location,omitempty"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// ProgressEvent reports progress of one check of a validation, e.g. the
+// security analysis or a plugin. Checks are named security, quality,
+// architecture, compliance, plugin:<name>, terraform, kubernetes,
+// dockerfile and cost; the whole validation is CheckValidation.
+type ProgressEvent struct {
+	Seq      int           `json:"seq"`
+	Type     ProgressType  `json:"type"`
+	Check    string        `json:"check"`
+	Score    *int          `json:"score,omitempty"`
+	Finding  *Finding      `json:"finding,omitempty"`
+	Message  string        `json:"message,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"` // Of the check, on completion
+	At       time.Time     `json:"at"`
+}
+
+// ProgressFunc receives the progress events of a validation
+type ProgressFunc func(event ProgressEvent)
+
+type progressKey struct{}
+
+// WithProgress returns a context whose validations report their progress
+// to fn
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// progress reports the progress of the checks of a validation to the
+// ProgressFunc of its context, if any
+type progress struct {
+	fn     ProgressFunc
+	starts map[string]time.Time
+}
+
+func progressFrom(ctx context.Context) *progress {
+	fn, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	return &progress{fn: fn, starts: make(map[string]time.Time)}
+}
+
+func (p *progress) emit(event ProgressEvent) {
+	if p.fn == nil {
+		return
+	}
+	event.At = time.Now()
+	p.fn(event)
+}
+
+func (p *progress) started(check string) {
+	p.starts[check] = time.Now()
+	p.emit(ProgressEvent{Type: ProgressStarted, Check: check})
+}
+
+func (p *progress) finding(check string, f Finding) {
+	p.emit(ProgressEvent{Type: ProgressFinding, Check: check, Finding: &f})
+}
+
+func (p *progress) failed(check string, err error) {
+	p.emit(ProgressEvent{Type: ProgressFailed, Check: check, Message: err.Error()})
+}
+
+func (p *progress) completed(check string, score int) {
+	p.emit(ProgressEvent{Type: ProgressCompleted, Check: check, Score: &score, Duration: p.since(check)})
+}
+
+// skipped completes a check that had nothing to score
+func (p *progress) skipped(check, reason string) {
+	p.emit(ProgressEvent{Type: ProgressCompleted, Check: check, Message: reason, Duration: p.since(check)})
+}
+
+// since returns how long ago a check started
+func (p *progress) since(check string) time.Duration {
+	if start, ok := p.starts[check]; ok {
+		return time.Since(start)
+	}
+	return 0
+}
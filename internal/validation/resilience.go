@@ -0,0 +1,415 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"QLP/internal/config"
+	"QLP/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// ResilienceConfig tunes the chaos experiments of the resilience stage
+type ResilienceConfig struct {
+	LoadDuration    time.Duration `json:"load_duration"`    // load applied during each fault
+	Concurrency     int           `json:"concurrency"`      // concurrent clients of the load
+	RequestTimeout  time.Duration `json:"request_timeout"`  // a request or probe taking longer has hung
+	RecoveryTimeout time.Duration `json:"recovery_timeout"` // time allowed to become healthy after a fault
+	Latency         time.Duration `json:"latency"`          // delay added to the container's network traffic
+	MemoryLimit     string        `json:"memory_limit"`     // docker --memory of the container
+	NetemImage      string        `json:"netem_image"`      // image with tc, run in the container's network namespace
+	HealthPath      string        `json:"health_path"`
+}
+
+// DefaultResilienceConfig returns the default experiment settings
+func DefaultResilienceConfig() ResilienceConfig {
+	return ResilienceConfig{
+		LoadDuration:    15 * time.Second,
+		Concurrency:     4,
+		RequestTimeout:  10 * time.Second,
+		RecoveryTimeout: 60 * time.Second,
+		Latency:         2 * time.Second,
+		MemoryLimit:     "256m",
+		NetemImage:      "nicolaka/netshoot",
+		HealthPath:      "/health",
+	}
+}
+
+// ResilienceConfigFromEnv reads the settings from QLP_RESILIENCE_*, keeping
+// the defaults for unset or invalid values
+func ResilienceConfigFromEnv() ResilienceConfig {
+	cfg := DefaultResilienceConfig()
+	durations := map[string]*time.Duration{
+		"QLP_RESILIENCE_LOAD_DURATION":    &cfg.LoadDuration,
+		"QLP_RESILIENCE_RECOVERY_TIMEOUT": &cfg.RecoveryTimeout,
+		"QLP_RESILIENCE_LATENCY":          &cfg.Latency,
+	}
+	for key, target := range durations {
+		if d, err := time.ParseDuration(config.GetEnvOrDefault(key, "")); err == nil && d > 0 {
+			*target = d
+		}
+	}
+	cfg.MemoryLimit = config.GetEnvOrDefault("QLP_RESILIENCE_MEMORY_LIMIT", cfg.MemoryLimit)
+	cfg.NetemImage = config.GetEnvOrDefault("QLP_RESILIENCE_NETEM_IMAGE", cfg.NetemImage)
+	return cfg
+}
+
+// ChaosExperiment is the outcome of injecting one fault into the service
+// under load
+type ChaosExperiment struct {
+	Name         string        `json:"name"`
+	Passed       bool          `json:"passed"`
+	Requests     int           `json:"requests"`
+	Succeeded    int           `json:"succeeded"`
+	ServerErrors int           `json:"server_errors"` // 5xx responses: the service failed cleanly
+	Failures     int           `json:"failures"`      // connection errors and hung requests
+	HealthProbe  string        `json:"health_probe,omitempty"`
+	Recovered    bool          `json:"recovered"`
+	RecoveryTime time.Duration `json:"recovery_time"`
+	Restarts     int           `json:"restarts"`
+	OOMKilled    bool          `json:"oom_killed"`
+	Details      string        `json:"details,omitempty"`
+	Error        string        `json:"error,omitempty"` // the fault could not be injected
+}
+
+// ResilienceResult holds the chaos experiments run against a service. The
+// score is the percentage of experiments passed, of those that ran.
+type ResilienceResult struct {
+	Experiments []ChaosExperiment `json:"experiments"`
+	Score       int               `json:"score"`
+	Skipped     string            `json:"skipped,omitempty"`
+}
+
+// Ran returns the experiments whose fault was injected
+func (r *ResilienceResult) Ran() []ChaosExperiment {
+	var ran []ChaosExperiment
+	for _, e := range r.Experiments {
+		if e.Error == "" {
+			ran = append(ran, e)
+		}
+	}
+	return ran
+}
+
+// ResilienceTester runs the service's image in a container and injects
+// faults while it is under load: the container is killed, its network is
+// slowed down and its memory is exhausted. Each experiment checks that the
+// service keeps answering, with 5xx rather than hangs or resets, that health
+// probes answer in time and that the service recovers.
+type ResilienceTester struct {
+	config       ResilienceConfig
+	client       *http.Client
+	pollInterval time.Duration
+	docker       func(ctx context.Context, args ...string) (string, error)
+}
+
+// NewResilienceTester creates a resilience tester using the docker CLI
+func NewResilienceTester(cfg ResilienceConfig) *ResilienceTester {
+	return &ResilienceTester{
+		config:       cfg,
+		client:       &http.Client{Timeout: cfg.RequestTimeout},
+		pollInterval: 500 * time.Millisecond,
+		docker:       runDocker,
+	}
+}
+
+func runDocker(ctx context.Context, args ...string) (string, error) {
+	out, err := exec.CommandContext(ctx, "docker", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("docker %s: %w: %s", args[0], err, lastLines(string(out), 5))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Run builds the project's Dockerfile and runs the experiments against a
+// container of the image
+func (rt *ResilienceTester) Run(ctx context.Context, projectPath string, env []string) *ResilienceResult {
+	result := &ResilienceResult{Experiments: make([]ChaosExperiment, 0)}
+	dockerfile, err := os.ReadFile(filepath.Join(projectPath, "Dockerfile"))
+	if err != nil {
+		result.Skipped = "no Dockerfile to build a container from"
+		return result
+	}
+	port := "8080"
+	instructions := parseDockerfile(string(dockerfile))
+	if len(instructions) > 0 {
+		if p := exposedPort(instructions, instructions[len(instructions)-1].stage); p != "" {
+			port = p
+		}
+	}
+
+	id := strconv.FormatInt(time.Now().UnixNano(), 36)
+	image, container := "qlp-resilience:"+id, "qlp-resilience-"+id
+	if _, err := rt.docker(ctx, "build", "-t", image, projectPath); err != nil {
+		result.Skipped = "image build failed: " + err.Error()
+		return result
+	}
+	defer rt.docker(context.Background(), "rmi", "-f", image)
+
+	// The restart policy stands in for the orchestrator restarting crashed
+	// or OOM-killed pods
+	args := []string{"run", "-d", "--name", container, "--restart", "on-failure:5",
+		"--memory", rt.config.MemoryLimit, "-p", "127.0.0.1::" + port}
+	for _, e := range env {
+		args = append(args, "-e", e)
+	}
+	if _, err := rt.docker(ctx, append(args, image)...); err != nil {
+		result.Skipped = "container failed to start: " + err.Error()
+		return result
+	}
+	defer rt.docker(context.Background(), "rm", "-f", container)
+
+	address, err := rt.docker(ctx, "port", container, port+"/tcp")
+	if err != nil {
+		result.Skipped = "container port not published: " + err.Error()
+		return result
+	}
+	baseURL := "http://" + strings.SplitN(address, "\n", 2)[0]
+	if _, ok := rt.waitHealthy(ctx, baseURL); !ok {
+		result.Skipped = "container never became healthy"
+		return result
+	}
+
+	for _, experiment := range []func(context.Context, string, string) ChaosExperiment{
+		rt.killContainer,
+		rt.injectLatency,
+		rt.exhaustMemory,
+	} {
+		e := experiment(ctx, container, baseURL)
+		logger.WithComponent("validation").Info("Chaos experiment completed",
+			zap.String("experiment", e.Name),
+			zap.Bool("passed", e.Passed),
+			zap.Int("requests", e.Requests),
+			zap.Int("server_errors", e.ServerErrors),
+			zap.Int("failures", e.Failures),
+			zap.Duration("recovery_time", e.RecoveryTime),
+			zap.String("error", e.Error))
+		result.Experiments = append(result.Experiments, e)
+	}
+
+	ran := result.Ran()
+	if len(ran) == 0 {
+		result.Skipped = "no fault could be injected"
+		return result
+	}
+	passed := 0
+	for _, e := range ran {
+		if e.Passed {
+			passed++
+		}
+	}
+	result.Score = passed * 100 / len(ran)
+	return result
+}
+
+// killContainer kills the container mid-load and restarts it, as an
+// orchestrator would, to check the service starts cleanly after an unclean
+// shutdown
+func (rt *ResilienceTester) killContainer(ctx context.Context, container, baseURL string) ChaosExperiment {
+	e := ChaosExperiment{Name: "container kill"}
+	stop := rt.startLoad(ctx, baseURL)
+	sleep(ctx, rt.config.LoadDuration/3)
+	if _, err := rt.docker(ctx, "kill", container); err != nil {
+		stop().apply(&e)
+		e.Error = err.Error()
+		return e
+	}
+	e.HealthProbe = rt.probe(ctx, baseURL)
+	if _, err := rt.docker(ctx, "start", container); err != nil {
+		stop().apply(&e)
+		e.Details = "restart failed: " + err.Error()
+		return e
+	}
+	e.RecoveryTime, e.Recovered = rt.waitHealthy(ctx, baseURL)
+	sleep(ctx, rt.config.LoadDuration/3)
+	stop().apply(&e)
+
+	e.Passed = e.Recovered && e.HealthProbe != "healthy"
+	switch {
+	case !e.Recovered:
+		e.Details = fmt.Sprintf("service was not healthy within %s of restarting", rt.config.RecoveryTimeout)
+	case e.HealthProbe == "healthy":
+		e.Details = "health probe reported healthy while the container was down"
+	}
+	return e
+}
+
+// injectLatency delays the container's network traffic, including its calls
+// to dependencies, to check that requests fail with 5xx or succeed slowly
+// instead of hanging, and that health probes still answer in time
+func (rt *ResilienceTester) injectLatency(ctx context.Context, container, baseURL string) ChaosExperiment {
+	e := ChaosExperiment{Name: "dependency latency"}
+	netem := []string{"run", "--rm", "--net", "container:" + container, "--cap-add", "NET_ADMIN", rt.config.NetemImage, "tc", "qdisc"}
+	delay := strconv.FormatInt(rt.config.Latency.Milliseconds(), 10) + "ms"
+	if _, err := rt.docker(ctx, append(netem, "add", "dev", "eth0", "root", "netem", "delay", delay)...); err != nil {
+		e.Error = err.Error()
+		return e
+	}
+	stop := rt.startLoad(ctx, baseURL)
+	sleep(ctx, rt.config.LoadDuration/2)
+	e.HealthProbe = rt.probe(ctx, baseURL)
+	sleep(ctx, rt.config.LoadDuration/2)
+	stop().apply(&e)
+	if _, err := rt.docker(ctx, append(netem, "del", "dev", "eth0", "root")...); err != nil {
+		e.Details = "latency could not be removed: " + err.Error()
+	}
+	e.RecoveryTime, e.Recovered = rt.waitHealthy(ctx, baseURL)
+
+	e.Passed = e.Recovered && e.HealthProbe != "timeout" && e.Failures*10 <= e.Requests
+	switch {
+	case e.Failures*10 > e.Requests:
+		e.Details = fmt.Sprintf("%d of %d requests hung or were reset with %s of added latency instead of failing with 5xx", e.Failures, e.Requests, rt.config.Latency)
+	case e.HealthProbe == "timeout":
+		e.Details = fmt.Sprintf("health probe did not answer within %s", rt.config.RequestTimeout)
+	case !e.Recovered:
+		e.Details = "service did not recover after the latency was removed"
+	}
+	return e
+}
+
+// exhaustMemory allocates memory inside the container until the memory
+// limit is hit, to check that the service survives or is restarted after
+// the OOM kill
+func (rt *ResilienceTester) exhaustMemory(ctx context.Context, container, baseURL string) ChaosExperiment {
+	e := ChaosExperiment{Name: "memory exhaustion"}
+	stop := rt.startLoad(ctx, baseURL)
+	hogCtx, cancel := context.WithTimeout(ctx, rt.config.RecoveryTimeout)
+	defer cancel()
+	// tail buffers a line that never ends, growing until the limit is hit
+	_, err := rt.docker(hogCtx, "exec", container, "sh", "-c", "tail /dev/zero")
+	if err != nil && strings.Contains(err.Error(), "executable file not found") {
+		stop().apply(&e)
+		e.Error = "no shell in the image to allocate memory with"
+		return e
+	}
+	e.HealthProbe = rt.probe(ctx, baseURL)
+	e.RecoveryTime, e.Recovered = rt.waitHealthy(ctx, baseURL)
+	stop().apply(&e)
+
+	if state, err := rt.docker(ctx, "inspect", "-f", "{{.RestartCount}} {{.State.OOMKilled}}", container); err == nil {
+		fields := strings.Fields(state)
+		if len(fields) == 2 {
+			e.Restarts, _ = strconv.Atoi(fields[0])
+			e.OOMKilled = fields[1] == "true"
+		}
+	}
+
+	e.Passed = e.Recovered
+	if !e.Recovered {
+		e.Details = fmt.Sprintf("service was not healthy within %s of exhausting its %s memory limit", rt.config.RecoveryTimeout, rt.config.MemoryLimit)
+	} else if e.Restarts > 0 {
+		e.Details = fmt.Sprintf("service was OOM-killed and restarted %d times", e.Restarts)
+	}
+	return e
+}
+
+// loadStats counts the outcomes of the load requests
+type loadStats struct {
+	mu           sync.Mutex
+	requests     int
+	succeeded    int
+	serverErrors int
+	failures     int
+}
+
+func (s *loadStats) apply(e *ChaosExperiment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.Requests, e.Succeeded, e.ServerErrors, e.Failures = s.requests, s.succeeded, s.serverErrors, s.failures
+}
+
+// startLoad sends requests to the service until the returned function is
+// called, which waits for the clients and returns the counts
+func (rt *ResilienceTester) startLoad(ctx context.Context, baseURL string) func() *loadStats {
+	stats := &loadStats{}
+	loadCtx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	for i := 0; i < max(rt.config.Concurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for loadCtx.Err() == nil {
+				status, err := rt.get(loadCtx, baseURL+"/")
+				if loadCtx.Err() != nil {
+					return
+				}
+				stats.mu.Lock()
+				stats.requests++
+				switch {
+				case err != nil:
+					stats.failures++
+				case status >= 500:
+					stats.serverErrors++
+				default:
+					stats.succeeded++
+				}
+				stats.mu.Unlock()
+				if err != nil {
+					sleep(loadCtx, rt.pollInterval)
+				}
+			}
+		}()
+	}
+	return func() *loadStats {
+		cancel()
+		wg.Wait()
+		return stats
+	}
+}
+
+// probe calls the health endpoint once: "healthy", "unhealthy" for an error
+// status, "timeout" when it hung and "down" when the connection failed
+func (rt *ResilienceTester) probe(ctx context.Context, baseURL string) string {
+	status, err := rt.get(ctx, baseURL+rt.config.HealthPath)
+	switch {
+	case err != nil && strings.Contains(err.Error(), "Client.Timeout"):
+		return "timeout"
+	case err != nil:
+		return "down"
+	case status >= 200 && status < 300:
+		return "healthy"
+	}
+	return "unhealthy"
+}
+
+// waitHealthy polls the health endpoint until it succeeds or the recovery
+// timeout passes
+func (rt *ResilienceTester) waitHealthy(ctx context.Context, baseURL string) (time.Duration, bool) {
+	start := time.Now()
+	for time.Since(start) < rt.config.RecoveryTimeout && ctx.Err() == nil {
+		if rt.probe(ctx, baseURL) == "healthy" {
+			return time.Since(start), true
+		}
+		sleep(ctx, rt.pollInterval)
+	}
+	return time.Since(start), false
+}
+
+func (rt *ResilienceTester) get(ctx context.Context, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := rt.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
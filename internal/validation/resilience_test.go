@@ -0,0 +1,201 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeService stands in for the container: docker commands toggle whether it
+// is down, slow or out of memory
+type fakeService struct {
+	server      *httptest.Server
+	down        atomic.Bool
+	slow        atomic.Bool
+	hangOnSlow  bool
+	failOOM     bool
+	commands    []string
+	failCommand string
+}
+
+func newFakeService(t *testing.T) *fakeService {
+	fs := &fakeService{}
+	fs.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fs.down.Load() {
+			// Drop the connection like a dead container
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		if fs.slow.Load() {
+			if fs.hangOnSlow {
+				time.Sleep(200 * time.Millisecond)
+			} else if r.URL.Path != "/health" {
+				w.WriteHeader(http.StatusGatewayTimeout)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(fs.server.Close)
+	return fs
+}
+
+func (fs *fakeService) docker(ctx context.Context, args ...string) (string, error) {
+	command := strings.Join(args, " ")
+	fs.commands = append(fs.commands, command)
+	if fs.failCommand != "" && strings.HasPrefix(command, fs.failCommand) {
+		return "", errors.New("docker " + args[0] + ": exit status 1")
+	}
+	switch {
+	case args[0] == "port":
+		return strings.TrimPrefix(fs.server.URL, "http://"), nil
+	case args[0] == "kill":
+		fs.down.Store(true)
+	case args[0] == "start":
+		fs.down.Store(false)
+	case strings.Contains(command, "netem delay"):
+		fs.slow.Store(true)
+	case strings.HasSuffix(command, "del dev eth0 root"):
+		fs.slow.Store(false)
+	case args[0] == "exec":
+		if fs.failOOM {
+			fs.down.Store(true)
+		}
+		return "", errors.New("docker exec: exit status 137")
+	case args[0] == "inspect":
+		if fs.failOOM {
+			return "0 true", nil
+		}
+		return "1 true", nil
+	}
+	return "", nil
+}
+
+func newTestResilienceTester(fs *fakeService) *ResilienceTester {
+	cfg := DefaultResilienceConfig()
+	cfg.LoadDuration = 150 * time.Millisecond
+	cfg.RecoveryTimeout = 300 * time.Millisecond
+	cfg.RequestTimeout = 100 * time.Millisecond
+	rt := NewResilienceTester(cfg)
+	rt.pollInterval = 10 * time.Millisecond
+	rt.docker = fs.docker
+	return rt
+}
+
+func resilienceProject(t *testing.T) string {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM golang:1.21 AS build\nEXPOSE 9000\nFROM alpine:3.19\nEXPOSE 3000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func experiment(r *ResilienceResult, name string) ChaosExperiment {
+	for _, e := range r.Experiments {
+		if e.Name == name {
+			return e
+		}
+	}
+	return ChaosExperiment{}
+}
+
+func TestResilienceTesterResilientService(t *testing.T) {
+	fs := newFakeService(t)
+	result := newTestResilienceTester(fs).Run(context.Background(), resilienceProject(t), []string{"PORT=3000"})
+
+	if result.Skipped != "" {
+		t.Fatalf("expected the experiments to run, skipped: %s", result.Skipped)
+	}
+	if result.Score != 100 || len(result.Experiments) != 3 {
+		t.Fatalf("expected 3 passed experiments, got score %d: %+v", result.Score, result.Experiments)
+	}
+	kill := experiment(result, "container kill")
+	if kill.HealthProbe != "down" || !kill.Recovered {
+		t.Errorf("expected the health probe to fail while killed and the service to recover: %+v", kill)
+	}
+	latency := experiment(result, "dependency latency")
+	if latency.ServerErrors == 0 || latency.Failures != 0 || latency.HealthProbe != "healthy" {
+		t.Errorf("expected slow requests to fail with 5xx and health to answer: %+v", latency)
+	}
+	if memory := experiment(result, "memory exhaustion"); memory.Restarts != 1 || !memory.OOMKilled {
+		t.Errorf("expected the OOM restart to be recorded: %+v", memory)
+	}
+
+	run := ""
+	for _, c := range fs.commands {
+		if strings.HasPrefix(c, "run -d") {
+			run = c
+		}
+	}
+	if !strings.Contains(run, "-p 127.0.0.1::3000") || !strings.Contains(run, "-e PORT=3000") || !strings.Contains(run, "--memory 256m") {
+		t.Errorf("expected the final stage's port, the env and the memory limit in %q", run)
+	}
+	if last := fs.commands[len(fs.commands)-1]; !strings.HasPrefix(last, "rmi -f qlp-resilience:") {
+		t.Errorf("expected the image to be removed last, got %q", last)
+	}
+}
+
+func TestResilienceTesterFragileService(t *testing.T) {
+	fs := newFakeService(t)
+	fs.hangOnSlow = true
+	fs.failOOM = true
+	result := newTestResilienceTester(fs).Run(context.Background(), resilienceProject(t), nil)
+
+	if result.Score != 33 {
+		t.Errorf("expected only the kill experiment to pass, got score %d: %+v", result.Score, result.Experiments)
+	}
+	latency := experiment(result, "dependency latency")
+	if latency.Passed || latency.HealthProbe != "timeout" || !strings.Contains(latency.Details, "hung or were reset") {
+		t.Errorf("expected hung requests to fail the latency experiment: %+v", latency)
+	}
+	if memory := experiment(result, "memory exhaustion"); memory.Passed || memory.Recovered {
+		t.Errorf("expected the service not to recover from memory exhaustion: %+v", memory)
+	}
+}
+
+func TestResilienceTesterSkips(t *testing.T) {
+	fs := newFakeService(t)
+	rt := newTestResilienceTester(fs)
+	if result := rt.Run(context.Background(), t.TempDir(), nil); !strings.Contains(result.Skipped, "no Dockerfile") {
+		t.Errorf("expected projects without a Dockerfile to be skipped, got %+v", result)
+	}
+
+	fs.failCommand = "build"
+	if result := rt.Run(context.Background(), resilienceProject(t), nil); !strings.HasPrefix(result.Skipped, "image build failed") {
+		t.Errorf("expected a failed build to skip the experiments, got %+v", result)
+	}
+
+	// An experiment whose fault cannot be injected is left out of the score
+	fs.failCommand = "run --rm"
+	result := rt.Run(context.Background(), resilienceProject(t), nil)
+	if e := experiment(result, "dependency latency"); e.Error == "" {
+		t.Errorf("expected the latency experiment to record the injection error: %+v", e)
+	}
+	if len(result.Ran()) != 2 || result.Score != 100 {
+		t.Errorf("expected 2 scored experiments, got score %d: %+v", result.Score, result.Experiments)
+	}
+}
+
+func TestReliabilityScoreIncludesResilience(t *testing.T) {
+	dv := &DeploymentValidator{}
+	result := &DeploymentTestResult{BuildSuccess: true, StartupSuccess: true, HealthCheckPass: true, TestCoverage: 100}
+	if got := dv.calculateReliabilityScore(result); got != 100 {
+		t.Fatalf("expected 100 without resilience results, got %d", got)
+	}
+	result.Resilience = &ResilienceResult{Score: 0}
+	if got := dv.calculateReliabilityScore(result); got != 70 {
+		t.Errorf("expected failed experiments to weigh 30%%, got %d", got)
+	}
+	result.Resilience.Skipped = "no Dockerfile to build a container from"
+	if got := dv.calculateReliabilityScore(result); got != 100 {
+		t.Errorf("expected skipped experiments not to count, got %d", got)
+	}
+}
@@ -0,0 +1,166 @@
+package validation
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"QLP/internal/database"
+	"QLP/internal/logger"
+)
+
+// MemoryRuleStore keeps rules in memory, for tests and deployments without
+// a database
+type MemoryRuleStore struct {
+	mu    sync.Mutex
+	rules map[string]Rule
+}
+
+// NewMemoryRuleStore creates an empty in-memory rule store
+func NewMemoryRuleStore() *MemoryRuleStore {
+	return &MemoryRuleStore{rules: make(map[string]Rule)}
+}
+
+func (s *MemoryRuleStore) List(_ context.Context, tenantID string) ([]Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rules []Rule
+	for _, rule := range s.rules {
+		if rule.TenantID == "" || rule.TenantID == tenantID {
+			rules = append(rules, rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules, nil
+}
+
+func (s *MemoryRuleStore) Get(_ context.Context, id string) (*Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rule, ok := s.rules[id]
+	if !ok {
+		return nil, ErrRuleNotFound
+	}
+	return &rule, nil
+}
+
+func (s *MemoryRuleStore) Put(_ context.Context, rule Rule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rule.Languages = append([]string(nil), rule.Languages...)
+	s.rules[rule.ID] = rule
+	return nil
+}
+
+func (s *MemoryRuleStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rules[id]; !ok {
+		return ErrRuleNotFound
+	}
+	delete(s.rules, id)
+	return nil
+}
+
+// PostgresRuleStore keeps rules in the validation_rules table, shared by
+// every QLP instance
+type PostgresRuleStore struct {
+	db *sql.DB
+}
+
+// NewPostgresRuleStore creates the validation_rules table if needed
+func NewPostgresRuleStore(db *sql.DB) (*PostgresRuleStore, error) {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS validation_rules (
+			id VARCHAR(50) PRIMARY KEY,
+			tenant_id VARCHAR(100) NOT NULL DEFAULT '',
+			rule JSONB NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create validation_rules table: %w", err)
+	}
+	return &PostgresRuleStore{db: db}, nil
+}
+
+func (s *PostgresRuleStore) List(ctx context.Context, tenantID string) ([]Rule, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT rule FROM validation_rules WHERE tenant_id = '' OR tenant_id = $1 ORDER BY id`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list validation rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []Rule
+	for rows.Next() {
+		var data []byte
+		var rule Rule
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to read validation rule: %w", err)
+		}
+		if err := json.Unmarshal(data, &rule); err != nil {
+			return nil, fmt.Errorf("failed to decode validation rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (s *PostgresRuleStore) Get(ctx context.Context, id string) (*Rule, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, `SELECT rule FROM validation_rules WHERE id = $1`, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRuleNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read validation rule: %w", err)
+	}
+	var rule Rule
+	if err := json.Unmarshal(data, &rule); err != nil {
+		return nil, fmt.Errorf("failed to decode validation rule: %w", err)
+	}
+	return &rule, nil
+}
+
+func (s *PostgresRuleStore) Put(ctx context.Context, rule Rule) error {
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return fmt.Errorf("failed to encode validation rule: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO validation_rules (id, tenant_id, rule, updated_at) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (id) DO UPDATE SET tenant_id = $2, rule = $3, updated_at = $4`,
+		rule.ID, rule.TenantID, data, rule.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to save validation rule: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresRuleStore) Delete(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM validation_rules WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete validation rule: %w", err)
+	}
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		return ErrRuleNotFound
+	}
+	return nil
+}
+
+// NewRuleStoreFromEnv keeps rules in the database at DATABASE_URL, falling
+// back to memory when it is unavailable
+func NewRuleStoreFromEnv() (RuleStore, error) {
+	db, err := database.New()
+	if err != nil {
+		return nil, err
+	}
+	if !db.IsConnected() {
+		logger.WithComponent("validation").Warn("Database unavailable, custom validation rules are kept in memory")
+		return NewMemoryRuleStore(), nil
+	}
+	return NewPostgresRuleStore(db.GetConnection())
+}
@@ -0,0 +1,418 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"QLP/internal/audit"
+)
+
+var (
+	// ErrRuleNotFound is returned for rules that do not exist or belong to
+	// another tenant
+	ErrRuleNotFound = errors.New("validation rule not found")
+	// ErrInvalidRule is returned for rules that cannot be evaluated
+	ErrInvalidRule = errors.New("invalid validation rule")
+)
+
+// RulesPluginName is the plugin name custom rule findings are reported under
+const RulesPluginName = "custom-rules"
+
+// maxRuleMatches caps the findings one rule reports per file
+const maxRuleMatches = 20
+
+// RuleKind is how a rule's pattern is matched
+type RuleKind string
+
+const (
+	// RuleRegex patterns are regular expressions matched against file contents
+	RuleRegex RuleKind = "regex"
+	// RuleAST patterns match Go syntax: "call:pkg.Func" (or "call:panic") for
+	// calls and "import:path" for imports
+	RuleAST RuleKind = "ast"
+)
+
+// Rule is a tenant's custom validation rule. Rules without a tenant apply to
+// every tenant.
+type Rule struct {
+	ID          string    `json:"id"`
+	TenantID    string    `json:"tenant_id,omitempty"`
+	Name        string    `json:"name"`
+	Kind        RuleKind  `json:"kind"`
+	Pattern     string    `json:"pattern"`
+	Severity    string    `json:"severity"` // critical, high, medium, low or info
+	Message     string    `json:"message"`
+	Remediation string    `json:"remediation,omitempty"`
+	Languages   []string  `json:"languages,omitempty"` // e.g. go, python; empty for all
+	Disabled    bool      `json:"disabled,omitempty"`
+	Version     int       `json:"version"`
+	CreatedBy   string    `json:"created_by,omitempty"`
+	UpdatedBy   string    `json:"updated_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// RuleProvenance identifies the rule, and the version of it, behind a
+// finding
+type RuleProvenance struct {
+	RuleID    string    `json:"rule_id"`
+	Name      string    `json:"name"`
+	TenantID  string    `json:"tenant_id,omitempty"`
+	Version   int       `json:"version"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RuleStore persists custom rules
+type RuleStore interface {
+	// List returns the rules of a tenant, including the rules of every tenant
+	List(ctx context.Context, tenantID string) ([]Rule, error)
+	// Get returns a rule by ID, or ErrRuleNotFound
+	Get(ctx context.Context, id string) (*Rule, error)
+	// Put creates or replaces a rule
+	Put(ctx context.Context, rule Rule) error
+	// Delete removes a rule, or returns ErrRuleNotFound
+	Delete(ctx context.Context, id string) error
+}
+
+// ruleLanguages maps file extensions and names to the languages rules target
+var ruleLanguages = map[string]string{
+	".go":        "go",
+	".py":        "python",
+	".js":        "javascript",
+	".jsx":       "javascript",
+	".mjs":       "javascript",
+	".ts":        "typescript",
+	".tsx":       "typescript",
+	".java":      "java",
+	".cs":        "csharp",
+	".rb":        "ruby",
+	".rs":        "rust",
+	".php":       "php",
+	".tf":        "terraform",
+	".yaml":      "yaml",
+	".yml":       "yaml",
+	".json":      "json",
+	".sh":        "shell",
+	".sql":       "sql",
+	"dockerfile": "dockerfile",
+}
+
+// ruleLanguage returns the language of a file, or "" when unknown
+func ruleLanguage(file string) string {
+	base := strings.ToLower(path.Base(file))
+	if base == "dockerfile" || strings.HasPrefix(base, "dockerfile.") {
+		return "dockerfile"
+	}
+	return ruleLanguages[path.Ext(base)]
+}
+
+// compiledRule is a rule ready to be matched
+type compiledRule struct {
+	rule    Rule
+	regex   *regexp.Regexp
+	astKind string // call or import
+	astName string
+}
+
+// compileRule checks that a rule can be evaluated
+func compileRule(rule Rule) (*compiledRule, error) {
+	c := &compiledRule{rule: rule}
+	switch rule.Kind {
+	case RuleRegex:
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: pattern: %v", ErrInvalidRule, err)
+		}
+		c.regex = re
+	case RuleAST:
+		kind, name, ok := strings.Cut(rule.Pattern, ":")
+		name = strings.TrimSpace(name)
+		if !ok || (kind != "call" && kind != "import") || name == "" {
+			return nil, fmt.Errorf("%w: ast pattern %q, want call:pkg.Func or import:path", ErrInvalidRule, rule.Pattern)
+		}
+		for _, lang := range rule.Languages {
+			if lang != "go" {
+				return nil, fmt.Errorf("%w: ast rules only support go, not %s", ErrInvalidRule, lang)
+			}
+		}
+		c.astKind, c.astName = kind, name
+	default:
+		return nil, fmt.Errorf("%w: kind %q (supported: regex, ast)", ErrInvalidRule, rule.Kind)
+	}
+	return c, nil
+}
+
+// applies reports whether the rule targets a file
+func (c *compiledRule) applies(file string) bool {
+	lang := ruleLanguage(file)
+	if c.rule.Kind == RuleAST {
+		return lang == "go"
+	}
+	return len(c.rule.Languages) == 0 || slices.Contains(c.rule.Languages, lang)
+}
+
+// match returns the lines of a file matching the rule
+func (c *compiledRule) match(file, content string) []int {
+	if c.regex != nil {
+		var lines []int
+		for _, loc := range c.regex.FindAllStringIndex(content, maxRuleMatches) {
+			lines = append(lines, strings.Count(content[:loc[0]], "\n")+1)
+		}
+		return lines
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, content, parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+	var lines []int
+	if c.astKind == "import" {
+		for _, imp := range f.Imports {
+			if p, _ := strconv.Unquote(imp.Path.Value); p == c.astName {
+				lines = append(lines, fset.Position(imp.Pos()).Line)
+			}
+		}
+		return lines
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if ok && len(lines) < maxRuleMatches && callName(call.Fun) == c.astName {
+			lines = append(lines, fset.Position(call.Pos()).Line)
+		}
+		return true
+	})
+	return lines
+}
+
+// callName renders the function of a call as written, e.g. fmt.Println
+func callName(fun ast.Expr) string {
+	switch fn := fun.(type) {
+	case *ast.Ident:
+		return fn.Name
+	case *ast.SelectorExpr:
+		if x := callName(fn.X); x != "" {
+			return x + "." + fn.Sel.Name
+		}
+	}
+	return ""
+}
+
+// Rules manages tenant-scoped custom validation rules. It is also the plugin
+// that evaluates them: each validation loads the current rules of the tenant
+// in its context, so changes apply without a restart.
+type Rules struct {
+	store RuleStore
+
+	mu       sync.Mutex
+	compiled map[string]*compiledRule // by rule ID, for the stored version
+}
+
+// NewRules manages the rules kept in store
+func NewRules(store RuleStore) *Rules {
+	return &Rules{store: store, compiled: make(map[string]*compiledRule)}
+}
+
+// List returns the rules that apply to a tenant
+func (rs *Rules) List(ctx context.Context, tenantID string) ([]Rule, error) {
+	return rs.store.List(ctx, tenantID)
+}
+
+// Get returns a rule visible to a tenant
+func (rs *Rules) Get(ctx context.Context, tenantID, id string) (*Rule, error) {
+	rule, err := rs.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rule.TenantID != "" && rule.TenantID != tenantID {
+		return nil, ErrRuleNotFound
+	}
+	return rule, nil
+}
+
+// Create adds a rule for rule.TenantID
+func (rs *Rules) Create(ctx context.Context, rule Rule, actor string) (*Rule, error) {
+	now := time.Now()
+	rule.ID = fmt.Sprintf("RULE-%d", now.UnixNano())
+	rule.Version = 1
+	rule.CreatedBy, rule.UpdatedBy = actor, actor
+	rule.CreatedAt, rule.UpdatedAt = now, now
+	if err := rs.save(ctx, audit.ActionValidationRuleCreate, &rule, actor); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// Update replaces the definition of a tenant's rule, bumping its version
+func (rs *Rules) Update(ctx context.Context, id string, rule Rule, actor string) (*Rule, error) {
+	existing, err := rs.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if existing.TenantID != rule.TenantID {
+		return nil, ErrRuleNotFound
+	}
+	rule.ID = id
+	rule.Version = existing.Version + 1
+	rule.CreatedBy, rule.CreatedAt = existing.CreatedBy, existing.CreatedAt
+	rule.UpdatedBy, rule.UpdatedAt = actor, time.Now()
+	if err := rs.save(ctx, audit.ActionValidationRuleUpdate, &rule, actor); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// save normalizes, checks and stores a rule, recording it in the audit log
+func (rs *Rules) save(ctx context.Context, action audit.Action, rule *Rule, actor string) error {
+	rule.Severity = strings.ToLower(rule.Severity)
+	languages := make([]string, len(rule.Languages))
+	for i, lang := range rule.Languages {
+		languages[i] = strings.ToLower(lang)
+	}
+	rule.Languages = languages
+	err := validateRule(*rule)
+	if err == nil {
+		err = rs.store.Put(ctx, *rule)
+	}
+	recordRule(ctx, action, *rule, actor, err)
+	return err
+}
+
+// validateRule checks the fields of a rule and compiles its pattern
+func validateRule(rule Rule) error {
+	switch {
+	case strings.TrimSpace(rule.Name) == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidRule)
+	case rule.Message == "":
+		return fmt.Errorf("%w: message is required", ErrInvalidRule)
+	}
+	switch rule.Severity {
+	case "critical", "high", "medium", "low", "info":
+	default:
+		return fmt.Errorf("%w: severity %q (supported: critical, high, medium, low, info)", ErrInvalidRule, rule.Severity)
+	}
+	_, err := compileRule(rule)
+	return err
+}
+
+// Delete removes a tenant's rule
+func (rs *Rules) Delete(ctx context.Context, tenantID, id, actor string) error {
+	rule, err := rs.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if rule.TenantID != tenantID {
+		return ErrRuleNotFound
+	}
+	err = rs.store.Delete(ctx, id)
+	if err == nil {
+		rs.mu.Lock()
+		delete(rs.compiled, id)
+		rs.mu.Unlock()
+	}
+	recordRule(ctx, audit.ActionValidationRuleDelete, *rule, actor, err)
+	return err
+}
+
+func recordRule(ctx context.Context, action audit.Action, rule Rule, actor string, err error) {
+	entry := audit.Entry{
+		Action:       action,
+		Outcome:      audit.OutcomeSuccess,
+		ResourceType: "validation_rule",
+		ResourceIDs:  []string{rule.ID},
+		Details: map[string]interface{}{
+			"name":    rule.Name,
+			"kind":    rule.Kind,
+			"version": rule.Version,
+		},
+	}
+	if err != nil {
+		entry.Outcome = audit.OutcomeFailure
+		entry.Error = err.Error()
+	}
+	audit.Record(audit.WithActor(audit.WithTenant(ctx, rule.TenantID), actor), entry)
+}
+
+// Name returns the plugin name custom rule findings are reported under
+func (rs *Rules) Name() string {
+	return RulesPluginName
+}
+
+// Validate matches the enabled rules of the tenant in ctx against the files.
+// Without any rule it returns no result, leaving the scores unchanged.
+func (rs *Rules) Validate(ctx context.Context, req PluginRequest) (*PluginResult, error) {
+	rules, err := rs.store.List(ctx, audit.TenantFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load validation rules: %w", err)
+	}
+	rules = slices.DeleteFunc(rules, func(rule Rule) bool { return rule.Disabled })
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	files := make([]string, 0, len(req.Files))
+	for file := range req.Files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	result := &PluginResult{Findings: []PluginFinding{}}
+	for _, rule := range rules {
+		c, err := rs.compile(rule)
+		if err != nil {
+			continue
+		}
+		provenance := &RuleProvenance{
+			RuleID:    rule.ID,
+			Name:      rule.Name,
+			TenantID:  rule.TenantID,
+			Version:   rule.Version,
+			UpdatedBy: rule.UpdatedBy,
+			UpdatedAt: rule.UpdatedAt,
+		}
+		for _, file := range files {
+			if !c.applies(file) {
+				continue
+			}
+			for _, line := range c.match(file, req.Files[file]) {
+				result.Findings = append(result.Findings, PluginFinding{
+					Rule:        rule.ID,
+					Severity:    rule.Severity,
+					Message:     rule.Message,
+					Location:    fmt.Sprintf("%s:%d", file, line),
+					Remediation: rule.Remediation,
+					Provenance:  provenance,
+				})
+			}
+		}
+	}
+	return result, nil
+}
+
+// compile returns the compiled form of a rule, compiling each version once
+func (rs *Rules) compile(rule Rule) (*compiledRule, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if c, ok := rs.compiled[rule.ID]; ok && c.rule.Version == rule.Version {
+		return c, nil
+	}
+	c, err := compileRule(rule)
+	if err != nil {
+		return nil, err
+	}
+	rs.compiled[rule.ID] = c
+	return c, nil
+}
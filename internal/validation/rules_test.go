@@ -0,0 +1,181 @@
+package validation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"QLP/internal/audit"
+)
+
+func TestRuleRoutes(t *testing.T) {
+	rules := NewRules(NewMemoryRuleStore())
+	mux := http.NewServeMux()
+	for pattern, h := range RuleRoutes(rules) {
+		mux.Handle(pattern, h)
+	}
+	do := func(method, target, body string) (*httptest.ResponseRecorder, Rule) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		var rule Rule
+		json.Unmarshal(rec.Body.Bytes(), &rule)
+		return rec, rule
+	}
+
+	rec, rule := do(http.MethodPost, "/rules", `{"tenant_id":"acme","name":"no-println","kind":"regex","pattern":"fmt\\.Println","severity":"High","message":"use the logger","languages":["Go"],"actor":"alice"}`)
+	if rec.Code != http.StatusCreated || rule.Version != 1 || rule.Severity != "high" || rule.Languages[0] != "go" || rule.CreatedBy != "alice" {
+		t.Fatalf("create: %d %+v", rec.Code, rule)
+	}
+
+	if rec, _ := do(http.MethodPost, "/rules", `{"tenant_id":"acme","name":"bad","kind":"regex","pattern":"(","severity":"high","message":"x"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid pattern: %d", rec.Code)
+	}
+	if rec, _ := do(http.MethodPost, "/rules", `{"name":"bad","kind":"ast","pattern":"call:os.Exit","severity":"high","message":"x","languages":["python"]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("ast rule for python: %d", rec.Code)
+	}
+
+	// Another tenant can neither see nor change the rule
+	if rec, _ := do(http.MethodGet, "/rules/"+rule.ID+"?tenant=globex", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("get from another tenant: %d", rec.Code)
+	}
+	if rec, _ := do(http.MethodPut, "/rules/"+rule.ID, `{"tenant_id":"globex","name":"x","kind":"regex","pattern":"x","severity":"low","message":"x"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("update from another tenant: %d", rec.Code)
+	}
+
+	rec, updated := do(http.MethodPut, "/rules/"+rule.ID, `{"tenant_id":"acme","name":"no-println","kind":"regex","pattern":"fmt\\.Print","severity":"medium","message":"use the logger","actor":"bob"}`)
+	if rec.Code != http.StatusOK || updated.Version != 2 || updated.CreatedBy != "alice" || updated.UpdatedBy != "bob" {
+		t.Errorf("update: %d %+v", rec.Code, updated)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rules?tenant=acme", nil))
+	var list struct{ Rules []Rule }
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list.Rules) != 1 || list.Rules[0].Pattern != `fmt\.Print` {
+		t.Errorf("list: %+v", list.Rules)
+	}
+
+	if rec, _ := do(http.MethodDelete, "/rules/"+rule.ID+"?tenant=acme&actor=bob", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("delete: %d", rec.Code)
+	}
+	if rec, _ := do(http.MethodGet, "/rules/"+rule.ID+"?tenant=acme", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("get after delete: %d", rec.Code)
+	}
+}
+
+func TestRuleRoutesUseTheAPIKeyTenant(t *testing.T) {
+	rules := NewRules(NewMemoryRuleStore())
+	mux := http.NewServeMux()
+	for pattern, h := range RuleRoutes(rules) {
+		mux.Handle(pattern, h)
+	}
+	as := func(tenant, method, target, body string) (*httptest.ResponseRecorder, Rule) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req = req.WithContext(audit.WithActor(audit.WithTenant(req.Context(), tenant), "api-key:k-"+tenant))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		var rule Rule
+		json.Unmarshal(rec.Body.Bytes(), &rule)
+		return rec, rule
+	}
+
+	body := `{"tenant_id":"globex","name":"no-println","kind":"regex","pattern":"fmt\\.Println","severity":"high","message":"use the logger","actor":"alice"}`
+	rec, rule := as("acme", http.MethodPost, "/rules", body)
+	if rec.Code != http.StatusCreated || rule.TenantID != "acme" || rule.CreatedBy != "api-key:k-acme" {
+		t.Fatalf("create: %d %+v", rec.Code, rule)
+	}
+
+	// Naming the rule's tenant in the body does not let another key overwrite it
+	body = `{"tenant_id":"acme","name":"x","kind":"regex","pattern":"x","severity":"low","message":"x"}`
+	if rec, _ := as("globex", http.MethodPut, "/rules/"+rule.ID, body); rec.Code != http.StatusNotFound {
+		t.Errorf("update from another tenant: %d", rec.Code)
+	}
+	if stored, err := rules.Get(context.Background(), "acme", rule.ID); err != nil || stored.Version != 1 {
+		t.Errorf("rule changed by another tenant: %+v, %v", stored, err)
+	}
+	if rec, updated := as("acme", http.MethodPut, "/rules/"+rule.ID, body); rec.Code != http.StatusOK || updated.UpdatedBy != "api-key:k-acme" {
+		t.Errorf("update: %d %+v", rec.Code, updated)
+	}
+}
+
+func TestRulesApplyToTenantValidations(t *testing.T) {
+	ctx := context.Background()
+	rules := NewRules(NewMemoryRuleStore())
+	println, err := rules.Create(ctx, Rule{TenantID: "acme", Name: "no-println", Kind: RuleRegex, Pattern: `fmt\.Println`,
+		Severity: "high", Message: "use the logger", Languages: []string{"go"}}, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rules.Create(ctx, Rule{Name: "no-unsafe", Kind: RuleAST, Pattern: "import:unsafe",
+		Severity: "critical", Message: "unsafe is not allowed"}, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	static := NewStaticValidator(&slowLLM{})
+	static.SetPlugins(nil)
+	static.AddPlugin(rules)
+	mux := http.NewServeMux()
+	for pattern, h := range Routes(static, &InfrastructureValidator{}) {
+		mux.Handle(pattern, h)
+	}
+	validate := func(tenant string) *StaticValidationResult {
+		body, _ := json.Marshal(staticRequest{TenantID: tenant, Files: map[string]string{
+			"main.go":   "package main\n\nimport (\n\t\"fmt\"\n\t\"unsafe\"\n)\n\nfunc main() {\n\tfmt.Println(unsafe.Sizeof(0))\n}\n",
+			"README.md": "fmt.Println",
+		}})
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+		var result StaticValidationResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatalf("%d: %s", rec.Code, rec.Body.String())
+		}
+		return &result
+	}
+
+	result := validate("acme")
+	if len(result.PluginResults) != 1 || len(result.PluginResults[0].Findings) != 2 {
+		t.Fatalf("plugin results = %+v", result.PluginResults)
+	}
+	findings := result.PluginResults[0].Findings
+	if f := findings[0]; f.Location != "main.go:9" || f.Provenance == nil || f.Provenance.RuleID != println.ID || f.Provenance.Version != 1 {
+		t.Errorf("regex finding = %+v", f)
+	}
+	if f := findings[1]; f.Location != "main.go:5" || f.Severity != "critical" || f.Provenance.TenantID != "" {
+		t.Errorf("ast finding = %+v", f)
+	}
+	if result.DeploymentReady {
+		t.Error("a critical rule finding should block deployment")
+	}
+	found := false
+	for _, issue := range result.Issues {
+		found = found || strings.HasPrefix(issue.Category, "Rule: no-println v1")
+	}
+	if !found {
+		t.Errorf("issues carry no rule provenance: %+v", result.Issues)
+	}
+
+	// Other tenants only get the shared rule
+	if result := validate("globex"); len(result.PluginResults[0].Findings) != 1 {
+		t.Errorf("globex findings = %+v", result.PluginResults[0].Findings)
+	}
+
+	// Changes apply to the next validation
+	disabled := *println
+	disabled.Disabled = true
+	if _, err := rules.Update(ctx, println.ID, disabled, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if result := validate("acme"); len(result.PluginResults[0].Findings) != 1 {
+		t.Errorf("findings after disabling = %+v", result.PluginResults[0].Findings)
+	}
+
+	// A validation without rules to apply is scored as before
+	plugin, err := NewRules(NewMemoryRuleStore()).Validate(audit.WithTenant(ctx, "acme"), PluginRequest{})
+	if plugin != nil || err != nil {
+		t.Errorf("no rules: %+v, %v", plugin, err)
+	}
+}
@@ -0,0 +1,148 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"QLP/internal/audit"
+)
+
+// ErrRunNotFound is returned for unknown validation run IDs
+var ErrRunNotFound = errors.New("validation run not found")
+
+// RunStatus is where a validation run is
+type RunStatus string
+
+const (
+	RunRunning   RunStatus = "running"
+	RunCompleted RunStatus = "completed"
+	RunFailed    RunStatus = "failed"
+)
+
+// Run is a validation started in the background, whose progress events can
+// be followed while it runs
+type Run struct {
+	ID          string      `json:"id"`
+	Kind        string      `json:"kind"` // static or infrastructure
+	TenantID    string      `json:"tenant_id,omitempty"`
+	Status      RunStatus   `json:"status"`
+	Events      int         `json:"events"` // Progress events so far
+	Result      interface{} `json:"result,omitempty"`
+	Error       string      `json:"error,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	CompletedAt *time.Time  `json:"completed_at,omitempty"`
+}
+
+// run is a Run with its progress events. changed is closed, and replaced,
+// whenever an event is added or the run ends.
+type run struct {
+	Run
+	events  []ProgressEvent
+	changed chan struct{}
+}
+
+// Runs keeps validation runs in memory, forgetting finished ones after a
+// retention period
+type Runs struct {
+	retention time.Duration
+
+	mu   sync.Mutex
+	runs map[string]*run
+}
+
+// NewRuns keeps finished runs for retention
+func NewRuns(retention time.Duration) *Runs {
+	return &Runs{retention: retention, runs: make(map[string]*run)}
+}
+
+// Start runs validate in the background with a context reporting its
+// progress to the run, for the tenant of ctx. The run outlives the request
+// that started it.
+func (rs *Runs) Start(ctx context.Context, kind string, validate func(ctx context.Context) (interface{}, error)) *Run {
+	now := time.Now()
+	r := &run{
+		Run:     Run{ID: fmt.Sprintf("VAL-%d", now.UnixNano()), Kind: kind, TenantID: audit.TenantFromContext(ctx), Status: RunRunning, CreatedAt: now},
+		changed: make(chan struct{}),
+	}
+
+	rs.mu.Lock()
+	rs.prune(now)
+	rs.runs[r.ID] = r
+	out := r.Run
+	rs.mu.Unlock()
+
+	ctx = WithProgress(context.WithoutCancel(ctx), func(event ProgressEvent) {
+		rs.mu.Lock()
+		defer rs.mu.Unlock()
+		event.Seq = len(r.events) + 1
+		r.events = append(r.events, event)
+		r.Events = len(r.events)
+		r.notify()
+	})
+	go func() {
+		result, err := validate(ctx)
+		if err != nil {
+			progressFrom(ctx).failed(CheckValidation, err)
+		}
+		rs.mu.Lock()
+		defer rs.mu.Unlock()
+		done := time.Now()
+		r.CompletedAt = &done
+		r.Status = RunCompleted
+		r.Result = result
+		if err != nil {
+			r.Status = RunFailed
+			r.Error = err.Error()
+		}
+		r.notify()
+	}()
+	return &out
+}
+
+// notify wakes up the followers of a run. Callers hold rs.mu.
+func (r *run) notify() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// prune forgets runs finished longer than the retention ago. Callers hold
+// rs.mu.
+func (rs *Runs) prune(now time.Time) {
+	for id, r := range rs.runs {
+		if r.CompletedAt != nil && now.Sub(*r.CompletedAt) > rs.retention {
+			delete(rs.runs, id)
+		}
+	}
+}
+
+// Get returns a run, with its result once finished
+func (rs *Runs) Get(id string) (*Run, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	r, ok := rs.runs[id]
+	if !ok {
+		return nil, ErrRunNotFound
+	}
+	out := r.Run
+	return &out, nil
+}
+
+// Events returns the progress events of a run after sequence number after,
+// whether the run has finished, and a channel closed when there is more to
+// read
+func (rs *Runs) Events(id string, after int) ([]ProgressEvent, bool, <-chan struct{}, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	r, ok := rs.runs[id]
+	if !ok {
+		return nil, false, nil, ErrRunNotFound
+	}
+	var events []ProgressEvent
+	if after < len(r.events) {
+		events = append(events, r.events[max(after, 0):]...)
+	}
+	return events, r.Status != RunRunning, r.changed, nil
+}
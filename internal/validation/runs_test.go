@@ -0,0 +1,133 @@
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/metrics"
+	"QLP/internal/tracing"
+)
+
+func TestValidationStream(t *testing.T) {
+	// Serve the routes the way main does, behind the metrics and tracing
+	// middleware, which must let the stream flush
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	routes := Routes(NewStaticValidator(nil), &InfrastructureValidator{})
+	for pattern, h := range routes {
+		routes[pattern] = tracing.HTTPMiddleware(pattern, h)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go metrics.StartServer(ctx, addr, routes)
+	base := "http://" + addr
+	for i := 0; ; i++ {
+		resp, err := http.Get(base + "/metrics")
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		if i == 50 {
+			t.Fatal(err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	body := `{"kind":"infrastructure","type":"dockerfile","code":"FROM node:latest\nADD . /app\n"}`
+	resp, err := http.Post(base+"/validations", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var run Run
+	json.NewDecoder(resp.Body).Decode(&run)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || run.ID == "" {
+		t.Fatalf("start: %d %+v", resp.StatusCode, run)
+	}
+
+	stream := func(lastEventID string) string {
+		req, _ := http.NewRequest(http.MethodGet, base+"/validations/"+run.ID+"/stream", nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("content type %q", ct)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		return string(data)
+	}
+
+	out := stream("")
+	for _, want := range []string{
+		"id: 1\nevent: started\ndata: {\"seq\":1,\"type\":\"started\",\"check\":\"validation\"",
+		`"type":"started","check":"dockerfile"`,
+		`"type":"finding","check":"dockerfile"`,
+		`"type":"completed","check":"dockerfile","score":`,
+		`"type":"completed","check":"compliance"`,
+		`"type":"completed","check":"validation","score":`,
+		"event: end\ndata: {\"id\":\"" + run.ID + "\",\"kind\":\"infrastructure\",\"status\":\"completed\"",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("stream is missing %q:\n%s", want, out)
+		}
+	}
+
+	// A reconnecting client only gets the events after the last it saw
+	if resumed := stream("2"); strings.Contains(resumed, "id: 2\n") || !strings.Contains(resumed, "id: 3\n") {
+		t.Errorf("resumed stream:\n%s", resumed)
+	}
+
+	resp, _ = http.Get(base + "/validations/VAL-0/stream")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown run: %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestRunRoutesScopeToTheAPIKey(t *testing.T) {
+	mux := http.NewServeMux()
+	for pattern, h := range Routes(NewStaticValidator(nil), &InfrastructureValidator{}) {
+		mux.Handle(pattern, h)
+	}
+	as := func(tenant, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(audit.WithTenant(req.Context(), tenant))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	body := `{"kind":"infrastructure","type":"dockerfile","tenant_id":"t2","code":"FROM node:latest\n"}`
+	rec := as("t1", http.MethodPost, "/validations", body)
+	var run Run
+	json.NewDecoder(rec.Body).Decode(&run)
+	if rec.Code != http.StatusAccepted || run.TenantID != "t1" {
+		t.Fatalf("start: %d %+v, want a run of the key's tenant", rec.Code, run)
+	}
+
+	for _, path := range []string{"/validations/" + run.ID, "/validations/" + run.ID + "/stream"} {
+		if rec := as("t2", http.MethodGet, path, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("%s from another tenant: %d", path, rec.Code)
+		}
+	}
+	if rec := as("t1", http.MethodGet, "/validations/"+run.ID, ""); rec.Code != http.StatusOK {
+		t.Errorf("get: %d %s", rec.Code, rec.Body)
+	}
+}
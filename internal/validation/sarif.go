@@ -0,0 +1,140 @@
+package validation
+
+import (
+	"fmt"
+
+	"QLP/internal/sarif"
+	"QLP/internal/types"
+)
+
+// SARIF tool names for the validators
+const (
+	sarifStaticTool = "qlp-static-validator"
+	sarifInfraTool  = "qlp-infrastructure-validator"
+	sarifVersion    = "1.0.0"
+)
+
+// SARIF converts the security, quality, architecture and plugin findings into a
+// SARIF log. Findings without a file are reported against defaultURI.
+func (r *StaticValidationResult) SARIF(defaultURI string) *sarif.Log {
+	b := sarif.NewBuilder(sarifStaticTool, sarifVersion)
+	b.SetDefaultLocation(defaultURI)
+
+	AddSecurityFindings(b, r.SecurityFindings)
+	for _, f := range r.QualityFindings {
+		b.Add(sarif.Finding{
+			RuleID:      "quality/" + f.Type,
+			Severity:    f.Severity,
+			Message:     f.Description,
+			Location:    f.Location,
+			Remediation: f.Recommendation,
+			Tags:        sarif.SortedTags("quality", f.Category),
+		})
+	}
+	for _, f := range r.ArchitectureFindings {
+		b.Add(sarif.Finding{
+			RuleID:      "architecture/" + f.Type,
+			Severity:    f.Severity,
+			Message:     f.Description,
+			Location:    f.Component,
+			Remediation: f.Recommendation,
+			Tags:        sarif.SortedTags("architecture", f.Pattern),
+		})
+	}
+	for _, pr := range r.PluginResults {
+		for _, f := range pr.Findings {
+			tags := []string{"plugin", pr.Plugin}
+			if p := f.Provenance; p != nil {
+				tags = append(tags, fmt.Sprintf("%s@v%d", p.Name, p.Version))
+			}
+			b.Add(sarif.Finding{
+				RuleID:      "plugin/" + pr.Plugin + "/" + f.Rule,
+				Severity:    f.Severity,
+				Message:     f.Message,
+				Location:    f.Location,
+				Remediation: f.Remediation,
+				Tags:        tags,
+			})
+		}
+	}
+	return b.Log()
+}
+
+// SARIF converts the Terraform, Kubernetes, security and policy findings into
+// a SARIF log. Findings without a file are reported against defaultURI.
+func (r *InfraValidationResult) SARIF(defaultURI string) *sarif.Log {
+	b := sarif.NewBuilder(sarifInfraTool, sarifVersion)
+	b.SetDefaultLocation(defaultURI)
+
+	if tf := r.TerraformResult; tf != nil {
+		for _, issue := range tf.SecurityIssues {
+			message := issue.Title
+			if issue.Description != "" {
+				message += ": " + issue.Description
+			}
+			b.Add(sarif.Finding{
+				RuleID:      "terraform/" + issue.ID,
+				Severity:    issue.Severity,
+				Message:     message,
+				Location:    issue.Resource,
+				Remediation: issue.Remediation,
+				Tags:        []string{"security", "terraform"},
+			})
+		}
+		for _, v := range tf.PolicyViolations {
+			b.Add(sarif.Finding{
+				RuleID:      "policy/" + v.Policy,
+				Severity:    v.Severity,
+				Message:     v.Violation,
+				Location:    v.Resource,
+				Remediation: v.Action,
+				Tags:        []string{"policy", "terraform"},
+			})
+		}
+	}
+	if k8s := r.KubernetesResult; k8s != nil {
+		AddKubernetesIssues(b, k8s.Issues)
+	}
+	if sec := r.SecurityResult; sec != nil {
+		for _, f := range sec.CriticalFindings {
+			b.Add(sarif.Finding{
+				RuleID:      "infra-security/" + f.Rule,
+				Severity:    f.Severity,
+				Message:     f.Finding,
+				Location:    f.Resource,
+				Remediation: f.Remediation,
+				Tags:        []string{"security"},
+			})
+		}
+	}
+	return b.Log()
+}
+
+// AddSecurityFindings adds scanner findings, tagged with their CWE and OWASP
+// categories
+func AddSecurityFindings(b *sarif.Builder, findings []types.SecurityFinding) {
+	for _, f := range findings {
+		b.Add(sarif.Finding{
+			RuleID:      "security/" + f.Type,
+			Severity:    f.Severity,
+			Message:     f.Description,
+			Location:    f.Location,
+			Remediation: f.Recommendation,
+			Tags:        sarif.SortedTags("security", f.CWE, f.OWASP),
+		})
+	}
+}
+
+// AddKubernetesIssues adds Kubernetes manifest issues
+func AddKubernetesIssues(b *sarif.Builder, issues []KubernetesIssue) {
+	for _, issue := range issues {
+		b.Add(sarif.Finding{
+			RuleID:      "kubernetes/" + issue.Type,
+			Severity:    issue.Severity,
+			Message:     issue.Message,
+			Location:    issue.Resource,
+			Remediation: issue.Suggestion,
+			Tags:        []string{"kubernetes"},
+		})
+	}
+}
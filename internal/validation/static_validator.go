@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
+	"QLP/internal/codemetrics"
 	"QLP/internal/llm"
 	"QLP/internal/llm/parser"
 	"QLP/internal/logger"
@@ -23,6 +25,7 @@ type StaticValidator struct {
 	securityScanner   *SecurityScanner
 	qualityChecker    *QualityChecker
 	complianceChecker *ComplianceChecker
+	plugins           []Plugin
 }
 
 // StaticValidationResult represents comprehensive static validation results
@@ -39,12 +42,23 @@ type StaticValidationResult struct {
 	SecurityFindings   []types.SecurityFinding `json:"security_findings"`
 	QualityFindings    []QualityFinding       `json:"quality_findings"`
 	ArchitectureFindings []ArchitectureFinding `json:"architecture_findings"`
+	PluginResults      []PluginResult         `json:"plugin_results,omitempty"`
+	CodeMetrics        *codemetrics.Report    `json:"code_metrics,omitempty"`
 	ValidationTime     time.Duration          `json:"validation_time"`
 	ValidatedAt        time.Time              `json:"validated_at"`
 }
 
 // Use SecurityFinding from types package
 
+// hotspotRemediation is the advice given for each kind of code metrics
+// hotspot
+var hotspotRemediation = map[string]string{
+	"complexity":      "Split the function or replace branching with lookups to reduce its cyclomatic complexity",
+	"length":          "Extract parts of the function into smaller, named functions",
+	"duplication":     "Extract the duplicated code into a shared function",
+	"maintainability": "Simplify the file: shorter functions, fewer branches and less code per file",
+}
+
 // QualityFinding represents a code quality finding
 type QualityFinding struct {
 	Type           string `json:"type"`
@@ -75,8 +89,13 @@ type QualityChecker struct {
 	llmClient llm.Client
 }
 
-// NewStaticValidator creates a new static validator
+// NewStaticValidator creates a new static validator with the plugins
+// registered in QLP_VALIDATOR_PLUGINS
 func NewStaticValidator(llmClient llm.Client) *StaticValidator {
+	plugins, err := PluginsFromEnv()
+	if err != nil {
+		logger.WithComponent("validation").Warn("Validator plugins disabled", zap.Error(err))
+	}
 	return &StaticValidator{
 		llmClient:         llmClient,
 		responseParser:    parser.NewUnifiedResponseParser(logger.GetDefaultLogger()),
@@ -84,9 +103,20 @@ func NewStaticValidator(llmClient llm.Client) *StaticValidator {
 		securityScanner:   NewSecurityScanner(),
 		qualityChecker:    &QualityChecker{llmClient: llmClient},
 		complianceChecker: NewComplianceChecker(),
+		plugins:           plugins,
 	}
 }
 
+// SetPlugins replaces the custom validator plugins
+func (sv *StaticValidator) SetPlugins(plugins []Plugin) {
+	sv.plugins = plugins
+}
+
+// AddPlugin adds a custom validator plugin, such as the tenants' custom rules
+func (sv *StaticValidator) AddPlugin(plugin Plugin) {
+	sv.plugins = append(sv.plugins, plugin)
+}
+
 // NewComplianceChecker creates a new compliance checker
 func NewComplianceChecker() *ComplianceChecker {
 	return &ComplianceChecker{}
@@ -110,48 +140,108 @@ func (sv *StaticValidator) ValidateQuantumDrop(ctx context.Context, drop *packag
 
 	// Extract code content from QuantumDrop files
 	codeContent, projectStructure := sv.extractCodeContent(drop)
+	report := progressFrom(ctx)
+	report.started(CheckValidation)
 
 	// Multi-LLM validation with different specialized models
 	results := make([]int, 0)
 
 	// 1. Security-focused LLM validation
+	report.started("security")
 	securityScore, securityFindings, err := sv.validateSecurity(ctx, codeContent, drop.Type)
 	if err != nil {
 		logger.WithComponent("validation").Warn("Security validation failed",
 			zap.Error(err))
+		report.failed("security", err)
 		securityScore = 50 // Fallback score
 	}
+	for _, f := range securityFindings {
+		report.finding("security", Finding{Severity: f.Severity, Type: f.Type, Message: f.Description, Location: f.Location, Remediation: f.Recommendation})
+	}
+	report.completed("security", securityScore)
 	result.SecurityScore = securityScore
 	result.SecurityFindings = securityFindings
 	results = append(results, securityScore)
 
 	// 2. Code quality-focused LLM validation
+	report.started("quality")
 	qualityScore, qualityFindings, err := sv.validateQuality(ctx, codeContent, drop.Type)
 	if err != nil {
 		logger.WithComponent("validation").Warn("Quality validation failed",
 			zap.Error(err))
+		report.failed("quality", err)
 		qualityScore = 60 // Fallback score
 	}
+	// Go files are also parsed and type-checked, for findings at exact lines;
+	// without the LLM their score replaces the fallback
+	if goAnalysis := AnalyzeGo(drop.Files); goAnalysis != nil {
+		if err != nil {
+			qualityScore = goAnalysis.Score
+		}
+		qualityFindings = append(qualityFindings, goAnalysis.QualityFindings()...)
+	}
+	// Complexity, duplication and maintainability count for a quarter of the
+	// quality score, and their hotspots are reported as findings
+	if metrics := codemetrics.Analyze(drop.Files); metrics != nil {
+		result.CodeMetrics = metrics
+		qualityScore = (qualityScore*3 + metrics.Score) / 4
+		for _, h := range metrics.Hotspots {
+			qualityFindings = append(qualityFindings, QualityFinding{
+				Type:           h.Metric,
+				Severity:       strings.ToUpper(h.Severity),
+				Description:    h.Message,
+				Location:       fmt.Sprintf("%s:%d", h.File, h.Line),
+				Recommendation: hotspotRemediation[h.Metric],
+				Category:       "Code metrics",
+			})
+		}
+	}
+	for _, f := range qualityFindings {
+		report.finding("quality", Finding{Severity: f.Severity, Type: f.Type, Message: f.Description, Location: f.Location, Remediation: f.Recommendation})
+	}
+	report.completed("quality", qualityScore)
 	result.QualityScore = qualityScore
 	result.QualityFindings = qualityFindings
 	results = append(results, qualityScore)
 
 	// 3. Architecture-focused LLM validation
+	report.started("architecture")
 	architectureScore, architectureFindings, err := sv.validateArchitecture(ctx, codeContent, projectStructure, drop.Type)
 	if err != nil {
 		logger.WithComponent("validation").Warn("Architecture validation failed",
 			zap.Error(err))
+		report.failed("architecture", err)
 		architectureScore = 65 // Fallback score
 	}
+	for _, f := range architectureFindings {
+		report.finding("architecture", Finding{Severity: f.Severity, Type: f.Type, Message: f.Description, Location: f.Component, Remediation: f.Recommendation})
+	}
+	report.completed("architecture", architectureScore)
 	result.ArchitectureScore = architectureScore
 	result.ArchitectureFindings = architectureFindings
 	results = append(results, architectureScore)
 
 	// 4. Compliance validation
+	report.started("compliance")
 	complianceScore := sv.validateCompliance(codeContent, drop.Type)
+	report.completed("compliance", complianceScore)
 	result.ComplianceScore = complianceScore
 	results = append(results, complianceScore)
 
+	// 5. Custom validator plugins, each scored like a built-in analysis
+	if len(sv.plugins) > 0 {
+		result.PluginResults = runPlugins(ctx, sv.plugins, PluginRequest{
+			DropID:   drop.ID,
+			DropType: string(drop.Type),
+			Files:    drop.Files,
+		})
+		for _, pr := range result.PluginResults {
+			if pr.Score != nil {
+				results = append(results, *pr.Score)
+			}
+		}
+	}
+
 	// Aggregate results
 	result.OverallScore = sv.calculateOverallScore(results)
 	result.DeploymentReady = sv.assessDeploymentReadiness(result)
@@ -159,6 +249,7 @@ func (sv *StaticValidator) ValidateQuantumDrop(ctx context.Context, drop *packag
 	result.Issues = sv.aggregateIssues(result)
 	result.Recommendations = sv.generateRecommendations(result)
 	result.ValidationTime = time.Since(startTime)
+	report.completed(CheckValidation, result.OverallScore)
 
 	logger.WithComponent("validation").Info("Static validation completed",
 		zap.String("drop_name", drop.Name),
@@ -170,8 +261,28 @@ func (sv *StaticValidator) ValidateQuantumDrop(ctx context.Context, drop *packag
 	return result, nil
 }
 
-// validateSecurity performs security-focused LLM validation
+// validateSecurity performs security-focused LLM validation, in chunks when
+// the code does not fit the context window. The weakest chunk sets the score,
+// as one vulnerable file is enough to make the drop vulnerable.
 func (sv *StaticValidator) validateSecurity(ctx context.Context, codeContent string, dropType packaging.DropType) (int, []types.SecurityFinding, error) {
+	chunks := llm.NewBudgeter(sv.llmClient).Split(ctx, codeContent, promptOverheadTokens)
+	if len(chunks) == 1 {
+		return sv.validateSecurityChunk(ctx, codeContent, dropType)
+	}
+	lowest := 100
+	findings := make([][]types.SecurityFinding, len(chunks))
+	for i, chunk := range chunks {
+		score, chunkFindings, err := sv.validateSecurityChunk(ctx, chunk, dropType)
+		if err != nil {
+			return 0, nil, fmt.Errorf("part %d of %d: %w", i+1, len(chunks), err)
+		}
+		lowest = min(lowest, score)
+		findings[i] = chunkFindings
+	}
+	return lowest, mergeSecurityFindings(findings), nil
+}
+
+func (sv *StaticValidator) validateSecurityChunk(ctx context.Context, codeContent string, dropType packaging.DropType) (int, []types.SecurityFinding, error) {
 	prompt := fmt.Sprintf(`You are a senior cybersecurity expert and penetration tester reviewing code for enterprise deployment in a Fortune 500 company.
 
 CRITICAL SECURITY ASSESSMENT for %s:
@@ -283,8 +394,28 @@ RESPOND WITH JSON:
 	return securityResult.SecurityScore, securityResult.Findings, nil
 }
 
-// validateQuality performs code quality-focused LLM validation
+// validateQuality performs code quality-focused LLM validation, in chunks
+// when the code does not fit the context window, weighting each chunk's
+// score by its size
 func (sv *StaticValidator) validateQuality(ctx context.Context, codeContent string, dropType packaging.DropType) (int, []QualityFinding, error) {
+	chunks := llm.NewBudgeter(sv.llmClient).Split(ctx, codeContent, promptOverheadTokens)
+	if len(chunks) == 1 {
+		return sv.validateQualityChunk(ctx, codeContent, dropType)
+	}
+	scores := make([]int, len(chunks))
+	weights := make([]int, len(chunks))
+	findings := make([][]QualityFinding, len(chunks))
+	for i, chunk := range chunks {
+		score, chunkFindings, err := sv.validateQualityChunk(ctx, chunk, dropType)
+		if err != nil {
+			return 0, nil, fmt.Errorf("part %d of %d: %w", i+1, len(chunks), err)
+		}
+		scores[i], weights[i], findings[i] = score, llm.EstimateTokens(chunk), chunkFindings
+	}
+	return weightedScore(scores, weights), mergeQualityFindings(findings), nil
+}
+
+func (sv *StaticValidator) validateQualityChunk(ctx context.Context, codeContent string, dropType packaging.DropType) (int, []QualityFinding, error) {
 	prompt := fmt.Sprintf(`You are a principal software engineer and code review expert with 15+ years of experience, reviewing code for production deployment at a tech unicorn.
 
 CODE QUALITY ASSESSMENT for %s:
@@ -404,8 +535,15 @@ RESPOND WITH JSON:
 	return qualityResult.QualityScore, qualityResult.Findings, nil
 }
 
-// validateArchitecture performs architecture-focused LLM validation
+// validateArchitecture performs architecture-focused LLM validation. The
+// architecture is judged as a whole, so code that does not fit the context
+// window is summarized rather than reviewed in chunks.
 func (sv *StaticValidator) validateArchitecture(ctx context.Context, codeContent string, projectStructure string, dropType packaging.DropType) (int, []ArchitectureFinding, error) {
+	codeContent, err := llm.NewBudgeter(sv.llmClient).Fit(ctx, codeContent, promptOverheadTokens+llm.EstimateTokens(projectStructure))
+	if err != nil {
+		return 0, nil, fmt.Errorf("LLM architecture validation failed: %w", err)
+	}
+
 	prompt := fmt.Sprintf(`You are a senior solutions architect and enterprise architect with deep expertise in system design, reviewing architecture for enterprise deployment.
 
 ARCHITECTURE ASSESSMENT for %s:
@@ -550,10 +688,17 @@ func (sv *StaticValidator) extractCodeContent(drop *packaging.QuantumDrop) (stri
 	var codeContent strings.Builder
 	var projectStructure strings.Builder
 
+	// Sorted, so chunks of large drops split the same way every time
+	paths := make([]string, 0, len(drop.Files))
+	for filePath := range drop.Files {
+		paths = append(paths, filePath)
+	}
+	sort.Strings(paths)
+
 	projectStructure.WriteString("Project Structure:\n")
-	for filePath, content := range drop.Files {
+	for _, filePath := range paths {
 		projectStructure.WriteString(fmt.Sprintf("- %s\n", filePath))
-		codeContent.WriteString(fmt.Sprintf("=== %s ===\n%s\n\n", filePath, content))
+		codeContent.WriteString(fmt.Sprintf("=== %s ===\n%s\n\n", filePath, drop.Files[filePath]))
 	}
 
 	return codeContent.String(), projectStructure.String()
@@ -574,6 +719,11 @@ func (sv *StaticValidator) calculateOverallScore(scores []int) int {
 
 func (sv *StaticValidator) assessDeploymentReadiness(result *StaticValidationResult) bool {
 	// Enterprise deployment thresholds
+	for _, pr := range result.PluginResults {
+		if pr.Blocking() {
+			return false
+		}
+	}
 	return result.SecurityScore >= 85 &&
 		result.QualityScore >= 80 &&
 		result.ArchitectureScore >= 80 &&
@@ -645,6 +795,26 @@ func (sv *StaticValidator) aggregateIssues(result *StaticValidationResult) []Val
 		}
 	}
 
+	// Convert plugin findings to validation issues
+	for _, pr := range result.PluginResults {
+		for _, finding := range pr.Findings {
+			severity := strings.ToUpper(finding.Severity)
+			if severity == "CRITICAL" || severity == "HIGH" {
+				category := "Plugin: " + pr.Plugin
+				if p := finding.Provenance; p != nil {
+					category = fmt.Sprintf("Rule: %s v%d (%s)", p.Name, p.Version, p.RuleID)
+				}
+				issues = append(issues, ValidationIssue{
+					Severity:    severity,
+					Category:    category,
+					Message:     finding.Message,
+					Resource:    finding.Location,
+					Remediation: finding.Remediation,
+				})
+			}
+		}
+	}
+
 	return issues
 }
 
@@ -44,6 +44,7 @@ type ProjectAnalysis struct {
 	PotentialIssues     []string          `json:"potential_issues"`
 	BestPractices       []string          `json:"best_practices"`
 	SecurityConsiderations []string       `json:"security_considerations"`
+	Heuristic           bool              `json:"heuristic,omitempty"` // Guessed from file names because the LLM analysis was unusable
 }
 
 // UniversalBuildResult contains results from LLM-guided universal build
@@ -414,6 +415,7 @@ func (uv *UniversalValidator) fallbackProjectAnalysis(files map[string]string) *
 		BuildTool:    "unknown",
 		Confidence:   0.1,
 		Recommendations: []string{"Manual analysis required - AI analysis failed"},
+		Heuristic:    true,
 	}
 	
 	// Simple heuristics
@@ -8,8 +8,11 @@ import (
 	"strings"
 	"time"
 
+	"QLP/internal/audit"
 	"QLP/internal/llm"
 	"QLP/internal/logger"
+	"QLP/internal/metering"
+	"QLP/internal/metrics"
 	"QLP/internal/models"
 	"QLP/internal/sandbox"
 	"QLP/internal/types"
@@ -92,6 +95,8 @@ func (ve *ValidationEngine) ValidateTaskOutput(ctx context.Context, task models.
 			zap.Int("quality_score", result.QualityScore),
 			zap.Bool("passed", result.Passed),
 			zap.String("mode", "fast"))
+		metrics.ObserveValidation("fast", result.Passed, result.OverallScore, result.SecurityScore, result.QualityScore)
+		metering.Record(ctx, audit.TenantFromContext(ctx), metering.ValidationMinutes, result.ValidationTime.Minutes())
 		return result, nil
 	}
 
@@ -138,6 +143,9 @@ func (ve *ValidationEngine) ValidateTaskOutput(ctx context.Context, task models.
 		zap.Int("quality_score", result.QualityScore),
 		zap.Duration("validation_time", result.ValidationTime))
 
+	metrics.ObserveValidation("full", result.Passed, result.OverallScore, result.SecurityScore, result.QualityScore)
+	metering.Record(ctx, audit.TenantFromContext(ctx), metering.ValidationMinutes, result.ValidationTime.Minutes())
+
 	return result, nil
 }
 
@@ -296,8 +304,7 @@ func (gsv *GoSyntaxValidator) Validate(ctx context.Context, output string) (*Syn
 	}
 
 	for i, code := range codeBlocks {
-		// Basic Go syntax checks
-		issues := gsv.checkGoSyntax(code)
+		issues, warnings := gsv.checkGo(code)
 		if len(issues) > 0 {
 			result.Score -= 20
 			result.Valid = false
@@ -305,9 +312,6 @@ func (gsv *GoSyntaxValidator) Validate(ctx context.Context, output string) (*Syn
 				result.Issues = append(result.Issues, fmt.Sprintf("Block %d: %s", i+1, issue))
 			}
 		}
-
-		// Best practices checks
-		warnings := gsv.checkGoBestPractices(code)
 		result.Warnings = append(result.Warnings, warnings...)
 	}
 
@@ -318,52 +322,17 @@ func (gsv *GoSyntaxValidator) Validate(ctx context.Context, output string) (*Syn
 	return result, nil
 }
 
-func (gsv *GoSyntaxValidator) checkGoSyntax(code string) []string {
-	var issues []string
-
-	// Basic syntax checks
-	if !strings.Contains(code, "package ") {
-		issues = append(issues, "Missing package declaration")
-	}
-
-	// Check for basic Go syntax errors
-	patterns := map[string]string{
-		`func\s+\w+\s*\([^)]*\)\s*[^{]*\s*{`: "Function declaration",
-		`import\s*\(`: "Import statement",
-		`var\s+\w+\s+\w+`: "Variable declaration",
-	}
-
-	for pattern := range patterns {
-		if matched, _ := regexp.MatchString(pattern, code); !matched && strings.Contains(code, "func ") {
-			// Only check function patterns if there are functions
-			continue
+// checkGo parses and type-checks a code block with the Go analyzer,
+// returning its syntax errors as issues and its other findings as warnings
+func (gsv *GoSyntaxValidator) checkGo(code string) (issues, warnings []string) {
+	for _, f := range AnalyzeGo(map[string]string{"main.go": code}).Findings {
+		if f.Rule == "syntax-error" {
+			issues = append(issues, fmt.Sprintf("line %d: %s", f.Line, f.Message))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("line %d: %s (%s)", f.Line, f.Message, f.Rule))
 		}
 	}
-
-	// Check for unmatched braces
-	openBraces := strings.Count(code, "{")
-	closeBraces := strings.Count(code, "}")
-	if openBraces != closeBraces {
-		issues = append(issues, fmt.Sprintf("Unmatched braces: %d open, %d close", openBraces, closeBraces))
-	}
-
-	return issues
-}
-
-func (gsv *GoSyntaxValidator) checkGoBestPractices(code string) []string {
-	var warnings []string
-
-	// Check for error handling
-	if strings.Contains(code, "err") && !strings.Contains(code, "if err != nil") {
-		warnings = append(warnings, "Consider proper error handling")
-	}
-
-	// Check for exported functions without comments
-	if matched, _ := regexp.MatchString(`func\s+[A-Z]\w*`, code); matched && !strings.Contains(code, "//") {
-		warnings = append(warnings, "Exported functions should have comments")
-	}
-
-	return warnings
+	return issues, warnings
 }
 
 // Helper functions
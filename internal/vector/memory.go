@@ -0,0 +1,289 @@
+package vector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"QLP/internal/audit"
+	"QLP/internal/database"
+)
+
+// MemoryEntry is a completed, validated intent remembered for reuse. It is
+// only recalled for intents of the tenant that submitted it.
+type MemoryEntry struct {
+	IntentID     string            `json:"intent_id"`
+	TenantID     string            `json:"tenant_id,omitempty"`
+	CapsuleID    string            `json:"capsule_id"`
+	UserInput    string            `json:"user_input"`
+	Summary      string            `json:"summary"`
+	OverallScore int               `json:"overall_score"`
+	TaskOutputs  map[string]string `json:"task_outputs"` // task type -> output excerpt
+	CreatedAt    time.Time         `json:"created_at"`
+	Embedding    []float32         `json:"-"`
+}
+
+// Recollection is a remembered entry matched against a new intent
+type Recollection struct {
+	Entry      MemoryEntry `json:"entry"`
+	Similarity float64     `json:"similarity"`
+}
+
+// MemoryStore persists generation memory entries and searches a tenant's
+// entries by embedding
+type MemoryStore interface {
+	Save(ctx context.Context, entry MemoryEntry) error
+	Search(ctx context.Context, tenantID string, embedding []float32, limit int) ([]Recollection, error)
+}
+
+// maxOutputExcerpt bounds how much of each task output is kept in memory
+const maxOutputExcerpt = 4000
+
+// GenerationMemory embeds completed intents and retrieves relevant prior
+// solutions for new intents
+type GenerationMemory struct {
+	vectors       *VectorService
+	store         MemoryStore
+	minScore      int
+	minSimilarity float64
+}
+
+// NewGenerationMemory creates a memory backed by Postgres when connected and
+// an in-process store otherwise
+func NewGenerationMemory(vs *VectorService, db *database.Database) *GenerationMemory {
+	var store MemoryStore = NewInMemoryMemoryStore()
+	if db != nil && db.IsConnected() {
+		store = NewPostgresMemoryStore(db)
+	}
+	return &GenerationMemory{
+		vectors:       vs,
+		store:         store,
+		minScore:      70,
+		minSimilarity: 0.75,
+	}
+}
+
+// SetThresholds sets the minimum capsule score worth remembering and the
+// minimum similarity for a memory to be recalled
+func (gm *GenerationMemory) SetThresholds(minScore int, minSimilarity float64) {
+	gm.minScore = minScore
+	gm.minSimilarity = minSimilarity
+}
+
+// Remember stores a completed intent if its capsule scored well enough,
+// for the tenant of ctx unless the entry names one
+func (gm *GenerationMemory) Remember(ctx context.Context, entry MemoryEntry) error {
+	if entry.OverallScore < gm.minScore {
+		return nil
+	}
+	if entry.TenantID == "" {
+		entry.TenantID = audit.TenantFromContext(ctx)
+	}
+
+	embedding, err := gm.vectors.GenerateEmbedding(ctx, entry.UserInput+"\n"+entry.Summary)
+	if err != nil {
+		return fmt.Errorf("failed to embed memory entry: %w", err)
+	}
+	entry.Embedding = embedding
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	for taskType, output := range entry.TaskOutputs {
+		if len(output) > maxOutputExcerpt {
+			entry.TaskOutputs[taskType] = output[:maxOutputExcerpt]
+		}
+	}
+
+	return gm.store.Save(ctx, entry)
+}
+
+// Recall returns the prior solutions of the tenant of ctx similar to
+// userInput, best match first
+func (gm *GenerationMemory) Recall(ctx context.Context, userInput string, limit int) ([]Recollection, error) {
+	embedding, err := gm.vectors.GenerateEmbedding(ctx, userInput)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	matches, err := gm.store.Search(ctx, audit.TenantFromContext(ctx), embedding, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	recollections := make([]Recollection, 0, len(matches))
+	for _, m := range matches {
+		if m.Similarity >= gm.minSimilarity {
+			recollections = append(recollections, m)
+		}
+	}
+	return recollections, nil
+}
+
+// PromptContext renders recollections as prior-solution context for agent
+// prompts. When clone is set the best match is presented as a project to adapt.
+func PromptContext(recollections []Recollection, clone bool) []string {
+	var sections []string
+	for i, r := range recollections {
+		var b strings.Builder
+		if clone && i == 0 {
+			fmt.Fprintf(&b, "ADAPT THIS PREVIOUS PROJECT (%.0f%% match, scored %d/100): %s\n",
+				r.Similarity*100, r.Entry.OverallScore, r.Entry.UserInput)
+		} else {
+			fmt.Fprintf(&b, "Prior solution (%.0f%% match, scored %d/100): %s\n",
+				r.Similarity*100, r.Entry.OverallScore, r.Entry.UserInput)
+		}
+		if r.Entry.Summary != "" {
+			fmt.Fprintf(&b, "Summary: %s\n", r.Entry.Summary)
+		}
+
+		taskTypes := make([]string, 0, len(r.Entry.TaskOutputs))
+		for taskType := range r.Entry.TaskOutputs {
+			taskTypes = append(taskTypes, taskType)
+		}
+		sort.Strings(taskTypes)
+		for _, taskType := range taskTypes {
+			output := r.Entry.TaskOutputs[taskType]
+			// Only the clone target carries full excerpts, the rest stay brief
+			if !(clone && i == 0) && len(output) > 500 {
+				output = output[:500] + "..."
+			}
+			fmt.Fprintf(&b, "[%s]\n%s\n", taskType, output)
+		}
+		sections = append(sections, b.String())
+	}
+	return sections
+}
+
+// InMemoryMemoryStore keeps memory entries in process, for development and tests
+type InMemoryMemoryStore struct {
+	mu      sync.RWMutex
+	entries []MemoryEntry
+}
+
+func NewInMemoryMemoryStore() *InMemoryMemoryStore {
+	return &InMemoryMemoryStore{}
+}
+
+func (s *InMemoryMemoryStore) Save(_ context.Context, entry MemoryEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *InMemoryMemoryStore) Search(_ context.Context, tenantID string, embedding []float32, limit int) ([]Recollection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	results := make([]Recollection, 0, len(s.entries))
+	for _, e := range s.entries {
+		if e.TenantID != tenantID {
+			continue
+		}
+		results = append(results, Recollection{Entry: e, Similarity: cosineSimilarity(embedding, e.Embedding)})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Similarity > results[j].Similarity })
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// PostgresMemoryStore keeps memory entries in the generation_memory table using pgvector
+type PostgresMemoryStore struct {
+	db *database.Database
+}
+
+func NewPostgresMemoryStore(db *database.Database) *PostgresMemoryStore {
+	return &PostgresMemoryStore{db: db}
+}
+
+func (s *PostgresMemoryStore) Save(ctx context.Context, entry MemoryEntry) error {
+	embeddingJSON, err := json.Marshal(entry.Embedding)
+	if err != nil {
+		return fmt.Errorf("failed to marshal embedding: %w", err)
+	}
+	outputsJSON, err := json.Marshal(entry.TaskOutputs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task outputs: %w", err)
+	}
+
+	query := `
+		INSERT INTO generation_memory (intent_id, tenant_id, capsule_id, user_input, summary, overall_score, task_outputs, embedding, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8::vector, $9)
+		ON CONFLICT (intent_id) DO UPDATE SET
+			tenant_id = EXCLUDED.tenant_id,
+			capsule_id = EXCLUDED.capsule_id,
+			summary = EXCLUDED.summary,
+			overall_score = EXCLUDED.overall_score,
+			task_outputs = EXCLUDED.task_outputs,
+			embedding = EXCLUDED.embedding
+	`
+	_, err = s.db.GetConnection().ExecContext(ctx, query,
+		entry.IntentID, entry.TenantID, entry.CapsuleID, entry.UserInput, entry.Summary,
+		entry.OverallScore, outputsJSON, string(embeddingJSON), entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store generation memory: %w", err)
+	}
+
+	log.Printf("🧠 Remembered intent %s for future generations", entry.IntentID)
+	return nil
+}
+
+func (s *PostgresMemoryStore) Search(ctx context.Context, tenantID string, embedding []float32, limit int) ([]Recollection, error) {
+	embeddingJSON, err := json.Marshal(embedding)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal query embedding: %w", err)
+	}
+
+	query := `
+		SELECT intent_id, tenant_id, capsule_id, user_input, summary, overall_score, task_outputs, created_at,
+		       1 - (embedding <=> $1::vector) AS similarity
+		FROM generation_memory
+		WHERE tenant_id = $2
+		ORDER BY embedding <=> $1::vector
+		LIMIT $3
+	`
+	rows, err := s.db.GetConnection().QueryContext(ctx, query, string(embeddingJSON), tenantID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search generation memory: %w", err)
+	}
+	defer rows.Close()
+
+	var results []Recollection
+	for rows.Next() {
+		var r Recollection
+		var outputsJSON []byte
+		if err := rows.Scan(&r.Entry.IntentID, &r.Entry.TenantID, &r.Entry.CapsuleID, &r.Entry.UserInput, &r.Entry.Summary,
+			&r.Entry.OverallScore, &outputsJSON, &r.Entry.CreatedAt, &r.Similarity); err != nil {
+			continue // Skip malformed records
+		}
+		if err := json.Unmarshal(outputsJSON, &r.Entry.TaskOutputs); err != nil {
+			continue
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
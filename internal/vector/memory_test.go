@@ -0,0 +1,71 @@
+package vector
+
+import (
+	"context"
+	"testing"
+
+	"QLP/internal/audit"
+	"QLP/internal/database"
+)
+
+func newTestMemory() *GenerationMemory {
+	gm := &GenerationMemory{
+		vectors: NewVectorService(&database.Database{}, nil),
+		store:   NewInMemoryMemoryStore(),
+	}
+	gm.SetThresholds(70, 0)
+	return gm
+}
+
+func TestGenerationMemoryIsolatesTenants(t *testing.T) {
+	gm := newTestMemory()
+	acme := audit.WithTenant(context.Background(), "acme")
+	globex := audit.WithTenant(context.Background(), "globex")
+
+	entry := MemoryEntry{
+		IntentID:     "intent-1",
+		UserInput:    "Build an orders API in Go with PostgreSQL",
+		OverallScore: 90,
+		TaskOutputs:  map[string]string{"codegen": "package orders // acme's proprietary pricing"},
+	}
+	if err := gm.Remember(acme, entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := gm.Remember(acme, MemoryEntry{IntentID: "intent-2", UserInput: "Build an orders API", OverallScore: 40}); err != nil {
+		t.Fatal(err)
+	}
+
+	recalled, err := gm.Recall(acme, "Build an orders API in Go", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recalled) != 1 || recalled[0].Entry.IntentID != "intent-1" || recalled[0].Entry.TenantID != "acme" {
+		t.Errorf("acme recalled %+v", recalled)
+	}
+
+	for name, ctx := range map[string]context.Context{"globex": globex, "no tenant": context.Background()} {
+		recalled, err := gm.Recall(ctx, "Build an orders API in Go", 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(recalled) != 0 {
+			t.Errorf("%s recalled acme's memory: %+v", name, recalled)
+		}
+	}
+}
+
+func TestInMemoryMemoryStoreSearch(t *testing.T) {
+	store := NewInMemoryMemoryStore()
+	ctx := context.Background()
+	store.Save(ctx, MemoryEntry{IntentID: "near", TenantID: "acme", Embedding: []float32{1, 0.1}})
+	store.Save(ctx, MemoryEntry{IntentID: "far", TenantID: "acme", Embedding: []float32{0, 1}})
+	store.Save(ctx, MemoryEntry{IntentID: "other", TenantID: "globex", Embedding: []float32{1, 0}})
+
+	results, err := store.Search(ctx, "acme", []float32{1, 0}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Entry.IntentID != "near" {
+		t.Errorf("results = %+v", results)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"log"
 
 	"QLP/internal/database"
+	"QLP/internal/embeddings"
 	"QLP/internal/llm"
 	"QLP/internal/models"
 )
@@ -15,6 +16,7 @@ import (
 type VectorService struct {
 	db        *database.Database
 	llmClient llm.Client
+	embedder  *embeddings.Embedder
 }
 
 type SimilarIntent struct {
@@ -34,6 +36,12 @@ func NewVectorService(db *database.Database, llmClient llm.Client) *VectorServic
 	}
 }
 
+// SetEmbedder routes embeddings through a dedicated provider instead of the
+// LLM client, shaped to the store's dimension and normalization
+func (vs *VectorService) SetEmbedder(e *embeddings.Embedder) {
+	vs.embedder = e
+}
+
 // GenerateEmbedding creates a vector embedding for the given text
 func (vs *VectorService) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
 	if !vs.db.IsConnected() {
@@ -41,8 +49,13 @@ func (vs *VectorService) GenerateEmbedding(ctx context.Context, text string) ([]
 		return vs.generateSimpleEmbedding(text), nil
 	}
 
-	// Use OpenAI's embedding API
-	embedding, err := vs.llmClient.GenerateEmbedding(ctx, text)
+	var embedding []float32
+	var err error
+	if vs.embedder != nil {
+		embedding, err = vs.embedder.EmbedOne(ctx, text)
+	} else {
+		embedding, err = vs.llmClient.GenerateEmbedding(ctx, text)
+	}
 	if err != nil {
 		log.Printf("⚠️  Failed to generate LLM embedding: %v", err)
 		// Fallback to simple embedding
@@ -0,0 +1,71 @@
+package workspace
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+const sharedComposeFile = "docker-compose.yml"
+
+func isComposeFile(p string) bool {
+	switch path.Base(p) {
+	case "docker-compose.yml", "docker-compose.yaml", "compose.yml", "compose.yaml":
+		return true
+	}
+	return false
+}
+
+// mergeCompose adds the services, volumes and networks of addition to the
+// shared Compose file. Identical definitions are shared; a name already taken
+// by a different definition is prefixed with the service name so earlier
+// services keep running unchanged.
+func mergeCompose(shared, addition, service string) (string, error) {
+	base := map[string]interface{}{}
+	if shared != "" {
+		if err := yaml.Unmarshal([]byte(shared), &base); err != nil {
+			return "", fmt.Errorf("invalid shared compose file: %w", err)
+		}
+	}
+	add := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(addition), &add); err != nil {
+		return "", fmt.Errorf("invalid compose file: %w", err)
+	}
+
+	if _, ok := base["version"]; !ok {
+		if version, ok := add["version"]; ok {
+			base["version"] = version
+		}
+	}
+
+	for _, section := range []string{"services", "volumes", "networks"} {
+		incoming, _ := add[section].(map[string]interface{})
+		if len(incoming) == 0 {
+			continue
+		}
+		current, _ := base[section].(map[string]interface{})
+		if current == nil {
+			current = make(map[string]interface{})
+		}
+		for name, definition := range incoming {
+			existing, taken := current[name]
+			switch {
+			case !taken:
+				current[name] = definition
+			case reflect.DeepEqual(existing, definition):
+				// Already shared, e.g. the same database image
+			default:
+				current[service+"-"+name] = definition
+			}
+		}
+		base[section] = current
+	}
+
+	out, err := yaml.Marshal(base)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
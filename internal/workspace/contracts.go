@@ -0,0 +1,81 @@
+package workspace
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// routePatterns recognise HTTP route registrations in common frameworks.
+// Each pattern captures the method (when present) and the path.
+var routePatterns = []*regexp.Regexp{
+	// Go net/http 1.22 patterns: mux.HandleFunc("GET /users/{id}", ...)
+	regexp.MustCompile(`Handle(?:Func)?\(\s*"(?:(GET|POST|PUT|PATCH|DELETE) )?(/[^"]*)"`),
+	// gin, echo, chi, fiber: r.GET("/users", ...), e.Post("/users", ...)
+	regexp.MustCompile(`\.(GET|POST|PUT|PATCH|DELETE|Get|Post|Put|Patch|Delete)\(\s*"(/[^"]*)"`),
+	// express: app.get('/users', ...)
+	regexp.MustCompile(`\b(?:app|router)\.(get|post|put|patch|delete)\(\s*['"](/[^'"]*)['"]`),
+	// Flask and FastAPI: @app.get("/users"), @app.route("/users")
+	regexp.MustCompile(`@\w+\.(get|post|put|patch|delete|route)\(\s*['"](/[^'"]*)['"]`),
+	// Spring: @GetMapping("/users")
+	regexp.MustCompile(`@(Get|Post|Put|Patch|Delete|Request)Mapping\(\s*(?:value\s*=\s*)?"(/[^"]*)"`),
+}
+
+// ExtractContracts derives the HTTP contract a service exposes from its
+// route registrations and any OpenAPI document it ships
+func ExtractContracts(service, root string, files map[string]string) []Contract {
+	endpoints := make(map[string]bool)
+	var spec string
+
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		content := files[p]
+		if spec == "" && isOpenAPISpec(p, content) {
+			spec = root + "/" + strings.TrimPrefix(p, "/")
+			continue
+		}
+		if _, ok := languageByExt[path.Ext(p)]; !ok || isTestFile(p) {
+			continue
+		}
+		for _, pattern := range routePatterns {
+			for _, m := range pattern.FindAllStringSubmatch(content, -1) {
+				method := strings.ToUpper(m[1])
+				if method == "" || method == "ROUTE" || method == "REQUEST" {
+					method = "ANY"
+				}
+				endpoints[fmt.Sprintf("%s %s", method, m[2])] = true
+			}
+		}
+	}
+
+	if len(endpoints) == 0 && spec == "" {
+		return nil
+	}
+	contract := Contract{Service: service, Kind: "http", SpecFile: spec}
+	for e := range endpoints {
+		contract.Endpoints = append(contract.Endpoints, e)
+	}
+	sort.Strings(contract.Endpoints)
+	return []Contract{contract}
+}
+
+func isOpenAPISpec(p, content string) bool {
+	switch path.Ext(p) {
+	case ".yaml", ".yml", ".json":
+		return strings.Contains(content, "openapi") || strings.Contains(content, "swagger")
+	}
+	return false
+}
+
+func isTestFile(p string) bool {
+	base := path.Base(p)
+	return strings.HasSuffix(base, "_test.go") || strings.HasPrefix(base, "test_") ||
+		strings.Contains(base, ".test.") || strings.Contains(base, ".spec.")
+}
@@ -0,0 +1,61 @@
+package workspace
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"QLP/internal/audit"
+)
+
+// Routes returns the workspace endpoints:
+//
+//	GET /workspaces         lists workspaces, most recently updated first
+//	GET /workspaces/{id}    returns a workspace with its services and contracts
+//
+// Requests with an API key only see their tenant's workspaces.
+func Routes(store *Store) map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /workspaces":      listHandler(store),
+		"GET /workspaces/{id}": getHandler(store),
+	}
+}
+
+func listHandler(store *Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		all, err := store.List()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		workspaces := make([]*Workspace, 0, len(all))
+		for _, ws := range all {
+			if audit.TenantAllowed(r, ws.TenantID) {
+				workspaces = append(workspaces, ws)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"workspaces": workspaces,
+		})
+	})
+}
+
+func getHandler(store *Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := store.Get(r.PathValue("id"))
+		if err == nil && !audit.TenantAllowed(r, ws.TenantID) {
+			err = ErrNotFound
+		}
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ws)
+	})
+}
@@ -0,0 +1,249 @@
+package workspace
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"QLP/internal/config"
+)
+
+var ErrNotFound = errors.New("workspace not found")
+
+const (
+	metadataFile = "workspace.json"
+	projectDir   = "project"
+)
+
+// Store keeps workspaces on disk as <root>/<id>/workspace.json with the
+// composed project files under <root>/<id>/project/
+type Store struct {
+	root string
+	mu   sync.Mutex
+}
+
+// NewStore creates a workspace store rooted at dir
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create workspace directory: %w", err)
+	}
+	return &Store{root: dir}, nil
+}
+
+// NewStoreFromEnv opens the store at QLP_WORKSPACE_DIR (default ./data/workspaces)
+func NewStoreFromEnv() (*Store, error) {
+	return NewStore(config.GetEnvOrDefault("QLP_WORKSPACE_DIR", "./data/workspaces"))
+}
+
+var nonID = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// Create starts an empty workspace
+func (s *Store) Create(name, tenantID string) (*Workspace, error) {
+	now := time.Now()
+	slug := strings.Trim(nonID.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if slug == "" {
+		slug = "workspace"
+	}
+	ws := &Workspace{
+		ID:          fmt.Sprintf("WS-%s-%d", slug, now.UnixNano()),
+		Name:        name,
+		TenantID:    tenantID,
+		Services:    []Service{},
+		SharedInfra: []string{},
+		Contracts:   []Contract{},
+		Capsules:    []CapsuleRef{},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.Save(ws); err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
+
+// Save writes the workspace metadata
+func (s *Store) Save(ws *Workspace) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir, err := s.dir(ws.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create workspace: %w", err)
+	}
+	data, err := json.MarshalIndent(ws, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, metadataFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to save workspace: %w", err)
+	}
+	return os.Rename(tmp, filepath.Join(dir, metadataFile))
+}
+
+// Get loads a workspace by ID
+func (s *Store) Get(id string) (*Workspace, error) {
+	dir, err := s.dir(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, metadataFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace: %w", err)
+	}
+	var ws Workspace
+	if err := json.Unmarshal(data, &ws); err != nil {
+		return nil, fmt.Errorf("failed to parse workspace %s: %w", id, err)
+	}
+	return &ws, nil
+}
+
+// Delete removes a workspace with its composed project
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir, err := s.dir(id)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(dir, metadataFile)); errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to delete workspace: %w", err)
+	}
+	return nil
+}
+
+// List returns all workspaces, most recently updated first
+func (s *Store) List() ([]*Workspace, error) {
+	entries, err := os.ReadDir(s.root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspaces: %w", err)
+	}
+	workspaces := make([]*Workspace, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		ws, err := s.Get(entry.Name())
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, ws)
+	}
+	sort.Slice(workspaces, func(i, j int) bool { return workspaces[i].UpdatedAt.After(workspaces[j].UpdatedAt) })
+	return workspaces, nil
+}
+
+// Resolve finds a workspace by ID or name; "last" selects the most recently
+// updated one, so "add a billing service to the project generated last week"
+// can be run against whatever was built most recently
+func (s *Store) Resolve(ref string) (*Workspace, error) {
+	if ws, err := s.Get(ref); err == nil {
+		return ws, nil
+	}
+	workspaces, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, ws := range workspaces {
+		if ref == "last" || strings.EqualFold(ws.Name, ref) {
+			return ws, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Files returns the composed project files keyed by project path
+func (s *Store) Files(ws *Workspace) (map[string]string, error) {
+	dir, err := s.dir(ws.ID)
+	if err != nil {
+		return nil, err
+	}
+	root := filepath.Join(dir, projectDir)
+	files := make(map[string]string)
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && p == root {
+			return filepath.SkipDir
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace files: %w", err)
+	}
+	return files, nil
+}
+
+// Apply composes an addition into the workspace, writes the placed files and
+// saves the updated metadata
+func (s *Store) Apply(ws *Workspace, add Addition) (*Composition, error) {
+	existing, err := s.Files(ws)
+	if err != nil {
+		return nil, err
+	}
+	composition, err := ws.Compose(existing, add)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := s.dir(ws.ID)
+	if err != nil {
+		return nil, err
+	}
+	root := filepath.Join(dir, projectDir)
+	for p, content := range composition.Written {
+		full := filepath.Join(root, filepath.FromSlash(p))
+		if !strings.HasPrefix(full, root+string(filepath.Separator)) {
+			return nil, fmt.Errorf("invalid workspace path: %q", p)
+		}
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create workspace directory: %w", err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write workspace file: %w", err)
+		}
+	}
+
+	if err := s.Save(ws); err != nil {
+		return nil, err
+	}
+	return composition, nil
+}
+
+func (s *Store) dir(id string) (string, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return "", fmt.Errorf("invalid workspace id: %q", id)
+	}
+	return filepath.Join(s.root, id), nil
+}
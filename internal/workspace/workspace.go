@@ -0,0 +1,279 @@
+// Package workspace composes the output of several related intents into one
+// project. A workspace tracks the services generated so far, the
+// infrastructure they share and the contracts between them, so follow-up
+// intents extend the project instead of replacing it.
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Workspace is a multi-intent project
+type Workspace struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	TenantID    string       `json:"tenant_id,omitempty"`
+	Services    []Service    `json:"services"`
+	SharedInfra []string     `json:"shared_infra"`
+	Contracts   []Contract   `json:"contracts"`
+	Capsules    []CapsuleRef `json:"capsules"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// Service is a component added to the workspace by one intent
+type Service struct {
+	Name      string    `json:"name"`
+	Root      string    `json:"root"`
+	IntentID  string    `json:"intent_id"`
+	CapsuleID string    `json:"capsule_id"`
+	Languages []string  `json:"languages,omitempty"`
+	AddedAt   time.Time `json:"added_at"`
+}
+
+// Contract is an interface a service exposes to the rest of the workspace
+type Contract struct {
+	Service   string   `json:"service"`
+	Kind      string   `json:"kind"` // http
+	Endpoints []string `json:"endpoints,omitempty"`
+	SpecFile  string   `json:"spec_file,omitempty"`
+}
+
+// CapsuleRef records a capsule that contributed to the workspace
+type CapsuleRef struct {
+	CapsuleID  string    `json:"capsule_id"`
+	IntentID   string    `json:"intent_id"`
+	IntentText string    `json:"intent_text"`
+	Service    string    `json:"service"`
+	AddedAt    time.Time `json:"added_at"`
+}
+
+// Service returns the named service, or nil
+func (w *Workspace) Service(name string) *Service {
+	for i := range w.Services {
+		if w.Services[i].Name == name {
+			return &w.Services[i]
+		}
+	}
+	return nil
+}
+
+// PromptContext describes the existing project so agents extend it
+func (w *Workspace) PromptContext() string {
+	if w == nil || len(w.Services) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "EXISTING PROJECT WORKSPACE %q (extend it; do not regenerate or replace existing services):\n", w.Name)
+	b.WriteString("Services:\n")
+	for _, s := range w.Services {
+		fmt.Fprintf(&b, "- %s in %s/", s.Name, s.Root)
+		if len(s.Languages) > 0 {
+			fmt.Fprintf(&b, " (%s)", strings.Join(s.Languages, ", "))
+		}
+		b.WriteString("\n")
+		for _, c := range w.Contracts {
+			if c.Service != s.Name {
+				continue
+			}
+			if len(c.Endpoints) > 0 {
+				fmt.Fprintf(&b, "  %s endpoints: %s\n", strings.ToUpper(c.Kind), strings.Join(c.Endpoints, ", "))
+			}
+			if c.SpecFile != "" {
+				fmt.Fprintf(&b, "  API spec: %s\n", c.SpecFile)
+			}
+		}
+	}
+	if len(w.SharedInfra) > 0 {
+		fmt.Fprintf(&b, "Shared infrastructure: %s\n", strings.Join(w.SharedInfra, ", "))
+	}
+	b.WriteString("Call existing services through their endpoints rather than duplicating their functionality, and reuse shared infrastructure.\n")
+	return b.String()
+}
+
+// Addition is the output of one intent to compose into a workspace
+type Addition struct {
+	IntentID   string
+	IntentText string
+	CapsuleID  string
+	// Files holds service code (codebase, tests, docs) keyed by project path
+	Files map[string]string
+	// Infra holds infrastructure files keyed by project path
+	Infra map[string]string
+}
+
+// Composition reports where an addition's files were placed
+type Composition struct {
+	Service   string            `json:"service"`
+	Written   map[string]string `json:"-"`
+	Conflicts []string          `json:"conflicts,omitempty"`
+}
+
+// Compose places an addition into the workspace: service files under
+// services/<name>/, Compose services merged into the shared
+// docker-compose.yml, and other infrastructure shared under infra/ unless it
+// conflicts with what earlier intents produced. existing holds the
+// workspace's current files.
+func (w *Workspace) Compose(existing map[string]string, add Addition) (*Composition, error) {
+	service := uniqueServiceName(ServiceName(add.IntentText), w)
+	root := "services/" + service
+	result := &Composition{Service: service, Written: make(map[string]string)}
+
+	for p, content := range add.Files {
+		result.Written[root+"/"+strings.TrimPrefix(p, "/")] = content
+	}
+
+	for p, content := range add.Infra {
+		p = strings.TrimPrefix(p, "/")
+		switch {
+		case isComposeFile(p):
+			merged, err := mergeCompose(firstNonEmpty(result.Written[sharedComposeFile], existing[sharedComposeFile]), content, service)
+			if err != nil {
+				return nil, fmt.Errorf("failed to merge %s: %w", p, err)
+			}
+			result.Written[sharedComposeFile] = merged
+			w.addSharedInfra(sharedComposeFile)
+		case path.Base(p) == "Dockerfile" || isKubernetesManifest(p, content):
+			// Build and deployment manifests belong to the service
+			result.Written[root+"/"+p] = content
+		default:
+			shared := "infra/" + strings.TrimPrefix(p, "infra/")
+			if current, ok := existing[shared]; ok && current != content {
+				scoped := "infra/" + service + "/" + strings.TrimPrefix(p, "infra/")
+				result.Written[scoped] = content
+				result.Conflicts = append(result.Conflicts, fmt.Sprintf("%s kept; %s's version written to %s", shared, service, scoped))
+				continue
+			}
+			result.Written[shared] = content
+			w.addSharedInfra(shared)
+		}
+	}
+
+	now := time.Now()
+	w.Services = append(w.Services, Service{
+		Name:      service,
+		Root:      root,
+		IntentID:  add.IntentID,
+		CapsuleID: add.CapsuleID,
+		Languages: detectLanguages(add.Files),
+		AddedAt:   now,
+	})
+	w.Contracts = append(w.Contracts, ExtractContracts(service, root, add.Files)...)
+	w.Capsules = append(w.Capsules, CapsuleRef{
+		CapsuleID:  add.CapsuleID,
+		IntentID:   add.IntentID,
+		IntentText: add.IntentText,
+		Service:    service,
+		AddedAt:    now,
+	})
+	w.UpdatedAt = now
+	return result, nil
+}
+
+func (w *Workspace) addSharedInfra(p string) {
+	for _, existing := range w.SharedInfra {
+		if existing == p {
+			return
+		}
+	}
+	w.SharedInfra = append(w.SharedInfra, p)
+	sort.Strings(w.SharedInfra)
+}
+
+var (
+	servicePhrase = regexp.MustCompile(`(?i)\b([a-z][a-z0-9-]*)[ -](?:micro)?service\b`)
+	stopWords     = map[string]bool{
+		"a": true, "an": true, "the": true, "new": true, "add": true, "create": true, "build": true,
+		"simple": true, "secure": true, "rest": true, "web": true, "api": true, "another": true,
+		"service": true, "microservice": true, "for": true, "with": true, "and": true, "that": true,
+	}
+	nonName = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// ServiceName derives a short service name from an intent, preferring the
+// word before "service" ("add a billing service" gives "billing")
+func ServiceName(intentText string) string {
+	for _, m := range servicePhrase.FindAllStringSubmatch(intentText, -1) {
+		if word := strings.ToLower(m[1]); !stopWords[word] {
+			return word
+		}
+	}
+
+	var words []string
+	for _, word := range strings.Fields(strings.ToLower(intentText)) {
+		word = nonName.ReplaceAllString(word, "")
+		if word == "" || stopWords[word] || len(word) < 3 {
+			continue
+		}
+		words = append(words, word)
+		if len(words) == 2 {
+			break
+		}
+	}
+	if len(words) == 0 {
+		return "service"
+	}
+	return strings.Join(words, "-")
+}
+
+func uniqueServiceName(name string, w *Workspace) string {
+	candidate := name
+	for i := 2; w.Service(candidate) != nil; i++ {
+		candidate = fmt.Sprintf("%s-%d", name, i)
+	}
+	return candidate
+}
+
+func isKubernetesManifest(p, content string) bool {
+	ext := path.Ext(p)
+	return (ext == ".yaml" || ext == ".yml") && strings.Contains(content, "apiVersion:") && strings.Contains(content, "kind:")
+}
+
+var languageByExt = map[string]string{
+	".go": "Go", ".py": "Python", ".js": "JavaScript", ".ts": "TypeScript", ".java": "Java", ".cs": "C#", ".rs": "Rust",
+}
+
+func detectLanguages(files map[string]string) []string {
+	seen := make(map[string]bool)
+	for p := range files {
+		if lang, ok := languageByExt[path.Ext(p)]; ok {
+			seen[lang] = true
+		}
+	}
+	languages := make([]string, 0, len(seen))
+	for lang := range seen {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+type contextKey struct{}
+
+// WithWorkspace attaches a workspace reference (ID, name or "last") to ctx so
+// the intent it carries is composed into that workspace
+func WithWorkspace(ctx context.Context, ref string) context.Context {
+	return context.WithValue(ctx, contextKey{}, ref)
+}
+
+// FromContext returns the workspace reference attached to ctx, if any
+func FromContext(ctx context.Context) string {
+	ref, _ := ctx.Value(contextKey{}).(string)
+	return ref
+}
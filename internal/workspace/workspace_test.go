@@ -0,0 +1,221 @@
+package workspace
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"QLP/internal/audit"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestServiceName(t *testing.T) {
+	cases := map[string]string{
+		"add a billing service to the project generated last week": "billing",
+		"Create a REST API service for user management":            "user-management",
+		"build a notification microservice":                        "notification",
+		"inventory tracker with postgres":                          "inventory-tracker",
+		"":                                                         "service",
+	}
+	for input, want := range cases {
+		if got := ServiceName(input); got != want {
+			t.Errorf("ServiceName(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+const usersCompose = `version: "3.8"
+services:
+  app:
+    build: .
+    ports: ["8080:8080"]
+  postgres:
+    image: postgres:16
+`
+
+const billingCompose = `version: "3.8"
+services:
+  app:
+    build: .
+    ports: ["8081:8080"]
+  postgres:
+    image: postgres:16
+  stripe-mock:
+    image: stripe/stripe-mock
+`
+
+func TestStoreComposesFollowUpIntents(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, err := store.Create("shop", "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.Apply(ws, Addition{
+		IntentID:   "INT-1",
+		IntentText: "Create a users service",
+		CapsuleID:  "QL-CAP-1",
+		Files: map[string]string{
+			"main.go": `mux.HandleFunc("GET /users/{id}", getUser)` + "\n" + `mux.HandleFunc("POST /users", createUser)`,
+		},
+		Infra: map[string]string{
+			"docker-compose.yml": usersCompose,
+			"main.tf":            `resource "azurerm_resource_group" "rg" {}`,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	// The follow-up finds the workspace by name and sees the first service
+	ws, err = store.Resolve("shop")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	context := ws.PromptContext()
+	for _, want := range []string{"users in services/users/", "GET /users/{id}", "docker-compose.yml"} {
+		if !strings.Contains(context, want) {
+			t.Errorf("PromptContext() missing %q:\n%s", want, context)
+		}
+	}
+
+	composition, err := store.Apply(ws, Addition{
+		IntentID:   "INT-2",
+		IntentText: "add a billing service to the project generated last week",
+		CapsuleID:  "QL-CAP-2",
+		Files:      map[string]string{"main.go": `r.POST("/invoices", create)`},
+		Infra: map[string]string{
+			"docker-compose.yml": billingCompose,
+			"main.tf":            `resource "azurerm_storage_account" "sa" {}`,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if composition.Service != "billing" {
+		t.Errorf("service = %q, want billing", composition.Service)
+	}
+	if len(composition.Conflicts) != 1 {
+		t.Errorf("conflicts = %v, want the main.tf conflict", composition.Conflicts)
+	}
+
+	files, err := store.Files(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"services/users/main.go", "services/billing/main.go", "infra/main.tf", "infra/billing/main.tf"} {
+		if _, ok := files[p]; !ok {
+			t.Errorf("missing %s in %v", p, keys(files))
+		}
+	}
+	if !strings.Contains(files["infra/main.tf"], "azurerm_resource_group") {
+		t.Error("existing shared infrastructure was replaced")
+	}
+
+	var compose struct {
+		Services map[string]interface{} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal([]byte(files["docker-compose.yml"]), &compose); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"app", "postgres", "billing-app", "stripe-mock"} {
+		if _, ok := compose.Services[name]; !ok {
+			t.Errorf("compose services missing %q: %v", name, compose.Services)
+		}
+	}
+	if len(compose.Services) != 4 {
+		t.Errorf("compose services = %d, want 4 (postgres shared)", len(compose.Services))
+	}
+
+	ws, err = store.Get(ws.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ws.Services) != 2 || len(ws.Capsules) != 2 || len(ws.Contracts) != 2 {
+		t.Errorf("services=%d capsules=%d contracts=%d, want 2 each", len(ws.Services), len(ws.Capsules), len(ws.Contracts))
+	}
+}
+
+func TestResolveLast(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Resolve("last"); err != ErrNotFound {
+		t.Fatalf("Resolve(last) on empty store error = %v, want ErrNotFound", err)
+	}
+	store.Create("first", "")
+	second, _ := store.Create("second", "")
+	got, err := store.Resolve("last")
+	if err != nil || got.ID != second.ID {
+		t.Errorf("Resolve(last) = %v, %v; want %s", got, err, second.ID)
+	}
+}
+
+func TestExtractContracts(t *testing.T) {
+	contracts := ExtractContracts("orders", "services/orders", map[string]string{
+		"app.py":         "@app.get(\"/orders\")\ndef list_orders(): ...",
+		"server.js":      "app.post('/orders', handler)",
+		"test_app.py":    "@app.get(\"/ignored\")",
+		"openapi.yaml":   "openapi: 3.0.0",
+		"OrderCtrl.java": `@GetMapping("/orders/{id}")`,
+	})
+	if len(contracts) != 1 {
+		t.Fatalf("contracts = %v", contracts)
+	}
+	want := []string{"GET /orders", "GET /orders/{id}", "POST /orders"}
+	if strings.Join(contracts[0].Endpoints, ",") != strings.Join(want, ",") {
+		t.Errorf("endpoints = %v, want %v", contracts[0].Endpoints, want)
+	}
+	if contracts[0].SpecFile != "services/orders/openapi.yaml" {
+		t.Errorf("spec = %q", contracts[0].SpecFile)
+	}
+}
+
+func keys(m map[string]string) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
+
+func TestRoutesScopeToTheAPIKey(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, err := store.Create("shop", "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	for pattern, h := range Routes(store) {
+		mux.Handle(pattern, h)
+	}
+	as := func(tenant, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req = req.WithContext(audit.WithTenant(req.Context(), tenant))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := as("t2", "/workspaces/"+ws.ID); rec.Code != http.StatusNotFound {
+		t.Errorf("get from another tenant: %d", rec.Code)
+	}
+	if rec := as("t2", "/workspaces"); strings.Contains(rec.Body.String(), ws.ID) {
+		t.Errorf("another tenant lists the workspace: %s", rec.Body)
+	}
+	if rec := as("t1", "/workspaces/"+ws.ID); rec.Code != http.StatusOK {
+		t.Errorf("get: %d %s", rec.Code, rec.Body)
+	}
+	if rec := as("t1", "/workspaces"); !strings.Contains(rec.Body.String(), ws.ID) {
+		t.Errorf("list: %s", rec.Body)
+	}
+}
@@ -3,96 +3,582 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"QLP/internal/agenttrace"
+	"QLP/internal/audit"
+	"QLP/internal/clarify"
+	"QLP/internal/batch"
+	"QLP/internal/capabilities"
+	"QLP/internal/capsulediff"
+	"QLP/internal/catalog"
 	"QLP/internal/config"
+	"QLP/internal/constraints"
+	"QLP/internal/database"
+	"QLP/internal/deployment/azure"
+	"QLP/internal/deployments"
+	"QLP/internal/diagnostics"
+	"QLP/internal/e2e"
+	"QLP/internal/embeddings"
+	"QLP/internal/erasure"
+	"QLP/internal/failures"
+	"QLP/internal/github"
+	"QLP/internal/hitl"
+	"QLP/internal/importer"
+	"QLP/internal/llm"
 	"QLP/internal/logger"
+	"QLP/internal/metering"
+	"QLP/internal/metrics"
+	"QLP/internal/models"
 	"QLP/internal/orchestrator"
+	"QLP/internal/packaging"
+	"QLP/internal/preview"
+	"QLP/internal/promotion"
+	"QLP/internal/prompts"
+	"QLP/internal/quota"
+	"QLP/internal/sandbox"
+	"QLP/internal/scheduler"
+	"QLP/internal/secrets"
+	"QLP/internal/storage"
+	"QLP/internal/tenants"
+	"QLP/internal/tracing"
+	"QLP/internal/trends"
+	"QLP/internal/validation"
+	"QLP/internal/workspace"
 	"go.uber.org/zap"
 )
 
+// stdin is shared by the menus, interactive mode and clarification prompts so
+// buffered input is not lost between readers
+var stdin = bufio.NewScanner(os.Stdin)
+
+// console receives progress output. Commands run with --json send it to
+// stderr so stdout carries only the result document.
+var console io.Writer = os.Stdout
+
 func main() {
-	// Load environment variables from .env file
-	config.LoadEnv()
-	
+	if err := execute(newRootCommand()); err != nil {
+		os.Exit(1)
+	}
+}
+
+// initProcess loads the environment and logging shared by every command
+func initProcess(jsonOutput bool) error {
+	// Load .env and the qlp.yaml profile; the environment overrides both
+	effective, err := config.Init(profileName)
+	if err != nil {
+		return err
+	}
+	activeConfig = effective
+
+	if jsonOutput {
+		console = os.Stderr
+		if os.Getenv("QLP_LOG_OUTPUT") == "" {
+			os.Setenv("QLP_LOG_OUTPUT", "stderr")
+		}
+	}
+
 	// Initialize logger from environment
 	if err := logger.InitFromEnv(); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to initialize logger: %w", err)
 	}
-	defer logger.Sync()
-	
+
+	// Mask resolved deployment secrets in all log output
+	logger.SetRedactor(secrets.Scrub)
+	log.SetOutput(logger.RedactingWriter(os.Stderr))
+
+	if effective.Path != "" {
+		logger.Logger.Info("Loaded config file",
+			zap.String("path", effective.Path),
+			zap.String("profile", effective.Profile),
+			zap.Int("settings", len(effective.Settings)))
+	}
+	for _, issue := range effective.Issues {
+		logger.Logger.Warn("Config file issue", zap.String("issue", issue.String()))
+	}
+	return nil
+}
+
+// initSecrets enables deployment secret injection when configured
+func initSecrets() {
+	if config.GetEnvOrDefault("QLP_ENABLE_SECRET_INJECTION", "false") == "true" {
+		if _, err := secrets.InitFromEnv(); err != nil {
+			logger.Logger.Warn("Secret injection disabled", zap.Error(err))
+		}
+	}
+}
+
+// runtime is the orchestrator and the optional subsystems enabled in the
+// environment, shared by the commands that process intents
+type runtime struct {
+	ctx        context.Context
+	orch       *orchestrator.Orchestrator
+	workspaces *workspace.Store
+	closers    []func()
+}
+
+func (rt *runtime) onClose(fn func()) {
+	rt.closers = append(rt.closers, fn)
+}
+
+// Close releases the runtime in reverse order of setup
+func (rt *runtime) Close() {
+	for i := len(rt.closers) - 1; i >= 0; i-- {
+		rt.closers[i]()
+	}
+}
+
+// openWorkspaces returns the workspace store, opening it on first use when
+// QLP_ENABLE_WORKSPACES is off but a command names a workspace
+func (rt *runtime) openWorkspaces() (*workspace.Store, error) {
+	if rt.workspaces == nil {
+		store, err := workspace.NewStoreFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		rt.workspaces = store
+		rt.orch.SetWorkspaceStore(store)
+	}
+	return rt.workspaces, nil
+}
+
+// startRuntime wires the orchestrator with the subsystems enabled in the
+// environment and starts the metrics server and shutdown handling
+func startRuntime() *runtime {
 	logger.Logger.Info("Starting QuantumLayer Universal Agent Orchestration System")
 	
-	fmt.Println("🚀 QuantumLayer Universal Agent Orchestration System")
-	fmt.Println("============================================")
-	fmt.Println()
+	fmt.Fprintln(console, "🚀 QuantumLayer Universal Agent Orchestration System")
+	fmt.Fprintln(console, "============================================")
+	fmt.Fprintln(console)
 
 	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	rt := &runtime{closers: []func(){cancel}}
+
+	shutdownTracing, err := tracing.Init(ctx, config.GetEnvOrDefault("OTEL_SERVICE_NAME", "quantumlayer"))
+	if err != nil {
+		logger.Logger.Warn("Tracing disabled", zap.Error(err))
+	} else {
+		rt.onClose(func() { shutdownTracing(context.Background()) })
+	}
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	ctx = audit.WithActor(ctx, config.GetEnvOrDefault("QLP_ACTOR", config.GetEnvOrDefault("USER", "system")))
+	if config.GetEnvOrDefault("QLP_ENABLE_AUDIT_LOGGING", "false") == "true" {
+		auditLogger, err := audit.InitFromEnv()
+		if err != nil {
+			logger.Logger.Warn("Audit logging disabled", zap.Error(err))
+		} else {
+			rt.onClose(func() { auditLogger.Close() })
+		}
+	}
+
+	initSecrets()
+
+	if _, err := constraints.InitFromEnv(); err != nil {
+		logger.Logger.Warn("Tenant constraint defaults disabled", zap.Error(err))
+	}
+
+	agentTypes, err := capabilities.InitFromEnv()
+	if err != nil {
+		logger.Logger.Warn("Custom agent types disabled", zap.Error(err))
+		agentTypes = capabilities.Default()
+	}
+
+	var promptRegistry *prompts.Registry
+	if config.GetEnvOrDefault("QLP_ENABLE_PROMPT_VERSIONING", "false") == "true" {
+		if promptRegistry, err = prompts.InitFromEnv(); err != nil {
+			logger.Logger.Warn("Prompt versioning disabled", zap.Error(err))
+		}
+	}
+
+	var agentTraces *agenttrace.Store
+	if config.GetEnvOrDefault("QLP_ENABLE_AGENT_TRACES", "false") == "true" {
+		if agentTraces, err = agenttrace.InitFromEnv(ctx); err != nil {
+			logger.Logger.Warn("Agent execution traces disabled", zap.Error(err))
+		}
+	}
+
+	var clarifier *clarify.Service
+	if config.GetEnvOrDefault("QLP_ENABLE_CLARIFICATION", "false") == "true" {
+		timeout, err := time.ParseDuration(config.GetEnvOrDefault("QLP_CLARIFICATION_TIMEOUT", "5m"))
+		if err != nil {
+			timeout = 5 * time.Minute
+		}
+		maxQuestions, err := strconv.Atoi(config.GetEnvOrDefault("QLP_CLARIFICATION_MAX_QUESTIONS", "4"))
+		if err != nil {
+			maxQuestions = 4
+		}
+		clarifier = clarify.NewService(llm.NewLLMClient(), timeout, maxQuestions)
+		// Ask on the terminal when one is attached; otherwise answers come through the API
+		if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice != 0 &&
+			config.GetEnvOrDefault("QLP_CLARIFICATION_TERMINAL", "true") == "true" {
+			clarifier.SetResponder(clarify.TerminalResponder(stdin, console))
+		}
+	}
+
+	var workspaces *workspace.Store
+	if config.GetEnvOrDefault("QLP_ENABLE_WORKSPACES", "false") == "true" {
+		if workspaces, err = workspace.NewStoreFromEnv(); err != nil {
+			logger.Logger.Warn("Workspaces disabled", zap.Error(err))
+		}
+	}
+
+	var sched *scheduler.Scheduler
+	if config.GetEnvOrDefault("QLP_ENABLE_SCHEDULER", "false") == "true" {
+		if sched, err = newScheduler(); err != nil {
+			logger.Logger.Warn("Scheduled intents disabled", zap.Error(err))
+		} else {
+			interval, err := time.ParseDuration(config.GetEnvOrDefault("QLP_SCHEDULE_INTERVAL", "30s"))
+			if err != nil || interval <= 0 {
+				interval = 30 * time.Second
+			}
+			go sched.Start(ctx, interval)
+		}
+	}
+
 	orch := orchestrator.New()
 
+	var artifactStore storage.ArtifactStore
+	if config.GetEnvOrDefault("QLP_ENABLE_METRICS", "false") == "true" {
+		port := config.GetEnvOrDefault("QLP_METRICS_PORT", "9090")
+		addr := config.GetEnvOrDefault("QLP_METRICS_HOST", "") + ":" + port
+		routes := map[string]http.Handler{
+			"/audit": tracing.HTTPMiddleware("audit", audit.Handler(audit.Default())),
+		}
+		for pattern, h := range orch.ScaleRoutes() {
+			routes[pattern] = tracing.HTTPMiddleware("scale_status", h)
+		}
+		for pattern, h := range hitl.Routes(hitl.DefaultDecisionHistory()) {
+			routes[pattern] = tracing.HTTPMiddleware("hitl_decisions", h)
+		}
+		for pattern, h := range capabilities.Routes(agentTypes) {
+			routes[pattern] = tracing.HTTPMiddleware("agent_types", h)
+		}
+		for pattern, h := range llm.Routes() {
+			routes[pattern] = tracing.HTTPMiddleware("llm_providers", h)
+		}
+		staticValidator := validation.NewStaticValidator(llm.NewLLMClient())
+		ruleStore, err := validation.NewRuleStoreFromEnv()
+		if err != nil {
+			logger.Logger.Warn("Custom validation rules disabled", zap.Error(err))
+		} else {
+			rules := validation.NewRules(ruleStore)
+			staticValidator.AddPlugin(rules)
+			for pattern, h := range validation.RuleRoutes(rules) {
+				routes[pattern] = tracing.HTTPMiddleware("validation_rules", h)
+			}
+		}
+		for pattern, h := range validation.Routes(staticValidator, validation.NewInfrastructureValidator()) {
+			routes[pattern] = tracing.HTTPMiddleware("validation", h)
+		}
+		if embedder, err := embeddings.NewFromEnv(llm.NewLLMClient()); err != nil {
+			logger.Logger.Warn("Embedding endpoint disabled", zap.Error(err))
+		} else {
+			for pattern, h := range embeddings.Routes(embedder) {
+				routes[pattern] = tracing.HTTPMiddleware("embeddings", h)
+			}
+		}
+		deploymentHistory, err := openDeploymentHistory()
+		if err != nil {
+			logger.Logger.Warn("Deployment history disabled", zap.Error(err))
+		} else {
+			for pattern, h := range deployments.Routes(deployments.New(deploymentHistory, deploymentRestorer())) {
+				routes[pattern] = tracing.HTTPMiddleware("deployments", h)
+			}
+		}
+		store, artifactRoutes, err := storage.InitFromEnv(ctx, "http://localhost:"+port)
+		if err != nil {
+			logger.Logger.Warn("Artifact storage disabled", zap.Error(err))
+		} else {
+			artifactStore = store
+			for pattern, h := range artifactRoutes {
+				routes[pattern] = tracing.HTTPMiddleware("artifacts", h)
+			}
+			var summaryClient llm.Client
+			if config.GetEnvOrDefault("QLP_CAPSULE_DIFF_LLM_SUMMARY", "true") == "true" {
+				summaryClient = llm.NewLLMClient()
+			}
+			for pattern, h := range capsulediff.Routes(store, capsulediff.NewEngine(summaryClient)) {
+				routes[pattern] = tracing.HTTPMiddleware("capsule_diff", h)
+			}
+			for pattern, h := range packaging.Routes(store) {
+				routes[pattern] = tracing.HTTPMiddleware("capsule_download", h)
+			}
+			for pattern, h := range catalog.Routes(catalog.New(store)) {
+				routes[pattern] = tracing.HTTPMiddleware("catalog", h)
+			}
+			if config.GetEnvOrDefault("QLP_ENABLE_PROMOTIONS", "false") == "true" {
+				if svc, err := newPromotionService(store, deploymentHistory); err != nil {
+					logger.Logger.Warn("Capsule promotion disabled", zap.Error(err))
+				} else {
+					for pattern, h := range promotion.Routes(svc) {
+						routes[pattern] = tracing.HTTPMiddleware("promotions", h)
+					}
+				}
+			}
+		}
+		if feeds := diagnostics.Shared(); feeds != nil {
+			var load diagnostics.Loader
+			if artifactStore != nil {
+				load = storedDiagnostics(artifactStore)
+			}
+			for pattern, h := range diagnostics.Routes(feeds, load) {
+				routes[pattern] = tracing.HTTPMiddleware("diagnostics", h)
+			}
+		}
+		var tracker *quota.Tracker
+		if config.GetEnvOrDefault("QLP_ENABLE_EXECUTION_QUOTAS", "false") == "true" {
+			if tracker, err = quota.SharedTracker(); err != nil {
+				logger.Logger.Warn("Execution usage endpoint disabled", zap.Error(err))
+			} else {
+				for pattern, h := range quota.Routes(tracker) {
+					routes[pattern] = tracing.HTTPMiddleware("execution_usage", h)
+				}
+			}
+		}
+		if analyzer := failures.Shared(); analyzer != nil {
+			for pattern, h := range failures.Routes(analyzer) {
+				routes[pattern] = tracing.HTTPMiddleware("failures", h)
+			}
+		}
+		if qualityTrends := trends.Shared(); qualityTrends != nil {
+			for pattern, h := range trends.Routes(qualityTrends) {
+				routes[pattern] = tracing.HTTPMiddleware("trends", h)
+			}
+		}
+		if meter := metering.Shared(); meter != nil {
+			if artifactStore != nil {
+				meter.SetStorageSource(storedBytes(artifactStore))
+				interval, err := time.ParseDuration(config.GetEnvOrDefault("QLP_METERING_STORAGE_INTERVAL", "1h"))
+				if err != nil || interval <= 0 {
+					interval = time.Hour
+				}
+				go meter.RunStorageSampler(ctx, interval)
+			}
+			for pattern, h := range metering.Routes(meter) {
+				routes[pattern] = tracing.HTTPMiddleware("metering", h)
+			}
+		}
+		if config.GetEnvOrDefault("QLP_ENABLE_BATCHES", "false") == "true" {
+			if svc, err := newBatchService(); err != nil {
+				logger.Logger.Warn("Batch submission disabled", zap.Error(err))
+			} else {
+				if tracker != nil {
+					svc.SetQuota(tracker)
+				}
+				for pattern, h := range batch.Routes(svc) {
+					routes[pattern] = tracing.HTTPMiddleware("batches", h)
+				}
+			}
+		}
+		if config.GetEnvOrDefault("QLP_ENABLE_GITHUB", "false") == "true" {
+			githubCfg := github.ConfigFromEnv()
+			if client, err := github.NewClientFromEnv(); err != nil {
+				logger.Logger.Warn("GitHub integration disabled", zap.Error(err))
+			} else if githubCfg.WebhookSecret == "" {
+				// Unsigned deliveries could trigger validations of any repository
+				logger.Logger.Warn("GitHub integration disabled: QLP_GITHUB_WEBHOOK_SECRET is not set")
+			} else {
+				// Pull request code is untrusted, so its tests are not run
+				analyzer := &importer.Analyzer{
+					Static:      validation.NewStaticValidator(llm.NewLLMClient()),
+					Infra:       validation.NewInfrastructureValidator(),
+					Dockerfiles: validation.NewDockerfileValidator(),
+					PowerShell:  validation.NewPowerShellValidator(),
+				}
+				for pattern, h := range github.Routes(github.NewService(client, analyzer, githubCfg)) {
+					routes[pattern] = tracing.HTTPMiddleware("github", h)
+				}
+			}
+		}
+		if sched != nil {
+			for pattern, h := range scheduler.Routes(sched) {
+				routes[pattern] = tracing.HTTPMiddleware("schedules", h)
+			}
+		}
+		if clarifier != nil {
+			for pattern, h := range clarify.Routes(clarifier.Broker()) {
+				routes[pattern] = tracing.HTTPMiddleware("clarifications", h)
+			}
+		}
+		if workspaces != nil {
+			for pattern, h := range workspace.Routes(workspaces) {
+				routes[pattern] = tracing.HTTPMiddleware("workspaces", h)
+			}
+		}
+		if promptRegistry != nil {
+			for pattern, h := range prompts.Routes(promptRegistry) {
+				routes[pattern] = tracing.HTTPMiddleware("prompts", h)
+			}
+		}
+		if agentTraces != nil {
+			for pattern, h := range agenttrace.Routes(agentTraces) {
+				routes[pattern] = tracing.HTTPMiddleware("agent_traces", h)
+			}
+		}
+		// Resolve API keys on every endpoint, whether or not tenants are
+		// administered here, before the admin ones, which authenticate
+		// with the admin token instead
+		if svc, err := newTenantService(ctx, tracker); err != nil {
+			// Without tenants API keys cannot be checked
+			logger.Logger.Error("API endpoints disabled: tenants are unavailable", zap.Error(err))
+			clear(routes)
+		} else {
+			require := tenants.KeyRequirementFromEnv()
+			for pattern, h := range routes {
+				routes[pattern] = tenants.Middleware(svc, require, pattern, h)
+			}
+			if config.GetEnvOrDefault("QLP_ENABLE_TENANT_ADMIN", "false") == "true" {
+				if token := os.Getenv("QLP_ADMIN_TOKEN"); token == "" {
+					logger.Logger.Warn("Tenant admin endpoints disabled: QLP_ADMIN_TOKEN is not set")
+				} else {
+					for pattern, h := range tenants.Routes(svc, token) {
+						routes[pattern] = tracing.HTTPMiddleware("tenant_admin", h)
+					}
+				}
+			}
+		}
+		// Deletion wipes a tenant, so it authenticates with the admin token
+		// and is registered after the API key middleware
+		if config.GetEnvOrDefault("QLP_ENABLE_TENANT_DELETION", "false") == "true" {
+			if token := os.Getenv("QLP_ADMIN_TOKEN"); token == "" {
+				logger.Logger.Warn("Tenant deletion disabled: QLP_ADMIN_TOKEN is not set")
+			} else if svc, err := newErasureService(artifactStore, ruleStore, sched, workspaces); err != nil {
+				logger.Logger.Warn("Tenant deletion disabled", zap.Error(err))
+			} else {
+				for pattern, h := range erasure.Routes(svc, token) {
+					routes[pattern] = tracing.HTTPMiddleware("tenant_deletion", h)
+				}
+			}
+		}
+		go func() {
+			if err := metrics.StartServer(ctx, addr, routes); err != nil {
+				logger.Logger.Error("Metrics server failed", zap.Error(err))
+			}
+		}()
+	}
+
+	// Enforce deployment TTLs from the resource group tags, including
+	// groups left behind by processes that exited before their cleanup
+	if config.GetEnvOrDefault("QLP_ENABLE_AZURE_CLEANUP", "false") == "true" {
+		if client, err := azure.NewAzureClient(azure.ClientConfigFromEnv()); err != nil {
+			logger.Logger.Warn("Azure cleanup controller disabled", zap.Error(err))
+		} else {
+			policy := azure.DefaultCleanupPolicy()
+			if interval, err := time.ParseDuration(config.GetEnvOrDefault("QLP_AZURE_CLEANUP_INTERVAL", "")); err == nil && interval > 0 {
+				policy.CheckInterval = interval
+			}
+			policy.DryRun = config.GetEnvOrDefault("QLP_AZURE_CLEANUP_DRY_RUN", "false") == "true"
+			go azure.NewCleanupManager(client).StartCleanupScheduler(ctx, policy)
+		}
+	}
+
+	// Compare long-lived validation environments with their capsules and
+	// record drift in the service catalog
+	if config.GetEnvOrDefault("QLP_ENABLE_AZURE_DRIFT", "false") == "true" {
+		if artifactStore == nil {
+			logger.Logger.Warn("Azure drift detector disabled: artifact storage is not enabled")
+		} else if client, err := azure.NewAzureClient(azure.ClientConfigFromEnv()); err != nil {
+			logger.Logger.Warn("Azure drift detector disabled", zap.Error(err))
+		} else {
+			policy := azure.DefaultDriftPolicy()
+			if interval, err := time.ParseDuration(config.GetEnvOrDefault("QLP_AZURE_DRIFT_INTERVAL", "")); err == nil && interval > 0 {
+				policy.CheckInterval = interval
+			}
+			if lifetime, err := time.ParseDuration(config.GetEnvOrDefault("QLP_AZURE_DRIFT_MIN_LIFETIME", "")); err == nil {
+				policy.MinLifetime = lifetime
+			}
+			policy.Remediate = config.GetEnvOrDefault("QLP_AZURE_DRIFT_REMEDIATE", "false") == "true"
+			policy.AlertWebhook = config.GetEnvOrDefault("QLP_AZURE_DRIFT_WEBHOOK", "")
+			go newDriftDetector(client, artifactStore).Start(ctx, policy)
+		}
+	}
+
+	if clarifier != nil {
+		orch.SetClarifier(clarifier)
+	}
+	if artifactStore != nil {
+		orch.SetArtifactStore(artifactStore)
+	}
+	if workspaces != nil {
+		orch.SetWorkspaceStore(workspaces)
+	}
+	orch.StartBackground(ctx)
+
 	go func() {
 		<-sigChan
-		fmt.Println("\n🛑 Shutting down QuantumLayer...")
+		drainTimeout, err := time.ParseDuration(config.GetEnvOrDefault("QLP_DRAIN_TIMEOUT", "60s"))
+		if err != nil {
+			drainTimeout = 60 * time.Second
+		}
+		fmt.Fprintf(console, "\n🛑 Shutting down QuantumLayer... draining in-flight agents (up to %s, press Ctrl+C again to force)\n", drainTimeout)
+
+		drainCtx, drainCancel := context.WithTimeout(context.Background(), drainTimeout)
+		go func() {
+			select {
+			case <-sigChan:
+				fmt.Fprintln(console, "🛑 Forcing shutdown")
+				drainCancel()
+			case <-drainCtx.Done():
+			}
+		}()
+
+		if err := orch.Drain(drainCtx); err != nil {
+			logger.Logger.Warn("Drain incomplete", zap.Error(err))
+		}
+		drainCancel()
 		cancel()
 	}()
 
-	// Check if intent provided as command line argument
-	if len(os.Args) > 1 {
-		// Use provided intent
-		intentText := strings.Join(os.Args[1:], " ")
-		if err := processSingleIntent(ctx, orch, intentText); err != nil {
-			logger.Logger.Fatal("Intent processing failed",
-				zap.Error(err),
-				zap.String("intent", intentText))
-		}
-		return
-	}
-
-	// Check for demo mode
-	if len(os.Args) == 1 {
-		fmt.Println("🎯 Choose mode:")
-		fmt.Println("1. Interactive mode (enter your intent)")
-		fmt.Println("2. Demo mode (run predefined examples)")
-		fmt.Println("3. Exit")
-		fmt.Print("\nEnter choice (1-3): ")
+	rt.ctx = ctx
+	rt.orch = orch
+	rt.workspaces = workspaces
+	return rt
+}
+
+// runMenu offers interactive or demo mode when qlp runs without arguments
+func runMenu(rt *runtime) error {
+	fmt.Println("🎯 Choose mode:")
+	fmt.Println("1. Interactive mode (enter your intent)")
+	fmt.Println("2. Demo mode (run predefined examples)")
+	fmt.Println("3. Exit")
+	fmt.Print("\nEnter choice (1-3): ")
+	
+	if stdin.Scan() {
+		choice := strings.TrimSpace(stdin.Text())
 		
-		scanner := bufio.NewScanner(os.Stdin)
-		if scanner.Scan() {
-			choice := strings.TrimSpace(scanner.Text())
-			
-			switch choice {
-			case "1":
-				if err := runInteractiveMode(ctx, orch); err != nil {
-					logger.Logger.Fatal("Interactive mode failed",
-						zap.Error(err))
-				}
-			case "2":
-				if err := runProductionDemo(ctx, orch); err != nil {
-					logger.Logger.Fatal("Demo failed",
-						zap.Error(err))
-				}
-			case "3":
-				fmt.Println("👋 Goodbye!")
-				return
-			default:
-				fmt.Println("❌ Invalid choice. Exiting...")
-				return
+		switch choice {
+		case "1":
+			if err := runInteractiveMode(rt.ctx, rt.orch); err != nil {
+				return fmt.Errorf("interactive mode failed: %w", err)
+			}
+		case "2":
+			if err := runProductionDemo(rt.ctx, rt.orch); err != nil {
+				return fmt.Errorf("demo failed: %w", err)
 			}
+		case "3":
+			fmt.Println("👋 Goodbye!")
+			return nil
+		default:
+			fmt.Println("❌ Invalid choice. Exiting...")
+			return nil
 		}
 	}
 
 	fmt.Println("\n✅ QuantumLayer session completed!")
+	return nil
 }
 
 func runProductionDemo(ctx context.Context, o *orchestrator.Orchestrator) error {
@@ -138,16 +624,204 @@ func runProductionDemo(ctx context.Context, o *orchestrator.Orchestrator) error
 	return nil
 }
 
-func processSingleIntent(ctx context.Context, o *orchestrator.Orchestrator, intentText string) error {
-	fmt.Printf("🎯 Processing Intent: %s\n", intentText)
-	fmt.Println("=" + strings.Repeat("=", len(intentText)+20))
+// newBatchService runs batch intents as qlp generate subprocesses of this
+// binary, since the orchestrator processes one intent at a time
+func newBatchService() (*batch.Service, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	maxConcurrency, err := strconv.Atoi(config.GetEnvOrDefault("QLP_BATCH_MAX_CONCURRENCY", "4"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid QLP_BATCH_MAX_CONCURRENCY: %w", err)
+	}
+	maxIntents, err := strconv.Atoi(config.GetEnvOrDefault("QLP_BATCH_MAX_INTENTS", "100"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid QLP_BATCH_MAX_INTENTS: %w", err)
+	}
+	timeout, err := time.ParseDuration(config.GetEnvOrDefault("QLP_BATCH_INTENT_TIMEOUT", "30m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid QLP_BATCH_INTENT_TIMEOUT: %w", err)
+	}
+	return batch.NewService(e2e.CommandExecutor(self), maxConcurrency, maxIntents, timeout), nil
+}
+
+// newScheduler loads the scheduled intents, run as qlp subprocesses of this binary
+func newScheduler() (*scheduler.Scheduler, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	history, err := strconv.Atoi(config.GetEnvOrDefault("QLP_SCHEDULE_HISTORY", "50"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid QLP_SCHEDULE_HISTORY: %w", err)
+	}
+	timeout, err := time.ParseDuration(config.GetEnvOrDefault("QLP_SCHEDULE_RUN_TIMEOUT", "1h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid QLP_SCHEDULE_RUN_TIMEOUT: %w", err)
+	}
+	return scheduler.New(e2e.CommandExecutor(self), config.GetEnvOrDefault("QLP_SCHEDULE_STORE", "./data/schedules.json"), history, timeout)
+}
+
+// newPromotionService promotes the capsules in store through the
+// environments of QLP_PROMOTION_ENVIRONMENTS, deploying them to Azure. A
+// capsule leaves the first environment once the catalog records it passed
+// validation. Promotion deployments are recorded in history, when set.
+func newPromotionService(store storage.ArtifactStore, history *deployments.History) (*promotion.Service, error) {
+	pipeline, err := promotion.PipelineFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	ttl, err := time.ParseDuration(config.GetEnvOrDefault("QLP_PROMOTION_TTL", "168h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid QLP_PROMOTION_TTL: %w", err)
+	}
+	timeout, err := time.ParseDuration(config.GetEnvOrDefault("QLP_PROMOTION_TIMEOUT", "2h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid QLP_PROMOTION_TIMEOUT: %w", err)
+	}
+	costLimit, err := strconv.ParseFloat(config.GetEnvOrDefault("QLP_PROMOTION_COST_LIMIT", "50"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid QLP_PROMOTION_COST_LIMIT: %w", err)
+	}
+	events, err := promotion.NewFileEventStore(config.GetEnvOrDefault("QLP_PROMOTION_HISTORY", "./data/promotions.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+
+	svc, err := promotion.New(pipeline, promotion.CapsuleLoader(storedCapsuleLoader(store)), promotionDeployer(ttl, costLimit, history), events)
+	if err != nil {
+		return nil, err
+	}
+	svc.SetTimeout(timeout)
+	svc.SetDecisionHistory(hitl.DefaultDecisionHistory())
+	services := catalog.New(store)
+	svc.SetValidationCheck(func(ctx context.Context, capsuleID string) error {
+		service, err := services.Get(ctx, capsuleID)
+		if err != nil {
+			return err
+		}
+		if service.Health.Status != catalog.StatusPassing {
+			return fmt.Errorf("validation status is %s", service.Health.Status)
+		}
+		return nil
+	})
+	return svc, nil
+}
+
+// storedDiagnostics diagnoses capsules packaged by other processes from
+// artifact storage
+func storedDiagnostics(store storage.ArtifactStore) diagnostics.Loader {
+	return func(ctx context.Context, capsuleID string) (*preview.Capsule, string, error) {
+		artifact, err := capsuleArtifact(ctx, store, capsuleID)
+		if err != nil {
+			return nil, "", err
+		}
+		data, err := readArtifact(ctx, store, artifact)
+		if err != nil {
+			return nil, "", err
+		}
+		c, err := preview.Load(ctx, data)
+		return c, artifact.TenantID, err
+	}
+}
+
+// storedBytes sums the artifacts each tenant has stored
+func storedBytes(store storage.ArtifactStore) metering.StorageSource {
+	return func(ctx context.Context) (map[string]int64, error) {
+		artifacts, err := store.ListAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+		stored := make(map[string]int64)
+		for _, a := range artifacts {
+			tenantID := a.TenantID
+			if tenantID == "" {
+				tenantID = storage.DefaultTenant
+			}
+			stored[tenantID] += a.SizeBytes
+		}
+		return stored, nil
+	}
+}
+
+// newErasureService deletes tenant data from every store this process runs
+// with. Reports are signed with QLP_DELETION_SIGNING_KEY.
+func newErasureService(store storage.ArtifactStore, rules validation.RuleStore, sched *scheduler.Scheduler, workspaces *workspace.Store) (*erasure.Service, error) {
+	reports, err := erasure.NewReportStoreFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	var targets []erasure.Target
+	// Artifacts go first: the capsules they name lead to the intents to delete
+	if store != nil {
+		targets = append(targets, erasure.ArtifactTarget{Store: store})
+	}
+	if workspaces != nil {
+		targets = append(targets, erasure.WorkspaceTarget{Store: workspaces})
+	}
+	db, err := database.New()
+	if err != nil {
+		return nil, err
+	}
+	if db.IsConnected() {
+		targets = append(targets, erasure.PostgresTarget{DB: db.GetConnection()})
+	}
+	if rules != nil {
+		targets = append(targets, erasure.RuleTarget{Store: rules})
+	}
+	if sched != nil {
+		targets = append(targets, erasure.ScheduleTarget{Scheduler: sched})
+	}
+	return erasure.NewService(reports, []byte(os.Getenv("QLP_DELETION_SIGNING_KEY")), targets...), nil
+}
+
+// newTenantService manages tenants and enforces their limits on the
+// execution quota tracker, when quotas are enabled, and on sandbox isolation
+func newTenantService(ctx context.Context, tracker *quota.Tracker) (*tenants.Service, error) {
+	svc, err := tenants.NewServiceFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	svc.OnChange(func(t tenants.Tenant) {
+		if tracker != nil {
+			tracker.SetLimit(t.ID, t.Limits.DailyMinutes)
+		}
+		sandbox.SharedIsolation().SetQuota(t.ID, sandbox.TenantQuota{
+			MaxConcurrent: t.Limits.MaxConcurrent,
+			CPU:           t.Limits.CPU,
+			MemoryMB:      t.Limits.MemoryMB,
+		})
+	})
+	if err := svc.ApplyAll(ctx); err != nil {
+		return nil, fmt.Errorf("failed to apply tenant limits: %w", err)
+	}
+	return svc, nil
+}
+
+// loadConstraints reads intent constraints from a JSON file
+func loadConstraints(path string) (*models.Constraints, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c models.Constraints
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+func processSingleIntent(ctx context.Context, o *orchestrator.Orchestrator, intentText string, intentConstraints *models.Constraints) error {
+	fmt.Fprintf(console, "🎯 Processing Intent: %s\n", intentText)
+	fmt.Fprintln(console, "=" + strings.Repeat("=", len(intentText)+20))
 	
 	startTime := time.Now()
 	
 	logger.WithComponent("main").Info("Processing single intent",
 		zap.String("intent", intentText))
 	
-	if err := o.ProcessAndExecuteIntent(ctx, intentText); err != nil {
+	if err := o.ProcessAndExecuteConstrainedIntent(ctx, intentText, intentConstraints); err != nil {
 		logger.WithComponent("main").Error("Intent processing failed",
 			zap.String("intent", intentText),
 			zap.Error(err))
@@ -155,7 +829,11 @@ func processSingleIntent(ctx context.Context, o *orchestrator.Orchestrator, inte
 	}
 	
 	duration := time.Since(startTime)
-	fmt.Printf("⏱️  Completed in %v\n", duration)
+	fmt.Fprintf(console, "⏱️  Completed in %v\n", duration)
+	if intent, _ := o.LastResult(); intent != nil && intent.Status == models.IntentStatusPartial {
+		fmt.Fprintf(console, "⚠️  Packaged without failed tasks %s; re-run them with: qlp retry-failed %s\n",
+			strings.Join(orchestrator.UnfinishedTasks(intent), ", "), intent.ID)
+	}
 	
 	logger.LogPerformance("single_intent", duration.Milliseconds(), true)
 	logger.WithComponent("main").Info("Intent processing completed",
@@ -166,13 +844,13 @@ func processSingleIntent(ctx context.Context, o *orchestrator.Orchestrator, inte
 }
 
 func runInteractiveMode(ctx context.Context, o *orchestrator.Orchestrator) error {
-	scanner := bufio.NewScanner(os.Stdin)
+	scanner := stdin
 	
 	logger.WithComponent("interactive").Info("Starting interactive mode")
 	
 	for {
 		fmt.Println("\n🎯 Interactive Mode")
-		fmt.Println("Enter your intent (or 'quit' to exit):")
+		fmt.Println("Enter your intent, 'regenerate <drop|task|file>[: requirement]', or 'quit' to exit:")
 		fmt.Print("> ")
 		
 		if !scanner.Scan() {
@@ -193,7 +871,22 @@ func runInteractiveMode(ctx context.Context, o *orchestrator.Orchestrator) error
 			break
 		}
 		
-		if err := processSingleIntent(ctx, o, intentText); err != nil {
+		if req, ok := orchestrator.ParseRegenerationCommand(intentText); ok {
+			result, err := o.Regenerate(ctx, req)
+			if err != nil {
+				fmt.Printf("❌ Regeneration failed: %v\n", err)
+				logger.WithComponent("interactive").Error("Regeneration failed",
+					zap.String("target", req.Target),
+					zap.Error(err))
+				continue
+			}
+			fmt.Printf("♻️  Regenerated tasks %v (downstream: %v)\n", result.Regenerated, result.Invalidated)
+			fmt.Printf("📦 Capsule %s: replaced drops %v, kept %v\n",
+				result.Capsule.Metadata.CapsuleID, result.ReplacedDrops, result.KeptDrops)
+			continue
+		}
+
+		if err := processSingleIntent(ctx, o, intentText, nil); err != nil {
 			fmt.Printf("❌ Error processing intent: %v\n", err)
 			fmt.Println("💡 Try again with a different intent...")
 			logger.WithComponent("interactive").Error("Interactive intent failed",